@@ -1,9 +1,12 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"os"
+	"path"
+	"sort"
 	"strings"
 
 	"github.com/openshift/ci-tools/pkg/api"
@@ -15,22 +18,52 @@ import (
 
 type tagSet map[api.ImageStreamTagReference][]*config.Info
 
+// imageReference is a reference of a configuration to an image it uses
+type imageReference struct {
+	field string
+	tag   api.ImageStreamTagReference
+	info  *config.Info
+}
+
 func main() {
-	var configDir, registryDir string
+	var configDir, registryDir, knownImagesPath string
+	var strictDeprecations, strictParameters, validateImageReferences bool
 	flag.StringVar(&configDir, "config-dir", "", "The directory containing configuration files.")
 	flag.StringVar(&registryDir, "registry", "", "Path to the step registry directory")
+	flag.BoolVar(&strictDeprecations, "strict-deprecations", false, "Fail when a configuration references a deprecated step, chain or workflow instead of warning about it")
+	flag.BoolVar(&strictParameters, "strict-parameters", false, "Fail when the registry declares unused or shadowed parameters instead of warning about them")
+	flag.BoolVar(&validateImageReferences, "validate-image-references", false, "Fail when base images are neither promoted by any configuration nor known to exist")
+	flag.StringVar(&knownImagesPath, "known-images", "", "File listing images known to exist without being promoted by any configuration, as namespace/name:tag patterns, one per line")
 	flag.Parse()
 
 	if configDir == "" {
 		fmt.Fprintln(os.Stderr, "The --config-dir flag is required but was not provided")
 		os.Exit(1)
 	}
-	resolver, err := loadResolver(registryDir)
+	resolver, problems, err := loadResolver(registryDir, strictDeprecations)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to load registry: %v\n", err)
 		os.Exit(1)
 	}
+	for _, problem := range problems {
+		level := "WARNING"
+		if strictParameters {
+			level = "ERROR"
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", level, problem)
+	}
+	if strictParameters && len(problems) > 0 {
+		os.Exit(1)
+	}
+	var knownImages []string
+	if knownImagesPath != "" {
+		if knownImages, err = loadKnownImages(knownImagesPath); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load known images: %v\n", err)
+			os.Exit(1)
+		}
+	}
 	seen := tagSet{}
+	var references []imageReference
 	if err := config.OperateOnCIOperatorConfigDir(configDir, func(configuration *api.ReleaseBuildConfiguration, repoInfo *config.Info) error {
 		// basic validation of the configuration is implicit in the iteration
 		if resolver != nil {
@@ -41,6 +74,11 @@ func main() {
 		for _, tag := range release.PromotedTags(configuration) {
 			seen[tag] = append(seen[tag], repoInfo)
 		}
+		for field, images := range map[string]map[string]api.ImageStreamTagReference{"base_images": configuration.BaseImages, "base_rpm_images": configuration.BaseRPMImages} {
+			for name, tag := range images {
+				references = append(references, imageReference{field: fmt.Sprintf("%s.%s", field, name), tag: tag, info: repoInfo})
+			}
+		}
 		return nil
 	}); err != nil {
 		fmt.Fprintf(os.Stderr, "error validating configuration files: %v\n", err)
@@ -53,17 +91,30 @@ func main() {
 		}
 		os.Exit(1)
 	}
+	if validateImageReferences {
+		if dangling := validateImages(references, seen, knownImages); len(dangling) > 0 {
+			fmt.Fprintln(os.Stderr, "references to images which are neither promoted nor known to exist found: ")
+			for _, err := range dangling {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	}
 }
 
-func loadResolver(path string) (registry.Resolver, error) {
+func loadResolver(path string, strictDeprecations bool) (registry.Resolver, []registry.ParameterProblem, error) {
 	if path == "" {
-		return nil, nil
+		return nil, nil, nil
 	}
-	refs, chains, workflows, _, _, observers, err := load.Registry(path, false)
+	refs, chains, workflows, _, _, observers, deprecations, err := load.Registry(path, false)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	problems := registry.CheckParameters(refs, chains, workflows)
+	if err := load.LocateParameterProblems(path, problems); err != nil {
+		return nil, nil, fmt.Errorf("failed to locate parameters: %w", err)
 	}
-	return registry.NewResolver(refs, chains, workflows, observers), nil
+	return registry.NewResolver(refs, chains, workflows, observers, registry.WithDeprecations(deprecations, strictDeprecations)), problems, nil
 }
 
 func validateTags(seen tagSet) []error {
@@ -84,3 +135,112 @@ func validateTags(seen tagSet) []error {
 	}
 	return dupes
 }
+
+// loadKnownImages reads the patterns of images known to exist, ignoring empty
+// lines and comments
+func loadKnownImages(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", line, err)
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+func imageName(tag api.ImageStreamTagReference) string {
+	return fmt.Sprintf("%s/%s:%s", tag.Namespace, tag.Name, tag.Tag)
+}
+
+// validateImages verifies that every referenced image is either promoted by
+// a configuration or matches a pattern of images known to exist
+func validateImages(references []imageReference, promoted tagSet, known []string) []error {
+	var promotedNames []string
+	for tag := range promoted {
+		promotedNames = append(promotedNames, imageName(tag))
+	}
+	sort.Strings(promotedNames)
+	var errs []error
+	for _, reference := range references {
+		tag := reference.tag
+		tag.As = ""
+		if _, ok := promoted[tag]; ok {
+			continue
+		}
+		name := imageName(tag)
+		var isKnown bool
+		for _, pattern := range known {
+			if matches, _ := path.Match(pattern, name); matches {
+				isKnown = true
+				break
+			}
+		}
+		if isKnown {
+			continue
+		}
+		message := fmt.Sprintf("%s: %s references %s", reference.info.Filename, reference.field, name)
+		if suggestion := closest(name, promotedNames); suggestion != "" {
+			message = fmt.Sprintf("%s, did you mean %s?", message, suggestion)
+		}
+		errs = append(errs, fmt.Errorf("%s", message))
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return errs
+}
+
+// closest finds the candidate most similar to the name, if any is similar
+// enough that the name is likely a typo of it
+func closest(name string, candidates []string) string {
+	var ret string
+	best := 3
+	for _, candidate := range candidates {
+		if d := distance(name, candidate); d < best {
+			ret, best = candidate, d
+		}
+	}
+	return ret
+}
+
+// distance is the Levenshtein distance between two strings
+func distance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func min(values ...int) int {
+	ret := values[0]
+	for _, v := range values[1:] {
+		if v < ret {
+			ret = v
+		}
+	}
+	return ret
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/config"
+)
+
+func TestValidateImages(t *testing.T) {
+	info := &config.Info{Filename: "org-repo-master.yaml"}
+	promoted := tagSet{
+		{Namespace: "ocp", Name: "4.8", Tag: "base"}:                  {info},
+		{Namespace: "ocp", Name: "4.8", Tag: "cli"}:                   {info},
+		{Namespace: "openshift", Name: "release", Tag: "golang-1.15"}: {info},
+	}
+	var testCases = []struct {
+		name       string
+		references []imageReference
+		known      []string
+		expected   []string
+	}{
+		{
+			name: "promoted images",
+			references: []imageReference{
+				{field: "base_images.base", tag: api.ImageStreamTagReference{Namespace: "ocp", Name: "4.8", Tag: "base", As: "alias"}, info: info},
+				{field: "base_rpm_images.cli", tag: api.ImageStreamTagReference{Namespace: "ocp", Name: "4.8", Tag: "cli"}, info: info},
+			},
+		},
+		{
+			name: "known images",
+			references: []imageReference{
+				{field: "base_images.centos", tag: api.ImageStreamTagReference{Namespace: "origin", Name: "centos", Tag: "8"}, info: info},
+				{field: "base_images.tools", tag: api.ImageStreamTagReference{Namespace: "ocp", Name: "4.7", Tag: "tools"}, info: info},
+			},
+			known: []string{"origin/centos:8", "ocp/4.7:*"},
+		},
+		{
+			name: "dangling references",
+			references: []imageReference{
+				{field: "base_images.base", tag: api.ImageStreamTagReference{Namespace: "ocp", Name: "4.8", Tag: "bsae"}, info: info},
+				{field: "base_images.other", tag: api.ImageStreamTagReference{Namespace: "ocp", Name: "4.6", Tag: "other"}, info: info},
+			},
+			known: []string{"ocp/4.7:*"},
+			expected: []string{
+				"org-repo-master.yaml: base_images.base references ocp/4.8:bsae, did you mean ocp/4.8:base?",
+				"org-repo-master.yaml: base_images.other references ocp/4.6:other",
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var actual []string
+			for _, err := range validateImages(testCase.references, promoted, testCase.known) {
+				actual = append(actual, err.Error())
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected errors: %s", diff)
+			}
+		})
+	}
+}
@@ -22,7 +22,10 @@ import (
 	"k8s.io/test-infra/prow/simplifypath"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/jsonschema"
 	"github.com/openshift/ci-tools/pkg/load/agents"
+	"github.com/openshift/ci-tools/pkg/registry/service"
+	"github.com/openshift/ci-tools/pkg/resolvercache"
 	"github.com/openshift/ci-tools/pkg/webreg"
 )
 
@@ -39,6 +42,9 @@ type options struct {
 	gracePeriod            time.Duration
 	validateOnly           bool
 	flatRegistry           bool
+	cacheSize              int
+	redisAddress           string
+	redisTTL               time.Duration
 	instrumentationOptions flagutil.InstrumentationOptions
 }
 
@@ -62,6 +68,9 @@ func gatherOptions() (options, error) {
 	_ = fs.Duration("cycle", time.Minute*2, "Legacy flag kept for compatibility. Does nothing")
 	fs.BoolVar(&o.validateOnly, "validate-only", false, "Load the config and registry, validate them and exit.")
 	fs.BoolVar(&o.flatRegistry, "flat-registry", false, "Disable directory structure based registry validation")
+	fs.IntVar(&o.cacheSize, "cache-size", 1000, "Number of resolved configurations to cache in memory, 0 disables the cache")
+	fs.StringVar(&o.redisAddress, "redis-address", "", "Address of a Redis server to cache resolved configurations in, shared between resolvers")
+	fs.DurationVar(&o.redisTTL, "redis-ttl", time.Hour, "How long resolved configurations are cached in Redis")
 	o.instrumentationOptions.AddFlags(fs)
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		return o, fmt.Errorf("failed to parse flags: %w", err)
@@ -101,13 +110,19 @@ func validateOptions(o options) error {
 		}
 		return fmt.Errorf("Error getting stat info for --prow-config file: %w", err)
 	}
+	if o.cacheSize < 0 {
+		return errors.New("--cache-size cannot be negative")
+	}
+	if o.redisAddress != "" && o.redisTTL < time.Millisecond {
+		return errors.New("--redis-ttl must be at least a millisecond")
+	}
 	if o.validateOnly && o.flatRegistry {
 		return errors.New("--validate-only and --flat-registry flags cannot be set simultaneously")
 	}
 	return o.instrumentationOptions.Validate(false)
 }
 
-func resolveConfig(configAgent agents.ConfigAgent, registryAgent agents.RegistryAgent) http.HandlerFunc {
+func resolveConfig(configAgent agents.ConfigAgent, registryAgent agents.RegistryAgent, cache *resolvercache.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
 			w.WriteHeader(http.StatusNotImplemented)
@@ -128,11 +143,11 @@ func resolveConfig(configAgent agents.ConfigAgent, registryAgent agents.Registry
 			logger.WithError(err).Warning("failed to get config")
 			return
 		}
-		resolveAndRespond(registryAgent, config, w, logger)
+		resolveAndRespond(registryAgent, cache, config, w, logger)
 	}
 }
 
-func resolveLiteralConfig(registryAgent agents.RegistryAgent) http.HandlerFunc {
+func resolveLiteralConfig(registryAgent agents.RegistryAgent, cache *resolvercache.Cache) http.HandlerFunc {
 	logger := logrus.NewEntry(logrus.New())
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
@@ -153,12 +168,23 @@ func resolveLiteralConfig(registryAgent agents.RegistryAgent) http.HandlerFunc {
 			_, _ = w.Write([]byte("Could not parse request body as unresolved config."))
 			return
 		}
-		resolveAndRespond(registryAgent, unresolvedConfig, w, logger)
+		resolveAndRespond(registryAgent, cache, unresolvedConfig, w, logger)
 	}
 }
 
-func resolveAndRespond(registryAgent agents.RegistryAgent, config api.ReleaseBuildConfiguration, w http.ResponseWriter, logger *logrus.Entry) {
-	config, err := registryAgent.ResolveConfig(config)
+func resolveAndRespond(registryAgent agents.RegistryAgent, cache *resolvercache.Cache, config api.ReleaseBuildConfiguration, w http.ResponseWriter, logger *logrus.Entry) {
+	revision, generation := registryAgent.GetRevision()
+	key, keyErr := resolvercache.KeyFor(config, revision, generation)
+	if keyErr != nil {
+		logger.WithError(keyErr).Warning("failed to determine cache key")
+	} else if cached, ok := cache.Get(key); ok {
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(cached); err != nil {
+			logrus.WithError(err).Error("Failed to write response")
+		}
+		return
+	}
+	config, revision, generation, err := registryAgent.ResolveConfigAtRevision(config)
 	if err != nil {
 		metrics.RecordError("failed to resolve config with registry", configresolverMetrics.ErrorRate)
 		w.WriteHeader(http.StatusBadRequest)
@@ -177,6 +203,12 @@ func resolveAndRespond(registryAgent agents.RegistryAgent, config api.ReleaseBui
 		logger.WithError(err).Errorf("failed to marshal config to JSON")
 		return
 	}
+	if keyErr == nil {
+		// the registry may have been reloaded since the lookup, so the value
+		// is cached for the registry it was resolved against
+		key.Revision, key.Generation = revision, generation
+		cache.Add(key, jsonConfig)
+	}
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write(jsonConfig); err != nil {
 		logrus.WithError(err).Error("Failed to write response")
@@ -202,6 +234,10 @@ func l(fragment string, children ...simplifypath.Node) simplifypath.Node {
 	return simplifypath.L(fragment, children...)
 }
 
+func v(fragment string, children ...simplifypath.Node) simplifypath.Node {
+	return simplifypath.V(fragment, children...)
+}
+
 func main() {
 	logrusutil.ComponentInit()
 	o, err := gatherOptions()
@@ -227,6 +263,23 @@ func main() {
 	if o.validateOnly {
 		os.Exit(0)
 	}
+
+	var stores []resolvercache.Store
+	if o.cacheSize > 0 {
+		store, err := resolvercache.NewLRU(o.cacheSize)
+		if err != nil {
+			logrus.Fatalf("Failed to create cache: %v", err)
+		}
+		stores = append(stores, store)
+	}
+	if o.redisAddress != "" {
+		store, err := resolvercache.NewRedis(o.redisAddress, o.redisTTL)
+		if err != nil {
+			logrus.Fatalf("Failed to create cache: %v", err)
+		}
+		stores = append(stores, store)
+	}
+	cache := resolvercache.New(stores...)
 	health := pjutil.NewHealthOnPort(o.instrumentationOptions.HealthPort)
 	metrics.ExposeMetrics("ci-operator-configresolver", prowConfig.PushGateway{}, flagutil.DefaultMetricsPort)
 	simplifier := simplifypath.NewSimplifier(l("", // shadow element mimicing the root
@@ -234,6 +287,17 @@ func main() {
 		l("resolve"),
 		l("configGeneration"),
 		l("registryGeneration"),
+		l("jobs"),
+		l("schemas",
+			v("name"),
+		),
+		l("registry",
+			l("components",
+				v("type",
+					v("name"),
+				),
+			),
+		),
 	))
 
 	uisimplifier := simplifypath.NewSimplifier(l("", // shadow element mimicing the root
@@ -254,10 +318,14 @@ func main() {
 	uihandler := metrics.TraceHandler(uisimplifier, configresolverMetrics.HTTPRequestDuration, configresolverMetrics.HTTPResponseSize)
 	// add handler func for incorrect paths as well; can help with identifying errors/404s caused by incorrect paths
 	http.HandleFunc("/", handler(http.HandlerFunc(http.NotFound)).ServeHTTP)
-	http.HandleFunc("/config", handler(resolveConfig(configAgent, registryAgent)).ServeHTTP)
-	http.HandleFunc("/resolve", handler(resolveLiteralConfig(registryAgent)).ServeHTTP)
+	http.HandleFunc("/config", handler(resolveConfig(configAgent, registryAgent, cache)).ServeHTTP)
+	http.HandleFunc("/resolve", handler(resolveLiteralConfig(registryAgent, cache)).ServeHTTP)
 	http.HandleFunc("/configGeneration", handler(getConfigGeneration(configAgent)).ServeHTTP)
 	http.HandleFunc("/registryGeneration", handler(getRegistryGeneration(registryAgent)).ServeHTTP)
+	http.Handle(service.ComponentsPath, handler(service.Handler(registryAgent)))
+	http.Handle(service.ComponentsPath+"/", handler(service.Handler(registryAgent)))
+	http.Handle(service.JobsPath, handler(service.JobsHandler(configAgent, registryAgent)))
+	http.Handle(jsonschema.Path, handler(jsonschema.Handler()))
 	interrupts.ListenAndServe(&http.Server{Addr: ":" + strconv.Itoa(o.port)}, o.gracePeriod)
 	uiServer := &http.Server{
 		Addr:    ":" + strconv.Itoa(o.uiPort),
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/config"
+	"github.com/openshift/ci-tools/pkg/deprecation"
+)
+
+type options struct {
+	configDir     string
+	sunsetConfig  string
+	json          bool
+	failOnExpired bool
+}
+
+func gatherOptions() options {
+	o := options{}
+	flag.StringVar(&o.configDir, "config-dir", "", "The directory containing configuration files.")
+	flag.StringVar(&o.sunsetConfig, "sunset-config", "", "A file mapping deprecated features to their announced sunset dates. Features without a date never expire.")
+	flag.BoolVar(&o.json, "json", false, "Print the usages of deprecated features as JSON.")
+	flag.BoolVar(&o.failOnExpired, "fail-on-expired", false, "Exit with an error if any configuration uses a feature past its sunset date.")
+	flag.Parse()
+	return o
+}
+
+// usage is a deprecated feature used by a configuration file
+type usage struct {
+	Config string `json:"config"`
+	deprecation.Warning
+}
+
+func main() {
+	o := gatherOptions()
+	if o.configDir == "" {
+		fmt.Fprintln(os.Stderr, "The --config-dir flag is required but was not provided")
+		os.Exit(1)
+	}
+	sunsets := deprecation.Sunsets{}
+	if o.sunsetConfig != "" {
+		var err error
+		if sunsets, err = deprecation.LoadSunsets(o.sunsetConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "error loading sunset dates: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	now := time.Now()
+	var usages []usage
+	if err := config.OperateOnCIOperatorConfigDir(o.configDir, func(configuration *api.ReleaseBuildConfiguration, info *config.Info) error {
+		for _, warning := range deprecation.Check(configuration, sunsets, now) {
+			usages = append(usages, usage{Config: info.RelativePath(), Warning: warning})
+		}
+		return nil
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "error loading configuration files: %v\n", err)
+		os.Exit(1)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Config != usages[j].Config {
+			return usages[i].Config < usages[j].Config
+		}
+		return usages[i].Path < usages[j].Path
+	})
+
+	expired := 0
+	for _, u := range usages {
+		if u.Expired {
+			expired++
+		}
+	}
+	if o.json {
+		raw, err := json.MarshalIndent(usages, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error marshalling usages: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(raw))
+	} else {
+		for _, u := range usages {
+			fmt.Printf("%s: %s\n", u.Config, u.Warning)
+		}
+		fmt.Fprintf(os.Stderr, "%d usages of deprecated features found, %d past their sunset date\n", len(usages), expired)
+	}
+	if o.failOnExpired && expired > 0 {
+		os.Exit(1)
+	}
+}
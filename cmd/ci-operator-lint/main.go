@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/config"
+	"github.com/openshift/ci-tools/pkg/lint"
+	"github.com/openshift/ci-tools/pkg/load"
+	"github.com/openshift/ci-tools/pkg/registry"
+)
+
+type options struct {
+	configDir   string
+	registryDir string
+	fix         bool
+	json        bool
+}
+
+func gatherOptions() options {
+	o := options{}
+	flag.StringVar(&o.configDir, "config-dir", "", "The directory containing configuration files.")
+	flag.StringVar(&o.registryDir, "registry", "", "Path to the step registry directory. When set, literal steps are compared to the registry steps.")
+	flag.BoolVar(&o.fix, "fix", false, "Rewrite configuration files in place to fix the findings which can be fixed automatically.")
+	flag.BoolVar(&o.json, "json", false, "Print the findings as JSON.")
+	flag.Parse()
+	return o
+}
+
+// result is a finding in a configuration file
+type result struct {
+	Config string `json:"config"`
+	lint.Finding
+	Fixed bool `json:"fixed,omitempty"`
+}
+
+func main() {
+	o := gatherOptions()
+	if o.configDir == "" {
+		fmt.Fprintln(os.Stderr, "The --config-dir flag is required but was not provided")
+		os.Exit(1)
+	}
+	var steps registry.ReferenceByName
+	if o.registryDir != "" {
+		var err error
+		if steps, _, _, _, _, _, _, err = load.Registry(o.registryDir, false); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load registry: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	linter := lint.NewLinter(steps)
+
+	var results []result
+	var toCommit []config.DataWithInfo
+	if err := config.OperateOnCIOperatorConfigDir(o.configDir, func(configuration *api.ReleaseBuildConfiguration, info *config.Info) error {
+		findings := linter.Lint(configuration)
+		for _, finding := range findings {
+			results = append(results, result{Config: info.RelativePath(), Finding: finding, Fixed: o.fix && finding.Fixable})
+		}
+		if o.fix && lint.Fix(configuration, findings) > 0 {
+			toCommit = append(toCommit, config.DataWithInfo{Configuration: *configuration, Info: *info})
+		}
+		return nil
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "error loading configuration files: %v\n", err)
+		os.Exit(1)
+	}
+	for _, output := range toCommit {
+		if err := output.CommitTo(o.configDir); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing fixed configuration: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	remaining := 0
+	for _, r := range results {
+		if !r.Fixed {
+			remaining++
+		}
+	}
+	if o.json {
+		raw, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error marshalling findings: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(raw))
+	} else {
+		for _, r := range results {
+			line := fmt.Sprintf("%s: %s", r.Config, r.Finding)
+			switch {
+			case r.Fixed:
+				line += " (fixed)"
+			case r.Fixable:
+				line += " (fixable with --fix)"
+			}
+			fmt.Println(line)
+		}
+		fmt.Fprintf(os.Stderr, "%d findings, %d fixed\n", len(results), len(results)-remaining)
+	}
+	if remaining > 0 {
+		os.Exit(1)
+	}
+}
@@ -125,6 +125,9 @@ func generateJobsToDir(dir string) func(configSpec *cioperatorapi.ReleaseBuildCo
 			pInfo.Config = *repoConfig
 		}
 
+		if configSpec.Tests, err = cioperatorapi.ExpandTestMatrices(configSpec.Tests); err != nil {
+			return fmt.Errorf("could not expand test matrices: %w", err)
+		}
 		return jc.WriteToDir(dir, info.Org, info.Repo, prowgen.GenerateJobs(configSpec, pInfo))
 	}
 }
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/steps"
+	"github.com/openshift/ci-tools/pkg/telemetry"
+)
+
+const daemonUsage = `Execute jobs submitted over HTTP concurrently in one process.
+
+Usage: ci-operator daemon [flags] [-- CI-OPERATOR-FLAGS...]
+
+A job is submitted by POSTing {"job_spec": JOB_SPEC, "args": [FLAGS...]} to
+/jobs, where JOB_SPEC is what Prow passes in $JOB_SPEC and the flags are
+added to the flags given after "--" for every job. Only flags selecting what
+the job runs may be submitted: %s.
+Up to --max-concurrent-jobs are executed at a time, the others are queued.
+Every job runs in the namespace it would run in on its own and writes its
+artifacts and log to its own directory below --work-dir.
+
+Every request must carry "Authorization: Bearer TOKEN" with the token from
+--token-file. Requests with the token from --viewer-token-file may only list
+and show jobs and their logs.
+
+  GET    /jobs          list the jobs
+  POST   /jobs          submit a job
+  GET    /jobs/ID       show the state of a job
+  GET    /jobs/ID/log   show the log of a job
+  DELETE /jobs/ID       cancel a job
+
+`
+
+// daemonJobLog is the log of a job in its directory
+const daemonJobLog = "ci-operator.log"
+
+// daemonJobFlags are the flags of ci-operator a submitted job may set, the
+// others could make the daemon read its own credentials or write to files
+// outside of the directory of the job and may only be set after "--"
+var daemonJobFlags = []string{
+	"build-all-images",
+	"delete-pipeline-images",
+	"dependency-override-param",
+	"input-hash",
+	"payload-override",
+	"post-steps-grace-period",
+	"promote",
+	"target",
+	"unschedulable-timeout",
+}
+
+// daemonTokens are the bearer tokens requests to the daemon are authorized
+// with: the admin token may submit and cancel jobs, the viewer token may
+// only read them
+type daemonTokens struct {
+	admin  string
+	viewer string
+}
+
+type daemonJobState string
+
+const (
+	daemonJobQueued    daemonJobState = "queued"
+	daemonJobRunning   daemonJobState = "running"
+	daemonJobSucceeded daemonJobState = "succeeded"
+	daemonJobFailed    daemonJobState = "failed"
+)
+
+// daemonJobRequest is the job submitted to the daemon
+type daemonJobRequest struct {
+	JobSpec json.RawMessage `json:"job_spec"`
+	Args    []string        `json:"args,omitempty"`
+}
+
+// daemonJob is the state of a job submitted to the daemon
+type daemonJob struct {
+	ID          string         `json:"id"`
+	Job         string         `json:"job"`
+	State       daemonJobState `json:"state"`
+	Cancelled   bool           `json:"cancelled,omitempty"`
+	Submitted   time.Time      `json:"submitted"`
+	Started     *time.Time     `json:"started,omitempty"`
+	Finished    *time.Time     `json:"finished,omitempty"`
+	Errors      []string       `json:"errors,omitempty"`
+	ArtifactDir string         `json:"artifact_dir"`
+
+	cancel context.CancelFunc
+}
+
+// jobRunner executes a job with its artifacts and log in the directory
+type jobRunner func(ctx context.Context, spec *api.JobSpec, args []string, dir string) []error
+
+// jobDaemon queues the submitted jobs and executes them concurrently
+type jobDaemon struct {
+	ctx      context.Context
+	workDir  string
+	baseArgs []string
+	slots    chan struct{}
+	tokens   daemonTokens
+	run      jobRunner
+
+	lock sync.RWMutex
+	jobs map[string]*daemonJob
+	ids  []string
+	wg   sync.WaitGroup
+}
+
+func newJobDaemon(ctx context.Context, workDir string, baseArgs []string, maxConcurrent int, tokens daemonTokens, run jobRunner) *jobDaemon {
+	return &jobDaemon{
+		ctx:      ctx,
+		workDir:  workDir,
+		baseArgs: baseArgs,
+		slots:    make(chan struct{}, maxConcurrent),
+		tokens:   tokens,
+		run:      run,
+		jobs:     map[string]*daemonJob{},
+	}
+}
+
+// daemon implements the `ci-operator daemon` subcommand and returns the exit
+// code for the process
+func daemon(args []string, out io.Writer) int {
+	var listen, workDir, tokenFile, viewerTokenFile string
+	var maxConcurrent int
+	flagSet := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(out, daemonUsage, strings.Join(daemonJobFlags, ", "))
+		flagSet.SetOutput(out)
+		flagSet.PrintDefaults()
+	}
+	flagSet.StringVar(&listen, "listen", "127.0.0.1:8080", "Address to serve the job API on.")
+	flagSet.StringVar(&tokenFile, "token-file", "", "File holding the bearer token requests to the job API must carry.")
+	flagSet.StringVar(&viewerTokenFile, "viewer-token-file", "", "File holding a bearer token which may only list and show jobs.")
+	flagSet.StringVar(&workDir, "work-dir", "", "Directory below which every job gets a directory for its artifacts and log.")
+	flagSet.IntVar(&maxConcurrent, "max-concurrent-jobs", 4, "How many jobs are executed at a time.")
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+	if workDir == "" {
+		fmt.Fprintln(out, "error: --work-dir is required")
+		return 2
+	}
+	if maxConcurrent < 1 {
+		fmt.Fprintln(out, "error: --max-concurrent-jobs must be positive")
+		return 2
+	}
+	if tokenFile == "" {
+		fmt.Fprintln(out, "error: --token-file is required")
+		return 2
+	}
+	var tokens daemonTokens
+	for path, token := range map[string]*string{tokenFile: &tokens.admin, viewerTokenFile: &tokens.viewer} {
+		if path == "" {
+			continue
+		}
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(out, "error: could not read the token: %v\n", err)
+			return 1
+		}
+		if *token = strings.TrimSpace(string(raw)); *token == "" {
+			fmt.Fprintf(out, "error: the token in %s is empty\n", path)
+			return 1
+		}
+	}
+	logrus.SetFormatter(steps.HumanFormatter{})
+	if err := addSchemes(); err != nil {
+		fmt.Fprintf(out, "error: failed to set up scheme: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	d := newJobDaemon(ctx, workDir, flagSet.Args(), maxConcurrent, tokens, executeDaemonJob)
+	server := &http.Server{Addr: listen, Handler: d}
+	go func() {
+		<-ctx.Done()
+		logrus.Info("Shutting down, cancelling running jobs...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logrus.WithError(err).Warn("Could not shut down the server.")
+		}
+	}()
+	logrus.Infof("Serving the job API on %s", listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(out, "error: %v\n", err)
+		return 1
+	}
+	d.wg.Wait()
+	return 0
+}
+
+// executeDaemonJob executes a job in this process like ci-operator executes
+// it in its own, with the artifacts and log of the job in its directory
+func executeDaemonJob(ctx context.Context, spec *api.JobSpec, args []string, dir string) []error {
+	artifactDir := filepath.Join(dir, "artifacts")
+	if err := os.MkdirAll(artifactDir, 0755); err != nil {
+		return []error{fmt.Errorf("could not create the artifacts directory: %w", err)}
+	}
+	logFile, err := os.Create(filepath.Join(dir, daemonJobLog))
+	if err != nil {
+		return []error{fmt.Errorf("could not create the log: %w", err)}
+	}
+	defer logFile.Close()
+	logger := logrus.New()
+	logger.SetOutput(logFile)
+	logger.SetFormatter(steps.HumanFormatter{})
+
+	flagSet := flag.NewFlagSet("job", flag.ContinueOnError)
+	flagSet.SetOutput(logFile)
+	opt := bindOptions(flagSet)
+	if err := flagSet.Parse(args); err != nil {
+		return []error{fmt.Errorf("invalid arguments: %w", err)}
+	}
+	opt.recordSetFlags(flagSet)
+	opt.jobSpec = spec
+	opt.jobContext = telemetry.WithUsage(api.WithArtifacts(steps.WithLogger(ctx, logrus.NewEntry(logger)), artifactDir))
+	defer opt.logToArtifacts()()
+	if err := opt.Complete(); err != nil {
+		opt.Report(results.ForReason(results.ReasonLoadingArgs).ForError(err))
+		return []error{err}
+	}
+	errs := opt.Run()
+	var defaulted []error
+	for _, err := range errs {
+		defaulted = append(defaulted, results.DefaultReason(err))
+	}
+	opt.Report(defaulted...)
+	return errs
+}
+
+// submit queues the job and executes it once a slot is free, returning a
+// copy of its state
+func (d *jobDaemon) submit(spec *api.JobSpec, args []string) daemonJob {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	id := strconv.Itoa(len(d.ids) + 1)
+	ctx, cancel := context.WithCancel(d.ctx)
+	job := &daemonJob{
+		ID:          id,
+		Job:         spec.Job,
+		State:       daemonJobQueued,
+		Submitted:   time.Now(),
+		ArtifactDir: filepath.Join(d.workDir, id, "artifacts"),
+		cancel:      cancel,
+	}
+	d.jobs[id] = job
+	d.ids = append(d.ids, id)
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer cancel()
+		select {
+		case d.slots <- struct{}{}:
+			defer func() { <-d.slots }()
+		case <-ctx.Done():
+			d.finish(job, []error{errors.New("the job was cancelled before it started")})
+			return
+		}
+		d.update(job, func(job *daemonJob) {
+			now := time.Now()
+			job.State, job.Started = daemonJobRunning, &now
+		})
+		logrus.Infof("Executing job %s (%s)", id, spec.Job)
+		d.finish(job, d.run(ctx, spec, append(append([]string{}, d.baseArgs...), args...), filepath.Join(d.workDir, id)))
+	}()
+	return *job
+}
+
+func (d *jobDaemon) finish(job *daemonJob, errs []error) {
+	d.update(job, func(job *daemonJob) {
+		now := time.Now()
+		job.Finished = &now
+		job.State = daemonJobSucceeded
+		if len(errs) > 0 {
+			job.State = daemonJobFailed
+		}
+		for _, err := range errs {
+			job.Errors = append(job.Errors, err.Error())
+		}
+	})
+	logrus.Infof("Job %s (%s) %s", job.ID, job.Job, job.State)
+}
+
+func (d *jobDaemon) update(job *daemonJob, mutate func(*daemonJob)) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	mutate(job)
+}
+
+// get returns a copy of the state of the job
+func (d *jobDaemon) get(id string) (daemonJob, bool) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	job, ok := d.jobs[id]
+	if !ok {
+		return daemonJob{}, false
+	}
+	return *job, true
+}
+
+func (d *jobDaemon) list() []daemonJob {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	jobs := make([]daemonJob, 0, len(d.ids))
+	for _, id := range d.ids {
+		jobs = append(jobs, *d.jobs[id])
+	}
+	return jobs
+}
+
+func (d *jobDaemon) cancel(id string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	job, ok := d.jobs[id]
+	if !ok {
+		return false
+	}
+	if job.Finished == nil {
+		job.Cancelled = true
+	}
+	job.cancel()
+	return true
+}
+
+// authorized determines whether the request carries a token which allows
+// its method
+func (d *jobDaemon) authorized(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == "" {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(d.tokens.admin)) == 1 {
+		return true
+	}
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+	return readOnly && d.tokens.viewer != "" && subtle.ConstantTimeCompare([]byte(token), []byte(d.tokens.viewer)) == 1
+}
+
+func (d *jobDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !d.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	if parts[0] != "jobs" || len(parts) > 3 {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, d.list())
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		d.handleSubmit(w, r)
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		job, ok := d.get(parts[1])
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		if !d.cancel(parts[1]) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case len(parts) == 3 && parts[2] == "log" && r.Method == http.MethodGet:
+		d.handleLog(w, r, parts[1])
+	default:
+		http.Error(w, fmt.Sprintf("%s is not supported on %s", r.Method, r.URL.Path), http.StatusMethodNotAllowed)
+	}
+}
+
+func (d *jobDaemon) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var request daemonJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("malformed request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(request.JobSpec) == 0 {
+		http.Error(w, "the job spec is required", http.StatusBadRequest)
+		return
+	}
+	spec, err := api.ParseSpec(request.JobSpec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateDaemonJobArgs(request.Args); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	select {
+	case <-d.ctx.Done():
+		http.Error(w, "the daemon is shutting down", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+	job := d.submit(spec, request.Args)
+	writeJSON(w, http.StatusCreated, job)
+}
+
+// validateDaemonJobArgs ensures the arguments of a submitted job only set
+// the flags in daemonJobFlags
+func validateDaemonJobArgs(args []string) error {
+	flagSet := flag.NewFlagSet("job", flag.ContinueOnError)
+	flagSet.SetOutput(ioutil.Discard)
+	bindOptions(flagSet)
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	if flagSet.NArg() > 0 {
+		return fmt.Errorf("invalid arguments: unexpected positional arguments %v", flagSet.Args())
+	}
+	allowed := sets.NewString(daemonJobFlags...)
+	var forbidden []string
+	flagSet.Visit(func(f *flag.Flag) {
+		if !allowed.Has(f.Name) {
+			forbidden = append(forbidden, "--"+f.Name)
+		}
+	})
+	if len(forbidden) > 0 {
+		return fmt.Errorf("flags %s may not be submitted, only %s", strings.Join(forbidden, ", "), strings.Join(daemonJobFlags, ", "))
+	}
+	return nil
+}
+
+func (d *jobDaemon) handleLog(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := d.get(id); !ok {
+		http.NotFound(w, r)
+		return
+	}
+	raw, err := ioutil.ReadFile(filepath.Join(d.workDir, id, daemonJobLog))
+	if err != nil && !os.IsNotExist(err) {
+		http.Error(w, fmt.Sprintf("could not read the log: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write(raw)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not marshal the response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(raw)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestJobDaemon(t *testing.T) {
+	workDir := t.TempDir()
+	release := map[string]chan struct{}{"first": make(chan struct{}), "second": make(chan struct{})}
+	var ran [][]string
+	run := func(ctx context.Context, spec *api.JobSpec, args []string, dir string) []error {
+		ran = append(ran, args)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return []error{err}
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, daemonJobLog), []byte("log of "+spec.Job), 0644); err != nil {
+			return []error{err}
+		}
+		select {
+		case <-release[spec.Job]:
+		case <-ctx.Done():
+			return []error{errors.New("cancelled")}
+		}
+		if spec.Job == "second" {
+			return []error{errors.New("oops")}
+		}
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := newJobDaemon(ctx, workDir, []string{"--target=unit"}, 1, daemonTokens{admin: "admin"}, run)
+	server := httptest.NewServer(d)
+	defer server.Close()
+
+	do := func(method, path, body string) (*http.Response, error) {
+		request, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Authorization", "Bearer admin")
+		return http.DefaultClient.Do(request)
+	}
+	submit := func(job string) daemonJob {
+		body := `{"job_spec": {"type": "periodic", "job": "` + job + `", "buildid": "1"}, "args": ["--target=e2e"]}`
+		response, err := do(http.MethodPost, "/jobs", body)
+		if err != nil {
+			t.Fatalf("could not submit job: %v", err)
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusCreated {
+			t.Fatalf("expected the job to be created, got %d", response.StatusCode)
+		}
+		var created daemonJob
+		if err := json.NewDecoder(response.Body).Decode(&created); err != nil {
+			t.Fatalf("could not decode job: %v", err)
+		}
+		return created
+	}
+	waitFor := func(id string, state daemonJobState) daemonJob {
+		var job daemonJob
+		if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+			job, _ = d.get(id)
+			return job.State == state, nil
+		}); err != nil {
+			t.Fatalf("job %s did not become %s, is %s", id, state, job.State)
+		}
+		return job
+	}
+
+	first, second, third := submit("first"), submit("second"), submit("third")
+	waitFor(first.ID, daemonJobRunning)
+	if job, _ := d.get(second.ID); job.State != daemonJobQueued {
+		t.Errorf("expected the second job to wait for a free slot, got %s", job.State)
+	}
+
+	if response, err := do(http.MethodDelete, "/jobs/"+third.ID, ""); err != nil || response.StatusCode != http.StatusAccepted {
+		t.Fatalf("could not cancel the third job: %v", err)
+	}
+	close(release["first"])
+	waitFor(first.ID, daemonJobSucceeded)
+	waitFor(second.ID, daemonJobRunning)
+	close(release["second"])
+	failed := waitFor(second.ID, daemonJobFailed)
+	if diff := cmp.Diff([]string{"oops"}, failed.Errors); diff != "" {
+		t.Errorf("unexpected errors: %s", diff)
+	}
+	cancelled := waitFor(third.ID, daemonJobFailed)
+	if !cancelled.Cancelled || cancelled.Started != nil {
+		t.Errorf("expected the third job to be cancelled before it started: %+v", cancelled)
+	}
+	if diff := cmp.Diff([][]string{{"--target=unit", "--target=e2e"}, {"--target=unit", "--target=e2e"}}, ran); diff != "" {
+		t.Errorf("unexpected arguments: %s", diff)
+	}
+
+	response, err := do(http.MethodGet, "/jobs/"+first.ID+"/log", "")
+	if err != nil {
+		t.Fatalf("could not get the log: %v", err)
+	}
+	defer response.Body.Close()
+	log, _ := ioutil.ReadAll(response.Body)
+	if diff := cmp.Diff("log of first", string(log)); diff != "" {
+		t.Errorf("unexpected log: %s", diff)
+	}
+	if response, err := do(http.MethodGet, "/jobs/404", ""); err != nil || response.StatusCode != http.StatusNotFound {
+		t.Errorf("expected an unknown job not to be found: %v", err)
+	}
+	if response, err := do(http.MethodPost, "/jobs", `{}`); err != nil || response.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a job without a spec to be rejected: %v", err)
+	}
+	var listed []daemonJob
+	response, err = do(http.MethodGet, "/jobs", "")
+	if err != nil {
+		t.Fatalf("could not list jobs: %v", err)
+	}
+	defer response.Body.Close()
+	if err := json.NewDecoder(response.Body).Decode(&listed); err != nil {
+		t.Fatalf("could not decode jobs: %v", err)
+	}
+	var ids []string
+	for _, job := range listed {
+		ids = append(ids, job.ID)
+	}
+	if diff := cmp.Diff([]string{first.ID, second.ID, third.ID}, ids); diff != "" {
+		t.Errorf("unexpected jobs: %s", diff)
+	}
+}
+
+func TestJobDaemonAuthorization(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	run := func(context.Context, *api.JobSpec, []string, string) []error { return nil }
+	d := newJobDaemon(ctx, t.TempDir(), nil, 1, daemonTokens{admin: "admin", viewer: "viewer"}, run)
+	server := httptest.NewServer(d)
+	defer server.Close()
+
+	spec := `{"job_spec": {"type": "periodic", "job": "job", "buildid": "1"}}`
+	for _, tc := range []struct {
+		name          string
+		authorization string
+		method        string
+		body          string
+		expected      int
+	}{
+		{name: "no token", method: http.MethodGet, expected: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer nope", method: http.MethodGet, expected: http.StatusUnauthorized},
+		{name: "token without scheme", authorization: "admin", method: http.MethodGet, expected: http.StatusUnauthorized},
+		{name: "viewer lists jobs", authorization: "Bearer viewer", method: http.MethodGet, expected: http.StatusOK},
+		{name: "viewer cannot submit", authorization: "Bearer viewer", method: http.MethodPost, body: spec, expected: http.StatusUnauthorized},
+		{name: "admin submits", authorization: "Bearer admin", method: http.MethodPost, body: spec, expected: http.StatusCreated},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(tc.method, server.URL+"/jobs", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("could not create request: %v", err)
+			}
+			if tc.authorization != "" {
+				request.Header.Set("Authorization", tc.authorization)
+			}
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer response.Body.Close()
+			if response.StatusCode != tc.expected {
+				t.Errorf("expected %d, got %d", tc.expected, response.StatusCode)
+			}
+		})
+	}
+}
+
+func TestValidateDaemonJobArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{name: "no arguments"},
+		{name: "allowed flags", args: []string{"--target=e2e", "--target", "unit", "--promote", "--input-hash=1"}},
+		{name: "credentials", args: []string{"--target=e2e", "--image-mirror-push-secret=/etc/push/.dockerconfigjson"}, expected: "flags --image-mirror-push-secret may not be submitted, only build-all-images, delete-pipeline-images, dependency-override-param, input-hash, payload-override, post-steps-grace-period, promote, target, unschedulable-timeout"},
+		{name: "positional arguments", args: []string{"--target=e2e", "extra"}, expected: "invalid arguments: unexpected positional arguments [extra]"},
+		{name: "unknown flags", args: []string{"--nope"}, expected: "invalid arguments: flag provided but not defined: -nope"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var actual string
+			if err := validateDaemonJobArgs(tc.args); err != nil {
+				actual = err.Error()
+			}
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+		})
+	}
+}
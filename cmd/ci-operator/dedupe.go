@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// Periodic jobs often run again before any of their inputs change. As the
+// namespace of a job is named after the hash of its inputs, the result of a
+// periodic is recorded on its namespace and later executions of the job with
+// identical inputs can report that result instead of running again. Only
+// successes are recorded: a failure may be caused by a flake or by something
+// outside of the inputs, so executions after it have to run.
+
+// resultAnnotationPrefix is followed by the hash of the job name, as jobs with
+// different targets may resolve to the same inputs
+const resultAnnotationPrefix = "result.ci.openshift.io/"
+
+// executionResult is the outcome of an execution of a job
+type executionResult struct {
+	Job       string    `json:"job"`
+	BuildID   string    `json:"build_id"`
+	Succeeded bool      `json:"succeeded"`
+	Finished  time.Time `json:"finished"`
+}
+
+func (r executionResult) String() string {
+	outcome := "failed"
+	if r.Succeeded {
+		outcome = "succeeded"
+	}
+	return fmt.Sprintf("build %s of %s %s at %s", r.BuildID, r.Job, outcome, r.Finished.Format(time.RFC3339))
+}
+
+func resultAnnotation(jobSpec *api.JobSpec) string {
+	return resultAnnotationPrefix + jobSpec.JobNameHash()
+}
+
+// previousResult returns the recorded success of the job in the namespace, if
+// the namespace exists and is not being deleted
+func previousResult(ctx context.Context, client ctrlruntimeclient.Client, namespace string, jobSpec *api.JobSpec) (*executionResult, error) {
+	ns := &coreapi.Namespace{}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Name: namespace}, ns); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	if ns.DeletionTimestamp != nil || ns.Status.Phase == coreapi.NamespaceTerminating {
+		return nil, nil
+	}
+	raw, ok := ns.Annotations[resultAnnotation(jobSpec)]
+	if !ok {
+		return nil, nil
+	}
+	var result executionResult
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result recorded on namespace %s: %w", namespace, err)
+	}
+	if result.Job != jobSpec.Job || !result.Succeeded {
+		return nil, nil
+	}
+	return &result, nil
+}
+
+// recordResult records a success of the job on the namespace. A failure
+// clears the result recorded for the job instead, so that the next execution
+// runs.
+func recordResult(ctx context.Context, client ctrlruntimeclient.Client, namespace string, result executionResult, jobSpec *api.JobSpec) error {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+	ns := &coreapi.Namespace{}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Name: namespace}, ns); err != nil {
+		return fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	original := ns.DeepCopy()
+	if !result.Succeeded {
+		if _, recorded := ns.Annotations[resultAnnotation(jobSpec)]; !recorded {
+			return nil
+		}
+		delete(ns.Annotations, resultAnnotation(jobSpec))
+	} else {
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Annotations[resultAnnotation(jobSpec)] = string(raw)
+	}
+	if err := client.Patch(ctx, ns, ctrlruntimeclient.MergeFrom(original)); err != nil {
+		return fmt.Errorf("failed to record result on namespace %s: %w", namespace, err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestPreviousResult(t *testing.T) {
+	now := metav1.Now()
+	finished := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	recorded := executionResult{Job: "periodic", BuildID: "1", Succeeded: true, Finished: finished}
+	var testCases = []struct {
+		name      string
+		namespace *coreapi.Namespace
+		job       string
+		record    bool
+		expected  *executionResult
+	}{
+		{
+			name:      "recorded result is returned",
+			namespace: &coreapi.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}},
+			job:       "periodic",
+			record:    true,
+			expected:  &recorded,
+		},
+		{
+			name:      "no result recorded",
+			namespace: &coreapi.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}},
+			job:       "periodic",
+		},
+		{
+			name:      "result of another job is ignored",
+			namespace: &coreapi.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", Annotations: map[string]string{resultAnnotation(&api.JobSpec{JobSpec: downwardapi.JobSpec{Job: "other"}}): `{"job":"periodic"}`}}},
+			job:       "other",
+		},
+		{
+			name: "namespace does not exist",
+			job:  "periodic",
+		},
+		{
+			name:      "recorded failure is ignored",
+			namespace: &coreapi.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", Annotations: map[string]string{resultAnnotation(&api.JobSpec{JobSpec: downwardapi.JobSpec{Job: "periodic"}}): `{"job":"periodic","build_id":"1","succeeded":false}`}}},
+			job:       "periodic",
+		},
+		{
+			name:      "result on terminating namespace is ignored",
+			namespace: &coreapi.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", DeletionTimestamp: &now}},
+			job:       "periodic",
+			record:    true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := fakectrlruntimeclient.NewFakeClient()
+			if testCase.namespace != nil {
+				client = fakectrlruntimeclient.NewFakeClient(testCase.namespace)
+			}
+			jobSpec := &api.JobSpec{JobSpec: downwardapi.JobSpec{Job: testCase.job}}
+			if testCase.record {
+				if err := recordResult(context.Background(), client, "ns", recorded, jobSpec); err != nil {
+					t.Fatalf("failed to record result: %v", err)
+				}
+			}
+			actual, err := previousResult(context.Background(), client, "ns", jobSpec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected result: %s", diff)
+			}
+		})
+	}
+}
+
+func TestRecordResult(t *testing.T) {
+	finished := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	jobSpec := &api.JobSpec{JobSpec: downwardapi.JobSpec{Job: "periodic"}}
+	var testCases = []struct {
+		name      string
+		recorded  map[string]string
+		succeeded bool
+		expected  map[string]string
+	}{
+		{
+			name:      "success is recorded",
+			succeeded: true,
+			expected:  map[string]string{resultAnnotation(jobSpec): `{"job":"periodic","build_id":"2","succeeded":true,"finished":"2021-01-02T03:04:05Z"}`},
+		},
+		{
+			name: "failure is not recorded",
+		},
+		{
+			name:     "failure clears a recorded success",
+			recorded: map[string]string{resultAnnotation(jobSpec): `{"job":"periodic","build_id":"1","succeeded":true}`, "other": "value"},
+			expected: map[string]string{"other": "value"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := fakectrlruntimeclient.NewFakeClient(&coreapi.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", Annotations: testCase.recorded}})
+			result := executionResult{Job: "periodic", BuildID: "2", Succeeded: testCase.succeeded, Finished: finished}
+			if err := recordResult(context.Background(), client, "ns", result, jobSpec); err != nil {
+				t.Fatalf("failed to record result: %v", err)
+			}
+			ns := &coreapi.Namespace{}
+			if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Name: "ns"}, ns); err != nil {
+				t.Fatalf("failed to get namespace: %v", err)
+			}
+			if diff := cmp.Diff(testCase.expected, ns.Annotations); diff != "" {
+				t.Errorf("unexpected annotations: %s", diff)
+			}
+			previous, err := previousResult(context.Background(), client, "ns", jobSpec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (previous != nil) != testCase.succeeded {
+				t.Errorf("expected a previous result to be reported: %t, got %v", testCase.succeeded, previous)
+			}
+		})
+	}
+}
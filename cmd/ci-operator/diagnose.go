@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	authapi "k8s.io/api/authorization/v1"
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imageapi "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/util"
+)
+
+const diagnoseUsage = `Check that a build farm cluster provides everything ci-operator needs.
+
+Usage: ci-operator diagnose [flags]
+
+The cluster is chosen like for a regular run: from $KUBECONFIG if set,
+otherwise from the in-cluster configuration. Every check is executed and
+a pass/fail report is printed; the exit code is non-zero if any check failed.
+
+`
+
+// diagnosticCheck verifies one prerequisite of ci-operator on a cluster
+type diagnosticCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+type diagnoseOptions struct {
+	imageStream string
+	namespace   string
+	leaseServer string
+}
+
+// diagnose implements the `ci-operator diagnose` subcommand and returns the
+// exit code for the process
+func diagnose(args []string, out io.Writer) int {
+	opts := diagnoseOptions{}
+	flagSet := flag.NewFlagSet("diagnose", flag.ContinueOnError)
+	flagSet.Usage = func() {
+		fmt.Fprint(out, diagnoseUsage)
+		flagSet.SetOutput(out)
+		flagSet.PrintDefaults()
+	}
+	flagSet.StringVar(&opts.imageStream, "image-stream", "ci/managed-clonerefs", "NAMESPACE/NAME of an image stream the build farm must be able to read.")
+	flagSet.StringVar(&opts.namespace, "namespace", "", "An existing namespace in which to check resource quota usage.")
+	flagSet.StringVar(&opts.leaseServer, "lease-server", "", "Address of the Boskos server to check connectivity to.")
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	clusterConfig, err := util.LoadClusterConfig()
+	if err != nil {
+		fmt.Fprintf(out, "error: failed to load cluster config: %v\n", err)
+		return 1
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(clusterConfig)
+	if err != nil {
+		fmt.Fprintf(out, "error: failed to construct discovery client: %v\n", err)
+		return 1
+	}
+	client, err := ctrlruntimeclient.New(clusterConfig, ctrlruntimeclient.Options{})
+	if err != nil {
+		fmt.Fprintf(out, "error: failed to construct client: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(out, "Diagnosing cluster %s\n", clusterConfig.Host)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if !runDiagnostics(ctx, out, diagnosticChecks(opts, discoveryClient, client, http.DefaultClient)) {
+		return 1
+	}
+	return 0
+}
+
+func diagnosticChecks(opts diagnoseOptions, discoveryClient discovery.DiscoveryInterface, client ctrlruntimeclient.Client, httpClient *http.Client) []diagnosticCheck {
+	checks := []diagnosticCheck{
+		{
+			name: "API server is reachable",
+			check: func(_ context.Context) error {
+				_, err := discoveryClient.ServerVersion()
+				return err
+			},
+		},
+		{name: "build controller is present", check: apiResourceCheck(discoveryClient, "build.openshift.io/v1", "builds")},
+		{name: "image API is present", check: apiResourceCheck(discoveryClient, "image.openshift.io/v1", "imagestreams")},
+		{name: "security context constraints are present", check: apiResourceCheck(discoveryClient, "security.openshift.io/v1", "securitycontextconstraints")},
+		{
+			name: fmt.Sprintf("image stream %s is reachable", opts.imageStream),
+			check: func(ctx context.Context) error {
+				parts := strings.Split(opts.imageStream, "/")
+				if len(parts) != 2 {
+					return fmt.Errorf("--image-stream must be in the form NAMESPACE/NAME, not %q", opts.imageStream)
+				}
+				return client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: parts[0], Name: parts[1]}, &imageapi.ImageStream{})
+			},
+		},
+		{name: "test namespaces can be created", check: accessCheck(client, "project.openshift.io", "projectrequests", "create")},
+		{
+			name: "restricted security context constraint exists",
+			check: func(ctx context.Context) error {
+				scc := &unstructured.Unstructured{}
+				scc.SetGroupVersionKind(schema.GroupVersionKind{Group: "security.openshift.io", Version: "v1", Kind: "SecurityContextConstraints"})
+				return client.Get(ctx, ctrlruntimeclient.ObjectKey{Name: "restricted"}, scc)
+			},
+		},
+	}
+	if opts.namespace != "" {
+		checks = append(checks, diagnosticCheck{
+			name: fmt.Sprintf("resource quota in namespace %s is not exhausted", opts.namespace),
+			check: func(ctx context.Context) error {
+				return quotaCheck(ctx, client, opts.namespace)
+			},
+		})
+	}
+	if opts.leaseServer != "" {
+		checks = append(checks, diagnosticCheck{
+			name: fmt.Sprintf("lease server %s is reachable", opts.leaseServer),
+			check: func(ctx context.Context) error {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.leaseServer, nil)
+				if err != nil {
+					return err
+				}
+				resp, err := httpClient.Do(req)
+				if err != nil {
+					return err
+				}
+				defer resp.Body.Close()
+				if resp.StatusCode >= http.StatusInternalServerError {
+					return fmt.Errorf("server responded with %s", resp.Status)
+				}
+				return nil
+			},
+		})
+	}
+	return checks
+}
+
+// runDiagnostics executes every check, prints a report and returns whether
+// all checks passed
+func runDiagnostics(ctx context.Context, out io.Writer, checks []diagnosticCheck) bool {
+	passed := true
+	for _, check := range checks {
+		if err := check.check(ctx); err != nil {
+			passed = false
+			fmt.Fprintf(out, "[FAIL] %s: %v\n", check.name, err)
+			continue
+		}
+		fmt.Fprintf(out, "[PASS] %s\n", check.name)
+	}
+	return passed
+}
+
+func apiResourceCheck(discoveryClient discovery.DiscoveryInterface, groupVersion, resource string) func(context.Context) error {
+	return func(_ context.Context) error {
+		resources, err := discoveryClient.ServerResourcesForGroupVersion(groupVersion)
+		if err != nil {
+			return fmt.Errorf("could not discover %s: %w", groupVersion, err)
+		}
+		for _, r := range resources.APIResources {
+			if r.Name == resource {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s does not serve %s", groupVersion, resource)
+	}
+}
+
+func accessCheck(client ctrlruntimeclient.Client, group, resource, verb string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		sar := &authapi.SelfSubjectAccessReview{Spec: authapi.SelfSubjectAccessReviewSpec{ResourceAttributes: &authapi.ResourceAttributes{
+			Group:    group,
+			Resource: resource,
+			Verb:     verb,
+		}}}
+		if err := client.Create(ctx, sar); err != nil {
+			return fmt.Errorf("could not review access: %w", err)
+		}
+		if !sar.Status.Allowed {
+			return fmt.Errorf("not allowed to %s %s.%s: %s", verb, resource, group, sar.Status.Reason)
+		}
+		return nil
+	}
+}
+
+func quotaCheck(ctx context.Context, client ctrlruntimeclient.Client, namespace string) error {
+	quotas := &coreapi.ResourceQuotaList{}
+	if err := client.List(ctx, quotas, ctrlruntimeclient.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("could not list resource quotas: %w", err)
+	}
+	var exhausted []string
+	for _, quota := range quotas.Items {
+		for name, hard := range quota.Status.Hard {
+			if used, ok := quota.Status.Used[name]; ok && used.Cmp(hard) >= 0 {
+				exhausted = append(exhausted, fmt.Sprintf("%s/%s (%s of %s used)", quota.Name, name, used.String(), hard.String()))
+			}
+		}
+	}
+	if len(exhausted) > 0 {
+		return fmt.Errorf("quota exhausted: %s", strings.Join(exhausted, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRunDiagnostics(t *testing.T) {
+	out := &bytes.Buffer{}
+	passed := runDiagnostics(context.Background(), out, []diagnosticCheck{
+		{name: "works", check: func(context.Context) error { return nil }},
+		{name: "breaks", check: func(context.Context) error { return errors.New("oops") }},
+		{name: "works too", check: func(context.Context) error { return nil }},
+	})
+	if passed {
+		t.Error("expected diagnostics to fail")
+	}
+	expected := "[PASS] works\n[FAIL] breaks: oops\n[PASS] works too\n"
+	if diff := cmp.Diff(expected, out.String()); diff != "" {
+		t.Errorf("unexpected report: %s", diff)
+	}
+}
+
+func TestAPIResourceCheck(t *testing.T) {
+	discoveryClient := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{{
+		GroupVersion: "build.openshift.io/v1",
+		APIResources: []metav1.APIResource{{Name: "builds"}},
+	}}}}
+	for _, tc := range []struct {
+		name         string
+		groupVersion string
+		resource     string
+		expectedErr  bool
+	}{
+		{name: "served resource", groupVersion: "build.openshift.io/v1", resource: "builds"},
+		{name: "resource missing from group", groupVersion: "build.openshift.io/v1", resource: "buildconfigs", expectedErr: true},
+		{name: "group missing", groupVersion: "image.openshift.io/v1", resource: "imagestreams", expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := apiResourceCheck(discoveryClient, tc.groupVersion, tc.resource)(context.Background())
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("expected error: %t, got: %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestQuotaCheck(t *testing.T) {
+	quota := func(used string) *coreapi.ResourceQuota {
+		return &coreapi.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "compute"},
+			Status: coreapi.ResourceQuotaStatus{
+				Hard: coreapi.ResourceList{coreapi.ResourcePods: resource.MustParse("10")},
+				Used: coreapi.ResourceList{coreapi.ResourcePods: resource.MustParse(used)},
+			},
+		}
+	}
+	for _, tc := range []struct {
+		name        string
+		objects     []runtime.Object
+		expectedErr bool
+	}{
+		{name: "no quota"},
+		{name: "quota with room", objects: []runtime.Object{quota("3")}},
+		{name: "exhausted quota", objects: []runtime.Object{quota("10")}, expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := quotaCheck(context.Background(), fakectrlruntimeclient.NewFakeClient(tc.objects...), "ci")
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("expected error: %t, got: %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
@@ -13,6 +13,9 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path"
@@ -22,19 +25,21 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/ghodss/yaml"
 	"github.com/sirupsen/logrus"
+	tektonapi "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	"go.uber.org/zap/zapcore"
 
 	appsv1 "k8s.io/api/apps/v1"
 	authapi "k8s.io/api/authorization/v1"
 	coreapi "k8s.io/api/core/v1"
 	policyv1beta1 "k8s.io/api/policy/v1beta1"
-	rbacapi "k8s.io/api/rbac/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -46,6 +51,9 @@ import (
 	"k8s.io/klog/v2"
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/config/secret"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/gcsupload"
+	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/pod-utils/downwardapi"
 	"k8s.io/test-infra/prow/version"
 	controllerruntime "sigs.k8s.io/controller-runtime"
@@ -65,15 +73,26 @@ import (
 
 	"github.com/openshift/ci-tools/pkg/api"
 	"github.com/openshift/ci-tools/pkg/api/nsttl"
+	"github.com/openshift/ci-tools/pkg/artifacts"
+	"github.com/openshift/ci-tools/pkg/cost"
 	"github.com/openshift/ci-tools/pkg/defaults"
+	"github.com/openshift/ci-tools/pkg/deprecation"
+	"github.com/openshift/ci-tools/pkg/github/status"
 	"github.com/openshift/ci-tools/pkg/interrupt"
 	"github.com/openshift/ci-tools/pkg/junit"
 	"github.com/openshift/ci-tools/pkg/lease"
+	"github.com/openshift/ci-tools/pkg/legacytemplates"
 	"github.com/openshift/ci-tools/pkg/load"
+	"github.com/openshift/ci-tools/pkg/notifier"
+	"github.com/openshift/ci-tools/pkg/quay"
 	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/signing"
 	"github.com/openshift/ci-tools/pkg/steps"
+	releasesteps "github.com/openshift/ci-tools/pkg/steps/release"
+	"github.com/openshift/ci-tools/pkg/telemetry"
 	"github.com/openshift/ci-tools/pkg/util"
 	"github.com/openshift/ci-tools/pkg/validation"
+	"github.com/openshift/ci-tools/pkg/vaultclient"
 )
 
 const usage = `Orchestrate multi-stage image-based builds
@@ -97,6 +116,10 @@ The inputs of the build (source code, tagged images, configuration) are combined
 a consistent name for the target namespace that will change if any of the inputs change.
 This allows multiple test jobs to share common artifacts and still perform retries.
 
+Run "ci-operator diagnose" to check that a cluster provides everything ci-operator
+needs before pointing jobs at it. Run "ci-operator migrate" to upgrade configuration
+files written in older versions of the schema.
+
 The standard build steps are designed for simple command-line actions (like invoking
 "make test") but can be extended by passing one or more templates via the --template flag.
 The name of the template defines the stage and the template must contain at least one
@@ -174,6 +197,15 @@ func main() {
 	// "i just doin't want spam"
 	klog.LogToStderr(false)
 	log.Printf("%s version %s", version.Name, version.Version)
+	if len(os.Args) > 1 && os.Args[1] == "diagnose" {
+		os.Exit(diagnose(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(migrate(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "daemon" {
+		os.Exit(daemon(os.Args[2:], os.Stdout))
+	}
 	flagSet := flag.NewFlagSet("", flag.ExitOnError)
 	opt := bindOptions(flagSet)
 	if err := flagSet.Parse(os.Args[1:]); err != nil {
@@ -200,6 +232,8 @@ func main() {
 		logrus.SetLevel(logrus.TraceLevel)
 		logrus.SetFormatter(&logrus.JSONFormatter{})
 		logrus.SetReportCaller(true)
+	} else {
+		logrus.SetFormatter(steps.HumanFormatter{})
 	}
 	if opt.help {
 		fmt.Print(usage)
@@ -207,21 +241,16 @@ func main() {
 		flagSet.Usage()
 		os.Exit(0)
 	}
-	flagSet.Visit(func(f *flag.Flag) {
-		switch f.Name {
-		case "delete-when-idle":
-			opt.idleCleanupDurationSet = true
-		case "delete-after":
-			opt.cleanupDurationSet = true
-		}
-	})
+	opt.recordSetFlags(flagSet)
 	if err := addSchemes(); err != nil {
 		logrus.WithError(err).Fatal("failed to set up scheme")
 	}
 
+	opt.logToArtifacts()
+
 	if err := opt.Complete(); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		opt.Report(results.ForReason("loading_args").ForError(err))
+		opt.Report(results.ForReason(results.ReasonLoadingArgs).ForError(err))
 		os.Exit(1)
 	}
 
@@ -236,6 +265,9 @@ func main() {
 			message.WriteString(fmt.Sprintf("\n  * %s", err.Error()))
 		}
 		fmt.Fprintf(os.Stderr, "error: some steps failed:%s\n", message.String())
+		if contacts := opt.contacts(); contacts != nil {
+			fmt.Fprintln(os.Stderr, contacts.Summary())
+		}
 		opt.Report(defaulted...)
 		os.Exit(1)
 	}
@@ -259,6 +291,7 @@ type options struct {
 	configSpecPath       string
 	unresolvedConfigPath string
 	templatePaths        stringSlice
+	convertTemplates     bool
 	secretDirectories    stringSlice
 	sshKeyPath           string
 	oauthTokenPath       string
@@ -269,18 +302,33 @@ type options struct {
 	verbose bool
 	help    bool
 	print   bool
+	explain bool
 
 	writeParams string
 	artifactDir string
 
 	gitRef                 string
 	namespace              string
+	reuseNamespace         string
 	baseNamespace          string
 	extraInputHash         stringSlice
 	idleCleanupDuration    time.Duration
 	idleCleanupDurationSet bool
 	cleanupDuration        time.Duration
 	cleanupDurationSet     bool
+	postStepsGracePeriod   time.Duration
+	unschedulableTimeout   time.Duration
+	kubeAPIQPS             float64
+	kubeAPIBurst           int
+	sharedImagesNamespace  string
+	buildCacheNamespace    string
+	buildCacheTTL          time.Duration
+	heartbeatInterval      time.Duration
+	heartbeatConfigMap     bool
+	progress               *steps.Progress
+	// jobContext is set when the daemon executes the job; it carries the
+	// artifacts directory and logger of the job and is cancelled with it
+	jobContext context.Context
 
 	inputHash                  string
 	secrets                    []*coreapi.Secret
@@ -310,15 +358,112 @@ type options struct {
 	pullSecretPath string
 	pullSecret     *coreapi.Secret
 
+	byoClusterSecret string
+	byoCluster       *steps.BYOClusterConfig
+
+	clusterQuotasPath   string
+	clusterUsageAddress string
+	clusterAccounting   *steps.ClusterAccounting
+
+	buildFarmsConfigPath string
+	buildFarmSelector    string
+	buildDispatcher      *steps.BuildDispatcher
+	buildBackendName     string
+	buildBackend         steps.BuildBackend
+
+	clonerefsImage    string
+	clonerefsPath     string
+	clonerefsOverride *steps.ClonerefsOverride
+
+	httpProxy       string
+	httpsProxy      string
+	noProxy         string
+	trustedCABundle string
+	proxy           *steps.ProxyConfiguration
+
+	hermeticBuildEgress stringSlice
+
+	payloadOverrideValues stringSlice
+	payloadOverrides      releasesteps.PayloadOverrides
+
+	dependencyOverrideValues stringSlice
+	dependencyOverrides      steps.DependencyOverrides
+
+	writeInputsPath  string
+	replayInputsPath string
+	replayInputs     *steps.InputsLock
+	// apiMetrics record the requests the steps of the job make to the API
+	// server
+	apiMetrics *steps.APIClientMetrics
+
+	// releaseInputs are the pull specs of the releases the replayed inputs
+	// pin, by the environment variable they would be provided in otherwise
+	releaseInputs map[string]string
+
+	// sunsetConfigPath points to the announced sunset dates of deprecated
+	// features
+	sunsetConfigPath string
+	// deprecations are the deprecated features the configuration uses
+	deprecations []deprecation.Warning
+
+	local        bool
+	localRuntime string
+	localImages  stringSlice
+	localImageTo map[string]string
+
 	pushSecretPath string
 	pushSecret     *coreapi.Secret
 
+	signingKeyPath string
+	signingSecret  *coreapi.Secret
+
+	quayTokenPath string
+	// quayClient manages the repositories images are pushed to when
+	// promoting to Quay
+	quayClient quay.Client
+
 	uploadSecretPath string
 	uploadSecret     *coreapi.Secret
 
+	artifactsBucket   string
+	s3CredentialsPath string
+	// artifactsUploader uploads the artifacts of steps as they finish
+	artifactsUploader *artifacts.Uploader
+	// artifactsCensorer censors credentials in the artifacts of the job
+	artifactsCensorer *artifacts.Censorer
+
+	github prowflagutil.GitHubOptions
+	// jobURLPrefix is where Deck renders jobs, statuses link the job there
+	jobURLPrefix string
+
+	dedupePeriodics bool
+	// deduplicated is the result of a previous execution with identical
+	// inputs that is reported instead of running the job
+	deduplicated *executionResult
+	// statusClient posts the status contexts of the configuration
+	statusClient status.Client
+	// changesClient lists the files changed by the pull requests under test
+	changesClient pullRequestChangesClient
+	// buildAllImages disables pruning unchanged images from [images]
+	buildAllImages bool
+	// deletePipelineImages enables deleting pipeline images this execution
+	// built once no remaining step requires them
+	deletePipelineImages bool
+
+	vaultAddress        string
+	vaultRoleIDPath     string
+	vaultSecretIDPath   string
+	vaultKubernetesRole string
+	// vaultClient reads the credentials steps request from Vault, its token
+	// is revoked when the job finishes
+	vaultClient *vaultclient.VaultClient
+
 	cloneAuthConfig *steps.CloneAuthConfig
 
-	resultsOptions results.Options
+	resultsOptions   results.Options
+	notifierOptions  notifier.Options
+	costOptions      cost.Options
+	telemetryOptions telemetry.Options
 }
 
 func bindOptions(flag *flag.FlagSet) *options {
@@ -336,14 +481,17 @@ func bindOptions(flag *flag.FlagSet) *options {
 	flag.StringVar(&opt.leaseServer, "lease-server", leaseServerAddress, "Address of the server that manages leases. Required if any test is configured to acquire a lease.")
 	flag.StringVar(&opt.leaseServerCredentialsFile, "lease-server-credentials-file", "", "The path to credentials file used to access the lease server. The content is of the form <username>:<password>.")
 	flag.DurationVar(&opt.leaseAcquireTimeout, "lease-acquire-timeout", leaseAcquireTimeout, "Maximum amount of time to wait for lease acquisition")
-	flag.StringVar(&opt.registryPath, "registry", "", "Path to the step registry directory")
+	flag.StringVar(&opt.registryPath, "registry", "", "Path to a local step registry checkout to resolve the configuration against, instead of the configresolver")
 	flag.StringVar(&opt.configSpecPath, "config", "", "The configuration file. If not specified the CONFIG_SPEC environment variable or the configresolver will be used.")
+	flag.StringVar(&opt.sunsetConfigPath, "sunset-config", "", "A file mapping deprecated configuration features to their announced sunset dates. Features without a date are reported without one.")
 	flag.StringVar(&opt.unresolvedConfigPath, "unresolved-config", "", "The configuration file, before resolution. If not specified the UNRESOLVED_CONFIG environment variable will be used, if set.")
 	flag.Var(&opt.targets, "target", "One or more targets in the configuration to build. Only steps that are required for this target will be run.")
 	flag.BoolVar(&opt.print, "print-graph", opt.print, "Print a directed graph of the build steps and exit. Intended for use with the golang digraph utility.")
+	flag.BoolVar(&opt.explain, "explain", opt.explain, "Print why every step of the graph does or does not run for the targets, with the dependency chain that requires it, and exit.")
 
 	// add to the graph of things we run or create
 	flag.Var(&opt.templatePaths, "template", "A set of paths to optional templates to add as stages to this job. Each template is expected to contain at least one restart=Never pod. Parameters are filled from environment or from the automatic parameters generated by the operator.")
+	flag.BoolVar(&opt.convertTemplates, "convert-templates", false, "Run the templates given with --template as multi-stage tests replacing the legacy tests they are named after, when they can be converted. A report of the conversion is written to the artifacts.")
 	flag.Var(&opt.secretDirectories, "secret-dir", "One or more directories that should converted into secrets in the test namespace. If the directory contains a single file with name .dockercfg or config.json it becomes a pull secret.")
 	flag.StringVar(&opt.sshKeyPath, "ssh-key-path", "", "A path of the private ssh key that is going to be used to clone a private repository.")
 	flag.StringVar(&opt.oauthTokenPath, "oauth-token-path", "", "A path of the OAuth token that is going to be used to clone a private repository.")
@@ -351,8 +499,18 @@ func bindOptions(flag *flag.FlagSet) *options {
 	// the target namespace and cleanup behavior
 	flag.Var(&opt.extraInputHash, "input-hash", "Add arbitrary inputs to the build input hash to make the created namespace unique.")
 	flag.StringVar(&opt.namespace, "namespace", "", "Namespace to create builds into, defaults to build_id from JOB_SPEC. If the string '{id}' is in this value it will be replaced with the build input hash.")
+	flag.StringVar(&opt.reuseNamespace, "reuse-namespace", "", "Resume a previous run by executing in its namespace. Builds whose inputs are unchanged since the previous run are reused instead of being recreated. Conflicts with --namespace.")
 	flag.StringVar(&opt.baseNamespace, "base-namespace", "stable", "Namespace to read builds from, defaults to stable.")
 	flag.DurationVar(&opt.idleCleanupDuration, "delete-when-idle", opt.idleCleanupDuration, "If no pod is running for longer than this interval, delete the namespace. Set to zero to retain the contents. Requires the namespace TTL controller to be deployed.")
+	flag.DurationVar(&opt.heartbeatInterval, "heartbeat-interval", 10*time.Minute, "How often to record the liveness, the running steps and the estimated completion of the job on the test namespace. Set to zero to only record when the namespace was last active.")
+	flag.BoolVar(&opt.heartbeatConfigMap, "heartbeat-configmap", false, "Also record the heartbeat in the "+nsttl.HeartbeatConfigMap+" ConfigMap in the test namespace.")
+	flag.DurationVar(&opt.unschedulableTimeout, "unschedulable-timeout", 0, "Fail a step once one of its pods could not be scheduled for this long, reporting the scheduler events and the capacity the pod asks for. Should exceed the time the cluster autoscaler takes to add nodes. Disabled by default, waiting until the pod starts or the step times out.")
+	flag.Float64Var(&opt.kubeAPIQPS, "kube-api-qps", 20, "Maximum rate of requests all steps together make to the API server of the build cluster.")
+	flag.IntVar(&opt.kubeAPIBurst, "kube-api-burst", 40, "Maximum burst of requests all steps together make to the API server of the build cluster.")
+	flag.StringVar(&opt.sharedImagesNamespace, "shared-images-namespace", "", "Share the images built by jobs testing the same pull requests through image streams in this namespace: builds whose inputs match a build of another job import its output instead of building it again. Jobs must be allowed to pull from the namespace; its image streams are not deleted by ci-operator.")
+	flag.StringVar(&opt.buildCacheNamespace, "build-cache-namespace", "", "Cache the images built by all jobs in an image stream in this namespace: builds of the same source tree from the same base images import the cached output instead of building it again, whichever branch the job tests. Presubmits share what they build only with other presubmits. Jobs must be allowed to pull from and tag into the namespace.")
+	flag.DurationVar(&opt.buildCacheTTL, "build-cache-ttl", 7*24*time.Hour, "Cached images older than this are rebuilt and pruned from the build cache. Set to zero to keep them forever.")
+	flag.DurationVar(&opt.postStepsGracePeriod, "post-steps-grace-period", steps.DefaultPostStepsGracePeriod, "When the job is interrupted, the test steps are cancelled and the post steps of tests keep running for this long before they are cancelled too. Set to zero to cancel post steps right away.")
 	flag.DurationVar(&opt.cleanupDuration, "delete-after", opt.cleanupDuration, "If namespace exists for longer than this interval, delete the namespace. Set to zero to retain the contents. Requires the namespace TTL controller to be deployed.")
 
 	// actions to add to the graph
@@ -376,27 +534,82 @@ func bindOptions(flag *flag.FlagSet) *options {
 
 	flag.StringVar(&opt.pullSecretPath, "image-import-pull-secret", "", "A set of dockercfg credentials used to import images for the tag_specification.")
 	flag.StringVar(&opt.pushSecretPath, "image-mirror-push-secret", "", "A set of dockercfg credentials used to mirror images for the promotion.")
+	flag.StringVar(&opt.signingKeyPath, "image-signing-key", "", "A directory with the cosign key ("+signing.PrivateKey+") and its password ("+signing.PasswordKey+") used to sign promoted images, and the public key ("+signing.PublicKey+") signed base images are verified with.")
+	flag.StringVar(&opt.quayTokenPath, "quay-api-token-path", "", "A path of the OAuth token used to create repositories and grant robot accounts access to them when promoting to Quay. The push credentials must include "+quay.Host+".")
 	flag.StringVar(&opt.uploadSecretPath, "gcs-upload-secret", "", "GCS credentials used to upload logs and artifacts.")
+	flag.StringVar(&opt.artifactsBucket, "artifacts-bucket", "", "A gs:// or s3:// bucket to upload the contents of $ARTIFACTS to whenever a step finishes, with the layout Prow uses for the job. Uploads to GCS use the credentials from --gcs-upload-secret.")
+	flag.StringVar(&opt.s3CredentialsPath, "s3-credentials-file", "", "A path of the credentials used to upload artifacts to an s3:// bucket given with --artifacts-bucket.")
+	flag.BoolVar(&opt.dedupePeriodics, "dedupe-periodics", false, "Report the result of the previous execution of a periodic job instead of running it again if its inputs did not change.")
+	flag.StringVar(&opt.vaultAddress, "vault-address", "", "Address of the Vault server to read step credentials from.")
+	flag.StringVar(&opt.vaultRoleIDPath, "vault-role-id-path", "", "A path of the role ID used to log into Vault with the AppRole auth method.")
+	flag.StringVar(&opt.vaultSecretIDPath, "vault-secret-id-path", "", "A path of the secret ID used to log into Vault with the AppRole auth method.")
+	flag.StringVar(&opt.vaultKubernetesRole, "vault-kubernetes-role", "", "The role used to log into Vault with the Kubernetes auth method, as the service account ci-operator runs as.")
+	opt.github.AddFlags(flag)
+	opt.github.AllowAnonymous = true
+	flag.StringVar(&opt.jobURLPrefix, "job-url-prefix", "https://prow.ci.openshift.org/view/", "Where Deck renders jobs. The status contexts of the configuration link the job there.")
+	flag.BoolVar(&opt.buildAllImages, "build-all-images", false, "Build every image when targeting "+steps.ImagesTarget+", regardless of the files changed by the pull requests under test.")
+	flag.BoolVar(&opt.deletePipelineImages, "delete-pipeline-images", false, "Delete the images of the pipeline image stream this execution built as soon as no remaining step requires them, instead of keeping them until the namespace is deleted. Images other executions in the namespace reuse are kept.")
+	flag.BoolVar(&opt.local, "local", false, "Run the multi-stage tests given with --target on this machine using podman or docker instead of in a namespace on the cluster.")
+	flag.StringVar(&opt.localRuntime, "local-runtime", "podman", "The container runtime to use with --local, either podman or docker.")
+	flag.Var(&opt.localImages, "local-image", "NAME=PULLSPEC of an image to use with --local for a pipeline image the job would otherwise build, like src.")
+	flag.StringVar(&opt.byoClusterSecret, "byo-cluster-kubeconfig-secret", "", "NAMESPACE/NAME of a secret holding the kubeconfig for a long-lived cluster. Multi-stage tests will target this cluster instead of installing or claiming one. The secret must be labeled "+steps.BYOClusterLabel+"=true.")
+	flag.StringVar(&opt.clusterQuotasPath, "cluster-quotas", "", "YAML file mapping org/repo or org to the number of clusters the multi-stage tests of the repositories may provision at once. Tests exceeding the quota fail instead of provisioning a cluster. Counting the clusters requires listing ClusterDeployments in all namespaces.")
+	flag.StringVar(&opt.clusterUsageAddress, "cluster-usage-address", "", "Address of the server to report how long multi-stage tests held the clusters they provisioned to.")
+	flag.StringVar(&opt.buildFarmsConfigPath, "build-farms-config", "", "If set, dispatch image builds to the least loaded of the build farms configured in this file which has spare capacity, instead of running them on the cluster of the tests.")
+	flag.StringVar(&opt.buildFarmSelector, "build-farm-selector", "", "Label selector for the build farms from --build-farms-config builds may be dispatched to. Defaults to all of them.")
+	flag.StringVar(&opt.buildBackendName, "build-backend", string(steps.BuildBackendOpenShift), "What image builds are executed as on the cluster of the tests: openshift for OpenShift Builds or tekton for Tekton PipelineRuns running buildah, which has to be mirrored to ci/buildah:stable on the cluster.")
+	flag.StringVar(&opt.clonerefsImage, "clonerefs-image", "", "NAMESPACE/NAME:TAG of an image stream tag to take the clonerefs tool from instead of the default image or the one configured for the repository. The tool is checked to read its options like clonerefs of Prow before it is used.")
+	flag.StringVar(&opt.clonerefsPath, "clonerefs-path", "", "The path of the clonerefs tool in the image given with --clonerefs-image. Defaults to /clonerefs.")
+	flag.StringVar(&opt.httpProxy, "http-proxy", "", "If set, the proxy for HTTP requests of every build and container of the job.")
+	flag.StringVar(&opt.httpsProxy, "https-proxy", "", "If set, the proxy for HTTPS requests of every build and container of the job.")
+	flag.StringVar(&opt.noProxy, "no-proxy", "", "Comma-separated hosts and domains every build and container of the job reaches without the proxy.")
+	flag.StringVar(&opt.trustedCABundle, "trusted-ca-bundle", "", "NAMESPACE/NAME of a config map holding the complete bundle of certificate authorities to trust under the "+steps.TrustedCABundleKey+" key. The bundle replaces the trust of every container of the job.")
+	flag.Var(&opt.hermeticBuildEgress, "hermetic-build-egress", "CIDR of a network hermetic builds may reach besides the registry of the cluster, like that of a proxy or a mirror. May be passed multiple times.")
+	flag.Var(&opt.payloadOverrideValues, "payload-override", "[RELEASE:]COMPONENT=PULLSPEC of a component to replace in the payload of a release, which defaults to latest. Overrides are also read from the "+releasesteps.PayloadOverridesEnv+" environment variable, separated by commas or whitespace.")
+	flag.Var(&opt.dependencyOverrideValues, "dependency-override-param", "ENV=PULLSPEC of a dependency of multi-stage test steps to replace, by the environment variable the dependency is exposed in. Every overridden dependency must be declared by a step. Overrides are also read from the "+steps.DependencyOverridesEnv+" environment variable, separated by commas or whitespace.")
+	flag.StringVar(&opt.writeInputsPath, "write-inputs", "", "If set, record every input the job resolves (the resolved configuration, base image digests, the tag specification snapshot, release payloads and cluster profile digests) to this file.")
+	flag.StringVar(&opt.replayInputsPath, "replay-inputs", "", "If set, pin the inputs recorded with --write-inputs in this file to reproduce the job that recorded them.")
 
 	opt.resultsOptions.Bind(flag)
+	opt.notifierOptions.Bind(flag)
+	opt.costOptions.Bind(flag)
+	opt.telemetryOptions.Bind(flag)
 	return opt
 }
 
 func (o *options) Complete() error {
-	jobSpec, err := api.ResolveSpecFromEnv()
+	if o.reuseNamespace != "" && o.namespace != "" {
+		return errors.New("--reuse-namespace and --namespace are mutually exclusive")
+	}
+	if o.kubeAPIQPS <= 0 || o.kubeAPIBurst <= 0 {
+		return errors.New("--kube-api-qps and --kube-api-burst must be positive")
+	}
+	if o.postStepsGracePeriod < 0 {
+		return errors.New("--post-steps-grace-period cannot be negative")
+	}
+	if err := o.costOptions.Complete(); err != nil {
+		return fmt.Errorf("failed to load --cost-rates: %w", err)
+	}
+
+	jobSpec := o.jobSpec
+	var err error
+	if jobSpec == nil {
+		// a job spec submitted to the daemon takes precedence over $JOB_SPEC
+		jobSpec, err = api.ResolveSpecFromEnv()
+	}
 	if err != nil {
 		if len(o.gitRef) == 0 {
 			return fmt.Errorf("failed to determine job spec: no --git-ref passed and failed to resolve job spec from env: %w", err)
 		}
 		// Failed to read $JOB_SPEC but --git-ref was passed, so try that instead
-		spec, refErr := jobSpecFromGitRef(o.gitRef)
+		spec, refErr := jobSpecFromGitRef(o.logger(), o.gitRef)
 		if refErr != nil {
 			return fmt.Errorf("failed to determine job spec: failed to resolve --git-ref: %w", refErr)
 		}
 		jobSpec = spec
 	} else if len(o.gitRef) > 0 {
 		// Read from $JOB_SPEC but --git-ref was also passed, so merge them
-		spec, err := jobSpecFromGitRef(o.gitRef)
+		spec, err := jobSpecFromGitRef(o.logger(), o.gitRef)
 		if err != nil {
 			return fmt.Errorf("failed to determine job spec: failed to resolve --git-ref: %w", err)
 		}
@@ -410,27 +623,48 @@ func (o *options) Complete() error {
 	if o.unresolvedConfigPath != "" && o.configSpecPath != "" {
 		return errors.New("cannot set --config and --unresolved-config at the same time")
 	}
-	if o.unresolvedConfigPath != "" && o.resolverAddress == "" {
-		return errors.New("cannot request resolved config with --unresolved-config unless providing --resolver-address")
+	if o.unresolvedConfigPath != "" && o.resolverAddress == "" && o.registryPath == "" {
+		return errors.New("cannot request resolved config with --unresolved-config unless providing --resolver-address or --registry")
 	}
 
-	config, err := load.Config(o.configSpecPath, o.unresolvedConfigPath, o.registryPath, info)
-	if err != nil {
-		return results.ForReason("loading_config").WithError(err).Errorf("failed to load configuration: %v", err)
+	var config *api.ReleaseBuildConfiguration
+	if o.replayInputsPath != "" {
+		if o.replayInputs, err = steps.ReadInputsLock(o.replayInputsPath); err != nil {
+			return results.ForReason(results.ReasonLoadingConfig).WithError(err).Errorf("failed to load inputs to replay: %v", err)
+		}
+		if o.releaseInputs, err = o.replayInputs.ReleaseInputs(); err != nil {
+			return fmt.Errorf("could not replay inputs: %w", err)
+		}
+		config = o.replayInputs.Configuration
+	}
+	if config != nil {
+		o.logger().Printf("Using the configuration recorded in %s", o.replayInputsPath)
+	} else if config, err = load.Config(o.configSpecPath, o.unresolvedConfigPath, o.registryPath, info); err != nil {
+		return results.ForReason(results.ReasonLoadingConfig).WithError(err).Errorf("failed to load configuration: %v", err)
 	}
 	if len(o.gitRef) != 0 && config.CanonicalGoRepository != nil {
 		o.jobSpec.Refs.PathAlias = *config.CanonicalGoRepository
 	}
 	o.configSpec = config
 	if err := validation.IsValidResolvedConfiguration(o.configSpec); err != nil {
-		return results.ForReason("validating_config").ForError(err)
+		return results.ForReason(results.ReasonValidatingConfig).ForError(err)
+	}
+	sunsets := deprecation.Sunsets{}
+	if o.sunsetConfigPath != "" {
+		if sunsets, err = deprecation.LoadSunsets(o.sunsetConfigPath); err != nil {
+			return results.ForReason(results.ReasonLoadingArgs).WithError(err).Errorf("failed to load sunset dates: %v", err)
+		}
+	}
+	o.deprecations = deprecation.Check(o.configSpec, sunsets, time.Now())
+	for _, warning := range o.deprecations {
+		o.logger().Printf("warning: %s", warning)
 	}
 
 	if o.verbose {
 		config, _ := yaml.Marshal(o.configSpec)
-		log.Printf("Resolved configuration:\n%s", string(config))
+		o.logger().Printf("Resolved configuration:\n%s", string(config))
 		job, _ := json.Marshal(o.jobSpec)
-		log.Printf("Resolved job spec:\n%s", string(job))
+		o.logger().Printf("Resolved job spec:\n%s", string(job))
 	}
 
 	var refs []prowapi.Refs
@@ -440,10 +674,10 @@ func (o *options) Complete() error {
 	refs = append(refs, o.jobSpec.ExtraRefs...)
 
 	if len(refs) == 0 {
-		log.Printf("No source defined")
+		o.logger().Printf("No source defined")
 	}
 	for _, ref := range refs {
-		log.Print(summarizeRef(ref))
+		o.logger().Print(summarizeRef(ref))
 
 		for _, pull := range ref.Pulls {
 			o.authors = append(o.authors, pull.Author)
@@ -454,6 +688,85 @@ func (o *options) Complete() error {
 		return errors.New("both --ssh-key-path and --oauth-token-path are specified")
 	}
 
+	if o.byoClusterSecret != "" {
+		if o.byoCluster, err = steps.ParseBYOClusterConfig(o.byoClusterSecret); err != nil {
+			return fmt.Errorf("invalid --byo-cluster-kubeconfig-secret: %w", err)
+		}
+	}
+
+	if o.clusterQuotasPath != "" || o.clusterUsageAddress != "" {
+		o.clusterAccounting = &steps.ClusterAccounting{}
+		if o.clusterQuotasPath != "" {
+			if o.clusterAccounting.Quotas, err = steps.LoadClusterQuotas(o.clusterQuotasPath); err != nil {
+				return fmt.Errorf("invalid --cluster-quotas: %w", err)
+			}
+		}
+		if o.clusterUsageAddress != "" {
+			o.clusterAccounting.Sinks = append(o.clusterAccounting.Sinks, steps.NewHTTPClusterUsageSink(o.clusterUsageAddress))
+		}
+	}
+
+	if o.buildFarmsConfigPath != "" {
+		selector, err := labels.Parse(o.buildFarmSelector)
+		if err != nil {
+			return fmt.Errorf("invalid --build-farm-selector: %w", err)
+		}
+		farms, err := steps.LoadBuildFarms(o.buildFarmsConfigPath)
+		if err != nil {
+			return fmt.Errorf("invalid --build-farms-config: %w", err)
+		}
+		annotations := map[string]string{}
+		if o.cleanupDuration > 0 {
+			annotations[nsttl.AnnotationCleanupDurationTTL] = o.cleanupDuration.String()
+		}
+		o.buildDispatcher = steps.NewBuildDispatcher(farms, selector, annotations)
+	} else if o.buildFarmSelector != "" {
+		return errors.New("--build-farm-selector requires --build-farms-config")
+	}
+	if o.buildBackend, err = steps.ParseBuildBackend(o.buildBackendName); err != nil {
+		return fmt.Errorf("invalid --build-backend: %w", err)
+	}
+	if o.buildBackend == steps.BuildBackendTekton && o.buildFarmsConfigPath != "" {
+		return errors.New("--build-farms-config cannot be used with --build-backend=tekton")
+	}
+
+	if o.clonerefsImage != "" {
+		if o.clonerefsOverride, err = steps.ParseClonerefsOverride(o.clonerefsImage, o.clonerefsPath); err != nil {
+			return fmt.Errorf("invalid --clonerefs-image: %w", err)
+		}
+	} else if o.clonerefsPath != "" {
+		return errors.New("--clonerefs-path requires --clonerefs-image")
+	}
+
+	if o.httpProxy != "" || o.httpsProxy != "" || o.noProxy != "" || o.trustedCABundle != "" {
+		o.proxy = &steps.ProxyConfiguration{HTTPProxy: o.httpProxy, HTTPSProxy: o.httpsProxy, NoProxy: o.noProxy}
+		if o.trustedCABundle != "" {
+			if o.proxy.TrustedCABundle, err = steps.ParseTrustedCABundle(o.trustedCABundle); err != nil {
+				return fmt.Errorf("invalid --trusted-ca-bundle: %w", err)
+			}
+		}
+	}
+	for _, cidr := range o.hermeticBuildEgress.values {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid --hermetic-build-egress: %w", err)
+		}
+	}
+
+	overrides := o.payloadOverrideValues.values
+	if raw := os.Getenv(releasesteps.PayloadOverridesEnv); raw != "" {
+		overrides = append(overrides, strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })...)
+	}
+	if o.payloadOverrides, err = releasesteps.ParsePayloadOverrides(overrides); err != nil {
+		return fmt.Errorf("invalid payload overrides: %w", err)
+	}
+	dependencyOverrides := o.dependencyOverrideValues.values
+	if raw := os.Getenv(steps.DependencyOverridesEnv); raw != "" {
+		dependencyOverrides = append(dependencyOverrides, strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })...)
+	}
+	if o.dependencyOverrides, err = steps.ParseDependencyOverrides(dependencyOverrides); err != nil {
+		return fmt.Errorf("invalid dependency overrides: %w", err)
+	}
+
 	var cloneAuthSecretPath string
 	if len(o.oauthTokenPath) > 0 {
 		cloneAuthSecretPath = o.oauthTokenPath
@@ -487,6 +800,11 @@ func (o *options) Complete() error {
 		}
 		o.secrets = append(o.secrets, secret)
 	}
+	if o.replayInputs != nil {
+		for _, name := range o.replayInputs.CheckClusterProfiles(o.secrets) {
+			o.logger().Printf("warning: The content of the cluster profile secret %s changed since the inputs were recorded, the job cannot be reproduced exactly", name)
+		}
+	}
 
 	for _, path := range o.templatePaths.values {
 		contents, err := ioutil.ReadFile(path)
@@ -507,6 +825,19 @@ func (o *options) Complete() error {
 		}
 		o.templates = append(o.templates, template)
 	}
+	if o.convertTemplates && len(o.templates) != 0 {
+		var reports []legacytemplates.Report
+		o.configSpec, o.templates, reports = convertTemplates(o.configSpec, o.templates, os.LookupEnv)
+		if artifactDir, set := o.artifacts(); set && len(artifactDir) != 0 {
+			if err := legacytemplates.WriteReports(artifactDir, reports); err != nil {
+				o.logger().Printf("warning: Unable to write template conversion report: %v", err)
+			}
+		}
+	}
+
+	if o.local {
+		return o.completeLocal()
+	}
 
 	clusterConfig, err := util.LoadClusterConfig()
 	if err != nil {
@@ -522,7 +853,7 @@ func (o *options) Complete() error {
 		clusterConfig.AcceptContentTypes = "application/json"
 	}
 
-	o.clusterConfig = clusterConfig
+	o.clusterConfig = steps.WithRateLimiter(clusterConfig, float32(o.kubeAPIQPS), o.kubeAPIBurst)
 
 	if o.pullSecretPath != "" {
 		if o.pullSecret, err = getDockerConfigSecret(steps.PullSecretName, o.pullSecretPath); err != nil {
@@ -535,22 +866,218 @@ func (o *options) Complete() error {
 		}
 	}
 
+	if o.signingKeyPath != "" {
+		if o.signingSecret, err = util.SecretFromDir(o.signingKeyPath); err != nil {
+			return fmt.Errorf("could not get signing key from path %s: %w", o.signingKeyPath, err)
+		}
+		o.signingSecret.Name = api.ImageSigningKeySecret
+	}
+
+	if o.quayTokenPath != "" {
+		raw, err := ioutil.ReadFile(o.quayTokenPath)
+		if err != nil {
+			return fmt.Errorf("could not read Quay API token from path %s: %w", o.quayTokenPath, err)
+		}
+		o.quayClient = quay.NewClient(quay.DefaultAPIEndpoint, bytes.TrimSpace(raw), &http.Client{})
+	}
+
 	if o.uploadSecretPath != "" {
 		if o.uploadSecret, err = getSecret(api.GCSUploadCredentialsSecret, o.uploadSecretPath); err != nil {
 			return fmt.Errorf("could not get upload secret %s from path %s: %w", api.GCSUploadCredentialsSecret, o.uploadSecretPath, err)
 		}
 	}
+
+	if o.github.TokenPath != "" {
+		if err := o.github.Validate(false); err != nil {
+			return fmt.Errorf("invalid GitHub options: %w", err)
+		}
+		secretAgent := &secret.Agent{}
+		if err := secretAgent.Start([]string{o.github.TokenPath}); err != nil {
+			return fmt.Errorf("could not load GitHub token from path %s: %w", o.github.TokenPath, err)
+		}
+		client, err := o.github.GitHubClient(secretAgent, false)
+		if err != nil {
+			return fmt.Errorf("could not create GitHub client: %w", err)
+		}
+		o.statusClient = client
+		o.changesClient = client
+	}
+
+	if artifactDir, set := o.artifacts(); set {
+		o.artifactsCensorer = artifacts.NewCensorer(artifactDir, o.secretValues()...)
+	}
+
+	if o.artifactsBucket != "" {
+		artifactDir, set := o.artifacts()
+		if !set {
+			return errors.New("--artifacts-bucket requires $ARTIFACTS to be set")
+		}
+		o.artifactsUploader = artifacts.NewUploader(artifactDir, artifacts.Options{
+			Bucket:             o.artifactsBucket,
+			GCSCredentialsFile: o.uploadSecretPath,
+			S3CredentialsFile:  o.s3CredentialsPath,
+			Censorer:           o.artifactsCensorer,
+		}, &o.jobSpec.JobSpec)
+	}
+
+	if o.vaultAddress != "" {
+		if o.vaultClient, err = o.vaultLogin(); err != nil {
+			return fmt.Errorf("could not log into Vault: %w", err)
+		}
+	}
+	return nil
+}
+
+// secretValues lists the values of the secrets the job has access to, which
+// are censored from its artifacts
+func (o *options) secretValues() [][]byte {
+	secrets := append([]*coreapi.Secret{o.pullSecret, o.pushSecret, o.signingSecret, o.uploadSecret}, o.secrets...)
+	if o.cloneAuthConfig != nil {
+		secrets = append(secrets, o.cloneAuthConfig.Secret)
+	}
+	var values [][]byte
+	for _, secret := range secrets {
+		if secret == nil {
+			continue
+		}
+		for _, value := range secret.Data {
+			values = append(values, value)
+		}
+	}
+	if o.github.TokenPath != "" {
+		if raw, err := ioutil.ReadFile(o.github.TokenPath); err == nil {
+			values = append(values, bytes.TrimSpace(raw))
+		}
+	}
+	return values
+}
+
+// serviceAccountTokenPath is where the token of the service account a pod
+// runs as is mounted
+const serviceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+func (o *options) vaultLogin() (*vaultclient.VaultClient, error) {
+	readFile := func(path string) (string, error) {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("could not read %s: %w", path, err)
+		}
+		return string(bytes.TrimSpace(raw)), nil
+	}
+	switch {
+	case o.vaultRoleIDPath != "" && o.vaultSecretIDPath != "":
+		roleID, err := readFile(o.vaultRoleIDPath)
+		if err != nil {
+			return nil, err
+		}
+		secretID, err := readFile(o.vaultSecretIDPath)
+		if err != nil {
+			return nil, err
+		}
+		return vaultclient.NewFromAppRole(o.vaultAddress, roleID, secretID)
+	case o.vaultKubernetesRole != "":
+		token, err := readFile(serviceAccountTokenPath)
+		if err != nil {
+			return nil, err
+		}
+		return vaultclient.NewFromKubernetesAuth(o.vaultAddress, o.vaultKubernetesRole, token)
+	default:
+		return nil, errors.New("--vault-address requires either --vault-role-id-path and --vault-secret-id-path or --vault-kubernetes-role")
+	}
+}
+
+// completeLocal validates the options for running tests on this machine,
+// where no cluster configuration is needed
+func (o *options) completeLocal() error {
+	if len(o.targets.values) == 0 {
+		return errors.New("--local requires at least one --target")
+	}
+	if o.localRuntime != "podman" && o.localRuntime != "docker" {
+		return fmt.Errorf("--local-runtime must be podman or docker, not %q", o.localRuntime)
+	}
+	o.localImageTo = map[string]string{}
+	for _, image := range o.localImages.values {
+		parts := strings.SplitN(image, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("--local-image must be in the form NAME=PULLSPEC, not %q", image)
+		}
+		o.localImageTo[parts[0]] = parts[1]
+	}
 	return nil
 }
 
+// runLocal runs the targeted multi-stage tests on this machine
+func (o *options) runLocal() []error {
+	workDir := o.artifactDir
+	if workDir == "" {
+		dir, err := ioutil.TempDir("", "ci-operator-local-")
+		if err != nil {
+			return []error{fmt.Errorf("could not create working directory: %w", err)}
+		}
+		workDir = dir
+	}
+	o.logger().Printf("Running locally with %s, shared directories and artifacts are stored in %s", o.localRuntime, workDir)
+	tests := map[string]api.TestStepConfiguration{}
+	for _, test := range o.configSpec.Tests {
+		tests[test.As] = test
+	}
+	runner := steps.NewLocalTestRunner(o.configSpec, steps.NewLocalRuntime(o.localRuntime), o.localImageTo, workDir)
+	ctx, cancel := context.WithCancel(o.baseContext())
+	defer cancel()
+	var errs []error
+	for _, target := range o.targets.values {
+		test, ok := tests[target]
+		if !ok {
+			errs = append(errs, fmt.Errorf("target %s is not a test, only tests can be run locally", target))
+			continue
+		}
+		if err := runner.Run(ctx, test); err != nil {
+			errs = append(errs, results.ForReason(results.ReasonExecutingTest).WithError(err).Errorf("test %s failed: %v", target, err))
+		}
+	}
+	return errs
+}
+
+// recordSetFlags records which flags whose defaults differ from their zero
+// values were set explicitly
+func (o *options) recordSetFlags(flagSet *flag.FlagSet) {
+	flagSet.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "delete-when-idle":
+			o.idleCleanupDurationSet = true
+		case "delete-after":
+			o.cleanupDurationSet = true
+		}
+	})
+}
+
+// baseContext returns the context the job is executed in
+func (o *options) baseContext() context.Context {
+	if o.jobContext != nil {
+		return o.jobContext
+	}
+	return context.Background()
+}
+
+// logger returns the logger of the job, which is not the logger of the
+// process when the job is executed by the daemon
+func (o *options) logger() *logrus.Entry {
+	return steps.Logger(o.baseContext())
+}
+
+// artifacts returns the directory the artifacts of the job are written to
+func (o *options) artifacts() (string, bool) {
+	return api.ArtifactsFor(o.baseContext())
+}
+
 func (o *options) Report(errs ...error) {
 	if len(errs) > 0 {
 		o.writeFailingJUnit(errs)
 	}
 
-	reporter, loadErr := o.resultsOptions.Reporter(o.jobSpec, o.consoleHost)
+	reporter, loadErr := o.resultsOptions.Reporter(o.jobSpec, o.consoleHost, o.contacts())
 	if loadErr != nil {
-		log.Printf("could not load result reporting options: %v", loadErr)
+		o.logger().Printf("could not load result reporting options: %v", loadErr)
 		return
 	}
 
@@ -560,33 +1087,212 @@ func (o *options) Report(errs ...error) {
 	if len(errs) == 0 {
 		reporter.Report(nil)
 	}
+	o.telemetryOptions.Report(o.baseContext(), o.jobSpec)
+}
+
+// contacts returns the owners of the job, if the configuration was loaded
+// and declares them
+func (o *options) contacts() *api.Contacts {
+	if o.configSpec == nil {
+		return nil
+	}
+	return o.configSpec.Contacts
+}
+
+// pullRequestChangesClient lists the files changed by a pull request
+type pullRequestChangesClient interface {
+	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
+}
+
+// changedImages determines the images affected by the pull requests under
+// test when targeting [images]. All images are built when that is requested,
+// when another target needs all of them, when the job does not test pull
+// requests or when the changes cannot be listed, which is signalled by
+// returning nil.
+func (o *options) changedImages() sets.String {
+	tests := sets.NewString()
+	for _, test := range o.configSpec.Tests {
+		tests.Insert(test.As)
+	}
+	var targeted bool
+	for _, target := range o.targets.values {
+		if tests.Has(target) || strings.HasPrefix(target, "[release:") {
+			return nil
+		}
+		targeted = targeted || target == steps.ImagesTarget
+	}
+	if !targeted || o.buildAllImages {
+		return nil
+	}
+	refs := o.jobSpec.Refs
+	if refs == nil || len(refs.Pulls) == 0 {
+		o.logger().Printf("Building all images for %s, the job does not test pull requests", steps.ImagesTarget)
+		return nil
+	}
+	if o.changesClient == nil {
+		o.logger().Printf("Building all images for %s, the changed files cannot be listed without --github-token-path", steps.ImagesTarget)
+		return nil
+	}
+	var changed []string
+	for _, pull := range refs.Pulls {
+		changes, err := o.changesClient.GetPullRequestChanges(refs.Org, refs.Repo, pull.Number)
+		if err != nil {
+			o.logger().Printf("Building all images for %s, could not list the files changed by %s/%s#%d: %v", steps.ImagesTarget, refs.Org, refs.Repo, pull.Number, err)
+			return nil
+		}
+		for _, change := range changes {
+			changed = append(changed, change.Filename)
+			if change.PreviousFilename != "" {
+				changed = append(changed, change.PreviousFilename)
+			}
+		}
+	}
+	affected := defaults.ChangedImages(o.configSpec.Images, changed)
+	if affected.Len() == 0 {
+		o.logger().Printf("No images are affected by the changes, %s will not build any", steps.ImagesTarget)
+	} else {
+		o.logger().Printf("Images affected by the changes: %s", strings.Join(affected.List(), ", "))
+	}
+	return affected
+}
+
+// statusUpdateInterval limits how often status contexts are posted
+const statusUpdateInterval = 30 * time.Second
+
+// milestones starts posting the status contexts of the configuration for the
+// commit the job tests. The returned function reports the contexts that were
+// not reached and waits for the statuses to be posted. Nothing is posted if
+// no contexts are configured, no GitHub token was provided or the job does
+// not test a single commit.
+func (o *options) milestones(nodes []*api.StepNode, postSteps []api.Step) (*status.Milestones, func()) {
+	if o.statusClient == nil || len(o.configSpec.StatusContexts) == 0 {
+		return nil, nil
+	}
+	refs := o.jobSpec.Refs
+	if refs == nil {
+		return nil, nil
+	}
+	sha := refs.BaseSHA
+	switch len(refs.Pulls) {
+	case 0:
+	case 1:
+		sha = refs.Pulls[0].SHA
+	default:
+		o.logger().Printf("Not posting status contexts for a job testing %d pull requests", len(refs.Pulls))
+		return nil, nil
+	}
+	names := sets.NewString()
+	api.IterateAllEdges(nodes, func(n *api.StepNode) {
+		names.Insert(n.Step.Name())
+	})
+	for _, step := range postSteps {
+		names.Insert(step.Name())
+	}
+	updater := status.NewUpdater(o.statusClient, refs.Org, refs.Repo, sha, o.jobURL(), statusUpdateInterval)
+	milestones := status.NewMilestones(updater, o.configSpec.StatusContexts, names)
+	ctx, cancel := context.WithCancel(o.baseContext())
+	done := make(chan struct{})
+	go func() {
+		updater.Run(ctx)
+		close(done)
+	}()
+	return milestones, func() {
+		milestones.Finish()
+		cancel()
+		<-done
+	}
+}
+
+// jobURL links the page Deck renders for the job, built the way Prow builds
+// the URLs it reports for jobs. It is empty when the job is not decorated.
+func (o *options) jobURL() string {
+	spec := o.jobSpec.JobSpec
+	if o.jobURLPrefix == "" || spec.DecorationConfig == nil || spec.DecorationConfig.GCSConfiguration == nil {
+		return ""
+	}
+	prefix, err := url.Parse(o.jobURLPrefix)
+	if err != nil {
+		o.logger().Printf("warning: Invalid job URL prefix %s: %v", o.jobURLPrefix, err)
+		return ""
+	}
+	gcsConfig := spec.DecorationConfig.GCSConfiguration
+	bucket, err := prowapi.ParsePath(gcsConfig.Bucket)
+	if err != nil {
+		o.logger().Printf("warning: Invalid bucket %s: %v", gcsConfig.Bucket, err)
+		return ""
+	}
+	_, gcsPath, _ := gcsupload.PathsForJob(gcsConfig, &spec, "")
+	prefix.Path = path.Join(prefix.Path, bucket.StorageProvider(), bucket.FullPath(), gcsPath)
+	return prefix.String()
+}
+
+// notificationLinks returns the links included in notifications of failed
+// steps
+func (o *options) notificationLinks() []notifier.Link {
+	if o.consoleHost == "" {
+		return nil
+	}
+	return []notifier.Link{{Title: "Namespace", URL: fmt.Sprintf("https://%s/k8s/cluster/projects/%s", o.consoleHost, o.namespace)}}
 }
 
 func (o *options) Run() []error {
 	start := time.Now()
 	defer func() {
-		log.Printf("Ran for %s", time.Since(start).Truncate(time.Second))
+		o.logger().Printf("Ran for %s", time.Since(start).Truncate(time.Second))
 	}()
+	if o.local {
+		return o.runLocal()
+	}
+	o.apiMetrics = steps.NewAPIClientMetrics()
 	var leaseClient *lease.Client
 	if o.leaseServer != "" && o.leaseServerCredentialsFile != "" {
 		leaseClient = &o.leaseClient
 	}
+	var vault steps.VaultClient
+	if o.vaultClient != nil {
+		vault = o.vaultClient
+		defer func() {
+			if err := o.vaultClient.RevokeSelf(); err != nil {
+				o.logger().Printf("warning: Could not revoke Vault token: %v", err)
+			}
+		}()
+	}
 	// load the graph from the configuration
-	buildSteps, postSteps, err := defaults.FromConfig(o.configSpec, o.jobSpec, o.templates, o.writeParams, o.promote, o.clusterConfig, leaseClient, o.targets.values, o.cloneAuthConfig, o.pullSecret, o.pushSecret)
+	buildSteps, postSteps, err := defaults.FromConfig(o.configSpec, o.jobSpec, o.templates, o.writeParams, o.promote, o.clusterConfig, leaseClient, o.targets.values, o.cloneAuthConfig, o.pullSecret, o.pushSecret, o.signingSecret, o.quayClient, o.byoCluster, vault, o.payloadOverrides, o.dependencyOverrides, o.changedImages(), o.buildDispatcher, o.buildBackend, o.clonerefsOverride, o.proxy, o.hermeticBuildEgress.values, o.releaseInputs, o.apiMetrics)
 	if err != nil {
-		return []error{results.ForReason("defaulting_config").WithError(err).Errorf("failed to generate steps from config: %v", err)}
+		return []error{results.ForReason(results.ReasonDefaultingConfig).WithError(err).Errorf("failed to generate steps from config: %v", err)}
+	}
+	if o.explain {
+		if err := printExplanation(os.Stdout, buildSteps, o.targets.values); err != nil {
+			return []error{results.ForReason(results.ReasonBuildingGraph).WithError(err).Errorf("could not explain execution graph: %v", err)}
+		}
+		return nil
+	}
+	if o.replayInputs != nil {
+		o.replayInputs.PinSteps(buildSteps)
 	}
 	// Before we create the namespace, we need to ensure all inputs to the graph
 	// have been resolved. We must run this step before we resolve the partial
 	// graph or otherwise two jobs with different targets would create different
 	// artifact caches.
 	if err := o.resolveInputs(buildSteps); err != nil {
-		return []error{results.ForReason("resolving_inputs").WithError(err).Errorf("could not resolve inputs: %v", err)}
+		return []error{results.ForReason(results.ReasonResolvingInputs).WithError(err).Errorf("could not resolve inputs: %v", err)}
+	}
+	if o.writeInputsPath != "" {
+		if err := o.writeInputs(buildSteps); err != nil {
+			return []error{fmt.Errorf("could not record inputs: %w", err)}
+		}
 	}
 
 	if err := o.writeMetadataJSON(); err != nil {
 		return []error{fmt.Errorf("unable to write metadata.json for build: %w", err)}
 	}
+	dedupe := o.dedupePeriodics && o.jobSpec.Type == prowapi.PeriodicJob && !o.print
+	if dedupe {
+		if errs, deduplicated := o.reportPreviousResult(); deduplicated {
+			return errs
+		}
+	}
 	if o.print {
 		if err := printDigraph(os.Stdout, buildSteps); err != nil {
 			return []error{fmt.Errorf("could not print graph: %w", err)}
@@ -597,29 +1303,55 @@ func (o *options) Run() []error {
 	// convert the full graph into the subset we must run
 	nodes, err := api.BuildPartialGraph(buildSteps, o.targets.values)
 	if err != nil {
-		return []error{results.ForReason("building_graph").WithError(err).Errorf("could not build execution graph: %v", err)}
+		return []error{results.ForReason(results.ReasonBuildingGraph).WithError(err).Errorf("could not build execution graph: %v", err)}
 	}
 
-	if err := printExecutionOrder(nodes); err != nil {
+	if err := printExecutionOrder(o.logger(), nodes); err != nil {
 		return []error{fmt.Errorf("could not print execution order: %w", err)}
 	}
 
 	graph := calculateGraph(nodes)
+	o.progress = steps.NewProgress(nodes, time.Now())
 	if err := validateGraph(nodes); err != nil {
 		return err
 	}
 	// initialize the namespace if necessary and create any resources that must
 	// exist prior to execution
 	if err := o.initializeNamespace(); err != nil {
-		return []error{results.ForReason("initializing_namespace").WithError(err).Errorf("could not initialize namespace: %v", err)}
+		return []error{results.ForReason(results.ReasonInitializingNamespace).WithError(err).Errorf("could not initialize namespace: %v", err)}
+	}
+	interruption := steps.NewInterruption(o.postStepsGracePeriod)
+	defer interruption.Stop()
+	ctx, cancel := context.WithCancel(interruption.Context(o.baseContext()))
+	ctx = steps.WithLogFields(ctx, logrus.Fields{"namespace": o.namespace})
+	ctx = steps.WithSchedulingTimeout(ctx, o.unschedulableTimeout)
+	if stream, ok := steps.PullRequestImageStream(o.jobSpec.Refs); ok && o.sharedImagesNamespace != "" {
+		ctx = steps.WithImageSharing(ctx, steps.ImageSharing{Namespace: o.sharedImagesNamespace, Stream: stream})
+	}
+	if o.buildCacheNamespace != "" {
+		ctx = o.withBuildCache(ctx)
+	}
+	if o.clusterAccounting != nil {
+		ctx = steps.WithClusterAccounting(ctx, o.clusterAccounting)
 	}
-	ctx, cancel := context.WithCancel(context.Background())
 	handler := func(s os.Signal) {
-		log.Printf("error: Process interrupted with signal %s, cancelling execution...", s)
+		o.logger().Printf("error: Process interrupted with signal %s, cancelling execution...", s)
+		interruption.Interrupt()
 		cancel()
 	}
+	if o.jobContext != nil {
+		// cancelling a job of the daemon interrupts it like a signal would
+		go func() {
+			select {
+			case <-o.jobContext.Done():
+				steps.Logger(ctx).Warn("Job cancelled, cancelling execution...")
+				interruption.Interrupt()
+			case <-ctx.Done():
+			}
+		}()
+	}
 
-	return interrupt.New(handler, o.saveNamespaceArtifacts).Run(func() []error {
+	errs := interrupt.New(handler, o.saveNamespaceArtifacts).Run(func() []error {
 		if leaseClient != nil {
 			if err := o.initializeLeaseClient(); err != nil {
 				return []error{fmt.Errorf("failed to create the lease client: %w", err)}
@@ -634,27 +1366,92 @@ func (o *options) Run() []error {
 		if err != nil {
 			return []error{fmt.Errorf("could not get auth client for cluster config: %w", err)}
 		}
-		eventRecorder, err := eventRecorder(client, authClient, o.namespace)
+		eventRecorder, err := eventRecorder(o.logger(), client, authClient, o.namespace)
 		if err != nil {
 			return []error{fmt.Errorf("could not create event recorder: %w", err)}
 		}
 		runtimeObject := &coreapi.ObjectReference{Namespace: o.namespace}
 		eventRecorder.Event(runtimeObject, coreapi.EventTypeNormal, "CiJobStarted", eventJobDescription(o.jobSpec, o.namespace))
+		onFinished := o.progress.StepFinished
+		if reporter, err := o.resultsOptions.Reporter(o.jobSpec, o.consoleHost, o.contacts()); err == nil {
+			onFinished = notifyAll(onFinished, reporter.ReportStep)
+		}
+		if milestones, stop := o.milestones(nodes, postSteps); milestones != nil {
+			onFinished = notifyAll(onFinished, milestones.StepFinished)
+			defer stop()
+		}
+		censorer := o.artifactsCensorer
+		if censorer == nil {
+			censorer = artifacts.NewCensorer("", o.secretValues()...)
+		}
+		if failureNotifier, err := o.notifierOptions.Notifier(o.jobSpec.Job, censorer.CensorBytes, o.notificationLinks()...); err != nil {
+			o.logger().Printf("warning: Not notifying about failed steps: %v", err)
+		} else if failureNotifier != nil {
+			onFinished = notifyAll(onFinished, failureNotifier.StepFinished)
+			notifyCtx, stopNotifying := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				failureNotifier.Run(notifyCtx)
+				close(done)
+			}()
+			defer func() {
+				stopNotifying()
+				<-done
+			}()
+		}
+		if censorer := o.artifactsCensorer; censorer != nil && o.artifactsUploader == nil {
+			// the uploader censors the artifacts before every upload, else
+			// they are censored once before the sidecar uploads them
+			defer func() {
+				if err := censorer.Censor(); err != nil {
+					o.logger().Printf("warning: %v", err)
+				}
+			}()
+		}
+		if uploader := o.artifactsUploader; uploader != nil {
+			onFinished = notifyAll(onFinished, uploader.StepFinished)
+			uploadCtx, stopUploading := context.WithCancel(ctx)
+			go uploader.Run(uploadCtx)
+			defer func() {
+				stopUploading()
+				if err := uploader.Sync(); err != nil {
+					o.logger().Printf("warning: %v", err)
+				}
+			}()
+		}
+		if dispatcher := o.buildDispatcher; dispatcher != nil {
+			// the namespaces on the build farms are deleted even when the
+			// namespace of the tests is kept, they only held the builds
+			defer func() {
+				if err := dispatcher.Cleanup(context.Background()); err != nil {
+					o.logger().Printf("warning: Could not clean up build farms: %v", err)
+				}
+			}()
+		}
+		if o.deletePipelineImages {
+			if client, err := ctrlruntimeclient.New(o.clusterConfig, ctrlruntimeclient.Options{}); err != nil {
+				o.logger().Printf("warning: Not deleting pipeline images no longer required, failed to construct client: %v", err)
+			} else {
+				collector := steps.NewPipelineGarbageCollector(client, o.namespace, nodes, postSteps)
+				ctx = steps.WithPipelineGarbageCollector(ctx, collector)
+				onFinished = notifyAll(onFinished, collector.StepFinished)
+			}
+		}
 		// execute the graph
-		suites, graphDetails, errs := steps.Run(ctx, nodes)
+		suites, graphDetails, errs := steps.Run(ctx, nodes, onFinished)
 		if err := o.writeJUnit(suites, "operator"); err != nil {
-			log.Printf("warning: Unable to write JUnit result: %v", err)
+			o.logger().Printf("warning: Unable to write JUnit result: %v", err)
 		}
 		graph.MergeFrom(graphDetails...)
 		// Rewrite the Metadata JSON to catch custom metadata if it has been generated by the job
 		if err := o.writeMetadataJSON(); err != nil {
-			log.Printf("warning: unable to update metadata.json for build: %v", err)
+			o.logger().Printf("warning: unable to update metadata.json for build: %v", err)
 		}
 		if len(errs) > 0 {
 			eventRecorder.Event(runtimeObject, coreapi.EventTypeWarning, "CiJobFailed", eventJobDescription(o.jobSpec, o.namespace))
 			var wrapped []error
 			for _, err := range errs {
-				wrapped = append(wrapped, &errWroteJUnit{wrapped: results.ForReason("executing_graph").WithError(err).Errorf("could not run steps: %v", err)})
+				wrapped = append(wrapped, &errWroteJUnit{wrapped: results.ForReason(results.ReasonExecutingGraph).WithError(err).Errorf("could not run steps: %v", err)})
 			}
 			return wrapped
 		}
@@ -662,16 +1459,177 @@ func (o *options) Run() []error {
 		for _, step := range postSteps {
 			details, err := runStep(ctx, step)
 			graph.MergeFrom(details)
+			if onFinished != nil {
+				onFinished(step.Name(), err)
+			}
 			if err != nil {
 				eventRecorder.Event(runtimeObject, coreapi.EventTypeWarning, "PostStepFailed",
 					fmt.Sprintf("Post step %s failed while %s", step.Name(), eventJobDescription(o.jobSpec, o.namespace)))
-				return []error{results.ForReason("executing_post").WithError(err).Errorf("could not run post step %s: %v", step.Name(), err)}
+				return []error{results.ForReason(results.ReasonExecutingPost).WithError(err).Errorf("could not run post step %s: %v", step.Name(), err)}
 			}
 		}
 
 		eventRecorder.Event(runtimeObject, coreapi.EventTypeNormal, "CiJobSucceeded", eventJobDescription(o.jobSpec, o.namespace))
 		return nil
 	})
+	reportInterruption(o.logger(), interruption)
+	o.writeCostReport(*graph)
+	o.writeAPIClientMetrics()
+	// results of interrupted executions say nothing about the inputs
+	if dedupe && ctx.Err() == nil {
+		o.recordResult(len(errs) == 0)
+	}
+	return errs
+}
+
+// reportInterruption logs which post steps ran after the job was
+// interrupted and which did not fit into the grace period
+func reportInterruption(logger *logrus.Entry, interruption *steps.Interruption) {
+	interrupted, ran, skipped := interruption.Report()
+	if !interrupted {
+		return
+	}
+	if len(ran) > 0 {
+		logger.Printf("Post steps that ran after the interruption: %s", strings.Join(ran, ", "))
+	}
+	if len(skipped) > 0 {
+		logger.Printf("warning: Post steps skipped after the grace period of the interruption ran out: %s", strings.Join(skipped, ", "))
+	}
+}
+
+// lastActiveInterval is how often the namespace is marked as active when no
+// heartbeat is recorded
+var lastActiveInterval = 10 * time.Minute
+
+// heartbeat periodically records on the namespace that ci-operator is alive,
+// which steps run and when the job is expected to finish, so that abandoned
+// namespaces can be told apart from the ones of live jobs. Without a
+// heartbeat interval, only the time the namespace was last active is kept
+// up to date.
+func (o *options) heartbeat(ctx context.Context, client ctrlruntimeclient.Client) {
+	interval, record := o.heartbeatInterval, o.heartbeatInterval > 0
+	if !record {
+		interval = lastActiveInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		var heartbeat []byte
+		if record && o.progress != nil {
+			raw, err := json.Marshal(o.progress.Heartbeat(now, o.heartbeatInterval))
+			if err != nil {
+				o.logger().Printf("warning: Failed to marshal the heartbeat: %v", err)
+				continue
+			}
+			heartbeat = raw
+		}
+		ns := &coreapi.Namespace{}
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Name: o.namespace}, ns); err != nil {
+			o.logger().Printf("warning: Failed to get namespace %s for heartbeating: %v", o.namespace, err)
+			continue
+		}
+		originalNS := ns.DeepCopy()
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Annotations[nsttl.AnnotationNamespaceLastActive] = now.Format(time.RFC3339)
+		if heartbeat != nil {
+			ns.Annotations[nsttl.AnnotationHeartbeat] = string(heartbeat)
+		}
+		if err := client.Patch(ctx, ns, ctrlruntimeclient.MergeFrom(originalNS)); err != nil {
+			o.logger().Printf("warning: Failed to patch the %s namespace to update the heartbeat: %v", o.namespace, err)
+		}
+		if o.heartbeatConfigMap && heartbeat != nil {
+			cm := &coreapi.ConfigMap{ObjectMeta: meta.ObjectMeta{Namespace: o.namespace, Name: nsttl.HeartbeatConfigMap}}
+			if _, err := crcontrollerutil.CreateOrUpdate(ctx, client, cm, func() error {
+				cm.Data = map[string]string{nsttl.HeartbeatConfigMapKey: string(heartbeat)}
+				return nil
+			}); err != nil {
+				o.logger().Printf("warning: Failed to update the %s ConfigMap: %v", nsttl.HeartbeatConfigMap, err)
+			}
+		}
+	}
+}
+
+// notifyAll combines the functions notified when a step finishes, any of
+// which may be nil
+func notifyAll(funcs ...steps.StepFinishedFunc) steps.StepFinishedFunc {
+	return func(name string, err error) {
+		for _, f := range funcs {
+			if f != nil {
+				f(name, err)
+			}
+		}
+	}
+}
+
+// withBuildCache returns a context in which builds use the build cache,
+// pruning the expired images from it. Presubmits only read from the cache of
+// the other jobs and share the images they build in their own.
+func (o *options) withBuildCache(ctx context.Context) context.Context {
+	presubmit := o.jobSpec.Type == prowapi.PresubmitJob
+	caches := []steps.ImageSharing{steps.BuildCache(o.buildCacheNamespace, o.buildCacheTTL, presubmit)}
+	if presubmit {
+		caches = append(caches, steps.PresubmitBuildCache(o.buildCacheNamespace, o.buildCacheTTL))
+	}
+	client, err := ctrlruntimeclient.New(o.clusterConfig, ctrlruntimeclient.Options{})
+	if err != nil {
+		o.logger().Printf("warning: Not pruning the build cache, failed to construct client: %v", err)
+	}
+	for _, cache := range caches {
+		if client != nil {
+			if err := cache.Prune(ctx, client); err != nil {
+				o.logger().Printf("warning: Could not prune the build cache: %v", err)
+			}
+		}
+		ctx = steps.WithImageSharing(ctx, cache)
+	}
+	return ctx
+}
+
+// reportPreviousResult looks for a previous successful execution of the job
+// with identical inputs, which is reported instead of running the job
+func (o *options) reportPreviousResult() ([]error, bool) {
+	client, err := ctrlruntimeclient.New(o.clusterConfig, ctrlruntimeclient.Options{})
+	if err != nil {
+		o.logger().Printf("warning: Could not look up previous results, failed to construct client: %v", err)
+		return nil, false
+	}
+	previous, err := previousResult(context.Background(), client, o.namespace, o.jobSpec)
+	if err != nil {
+		o.logger().Printf("warning: Could not look up previous results: %v", err)
+		return nil, false
+	}
+	if previous == nil {
+		return nil, false
+	}
+	o.logger().Printf("Inputs are identical to those of %s, skipping execution and reporting its result", previous)
+	telemetry.RecordCacheHit(o.baseContext(), "execution")
+	o.deduplicated = previous
+	if err := o.writeMetadataJSON(); err != nil {
+		o.logger().Printf("warning: unable to update metadata.json for build: %v", err)
+	}
+	return nil, true
+}
+
+// recordResult records the result of the job, so later executions with
+// identical inputs can report a success
+func (o *options) recordResult(succeeded bool) {
+	client, err := ctrlruntimeclient.New(o.clusterConfig, ctrlruntimeclient.Options{})
+	if err != nil {
+		o.logger().Printf("warning: Could not record result, failed to construct client: %v", err)
+		return
+	}
+	result := executionResult{Job: o.jobSpec.Job, BuildID: o.jobSpec.BuildID, Succeeded: succeeded, Finished: time.Now()}
+	if err := recordResult(context.Background(), client, o.namespace, result, o.jobSpec); err != nil {
+		o.logger().Printf("warning: Could not record result: %v", err)
+	}
 }
 
 // runStep mostly duplicates steps.runStep. The latter uses an *api.StepNode though and we only have an api.Step for the PostSteps
@@ -679,6 +1637,7 @@ func (o *options) Run() []error {
 func runStep(ctx context.Context, step api.Step) (api.CIOperatorStepDetails, error) {
 	start := time.Now()
 	err := step.Run(ctx)
+	telemetry.RecordStep(ctx, step)
 	duration := time.Since(start)
 	failed := err != nil
 
@@ -700,6 +1659,21 @@ func runStep(ctx context.Context, step api.Step) (api.CIOperatorStepDetails, err
 	}, err
 }
 
+// writeInputs records the resolved inputs of the job for a later replay
+func (o *options) writeInputs(buildSteps []api.Step) error {
+	lock := &steps.InputsLock{Configuration: o.configSpec}
+	if o.registryPath != "" {
+		if out, err := exec.Command("git", "-C", o.registryPath, "rev-parse", "HEAD").Output(); err != nil {
+			o.logger().Printf("warning: Could not determine the commit of the step registry checkout: %v", err)
+		} else {
+			lock.RegistryCommit = strings.TrimSpace(string(out))
+		}
+	}
+	lock.LockSteps(buildSteps)
+	lock.LockClusterProfiles(o.secrets)
+	return lock.Write(o.writeInputsPath)
+}
+
 func (o *options) resolveInputs(steps []api.Step) error {
 	var inputs api.InputDefinition
 	for _, step := range steps {
@@ -726,15 +1700,18 @@ func (o *options) resolveInputs(steps []api.Step) error {
 		path = os.Args[0]
 	}
 	if stat, err := os.Stat(path); err == nil {
-		logrus.Tracef("Using binary as hash: %s %d %d", path, stat.ModTime().UTC().Unix(), stat.Size())
+		o.logger().Tracef("Using binary as hash: %s %d %d", path, stat.ModTime().UTC().Unix(), stat.Size())
 		inputs = append(inputs, fmt.Sprintf("%d-%d", stat.ModTime().UTC().Unix(), stat.Size()))
 	} else {
-		logrus.Tracef("Could not calculate info from current binary to add to input hash: %v", err)
+		o.logger().Tracef("Could not calculate info from current binary to add to input hash: %v", err)
 	}
 
 	sort.Strings(inputs)
 	o.inputHash = inputHash(inputs)
 
+	if o.reuseNamespace != "" {
+		o.namespace = o.reuseNamespace
+	}
 	// input hash is unique for a given job definition and input refs
 	if len(o.namespace) == 0 {
 		o.namespace = "ci-op-{id}"
@@ -746,11 +1723,11 @@ func (o *options) resolveInputs(steps []api.Step) error {
 
 	// If we can resolve the field, use it. If not, don't.
 	if client, err := ctrlruntimeclient.New(o.clusterConfig, ctrlruntimeclient.Options{}); err != nil {
-		log.Printf("could not get route client for cluster config: %v", err)
+		o.logger().Printf("could not get route client for cluster config: %v", err)
 	} else {
 		consoleRoutes := &routev1.RouteList{}
 		if err := client.List(context.TODO(), consoleRoutes, ctrlruntimeclient.InNamespace("openshift-console")); err != nil {
-			log.Printf("could not get routes in namespace openshift-console: %v", err)
+			o.logger().Printf("could not get routes in namespace openshift-console: %v", err)
 		} else {
 			hostForRoute := func(name string, routes []routev1.Route) string {
 				for _, route := range routes {
@@ -772,9 +1749,9 @@ func (o *options) resolveInputs(steps []api.Step) error {
 	}
 
 	if o.consoleHost != "" {
-		log.Printf("Using namespace https://%s/k8s/cluster/projects/%s", o.consoleHost, o.namespace)
+		o.logger().Printf("Using namespace https://%s/k8s/cluster/projects/%s", o.consoleHost, o.namespace)
 	} else {
-		log.Printf("Using namespace %s", o.namespace)
+		o.logger().Printf("Using namespace %s", o.namespace)
 	}
 
 	return nil
@@ -791,9 +1768,20 @@ func (o *options) initializeNamespace() error {
 		return fmt.Errorf("failed to construct client: %w", err)
 	}
 	client = ctrlruntimeclient.NewNamespacedClient(client, o.namespace)
-	ctx := context.Background()
+	ctx := o.baseContext()
 
-	log.Printf("Creating namespace %s", o.namespace)
+	if o.reuseNamespace != "" {
+		project, err := projectGetter.ProjectV1().Projects().Get(context.TODO(), o.namespace, meta.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("could not resume in namespace %s: %w", o.namespace, err)
+		}
+		if project.Status.Phase == coreapi.NamespaceTerminating {
+			return fmt.Errorf("could not resume in namespace %s: it is being deleted", o.namespace)
+		}
+		o.logger().Printf("Resuming in namespace %s", o.namespace)
+	} else {
+		o.logger().Printf("Creating namespace %s", o.namespace)
+	}
 	authTimeout := 15 * time.Second
 	initBeginning := time.Now()
 	for {
@@ -822,7 +1810,7 @@ func (o *options) initializeNamespace() error {
 			}
 		}
 		if project.Status.Phase == coreapi.NamespaceTerminating {
-			log.Println("Waiting for namespace to finish terminating before creating another")
+			o.logger().Println("Waiting for namespace to finish terminating before creating another")
 			time.Sleep(3 * time.Second)
 			continue
 		}
@@ -830,27 +1818,10 @@ func (o *options) initializeNamespace() error {
 	}
 
 	ssarStart := time.Now()
-	var selfSubjectAccessReviewSucceeded bool
-	for i := 0; i < 30; i++ {
-		sar := &authapi.SelfSubjectAccessReview{Spec: authapi.SelfSubjectAccessReviewSpec{ResourceAttributes: &authapi.ResourceAttributes{
-			Namespace: o.namespace,
-			Verb:      "create",
-			Resource:  "rolebindings",
-		}}}
-		if err := client.Create(ctx, sar); err != nil {
-			log.Printf("Warning: failed to create SelfSubjectAccessReview: %v\n", err)
-			continue
-		}
-		if sar.Status.Allowed {
-			selfSubjectAccessReviewSucceeded = true
-			break
-		}
-		log.Printf("[%d/30] RBAC in namespace not yet ready, sleeping for a second...\n", i)
-		time.Sleep(time.Second)
-	}
-	log.Printf("Spent %v waiting for RBAC to initialize in the new namespace.\n", time.Since(ssarStart))
-	if !selfSubjectAccessReviewSucceeded {
-		log.Println("ERROR: timed out waiting for RBAC")
+	err = waitForNamespaceRBAC(ctx, client, o.namespace, time.Second, 30*time.Second)
+	o.logger().Printf("Spent %v waiting for RBAC to initialize in the new namespace.\n", time.Since(ssarStart))
+	if err != nil {
+		o.logger().Println("ERROR: timed out waiting for RBAC")
 		return errors.New("timed out waiting for RBAC")
 	}
 
@@ -863,14 +1834,14 @@ func (o *options) initializeNamespace() error {
 	updates := map[string]string{}
 	if o.idleCleanupDuration > 0 {
 		if o.idleCleanupDurationSet {
-			log.Printf("Setting a soft TTL of %s for the namespace\n", o.idleCleanupDuration.String())
+			o.logger().Printf("Setting a soft TTL of %s for the namespace\n", o.idleCleanupDuration.String())
 		}
 		updates[nsttl.AnnotationIdleCleanupDurationTTL] = o.idleCleanupDuration.String()
 	}
 
 	if o.cleanupDuration > 0 {
 		if o.cleanupDurationSet {
-			log.Printf("Setting a hard TTL of %s for the namespace\n", o.cleanupDuration.String())
+			o.logger().Printf("Setting a hard TTL of %s for the namespace\n", o.cleanupDuration.String())
 		}
 		updates[nsttl.AnnotationCleanupDurationTTL] = o.cleanupDuration.String()
 	}
@@ -906,7 +1877,7 @@ func (o *options) initializeNamespace() error {
 
 			updateErr := client.Update(ctx, ns)
 			if kerrors.IsForbidden(updateErr) {
-				log.Printf("warning: Could not add annotations because you do not have permission to update the namespace (details: %v)", updateErr)
+				o.logger().Printf("warning: Could not add annotations because you do not have permission to update the namespace (details: %v)", updateErr)
 				return nil
 			}
 			return updateErr
@@ -932,88 +1903,38 @@ func (o *options) initializeNamespace() error {
 		if imagePullSecretsMinted {
 			break
 		}
-		log.Printf("[%d/30] Image pull secrets in namespace not yet ready, sleeping for a second...\n", i)
+		o.logger().Printf("[%d/30] Image pull secrets in namespace not yet ready, sleeping for a second...\n", i)
 		time.Sleep(time.Second)
 	}
-	log.Printf("Spent %v waiting for image pull secrets to initialize in the new namespace.\n", time.Since(pullStart))
+	o.logger().Printf("Spent %v waiting for image pull secrets to initialize in the new namespace.\n", time.Since(pullStart))
 	if !imagePullSecretsMinted {
-		log.Println("ERROR: timed out waiting for image pull secrets")
+		o.logger().Println("ERROR: timed out waiting for image pull secrets")
 		return errors.New("timed out waiting for image pull secrets")
 	}
 
-	if o.givePrAuthorAccessToNamespace {
+	if o.givePrAuthorAccessToNamespace && len(o.authors) > 0 {
 		// Generate rolebinding for all the PR Authors.
-		for _, author := range o.authors {
-			log.Printf("Creating rolebinding for user %s in namespace %s", author, o.namespace)
-			if err := client.Create(ctx, &rbacapi.RoleBinding{
-				ObjectMeta: meta.ObjectMeta{
-					Name:      "ci-op-author-access",
-					Namespace: o.namespace,
-				},
-				Subjects: []rbacapi.Subject{{Kind: "User", Name: author}},
-				RoleRef: rbacapi.RoleRef{
-					Kind: "ClusterRole",
-					Name: "admin",
-				},
-			}); err != nil && !kerrors.IsAlreadyExists(err) {
-				return fmt.Errorf("could not create role binding for: %w", err)
-			}
+		if err := ensureAuthorAccess(ctx, client, o.namespace, o.authors); err != nil {
+			return err
 		}
 	}
 
-	for _, secret := range []*coreapi.Secret{o.pullSecret, o.pushSecret, o.uploadSecret} {
+	for _, secret := range []*coreapi.Secret{o.pullSecret, o.pushSecret, o.signingSecret, o.uploadSecret} {
 		if secret != nil {
-			if err := client.Create(ctx, secret); err != nil && !kerrors.IsAlreadyExists(err) {
+			if _, err := util.UpdateSecret(ctx, client, secret); err != nil {
 				return fmt.Errorf("couldn't create secret %s: %w", secret.Name, err)
 			}
 		}
 	}
 
-	go func() {
-		ticker := time.NewTicker(10 * time.Minute)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				ns := &coreapi.Namespace{}
-				if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Name: o.namespace}, ns); err != nil {
-					log.Printf("warning: Failed to get namespace %s for heartbeating: %v", o.namespace, err)
-					continue
-				}
-				originalNS := ns.DeepCopy()
-				if ns.Annotations == nil {
-					ns.Annotations = map[string]string{}
-				}
-				ns.Annotations[nsttl.AnnotationNamespaceLastActive] = time.Now().Format(time.RFC3339)
-				if err := client.Patch(ctx, ns, ctrlruntimeclient.MergeFrom(originalNS)); err != nil {
-					log.Printf("warning: Failed to patch the %s namespace to update the %s annotation: %v", o.namespace, nsttl.AnnotationNamespaceLastActive, err)
-				}
-			}
-		}
-	}()
+	go o.heartbeat(ctx, client)
 
-	log.Printf("Setting up pipeline imagestream for the test")
+	o.logger().Printf("Setting up pipeline imagestream for the test")
 
 	// create the image stream or read it to get its uid
-	is := &imageapi.ImageStream{
-		ObjectMeta: meta.ObjectMeta{
-			Namespace: o.jobSpec.Namespace(),
-			Name:      api.PipelineImageStream,
-		},
-		Spec: imageapi.ImageStreamSpec{
-			// pipeline:* will now be directly referenceable
-			LookupPolicy: imageapi.ImageLookupPolicy{Local: true},
-		},
-	}
-	if err := client.Create(ctx, is); err != nil {
-		if !kerrors.IsAlreadyExists(err) {
-			return fmt.Errorf("could not set up pipeline imagestream for test: %w", err)
-		}
-		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Name: api.PipelineImageStream}, is); err != nil {
-			return fmt.Errorf("failed to get pipeline imagestream: %w", err)
-		}
+	is, err := ensurePipelineImageStream(ctx, client, o.jobSpec.Namespace(), 3*time.Second, 5*time.Minute)
+	if err != nil {
+		return fmt.Errorf("failed to wait for pipeline imagestream: %w", err)
 	}
 	if is != nil {
 		o.jobSpec.SetOwner(&meta.OwnerReference{
@@ -1025,7 +1946,7 @@ func (o *options) initializeNamespace() error {
 	}
 
 	if o.cloneAuthConfig != nil && o.cloneAuthConfig.Secret != nil {
-		if err := client.Create(ctx, o.cloneAuthConfig.Secret); err != nil && !kerrors.IsAlreadyExists(err) {
+		if _, err := util.UpdateSecret(ctx, client, o.cloneAuthConfig.Secret); err != nil {
 			return fmt.Errorf("couldn't create secret %s for %s authentication: %w", o.cloneAuthConfig.Secret.Name, o.cloneAuthConfig.Type, err)
 		}
 	}
@@ -1036,18 +1957,25 @@ func (o *options) initializeNamespace() error {
 			return fmt.Errorf("could not update secret %s: %w", secret.Name, err)
 		}
 		if created {
-			log.Printf("Created secret %s", secret.Name)
+			o.logger().Printf("Created secret %s", secret.Name)
 		} else {
-			log.Printf("Updated secret %s", secret.Name)
+			o.logger().Printf("Updated secret %s", secret.Name)
 		}
 	}
 
 	for _, pdbLabelKey := range []string{"openshift.io/build.name", "created-by-ci"} {
 		pdb, mutateFn := pdb(pdbLabelKey, o.namespace)
-		if _, err := crcontrollerutil.CreateOrUpdate(ctx, client, pdb, mutateFn); err != nil && !kerrors.IsAlreadyExists(err) {
+		result, err := ensureObject(ctx, client, pdb, mutateFn)
+		if err != nil {
 			return fmt.Errorf("failed to create pdb for label key %s: %w", pdbLabelKey, err)
 		}
-		log.Printf("Created PDB for pods with %s label", pdbLabelKey)
+		o.logger().Printf("PDB for pods with %s label: %s", pdbLabelKey, result)
+	}
+
+	if o.configSpec.Namespace != nil {
+		if err := ensureNamespacePolicies(ctx, client, o.namespace, *o.configSpec.Namespace); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -1105,10 +2033,19 @@ type prowResultMetadata struct {
 	Pod           string            `json:"pod"`
 	WorkNamespace string            `json:"work-namespace"`
 	Metadata      map[string]string `json:"metadata"`
+	// PayloadOverrides records the payload components replaced for this run
+	PayloadOverrides releasesteps.PayloadOverrides `json:"payload-overrides,omitempty"`
+	// DependencyOverrides records the dependencies replaced for this run
+	DependencyOverrides steps.DependencyOverrides `json:"dependency-overrides,omitempty"`
+	// Deprecations lists the deprecated features the configuration uses
+	Deprecations []deprecation.Warning `json:"deprecations,omitempty"`
+	// Deduplicated is the previous execution with identical inputs whose
+	// result was reported instead of running the job
+	Deduplicated *executionResult `json:"deduplicated,omitempty"`
 }
 
 func (o *options) writeMetadataJSON() error {
-	artifactDir, set := api.Artifacts()
+	artifactDir, set := o.artifacts()
 	if !set {
 		return nil
 	}
@@ -1118,14 +2055,14 @@ func (o *options) writeMetadataJSON() error {
 	customProwMetadataFile, err := o.findCustomMetadataFile(artifactDir)
 
 	if err != nil {
-		log.Printf("Error finding custom prow metadata file: %v", err)
+		o.logger().Printf("Error finding custom prow metadata file: %v", err)
 		return err
 	}
 
 	// If the metadata JSON exists and there's no custom prow metadata, then skip the second write.
 	_, err = os.Stat(metadataJSONPath)
 	if customProwMetadataFile == "" && err == nil {
-		log.Printf("No custom metadata found and prow metadata already exists. Not updating the metadata.")
+		o.logger().Printf("No custom metadata found and prow metadata already exists. Not updating the metadata.")
 		return nil
 	}
 
@@ -1137,7 +2074,7 @@ func (o *options) writeMetadataJSON() error {
 	}
 
 	if customMetadataErr != nil {
-		log.Printf("Error parsing custom metadata: %v", err)
+		o.logger().Printf("Error parsing custom metadata: %v", err)
 	}
 
 	data, _ := json.MarshalIndent(m, "", "")
@@ -1164,7 +2101,7 @@ func (o *options) findCustomMetadataFile(artifactDir string) (customProwMetadata
 			if customProwMetadataFile == "" {
 				customProwMetadataFile = path
 			} else {
-				log.Printf("Multiple custom prow metadata files found, which are not currently supported by ci-operator.")
+				o.logger().Printf("Multiple custom prow metadata files found, which are not currently supported by ci-operator.")
 			}
 			return filepath.SkipDir
 		}
@@ -1198,20 +2135,28 @@ func (o *options) generateProwMetadata() (m prowResultMetadata) {
 
 	m.Pod = o.jobSpec.ProwJobID
 	m.WorkNamespace = o.namespace
+	if len(o.payloadOverrides) > 0 {
+		m.PayloadOverrides = o.payloadOverrides
+	}
+	if len(o.dependencyOverrides) > 0 {
+		m.DependencyOverrides = o.dependencyOverrides
+	}
+	m.Deprecations = o.deprecations
+	m.Deduplicated = o.deduplicated
 
 	return m
 }
 
 // parseCustomMetadata parses metadata from the custom prow metadata file
 func (o *options) parseCustomMetadata(customProwMetadataFile string) (customMetadata map[string]string, err error) {
-	log.Printf("Found custom prow metadata.")
+	o.logger().Printf("Found custom prow metadata.")
 
 	if customJSONFile, readingError := ioutil.ReadFile(customProwMetadataFile); readingError != nil {
-		log.Printf("Error while reading custom prow metadata: %v", readingError)
+		o.logger().Printf("Error while reading custom prow metadata: %v", readingError)
 	} else {
 		err = json.Unmarshal(customJSONFile, &customMetadata)
 		if err != nil {
-			log.Printf("Error while unmarshaling custom prow metadata: %v", err)
+			o.logger().Printf("Error while unmarshaling custom prow metadata: %v", err)
 		}
 	}
 
@@ -1263,10 +2208,14 @@ func (o *options) writeFailingJUnit(errs []error) {
 		if errors.Is(err, &errWroteJUnit{}) {
 			continue
 		}
+		output := err.Error()
+		if contacts := o.contacts(); contacts != nil {
+			output = fmt.Sprintf("%s\n\n%s", output, contacts.Summary())
+		}
 		testCases = append(testCases, &junit.TestCase{
 			Name: "initialize",
 			FailureOutput: &junit.FailureOutput{
-				Output: err.Error(),
+				Output: output,
 			},
 		})
 	}
@@ -1283,12 +2232,72 @@ func (o *options) writeFailingJUnit(errs []error) {
 		},
 	}
 	if err := o.writeJUnit(suites, "job"); err != nil {
-		logrus.Trace("Unable to write top level failing JUnit artifact")
+		o.logger().Trace("Unable to write top level failing JUnit artifact")
+	}
+}
+
+// logToArtifacts writes every log line of the job with its fields as JSON
+// to the artifacts, next to the human-readable log, until the returned
+// function is called
+func (o *options) logToArtifacts() func() {
+	artifactDir, set := o.artifacts()
+	if !set || len(artifactDir) == 0 {
+		return func() {}
+	}
+	if err := os.MkdirAll(artifactDir, 0755); err != nil {
+		o.logger().Printf("warning: could not create the artifacts directory: %v", err)
+		return func() {}
+	}
+	file, err := os.Create(filepath.Join(artifactDir, "ci-operator-log.json"))
+	if err != nil {
+		o.logger().Printf("warning: could not create the JSON log: %v", err)
+		return func() {}
+	}
+	o.logger().Logger.AddHook(steps.NewJSONLogHook(file))
+	return func() {
+		if err := file.Close(); err != nil {
+			o.logger().Printf("warning: could not close the JSON log: %v", err)
+		}
+	}
+}
+
+// writeCostReport estimates the compute cost of the steps which ran and
+// writes the report to the artifacts, when the rates are configured
+func (o *options) writeCostReport(graph api.CIOperatorStepGraph) {
+	rates, configured := o.costOptions.Rates()
+	if !configured {
+		return
+	}
+	report := cost.Estimate(graph, o.configSpec.Resources, rates)
+	o.logger().Printf("Estimated compute cost of the job: %.2f (%.2f core-hours, %.2f GB-hours)", report.Cost, report.CoreHours, report.GBHours)
+	artifactDir, set := o.artifacts()
+	if !set || len(artifactDir) == 0 {
+		return
+	}
+	if err := report.Write(artifactDir); err != nil {
+		o.logger().Printf("warning: Unable to write cost report: %v", err)
+	}
+}
+
+// writeAPIClientMetrics writes the latency and errors of the requests steps
+// made to the API server to the artifacts
+func (o *options) writeAPIClientMetrics() {
+	artifactDir, set := o.artifacts()
+	if !set || len(artifactDir) == 0 || o.apiMetrics == nil {
+		return
+	}
+	buf := &bytes.Buffer{}
+	if err := o.apiMetrics.Write(buf); err != nil {
+		o.logger().Printf("warning: Unable to gather API client metrics: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(artifactDir, "ci-operator-api-metrics.txt"), buf.Bytes(), 0644); err != nil {
+		o.logger().Printf("warning: Unable to write API client metrics: %v", err)
 	}
 }
 
 func (o *options) writeJUnit(suites *junit.TestSuites, name string) error {
-	artifactDir, set := api.Artifacts()
+	artifactDir, set := o.artifacts()
 	if !set {
 		return nil
 	}
@@ -1334,14 +2343,14 @@ func inputHash(inputs api.InputDefinition) string {
 // saveNamespaceArtifacts is a best effort attempt to save ci-operator namespace artifacts to disk
 // for review later.
 func (o *options) saveNamespaceArtifacts() {
-	artifactDir, set := api.Artifacts()
+	artifactDir, set := o.artifacts()
 	if !set {
 		return
 	}
 
 	namespaceDir := filepath.Join(artifactDir, "build-resources")
 	if err := os.Mkdir(namespaceDir, 0777); err != nil {
-		log.Printf("Unable to create build-resources directory: %v", err)
+		o.logger().Printf("Unable to create build-resources directory: %v", err)
 		return
 	}
 
@@ -1350,13 +2359,13 @@ func (o *options) saveNamespaceArtifacts() {
 		data, _ := json.MarshalIndent(pods, "", "  ")
 		path := filepath.Join(namespaceDir, "pods.json")
 		if err := ioutil.WriteFile(path, data, 0644); err != nil {
-			logrus.WithError(err).Errorf("Failed to write %s", path)
+			o.logger().WithError(err).Errorf("Failed to write %s", path)
 		}
 		events, _ := kubeClient.Events(o.namespace).List(context.TODO(), meta.ListOptions{})
 		data, _ = json.MarshalIndent(events, "", "  ")
 		path = filepath.Join(namespaceDir, "events.json")
 		if err := ioutil.WriteFile(path, data, 0644); err != nil {
-			logrus.WithError(err).Errorf("Failed to write %s", path)
+			o.logger().WithError(err).Errorf("Failed to write %s", path)
 		}
 	}
 
@@ -1365,7 +2374,7 @@ func (o *options) saveNamespaceArtifacts() {
 		data, _ := json.MarshalIndent(builds, "", "  ")
 		path := filepath.Join(namespaceDir, "builds.json")
 		if err := ioutil.WriteFile(path, data, 0644); err != nil {
-			logrus.WithError(err).Errorf("Failed to write %s", path)
+			o.logger().WithError(err).Errorf("Failed to write %s", path)
 		}
 	}
 
@@ -1375,7 +2384,7 @@ func (o *options) saveNamespaceArtifacts() {
 		data, _ := json.MarshalIndent(imagestreams, "", "  ")
 		path := filepath.Join(namespaceDir, "imagestreams.json")
 		if err := ioutil.WriteFile(path, data, 0644); err != nil {
-			logrus.WithError(err).Errorf("Failed to write %s", path)
+			o.logger().WithError(err).Errorf("Failed to write %s", path)
 		}
 	}
 
@@ -1384,7 +2393,7 @@ func (o *options) saveNamespaceArtifacts() {
 		data, _ := json.MarshalIndent(templateInstances, "", "  ")
 		path := filepath.Join(namespaceDir, "templateinstances.json")
 		if err := ioutil.WriteFile(path, data, 0644); err != nil {
-			logrus.WithError(err).Errorf("Failed to write %s", path)
+			o.logger().WithError(err).Errorf("Failed to write %s", path)
 		}
 	}
 }
@@ -1419,13 +2428,13 @@ func (o *options) initializeLeaseClient() error {
 	go func() {
 		for range t.C {
 			if err := o.leaseClient.Heartbeat(); err != nil {
-				log.Printf("failed to update leases: %v", err)
+				o.logger().Printf("failed to update leases: %v", err)
 			}
 		}
 		if l, err := o.leaseClient.ReleaseAll(); err != nil {
-			log.Printf("failed to release leaked leases (%v): %v", l, err)
+			o.logger().Printf("failed to release leaked leases (%v): %v", l, err)
 		} else if len(l) != 0 {
-			log.Printf("warning: Would leak leases: %v", l)
+			o.logger().Printf("warning: Would leak leases: %v", l)
 		}
 	}()
 	return nil
@@ -1441,11 +2450,11 @@ func eventJobDescription(jobSpec *api.JobSpec, namespace string) string {
 	}
 	if len(jobSpec.Refs.Pulls) == 1 {
 		pull := jobSpec.Refs.Pulls[0]
-		return fmt.Sprintf("Running job %s for PR https://github.com/%s/%s/pull/%d in namespace %s from author %s",
-			jobSpec.Job, jobSpec.Refs.Org, jobSpec.Refs.Repo, pull.Number, namespace, pull.Author)
+		return fmt.Sprintf("Running job %s for PR %s in namespace %s from author %s",
+			jobSpec.Job, api.PullURL(jobSpec.Refs, pull), namespace, pull.Author)
 	}
 	for _, pull := range jobSpec.Refs.Pulls {
-		pulls = append(pulls, fmt.Sprintf("https://github.com/%s/%s/pull/%d", jobSpec.Refs.Org, jobSpec.Refs.Repo, pull.Number))
+		pulls = append(pulls, api.PullURL(jobSpec.Refs, pull))
 		authors = append(authors, pull.Author)
 	}
 	return fmt.Sprintf("Running job %s for PRs (%s) in namespace %s from authors (%s)",
@@ -1459,15 +2468,15 @@ func jobDescription(job *api.JobSpec) string {
 	}
 	var links []string
 	for _, pull := range job.Refs.Pulls {
-		links = append(links, fmt.Sprintf("https://github.com/%s/%s/pull/%d - %s", job.Refs.Org, job.Refs.Repo, pull.Number, pull.Author))
+		links = append(links, fmt.Sprintf("%s - %s", api.PullURL(job.Refs, pull), pull.Author))
 	}
 	if len(links) > 0 {
-		return fmt.Sprintf("%s\n\n%s on https://github.com/%s/%s", strings.Join(links, "\n"), job.Job, job.Refs.Org, job.Refs.Repo)
+		return fmt.Sprintf("%s\n\n%s on %s", strings.Join(links, "\n"), job.Job, api.RepoURL(job.Refs))
 	}
-	return fmt.Sprintf("%s on https://github.com/%s/%s ref=%s commit=%s", job.Job, job.Refs.Org, job.Refs.Repo, job.Refs.BaseRef, job.Refs.BaseSHA)
+	return fmt.Sprintf("%s on %s ref=%s commit=%s", job.Job, api.RepoURL(job.Refs), job.Refs.BaseRef, job.Refs.BaseSHA)
 }
 
-func jobSpecFromGitRef(ref string) (*api.JobSpec, error) {
+func jobSpecFromGitRef(logger *logrus.Entry, ref string) (*api.JobSpec, error) {
 	parts := strings.Split(ref, "@")
 	if len(parts) != 2 {
 		return nil, fmt.Errorf("must be ORG/NAME@REF")
@@ -1494,7 +2503,7 @@ func jobSpecFromGitRef(ref string) (*api.JobSpec, error) {
 			return nil, fmt.Errorf("ref '%s' does not point to any commit in '%s' (did you mean '%s'?)", parts[1], parts[0], trimmed)
 		}
 	}
-	log.Printf("Resolved %s to commit %s", ref, sha)
+	logger.Printf("Resolved %s to commit %s", ref, sha)
 	spec := &api.JobSpec{
 		JobSpec: downwardapi.JobSpec{
 			Type: prowapi.PeriodicJob,
@@ -1521,7 +2530,7 @@ func nodeNames(nodes []*api.StepNode) []string {
 	return names
 }
 
-func topologicalSort(nodes []*api.StepNode) ([]*api.StepNode, error) {
+func topologicalSort(logger *logrus.Entry, nodes []*api.StepNode) ([]*api.StepNode, error) {
 	var sortedNodes []*api.StepNode
 	var satisfied []api.StepLink
 	api.IterateAllEdges(nodes, func(inner *api.StepNode) {
@@ -1565,7 +2574,7 @@ func topologicalSort(nodes []*api.StepNode) ([]*api.StepNode, error) {
 				errMessages.Insert(fmt.Sprintf("step <%T> is missing dependencies: %s", node.Step, strings.Join(missing.List(), ", ")))
 			}
 			for _, message := range errMessages.List() {
-				log.Print(message)
+				logger.Print(message)
 			}
 			return nil, errors.New("steps are missing dependencies")
 		}
@@ -1590,12 +2599,30 @@ func printDigraph(w io.Writer, steps []api.Step) error {
 	return nil
 }
 
-func printExecutionOrder(nodes []*api.StepNode) error {
-	ordered, err := topologicalSort(nodes)
+// printExplanation prints whether each step runs for the targets and why
+func printExplanation(w io.Writer, steps []api.Step, targets []string) error {
+	explanations, err := api.ExplainPartialGraph(steps, targets)
+	if err != nil {
+		return err
+	}
+	for _, explanation := range explanations {
+		line := fmt.Sprintf("skip %s: %s", explanation.Name, explanation.Reason)
+		if explanation.Included {
+			line = fmt.Sprintf("run  %s: %s (%s)", explanation.Name, explanation.Reason, strings.Join(explanation.Chain, " -> "))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printExecutionOrder(logger *logrus.Entry, nodes []*api.StepNode) error {
+	ordered, err := topologicalSort(logger, nodes)
 	if err != nil {
 		return fmt.Errorf("could not sort nodes: %w", err)
 	}
-	log.Printf("Running %s", strings.Join(nodeNames(ordered), ", "))
+	logger.Printf("Running %s", strings.Join(nodeNames(ordered), ", "))
 	return nil
 }
 
@@ -1648,12 +2675,12 @@ func summarizeRef(refs prowapi.Refs) string {
 		for _, pull := range refs.Pulls {
 			pulls = append(pulls, fmt.Sprintf("#%d %s @%s", pull.Number, shorten(pull.SHA, 8), pull.Author))
 		}
-		return fmt.Sprintf("Resolved source https://github.com/%s/%s to %s@%s, merging: %s", refs.Org, refs.Repo, refs.BaseRef, shorten(refs.BaseSHA, 8), strings.Join(pulls, ", "))
+		return fmt.Sprintf("Resolved source %s to %s@%s, merging: %s", api.RepoURL(&refs), refs.BaseRef, shorten(refs.BaseSHA, 8), strings.Join(pulls, ", "))
 	}
-	return fmt.Sprintf("Resolved source https://github.com/%s/%s to %s@%s", refs.Org, refs.Repo, refs.BaseRef, shorten(refs.BaseSHA, 8))
+	return fmt.Sprintf("Resolved source %s to %s@%s", api.RepoURL(&refs), refs.BaseRef, shorten(refs.BaseSHA, 8))
 }
 
-func eventRecorder(kubeClient *coreclientset.CoreV1Client, authClient *authclientset.AuthorizationV1Client, namespace string) (record.EventRecorder, error) {
+func eventRecorder(logger *logrus.Entry, kubeClient *coreclientset.CoreV1Client, authClient *authclientset.AuthorizationV1Client, namespace string) (record.EventRecorder, error) {
 	res, err := authClient.SelfSubjectAccessReviews().Create(context.TODO(), &authapi.SelfSubjectAccessReview{
 		Spec: authapi.SelfSubjectAccessReviewSpec{
 			ResourceAttributes: &authapi.ResourceAttributes{
@@ -1667,7 +2694,7 @@ func eventRecorder(kubeClient *coreclientset.CoreV1Client, authClient *authclien
 		return nil, fmt.Errorf("could not check permission to create events: %w", err)
 	}
 	if !res.Status.Allowed {
-		log.Println("warning: Events will not be created because of lack of permission")
+		logger.Println("warning: Events will not be created because of lack of permission")
 		return &record.FakeRecorder{}, nil
 	}
 	eventBroadcaster := record.NewBroadcaster()
@@ -1789,7 +2816,7 @@ reset:
 			Watch:         true,
 		})
 		if err != nil {
-			log.Printf("Could not start a watch on our test namespace... (details; %v)", err)
+			steps.Logger(ctx).Printf("Could not start a watch on our test namespace... (details; %v)", err)
 			cancel()
 			return
 		}
@@ -1810,7 +2837,7 @@ reset:
 					continue
 				}
 				if ns.DeletionTimestamp != nil {
-					log.Print("The namespace in which this test is executing has been deleted, cancelling the test...")
+					steps.Logger(ctx).Print("The namespace in which this test is executing has been deleted, cancelling the test...")
 					cancel()
 					return
 				}
@@ -1835,5 +2862,8 @@ func addSchemes() error {
 	if err := templateapi.AddToScheme(scheme.Scheme); err != nil {
 		return fmt.Errorf("failed to add templatev1 to scheme: %w", err)
 	}
+	if err := tektonapi.AddToScheme(scheme.Scheme); err != nil {
+		return fmt.Errorf("failed to add tekton v1beta1 to scheme: %w", err)
+	}
 	return nil
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,20 +10,27 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
 
+	coreapi "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes/scheme"
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/pod-utils/downwardapi"
 	"k8s.io/utils/diff"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	imagev1 "github.com/openshift/api/image/v1"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/api/nsttl"
 	"github.com/openshift/ci-tools/pkg/load"
 	"github.com/openshift/ci-tools/pkg/results"
 	"github.com/openshift/ci-tools/pkg/steps"
@@ -388,6 +396,45 @@ func TestErrWroteJUnit(t *testing.T) {
 	}
 }
 
+func TestJobURL(t *testing.T) {
+	spec := downwardapi.JobSpec{
+		Type:    prowapi.PresubmitJob,
+		Job:     "pull-ci-org-repo-master-unit",
+		BuildID: "123",
+		Refs:    &prowapi.Refs{Org: "org", Repo: "repo", Pulls: []prowapi.Pull{{Number: 5}}},
+	}
+	decorated := spec
+	decorated.DecorationConfig = &prowapi.DecorationConfig{GCSConfiguration: &prowapi.GCSConfiguration{
+		Bucket:       "origin-ci-test",
+		PathStrategy: prowapi.PathStrategySingle,
+		DefaultOrg:   "openshift",
+		DefaultRepo:  "origin",
+	}}
+	var testCases = []struct {
+		name     string
+		spec     downwardapi.JobSpec
+		expected string
+	}{
+		{
+			name: "undecorated job has no URL",
+			spec: spec,
+		},
+		{
+			name:     "decorated job links Deck",
+			spec:     decorated,
+			expected: "https://prow.ci.openshift.org/view/gs/origin-ci-test/pr-logs/pull/org_repo/5/pull-ci-org-repo-master-unit/123",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			o := &options{jobURLPrefix: "https://prow.ci.openshift.org/view/", jobSpec: &api.JobSpec{JobSpec: testCase.spec}}
+			if diff := cmp.Diff(testCase.expected, o.jobURL()); diff != "" {
+				t.Errorf("unexpected job URL: %s", diff)
+			}
+		})
+	}
+}
+
 func TestBuildPartialGraph(t *testing.T) {
 	testCases := []struct {
 		name             string
@@ -402,8 +449,11 @@ func TestBuildPartialGraph(t *testing.T) {
 					api.InputImageTagStepConfiguration{To: api.PipelineImageStreamTagReferenceRoot},
 					loggingclient.New(fakectrlruntimeclient.NewFakeClient(&imagev1.ImageStreamTag{ObjectMeta: metav1.ObjectMeta{Name: ":"}})),
 					nil,
+					nil,
+					nil,
+					nil,
 				),
-				steps.SourceStep(api.SourceStepConfiguration{From: api.PipelineImageStreamTagReferenceRoot, To: api.PipelineImageStreamTagReferenceSource}, api.ResourceConfiguration{}, nil, &api.JobSpec{}, nil, nil),
+				steps.SourceStep(api.SourceStepConfiguration{From: api.PipelineImageStreamTagReferenceRoot, To: api.PipelineImageStreamTagReferenceSource}, api.ResourceConfiguration{}, nil, &api.JobSpec{}, nil, nil, nil, nil),
 				steps.ProjectDirectoryImageBuildStep(
 					api.ProjectDirectoryImageBuildStepConfiguration{
 						From: api.PipelineImageStreamTagReferenceSource,
@@ -412,7 +462,7 @@ func TestBuildPartialGraph(t *testing.T) {
 						},
 						To: api.PipelineImageStreamTagReference("oc-bin-image"),
 					},
-					api.ResourceConfiguration{}, nil, nil, nil,
+					api.ResourceConfiguration{}, nil, nil, nil, nil, nil,
 				),
 				steps.OutputImageTagStep(api.OutputImageTagStepConfiguration{From: api.PipelineImageStreamTagReference("oc-bin-image")}, nil, nil),
 				steps.ImagesReadyStep(steps.OutputImageTagStep(api.OutputImageTagStepConfiguration{From: api.PipelineImageStreamTagReference("oc-bin-image")}, nil, nil).Creates()),
@@ -430,7 +480,7 @@ func TestBuildPartialGraph(t *testing.T) {
 			}
 
 			// Apparently we only coincidentally validate the graph during the topologicalSort we do prior to printing it
-			_, err = topologicalSort(steps)
+			_, err = topologicalSort(logrus.NewEntry(logrus.StandardLogger()), steps)
 			if err == nil {
 				return
 			}
@@ -502,3 +552,108 @@ func TestLoadLeaseCredentials(t *testing.T) {
 		})
 	}
 }
+
+type fakeChangesClient []github.PullRequestChange
+
+func (c fakeChangesClient) GetPullRequestChanges(string, string, int) ([]github.PullRequestChange, error) {
+	return c, nil
+}
+
+func TestChangedImages(t *testing.T) {
+	config := &api.ReleaseBuildConfiguration{
+		Images: []api.ProjectDirectoryImageBuildStepConfiguration{
+			{To: "cli", ProjectDirectoryImageBuildInputs: api.ProjectDirectoryImageBuildInputs{ContextDir: "cmd/cli"}},
+			{To: "operator", ProjectDirectoryImageBuildInputs: api.ProjectDirectoryImageBuildInputs{ContextDir: "cmd/operator"}},
+		},
+		Tests: []api.TestStepConfiguration{{As: "e2e"}},
+	}
+	pull := &api.JobSpec{JobSpec: downwardapi.JobSpec{Refs: &prowapi.Refs{Org: "org", Repo: "repo", Pulls: []prowapi.Pull{{Number: 1}}}}}
+	var testCases = []struct {
+		name           string
+		targets        []string
+		jobSpec        *api.JobSpec
+		buildAllImages bool
+		expected       sets.String
+	}{
+		{
+			name:     "images targeted for a pull request",
+			targets:  []string{"[images]"},
+			jobSpec:  pull,
+			expected: sets.NewString("cli"),
+		},
+		{
+			name:           "all images requested",
+			targets:        []string{"[images]"},
+			jobSpec:        pull,
+			buildAllImages: true,
+		},
+		{
+			name:    "images not targeted",
+			targets: []string{"cli"},
+			jobSpec: pull,
+		},
+		{
+			name:    "release needs all images",
+			targets: []string{"[images]", "[release:latest]"},
+			jobSpec: pull,
+		},
+		{
+			name:    "test needs all images",
+			targets: []string{"[images]", "e2e"},
+			jobSpec: pull,
+		},
+		{
+			name:    "no pull request",
+			targets: []string{"[images]"},
+			jobSpec: &api.JobSpec{JobSpec: downwardapi.JobSpec{Refs: &prowapi.Refs{Org: "org", Repo: "repo"}}},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			o := &options{
+				configSpec:     config,
+				targets:        stringSlice{values: testCase.targets},
+				jobSpec:        testCase.jobSpec,
+				buildAllImages: testCase.buildAllImages,
+				changesClient:  fakeChangesClient{{Filename: "cmd/cli/main.go"}},
+			}
+			if diff := cmp.Diff(testCase.expected, o.changedImages()); diff != "" {
+				t.Errorf("unexpected changed images: %s", diff)
+			}
+		})
+	}
+}
+
+func TestHeartbeatWithoutInterval(t *testing.T) {
+	interval := lastActiveInterval
+	lastActiveInterval = 10 * time.Millisecond
+	defer func() { lastActiveInterval = interval }()
+
+	client := fakectrlruntimeclient.NewFakeClient(&coreapi.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}})
+	o := &options{namespace: "ns"}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		o.heartbeat(ctx, client)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	ns := &coreapi.Namespace{}
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Name: "ns"}, ns); err != nil {
+			t.Fatalf("could not get namespace: %v", err)
+		}
+		if _, ok := ns.Annotations[nsttl.AnnotationNamespaceLastActive]; ok {
+			break
+		}
+	}
+	if _, ok := ns.Annotations[nsttl.AnnotationNamespaceLastActive]; !ok {
+		t.Error("expected the namespace to be marked as active without a heartbeat interval")
+	}
+	if heartbeat, ok := ns.Annotations[nsttl.AnnotationHeartbeat]; ok {
+		t.Errorf("expected no heartbeat to be recorded, got %s", heartbeat)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/migration"
+)
+
+const migrateUsage = `Upgrade configuration files to the current version of the schema.
+
+Usage: ci-operator migrate [flags] PATH...
+
+Every YAML file at or below the given paths is migrated to %s
+and rewritten in place with its version recorded. Files which already record
+the current version are left untouched. With --dry-run, the files which would
+be rewritten are listed and the exit code is non-zero if there are any.
+
+`
+
+// migrate implements the `ci-operator migrate` subcommand and returns the
+// exit code for the process
+func migrate(args []string, out io.Writer) int {
+	var dryRun bool
+	flagSet := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(out, migrateUsage, api.ConfigAPIVersion)
+		flagSet.SetOutput(out)
+		flagSet.PrintDefaults()
+	}
+	flagSet.BoolVar(&dryRun, "dry-run", false, "List the files which need to be migrated instead of rewriting them.")
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+	if flagSet.NArg() == 0 {
+		fmt.Fprintln(out, "error: at least one path to migrate is required")
+		return 2
+	}
+
+	var outdated int
+	for _, root := range flagSet.Args() {
+		if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if ext := filepath.Ext(path); info.IsDir() || (ext != ".yaml" && ext != ".yml") {
+				return nil
+			}
+			changed, err := migrateFile(path, info.Mode(), migration.Default, dryRun, out)
+			if changed {
+				outdated++
+			}
+			return err
+		}); err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			return 1
+		}
+	}
+	if dryRun && outdated > 0 {
+		fmt.Fprintf(out, "%d files need to be migrated\n", outdated)
+		return 1
+	}
+	return 0
+}
+
+// migrateFile upgrades a single configuration file and reports whether it
+// needed to be changed
+func migrateFile(path string, mode os.FileMode, migrator *migration.Migrator, dryRun bool, out io.Writer) (bool, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	upgraded, applied, err := migrator.Upgrade(raw)
+	if err != nil {
+		return false, fmt.Errorf("failed to migrate %s: %w", path, err)
+	}
+	if upgraded == nil {
+		return false, nil
+	}
+	if dryRun {
+		fmt.Fprintf(out, "%s needs to be migrated\n", path)
+		return true, nil
+	}
+	if err := ioutil.WriteFile(path, upgraded, mode); err != nil {
+		return true, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Fprintf(out, "migrated %s\n", path)
+	for _, description := range applied {
+		fmt.Fprintf(out, "  %s\n", description)
+	}
+	return true, nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	authapi "k8s.io/api/authorization/v1"
+	coreapi "k8s.io/api/core/v1"
+	rbacapi "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	crcontrollerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	imageapi "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// The namespace may already be partially initialized when we get to it, for
+// example when ci-operator was restarted or when it resumes in a namespace
+// another execution created. All resources we set up in it are therefore
+// created or updated to the desired state, never just created.
+
+// ensureObject creates the object or updates it with the mutation. Another
+// client may create or update the object concurrently, so these conflicts
+// are retried.
+func ensureObject(ctx context.Context, client ctrlruntimeclient.Client, obj ctrlruntimeclient.Object, mutate crcontrollerutil.MutateFn) (crcontrollerutil.OperationResult, error) {
+	var result crcontrollerutil.OperationResult
+	err := retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return kerrors.IsConflict(err) || kerrors.IsAlreadyExists(err)
+	}, func() error {
+		var err error
+		result, err = crcontrollerutil.CreateOrUpdate(ctx, client, obj, mutate)
+		return err
+	})
+	return result, err
+}
+
+// ensureAuthorAccess binds the admin role in the namespace to the authors
+func ensureAuthorAccess(ctx context.Context, client ctrlruntimeclient.Client, namespace string, authors []string) error {
+	subjects := make([]rbacapi.Subject, 0, len(authors))
+	for _, author := range authors {
+		subjects = append(subjects, rbacapi.Subject{Kind: "User", Name: author})
+	}
+	sort.Slice(subjects, func(i, j int) bool { return subjects[i].Name < subjects[j].Name })
+	binding := &rbacapi.RoleBinding{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "ci-op-author-access",
+			Namespace: namespace,
+		},
+	}
+	result, err := ensureObject(ctx, client, binding, func() error {
+		binding.Subjects = subjects
+		binding.RoleRef = rbacapi.RoleRef{
+			APIGroup: rbacapi.GroupName,
+			Kind:     "ClusterRole",
+			Name:     "admin",
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not ensure role binding for authors: %w", err)
+	}
+	log.Printf("Role binding for authors %v in namespace %s: %s", authors, namespace, result)
+	return nil
+}
+
+// waitForNamespaceRBAC waits until we may create role bindings in the
+// namespace, which takes a while after the namespace was created. Reviews
+// which fail are retried, as the authorization caches may not have caught up.
+func waitForNamespaceRBAC(ctx context.Context, client ctrlruntimeclient.Client, namespace string, interval, timeout time.Duration) error {
+	return wait.PollImmediate(interval, timeout, func() (bool, error) {
+		sar := &authapi.SelfSubjectAccessReview{Spec: authapi.SelfSubjectAccessReviewSpec{ResourceAttributes: &authapi.ResourceAttributes{
+			Namespace: namespace,
+			Verb:      "create",
+			Resource:  "rolebindings",
+		}}}
+		if err := client.Create(ctx, sar); err != nil {
+			log.Printf("Warning: failed to create SelfSubjectAccessReview: %v", err)
+			return false, nil
+		}
+		if !sar.Status.Allowed {
+			log.Printf("RBAC in namespace %s not yet ready", namespace)
+		}
+		return sar.Status.Allowed, nil
+	})
+}
+
+// namespacePolicyName is the name of the quota and limit range set up in the
+// namespace from the configuration
+const namespacePolicyName = "ci-operator"
+
+// ensureNamespacePolicies sets up the quota and limit range configured for
+// the namespace
+func ensureNamespacePolicies(ctx context.Context, client ctrlruntimeclient.Client, namespace string, config api.NamespaceConfiguration) error {
+	if len(config.Quota) != 0 {
+		hard, err := resourceListFor(config.Quota)
+		if err != nil {
+			return fmt.Errorf("invalid quota: %w", err)
+		}
+		quota := &coreapi.ResourceQuota{ObjectMeta: meta.ObjectMeta{Namespace: namespace, Name: namespacePolicyName}}
+		result, err := ensureObject(ctx, client, quota, func() error {
+			quota.Spec.Hard = hard
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("could not ensure resource quota: %w", err)
+		}
+		log.Printf("Resource quota in namespace %s: %s", namespace, result)
+	}
+	if config.LimitRange != nil {
+		item := coreapi.LimitRangeItem{Type: coreapi.LimitTypeContainer}
+		for _, field := range []struct {
+			list api.ResourceList
+			into *coreapi.ResourceList
+		}{{config.LimitRange.Default, &item.Default}, {config.LimitRange.DefaultRequest, &item.DefaultRequest}, {config.LimitRange.Max, &item.Max}} {
+			var err error
+			if *field.into, err = resourceListFor(field.list); err != nil {
+				return fmt.Errorf("invalid limit range: %w", err)
+			}
+		}
+		limitRange := &coreapi.LimitRange{ObjectMeta: meta.ObjectMeta{Namespace: namespace, Name: namespacePolicyName}}
+		result, err := ensureObject(ctx, client, limitRange, func() error {
+			limitRange.Spec.Limits = []coreapi.LimitRangeItem{item}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("could not ensure limit range: %w", err)
+		}
+		log.Printf("Limit range in namespace %s: %s", namespace, result)
+	}
+	return nil
+}
+
+func resourceListFor(list api.ResourceList) (coreapi.ResourceList, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	resources := coreapi.ResourceList{}
+	for name, value := range list {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity %q for %s: %w", value, name, err)
+		}
+		resources[coreapi.ResourceName(name)] = quantity
+	}
+	return resources, nil
+}
+
+// ensurePipelineImageStream creates the pipeline ImageStream or returns the
+// existing one. A stream that is still being deleted can't be used, so we
+// wait for the deletion to finish and create a new one.
+func ensurePipelineImageStream(ctx context.Context, client ctrlruntimeclient.Client, namespace string, interval, timeout time.Duration) (*imageapi.ImageStream, error) {
+	var is *imageapi.ImageStream
+	if err := wait.PollImmediate(interval, timeout, func() (bool, error) {
+		is = &imageapi.ImageStream{
+			ObjectMeta: meta.ObjectMeta{
+				Namespace: namespace,
+				Name:      api.PipelineImageStream,
+			},
+			Spec: imageapi.ImageStreamSpec{
+				// pipeline:* will now be directly referenceable
+				LookupPolicy: imageapi.ImageLookupPolicy{Local: true},
+			},
+		}
+		err := client.Create(ctx, is)
+		if err == nil {
+			return true, nil
+		}
+		if !kerrors.IsAlreadyExists(err) {
+			return false, fmt.Errorf("could not set up pipeline imagestream for test: %w", err)
+		}
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: api.PipelineImageStream}, is); err != nil {
+			if kerrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to get pipeline imagestream: %w", err)
+		}
+		if is.DeletionTimestamp != nil {
+			log.Printf("Waiting for the pipeline imagestream to finish being deleted before creating another")
+			return false, nil
+		}
+		if !is.Spec.LookupPolicy.Local {
+			original := is.DeepCopy()
+			is.Spec.LookupPolicy.Local = true
+			if err := client.Patch(ctx, is, ctrlruntimeclient.MergeFrom(original)); err != nil {
+				return false, fmt.Errorf("failed to update pipeline imagestream: %w", err)
+			}
+		}
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+	return is, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	authapi "k8s.io/api/authorization/v1"
+	coreapi "k8s.io/api/core/v1"
+	rbacapi "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestEnsureAuthorAccess(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		existing []runtime.Object
+	}{
+		{
+			name: "binding does not exist",
+		},
+		{
+			name: "binding from a previous execution is updated",
+			existing: []runtime.Object{&rbacapi.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "ci-op-author-access"},
+				Subjects:   []rbacapi.Subject{{Kind: "User", Name: "bob"}},
+				RoleRef:    rbacapi.RoleRef{APIGroup: rbacapi.GroupName, Kind: "ClusterRole", Name: "admin"},
+			}},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := fakectrlruntimeclient.NewFakeClient(testCase.existing...)
+			for i := 0; i < 2; i++ {
+				if err := ensureAuthorAccess(context.Background(), client, "ns", []string{"bob", "alice"}); err != nil {
+					t.Fatalf("attempt %d: unexpected error: %v", i, err)
+				}
+			}
+			binding := &rbacapi.RoleBinding{}
+			if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "ci-op-author-access"}, binding); err != nil {
+				t.Fatalf("failed to get binding: %v", err)
+			}
+			expected := []rbacapi.Subject{{Kind: "User", Name: "alice"}, {Kind: "User", Name: "bob"}}
+			if diff := cmp.Diff(expected, binding.Subjects); diff != "" {
+				t.Errorf("unexpected subjects: %s", diff)
+			}
+		})
+	}
+}
+
+// rbacClient allows access once enough reviews were created, and fails the
+// first review like a cold authorization cache
+type rbacClient struct {
+	ctrlruntimeclient.Client
+	reviews, readyAfter int
+}
+
+func (c *rbacClient) Create(_ context.Context, obj ctrlruntimeclient.Object, _ ...ctrlruntimeclient.CreateOption) error {
+	c.reviews++
+	if c.reviews == 1 {
+		return errors.New("injected failure")
+	}
+	obj.(*authapi.SelfSubjectAccessReview).Status.Allowed = c.reviews >= c.readyAfter
+	return nil
+}
+
+func TestWaitForNamespaceRBAC(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		readyAfter  int
+		expectedErr bool
+	}{
+		{
+			name:       "access is granted after failed reviews",
+			readyAfter: 3,
+		},
+		{
+			name:        "access is never granted",
+			readyAfter:  1000,
+			expectedErr: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := &rbacClient{readyAfter: testCase.readyAfter}
+			err := waitForNamespaceRBAC(context.Background(), client, "ns", time.Millisecond, 100*time.Millisecond)
+			if (err != nil) != testCase.expectedErr {
+				t.Fatalf("expected error: %t, got: %v", testCase.expectedErr, err)
+			}
+			if !testCase.expectedErr && client.reviews != testCase.readyAfter {
+				t.Errorf("expected %d reviews, got %d", testCase.readyAfter, client.reviews)
+			}
+		})
+	}
+}
+
+func TestEnsurePipelineImageStream(t *testing.T) {
+	now := metav1.Now()
+	var testCases = []struct {
+		name        string
+		existing    []runtime.Object
+		expectedErr bool
+	}{
+		{
+			name: "stream does not exist",
+		},
+		{
+			name: "stream from a previous execution is reused",
+			existing: []runtime.Object{&imagev1.ImageStream{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: api.PipelineImageStream, UID: "uid"},
+			}},
+		},
+		{
+			name: "stream being deleted is waited for",
+			existing: []runtime.Object{&imagev1.ImageStream{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: api.PipelineImageStream, DeletionTimestamp: &now},
+			}},
+			expectedErr: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := fakectrlruntimeclient.NewFakeClient(testCase.existing...)
+			is, err := ensurePipelineImageStream(context.Background(), client, "ns", time.Millisecond, 10*time.Millisecond)
+			if (err != nil) != testCase.expectedErr {
+				t.Fatalf("expected error: %t, got %v", testCase.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			actual := &imagev1.ImageStream{}
+			if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: api.PipelineImageStream}, actual); err != nil {
+				t.Fatalf("failed to get stream: %v", err)
+			}
+			if !actual.Spec.LookupPolicy.Local {
+				t.Error("expected the stream to have local lookup enabled")
+			}
+			if is.UID != actual.UID {
+				t.Errorf("expected the returned stream to have UID %q, got %q", actual.UID, is.UID)
+			}
+		})
+	}
+}
+
+func TestEnsureNamespacePolicies(t *testing.T) {
+	config := api.NamespaceConfiguration{
+		Quota:      api.ResourceList{"requests.cpu": "20", "pods": "50"},
+		LimitRange: &api.LimitRangeConfiguration{DefaultRequest: api.ResourceList{"cpu": "100m"}, Max: api.ResourceList{"memory": "16Gi"}},
+	}
+	client := fakectrlruntimeclient.NewFakeClient(&coreapi.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "ci-operator"},
+		Spec:       coreapi.ResourceQuotaSpec{Hard: coreapi.ResourceList{"pods": resource.MustParse("1")}},
+	})
+	for i := 0; i < 2; i++ {
+		if err := ensureNamespacePolicies(context.Background(), client, "ns", config); err != nil {
+			t.Fatalf("attempt %d: unexpected error: %v", i, err)
+		}
+	}
+	quota := &coreapi.ResourceQuota{}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "ci-operator"}, quota); err != nil {
+		t.Fatalf("failed to get quota: %v", err)
+	}
+	expectedHard := coreapi.ResourceList{"requests.cpu": resource.MustParse("20"), "pods": resource.MustParse("50")}
+	if diff := cmp.Diff(expectedHard, quota.Spec.Hard); diff != "" {
+		t.Errorf("unexpected quota: %s", diff)
+	}
+	limitRange := &coreapi.LimitRange{}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "ci-operator"}, limitRange); err != nil {
+		t.Fatalf("failed to get limit range: %v", err)
+	}
+	expectedLimits := []coreapi.LimitRangeItem{{
+		Type:           coreapi.LimitTypeContainer,
+		DefaultRequest: coreapi.ResourceList{"cpu": resource.MustParse("100m")},
+		Max:            coreapi.ResourceList{"memory": resource.MustParse("16Gi")},
+	}}
+	if diff := cmp.Diff(expectedLimits, limitRange.Spec.Limits); diff != "" {
+		t.Errorf("unexpected limit range: %s", diff)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	templateapi "github.com/openshift/api/template/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/legacytemplates"
+	"github.com/openshift/ci-tools/pkg/validation"
+)
+
+// Legacy cluster tests run templates that are given to ci-operator next to
+// the configuration, named after the test. With --convert-templates, these
+// templates are converted to multi-stage tests replacing the legacy tests in
+// the configuration, so they run like every other test while their owners
+// migrate them. Templates which cannot be converted run as they are.
+
+// convertTemplates converts the templates to multi-stage tests in the
+// configuration. The configuration with the converted tests is returned
+// along with the templates which could not be converted and a report for
+// every template.
+func convertTemplates(config *api.ReleaseBuildConfiguration, templates []*templateapi.Template, lookup func(name string) (string, bool)) (*api.ReleaseBuildConfiguration, []*templateapi.Template, []legacytemplates.Report) {
+	var remaining []*templateapi.Template
+	var reports []legacytemplates.Report
+	for _, template := range templates {
+		index, test := -1, api.TestStepConfiguration{As: template.Name}
+		for i := range config.Tests {
+			if config.Tests[i].As == template.Name {
+				index, test = i, config.Tests[i]
+				break
+			}
+		}
+		// the command of legacy tests is passed to their template
+		parameters := func(name string) (string, bool) {
+			if value, ok := lookup(name); ok {
+				return value, true
+			}
+			if name == "TEST_COMMAND" && test.Commands != "" {
+				return test.Commands, true
+			}
+			return "", false
+		}
+		literal, report := legacytemplates.Convert(template, legacytemplates.ClusterProfile(test), config.Resources.RequirementsForStep(template.Name), parameters)
+		if literal != nil {
+			converted := *config
+			converted.Tests = append([]api.TestStepConfiguration{}, config.Tests...)
+			test := api.TestStepConfiguration{As: template.Name, MultiStageTestConfigurationLiteral: literal}
+			if index == -1 {
+				converted.Tests = append(converted.Tests, test)
+			} else {
+				converted.Tests[index] = test
+			}
+			if err := validation.IsValidResolvedConfiguration(&converted); err != nil {
+				report.Converted = false
+				report.Unconvertible = append(report.Unconvertible, legacytemplates.Construct{Reason: fmt.Sprintf("the converted test is invalid: %v", err)})
+			} else {
+				config = &converted
+			}
+		}
+		if report.Converted {
+			log.Printf("Template %s was converted to a multi-stage test", template.Name)
+		} else {
+			var reasons []string
+			for _, construct := range report.Unconvertible {
+				reasons = append(reasons, strings.TrimPrefix(fmt.Sprintf("%s: %s", construct.Path, construct.Reason), ": "))
+			}
+			log.Printf("warning: Template %s cannot be converted to a multi-stage test and runs as a template: %s", template.Name, strings.Join(reasons, "; "))
+			remaining = append(remaining, template)
+		}
+		reports = append(reports, report)
+	}
+	return config, remaining, reports
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	templateapi "github.com/openshift/api/template/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestConvertTemplates(t *testing.T) {
+	pod := func(image string) runtime.RawExtension {
+		return runtime.RawExtension{Raw: []byte(`{"kind":"Pod","apiVersion":"v1","spec":{"containers":[{"name":"test","image":"` + image + `","command":["/bin/bash","-c","${TEST_COMMAND}"]}]}}`)}
+	}
+	parameters := []templateapi.Parameter{{Name: "IMAGE_TESTS", Required: true}, {Name: "TEST_COMMAND", Required: true}}
+	templates := []*templateapi.Template{
+		{ObjectMeta: metav1.ObjectMeta{Name: "e2e-aws"}, Parameters: parameters, Objects: []runtime.RawExtension{pod("${IMAGE_TESTS}")}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "e2e-gcp"}, Parameters: parameters, Objects: []runtime.RawExtension{pod("quay.io/org/tests:latest")}},
+	}
+	config := &api.ReleaseBuildConfiguration{
+		InputConfiguration: api.InputConfiguration{
+			BuildRootImage: &api.BuildRootImageConfiguration{ImageStreamTagReference: &api.ImageStreamTagReference{Namespace: "ci", Name: "root", Tag: "latest"}},
+		},
+		Resources: api.ResourceConfiguration{"*": {Requests: api.ResourceList{"cpu": "100m"}}},
+		Tests: []api.TestStepConfiguration{
+			{As: "e2e-aws", Commands: "make e2e", OpenshiftInstallerClusterTestConfiguration: &api.OpenshiftInstallerClusterTestConfiguration{ClusterTestConfiguration: api.ClusterTestConfiguration{ClusterProfile: api.ClusterProfileAWS}}},
+			{As: "e2e-gcp", Commands: "make e2e", OpenshiftInstallerClusterTestConfiguration: &api.OpenshiftInstallerClusterTestConfiguration{ClusterTestConfiguration: api.ClusterTestConfiguration{ClusterProfile: api.ClusterProfileGCP}}},
+		},
+	}
+
+	converted, remaining, reports := convertTemplates(config, templates, func(string) (string, bool) { return "", false })
+	expectedTests := []api.TestStepConfiguration{
+		{As: "e2e-aws", MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+			ClusterProfile: api.ClusterProfileAWS,
+			Test: []api.LiteralTestStep{{
+				As:        "test",
+				From:      "stable:tests",
+				Commands:  "make e2e",
+				Resources: api.ResourceRequirements{Requests: api.ResourceList{"cpu": "100m"}},
+			}},
+		}},
+		config.Tests[1],
+	}
+	if diff := cmp.Diff(expectedTests, converted.Tests, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("unexpected tests: %s", diff)
+	}
+	if len(remaining) != 1 || remaining[0].Name != "e2e-gcp" {
+		t.Errorf("expected only e2e-gcp to remain a template, got %v", remaining)
+	}
+	if len(reports) != 2 || !reports[0].Converted || reports[1].Converted {
+		t.Errorf("unexpected reports: %v", reports)
+	}
+	if config.Tests[0].MultiStageTestConfigurationLiteral != nil {
+		t.Error("the original configuration was changed")
+	}
+}
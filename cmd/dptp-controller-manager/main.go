@@ -32,6 +32,7 @@ import (
 
 	"github.com/openshift/ci-tools/pkg/api/secretbootstrap"
 	"github.com/openshift/ci-tools/pkg/controller/imagepusher"
+	"github.com/openshift/ci-tools/pkg/controller/namespacettl"
 	"github.com/openshift/ci-tools/pkg/controller/promotionreconciler"
 	"github.com/openshift/ci-tools/pkg/controller/registrysyncer"
 	"github.com/openshift/ci-tools/pkg/controller/secretsyncer"
@@ -56,6 +57,7 @@ var allControllers = sets.NewString(
 	registrysyncer.ControllerName,
 	serviceaccountsecretrefresher.ControllerName,
 	imagepusher.ControllerName,
+	namespacettl.ControllerName,
 )
 
 type options struct {
@@ -523,6 +525,14 @@ func main() {
 		}
 	}
 
+	if opts.enabledControllersSet.Has(namespacettl.ControllerName) {
+		for clusterName, clusterMgr := range allManagers {
+			if err := namespacettl.AddToManager(clusterName, clusterMgr); err != nil {
+				logrus.WithError(err).Fatalf("Failed to add the %s controller to the %s cluster", namespacettl.ControllerName, clusterName)
+			}
+		}
+	}
+
 	if err := mgr.Start(ctx); err != nil {
 		logrus.WithError(err).Fatal("Manager ended with error")
 	}
@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"gopkg.in/fsnotify.v1"
 
 	coreapi "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -27,6 +28,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	coreclientset "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/test-infra/prow/secretutil"
 
 	"github.com/openshift/ci-tools/pkg/util"
 )
@@ -65,13 +67,23 @@ type options struct {
 	name    string
 	srcPath string
 	dstPath string
-	cmd     []string
-	client  coreclientset.SecretInterface
+	// sharedDir is where the writable copy of the shared dir is kept,
+	// a temporary directory by default
+	sharedDir string
+	// sealedSrcPath and sealedDstPath are like the shared dir paths but
+	// for values which must not show up in the output of the command
+	sealedSrcPath string
+	sealedDstPath string
+	// sealingKey encrypts the sealed dir secret
+	sealingKey string
+	cmd        []string
+	client     coreclientset.SecretInterface
 }
 
 func bindOptions(flag *flag.FlagSet) *options {
 	opt := &options{}
 	flag.BoolVar(&opt.dry, "dry-run", false, "Print the secret instead of creating it")
+	flag.StringVar(&opt.sharedDir, "shared-dir", "", "Directory to keep the writable copy of the shared dir in, a temporary directory by default")
 	return opt
 }
 
@@ -82,8 +94,20 @@ func (o *options) complete() error {
 	if o.srcPath = os.Getenv("SHARED_DIR"); o.srcPath == "" {
 		return fmt.Errorf("environment variable SHARED_DIR is empty")
 	}
-	o.dstPath = filepath.Join(os.TempDir(), "secret")
+	o.dstPath = o.sharedDir
+	if o.dstPath == "" {
+		o.dstPath = filepath.Join(os.TempDir(), "secret")
+	}
 	os.Setenv("SHARED_DIR", o.dstPath)
+	if o.sealedSrcPath = os.Getenv("SEALED_DIR"); o.sealedSrcPath != "" {
+		o.sealedDstPath = filepath.Join(os.TempDir(), "sealed")
+		os.Setenv("SEALED_DIR", o.sealedDstPath)
+		if o.sealingKey = os.Getenv("SEALED_KEY"); o.sealingKey == "" {
+			return fmt.Errorf("environment variable SEALED_KEY is empty")
+		}
+		// the command has no business decrypting the secret
+		os.Unsetenv("SEALED_KEY")
+	}
 	var ns string
 	if ns = os.Getenv("NAMESPACE"); ns == "" {
 		return fmt.Errorf("environment variable NAMESPACE is empty")
@@ -104,10 +128,22 @@ func (o *options) run() error {
 	if err := copyDir(o.dstPath, o.srcPath); err != nil {
 		return fmt.Errorf("failed to copy secret mount: %w", err)
 	}
+	var censorer *secretutil.ReloadingCensorer
+	if o.sealedSrcPath != "" {
+		if err := unsealDir(o.sealedDstPath, o.sealedSrcPath, o.sealingKey); err != nil {
+			return fmt.Errorf("failed to copy sealed secret mount: %w", err)
+		}
+		censorer = secretutil.NewCensorer()
+		watchCtx, stopWatching := context.WithCancel(context.Background())
+		defer stopWatching()
+		if err := watchSealedDir(watchCtx, o.sealedDstPath, censorer); err != nil {
+			return fmt.Errorf("failed to watch sealed secret mount: %w", err)
+		}
+	}
 	var errs []error
 	ctx, cancel := context.WithCancel(context.Background())
 	go uploadKubeconfig(ctx, o.client, o.name, o.dstPath, o.dry)
-	if err := execCmd(o.cmd); err != nil {
+	if err := execCmd(o.cmd, censorer); err != nil {
 		errs = append(errs, fmt.Errorf("failed to execute wrapped command: %w", err))
 	}
 	// we will upload the secret from the post-execution state, so we know
@@ -117,6 +153,11 @@ func (o *options) run() error {
 	if err := createSecret(o.client, o.name, o.dstPath, o.dry); err != nil {
 		errs = append(errs, fmt.Errorf("failed to create/update secret: %w", err))
 	}
+	if o.sealedDstPath != "" {
+		if err := createSealedSecret(o.client, o.name+"-sealed", o.sealedDstPath, o.sealingKey, o.dry); err != nil {
+			errs = append(errs, fmt.Errorf("failed to create/update sealed secret: %w", err))
+		}
+	}
 	return utilerrors.NewAggregate(errs)
 }
 
@@ -169,10 +210,129 @@ func copyDir(dst, src string) error {
 	return nil
 }
 
-func execCmd(argv []string) error {
+// unsealDir decrypts the values of the sealed dir secret mounted in src and
+// writes them to dst, where the command reads and changes them
+func unsealDir(dst, src, key string) error {
+	sealed, err := util.SecretFromDir(src)
+	if err != nil {
+		return err
+	}
+	values, err := util.UnsealValues(key, sealed.Data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0700); err != nil {
+		return err
+	}
+	for name, value := range values {
+		if err := ioutil.WriteFile(filepath.Join(dst, name), value, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchSealedDir loads the values in the sealed dir into the censorer and
+// reloads them whenever the dir changes, so that values are also censored
+// from the output of the step which writes them.
+func watchSealedDir(ctx context.Context, dir string, censorer *secretutil.ReloadingCensorer) error {
+	if err := loadSealedDir(dir, censorer); err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to construct watcher: %w", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-watcher.Events:
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				if err := loadSealedDir(dir, censorer); err != nil {
+					logrus.WithError(err).Warn("Failed to reload the sealed dir")
+				}
+			case err := <-watcher.Errors:
+				logrus.WithError(err).Warn("Failed to watch the sealed dir")
+			}
+		}
+	}()
+	return nil
+}
+
+func loadSealedDir(dir string, censorer *secretutil.ReloadingCensorer) error {
+	secret, err := util.SecretFromDir(dir)
+	if err != nil {
+		return err
+	}
+	var values [][]byte
+	for _, value := range secret.Data {
+		values = append(values, value)
+	}
+	censorer.RefreshBytes(values...)
+	return nil
+}
+
+// censoringWriter removes sealed values from everything written through it.
+// A value may be split across writes, so the end of the output which could
+// be the start of one is held back until the next write or Flush.
+type censoringWriter struct {
+	censorer *secretutil.ReloadingCensorer
+	delegate io.Writer
+	pending  []byte
+}
+
+func (w *censoringWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	w.censorer.Censor(&w.pending)
+	// a value which is not complete yet starts in the last bytes
+	held := w.censorer.LargestSecret() - 1
+	if held < 0 {
+		held = 0
+	}
+	if len(w.pending) <= held {
+		return len(p), nil
+	}
+	done := len(w.pending) - held
+	if _, err := w.delegate.Write(w.pending[:done]); err != nil {
+		return 0, err
+	}
+	w.pending = append(w.pending[:0], w.pending[done:]...)
+	return len(p), nil
+}
+
+// Flush writes the output held back
+func (w *censoringWriter) Flush() error {
+	w.censorer.Censor(&w.pending)
+	_, err := w.delegate.Write(w.pending)
+	w.pending = nil
+	return err
+}
+
+// execCmd runs the command, censoring its output if a censorer is given.
+// Censoring requires the output to be piped through this process, so we
+// avoid it when there is nothing to censor.
+func execCmd(argv []string, censorer *secretutil.ReloadingCensorer) error {
 	proc := exec.Command(argv[0], argv[1:]...)
 	proc.Stdout = os.Stdout
 	proc.Stderr = os.Stderr
+	if censorer != nil {
+		stdout := &censoringWriter{censorer: censorer, delegate: os.Stdout}
+		stderr := &censoringWriter{censorer: censorer, delegate: os.Stderr}
+		// the output is copied until the command exits, so what was held
+		// back can be written once we return
+		defer stdout.Flush()
+		defer stderr.Flush()
+		proc.Stdout, proc.Stderr = stdout, stderr
+	}
 	if proc.Env == nil {
 		// the command inherits the environment if it's nil,
 		// explicitly set it so when we change it, we add to
@@ -271,6 +431,23 @@ func createSecret(client coreclientset.SecretInterface, name, dir string, dry bo
 		return fmt.Errorf("failed to generate secret: %w", err)
 	}
 	secret.Name = name
+	return updateSecret(client, secret, dry)
+}
+
+// createSealedSecret is like createSecret, but encrypts the values
+func createSealedSecret(client coreclientset.SecretInterface, name, dir, key string, dry bool) error {
+	secret, err := util.SecretFromDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to generate secret: %w", err)
+	}
+	if secret.Data, err = util.SealValues(key, secret.Data); err != nil {
+		return fmt.Errorf("failed to seal secret: %w", err)
+	}
+	secret.Name = name
+	return updateSecret(client, secret, dry)
+}
+
+func updateSecret(client coreclientset.SecretInterface, secret *coreapi.Secret, dry bool) error {
 	if dry {
 		err := encoder.Encode(secret, os.Stdout)
 		if err != nil {
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/test-infra/prow/secretutil"
+
+	"github.com/openshift/ci-tools/pkg/util"
+)
+
+func TestCensoringWriter(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		writes   []string
+		expected string
+	}{
+		{
+			name:     "value in one write",
+			writes:   []string{"the password is hunter2\n"},
+			expected: "the password is *******\n",
+		},
+		{
+			name:     "value split across writes",
+			writes:   []string{"the password is hun", "te", "r2\n"},
+			expected: "the password is *******\n",
+		},
+		{
+			name:     "output shorter than the value",
+			writes:   []string{"hu", "nt"},
+			expected: "hunt",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			censorer := secretutil.NewCensorer()
+			censorer.Refresh("hunter2")
+			out := &bytes.Buffer{}
+			w := &censoringWriter{censorer: censorer, delegate: out}
+			for _, write := range testCase.writes {
+				input := []byte(write)
+				n, err := w.Write(input)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if n != len(input) {
+					t.Errorf("expected to write %d bytes, wrote %d", len(input), n)
+				}
+				if string(input) != write {
+					t.Errorf("input was modified: %q", string(input))
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out.String() != testCase.expected {
+				t.Errorf("expected %q, got %q", testCase.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestSealedDir(t *testing.T) {
+	key, err := util.NewSealingKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "sealed")
+	sealed, err := util.SealValues(key, map[string][]byte{"password": []byte("hunter2")})
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "password"), sealed["password"], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := unsealDir(dst, src, key); err != nil {
+		t.Fatalf("failed to unseal: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	censorer := secretutil.NewCensorer()
+	if err := watchSealedDir(ctx, dst, censorer); err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	out := &bytes.Buffer{}
+	w := &censoringWriter{censorer: censorer, delegate: out}
+	if _, err := w.Write([]byte("hunter2 correcthorse\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the step writes a value and prints it once it was reloaded
+	if err := ioutil.WriteFile(filepath.Join(dst, "token"), []byte("correcthorse"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		value := []byte("correcthorse")
+		censorer.Censor(&value)
+		return string(value) == "************", nil
+	}); err != nil {
+		t.Fatalf("value was not reloaded: %v", err)
+	}
+	if _, err := w.Write([]byte("hunter2 correcthorse\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "******* correcthorse\n******* ************\n"; out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+	other, err := util.NewSealingKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	if _, err := util.UnsealValues(other, sealed); err == nil {
+		t.Error("expected values not to be unsealed with another key")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/sirupsen/logrus"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/test-infra/prow/logrusutil"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	ctrlruntimelog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/openshift/ci-tools/pkg/dispatcher"
+)
+
+// webhookPath is where the webhook is served, the MutatingWebhookConfiguration
+// of the build farm has to point to it
+const webhookPath = "/mutate-pods"
+
+type options struct {
+	namespace     string
+	priorityClass string
+	priority      int
+	port          int
+	certDir       string
+}
+
+func gatherOptions() (*options, error) {
+	o := &options{}
+	flag.StringVar(&o.namespace, "namespace", "ci", "The namespace the pods of jobs run in.")
+	flag.StringVar(&o.priorityClass, "priority-class", "", "The priority class set on pods of tenants above their fair share. It must not preempt other pods.")
+	flag.IntVar(&o.priority, "priority", 0, "The priority of --priority-class.")
+	flag.IntVar(&o.port, "port", 8443, "The port to serve the webhook on.")
+	flag.StringVar(&o.certDir, "cert-dir", "", "The directory holding the tls.crt and tls.key the webhook is served with.")
+	flag.Parse()
+
+	var errs []error
+	if o.namespace == "" {
+		errs = append(errs, errors.New("--namespace is required"))
+	}
+	if o.priorityClass == "" {
+		errs = append(errs, errors.New("--priority-class is required"))
+	}
+	if o.certDir == "" {
+		errs = append(errs, errors.New("--cert-dir is required"))
+	}
+	return o, utilerrors.NewAggregate(errs)
+}
+
+func main() {
+	logrusutil.ComponentInit()
+	o, err := gatherOptions()
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	cfg, err := controllerruntime.GetConfig()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load cluster config")
+	}
+	mgr, err := controllerruntime.NewManager(cfg, controllerruntime.Options{
+		Namespace: o.namespace,
+		Port:      o.port,
+		CertDir:   o.certDir,
+		Logger:    ctrlruntimelog.NullLogger{},
+	})
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to construct manager")
+	}
+	// the pods of the namespace are listed for every admission, serve them
+	// from the cache of the manager
+	handler, err := dispatcher.NewFairShareAdmission(mgr.GetClient(), o.namespace, o.priorityClass, int32(o.priority))
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to construct webhook")
+	}
+	mgr.GetWebhookServer().Register(webhookPath, &webhook.Admission{Handler: handler})
+
+	logrus.Infof("Serving fair-share admission for pods in %s on :%d%s", o.namespace, o.port, webhookPath)
+	if err := mgr.Start(controllerruntime.SetupSignalHandler()); err != nil {
+		logrus.WithError(err).Fatal("Manager exited")
+	}
+}
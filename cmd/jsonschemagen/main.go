@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/jsonschema"
+)
+
+func main() {
+	var apiDir, outputDir string
+	flag.StringVar(&apiDir, "api-dir", "./pkg/api", "The directory of the package declaring the configuration types.")
+	flag.StringVar(&outputDir, "output-dir", "./pkg/jsonschema/schemas", "The directory to write the schemas to.")
+	flag.Parse()
+
+	descriptions, err := jsonschema.Descriptions(apiDir)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to read type descriptions")
+	}
+	for _, name := range jsonschema.Names() {
+		schema := jsonschema.Generate(name, jsonschema.Roots[name], descriptions)
+		raw, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			logrus.WithError(err).Fatalf("Failed to marshal schema %s", name)
+		}
+		if err := ioutil.WriteFile(filepath.Join(outputDir, name+".json"), append(raw, '\n'), 0644); err != nil {
+			logrus.WithError(err).Fatalf("Failed to write schema %s", name)
+		}
+	}
+}
@@ -32,10 +32,18 @@ var (
 		},
 		[]string{"job_name", "type", "state", "reason", "cluster"},
 	)
+	stepErrorRate = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ci_operator_step_error_rate",
+			Help: "number of errors of steps, sorted by label/type",
+		},
+		[]string{"job_name", "type", "state", "reason", "cluster", "step"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(errorRate)
+	prometheus.MustRegister(stepErrorRate)
 }
 
 type options struct {
@@ -104,6 +112,18 @@ func withErrorRate(request *results.Request) {
 	errorRate.With(labels).Inc()
 }
 
+func withStepErrorRate(request *results.Request) {
+	labels := prometheus.Labels{
+		"job_name": request.JobName,
+		"type":     request.Type,
+		"state":    request.State,
+		"reason":   request.Reason,
+		"cluster":  request.Cluster,
+		"step":     request.Step,
+	}
+	stepErrorRate.With(labels).Inc()
+}
+
 type validator interface {
 	Validate(username, password string) bool
 }
@@ -119,7 +139,32 @@ func loginHandler(validator validator, next http.Handler) http.Handler {
 	})
 }
 
+// handleCIOperatorResult records the results of jobs. Results of steps are
+// recorded apart from them, even when older clients send them here.
 func handleCIOperatorResult() http.HandlerFunc {
+	return handleRequest(func(request *results.Request) error {
+		if request.Step != "" {
+			withStepErrorRate(request)
+		} else {
+			withErrorRate(request)
+		}
+		return nil
+	})
+}
+
+// handleCIOperatorStepResult records the results of steps
+func handleCIOperatorStepResult() http.HandlerFunc {
+	return handleRequest(func(request *results.Request) error {
+		if request.Step == "" {
+			return fmt.Errorf("step field in request is empty")
+		}
+		withStepErrorRate(request)
+		return nil
+	})
+}
+
+// handleRequest validates requests and has them recorded
+func handleRequest(record func(request *results.Request) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
@@ -140,7 +185,10 @@ func handleCIOperatorResult() http.HandlerFunc {
 			return
 		}
 
-		withErrorRate(request)
+		if err := record(request); err != nil {
+			handleError(w, err)
+			return
+		}
 
 		w.WriteHeader(http.StatusOK)
 
@@ -166,7 +214,8 @@ func main() {
 
 	validator := &multi{delegates: []validator{&passwdFile{file: o.passwdFile}}}
 
-	http.Handle("/result", loginHandler(validator, handleCIOperatorResult()))
+	http.Handle(results.ResultPath, loginHandler(validator, handleCIOperatorResult()))
+	http.Handle(results.StepResultPath, loginHandler(validator, handleCIOperatorStepResult()))
 	metrics.ExposeMetrics("result-aggregator", prowConfig.PushGateway{}, flagutil.DefaultMetricsPort)
 
 	interrupts.ListenAndServe(&http.Server{Addr: o.address}, o.gracePeriod)
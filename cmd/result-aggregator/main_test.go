@@ -7,9 +7,12 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestValidator(t *testing.T) {
@@ -148,3 +151,62 @@ func TestLoginHandler(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleCIOperatorResult(t *testing.T) {
+	job := `"job_name":"job","type":"presubmit","state":"failed","reason":"because","cluster":"build01"`
+	testCases := []struct {
+		name               string
+		handler            http.HandlerFunc
+		body               string
+		expectedStatusCode int
+		expectedJobs       float64
+		expectedSteps      float64
+	}{
+		{
+			name:               "result of a job",
+			handler:            handleCIOperatorResult(),
+			body:               "{" + job + "}",
+			expectedStatusCode: http.StatusOK,
+			expectedJobs:       1,
+		},
+		{
+			name:               "result of a step sent as a job result",
+			handler:            handleCIOperatorResult(),
+			body:               "{" + job + `,"step":"e2e"}`,
+			expectedStatusCode: http.StatusOK,
+			expectedSteps:      1,
+		},
+		{
+			name:               "result of a step",
+			handler:            handleCIOperatorStepResult(),
+			body:               "{" + job + `,"step":"e2e"}`,
+			expectedStatusCode: http.StatusOK,
+			expectedSteps:      1,
+		},
+		{
+			name:               "step result without a step",
+			handler:            handleCIOperatorStepResult(),
+			body:               "{" + job + "}",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errorRate.Reset()
+			stepErrorRate.Reset()
+			responseRecorder := httptest.NewRecorder()
+			tc.handler.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body)))
+			if diff := cmp.Diff(tc.expectedStatusCode, responseRecorder.Code); diff != "" {
+				t.Errorf("unexpected status code: %s", diff)
+			}
+			jobLabels := prometheus.Labels{"job_name": "job", "type": "presubmit", "state": "failed", "reason": "because", "cluster": "build01"}
+			if actual := testutil.ToFloat64(errorRate.With(jobLabels)); actual != tc.expectedJobs {
+				t.Errorf("expected %v job results, got %v", tc.expectedJobs, actual)
+			}
+			jobLabels["step"] = "e2e"
+			if actual := testutil.ToFloat64(stepErrorRate.With(jobLabels)); actual != tc.expectedSteps {
+				t.Errorf("expected %v step results, got %v", tc.expectedSteps, actual)
+			}
+		})
+	}
+}
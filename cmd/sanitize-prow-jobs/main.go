@@ -104,30 +104,42 @@ func determinizeJobs(prowJobConfigDir string, config *dispatcher.Config) error {
 }
 
 func defaultJobConfig(jc *prowconfig.JobConfig, path string, config *dispatcher.Config) error {
+	labels := config.SchedulingLabels(path)
 	for k := range jc.PresubmitsStatic {
 		for idx := range jc.PresubmitsStatic[k] {
-			cluster, err := config.GetClusterForJob(jc.PresubmitsStatic[k][idx].JobBase, path)
-			if err != nil {
+			if err := defaultJobBase(&jc.PresubmitsStatic[k][idx].JobBase, path, config, labels); err != nil {
 				return err
 			}
-			jc.PresubmitsStatic[k][idx].JobBase.Cluster = string(cluster)
 		}
 	}
 	for k := range jc.PostsubmitsStatic {
 		for idx := range jc.PostsubmitsStatic[k] {
-			cluster, err := config.GetClusterForJob(jc.PostsubmitsStatic[k][idx].JobBase, path)
-			if err != nil {
+			if err := defaultJobBase(&jc.PostsubmitsStatic[k][idx].JobBase, path, config, labels); err != nil {
 				return err
 			}
-			jc.PostsubmitsStatic[k][idx].JobBase.Cluster = string(cluster)
 		}
 	}
 	for idx := range jc.Periodics {
-		cluster, err := config.GetClusterForJob(jc.Periodics[idx].JobBase, path)
-		if err != nil {
+		if err := defaultJobBase(&jc.Periodics[idx].JobBase, path, config, labels); err != nil {
 			return err
 		}
-		jc.Periodics[idx].JobBase.Cluster = string(cluster)
+	}
+	return nil
+}
+
+func defaultJobBase(jobBase *prowconfig.JobBase, path string, config *dispatcher.Config, schedulingLabels map[string]string) error {
+	cluster, err := config.GetClusterForJob(*jobBase, path)
+	if err != nil {
+		return err
+	}
+	jobBase.Cluster = string(cluster)
+	if len(schedulingLabels) > 0 && cluster != "" {
+		if jobBase.Labels == nil {
+			jobBase.Labels = map[string]string{}
+		}
+		for k, v := range schedulingLabels {
+			jobBase.Labels[k] = v
+		}
 	}
 	return nil
 }
@@ -3,6 +3,8 @@ package main
 import (
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+
 	"k8s.io/test-infra/prow/config"
 
 	"github.com/openshift/ci-tools/pkg/dispatcher"
@@ -46,3 +48,28 @@ func TestDefaultJobConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestDefaultJobConfigSchedulingLabels(t *testing.T) {
+	jc := &config.JobConfig{
+		PresubmitsStatic: map[string][]config.Presubmit{
+			"org/repo": {{JobBase: config.JobBase{Agent: "kubernetes", Labels: map[string]string{"existing": "true"}}}, {JobBase: config.JobBase{Agent: "jenkins"}}},
+		},
+		Periodics: []config.Periodic{{JobBase: config.JobBase{Agent: "kubernetes"}}},
+	}
+
+	config := &dispatcher.Config{Default: "api.ci", FairShare: &dispatcher.FairShare{Weights: map[string]int{"org": 2}}}
+	if err := defaultJobConfig(jc, "ci-operator/jobs/org/repo/org-repo-master-presubmits.yaml", config); err != nil {
+		t.Fatalf("failed default job config: %v", err)
+	}
+
+	expected := map[string]string{"existing": "true", dispatcher.TenantOrgLabel: "org", dispatcher.TenantRepoLabel: "repo", dispatcher.TenantWeightLabel: "2"}
+	if diff := cmp.Diff(expected, jc.PresubmitsStatic["org/repo"][0].Labels); diff != "" {
+		t.Errorf("unexpected labels: %s", diff)
+	}
+	if labels := jc.PresubmitsStatic["org/repo"][1].Labels; labels != nil {
+		t.Errorf("expected no labels on jobs not dispatched to a cluster, got %v", labels)
+	}
+	if diff := cmp.Diff(expected[dispatcher.TenantOrgLabel], jc.Periodics[0].Labels[dispatcher.TenantOrgLabel]); diff != "" {
+		t.Errorf("unexpected tenant: %s", diff)
+	}
+}
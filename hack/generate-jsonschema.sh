@@ -0,0 +1,7 @@
+#!/usr/bin/bash
+
+set -euo pipefail
+
+cd $(dirname $0)/..
+
+go run ./cmd/jsonschemagen
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Condition is a parsed `if` expression of a step. It is a disjunction of
+// conjunctions of comparisons, as `&&` binds tighter than `||`.
+type Condition [][]comparison
+
+// comparison tests the value of a variable for (in)equality
+type comparison struct {
+	variable string
+	negated  bool
+	value    string
+}
+
+var variableRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ParseCondition parses an expression comparing variables to values, like
+// `CLUSTER_TYPE == aws || CLUSTER_TYPE == "gcp" && FIPS_ENABLED != true`.
+// Values containing spaces or any of the characters "=!&| need to be quoted.
+func ParseCondition(expression string) (Condition, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	var condition Condition
+	var conjunction []comparison
+	for len(tokens) > 0 {
+		if len(tokens) < 3 {
+			return nil, fmt.Errorf("incomplete comparison: %s", strings.Join(tokens, " "))
+		}
+		variable, operator, value := tokens[0], tokens[1], tokens[2]
+		if !variableRegexp.MatchString(variable) {
+			return nil, fmt.Errorf("invalid variable name %q", variable)
+		}
+		if operator != "==" && operator != "!=" {
+			return nil, fmt.Errorf("expected == or != after %s, got %q", variable, operator)
+		}
+		if isOperator(value) {
+			return nil, fmt.Errorf("expected a value after %s %s, got %q", variable, operator, value)
+		}
+		if strings.HasPrefix(value, `"`) {
+			if value, err = strconv.Unquote(value); err != nil {
+				return nil, fmt.Errorf("invalid quoted value %s: %w", tokens[2], err)
+			}
+		}
+		conjunction = append(conjunction, comparison{variable: variable, negated: operator == "!=", value: value})
+		tokens = tokens[3:]
+		if len(tokens) == 0 {
+			break
+		}
+		switch tokens[0] {
+		case "&&":
+		case "||":
+			condition = append(condition, conjunction)
+			conjunction = nil
+		default:
+			return nil, fmt.Errorf("expected && or || after %s %s %s, got %q", variable, operator, value, tokens[0])
+		}
+		tokens = tokens[1:]
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("expression ends with an operator")
+		}
+	}
+	return append(condition, conjunction), nil
+}
+
+func isOperator(token string) bool {
+	switch token {
+	case "==", "!=", "&&", "||":
+		return true
+	}
+	return false
+}
+
+// tokenize splits the expression into variables, operators and values,
+// keeping quoted values, including their quotes, in a single token
+func tokenize(expression string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expression); {
+		switch c := expression[i]; {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(expression) && expression[end] != '"'; end++ {
+				if expression[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expression) {
+				return nil, fmt.Errorf("unterminated quoted value: %s", expression[i:])
+			}
+			tokens = append(tokens, expression[i:end+1])
+			i = end + 1
+		case i+1 < len(expression) && isOperator(expression[i:i+2]):
+			tokens = append(tokens, expression[i:i+2])
+			i += 2
+		default:
+			end := i
+			for ; end < len(expression) && !unicode.IsSpace(rune(expression[end])) && !strings.ContainsRune(`"=!&|`, rune(expression[end])); end++ {
+			}
+			if end == i {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, expression[i:end])
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// Evaluate determines whether the condition holds for the values of the
+// variables. Variables without a value compare as empty strings.
+func (c Condition) Evaluate(variables map[string]string) bool {
+	for _, conjunction := range c {
+		holds := true
+		for _, comparison := range conjunction {
+			if (variables[comparison.variable] == comparison.value) == comparison.negated {
+				holds = false
+				break
+			}
+		}
+		if holds {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseCondition(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		expression  string
+		expected    Condition
+		expectedErr string
+	}{
+		{
+			name:       "single comparison",
+			expression: "CLUSTER_TYPE == aws",
+			expected:   Condition{{{variable: "CLUSTER_TYPE", value: "aws"}}},
+		},
+		{
+			name:       "&& binds tighter than ||",
+			expression: `CLUSTER_TYPE==aws || CLUSTER_TYPE == "gcp" && FIPS_ENABLED != true`,
+			expected: Condition{
+				{{variable: "CLUSTER_TYPE", value: "aws"}},
+				{{variable: "CLUSTER_TYPE", value: "gcp"}, {variable: "FIPS_ENABLED", negated: true, value: "true"}},
+			},
+		},
+		{
+			name:       "quoted values may contain spaces and operators",
+			expression: `TEST_ARGS == "-run 'a || b'" && EMPTY == ""`,
+			expected:   Condition{{{variable: "TEST_ARGS", value: "-run 'a || b'"}, {variable: "EMPTY", value: ""}}},
+		},
+		{
+			name:        "empty expression",
+			expression:  " ",
+			expectedErr: "empty expression",
+		},
+		{
+			name:        "assignment instead of comparison",
+			expression:  "CLUSTER_TYPE = aws",
+			expectedErr: `unexpected character '='`,
+		},
+		{
+			name:        "missing value",
+			expression:  "CLUSTER_TYPE == && A == b",
+			expectedErr: `expected a value after CLUSTER_TYPE ==, got "&&"`,
+		},
+		{
+			name:        "missing operator between comparisons",
+			expression:  "A == b C == d",
+			expectedErr: `expected && or || after A == b, got "C"`,
+		},
+		{
+			name:        "trailing operator",
+			expression:  "A == b ||",
+			expectedErr: "expression ends with an operator",
+		},
+		{
+			name:        "incomplete comparison",
+			expression:  "A ==",
+			expectedErr: "incomplete comparison: A ==",
+		},
+		{
+			name:        "invalid variable",
+			expression:  "1A == b",
+			expectedErr: `invalid variable name "1A"`,
+		},
+		{
+			name:        "unterminated quote",
+			expression:  `A == "b`,
+			expectedErr: `unterminated quoted value: "b`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := ParseCondition(testCase.expression)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, actual, cmp.AllowUnexported(comparison{})); diff != "" {
+				t.Errorf("unexpected condition: %s", diff)
+			}
+		})
+	}
+}
+
+func TestConditionEvaluate(t *testing.T) {
+	condition, err := ParseCondition("CLUSTER_TYPE == aws || CLUSTER_TYPE == gcp && FIPS_ENABLED != true")
+	if err != nil {
+		t.Fatalf("failed to parse condition: %v", err)
+	}
+	var testCases = []struct {
+		name      string
+		variables map[string]string
+		expected  bool
+	}{
+		{
+			name:      "first alternative holds",
+			variables: map[string]string{"CLUSTER_TYPE": "aws", "FIPS_ENABLED": "true"},
+			expected:  true,
+		},
+		{
+			name:      "second alternative holds",
+			variables: map[string]string{"CLUSTER_TYPE": "gcp", "FIPS_ENABLED": "false"},
+			expected:  true,
+		},
+		{
+			name:      "unset variables compare as empty",
+			variables: map[string]string{"CLUSTER_TYPE": "gcp"},
+			expected:  true,
+		},
+		{
+			name:      "second alternative does not hold",
+			variables: map[string]string{"CLUSTER_TYPE": "gcp", "FIPS_ENABLED": "true"},
+		},
+		{
+			name:      "no alternative holds",
+			variables: map[string]string{"CLUSTER_TYPE": "azure4"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := condition.Evaluate(testCase.variables); actual != testCase.expected {
+				t.Errorf("expected %t, got %t", testCase.expected, actual)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package api
 
-import "os"
+import (
+	"context"
+	"os"
+)
 
 // prowArtifactsEnv is the directory Prow wants us to put artifacts into for upload
 const prowArtifactsEnv string = "ARTIFACTS"
@@ -8,3 +11,21 @@ const prowArtifactsEnv string = "ARTIFACTS"
 func Artifacts() (string, bool) {
 	return os.LookupEnv(prowArtifactsEnv)
 }
+
+type artifactsKey struct{}
+
+// WithArtifacts returns a context whose artifacts are written to the
+// directory instead of the one Prow set for the process, so that jobs
+// executed by the same process keep their artifacts apart
+func WithArtifacts(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, artifactsKey{}, dir)
+}
+
+// ArtifactsFor returns the directory artifacts are written to in the
+// context, falling back to the one Prow set for the process
+func ArtifactsFor(ctx context.Context) (string, bool) {
+	if dir, ok := ctx.Value(artifactsKey{}).(string); ok {
+		return dir, true
+	}
+	return Artifacts()
+}
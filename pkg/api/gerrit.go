@@ -0,0 +1,134 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+// gerritChangeRef matches the refs Gerrit publishes the patchsets of changes
+// as, e.g. refs/changes/34/1234/5 for the fifth patchset of change 1234
+var gerritChangeRef = regexp.MustCompile(`^refs/changes/\d+/(\d+)/(\d+)$`)
+
+// IsGerrit determines whether the refs are of a project hosted on Gerrit.
+// Prow records the URL of the Gerrit instance as the org of such refs and
+// the ref of its patchset for every change under test.
+func IsGerrit(refs *prowapi.Refs) bool {
+	if refs == nil {
+		return false
+	}
+	if strings.Contains(refs.Org, "://") {
+		return true
+	}
+	for _, pull := range refs.Pulls {
+		if gerritChangeRef.MatchString(pull.Ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// gerritHost returns the URL of the Gerrit instance of the refs
+func gerritHost(refs *prowapi.Refs) string {
+	host := strings.TrimSuffix(refs.Org, "/")
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	return host
+}
+
+// RepoURL returns the URL the repository of the refs is browsed at
+func RepoURL(refs *prowapi.Refs) string {
+	if !IsGerrit(refs) {
+		return fmt.Sprintf("https://github.com/%s/%s", refs.Org, refs.Repo)
+	}
+	if refs.RepoLink != "" {
+		return refs.RepoLink
+	}
+	return fmt.Sprintf("%s/%s", gerritHost(refs), refs.Repo)
+}
+
+// CloneURI returns the URI the repository of the refs is cloned from
+func CloneURI(refs *prowapi.Refs) string {
+	if refs.CloneURI != "" {
+		return refs.CloneURI
+	}
+	if IsGerrit(refs) {
+		return fmt.Sprintf("%s/%s", gerritHost(refs), refs.Repo)
+	}
+	return fmt.Sprintf("https://github.com/%s/%s.git", refs.Org, refs.Repo)
+}
+
+// PullURL returns the URL of the pull request or Gerrit change
+func PullURL(refs *prowapi.Refs, pull prowapi.Pull) string {
+	if pull.Link != "" {
+		return pull.Link
+	}
+	if IsGerrit(refs) {
+		return fmt.Sprintf("%s/c/%s/+/%d", gerritHost(refs), refs.Repo, pull.Number)
+	}
+	return fmt.Sprintf("https://github.com/%s/%s/pull/%d", refs.Org, refs.Repo, pull.Number)
+}
+
+// PullRef returns the ref the pull request or Gerrit change is fetched from
+func PullRef(pull prowapi.Pull) string {
+	if pull.Ref != "" {
+		return pull.Ref
+	}
+	return fmt.Sprintf("refs/pull/%d/head", pull.Number)
+}
+
+// GerritChange is the patchset of a Gerrit change under test
+type GerritChange struct {
+	Number   int
+	Patchset int
+	Ref      string
+	URL      string
+}
+
+// GerritChanges returns the patchsets of the Gerrit changes under test, if
+// the refs are of a project hosted on Gerrit
+func GerritChanges(refs *prowapi.Refs) []GerritChange {
+	if !IsGerrit(refs) {
+		return nil
+	}
+	var changes []GerritChange
+	for _, pull := range refs.Pulls {
+		change := GerritChange{Number: pull.Number, Ref: pull.Ref, URL: PullURL(refs, pull)}
+		if match := gerritChangeRef.FindStringSubmatch(pull.Ref); match != nil {
+			change.Number, _ = strconv.Atoi(match[1])
+			change.Patchset, _ = strconv.Atoi(match[2])
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// GerritEnv exposes the Gerrit change under test to the commands of tests
+// with the variables the Gerrit Trigger plugin of Jenkins defines, so that
+// scripts written for it keep working
+func GerritEnv(refs *prowapi.Refs) map[string]string {
+	if !IsGerrit(refs) {
+		return nil
+	}
+	host := gerritHost(refs)
+	env := map[string]string{
+		"GERRIT_HOST":    strings.SplitN(host, "://", 2)[1],
+		"GERRIT_PROJECT": refs.Repo,
+		"GERRIT_BRANCH":  refs.BaseRef,
+	}
+	if changes := GerritChanges(refs); len(changes) > 0 {
+		change := changes[0]
+		env["GERRIT_CHANGE_NUMBER"] = strconv.Itoa(change.Number)
+		env["GERRIT_CHANGE_URL"] = change.URL
+		env["GERRIT_REFSPEC"] = change.Ref
+		if change.Patchset != 0 {
+			env["GERRIT_PATCHSET_NUMBER"] = strconv.Itoa(change.Patchset)
+		}
+		env["GERRIT_PATCHSET_REVISION"] = refs.Pulls[0].SHA
+	}
+	return env
+}
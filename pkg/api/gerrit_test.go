@@ -0,0 +1,78 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+func TestGerritRefs(t *testing.T) {
+	gerrit := &prowapi.Refs{
+		Org:     "https://review.example.com/",
+		Repo:    "platform/build",
+		BaseRef: "main",
+		Pulls:   []prowapi.Pull{{Number: 1234, SHA: "abc", Ref: "refs/changes/34/1234/5"}},
+	}
+	github := &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", Pulls: []prowapi.Pull{{Number: 1, SHA: "def"}}}
+	for _, tc := range []struct {
+		name            string
+		refs            *prowapi.Refs
+		expectedGerrit  bool
+		expectedRepo    string
+		expectedClone   string
+		expectedPull    string
+		expectedPullRef string
+		expectedEnv     map[string]string
+		expectedChanges []GerritChange
+	}{{
+		name:            "GitHub",
+		refs:            github,
+		expectedRepo:    "https://github.com/org/repo",
+		expectedClone:   "https://github.com/org/repo.git",
+		expectedPull:    "https://github.com/org/repo/pull/1",
+		expectedPullRef: "refs/pull/1/head",
+	}, {
+		name:            "Gerrit",
+		refs:            gerrit,
+		expectedGerrit:  true,
+		expectedRepo:    "https://review.example.com/platform/build",
+		expectedClone:   "https://review.example.com/platform/build",
+		expectedPull:    "https://review.example.com/c/platform/build/+/1234",
+		expectedPullRef: "refs/changes/34/1234/5",
+		expectedEnv: map[string]string{
+			"GERRIT_HOST":              "review.example.com",
+			"GERRIT_PROJECT":           "platform/build",
+			"GERRIT_BRANCH":            "main",
+			"GERRIT_CHANGE_NUMBER":     "1234",
+			"GERRIT_CHANGE_URL":        "https://review.example.com/c/platform/build/+/1234",
+			"GERRIT_REFSPEC":           "refs/changes/34/1234/5",
+			"GERRIT_PATCHSET_NUMBER":   "5",
+			"GERRIT_PATCHSET_REVISION": "abc",
+		},
+		expectedChanges: []GerritChange{{Number: 1234, Patchset: 5, Ref: "refs/changes/34/1234/5", URL: "https://review.example.com/c/platform/build/+/1234"}},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := IsGerrit(tc.refs); actual != tc.expectedGerrit {
+				t.Errorf("expected Gerrit: %t, got %t", tc.expectedGerrit, actual)
+			}
+			for _, item := range []struct{ what, expected, actual string }{
+				{what: "repository URL", expected: tc.expectedRepo, actual: RepoURL(tc.refs)},
+				{what: "clone URI", expected: tc.expectedClone, actual: CloneURI(tc.refs)},
+				{what: "pull URL", expected: tc.expectedPull, actual: PullURL(tc.refs, tc.refs.Pulls[0])},
+				{what: "pull ref", expected: tc.expectedPullRef, actual: PullRef(tc.refs.Pulls[0])},
+			} {
+				if item.actual != item.expected {
+					t.Errorf("expected %s %q, got %q", item.what, item.expected, item.actual)
+				}
+			}
+			if diff := cmp.Diff(tc.expectedEnv, GerritEnv(tc.refs)); diff != "" {
+				t.Errorf("unexpected env: %s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedChanges, GerritChanges(tc.refs)); diff != "" {
+				t.Errorf("unexpected changes: %s", diff)
+			}
+		})
+	}
+}
@@ -192,6 +192,90 @@ func (l *imagesReadyLink) UnsatisfiableError() string {
 	return ""
 }
 
+// AttestationsLink describes the bills of materials and provenance
+// generated for the images built by the job.
+func AttestationsLink() StepLink {
+	return &attestationsLink{}
+}
+
+type attestationsLink struct{}
+
+func (l *attestationsLink) SatisfiedBy(other StepLink) bool {
+	switch other.(type) {
+	case *attestationsLink:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *attestationsLink) UnsatisfiableError() string {
+	return ""
+}
+
+// VulnerabilityScanLink describes the scan of the images built by the job
+// for known vulnerabilities.
+func VulnerabilityScanLink() StepLink {
+	return &vulnerabilityScanLink{}
+}
+
+type vulnerabilityScanLink struct{}
+
+func (l *vulnerabilityScanLink) SatisfiedBy(other StepLink) bool {
+	switch other.(type) {
+	case *vulnerabilityScanLink:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *vulnerabilityScanLink) UnsatisfiableError() string {
+	return ""
+}
+
+// TagSpecificationSnapshotLink describes the images the tags of the
+// tag specification resolved to when the job started.
+func TagSpecificationSnapshotLink() StepLink {
+	return &tagSpecificationSnapshotLink{}
+}
+
+type tagSpecificationSnapshotLink struct{}
+
+func (l *tagSpecificationSnapshotLink) SatisfiedBy(other StepLink) bool {
+	switch other.(type) {
+	case *tagSpecificationSnapshotLink:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *tagSpecificationSnapshotLink) UnsatisfiableError() string {
+	return ""
+}
+
+// FIPSCheckLink describes the check of the images built by the job for
+// cryptography which is not FIPS-compliant.
+func FIPSCheckLink() StepLink {
+	return &fipsCheckLink{}
+}
+
+type fipsCheckLink struct{}
+
+func (l *fipsCheckLink) SatisfiedBy(other StepLink) bool {
+	switch other.(type) {
+	case *fipsCheckLink:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *fipsCheckLink) UnsatisfiableError() string {
+	return ""
+}
+
 func RPMRepoLink() StepLink {
 	return &rpmRepoLink{}
 }
@@ -356,6 +440,86 @@ func BuildPartialGraph(steps []Step, names []string) ([]*StepNode, error) {
 	return BuildGraph(targeted), nil
 }
 
+// StepExplanation describes why a step is or is not part of
+// the graph built for a set of targets.
+type StepExplanation struct {
+	// Name is the name of the step.
+	Name string `json:"name"`
+	// Included determines whether the step runs for the targets.
+	Included bool `json:"included"`
+	// Reason explains why the step is included or pruned.
+	Reason string `json:"reason"`
+	// Chain is the dependency chain through which a target requires
+	// the step, from the step itself to the target.
+	Chain []string `json:"chain,omitempty"`
+}
+
+// ExplainPartialGraph explains for every step why BuildPartialGraph
+// includes it in or prunes it from the graph for the named steps.
+func ExplainPartialGraph(steps []Step, names []string) ([]StepExplanation, error) {
+	if _, err := BuildPartialGraph(steps, append([]string{}, names...)); err != nil {
+		return nil, err
+	}
+	explanations := make([]StepExplanation, len(steps))
+	if len(names) == 0 {
+		for i, step := range steps {
+			explanations[i] = StepExplanation{Name: step.Name(), Included: true, Reason: "all steps run when no target is selected", Chain: []string{step.Name()}}
+		}
+		return explanations, nil
+	}
+
+	// walk the dependencies breadth-first from the targets, so
+	// that every step is explained by its shortest chain
+	targets := sets.NewString(names...)
+	included := make([]bool, len(steps))
+	parents := make([]int, len(steps))
+	var queue []int
+	for i, step := range steps {
+		if targets.Has(step.Name()) {
+			targets.Delete(step.Name())
+			included[i], parents[i] = true, -1
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for i, step := range steps {
+			if !included[i] && HasAnyLinks(steps[current].Requires(), step.Creates()) {
+				included[i], parents[i] = true, current
+				queue = append(queue, i)
+			}
+		}
+	}
+
+	for i, step := range steps {
+		explanation := StepExplanation{Name: step.Name(), Included: included[i]}
+		var consumers []string
+		for j, other := range steps {
+			if i != j && included[j] == included[i] && HasAnyLinks(other.Requires(), step.Creates()) {
+				consumers = append(consumers, other.Name())
+			}
+		}
+		switch {
+		case included[i] && parents[i] == -1:
+			explanation.Reason = "selected as a target"
+		case included[i]:
+			explanation.Reason = fmt.Sprintf("dependency of %s", strings.Join(consumers, ", "))
+		case len(consumers) > 0:
+			explanation.Reason = fmt.Sprintf("pruned because it is only a dependency of pruned steps %s", strings.Join(consumers, ", "))
+		default:
+			explanation.Reason = "pruned because no consumer"
+		}
+		if included[i] {
+			for j := i; j != -1; j = parents[j] {
+				explanation.Chain = append(explanation.Chain, steps[j].Name())
+			}
+		}
+		explanations[i] = explanation
+	}
+	return explanations, nil
+}
+
 // ValidateGraph performs validations on each step in the graph once.
 func ValidateGraph(nodes []*StepNode) []error {
 	var errs []error
@@ -469,6 +633,9 @@ func mergeSteps(into, from CIOperatorStepDetails) CIOperatorStepDetails {
 	if into.LogURL == "" {
 		into.LogURL = from.LogURL
 	}
+	if into.ResourceUsage == nil {
+		into.ResourceUsage = from.ResourceUsage
+	}
 	if into.Failed == nil {
 		into.Failed = from.Failed
 	}
@@ -494,6 +661,19 @@ type CIOperatorStepDetailInfo struct {
 	Manifests    []ctrlruntimeclient.Object `json:"manifests,omitempty"`
 	LogURL       string                     `json:"log_url,omitempty"`
 	Failed       *bool                      `json:"failed,omitempty"`
+	// ResourceUsage is the observed resource usage per container, if
+	// metrics were available while the step ran
+	ResourceUsage map[string]ContainerResourceUsage `json:"resource_usage,omitempty"`
+}
+
+// ContainerResourceUsage describes the resources one container of a step used
+type ContainerResourceUsage struct {
+	// Peak is the highest usage observed while the container ran
+	Peak ResourceList `json:"peak"`
+	// Requested is what the container requested
+	Requested ResourceList `json:"requested,omitempty"`
+	// Suggested are requests fitting the observed peak usage
+	Suggested ResourceList `json:"suggested,omitempty"`
 }
 
 func (c *CIOperatorStepDetailInfo) UnmarshalJSON(data []byte) error {
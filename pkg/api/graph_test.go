@@ -232,6 +232,101 @@ func TestBuildGraph(t *testing.T) {
 	}
 }
 
+func TestExplainPartialGraph(t *testing.T) {
+	root := &fakeStep{
+		name:    "root",
+		creates: []StepLink{InternalImageLink(PipelineImageStreamTagReferenceRoot)},
+	}
+	src := &fakeStep{
+		name:     "src",
+		requires: []StepLink{InternalImageLink(PipelineImageStreamTagReferenceRoot)},
+		creates:  []StepLink{InternalImageLink(PipelineImageStreamTagReferenceSource)},
+	}
+	bin := &fakeStep{
+		name:     "bin",
+		requires: []StepLink{InternalImageLink(PipelineImageStreamTagReferenceSource)},
+		creates:  []StepLink{InternalImageLink(PipelineImageStreamTagReferenceBinaries)},
+	}
+	unit := &fakeStep{
+		name:     "unit",
+		requires: []StepLink{InternalImageLink(PipelineImageStreamTagReferenceSource)},
+	}
+	rpm := &fakeStep{
+		name:     "rpm",
+		requires: []StepLink{InternalImageLink(PipelineImageStreamTagReferenceBinaries)},
+		creates:  []StepLink{InternalImageLink(PipelineImageStreamTagReferenceRPMs)},
+	}
+	images := &fakeStep{
+		name:     "images",
+		requires: []StepLink{InternalImageLink(PipelineImageStreamTagReferenceRPMs)},
+	}
+	steps := []Step{root, src, bin, unit, rpm, images}
+
+	var testCases = []struct {
+		name        string
+		targets     []string
+		expected    []StepExplanation
+		expectedErr string
+	}{
+		{
+			name:    "no targets",
+			targets: nil,
+			expected: []StepExplanation{
+				{Name: "root", Included: true, Reason: "all steps run when no target is selected", Chain: []string{"root"}},
+				{Name: "src", Included: true, Reason: "all steps run when no target is selected", Chain: []string{"src"}},
+				{Name: "bin", Included: true, Reason: "all steps run when no target is selected", Chain: []string{"bin"}},
+				{Name: "unit", Included: true, Reason: "all steps run when no target is selected", Chain: []string{"unit"}},
+				{Name: "rpm", Included: true, Reason: "all steps run when no target is selected", Chain: []string{"rpm"}},
+				{Name: "images", Included: true, Reason: "all steps run when no target is selected", Chain: []string{"images"}},
+			},
+		},
+		{
+			name:    "single target",
+			targets: []string{"unit"},
+			expected: []StepExplanation{
+				{Name: "root", Included: true, Reason: "dependency of src", Chain: []string{"root", "src", "unit"}},
+				{Name: "src", Included: true, Reason: "dependency of unit", Chain: []string{"src", "unit"}},
+				{Name: "bin", Reason: "pruned because it is only a dependency of pruned steps rpm"},
+				{Name: "unit", Included: true, Reason: "selected as a target", Chain: []string{"unit"}},
+				{Name: "rpm", Reason: "pruned because it is only a dependency of pruned steps images"},
+				{Name: "images", Reason: "pruned because no consumer"},
+			},
+		},
+		{
+			name:    "multiple targets",
+			targets: []string{"unit", "rpm"},
+			expected: []StepExplanation{
+				{Name: "root", Included: true, Reason: "dependency of src", Chain: []string{"root", "src", "unit"}},
+				{Name: "src", Included: true, Reason: "dependency of bin, unit", Chain: []string{"src", "unit"}},
+				{Name: "bin", Included: true, Reason: "dependency of rpm", Chain: []string{"bin", "rpm"}},
+				{Name: "unit", Included: true, Reason: "selected as a target", Chain: []string{"unit"}},
+				{Name: "rpm", Included: true, Reason: "selected as a target", Chain: []string{"rpm"}},
+				{Name: "images", Reason: "pruned because no consumer"},
+			},
+		},
+		{
+			name:        "unknown target",
+			targets:     []string{"e2e"},
+			expectedErr: "the following names were not found in the config or were duplicates: e2e (from root, src, bin, unit, rpm, images)",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			explanations, err := ExplainPartialGraph(steps, testCase.targets)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, explanations); diff != "" {
+				t.Errorf("unexpected explanations: %s", diff)
+			}
+		})
+	}
+}
+
 type fakeValidationStep struct {
 	name string
 	err  error
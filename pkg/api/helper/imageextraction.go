@@ -36,6 +36,9 @@ func TestInputImageStreamTagsFromResolvedConfig(cfg api.ReleaseBuildConfiguratio
 	if cfg.BuildRootImage != nil && cfg.BuildRootImage.ImageStreamTagReference != nil {
 		insert(*cfg.BuildRootImage.ImageStreamTagReference, result)
 	}
+	if cfg.Clonerefs != nil {
+		insert(cfg.Clonerefs.Image, result)
+	}
 
 	var errs []error
 	for _, testStep := range cfg.Tests {
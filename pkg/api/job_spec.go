@@ -24,6 +24,9 @@ type JobSpec struct {
 
 	// if set, any new artifacts will be a child of this object
 	owner *meta.OwnerReference
+
+	// mergeSHA is the commit the pulls were merged as when cloning
+	mergeSHA string
 }
 
 // Namespace returns the namespace of the job. Must not be evaluated
@@ -52,6 +55,16 @@ func (s *JobSpec) SetOwner(owner *meta.OwnerReference) {
 	s.owner = owner
 }
 
+// MergeSHA returns the commit the pull requests under test were merged
+// into the base as. It is only known once the source was cloned.
+func (s *JobSpec) MergeSHA() string {
+	return s.mergeSHA
+}
+
+func (s *JobSpec) SetMergeSHA(sha string) {
+	s.mergeSHA = sha
+}
+
 // Inputs returns the definition of the job as an input to
 // the execution graph.
 func (s *JobSpec) Inputs() InputDefinition {
@@ -71,6 +84,22 @@ func (s JobSpec) JobNameHash() string {
 	return fmt.Sprintf("%x", sha256.Sum256([]byte(s.Job)))[:5]
 }
 
+// ParseSpec parses a job spec in the format Prow passes it in $JOB_SPEC
+func ParseSpec(raw []byte) (*JobSpec, error) {
+	apiSpec := &downwardapi.JobSpec{}
+	if err := json.Unmarshal(raw, apiSpec); err != nil {
+		return nil, fmt.Errorf("malformed job spec: %w", err)
+	}
+	normalized, err := json.Marshal(apiSpec)
+	if err != nil {
+		panic(err)
+	}
+	return &JobSpec{
+		JobSpec: *apiSpec,
+		rawSpec: string(normalized),
+	}, nil
+}
+
 // ResolveSpecFromEnv will determine the Refs being
 // tested in by parsing Prow environment variable contents
 func ResolveSpecFromEnv() (*JobSpec, error) {
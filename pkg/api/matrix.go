@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TestMatrix maps the parameters of a test to the values it is expanded over
+type TestMatrix map[string][]string
+
+var (
+	matrixPlaceholderRe = regexp.MustCompile(`\$\{\{\s*([^}\s]*)\s*\}\}`)
+	matrixParameterRe   = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// ExpandTestMatrices replaces every test with a matrix with the tests it
+// expands into, in the order of the values of its parameters, sorted by name.
+// Tests without a matrix are returned as they are.
+func ExpandTestMatrices(tests []TestStepConfiguration) ([]TestStepConfiguration, error) {
+	hasMatrix := false
+	for _, test := range tests {
+		hasMatrix = hasMatrix || len(test.Matrix) > 0
+	}
+	if !hasMatrix {
+		return tests, nil
+	}
+	var expanded []TestStepConfiguration
+	for i, test := range tests {
+		if len(test.Matrix) == 0 {
+			expanded = append(expanded, test)
+			continue
+		}
+		instances, err := expandTestMatrix(test)
+		if err != nil {
+			return nil, fmt.Errorf("tests[%d].matrix: %w", i, err)
+		}
+		expanded = append(expanded, instances...)
+	}
+	return expanded, nil
+}
+
+func expandTestMatrix(test TestStepConfiguration) ([]TestStepConfiguration, error) {
+	matrix := test.Matrix
+	var parameters []string
+	for parameter, values := range matrix {
+		if !matrixParameterRe.MatchString(parameter) {
+			return nil, fmt.Errorf("parameter %q must be a valid identifier", parameter)
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("parameter %q has no values", parameter)
+		}
+		for _, value := range values {
+			if value == "" {
+				return nil, fmt.Errorf("parameter %q has an empty value", parameter)
+			}
+		}
+		parameters = append(parameters, parameter)
+	}
+	sort.Strings(parameters)
+	namedByPlaceholders := matrixPlaceholderRe.MatchString(test.As)
+	test.Matrix = nil
+	raw, err := json.Marshal(test)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal test %s: %w", test.As, err)
+	}
+
+	var instances []TestStepConfiguration
+	// indices holds the index of the current value of every parameter
+	indices := make([]int, len(parameters))
+	for {
+		values := map[string]string{}
+		var suffix []string
+		for i, parameter := range parameters {
+			values[parameter] = matrix[parameter][indices[i]]
+			suffix = append(suffix, values[parameter])
+		}
+		instance, err := interpolateTest(raw, values)
+		if err != nil {
+			return nil, err
+		}
+		if !namedByPlaceholders {
+			instance.As = strings.Join(append([]string{test.As}, suffix...), "-")
+		}
+		instances = append(instances, instance)
+
+		// advance the last parameter first, like an odometer
+		i := len(parameters) - 1
+		for ; i >= 0; i-- {
+			indices[i]++
+			if indices[i] < len(matrix[parameters[i]]) {
+				break
+			}
+			indices[i] = 0
+		}
+		if i < 0 {
+			return instances, nil
+		}
+	}
+}
+
+// interpolateTest replaces the placeholders in the serialized test with the
+// values of the parameters
+func interpolateTest(raw []byte, values map[string]string) (TestStepConfiguration, error) {
+	var unknown []string
+	interpolated := matrixPlaceholderRe.ReplaceAllFunc(raw, func(placeholder []byte) []byte {
+		parameter := string(matrixPlaceholderRe.FindSubmatch(placeholder)[1])
+		value, ok := values[parameter]
+		if !ok {
+			unknown = append(unknown, parameter)
+			return placeholder
+		}
+		// the value is placed in a JSON string, so it must be escaped as one
+		escaped, _ := json.Marshal(value)
+		return escaped[1 : len(escaped)-1]
+	})
+	if len(unknown) > 0 {
+		return TestStepConfiguration{}, fmt.Errorf("test references parameters not in the matrix: %s", strings.Join(unknown, ", "))
+	}
+	var instance TestStepConfiguration
+	if err := json.Unmarshal(interpolated, &instance); err != nil {
+		return TestStepConfiguration{}, fmt.Errorf("could not interpolate test: %w", err)
+	}
+	return instance, nil
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExpandTestMatrices(t *testing.T) {
+	workflow := "ipi-${{ platform }}"
+	var testCases = []struct {
+		name        string
+		tests       []TestStepConfiguration
+		expected    []TestStepConfiguration
+		expectedErr string
+	}{
+		{
+			name:     "no matrix",
+			tests:    []TestStepConfiguration{{As: "unit", Commands: "make test"}},
+			expected: []TestStepConfiguration{{As: "unit", Commands: "make test"}},
+		},
+		{
+			name: "values are appended to the name without placeholders",
+			tests: []TestStepConfiguration{
+				{As: "unit", Commands: "make test"},
+				{As: "e2e", Commands: "make e2e VERSION=${{version}} ARCH=${{arch}}", Matrix: TestMatrix{"version": {"4.7", "4.8"}, "arch": {"amd64", "arm64"}}},
+			},
+			expected: []TestStepConfiguration{
+				{As: "unit", Commands: "make test"},
+				{As: "e2e-amd64-4.7", Commands: "make e2e VERSION=4.7 ARCH=amd64"},
+				{As: "e2e-amd64-4.8", Commands: "make e2e VERSION=4.8 ARCH=amd64"},
+				{As: "e2e-arm64-4.7", Commands: "make e2e VERSION=4.7 ARCH=arm64"},
+				{As: "e2e-arm64-4.8", Commands: "make e2e VERSION=4.8 ARCH=arm64"},
+			},
+		},
+		{
+			name: "placeholders in the name, the profile and the environment",
+			tests: []TestStepConfiguration{{
+				As:     "e2e-${{ platform }}",
+				Matrix: TestMatrix{"platform": {"aws", "gcp"}, "release": {"4.8"}},
+				MultiStageTestConfiguration: &MultiStageTestConfiguration{
+					ClusterProfile: "${{ platform }}",
+					Workflow:       &workflow,
+					Environment:    TestEnvironment{"RELEASE": "${{release}}", "QUOTED": `"${{platform}}"`},
+				},
+			}},
+			expected: []TestStepConfiguration{
+				{
+					As: "e2e-aws",
+					MultiStageTestConfiguration: &MultiStageTestConfiguration{
+						ClusterProfile: "aws",
+						Workflow:       stringPointer("ipi-aws"),
+						Environment:    TestEnvironment{"RELEASE": "4.8", "QUOTED": `"aws"`},
+					},
+				},
+				{
+					As: "e2e-gcp",
+					MultiStageTestConfiguration: &MultiStageTestConfiguration{
+						ClusterProfile: "gcp",
+						Workflow:       stringPointer("ipi-gcp"),
+						Environment:    TestEnvironment{"RELEASE": "4.8", "QUOTED": `"gcp"`},
+					},
+				},
+			},
+		},
+		{
+			name:        "unknown parameter",
+			tests:       []TestStepConfiguration{{As: "e2e", Commands: "make ${{target}}", Matrix: TestMatrix{"arch": {"amd64"}}}},
+			expectedErr: "tests[0].matrix: test references parameters not in the matrix: target",
+		},
+		{
+			name:        "parameter without values",
+			tests:       []TestStepConfiguration{{As: "e2e", Commands: "make", Matrix: TestMatrix{"arch": {}}}},
+			expectedErr: `tests[0].matrix: parameter "arch" has no values`,
+		},
+		{
+			name:        "invalid parameter",
+			tests:       []TestStepConfiguration{{As: "e2e", Commands: "make", Matrix: TestMatrix{"cluster-profile": {"aws"}}}},
+			expectedErr: `tests[0].matrix: parameter "cluster-profile" must be a valid identifier`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := ExpandTestMatrices(testCase.tests)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected tests: %s", diff)
+			}
+		})
+	}
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
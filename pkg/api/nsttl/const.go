@@ -1,7 +1,8 @@
 package nsttl
 
 // This package contains constants for tools that create namespaces
-// to be reaped by https://github.com/openshift/ci-ns-ttl-controller/
+// to be reaped by the namespace_ttl controller in dptp-controller-manager
+// or https://github.com/openshift/ci-ns-ttl-controller/
 
 const (
 	// AnnotationIdleCleanupDurationTTL is the annotation for requesting namespace cleanup after all pods complete
@@ -9,6 +10,6 @@ const (
 	// AnnotationCleanupDurationTTL is the annotation for requesting namespace cleanup after the namespace has been active
 	AnnotationCleanupDurationTTL = "ci.openshift.io/ttl.hard"
 	// AnnotationNamespaceLastActive contains time.RFC3339 timestamp at which the namespace was last in active use. We
-	// update this every ten minutes.
+	// update this with every heartbeat, every ten minutes by default.
 	AnnotationNamespaceLastActive = "ci.openshift.io/active"
 )
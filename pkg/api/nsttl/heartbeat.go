@@ -0,0 +1,61 @@
+package nsttl
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// AnnotationHeartbeat contains the Heartbeat of the ci-operator running a job in the namespace, as JSON.
+	AnnotationHeartbeat = "ci.openshift.io/heartbeat"
+	// HeartbeatConfigMap is the name of the ConfigMap ci-operator optionally mirrors its heartbeat to,
+	// for consumers that may not read namespaces.
+	HeartbeatConfigMap = "ci-operator-heartbeat"
+	// HeartbeatConfigMapKey is the key of the heartbeat in the ConfigMap.
+	HeartbeatConfigMapKey = "heartbeat.json"
+
+	// missedHeartbeats is the number of heartbeats that may be missed before ci-operator is considered gone
+	missedHeartbeats = 3
+)
+
+// Heartbeat is the liveness and the progress ci-operator periodically reports for the job it runs.
+type Heartbeat struct {
+	// Time is when ci-operator last reported.
+	Time metav1.Time `json:"time"`
+	// Interval is how often ci-operator reports.
+	Interval metav1.Duration `json:"interval"`
+	// Steps are the steps running at the time of the report.
+	Steps []string `json:"steps,omitempty"`
+	// Finished is the number of steps that finished.
+	Finished int `json:"finished"`
+	// Total is the number of steps the job runs.
+	Total int `json:"total"`
+	// ETA estimates when the job finishes, once any step finished.
+	ETA *metav1.Time `json:"eta,omitempty"`
+}
+
+// Expires determines when ci-operator is considered gone unless it reports again.
+func (h Heartbeat) Expires() time.Time {
+	return h.Time.Add(missedHeartbeats * h.Interval.Duration)
+}
+
+// Alive determines whether ci-operator still reported recently at the given time.
+func (h Heartbeat) Alive(now time.Time) bool {
+	return now.Before(h.Expires())
+}
+
+// HeartbeatFrom reads the heartbeat from the annotations of a namespace, if there is one.
+func HeartbeatFrom(annotations map[string]string) (*Heartbeat, error) {
+	raw, ok := annotations[AnnotationHeartbeat]
+	if !ok {
+		return nil, nil
+	}
+	var heartbeat Heartbeat
+	if err := json.Unmarshal([]byte(raw), &heartbeat); err != nil {
+		return nil, fmt.Errorf("failed to parse %s annotation value: %w", AnnotationHeartbeat, err)
+	}
+	return &heartbeat, nil
+}
@@ -49,6 +49,8 @@ type DeferredParameters struct {
 	params Parameters
 	fns    ParameterMap
 	values map[string]string
+	// inputs are read like the environment and take precedence over it
+	inputs map[string]string
 }
 
 func NewDeferredParameters(params Parameters) *DeferredParameters {
@@ -59,6 +61,22 @@ func NewDeferredParameters(params Parameters) *DeferredParameters {
 	}
 }
 
+// NewDeferredParametersWithInputs returns parameters reading the inputs from
+// outside the graph from the given values before the environment, so jobs
+// executed in one process do not share them
+func NewDeferredParametersWithInputs(inputs map[string]string) *DeferredParameters {
+	p := NewDeferredParameters(nil)
+	p.inputs = inputs
+	return p
+}
+
+func (p *DeferredParameters) lookupInput(name string) (string, bool) {
+	if value, ok := p.inputs[name]; ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
 func (p *DeferredParameters) Map() (map[string]string, error) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -113,7 +131,7 @@ func (p *DeferredParameters) HasInput(name string) bool {
 func (p *DeferredParameters) hasInput(name string) bool {
 	p.lock.Lock()
 	defer p.lock.Unlock()
-	_, ok := os.LookupEnv(name)
+	_, ok := p.lookupInput(name)
 	return ok
 }
 
@@ -126,7 +144,7 @@ func (p *DeferredParameters) Has(name string) bool {
 	if p.params != nil && p.params.Has(name) {
 		return true
 	}
-	_, ok := os.LookupEnv(name)
+	_, ok := p.lookupInput(name)
 	return ok
 }
 
@@ -155,7 +173,7 @@ func (p *DeferredParameters) get(name string) (string, error) {
 	if value, ok := p.values[name]; ok {
 		return value, nil
 	}
-	if value, ok := os.LookupEnv(name); ok {
+	if value, ok := p.lookupInput(name); ok {
 		p.values[name] = value
 		return value, nil
 	}
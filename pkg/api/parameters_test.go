@@ -203,3 +203,19 @@ func TestDeferredParametersParent(t *testing.T) {
 		})
 	}
 }
+
+func TestDeferredParametersWithInputs(t *testing.T) {
+	params := NewDeferredParametersWithInputs(map[string]string{"RELEASE_IMAGE_LATEST": "pinned"})
+	nested := NewDeferredParameters(params)
+	for _, p := range []Parameters{params, nested} {
+		if !p.HasInput("RELEASE_IMAGE_LATEST") || !p.Has("RELEASE_IMAGE_LATEST") {
+			t.Errorf("expected the input to be provided")
+		}
+		if value, err := p.Get("RELEASE_IMAGE_LATEST"); err != nil || value != "pinned" {
+			t.Errorf("expected the input, got %q: %v", value, err)
+		}
+	}
+	if params.HasInput("RELEASE_IMAGE_INITIAL") {
+		t.Errorf("expected only the given inputs to be provided")
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
@@ -20,6 +21,10 @@ func IsPromotionJob(jobLabels map[string]string) bool {
 	return ok
 }
 
+// ConfigAPIVersion is the current version of the schema of ci-operator
+// configuration
+const ConfigAPIVersion = "ci-operator.openshift.io/v1"
+
 // ReleaseBuildConfiguration describes how release
 // artifacts are built from a repository of source
 // code. The configuration is made up of two parts:
@@ -31,6 +36,11 @@ func IsPromotionJob(jobLabels map[string]string) bool {
 //  - raw steps that can be used to create custom and
 //    fine-grained build flows
 type ReleaseBuildConfiguration struct {
+	// APIVersion is the version of the schema the configuration is
+	// written in. Configurations without it are in the first version.
+	// Older versions are migrated to ConfigAPIVersion when loaded.
+	APIVersion string `json:"apiVersion,omitempty"`
+
 	Metadata Metadata `json:"zz_generated_metadata"`
 
 	InputConfiguration `json:",inline"`
@@ -60,6 +70,17 @@ type ReleaseBuildConfiguration struct {
 	// cloning from is ignored.
 	CanonicalGoRepository *string `json:"canonical_go_repository,omitempty"`
 
+	// CloneIntoPods skips building the "src" image for repositories
+	// which build nothing. Container tests from "src" instead run from
+	// the build root, with the source cloned into their pod when it
+	// starts. Nothing else may use the "src" image.
+	CloneIntoPods bool `json:"clone_into_pods,omitempty"`
+
+	// Clonerefs replaces the image the source is cloned with, for forks and
+	// disconnected installations which supply their own clonerefs. The
+	// --clonerefs-image flag of ci-operator takes precedence.
+	Clonerefs *ClonerefsConfiguration `json:"clonerefs,omitempty"`
+
 	// Images describes the images that are built
 	// baseImage the project as part of the release
 	// process. The name of each image is its "to" value
@@ -85,10 +106,120 @@ type ReleaseBuildConfiguration struct {
 	// If no promotion is defined, it is defaulted from the ReleaseTagConfiguration.
 	PromotionConfiguration *PromotionConfiguration `json:"promotion,omitempty"`
 
+	// Attestations enables generating a software bill of materials
+	// and provenance for every image built by the job.
+	Attestations *AttestationConfiguration `json:"attestations,omitempty"`
+
+	// VulnerabilityScan enables scanning images built by the job
+	// for known vulnerabilities.
+	VulnerabilityScan *VulnerabilityScanConfiguration `json:"vulnerability_scan,omitempty"`
+
+	// FIPSCheck enables checking that binaries in images built by the
+	// job use FIPS-compliant cryptography.
+	FIPSCheck *FIPSCheckConfiguration `json:"fips_check,omitempty"`
+
+	// Approval pauses the job before it promotes until the promotion
+	// is approved, for pipelines which promote in stages.
+	Approval *ApprovalConfiguration `json:"approval,omitempty"`
+
+	// Mirror lists images of the job which are pushed to external
+	// registries once its tests pass, whether it promotes or not.
+	Mirror []ImageMirror `json:"mirror,omitempty"`
+
 	// Resources is a set of resource requests or limits over the
 	// input types. The special name '*' may be used to set default
 	// requests and limits.
 	Resources ResourceConfiguration `json:"resources,omitempty"`
+
+	// ArtifactGathering limits the artifacts ci-operator gathers from the
+	// pods of steps. The special name '*' may be used to set the default for
+	// all steps. Multi-stage tests upload their artifacts from their pods
+	// and cannot be limited here.
+	ArtifactGathering ArtifactGatheringConfiguration `json:"artifact_gathering,omitempty"`
+
+	// Contacts identifies the team owning the jobs generated from this
+	// configuration and how to reach it. They are included in failure
+	// summaries so that failures can be routed to the owners.
+	Contacts *Contacts `json:"contacts,omitempty"`
+
+	// StatusContexts are GitHub commit statuses posted in addition to the
+	// status of the job, each reporting whether a milestone of the job was
+	// reached, so merges can be gated on them.
+	StatusContexts []StatusContext `json:"status_contexts,omitempty"`
+
+	// Namespace configures policies applied to the ephemeral namespace the
+	// job runs in, protecting shared clusters from runaway tests.
+	Namespace *NamespaceConfiguration `json:"namespace,omitempty"`
+}
+
+// NamespaceConfiguration holds the policies set up in the ephemeral test
+// namespace before any step runs.
+type NamespaceConfiguration struct {
+	// Quota caps the aggregate resources used in the namespace, keyed by
+	// the resource names of a ResourceQuota, e.g. requests.cpu or pods.
+	Quota ResourceList `json:"quota,omitempty"`
+	// LimitRange sets defaults and bounds for the containers created in
+	// the namespace.
+	LimitRange *LimitRangeConfiguration `json:"limit_range,omitempty"`
+	// PriorityClassName is set on the pods created in the namespace which
+	// do not request a priority class, e.g. to prioritize release-blocking
+	// jobs. The priority class must exist in the cluster.
+	PriorityClassName string `json:"priority_class_name,omitempty"`
+}
+
+// LimitRangeConfiguration holds the limits of the containers of a namespace.
+type LimitRangeConfiguration struct {
+	// Default are the limits of containers which do not set them.
+	Default ResourceList `json:"default,omitempty"`
+	// DefaultRequest are the requests of containers which do not set them.
+	DefaultRequest ResourceList `json:"default_request,omitempty"`
+	// Max are the largest limits a container may set.
+	Max ResourceList `json:"max,omitempty"`
+}
+
+// StatusContext is a GitHub commit status reporting a milestone of a job,
+// which is reached when all of its steps succeed.
+type StatusContext struct {
+	// Context is the name of the status, e.g. images-built.
+	Context string `json:"context"`
+	// Description is shown with the status once the milestone is reached.
+	Description string `json:"description,omitempty"`
+	// Steps are the names of the steps of the job that need to succeed for
+	// the milestone to be reached, e.g. [images] or the name of a test.
+	Steps []string `json:"steps"`
+}
+
+// Contacts describes whom to contact about failures of a job and how
+// to escalate them.
+type Contacts struct {
+	// Team is the name of the team owning the jobs.
+	Team string `json:"team"`
+	// SlackChannel is the channel where the team can be reached, e.g. #forum-team.
+	SlackChannel string `json:"slack_channel,omitempty"`
+	// Email is the address of the team's mailing list.
+	Email string `json:"email,omitempty"`
+	// Escalation is a link to where issues should be reported, e.g. a bug
+	// tracker component or a runbook.
+	Escalation string `json:"escalation,omitempty"`
+}
+
+// Summary formats the contacts for humans triaging a failure.
+func (c *Contacts) Summary() string {
+	summary := fmt.Sprintf("This job is owned by %s", c.Team)
+	var reach []string
+	if c.SlackChannel != "" {
+		reach = append(reach, c.SlackChannel)
+	}
+	if c.Email != "" {
+		reach = append(reach, c.Email)
+	}
+	if len(reach) != 0 {
+		summary += fmt.Sprintf(", who can be reached at %s", strings.Join(reach, " or "))
+	}
+	if c.Escalation != "" {
+		summary += fmt.Sprintf(". Report issues at %s", c.Escalation)
+	}
+	return summary + "."
 }
 
 // Metadata describes the source repo for which a config is written
@@ -164,6 +295,40 @@ func (c ResourceConfiguration) RequirementsForStep(name string) ResourceRequirem
 	return req
 }
 
+// ArtifactGatheringConfiguration defines how the artifacts of steps are
+// gathered, by the name of the step.
+type ArtifactGatheringConfiguration map[string]ArtifactGathering
+
+// ForStep returns how the artifacts of the step are gathered, with the
+// settings of the step overriding the defaults.
+func (c ArtifactGatheringConfiguration) ForStep(name string) ArtifactGathering {
+	gathering := c["*"]
+	if values, ok := c[name]; ok {
+		if values.MaxSize != "" {
+			gathering.MaxSize = values.MaxSize
+		}
+		if values.Compress != nil {
+			gathering.Compress = values.Compress
+		}
+	}
+	return gathering
+}
+
+// ArtifactGathering describes how the artifacts of the pods of a step are
+// stored while they are gathered. It applies to the artifacts ci-operator
+// copies out of pods, i.e. those of template tests; the steps of multi-stage
+// tests upload their artifacts from their pods and are not limited by it.
+type ArtifactGathering struct {
+	// MaxSize is the maximum size of the artifacts stored for the step,
+	// e.g. 10Gi. Files which do not fit are left out and listed in a
+	// truncation report in the artifacts of the step.
+	MaxSize string `json:"max_size,omitempty"`
+	// Compress lists directories, relative to the artifact directory of the
+	// pods, which are streamed into compressed tarballs instead of being
+	// extracted, e.g. must-gather.
+	Compress []string `json:"compress,omitempty"`
+}
+
 // ResourceRequirements are resource requests and limits applied
 // to the individual steps in the job. They are passed directly to
 // builds or pods.
@@ -203,6 +368,11 @@ type InputConfiguration struct {
 	// have RPM repositories injected into them for downstream
 	// image builds that require built project RPMs.
 	BaseRPMImages map[string]ImageStreamTagReference `json:"base_rpm_images,omitempty"`
+	// SignedBaseImages are the base images whose signatures are
+	// verified before they are used, keyed by their alias. Signatures
+	// made with a key are verified with the public key ci-operator is
+	// given.
+	SignedBaseImages map[string]ImageSigningConfiguration `json:"signed_base_images,omitempty"`
 
 	// BuildRootImage supports two ways to get the image that
 	// the pipeline will caches on. The one way is to take the reference
@@ -231,6 +401,36 @@ type UnresolvedRelease struct {
 	Prerelease *Prerelease `json:"prerelease,omitempty"`
 	// Release describes a released payload
 	Release *Release `json:"release,omitempty"`
+	// Assembled describes a payload assembled by the job from a subset of
+	// the images of another release
+	Assembled *AssembledRelease `json:"assembled,omitempty"`
+}
+
+// AssembledRelease describes a release payload which the job assembles from
+// the images of another release, leaving some out and replacing others with
+// images built by the job. This allows testing payloads with any set of
+// components, not just the `latest` and `initial` releases.
+type AssembledRelease struct {
+	// From is the name of the release whose images are assembled into the
+	// payload, `latest` by default.
+	From string `json:"from,omitempty"`
+	// Include is a regular expression matching the tags of the release which
+	// are part of the payload. All tags are included if unset.
+	Include string `json:"include,omitempty"`
+	// Exclude is a regular expression matching the tags of the release which
+	// are left out of the payload.
+	Exclude string `json:"exclude,omitempty"`
+	// Overrides maps tags of the payload to the images built by the job which
+	// replace them, e.g. `machine-config-operator: machine-config-operator`.
+	Overrides map[string]string `json:"overrides,omitempty"`
+}
+
+// SourceRelease is the name of the release the payload is assembled from
+func (r *AssembledRelease) SourceRelease() string {
+	if r.From == "" {
+		return LatestReleaseName
+	}
+	return r.From
 }
 
 // Candidate describes a validated candidate release payload
@@ -300,7 +500,11 @@ const (
 // Release describes a generally available release payload
 type Release struct {
 	// Version is the minor version to search for
-	Version string `json:"version"`
+	Version string `json:"version,omitempty"`
+	// VersionRange is a semantic version range, e.g. `>=4.12.0 <4.14.0`, to
+	// search for the newest release in instead of the minor version. Every
+	// alternative of the range needs an upper bound.
+	VersionRange string `json:"version_range,omitempty"`
 	// Channel is the release channel to search in
 	Channel ReleaseChannel `json:"channel"`
 	// Architecture is the architecture for the release.
@@ -333,6 +537,14 @@ type BuildRootImageConfiguration struct {
 	FromRepository bool `json:"from_repository,omitempty"`
 }
 
+// ClonerefsConfiguration is where the clonerefs tool is taken from
+type ClonerefsConfiguration struct {
+	// Image is the image stream tag holding the tool.
+	Image ImageStreamTagReference `json:"image"`
+	// Path is the path of the tool in the image, /clonerefs by default.
+	Path string `json:"path,omitempty"`
+}
+
 // ImageStreamTagReference identifies an ImageStreamTag
 type ImageStreamTagReference struct {
 	Namespace string `json:"namespace"`
@@ -403,6 +615,239 @@ type PromotionConfiguration struct {
 	// never concurrently, and you want to have promotion config
 	// in the ci-operator configuration files all the time.
 	Disabled bool `json:"disabled,omitempty"`
+
+	// Targets are further destinations the images are promoted
+	// to, in addition to the one configured above.
+	Targets []PromotionTarget `json:"targets,omitempty"`
+
+	// Signing signs the images with cosign once they are
+	// promoted, pushing the signatures next to them.
+	Signing *ImageSigningConfiguration `json:"signing,omitempty"`
+}
+
+// ImageSigningConfiguration configures how promoted images are signed. By
+// default, the key ci-operator is given is used.
+type ImageSigningConfiguration struct {
+	// Keyless signs with a short-lived certificate issued by Fulcio
+	// for the identity of the promotion pod instead of a key.
+	Keyless bool `json:"keyless,omitempty"`
+
+	// FulcioURL is the Fulcio instance issuing certificates for
+	// keyless signing. Defaults to the public instance.
+	FulcioURL string `json:"fulcio_url,omitempty"`
+
+	// RekorURL is the transparency log signatures are recorded
+	// in. Keyless signatures are always recorded, by default in
+	// the public instance, while signatures made with a key are
+	// only recorded if this is set.
+	RekorURL string `json:"rekor_url,omitempty"`
+
+	// OIDCIssuer is the issuer of the identity tokens of the
+	// promotion pod, which keyless signatures are verified
+	// against. Required for keyless signing.
+	OIDCIssuer string `json:"oidc_issuer,omitempty"`
+}
+
+// AttestationConfiguration configures the software bill of materials and
+// SLSA provenance generated for built images. Both are attached to the
+// images as OCI artifacts and archived in the job artifacts.
+type AttestationConfiguration struct {
+	// SBOMFormat is the format of the bill of materials, one of
+	// spdx-json or cyclonedx-json. Defaults to spdx-json.
+	SBOMFormat string `json:"sbom_format,omitempty"`
+}
+
+const (
+	SBOMFormatSPDX      = "spdx-json"
+	SBOMFormatCycloneDX = "cyclonedx-json"
+)
+
+// VulnerabilityScanConfiguration configures scanning built images for known
+// vulnerabilities. Reports are archived in the job artifacts and the number
+// of vulnerabilities found is recorded on the pipeline image stream.
+type VulnerabilityScanConfiguration struct {
+	// Scanner is the scanner used, one of trivy or clair. Defaults
+	// to trivy.
+	Scanner string `json:"scanner,omitempty"`
+
+	// ClairURL is the address of the Clair instance. Required with
+	// the clair scanner.
+	ClairURL string `json:"clair_url,omitempty"`
+
+	// Images are the built images which are scanned. Defaults to
+	// all of them.
+	Images []PipelineImageStreamTagReference `json:"images,omitempty"`
+
+	// SeverityThreshold is the lowest severity of the
+	// vulnerabilities acted on, one of LOW, MEDIUM, HIGH or
+	// CRITICAL. Defaults to HIGH.
+	SeverityThreshold string `json:"severity_threshold,omitempty"`
+
+	// Action is taken when vulnerabilities at or above the
+	// threshold are found: fail fails the job, while annotate only
+	// records them on the image stream. Defaults to fail.
+	Action string `json:"action,omitempty"`
+}
+
+const (
+	VulnerabilityScannerTrivy = "trivy"
+	VulnerabilityScannerClair = "clair"
+
+	VulnerabilityScanActionFail     = "fail"
+	VulnerabilityScanActionAnnotate = "annotate"
+)
+
+// ImageMirror mirrors an image of the job to an external registry. The
+// image is copied with its manifests intact, so it has the same digest in
+// the registry it is mirrored to.
+type ImageMirror struct {
+	// From is the image mirrored, as a tag of the pipeline or of a
+	// stable image stream, like pipeline:bin or stable:installer.
+	From string `json:"from"`
+
+	// To is the pull spec the image is pushed to.
+	To string `json:"to"`
+}
+
+// VulnerabilitySeverities are the severities vulnerabilities are reported
+// with, from the lowest to the highest
+var VulnerabilitySeverities = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// FIPSCheckConfiguration configures checking the binaries in built images
+// for cryptography which is not FIPS-compliant. A binary is not compliant
+// when it contains one of the forbidden symbols, for example because it
+// was built with the native Go cryptography, or when it is linked
+// statically and cannot use the OpenSSL library of the system. Reports are
+// archived in the job artifacts.
+type FIPSCheckConfiguration struct {
+	// Images are the built images which are checked.
+	Images []PipelineImageStreamTagReference `json:"images"`
+
+	// Paths are the directories in the images searched for
+	// binaries. Defaults to /usr/bin, /usr/sbin, /usr/libexec and
+	// /usr/local/bin.
+	Paths []string `json:"paths,omitempty"`
+
+	// ForbiddenSymbols are symbols binaries must not contain.
+	// Defaults to the marker of the native Go cryptography.
+	ForbiddenSymbols []string `json:"forbidden_symbols,omitempty"`
+
+	// AllowStaticBinaries skips checking that binaries link the
+	// OpenSSL library of the system dynamically.
+	AllowStaticBinaries bool `json:"allow_static_binaries,omitempty"`
+
+	// Action is taken when binaries which are not compliant are
+	// found: fail fails the job, while warn only reports them.
+	// Defaults to fail.
+	Action string `json:"action,omitempty"`
+}
+
+const (
+	FIPSCheckActionFail = "fail"
+	FIPSCheckActionWarn = "warn"
+)
+
+// FIPSCheckDefaultPaths are the directories searched for binaries unless
+// configured otherwise
+var FIPSCheckDefaultPaths = []string{"/usr/bin", "/usr/sbin", "/usr/libexec", "/usr/local/bin"}
+
+// FIPSCheckDefaultForbiddenSymbols mark binaries using cryptography which is
+// not FIPS-compliant unless configured otherwise
+var FIPSCheckDefaultForbiddenSymbols = []string{"crypto/internal/boring/sig.StandardCrypto"}
+
+// ApprovalConfiguration configures waiting for the approval of a promotion.
+// By default, the job creates an approval config map named after its build
+// ID in its namespace and waits for it to be annotated with the name of the
+// approver or of the user rejecting the promotion, so access to the namespace
+// authorizes approvers. When a URL is set, the job polls it for the decision
+// instead.
+type ApprovalConfiguration struct {
+	// URL is polled for the decision with the namespace, job and
+	// build ID as query parameters. It must respond with a JSON
+	// object holding the decision, one of pending, approved and
+	// rejected, and the approver.
+	URL string `json:"url,omitempty"`
+
+	// Timeout is how long the job waits for a decision before it
+	// fails. Defaults to one hour.
+	Timeout *prowv1.Duration `json:"timeout,omitempty"`
+}
+
+const (
+	// ApprovalConfigMap prefixes the name of the config map approvers
+	// annotate, which is suffixed with the build ID of the job
+	ApprovalConfigMap = "approval"
+	// ApprovedByAnnotation holds the name of the approver
+	ApprovedByAnnotation = "ci.openshift.io/approved-by"
+	// RejectedByAnnotation holds the name of the user rejecting the promotion
+	RejectedByAnnotation = "ci.openshift.io/rejected-by"
+
+	ApprovalDecisionPending  = "pending"
+	ApprovalDecisionApproved = "approved"
+	ApprovalDecisionRejected = "rejected"
+)
+
+// PromotionTarget is an additional destination of promoted images.
+type PromotionTarget struct {
+	// Namespace identifies the namespace to which the built
+	// artifacts will be published to.
+	Namespace string `json:"namespace"`
+
+	// Name is an optional image stream name to use that
+	// contains all component tags. If specified, tag is
+	// ignored.
+	Name string `json:"name,omitempty"`
+
+	// Tag is the ImageStreamTag tagged in for each
+	// build image's ImageStream.
+	Tag string `json:"tag,omitempty"`
+
+	// TagTemplate names the tags of the image stream set in
+	// name, e.g. `${component}-${branch}`. Besides ${component},
+	// the name of the image, ${org}, ${repo}, ${branch} and
+	// ${variant} of the configuration can be used. Defaults to
+	// `${component}`.
+	TagTemplate string `json:"tag_template,omitempty"`
+
+	// Disabled skips promotion to this target only.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Quay pushes the images directly to repositories on quay.io
+	// instead of tagging them into image streams. The namespace
+	// is the Quay organization then.
+	Quay *QuayPromotion `json:"quay,omitempty"`
+}
+
+// QuayPromotion configures the repositories images are pushed to on Quay.
+// Repositories which do not exist yet are created.
+type QuayPromotion struct {
+	// Public makes created repositories public.
+	Public bool `json:"public,omitempty"`
+
+	// RobotPermissions grants robot accounts of the organization
+	// a role on the repositories. Keys are robot account names
+	// without the organization prefix and values are one of
+	// read, write or admin.
+	RobotPermissions map[string]string `json:"robot_permissions,omitempty"`
+}
+
+// PromotionTagTemplateVariables are the variables which can be used in
+// tag templates of promotion targets
+var PromotionTagTemplateVariables = []string{"component", "org", "repo", "branch", "variant"}
+
+// EnabledTargets returns all destinations images are promoted to: the one
+// configured at the top level and the additional targets which are enabled.
+func (config PromotionConfiguration) EnabledTargets() []PromotionTarget {
+	if config.Disabled {
+		return nil
+	}
+	targets := []PromotionTarget{{Namespace: config.Namespace, Name: config.Name, Tag: config.Tag}}
+	for _, target := range config.Targets {
+		if !target.Disabled {
+			targets = append(targets, target)
+		}
+	}
+	return targets
 }
 
 // StepConfiguration holds one step configuration.
@@ -430,6 +875,9 @@ type StepConfiguration struct {
 type InputImageTagStepConfiguration struct {
 	BaseImage ImageStreamTagReference         `json:"base_image"`
 	To        PipelineImageStreamTagReference `json:"to,omitempty"`
+
+	// Signature is verified before the image is tagged in.
+	Signature *ImageSigningConfiguration `json:"signature,omitempty"`
 }
 
 // OutputImageTagStepConfiguration describes a step that
@@ -492,6 +940,12 @@ type TestStepConfiguration struct {
 	// Postsubmit configures prowgen to generate the job as a postsubmit rather than a presubmit
 	Postsubmit bool `json:"postsubmit,omitempty"`
 
+	// Matrix expands the test into one test for every combination of the
+	// values of its parameters. Every ${{parameter}} in the test is replaced
+	// with the value of the parameter; when the name of the test does not
+	// reference any, the values are appended to it instead.
+	Matrix TestMatrix `json:"matrix,omitempty"`
+
 	// Only one of the following can be not-null.
 	ContainerTestConfiguration                                *ContainerTestConfiguration                                `json:"container,omitempty"`
 	MultiStageTestConfiguration                               *MultiStageTestConfiguration                               `json:"steps,omitempty"`
@@ -517,6 +971,17 @@ type RegistryReference struct {
 	LiteralTestStep `json:",inline"`
 	// Documentation describes what the step being referenced does.
 	Documentation string `json:"documentation,omitempty"`
+	// Deprecated marks the step as deprecated.
+	Deprecated *Deprecation `json:"deprecated,omitempty"`
+}
+
+// Deprecation describes why a registry component is deprecated and what
+// should be used instead.
+type Deprecation struct {
+	// Message explains why the component is deprecated.
+	Message string `json:"message"`
+	// Replacement names the component that should be used instead, if any.
+	Replacement string `json:"replacement,omitempty"`
 }
 
 // RegistryChainConfig is the struct that chain references are unmarshalled into.
@@ -537,6 +1002,8 @@ type RegistryChain struct {
 	Environment []StepParameter `json:"env,omitempty"`
 	// Leases lists resources that should be acquired for the test.
 	Leases []StepLease `json:"leases,omitempty"`
+	// Deprecated marks the chain as deprecated.
+	Deprecated *Deprecation `json:"deprecated,omitempty"`
 }
 
 // RegistryWorkflowConfig is the struct that workflow references are unmarshalled into.
@@ -549,10 +1016,17 @@ type RegistryWorkflowConfig struct {
 type RegistryWorkflow struct {
 	// As defines the name of the workflow. This is how the workflow will be referenced from a job's config.
 	As string `json:"as,omitempty"`
+	// Extends names a workflow this workflow inherits from. The phases, the
+	// cluster profile and the options of the parent are used unless set in
+	// this workflow, the environment and dependencies are merged with the
+	// values of this workflow taking precedence and the leases are joined.
+	Extends string `json:"extends,omitempty"`
 	// Steps contains the MultiStageTestConfiguration that the workflow defines.
 	Steps MultiStageTestConfiguration `json:"steps,omitempty"`
 	// Documentation describes what the workflow does.
 	Documentation string `json:"documentation,omitempty"`
+	// Deprecated marks the workflow as deprecated.
+	Deprecated *Deprecation `json:"deprecated,omitempty"`
 }
 
 // RegistryObserverConfig is the struct that observer configs are unmarshalled into
@@ -581,7 +1055,10 @@ type RegistryInfo struct {
 }
 
 // Observer is the configuration for an observer Pod that will run in parallel
-// with a multi-stage test job.
+// with a multi-stage test job. Observers are started before the pre steps and
+// stopped once the post steps finish, their artifacts are gathered with those
+// of the steps. When the test targets a cluster, $KUBECONFIG points to its
+// kubeconfig, which does not exist until the cluster is installed.
 type Observer struct {
 	// Name is the name of this observer
 	Name string `json:"name"`
@@ -593,6 +1070,11 @@ type Observer struct {
 	Commands string `json:"commands,omitempty"`
 }
 
+// LiteralTestStep returns the test step the observer Pod is created from.
+func (o Observer) LiteralTestStep() LiteralTestStep {
+	return LiteralTestStep{As: o.Name, From: o.From, FromImage: o.FromImage, Commands: o.Commands}
+}
+
 // Observers is a configuration for which observer pods should and should not
 // be run during a job
 type Observers struct {
@@ -617,6 +1099,9 @@ type LiteralTestStep struct {
 	// Resources defines the resource requirements for the step.
 	Resources ResourceRequirements `json:"resources"`
 	// Timeout is how long the we will wait before aborting a job with SIGINT.
+	// The pod of the step is terminated if it still runs once the timeout,
+	// the grace period and some time to start the pod and upload artifacts
+	// have passed.
 	Timeout *prowv1.Duration `json:"timeout,omitempty"`
 	// GracePeriod is how long the we will wait after sending SIGINT to send
 	// SIGKILL when aborting a Step.
@@ -628,6 +1113,10 @@ type LiteralTestStep struct {
 	// Dependencies lists images which must be available before the test runs
 	// and the environment variables which are used to expose their pull specs.
 	Dependencies []StepDependency `json:"dependencies,omitempty"`
+	// ExternalDependencies lists images from outside of the CI system, pinned
+	// to a digest, which are imported before the test runs and exposed with
+	// environment variables like Dependencies.
+	ExternalDependencies []ExternalImageDependency `json:"external_dependencies,omitempty"`
 	// Leases lists resources that should be acquired for the test.
 	Leases []StepLease `json:"leases,omitempty"`
 	// OptionalOnSuccess defines if this step should be skipped as long
@@ -635,18 +1124,252 @@ type LiteralTestStep struct {
 	// flag is set to true in MultiStageTestConfiguration. This option is
 	// applicable to `post` steps.
 	OptionalOnSuccess *bool `json:"optional_on_success,omitempty"`
+	// If is an expression which determines whether the step runs, comparing
+	// parameters of the step and CLUSTER_TYPE to values, for example
+	// `CLUSTER_TYPE == aws || CLUSTER_TYPE == gcp && FIPS_ENABLED != true`.
+	// Values containing spaces or operators need to be quoted. The step is
+	// skipped when the expression does not hold.
+	If string `json:"if,omitempty"`
 	// BestEffort defines if this step should cause the job to fail when the
-	// step fails. This only applies when AllowBestEffortPostSteps flag is set
-	// to true in MultiStageTestConfiguration. This option is applicable to
-	// `post` steps.
+	// step fails. The failure of a best-effort step is still reported, but
+	// the following steps run as if it succeeded. For `post` steps, this only
+	// applies when AllowBestEffortPostSteps flag is set to true in
+	// MultiStageTestConfiguration.
 	BestEffort *bool `json:"best_effort,omitempty"`
 	// Cli is the (optional) name of the release from which the `oc` binary
 	// will be injected into this step.
 	Cli string `json:"cli,omitempty"`
 	// Observers are the observers that should be running
 	Observers []string `json:"observers,omitempty"`
+	// Workspace is a volume backed by a PersistentVolumeClaim that is
+	// provisioned for this step, for scratch space larger than the node's
+	// ephemeral storage allows.
+	Workspace *WorkspaceConfiguration `json:"workspace,omitempty"`
+	// Sidecars are containers that run next to the step's container, e.g.
+	// to provide a database the test connects to over localhost. They are
+	// terminated when the step's commands finish.
+	Sidecars []Sidecar `json:"sidecars,omitempty"`
+	// ServiceAccount, when set, runs the step with a service account of its
+	// own that is only granted the declared rules, instead of the one shared
+	// by all steps of the test, which can view everything in the namespace.
+	ServiceAccount *StepServiceAccount `json:"service_account,omitempty"`
+	// Retries, when set, re-runs the step in a new pod when it fails. The
+	// artifacts of each attempt are stored in a directory named after it.
+	Retries *StepRetries `json:"retries,omitempty"`
+	// ArtifactsToRegistry, when set, publishes files from the artifacts of
+	// the step as an OCI artifact once the step succeeds.
+	ArtifactsToRegistry *ArtifactsToRegistry `json:"artifacts_to_registry,omitempty"`
+	// Upgrade, when set, makes ci-operator upgrade the cluster of the test
+	// itself instead of running commands in a pod. The step then needs no
+	// `from` or `commands` and its timeout bounds the whole upgrade.
+	Upgrade *UpgradeStep `json:"upgrade,omitempty"`
+}
+
+// UpgradeStep upgrades the cluster installed by earlier steps from one
+// release payload to another through the cluster-version operator. The
+// kubeconfig of the cluster is read from the shared directory of the test.
+type UpgradeStep struct {
+	// From is the name of the release the cluster must run before the
+	// upgrade, e.g. initial.
+	From string `json:"from"`
+	// To is the name of the release the cluster is upgraded to, e.g. latest.
+	To string `json:"to"`
+}
+
+// ArtifactsToRegistry declares files from the artifacts directory of a step
+// which are pushed to a repository as an OCI artifact, annotated with the
+// job, build and step that produced them.
+type ArtifactsToRegistry struct {
+	// Repository is the repository the artifact is pushed to, e.g.
+	// quay.io/org/bundles.
+	Repository string `json:"repository"`
+	// Tag is the tag of the artifact. Defaults to the names of the test and
+	// the step and the build ID of the job.
+	Tag string `json:"tag,omitempty"`
+	// ArtifactType is the media type of the artifact.
+	ArtifactType string `json:"artifact_type,omitempty"`
+	// Files are the paths of the files in the artifact, relative to the
+	// artifacts directory of the step. Glob patterns are allowed.
+	Files []string `json:"files"`
+	// Credentials is the secret of the repository's owners holding the
+	// .dockerconfigjson which may push to the repository, referenced by
+	// namespace and name or by its path in Vault. The mount path is chosen
+	// by ci-operator and must not be set.
+	Credentials CredentialReference `json:"credentials"`
+}
+
+// StepRetries configures how a failing step is re-run.
+type StepRetries struct {
+	// Count is how many times the step is re-run after it fails.
+	Count int `json:"count"`
+	// Until limits how long after the first attempt started new attempts
+	// may start, so retries do not push the test past its timeout. New
+	// attempts are only limited by Count when unset.
+	Until *prowv1.Duration `json:"until,omitempty"`
+}
+
+// StepServiceAccount declares the permissions a step needs in the test
+// namespace.
+type StepServiceAccount struct {
+	// Rules are the permissions granted to the step in the test namespace.
+	Rules []PolicyRule `json:"rules,omitempty"`
+}
+
+// PolicyRule grants access to resources in the test namespace, with the same
+// semantics as a rule of a Kubernetes Role.
+type PolicyRule struct {
+	// APIGroups are the groups of the resources, "" for the core group.
+	APIGroups []string `json:"api_groups"`
+	// Resources are the resources the rule applies to.
+	Resources []string `json:"resources"`
+	// ResourceNames optionally restricts the rule to named objects.
+	ResourceNames []string `json:"resource_names,omitempty"`
+	// Verbs are the operations allowed on the resources.
+	Verbs []string `json:"verbs"`
+}
+
+// Sidecar is a container running alongside the container of a step. It shares
+// the network of the step as well as the shared directory and workspace.
+type Sidecar struct {
+	// Name is the name of the sidecar container.
+	Name string `json:"name"`
+	// From is the container image that will be used for the sidecar, with
+	// the same semantics as the `from` field of a step.
+	From string `json:"from"`
+	// Commands is the shell script that starts the service.
+	Commands string `json:"commands"`
+	// Resources defines the resource requirements for the sidecar.
+	Resources ResourceRequirements `json:"resources"`
+}
+
+// WorkspaceConfiguration describes a volume provisioned for a step. The volume
+// is deleted when the test finishes.
+type WorkspaceConfiguration struct {
+	// Size is the requested capacity of the volume, e.g. 200Gi.
+	Size string `json:"size"`
+	// StorageClass is the storage class used to provision the volume. The
+	// default storage class of the cluster is used if unset.
+	StorageClass string `json:"storage_class,omitempty"`
+	// MountPath is where the volume is mounted, /workspace by default.
+	// The path is exposed to the step as $WORKSPACE_DIR.
+	MountPath string `json:"mount_path,omitempty"`
+}
+
+// SharedDirMedium is the storage medium backing the shared directory.
+type SharedDirMedium string
+
+const (
+	// SharedDirMediumDisk backs the shared directory with the node's disk.
+	SharedDirMediumDisk SharedDirMedium = "disk"
+	// SharedDirMediumMemory backs the shared directory with memory, which
+	// counts against the memory limits of the step.
+	SharedDirMediumMemory SharedDirMedium = "memory"
+)
+
+// SharedDirConfiguration describes the volume that steps write the contents
+// of $SHARED_DIR to. The contents are stored in a secret between steps, so
+// only up to 1MiB of them is handed off to the following steps.
+type SharedDirConfiguration struct {
+	// Medium backs the volume, either `disk` (the default) or `memory`.
+	Medium SharedDirMedium `json:"medium,omitempty"`
+	// SizeLimit is the maximum size of the volume, e.g. 100Mi. Steps which
+	// write more than that are evicted.
+	SizeLimit string `json:"size_limit,omitempty"`
+}
+
+// DataDirConfiguration describes a volume provisioned for a test and mounted
+// in all of its steps. The volume is deleted when the test finishes.
+type DataDirConfiguration struct {
+	// Size is the requested capacity of the volume, e.g. 200Gi.
+	Size string `json:"size"`
+	// StorageClass is the storage class used to provision the volume. The
+	// default storage class of the cluster is used if unset.
+	StorageClass string `json:"storage_class,omitempty"`
+}
+
+// ClusterProvisioningConfiguration describes a cluster installed for a test
+// through a Hive ClusterDeployment, using the credentials of the cluster
+// profile of the test. The kubeconfig of the cluster is handed to the steps
+// like one written to $SHARED_DIR by an installation step would be.
+type ClusterProvisioningConfiguration struct {
+	// Version is the name of the ClusterImageSet on the Hive cluster with the
+	// release to install, e.g. openshift-v4.9.0.
+	Version string `json:"version"`
+	// Region is the region of the cloud the cluster is installed in.
+	Region string `json:"region"`
+	// BaseDomain is the DNS zone of the cloud account of the cluster profile
+	// the cluster is installed under, e.g. origin-ci-int-aws.dev.rhcloud.com.
+	BaseDomain string `json:"base_domain"`
+}
+
+// ClusterClaimConfiguration describes a cluster claimed for a test from a
+// Hive ClusterPool. The claimed cluster has to be healthy before the steps
+// run against it, unhealthy clusters are released and another one is claimed
+// in their place. The cluster is released when the steps finish.
+type ClusterClaimConfiguration struct {
+	// Namespace is the namespace of the ClusterPool on the Hive cluster.
+	Namespace string `json:"namespace"`
+	// Pool is the name of the ClusterPool the cluster is claimed from.
+	Pool string `json:"pool"`
+	// Retries is how many times an unhealthy cluster is released and another
+	// one is claimed. Defaults to two.
+	Retries *int `json:"retries,omitempty"`
+}
+
+// ClusterProfileOverlay adds data to the cluster profile of a test, for
+// example a custom pull secret or the credentials of an extra cloud account.
+// The profile and the overlay are merged into one secret which is mounted
+// in place of the profile.
+type ClusterProfileOverlay struct {
+	// Secrets are copied into the cluster profile. Their keys override keys
+	// of the profile and of secrets earlier in the list.
+	Secrets []ClusterProfileOverlaySource `json:"secrets,omitempty"`
+	// ConfigMaps are copied into the cluster profile after the secrets, with
+	// the same precedence.
+	ConfigMaps []ClusterProfileOverlaySource `json:"config_maps,omitempty"`
+	// Environment has additional parameters exposed to all steps.
+	Environment TestEnvironment `json:"env,omitempty"`
+}
+
+// ClusterProfileOverlaySource references a secret or a config map on the
+// build farm cluster.
+type ClusterProfileOverlaySource struct {
+	// Namespace is where the source object lives.
+	Namespace string `json:"namespace"`
+	// Name is the name of the source object.
+	Name string `json:"name"`
+}
+
+// GatherStep is the name of a built-in step collecting data from a cluster
+type GatherStep string
+
+const (
+	// GatherStepMustGather runs `oc adm must-gather` against the cluster.
+	GatherStepMustGather GatherStep = "must-gather"
+	// GatherStepExtra collects the state of cluster resources and the logs
+	// of their pods that must-gather does not.
+	GatherStepExtra GatherStep = "extra"
+)
+
+// GatherConfiguration describes the built-in steps collecting data from the
+// cluster of a test before it is torn down. Steps of the same name already
+// in the post phase of the test take precedence over the built-in ones.
+type GatherConfiguration struct {
+	// Disabled turns off the built-in gather steps.
+	Disabled bool `json:"disabled,omitempty"`
+	// Steps are the built-in steps to run, all of them if unset.
+	Steps []GatherStep `json:"steps,omitempty"`
+	// MaxSize is the maximum size of the data collected by every step, e.g.
+	// 500Mi. The largest files are removed until the data fits.
+	MaxSize string `json:"max_size,omitempty"`
+	// Timeout is how long every step may collect data before it is stopped.
+	Timeout *prowv1.Duration `json:"timeout,omitempty"`
 }
 
+// HiveClusterTypes are the types of clusters which can be provisioned with
+// Hive
+var HiveClusterTypes = []string{"aws", "gcp"}
+
 // StepParameter is a variable set by the test, with an optional default.
 type StepParameter struct {
 	// Name of the environment variable.
@@ -655,14 +1378,63 @@ type StepParameter struct {
 	Default *string `json:"default,omitempty"`
 	// Documentation is a textual description of the parameter.
 	Documentation string `json:"documentation,omitempty"`
+	// Type is the type of the values of the parameter, a string if unset.
+	Type ParameterType `json:"type,omitempty"`
+	// Values are the values allowed for a parameter of the enum type.
+	Values []string `json:"values,omitempty"`
+	// Secret marks the parameter as sensitive. Its value is not exposed in
+	// the definition of the pods of the step and is censored from their
+	// output.
+	Secret bool `json:"secret,omitempty"`
+}
+
+// ParameterType is the type of the values of a step parameter
+type ParameterType string
+
+const (
+	ParameterTypeString ParameterType = "string"
+	ParameterTypeInt    ParameterType = "int"
+	ParameterTypeBool   ParameterType = "bool"
+	ParameterTypeEnum   ParameterType = "enum"
+)
+
+// ValidateValue determines whether the value is valid for the type of the
+// parameter
+func (p StepParameter) ValidateValue(value string) error {
+	switch p.Type {
+	case "", ParameterTypeString:
+	case ParameterTypeInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("value %q is not an integer", value)
+		}
+	case ParameterTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("value %q is not a boolean", value)
+		}
+	case ParameterTypeEnum:
+		for _, allowed := range p.Values {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("value %q is not one of %s", value, strings.Join(p.Values, ", "))
+	default:
+		return fmt.Errorf("unknown type %q", p.Type)
+	}
+	return nil
 }
 
 // CredentialReference defines a secret to mount into a step and where to mount it.
+// The secret is either read from a namespace on the build farm or from Vault.
 type CredentialReference struct {
 	// Namespace is where the source secret exists.
-	Namespace string `json:"namespace"`
+	Namespace string `json:"namespace,omitempty"`
 	// Names is which source secret to mount.
-	Name string `json:"name"`
+	Name string `json:"name,omitempty"`
+	// VaultPath is the path of a key-value secret in Vault to mount instead
+	// of a secret from a namespace. The secret is read when the test starts
+	// and deleted from the test namespace when it finishes.
+	VaultPath string `json:"vault_path,omitempty"`
 	// MountPath is where the secret should be mounted.
 	MountPath string `json:"mount_path"`
 }
@@ -676,6 +1448,24 @@ type StepDependency struct {
 	Env string `json:"env"`
 }
 
+// ExternalImageDependency defines a dependency on an image that is not built
+// or imported by the CI system otherwise. The image is imported into the
+// pipeline ImageStream and must resolve to the expected digest.
+type ExternalImageDependency struct {
+	// PullSpec is the full pull spec of the image, e.g. quay.io/org/image:tag
+	PullSpec string `json:"pull_spec"`
+	// Digest is the digest the image is expected to have, e.g. sha256:...
+	Digest string `json:"digest"`
+	// Env is the environment variable that the image's pull spec is exposed with
+	Env string `json:"env"`
+}
+
+// PipelineTag returns the tag the image is imported to in the pipeline
+// ImageStream. Dependencies on the same digest share the tag.
+func (d ExternalImageDependency) PipelineTag() PipelineImageStreamTagReference {
+	return PipelineImageStreamTagReference(fmt.Sprintf("external-%s", strings.Replace(d.Digest, ":", "-", 1)))
+}
+
 // StepLease defines a resource that needs to be acquired prior to execution.
 // The resource name will be exposed to the step via the specificed environment
 // variable.
@@ -741,6 +1531,31 @@ type MultiStageTestConfiguration struct {
 	AllowBestEffortPostSteps *bool `json:"allow_best_effort_post_steps,omitempty"`
 	// Observers are the observers that should be running
 	Observers *Observers `json:"observers,omitempty"`
+	// Comparison runs the test twice, once for a baseline and once for a
+	// candidate, and records a combined comparison of both runs.
+	Comparison *ComparisonConfiguration `json:"comparison,omitempty"`
+	// SharedDir configures the volume backing $SHARED_DIR in steps.
+	SharedDir *SharedDirConfiguration `json:"shared_dir,omitempty"`
+	// DataDir provisions a volume which is mounted in all steps as $DATA_DIR,
+	// for data that is too large to be handed off in $SHARED_DIR.
+	DataDir *DataDirConfiguration `json:"data_dir,omitempty"`
+	// ClusterProvisioning installs a short-lived cluster with Hive before
+	// the steps run and deprovisions it when they finish.
+	ClusterProvisioning *ClusterProvisioningConfiguration `json:"cluster_provisioning,omitempty"`
+	// ClusterClaim claims a cluster from a Hive pool before the steps run
+	// and releases it when they finish.
+	ClusterClaim *ClusterClaimConfiguration `json:"cluster_claim,omitempty"`
+	// ClusterProfileOverlay adds secrets, config maps and parameters to the
+	// cluster profile of the test.
+	ClusterProfileOverlay *ClusterProfileOverlay `json:"cluster_profile_overlay,omitempty"`
+	// Gather configures the built-in steps collecting data from the cluster
+	// of the test, which run first in the post phase of tests using a
+	// cluster profile.
+	Gather *GatherConfiguration `json:"gather,omitempty"`
+	// DisruptionRetries is how many times a pod of the test is re-created
+	// when the infrastructure terminates it before it completes, e.g. when
+	// it is evicted or its node is lost. Defaults to one; zero disables it.
+	DisruptionRetries *int `json:"disruption_retries,omitempty"`
 }
 
 // MultiStageTestConfigurationLiteral is a form of the MultiStageTestConfiguration that does not include
@@ -772,6 +1587,54 @@ type MultiStageTestConfigurationLiteral struct {
 	AllowBestEffortPostSteps *bool `json:"allow_best_effort_post_steps,omitempty"`
 	// Observers are the observers that need to be run
 	Observers []Observer `json:"observers,omitempty"`
+	// Comparison runs the test twice, once for a baseline and once for a
+	// candidate, and records a combined comparison of both runs.
+	Comparison *ComparisonConfiguration `json:"comparison,omitempty"`
+	// SharedDir configures the volume backing $SHARED_DIR in steps.
+	SharedDir *SharedDirConfiguration `json:"shared_dir,omitempty"`
+	// DataDir provisions a volume which is mounted in all steps as $DATA_DIR,
+	// for data that is too large to be handed off in $SHARED_DIR.
+	DataDir *DataDirConfiguration `json:"data_dir,omitempty"`
+	// ClusterProvisioning installs a short-lived cluster with Hive before
+	// the steps run and deprovisions it when they finish.
+	ClusterProvisioning *ClusterProvisioningConfiguration `json:"cluster_provisioning,omitempty"`
+	// ClusterClaim claims a cluster from a Hive pool before the steps run
+	// and releases it when they finish.
+	ClusterClaim *ClusterClaimConfiguration `json:"cluster_claim,omitempty"`
+	// ClusterProfileOverlay adds secrets, config maps and parameters to the
+	// cluster profile of the test.
+	ClusterProfileOverlay *ClusterProfileOverlay `json:"cluster_profile_overlay,omitempty"`
+	// Gather configures the built-in steps collecting data from the cluster
+	// of the test, which run first in the post phase of tests using a
+	// cluster profile.
+	Gather *GatherConfiguration `json:"gather,omitempty"`
+	// DisruptionRetries is how many times a pod of the test is re-created
+	// when the infrastructure terminates it before it completes, e.g. when
+	// it is evicted or its node is lost. Defaults to one; zero disables it.
+	DisruptionRetries *int `json:"disruption_retries,omitempty"`
+	// Deprecations holds the notices of the deprecated registry components
+	// the test was resolved from, keyed like `chain/ipi-install`. It is set
+	// by the resolver so that the job can report them.
+	Deprecations map[string]Deprecation `json:"deprecations,omitempty"`
+}
+
+// ComparisonConfiguration describes the two sides of a side-by-side
+// comparison run. Each side runs in isolation, with its own shared directory
+// and its own leases, so that each acquires a separate cluster.
+type ComparisonConfiguration struct {
+	// Baseline holds the overrides for the reference run.
+	Baseline ComparisonVariant `json:"baseline"`
+	// Candidate holds the overrides for the run being evaluated.
+	Candidate ComparisonVariant `json:"candidate"`
+}
+
+// ComparisonVariant holds the overrides for one side of a comparison.
+type ComparisonVariant struct {
+	// Environment overrides the values of parameters for the steps.
+	Environment TestEnvironment `json:"env,omitempty"`
+	// Dependencies overrides the images used for dependency parameters,
+	// for example to run the test against a different release payload.
+	Dependencies TestDependencies `json:"dependencies,omitempty"`
 }
 
 // TestEnvironment has the values of parameters for multi-stage tests.
@@ -985,6 +1848,17 @@ func (p ClusterProfile) LeaseType() string {
 	}
 }
 
+// Secret maps profiles to the name of the secret jobs mount their
+// credentials from.
+func (p ClusterProfile) Secret() string {
+	// CPaaS AWS is `aws` for all other purposes except for determining
+	// which Secret should be provided to jobs
+	if p == ClusterProfileAWSCPaaS {
+		return fmt.Sprintf("cluster-secrets-%s", p)
+	}
+	return fmt.Sprintf("cluster-secrets-%s", p.ClusterType())
+}
+
 // LeaseTypeFromClusterType maps cluster types to lease types
 func LeaseTypeFromClusterType(t string) (string, error) {
 	switch t {
@@ -1134,12 +2008,51 @@ type OperatorStepConfiguration struct {
 	// Substitutions describes the pullspecs in the operator manifests that must be subsituted
 	// with the pull specs of the images in the CI registry
 	Substitutions []PullSpecSubstitution `json:"substitutions,omitempty"`
+
+	// Index configures the index image built from the bundles, which steps
+	// can use as the `ci-index` dependency.
+	Index *OperatorIndexConfiguration `json:"index,omitempty"`
+
+	// PinRelatedImages pins the pullspecs of images the job builds, which
+	// the CSVs of the bundles reference in related images, container images
+	// and environment variables, to the digests of the built images. An
+	// image is referenced when the last part of the repository in the
+	// pullspec is the name of the image.
+	PinRelatedImages bool `json:"pin_related_images,omitempty"`
 }
 
 // Bundle contains the data needed to build a bundle from the bundle source image
 type Bundle struct {
 	DockerfilePath string `json:"dockerfile_path,omitempty"`
 	ContextDir     string `json:"context_dir,omitempty"`
+
+	// Channels are the channels the bundle is published in, overriding the
+	// ones in the metadata/annotations.yaml file of the bundle.
+	Channels []string `json:"channels,omitempty"`
+	// DefaultChannel is the default channel of the package, overriding the
+	// one in the metadata of the bundle.
+	DefaultChannel string `json:"default_channel,omitempty"`
+}
+
+const (
+	IndexUpdateGraphSemver          = "semver"
+	IndexUpdateGraphSemverSkipPatch = "semver-skippatch"
+	IndexUpdateGraphReplaces        = "replaces"
+)
+
+// OperatorIndexConfiguration describes how the index image is built from
+// the bundles and where it is published.
+type OperatorIndexConfiguration struct {
+	// BaseIndex is the pull spec of an existing index the bundles are added
+	// to. The index only contains the bundles when unset.
+	BaseIndex string `json:"base_index,omitempty"`
+	// UpdateGraph is the mode used to add the bundles to the update graph
+	// of their package: semver, semver-skippatch or replaces. Defaults to
+	// semver.
+	UpdateGraph string `json:"update_graph,omitempty"`
+	// PushTo is a pull spec, with a tag, the index is pushed to together
+	// with the promoted images.
+	PushTo string `json:"push_to,omitempty"`
 }
 
 // IndexGeneratorStepConfiguration describes a step that creates an index database and
@@ -1151,6 +2064,12 @@ type IndexGeneratorStepConfiguration struct {
 	// OperatorIndex is a list of the names of the bundle images that the
 	// index will contain in its database.
 	OperatorIndex []string `json:"operator_index,omitempty"`
+
+	// BaseIndex is the pull spec of an index the bundles are added to.
+	BaseIndex string `json:"base_index,omitempty"`
+
+	// UpdateGraph is the mode used to add the bundles to the update graph.
+	UpdateGraph string `json:"update_graph,omitempty"`
 }
 
 // PipelineImageStreamTagReferenceIndexImageGenerator is the name of the index image generator built by ci-operator
@@ -1167,6 +2086,12 @@ type BundleSourceStepConfiguration struct {
 	// Substitutions contains pullspecs that need to be replaced by images
 	// in the CI cluster for operator bundle images
 	Substitutions []PullSpecSubstitution `json:"substitutions,omitempty"`
+	// Bundles are the bundles built from the source, whose channels are
+	// overridden when configured
+	Bundles []Bundle `json:"bundles,omitempty"`
+	// PinnedImages are the images built by the job whose pullspecs in the
+	// CSVs are pinned to their digests
+	PinnedImages []PipelineImageStreamTagReference `json:"pinned_images,omitempty"`
 }
 
 // PipelineImageStreamTagReferenceBundleSourceName is the name of the bundle source image built by the CI
@@ -1191,6 +2116,22 @@ type ProjectDirectoryImageBuildStepConfiguration struct {
 
 	ProjectDirectoryImageBuildInputs `json:",inline"`
 
+	// BuildArgs are passed to the Dockerfile as build arguments, so
+	// that it can consume them with ARG instructions.
+	BuildArgs []BuildArg `json:"build_args,omitempty"`
+
+	// InjectSourceEnv exposes the source the image is built from as
+	// SOURCE_GIT_URL, SOURCE_GIT_COMMIT, SOURCE_GIT_REF and, for pull
+	// requests, SOURCE_GIT_BASE_COMMIT and SOURCE_GIT_PULLS environment
+	// variables, both to the build and in the image.
+	InjectSourceEnv bool `json:"inject_source_env,omitempty"`
+
+	// Hermetic runs the build without access to the network other than to
+	// the registry of the cluster and the networks the cluster allows for
+	// hermetic builds. The build fails when it reaches for anything else,
+	// which ensures it is reproducible from its inputs.
+	Hermetic bool `json:"hermetic,omitempty"`
+
 	// Optional means the build step is not built, published, or
 	// promoted unless explicitly targeted. Use for builds which
 	// are invoked only when testing certain parts of the repo.
@@ -1251,6 +2192,43 @@ type ImageSourcePath struct {
 	DestinationDir string `json:"destination_dir"`
 }
 
+// BuildArg is a build argument passed to the Dockerfile of an image build.
+type BuildArg struct {
+	// Name is the name of the argument in the ARG instruction.
+	Name string `json:"name"`
+	// Value is the static value of the argument.
+	Value string `json:"value,omitempty"`
+	// ValueFrom sources the value of the argument from the job.
+	// Mutually exclusive with Value.
+	ValueFrom *BuildArgSource `json:"value_from,omitempty"`
+}
+
+// BuildArgSource describes where the value of a build argument comes from.
+// Exactly one of the fields must be set.
+type BuildArgSource struct {
+	// JobMetadata is a property of the job: one of commit, base_ref,
+	// base_sha, pull_number, job_name or build_id. The commit is the
+	// head of the pull request under test or the base when there is
+	// none. Properties the job does not have are passed empty.
+	JobMetadata BuildArgJobMetadata `json:"job_metadata,omitempty"`
+	// Parameter is a parameter of the job, like RELEASE_IMAGE_LATEST.
+	// The build waits for the step providing it.
+	Parameter string `json:"parameter,omitempty"`
+}
+
+// BuildArgJobMetadata is a property of the job a build argument is
+// sourced from.
+type BuildArgJobMetadata string
+
+const (
+	BuildArgCommit     BuildArgJobMetadata = "commit"
+	BuildArgBaseRef    BuildArgJobMetadata = "base_ref"
+	BuildArgBaseSHA    BuildArgJobMetadata = "base_sha"
+	BuildArgPullNumber BuildArgJobMetadata = "pull_number"
+	BuildArgJobName    BuildArgJobMetadata = "job_name"
+	BuildArgBuildID    BuildArgJobMetadata = "build_id"
+)
+
 // RPMImageInjectionStepConfiguration describes a step
 // that updates injects an RPM repo into an image. If no
 // output tag is provided, the input tag is updated.
@@ -220,3 +220,76 @@ func TestIsBundleImage(t *testing.T) {
 		t.Errorf("Expected true, got false for func BundleName(1)")
 	}
 }
+
+func TestContactsSummary(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		contacts Contacts
+		expected string
+	}{
+		{
+			name:     "only the team",
+			contacts: Contacts{Team: "Test Platform"},
+			expected: "This job is owned by Test Platform.",
+		},
+		{
+			name:     "all contacts",
+			contacts: Contacts{Team: "Test Platform", SlackChannel: "#forum-testplatform", Email: "team@example.com", Escalation: "https://issues.example.com/browse/DPTP"},
+			expected: "This job is owned by Test Platform, who can be reached at #forum-testplatform or team@example.com. Report issues at https://issues.example.com/browse/DPTP.",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := testCase.contacts.Summary(); actual != testCase.expected {
+				t.Errorf("expected %q, got %q", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestArtifactGatheringForStep(t *testing.T) {
+	config := ArtifactGatheringConfiguration{
+		"*":   {MaxSize: "10Gi", Compress: []string{"must-gather"}},
+		"e2e": {MaxSize: "50Gi"},
+	}
+	var testCases = []struct {
+		step     string
+		expected ArtifactGathering
+	}{
+		{step: "e2e", expected: ArtifactGathering{MaxSize: "50Gi", Compress: []string{"must-gather"}}},
+		{step: "other", expected: ArtifactGathering{MaxSize: "10Gi", Compress: []string{"must-gather"}}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.step, func(t *testing.T) {
+			if actual := config.ForStep(testCase.step); !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("expected %v, got %v", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStepParameterValidateValue(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		param     StepParameter
+		value     string
+		expectErr bool
+	}{
+		{name: "untyped parameter takes anything", value: "anything"},
+		{name: "string parameter takes anything", param: StepParameter{Type: ParameterTypeString}, value: "anything"},
+		{name: "valid integer", param: StepParameter{Type: ParameterTypeInt}, value: "-3"},
+		{name: "invalid integer", param: StepParameter{Type: ParameterTypeInt}, value: "3.5", expectErr: true},
+		{name: "valid boolean", param: StepParameter{Type: ParameterTypeBool}, value: "false"},
+		{name: "invalid boolean", param: StepParameter{Type: ParameterTypeBool}, value: "yes", expectErr: true},
+		{name: "allowed value", param: StepParameter{Type: ParameterTypeEnum, Values: []string{"a", "b"}}, value: "b"},
+		{name: "value not allowed", param: StepParameter{Type: ParameterTypeEnum, Values: []string{"a", "b"}}, value: "c", expectErr: true},
+		{name: "unknown type", param: StepParameter{Type: "float"}, value: "1.0", expectErr: true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.param.ValidateValue(testCase.value); (err != nil) != testCase.expectErr {
+				t.Errorf("expected error: %t, got: %v", testCase.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package artifacts
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/test-infra/prow/secretutil"
+)
+
+// CensoredReport lists the artifacts which had credentials censored from
+// them, as a line with the name of the file and the number of censored
+// occurrences
+const CensoredReport = "censored-artifacts.txt"
+
+const (
+	// minimumSecretLength is the length below which secret values are not
+	// censored, as short values like "true" appear in artifacts by chance
+	minimumSecretLength = 8
+	// maximumCensoredSize is the size above which artifacts are not scanned,
+	// as they are read into memory to be censored
+	maximumCensoredSize = 256 * 1024 * 1024
+	// sniffLength is how much of an artifact is inspected to determine
+	// whether it holds text
+	sniffLength = 8000
+)
+
+// credentialPatterns match common credentials which may end up in artifacts
+// without being one of the known secret values. If a pattern has a group,
+// only the group is censored.
+var credentialPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----([\s\S]*?)-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`(?i)aws_secret_access_key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+	regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]{20,}=*)`),
+	regexp.MustCompile(`(?m)^\s*(?:token|client-key-data|password):\s*["']?([^\s"']{16,})`),
+}
+
+// Censorer removes credentials from the artifacts of a job before they are
+// uploaded: the values of the secrets the job has access to, as well as
+// anything that looks like a credential. Binary and compressed files are
+// left alone, and files are only scanned again when they changed.
+//
+// The censorer is thread safe and may be invoked in parallel.
+type Censorer struct {
+	dir    string
+	values *secretutil.ReloadingCensorer
+
+	lock sync.Mutex
+	// scanned holds the state of every file when it was last scanned
+	scanned map[string]fileState
+}
+
+// NewCensorer creates a censorer for the artifacts in the directory which
+// censors the secret values. Multi-line values are also censored line by
+// line, as files like kubeconfigs seldom appear verbatim.
+func NewCensorer(dir string, secrets ...[]byte) *Censorer {
+	var values []string
+	for _, secret := range secrets {
+		candidates := []string{string(secret)}
+		if bytes.Contains(secret, []byte("\n")) {
+			candidates = append(candidates, strings.Split(string(secret), "\n")...)
+		}
+		for _, candidate := range candidates {
+			if candidate = strings.TrimSpace(candidate); len(candidate) >= minimumSecretLength {
+				values = append(values, candidate)
+			}
+		}
+	}
+	censorer := secretutil.NewCensorer()
+	censorer.Refresh(values...)
+	return &Censorer{dir: dir, values: censorer, scanned: map[string]fileState{}}
+}
+
+// Censor scans the files in the directory which were not scanned before or
+// changed since they were, censoring credentials in place. Censored files
+// are recorded in the report at the root of the directory.
+func (c *Censorer) Censor() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	report := map[string]int{}
+	err := filepath.Walk(c.dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relative, err := filepath.Rel(c.dir, file)
+		if err != nil {
+			return err
+		}
+		if relative == CensoredReport {
+			return nil
+		}
+		if previous, ok := c.scanned[relative]; ok && previous == (fileState{size: info.Size(), modified: info.ModTime()}) {
+			return nil
+		}
+		censored, err := c.censorFile(file, info)
+		if err != nil {
+			return err
+		}
+		if censored > 0 {
+			report[filepath.ToSlash(relative)] = censored
+			if info, err = os.Stat(file); err != nil {
+				return err
+			}
+		}
+		c.scanned[relative] = fileState{size: info.Size(), modified: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not censor artifacts in %s: %w", c.dir, err)
+	}
+	return c.appendReport(report)
+}
+
+// CensorBytes returns a copy of the content with credentials censored, for
+// output which does not end up in the artifacts directory
+func (c *Censorer) CensorBytes(content []byte) []byte {
+	censored := make([]byte, len(content))
+	copy(censored, content)
+	censorContent(c.values, censored)
+	return censored
+}
+
+// censorFile censors the file in place if it holds text, determining the
+// number of censored occurrences
+func (c *Censorer) censorFile(file string, info os.FileInfo) (int, error) {
+	if info.Size() == 0 || info.Size() > maximumCensoredSize || strings.HasSuffix(file, ".gz") {
+		return 0, nil
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, fmt.Errorf("could not read %s: %w", file, err)
+	}
+	if bytes.IndexByte(content[:min(len(content), sniffLength)], 0) != -1 {
+		return 0, nil
+	}
+	censored := censorContent(c.values, content)
+	if censored == 0 {
+		return 0, nil
+	}
+	if err := ioutil.WriteFile(file, content, info.Mode().Perm()); err != nil {
+		return 0, fmt.Errorf("could not write censored %s: %w", file, err)
+	}
+	return censored, nil
+}
+
+// censorContent censors the content in place, determining the number of
+// censored occurrences. Censoring never changes the size of the content.
+func censorContent(values secretutil.Censorer, content []byte) int {
+	original := make([]byte, len(content))
+	copy(original, content)
+	values.Censor(&content)
+	censored := 0
+	for i := 0; i < len(content); i++ {
+		if content[i] != original[i] {
+			censored++
+			for i < len(content) && content[i] != original[i] {
+				i++
+			}
+		}
+	}
+	for _, pattern := range credentialPatterns {
+		for _, match := range pattern.FindAllSubmatchIndex(content, -1) {
+			start, end := match[0], match[1]
+			if len(match) > 2 && match[2] != -1 {
+				start, end = match[2], match[3]
+			}
+			if isCensored(content[start:end]) {
+				continue
+			}
+			censored++
+			for i := start; i < end; i++ {
+				if content[i] != '\n' && content[i] != '\r' {
+					content[i] = '*'
+				}
+			}
+		}
+	}
+	return censored
+}
+
+func isCensored(content []byte) bool {
+	return len(bytes.Trim(content, "*\r\n")) == 0
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// appendReport records the censored files at the root of the directory
+func (c *Censorer) appendReport(report map[string]int) error {
+	if len(report) == 0 {
+		return nil
+	}
+	var names []string
+	for name := range report {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	file, err := os.OpenFile(filepath.Join(c.dir, CensoredReport), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("could not open report of censored artifacts: %w", err)
+	}
+	for _, name := range names {
+		if _, err := fmt.Fprintf(file, "%s\t%d\n", name, report[name]); err != nil {
+			file.Close()
+			return fmt.Errorf("could not write report of censored artifacts: %w", err)
+		}
+	}
+	return file.Close()
+}
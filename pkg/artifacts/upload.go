@@ -0,0 +1,159 @@
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/gcsupload"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+	"k8s.io/test-infra/prow/pod-utils/gcs"
+)
+
+// Options configure the blob storage the artifacts of a job are uploaded to
+type Options struct {
+	// Bucket is the bucket to upload to, either gs://name or s3://name
+	Bucket string
+	// GCSCredentialsFile holds the credentials used to upload to GCS
+	GCSCredentialsFile string
+	// S3CredentialsFile holds the credentials used to upload to S3
+	S3CredentialsFile string
+	// Censorer, if set, censors credentials in the artifacts before they
+	// are uploaded
+	Censorer *Censorer
+}
+
+// uploadFunc uploads data to the destinations in the bucket
+type uploadFunc func(targets map[string]gcs.UploadFunc) error
+
+// Uploader copies the artifacts a job collects locally to blob storage while
+// the job is still running, using the layout Prow uploads artifacts with.
+// Artifacts of steps which finished are then available even when the job is
+// killed before its sidecar gets to upload them.
+//
+// The uploader is thread safe and may be invoked in parallel.
+type Uploader struct {
+	dir    string
+	prefix string
+	upload uploadFunc
+	// censorer censors the artifacts before they are uploaded, if set
+	censorer *Censorer
+
+	trigger chan struct{}
+
+	lock sync.Mutex
+	// uploaded holds the state of every file when it was last uploaded,
+	// so that only new or changed files are uploaded again
+	uploaded map[string]fileState
+}
+
+type fileState struct {
+	size     int64
+	modified time.Time
+}
+
+// NewUploader creates an uploader for the artifacts in the directory. The
+// artifacts are stored under the path of the job in the bucket.
+func NewUploader(dir string, options Options, spec *downwardapi.JobSpec) *Uploader {
+	config := &prowv1.GCSConfiguration{PathStrategy: prowv1.PathStrategyExplicit}
+	if spec.DecorationConfig != nil && spec.DecorationConfig.GCSConfiguration != nil {
+		config = spec.DecorationConfig.GCSConfiguration
+	}
+	_, blobStoragePath, _ := gcsupload.PathsForJob(config, spec, "")
+	uploader := newUploader(dir, path.Join(blobStoragePath, "artifacts"), func(targets map[string]gcs.UploadFunc) error {
+		return gcs.Upload(options.Bucket, options.GCSCredentialsFile, options.S3CredentialsFile, targets)
+	})
+	uploader.censorer = options.Censorer
+	return uploader
+}
+
+func newUploader(dir, prefix string, upload uploadFunc) *Uploader {
+	return &Uploader{
+		dir:      dir,
+		prefix:   prefix,
+		upload:   upload,
+		trigger:  make(chan struct{}, 1),
+		uploaded: map[string]fileState{},
+	}
+}
+
+// Sync uploads the files in the directory which were not uploaded before or
+// changed since they were, censoring them first
+func (u *Uploader) Sync() error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if u.censorer != nil {
+		if err := u.censorer.Censor(); err != nil {
+			return err
+		}
+	}
+	targets := map[string]gcs.UploadFunc{}
+	changed := map[string]fileState{}
+	err := filepath.Walk(u.dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relative, err := filepath.Rel(u.dir, file)
+		if err != nil {
+			return err
+		}
+		state := fileState{size: info.Size(), modified: info.ModTime()}
+		if previous, ok := u.uploaded[relative]; ok && previous == state {
+			return nil
+		}
+		dir, filename := path.Split(path.Join(u.prefix, filepath.ToSlash(relative)))
+		name, options := gcs.WriterOptionsFromFileName(filename)
+		targets[path.Join(dir, name)] = gcs.FileUploadWithOptions(file, options)
+		changed[relative] = state
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not list artifacts in %s: %w", u.dir, err)
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	if err := u.upload(targets); err != nil {
+		return fmt.Errorf("could not upload artifacts: %w", err)
+	}
+	for relative, state := range changed {
+		u.uploaded[relative] = state
+	}
+	return nil
+}
+
+// StepFinished requests the artifacts to be uploaded in the background once
+// a step finished. Requests made while an upload runs are coalesced.
+func (u *Uploader) StepFinished(_ string, _ error) {
+	select {
+	case u.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run uploads the artifacts whenever a step finished until the context is
+// cancelled
+func (u *Uploader) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-u.trigger:
+			if err := u.Sync(); err != nil {
+				log.Printf("warning: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package artifacts
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+	"k8s.io/test-infra/prow/pod-utils/gcs"
+)
+
+func TestNewUploader(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		spec     downwardapi.JobSpec
+		expected string
+	}{
+		{
+			name: "presubmit",
+			spec: downwardapi.JobSpec{
+				Type:    prowv1.PresubmitJob,
+				Job:     "pull-ci-org-repo-master-e2e",
+				BuildID: "123",
+				Refs:    &prowv1.Refs{Org: "org", Repo: "repo", Pulls: []prowv1.Pull{{Number: 1}}},
+			},
+			expected: "pr-logs/pull/org_repo/1/pull-ci-org-repo-master-e2e/123/artifacts",
+		},
+		{
+			name: "periodic with a path prefix",
+			spec: downwardapi.JobSpec{
+				Type:    prowv1.PeriodicJob,
+				Job:     "periodic-ci-org-repo-master-e2e",
+				BuildID: "456",
+				DecorationConfig: &prowv1.DecorationConfig{GCSConfiguration: &prowv1.GCSConfiguration{
+					PathStrategy: prowv1.PathStrategyExplicit,
+					PathPrefix:   "prefix",
+				}},
+			},
+			expected: "prefix/logs/periodic-ci-org-repo-master-e2e/456/artifacts",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, NewUploader("dir", Options{}, &testCase.spec).prefix); diff != "" {
+				t.Errorf("unexpected prefix: %s", diff)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	if err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string, modified time.Time) {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("could not create directory: %v", err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("could not write file: %v", err)
+		}
+		if err := os.Chtimes(file, modified, modified); err != nil {
+			t.Fatalf("could not set modification time: %v", err)
+		}
+	}
+	var uploaded sets.String
+	var uploadErr error
+	uploader := newUploader(dir, "logs/job/1/artifacts", func(targets map[string]gcs.UploadFunc) error {
+		uploaded = sets.StringKeySet(targets)
+		return uploadErr
+	})
+	sync := func(expected ...string) {
+		t.Helper()
+		uploaded = sets.NewString()
+		if err := uploader.Sync(); err != nil && uploadErr == nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(sets.NewString(expected...).List(), uploaded.List()); diff != "" {
+			t.Errorf("unexpected uploads: %s", diff)
+		}
+	}
+
+	before := time.Now().Add(-time.Hour)
+	write("build-logs/src.log", "cloned", before)
+	write("e2e/junit.xml", "<testsuite/>", before)
+	sync("logs/job/1/artifacts/build-logs/src.log", "logs/job/1/artifacts/e2e/junit.xml")
+	// nothing changed
+	sync()
+
+	write("e2e/junit.xml", "<testsuite></testsuite>", time.Now())
+	write("e2e/container-logs/test.log.gz", "log", time.Now())
+	uploadErr = errors.New("injected failure")
+	sync("logs/job/1/artifacts/e2e/junit.xml", "logs/job/1/artifacts/e2e/container-logs/test.log")
+	// failed uploads are retried
+	uploadErr = nil
+	sync("logs/job/1/artifacts/e2e/junit.xml", "logs/job/1/artifacts/e2e/container-logs/test.log")
+	sync()
+}
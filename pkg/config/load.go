@@ -12,6 +12,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	cioperatorapi "github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/migration"
 	"github.com/openshift/ci-tools/pkg/util/gzip"
 	"github.com/openshift/ci-tools/pkg/validation"
 )
@@ -37,6 +38,11 @@ func readCiOperatorConfig(configFilePath string, info Info) (*cioperatorapi.Rele
 		return nil, fmt.Errorf("failed to read ci-operator config (%w)", err)
 	}
 
+	data, _, err = migration.Default.Migrate(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate ci-operator config (%w)", err)
+	}
+
 	var configSpec cioperatorapi.ReleaseBuildConfiguration
 	if err := yaml.Unmarshal(data, &configSpec); err != nil {
 		return nil, fmt.Errorf("failed to load ci-operator config (%w)", err)
@@ -0,0 +1,221 @@
+package namespacettl
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/openshift/ci-tools/pkg/api/nsttl"
+)
+
+const ControllerName = "namespace_ttl"
+
+// ciPodSelector selects the pods ci-operator creates for the steps of a job
+const ciPodSelector = "created-by-ci=true"
+
+// AddToManager adds a controller that reaps namespaces which carry the TTL
+// annotations ci-operator sets on its test namespaces, so that namespaces of
+// jobs that were killed without cleaning up after themselves do not leak.
+func AddToManager(clusterName string, mgr manager.Manager) error {
+	r := &reconciler{
+		client: mgr.GetClient(),
+		pods:   mgr.GetAPIReader(),
+		log:    logrus.WithField("controller", ControllerName).WithField("cluster", clusterName),
+		now:    time.Now,
+	}
+	c, err := controller.New(fmt.Sprintf("%s_%s", ControllerName, clusterName), mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: 10,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to construct controller: %w", err)
+	}
+
+	if err := c.Watch(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestForObject{}); err != nil {
+		return fmt.Errorf("failed to construct watch for Namespaces: %w", err)
+	}
+	// Pods determine whether a namespace is idle. Only the pods ci-operator
+	// creates are watched, so that not all pods of the cluster are cached;
+	// namespaces in use are checked again once their idle TTL passed.
+	client, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return fmt.Errorf("failed to construct client for Pods: %w", err)
+	}
+	informers := kubeinformers.NewSharedInformerFactoryWithOptions(client, 0, kubeinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+		options.LabelSelector = ciPodSelector
+	}))
+	if err := c.Watch(&source.Informer{Informer: informers.Core().V1().Pods().Informer()}, handler.EnqueueRequestsFromMapFunc(podMapper)); err != nil {
+		return fmt.Errorf("failed to construct watch for Pods: %w", err)
+	}
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		informers.Start(ctx.Done())
+		<-ctx.Done()
+		return nil
+	})); err != nil {
+		return fmt.Errorf("failed to add informers for Pods: %w", err)
+	}
+
+	return nil
+}
+
+func podMapper(o ctrlruntimeclient.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: o.GetNamespace()}}}
+}
+
+type reconciler struct {
+	client ctrlruntimeclient.Client
+	// pods lists all pods in a namespace, not only the ones which are watched
+	pods ctrlruntimeclient.Reader
+	log  *logrus.Entry
+	// Allow controlling time for tests
+	now func() time.Time
+}
+
+func (r *reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	l := r.log.WithField("namespace", req.Name)
+	res, err := r.reconcile(ctx, l, req)
+	if err != nil {
+		l.WithError(err).Error("Reconciliation failed")
+	}
+	if res == nil {
+		res = &reconcile.Result{}
+	}
+	return *res, err
+}
+
+func (r *reconciler) reconcile(ctx context.Context, l *logrus.Entry, req reconcile.Request) (*reconcile.Result, error) {
+	ns := &corev1.Namespace{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: req.Name}, ns); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get namespace %s: %w", req.Name, err)
+	}
+	if ns.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	hard, hasHard, err := durationFromAnnotation(ns, nsttl.AnnotationCleanupDurationTTL)
+	if err != nil {
+		// Retrying won't help, if someone fixes the value we will get triggered
+		l.WithError(err).Error("Ignoring invalid TTL")
+		hasHard = false
+	}
+	soft, hasSoft, err := durationFromAnnotation(ns, nsttl.AnnotationIdleCleanupDurationTTL)
+	if err != nil {
+		l.WithError(err).Error("Ignoring invalid TTL")
+		hasSoft = false
+	}
+	if !hasHard && !hasSoft {
+		return nil, nil
+	}
+
+	now := r.now()
+	var requeueAfter time.Duration
+	consider := func(deleteAt time.Time, reason string) bool {
+		if !deleteAt.After(now) {
+			l.WithField("reason", reason).WithField("delete_at", deleteAt.String()).Info("Deleting expired namespace")
+			return true
+		}
+		if until := deleteAt.Sub(now); requeueAfter == 0 || until < requeueAfter {
+			requeueAfter = until
+		}
+		return false
+	}
+
+	expired := hasHard && consider(ns.CreationTimestamp.Time.Add(hard), "hard TTL")
+	if beat := heartbeat(l, ns); !expired && hasSoft && beat != nil && beat.Alive(now) {
+		// ci-operator is still running the job, even if no pod runs right now
+		consider(beat.Expires(), "heartbeat")
+	} else if !expired && hasSoft {
+		idleSince, idle, err := r.idleSince(ctx, ns.Name, lastActive(l, ns))
+		if err != nil {
+			return nil, err
+		}
+		expired = idle && consider(idleSince.Add(soft), "idle TTL")
+		if !idle {
+			consider(now.Add(soft), "idle TTL")
+		}
+	}
+	if !expired {
+		return &reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	// Deleting the namespace deletes every object in it
+	background := metav1.DeletePropagationBackground
+	if err := r.client.Delete(ctx, ns, &ctrlruntimeclient.DeleteOptions{PropagationPolicy: &background}); err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to delete namespace %s: %w", ns.Name, err)
+	}
+	return nil, nil
+}
+
+func durationFromAnnotation(ns *corev1.Namespace, annotation string) (time.Duration, bool, error) {
+	raw, ok := ns.Annotations[annotation]
+	if !ok {
+		return 0, false, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to parse %s annotation value %q: %w", annotation, raw, err)
+	}
+	return d, d > 0, nil
+}
+
+// lastActive determines when the namespace was last in use, falling back to
+// its creation if ci-operator never recorded activity
+func lastActive(l *logrus.Entry, ns *corev1.Namespace) time.Time {
+	last := ns.CreationTimestamp.Time
+	if raw, ok := ns.Annotations[nsttl.AnnotationNamespaceLastActive]; ok {
+		active, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			l.WithError(err).Errorf("Failed to parse %s annotation value", nsttl.AnnotationNamespaceLastActive)
+		} else if active.After(last) {
+			last = active
+		}
+	}
+	return last
+}
+
+// heartbeat reads the heartbeat ci-operator records on the namespace
+func heartbeat(l *logrus.Entry, ns *corev1.Namespace) *nsttl.Heartbeat {
+	heartbeat, err := nsttl.HeartbeatFrom(ns.Annotations)
+	if err != nil {
+		l.WithError(err).Error("Ignoring invalid heartbeat")
+	}
+	return heartbeat
+}
+
+// idleSince determines if no pod in the namespace is running and if so, since when
+func (r *reconciler) idleSince(ctx context.Context, namespace string, lastActive time.Time) (time.Time, bool, error) {
+	pods := &corev1.PodList{}
+	if err := r.pods.List(ctx, pods, ctrlruntimeclient.InNamespace(namespace)); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to list pods in namespace %s: %w", namespace, err)
+	}
+	since := lastActive
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			return time.Time{}, false, nil
+		}
+		for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			if terminated := status.State.Terminated; terminated != nil && terminated.FinishedAt.Time.After(since) {
+				since = terminated.FinishedAt.Time
+			}
+		}
+	}
+	return since, true, nil
+}
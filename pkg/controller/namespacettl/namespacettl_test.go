@@ -0,0 +1,149 @@
+package namespacettl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/openshift/ci-tools/pkg/api/nsttl"
+)
+
+func TestReconcile(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	namespace := func(age time.Duration, annotations map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:              "ci-op-1234",
+			CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			Annotations:       annotations,
+		}}
+	}
+	pod := func(phase corev1.PodPhase, finishedAgo time.Duration) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-1234", Name: "test"},
+			Status: corev1.PodStatus{
+				Phase: phase,
+				ContainerStatuses: []corev1.ContainerStatus{{State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(now.Add(-finishedAgo))},
+				}}},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name                 string
+		objects              []runtime.Object
+		expectDeleted        bool
+		expectedRequeueAfter time.Duration
+	}{
+		{
+			name:    "namespace without TTL is left alone",
+			objects: []runtime.Object{namespace(100*time.Hour, nil)},
+		},
+		{
+			name:          "namespace past its hard TTL is deleted",
+			objects:       []runtime.Object{namespace(13*time.Hour, map[string]string{nsttl.AnnotationCleanupDurationTTL: "12h"})},
+			expectDeleted: true,
+		},
+		{
+			name:                 "namespace before its hard TTL is requeued",
+			objects:              []runtime.Object{namespace(10*time.Hour, map[string]string{nsttl.AnnotationCleanupDurationTTL: "12h"})},
+			expectedRequeueAfter: 2 * time.Hour,
+		},
+		{
+			name: "idle namespace past its soft TTL is deleted",
+			objects: []runtime.Object{
+				namespace(3*time.Hour, map[string]string{nsttl.AnnotationIdleCleanupDurationTTL: "1h", nsttl.AnnotationCleanupDurationTTL: "12h"}),
+				pod(corev1.PodSucceeded, 2*time.Hour),
+			},
+			expectDeleted: true,
+		},
+		{
+			name: "idle namespace recently active is requeued",
+			objects: []runtime.Object{
+				namespace(3*time.Hour, map[string]string{
+					nsttl.AnnotationIdleCleanupDurationTTL: "1h",
+					nsttl.AnnotationNamespaceLastActive:    now.Add(-30 * time.Minute).Format(time.RFC3339),
+				}),
+				pod(corev1.PodFailed, 2*time.Hour),
+			},
+			expectedRequeueAfter: 30 * time.Minute,
+		},
+		{
+			name: "namespace with a running pod is checked again after its soft TTL",
+			objects: []runtime.Object{
+				namespace(3*time.Hour, map[string]string{nsttl.AnnotationIdleCleanupDurationTTL: "1h", nsttl.AnnotationCleanupDurationTTL: "12h"}),
+				pod(corev1.PodRunning, 0),
+			},
+			expectedRequeueAfter: time.Hour,
+		},
+		{
+			name: "namespace with a running pod waits for its hard TTL when it comes first",
+			objects: []runtime.Object{
+				namespace(11*time.Hour+30*time.Minute, map[string]string{nsttl.AnnotationIdleCleanupDurationTTL: "1h", nsttl.AnnotationCleanupDurationTTL: "12h"}),
+				pod(corev1.PodRunning, 0),
+			},
+			expectedRequeueAfter: 30 * time.Minute,
+		},
+		{
+			name: "idle namespace of a live job is requeued until its heartbeat expires",
+			objects: []runtime.Object{
+				namespace(3*time.Hour, map[string]string{
+					nsttl.AnnotationIdleCleanupDurationTTL: "1h",
+					nsttl.AnnotationNamespaceLastActive:    now.Add(-2 * time.Hour).Format(time.RFC3339),
+					nsttl.AnnotationHeartbeat:              `{"time":"2021-01-01T11:50:00Z","interval":"10m0s","finished":1,"total":3}`,
+				}),
+				pod(corev1.PodSucceeded, 2*time.Hour),
+			},
+			expectedRequeueAfter: 20 * time.Minute,
+		},
+		{
+			name: "idle namespace of an abandoned job past its soft TTL is deleted",
+			objects: []runtime.Object{
+				namespace(3*time.Hour, map[string]string{
+					nsttl.AnnotationIdleCleanupDurationTTL: "1h",
+					nsttl.AnnotationNamespaceLastActive:    now.Add(-2 * time.Hour).Format(time.RFC3339),
+					nsttl.AnnotationHeartbeat:              `{"time":"2021-01-01T10:00:00Z","interval":"10m0s","finished":1,"total":3}`,
+				}),
+				pod(corev1.PodSucceeded, 2*time.Hour),
+			},
+			expectDeleted: true,
+		},
+		{
+			name:    "invalid TTL is ignored",
+			objects: []runtime.Object{namespace(100*time.Hour, map[string]string{nsttl.AnnotationCleanupDurationTTL: "forever"})},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fakectrlruntimeclient.NewFakeClient(tc.objects...)
+			r := &reconciler{
+				client: client,
+				pods:   client,
+				log:    logrus.NewEntry(logrus.New()),
+				now:    func() time.Time { return now },
+			}
+			result, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "ci-op-1234"}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.RequeueAfter != tc.expectedRequeueAfter {
+				t.Errorf("expected requeue after %s, got %s", tc.expectedRequeueAfter, result.RequeueAfter)
+			}
+			err = client.Get(context.Background(), types.NamespacedName{Name: "ci-op-1234"}, &corev1.Namespace{})
+			if deleted := apierrors.IsNotFound(err); deleted != tc.expectDeleted {
+				t.Errorf("expected deleted: %t, got error: %v", tc.expectDeleted, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+// Package cost estimates the compute cost of the steps of a job from how long
+// they ran and the resources they requested.
+package cost
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	buildapi "github.com/openshift/api/build/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// ReportFilename is the name of the cost report in the artifacts of a job
+const ReportFilename = "ci-operator-cost.json"
+
+// Rates are the prices of compute resources, in the currency of the budget
+type Rates struct {
+	// CoreHour is the price of one requested core for an hour
+	CoreHour float64 `json:"core_hour"`
+	// GBHour is the price of one requested gigabyte of memory for an hour
+	GBHour float64 `json:"gb_hour"`
+}
+
+// LoadRates reads the rates from a file, which has to set all of them
+func LoadRates(path string) (Rates, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Rates{}, fmt.Errorf("could not read cost rates: %w", err)
+	}
+	var rates struct {
+		CoreHour *float64 `json:"core_hour"`
+		GBHour   *float64 `json:"gb_hour"`
+	}
+	if err := yaml.UnmarshalStrict(raw, &rates); err != nil {
+		return Rates{}, fmt.Errorf("could not parse cost rates: %w", err)
+	}
+	for _, rate := range []struct {
+		name  string
+		value *float64
+	}{{name: "core_hour", value: rates.CoreHour}, {name: "gb_hour", value: rates.GBHour}} {
+		if rate.value == nil {
+			return Rates{}, fmt.Errorf("cost rate %s is required", rate.name)
+		}
+		if *rate.value < 0 {
+			return Rates{}, fmt.Errorf("cost rate %s cannot be negative", rate.name)
+		}
+	}
+	return Rates{CoreHour: *rates.CoreHour, GBHour: *rates.GBHour}, nil
+}
+
+// Options holds the rates costs are estimated with
+type Options struct {
+	path  string
+	rates *Rates
+}
+
+// Bind adds flags for the options
+func (o *Options) Bind(flag *flag.FlagSet) {
+	flag.StringVar(&o.path, "cost-rates", "", "A file with the prices of one requested core (core_hour) and of one requested gigabyte of memory (gb_hour) for an hour, used to estimate the cost of steps. Costs are not estimated unless set.")
+}
+
+// Complete loads the configured rates
+func (o *Options) Complete() error {
+	if o.path == "" {
+		return nil
+	}
+	rates, err := LoadRates(o.path)
+	if err != nil {
+		return err
+	}
+	o.rates = &rates
+	return nil
+}
+
+// Rates returns the configured rates, if any
+func (o *Options) Rates() (Rates, bool) {
+	if o.rates == nil {
+		return Rates{}, false
+	}
+	return *o.rates, true
+}
+
+// StepCost is the estimated cost of a step
+type StepCost struct {
+	Name string `json:"name"`
+	// Duration is how long the step ran, in seconds
+	Duration float64 `json:"duration_seconds"`
+	// CoreHours are the cores the step requested over the time it ran
+	CoreHours float64 `json:"core_hours"`
+	// GBHours are the gigabytes of memory the step requested over the time
+	// it ran
+	GBHours float64 `json:"gb_hours"`
+	Cost    float64 `json:"cost"`
+	// Substeps hold the costs of the pods of multi-stage tests
+	Substeps []StepCost `json:"substeps,omitempty"`
+}
+
+// Report is the estimated cost of a job
+type Report struct {
+	Rates     Rates      `json:"rates"`
+	CoreHours float64    `json:"core_hours"`
+	GBHours   float64    `json:"gb_hours"`
+	Cost      float64    `json:"cost"`
+	Steps     []StepCost `json:"steps"`
+}
+
+// Estimate computes the cost of the steps of the graph. Pods of multi-stage
+// tests are charged for what their containers requested; other steps which
+// ran pods or builds are charged for the resources configured for them.
+// Steps which ran no workload are not charged.
+func Estimate(graph api.CIOperatorStepGraph, resources api.ResourceConfiguration, rates Rates) Report {
+	report := Report{Rates: rates, Steps: []StepCost{}}
+	for _, step := range graph {
+		var cost StepCost
+		if len(step.Substeps) > 0 {
+			cost = StepCost{Name: step.StepName, Duration: seconds(step.Duration)}
+			for _, substep := range step.Substeps {
+				requests := resources.RequirementsForStep(step.StepName).Requests
+				if usage := requested(substep.ResourceUsage); usage != nil {
+					requests = usage
+				}
+				substepCost := estimate(substep.StepName, substep.Duration, requests, rates)
+				cost.Substeps = append(cost.Substeps, substepCost)
+				cost.CoreHours += substepCost.CoreHours
+				cost.GBHours += substepCost.GBHours
+				cost.Cost += substepCost.Cost
+			}
+		} else if runsWorkload(step.Manifests) {
+			cost = estimate(step.StepName, step.Duration, resources.RequirementsForStep(step.StepName).Requests, rates)
+		} else {
+			continue
+		}
+		report.Steps = append(report.Steps, cost)
+		report.CoreHours += cost.CoreHours
+		report.GBHours += cost.GBHours
+		report.Cost += cost.Cost
+	}
+	return report
+}
+
+// Write stores the report in the directory
+func (r Report) Write(dir string) error {
+	raw, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal cost report: %w", err)
+	}
+	return ioutil.WriteFile(filepath.Join(dir, ReportFilename), raw, 0644)
+}
+
+func estimate(name string, duration *time.Duration, requests api.ResourceList, rates Rates) StepCost {
+	cost := StepCost{Name: name, Duration: seconds(duration)}
+	hours := cost.Duration / time.Hour.Seconds()
+	if cpu, err := resource.ParseQuantity(requests["cpu"]); err == nil {
+		cost.CoreHours = float64(cpu.MilliValue()) / 1000 * hours
+	}
+	if memory, err := resource.ParseQuantity(requests["memory"]); err == nil {
+		cost.GBHours = float64(memory.Value()) / 1e9 * hours
+	}
+	cost.Cost = cost.CoreHours*rates.CoreHour + cost.GBHours*rates.GBHour
+	return cost
+}
+
+// requested sums what the containers of a pod requested
+func requested(usage map[string]api.ContainerResourceUsage) api.ResourceList {
+	var cpu, memory resource.Quantity
+	var found bool
+	for _, container := range usage {
+		for name, total := range map[string]*resource.Quantity{"cpu": &cpu, "memory": &memory} {
+			if quantity, err := resource.ParseQuantity(container.Requested[name]); err == nil {
+				total.Add(quantity)
+				found = true
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	return api.ResourceList{"cpu": cpu.String(), "memory": memory.String()}
+}
+
+func seconds(duration *time.Duration) float64 {
+	if duration == nil {
+		return 0
+	}
+	return duration.Seconds()
+}
+
+// runsWorkload determines whether a step ran a pod or a build, which is
+// what consumes compute
+func runsWorkload(manifests []ctrlruntimeclient.Object) bool {
+	for _, manifest := range manifests {
+		switch m := manifest.(type) {
+		case *coreapi.Pod, *buildapi.Build:
+			return true
+		case *unstructured.Unstructured:
+			if kind := m.GetKind(); kind == "Pod" || kind == "Build" {
+				return true
+			}
+		}
+	}
+	return false
+}
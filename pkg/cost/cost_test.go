@@ -0,0 +1,130 @@
+package cost
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	coreapi "k8s.io/api/core/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildapi "github.com/openshift/api/build/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestEstimate(t *testing.T) {
+	hour, halfHour := time.Hour, 30*time.Minute
+	graph := api.CIOperatorStepGraph{
+		{CIOperatorStepDetailInfo: api.CIOperatorStepDetailInfo{StepName: "[images]", Duration: &hour}},
+		{CIOperatorStepDetailInfo: api.CIOperatorStepDetailInfo{StepName: "src", Duration: &hour, Manifests: []ctrlruntimeclient.Object{&buildapi.Build{}}}},
+		{CIOperatorStepDetailInfo: api.CIOperatorStepDetailInfo{StepName: "unit", Duration: &halfHour, Manifests: []ctrlruntimeclient.Object{&coreapi.Pod{}}}},
+		{
+			CIOperatorStepDetailInfo: api.CIOperatorStepDetailInfo{StepName: "e2e", Duration: &hour},
+			Substeps: []api.CIOperatorStepDetailInfo{
+				{StepName: "e2e-install", Duration: &halfHour, ResourceUsage: map[string]api.ContainerResourceUsage{
+					"test":    {Requested: api.ResourceList{"cpu": "1500m", "memory": "2G"}},
+					"sidecar": {Requested: api.ResourceList{"cpu": "500m", "memory": "2G"}},
+				}},
+				{StepName: "e2e-test", Duration: &hour},
+			},
+		},
+	}
+	resources := api.ResourceConfiguration{
+		"*":    {Requests: api.ResourceList{"cpu": "100m", "memory": "200M"}},
+		"src":  {Requests: api.ResourceList{"cpu": "2", "memory": "4G"}},
+		"unit": {Requests: api.ResourceList{"cpu": "4"}},
+	}
+	expected := Report{
+		Rates:     Rates{CoreHour: 0.04, GBHour: 0.005},
+		CoreHours: 2 + 2 + 1 + 0.1,
+		GBHours:   4 + 0.1 + 2 + 0.2,
+		Cost:      0.04*5.1 + 0.005*6.3,
+		Steps: []StepCost{
+			{Name: "src", Duration: 3600, CoreHours: 2, GBHours: 4, Cost: 0.04*2 + 0.005*4},
+			{Name: "unit", Duration: 1800, CoreHours: 2, GBHours: 0.1, Cost: 0.04*2 + 0.005*0.1},
+			{
+				Name: "e2e", Duration: 3600, CoreHours: 1.1, GBHours: 2.2, Cost: 0.04*1.1 + 0.005*2.2,
+				Substeps: []StepCost{
+					{Name: "e2e-install", Duration: 1800, CoreHours: 1, GBHours: 2, Cost: 0.04*1 + 0.005*2},
+					{Name: "e2e-test", Duration: 3600, CoreHours: 0.1, GBHours: 0.2, Cost: 0.04*0.1 + 0.005*0.2},
+				},
+			},
+		},
+	}
+	actual := Estimate(graph, resources, Rates{CoreHour: 0.04, GBHour: 0.005})
+	if diff := cmp.Diff(expected, actual, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("unexpected report: %s", diff)
+	}
+
+	dir := t.TempDir()
+	if err := actual.Write(dir); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+	raw, err := ioutil.ReadFile(filepath.Join(dir, ReportFilename))
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	var written Report
+	if err := json.Unmarshal(raw, &written); err != nil {
+		t.Fatalf("failed to unmarshal report: %v", err)
+	}
+	if diff := cmp.Diff(actual, written); diff != "" {
+		t.Errorf("report changed when written: %s", diff)
+	}
+}
+
+func TestLoadRates(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		raw         string
+		expected    Rates
+		expectedErr bool
+	}{
+		{
+			name:     "all rates set",
+			raw:      "core_hour: 0.04\ngb_hour: 0.005\n",
+			expected: Rates{CoreHour: 0.04, GBHour: 0.005},
+		},
+		{
+			name:     "free memory is allowed",
+			raw:      "core_hour: 0.04\ngb_hour: 0\n",
+			expected: Rates{CoreHour: 0.04},
+		},
+		{
+			name:        "missing rate",
+			raw:         "core_hour: 0.04\n",
+			expectedErr: true,
+		},
+		{
+			name:        "negative rate",
+			raw:         "core_hour: -1\ngb_hour: 0.005\n",
+			expectedErr: true,
+		},
+		{
+			name:        "unknown field",
+			raw:         "core_hour: 0.04\ngb_hour: 0.005\ngpu_hour: 1\n",
+			expectedErr: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rates.yaml")
+			if err := ioutil.WriteFile(path, []byte(testCase.raw), 0644); err != nil {
+				t.Fatal(err)
+			}
+			rates, err := LoadRates(path)
+			if testCase.expectedErr != (err != nil) {
+				t.Fatalf("expected error %t, got %v", testCase.expectedErr, err)
+			}
+			if diff := cmp.Diff(testCase.expected, rates); diff != "" {
+				t.Errorf("unexpected rates: %s", diff)
+			}
+		})
+	}
+}
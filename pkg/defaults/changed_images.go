@@ -0,0 +1,64 @@
+package defaults
+
+import (
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// ChangedImages determines which images have to be rebuilt when the files in
+// the repository were changed. An image is affected when a changed file is in
+// its context directory or is its Dockerfile, or when it is built from or
+// copies content out of another affected image.
+func ChangedImages(images []api.ProjectDirectoryImageBuildStepConfiguration, changed []string) sets.String {
+	affected := sets.NewString()
+	for _, image := range images {
+		if touched(image.ProjectDirectoryImageBuildInputs, changed) {
+			affected.Insert(string(image.To))
+		}
+	}
+	// propagate changes through images built on top of each other until
+	// nothing else is affected
+	for {
+		before := affected.Len()
+		for _, image := range images {
+			if affected.Has(string(image.To)) {
+				continue
+			}
+			dependencies := sets.NewString(string(image.From))
+			for name := range image.Inputs {
+				dependencies.Insert(name)
+			}
+			if dependencies.HasAny(affected.UnsortedList()...) {
+				affected.Insert(string(image.To))
+			}
+		}
+		if affected.Len() == before {
+			return affected
+		}
+	}
+}
+
+// touched determines whether any of the changed files are a part of the build
+// context or the Dockerfile of the image
+func touched(inputs api.ProjectDirectoryImageBuildInputs, changed []string) bool {
+	contextDir := path.Clean(inputs.ContextDir)
+	var dockerfile string
+	if inputs.DockerfileLiteral == nil {
+		dockerfilePath := inputs.DockerfilePath
+		if dockerfilePath == "" {
+			dockerfilePath = "Dockerfile"
+		}
+		dockerfile = path.Join(contextDir, dockerfilePath)
+	}
+	for _, file := range changed {
+		file = path.Clean(file)
+		if contextDir == "." || file == contextDir || strings.HasPrefix(file, contextDir+"/") || file == dockerfile {
+			return true
+		}
+	}
+	return false
+}
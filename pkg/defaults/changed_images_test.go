@@ -0,0 +1,84 @@
+package defaults
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestChangedImages(t *testing.T) {
+	literal := "FROM base"
+	images := []api.ProjectDirectoryImageBuildStepConfiguration{
+		{
+			To:                               "operator",
+			ProjectDirectoryImageBuildInputs: api.ProjectDirectoryImageBuildInputs{ContextDir: "images/operator"},
+		},
+		{
+			To:                               "installer",
+			ProjectDirectoryImageBuildInputs: api.ProjectDirectoryImageBuildInputs{ContextDir: "images/installer/", DockerfilePath: "../../build/Dockerfile.installer"},
+		},
+		{
+			From:                             "operator",
+			To:                               "operator-tests",
+			ProjectDirectoryImageBuildInputs: api.ProjectDirectoryImageBuildInputs{ContextDir: "test", DockerfileLiteral: &literal},
+		},
+		{
+			To: "bundle",
+			ProjectDirectoryImageBuildInputs: api.ProjectDirectoryImageBuildInputs{
+				ContextDir: "manifests",
+				Inputs:     map[string]api.ImageBuildInputs{"operator-tests": {Paths: []api.ImageSourcePath{{SourcePath: "/bin/tests", DestinationDir: "."}}}},
+			},
+		},
+	}
+	var testCases = []struct {
+		name     string
+		images   []api.ProjectDirectoryImageBuildStepConfiguration
+		changed  []string
+		expected []string
+	}{
+		{
+			name:     "nothing changed",
+			images:   images,
+			expected: []string{},
+		},
+		{
+			name:     "unrelated files changed",
+			images:   images,
+			changed:  []string{"README.md", "images/operator-old/Dockerfile", "build/Dockerfile.other"},
+			expected: []string{},
+		},
+		{
+			name:     "change in the context propagates to images built from it",
+			images:   images,
+			changed:  []string{"images/operator/main.go"},
+			expected: []string{"bundle", "operator", "operator-tests"},
+		},
+		{
+			name:     "Dockerfile outside of the context changed",
+			images:   images,
+			changed:  []string{"build/Dockerfile.installer"},
+			expected: []string{"installer"},
+		},
+		{
+			name:     "change in the context of an image with an inline Dockerfile",
+			images:   images,
+			changed:  []string{"test/e2e.go", "manifests/csv.yaml"},
+			expected: []string{"bundle", "operator-tests"},
+		},
+		{
+			name:     "image built from the repository root is always affected",
+			images:   []api.ProjectDirectoryImageBuildStepConfiguration{{To: "root"}},
+			changed:  []string{"docs/index.md"},
+			expected: []string{"root"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, ChangedImages(testCase.images, testCase.changed).List()); diff != "" {
+				t.Errorf("unexpected images: %s", diff)
+			}
+		})
+	}
+}
@@ -19,7 +19,9 @@ import (
 	templateclientset "github.com/openshift/client-go/template/clientset/versioned/typed/template/v1"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/imagemirror"
 	"github.com/openshift/ci-tools/pkg/lease"
+	"github.com/openshift/ci-tools/pkg/quay"
 	"github.com/openshift/ci-tools/pkg/release"
 	"github.com/openshift/ci-tools/pkg/release/candidate"
 	"github.com/openshift/ci-tools/pkg/release/official"
@@ -27,6 +29,7 @@ import (
 	"github.com/openshift/ci-tools/pkg/results"
 	"github.com/openshift/ci-tools/pkg/steps"
 	"github.com/openshift/ci-tools/pkg/steps/clusterinstall"
+	"github.com/openshift/ci-tools/pkg/steps/gather"
 	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
 	releasesteps "github.com/openshift/ci-tools/pkg/steps/release"
 	"github.com/openshift/ci-tools/pkg/steps/utils"
@@ -38,7 +41,8 @@ type inputImageSet map[api.InputImageTagStepConfiguration]struct{}
 // the release build configuration and generates steps for
 // them, returning the full set of steps requires for the
 // build, including defaulted steps, generated steps and
-// all raw steps that the user provided.
+// all raw steps that the user provided. When the changed
+// images are known, [images] only builds those.
 func FromConfig(
 	config *api.ReleaseBuildConfiguration,
 	jobSpec *api.JobSpec,
@@ -49,32 +53,57 @@ func FromConfig(
 	leaseClient *lease.Client,
 	requiredTargets []string,
 	cloneAuthConfig *steps.CloneAuthConfig,
-	pullSecret, pushSecret *coreapi.Secret,
+	pullSecret, pushSecret, signingSecret *coreapi.Secret,
+	quayClient quay.Client,
+	byoCluster *steps.BYOClusterConfig,
+	vault steps.VaultClient,
+	payloadOverrides releasesteps.PayloadOverrides,
+	dependencyOverrides steps.DependencyOverrides,
+	changedImages sets.String,
+	buildDispatcher *steps.BuildDispatcher,
+	buildBackend steps.BuildBackend,
+	clonerefs *steps.ClonerefsOverride,
+	proxy *steps.ProxyConfiguration,
+	hermeticEgress []string,
+	inputs map[string]string,
+	apiMetrics *steps.APIClientMetrics,
 ) ([]api.Step, []api.Step, error) {
 	crclient, err := ctrlruntimeclient.New(clusterConfig, ctrlruntimeclient.Options{})
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to construct client: %w", err)
 	}
+	crclient = steps.NewResilientClient(crclient, steps.DefaultAPIBackoff, apiMetrics)
+	if config.Namespace != nil && config.Namespace.PriorityClassName != "" {
+		crclient = steps.NewPriorityClassClient(crclient, config.Namespace.PriorityClassName)
+	}
+	if proxy != nil {
+		crclient = steps.NewProxyClient(crclient, *proxy)
+	}
 	client := loggingclient.New(crclient)
 	buildGetter, err := buildclientset.NewForConfig(clusterConfig)
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not get build client for cluster config: %w", err)
 	}
+	coreGetter, err := coreclientset.NewForConfig(clusterConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get core client for cluster config: %w", err)
+	}
+	podClient := steps.NewPodClient(client, clusterConfig, coreGetter.RESTClient())
+
 	buildClient := steps.NewBuildClient(client, buildGetter.RESTClient())
+	if buildBackend == steps.BuildBackendTekton {
+		buildClient = steps.NewTektonBuildClient(buildClient, podClient)
+	}
+	if buildDispatcher != nil {
+		buildClient = steps.NewDispatchingBuildClient(buildClient, buildDispatcher)
+	}
 
 	templateGetter, err := templateclientset.NewForConfig(clusterConfig)
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not get template client for cluster config: %w", err)
 	}
 	templateClient := steps.NewTemplateClient(client, templateGetter.RESTClient())
-
-	coreGetter, err := coreclientset.NewForConfig(clusterConfig)
-	if err != nil {
-		return nil, nil, fmt.Errorf("could not get core client for cluster config: %w", err)
-	}
-
-	podClient := steps.NewPodClient(client, clusterConfig, coreGetter.RESTClient())
-	return fromConfig(config, jobSpec, templates, paramFile, promote, client, buildClient, templateClient, podClient, leaseClient, &http.Client{}, requiredTargets, cloneAuthConfig, pullSecret, pushSecret, api.NewDeferredParameters(nil))
+	return fromConfig(config, jobSpec, templates, paramFile, promote, client, buildClient, templateClient, podClient, leaseClient, &http.Client{}, requiredTargets, cloneAuthConfig, pullSecret, pushSecret, signingSecret, quayClient, byoCluster, vault, payloadOverrides, dependencyOverrides, changedImages, clonerefs, hermeticEgress, api.NewDeferredParametersWithInputs(inputs))
 }
 
 func fromConfig(
@@ -91,7 +120,15 @@ func fromConfig(
 	httpClient release.HTTPClient,
 	requiredTargets []string,
 	cloneAuthConfig *steps.CloneAuthConfig,
-	pullSecret, pushSecret *coreapi.Secret,
+	pullSecret, pushSecret, signingSecret *coreapi.Secret,
+	quayClient quay.Client,
+	byoCluster *steps.BYOClusterConfig,
+	vault steps.VaultClient,
+	payloadOverrides releasesteps.PayloadOverrides,
+	dependencyOverrides steps.DependencyOverrides,
+	changedImages sets.String,
+	clonerefs *steps.ClonerefsOverride,
+	hermeticEgress []string,
 	params *api.DeferredParameters,
 ) ([]api.Step, []api.Step, error) {
 	requiredNames := sets.NewString()
@@ -102,17 +139,23 @@ func fromConfig(
 	params.Add("JOB_NAME_HASH", func() (string, error) { return jobSpec.JobNameHash(), nil })
 	params.Add("JOB_NAME_SAFE", func() (string, error) { return strings.Replace(jobSpec.Job, "_", "-", -1), nil })
 	params.Add("NAMESPACE", func() (string, error) { return jobSpec.Namespace(), nil })
+	if clonerefs == nil && config.Clonerefs != nil {
+		clonerefs = steps.ClonerefsOverrideFromConfig(*config.Clonerefs)
+	}
 	inputImages := make(inputImageSet)
+	externalImages := sets.NewString()
+	imports := steps.NewImportManager(steps.DefaultImportConcurrency, steps.DefaultImportBackoff)
 	var overridableSteps, buildSteps, postSteps []api.Step
 	var imageStepLinks []api.StepLink
 	var hasReleaseStep bool
+	releases := sets.NewString()
 	rawSteps, err := stepConfigsForBuild(config, jobSpec, ioutil.ReadFile)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get stepConfigsForBuild: %w", err)
 	}
 	for _, rawStep := range rawSteps {
 		if testStep := rawStep.TestStepConfiguration; testStep != nil {
-			steps, err := stepForTest(config, params, podClient, leaseClient, templateClient, client, jobSpec, inputImages, testStep)
+			steps, err := stepForTest(config, params, podClient, leaseClient, templateClient, client, imports, jobSpec, inputImages, externalImages, testStep, byoCluster, vault, dependencyOverrides, cloneAuthConfig, clonerefs)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -128,11 +171,18 @@ func fromConfig(
 			// this is a disgusting hack but the simplest implementation until we
 			// factor release steps into something more reusable
 			hasReleaseStep = true
-			var value string
+			if resolveConfig.Assembled != nil && !params.HasInput(utils.ReleaseImageEnv(resolveConfig.Name)) {
+				releases.Insert(resolveConfig.Name)
+				step := releasesteps.AssembledReleaseStep(resolveConfig.Name, resolveConfig.Assembled, payloadOverrides[resolveConfig.Name], podClient, jobSpec)
+				buildSteps = append(buildSteps, step)
+				addProvidesForStep(step, params)
+				continue
+			}
+			var value, version string
 			if env := utils.ReleaseImageEnv(resolveConfig.Name); params.HasInput(env) {
 				value, err = params.Get(env)
 				if err != nil {
-					return nil, nil, results.ForReason("resolving_release").ForError(fmt.Errorf("failed to get %q parameter: %w", env, err))
+					return nil, nil, results.ForReason(results.ReasonResolvingRelease).ForError(fmt.Errorf("failed to get %q parameter: %w", env, err))
 				}
 				log.Printf("Using explicitly provided pull-spec for release %s (%s)", resolveConfig.Name, value)
 			} else {
@@ -140,16 +190,21 @@ func fromConfig(
 				case resolveConfig.Candidate != nil:
 					value, err = candidate.ResolvePullSpec(httpClient, *resolveConfig.Candidate)
 				case resolveConfig.Release != nil:
-					value, _, err = official.ResolvePullSpecAndVersion(httpClient, *resolveConfig.Release)
+					value, version, err = official.ResolvePullSpecAndVersion(httpClient, *resolveConfig.Release)
 				case resolveConfig.Prerelease != nil:
 					value, err = prerelease.ResolvePullSpec(httpClient, *resolveConfig.Prerelease)
 				}
 				if err != nil {
-					return nil, nil, results.ForReason("resolving_release").ForError(fmt.Errorf("failed to resolve release %s: %w", resolveConfig.Name, err))
+					return nil, nil, results.ForReason(results.ReasonResolvingRelease).ForError(fmt.Errorf("failed to resolve release %s: %w", resolveConfig.Name, err))
+				}
+				if version != "" {
+					log.Printf("Resolved release %s to %s (version %s)", resolveConfig.Name, value, version)
+				} else {
+					log.Printf("Resolved release %s to %s", resolveConfig.Name, value)
 				}
-				log.Printf("Resolved release %s to %s", resolveConfig.Name, value)
 			}
-			step := releasesteps.ImportReleaseStep(resolveConfig.Name, value, false, config.Resources, podClient, jobSpec, pullSecret)
+			releases.Insert(resolveConfig.Name)
+			step := releasesteps.ImportReleaseStep(resolveConfig.Name, value, version, payloadOverrides[resolveConfig.Name], false, config.Resources, podClient, imports, jobSpec, pullSecret)
 			buildSteps = append(buildSteps, step)
 			addProvidesForStep(step, params)
 			continue
@@ -161,18 +216,18 @@ func fromConfig(
 			if _, ok := inputImages[conf]; ok {
 				continue
 			}
-			step = steps.InputImageTagStep(conf, client, jobSpec)
+			step = steps.InputImageTagStep(conf, client, imports, jobSpec, pullSecret, signingSecret)
 			inputImages[conf] = struct{}{}
 		} else if rawStep.PipelineImageCacheStepConfiguration != nil {
 			step = steps.PipelineImageCacheStep(*rawStep.PipelineImageCacheStepConfiguration, config.Resources, buildClient, jobSpec, pullSecret)
 		} else if rawStep.SourceStepConfiguration != nil {
-			step = steps.SourceStep(*rawStep.SourceStepConfiguration, config.Resources, buildClient, jobSpec, cloneAuthConfig, pullSecret)
+			step = steps.SourceStep(*rawStep.SourceStepConfiguration, config.Resources, buildClient, jobSpec, cloneAuthConfig, pullSecret, podClient, clonerefs)
 		} else if rawStep.BundleSourceStepConfiguration != nil {
 			step = steps.BundleSourceStep(*rawStep.BundleSourceStepConfiguration, config, config.Resources, buildClient, jobSpec, pullSecret)
 		} else if rawStep.IndexGeneratorStepConfiguration != nil {
 			step = steps.IndexGeneratorStep(*rawStep.IndexGeneratorStepConfiguration, config, config.Resources, buildClient, jobSpec, pullSecret)
 		} else if rawStep.ProjectDirectoryImageBuildStepConfiguration != nil {
-			step = steps.ProjectDirectoryImageBuildStep(*rawStep.ProjectDirectoryImageBuildStepConfiguration, config.Resources, buildClient, jobSpec, pullSecret)
+			step = steps.ProjectDirectoryImageBuildStep(*rawStep.ProjectDirectoryImageBuildStepConfiguration, config.Resources, buildClient, params, jobSpec, pullSecret, hermeticEgress)
 		} else if rawStep.ProjectDirectoryImageBuildInputs != nil {
 			step = steps.GitSourceStep(*rawStep.ProjectDirectoryImageBuildInputs, config.Resources, buildClient, jobSpec, cloneAuthConfig, pullSecret)
 		} else if rawStep.RPMImageInjectionStepConfiguration != nil {
@@ -181,14 +236,18 @@ func fromConfig(
 			step = steps.RPMServerStep(*rawStep.RPMServeStepConfiguration, client, jobSpec)
 		} else if rawStep.OutputImageTagStepConfiguration != nil {
 			step = steps.OutputImageTagStep(*rawStep.OutputImageTagStepConfiguration, client, jobSpec)
-			// all required or non-optional output images are considered part of [images]
-			if requiredNames.Has(string(rawStep.OutputImageTagStepConfiguration.From)) || !rawStep.OutputImageTagStepConfiguration.Optional {
+			// all required or non-optional output images are considered part of [images],
+			// unless they are known not to be affected by the changes
+			from := string(rawStep.OutputImageTagStepConfiguration.From)
+			if (requiredNames.Has(from) || !rawStep.OutputImageTagStepConfiguration.Optional) && (changedImages == nil || changedImages.Has(from)) {
 				stepLinks = append(stepLinks, step.Creates()...)
 			}
 		} else if rawStep.ReleaseImagesTagStepConfiguration != nil {
 			// if the user has specified a tag_specification we always
 			// will import those images to the stable stream
-			step = releasesteps.ReleaseImagesTagStep(*rawStep.ReleaseImagesTagStepConfiguration, client, params, jobSpec)
+			snapshot := &releasesteps.TagSnapshot{}
+			overridableSteps = append(overridableSteps, releasesteps.TagSpecificationSnapshotStep(*rawStep.ReleaseImagesTagStepConfiguration, client, jobSpec, snapshot))
+			step = releasesteps.ReleaseImagesTagStep(*rawStep.ReleaseImagesTagStepConfiguration, client, params, jobSpec, snapshot)
 			stepLinks = append(stepLinks, step.Creates()...)
 
 			hasReleaseStep = true
@@ -203,13 +262,14 @@ func fromConfig(
 				if params.HasInput(envVar) {
 					pullSpec, err := params.Get(envVar)
 					if err != nil {
-						return nil, nil, results.ForReason("reading_release").ForError(fmt.Errorf("failed to read input release pullSpec %s: %w", name, err))
+						return nil, nil, results.ForReason(results.ReasonReadingRelease).ForError(fmt.Errorf("failed to read input release pullSpec %s: %w", name, err))
 					}
 					log.Printf("Resolved release %s to %s", name, pullSpec)
-					releaseStep = releasesteps.ImportReleaseStep(name, pullSpec, true, config.Resources, podClient, jobSpec, pullSecret)
+					releaseStep = releasesteps.ImportReleaseStep(name, pullSpec, "", payloadOverrides[name], true, config.Resources, podClient, imports, jobSpec, pullSecret)
 				} else {
-					releaseStep = releasesteps.AssembleReleaseStep(name, rawStep.ReleaseImagesTagStepConfiguration, config.Resources, podClient, jobSpec)
+					releaseStep = releasesteps.AssembleReleaseStep(name, rawStep.ReleaseImagesTagStepConfiguration, payloadOverrides[name], podClient, jobSpec)
 				}
+				releases.Insert(name)
 				overridableSteps = append(overridableSteps, releaseStep)
 				addProvidesForStep(releaseStep, params)
 			}
@@ -224,7 +284,7 @@ func fromConfig(
 	}
 
 	for _, template := range templates {
-		step := steps.TemplateExecutionStep(template, params, podClient, templateClient, jobSpec, config.Resources)
+		step := steps.TemplateExecutionStep(template, params, podClient, templateClient, jobSpec, config.Resources, config.ArtifactGathering)
 		var hasClusterType, hasUseLease bool
 		for _, p := range template.Parameters {
 			hasClusterType = hasClusterType || p.Name == "CLUSTER_TYPE"
@@ -257,12 +317,39 @@ func fromConfig(
 		addProvidesForStep(step, params)
 	}
 
+	for name := range payloadOverrides {
+		if !releases.Has(name) {
+			return nil, nil, fmt.Errorf("payload components of release %s are overridden, but the job does not use that release", name)
+		}
+	}
+	if err := dependencyOverrides.Validate(config); err != nil {
+		return nil, nil, err
+	}
+
 	if !hasReleaseStep {
 		step := releasesteps.StableImagesTagStep(client, jobSpec)
 		buildSteps = append(buildSteps, step)
 		addProvidesForStep(step, params)
 	}
 
+	if config.Attestations != nil && len(config.Images) > 0 {
+		step := steps.AttestationStep(*config.Attestations, config.Images, podClient, jobSpec)
+		buildSteps = append(buildSteps, step)
+		imageStepLinks = append(imageStepLinks, step.Creates()...)
+	}
+
+	if config.VulnerabilityScan != nil && len(config.Images) > 0 {
+		step := steps.VulnerabilityScanStep(*config.VulnerabilityScan, config.Images, podClient, jobSpec)
+		buildSteps = append(buildSteps, step)
+		imageStepLinks = append(imageStepLinks, step.Creates()...)
+	}
+
+	if config.FIPSCheck != nil && len(config.FIPSCheck.Images) > 0 {
+		step := steps.FIPSCheckStep(*config.FIPSCheck, podClient, jobSpec)
+		buildSteps = append(buildSteps, step)
+		imageStepLinks = append(imageStepLinks, step.Creates()...)
+	}
+
 	step := steps.ImagesReadyStep(imageStepLinks)
 	buildSteps = append(buildSteps, step)
 	addProvidesForStep(step, params)
@@ -272,7 +359,31 @@ func fromConfig(
 		if err != nil {
 			return nil, nil, fmt.Errorf("could not determine promotion defaults: %w", err)
 		}
-		postSteps = append(postSteps, releasesteps.PromotionStep(*cfg, config.Images, requiredNames, jobSpec, podClient, pushSecret))
+		if config.Approval != nil {
+			postSteps = append(postSteps, steps.ApprovalStep(*config.Approval, client, httpClient, jobSpec))
+		}
+		postSteps = append(postSteps, releasesteps.PromotionStep(*cfg, config.Metadata, config.Images, requiredNames, jobSpec, podClient, pushSecret, signingSecret, quayClient))
+	}
+
+	// images are mirrored whenever configured, the index only along with
+	// the promoted images
+	mirrors := append([]api.ImageMirror{}, config.Mirror...)
+	if promote && config.Operator != nil && config.Operator.Index != nil && config.Operator.Index.PushTo != "" {
+		mirrors = append(mirrors, api.ImageMirror{
+			From: fmt.Sprintf("%s:%s", api.PipelineImageStream, api.PipelineImageStreamTagReferenceIndexImage),
+			To:   config.Operator.Index.PushTo,
+		})
+	}
+	if len(mirrors) > 0 {
+		var mirrorClient imagemirror.Client
+		if pushSecret != nil {
+			credentials, err := imagemirror.CredentialsFromDockerConfig(pushSecret.Data[coreapi.DockerConfigJsonKey])
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not load mirroring credentials: %w", err)
+			}
+			mirrorClient = imagemirror.NewClient(credentials, http.DefaultTransport)
+		}
+		postSteps = append(postSteps, steps.ImageMirrorStep(mirrors, client, mirrorClient, jobSpec))
 	}
 
 	return append(overridableSteps, buildSteps...), postSteps, nil
@@ -289,28 +400,52 @@ func stepForTest(
 	leaseClient *lease.Client,
 	templateClient steps.TemplateClient,
 	client loggingclient.LoggingClient,
+	imports *steps.ImportManager,
 	jobSpec *api.JobSpec,
 	inputImages inputImageSet,
+	externalImages sets.String,
 	c *api.TestStepConfiguration,
+	byoCluster *steps.BYOClusterConfig,
+	vault steps.VaultClient,
+	dependencyOverrides steps.DependencyOverrides,
+	cloneAuthConfig *steps.CloneAuthConfig,
+	clonerefs *steps.ClonerefsOverride,
 ) ([]api.Step, error) {
-	if test := c.MultiStageTestConfigurationLiteral; test != nil {
-		leases := leasesForTest(test)
-		if len(leases) != 0 {
-			params = api.NewDeferredParameters(params)
-		}
-		step := steps.MultiStageTestStep(*c, config, params, podClient, jobSpec, leases)
-		if len(leases) != 0 {
-			step = steps.LeaseStep(leaseClient, leases, step, jobSpec.Namespace)
-			addProvidesForStep(step, params)
+	if c.MultiStageTestConfigurationLiteral != nil {
+		c = withGatherSteps(c)
+		test := c.MultiStageTestConfigurationLiteral
+		multiStageStep := func(c api.TestStepConfiguration) api.Step {
+			leases := leasesForTest(c.MultiStageTestConfigurationLiteral, byoCluster != nil)
+			params := params
+			if len(leases) != 0 {
+				params = api.NewDeferredParameters(params)
+			}
+			step := steps.MultiStageTestStep(c, config, params, podClient, jobSpec, leases, byoCluster, vault, dependencyOverrides)
+			if len(leases) != 0 {
+				step = steps.LeaseStep(leaseClient, leases, step, jobSpec.Namespace)
+				addProvidesForStep(step, params)
+			}
+			return step
 		}
-		return append([]api.Step{step}, stepsForStepImages(client, jobSpec, inputImages, test)...), nil
+		var step api.Step
+		if comparison := test.Comparison; comparison != nil {
+			baseline := comparisonVariant(*c, steps.ComparisonBaseline, comparison.Baseline)
+			candidate := comparisonVariant(*c, steps.ComparisonCandidate, comparison.Candidate)
+			step = steps.ComparisonStep(c.As, multiStageStep(baseline), multiStageStep(candidate))
+			// each variant may reference images the other does not
+			ret := []api.Step{step}
+			ret = append(ret, stepsForStepImages(client, imports, jobSpec, inputImages, externalImages, baseline.MultiStageTestConfigurationLiteral)...)
+			return append(ret, stepsForStepImages(client, imports, jobSpec, inputImages, externalImages, candidate.MultiStageTestConfigurationLiteral)...), nil
+		}
+		step = multiStageStep(*c)
+		return append([]api.Step{step}, stepsForStepImages(client, imports, jobSpec, inputImages, externalImages, test)...), nil
 	}
 	if test := c.OpenshiftInstallerClusterTestConfiguration; test != nil {
 		if !test.Upgrade {
 			return nil, nil
 		}
 		params = api.NewDeferredParameters(params)
-		step, err := clusterinstall.E2ETestStep(*c.OpenshiftInstallerClusterTestConfiguration, *c, params, podClient, templateClient, jobSpec, config.Resources)
+		step, err := clusterinstall.E2ETestStep(*c.OpenshiftInstallerClusterTestConfiguration, *c, params, podClient, templateClient, jobSpec, config.Resources, config.ArtifactGathering)
 		if err != nil {
 			return nil, fmt.Errorf("unable to create end to end test step: %w", err)
 		}
@@ -322,17 +457,81 @@ func stepForTest(
 		addProvidesForStep(step, params)
 		return []api.Step{step}, nil
 	}
+	if config.CloneIntoPods && c.ContainerTestConfiguration.From == api.PipelineImageStreamTagReferenceSource {
+		return []api.Step{steps.ClonedTestStep(*c, config.Resources, podClient, jobSpec, cloneAuthConfig, clonerefs)}, nil
+	}
 	return []api.Step{steps.TestStep(*c, config.Resources, podClient, jobSpec)}, nil
 }
 
+// withGatherSteps adds the built-in steps collecting data from the cluster
+// to the start of the post phase of tests using a cluster profile. The test
+// is copied so that the configuration is left as it was.
+func withGatherSteps(c *api.TestStepConfiguration) *api.TestStepConfiguration {
+	test := c.MultiStageTestConfigurationLiteral
+	if test.ClusterProfile == "" {
+		return c
+	}
+	gatherSteps := gather.Steps(test.Gather, test.Post)
+	if len(gatherSteps) == 0 {
+		return c
+	}
+	literal := *test
+	literal.Post = append(gatherSteps, test.Post...)
+	copied := *c
+	copied.MultiStageTestConfigurationLiteral = &literal
+	return &copied
+}
+
+// comparisonVariant creates one side of a comparison run: a copy of the test
+// with its own name, so that it does not share resources with the other side,
+// and with the overrides of the variant applied.
+func comparisonVariant(c api.TestStepConfiguration, suffix string, variant api.ComparisonVariant) api.TestStepConfiguration {
+	literal := *c.MultiStageTestConfigurationLiteral
+	literal.Comparison = nil
+	literal.Environment = api.TestEnvironment{}
+	for name, value := range c.MultiStageTestConfigurationLiteral.Environment {
+		literal.Environment[name] = value
+	}
+	for name, value := range variant.Environment {
+		literal.Environment[name] = value
+	}
+	overrideDependencies := func(in []api.LiteralTestStep) []api.LiteralTestStep {
+		var out []api.LiteralTestStep
+		for _, step := range in {
+			var dependencies []api.StepDependency
+			for _, dependency := range step.Dependencies {
+				if name, ok := variant.Dependencies[dependency.Env]; ok {
+					dependency.Name = name
+				}
+				dependencies = append(dependencies, dependency)
+			}
+			step.Dependencies = dependencies
+			out = append(out, step)
+		}
+		return out
+	}
+	literal.Pre = overrideDependencies(literal.Pre)
+	literal.Test = overrideDependencies(literal.Test)
+	literal.Post = overrideDependencies(literal.Post)
+	c.As = fmt.Sprintf("%s-%s", c.As, suffix)
+	c.MultiStageTestConfigurationLiteral = &literal
+	return c
+}
+
 // stepsForStepImages creates steps that import images referenced in test steps.
 func stepsForStepImages(
 	client loggingclient.LoggingClient,
+	imports *steps.ImportManager,
 	jobSpec *api.JobSpec,
 	inputImages inputImageSet,
+	externalImages sets.String,
 	test *api.MultiStageTestConfigurationLiteral,
 ) (ret []api.Step) {
-	for _, subStep := range append(append(test.Pre, test.Test...), test.Post...) {
+	subSteps := append(append(append([]api.LiteralTestStep{}, test.Pre...), test.Test...), test.Post...)
+	for _, observer := range test.Observers {
+		subSteps = append(subSteps, observer.LiteralTestStep())
+	}
+	for _, subStep := range subSteps {
 		if link, ok := subStep.FromImageTag(); ok {
 			config := api.InputImageTagStepConfiguration{
 				BaseImage: *subStep.FromImage,
@@ -342,7 +541,14 @@ func stepsForStepImages(
 				continue
 			}
 			inputImages[config] = struct{}{}
-			ret = append(ret, steps.InputImageTagStep(config, client, jobSpec))
+			ret = append(ret, steps.InputImageTagStep(config, client, imports, jobSpec, nil, nil))
+		}
+		for _, dependency := range subStep.ExternalDependencies {
+			// dependencies on the same digest share the pipeline tag
+			if tag := string(dependency.PipelineTag()); !externalImages.Has(tag) {
+				externalImages.Insert(tag)
+				ret = append(ret, steps.ExternalImageStep(dependency, client, imports, jobSpec))
+			}
 		}
 	}
 	return
@@ -386,9 +592,10 @@ func promotionDefaults(configSpec *api.ReleaseBuildConfiguration) (*api.Promotio
 
 // leasesForTest aggregates all the lease configurations in a test.
 // It is assumed that they have been validated and contain only valid and
-// unique values.
-func leasesForTest(s *api.MultiStageTestConfigurationLiteral) (ret []api.StepLease) {
-	if p := s.ClusterProfile; p != "" {
+// unique values. No lease is acquired for the cluster profile when the test
+// targets a user-supplied cluster.
+func leasesForTest(s *api.MultiStageTestConfigurationLiteral, byoCluster bool) (ret []api.StepLease) {
+	if p := s.ClusterProfile; p != "" && !byoCluster {
 		ret = append(ret, api.StepLease{
 			ResourceType: p.LeaseType(),
 			Env:          steps.DefaultLeaseEnv,
@@ -439,16 +646,13 @@ func stepConfigsForBuild(config *api.ReleaseBuildConfiguration, jobSpec *api.Job
 		}
 	}
 
-	if jobSpec.Refs != nil || len(jobSpec.ExtraRefs) > 0 {
+	// tests clone the source themselves when no "src" image is built
+	if (jobSpec.Refs != nil || len(jobSpec.ExtraRefs) > 0) && !config.CloneIntoPods {
 		step := api.StepConfiguration{SourceStepConfiguration: &api.SourceStepConfiguration{
-			From: api.PipelineImageStreamTagReferenceRoot,
-			To:   api.PipelineImageStreamTagReferenceSource,
-			ClonerefsImage: api.ImageStreamTagReference{
-				Namespace: "ci",
-				Name:      "managed-clonerefs",
-				Tag:       "latest",
-			},
-			ClonerefsPath: "/clonerefs",
+			From:           api.PipelineImageStreamTagReferenceRoot,
+			To:             api.PipelineImageStreamTagReferenceSource,
+			ClonerefsImage: steps.DefaultClonerefs.Image,
+			ClonerefsPath:  steps.DefaultClonerefs.Path,
 		}}
 		buildSteps = append(buildSteps, step)
 	}
@@ -496,10 +700,14 @@ func stepConfigsForBuild(config *api.ReleaseBuildConfiguration, jobSpec *api.Job
 	}
 
 	for alias, baseImage := range config.BaseImages {
-		buildSteps = append(buildSteps, api.StepConfiguration{InputImageTagStepConfiguration: &api.InputImageTagStepConfiguration{
+		step := &api.InputImageTagStepConfiguration{
 			BaseImage: defaultImageFromReleaseTag(baseImage, config.ReleaseTagConfiguration),
 			To:        api.PipelineImageStreamTagReference(alias),
-		}})
+		}
+		if signature, ok := config.SignedBaseImages[alias]; ok {
+			step.Signature = &signature
+		}
+		buildSteps = append(buildSteps, api.StepConfiguration{InputImageTagStepConfiguration: step})
 	}
 
 	for alias, target := range config.InputConfiguration.BaseRPMImages {
@@ -531,9 +739,16 @@ func stepConfigsForBuild(config *api.ReleaseBuildConfiguration, jobSpec *api.Job
 
 	if config.Operator != nil {
 		// Build a bundle source image that substitutes all values in `substitutions` in all `manifests` directories
-		buildSteps = append(buildSteps, api.StepConfiguration{BundleSourceStepConfiguration: &api.BundleSourceStepConfiguration{
+		bundleSource := &api.BundleSourceStepConfiguration{
 			Substitutions: config.Operator.Substitutions,
-		}})
+			Bundles:       config.Operator.Bundles,
+		}
+		if config.Operator.PinRelatedImages {
+			for _, image := range config.Images {
+				bundleSource.PinnedImages = append(bundleSource.PinnedImages, image.To)
+			}
+		}
+		buildSteps = append(buildSteps, api.StepConfiguration{BundleSourceStepConfiguration: bundleSource})
 		// Build bundles
 		var bundles []string
 		for index, bundle := range config.Operator.Bundles {
@@ -549,10 +764,15 @@ func stepConfigsForBuild(config *api.ReleaseBuildConfiguration, jobSpec *api.Job
 			buildSteps = append(buildSteps, api.StepConfiguration{ProjectDirectoryImageBuildStepConfiguration: image})
 		}
 		// Build index generator
-		buildSteps = append(buildSteps, api.StepConfiguration{IndexGeneratorStepConfiguration: &api.IndexGeneratorStepConfiguration{
+		indexGenerator := &api.IndexGeneratorStepConfiguration{
 			To:            api.PipelineImageStreamTagReferenceIndexImageGenerator,
 			OperatorIndex: bundles,
-		}})
+		}
+		if index := config.Operator.Index; index != nil {
+			indexGenerator.BaseIndex = index.BaseIndex
+			indexGenerator.UpdateGraph = index.UpdateGraph
+		}
+		buildSteps = append(buildSteps, api.StepConfiguration{IndexGeneratorStepConfiguration: indexGenerator})
 		// Build the index
 		image := &api.ProjectDirectoryImageBuildStepConfiguration{
 			To: api.PipelineImageStreamTagReferenceIndexImage,
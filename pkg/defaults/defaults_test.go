@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -28,7 +29,9 @@ import (
 	"github.com/openshift/ci-tools/pkg/release"
 	"github.com/openshift/ci-tools/pkg/steps"
 	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+	releasesteps "github.com/openshift/ci-tools/pkg/steps/release"
 	"github.com/openshift/ci-tools/pkg/steps/utils"
+	"github.com/openshift/ci-tools/pkg/testhelper"
 )
 
 func addCloneRefs(cfg *api.SourceStepConfiguration) *api.SourceStepConfiguration {
@@ -357,6 +360,61 @@ func TestStepConfigsForBuild(t *testing.T) {
 				},
 			}},
 		},
+		{
+			name: "signed base image requested",
+			input: &api.ReleaseBuildConfiguration{
+				InputConfiguration: api.InputConfiguration{
+					BuildRootImage: &api.BuildRootImageConfiguration{
+						ImageStreamTagReference: &api.ImageStreamTagReference{Tag: "manual"},
+					},
+					BaseImages: map[string]api.ImageStreamTagReference{
+						"name": {
+							Namespace: "namespace",
+							Name:      "name",
+							Tag:       "tag",
+						},
+					},
+					SignedBaseImages: map[string]api.ImageSigningConfiguration{
+						"name": {Keyless: true, OIDCIssuer: "https://oidc.example.com"},
+					},
+				},
+			},
+			jobSpec: &api.JobSpec{
+				JobSpec: downwardapi.JobSpec{
+					Refs: &prowapi.Refs{
+						Org:  "org",
+						Repo: "repo",
+					},
+				},
+				BaseNamespace: "base-1",
+			},
+			output: []api.StepConfiguration{{
+				SourceStepConfiguration: addCloneRefs(&api.SourceStepConfiguration{
+					From: api.PipelineImageStreamTagReferenceRoot,
+					To:   api.PipelineImageStreamTagReferenceSource,
+				}),
+			}, {
+				InputImageTagStepConfiguration: &api.InputImageTagStepConfiguration{
+					BaseImage: api.ImageStreamTagReference{
+						Namespace: "base-1",
+						Name:      "repo-test-base",
+						Tag:       "manual",
+					},
+					To: api.PipelineImageStreamTagReferenceRoot,
+				},
+			}, {
+				InputImageTagStepConfiguration: &api.InputImageTagStepConfiguration{
+					BaseImage: api.ImageStreamTagReference{
+						Namespace: "namespace",
+						Name:      "name",
+						Tag:       "tag",
+						As:        "name",
+					},
+					To:        api.PipelineImageStreamTagReference("name"),
+					Signature: &api.ImageSigningConfiguration{Keyless: true, OIDCIssuer: "https://oidc.example.com"},
+				},
+			}},
+		},
 		{
 			name: "implicit base image from release configuration",
 			input: &api.ReleaseBuildConfiguration{
@@ -510,6 +568,10 @@ func TestStepConfigsForBuild(t *testing.T) {
 						PullSpec: "quay.io/origin/oc",
 						With:     "pipeline:oc",
 					}},
+					Bundles: []api.Bundle{{
+						DockerfilePath: "bundle.Dockerfile",
+						ContextDir:     "manifests/olm",
+					}},
 				},
 			}, {
 				IndexGeneratorStepConfiguration: &api.IndexGeneratorStepConfiguration{
@@ -651,18 +713,19 @@ func TestFromConfig(t *testing.T) {
 	var cloneAuthConfig *steps.CloneAuthConfig
 	var pullSecret, pushSecret *coreapi.Secret
 	for _, tc := range []struct {
-		name           string
-		config         api.ReleaseBuildConfiguration
-		refs           *prowapi.Refs
-		paramFiles     string
-		promote        bool
-		templates      []*templateapi.Template
-		env            api.Parameters
-		params         map[string]string
-		expectedSteps  []string
-		expectedPost   []string
-		expectedParams map[string]string
-		expectedErr    error
+		name             string
+		config           api.ReleaseBuildConfiguration
+		refs             *prowapi.Refs
+		paramFiles       string
+		promote          bool
+		templates        []*templateapi.Template
+		env              api.Parameters
+		params           map[string]string
+		payloadOverrides releasesteps.PayloadOverrides
+		expectedSteps    []string
+		expectedPost     []string
+		expectedParams   map[string]string
+		expectedErr      error
 	}{{
 		name:          "no steps",
 		expectedSteps: []string{"[output-images]", "[images]"},
@@ -788,6 +851,7 @@ func TestFromConfig(t *testing.T) {
 			},
 		},
 		expectedSteps: []string{
+			"[tag-specification-snapshot]",
 			"[release:initial]",
 			"[release:latest]",
 			"[release-inputs]",
@@ -814,6 +878,7 @@ func TestFromConfig(t *testing.T) {
 			},
 		},
 		expectedSteps: []string{
+			"[tag-specification-snapshot]",
 			"[release:initial]",
 			"[release:latest]",
 			"[release-inputs]",
@@ -855,6 +920,24 @@ func TestFromConfig(t *testing.T) {
 		expectedParams: map[string]string{
 			utils.ReleaseImageEnv("release"): "public_docker_image_repository:release",
 		},
+	}, {
+		name: "resolve release with payload overrides",
+		config: api.ReleaseBuildConfiguration{
+			InputConfiguration: api.InputConfiguration{
+				Releases: map[string]api.UnresolvedRelease{
+					"release": {Release: &api.Release{Version: "version"}},
+				},
+			},
+		},
+		payloadOverrides: releasesteps.PayloadOverrides{"release": {"installer": "quay.io/org/installer:fix"}},
+		expectedSteps:    []string{"[release:release]", "[images]"},
+		expectedParams: map[string]string{
+			utils.ReleaseImageEnv("release"): "public_docker_image_repository:release",
+		},
+	}, {
+		name:             "payload overrides for a release the job does not use",
+		payloadOverrides: releasesteps.PayloadOverrides{"latest": {"installer": "quay.io/org/installer:fix"}},
+		expectedErr:      errors.New("payload components of release latest are overridden, but the job does not use that release"),
 	}, {
 		name: "container test",
 		config: api.ReleaseBuildConfiguration{
@@ -864,6 +947,17 @@ func TestFromConfig(t *testing.T) {
 			}},
 		},
 		expectedSteps: []string{"test", "[output-images]", "[images]"},
+	}, {
+		name: "container test cloning into its pod does not build src",
+		config: api.ReleaseBuildConfiguration{
+			CloneIntoPods: true,
+			Tests: []api.TestStepConfiguration{{
+				As:                         "test",
+				ContainerTestConfiguration: &api.ContainerTestConfiguration{From: api.PipelineImageStreamTagReferenceSource},
+			}},
+		},
+		refs:          &prowapi.Refs{Org: "org", Repo: "repo"},
+		expectedSteps: []string{"test", "[output-images]", "[images]"},
 	}, {
 		name: "openshift-installer test",
 		config: api.ReleaseBuildConfiguration{
@@ -903,7 +997,7 @@ func TestFromConfig(t *testing.T) {
 				},
 			}},
 		},
-		expectedSteps: []string{"test", "[output-images]", "[images]"},
+		expectedSteps: []string{"test", "[input:openshift-tools-latest]", "[output-images]", "[images]"},
 	}, {
 		name: "template test",
 		templates: []*templateapi.Template{
@@ -986,8 +1080,8 @@ func TestFromConfig(t *testing.T) {
 			for k, v := range tc.params {
 				params.Add(k, func() (string, error) { return v, nil })
 			}
-			steps, post, err := fromConfig(&tc.config, &jobSpec, tc.templates, tc.paramFiles, tc.promote, client, buildClient, templateClient, podClient, leaseClient, httpClient, requiredTargets, cloneAuthConfig, pullSecret, pushSecret, params)
-			if diff := cmp.Diff(tc.expectedErr, err); diff != "" {
+			steps, post, err := fromConfig(&tc.config, &jobSpec, tc.templates, tc.paramFiles, tc.promote, client, buildClient, templateClient, podClient, leaseClient, httpClient, requiredTargets, cloneAuthConfig, pullSecret, pushSecret, nil, nil, nil, nil, tc.payloadOverrides, nil, nil, nil, nil, params)
+			if diff := cmp.Diff(tc.expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
 				t.Errorf("unexpected error: %v", diff)
 			}
 			var stepNames, postNames []string
@@ -1027,9 +1121,10 @@ func TestFromConfig(t *testing.T) {
 
 func TestLeasesForTest(t *testing.T) {
 	for _, tc := range []struct {
-		name     string
-		tests    api.MultiStageTestConfigurationLiteral
-		expected []api.StepLease
+		name       string
+		tests      api.MultiStageTestConfigurationLiteral
+		byoCluster bool
+		expected   []api.StepLease
 	}{{
 		name:  "no configuration or cluster profile, no lease",
 		tests: api.MultiStageTestConfigurationLiteral{},
@@ -1057,12 +1152,56 @@ func TestLeasesForTest(t *testing.T) {
 			},
 		},
 		expected: []api.StepLease{{ResourceType: "aws-quota-slice"}},
+	}, {
+		name: "cluster profile with a user-supplied cluster, no lease",
+		tests: api.MultiStageTestConfigurationLiteral{
+			ClusterProfile: api.ClusterProfileAWS,
+		},
+		byoCluster: true,
 	}} {
 		t.Run(tc.name, func(t *testing.T) {
-			ret := leasesForTest(&tc.tests)
+			ret := leasesForTest(&tc.tests, tc.byoCluster)
 			if diff := diff.ObjectReflectDiff(tc.expected, ret); diff != "<no diffs>" {
 				t.Errorf("incorrect leases: %s", diff)
 			}
 		})
 	}
 }
+
+func TestComparisonVariant(t *testing.T) {
+	test := api.TestStepConfiguration{
+		As: "perf",
+		MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+			Test: []api.LiteralTestStep{{
+				As:           "benchmark",
+				Dependencies: []api.StepDependency{{Name: "release:latest", Env: "RELEASE_IMAGE"}, {Name: "src", Env: "SRC"}},
+			}},
+			Environment: api.TestEnvironment{"WORKLOAD": "small", "ITERATIONS": "10"},
+			Comparison: &api.ComparisonConfiguration{
+				Candidate: api.ComparisonVariant{
+					Environment:  api.TestEnvironment{"WORKLOAD": "large"},
+					Dependencies: api.TestDependencies{"RELEASE_IMAGE": "release:initial"},
+				},
+			},
+		},
+	}
+	expected := api.TestStepConfiguration{
+		As: "perf-candidate",
+		MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+			Test: []api.LiteralTestStep{{
+				As:           "benchmark",
+				Dependencies: []api.StepDependency{{Name: "release:initial", Env: "RELEASE_IMAGE"}, {Name: "src", Env: "SRC"}},
+			}},
+			Environment: api.TestEnvironment{"WORKLOAD": "large", "ITERATIONS": "10"},
+		},
+	}
+	if diff := cmp.Diff(expected, comparisonVariant(test, steps.ComparisonCandidate, test.MultiStageTestConfigurationLiteral.Comparison.Candidate)); diff != "" {
+		t.Errorf("incorrect variant: %s", diff)
+	}
+	if diff := cmp.Diff("small", test.MultiStageTestConfigurationLiteral.Environment["WORKLOAD"]); diff != "" {
+		t.Errorf("original test was mutated: %s", diff)
+	}
+	if diff := cmp.Diff("release:latest", test.MultiStageTestConfigurationLiteral.Test[0].Dependencies[0].Name); diff != "" {
+		t.Errorf("original test was mutated: %s", diff)
+	}
+}
@@ -0,0 +1,184 @@
+// Package deprecation tracks configuration features that are going away, so
+// that users of ci-operator are warned before the features stop working.
+package deprecation
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// dateFormat is used for sunset dates in warnings
+const dateFormat = "2006-01-02"
+
+// Deprecation describes a feature of the configuration that should no
+// longer be used
+type Deprecation struct {
+	// Field identifies the deprecated feature, as it appears in configuration
+	Field string
+	// Replacement tells users what to use instead
+	Replacement string
+	// usages returns the paths at which a configuration uses the feature
+	usages func(config *api.ReleaseBuildConfiguration) []string
+}
+
+// Warning is emitted for every use of a deprecated feature in a configuration
+type Warning struct {
+	// Field identifies the deprecated feature
+	Field string `json:"field"`
+	// Path is where the configuration uses the feature
+	Path string `json:"path"`
+	// Sunset is the date after which the feature is no longer supported,
+	// unset when no date was announced
+	Sunset string `json:"sunset,omitempty"`
+	// Replacement tells users what to use instead
+	Replacement string `json:"replacement"`
+	// Expired is set when the sunset date has already passed
+	Expired bool `json:"expired,omitempty"`
+}
+
+func (w Warning) String() string {
+	if w.Sunset == "" {
+		return fmt.Sprintf("%s is deprecated: %s", w.Path, w.Replacement)
+	}
+	when := "will stop being supported on"
+	if w.Expired {
+		when = "is no longer supported since"
+	}
+	return fmt.Sprintf("%s is deprecated and %s %s: %s", w.Path, when, w.Sunset, w.Replacement)
+}
+
+// Sunsets maps the fields of deprecations to the dates announced for their
+// removal. Deprecations without an announced date never expire.
+type Sunsets map[string]time.Time
+
+// LoadSunsets reads sunset dates from a file mapping fields of deprecations
+// to dates formatted like 2006-01-02
+func LoadSunsets(path string) (Sunsets, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read sunset dates: %w", err)
+	}
+	var dates map[string]string
+	if err := yaml.Unmarshal(raw, &dates); err != nil {
+		return nil, fmt.Errorf("could not parse sunset dates: %w", err)
+	}
+	known := map[string]bool{}
+	for _, deprecation := range Deprecations {
+		known[deprecation.Field] = true
+	}
+	sunsets := Sunsets{}
+	for field, value := range dates {
+		if !known[field] {
+			return nil, fmt.Errorf("sunset date for unknown deprecation %s", field)
+		}
+		sunset, err := time.Parse(dateFormat, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sunset date for %s: %w", field, err)
+		}
+		sunsets[field] = sunset
+	}
+	return sunsets, nil
+}
+
+// testsOfType returns the paths of tests for which the type accessor is set
+func testsOfType(field string, set func(test api.TestStepConfiguration) bool) func(config *api.ReleaseBuildConfiguration) []string {
+	return func(config *api.ReleaseBuildConfiguration) []string {
+		var paths []string
+		for i, test := range config.Tests {
+			if set(test) {
+				paths = append(paths, fmt.Sprintf("tests[%d].%s", i, field))
+			}
+		}
+		return paths
+	}
+}
+
+// Deprecations are all the features that are deprecated. Their sunset dates
+// are announced separately, see LoadSunsets.
+var Deprecations = []Deprecation{
+	{
+		Field:       "tag_specification",
+		Replacement: "use `releases` to import the release payloads the tests need",
+		usages: func(config *api.ReleaseBuildConfiguration) []string {
+			if config.ReleaseTagConfiguration != nil {
+				return []string{"tag_specification"}
+			}
+			return nil
+		},
+	},
+	{
+		Field:       "tests[].openshift_installer",
+		Replacement: "use a multi-stage test with an `ipi-*` workflow",
+		usages: testsOfType("openshift_installer", func(test api.TestStepConfiguration) bool {
+			return test.OpenshiftInstallerClusterTestConfiguration != nil
+		}),
+	},
+	{
+		Field:       "tests[].openshift_installer_upi",
+		Replacement: "use a multi-stage test with an `upi-*` workflow",
+		usages: testsOfType("openshift_installer_upi", func(test api.TestStepConfiguration) bool {
+			return test.OpenshiftInstallerUPIClusterTestConfiguration != nil
+		}),
+	},
+	{
+		Field:       "tests[].openshift_installer_custom_test_image",
+		Replacement: "use a multi-stage test with an `ipi-*` workflow and a step running the test image",
+		usages: testsOfType("openshift_installer_custom_test_image", func(test api.TestStepConfiguration) bool {
+			return test.OpenshiftInstallerCustomTestImageClusterTestConfiguration != nil
+		}),
+	},
+}
+
+// Check returns a warning for every use of a deprecated feature in the
+// configuration, in the order of Deprecations, followed by those for the
+// deprecated registry components the tests reference
+func Check(config *api.ReleaseBuildConfiguration, sunsets Sunsets, now time.Time) []Warning {
+	var warnings []Warning
+	for _, deprecation := range Deprecations {
+		sunset, announced := sunsets[deprecation.Field]
+		for _, path := range deprecation.usages(config) {
+			warning := Warning{
+				Field:       deprecation.Field,
+				Path:        path,
+				Replacement: deprecation.Replacement,
+			}
+			if announced {
+				warning.Sunset = sunset.Format(dateFormat)
+				warning.Expired = !now.Before(sunset)
+			}
+			warnings = append(warnings, warning)
+		}
+	}
+	// deprecated registry components are recorded in the tests when they
+	// are resolved and have no announced sunset dates
+	for i, test := range config.Tests {
+		literal := test.MultiStageTestConfigurationLiteral
+		if literal == nil {
+			continue
+		}
+		var components []string
+		for component := range literal.Deprecations {
+			components = append(components, component)
+		}
+		sort.Strings(components)
+		for _, component := range components {
+			notice := literal.Deprecations[component]
+			replacement := notice.Message
+			if notice.Replacement != "" {
+				replacement = fmt.Sprintf("%s, use %s instead", replacement, notice.Replacement)
+			}
+			warnings = append(warnings, Warning{
+				Field:       component,
+				Path:        fmt.Sprintf("%s in tests[%d]", component, i),
+				Replacement: replacement,
+			})
+		}
+	}
+	return warnings
+}
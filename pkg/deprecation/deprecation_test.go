@@ -0,0 +1,156 @@
+package deprecation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestCheck(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		config   api.ReleaseBuildConfiguration
+		sunsets  Sunsets
+		now      time.Time
+		expected []Warning
+	}{
+		{
+			name: "no deprecated features",
+			config: api.ReleaseBuildConfiguration{
+				Tests: []api.TestStepConfiguration{{As: "unit", ContainerTestConfiguration: &api.ContainerTestConfiguration{From: "src"}}},
+			},
+			now: date(2026, time.October, 1),
+		},
+		{
+			name: "deprecated features before their sunset",
+			config: api.ReleaseBuildConfiguration{
+				InputConfiguration: api.InputConfiguration{ReleaseTagConfiguration: &api.ReleaseTagConfiguration{Namespace: "ocp", Name: "4.9"}},
+				Tests: []api.TestStepConfiguration{
+					{As: "unit", ContainerTestConfiguration: &api.ContainerTestConfiguration{From: "src"}},
+					{As: "e2e", OpenshiftInstallerClusterTestConfiguration: &api.OpenshiftInstallerClusterTestConfiguration{}},
+				},
+			},
+			sunsets: Sunsets{"tag_specification": date(2027, time.April, 1), "tests[].openshift_installer": date(2027, time.January, 1)},
+			now:     date(2026, time.October, 1),
+			expected: []Warning{
+				{Field: "tag_specification", Path: "tag_specification", Sunset: "2027-04-01", Replacement: "use `releases` to import the release payloads the tests need"},
+				{Field: "tests[].openshift_installer", Path: "tests[1].openshift_installer", Sunset: "2027-01-01", Replacement: "use a multi-stage test with an `ipi-*` workflow"},
+			},
+		},
+		{
+			name: "deprecated feature without announced sunset",
+			config: api.ReleaseBuildConfiguration{
+				Tests: []api.TestStepConfiguration{{As: "e2e", OpenshiftInstallerClusterTestConfiguration: &api.OpenshiftInstallerClusterTestConfiguration{}}},
+			},
+			now: date(2030, time.January, 1),
+			expected: []Warning{
+				{Field: "tests[].openshift_installer", Path: "tests[0].openshift_installer", Replacement: "use a multi-stage test with an `ipi-*` workflow"},
+			},
+		},
+		{
+			name: "deprecated feature after its sunset",
+			config: api.ReleaseBuildConfiguration{
+				Tests: []api.TestStepConfiguration{{As: "e2e", OpenshiftInstallerUPIClusterTestConfiguration: &api.OpenshiftInstallerUPIClusterTestConfiguration{}}},
+			},
+			sunsets: Sunsets{"tests[].openshift_installer_upi": date(2027, time.January, 1)},
+			now:     date(2027, time.January, 1),
+			expected: []Warning{
+				{Field: "tests[].openshift_installer_upi", Path: "tests[0].openshift_installer_upi", Sunset: "2027-01-01", Replacement: "use a multi-stage test with an `upi-*` workflow", Expired: true},
+			},
+		},
+		{
+			name: "deprecated registry components referenced by a test",
+			config: api.ReleaseBuildConfiguration{
+				Tests: []api.TestStepConfiguration{
+					{As: "unit", ContainerTestConfiguration: &api.ContainerTestConfiguration{From: "src"}},
+					{As: "e2e", MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+						Deprecations: map[string]api.Deprecation{
+							"workflow/ipi": {Message: "not maintained", Replacement: "ipi-aws"},
+							"chain/gather": {Message: "gathering is built in"},
+						},
+					}},
+				},
+			},
+			now: date(2026, time.October, 1),
+			expected: []Warning{
+				{Field: "chain/gather", Path: "chain/gather in tests[1]", Replacement: "gathering is built in"},
+				{Field: "workflow/ipi", Path: "workflow/ipi in tests[1]", Replacement: "not maintained, use ipi-aws instead"},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, Check(&testCase.config, testCase.sunsets, testCase.now)); diff != "" {
+				t.Errorf("unexpected warnings: %s", diff)
+			}
+		})
+	}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestLoadSunsets(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		content     string
+		expected    Sunsets
+		expectedErr string
+	}{
+		{
+			name:     "dates are parsed",
+			content:  "tag_specification: \"2027-04-01\"\n",
+			expected: Sunsets{"tag_specification": date(2027, time.April, 1)},
+		},
+		{
+			name:        "unknown deprecation",
+			content:     "tests[].unknown: \"2027-04-01\"\n",
+			expectedErr: "sunset date for unknown deprecation tests[].unknown",
+		},
+		{
+			name:        "invalid date",
+			content:     "tag_specification: April\n",
+			expectedErr: `invalid sunset date for tag_specification: parsing time "April" as "2006-01-02": cannot parse "April" as "2006"`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sunsets.yaml")
+			if err := os.WriteFile(path, []byte(testCase.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			sunsets, err := LoadSunsets(path)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, sunsets); diff != "" {
+				t.Errorf("unexpected sunsets: %s", diff)
+			}
+		})
+	}
+}
+
+func TestWarningString(t *testing.T) {
+	warning := Warning{Path: "tests[0].openshift_installer", Sunset: "2027-01-01", Replacement: "use a multi-stage test"}
+	if expected, actual := "tests[0].openshift_installer is deprecated and will stop being supported on 2027-01-01: use a multi-stage test", warning.String(); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+	warning.Expired = true
+	if expected, actual := "tests[0].openshift_installer is deprecated and is no longer supported since 2027-01-01: use a multi-stage test", warning.String(); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+	warning.Sunset, warning.Expired = "", false
+	if expected, actual := "tests[0].openshift_installer is deprecated: use a multi-stage test", warning.String(); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
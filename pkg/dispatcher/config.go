@@ -38,6 +38,8 @@ type Config struct {
 	Groups JobGroups `json:"groups"`
 	// BuildFarm maps groups of jobs to a cloud provider, like GCP
 	BuildFarm map[CloudProvider]JobGroups `json:"buildFarm,omitempty"`
+	// FairShare, when set, labels jobs with the weight of their tenant for fair-share admission on build farms
+	FairShare *FairShare `json:"fairShare,omitempty"`
 }
 
 // ClusterName is the name of a cluster
@@ -0,0 +1,172 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// TenantOrgLabel identifies the tenant of a job by the org it tests
+	TenantOrgLabel = "ci.openshift.io/tenant-org"
+	// TenantRepoLabel is the repository a job tests
+	TenantRepoLabel = "ci.openshift.io/tenant-repo"
+	// TenantWeightLabel is the share of build farm capacity the tenant of a
+	// job is entitled to, relative to the weights of other tenants
+	TenantWeightLabel = "ci.openshift.io/tenant-weight"
+)
+
+// FairShare configures how build farms share their capacity between tenants
+type FairShare struct {
+	// DefaultWeight applies to tenants without a configured weight, 1 if unset
+	DefaultWeight int `json:"defaultWeight,omitempty"`
+	// Weights maps an org or an org/repo to its weight, the most specific wins
+	Weights map[string]int `json:"weights,omitempty"`
+}
+
+// WeightFor returns the weight of jobs testing the repository
+func (f *FairShare) WeightFor(org, repo string) int {
+	if weight, ok := f.Weights[fmt.Sprintf("%s/%s", org, repo)]; ok {
+		return weight
+	}
+	if weight, ok := f.Weights[org]; ok {
+		return weight
+	}
+	if f.DefaultWeight > 0 {
+		return f.DefaultWeight
+	}
+	return 1
+}
+
+// SchedulingLabels returns the labels build farms use to apply fair-share
+// admission to the jobs in the file at the path, which follows the
+// ci-operator/jobs/ORG/REPO/ layout. No labels are returned if fair-share
+// is not configured or the path does not identify a repository.
+func (config *Config) SchedulingLabels(path string) map[string]string {
+	if config.FairShare == nil {
+		return nil
+	}
+	dir := filepath.Dir(path)
+	org, repo := filepath.Base(filepath.Dir(dir)), filepath.Base(dir)
+	for _, value := range []string{org, repo} {
+		if value == "." || value == string(filepath.Separator) || len(validation.IsValidLabelValue(value)) != 0 {
+			return nil
+		}
+	}
+	return map[string]string{
+		TenantOrgLabel:    org,
+		TenantRepoLabel:   repo,
+		TenantWeightLabel: strconv.Itoa(config.FairShare.WeightFor(org, repo)),
+	}
+}
+
+// FairShareAdmission is a mutating admission webhook for build farms. It
+// lowers the priority of pods of tenants that already run more than their
+// weighted share of the job pods, so the scheduler starts pods of other
+// tenants first when the build farm is saturated. Pods are never denied as
+// Prow does not retry jobs whose pods could not be created.
+type FairShareAdmission struct {
+	client    ctrlruntimeclient.Reader
+	namespace string
+	// priorityClass and its priority are set on pods of tenants above
+	// their share; the class should not preempt other pods
+	priorityClass string
+	priority      int32
+	decoder       *admission.Decoder
+}
+
+var _ admission.Handler = &FairShareAdmission{}
+
+// NewFairShareAdmission creates a webhook handler for pods of the jobs in the namespace
+func NewFairShareAdmission(client ctrlruntimeclient.Reader, namespace, priorityClass string, priority int32) (*FairShareAdmission, error) {
+	decoder, err := admission.NewDecoder(scheme.Scheme)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create decoder: %w", err)
+	}
+	return &FairShareAdmission{
+		client:        client,
+		namespace:     namespace,
+		priorityClass: priorityClass,
+		priority:      priority,
+		decoder:       decoder,
+	}, nil
+}
+
+func (a *FairShareAdmission) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := a.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	tenant := pod.Labels[TenantOrgLabel]
+	if tenant == "" || req.Namespace != a.namespace {
+		return admission.Allowed("pod does not belong to a tenant")
+	}
+	if pod.Spec.PriorityClassName != "" {
+		return admission.Allowed("pod already has a priority class")
+	}
+
+	pods := &corev1.PodList{}
+	if err := a.client.List(ctx, pods, ctrlruntimeclient.InNamespace(a.namespace), ctrlruntimeclient.HasLabels{TenantOrgLabel}); err != nil {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("failed to list pods: %w", err))
+	}
+	usage, weights := map[string]int{}, map[string]int{}
+	for _, p := range append(pods.Items, *pod) {
+		if p.Status.Phase == corev1.PodSucceeded || p.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		org := p.Labels[TenantOrgLabel]
+		if p.Name != pod.Name || p.Namespace != pod.Namespace {
+			usage[org]++
+		}
+		if weight := weightFromLabels(p.Labels); weight > weights[org] {
+			weights[org] = weight
+		}
+	}
+	if !fairShareExceeded(tenant, usage, weights) {
+		return admission.Allowed("tenant is within its fair share")
+	}
+
+	pod.Spec.PriorityClassName = a.priorityClass
+	pod.Spec.Priority = &a.priority
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("failed to marshal pod: %w", err))
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, raw)
+}
+
+func weightFromLabels(labels map[string]string) int {
+	weight, err := strconv.Atoi(labels[TenantWeightLabel])
+	if err != nil || weight < 1 {
+		return 1
+	}
+	return weight
+}
+
+// fairShareExceeded determines whether starting one more pod for the tenant
+// puts it above its share of all pods, divided between the tenants that run
+// pods according to their weights
+func fairShareExceeded(tenant string, usage, weights map[string]int) bool {
+	total, totalWeight := 1, 0
+	for org, count := range usage {
+		total += count
+		if org != tenant && count > 0 {
+			totalWeight += weights[org]
+		}
+	}
+	totalWeight += weights[tenant]
+	if totalWeight == 0 {
+		return false
+	}
+	share := float64(total) * float64(weights[tenant]) / float64(totalWeight)
+	return float64(usage[tenant]+1) > share
+}
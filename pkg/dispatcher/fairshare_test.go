@@ -0,0 +1,176 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestSchedulingLabels(t *testing.T) {
+	fairShare := &FairShare{DefaultWeight: 2, Weights: map[string]int{"openshift": 3, "openshift/origin": 5}}
+	testCases := []struct {
+		name      string
+		fairShare *FairShare
+		path      string
+		expected  map[string]string
+	}{
+		{
+			name: "no fair share configured",
+			path: "ci-operator/jobs/openshift/origin/openshift-origin-master-presubmits.yaml",
+		},
+		{
+			name:      "repository weight wins",
+			fairShare: fairShare,
+			path:      "ci-operator/jobs/openshift/origin/openshift-origin-master-presubmits.yaml",
+			expected:  map[string]string{TenantOrgLabel: "openshift", TenantRepoLabel: "origin", TenantWeightLabel: "5"},
+		},
+		{
+			name:      "org weight applies to its repositories",
+			fairShare: fairShare,
+			path:      "ci-operator/jobs/openshift/installer/openshift-installer-master-presubmits.yaml",
+			expected:  map[string]string{TenantOrgLabel: "openshift", TenantRepoLabel: "installer", TenantWeightLabel: "3"},
+		},
+		{
+			name:      "default weight applies to other tenants",
+			fairShare: fairShare,
+			path:      "ci-operator/jobs/kubevirt/kubevirt/kubevirt-kubevirt-main-presubmits.yaml",
+			expected:  map[string]string{TenantOrgLabel: "kubevirt", TenantRepoLabel: "kubevirt", TenantWeightLabel: "2"},
+		},
+		{
+			name:      "path without a repository",
+			fairShare: fairShare,
+			path:      "infra-periodics.yaml",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &Config{FairShare: tc.fairShare}
+			if diff := cmp.Diff(tc.expected, config.SchedulingLabels(tc.path)); diff != "" {
+				t.Errorf("unexpected labels: %s", diff)
+			}
+		})
+	}
+}
+
+func TestFairShareExceeded(t *testing.T) {
+	testCases := []struct {
+		name     string
+		usage    map[string]int
+		weights  map[string]int
+		expected bool
+	}{
+		{
+			name:    "only tenant",
+			usage:   map[string]int{"a": 10},
+			weights: map[string]int{"a": 1},
+		},
+		{
+			name:    "other tenants are idle",
+			usage:   map[string]int{"a": 10, "b": 0},
+			weights: map[string]int{"a": 1, "b": 1},
+		},
+		{
+			name:     "tenant above its share",
+			usage:    map[string]int{"a": 5, "b": 1},
+			weights:  map[string]int{"a": 1, "b": 1},
+			expected: true,
+		},
+		{
+			name:    "heavier tenant within its share",
+			usage:   map[string]int{"a": 5, "b": 1},
+			weights: map[string]int{"a": 6, "b": 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := fairShareExceeded("a", tc.usage, tc.weights); actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFairShareAdmission(t *testing.T) {
+	pod := func(name, org string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: name, Labels: map[string]string{TenantOrgLabel: org, TenantWeightLabel: "1"}},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	testCases := []struct {
+		name          string
+		existing      []runtime.Object
+		pod           *corev1.Pod
+		expectedPatch bool
+	}{
+		{
+			name:     "pod without a tenant",
+			existing: []runtime.Object{pod("a-1", "a", corev1.PodRunning), pod("b-1", "b", corev1.PodRunning)},
+			pod:      &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "new"}},
+		},
+		{
+			name:     "tenant within its share",
+			existing: []runtime.Object{pod("a-1", "a", corev1.PodRunning), pod("b-1", "b", corev1.PodRunning), pod("b-2", "b", corev1.PodRunning)},
+			pod:      pod("new", "a", ""),
+		},
+		{
+			name: "finished pods do not count",
+			existing: []runtime.Object{
+				pod("a-1", "a", corev1.PodSucceeded), pod("a-2", "a", corev1.PodFailed), pod("a-3", "a", corev1.PodSucceeded),
+				pod("b-1", "b", corev1.PodRunning),
+			},
+			pod: pod("new", "a", ""),
+		},
+		{
+			name:          "tenant above its share is deprioritized",
+			existing:      []runtime.Object{pod("a-1", "a", corev1.PodRunning), pod("a-2", "a", corev1.PodPending), pod("b-1", "b", corev1.PodRunning)},
+			pod:           pod("new", "a", ""),
+			expectedPatch: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, err := NewFairShareAdmission(fakectrlruntimeclient.NewFakeClient(tc.existing...), "ci", "ci-over-fair-share", -10)
+			if err != nil {
+				t.Fatalf("failed to create handler: %v", err)
+			}
+			raw, err := json.Marshal(tc.pod)
+			if err != nil {
+				t.Fatalf("failed to marshal pod: %v", err)
+			}
+			response := handler.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Namespace: "ci",
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			}})
+			if !response.Allowed {
+				t.Fatalf("expected the pod to be allowed, got %v", response.Result)
+			}
+			if patched := len(response.Patches) > 0; patched != tc.expectedPatch {
+				t.Fatalf("expected patch: %t, got patches: %v", tc.expectedPatch, response.Patches)
+			}
+			if !tc.expectedPatch {
+				return
+			}
+			var paths []string
+			for _, patch := range response.Patches {
+				paths = append(paths, fmt.Sprintf("%s %s=%v", patch.Operation, patch.Path, patch.Value))
+			}
+			sort.Strings(paths)
+			if diff := cmp.Diff([]string{"add /spec/priority=-10", "add /spec/priorityClassName=ci-over-fair-share"}, paths); diff != "" {
+				t.Errorf("unexpected patches: %s", diff)
+			}
+		})
+	}
+}
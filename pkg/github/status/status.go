@@ -0,0 +1,199 @@
+// Package status posts auxiliary GitHub commit statuses for the milestones
+// of a job, in addition to the status Prow posts for the job itself.
+package status
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// Client posts commit statuses to GitHub
+type Client interface {
+	CreateStatus(org, repo, ref string, s github.Status) error
+}
+
+// Updater posts statuses for a commit. Updates are queued and sent at most
+// once per interval, so only the latest update of every context is sent and
+// updates which do not change the posted status of a context are dropped.
+type Updater struct {
+	client         Client
+	org, repo, sha string
+	// targetURL is linked from statuses which do not set their own
+	targetURL string
+	interval  time.Duration
+
+	lock    sync.Mutex
+	pending map[string]github.Status
+	posted  map[string]github.Status
+}
+
+// NewUpdater creates an updater for statuses of the commit, which link the
+// target URL
+func NewUpdater(client Client, org, repo, sha, targetURL string, interval time.Duration) *Updater {
+	return &Updater{
+		client:    client,
+		org:       org,
+		repo:      repo,
+		sha:       sha,
+		targetURL: targetURL,
+		interval:  interval,
+		pending:   map[string]github.Status{},
+		posted:    map[string]github.Status{},
+	}
+}
+
+// Update queues the status to be posted
+func (u *Updater) Update(status github.Status) {
+	if status.TargetURL == "" {
+		status.TargetURL = u.targetURL
+	}
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if posted, ok := u.posted[status.Context]; ok && posted == status {
+		delete(u.pending, status.Context)
+		return
+	}
+	u.pending[status.Context] = status
+}
+
+// Run posts the queued statuses periodically until the context is cancelled,
+// after which the remaining statuses are posted
+func (u *Updater) Run(ctx context.Context) {
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := u.Flush(); err != nil {
+				log.Printf("warning: Failed to post commit statuses: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := u.Flush(); err != nil {
+				log.Printf("warning: Failed to post commit statuses, will retry: %v", err)
+			}
+		}
+	}
+}
+
+// Flush posts the queued statuses. Statuses which could not be posted stay
+// queued unless they are replaced by a later update.
+func (u *Updater) Flush() error {
+	u.lock.Lock()
+	pending := u.pending
+	u.pending = map[string]github.Status{}
+	u.lock.Unlock()
+
+	contexts := make([]string, 0, len(pending))
+	for context := range pending {
+		contexts = append(contexts, context)
+	}
+	sort.Strings(contexts)
+	var errs []error
+	for _, context := range contexts {
+		status := pending[context]
+		err := u.client.CreateStatus(u.org, u.repo, u.sha, status)
+		u.lock.Lock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to post status %s: %w", context, err))
+			if _, updated := u.pending[context]; !updated {
+				u.pending[context] = status
+			}
+		} else {
+			u.posted[context] = status
+		}
+		u.lock.Unlock()
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// Milestones tracks the steps of a job and reports a status for every
+// configured context: pending until its steps finish, success when all of
+// them succeeded and failure as soon as one of them failed.
+type Milestones struct {
+	updater  *Updater
+	contexts []api.StatusContext
+
+	lock      sync.Mutex
+	succeeded sets.String
+	finished  sets.String
+}
+
+// NewMilestones reports the contexts as pending. Contexts that depend on
+// steps which are not part of the job could never be reached, so they are
+// not reported at all.
+func NewMilestones(updater *Updater, contexts []api.StatusContext, steps sets.String) *Milestones {
+	m := &Milestones{updater: updater, succeeded: sets.NewString(), finished: sets.NewString()}
+	for _, context := range contexts {
+		if missing := sets.NewString(context.Steps...).Difference(steps); missing.Len() != 0 {
+			log.Printf("Not reporting status %s, steps %s do not run in this job", context.Context, strings.Join(missing.List(), ", "))
+			continue
+		}
+		m.contexts = append(m.contexts, context)
+		updater.Update(github.Status{
+			State:       github.StatusPending,
+			Description: fmt.Sprintf("Waiting for %s", strings.Join(context.Steps, ", ")),
+			Context:     context.Context,
+		})
+	}
+	return m
+}
+
+// StepFinished updates the contexts which depend on the step
+func (m *Milestones) StepFinished(step string, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if err == nil {
+		m.succeeded.Insert(step)
+	}
+	for _, context := range m.contexts {
+		if m.finished.Has(context.Context) || !sets.NewString(context.Steps...).Has(step) {
+			continue
+		}
+		status := github.Status{Context: context.Context}
+		switch {
+		case err != nil:
+			status.State = github.StatusFailure
+			status.Description = fmt.Sprintf("Step %s failed", step)
+		case m.succeeded.HasAll(context.Steps...):
+			status.State = github.StatusSuccess
+			status.Description = context.Description
+			if status.Description == "" {
+				status.Description = fmt.Sprintf("%s succeeded", strings.Join(context.Steps, ", "))
+			}
+		default:
+			continue
+		}
+		m.finished.Insert(context.Context)
+		m.updater.Update(status)
+	}
+}
+
+// Finish reports the contexts whose steps did not all run as errored, as
+// the job ended before their milestones could be reached
+func (m *Milestones) Finish() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, context := range m.contexts {
+		if m.finished.Has(context.Context) {
+			continue
+		}
+		m.finished.Insert(context.Context)
+		m.updater.Update(github.Status{
+			State:       github.StatusError,
+			Description: "The job ended before the milestone was reached",
+			Context:     context.Context,
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package status
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+type fakeClient struct {
+	posted []github.Status
+	err    error
+}
+
+func (c *fakeClient) CreateStatus(org, repo, ref string, s github.Status) error {
+	if org != "org" || repo != "repo" || ref != "sha" {
+		return errors.New("unexpected commit")
+	}
+	if c.err != nil {
+		return c.err
+	}
+	c.posted = append(c.posted, s)
+	return nil
+}
+
+func withTargetURL(status github.Status) github.Status {
+	status.TargetURL = "https://deck.example.com/job"
+	return status
+}
+
+func TestUpdater(t *testing.T) {
+	pending := github.Status{State: github.StatusPending, Context: "a"}
+	success := github.Status{State: github.StatusSuccess, Context: "a"}
+	other := github.Status{State: github.StatusPending, Context: "b"}
+	linked := github.Status{State: github.StatusPending, Context: "c", TargetURL: "https://other.example.com"}
+	var testCases = []struct {
+		name     string
+		updates  [][]github.Status
+		failing  bool
+		expected []github.Status
+	}{
+		{
+			name:     "only the latest update of a context is posted",
+			updates:  [][]github.Status{{pending, success, other}},
+			expected: []github.Status{withTargetURL(success), withTargetURL(other)},
+		},
+		{
+			name:     "statuses linking elsewhere keep their target",
+			updates:  [][]github.Status{{linked}},
+			expected: []github.Status{linked},
+		},
+		{
+			name:     "updates which do not change the status are dropped",
+			updates:  [][]github.Status{{pending}, {pending}, {success}, {success}},
+			expected: []github.Status{withTargetURL(pending), withTargetURL(success)},
+		},
+		{
+			name:     "updates reverting to the posted status are dropped",
+			updates:  [][]github.Status{{pending}, {success, pending}},
+			expected: []github.Status{withTargetURL(pending)},
+		},
+		{
+			name:     "statuses that failed to be posted are retried",
+			updates:  [][]github.Status{{pending}, {}},
+			failing:  true,
+			expected: []github.Status{withTargetURL(pending)},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := &fakeClient{}
+			if testCase.failing {
+				client.err = errors.New("injected failure")
+			}
+			updater := NewUpdater(client, "org", "repo", "sha", "https://deck.example.com/job", 0)
+			for i, updates := range testCase.updates {
+				for _, update := range updates {
+					updater.Update(update)
+				}
+				err := updater.Flush()
+				if testCase.failing && i == 0 {
+					if err == nil {
+						t.Fatal("expected an error, got none")
+					}
+					client.err = nil
+				} else if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if diff := cmp.Diff(testCase.expected, client.posted); diff != "" {
+				t.Errorf("unexpected statuses: %s", diff)
+			}
+		})
+	}
+}
+
+func TestMilestones(t *testing.T) {
+	contexts := []api.StatusContext{
+		{Context: "images-built", Description: "Images were built", Steps: []string{"[images]"}},
+		{Context: "tests-passed", Steps: []string{"unit", "e2e"}},
+		{Context: "not-in-job", Steps: []string{"other"}},
+	}
+	type finished struct {
+		step string
+		err  error
+	}
+	var testCases = []struct {
+		name     string
+		finished []finished
+		expected []github.Status
+	}{
+		{
+			name: "milestones are pending until their steps finish",
+			expected: []github.Status{
+				{State: github.StatusPending, Description: "Waiting for [images]", Context: "images-built"},
+				{State: github.StatusPending, Description: "Waiting for unit, e2e", Context: "tests-passed"},
+				{State: github.StatusError, Description: "The job ended before the milestone was reached", Context: "images-built"},
+				{State: github.StatusError, Description: "The job ended before the milestone was reached", Context: "tests-passed"},
+			},
+		},
+		{
+			name:     "milestones are reached when all their steps succeed",
+			finished: []finished{{step: "[images]"}, {step: "unit"}, {step: "e2e"}},
+			expected: []github.Status{
+				{State: github.StatusPending, Description: "Waiting for [images]", Context: "images-built"},
+				{State: github.StatusPending, Description: "Waiting for unit, e2e", Context: "tests-passed"},
+				{State: github.StatusSuccess, Description: "Images were built", Context: "images-built"},
+				{State: github.StatusSuccess, Description: "unit, e2e succeeded", Context: "tests-passed"},
+			},
+		},
+		{
+			name:     "milestones fail when one of their steps fails",
+			finished: []finished{{step: "[images]"}, {step: "unit", err: errors.New("failed")}, {step: "e2e"}},
+			expected: []github.Status{
+				{State: github.StatusPending, Description: "Waiting for [images]", Context: "images-built"},
+				{State: github.StatusPending, Description: "Waiting for unit, e2e", Context: "tests-passed"},
+				{State: github.StatusSuccess, Description: "Images were built", Context: "images-built"},
+				{State: github.StatusFailure, Description: "Step unit failed", Context: "tests-passed"},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := &fakeClient{}
+			updater := NewUpdater(client, "org", "repo", "sha", "", 0)
+			milestones := NewMilestones(updater, contexts, sets.NewString("[images]", "unit", "e2e"))
+			if err := updater.Flush(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, step := range testCase.finished {
+				milestones.StepFinished(step.step, step.err)
+			}
+			milestones.Finish()
+			if err := updater.Flush(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testCase.expected, client.posted); diff != "" {
+				t.Errorf("unexpected statuses: %s", diff)
+			}
+		})
+	}
+}
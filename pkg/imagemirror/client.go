@@ -0,0 +1,134 @@
+package imagemirror
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Client copies images between registries
+type Client interface {
+	// Mirror copies the source image to the destination, keeping the
+	// manifests intact so that the image has the same digest in both
+	// places. It returns that digest.
+	Mirror(ctx context.Context, source, destination string) (string, error)
+}
+
+// Credentials resolve the username and password used for a registry
+type Credentials func(registry string) (username, password string)
+
+// CredentialsFromDockerConfig resolves credentials from the contents of a
+// .dockerconfigjson file
+func CredentialsFromDockerConfig(raw []byte) (Credentials, error) {
+	var config struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("could not parse docker config: %w", err)
+	}
+	type credentials struct{ username, password string }
+	byRegistry := map[string]credentials{}
+	for key, auth := range config.Auths {
+		registry := strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+		registry = strings.SplitN(registry, "/", 2)[0]
+		creds := credentials{username: auth.Username, password: auth.Password}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("could not decode credentials for %s: %w", key, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("credentials for %s are not in the username:password format", key)
+			}
+			creds = credentials{username: parts[0], password: parts[1]}
+		}
+		byRegistry[registry] = creds
+	}
+	return func(registry string) (string, string) {
+		creds, ok := byRegistry[registry]
+		if !ok && registry == name.DefaultRegistry {
+			creds = byRegistry["docker.io"]
+		}
+		return creds.username, creds.password
+	}, nil
+}
+
+// keychain authenticates to registries with the credentials, anonymously
+// to those it has none for
+type keychain struct {
+	credentials Credentials
+}
+
+func (k keychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	username, password := k.credentials(target.RegistryStr())
+	if username == "" && password == "" {
+		return authn.Anonymous, nil
+	}
+	return &authn.Basic{Username: username, Password: password}, nil
+}
+
+type client struct {
+	credentials Credentials
+	transport   http.RoundTripper
+}
+
+// NewClient creates a client authenticating to registries with the
+// credentials over the transport. Requests failing on network errors
+// are retried.
+func NewClient(credentials Credentials, transport http.RoundTripper) Client {
+	return NewRegistry(credentials, transport)
+}
+
+func (c *client) options(ctx context.Context) []remote.Option {
+	return []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(keychain{credentials: c.credentials}),
+		remote.WithTransport(c.transport),
+	}
+}
+
+func (c *client) Mirror(ctx context.Context, source, destination string) (string, error) {
+	src, err := name.ParseReference(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid pull spec %s: %w", source, err)
+	}
+	dst, err := name.ParseReference(destination)
+	if err != nil {
+		return "", fmt.Errorf("invalid pull spec %s: %w", destination, err)
+	}
+	desc, err := remote.Get(src, c.options(ctx)...)
+	if err != nil {
+		return "", fmt.Errorf("could not get manifest of %s: %w", source, err)
+	}
+	// the manifests are written as they were read, which keeps the digest
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return "", fmt.Errorf("could not read index of %s: %w", source, err)
+		}
+		if err := remote.WriteIndex(dst, index, c.options(ctx)...); err != nil {
+			return "", fmt.Errorf("could not push %s: %w", destination, err)
+		}
+	} else {
+		image, err := desc.Image()
+		if err != nil {
+			return "", fmt.Errorf("could not read image %s: %w", source, err)
+		}
+		if err := remote.Write(dst, image, c.options(ctx)...); err != nil {
+			return "", fmt.Errorf("could not push %s: %w", destination, err)
+		}
+	}
+	return desc.Digest.String(), nil
+}
@@ -0,0 +1,205 @@
+package imagemirror
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func noCredentials(string) (string, string) { return "", "" }
+
+func parse(t *testing.T, pullSpec string) name.Reference {
+	ref, err := name.ParseReference(pullSpec)
+	if err != nil {
+		t.Fatalf("invalid pull spec %s: %v", pullSpec, err)
+	}
+	return ref
+}
+
+func TestMirror(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	image, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatalf("could not create image: %v", err)
+	}
+	imageDigest, err := image.Digest()
+	if err != nil {
+		t.Fatalf("could not get digest: %v", err)
+	}
+	if err := remote.Write(parse(t, host+"/ns/image:latest"), image); err != nil {
+		t.Fatalf("could not push image: %v", err)
+	}
+	index, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatalf("could not create index: %v", err)
+	}
+	indexDigest, err := index.Digest()
+	if err != nil {
+		t.Fatalf("could not get digest: %v", err)
+	}
+	if err := remote.WriteIndex(parse(t, host+"/ns/release:latest"), index); err != nil {
+		t.Fatalf("could not push index: %v", err)
+	}
+
+	var testCases = []struct {
+		name           string
+		source         string
+		expectedDigest string
+		expectedErr    bool
+	}{
+		{
+			name:           "index mirrored",
+			source:         "ns/release:latest",
+			expectedDigest: indexDigest.String(),
+		},
+		{
+			name:           "image mirrored by digest",
+			source:         "ns/image@" + imageDigest.String(),
+			expectedDigest: imageDigest.String(),
+		},
+		{
+			name:        "missing image",
+			source:      "ns/missing:latest",
+			expectedErr: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := NewClient(noCredentials, http.DefaultTransport)
+			destination := host + "/mirror/" + strings.Replace(testCase.name, " ", "-", -1) + ":4.9"
+			digest, err := client.Mirror(context.Background(), host+"/"+testCase.source, destination)
+			if testCase.expectedErr != (err != nil) {
+				t.Fatalf("expected error %t, got %v", testCase.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if digest != testCase.expectedDigest {
+				t.Errorf("expected digest %s, got %s", testCase.expectedDigest, digest)
+			}
+			desc, err := remote.Get(parse(t, destination))
+			if err != nil {
+				t.Fatalf("image was not mirrored: %v", err)
+			}
+			if desc.Digest.String() != testCase.expectedDigest {
+				t.Errorf("mirrored image has digest %s", desc.Digest)
+			}
+		})
+	}
+}
+
+func TestCredentialsFromDockerConfig(t *testing.T) {
+	raw := fmt.Sprintf(`{"auths":{"https://quay.io":{"auth":%q},"registry.ci.openshift.org":{"username":"ci","password":"token"},"docker.io":{"auth":%q}}}`,
+		base64.StdEncoding.EncodeToString([]byte("robot:secret")), base64.StdEncoding.EncodeToString([]byte("hub:pass")))
+	credentials, err := CredentialsFromDockerConfig([]byte(raw))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for registry, expected := range map[string][2]string{
+		"quay.io":                   {"robot", "secret"},
+		"registry.ci.openshift.org": {"ci", "token"},
+		name.DefaultRegistry:        {"hub", "pass"},
+		"registry.redhat.io":        {"", ""},
+	} {
+		if username, password := credentials(registry); username != expected[0] || password != expected[1] {
+			t.Errorf("%s: expected %s:%s, got %s:%s", registry, expected[0], expected[1], username, password)
+		}
+	}
+}
+
+func TestKeychain(t *testing.T) {
+	credentials := func(registry string) (string, string) {
+		if registry == "quay.io" {
+			return "robot", "secret"
+		}
+		return "", ""
+	}
+	for registry, expected := range map[string]authn.Authenticator{
+		"quay.io":            &authn.Basic{Username: "robot", Password: "secret"},
+		"registry.redhat.io": authn.Anonymous,
+	} {
+		resource, err := name.NewRegistry(registry)
+		if err != nil {
+			t.Fatalf("invalid registry: %v", err)
+		}
+		actual, err := keychain{credentials: credentials}.Resolve(resource)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Errorf("%s: unexpected authenticator: %s", registry, diff)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	image, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("could not create image: %v", err)
+	}
+	if err := remote.Write(parse(t, host+"/ns/base:latest"), image); err != nil {
+		t.Fatalf("could not push image: %v", err)
+	}
+	layers, err := image.Layers()
+	if err != nil {
+		t.Fatalf("could not get layers: %v", err)
+	}
+	layer, err := layers[0].Digest()
+	if err != nil {
+		t.Fatalf("could not get digest: %v", err)
+	}
+	client := NewRegistry(noCredentials, http.DefaultTransport)
+	ctx := context.Background()
+
+	destination := host + "/ns/release:latest"
+	if err := client.CopyBlob(ctx, host+"/ns/base:latest", destination, layer.String()); err != nil {
+		t.Fatalf("could not copy blob: %v", err)
+	}
+	config := `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`
+	configDigest, err := client.PushBlob(ctx, destination, []byte(config))
+	if err != nil {
+		t.Fatalf("could not push blob: %v", err)
+	}
+	blob, err := client.Blob(ctx, destination, configDigest)
+	if err != nil {
+		t.Fatalf("could not get blob: %v", err)
+	}
+	defer blob.Close()
+	if pulled, _ := ioutil.ReadAll(blob); string(pulled) != config {
+		t.Errorf("unexpected blob %s", pulled)
+	}
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"config":{"mediaType":%q,"size":%d,"digest":%q},"layers":[]}`, types.DockerManifestSchema2, types.DockerConfigJSON, len(config), configDigest)
+	digest, err := client.PushManifest(ctx, destination, string(types.DockerManifestSchema2), []byte(manifest))
+	if err != nil {
+		t.Fatalf("could not push manifest: %v", err)
+	}
+	raw, mediaType, pulled, err := client.Manifest(ctx, host+"/ns/release@"+digest)
+	if err != nil {
+		t.Fatalf("could not get manifest: %v", err)
+	}
+	if string(raw) != manifest || mediaType != string(types.DockerManifestSchema2) || pulled != digest {
+		t.Errorf("unexpected manifest %s of type %s with digest %s", raw, mediaType, pulled)
+	}
+	if _, err := client.PushManifest(ctx, host+"/ns/release@"+layer.String(), string(types.DockerManifestSchema2), []byte(manifest)); err == nil {
+		t.Error("expected a manifest pushed to a different digest to fail")
+	}
+}
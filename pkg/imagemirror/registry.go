@@ -0,0 +1,157 @@
+package imagemirror
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Registry reads and writes the content of images, for clients which compose
+// new images instead of copying existing ones
+type Registry interface {
+	Client
+	// Manifest returns the manifest of the image, its media type and its digest
+	Manifest(ctx context.Context, pullSpec string) ([]byte, string, string, error)
+	// Blob opens a blob in the repository of the image
+	Blob(ctx context.Context, pullSpec, digest string) (io.ReadCloser, error)
+	// CopyBlob copies a blob between the repositories of the images, unless
+	// the destination has it already
+	CopyBlob(ctx context.Context, source, destination, digest string) error
+	// PushBlob uploads the blob to the repository of the image, unless it has
+	// it already, and returns its digest
+	PushBlob(ctx context.Context, pullSpec string, raw []byte) (string, error)
+	// PushManifest uploads the manifest to the repository of the image,
+	// tagging it if the pull spec has a tag, and returns its digest
+	PushManifest(ctx context.Context, pullSpec, mediaType string, raw []byte) (string, error)
+}
+
+// NewRegistry creates a registry client authenticating with the
+// credentials over the transport. Requests failing on network errors
+// are retried.
+func NewRegistry(credentials Credentials, transport http.RoundTripper) Registry {
+	return &client{
+		credentials: credentials,
+		transport:   transport,
+	}
+}
+
+// blob is a blob pushed from memory
+type blob struct {
+	raw    []byte
+	digest v1.Hash
+}
+
+func (b *blob) Digest() (v1.Hash, error) { return b.digest, nil }
+
+func (b *blob) Compressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(b.raw)), nil
+}
+
+func (b *blob) Size() (int64, error) { return int64(len(b.raw)), nil }
+
+func (b *blob) MediaType() (types.MediaType, error) { return types.OCIUncompressedLayer, nil }
+
+// manifest is a manifest pushed from memory
+type manifest struct {
+	raw       []byte
+	mediaType string
+}
+
+func (m *manifest) RawManifest() ([]byte, error) { return m.raw, nil }
+
+func (m *manifest) MediaType() (types.MediaType, error) { return types.MediaType(m.mediaType), nil }
+
+func (c *client) Manifest(ctx context.Context, pullSpec string) ([]byte, string, string, error) {
+	ref, err := name.ParseReference(pullSpec)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("invalid pull spec %s: %w", pullSpec, err)
+	}
+	desc, err := remote.Get(ref, c.options(ctx)...)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("could not get manifest of %s: %w", pullSpec, err)
+	}
+	return desc.Manifest, string(desc.MediaType), desc.Digest.String(), nil
+}
+
+// layer references a blob in the repository of the image
+func (c *client) layer(ctx context.Context, pullSpec, digest string) (v1.Layer, error) {
+	ref, err := name.ParseReference(pullSpec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pull spec %s: %w", pullSpec, err)
+	}
+	return remote.Layer(ref.Context().Digest(digest), c.options(ctx)...)
+}
+
+func (c *client) Blob(ctx context.Context, pullSpec, digest string) (io.ReadCloser, error) {
+	layer, err := c.layer(ctx, pullSpec, digest)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := layer.Compressed()
+	if err != nil {
+		return nil, fmt.Errorf("could not get blob %s of %s: %w", digest, pullSpec, err)
+	}
+	return reader, nil
+}
+
+func (c *client) CopyBlob(ctx context.Context, source, destination, digest string) error {
+	layer, err := c.layer(ctx, source, digest)
+	if err != nil {
+		return err
+	}
+	dst, err := name.ParseReference(destination)
+	if err != nil {
+		return fmt.Errorf("invalid pull spec %s: %w", destination, err)
+	}
+	// the blob is mounted rather than copied within a registry
+	if err := remote.WriteLayer(dst.Context(), layer, c.options(ctx)...); err != nil {
+		return fmt.Errorf("could not copy blob %s to %s: %w", digest, destination, err)
+	}
+	return nil
+}
+
+func (c *client) PushBlob(ctx context.Context, pullSpec string, raw []byte) (string, error) {
+	ref, err := name.ParseReference(pullSpec)
+	if err != nil {
+		return "", fmt.Errorf("invalid pull spec %s: %w", pullSpec, err)
+	}
+	digest, _, err := v1.SHA256(bytes.NewReader(raw))
+	if err != nil {
+		return "", err
+	}
+	layer, err := partial.CompressedToLayer(&blob{raw: raw, digest: digest})
+	if err != nil {
+		return "", err
+	}
+	if err := remote.WriteLayer(ref.Context(), layer, c.options(ctx)...); err != nil {
+		return "", fmt.Errorf("could not push blob to %s: %w", pullSpec, err)
+	}
+	return digest.String(), nil
+}
+
+func (c *client) PushManifest(ctx context.Context, pullSpec, mediaType string, raw []byte) (string, error) {
+	ref, err := name.ParseReference(pullSpec)
+	if err != nil {
+		return "", fmt.Errorf("invalid pull spec %s: %w", pullSpec, err)
+	}
+	digest, _, err := v1.SHA256(bytes.NewReader(raw))
+	if err != nil {
+		return "", err
+	}
+	if d, ok := ref.(name.Digest); ok && d.DigestStr() != digest.String() {
+		return "", fmt.Errorf("manifest pushed to %s has digest %s", pullSpec, digest)
+	}
+	if err := remote.Put(ref, &manifest{raw: raw, mediaType: mediaType}, c.options(ctx)...); err != nil {
+		return "", fmt.Errorf("could not push manifest to %s: %w", pullSpec, err)
+	}
+	return digest.String(), nil
+}
@@ -0,0 +1,278 @@
+// Package jsonschema generates JSON Schema for the configuration types of
+// ci-operator and the step registry, ships the generated schemas and
+// validates documents against them. Editors and external validators use
+// the schemas for completion and validation.
+package jsonschema
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// draft is the JSON Schema version the generated schemas conform to
+const draft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a JSON Schema, restricted to the keywords the generator uses
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+	// closed disallows properties which are not listed, serialized as
+	// additionalProperties: false
+	closed bool
+}
+
+// MarshalJSON serializes closed objects with additionalProperties: false
+func (s Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	raw, err := json.Marshal(plain(s))
+	if err != nil || !s.closed {
+		return raw, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	fields["additionalProperties"] = json.RawMessage("false")
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON reads additionalProperties: false into a closed schema
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type plain Schema
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	closed := string(fields["additionalProperties"]) == "false"
+	if closed {
+		delete(fields, "additionalProperties")
+		var err error
+		if data, err = json.Marshal(fields); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+	s.closed = closed
+	return nil
+}
+
+// Names of the shipped schemas
+const (
+	CIOperatorConfig      = "ci-operator-config"
+	StepRegistryReference = "step-registry-reference"
+	StepRegistryChain     = "step-registry-chain"
+	StepRegistryWorkflow  = "step-registry-workflow"
+	StepRegistryObserver  = "step-registry-observer"
+)
+
+// Roots are the types the shipped schemas are generated for, by name
+var Roots = map[string]interface{}{
+	CIOperatorConfig:      api.ReleaseBuildConfiguration{},
+	StepRegistryReference: api.RegistryReferenceConfig{},
+	StepRegistryChain:     api.RegistryChainConfig{},
+	StepRegistryWorkflow:  api.RegistryWorkflowConfig{},
+	StepRegistryObserver:  api.RegistryObserverConfig{},
+}
+
+//go:embed schemas/*.json
+var shipped embed.FS
+
+// Raw returns the shipped schema with the name
+func Raw(name string) ([]byte, error) {
+	return shipped.ReadFile(fmt.Sprintf("schemas/%s.json", name))
+}
+
+// Load returns the parsed shipped schema with the name
+func Load(name string) (*Schema, error) {
+	raw, err := Raw(name)
+	if err != nil {
+		return nil, fmt.Errorf("no schema %s: %w", name, err)
+	}
+	var schema Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse schema %s: %w", name, err)
+	}
+	return &schema, nil
+}
+
+// Generate returns the schema for the type of the value. Descriptions of
+// types and fields are looked up by TypeName and TypeName.FieldName.
+func Generate(name string, value interface{}, descriptions map[string]string) *Schema {
+	g := generator{descriptions: descriptions, definitions: map[string]*Schema{}}
+	root := g.schemaFor(reflect.TypeOf(value))
+	return &Schema{
+		Schema:      draft,
+		ID:          fmt.Sprintf("%s.json", name),
+		Title:       name,
+		Ref:         root.Ref,
+		Definitions: g.definitions,
+	}
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	apiPackage      = reflect.TypeOf(api.ReleaseBuildConfiguration{}).PkgPath()
+)
+
+type generator struct {
+	descriptions map[string]string
+	definitions  map[string]*Schema
+}
+
+// definitionName names the definition of a type, qualifying types which
+// are not declared in the api package
+func definitionName(t reflect.Type) string {
+	if t.PkgPath() == apiPackage {
+		return t.Name()
+	}
+	return strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + t.Name()
+}
+
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string"}
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		// the serialized form is custom and not known to us
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string"}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name := definitionName(t)
+		if _, seen := g.definitions[name]; !seen {
+			// reserve the name first, so recursive types terminate
+			g.definitions[name] = &Schema{}
+			definition := g.object(t)
+			definition.Description = g.describe(t, t.Name())
+			g.definitions[name] = definition
+		}
+		return &Schema{Ref: "#/definitions/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+// describe looks up a description, which are only known for the api package
+func (g *generator) describe(t reflect.Type, key string) string {
+	if t.PkgPath() != apiPackage {
+		return ""
+	}
+	return g.descriptions[key]
+}
+
+// object returns the schema of a struct, with the fields of inlined
+// structs merged into it
+func (g *generator) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}, closed: true}
+	g.addFields(schema, t)
+	return schema
+}
+
+func (g *generator) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(schema, embedded)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := g.schemaFor(field.Type)
+		// editors show descriptions next to $ref, even though validators
+		// ignore them there
+		property.Description = g.describe(t, t.Name()+"."+field.Name)
+		schema.Properties[name] = property
+	}
+}
+
+// Names returns the names of the shipped schemas, sorted
+func Names() []string {
+	var names []string
+	for name := range Roots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Path is the path the schemas are served under, as /schemas/NAME.json
+const Path = "/schemas/"
+
+// Handler serves the shipped schemas
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, Path), ".json")
+		if _, known := Roots[name]; !known {
+			http.NotFound(w, r)
+			return
+		}
+		raw, err := Raw(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/schema+json")
+		if _, err := w.Write(raw); err != nil {
+			logrus.WithError(err).Warn("Failed to write schema.")
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type inner struct {
+	// Name names the thing.
+	Name string `json:"name"`
+}
+
+type outer struct {
+	inner    `json:",inline"`
+	Count    int               `json:"count,omitempty"`
+	Children []*outer          `json:"children,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Skipped  string            `json:"-"`
+}
+
+func TestGenerateAndValidate(t *testing.T) {
+	schema := Generate("outer", outer{}, nil)
+	raw, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatalf("failed to marshal schema: %v", err)
+	}
+	var parsed Schema
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+
+	var testCases = []struct {
+		name     string
+		document string
+		expected []string
+	}{
+		{
+			name:     "valid document",
+			document: "name: a\ncount: 1\nchildren:\n- name: b\n  labels:\n    key: value\n",
+		},
+		{
+			name:     "null values are accepted",
+			document: "name:\nchildren:\n- null\n",
+		},
+		{
+			name:     "wrong types",
+			document: "name: 1\ncount: 1.5\nchildren:\n- labels:\n    key: true\n",
+			expected: []string{
+				"children[0].labels.key: expected string, got boolean (#/definitions/github.com.openshift.ci-tools.pkg.jsonschema.outer/properties/labels/additionalProperties/type)",
+				"count: expected integer, got number (#/definitions/github.com.openshift.ci-tools.pkg.jsonschema.outer/properties/count/type)",
+				"name: expected string, got number (#/definitions/github.com.openshift.ci-tools.pkg.jsonschema.outer/properties/name/type)",
+			},
+		},
+		{
+			name:     "unknown fields",
+			document: "children:\n- nmae: a\n",
+			expected: []string{
+				`children[0].nmae: unknown field "nmae" (#/definitions/github.com.openshift.ci-tools.pkg.jsonschema.outer/additionalProperties)`,
+			},
+		},
+		{
+			name:     "wrong root type",
+			document: "- a\n",
+			expected: []string{"<root>: expected object, got array (#/definitions/github.com.openshift.ci-tools.pkg.jsonschema.outer/type)"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			errs, err := parsed.ValidateYAML([]byte(testCase.document))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var actual []string
+			for _, err := range errs {
+				actual = append(actual, err.Error())
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected validation errors: %s", diff)
+			}
+		})
+	}
+}
+
+func TestShippedSchemasAreUpToDate(t *testing.T) {
+	descriptions, err := Descriptions("../api")
+	if err != nil {
+		t.Fatalf("failed to read descriptions: %v", err)
+	}
+	for _, name := range Names() {
+		expected, err := json.MarshalIndent(Generate(name, Roots[name], descriptions), "", "  ")
+		if err != nil {
+			t.Fatalf("failed to marshal schema %s: %v", name, err)
+		}
+		actual, err := Raw(name)
+		if err != nil {
+			t.Fatalf("failed to read schema %s: %v", name, err)
+		}
+		if diff := cmp.Diff(string(expected)+"\n", string(actual)); diff != "" {
+			t.Errorf("schema %s is outdated, run hack/generate-jsonschema.sh: %s", name, diff)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package jsonschema
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// ValidationError is a violation of a schema by a document
+type ValidationError struct {
+	// Path locates the offending value in the document, e.g. tests[0].as
+	Path string
+	// SchemaPath locates the violated part of the schema
+	SchemaPath string
+	// Message describes the violation
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "<root>"
+	}
+	return fmt.Sprintf("%s: %s (%s)", path, e.Message, e.SchemaPath)
+}
+
+// ValidateYAML validates a YAML or JSON document against the schema
+func (s *Schema) ValidateYAML(raw []byte) ([]ValidationError, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(raw, &document); err != nil {
+		return nil, fmt.Errorf("failed to decode the document: %w", err)
+	}
+	return s.Validate(document), nil
+}
+
+// Validate validates a decoded JSON document against the schema. Null values
+// are accepted everywhere, as decoding them leaves fields unset.
+func (s *Schema) Validate(document interface{}) []ValidationError {
+	v := validator{root: s}
+	v.validate(s, document, "", "#")
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		return v.errors[i].Path < v.errors[j].Path
+	})
+	return v.errors
+}
+
+type validator struct {
+	root   *Schema
+	errors []ValidationError
+}
+
+func (v *validator) fail(path, schemaPath, format string, args ...interface{}) {
+	v.errors = append(v.errors, ValidationError{Path: path, SchemaPath: schemaPath, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate(schema *Schema, value interface{}, path, schemaPath string) {
+	if value == nil {
+		return
+	}
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/definitions/")
+		definition, ok := v.root.Definitions[name]
+		if !ok {
+			v.fail(path, schemaPath, "unresolvable reference %s", schema.Ref)
+			return
+		}
+		v.validate(definition, value, path, schema.Ref)
+		return
+	}
+	if schema.Type != "" && !hasType(value, schema.Type) {
+		v.fail(path, schemaPath+"/type", "expected %s, got %s", schema.Type, typeOf(value))
+		return
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		var keys []string
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := join(path, key)
+			if property, ok := schema.Properties[key]; ok {
+				v.validate(property, value[key], child, schemaPath+"/properties/"+key)
+				continue
+			}
+			switch {
+			case schema.AdditionalProperties != nil:
+				v.validate(schema.AdditionalProperties, value[key], child, schemaPath+"/additionalProperties")
+			case schema.closed:
+				v.fail(child, schemaPath+"/additionalProperties", "unknown field %q", key)
+			}
+		}
+	case []interface{}:
+		if schema.Items == nil {
+			return
+		}
+		for i, item := range value {
+			v.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i), schemaPath+"/items")
+		}
+	}
+}
+
+// join appends a field to a path
+func join(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func hasType(value interface{}, schemaType string) bool {
+	switch schemaType {
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return typeOf(value) == schemaType
+	}
+}
+
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "null"
+	}
+}
+
+// ValidateDocument validates a YAML or JSON document against the shipped
+// schema with the name, aggregating the violations into one error
+func ValidateDocument(name string, raw []byte) error {
+	schema, err := Load(name)
+	if err != nil {
+		return err
+	}
+	violations, err := schema.ValidateYAML(raw)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, violation := range violations {
+		errs = append(errs, violation)
+	}
+	return utilerrors.NewAggregate(errs)
+}
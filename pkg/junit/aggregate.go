@@ -0,0 +1,164 @@
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StepResults are the test suites reported by one attempt to run a step of
+// a multi-stage test
+type StepResults struct {
+	Step    string
+	Attempt int
+	Suites  []*TestSuite
+}
+
+// ParseSuites reads a jUnit file, which either holds a collection of test
+// suites or a single one
+func ParseSuites(raw []byte) ([]*TestSuite, error) {
+	suites := &TestSuites{}
+	if err := xml.Unmarshal(raw, suites); err == nil {
+		return suites.Suites, nil
+	}
+	suite := &TestSuite{}
+	if err := xml.Unmarshal(raw, suite); err != nil {
+		return nil, err
+	}
+	return []*TestSuite{suite}, nil
+}
+
+var attemptDir = regexp.MustCompile(`^attempt-([0-9]+)$`)
+
+// CollectStepResults reads the jUnit files in the artifact directory of a
+// multi-stage test. Every step stores its artifacts in a directory named
+// after it, with a directory for every attempt if the step was retried.
+func CollectStepResults(dir string) ([]StepResults, error) {
+	results := map[string]*StepResults{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasPrefix(info.Name(), "junit") || filepath.Ext(info.Name()) != ".xml" {
+			return nil
+		}
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(relative), "/")
+		if len(parts) < 2 {
+			// reports of the test itself, not of one of its steps
+			return nil
+		}
+		step, attempt := parts[0], 1
+		if match := attemptDir.FindStringSubmatch(parts[1]); match != nil && len(parts) > 2 {
+			attempt, _ = strconv.Atoi(match[1])
+		}
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		suites, err := ParseSuites(raw)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", relative, err)
+		}
+		key := fmt.Sprintf("%s/%d", step, attempt)
+		if _, ok := results[key]; !ok {
+			results[key] = &StepResults{Step: step, Attempt: attempt}
+		}
+		results[key].Suites = append(results[key].Suites, suites...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var collected []StepResults
+	for _, result := range results {
+		collected = append(collected, *result)
+	}
+	sort.Slice(collected, func(i, j int) bool {
+		if collected[i].Step != collected[j].Step {
+			return collected[i].Step < collected[j].Step
+		}
+		return collected[i].Attempt < collected[j].Attempt
+	})
+	return collected, nil
+}
+
+// Aggregate merges the results of all steps of a multi-stage test into a
+// single suite named after the test. Test cases are prefixed with the name
+// of the step which reported them. A test case reported more than once is
+// recorded with the result of the last attempt of its step. Within that
+// attempt, a pass wins over a failure and a failure over a skip, as tests
+// may be retried in-process.
+func Aggregate(test string, results []StepResults) *TestSuite {
+	type reported struct {
+		attempt  int
+		testCase *TestCase
+	}
+	cases := map[string]reported{}
+	var names []string
+	for _, result := range results {
+		for _, testCase := range flatten(result.Suites) {
+			normalized := *testCase
+			normalized.Name = fmt.Sprintf("%s - %s", result.Step, testCase.Name)
+			previous, seen := cases[normalized.Name]
+			if !seen {
+				names = append(names, normalized.Name)
+			}
+			switch {
+			case !seen, result.Attempt > previous.attempt:
+			case result.Attempt < previous.attempt:
+				continue
+			case rank(&normalized) > rank(previous.testCase):
+			default:
+				continue
+			}
+			cases[normalized.Name] = reported{attempt: result.Attempt, testCase: &normalized}
+		}
+	}
+	sort.Strings(names)
+	suite := &TestSuite{Name: test}
+	for _, name := range names {
+		testCase := cases[name].testCase
+		suite.TestCases = append(suite.TestCases, testCase)
+		suite.NumTests++
+		suite.Duration += testCase.Duration
+		switch {
+		case testCase.FailureOutput != nil:
+			suite.NumFailed++
+		case testCase.SkipMessage != nil:
+			suite.NumSkipped++
+		}
+	}
+	return suite
+}
+
+// rank orders the results of a test case, preferring ones that ran
+func rank(testCase *TestCase) int {
+	switch {
+	case testCase.SkipMessage != nil:
+		return 0
+	case testCase.FailureOutput != nil:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// flatten collects the test cases of the suites and all their children
+func flatten(suites []*TestSuite) []*TestCase {
+	var cases []*TestCase
+	for _, suite := range suites {
+		cases = append(cases, suite.TestCases...)
+		cases = append(cases, flatten(suite.Children)...)
+	}
+	return cases
+}
@@ -0,0 +1,125 @@
+package junit
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestCollectStepResults(t *testing.T) {
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		"junit_e2e.xml":                                   `<testsuites><testsuite name="aggregated"></testsuite></testsuites>`,
+		"setup/artifacts/junit_install.xml":               `<testsuite name="install"><testcase name="install"></testcase></testsuite>`,
+		"test/attempt-1/artifacts/junit/junit_e2e.xml":    `<testsuites><testsuite name="e2e"><testcase name="a"><failure>oops</failure></testcase></testsuite></testsuites>`,
+		"test/attempt-2/artifacts/junit/junit_e2e.xml":    `<testsuites><testsuite name="e2e"><testcase name="a"></testcase></testsuite></testsuites>`,
+		"test/attempt-2/artifacts/junit/not-a-report.xml": `garbage`,
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	actual, err := CollectStepResults(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []StepResults{
+		{Step: "setup", Attempt: 1, Suites: []*TestSuite{{Name: "install", TestCases: []*TestCase{{Name: "install"}}}}},
+		{Step: "test", Attempt: 1, Suites: []*TestSuite{{Name: "e2e", TestCases: []*TestCase{{Name: "a", FailureOutput: &FailureOutput{Output: "oops"}}}}}},
+		{Step: "test", Attempt: 2, Suites: []*TestSuite{{Name: "e2e", TestCases: []*TestCase{{Name: "a"}}}}},
+	}
+	if diff := cmp.Diff(expected, actual, cmpopts.IgnoreTypes(xml.Name{})); diff != "" {
+		t.Errorf("unexpected results: %s", diff)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	failed := func(name string) *TestCase {
+		return &TestCase{Name: name, Duration: 1, FailureOutput: &FailureOutput{Output: "failed"}}
+	}
+	passed := func(name string) *TestCase { return &TestCase{Name: name, Duration: 1} }
+	skipped := func(name string) *TestCase {
+		return &TestCase{Name: name, SkipMessage: &SkipMessage{Message: "skipped"}}
+	}
+	var testCases = []struct {
+		name     string
+		results  []StepResults
+		expected *TestSuite
+	}{
+		{
+			name:     "no results",
+			expected: &TestSuite{Name: "e2e"},
+		},
+		{
+			name: "cases are prefixed with their steps, including nested suites",
+			results: []StepResults{
+				{Step: "setup", Attempt: 1, Suites: []*TestSuite{{TestCases: []*TestCase{passed("install")}}}},
+				{Step: "test", Attempt: 1, Suites: []*TestSuite{{Children: []*TestSuite{{TestCases: []*TestCase{failed("a"), skipped("b")}}}}}},
+			},
+			expected: &TestSuite{
+				Name:       "e2e",
+				NumTests:   3,
+				NumFailed:  1,
+				NumSkipped: 1,
+				Duration:   2,
+				TestCases:  []*TestCase{passed("setup - install"), failed("test - a"), skipped("test - b")},
+			},
+		},
+		{
+			name: "the last attempt of a step wins",
+			results: []StepResults{
+				{Step: "test", Attempt: 2, Suites: []*TestSuite{{TestCases: []*TestCase{failed("a")}}}},
+				{Step: "test", Attempt: 1, Suites: []*TestSuite{{TestCases: []*TestCase{passed("a"), passed("b")}}}},
+			},
+			expected: &TestSuite{
+				Name:      "e2e",
+				NumTests:  2,
+				NumFailed: 1,
+				Duration:  2,
+				TestCases: []*TestCase{failed("test - a"), passed("test - b")},
+			},
+		},
+		{
+			name: "a case retried within an attempt passes if it passed once",
+			results: []StepResults{
+				{Step: "test", Attempt: 1, Suites: []*TestSuite{{TestCases: []*TestCase{skipped("a"), failed("a"), passed("a"), failed("a")}}}},
+			},
+			expected: &TestSuite{
+				Name:      "e2e",
+				NumTests:  1,
+				Duration:  1,
+				TestCases: []*TestCase{passed("test - a")},
+			},
+		},
+		{
+			name: "the same case in different steps is kept apart",
+			results: []StepResults{
+				{Step: "test", Attempt: 1, Suites: []*TestSuite{{TestCases: []*TestCase{passed("a")}}}},
+				{Step: "upgrade", Attempt: 1, Suites: []*TestSuite{{TestCases: []*TestCase{failed("a")}}}},
+			},
+			expected: &TestSuite{
+				Name:      "e2e",
+				NumTests:  2,
+				NumFailed: 1,
+				Duration:  2,
+				TestCases: []*TestCase{passed("test - a"), failed("upgrade - a")},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, Aggregate("e2e", testCase.results), cmpopts.IgnoreTypes(xml.Name{})); diff != "" {
+				t.Errorf("unexpected suite: %s", diff)
+			}
+		})
+	}
+}
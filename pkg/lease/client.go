@@ -38,6 +38,7 @@ type Metrics struct {
 type Client interface {
 	// Acquire leases `n` resources and returns the lease names.
 	// Will block until resources are available or 150m pass, `n` must be > 0.
+	// Either all `n` resources are leased or, on error, none are held.
 	// `ctx` can be used to abort the operation, `cancel` is called if any
 	// subsequent updates to the lease fail.
 	Acquire(rtype string, n uint, ctx context.Context, cancel context.CancelFunc) ([]string, error)
@@ -104,6 +105,12 @@ func (c *client) Acquire(rtype string, n uint, ctx context.Context, cancel conte
 	for i := uint(0); i < n; i++ {
 		r, err := c.boskos.AcquireWaitWithPriority(ctx, rtype, freeState, leasedState, randId())
 		if err != nil {
+			// do not hold on to a subset of the resources requested
+			for _, name := range ret {
+				if err := c.Release(name); err != nil {
+					log.Printf("warning: failed to release lease %q after acquisition failure: %v", name, err)
+				}
+			}
 			return nil, err
 		}
 		c.Lock()
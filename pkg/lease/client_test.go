@@ -3,6 +3,7 @@ package lease
 import (
 	"context"
 	"reflect"
+	"strconv"
 	"testing"
 
 	"k8s.io/apimachinery/pkg/util/diff"
@@ -40,6 +41,35 @@ func TestAcquire(t *testing.T) {
 	}
 }
 
+func TestAcquirePartialFailure(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+	client := NewFakeClient("owner", "url", 0, sets.NewString("acquire owner rtype free leased 2"), &calls)
+	var id int
+	randId = func() string {
+		id++
+		return strconv.Itoa(id)
+	}
+	if _, err := client.Acquire("rtype", 3, ctx, nil); err == nil {
+		t.Fatal("Acquire() did not fail")
+	}
+	expected := []string{
+		"acquire owner rtype free leased 1",
+		"acquire owner rtype free leased 2",
+		"releaseone owner rtype_0 free",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("wrong calls to the boskos client: %v", diff.ObjectDiff(calls, expected))
+	}
+	list, err := client.ReleaseAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("leases were leaked: %v", list)
+	}
+}
+
 func TestHeartbeatCancel(t *testing.T) {
 	ctx := context.Background()
 	var calls []string
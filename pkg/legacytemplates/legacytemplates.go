@@ -0,0 +1,459 @@
+// Package legacytemplates converts the templates of legacy cluster tests into
+// multi-stage tests, so that they run like every other test while their
+// owners migrate them to the step registry.
+//
+// Every container of the pod of a template becomes a literal step: init
+// containers and the `setup` container run in the pre phase, the `teardown`
+// container in the post phase and the test container in the test phase.
+// Parameters are substituted as the template would have been processed,
+// images become dependencies of the steps and the volumes the containers
+// share are linked to the directories multi-stage tests share between steps.
+// Steps run one after another, so templates with pods or test containers
+// which run concurrently cannot be converted.
+package legacytemplates
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+
+	templateapi "github.com/openshift/api/template/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps/utils"
+)
+
+// ReportFilename is the name of the conversion report in the artifacts of a job
+const ReportFilename = "ci-operator-template-conversion.json"
+
+const (
+	setupContainer     = "setup"
+	teardownContainer  = "teardown"
+	artifactsContainer = "artifacts"
+	artifactsVolume    = "artifacts"
+	// exitMarker is the file legacy templates touch in their shared
+	// directory when the test container exits, which teardown containers
+	// wait for
+	exitMarker = "exit"
+)
+
+// automaticParameters are provided to the steps of every multi-stage test
+var automaticParameters = sets.NewString("NAMESPACE", "JOB_NAME", "JOB_NAME_SAFE", "JOB_NAME_HASH", "BUILD_ID")
+
+// profileParameters are provided to the steps of multi-stage tests which use
+// a cluster profile
+var profileParameters = sets.NewString("CLUSTER_TYPE", utils.ImageFormatEnv, utils.ReleaseImageEnv(api.LatestReleaseName))
+
+var parameterReference = regexp.MustCompile(`\$\{\{?([a-zA-Z0-9_]+)\}?\}`)
+
+// Construct is a part of a template that was not carried over to the
+// converted test
+type Construct struct {
+	// Path locates the construct in the template
+	Path string `json:"path"`
+	// Reason explains why the construct was not carried over
+	Reason string `json:"reason"`
+}
+
+// Report is the outcome of the conversion of a template
+type Report struct {
+	Template  string `json:"template"`
+	Converted bool   `json:"converted"`
+	// Unconvertible constructs prevent the conversion, the template runs
+	// as it is
+	Unconvertible []Construct `json:"unconvertible,omitempty"`
+	// Dropped constructs are not needed by multi-stage tests and are left
+	// out of the converted test
+	Dropped []Construct `json:"dropped,omitempty"`
+}
+
+// WriteReports stores the reports in the directory
+func WriteReports(dir string, reports []Report) error {
+	raw, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal template conversion report: %w", err)
+	}
+	return ioutil.WriteFile(filepath.Join(dir, ReportFilename), raw, 0644)
+}
+
+// ClusterProfile returns the profile of a legacy cluster test
+func ClusterProfile(test api.TestStepConfiguration) api.ClusterProfile {
+	switch {
+	case test.OpenshiftAnsibleClusterTestConfiguration != nil:
+		return test.OpenshiftAnsibleClusterTestConfiguration.ClusterProfile
+	case test.OpenshiftAnsibleSrcClusterTestConfiguration != nil:
+		return test.OpenshiftAnsibleSrcClusterTestConfiguration.ClusterProfile
+	case test.OpenshiftAnsibleCustomClusterTestConfiguration != nil:
+		return test.OpenshiftAnsibleCustomClusterTestConfiguration.ClusterProfile
+	case test.OpenshiftInstallerClusterTestConfiguration != nil:
+		return test.OpenshiftInstallerClusterTestConfiguration.ClusterProfile
+	case test.OpenshiftInstallerUPIClusterTestConfiguration != nil:
+		return test.OpenshiftInstallerUPIClusterTestConfiguration.ClusterProfile
+	case test.OpenshiftInstallerUPISrcClusterTestConfiguration != nil:
+		return test.OpenshiftInstallerUPISrcClusterTestConfiguration.ClusterProfile
+	case test.OpenshiftInstallerCustomTestImageClusterTestConfiguration != nil:
+		return test.OpenshiftInstallerCustomTestImageClusterTestConfiguration.ClusterProfile
+	}
+	return ""
+}
+
+// converter holds the state of the conversion of a template
+type converter struct {
+	profile   api.ClusterProfile
+	resources api.ResourceRequirements
+	// values are the parameters substituted when the template is converted
+	values map[string]string
+	// runtime are the parameters the steps expose in their environment,
+	// which are left for the shell to expand
+	runtime sets.String
+	report  *Report
+}
+
+// Convert translates the template into a multi-stage test using the cluster
+// profile. Parameters which are not provided to multi-stage steps are looked
+// up like ci-operator does for templates, falling back to their defaults.
+// Containers which do not request resources are given the requirements. The
+// test is nil when the template cannot be converted, the report tells why.
+func Convert(template *templateapi.Template, profile api.ClusterProfile, resources api.ResourceRequirements, lookup func(name string) (string, bool)) (*api.MultiStageTestConfigurationLiteral, Report) {
+	report := Report{Template: template.Name}
+	c := converter{profile: profile, resources: resources, values: map[string]string{}, runtime: sets.NewString(), report: &report}
+	c.parameters(template.Parameters, lookup)
+
+	test := &api.MultiStageTestConfigurationLiteral{ClusterProfile: profile}
+	var pods int
+	for i, object := range template.Objects {
+		path := fmt.Sprintf("objects[%d]", i)
+		raw := object.Raw
+		if raw == nil && object.Object != nil {
+			var err error
+			if raw, err = json.Marshal(object.Object); err != nil {
+				c.unconvertible(path, fmt.Sprintf("could not be decoded: %v", err))
+				continue
+			}
+		}
+		var meta struct {
+			Kind       string `json:"kind"`
+			APIVersion string `json:"apiVersion"`
+		}
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			c.unconvertible(path, fmt.Sprintf("could not be decoded: %v", err))
+			continue
+		}
+		switch meta.Kind {
+		case "Pod":
+			pods++
+			if pods > 1 {
+				c.unconvertible(path, "pods of a template run concurrently, steps run one after another")
+				continue
+			}
+			var pod coreapi.Pod
+			if err := json.Unmarshal(raw, &pod); err != nil {
+				c.unconvertible(path, fmt.Sprintf("could not be decoded: %v", err))
+				continue
+			}
+			c.pod(path, pod, test)
+		case "Role", "RoleBinding":
+			c.dropped(path, fmt.Sprintf("%s is not created, steps run with the permissions multi-stage tests are granted", meta.Kind))
+		default:
+			c.unconvertible(path, fmt.Sprintf("%s objects have no equivalent in multi-stage tests", meta.Kind))
+		}
+	}
+	if pods == 0 {
+		c.unconvertible("objects", "the template creates no pod")
+	}
+	if len(report.Unconvertible) != 0 {
+		return nil, report
+	}
+	report.Converted = true
+	return test, report
+}
+
+func (c *converter) unconvertible(path, reason string) {
+	c.report.Unconvertible = append(c.report.Unconvertible, Construct{Path: path, Reason: reason})
+}
+
+func (c *converter) dropped(path, reason string) {
+	c.report.Dropped = append(c.report.Dropped, Construct{Path: path, Reason: reason})
+}
+
+// parameters sorts the parameters of the template into those the steps
+// expose at runtime and those substituted now
+func (c *converter) parameters(parameters []templateapi.Parameter, lookup func(name string) (string, bool)) {
+	for i, parameter := range parameters {
+		path := fmt.Sprintf("parameters[%d]", i)
+		switch {
+		case automaticParameters.Has(parameter.Name):
+			c.runtime.Insert(parameter.Name)
+		case profileParameters.Has(parameter.Name) && c.profile != "":
+			c.runtime.Insert(parameter.Name)
+		case imageDependency(parameter.Name) != "":
+			c.runtime.Insert(parameter.Name)
+		case profileParameters.Has(parameter.Name):
+			c.unconvertible(path, fmt.Sprintf("%s is only provided to tests using a cluster profile", parameter.Name))
+		case parameter.Generate != "":
+			c.unconvertible(path, fmt.Sprintf("generated parameter %s is not supported", parameter.Name))
+		default:
+			value, ok := lookup(parameter.Name)
+			if !ok {
+				value = parameter.Value
+			}
+			if value == "" && parameter.Required {
+				c.unconvertible(path, fmt.Sprintf("required parameter %s has no value", parameter.Name))
+				continue
+			}
+			c.values[parameter.Name] = value
+		}
+	}
+}
+
+// imageDependency determines the dependency exposing the pull spec a
+// parameter of a template holds
+func imageDependency(parameter string) string {
+	switch {
+	case parameter == utils.ImageFormatEnv:
+		return ""
+	case utils.IsReleaseImageEnv(parameter):
+		return fmt.Sprintf("%s:%s", api.ReleaseImageStream, utils.ReleaseNameFrom(parameter))
+	case utils.IsPipelineImageEnv(parameter):
+		return fmt.Sprintf("%s:%s", api.PipelineImageStream, imageName(parameter, utils.PipelineImageEnvFor("")))
+	case utils.IsInitialImageEnv(parameter):
+		return fmt.Sprintf("%s:%s", api.ReleaseStreamFor(api.InitialReleaseName), imageName(parameter, utils.InitialImageEnv("")))
+	case utils.IsStableImageEnv(parameter):
+		return fmt.Sprintf("%s:%s", api.ReleaseStreamFor(api.LatestReleaseName), utils.StableImageNameFrom(parameter))
+	}
+	return ""
+}
+
+func imageName(parameter, prefix string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(parameter, prefix), "_", "-"))
+}
+
+// substitute processes parameters like templates are: known values are
+// replaced, runtime parameters are left for the shell to expand and other
+// references are left alone
+func (c *converter) substitute(value string) string {
+	return parameterReference.ReplaceAllStringFunc(value, func(reference string) string {
+		name := parameterReference.FindStringSubmatch(reference)[1]
+		if v, ok := c.values[name]; ok {
+			return v
+		}
+		if c.runtime.Has(name) {
+			return "${" + name + "}"
+		}
+		return reference
+	})
+}
+
+// runtimeReferences determines whether the value still references
+// parameters after substitution
+func (c *converter) runtimeReferences(value string) bool {
+	for _, match := range parameterReference.FindAllStringSubmatch(value, -1) {
+		if c.runtime.Has(match[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *converter) pod(path string, pod coreapi.Pod, test *api.MultiStageTestConfigurationLiteral) {
+	if pod.Spec.RestartPolicy != "" && pod.Spec.RestartPolicy != coreapi.RestartPolicyNever {
+		c.unconvertible(path+".spec.restartPolicy", fmt.Sprintf("pods restarting %s cannot run as steps", pod.Spec.RestartPolicy))
+	}
+	volumes := map[string]coreapi.Volume{}
+	for _, volume := range pod.Spec.Volumes {
+		volumes[volume.Name] = volume
+	}
+	c.sharedArtifacts(path, pod.Spec)
+	for i, container := range pod.Spec.InitContainers {
+		if step, ok := c.step(fmt.Sprintf("%s.spec.initContainers[%d]", path, i), container.Name, container, pod.Spec, volumes, false); ok {
+			test.Pre = append(test.Pre, step)
+		}
+	}
+	var post []api.LiteralTestStep
+	var tests int
+	for i, container := range pod.Spec.Containers {
+		containerPath := fmt.Sprintf("%s.spec.containers[%d]", path, i)
+		switch container.Name {
+		case artifactsContainer:
+			c.dropped(containerPath, "artifacts are gathered from every step of multi-stage tests")
+		case setupContainer:
+			if step, ok := c.step(containerPath, container.Name, container, pod.Spec, volumes, false); ok {
+				test.Pre = append(test.Pre, step)
+			}
+		case teardownContainer:
+			if step, ok := c.step(containerPath, container.Name, container, pod.Spec, volumes, true); ok {
+				post = append(post, step)
+			}
+		default:
+			tests++
+			if tests > 1 {
+				c.unconvertible(containerPath, "test containers of a pod run concurrently, steps run one after another")
+				continue
+			}
+			if step, ok := c.step(containerPath, container.Name, container, pod.Spec, volumes, false); ok {
+				test.Test = append(test.Test, step)
+			}
+		}
+	}
+	test.Post = append(test.Post, post...)
+}
+
+// sharedArtifacts reports an artifacts volume which more than one container
+// mounts, as every step has a directory for artifacts of its own and could
+// not read what the others wrote to it
+func (c *converter) sharedArtifacts(path string, spec coreapi.PodSpec) {
+	var mounting []string
+	for _, container := range append(append([]coreapi.Container{}, spec.InitContainers...), spec.Containers...) {
+		if container.Name == artifactsContainer {
+			continue
+		}
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == artifactsVolume {
+				mounting = append(mounting, container.Name)
+				break
+			}
+		}
+	}
+	if len(mounting) < 2 {
+		return
+	}
+	for i, volume := range spec.Volumes {
+		if volume.Name == artifactsVolume && volume.EmptyDir != nil {
+			c.unconvertible(fmt.Sprintf("%s.spec.volumes[%d]", path, i), fmt.Sprintf("the artifacts directory is shared by containers %s, every step has its own", strings.Join(mounting, ", ")))
+		}
+	}
+}
+
+// step converts a container of a pod into a literal step. Teardown steps
+// run in the post phase, which also runs when the test container did not.
+func (c *converter) step(path, name string, container coreapi.Container, spec coreapi.PodSpec, volumes map[string]coreapi.Volume, teardown bool) (api.LiteralTestStep, bool) {
+	before := len(c.report.Unconvertible)
+	step := api.LiteralTestStep{As: name}
+
+	image := c.substitute(container.Image)
+	if match := parameterReference.FindStringSubmatch(image); match != nil && match[0] == image && imageDependency(match[1]) != "" {
+		step.From = imageDependency(match[1])
+	} else {
+		c.unconvertible(path+".image", fmt.Sprintf("image %q is not an image of the job given by a parameter", container.Image))
+	}
+
+	var prelude []string
+	for i, env := range container.Env {
+		if env.ValueFrom != nil {
+			c.unconvertible(fmt.Sprintf("%s.env[%d]", path, i), fmt.Sprintf("%s is set from a reference, only values are supported", env.Name))
+			continue
+		}
+		value := c.substitute(env.Value)
+		prelude = append(prelude, fmt.Sprintf("export %s=%s", env.Name, c.quote(value)))
+	}
+	for i, mount := range container.VolumeMounts {
+		mountPath := fmt.Sprintf("%s.volumeMounts[%d]", path, i)
+		if mount.SubPath != "" || mount.SubPathExpr != "" {
+			c.unconvertible(mountPath, "mounts of sub-paths are not supported")
+			continue
+		}
+		volume, ok := volumes[mount.Name]
+		if !ok {
+			c.unconvertible(mountPath, fmt.Sprintf("volume %s is not defined", mount.Name))
+			continue
+		}
+		var target string
+		switch {
+		case volume.EmptyDir != nil && volume.Name == artifactsVolume:
+			target = "ARTIFACT_DIR"
+		case volume.EmptyDir != nil:
+			target = "SHARED_DIR"
+			if teardown {
+				prelude = append(prelude, fmt.Sprintf("touch \"${SHARED_DIR}/%s\"", exitMarker))
+			}
+		case volume.Secret != nil && strings.HasSuffix(volume.Secret.SecretName, "cluster-profile"):
+			if c.profile == "" {
+				c.unconvertible(mountPath, "the cluster profile is only mounted in tests using a cluster profile")
+				continue
+			}
+			target = "CLUSTER_PROFILE_DIR"
+		default:
+			c.unconvertible(mountPath, fmt.Sprintf("volume %s has no equivalent in multi-stage tests", volume.Name))
+			continue
+		}
+		prelude = append(prelude, fmt.Sprintf("mkdir -p %s && rm -rf %s && ln -s \"${%s}\" %s", singleQuote(filepath.Dir(mount.MountPath)), singleQuote(mount.MountPath), target, singleQuote(mount.MountPath)))
+	}
+
+	script, ok := commands(append(append([]string{}, container.Command...), container.Args...))
+	if !ok {
+		c.unconvertible(path+".command", "containers running the entrypoint of their image are not supported")
+	}
+	step.Commands = strings.Join(append(prelude, c.substitute(script)), "\n")
+
+	step.Resources = c.resources
+	if len(container.Resources.Requests) != 0 || len(container.Resources.Limits) != 0 {
+		step.Resources = api.ResourceRequirements{Requests: resourceList(container.Resources.Requests), Limits: resourceList(container.Resources.Limits)}
+	}
+	if spec.ActiveDeadlineSeconds != nil {
+		step.Timeout = &prowv1.Duration{Duration: time.Duration(*spec.ActiveDeadlineSeconds) * time.Second}
+	}
+	if spec.TerminationGracePeriodSeconds != nil {
+		step.GracePeriod = &prowv1.Duration{Duration: time.Duration(*spec.TerminationGracePeriodSeconds) * time.Second}
+	}
+	for _, parameter := range c.runtime.List() {
+		if c.profile != "" && profileParameters.Has(parameter) {
+			continue
+		}
+		if dependency := imageDependency(parameter); dependency != "" && strings.Contains(step.Commands, "${"+parameter+"}") {
+			step.Dependencies = append(step.Dependencies, api.StepDependency{Name: dependency, Env: parameter})
+		}
+	}
+	return step, len(c.report.Unconvertible) == before
+}
+
+// quote makes the value a single shell word, leaving runtime parameters
+// for the shell to expand
+func (c *converter) quote(value string) string {
+	if !c.runtimeReferences(value) {
+		return singleQuote(value)
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`").Replace(value)
+	return `"` + escaped + `"`
+}
+
+func singleQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
+// commands turns the command of a container into a script, unwrapping
+// scripts passed to a shell
+func commands(command []string) (string, bool) {
+	if len(command) == 0 {
+		return "", false
+	}
+	if len(command) == 3 && command[1] == "-c" {
+		switch command[0] {
+		case "/bin/bash", "bash", "/bin/sh", "sh":
+			return command[2], true
+		}
+	}
+	var words []string
+	for _, word := range command {
+		words = append(words, singleQuote(word))
+	}
+	return strings.Join(words, " "), true
+}
+
+func resourceList(list coreapi.ResourceList) api.ResourceList {
+	if len(list) == 0 {
+		return nil
+	}
+	ret := api.ResourceList{}
+	for name, quantity := range list {
+		ret[string(name)] = quantity.String()
+	}
+	return ret
+}
@@ -0,0 +1,180 @@
+package legacytemplates
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"sigs.k8s.io/yaml"
+
+	templateapi "github.com/openshift/api/template/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func loadTemplate(t *testing.T) *templateapi.Template {
+	raw, err := ioutil.ReadFile("testdata/template.yaml")
+	if err != nil {
+		t.Fatalf("failed to read template: %v", err)
+	}
+	template := &templateapi.Template{}
+	if err := yaml.Unmarshal(raw, template); err != nil {
+		t.Fatalf("failed to decode template: %v", err)
+	}
+	template.Name = "e2e-aws"
+	return template
+}
+
+func TestConvert(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "TEST_COMMAND" {
+			return "run-tests 'a b'", true
+		}
+		return "", false
+	}
+	resources := api.ResourceRequirements{Requests: api.ResourceList{"cpu": "100m"}}
+	timeout, gracePeriod := &prowv1.Duration{Duration: 4 * time.Hour}, &prowv1.Duration{Duration: 15 * time.Minute}
+
+	test, report := Convert(loadTemplate(t), "aws", resources, lookup)
+	expected := &api.MultiStageTestConfigurationLiteral{
+		ClusterProfile: "aws",
+		Pre: []api.LiteralTestStep{{
+			As:   "setup",
+			From: "stable:tests",
+			Commands: `mkdir -p '/tmp' && rm -rf '/tmp/shared' && ln -s "${SHARED_DIR}" '/tmp/shared'
+mkdir -p '/tmp' && rm -rf '/tmp/cluster' && ln -s "${CLUSTER_PROFILE_DIR}" '/tmp/cluster'
+#!/bin/bash
+echo "origin-ci-int-aws.dev.rhcloud.com" > /tmp/shared/domain
+`,
+			Resources:   resources,
+			Timeout:     timeout,
+			GracePeriod: gracePeriod,
+		}},
+		Test: []api.LiteralTestStep{{
+			As:   "test",
+			From: "pipeline:src",
+			Commands: `export TEST_COMMAND='run-tests '"'"'a b'"'"''
+export TESTS_IMAGE="${IMAGE_TESTS}"
+mkdir -p '/tmp' && rm -rf '/tmp/shared' && ln -s "${SHARED_DIR}" '/tmp/shared'
+mkdir -p '/tmp' && rm -rf '/tmp/artifacts' && ln -s "${ARTIFACT_DIR}" '/tmp/artifacts'
+#!/bin/bash
+set -euo pipefail
+trap 'touch /tmp/shared/exit' EXIT
+eval "run-tests 'a b'"
+`,
+			Resources:    api.ResourceRequirements{Requests: api.ResourceList{"cpu": "3", "memory": "600Mi"}},
+			Timeout:      timeout,
+			GracePeriod:  gracePeriod,
+			Dependencies: []api.StepDependency{{Name: "stable:tests", Env: "IMAGE_TESTS"}},
+		}},
+		Post: []api.LiteralTestStep{{
+			As:   "teardown",
+			From: "stable:tests",
+			Commands: `touch "${SHARED_DIR}/exit"
+mkdir -p '/tmp' && rm -rf '/tmp/shared' && ln -s "${SHARED_DIR}" '/tmp/shared'
+#!/bin/bash
+until [[ -f /tmp/shared/exit ]]; do sleep 1; done
+echo "${HOME}"
+`,
+			Resources:   resources,
+			Timeout:     timeout,
+			GracePeriod: gracePeriod,
+		}},
+	}
+	if diff := cmp.Diff(expected, test); diff != "" {
+		t.Errorf("unexpected test: %s", diff)
+	}
+	expectedReport := Report{
+		Template:  "e2e-aws",
+		Converted: true,
+		Dropped: []Construct{
+			{Path: "objects[0]", Reason: "RoleBinding is not created, steps run with the permissions multi-stage tests are granted"},
+			{Path: "objects[1].spec.containers[3]", Reason: "artifacts are gathered from every step of multi-stage tests"},
+		},
+	}
+	if diff := cmp.Diff(expectedReport, report); diff != "" {
+		t.Errorf("unexpected report: %s", diff)
+	}
+}
+
+func TestConvertUnconvertible(t *testing.T) {
+	template := loadTemplate(t)
+	template.Objects = append(template.Objects, template.Objects[0])
+	template.Objects[2].Raw = []byte(`{"kind":"Service","apiVersion":"v1"}`)
+
+	test, report := Convert(template, "", api.ResourceRequirements{}, func(string) (string, bool) { return "", false })
+	if test != nil {
+		t.Errorf("expected no test, got %v", test)
+	}
+	expected := []Construct{
+		{Path: "parameters[2]", Reason: "IMAGE_FORMAT is only provided to tests using a cluster profile"},
+		{Path: "parameters[5]", Reason: "CLUSTER_TYPE is only provided to tests using a cluster profile"},
+		{Path: "parameters[6]", Reason: "required parameter TEST_COMMAND has no value"},
+		{Path: "objects[1].spec.containers[1].volumeMounts[1]", Reason: "the cluster profile is only mounted in tests using a cluster profile"},
+		{Path: "objects[2]", Reason: "Service objects have no equivalent in multi-stage tests"},
+	}
+	if diff := cmp.Diff(expected, report.Unconvertible); diff != "" {
+		t.Errorf("unexpected unconvertible constructs: %s", diff)
+	}
+	if report.Converted {
+		t.Error("expected the template not to be converted")
+	}
+}
+
+func TestConvertConcurrent(t *testing.T) {
+	template := loadTemplate(t)
+	pod := templateapi.Template{}
+	if err := yaml.Unmarshal([]byte(`objects:
+- kind: Pod
+  apiVersion: v1
+  spec:
+    volumes:
+    - name: artifacts
+      emptyDir: {}
+    initContainers:
+    - name: prepare
+      image: ${IMAGE_TESTS}
+      command: ["/bin/true"]
+      volumeMounts:
+      - name: artifacts
+        mountPath: /tmp/artifacts
+    containers:
+    - name: e2e
+      image: ${IMAGE_TESTS}
+      command: ["/bin/true"]
+      volumeMounts:
+      - name: artifacts
+        mountPath: /tmp/artifacts
+    - name: conformance
+      image: ${IMAGE_TESTS}
+      command: ["/bin/true"]
+    - name: artifacts
+      image: ${IMAGE_TESTS}
+      command: ["/bin/true"]
+      volumeMounts:
+      - name: artifacts
+        mountPath: /tmp/artifacts
+`), &pod); err != nil {
+		t.Fatalf("failed to decode pod: %v", err)
+	}
+	// the pod replaces the one of the template, a second pod is added
+	template.Objects = []runtime.RawExtension{pod.Objects[0], template.Objects[1]}
+	lookup := func(name string) (string, bool) { return name, true }
+
+	test, report := Convert(template, "aws", api.ResourceRequirements{}, lookup)
+	if test != nil {
+		t.Errorf("expected no test, got %v", test)
+	}
+	expected := []Construct{
+		{Path: "objects[0].spec.volumes[0]", Reason: "the artifacts directory is shared by containers prepare, e2e, every step has its own"},
+		{Path: "objects[0].spec.containers[1]", Reason: "test containers of a pod run concurrently, steps run one after another"},
+		{Path: "objects[1]", Reason: "pods of a template run concurrently, steps run one after another"},
+	}
+	if diff := cmp.Diff(expected, report.Unconvertible); diff != "" {
+		t.Errorf("unexpected unconvertible constructs: %s", diff)
+	}
+}
@@ -0,0 +1,325 @@
+// Package lint detects anti-patterns in ci-operator configuration: setups
+// which pass validation but are likely to cause trouble when jobs run. Some
+// findings can be fixed without a human decision and carry a fix.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/registry"
+)
+
+const (
+	// RuleHeavyTestResources flags tests requesting less memory than
+	// their memory backed volume consumes
+	RuleHeavyTestResources = "heavy-test-resources"
+	// RuleReservedName flags images and tests named like a release
+	RuleReservedName = "reserved-name"
+	// RuleConflictingPromotion flags images promoted to the same tag twice
+	RuleConflictingPromotion = "conflicting-promotion"
+	// RuleLiteralRegistryStep flags literal steps identical to a registry step
+	RuleLiteralRegistryStep = "literal-registry-step"
+)
+
+// Finding is an anti-pattern found in a configuration
+type Finding struct {
+	// Rule identifies the anti-pattern
+	Rule string `json:"rule"`
+	// Path is where the configuration exhibits the anti-pattern
+	Path string `json:"path"`
+	// Message explains the problem
+	Message string `json:"message"`
+	// Fixable is set when the finding can be fixed automatically
+	Fixable bool `json:"fixable,omitempty"`
+	// fix rewrites the configuration to resolve the finding
+	fix func(config *api.ReleaseBuildConfiguration)
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s [%s]", f.Path, f.Message, f.Rule)
+}
+
+// Linter checks configurations for anti-patterns
+type Linter struct {
+	// steps are the registry steps literal steps are compared to
+	steps registry.ReferenceByName
+}
+
+// NewLinter returns a linter comparing literal steps to the registry steps,
+// which may be empty when no registry is available
+func NewLinter(steps registry.ReferenceByName) *Linter {
+	return &Linter{steps: steps}
+}
+
+// Lint returns the anti-patterns found in the configuration
+func (l *Linter) Lint(config *api.ReleaseBuildConfiguration) []Finding {
+	var findings []Finding
+	findings = append(findings, heavyTestResources(config)...)
+	findings = append(findings, reservedNames(config)...)
+	findings = append(findings, conflictingPromotions(config)...)
+	findings = append(findings, l.literalRegistrySteps(config)...)
+	return findings
+}
+
+// Fix applies the fixes of the findings to the configuration and returns how
+// many findings were fixed. Findings must come from linting the same
+// configuration.
+func Fix(config *api.ReleaseBuildConfiguration, findings []Finding) int {
+	var fixed int
+	for _, finding := range findings {
+		if finding.fix != nil {
+			finding.fix(config)
+			fixed++
+		}
+	}
+	return fixed
+}
+
+// heavyTestResources finds container tests whose memory backed volume does
+// not fit into the memory they request, which gets their pods evicted or
+// killed once the volume fills up
+func heavyTestResources(config *api.ReleaseBuildConfiguration) []Finding {
+	var findings []Finding
+	for i, test := range config.Tests {
+		if test.ContainerTestConfiguration == nil || test.ContainerTestConfiguration.MemoryBackedVolume == nil {
+			continue
+		}
+		size, err := resource.ParseQuantity(test.ContainerTestConfiguration.MemoryBackedVolume.Size)
+		if err != nil {
+			// validation reports this
+			continue
+		}
+		requirements := config.Resources.RequirementsForStep(test.As)
+		if fits(requirements.Requests, size) && (requirements.Limits["memory"] == "" || fits(requirements.Limits, size)) {
+			continue
+		}
+		name, volumeSize := test.As, size.String()
+		findings = append(findings, Finding{
+			Rule:    RuleHeavyTestResources,
+			Path:    fmt.Sprintf("tests[%d].container.memory_backed_volume", i),
+			Message: fmt.Sprintf("the memory backed volume of %s does not fit into the memory requested for the test", volumeSize),
+			Fixable: true,
+			fix: func(config *api.ReleaseBuildConfiguration) {
+				if config.Resources == nil {
+					config.Resources = api.ResourceConfiguration{}
+				}
+				requirements := config.Resources[name]
+				if requirements.Requests == nil {
+					requirements.Requests = api.ResourceList{}
+				}
+				requirements.Requests["memory"] = volumeSize
+				if limit := config.Resources.RequirementsForStep(name).Limits["memory"]; limit != "" && !fits(api.ResourceList{"memory": limit}, size) {
+					if requirements.Limits == nil {
+						requirements.Limits = api.ResourceList{}
+					}
+					requirements.Limits["memory"] = volumeSize
+				}
+				config.Resources[name] = requirements
+			},
+		})
+	}
+	return findings
+}
+
+// fits determines whether the memory in the list is at least the size
+func fits(list api.ResourceList, size resource.Quantity) bool {
+	memory, err := resource.ParseQuantity(list["memory"])
+	if err != nil {
+		return false
+	}
+	return memory.Cmp(size) >= 0
+}
+
+// reservedNames finds images and tests named like the releases ci-operator
+// imports, which makes references to them ambiguous
+func reservedNames(config *api.ReleaseBuildConfiguration) []Finding {
+	reserved := sets.NewString(api.LatestReleaseName, api.InitialReleaseName)
+	var findings []Finding
+	collision := func(path, name string) {
+		findings = append(findings, Finding{
+			Rule:    RuleReservedName,
+			Path:    path,
+			Message: fmt.Sprintf("the name %q collides with the %s release", name, name),
+		})
+	}
+	for i, image := range config.Images {
+		if reserved.Has(string(image.To)) {
+			collision(fmt.Sprintf("images[%d].to", i), string(image.To))
+		}
+	}
+	for i, test := range config.Tests {
+		if reserved.Has(test.As) {
+			collision(fmt.Sprintf("tests[%d].as", i), test.As)
+		}
+		forEachLiteralStep(test, func(path string, step *api.LiteralTestStep) {
+			if reserved.Has(step.As) {
+				collision(fmt.Sprintf("tests[%d].%s.as", i, path), step.As)
+			}
+		})
+	}
+	return findings
+}
+
+// conflictingPromotions finds tags which are promoted to more than once in
+// a single configuration, where only one of the promotions takes effect
+func conflictingPromotions(config *api.ReleaseBuildConfiguration) []Finding {
+	promotion := config.PromotionConfiguration
+	if promotion == nil || promotion.Disabled {
+		return nil
+	}
+	var findings []Finding
+
+	built := sets.NewString()
+	for _, image := range config.Images {
+		if !image.Optional {
+			built.Insert(string(image.To))
+		}
+	}
+	built.Delete(promotion.ExcludedImages...)
+	var shadowed []string
+	for dst, src := range promotion.AdditionalImages {
+		if built.Has(dst) && src != dst {
+			shadowed = append(shadowed, dst)
+		}
+	}
+	sort.Strings(shadowed)
+	for _, dst := range shadowed {
+		dst := dst
+		findings = append(findings, Finding{
+			Rule:    RuleConflictingPromotion,
+			Path:    fmt.Sprintf("promotion.additional_images.%s", dst),
+			Message: fmt.Sprintf("the built image %s is promoted to the same tag as %s, which replaces it", dst, promotion.AdditionalImages[dst]),
+			Fixable: true,
+			fix: func(config *api.ReleaseBuildConfiguration) {
+				// excluding the built image keeps the behavior but makes it explicit
+				if !sets.NewString(config.PromotionConfiguration.ExcludedImages...).Has(dst) {
+					config.PromotionConfiguration.ExcludedImages = append(config.PromotionConfiguration.ExcludedImages, dst)
+				}
+			},
+		})
+	}
+
+	seen := map[api.PromotionTarget]string{
+		{Namespace: promotion.Namespace, Name: promotion.Name, Tag: promotion.Tag}: "promotion",
+	}
+	for i, target := range promotion.Targets {
+		if target.Disabled {
+			continue
+		}
+		path := fmt.Sprintf("promotion.targets[%d]", i)
+		if previous, duplicate := seen[target]; duplicate {
+			findings = append(findings, Finding{
+				Rule:    RuleConflictingPromotion,
+				Path:    path,
+				Message: fmt.Sprintf("the target duplicates the one of %s", previous),
+				Fixable: true,
+				fix:     deduplicateTargets,
+			})
+			continue
+		}
+		seen[target] = path
+	}
+	return findings
+}
+
+// deduplicateTargets removes promotion targets which duplicate the default
+// target or an earlier one
+func deduplicateTargets(config *api.ReleaseBuildConfiguration) {
+	promotion := config.PromotionConfiguration
+	seen := map[api.PromotionTarget]bool{
+		{Namespace: promotion.Namespace, Name: promotion.Name, Tag: promotion.Tag}: true,
+	}
+	var targets []api.PromotionTarget
+	for _, target := range promotion.Targets {
+		if !target.Disabled {
+			if seen[target] {
+				continue
+			}
+			seen[target] = true
+		}
+		targets = append(targets, target)
+	}
+	promotion.Targets = targets
+}
+
+// literalRegistrySteps finds literal steps of multi-stage tests which are
+// copies of a registry step and should reference it instead
+func (l *Linter) literalRegistrySteps(config *api.ReleaseBuildConfiguration) []Finding {
+	if len(l.steps) == 0 {
+		return nil
+	}
+	var names []string
+	for name := range l.steps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var findings []Finding
+	for i, test := range config.Tests {
+		forEachLiteralStep(test, func(path string, step *api.LiteralTestStep) {
+			for _, name := range names {
+				if !sameStep(*step, l.steps[name]) {
+					continue
+				}
+				testIdx, name, step := i, name, step
+				findings = append(findings, Finding{
+					Rule:    RuleLiteralRegistryStep,
+					Path:    fmt.Sprintf("tests[%d].%s", i, path),
+					Message: fmt.Sprintf("the literal step duplicates the registry step %s", name),
+					Fixable: true,
+					fix: func(config *api.ReleaseBuildConfiguration) {
+						steps := config.Tests[testIdx].MultiStageTestConfiguration
+						for _, phase := range [][]api.TestStep{steps.Pre, steps.Test, steps.Post} {
+							for j := range phase {
+								if phase[j].LiteralTestStep == step {
+									phase[j] = api.TestStep{Reference: &name}
+								}
+							}
+						}
+					},
+				})
+				return
+			}
+		})
+	}
+	return findings
+}
+
+// sameStep determines whether a literal step is a copy of a registry step,
+// comparing serialized forms so that empty and unset fields are equivalent
+func sameStep(literal, reference api.LiteralTestStep) bool {
+	literal.As = reference.As
+	a, err := json.Marshal(literal)
+	if err != nil {
+		return false
+	}
+	b, err := json.Marshal(reference)
+	if err != nil {
+		return false
+	}
+	return string(a) == string(b)
+}
+
+// forEachLiteralStep calls the callback for the literal steps of a
+// multi-stage test with the path of the step within the test
+func forEachLiteralStep(test api.TestStepConfiguration, callback func(path string, step *api.LiteralTestStep)) {
+	steps := test.MultiStageTestConfiguration
+	if steps == nil {
+		return
+	}
+	for _, phase := range []struct {
+		name  string
+		steps []api.TestStep
+	}{{name: "pre", steps: steps.Pre}, {name: "test", steps: steps.Test}, {name: "post", steps: steps.Post}} {
+		for j, step := range phase.steps {
+			if step.LiteralTestStep != nil {
+				callback(fmt.Sprintf("steps.%s[%d]", phase.name, j), step.LiteralTestStep)
+			}
+		}
+	}
+}
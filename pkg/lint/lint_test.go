@@ -0,0 +1,141 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/registry"
+)
+
+func TestLintAndFix(t *testing.T) {
+	ref := "ipi-install"
+	registrySteps := registry.ReferenceByName{
+		ref: {As: ref, From: "installer", Commands: "install", Resources: api.ResourceRequirements{Requests: api.ResourceList{"cpu": "100m"}}},
+	}
+	var testCases = []struct {
+		name     string
+		config   api.ReleaseBuildConfiguration
+		expected []Finding
+		fixed    api.ReleaseBuildConfiguration
+	}{
+		{
+			name: "no anti-patterns",
+			config: api.ReleaseBuildConfiguration{
+				Images:    []api.ProjectDirectoryImageBuildStepConfiguration{{To: "component"}},
+				Tests:     []api.TestStepConfiguration{{As: "unit", ContainerTestConfiguration: &api.ContainerTestConfiguration{From: "src"}}},
+				Resources: api.ResourceConfiguration{"*": {Requests: api.ResourceList{"cpu": "100m"}}},
+			},
+			fixed: api.ReleaseBuildConfiguration{
+				Images:    []api.ProjectDirectoryImageBuildStepConfiguration{{To: "component"}},
+				Tests:     []api.TestStepConfiguration{{As: "unit", ContainerTestConfiguration: &api.ContainerTestConfiguration{From: "src"}}},
+				Resources: api.ResourceConfiguration{"*": {Requests: api.ResourceList{"cpu": "100m"}}},
+			},
+		},
+		{
+			name: "memory backed volume larger than the memory request",
+			config: api.ReleaseBuildConfiguration{
+				Tests: []api.TestStepConfiguration{{As: "unit", ContainerTestConfiguration: &api.ContainerTestConfiguration{
+					From:               "src",
+					MemoryBackedVolume: &api.MemoryBackedVolume{Size: "4Gi"},
+				}}},
+				Resources: api.ResourceConfiguration{"*": {Requests: api.ResourceList{"memory": "1Gi"}, Limits: api.ResourceList{"memory": "2Gi"}}},
+			},
+			expected: []Finding{{
+				Rule:    RuleHeavyTestResources,
+				Path:    "tests[0].container.memory_backed_volume",
+				Message: "the memory backed volume of 4Gi does not fit into the memory requested for the test",
+				Fixable: true,
+			}},
+			fixed: api.ReleaseBuildConfiguration{
+				Tests: []api.TestStepConfiguration{{As: "unit", ContainerTestConfiguration: &api.ContainerTestConfiguration{
+					From:               "src",
+					MemoryBackedVolume: &api.MemoryBackedVolume{Size: "4Gi"},
+				}}},
+				Resources: api.ResourceConfiguration{
+					"*":    {Requests: api.ResourceList{"memory": "1Gi"}, Limits: api.ResourceList{"memory": "2Gi"}},
+					"unit": {Requests: api.ResourceList{"memory": "4Gi"}, Limits: api.ResourceList{"memory": "4Gi"}},
+				},
+			},
+		},
+		{
+			name: "names colliding with releases",
+			config: api.ReleaseBuildConfiguration{
+				Images: []api.ProjectDirectoryImageBuildStepConfiguration{{To: "latest"}},
+				Tests: []api.TestStepConfiguration{{As: "initial", MultiStageTestConfiguration: &api.MultiStageTestConfiguration{
+					Test: []api.TestStep{{LiteralTestStep: &api.LiteralTestStep{As: "latest"}}},
+				}}},
+			},
+			expected: []Finding{
+				{Rule: RuleReservedName, Path: "images[0].to", Message: `the name "latest" collides with the latest release`},
+				{Rule: RuleReservedName, Path: "tests[0].as", Message: `the name "initial" collides with the initial release`},
+				{Rule: RuleReservedName, Path: "tests[0].steps.test[0].as", Message: `the name "latest" collides with the latest release`},
+			},
+			fixed: api.ReleaseBuildConfiguration{
+				Images: []api.ProjectDirectoryImageBuildStepConfiguration{{To: "latest"}},
+				Tests: []api.TestStepConfiguration{{As: "initial", MultiStageTestConfiguration: &api.MultiStageTestConfiguration{
+					Test: []api.TestStep{{LiteralTestStep: &api.LiteralTestStep{As: "latest"}}},
+				}}},
+			},
+		},
+		{
+			name: "conflicting promotions",
+			config: api.ReleaseBuildConfiguration{
+				Images: []api.ProjectDirectoryImageBuildStepConfiguration{{To: "component"}, {To: "other"}},
+				PromotionConfiguration: &api.PromotionConfiguration{
+					Namespace:        "ocp",
+					Name:             "4.10",
+					AdditionalImages: map[string]string{"component": "src", "other": "other"},
+					Targets:          []api.PromotionTarget{{Namespace: "ocp", Name: "4.10"}, {Namespace: "origin", Name: "4.10"}, {Namespace: "origin", Name: "4.10"}},
+				},
+			},
+			expected: []Finding{
+				{Rule: RuleConflictingPromotion, Path: "promotion.additional_images.component", Message: "the built image component is promoted to the same tag as src, which replaces it", Fixable: true},
+				{Rule: RuleConflictingPromotion, Path: "promotion.targets[0]", Message: "the target duplicates the one of promotion", Fixable: true},
+				{Rule: RuleConflictingPromotion, Path: "promotion.targets[2]", Message: "the target duplicates the one of promotion.targets[1]", Fixable: true},
+			},
+			fixed: api.ReleaseBuildConfiguration{
+				Images: []api.ProjectDirectoryImageBuildStepConfiguration{{To: "component"}, {To: "other"}},
+				PromotionConfiguration: &api.PromotionConfiguration{
+					Namespace:        "ocp",
+					Name:             "4.10",
+					ExcludedImages:   []string{"component"},
+					AdditionalImages: map[string]string{"component": "src", "other": "other"},
+					Targets:          []api.PromotionTarget{{Namespace: "origin", Name: "4.10"}},
+				},
+			},
+		},
+		{
+			name: "literal step duplicating a registry step",
+			config: api.ReleaseBuildConfiguration{
+				Tests: []api.TestStepConfiguration{{As: "e2e", MultiStageTestConfiguration: &api.MultiStageTestConfiguration{
+					Pre:  []api.TestStep{{LiteralTestStep: &api.LiteralTestStep{As: "install", From: "installer", Commands: "install", Resources: api.ResourceRequirements{Requests: api.ResourceList{"cpu": "100m"}}}}},
+					Test: []api.TestStep{{LiteralTestStep: &api.LiteralTestStep{As: "test", From: "src", Commands: "make e2e", Resources: api.ResourceRequirements{Requests: api.ResourceList{"cpu": "100m"}}}}},
+				}}},
+			},
+			expected: []Finding{
+				{Rule: RuleLiteralRegistryStep, Path: "tests[0].steps.pre[0]", Message: "the literal step duplicates the registry step ipi-install", Fixable: true},
+			},
+			fixed: api.ReleaseBuildConfiguration{
+				Tests: []api.TestStepConfiguration{{As: "e2e", MultiStageTestConfiguration: &api.MultiStageTestConfiguration{
+					Pre:  []api.TestStep{{Reference: &ref}},
+					Test: []api.TestStep{{LiteralTestStep: &api.LiteralTestStep{As: "test", From: "src", Commands: "make e2e", Resources: api.ResourceRequirements{Requests: api.ResourceList{"cpu": "100m"}}}}},
+				}}},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			findings := NewLinter(registrySteps).Lint(&testCase.config)
+			if diff := cmp.Diff(testCase.expected, findings, cmpopts.IgnoreUnexported(Finding{})); diff != "" {
+				t.Errorf("unexpected findings: %s", diff)
+			}
+			Fix(&testCase.config, findings)
+			if diff := cmp.Diff(testCase.fixed, testCase.config); diff != "" {
+				t.Errorf("unexpected fixed configuration: %s", diff)
+			}
+		})
+	}
+}
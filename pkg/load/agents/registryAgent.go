@@ -1,6 +1,8 @@
 package agents
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -21,14 +23,25 @@ type RegistryAgent interface {
 	ResolveConfig(config api.ReleaseBuildConfiguration) (api.ReleaseBuildConfiguration, error)
 	GetRegistryComponents() (registry.ReferenceByName, registry.ChainByName, registry.WorkflowByName, map[string]string, api.RegistryMetadata)
 	GetGeneration() int
+	// GetRevision identifies the content of the loaded registry and returns
+	// its generation
+	GetRevision() (string, int)
+	// ResolveConfigAtRevision resolves the configuration like ResolveConfig,
+	// returning the revision and the generation of the registry it was
+	// resolved against
+	ResolveConfigAtRevision(config api.ReleaseBuildConfiguration) (api.ReleaseBuildConfiguration, string, int, error)
 	registry.Resolver
 }
 
 type registryAgent struct {
-	lock          *sync.RWMutex
+	lock *sync.RWMutex
+	// reloadLock serializes reloads, so an older state of the registry can
+	// never replace a newer one
+	reloadLock    sync.Mutex
 	resolver      registry.Resolver
 	registryPath  string
 	generation    int
+	revision      string
 	errorMetrics  *prometheus.CounterVec
 	flatRegistry  bool
 	references    registry.ReferenceByName
@@ -46,8 +59,17 @@ var registryReloadTimeMetric = prometheus.NewHistogram(
 	},
 )
 
+var registryReloadsMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "configresolver_registry_reloads_total",
+		Help: "registry reloads by result",
+	},
+	[]string{"result"},
+)
+
 func init() {
 	prometheus.MustRegister(registryReloadTimeMetric)
+	prometheus.MustRegister(registryReloadsMetric)
 }
 
 type RegistryAgentOptions struct {
@@ -114,38 +136,80 @@ func (a *registryAgent) GetGeneration() int {
 	return a.generation
 }
 
+func (a *registryAgent) GetRevision() (string, int) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.revision, a.generation
+}
+
+func (a *registryAgent) ResolveConfigAtRevision(config api.ReleaseBuildConfiguration) (api.ReleaseBuildConfiguration, string, int, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	resolved, err := registry.ResolveConfig(a.resolver, config)
+	return resolved, a.revision, a.generation, err
+}
+
+// revisionOf digests the components of the registry, which identifies its
+// content independently of when and where it was loaded
+func revisionOf(components ...interface{}) (string, error) {
+	hash := sha256.New()
+	for _, component := range components {
+		raw, err := json.Marshal(component)
+		if err != nil {
+			return "", fmt.Errorf("could not marshal registry: %w", err)
+		}
+		hash.Write(raw)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
 func (a *registryAgent) GetRegistryComponents() (registry.ReferenceByName, registry.ChainByName, registry.WorkflowByName, map[string]string, api.RegistryMetadata) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
 	return a.references, a.chains, a.workflows, a.documentation, a.metadata
 }
 
+// loadRegistry reads the registry from disk and replaces the one in memory
+// with it. The registry is loaded and validated without holding the lock, so
+// resolving configurations is not blocked meanwhile, and an invalid registry
+// never replaces a valid one.
 func (a *registryAgent) loadRegistry() error {
 	logrus.Debug("Reloading registry")
-	duration, err := func() (time.Duration, error) {
-		a.lock.Lock()
-		defer a.lock.Unlock()
-		startTime := time.Now()
-		references, chains, workflows, documentation, metadata, observers, err := load.Registry(a.registryPath, a.flatRegistry)
-		if err != nil {
-			a.recordError("failed to load ci-operator registry")
-			return time.Duration(0), fmt.Errorf("failed to load ci-operator registry (%w)", err)
-		}
-		a.references = references
-		a.chains = chains
-		a.workflows = workflows
-		a.documentation = documentation
-		a.metadata = metadata
-		a.resolver = registry.NewResolver(references, chains, workflows, observers)
-		a.generation++
-		return time.Since(startTime), nil
-	}()
+	a.reloadLock.Lock()
+	defer a.reloadLock.Unlock()
+	startTime := time.Now()
+	references, chains, workflows, documentation, metadata, observers, deprecations, err := load.Registry(a.registryPath, a.flatRegistry)
+	if err != nil {
+		a.recordError("failed to load ci-operator registry")
+		registryReloadsMetric.WithLabelValues("failure").Inc()
+		return fmt.Errorf("failed to load ci-operator registry (%w)", err)
+	}
+	resolver := registry.NewResolver(references, chains, workflows, observers, registry.WithDeprecations(deprecations, false))
+	revision, err := revisionOf(references, chains, workflows, observers, deprecations)
 	if err != nil {
+		a.recordError("failed to determine registry revision")
+		registryReloadsMetric.WithLabelValues("failure").Inc()
 		return err
 	}
-	configReloadTimeMetric.Observe(duration.Seconds())
+	duration := time.Since(startTime)
+	a.lock.Lock()
+	a.references = references
+	a.chains = chains
+	a.workflows = workflows
+	a.documentation = documentation
+	a.metadata = metadata
+	a.resolver = resolver
+	a.revision = revision
+	a.generation++
+	a.lock.Unlock()
+	registryReloadTimeMetric.Observe(duration.Seconds())
+	registryReloadsMetric.WithLabelValues("success").Inc()
 	logrus.WithField("duration", duration).Info("Registry reloaded")
 	return nil
 }
 
 func (a *registryAgent) Resolve(name string, config api.MultiStageTestConfiguration) (api.MultiStageTestConfigurationLiteral, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
 	return a.resolver.Resolve(name, config)
 }
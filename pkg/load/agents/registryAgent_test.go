@@ -0,0 +1,99 @@
+package agents
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func reloads(t *testing.T, result string) float64 {
+	metric := &dto.Metric{}
+	if err := registryReloadsMetric.WithLabelValues(result).Write(metric); err != nil {
+		t.Fatalf("could not read metric: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestLoadRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry")
+	if err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("could not write file: %v", err)
+		}
+	}
+	write("step-commands.sh", "true\n")
+	write("step-ref.yaml", `ref:
+  as: step
+  from: cli
+  commands: step-commands.sh
+  resources:
+    requests:
+      cpu: 100m
+  documentation: The first version.
+`)
+	agent := &registryAgent{
+		lock:         &sync.RWMutex{},
+		registryPath: dir,
+		flatRegistry: true,
+		errorMetrics: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_registry_agent_errors_total"}, []string{"error"}),
+	}
+	successes, failures := reloads(t, "success"), reloads(t, "failure")
+	if err := agent.loadRegistry(); err != nil {
+		t.Fatalf("could not load registry: %v", err)
+	}
+	documentation := func() string {
+		_, _, _, docs, _ := agent.GetRegistryComponents()
+		return docs["step"]
+	}
+	if documentation() != "The first version." || agent.GetGeneration() != 1 {
+		t.Fatalf("unexpected state after loading: %q, generation %d", documentation(), agent.GetGeneration())
+	}
+
+	// a chain referencing a step which does not exist fails validation
+	write("chain-chain.yaml", `chain:
+  as: chain
+  steps:
+  - ref: missing
+`)
+	if err := agent.loadRegistry(); err == nil {
+		t.Fatal("expected loading an invalid registry to fail")
+	}
+	if documentation() != "The first version." || agent.GetGeneration() != 1 {
+		t.Errorf("invalid registry replaced the loaded one: %q, generation %d", documentation(), agent.GetGeneration())
+	}
+
+	if err := os.Remove(filepath.Join(dir, "chain-chain.yaml")); err != nil {
+		t.Fatalf("could not remove file: %v", err)
+	}
+	write("step-ref.yaml", `ref:
+  as: step
+  from: cli
+  commands: step-commands.sh
+  resources:
+    requests:
+      cpu: 100m
+  documentation: The second version.
+`)
+	if err := agent.loadRegistry(); err != nil {
+		t.Fatalf("could not reload registry: %v", err)
+	}
+	if documentation() != "The second version." || agent.GetGeneration() != 2 {
+		t.Errorf("unexpected state after reloading: %q, generation %d", documentation(), agent.GetGeneration())
+	}
+
+	if diff := reloads(t, "success") - successes; diff != 2 {
+		t.Errorf("expected 2 successful reloads to be counted, got %v", diff)
+	}
+	if diff := reloads(t, "failure") - failures; diff != 1 {
+		t.Errorf("expected 1 failed reload to be counted, got %v", diff)
+	}
+}
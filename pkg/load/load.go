@@ -21,6 +21,8 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/jsonschema"
+	"github.com/openshift/ci-tools/pkg/migration"
 	"github.com/openshift/ci-tools/pkg/registry"
 	"github.com/openshift/ci-tools/pkg/results"
 	"github.com/openshift/ci-tools/pkg/util/gzip"
@@ -117,7 +119,8 @@ func fromPath(path string) (filenameToConfig, error) {
 }
 
 func Config(path, unresolvedPath, registryPath string, info *ResolverInfo) (*api.ReleaseBuildConfiguration, error) {
-	// Load the standard configuration path, env, or configresolver (in that order of priority)
+	// Load the standard configuration path, env, or configresolver (in that order of priority).
+	// When a registry is given, configurations are resolved against it instead of the configresolver.
 	var raw string
 
 	configSpecEnv, configSpecSet := os.LookupEnv("CONFIG_SPEC")
@@ -140,31 +143,54 @@ func Config(path, unresolvedPath, registryPath string, info *ResolverInfo) (*api
 		if err != nil {
 			return nil, fmt.Errorf("--unresolved-config error: %w", err)
 		}
+		if registryPath != "" {
+			// resolved below against the local registry instead
+			raw = string(data)
+			break
+		}
 		configSpec, err := literalConfigFromResolver(data, info.Address)
-		err = results.ForReason("config_resolver_literal").ForError(err)
+		err = results.ForReason(results.ReasonConfigResolverLiteral).ForError(err)
 		return configSpec, err
 	case unresolvedConfigSet:
+		if registryPath != "" {
+			raw = unresolvedConfigEnv
+			break
+		}
 		configSpec, err := literalConfigFromResolver([]byte(unresolvedConfigEnv), info.Address)
-		err = results.ForReason("config_resolver_literal").ForError(err)
+		err = results.ForReason(results.ReasonConfigResolverLiteral).ForError(err)
 		return configSpec, err
+	case registryPath != "":
+		return nil, errors.New("--registry requires a configuration to resolve: use --config or --unresolved-config")
 	default:
 		configSpec, err := configFromResolver(info)
-		err = results.ForReason("config_resolver").ForError(err)
+		err = results.ForReason(results.ReasonConfigResolver).ForError(err)
 		return configSpec, err
 	}
 	configSpec := api.ReleaseBuildConfiguration{}
-	if err := yaml.UnmarshalStrict([]byte(raw), &configSpec); err != nil {
+	migrated, _, err := migration.Default.Migrate([]byte(raw))
+	if err == nil {
+		if err = yaml.UnmarshalStrict(migrated, &configSpec); err != nil {
+			// the schema locates every offending field, unlike the decoder
+			if violations := jsonschema.ValidateDocument(jsonschema.CIOperatorConfig, migrated); violations != nil {
+				err = violations
+			}
+		}
+	}
+	if err != nil {
 		if len(path) > 0 {
 			return nil, fmt.Errorf("invalid configuration in file %s: %w\nvalue:\n%s", path, err, raw)
 		}
 		return nil, fmt.Errorf("invalid configuration: %w\nvalue:\n%s", err, raw)
 	}
+	if configSpec.Tests, err = api.ExpandTestMatrices(configSpec.Tests); err != nil {
+		return nil, fmt.Errorf("could not expand test matrices: %w", err)
+	}
 	if registryPath != "" {
-		refs, chains, workflows, _, _, observers, err := Registry(registryPath, false)
+		refs, chains, workflows, _, _, observers, deprecations, err := Registry(registryPath, false)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load registry: %w", err)
 		}
-		configSpec, err = registry.ResolveConfig(registry.NewResolver(refs, chains, workflows, observers), configSpec)
+		configSpec, err = registry.ResolveConfig(registry.NewResolver(refs, chains, workflows, observers, registry.WithDeprecations(deprecations, false)), configSpec)
 		if err != nil {
 			return nil, fmt.Errorf("failed to resolve configuration: %w", err)
 		}
@@ -220,7 +246,11 @@ func configFromResolver(info *ResolverInfo) (*api.ReleaseBuildConfiguration, err
 func literalConfigFromResolver(raw []byte, address string) (*api.ReleaseBuildConfiguration, error) {
 	// check that the user has sent us something reasonable
 	unresolvedConfig := &api.ReleaseBuildConfiguration{}
-	if err := yaml.UnmarshalStrict(raw, unresolvedConfig); err != nil {
+	migrated, _, err := migration.Default.Migrate(raw)
+	if err == nil {
+		err = yaml.UnmarshalStrict(migrated, unresolvedConfig)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal unresolved config: invalid configuration: %w, raw: %v", err, string(raw))
 	}
 	encoded, err := json.Marshal(unresolvedConfig)
@@ -254,12 +284,15 @@ func literalConfigFromResolver(raw []byte, address string) (*api.ReleaseBuildCon
 
 // Registry takes the path to a registry config directory and returns the full set of references, chains,
 // and workflows that the registry's Resolver needs to resolve a user's MultiStageTestConfiguration
-func Registry(root string, flat bool) (registry.ReferenceByName, registry.ChainByName, registry.WorkflowByName, map[string]string, api.RegistryMetadata, registry.ObserverByName, error) {
+func Registry(root string, flat bool) (registry.ReferenceByName, registry.ChainByName, registry.WorkflowByName, map[string]string, api.RegistryMetadata, registry.ObserverByName, registry.DeprecationByName, error) {
 	references := registry.ReferenceByName{}
 	chains := registry.ChainByName{}
 	workflows := registry.WorkflowByName{}
+	// parents holds the workflow each workflow extends, if any
+	parents := map[string]string{}
 	observers := registry.ObserverByName{}
 	documentation := map[string]string{}
+	deprecations := registry.DeprecationByName{}
 	metadata := api.RegistryMetadata{}
 	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if info != nil && strings.HasPrefix(info.Name(), "..") {
@@ -293,7 +326,7 @@ func Registry(root string, flat bool) (registry.ReferenceByName, registry.ChainB
 				}
 			}
 			if strings.HasSuffix(path, RefSuffix) {
-				name, doc, ref, err := loadReference(raw, dir, prefix, flat)
+				name, doc, deprecation, ref, err := loadReference(raw, dir, prefix, flat)
 				if err != nil {
 					return fmt.Errorf("failed to load registry file %s: %w", path, err)
 				}
@@ -305,6 +338,9 @@ func Registry(root string, flat bool) (registry.ReferenceByName, registry.ChainB
 				}
 				references[name] = ref
 				documentation[name] = doc
+				if deprecation != nil {
+					deprecations["step/"+name] = *deprecation
+				}
 			} else if strings.HasSuffix(path, ChainSuffix) {
 				var chain api.RegistryChainConfig
 				err := yaml.UnmarshalStrict(raw, &chain)
@@ -319,9 +355,13 @@ func Registry(root string, flat bool) (registry.ReferenceByName, registry.ChainB
 				}
 				documentation[chain.Chain.As] = chain.Chain.Documentation
 				chain.Chain.Documentation = ""
+				if chain.Chain.Deprecated != nil {
+					deprecations["chain/"+chain.Chain.As] = *chain.Chain.Deprecated
+					chain.Chain.Deprecated = nil
+				}
 				chains[chain.Chain.As] = chain.Chain
 			} else if strings.HasSuffix(path, WorkflowSuffix) {
-				name, doc, workflow, err := loadWorkflow(raw)
+				name, doc, extends, deprecation, workflow, err := loadWorkflow(raw)
 				if err != nil {
 					return fmt.Errorf("failed to load registry file %s: %w", path, err)
 				}
@@ -332,7 +372,13 @@ func Registry(root string, flat bool) (registry.ReferenceByName, registry.ChainB
 					return fmt.Errorf("filename %s does not match name of workflow; filename should be %s", filepath.Base(path), fmt.Sprint(prefix, WorkflowSuffix))
 				}
 				workflows[name] = workflow
+				if extends != "" {
+					parents[name] = extends
+				}
 				documentation[name] = doc
+				if deprecation != nil {
+					deprecations["workflow/"+name] = *deprecation
+				}
 			} else if strings.HasSuffix(path, MetadataSuffix) {
 				var data api.RegistryInfo
 				err := json.Unmarshal(raw, &data)
@@ -376,41 +422,44 @@ func Registry(root string, flat bool) (registry.ReferenceByName, registry.ChainB
 		return nil
 	})
 	if err != nil {
-		return nil, nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, err
+	}
+	if workflows, err = registry.ExtendWorkflows(workflows, parents); err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, err
 	}
 	// create graph to verify that there are no cycles
 	if _, err = registry.NewGraph(references, chains, workflows); err != nil {
-		return nil, nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, err
 	}
 	err = registry.Validate(references, chains, workflows, observers)
-	return references, chains, workflows, documentation, metadata, observers, err
+	return references, chains, workflows, documentation, metadata, observers, deprecations, err
 }
 
-func loadReference(bytes []byte, baseDir, prefix string, flat bool) (string, string, api.LiteralTestStep, error) {
+func loadReference(bytes []byte, baseDir, prefix string, flat bool) (string, string, *api.Deprecation, api.LiteralTestStep, error) {
 	step := api.RegistryReferenceConfig{}
 	err := yaml.UnmarshalStrict(bytes, &step)
 	if err != nil {
-		return "", "", api.LiteralTestStep{}, err
+		return "", "", nil, api.LiteralTestStep{}, err
 	}
 	if !flat && step.Reference.Commands != fmt.Sprintf("%s%s", prefix, CommandsSuffix) {
-		return "", "", api.LiteralTestStep{}, fmt.Errorf("reference %s has invalid command file path; command should be set to %s", step.Reference.As, fmt.Sprintf("%s%s", prefix, CommandsSuffix))
+		return "", "", nil, api.LiteralTestStep{}, fmt.Errorf("reference %s has invalid command file path; command should be set to %s", step.Reference.As, fmt.Sprintf("%s%s", prefix, CommandsSuffix))
 	}
 	command, err := gzip.ReadFileMaybeGZIP(filepath.Join(baseDir, step.Reference.Commands))
 	if err != nil {
-		return "", "", api.LiteralTestStep{}, err
+		return "", "", nil, api.LiteralTestStep{}, err
 	}
 	step.Reference.Commands = string(command)
-	return step.Reference.As, step.Reference.Documentation, step.Reference.LiteralTestStep, nil
+	return step.Reference.As, step.Reference.Documentation, step.Reference.Deprecated, step.Reference.LiteralTestStep, nil
 }
 
-func loadWorkflow(bytes []byte) (string, string, api.MultiStageTestConfiguration, error) {
+func loadWorkflow(bytes []byte) (string, string, string, *api.Deprecation, api.MultiStageTestConfiguration, error) {
 	workflow := api.RegistryWorkflowConfig{}
 	err := yaml.UnmarshalStrict(bytes, &workflow)
 	if err != nil {
-		return "", "", api.MultiStageTestConfiguration{}, err
+		return "", "", "", nil, api.MultiStageTestConfiguration{}, err
 	}
 	if workflow.Workflow.Steps.Workflow != nil {
-		return "", "", api.MultiStageTestConfiguration{}, errors.New("workflows cannot contain other workflows")
+		return "", "", "", nil, api.MultiStageTestConfiguration{}, errors.New("workflows cannot contain other workflows, use extends to inherit from a workflow")
 	}
-	return workflow.Workflow.As, workflow.Workflow.Documentation, workflow.Workflow.Steps, nil
+	return workflow.Workflow.As, workflow.Workflow.Documentation, workflow.Workflow.Extends, workflow.Workflow.Deprecated, workflow.Workflow.Steps, nil
 }
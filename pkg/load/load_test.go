@@ -562,6 +562,13 @@ func TestConfig(t *testing.T) {
 			expected:      nil,
 			expectedError: true,
 		},
+		{
+			name:          "unsupported schema version results in error",
+			config:        "apiVersion: ci-operator.openshift.io/v0\n" + rawConfig,
+			asEnv:         true,
+			expected:      nil,
+			expectedError: true,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -681,6 +688,122 @@ func TestConfigFromResolver(t *testing.T) {
 	}
 }
 
+func TestConfigWithRegistry(t *testing.T) {
+	const registryDir = "../../test/multistage-registry/registry"
+	unresolved := api.ReleaseBuildConfiguration{
+		Metadata: api.Metadata{Org: "openshift", Repo: "hyperkube", Branch: "master"},
+		Tests: []api.TestStepConfiguration{{
+			As:                          "e2e",
+			MultiStageTestConfiguration: &api.MultiStageTestConfiguration{ClusterProfile: api.ClusterProfileAWS, Workflow: &[]string{"ipi"}[0]},
+		}},
+	}
+	raw, err := yaml.Marshal(unresolved)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, raw, 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	refs, chains, workflows, _, _, observers, deprecations, err := Registry(registryDir, false)
+	if err != nil {
+		t.Fatalf("failed to load registry: %v", err)
+	}
+	expected, err := registry.ResolveConfig(registry.NewResolver(refs, chains, workflows, observers, registry.WithDeprecations(deprecations, false)), unresolved)
+	if err != nil {
+		t.Fatalf("failed to resolve config: %v", err)
+	}
+
+	var testCases = []struct {
+		name           string
+		unresolvedPath string
+		unresolvedEnv  string
+		registryPath   string
+		expected       *api.ReleaseBuildConfiguration
+		expectedError  bool
+	}{
+		{
+			name:           "unresolved config file is resolved against the registry",
+			unresolvedPath: path,
+			registryPath:   registryDir,
+			expected:       &expected,
+		},
+		{
+			name:          "unresolved config from env is resolved against the registry",
+			unresolvedEnv: string(raw),
+			registryPath:  registryDir,
+			expected:      &expected,
+		},
+		{
+			name:           "missing registry fails",
+			unresolvedPath: path,
+			registryPath:   filepath.Join(dir, "missing"),
+			expectedError:  true,
+		},
+		{
+			name:          "registry without a config fails",
+			registryPath:  registryDir,
+			expectedError: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			for name, value := range map[string]string{"CONFIG_SPEC": "", "UNRESOLVED_CONFIG": testCase.unresolvedEnv} {
+				previous, set := os.LookupEnv(name)
+				if value == "" {
+					os.Unsetenv(name)
+				} else {
+					os.Setenv(name, value)
+				}
+				defer func(name string) {
+					if set {
+						os.Setenv(name, previous)
+					} else {
+						os.Unsetenv(name)
+					}
+				}(name)
+			}
+			config, err := Config("", testCase.unresolvedPath, testCase.registryPath, &ResolverInfo{})
+			if err == nil && testCase.expectedError {
+				t.Error("expected an error, but got none")
+			}
+			if err != nil && !testCase.expectedError {
+				t.Errorf("expected no error, but got one: %v", err)
+			}
+			if diff := cmp.Diff(testCase.expected, config); diff != "" {
+				t.Errorf("didn't get correct config: %s", diff)
+			}
+		})
+	}
+}
+
+func TestLocateParameterProblems(t *testing.T) {
+	const registryDir = "../../test/multistage-registry/registry"
+	problems := []registry.ParameterProblem{
+		{Component: "step/ipi-install-install", Parameter: "TEST_PARAMETER"},
+		{Component: "chain/ipi-install-with-parameter", Parameter: "TEST_PARAMETER"},
+		{Component: "workflow/ipi", Parameter: "TEST_PARAMETER"},
+		{Component: "step/missing", Parameter: "TEST_PARAMETER"},
+	}
+	if err := LocateParameterProblems(registryDir, problems); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []registry.ParameterProblem{
+		{Component: "step/ipi-install-install", Parameter: "TEST_PARAMETER", File: filepath.Join(registryDir, "ipi/install/install/ipi-install-install-ref.yaml"), Line: 10},
+		{Component: "chain/ipi-install-with-parameter", Parameter: "TEST_PARAMETER", File: filepath.Join(registryDir, "ipi/install/with-parameter/ipi-install-with-parameter-chain.yaml"), Line: 6},
+		{Component: "workflow/ipi", Parameter: "TEST_PARAMETER", File: filepath.Join(registryDir, "ipi/ipi-workflow.yaml")},
+		{Component: "step/missing", Parameter: "TEST_PARAMETER"},
+	}
+	if diff := cmp.Diff(expected, problems); diff != "" {
+		t.Errorf("unexpected problems: %s", diff)
+	}
+}
+
 func TestRegistry(t *testing.T) {
 	defaultStr := "test parameter default"
 	var (
@@ -848,7 +971,7 @@ func TestRegistry(t *testing.T) {
 	)
 
 	for _, testCase := range testCases {
-		references, chains, workflows, _, _, observers, err := Registry(testCase.registryDir, testCase.flatRegistry)
+		references, chains, workflows, _, _, observers, _, err := Registry(testCase.registryDir, testCase.flatRegistry)
 		if err == nil && testCase.expectedError == true {
 			t.Errorf("%s: got no error when error was expected", testCase.name)
 		}
@@ -893,7 +1016,7 @@ func TestRegistry(t *testing.T) {
 	if err := ioutil.WriteFile(filepath.Join(path, deprovisionGatherRef), fileData, 0664); err != nil {
 		t.Fatalf("failed to populate temp reference file: %v", err)
 	}
-	_, _, _, _, _, _, err = Registry(temp, false)
+	_, _, _, _, _, _, _, err = Registry(temp, false)
 	if err == nil {
 		t.Error("got no error when expecting error on incorrect reference name")
 	}
@@ -0,0 +1,90 @@
+package load
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/openshift/ci-tools/pkg/registry"
+	"github.com/openshift/ci-tools/pkg/util/gzip"
+)
+
+// LocateParameterProblems fills in the file and line of the declaration of
+// the parameter of each problem, looking for the files of the components in
+// the registry at root
+func LocateParameterProblems(root string, problems []registry.ParameterProblem) error {
+	files := map[string]string{}
+	if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && strings.HasPrefix(info.Name(), "..") {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			files[info.Name()] = path
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	suffixes := map[string]string{"step": RefSuffix, "chain": ChainSuffix, "workflow": WorkflowSuffix}
+	for i, problem := range problems {
+		kind := strings.SplitN(problem.Component, "/", 2)
+		if len(kind) != 2 {
+			continue
+		}
+		path, ok := files[kind[1]+suffixes[kind[0]]]
+		if !ok {
+			continue
+		}
+		problems[i].File = path
+		raw, err := gzip.ReadFileMaybeGZIP(path)
+		if err != nil {
+			return err
+		}
+		var node yamlv3.Node
+		if err := yamlv3.Unmarshal(raw, &node); err != nil {
+			continue
+		}
+		problems[i].Line = parameterLine(&node, problem.Parameter)
+	}
+	return nil
+}
+
+// parameterLine finds the line declaring the parameter in the `env` of a
+// registry component, either a list of parameters or a map of their values
+func parameterLine(node *yamlv3.Node, parameter string) int {
+	if node.Kind == yamlv3.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value != "env" {
+				continue
+			}
+			switch value.Kind {
+			case yamlv3.MappingNode:
+				for j := 0; j+1 < len(value.Content); j += 2 {
+					if value.Content[j].Value == parameter {
+						return value.Content[j].Line
+					}
+				}
+			case yamlv3.SequenceNode:
+				for _, item := range value.Content {
+					for j := 0; j+1 < len(item.Content); j += 2 {
+						if item.Content[j].Value == "name" && item.Content[j+1].Value == parameter {
+							return item.Content[j+1].Line
+						}
+					}
+				}
+			}
+		}
+	}
+	for _, child := range node.Content {
+		if line := parameterLine(child, parameter); line != 0 {
+			return line
+		}
+	}
+	return 0
+}
@@ -0,0 +1,154 @@
+// Package migration upgrades ci-operator configuration written in older
+// versions of the schema, so that fields can be renamed or removed without
+// breaking the configurations which still use them.
+package migration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// firstAPIVersion is the version of configurations which do not record one
+const firstAPIVersion = "ci-operator.openshift.io/v1"
+
+// Migration upgrades configuration from one version of the schema to the next
+type Migration struct {
+	// From is the version the migration applies to
+	From string
+	// To is the version the migration produces
+	To string
+	// Description tells users what changed between the versions
+	Description string
+	// Migrate rewrites the configuration, given as decoded JSON
+	Migrate func(config map[string]interface{}) error
+}
+
+// Migrations are all the migrations between versions of the schema, in
+// order. A schema change adds a migration from the previous version to a
+// new one and bumps api.ConfigAPIVersion to it.
+var Migrations []Migration
+
+// Migrator upgrades configuration to the current version of the schema
+type Migrator struct {
+	first      string
+	current    string
+	migrations map[string]Migration
+}
+
+// NewMigrator returns a migrator upgrading configuration from the first
+// version to the current one using the migrations
+func NewMigrator(first, current string, migrations []Migration) *Migrator {
+	byVersion := map[string]Migration{}
+	for _, migration := range migrations {
+		byVersion[migration.From] = migration
+	}
+	return &Migrator{first: first, current: current, migrations: byVersion}
+}
+
+// Default migrates configuration to api.ConfigAPIVersion
+var Default = NewMigrator(firstAPIVersion, api.ConfigAPIVersion, Migrations)
+
+// Version returns the version of the schema the raw configuration is written in
+func (m *Migrator) Version(raw []byte) (string, error) {
+	var versioned struct {
+		APIVersion string `json:"apiVersion"`
+	}
+	if err := yaml.Unmarshal(raw, &versioned); err != nil {
+		return "", fmt.Errorf("failed to determine the version of the configuration: %w", err)
+	}
+	if versioned.APIVersion == "" {
+		return m.first, nil
+	}
+	return versioned.APIVersion, nil
+}
+
+// Migrate upgrades the raw YAML or JSON configuration to the current version
+// of the schema. Configuration which is already current is returned as it is.
+// Otherwise, the migrated configuration is returned as JSON with its version
+// recorded, along with the descriptions of the migrations applied.
+func (m *Migrator) Migrate(raw []byte) ([]byte, []string, error) {
+	version, err := m.Version(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version == m.current {
+		return raw, nil, nil
+	}
+	if _, known := m.migrations[version]; !known && version != m.first {
+		return nil, nil, fmt.Errorf("unsupported configuration version %s, the current version is %s", version, m.current)
+	}
+	config, err := decode(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	var applied []string
+	for version != m.current {
+		migration, ok := m.migrations[version]
+		if !ok {
+			return nil, nil, fmt.Errorf("no migration from configuration version %s to %s", version, m.current)
+		}
+		if err := migration.Migrate(config); err != nil {
+			return nil, nil, fmt.Errorf("failed to migrate the configuration from version %s to %s: %w", migration.From, migration.To, err)
+		}
+		applied = append(applied, fmt.Sprintf("%s -> %s: %s", migration.From, migration.To, migration.Description))
+		version = migration.To
+	}
+	config["apiVersion"] = m.current
+	migrated, err := json.Marshal(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode the migrated configuration: %w", err)
+	}
+	return migrated, applied, nil
+}
+
+// Upgrade migrates a configuration file to the current version of the
+// schema and records the version in it. The upgraded file is returned as
+// YAML, or nil when the file already records the current version.
+func (m *Migrator) Upgrade(raw []byte) ([]byte, []string, error) {
+	var versioned struct {
+		APIVersion string `json:"apiVersion"`
+	}
+	if err := yaml.Unmarshal(raw, &versioned); err != nil {
+		return nil, nil, fmt.Errorf("failed to determine the version of the configuration: %w", err)
+	}
+	if versioned.APIVersion == m.current {
+		return nil, nil, nil
+	}
+	migrated, applied, err := m.Migrate(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err := decode(migrated)
+	if err != nil {
+		return nil, nil, err
+	}
+	config["apiVersion"] = m.current
+	upgraded, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode the upgraded configuration: %w", err)
+	}
+	return upgraded, applied, nil
+}
+
+// decode reads YAML or JSON configuration, keeping numbers as they are
+func decode(raw []byte) (map[string]interface{}, error) {
+	data, err := yaml.YAMLToJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the configuration: %w", err)
+	}
+	config := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to decode the configuration: %w", err)
+	}
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	return config, nil
+}
@@ -0,0 +1,119 @@
+package migration
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMigrate(t *testing.T) {
+	migrator := NewMigrator("v1", "v3", []Migration{
+		{
+			From:        "v1",
+			To:          "v2",
+			Description: "rename binary_build_commands to build_commands",
+			Migrate: func(config map[string]interface{}) error {
+				if value, set := config["binary_build_commands"]; set {
+					config["build_commands"] = value
+					delete(config, "binary_build_commands")
+				}
+				return nil
+			},
+		},
+		{
+			From:        "v2",
+			To:          "v3",
+			Description: "remove canonical_go_repository",
+			Migrate: func(config map[string]interface{}) error {
+				if _, set := config["canonical_go_repository"]; set {
+					return errors.New("canonical_go_repository must be removed by hand")
+				}
+				return nil
+			},
+		},
+	})
+	var testCases = []struct {
+		name        string
+		raw         string
+		expected    string
+		expectedErr string
+		applied     []string
+	}{
+		{
+			name:     "current configuration is not changed",
+			raw:      "apiVersion: v3\nbuild_commands: make\n",
+			expected: "apiVersion: v3\nbuild_commands: make\n",
+		},
+		{
+			name:     "configuration without a version is migrated from the first one",
+			raw:      "binary_build_commands: make\nreplicas: 10000000\n",
+			expected: `{"apiVersion":"v3","build_commands":"make","replicas":10000000}`,
+			applied:  []string{"v1 -> v2: rename binary_build_commands to build_commands", "v2 -> v3: remove canonical_go_repository"},
+		},
+		{
+			name:     "configuration is migrated from an intermediate version",
+			raw:      "apiVersion: v2\nbinary_build_commands: make\n",
+			expected: `{"apiVersion":"v3","binary_build_commands":"make"}`,
+			applied:  []string{"v2 -> v3: remove canonical_go_repository"},
+		},
+		{
+			name:        "failed migration",
+			raw:         "canonical_go_repository: github.com/org/repo\n",
+			expectedErr: "failed to migrate the configuration from version v2 to v3: canonical_go_repository must be removed by hand",
+		},
+		{
+			name:        "unknown version",
+			raw:         "apiVersion: v4\n",
+			expectedErr: "unsupported configuration version v4, the current version is v3",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			migrated, applied, err := migrator.Migrate([]byte(testCase.raw))
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, string(migrated)); diff != "" {
+				t.Errorf("unexpected migrated configuration: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.applied, applied); diff != "" {
+				t.Errorf("unexpected migrations: %s", diff)
+			}
+		})
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	migrator := NewMigrator("v1", "v1", nil)
+	var testCases = []struct {
+		name     string
+		raw      string
+		expected string
+	}{
+		{
+			name: "file recording the current version is not changed",
+			raw:  "apiVersion: v1\nbuild_root: {}\n",
+		},
+		{
+			name:     "version is recorded in files without one",
+			raw:      "build_root:\n  project_image:\n    dockerfile_path: Dockerfile\n",
+			expected: "apiVersion: v1\nbuild_root:\n  project_image:\n    dockerfile_path: Dockerfile\n",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			upgraded, _, err := migrator.Upgrade([]byte(testCase.raw))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testCase.expected, string(upgraded)); diff != "" {
+				t.Errorf("unexpected upgraded file: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+// Package notifier posts concise summaries of failed steps to a Slack webhook
+// or a generic HTTP endpoint, while a job is still running.
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+const (
+	// snippetLines is the number of trailing lines of the error of a step
+	// included in a notification
+	snippetLines = 10
+	// snippetLength caps the size of the snippet, errors may carry build logs
+	snippetLength = 1000
+)
+
+// Options holds the configuration of the endpoints failures are posted to
+type Options struct {
+	slackWebhookFile string
+	webhookURL       string
+	interval         time.Duration
+	jobURL           string
+}
+
+// Bind adds flags for the options
+func (o *Options) Bind(flag *flag.FlagSet) {
+	flag.StringVar(&o.slackWebhookFile, "notify-slack-webhook-file", "", "File holding the URL of a Slack incoming webhook to post failed steps to.")
+	flag.StringVar(&o.webhookURL, "notify-webhook-url", "", "URL of an HTTP endpoint to post failed steps to as JSON.")
+	flag.DurationVar(&o.interval, "notify-interval", time.Minute, "Minimum interval between notifications for the job; failures in between are posted together.")
+	flag.StringVar(&o.jobURL, "notify-job-url", "", "URL of the job, linked from notifications.")
+}
+
+// Censor removes credentials from the output of steps before it is posted
+type Censor func(content []byte) []byte
+
+// Notifier returns a notifier for the job, or nil when no endpoint is
+// configured. Links are included in every notification, in addition to the
+// URL of the job.
+func (o *Options) Notifier(job string, censor Censor, links ...Link) (*Notifier, error) {
+	var senders []Sender
+	if o.slackWebhookFile != "" {
+		raw, err := ioutil.ReadFile(o.slackWebhookFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Slack webhook file %q: %w", o.slackWebhookFile, err)
+		}
+		senders = append(senders, &slackSender{client: &http.Client{Timeout: time.Minute}, url: strings.TrimSpace(string(raw))})
+	}
+	if o.webhookURL != "" {
+		senders = append(senders, &webhookSender{client: &http.Client{Timeout: time.Minute}, url: o.webhookURL})
+	}
+	if len(senders) == 0 {
+		return nil, nil
+	}
+	if o.interval <= 0 {
+		return nil, fmt.Errorf("--notify-interval must be positive, got %s", o.interval)
+	}
+	if o.jobURL != "" {
+		links = append([]Link{{Title: "Job", URL: o.jobURL}}, links...)
+	}
+	return NewNotifier(senders, job, links, o.interval, censor), nil
+}
+
+// Link points to more details about a failure
+type Link struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Failure summarizes the failure of a step
+type Failure struct {
+	Job     string `json:"job"`
+	Step    string `json:"step"`
+	Reason  string `json:"reason"`
+	Snippet string `json:"snippet,omitempty"`
+	Links   []Link `json:"links,omitempty"`
+}
+
+// Sender posts failures to an endpoint
+type Sender interface {
+	Send(failures []Failure) error
+}
+
+// Notifier queues the failures of the steps of a job and posts them at most
+// once per interval. A step failing for the same reason more than once is
+// only posted the first time.
+type Notifier struct {
+	senders  []Sender
+	job      string
+	links    []Link
+	interval time.Duration
+	censor   Censor
+
+	lock sync.Mutex
+	// pending holds the failures queued for every sender
+	pending  [][]Failure
+	notified sets.String
+}
+
+// NewNotifier creates a notifier for failures of the job. Errors of steps are
+// censored before they are queued, as endpoints are outside the cluster.
+func NewNotifier(senders []Sender, job string, links []Link, interval time.Duration, censor Censor) *Notifier {
+	return &Notifier{
+		senders:  senders,
+		job:      job,
+		links:    links,
+		interval: interval,
+		censor:   censor,
+		pending:  make([][]Failure, len(senders)),
+		notified: sets.NewString(),
+	}
+}
+
+// StepFinished queues a notification when the step failed
+func (n *Notifier) StepFinished(step string, err error) {
+	if err == nil {
+		return
+	}
+	failure := Failure{
+		Job:     n.job,
+		Step:    step,
+		Reason:  results.FullReason(err),
+		Snippet: snippet(string(n.censor([]byte(err.Error())))),
+		Links:   n.links,
+	}
+	key := failure.Step + ":" + failure.Reason
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.notified.Has(key) {
+		return
+	}
+	n.notified.Insert(key)
+	for i := range n.pending {
+		n.pending[i] = append(n.pending[i], failure)
+	}
+}
+
+// Run posts the queued failures periodically until the context is
+// cancelled, after which the remaining failures are posted
+func (n *Notifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := n.Flush(); err != nil {
+				log.Printf("warning: Failed to post failure notifications: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := n.Flush(); err != nil {
+				log.Printf("warning: Failed to post failure notifications, will retry: %v", err)
+			}
+		}
+	}
+}
+
+// Flush posts the queued failures together. Failures which could not be
+// posted to an endpoint stay queued for it.
+func (n *Notifier) Flush() error {
+	var errs []error
+	for i, sender := range n.senders {
+		n.lock.Lock()
+		pending := n.pending[i]
+		n.pending[i] = nil
+		n.lock.Unlock()
+		if len(pending) == 0 {
+			continue
+		}
+		if err := sender.Send(pending); err != nil {
+			errs = append(errs, fmt.Errorf("failed to post %d failures: %w", len(pending), err))
+			n.lock.Lock()
+			n.pending[i] = append(pending, n.pending[i]...)
+			n.lock.Unlock()
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// snippet keeps the trailing lines of the message, where the cause of a
+// failure usually is. Truncation never splits a character.
+func snippet(message string) string {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	if len(lines) > snippetLines {
+		lines = lines[len(lines)-snippetLines:]
+	}
+	snippet := strings.Join(lines, "\n")
+	if len(snippet) > snippetLength {
+		start := len(snippet) - snippetLength
+		for start < len(snippet) && !utf8.RuneStart(snippet[start]) {
+			start++
+		}
+		snippet = "..." + snippet[start:]
+	}
+	return snippet
+}
+
+// slackSender posts failures to a Slack incoming webhook
+type slackSender struct {
+	client *http.Client
+	url    string
+}
+
+func (s *slackSender) Send(failures []Failure) error {
+	var text []string
+	for _, failure := range failures {
+		lines := []string{fmt.Sprintf(":x: Job *%s* failed at step *%s* (`%s`)", failure.Job, failure.Step, failure.Reason)}
+		if failure.Snippet != "" {
+			lines = append(lines, fmt.Sprintf("```\n%s\n```", failure.Snippet))
+		}
+		var links []string
+		for _, link := range failure.Links {
+			links = append(links, fmt.Sprintf("<%s|%s>", link.URL, link.Title))
+		}
+		if len(links) > 0 {
+			lines = append(lines, strings.Join(links, " | "))
+		}
+		text = append(text, strings.Join(lines, "\n"))
+	}
+	if err := slack.PostWebhookCustomHTTP(s.url, s.client, &slack.WebhookMessage{Text: strings.Join(text, "\n\n")}); err != nil {
+		return fmt.Errorf("could not post to Slack: %w", err)
+	}
+	return nil
+}
+
+// webhookSender posts failures as JSON to an HTTP endpoint
+type webhookSender struct {
+	client *http.Client
+	url    string
+}
+
+type webhookRequest struct {
+	Failures []Failure `json:"failures"`
+}
+
+func (s *webhookSender) Send(failures []Failure) error {
+	data, err := json.Marshal(webhookRequest{Failures: failures})
+	if err != nil {
+		return fmt.Errorf("could not marshal failures: %w", err)
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not post to %s: %w", s.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("posting to %s returned %d: %s", s.url, resp.StatusCode, body)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+type fakeSender struct {
+	sent [][]Failure
+	err  error
+}
+
+func (s *fakeSender) Send(failures []Failure) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, failures)
+	return nil
+}
+
+func TestNotifier(t *testing.T) {
+	links := []Link{{Title: "Job", URL: "https://prow/job"}}
+	pushFailed := results.ForReason(results.ReasonBuildingProjectImage).ForError(results.ForReason(results.ReasonPushFailed).ForError(errors.New("could not push")))
+	failure := func(step, reason, snippet string) Failure {
+		return Failure{Job: "job", Step: step, Reason: reason, Snippet: snippet, Links: links}
+	}
+	type finished struct {
+		step string
+		err  error
+	}
+	var testCases = []struct {
+		name     string
+		batches  [][]finished
+		failing  bool
+		expected [][]Failure
+	}{
+		{
+			name:    "successful steps are not posted",
+			batches: [][]finished{{{step: "src"}, {step: "unit"}}},
+		},
+		{
+			name:     "failures within an interval are posted together",
+			batches:  [][]finished{{{step: "src"}, {step: "unit", err: errors.New("tests failed")}, {step: "images", err: pushFailed}}},
+			expected: [][]Failure{{failure("unit", "unknown", "tests failed"), failure("images", "building_project_image:push_failed", "could not push")}},
+		},
+		{
+			name: "a step failing again for the same reason is posted once",
+			batches: [][]finished{
+				{{step: "images", err: pushFailed}},
+				{{step: "images", err: pushFailed}, {step: "images", err: errors.New("other")}},
+			},
+			expected: [][]Failure{
+				{failure("images", "building_project_image:push_failed", "could not push")},
+				{failure("images", "unknown", "other")},
+			},
+		},
+		{
+			name:     "errors are censored",
+			batches:  [][]finished{{{step: "unit", err: errors.New("login with hunter2 failed")}}},
+			expected: [][]Failure{{failure("unit", "unknown", "login with ******* failed")}},
+		},
+		{
+			name:     "failures that could not be posted are retried",
+			batches:  [][]finished{{{step: "unit", err: errors.New("tests failed")}}, {}},
+			failing:  true,
+			expected: [][]Failure{{failure("unit", "unknown", "tests failed")}},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			sender := &fakeSender{}
+			if testCase.failing {
+				sender.err = errors.New("injected failure")
+			}
+			notifier := NewNotifier([]Sender{sender}, "job", links, time.Minute, func(content []byte) []byte {
+				return bytes.ReplaceAll(content, []byte("hunter2"), []byte("*******"))
+			})
+			for i, batch := range testCase.batches {
+				for _, step := range batch {
+					notifier.StepFinished(step.step, step.err)
+				}
+				err := notifier.Flush()
+				if testCase.failing && i == 0 {
+					if err == nil {
+						t.Fatal("expected an error, got none")
+					}
+					sender.err = nil
+				} else if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if diff := cmp.Diff(testCase.expected, sender.sent); diff != "" {
+				t.Errorf("unexpected notifications: %s", diff)
+			}
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	var lines []string
+	for i := 0; i < 15; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	if diff := cmp.Diff(strings.Join(lines[5:], "\n"), snippet(strings.Join(lines, "\n")+"\n")); diff != "" {
+		t.Errorf("unexpected snippet: %s", diff)
+	}
+	if actual := snippet(strings.Repeat("a", 2*snippetLength)); len(actual) != snippetLength+3 || !strings.HasPrefix(actual, "...") {
+		t.Errorf("expected the snippet to be truncated to %d characters, got %d", snippetLength, len(actual))
+	}
+	if actual := snippet(strings.Repeat("é", snippetLength) + "."); !utf8.ValidString(actual) || len(actual) != snippetLength+2 {
+		t.Errorf("expected the snippet to be truncated on a character boundary, got %d bytes", len(actual))
+	}
+}
+
+func TestSenders(t *testing.T) {
+	failures := []Failure{{Job: "job", Step: "unit", Reason: "unknown", Snippet: "tests failed", Links: []Link{{Title: "Job", URL: "https://prow/job"}}}}
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read body: %v", err)
+		}
+		received = append(received, string(raw))
+	}))
+	defer server.Close()
+
+	if err := (&webhookSender{client: server.Client(), url: server.URL}).Send(failures); err != nil {
+		t.Fatalf("failed to post to the webhook: %v", err)
+	}
+	if err := (&slackSender{client: server.Client(), url: server.URL}).Send(failures); err != nil {
+		t.Fatalf("failed to post to Slack: %v", err)
+	}
+	var slackMessage struct {
+		Text string `json:"text"`
+	}
+	if len(received) != 2 {
+		t.Fatalf("expected two requests, got %d", len(received))
+	}
+	if err := json.Unmarshal([]byte(received[1]), &slackMessage); err != nil {
+		t.Fatalf("failed to decode Slack message: %v", err)
+	}
+	expected := []string{
+		`{"failures":[{"job":"job","step":"unit","reason":"unknown","snippet":"tests failed","links":[{"title":"Job","url":"https://prow/job"}]}]}`,
+		":x: Job *job* failed at step *unit* (`unknown`)\n```\ntests failed\n```\n<https://prow/job|Job>",
+	}
+	if diff := cmp.Diff(expected, []string{received[0], slackMessage.Text}); diff != "" {
+		t.Errorf("unexpected requests: %s", diff)
+	}
+}
@@ -293,7 +293,7 @@ func generatePodSpecMultiStage(info *ProwgenInfo, test *cioperatorapi.TestStepCo
 	if profile == "" {
 		return podSpec
 	}
-	podSpec.Volumes = append(podSpec.Volumes, generateClusterProfileVolume(profile, profile.ClusterType()))
+	podSpec.Volumes = append(podSpec.Volumes, generateClusterProfileVolume(profile))
 	clusterProfilePath := fmt.Sprintf("/usr/local/%s-cluster-profile", test.As)
 	container := &podSpec.Containers[0]
 	container.Args = append(container.Args, fmt.Sprintf("--secret-dir=%s", clusterProfilePath))
@@ -342,7 +342,7 @@ func generatePodSpecTemplate(info *ProwgenInfo, release string, test *cioperator
 	clusterProfilePath := fmt.Sprintf("/usr/local/%s-cluster-profile", test.As)
 	templatePath := fmt.Sprintf("/usr/local/%s", test.As)
 	podSpec := generateCiOperatorPodSpec(info, test.Secrets, []string{test.As})
-	clusterProfileVolume := generateClusterProfileVolume(clusterProfile, clusterType)
+	clusterProfileVolume := generateClusterProfileVolume(clusterProfile)
 	if len(template) > 0 {
 		podSpec.Volumes = append(podSpec.Volumes, generateConfigMapVolume("job-definition", []string{fmt.Sprintf("prow-job-%s", template)}))
 	}
@@ -445,12 +445,7 @@ func generatePeriodicForTest(name string, info *ProwgenInfo, podSpec *corev1.Pod
 	}
 }
 
-func generateClusterProfileVolume(profile cioperatorapi.ClusterProfile, clusterType string) corev1.Volume {
-	// CPaaS AWS is `aws` for all other purposes except for determining
-	// which Secret should be provided to jobs
-	if profile == cioperatorapi.ClusterProfileAWSCPaaS {
-		clusterType = string(profile)
-	}
+func generateClusterProfileVolume(profile cioperatorapi.ClusterProfile) corev1.Volume {
 	ret := corev1.Volume{
 		Name: "cluster-profile",
 		VolumeSource: corev1.VolumeSource{
@@ -458,7 +453,7 @@ func generateClusterProfileVolume(profile cioperatorapi.ClusterProfile, clusterT
 				Sources: []corev1.VolumeProjection{{
 					Secret: &corev1.SecretProjection{
 						LocalObjectReference: corev1.LocalObjectReference{
-							Name: fmt.Sprintf("cluster-secrets-%s", clusterType),
+							Name: profile.Secret(),
 						},
 					}},
 				},
@@ -0,0 +1,131 @@
+package quay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// Host is the registry images are pushed to when promoting to Quay
+	Host = "quay.io"
+	// DefaultAPIEndpoint is the address of the Quay API
+	DefaultAPIEndpoint = "https://quay.io/api/v1"
+)
+
+// Role is the level of access to a repository
+type Role string
+
+const (
+	RoleRead  Role = "read"
+	RoleWrite Role = "write"
+	RoleAdmin Role = "admin"
+)
+
+// Roles are the roles which can be granted on repositories
+var Roles = []Role{RoleRead, RoleWrite, RoleAdmin}
+
+// Client manages repositories of Quay organizations
+type Client interface {
+	// EnsureRepository creates the repository in the organization unless
+	// it exists already
+	EnsureRepository(organization, repository string, public bool) error
+	// SetRobotPermission grants the robot account of the organization the
+	// role on the repository
+	SetRobotPermission(organization, repository, robot string, role Role) error
+}
+
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+type client struct {
+	endpoint string
+	token    []byte
+	client   HTTPClient
+}
+
+// NewClient creates a client using the OAuth token of an application of the
+// organizations to talk to the API at the endpoint
+func NewClient(endpoint string, token []byte, httpClient HTTPClient) Client {
+	return &client{endpoint: endpoint, token: token, client: httpClient}
+}
+
+func (c *client) EnsureRepository(organization, repository string, public bool) error {
+	path := fmt.Sprintf("/repository/%s/%s", url.PathEscape(organization), url.PathEscape(repository))
+	status, _, err := c.request(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+	default:
+		return fmt.Errorf("could not get repository %s/%s: server responded with %d", organization, repository, status)
+	}
+	visibility := "private"
+	if public {
+		visibility = "public"
+	}
+	status, body, err := c.request(http.MethodPost, "/repository", map[string]string{
+		"namespace":   organization,
+		"repository":  repository,
+		"visibility":  visibility,
+		"description": "",
+		"repo_kind":   "image",
+	})
+	if err != nil {
+		return err
+	}
+	if status != http.StatusCreated && status != http.StatusOK {
+		return fmt.Errorf("could not create repository %s/%s: server responded with %d: %s", organization, repository, status, body)
+	}
+	return nil
+}
+
+func (c *client) SetRobotPermission(organization, repository, robot string, role Role) error {
+	path := fmt.Sprintf("/repository/%s/%s/permissions/user/%s", url.PathEscape(organization), url.PathEscape(repository), url.PathEscape(organization+"+"+robot))
+	status, body, err := c.request(http.MethodPut, path, map[string]string{"role": string(role)})
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("could not grant %s access on repository %s/%s to robot %s: server responded with %d: %s", role, organization, repository, robot, status, body)
+	}
+	return nil
+}
+
+func (c *client) request(method, path string, payload interface{}) (int, []byte, error) {
+	var body io.Reader
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, c.endpoint+path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+string(c.token))
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to request %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return resp.StatusCode, data, nil
+}
@@ -0,0 +1,90 @@
+package quay
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type request struct {
+	Method  string
+	Path    string
+	Payload map[string]string
+}
+
+func TestClient(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		existing    bool
+		call        func(Client) error
+		expected    []request
+		expectedErr string
+	}{
+		{
+			name:     "existing repository is not created",
+			existing: true,
+			call:     func(c Client) error { return c.EnsureRepository("org", "repo", false) },
+			expected: []request{{Method: http.MethodGet, Path: "/repository/org/repo"}},
+		},
+		{
+			name: "missing repository is created",
+			call: func(c Client) error { return c.EnsureRepository("org", "repo", true) },
+			expected: []request{
+				{Method: http.MethodGet, Path: "/repository/org/repo"},
+				{Method: http.MethodPost, Path: "/repository", Payload: map[string]string{
+					"namespace": "org", "repository": "repo", "visibility": "public", "description": "", "repo_kind": "image",
+				}},
+			},
+		},
+		{
+			name:     "robot permission",
+			existing: true,
+			call:     func(c Client) error { return c.SetRobotPermission("org", "repo", "ci", RoleWrite) },
+			expected: []request{{Method: http.MethodPut, Path: "/repository/org/repo/permissions/user/org+ci", Payload: map[string]string{"role": "write"}}},
+		},
+		{
+			name:        "robot permission for missing repository",
+			call:        func(c Client) error { return c.SetRobotPermission("org", "repo", "ci", RoleRead) },
+			expected:    []request{{Method: http.MethodPut, Path: "/repository/org/repo/permissions/user/org+ci", Payload: map[string]string{"role": "read"}}},
+			expectedErr: "could not grant read access on repository org/repo to robot ci: server responded with 404: ",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var actual []request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer token" {
+					t.Error("did not get correct authorization header")
+				}
+				req := request{Method: r.Method, Path: r.URL.Path}
+				if r.Body != nil && r.Method != http.MethodGet {
+					if err := json.NewDecoder(r.Body).Decode(&req.Payload); err != nil {
+						t.Errorf("failed to decode payload: %v", err)
+					}
+				}
+				actual = append(actual, req)
+				switch {
+				case r.Method == http.MethodPost:
+					w.WriteHeader(http.StatusCreated)
+				case !testCase.existing:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+			err := testCase.call(NewClient(server.URL, []byte("token"), &http.Client{}))
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected requests: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// ParameterProblem is a questionable declaration of a parameter in the
+// registry, which does not prevent configurations from being resolved
+type ParameterProblem struct {
+	// Component declares the parameter, e.g. `chain/ipi-install`
+	Component string
+	// Parameter is the name of the parameter
+	Parameter string
+	Message   string
+	// File and Line locate the declaration, if known
+	File string
+	Line int
+}
+
+func (p ParameterProblem) String() string {
+	switch {
+	case p.File == "":
+		return p.Message
+	case p.Line == 0:
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	default:
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	}
+}
+
+// parameterSetting is the value a component sets for a parameter
+type parameterSetting struct {
+	component string
+	value     string
+}
+
+// CheckParameters finds parameters which steps declare but never use, and
+// parameters set to conflicting values at multiple levels of a chain or
+// workflow, where all but the outermost value are shadowed.
+func CheckParameters(stepsByName ReferenceByName, chainsByName ChainByName, workflowsByName WorkflowByName) []ParameterProblem {
+	var ret []ParameterProblem
+	for name, step := range stepsByName {
+		for _, e := range step.Environment {
+			if !regexp.MustCompile(`\b` + regexp.QuoteMeta(e.Name) + `\b`).MatchString(step.Commands) {
+				ret = append(ret, ParameterProblem{
+					Component: "step/" + name,
+					Parameter: e.Name,
+					Message:   fmt.Sprintf("step/%s: parameter %s is declared but never used by the commands", name, e.Name),
+				})
+			}
+		}
+	}
+	seen := sets.NewString()
+	report := func(settings []parameterSetting, parameter string) {
+		for _, s := range settings[1:] {
+			if s.value == settings[0].value {
+				continue
+			}
+			message := fmt.Sprintf("%s: value %q of parameter %s is shadowed by %q in %s", s.component, s.value, parameter, settings[0].value, settings[0].component)
+			if seen.Has(message) {
+				continue
+			}
+			seen.Insert(message)
+			ret = append(ret, ParameterProblem{Component: s.component, Parameter: parameter, Message: message})
+		}
+	}
+	for name := range chainsByName {
+		checkShadowed(stepsByName, chainsByName, []api.TestStep{{Chain: &name}}, nil, report)
+	}
+	for name, workflow := range workflowsByName {
+		var levels []parameterLevel
+		if len(workflow.Environment) != 0 {
+			levels = append(levels, parameterLevel{component: "workflow/" + name, env: workflow.Environment})
+		}
+		for _, steps := range [][]api.TestStep{workflow.Pre, workflow.Test, workflow.Post} {
+			checkShadowed(stepsByName, chainsByName, steps, levels, report)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Message < ret[j].Message
+	})
+	return ret
+}
+
+// parameterLevel holds the values a component sets for parameters
+type parameterLevel struct {
+	component string
+	env       map[string]string
+}
+
+// checkShadowed walks the steps, reporting the settings of every parameter
+// of every step from the outermost level to the step itself
+func checkShadowed(stepsByName ReferenceByName, chainsByName ChainByName, steps []api.TestStep, levels []parameterLevel, report func([]parameterSetting, string)) {
+	for _, step := range steps {
+		if step.Chain != nil {
+			chain, ok := chainsByName[*step.Chain]
+			if !ok {
+				continue
+			}
+			env := map[string]string{}
+			for _, e := range chain.Environment {
+				if e.Default != nil {
+					env[e.Name] = *e.Default
+				}
+			}
+			nested := append(append([]parameterLevel(nil), levels...), parameterLevel{component: "chain/" + *step.Chain, env: env})
+			checkShadowed(stepsByName, chainsByName, chain.Steps, nested, report)
+			continue
+		}
+		var literal api.LiteralTestStep
+		var component string
+		if step.Reference != nil {
+			var ok bool
+			if literal, ok = stepsByName[*step.Reference]; !ok {
+				continue
+			}
+			component = "step/" + *step.Reference
+		} else if step.LiteralTestStep != nil {
+			literal = *step.LiteralTestStep
+			component = "step/" + literal.As
+		}
+		for _, e := range literal.Environment {
+			var settings []parameterSetting
+			for _, level := range levels {
+				if value, ok := level.env[e.Name]; ok {
+					settings = append(settings, parameterSetting{component: level.component, value: value})
+				}
+			}
+			if e.Default != nil {
+				settings = append(settings, parameterSetting{component: component, value: *e.Default})
+			}
+			if len(settings) > 1 {
+				report(settings, e.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestCheckParameters(t *testing.T) {
+	str := func(s string) *string { return &s }
+	step, chain, nested := "step", "chain", "nested"
+	for _, testCase := range []struct {
+		name      string
+		steps     ReferenceByName
+		chains    ChainByName
+		workflows WorkflowByName
+		expected  []ParameterProblem
+	}{
+		{
+			name: "parameters used by the commands",
+			steps: ReferenceByName{
+				"step": {As: "step", Commands: "echo ${PARAM} $OTHER_PARAM", Environment: []api.StepParameter{{Name: "PARAM"}, {Name: "OTHER_PARAM"}}},
+			},
+		},
+		{
+			name: "unused parameter",
+			steps: ReferenceByName{
+				"step": {As: "step", Commands: "echo $PARAM_SUFFIX", Environment: []api.StepParameter{{Name: "PARAM"}}},
+			},
+			expected: []ParameterProblem{{
+				Component: "step/step",
+				Parameter: "PARAM",
+				Message:   "step/step: parameter PARAM is declared but never used by the commands",
+			}},
+		},
+		{
+			name: "same value at multiple levels",
+			steps: ReferenceByName{
+				"step": {As: "step", Commands: "$PARAM", Environment: []api.StepParameter{{Name: "PARAM", Default: str("value")}}},
+			},
+			chains: ChainByName{
+				"chain": {As: "chain", Steps: []api.TestStep{{Reference: &step}}, Environment: []api.StepParameter{{Name: "PARAM", Default: str("value")}}},
+			},
+			workflows: WorkflowByName{
+				"workflow": {Pre: []api.TestStep{{Chain: &chain}}, Environment: api.TestEnvironment{"PARAM": "value"}},
+			},
+		},
+		{
+			name: "conflicting values are shadowed by the outermost one",
+			steps: ReferenceByName{
+				"step": {As: "step", Commands: "$PARAM", Environment: []api.StepParameter{{Name: "PARAM", Default: str("step")}}},
+			},
+			chains: ChainByName{
+				"chain":  {As: "chain", Steps: []api.TestStep{{Reference: &step}}},
+				"nested": {As: "nested", Steps: []api.TestStep{{Chain: &chain}}, Environment: []api.StepParameter{{Name: "PARAM", Default: str("nested")}}},
+			},
+			workflows: WorkflowByName{
+				"workflow": {Test: []api.TestStep{{Chain: &nested}}, Environment: api.TestEnvironment{"PARAM": "workflow"}},
+			},
+			expected: []ParameterProblem{
+				{
+					Component: "chain/nested",
+					Parameter: "PARAM",
+					Message:   `chain/nested: value "nested" of parameter PARAM is shadowed by "workflow" in workflow/workflow`,
+				},
+				{
+					Component: "step/step",
+					Parameter: "PARAM",
+					Message:   `step/step: value "step" of parameter PARAM is shadowed by "nested" in chain/nested`,
+				},
+				{
+					Component: "step/step",
+					Parameter: "PARAM",
+					Message:   `step/step: value "step" of parameter PARAM is shadowed by "workflow" in workflow/workflow`,
+				},
+			},
+		},
+		{
+			name: "parameters without defaults are not shadowed",
+			steps: ReferenceByName{
+				"step": {As: "step", Commands: "$PARAM", Environment: []api.StepParameter{{Name: "PARAM"}}},
+			},
+			chains: ChainByName{
+				"chain": {As: "chain", Steps: []api.TestStep{{Reference: &step}}, Environment: []api.StepParameter{{Name: "PARAM"}}},
+			},
+			workflows: WorkflowByName{
+				"workflow": {Pre: []api.TestStep{{Chain: &chain}}, Environment: api.TestEnvironment{"PARAM": "workflow"}},
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := CheckParameters(testCase.steps, testCase.chains, testCase.workflows)
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected problems: %s", diff)
+			}
+		})
+	}
+}
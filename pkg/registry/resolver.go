@@ -2,6 +2,9 @@ package registry
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
 
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -18,11 +21,28 @@ type ChainByName map[string]api.RegistryChain
 type WorkflowByName map[string]api.MultiStageTestConfiguration
 type ObserverByName map[string]api.Observer
 
+// DeprecationByName holds the deprecation notices of registry components,
+// keyed by the kind and the name of the component, e.g. `chain/ipi-install`
+type DeprecationByName map[string]api.Deprecation
+
+// Option configures a resolver
+type Option func(*registry)
+
+// WithDeprecations makes the resolver report references to deprecated
+// registry components. Such references are logged as warnings and recorded
+// in the resolved test or, in strict mode, fail the resolution.
+func WithDeprecations(deprecations DeprecationByName, strict bool) Option {
+	return func(r *registry) {
+		r.deprecations = deprecations
+		r.strictDeprecations = strict
+	}
+}
+
 // Validate verifies the internal consistency of steps, chains, and workflows.
 // A superset of this validation is performed later when actual test
 // configurations are resolved.
 func Validate(stepsByName ReferenceByName, chainsByName ChainByName, workflowsByName WorkflowByName, observersByName ObserverByName) error {
-	reg := registry{stepsByName, chainsByName, workflowsByName, observersByName}
+	reg := registry{stepsByName: stepsByName, chainsByName: chainsByName, workflowsByName: workflowsByName, observersByName: observersByName}
 	var ret []error
 	for k := range chainsByName {
 		if _, err := reg.process([]api.TestStep{{Chain: &k}}, sets.NewString(), stackForChain()); err != nil {
@@ -49,48 +69,65 @@ type registry struct {
 	chainsByName    ChainByName
 	workflowsByName WorkflowByName
 	observersByName ObserverByName
+
+	deprecations       DeprecationByName
+	strictDeprecations bool
 }
 
-func NewResolver(stepsByName ReferenceByName, chainsByName ChainByName, workflowsByName WorkflowByName, observersByName ObserverByName) Resolver {
-	return &registry{
+func NewResolver(stepsByName ReferenceByName, chainsByName ChainByName, workflowsByName WorkflowByName, observersByName ObserverByName, opts ...Option) Resolver {
+	r := &registry{
 		stepsByName:     stepsByName,
 		chainsByName:    chainsByName,
 		workflowsByName: workflowsByName,
 		observersByName: observersByName,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// checkDeprecated reports a reference to a deprecated registry component,
+// returning an error only in strict mode and recording it in the stack
+// otherwise
+func (r *registry) checkDeprecated(component string, stack stack) error {
+	deprecation, ok := r.deprecations[component]
+	if !ok {
+		return nil
+	}
+	message := deprecation.Message
+	if deprecation.Replacement != "" {
+		message = fmt.Sprintf("%s, use %s instead", message, deprecation.Replacement)
+	}
+	err := stack.errorf("%s is deprecated: %s", component, message)
+	if r.strictDeprecations {
+		return err
+	}
+	if stack.deprecations != nil {
+		stack.deprecations[component] = deprecation
+	}
+	logrus.WithFields(logrus.Fields{
+		"component":   component,
+		"replacement": deprecation.Replacement,
+	}).Warn(err.Error())
+	return nil
 }
 
 func (r *registry) Resolve(name string, config api.MultiStageTestConfiguration) (api.MultiStageTestConfigurationLiteral, error) {
 	var resolveErrors []error
+	deprecations := map[string]api.Deprecation{}
 	if config.Workflow != nil {
 		workflow, ok := r.workflowsByName[*config.Workflow]
 		if !ok {
 			return api.MultiStageTestConfigurationLiteral{}, fmt.Errorf("no workflow named %s", *config.Workflow)
 		}
-		if config.ClusterProfile == "" {
-			config.ClusterProfile = workflow.ClusterProfile
-		}
-		if config.Pre == nil {
-			config.Pre = workflow.Pre
-		}
-		if config.Test == nil {
-			config.Test = workflow.Test
-		}
-		if config.Post == nil {
-			config.Post = workflow.Post
-		}
-		mergeEnvironments(&config.Environment, workflow.Environment)
-		mergeDependencies(&config.Dependencies, workflow.Dependencies)
-		if l, err := mergeLeases(workflow.Leases, config.Leases); err != nil {
+		workflowStack := stackForTest(name, nil, nil)
+		workflowStack.deprecations = deprecations
+		if err := r.checkDeprecated("workflow/"+*config.Workflow, workflowStack); err != nil {
 			resolveErrors = append(resolveErrors, err)
-		} else {
-			config.Leases = l
-		}
-		if config.AllowSkipOnSuccess == nil {
-			config.AllowSkipOnSuccess = workflow.AllowSkipOnSuccess
 		}
-		if config.AllowBestEffortPostSteps == nil {
-			config.AllowBestEffortPostSteps = workflow.AllowBestEffortPostSteps
+		if err := mergeWorkflow(&config, workflow); err != nil {
+			resolveErrors = append(resolveErrors, err)
 		}
 	}
 	expandedFlow := api.MultiStageTestConfigurationLiteral{
@@ -98,8 +135,17 @@ func (r *registry) Resolve(name string, config api.MultiStageTestConfiguration)
 		AllowSkipOnSuccess:       config.AllowSkipOnSuccess,
 		AllowBestEffortPostSteps: config.AllowBestEffortPostSteps,
 		Leases:                   config.Leases,
+		Comparison:               config.Comparison,
+		SharedDir:                config.SharedDir,
+		DataDir:                  config.DataDir,
+		ClusterProvisioning:      config.ClusterProvisioning,
+		ClusterClaim:             config.ClusterClaim,
+		ClusterProfileOverlay:    config.ClusterProfileOverlay,
+		Gather:                   config.Gather,
+		DisruptionRetries:        config.DisruptionRetries,
 	}
 	stack := stackForTest(name, config.Environment, config.Dependencies)
+	stack.deprecations = deprecations
 	if config.Workflow != nil {
 		stack.push(stackRecordForTest("workflow/"+*config.Workflow, nil, nil))
 	}
@@ -132,12 +178,128 @@ func (r *registry) Resolve(name string, config api.MultiStageTestConfiguration)
 		observers = append(observers, observer)
 	}
 	expandedFlow.Observers = observers
+	if len(deprecations) > 0 {
+		expandedFlow.Deprecations = deprecations
+	}
 	if resolveErrors != nil {
 		return api.MultiStageTestConfigurationLiteral{}, utilerrors.NewAggregate(resolveErrors)
 	}
 	return expandedFlow, nil
 }
 
+// mergeWorkflow fills in the configuration the test or workflow in `config`
+// does not set itself from the `workflow` it uses:
+//   - the cluster profile, the pre, test and post phases and all options are
+//     taken from the workflow unless set in `config`
+//   - the environment and the dependencies are merged, with the values in
+//     `config` taking precedence
+//   - the leases are joined, which fails if both configure a lease for the
+//     same variable differently
+func mergeWorkflow(config *api.MultiStageTestConfiguration, workflow api.MultiStageTestConfiguration) error {
+	if config.ClusterProfile == "" {
+		config.ClusterProfile = workflow.ClusterProfile
+	}
+	if config.Pre == nil {
+		config.Pre = workflow.Pre
+	}
+	if config.Test == nil {
+		config.Test = workflow.Test
+	}
+	if config.Post == nil {
+		config.Post = workflow.Post
+	}
+	mergeEnvironments(&config.Environment, workflow.Environment)
+	mergeDependencies(&config.Dependencies, workflow.Dependencies)
+	l, err := mergeLeases(workflow.Leases, config.Leases)
+	if err != nil {
+		return err
+	}
+	config.Leases = l
+	if config.AllowSkipOnSuccess == nil {
+		config.AllowSkipOnSuccess = workflow.AllowSkipOnSuccess
+	}
+	if config.AllowBestEffortPostSteps == nil {
+		config.AllowBestEffortPostSteps = workflow.AllowBestEffortPostSteps
+	}
+	if config.Comparison == nil {
+		config.Comparison = workflow.Comparison
+	}
+	if config.SharedDir == nil {
+		config.SharedDir = workflow.SharedDir
+	}
+	if config.DataDir == nil {
+		config.DataDir = workflow.DataDir
+	}
+	if config.ClusterProvisioning == nil {
+		config.ClusterProvisioning = workflow.ClusterProvisioning
+	}
+	if config.ClusterClaim == nil {
+		config.ClusterClaim = workflow.ClusterClaim
+	}
+	if config.ClusterProfileOverlay == nil {
+		config.ClusterProfileOverlay = workflow.ClusterProfileOverlay
+	}
+	if config.Gather == nil {
+		config.Gather = workflow.Gather
+	}
+	if config.DisruptionRetries == nil {
+		config.DisruptionRetries = workflow.DisruptionRetries
+	}
+	return nil
+}
+
+// ExtendWorkflows expands the workflows which extend another workflow, as
+// named in `parents`, into complete workflows. A workflow inherits the
+// configuration of its parent, which may itself extend a workflow, following
+// the rules of mergeWorkflow.
+func ExtendWorkflows(workflowsByName WorkflowByName, parents map[string]string) (WorkflowByName, error) {
+	ret := make(WorkflowByName, len(workflowsByName))
+	var errs []error
+	var extend func(name string, chain []string) (api.MultiStageTestConfiguration, error)
+	extend = func(name string, chain []string) (api.MultiStageTestConfiguration, error) {
+		if workflow, ok := ret[name]; ok {
+			return workflow, nil
+		}
+		for i, seen := range chain {
+			if seen == name {
+				return api.MultiStageTestConfiguration{}, fmt.Errorf("workflow/%s: cycle in workflow extension: %s", name, strings.Join(append(chain[i:], name), " -> "))
+			}
+		}
+		workflow, ok := workflowsByName[name]
+		if !ok {
+			return api.MultiStageTestConfiguration{}, fmt.Errorf("workflow/%s: extends unknown workflow %s", chain[len(chain)-1], name)
+		}
+		parent, extends := parents[name]
+		if !extends {
+			ret[name] = workflow
+			return workflow, nil
+		}
+		base, err := extend(parent, append(chain, name))
+		if err != nil {
+			return api.MultiStageTestConfiguration{}, err
+		}
+		// the maps are merged into, do not modify the input
+		workflow.Environment, workflow.Dependencies = nil, nil
+		mergeEnvironments(&workflow.Environment, workflowsByName[name].Environment)
+		mergeDependencies(&workflow.Dependencies, workflowsByName[name].Dependencies)
+		if err := mergeWorkflow(&workflow, base); err != nil {
+			return api.MultiStageTestConfiguration{}, fmt.Errorf("workflow/%s: cannot extend workflow %s: %w", name, parent, err)
+		}
+		ret[name] = workflow
+		return workflow, nil
+	}
+	names := sets.StringKeySet(workflowsByName).List()
+	for _, name := range names {
+		if _, err := extend(name, nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return nil, utilerrors.NewAggregate(errs)
+	}
+	return ret, nil
+}
+
 // mergeEnvironments joins two environment maps.
 // Elements in `dst` are overwritten by those in `src` if they target the same
 // variable.
@@ -216,6 +378,9 @@ func (r *registry) processChain(step *api.TestStep, seen sets.String, stack stac
 	if !ok {
 		return nil, []error{stack.errorf("unknown step chain: %s", name)}
 	}
+	if err := r.checkDeprecated("chain/"+name, stack); err != nil {
+		return nil, []error{err}
+	}
 	rec := stackRecordForStep("chain/"+name, chain.Environment, nil)
 	stack.push(rec)
 	defer stack.pop()
@@ -231,6 +396,9 @@ func (r *registry) processStep(step *api.TestStep, seen sets.String, stack stack
 		if !ok {
 			return api.LiteralTestStep{}, []error{stack.errorf("invalid step reference: %s", *ref)}
 		}
+		if err := r.checkDeprecated("step/"+*ref, stack); err != nil {
+			return api.LiteralTestStep{}, []error{err}
+		}
 	} else if step.LiteralTestStep != nil {
 		ret = *step.LiteralTestStep
 	} else {
@@ -249,6 +417,14 @@ func (r *registry) processStep(step *api.TestStep, seen sets.String, stack stack
 			} else if e.Default == nil && !stack.partial {
 				errs = append(errs, stack.errorf("step/%s: unresolved parameter: %s", ret.As, e.Name))
 			}
+			if e.Default != nil {
+				if err := e.ValidateValue(*e.Default); err != nil {
+					if e.Secret {
+						err = fmt.Errorf("invalid value for parameter of type %s", e.Type)
+					}
+					errs = append(errs, stack.errorf("step/%s: parameter %s: %v", ret.As, e.Name, err))
+				}
+			}
 			env = append(env, e)
 		}
 		ret.Environment = env
@@ -268,8 +444,12 @@ func (r *registry) processStep(step *api.TestStep, seen sets.String, stack stack
 
 // ResolveConfig uses a resolver to resolve an entire ci-operator config
 func ResolveConfig(resolver Resolver, config api.ReleaseBuildConfiguration) (api.ReleaseBuildConfiguration, error) {
+	tests, err := api.ExpandTestMatrices(config.Tests)
+	if err != nil {
+		return api.ReleaseBuildConfiguration{}, fmt.Errorf("could not expand test matrices: %w", err)
+	}
 	var resolvedTests []api.TestStepConfiguration
-	for _, step := range config.Tests {
+	for _, step := range tests {
 		// no changes if step is not multi-stage
 		if step.MultiStageTestConfiguration == nil {
 			resolvedTests = append(resolvedTests, step)
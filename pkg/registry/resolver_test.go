@@ -958,6 +958,30 @@ func TestResolveParameters(t *testing.T) {
 			}},
 		},
 		err: errors.New("test/test: step/step: unresolved parameter: UNRESOLVED"),
+	}, {
+		name: "invalid value for typed parameter",
+		test: api.MultiStageTestConfiguration{
+			Test: []api.TestStep{{
+				LiteralTestStep: &api.LiteralTestStep{
+					As:          "step",
+					Environment: []api.StepParameter{{Name: "MODE", Type: api.ParameterTypeEnum, Values: []string{"fast", "slow"}}},
+				},
+			}},
+			Environment: api.TestEnvironment{"MODE": "medium"},
+		},
+		err: errors.New(`test/test: step/step: parameter MODE: value "medium" is not one of fast, slow`),
+	}, {
+		name: "invalid value for secret parameter is not exposed",
+		test: api.MultiStageTestConfiguration{
+			Test: []api.TestStep{{
+				LiteralTestStep: &api.LiteralTestStep{
+					As:          "step",
+					Environment: []api.StepParameter{{Name: "PORT", Type: api.ParameterTypeInt, Secret: true}},
+				},
+			}},
+			Environment: api.TestEnvironment{"PORT": "hunter2"},
+		},
+		err: errors.New("test/test: step/step: parameter PORT: invalid value for parameter of type int"),
 	}} {
 		t.Run(tc.name, func(t *testing.T) {
 			ret, err := NewResolver(refs, chains, workflows, observers).Resolve("test", tc.test)
@@ -1081,3 +1105,165 @@ func TestResolveLeases(t *testing.T) {
 		})
 	}
 }
+
+func TestExtendWorkflows(t *testing.T) {
+	pre, test, post := "pre", "test", "post"
+	yes := true
+	workflows := WorkflowByName{
+		"base": {
+			ClusterProfile:     api.ClusterProfileAWS,
+			Pre:                []api.TestStep{{Reference: &pre}},
+			Test:               []api.TestStep{{Reference: &test}},
+			Post:               []api.TestStep{{Reference: &post}},
+			Environment:        api.TestEnvironment{"A": "base", "B": "base"},
+			Leases:             []api.StepLease{{ResourceType: "base", Env: "BASE"}},
+			AllowSkipOnSuccess: &yes,
+		},
+		"child": {
+			Test:        []api.TestStep{{Reference: &pre}},
+			Environment: api.TestEnvironment{"B": "child"},
+			Leases:      []api.StepLease{{ResourceType: "child", Env: "CHILD"}},
+		},
+		"grandchild": {
+			ClusterProfile: api.ClusterProfileGCP,
+			Environment:    api.TestEnvironment{"C": "grandchild"},
+		},
+	}
+	for _, tc := range []struct {
+		name      string
+		workflows WorkflowByName
+		parents   map[string]string
+		expected  WorkflowByName
+		err       error
+	}{{
+		name:     "no extension",
+		expected: workflows,
+	}, {
+		name:    "workflows inherit from their ancestors",
+		parents: map[string]string{"child": "base", "grandchild": "child"},
+		expected: WorkflowByName{
+			"base": workflows["base"],
+			"child": {
+				ClusterProfile:     api.ClusterProfileAWS,
+				Pre:                []api.TestStep{{Reference: &pre}},
+				Test:               []api.TestStep{{Reference: &pre}},
+				Post:               []api.TestStep{{Reference: &post}},
+				Environment:        api.TestEnvironment{"A": "base", "B": "child"},
+				Leases:             []api.StepLease{{ResourceType: "base", Env: "BASE"}, {ResourceType: "child", Env: "CHILD"}},
+				AllowSkipOnSuccess: &yes,
+			},
+			"grandchild": {
+				ClusterProfile:     api.ClusterProfileGCP,
+				Pre:                []api.TestStep{{Reference: &pre}},
+				Test:               []api.TestStep{{Reference: &pre}},
+				Post:               []api.TestStep{{Reference: &post}},
+				Environment:        api.TestEnvironment{"A": "base", "B": "child", "C": "grandchild"},
+				Leases:             []api.StepLease{{ResourceType: "base", Env: "BASE"}, {ResourceType: "child", Env: "CHILD"}},
+				AllowSkipOnSuccess: &yes,
+			},
+		},
+	}, {
+		name:    "unknown parent",
+		parents: map[string]string{"child": "missing"},
+		err:     errors.New("workflow/child: extends unknown workflow missing"),
+	}, {
+		name:    "cycle",
+		parents: map[string]string{"base": "grandchild", "child": "base", "grandchild": "child"},
+		err: utilerrors.NewAggregate([]error{
+			errors.New("workflow/base: cycle in workflow extension: base -> grandchild -> child -> base"),
+			errors.New("workflow/child: cycle in workflow extension: child -> base -> grandchild -> child"),
+			errors.New("workflow/grandchild: cycle in workflow extension: grandchild -> child -> base -> grandchild"),
+		}),
+	}, {
+		name: "conflicting leases",
+		workflows: WorkflowByName{
+			"base":  workflows["base"],
+			"child": {Leases: []api.StepLease{{ResourceType: "other", Env: "BASE"}}},
+		},
+		parents: map[string]string{"child": "base"},
+		err:     errors.New("workflow/child: cannot extend workflow base: cannot override workflow environment variable for lease(s): [BASE]"),
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			input := tc.workflows
+			if input == nil {
+				input = workflows
+			}
+			ret, err := ExtendWorkflows(input, tc.parents)
+			if tc.err != nil {
+				if err == nil {
+					t.Fatal("unexpected success")
+				}
+				if diff := cmp.Diff(tc.err.Error(), err.Error()); diff != "" {
+					t.Fatal(diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, ret); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(api.TestEnvironment{"B": "child"}, workflows["child"].Environment); diff != "" {
+				t.Errorf("input was modified: %s", diff)
+			}
+		})
+	}
+}
+
+func TestResolveDeprecations(t *testing.T) {
+	step, chain, workflow := "step", "chain", "workflow"
+	refs := ReferenceByName{step: {As: step}}
+	chains := ChainByName{chain: {As: chain, Steps: []api.TestStep{{Reference: &step}}}}
+	workflows := WorkflowByName{workflow: {Test: []api.TestStep{{Chain: &chain}}}}
+	deprecations := DeprecationByName{
+		"step/step":         {Message: "not maintained", Replacement: "other-step"},
+		"chain/chain":       {Message: "not maintained"},
+		"workflow/workflow": {Message: "not maintained", Replacement: "other-workflow"},
+	}
+	for _, tc := range []struct {
+		name     string
+		test     api.MultiStageTestConfiguration
+		strict   bool
+		err      error
+		recorded map[string]api.Deprecation
+	}{{
+		name:     "deprecated components only warn",
+		test:     api.MultiStageTestConfiguration{Workflow: &workflow},
+		recorded: deprecations,
+	}, {
+		name:   "deprecated step fails in strict mode",
+		test:   api.MultiStageTestConfiguration{Test: []api.TestStep{{Reference: &step}}},
+		strict: true,
+		err:    errors.New("test/test: step/step is deprecated: not maintained, use other-step instead"),
+	}, {
+		name:   "deprecated chain fails in strict mode",
+		test:   api.MultiStageTestConfiguration{Test: []api.TestStep{{Chain: &chain}}},
+		strict: true,
+		err:    errors.New("test/test: chain/chain is deprecated: not maintained"),
+	}, {
+		name:   "deprecated workflow fails in strict mode",
+		test:   api.MultiStageTestConfiguration{Workflow: &workflow, Test: []api.TestStep{{LiteralTestStep: &api.LiteralTestStep{As: "literal"}}}},
+		strict: true,
+		err:    errors.New("test/test: workflow/workflow is deprecated: not maintained, use other-workflow instead"),
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			resolved, err := NewResolver(refs, chains, workflows, nil, WithDeprecations(deprecations, tc.strict)).Resolve("test", tc.test)
+			if tc.err == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if diff := cmp.Diff(tc.recorded, resolved.Deprecations); diff != "" {
+					t.Errorf("unexpected deprecations: %s", diff)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if diff := cmp.Diff(tc.err.Error(), err.Error()); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// Client talks to the configresolver to resolve configurations and to look up
+// components of the registry it serves
+type Client interface {
+	// Config resolves the configuration of the repository branch and variant
+	Config(metadata api.Metadata) (*api.ReleaseBuildConfiguration, error)
+	// Resolve resolves the references to the registry in the configuration
+	Resolve(config api.ReleaseBuildConfiguration) (*api.ReleaseBuildConfiguration, error)
+	// Search lists the components of the registry matching the query
+	Search(query Query) ([]Component, error)
+	// Component fetches a component of the registry with its definition
+	Component(componentType ComponentType, name string) (*Component, error)
+	// Jobs lists the test targets of the repository, of every branch when
+	// the branch is empty
+	Jobs(org, repo, branch string) (*JobList, error)
+}
+
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+type client struct {
+	endpoint string
+	client   HTTPClient
+}
+
+// NewClient creates a client for the configresolver at the endpoint
+func NewClient(endpoint string, httpClient HTTPClient) Client {
+	return &client{endpoint: endpoint, client: httpClient}
+}
+
+func (c *client) Config(metadata api.Metadata) (*api.ReleaseBuildConfiguration, error) {
+	query := url.Values{"org": {metadata.Org}, "repo": {metadata.Repo}, "branch": {metadata.Branch}}
+	if metadata.Variant != "" {
+		query.Set("variant", metadata.Variant)
+	}
+	var config api.ReleaseBuildConfiguration
+	if err := c.request(http.MethodGet, "/config", query, nil, &config); err != nil {
+		return nil, fmt.Errorf("could not resolve configuration for %s: %w", metadata.Basename(), err)
+	}
+	return &config, nil
+}
+
+func (c *client) Resolve(config api.ReleaseBuildConfiguration) (*api.ReleaseBuildConfiguration, error) {
+	var resolved api.ReleaseBuildConfiguration
+	if err := c.request(http.MethodPost, "/resolve", nil, config, &resolved); err != nil {
+		return nil, fmt.Errorf("could not resolve configuration: %w", err)
+	}
+	return &resolved, nil
+}
+
+func (c *client) Search(query Query) ([]Component, error) {
+	values := url.Values{}
+	for key, value := range map[string]string{TypeQuery: string(query.Type), NameQuery: query.Name, OwnerQuery: query.Owner} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	var components []Component
+	if err := c.request(http.MethodGet, ComponentsPath, values, nil, &components); err != nil {
+		return nil, fmt.Errorf("could not search the registry: %w", err)
+	}
+	return components, nil
+}
+
+func (c *client) Component(componentType ComponentType, name string) (*Component, error) {
+	var component Component
+	path := fmt.Sprintf("%s/%s/%s", ComponentsPath, url.PathEscape(string(componentType)), url.PathEscape(name))
+	if err := c.request(http.MethodGet, path, nil, nil, &component); err != nil {
+		return nil, fmt.Errorf("could not get %s %s: %w", componentType, name, err)
+	}
+	return &component, nil
+}
+
+func (c *client) Jobs(org, repo, branch string) (*JobList, error) {
+	query := url.Values{OrgQuery: {org}, RepoQuery: {repo}}
+	if branch != "" {
+		query.Set(BranchQuery, branch)
+	}
+	var jobs JobList
+	if err := c.request(http.MethodGet, JobsPath, query, nil, &jobs); err != nil {
+		return nil, fmt.Errorf("could not list the jobs of %s/%s: %w", org, repo, err)
+	}
+	return &jobs, nil
+}
+
+// request sends the body as JSON and decodes the JSON response into `into`
+func (c *client) request(method, path string, query url.Values, body, into interface{}) error {
+	address := c.endpoint + path
+	if len(query) != 0 {
+		address += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("could not marshal request: %w", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, address, reader)
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server responded with %d: %s", resp.StatusCode, string(raw))
+	}
+	if err := json.Unmarshal(raw, into); err != nil {
+		return fmt.Errorf("could not parse response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/test-infra/prow/repoowners"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/registry"
+)
+
+type fakeRegistry struct{}
+
+func (fakeRegistry) GetRegistryComponents() (registry.ReferenceByName, registry.ChainByName, registry.WorkflowByName, map[string]string, api.RegistryMetadata) {
+	install := "ipi-install-install"
+	return registry.ReferenceByName{
+			"ipi-install-install":         {As: "ipi-install-install", Commands: "install"},
+			"ipi-deprovision-deprovision": {As: "ipi-deprovision-deprovision", Commands: "deprovision"},
+		},
+		registry.ChainByName{
+			"ipi-install": {As: "ipi-install", Steps: []api.TestStep{{Reference: &install}}},
+		},
+		registry.WorkflowByName{
+			"ipi": {Pre: []api.TestStep{{Reference: &install}}},
+		},
+		map[string]string{
+			"ipi-install-install":         "Installs a cluster.",
+			"ipi-deprovision-deprovision": "Destroys a cluster.",
+			"ipi-install":                 "Installs a cluster.",
+			"ipi":                         "Tests a cluster.",
+		},
+		api.RegistryMetadata{
+			"ipi-install-install-ref.yaml":         {Path: "ipi/install/install", Owners: repoowners.Config{Approvers: []string{"alice"}}},
+			"ipi-deprovision-deprovision-ref.yaml": {Path: "ipi/deprovision/deprovision", Owners: repoowners.Config{Approvers: []string{"bob"}}},
+			"ipi-install-chain.yaml":               {Path: "ipi/install", Owners: repoowners.Config{Reviewers: []string{"alice"}}},
+			"ipi-workflow.yaml":                    {Path: "ipi", Owners: repoowners.Config{Approvers: []string{"bob"}}},
+		}
+}
+
+func TestClient(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle(ComponentsPath, Handler(fakeRegistry{}))
+	mux.Handle(ComponentsPath+"/", Handler(fakeRegistry{}))
+	mux.Handle(JobsPath, JobsHandler(testConfigs(), fakeResolver{}))
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("org") != "org" || r.URL.Query().Get("variant") != "variant" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(api.ReleaseBuildConfiguration{Metadata: api.Metadata{Org: "org", Variant: "variant"}})
+	})
+	mux.HandleFunc("/resolve", func(w http.ResponseWriter, r *http.Request) {
+		var config api.ReleaseBuildConfiguration
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		config.Metadata.Branch = "resolved"
+		_ = json.NewEncoder(w).Encode(config)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := NewClient(server.URL, server.Client())
+
+	t.Run("config", func(t *testing.T) {
+		config, err := client.Config(api.Metadata{Org: "org", Repo: "repo", Branch: "master", Variant: "variant"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(api.Metadata{Org: "org", Variant: "variant"}, config.Metadata); diff != "" {
+			t.Errorf("unexpected config: %s", diff)
+		}
+		if _, err := client.Config(api.Metadata{Org: "other", Repo: "repo", Branch: "master"}); err == nil {
+			t.Error("expected an error for a missing config")
+		}
+	})
+
+	t.Run("resolve", func(t *testing.T) {
+		config, err := client.Resolve(api.ReleaseBuildConfiguration{Metadata: api.Metadata{Org: "org"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(api.Metadata{Org: "org", Branch: "resolved"}, config.Metadata); diff != "" {
+			t.Errorf("unexpected config: %s", diff)
+		}
+	})
+
+	var searchCases = []struct {
+		name     string
+		query    Query
+		expected []string
+	}{
+		{
+			name:     "everything",
+			expected: []string{"step/ipi-deprovision-deprovision", "step/ipi-install-install", "chain/ipi-install", "workflow/ipi"},
+		},
+		{
+			name:     "by type",
+			query:    Query{Type: ComponentTypeStep},
+			expected: []string{"step/ipi-deprovision-deprovision", "step/ipi-install-install"},
+		},
+		{
+			name:     "by name",
+			query:    Query{Name: "install"},
+			expected: []string{"step/ipi-install-install", "chain/ipi-install"},
+		},
+		{
+			name:     "by owner",
+			query:    Query{Owner: "bob"},
+			expected: []string{"step/ipi-deprovision-deprovision", "workflow/ipi"},
+		},
+		{
+			name:     "no match",
+			query:    Query{Type: ComponentTypeWorkflow, Owner: "alice"},
+			expected: []string{},
+		},
+	}
+	for _, testCase := range searchCases {
+		t.Run("search "+testCase.name, func(t *testing.T) {
+			components, err := client.Search(testCase.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			actual := []string{}
+			for _, component := range components {
+				if component.Documentation == "" || component.Metadata.Path == "" {
+					t.Errorf("%s/%s: documentation or metadata missing", component.Type, component.Name)
+				}
+				actual = append(actual, string(component.Type)+"/"+component.Name)
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected components: %s", diff)
+			}
+		})
+	}
+
+	t.Run("component", func(t *testing.T) {
+		component, err := client.Component(ComponentTypeStep, "ipi-install-install")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := &Component{
+			Type:          ComponentTypeStep,
+			Name:          "ipi-install-install",
+			Documentation: "Installs a cluster.",
+			Metadata:      api.RegistryInfo{Path: "ipi/install/install", Owners: repoowners.Config{Approvers: []string{"alice"}}},
+			Step:          &api.LiteralTestStep{As: "ipi-install-install", Commands: "install"},
+		}
+		if diff := cmp.Diff(expected, component); diff != "" {
+			t.Errorf("unexpected component: %s", diff)
+		}
+		if _, err := client.Component(ComponentTypeChain, "ipi-install-install"); err == nil {
+			t.Error("expected an error for a missing component")
+		}
+		if _, err := client.Component("observer", "ipi"); err == nil {
+			t.Error("expected an error for an unknown type")
+		}
+	})
+	t.Run("jobs", func(t *testing.T) {
+		jobs, err := client.Jobs("org", "repo", "release-4.1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := &JobList{Jobs: []Job{{Org: "org", Repo: "repo", Branch: "release-4.1", Target: "unit", Type: TestTypeContainer}}}
+		if diff := cmp.Diff(expected, jobs); diff != "" {
+			t.Errorf("unexpected jobs: %s", diff)
+		}
+		if _, err := client.Jobs("", "repo", ""); err == nil {
+			t.Error("expected an error for a missing org")
+		}
+	})
+}
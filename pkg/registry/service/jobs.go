@@ -0,0 +1,207 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/legacytemplates"
+	"github.com/openshift/ci-tools/pkg/load"
+)
+
+const (
+	// JobsPath is the path the test targets of a repository are listed under
+	JobsPath = "/jobs"
+
+	// OrgQuery and RepoQuery select the repository whose test targets are
+	// listed, BranchQuery optionally limits the listing to one branch
+	OrgQuery    = "org"
+	RepoQuery   = "repo"
+	BranchQuery = "branch"
+
+	// clusterProfileNamespace holds the secrets of the cluster profiles,
+	// which jobs mount from the namespace they run in
+	clusterProfileNamespace = "ci"
+)
+
+// TestType is how a test target is executed
+type TestType string
+
+const (
+	TestTypeContainer  TestType = "container"
+	TestTypeMultiStage TestType = "multi-stage"
+	TestTypeTemplate   TestType = "template"
+)
+
+// SecretReference identifies a secret a test target needs, either by the
+// namespace and name of the secret or by its path in Vault. Secrets of
+// container tests have no namespace, they are looked up in the namespace
+// the test runs in.
+type SecretReference struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	VaultPath string `json:"vault_path,omitempty"`
+}
+
+// Job describes a test target generated from the configuration of a
+// repository, after references to the registry are resolved
+type Job struct {
+	Org     string `json:"org"`
+	Repo    string `json:"repo"`
+	Branch  string `json:"branch"`
+	Variant string `json:"variant,omitempty"`
+	// Target is the name of the test
+	Target string `json:"target"`
+	// Type is how the test is executed
+	Type TestType `json:"type"`
+	// ClusterProfile is the profile of the cluster the test runs against,
+	// if it needs one
+	ClusterProfile api.ClusterProfile `json:"cluster_profile,omitempty"`
+	// Secrets are the secrets mounted into the test, including the one
+	// of the cluster profile
+	Secrets []SecretReference `json:"secrets,omitempty"`
+	// Postsubmit is set for tests which run after merges
+	Postsubmit bool `json:"postsubmit,omitempty"`
+	// Periodic is set for tests which run on a schedule
+	Periodic bool `json:"periodic,omitempty"`
+}
+
+// ConfigError reports a configuration of the repository whose test targets
+// could not be listed
+type ConfigError struct {
+	Branch  string `json:"branch"`
+	Variant string `json:"variant,omitempty"`
+	Error   string `json:"error"`
+}
+
+// JobList holds the test targets of a repository, along with the
+// configurations which could not be resolved
+type JobList struct {
+	Jobs   []Job         `json:"jobs"`
+	Errors []ConfigError `json:"errors,omitempty"`
+}
+
+// Configs holds the configurations of repositories
+type Configs interface {
+	GetAll() load.ByOrgRepo
+}
+
+// ConfigResolver resolves the references to the registry in configurations
+type ConfigResolver interface {
+	ResolveConfig(config api.ReleaseBuildConfiguration) (api.ReleaseBuildConfiguration, error)
+}
+
+// JobsHandler lists the test targets of a repository:
+//   - GET JobsPath?org=<org>&repo=<repo>[&branch=<branch>]
+func JobsHandler(configs Configs, resolver ConfigResolver) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNotImplemented)
+			_, _ = w.Write([]byte(http.StatusText(http.StatusNotImplemented)))
+			return
+		}
+		query := r.URL.Query()
+		org, repo := query.Get(OrgQuery), query.Get(RepoQuery)
+		if org == "" || repo == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "%s and %s query parameters are required", OrgQuery, RepoQuery)
+			return
+		}
+		respond(w, Jobs(configs, resolver, org, repo, query.Get(BranchQuery)))
+	})
+}
+
+// Jobs lists the test targets of the configurations of the repository,
+// ordered by branch, variant and target. An empty branch lists the targets
+// of every branch. Configurations which do not resolve are reported instead
+// of failing the whole listing.
+func Jobs(configs Configs, resolver ConfigResolver, org, repo, branch string) JobList {
+	ret := JobList{Jobs: []Job{}}
+	for _, config := range configs.GetAll()[org][repo] {
+		if branch != "" && config.Metadata.Branch != branch {
+			continue
+		}
+		resolved, err := resolver.ResolveConfig(config)
+		if err != nil {
+			ret.Errors = append(ret.Errors, ConfigError{
+				Branch:  config.Metadata.Branch,
+				Variant: config.Metadata.Variant,
+				Error:   fmt.Sprintf("could not resolve configuration %s: %v", config.Metadata.Basename(), err),
+			})
+			continue
+		}
+		for _, test := range resolved.Tests {
+			ret.Jobs = append(ret.Jobs, jobFor(resolved.Metadata, test))
+		}
+	}
+	sort.Slice(ret.Jobs, func(i, j int) bool {
+		if ret.Jobs[i].Branch != ret.Jobs[j].Branch {
+			return ret.Jobs[i].Branch < ret.Jobs[j].Branch
+		}
+		if ret.Jobs[i].Variant != ret.Jobs[j].Variant {
+			return ret.Jobs[i].Variant < ret.Jobs[j].Variant
+		}
+		return ret.Jobs[i].Target < ret.Jobs[j].Target
+	})
+	sort.Slice(ret.Errors, func(i, j int) bool {
+		if ret.Errors[i].Branch != ret.Errors[j].Branch {
+			return ret.Errors[i].Branch < ret.Errors[j].Branch
+		}
+		return ret.Errors[i].Variant < ret.Errors[j].Variant
+	})
+	return ret
+}
+
+// jobFor describes a resolved test
+func jobFor(metadata api.Metadata, test api.TestStepConfiguration) Job {
+	job := Job{
+		Org:        metadata.Org,
+		Repo:       metadata.Repo,
+		Branch:     metadata.Branch,
+		Variant:    metadata.Variant,
+		Target:     test.As,
+		Postsubmit: test.Postsubmit,
+		Periodic:   test.Cron != nil || test.Interval != nil,
+	}
+	secrets := map[SecretReference]struct{}{}
+	switch {
+	case test.MultiStageTestConfigurationLiteral != nil:
+		job.Type = TestTypeMultiStage
+		job.ClusterProfile = test.MultiStageTestConfigurationLiteral.ClusterProfile
+		for _, phase := range [][]api.LiteralTestStep{test.MultiStageTestConfigurationLiteral.Pre, test.MultiStageTestConfigurationLiteral.Test, test.MultiStageTestConfigurationLiteral.Post} {
+			for _, step := range phase {
+				for _, credential := range step.Credentials {
+					secrets[SecretReference{Namespace: credential.Namespace, Name: credential.Name, VaultPath: credential.VaultPath}] = struct{}{}
+				}
+			}
+		}
+	case test.ContainerTestConfiguration != nil:
+		job.Type = TestTypeContainer
+	default:
+		job.Type = TestTypeTemplate
+		job.ClusterProfile = legacytemplates.ClusterProfile(test)
+	}
+	if job.ClusterProfile != "" {
+		secrets[SecretReference{Namespace: clusterProfileNamespace, Name: job.ClusterProfile.Secret()}] = struct{}{}
+	}
+	if test.Secret != nil {
+		secrets[SecretReference{Name: test.Secret.Name}] = struct{}{}
+	}
+	for _, secret := range test.Secrets {
+		if secret != nil {
+			secrets[SecretReference{Name: secret.Name}] = struct{}{}
+		}
+	}
+	for secret := range secrets {
+		job.Secrets = append(job.Secrets, secret)
+	}
+	sort.Slice(job.Secrets, func(i, j int) bool {
+		return secretKey(job.Secrets[i]) < secretKey(job.Secrets[j])
+	})
+	return job
+}
+
+func secretKey(secret SecretReference) string {
+	return fmt.Sprintf("%s/%s/%s", secret.Namespace, secret.Name, secret.VaultPath)
+}
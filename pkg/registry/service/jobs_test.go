@@ -0,0 +1,194 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/load"
+)
+
+type fakeConfigs load.ByOrgRepo
+
+func (c fakeConfigs) GetAll() load.ByOrgRepo {
+	return load.ByOrgRepo(c)
+}
+
+// fakeResolver replaces the workflows of multi-stage tests with their literal
+// form, which holds a step using a credential
+type fakeResolver struct{}
+
+func (fakeResolver) ResolveConfig(config api.ReleaseBuildConfiguration) (api.ReleaseBuildConfiguration, error) {
+	if config.Metadata.Branch == "broken" {
+		return config, errors.New("no such workflow")
+	}
+	var tests []api.TestStepConfiguration
+	for _, test := range config.Tests {
+		if test.MultiStageTestConfiguration != nil {
+			test.MultiStageTestConfigurationLiteral = &api.MultiStageTestConfigurationLiteral{
+				ClusterProfile: test.MultiStageTestConfiguration.ClusterProfile,
+				Pre: []api.LiteralTestStep{{
+					As:          "ipi-install-install",
+					Credentials: []api.CredentialReference{{Namespace: "ci", Name: "install", MountPath: "/install"}},
+				}},
+				Test: []api.LiteralTestStep{{
+					As: "e2e",
+					Credentials: []api.CredentialReference{
+						{VaultPath: "team/e2e", MountPath: "/e2e"},
+						{Namespace: "ci", Name: "install", MountPath: "/other"},
+					},
+				}},
+			}
+			test.MultiStageTestConfiguration = nil
+		}
+		tests = append(tests, test)
+	}
+	config.Tests = tests
+	return config, nil
+}
+
+func testConfigs() fakeConfigs {
+	return fakeConfigs{"org": {"repo": {
+		{
+			Metadata: api.Metadata{Org: "org", Repo: "repo", Branch: "master"},
+			Tests: []api.TestStepConfiguration{
+				{
+					As:                         "unit",
+					Commands:                   "make test",
+					ContainerTestConfiguration: &api.ContainerTestConfiguration{From: "src"},
+					Secrets:                    []*api.Secret{{Name: "token"}, {Name: "token", MountPath: "/other"}},
+					Postsubmit:                 true,
+				},
+				{
+					As:                          "e2e",
+					Cron:                        func() *string { cron := "@daily"; return &cron }(),
+					MultiStageTestConfiguration: &api.MultiStageTestConfiguration{ClusterProfile: api.ClusterProfileAWS, Workflow: func() *string { w := "ipi"; return &w }()},
+				},
+			},
+		},
+		{
+			Metadata: api.Metadata{Org: "org", Repo: "repo", Branch: "master", Variant: "old"},
+			Tests: []api.TestStepConfiguration{
+				{
+					As: "e2e-gcp",
+					OpenshiftInstallerClusterTestConfiguration: &api.OpenshiftInstallerClusterTestConfiguration{
+						ClusterTestConfiguration: api.ClusterTestConfiguration{ClusterProfile: api.ClusterProfileGCP},
+					},
+				},
+			},
+		},
+		{
+			Metadata: api.Metadata{Org: "org", Repo: "repo", Branch: "release-4.1"},
+			Tests: []api.TestStepConfiguration{
+				{As: "unit", Commands: "make test", ContainerTestConfiguration: &api.ContainerTestConfiguration{From: "src"}},
+			},
+		},
+	}}}
+}
+
+func TestJobs(t *testing.T) {
+	master := []Job{
+		{
+			Org:            "org",
+			Repo:           "repo",
+			Branch:         "master",
+			Target:         "e2e",
+			Type:           TestTypeMultiStage,
+			ClusterProfile: api.ClusterProfileAWS,
+			Secrets: []SecretReference{
+				{VaultPath: "team/e2e"},
+				{Namespace: "ci", Name: "cluster-secrets-aws"},
+				{Namespace: "ci", Name: "install"},
+			},
+			Periodic: true,
+		},
+		{
+			Org:        "org",
+			Repo:       "repo",
+			Branch:     "master",
+			Target:     "unit",
+			Type:       TestTypeContainer,
+			Secrets:    []SecretReference{{Name: "token"}},
+			Postsubmit: true,
+		},
+		{
+			Org:            "org",
+			Repo:           "repo",
+			Branch:         "master",
+			Variant:        "old",
+			Target:         "e2e-gcp",
+			Type:           TestTypeTemplate,
+			ClusterProfile: api.ClusterProfileGCP,
+			Secrets:        []SecretReference{{Namespace: "ci", Name: "cluster-secrets-gcp"}},
+		},
+	}
+	var testCases = []struct {
+		name      string
+		configs   fakeConfigs
+		org, repo string
+		branch    string
+		expected  JobList
+	}{
+		{
+			name:    "every branch",
+			configs: testConfigs(),
+			org:     "org",
+			repo:    "repo",
+			expected: JobList{Jobs: append(append([]Job{}, master...), Job{
+				Org: "org", Repo: "repo", Branch: "release-4.1", Target: "unit", Type: TestTypeContainer,
+			})},
+		},
+		{
+			name:     "one branch",
+			configs:  testConfigs(),
+			org:      "org",
+			repo:     "repo",
+			branch:   "master",
+			expected: JobList{Jobs: master},
+		},
+		{
+			name:     "unknown repository",
+			configs:  testConfigs(),
+			org:      "org",
+			repo:     "other",
+			expected: JobList{Jobs: []Job{}},
+		},
+		{
+			name: "configuration does not resolve",
+			configs: fakeConfigs{"org": {"repo": append(testConfigs()["org"]["repo"],
+				api.ReleaseBuildConfiguration{Metadata: api.Metadata{Org: "org", Repo: "repo", Branch: "broken"}},
+			)}},
+			org:    "org",
+			repo:   "repo",
+			branch: "broken",
+			expected: JobList{
+				Jobs:   []Job{},
+				Errors: []ConfigError{{Branch: "broken", Error: "could not resolve configuration org-repo-broken.yaml: no such workflow"}},
+			},
+		},
+		{
+			name: "other configurations are listed when one does not resolve",
+			configs: fakeConfigs{"org": {"repo": append(testConfigs()["org"]["repo"],
+				api.ReleaseBuildConfiguration{Metadata: api.Metadata{Org: "org", Repo: "repo", Branch: "broken", Variant: "old"}},
+			)}},
+			org:  "org",
+			repo: "repo",
+			expected: JobList{
+				Jobs: append(append([]Job{}, master...), Job{
+					Org: "org", Repo: "repo", Branch: "release-4.1", Target: "unit", Type: TestTypeContainer,
+				}),
+				Errors: []ConfigError{{Branch: "broken", Variant: "old", Error: "could not resolve configuration org-repo-broken__old.yaml: no such workflow"}},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			jobs := Jobs(testCase.configs, fakeResolver{}, testCase.org, testCase.repo, testCase.branch)
+			if diff := cmp.Diff(testCase.expected, jobs); diff != "" {
+				t.Errorf("unexpected jobs: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Package service exposes the step registry over HTTP, so that tools can
+// search the registry and read the documentation of its components without
+// loading the registry from disk.
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/load"
+	"github.com/openshift/ci-tools/pkg/registry"
+)
+
+const (
+	// ComponentsPath is the path components are searched and fetched under
+	ComponentsPath = "/registry/components"
+
+	// TypeQuery limits a search to components of one type
+	TypeQuery = "type"
+	// NameQuery limits a search to components with names containing it
+	NameQuery = "name"
+	// OwnerQuery limits a search to components the user approves or reviews
+	OwnerQuery = "owner"
+)
+
+// ComponentType is the kind of a registry component
+type ComponentType string
+
+const (
+	ComponentTypeStep     ComponentType = "step"
+	ComponentTypeChain    ComponentType = "chain"
+	ComponentTypeWorkflow ComponentType = "workflow"
+)
+
+// Component describes a component of the registry
+type Component struct {
+	// Type is the kind of the component
+	Type ComponentType `json:"type"`
+	// Name is how the component is referenced
+	Name string `json:"name"`
+	// Documentation describes what the component does
+	Documentation string `json:"documentation,omitempty"`
+	// Metadata holds the location and the owners of the component
+	Metadata api.RegistryInfo `json:"metadata"`
+
+	// Step is the definition of a step, only set when fetching one
+	Step *api.LiteralTestStep `json:"step,omitempty"`
+	// Chain is the definition of a chain, only set when fetching one
+	Chain *api.RegistryChain `json:"chain,omitempty"`
+	// Workflow is the definition of a workflow, only set when fetching one
+	Workflow *api.MultiStageTestConfiguration `json:"workflow,omitempty"`
+}
+
+// Query selects the components returned by a search. Unset fields match
+// every component.
+type Query struct {
+	Type  ComponentType
+	Name  string
+	Owner string
+}
+
+// Registry is the registry served by the service
+type Registry interface {
+	GetRegistryComponents() (registry.ReferenceByName, registry.ChainByName, registry.WorkflowByName, map[string]string, api.RegistryMetadata)
+}
+
+// Handler serves the components of the registry:
+//   - GET ComponentsPath searches for components matching the query
+//   - GET ComponentsPath/<type>/<name> fetches a component with its definition
+func Handler(reg Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNotImplemented)
+			_, _ = w.Write([]byte(http.StatusText(http.StatusNotImplemented)))
+			return
+		}
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, ComponentsPath), "/")
+		if path == "" {
+			query := Query{
+				Type:  ComponentType(r.URL.Query().Get(TypeQuery)),
+				Name:  r.URL.Query().Get(NameQuery),
+				Owner: r.URL.Query().Get(OwnerQuery),
+			}
+			respond(w, Search(reg, query))
+			return
+		}
+		parts := strings.Split(path, "/")
+		if len(parts) != 2 {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, "expected %s/<type>/<name>, got %s", ComponentsPath, r.URL.Path)
+			return
+		}
+		component, err := Get(reg, ComponentType(parts[0]), parts[1])
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		respond(w, component)
+	})
+}
+
+func respond(w http.ResponseWriter, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "failed to marshal response to JSON: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(raw); err != nil {
+		logrus.WithError(err).Error("Failed to write response")
+	}
+}
+
+// Search lists the components of the registry matching the query, ordered by
+// type and name
+func Search(reg Registry, query Query) []Component {
+	refs, chains, workflows, docs, metadata := reg.GetRegistryComponents()
+	names := map[ComponentType]sets.String{
+		ComponentTypeStep:     sets.StringKeySet(refs),
+		ComponentTypeChain:    sets.StringKeySet(chains),
+		ComponentTypeWorkflow: sets.StringKeySet(workflows),
+	}
+	ret := []Component{}
+	for _, componentType := range []ComponentType{ComponentTypeStep, ComponentTypeChain, ComponentTypeWorkflow} {
+		if query.Type != "" && query.Type != componentType {
+			continue
+		}
+		for _, name := range names[componentType].List() {
+			if !strings.Contains(name, query.Name) {
+				continue
+			}
+			info := metadata[metadataName(componentType, name)]
+			if query.Owner != "" && !sets.NewString(info.Owners.Approvers...).Insert(info.Owners.Reviewers...).Has(query.Owner) {
+				continue
+			}
+			ret = append(ret, Component{Type: componentType, Name: name, Documentation: docs[name], Metadata: info})
+		}
+	}
+	return ret
+}
+
+// Get fetches a component of the registry with its definition
+func Get(reg Registry, componentType ComponentType, name string) (*Component, error) {
+	refs, chains, workflows, docs, metadata := reg.GetRegistryComponents()
+	component := Component{
+		Type:          componentType,
+		Name:          name,
+		Documentation: docs[name],
+		Metadata:      metadata[metadataName(componentType, name)],
+	}
+	var ok bool
+	switch componentType {
+	case ComponentTypeStep:
+		var step api.LiteralTestStep
+		if step, ok = refs[name]; ok {
+			component.Step = &step
+		}
+	case ComponentTypeChain:
+		var chain api.RegistryChain
+		if chain, ok = chains[name]; ok {
+			component.Chain = &chain
+		}
+	case ComponentTypeWorkflow:
+		var workflow api.MultiStageTestConfiguration
+		if workflow, ok = workflows[name]; ok {
+			component.Workflow = &workflow
+		}
+	default:
+		return nil, fmt.Errorf("unknown component type %q", componentType)
+	}
+	if !ok {
+		return nil, fmt.Errorf("no %s named %s", componentType, name)
+	}
+	return &component, nil
+}
+
+// metadataName is the key of the metadata of the component, the name of the
+// file it is defined in
+func metadataName(componentType ComponentType, name string) string {
+	switch componentType {
+	case ComponentTypeStep:
+		return name + load.RefSuffix
+	case ComponentTypeChain:
+		return name + load.ChainSuffix
+	default:
+		return name + load.WorkflowSuffix
+	}
+}
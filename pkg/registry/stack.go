@@ -12,6 +12,8 @@ import (
 type stack struct {
 	records []stackRecord
 	partial bool
+	// deprecations collects the deprecated components referenced, if set
+	deprecations map[string]api.Deprecation
 }
 
 func stackForChain() stack {
@@ -260,7 +260,7 @@ func TestInlineCiopConfig(t *testing.T) {
 		},
 	}
 
-	references, chains, workflows, _, _, observers, err := load.Registry(testingRegistry, false)
+	references, chains, workflows, _, _, observers, _, err := load.Registry(testingRegistry, false)
 	if err != nil {
 		t.Fatalf("Failed to read registry: %v", err)
 	}
@@ -481,7 +481,7 @@ func TestExecuteJobsErrors(t *testing.T) {
 		failToCreate: sets.NewString("rehearse-123-job2"),
 	}}
 
-	references, chains, workflows, _, _, observers, err := load.Registry(testingRegistry, false)
+	references, chains, workflows, _, _, observers, _, err := load.Registry(testingRegistry, false)
 	if err != nil {
 		t.Fatalf("Failed to read registry: %v", err)
 	}
@@ -551,7 +551,7 @@ func TestExecuteJobsUnsuccessful(t *testing.T) {
 		},
 	}}
 
-	references, chains, workflows, _, _, observers, err := load.Registry(testingRegistry, false)
+	references, chains, workflows, _, _, observers, _, err := load.Registry(testingRegistry, false)
 	if err != nil {
 		t.Fatalf("Failed to read registry: %v", err)
 	}
@@ -660,7 +660,7 @@ func TestExecuteJobsPositive(t *testing.T) {
 		},
 	}
 
-	references, chains, workflows, _, _, observers, err := load.Registry(testingRegistry, false)
+	references, chains, workflows, _, _, observers, _, err := load.Registry(testingRegistry, false)
 	if err != nil {
 		t.Fatalf("Failed to read registry: %v", err)
 	}
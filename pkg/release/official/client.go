@@ -7,7 +7,10 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/blang/semver"
 
@@ -26,45 +29,139 @@ func defaultFields(release api.Release) api.Release {
 
 // ResolvePullSpecAndVersion determines the pull spec and version for the official release
 func ResolvePullSpecAndVersion(client release.HTTPClient, release api.Release) (string, string, error) {
-	return resolvePullSpec(client, cincinnatiAddress, defaultFields(release))
+	release = defaultFields(release)
+	if release.VersionRange != "" {
+		return resolvePullSpecInRange(client, cincinnatiAddress, release)
+	}
+	return resolvePullSpec(client, cincinnatiAddress, release)
 }
 
 func resolvePullSpec(client release.HTTPClient, endpoint string, release api.Release) (string, string, error) {
-	req, err := http.NewRequest("GET", endpoint, nil)
+	nodes, err := requestReleases(client, endpoint, fmt.Sprintf("%s-%s", release.Channel, release.Version), release.Architecture)
 	if err != nil {
 		return "", "", err
 	}
+	if len(nodes) == 0 {
+		return "", "", errors.New("failed to request latest release: server returned empty list of releases (despite status code 200)")
+	}
+	pullspec, version := latestPullSpecAndVersion(nodes)
+	return pullspec, version, nil
+}
+
+// resolvePullSpecInRange determines the newest release matching the version
+// range in the channels of all minor versions the range mentions
+func resolvePullSpecInRange(client release.HTTPClient, endpoint string, release api.Release) (string, string, error) {
+	inRange, err := semver.ParseRange(release.VersionRange)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid version range %q: %w", release.VersionRange, err)
+	}
+	minors, err := MinorVersionsInRange(release.VersionRange)
+	if err != nil {
+		return "", "", err
+	}
+	var matching []Release
+	for _, minor := range minors {
+		nodes, err := requestReleases(client, endpoint, fmt.Sprintf("%s-%s", release.Channel, minor), release.Architecture)
+		if err != nil {
+			return "", "", err
+		}
+		for _, node := range nodes {
+			if version, err := semver.Parse(node.Version); err == nil && inRange(version) {
+				matching = append(matching, node)
+			}
+		}
+	}
+	if len(matching) == 0 {
+		return "", "", fmt.Errorf("failed to request latest release: no release in the %s channel matches %s", release.Channel, release.VersionRange)
+	}
+	pullspec, version := latestPullSpecAndVersion(matching)
+	return pullspec, version, nil
+}
+
+var minorVersion = regexp.MustCompile(`(\d+)\.(\d+)`)
+
+// MinorVersionsInRange lists every minor version between the lowest and the
+// highest one mentioned in the range, for each major version. Every
+// alternative of the range needs an upper bound, as the channels of newer
+// minor versions would not be searched otherwise.
+func MinorVersionsInRange(versionRange string) ([]string, error) {
+	for _, alternative := range strings.Split(versionRange, "||") {
+		if !hasUpperBound(alternative) {
+			return nil, fmt.Errorf("range %q has no upper bound", strings.TrimSpace(alternative))
+		}
+	}
+	bounds := map[uint64][2]uint64{}
+	var majors []uint64
+	for _, match := range minorVersion.FindAllStringSubmatch(versionRange, -1) {
+		major, _ := strconv.ParseUint(match[1], 10, 64)
+		minor, _ := strconv.ParseUint(match[2], 10, 64)
+		bound, seen := bounds[major]
+		if !seen {
+			majors = append(majors, major)
+			bound = [2]uint64{minor, minor}
+		}
+		if minor < bound[0] {
+			bound[0] = minor
+		}
+		if minor > bound[1] {
+			bound[1] = minor
+		}
+		bounds[major] = bound
+	}
+	sort.Slice(majors, func(i, j int) bool { return majors[i] < majors[j] })
+	var versions []string
+	for _, major := range majors {
+		for minor := bounds[major][0]; minor <= bounds[major][1]; minor++ {
+			versions = append(versions, fmt.Sprintf("%d.%d", major, minor))
+		}
+	}
+	return versions, nil
+}
+
+// hasUpperBound determines whether a range without alternatives limits the
+// versions it matches from above, either with a less-than comparison or by
+// matching a single version
+func hasUpperBound(versionRange string) bool {
+	for _, comparison := range strings.Fields(versionRange) {
+		if !strings.HasPrefix(comparison, ">") && !strings.HasPrefix(comparison, "!") {
+			return true
+		}
+	}
+	return false
+}
+
+// requestReleases lists the releases in a channel
+func requestReleases(client release.HTTPClient, endpoint, channel string, architecture api.ReleaseArchitecture) ([]Release, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Accept", "application/json")
 	query := req.URL.Query()
-	query.Add("channel", fmt.Sprintf("%s-%s", release.Channel, release.Version))
-	query.Add("arch", string(release.Architecture))
+	query.Add("channel", channel)
+	query.Add("arch", string(architecture))
 	req.URL.RawQuery = query.Encode()
 	log.Println("INFO: Requesting a release from ", req.URL.String())
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to request latest release: %w", err)
+		return nil, fmt.Errorf("failed to request latest release: %w", err)
 	}
 	if resp == nil {
-		return "", "", errors.New("failed to request latest release: got a nil response")
+		return nil, errors.New("failed to request latest release: got a nil response")
 	}
 	defer resp.Body.Close()
 	data, readErr := ioutil.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return "", "", fmt.Errorf("failed to request latest release: server responded with %d: %s", resp.StatusCode, data)
+		return nil, fmt.Errorf("failed to request latest release: server responded with %d: %s", resp.StatusCode, data)
 	}
 	if readErr != nil {
-		return "", "", fmt.Errorf("failed to read response body: %w", readErr)
+		return nil, fmt.Errorf("failed to read response body: %w", readErr)
 	}
 	response := Response{}
-	err = json.Unmarshal(data, &response)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-	if len(response.Nodes) == 0 {
-		return "", "", errors.New("failed to request latest release: server returned empty list of releases (despite status code 200)")
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
-	pullspec, version := latestPullSpecAndVersion(response.Nodes)
-	return pullspec, version, nil
+	return response.Nodes, nil
 }
 
 // latestPullSpecAndVersion returns the pullSpec of the latest release in the list as a payload and version
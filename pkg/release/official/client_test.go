@@ -143,6 +143,126 @@ func TestResolvePullSpec(t *testing.T) {
 	}
 }
 
+func TestMinorVersionsInRange(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		versionRange string
+		expected     []string
+		expectedErr  string
+	}{
+		{
+			name:         "bounded range",
+			versionRange: ">=4.12.0 <4.14.0",
+			expected:     []string{"4.12", "4.13", "4.14"},
+		},
+		{
+			name:         "single version",
+			versionRange: "4.10.3",
+			expected:     []string{"4.10"},
+		},
+		{
+			name:         "disjoint ranges across majors",
+			versionRange: ">=3.11.0 <3.12.0 || >=4.1.0 <4.2.0",
+			expected:     []string{"3.11", "3.12", "4.1", "4.2"},
+		},
+		{
+			name:         "open-ended range is rejected",
+			versionRange: ">4.10.3",
+			expectedErr:  `range ">4.10.3" has no upper bound`,
+		},
+		{
+			name:         "open-ended alternative is rejected",
+			versionRange: ">=4.1.0 <4.2.0 || >=4.8.0 !4.9.1",
+			expectedErr:  `range ">=4.8.0 !4.9.1" has no upper bound`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			versions, err := MinorVersionsInRange(testCase.versionRange)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Errorf("got incorrect error: %v", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, versions); diff != "" {
+				t.Errorf("got incorrect minor versions: %v", diff)
+			}
+		})
+	}
+}
+
+func TestResolvePullSpecInRange(t *testing.T) {
+	channels := map[string][]byte{
+		"stable-4.12": []byte(`{"nodes":[{"version":"4.12.1","payload":"registry/ocp@sha256:4121"},{"version":"4.11.9","payload":"registry/ocp@sha256:4119"}]}`),
+		"stable-4.13": []byte(`{"nodes":[{"version":"4.13.4","payload":"registry/ocp@sha256:4134"},{"version":"4.12.1","payload":"registry/ocp@sha256:4121"}]}`),
+		"stable-4.14": []byte(`{"nodes":[{"version":"4.14.0","payload":"registry/ocp@sha256:4140"},{"version":"4.13.4","payload":"registry/ocp@sha256:4134"}]}`),
+	}
+	var testCases = []struct {
+		name             string
+		versionRange     string
+		expectedPullspec string
+		expectedVersion  string
+		expectedErr      bool
+	}{
+		{
+			name:             "newest release in range across channels",
+			versionRange:     ">=4.12.0 <4.14.0",
+			expectedPullspec: "registry/ocp@sha256:4134",
+			expectedVersion:  "4.13.4",
+		},
+		{
+			name:             "upper bound is inclusive",
+			versionRange:     ">=4.12.0 <=4.14.0",
+			expectedPullspec: "registry/ocp@sha256:4140",
+			expectedVersion:  "4.14.0",
+		},
+		{
+			name:         "no release in range",
+			versionRange: ">4.13.4 <4.14.0",
+			expectedErr:  true,
+		},
+		{
+			name:         "open-ended range",
+			versionRange: ">=4.12.0",
+			expectedErr:  true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				raw, ok := channels[r.URL.Query().Get("channel")]
+				if !ok {
+					raw = []byte(`{"nodes":[]}`)
+				}
+				if _, err := w.Write(raw); err != nil {
+					t.Errorf("failed to write data: %v", err)
+				}
+			}))
+			defer testServer.Close()
+			release := api.Release{
+				Architecture: api.ReleaseArchitectureAMD64,
+				Channel:      api.ReleaseChannelStable,
+				VersionRange: testCase.versionRange,
+			}
+			pullspec, version, err := resolvePullSpecInRange(&http.Client{}, testServer.URL, release)
+			if err != nil && !testCase.expectedErr {
+				t.Errorf("expected no error but got one: %v", err)
+			}
+			if err == nil && testCase.expectedErr {
+				t.Error("expected an error but got none")
+			}
+			if pullspec != testCase.expectedPullspec {
+				t.Errorf("got incorrect pullspec: %v", cmp.Diff(pullspec, testCase.expectedPullspec))
+			}
+			if version != testCase.expectedVersion {
+				t.Errorf("got incorrect version: %v", cmp.Diff(version, testCase.expectedVersion))
+			}
+		})
+	}
+}
+
 func TestLatestPullSpec(t *testing.T) {
 	pullspec, version := latestPullSpecAndVersion([]Release{
 		{Version: "4.2.19", Payload: "quay.io/openshift-release-dev/ocp-release@sha256:b51a0c316bb0c11686e6b038ec7c9f7ff96763f47a53c3443ac82e8c054bc035"},
@@ -0,0 +1,229 @@
+// Package resolvercache caches the configurations the configresolver
+// resolves, so that identical requests do not resolve the same configuration
+// against the same registry over and over again.
+package resolvercache
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+var requestsMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "configresolver_cache_requests_total",
+		Help: "cache lookups of resolved configurations by store and result",
+	},
+	[]string{"store", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(requestsMetric)
+}
+
+// Key identifies a resolved configuration. Configurations are identified by
+// their content as well as the revision of the registry they were resolved
+// against, so a cached configuration is never stale and caches may be shared
+// between resolvers which loaded the same registry.
+type Key struct {
+	Metadata api.Metadata
+	// Config is the digest of the unresolved configuration
+	Config string
+	// Revision is the revision of the registry
+	Revision string
+	// Generation orders the registries loaded by this resolver, so that
+	// values resolved against an older registry never evict newer ones
+	Generation int
+}
+
+// KeyFor determines the key of the configuration once resolved against the
+// registry at the revision, loaded as the generation
+func KeyFor(config api.ReleaseBuildConfiguration, revision string, generation int) (Key, error) {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return Key{}, fmt.Errorf("could not marshal configuration: %w", err)
+	}
+	return Key{Metadata: config.Metadata, Config: fmt.Sprintf("%x", sha256.Sum256(raw)), Revision: revision, Generation: generation}, nil
+}
+
+func (k Key) String() string {
+	return fmt.Sprintf("ci-operator-config:%s:%s:%s", k.Revision, k.Metadata.Basename(), k.Config)
+}
+
+// Store holds cached configurations
+type Store interface {
+	// Get returns the cached value of the key, if any
+	Get(key string) ([]byte, bool, error)
+	// Add caches the value of the key
+	Add(key string, value []byte) error
+	// Name identifies the store in metrics and logs
+	Name() string
+}
+
+// Cache looks up resolved configurations in its stores in order, filling
+// the stores which missed once a value is found or added. The in-memory
+// stores are emptied once a newer registry with a different revision is
+// loaded, as the cached values cannot be requested anymore. Values resolved
+// against an older registry are not cached.
+type Cache struct {
+	stores []Store
+
+	lock       sync.Mutex
+	revision   string
+	generation int
+}
+
+// New creates a cache backed by the stores, which are consulted in order
+func New(stores ...Store) *Cache {
+	return &Cache{stores: stores}
+}
+
+// Get returns the cached resolved configuration
+func (c *Cache) Get(key Key) ([]byte, bool) {
+	current := c.current(key)
+	for i, store := range c.stores {
+		value, ok, err := store.Get(key.String())
+		if err != nil {
+			logrus.WithError(err).WithField("store", store.Name()).Warn("Failed to read from the cache")
+		}
+		if !ok {
+			requestsMetric.WithLabelValues(store.Name(), "miss").Inc()
+			continue
+		}
+		requestsMetric.WithLabelValues(store.Name(), "hit").Inc()
+		if current {
+			c.add(c.stores[:i], key, value)
+		}
+		return value, true
+	}
+	return nil, false
+}
+
+// Add caches the resolved configuration in all stores, unless it was
+// resolved against an older registry than the latest one seen
+func (c *Cache) Add(key Key, value []byte) {
+	if c.current(key) {
+		c.add(c.stores, key, value)
+	}
+}
+
+func (c *Cache) add(stores []Store, key Key, value []byte) {
+	for _, store := range stores {
+		if err := store.Add(key.String(), value); err != nil {
+			logrus.WithError(err).WithField("store", store.Name()).Warn("Failed to write to the cache")
+		}
+	}
+}
+
+// purger is implemented by stores which can drop all their values
+type purger interface {
+	Purge()
+}
+
+// current determines whether the key is for the latest registry seen,
+// emptying the in-memory stores when it is for a newer registry with a
+// different revision
+func (c *Cache) current(key Key) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if key.Generation < c.generation {
+		return false
+	}
+	c.generation = key.Generation
+	if key.Revision == c.revision {
+		return true
+	}
+	c.revision = key.Revision
+	for _, store := range c.stores {
+		if p, ok := store.(purger); ok {
+			p.Purge()
+		}
+	}
+	return true
+}
+
+type lruStore struct {
+	cache *lru.Cache
+}
+
+// NewLRU creates an in-memory store holding up to `size` configurations,
+// dropping the least recently used ones first
+func NewLRU(size int) (Store, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &lruStore{cache: cache}, nil
+}
+
+func (s *lruStore) Get(key string) ([]byte, bool, error) {
+	value, ok := s.cache.Get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	return value.([]byte), true, nil
+}
+
+func (s *lruStore) Add(key string, value []byte) error {
+	s.cache.Add(key, value)
+	return nil
+}
+
+func (s *lruStore) Name() string { return "lru" }
+
+func (s *lruStore) Purge() { s.cache.Purge() }
+
+type redisStore struct {
+	pool *redis.Pool
+	ttl  time.Duration
+}
+
+// NewRedis creates a store backed by the Redis server at the address, which
+// may be shared by many resolvers. Values expire after the TTL, which must be
+// at least a millisecond.
+func NewRedis(address string, ttl time.Duration) (Store, error) {
+	if ttl < time.Millisecond {
+		return nil, fmt.Errorf("TTL must be at least a millisecond, got %s", ttl)
+	}
+	return &redisStore{
+		pool: &redis.Pool{
+			MaxIdle:     10,
+			IdleTimeout: 5 * time.Minute,
+			Dial: func() (redis.Conn, error) {
+				return redis.Dial("tcp", address, redis.DialConnectTimeout(5*time.Second), redis.DialReadTimeout(time.Second), redis.DialWriteTimeout(time.Second))
+			},
+		},
+		ttl: ttl,
+	}, nil
+}
+
+func (s *redisStore) Get(key string) ([]byte, bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+	value, err := redis.Bytes(conn.Do("GET", key))
+	if err == redis.ErrNil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s *redisStore) Add(key string, value []byte) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("SET", key, value, "PX", s.ttl.Milliseconds())
+	return err
+}
+
+func (s *redisStore) Name() string { return "redis" }
@@ -0,0 +1,108 @@
+package resolvercache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+type fakeStore struct {
+	values map[string][]byte
+	err    error
+}
+
+func (s *fakeStore) Get(key string) ([]byte, bool, error) {
+	value, ok := s.values[key]
+	return value, ok, s.err
+}
+
+func (s *fakeStore) Add(key string, value []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.values[key] = value
+	return nil
+}
+
+func (s *fakeStore) Name() string { return "fake" }
+
+func TestKeyFor(t *testing.T) {
+	config := api.ReleaseBuildConfiguration{Metadata: api.Metadata{Org: "org", Repo: "repo", Branch: "master"}}
+	key, err := KeyFor(config, "r1", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	same, _ := KeyFor(config, "r1", 1)
+	if key != same {
+		t.Errorf("keys of the same configuration differ: %s, %s", key, same)
+	}
+	config.Tests = []api.TestStepConfiguration{{As: "e2e"}}
+	changed, _ := KeyFor(config, "r1", 1)
+	if key == changed {
+		t.Error("keys of different configurations are the same")
+	}
+	other, _ := KeyFor(config, "r2", 2)
+	if changed == other {
+		t.Error("keys of different registry revisions are the same")
+	}
+}
+
+func TestCache(t *testing.T) {
+	local, err := NewLRU(2)
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	remote := &fakeStore{values: map[string][]byte{}}
+	cache := New(local, remote)
+	key := func(org, revision string) Key {
+		return Key{Metadata: api.Metadata{Org: org}, Config: "digest", Revision: revision, Generation: int(revision[1] - '0')}
+	}
+	get := func(key Key, expected string) {
+		t.Helper()
+		value, ok := cache.Get(key)
+		if diff := cmp.Diff(expected, string(value)); diff != "" || ok != (expected != "") {
+			t.Errorf("unexpected value for %s: %s", key, diff)
+		}
+	}
+
+	get(key("a", "r1"), "")
+	cache.Add(key("a", "r1"), []byte("resolved a"))
+	get(key("a", "r1"), "resolved a")
+
+	// values found in later stores are added to earlier ones
+	remote.values[key("b", "r1").String()] = []byte("resolved b")
+	get(key("b", "r1"), "resolved b")
+	remote.values = map[string][]byte{}
+	get(key("b", "r1"), "resolved b")
+
+	// the in-memory store is emptied once the registry changes
+	get(key("a", "r2"), "")
+	if value, ok, _ := local.Get(key("a", "r1").String()); ok {
+		t.Errorf("expected values of the old revision to be dropped, got %s", value)
+	}
+
+	// values resolved against an older registry are not cached and do not
+	// empty the in-memory store
+	cache.Add(key("a", "r2"), []byte("resolved a"))
+	cache.Add(key("d", "r1"), []byte("resolved d"))
+	get(key("d", "r1"), "")
+	get(key("a", "r2"), "resolved a")
+
+	// failing stores are skipped
+	remote.err = errors.New("injected failure")
+	cache.Add(key("c", "r2"), []byte("resolved c"))
+	get(key("c", "r2"), "resolved c")
+}
+
+func TestNewRedis(t *testing.T) {
+	if _, err := NewRedis("localhost:6379", 0); err == nil {
+		t.Error("expected a zero TTL to be rejected")
+	}
+	if _, err := NewRedis("localhost:6379", time.Minute); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -52,6 +52,22 @@ func FullReason(err error) string {
 	return reasonedError.FullReason()
 }
 
+// HasReason determines whether the reason is anywhere in the chain of
+// reasons of the error
+func HasReason(err error, reason Reason) bool {
+	for err != nil {
+		reasonedError := &Error{}
+		if !errors.As(err, &reasonedError) {
+			return false
+		}
+		if reasonedError.reason == reason {
+			return true
+		}
+		err = reasonedError.wrapped
+	}
+	return false
+}
+
 // BuilderWithReason starts the builder chain
 type BuilderWithReason struct {
 	Error
@@ -45,6 +45,13 @@ func TestError(t *testing.T) {
 	if actual, expected := FullReason(unchanged), "oops"; actual != expected {
 		t.Errorf("got incorrect reason for unchanged error; expected %s, got %v", expected, actual)
 	}
+
+	if !HasReason(third, "oops") || !HasReason(third, "argh") {
+		t.Errorf("expected every reason of the chain to be found in %v", third)
+	}
+	if HasReason(third, "simple") || HasReason(base, "unknown") || HasReason(nil, "oops") {
+		t.Errorf("expected reasons not in the chain not to be found")
+	}
 }
 
 func TestComplexError(t *testing.T) {
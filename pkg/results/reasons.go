@@ -0,0 +1,323 @@
+package results
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reasons are nested from the phase of the job down to the failure mode, so
+// the full reason of an error reads like building_project_image:push_failed.
+// Every reason a step may emit is declared here and its place in the
+// hierarchy is recorded in the Taxonomy, so that dashboards can rely on them.
+const (
+	// ReasonLoadingArgs is used when the arguments of ci-operator are invalid
+	ReasonLoadingArgs Reason = "loading_args"
+	// ReasonLoadingConfig is used when the configuration could not be loaded
+	ReasonLoadingConfig Reason = "loading_config"
+	// ReasonConfigResolver is used when the configuration could not be
+	// resolved by the configuration resolver
+	ReasonConfigResolver Reason = "config_resolver"
+	// ReasonConfigResolverLiteral is used when a literal configuration could
+	// not be resolved by the configuration resolver
+	ReasonConfigResolverLiteral Reason = "config_resolver_literal"
+	// ReasonValidatingConfig is used when the configuration is invalid
+	ReasonValidatingConfig Reason = "validating_config"
+	// ReasonDefaultingConfig is used when steps could not be generated from
+	// the configuration
+	ReasonDefaultingConfig Reason = "defaulting_config"
+	// ReasonResolvingRelease is used when a release could not be resolved
+	ReasonResolvingRelease Reason = "resolving_release"
+	// ReasonReadingRelease is used when a release pull spec could not be read
+	ReasonReadingRelease Reason = "reading_release"
+	// ReasonResolvingInputs is used when the inputs of the job could not be
+	// resolved
+	ReasonResolvingInputs Reason = "resolving_inputs"
+	// ReasonBuildingGraph is used when the execution graph is invalid
+	ReasonBuildingGraph Reason = "building_graph"
+	// ReasonInitializingNamespace is used when the test namespace could not
+	// be set up
+	ReasonInitializingNamespace Reason = "initializing_namespace"
+	// ReasonCreatingServiceAccount is used when a service account could not
+	// be created
+	ReasonCreatingServiceAccount Reason = "creating_service_account"
+	// ReasonCreatingRoles is used when a role could not be created
+	ReasonCreatingRoles Reason = "creating_roles"
+	// ReasonBindingRoles is used when a role binding could not be created
+	ReasonBindingRoles Reason = "binding_roles"
+	// ReasonCreatingDockercfgSecrets is used when the pull secrets of a
+	// service account were not created in time
+	ReasonCreatingDockercfgSecrets Reason = "create_dockercfg_secrets"
+	// ReasonExecutingGraph is used when steps of the graph failed
+	ReasonExecutingGraph Reason = "executing_graph"
+	// ReasonExecutingPost is used when a post step of the job failed
+	ReasonExecutingPost Reason = "executing_post"
+	// ReasonStepFailed is used for the failure of a single step, which
+	// carries the reason of the step
+	ReasonStepFailed Reason = "step_failed"
+	// ReasonInterrupted is used when the job was interrupted
+	ReasonInterrupted Reason = "interrupted"
+	// ReasonDeduplicated is used when the job was skipped because another
+	// job ran with identical inputs
+	ReasonDeduplicated Reason = "deduplicated"
+)
+
+// Reasons of the steps of the graph
+const (
+	// ReasonCloningSource is used by the step cloning the source code
+	ReasonCloningSource Reason = "cloning_source"
+	// ReasonBuildingImageFromSource is used by steps building an image from
+	// a git repository
+	ReasonBuildingImageFromSource Reason = "building_image_from_source"
+	// ReasonBuildingCacheImage is used by steps building a pipeline cache
+	ReasonBuildingCacheImage Reason = "building_cache_image"
+	// ReasonBuildingProjectImage is used by steps building an image from the
+	// project
+	ReasonBuildingProjectImage Reason = "building_project_image"
+	// ReasonBuildingIndexGenerator is used by steps generating an operator
+	// index
+	ReasonBuildingIndexGenerator Reason = "building_index_generator"
+	// ReasonGeneratingIndex is used when the bundles of an index are invalid
+	ReasonGeneratingIndex Reason = "generating_index"
+	// ReasonBuildingBundleSource is used by steps building an operator bundle
+	// source
+	ReasonBuildingBundleSource Reason = "building_bundle_source"
+	// ReasonInjectingRPMs is used by steps injecting the RPM repository
+	ReasonInjectingRPMs Reason = "injecting_rpms"
+	// ReasonServingRPMs is used by steps serving RPMs
+	ReasonServingRPMs Reason = "serving_rpms"
+	// ReasonTaggingInputImage is used by steps importing an input image
+	ReasonTaggingInputImage Reason = "tagging_input_image"
+	// ReasonTaggingOutputImage is used by steps tagging an output image
+	ReasonTaggingOutputImage Reason = "tagging_output_image"
+	// ReasonImportingExternalImage is used by steps importing an external
+	// image
+	ReasonImportingExternalImage Reason = "importing_external_image"
+	// ReasonExternalImageDigestMismatch is used when an external image did
+	// not resolve to the expected digest
+	ReasonExternalImageDigestMismatch Reason = "external_image_digest_mismatch"
+	// ReasonImportingRelease is used by steps importing a release payload
+	ReasonImportingRelease Reason = "importing_release"
+	// ReasonAssemblingRelease is used by steps assembling a release payload
+	ReasonAssemblingRelease Reason = "assembling_release"
+	// ReasonCreatingReleaseStream is used when the image stream of a release
+	// could not be created
+	ReasonCreatingReleaseStream Reason = "creating_release_stream"
+	// ReasonAssemblingReleaseStream is used when the image stream of a
+	// release could not be assembled
+	ReasonAssemblingReleaseStream Reason = "assembling_release_stream"
+	// ReasonMissingCLI is used when no cli image was built for a release
+	ReasonMissingCLI Reason = "missing_cli"
+	// ReasonMissingCVO is used when no cluster-version-operator image was
+	// built for a release
+	ReasonMissingCVO Reason = "missing_cvo"
+	// ReasonMissingRelease is used when the image stream of a release does
+	// not exist
+	ReasonMissingRelease Reason = "missing_release"
+	// ReasonInvalidRelease is used when the configuration of a release is
+	// invalid
+	ReasonInvalidRelease Reason = "invalid_release"
+	// ReasonCreatingRelease is used when the release payload could not be
+	// created
+	ReasonCreatingRelease Reason = "creating_release"
+	// ReasonOverridingComponents is used when components of a release could
+	// not be overridden
+	ReasonOverridingComponents Reason = "overriding_components"
+	// ReasonCreatingStableImages is used by steps tagging the stable images
+	ReasonCreatingStableImages Reason = "creating_stable_images"
+	// ReasonCreatingReleaseImages is used by steps tagging the images of a
+	// release
+	ReasonCreatingReleaseImages Reason = "creating_release_images"
+	// ReasonPromotingImages is used by steps promoting images
+	ReasonPromotingImages Reason = "promoting_images"
+	// ReasonMirroringImages is used by steps mirroring images
+	ReasonMirroringImages Reason = "mirroring_images"
+	// ReasonScanningImages is used by steps scanning images for
+	// vulnerabilities
+	ReasonScanningImages Reason = "scanning_images"
+	// ReasonCheckingFIPS is used by steps checking images for cryptography
+	// which is not FIPS-compliant
+	ReasonCheckingFIPS Reason = "checking_fips"
+	// ReasonWaitingForApproval is used by steps waiting for the approval of
+	// a promotion
+	ReasonWaitingForApproval Reason = "waiting_for_approval"
+	// ReasonGeneratingAttestations is used by steps generating attestations
+	ReasonGeneratingAttestations Reason = "generating_attestations"
+	// ReasonExecutingTemplate is used by template tests
+	ReasonExecutingTemplate Reason = "executing_template"
+	// ReasonExecutingComparison is used by steps comparing outputs
+	ReasonExecutingComparison Reason = "executing_comparison"
+	// ReasonRunningPod is used by container tests
+	ReasonRunningPod Reason = "running_pod"
+	// ReasonExecutingMultiStageTest is used by multi-stage tests
+	ReasonExecutingMultiStageTest Reason = "executing_multi_stage_test"
+	// ReasonProvisioningCluster is used when the cluster of a multi-stage
+	// test could not be provisioned
+	ReasonProvisioningCluster Reason = "provisioning_cluster"
+	// ReasonPreStepsFailed is used when a pre step of a multi-stage test
+	// failed
+	ReasonPreStepsFailed Reason = "pre_steps_failed"
+	// ReasonTestStepsFailed is used when a test step of a multi-stage test
+	// failed
+	ReasonTestStepsFailed Reason = "test_steps_failed"
+	// ReasonPostStepsFailed is used when a post step of a multi-stage test
+	// failed
+	ReasonPostStepsFailed Reason = "post_steps_failed"
+	// ReasonInstallingCluster is used by steps installing a cluster
+	ReasonInstallingCluster Reason = "installing_cluster"
+	// ReasonMissingClusterProfile is used when the secret of a cluster
+	// profile does not exist
+	ReasonMissingClusterProfile Reason = "missing_cluster_profile"
+	// ReasonExecutingTest is used for the failure of a test, which carries
+	// the reason of the step running the test
+	ReasonExecutingTest Reason = "executing_test"
+	// ReasonUtilizingLease is used by steps holding leases
+	ReasonUtilizingLease Reason = "utilizing_lease"
+	// ReasonAcquiringLease is used when a lease could not be acquired; it is
+	// followed by the type of the leased resource
+	ReasonAcquiringLease Reason = "acquiring_lease"
+	// ReasonReleasingLease is used when a lease could not be released
+	ReasonReleasingLease Reason = "releasing_lease"
+	// ReasonWritingParameters is used by steps writing parameters to a file
+	ReasonWritingParameters Reason = "writing_parameters"
+)
+
+// Failure modes, nested under the reason of the step that failed
+const (
+	// ReasonCloneAuth is used when the source could not be cloned because
+	// the credentials were missing or rejected
+	ReasonCloneAuth Reason = "clone_auth"
+	// ReasonCloneDrift is used when the cloned source does not match the
+	// requested refs
+	ReasonCloneDrift Reason = "clone_drift"
+	// ReasonCloneFailed is used when the source could not be fetched for
+	// any other reason
+	ReasonCloneFailed Reason = "clone_failed"
+	// ReasonPullFailed is used when a build could not pull its base images
+	ReasonPullFailed Reason = "pull_failed"
+	// ReasonPushFailed is used when a build could not push its output image
+	ReasonPushFailed Reason = "push_failed"
+	// ReasonBuildFailed is used when the build itself failed, most likely
+	// from an error in the code or the Dockerfile
+	ReasonBuildFailed Reason = "build_failed"
+	// ReasonOutOfMemory is used when a build was killed for running out of
+	// memory
+	ReasonOutOfMemory Reason = "out_of_memory"
+	// ReasonInfrastructure is used when a build failed because of the
+	// cluster it ran on
+	ReasonInfrastructure Reason = "infrastructure"
+	// ReasonCancelled is used when a build was cancelled
+	ReasonCancelled Reason = "cancelled"
+	// ReasonHermeticViolation is used when a hermetic build failed reaching
+	// for the network
+	ReasonHermeticViolation Reason = "hermetic_violation"
+	// ReasonUnauthorized is used when an import was rejected for the
+	// credentials of the import
+	ReasonUnauthorized Reason = "unauthorized"
+	// ReasonNotFound is used when the imported image does not exist
+	ReasonNotFound Reason = "not_found"
+	// ReasonThrottled is used when the registry kept throttling the import
+	// until it was given up
+	ReasonThrottled Reason = "throttled"
+	// ReasonImportFailed is used when the import failed for any other reason
+	ReasonImportFailed Reason = "import_failed"
+	// ReasonClusterQuotaExceeded is used when a cluster was not provisioned
+	// because the repository holds as many clusters as its quota allows
+	ReasonClusterQuotaExceeded Reason = "cluster_quota_exceeded"
+)
+
+// reasonAny allows any reason to be nested, for reasons which are followed
+// by a name rather than a reason
+const reasonAny Reason = "*"
+
+var (
+	buildFailures  = []Reason{ReasonPullFailed, ReasonPushFailed, ReasonBuildFailed, ReasonOutOfMemory, ReasonInfrastructure, ReasonCancelled, ReasonHermeticViolation}
+	cloneFailures  = append([]Reason{ReasonCloneAuth, ReasonCloneDrift, ReasonCloneFailed}, buildFailures...)
+	importFailures = []Reason{ReasonUnauthorized, ReasonNotFound, ReasonThrottled, ReasonImportFailed}
+
+	stepReasons = []Reason{
+		ReasonCloningSource, ReasonBuildingImageFromSource, ReasonBuildingCacheImage, ReasonBuildingProjectImage,
+		ReasonBuildingIndexGenerator, ReasonBuildingBundleSource, ReasonInjectingRPMs, ReasonServingRPMs,
+		ReasonTaggingInputImage, ReasonTaggingOutputImage, ReasonImportingExternalImage, ReasonImportingRelease,
+		ReasonAssemblingRelease, ReasonCreatingStableImages, ReasonCreatingReleaseImages, ReasonPromotingImages,
+		ReasonMirroringImages, ReasonScanningImages, ReasonCheckingFIPS, ReasonGeneratingAttestations, ReasonExecutingTemplate,
+		ReasonExecutingComparison, ReasonRunningPod, ReasonExecutingMultiStageTest, ReasonInstallingCluster,
+		ReasonUtilizingLease, ReasonReleasingLease, ReasonWritingParameters,
+	}
+)
+
+// Taxonomy records the reasons which may be nested under every reason;
+// reasons missing from the taxonomy do not nest other reasons
+var Taxonomy = map[Reason][]Reason{
+	ReasonLoadingArgs:           {ReasonLoadingConfig, ReasonValidatingConfig},
+	ReasonLoadingConfig:         {ReasonConfigResolver, ReasonConfigResolverLiteral},
+	ReasonDefaultingConfig:      {ReasonResolvingRelease, ReasonReadingRelease},
+	ReasonResolvingInputs:       nil,
+	ReasonBuildingGraph:         nil,
+	ReasonInitializingNamespace: {ReasonCreatingServiceAccount, ReasonCreatingRoles, ReasonBindingRoles, ReasonCreatingDockercfgSecrets},
+	ReasonExecutingGraph:        {ReasonStepFailed, ReasonInterrupted},
+	ReasonExecutingPost:         stepReasons,
+	ReasonStepFailed:            stepReasons,
+	ReasonDeduplicated:          nil,
+
+	ReasonCloningSource:           cloneFailures,
+	ReasonBuildingImageFromSource: cloneFailures,
+	ReasonBuildingCacheImage:      buildFailures,
+	ReasonBuildingProjectImage:    buildFailures,
+	ReasonBuildingIndexGenerator:  append([]Reason{ReasonGeneratingIndex}, buildFailures...),
+	ReasonBuildingBundleSource:    buildFailures,
+	ReasonInjectingRPMs:           buildFailures,
+	ReasonTaggingInputImage:       importFailures,
+	ReasonImportingExternalImage:  append([]Reason{ReasonExternalImageDigestMismatch}, importFailures...),
+	ReasonAssemblingRelease: {
+		ReasonCreatingReleaseStream, ReasonAssemblingReleaseStream, ReasonMissingCLI, ReasonMissingCVO,
+		ReasonMissingRelease, ReasonInvalidRelease, ReasonCreatingRelease, ReasonOverridingComponents,
+	},
+	ReasonExecutingMultiStageTest: {ReasonProvisioningCluster, ReasonPreStepsFailed, ReasonTestStepsFailed, ReasonPostStepsFailed},
+	ReasonProvisioningCluster:     {ReasonClusterQuotaExceeded},
+	ReasonInstallingCluster:       {ReasonMissingClusterProfile},
+	ReasonExecutingTest:           stepReasons,
+	ReasonUtilizingLease:          {ReasonAcquiringLease, ReasonExecutingTest, ReasonReleasingLease},
+	ReasonAcquiringLease:          {reasonAny},
+}
+
+// ValidateReason determines whether a full reason, as produced by FullReason,
+// follows the taxonomy: every reason must be declared and nested under a
+// reason that allows it
+func ValidateReason(fullReason string) error {
+	reasons := strings.Split(fullReason, ":")
+	if reason := Reason(reasons[0]); !isDeclared(reason) {
+		return fmt.Errorf("reason %s is not declared", reason)
+	}
+	for i := 1; i < len(reasons); i++ {
+		parent, child := Reason(reasons[i-1]), Reason(reasons[i])
+		allowed := Taxonomy[parent]
+		if contains(allowed, reasonAny) {
+			return nil
+		}
+		if !contains(allowed, child) {
+			return fmt.Errorf("reason %s may not be nested under %s", child, parent)
+		}
+	}
+	return nil
+}
+
+func isDeclared(reason Reason) bool {
+	if _, declared := Taxonomy[reason]; declared || reason == ReasonUnknown {
+		return true
+	}
+	for _, children := range Taxonomy {
+		if contains(children, reason) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(reasons []Reason, reason Reason) bool {
+	for _, r := range reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package results
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidateReason(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name: "failure mode of a step in the graph",
+			err: ForReason(ReasonExecutingGraph).ForError(
+				ForReason(ReasonStepFailed).ForError(
+					ForReason(ReasonBuildingProjectImage).ForError(
+						ForReason(ReasonPushFailed).ForError(errors.New("oops"))))),
+		},
+		{
+			name: "step reported on its own",
+			err:  ForReason(ReasonCloningSource).ForError(ForReason(ReasonCloneAuth).ForError(errors.New("oops"))),
+		},
+		{
+			name: "lease of a resource type",
+			err:  ForReason(ReasonUtilizingLease).ForError(ForReason(Reason("acquiring_lease:aws-quota-slice")).ForError(errors.New("oops"))),
+		},
+		{
+			name: "unknown error",
+			err:  errors.New("oops"),
+		},
+		{
+			name:     "undeclared reason",
+			err:      ForReason("whoopsie").ForError(errors.New("oops")),
+			expected: "reason whoopsie is not declared",
+		},
+		{
+			name:     "failure mode nested under the wrong step",
+			err:      ForReason(ReasonPromotingImages).ForError(ForReason(ReasonCloneAuth).ForError(errors.New("oops"))),
+			expected: "reason clone_auth may not be nested under promoting_images",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			var actual string
+			if err := ValidateReason(FullReason(testCase.err)); err != nil {
+				actual = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+		})
+	}
+}
@@ -1,8 +1,8 @@
 package results
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"google.golang.org/api/option"
 
 	"github.com/openshift/ci-tools/pkg/api"
 )
@@ -17,18 +18,38 @@ import (
 const (
 	// reportAddress is the default result aggregator address in api.ci
 	reportAddress = "https://result-aggregator-ci.apps.ci.l2s4.p1.openshiftapps.com"
+
+	// ResultPath is where the result aggregator receives the results of jobs
+	ResultPath = "/result"
+	// StepResultPath is where the result aggregator receives the results of
+	// steps, which are counted apart from the results of jobs
+	StepResultPath = "/step-result"
 )
 
 // Options holds the configuration options for connecting to the remote aggregation server
+// and to the other sinks results are streamed to
 type Options struct {
 	address     string
 	credentials string
+
+	bigQueryTable       string
+	bigQueryCredentials string
+
+	kafkaAddress string
+	kafkaTopic   string
+
+	reportSteps bool
 }
 
 // Bind adds flags for the options
 func (o *Options) Bind(flag *flag.FlagSet) {
 	flag.StringVar(&o.address, "report-address", reportAddress, "Address of the aggregate reporting server.")
 	flag.StringVar(&o.credentials, "report-credentials-file", "", "File holding the <username>:<password> for the aggregate reporting server.")
+	flag.StringVar(&o.bigQueryTable, "report-bigquery-table", "", "BigQuery table, as <project>.<dataset>.<table>, to insert results into.")
+	flag.StringVar(&o.bigQueryCredentials, "report-bigquery-credentials-file", "", "File holding the GCP service account credentials for the BigQuery table.")
+	flag.StringVar(&o.kafkaAddress, "report-kafka-address", "", "Address of the Kafka REST proxy to produce results through.")
+	flag.StringVar(&o.kafkaTopic, "report-kafka-topic", "", "Kafka topic to produce results to.")
+	flag.BoolVar(&o.reportSteps, "report-steps", false, "Report the outcome of every step of the graph in addition to the result of the job.")
 }
 
 func getUsernameAndPassword(credentials string) (string, string, error) {
@@ -43,23 +64,48 @@ func getUsernameAndPassword(credentials string) (string, string, error) {
 	return strings.TrimSpace(splits[0]), strings.Trim(splits[1], "\n "), nil
 }
 
-// Client returns an HTTP or HTTPs client, based on the options
-func (o *Options) Reporter(spec *api.JobSpec, consoleHost string) (Reporter, error) {
-	if o.address == "" || o.credentials == "" {
-		return &noopReporter{}, nil
+// Reporter returns a reporter sending results to every sink configured by the options
+func (o *Options) Reporter(spec *api.JobSpec, consoleHost string, contacts *api.Contacts) (Reporter, error) {
+	var sinks []Sink
+	if o.address != "" && o.credentials != "" {
+		username, password, err := getUsernameAndPassword(o.credentials)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get username and password: %w", err)
+		}
+		sinks = append(sinks, &aggregatorSink{
+			client:   &http.Client{},
+			address:  o.address,
+			username: username,
+			password: password,
+		})
 	}
-	username, password, err := getUsernameAndPassword(o.credentials)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get username and password: %w", err)
+	if o.bigQueryTable != "" {
+		var opts []option.ClientOption
+		if o.bigQueryCredentials != "" {
+			opts = append(opts, option.WithCredentialsFile(o.bigQueryCredentials))
+		}
+		sink, err := newBigQuerySink(context.Background(), o.bigQueryTable, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the BigQuery sink: %w", err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if o.kafkaAddress != "" || o.kafkaTopic != "" {
+		if o.kafkaAddress == "" || o.kafkaTopic == "" {
+			return nil, errors.New("both --report-kafka-address and --report-kafka-topic are required to report to Kafka")
+		}
+		sinks = append(sinks, &kafkaSink{client: &http.Client{}, address: o.kafkaAddress, topic: o.kafkaTopic})
+	}
+	if len(sinks) == 0 {
+		return &noopReporter{}, nil
 	}
 
 	return &reporter{
 		spec:        spec,
-		address:     o.address,
 		consoleHost: consoleHost,
-		client:      &http.Client{},
-		username:    username,
-		password:    password,
+		contacts:    contacts,
+		sinks:       sinks,
+		reportSteps: o.reportSteps,
 	}, nil
 }
 
@@ -75,6 +121,11 @@ type Request struct {
 	State string `json:"state"`
 	// Reason is a colon-delimited list of reasons for failure
 	Reason string `json:"reason"`
+	// Step is the step of the graph the result is reported for, if the
+	// request does not report the result of the job
+	Step string `json:"step,omitempty"`
+	// Contacts identify the owners of the job, if they are configured
+	Contacts *api.Contacts `json:"contacts,omitempty"`
 }
 
 const (
@@ -87,22 +138,27 @@ type Reporter interface {
 	// This action is best-effort and errors are logged but not exposed.
 	// Err may be nil in which case a success is reported.
 	Report(err error)
+	// ReportStep sends a report for the outcome of a step of the graph,
+	// if the reporter is configured to report steps.
+	ReportStep(name string, err error)
 }
 
 type noopReporter struct{}
 
 func (r *noopReporter) Report(err error) {}
 
+func (r *noopReporter) ReportStep(name string, err error) {}
+
 type reporter struct {
-	client             *http.Client
-	username, password string
-	address            string
+	sinks       []Sink
+	reportSteps bool
 
 	spec        *api.JobSpec
 	consoleHost string
+	contacts    *api.Contacts
 }
 
-func (r *reporter) Report(err error) {
+func (r *reporter) request(err error) Request {
 	state := StateSucceeded
 	if err != nil {
 		state = StateFailed
@@ -114,37 +170,36 @@ func (r *reporter) Report(err error) {
 		State:   state,
 		Reason:  FullReason(err),
 	}
-	data, err := json.Marshal(request)
-	if err != nil {
-		logrus.Tracef("could not marshal request: %v", err)
-		return
+	if state == StateFailed {
+		request.Contacts = r.contacts
 	}
+	return request
+}
 
+func (r *reporter) Report(err error) {
+	request := r.request(err)
 	reportMsg := fmt.Sprintf("Reporting job state '%s'", request.State)
-	if state != StateSucceeded {
+	if request.State != StateSucceeded {
 		reportMsg = fmt.Sprintf("Reporting job state '%s' with reason '%s'", request.State, request.Reason)
 	}
 
 	logrus.Infof(reportMsg)
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/result", r.address), bytes.NewReader(data))
-	if err != nil {
-		logrus.Tracef("could not create report request: %v", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth(r.username, r.password)
-	resp, err := r.client.Do(req)
-	if err != nil {
-		logrus.Tracef("could not send report request: %v", err)
+	r.send(request)
+}
+
+func (r *reporter) ReportStep(name string, err error) {
+	if !r.reportSteps {
 		return
 	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			logrus.Tracef("could not close report response: %v", err)
+	request := r.request(err)
+	request.Step = name
+	r.send(request)
+}
+
+func (r *reporter) send(request Request) {
+	for _, sink := range r.sinks {
+		if err := sink.Send(request); err != nil {
+			logrus.Tracef("could not report to %s: %v", sink.Name(), err)
 		}
-	}()
-	if resp != nil && resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		logrus.Tracef("response for report was not 200: %v", body)
 	}
 }
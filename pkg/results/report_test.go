@@ -27,6 +27,7 @@ func TestReporter_Report(t *testing.T) {
 		name        string
 		spec        *api.JobSpec
 		consoleHost string
+		contacts    *api.Contacts
 		err         error
 		expected    string
 	}{
@@ -58,6 +59,21 @@ func TestReporter_Report(t *testing.T) {
 			err:         ForReason("because").WithError(ForReason("something").ForError(errors.New("oops"))).Errorf("argh"),
 			expected:    `{"job_name":"runme","type":"presubmit","cluster":"foo.com","state":"failed","reason":"because:something"}`,
 		},
+		{
+			name:        "failure reports contacts",
+			spec:        &api.JobSpec{JobSpec: downwardapi.JobSpec{Job: "runme", Type: v1.PresubmitJob}},
+			consoleHost: "foo.com",
+			contacts:    &api.Contacts{Team: "Test Platform", SlackChannel: "#forum-testplatform"},
+			err:         ForReason("because").ForError(errors.New("oops")),
+			expected:    `{"job_name":"runme","type":"presubmit","cluster":"foo.com","state":"failed","reason":"because","contacts":{"team":"Test Platform","slack_channel":"#forum-testplatform"}}`,
+		},
+		{
+			name:        "success does not report contacts",
+			spec:        &api.JobSpec{JobSpec: downwardapi.JobSpec{Job: "runme", Type: v1.PresubmitJob}},
+			consoleHost: "foo.com",
+			contacts:    &api.Contacts{Team: "Test Platform", SlackChannel: "#forum-testplatform"},
+			expected:    `{"job_name":"runme","type":"presubmit","cluster":"foo.com","state":"succeeded","reason":"unknown"}`,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -90,14 +106,17 @@ func TestReporter_Report(t *testing.T) {
 			defer testServer.Close()
 
 			reporter := reporter{
-				client: &http.Client{
-					Transport: &http.Transport{
-						TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+				sinks: []Sink{&aggregatorSink{
+					client: &http.Client{
+						Transport: &http.Transport{
+							TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+						},
 					},
-				},
-				address:     testServer.URL,
+					address: testServer.URL,
+				}},
 				spec:        testCase.spec,
 				consoleHost: testCase.consoleHost,
+				contacts:    testCase.contacts,
 			}
 			reporter.Report(testCase.err)
 		})
@@ -107,7 +126,7 @@ func TestReporter_Report(t *testing.T) {
 func TestOptions_Reporter(t *testing.T) {
 	// this simulates the flow for ci-operator while we migrate to using the tool
 	options := Options{} // no flags set
-	reporter, err := options.Reporter(&api.JobSpec{JobSpec: downwardapi.JobSpec{Job: "runme", Type: v1.PresubmitJob}}, "http.com")
+	reporter, err := options.Reporter(&api.JobSpec{JobSpec: downwardapi.JobSpec{Job: "runme", Type: v1.PresubmitJob}}, "http.com", nil)
 	if err != nil {
 		t.Errorf("should not get an error creating a reporter, but got: %v", err)
 	}
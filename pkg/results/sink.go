@@ -0,0 +1,149 @@
+package results
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/option"
+)
+
+// Sink receives the results reported by ci-operator
+type Sink interface {
+	// Name identifies the sink in logs
+	Name() string
+	// Send delivers the request to the sink
+	Send(request Request) error
+}
+
+// aggregatorSink sends results to the result aggregation server, the
+// results of steps to a separate endpoint so they do not count as jobs
+type aggregatorSink struct {
+	client             *http.Client
+	username, password string
+	address            string
+}
+
+func (s *aggregatorSink) Name() string {
+	return "result aggregator"
+}
+
+func (s *aggregatorSink) Send(request Request) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("could not marshal request: %w", err)
+	}
+	path := ResultPath
+	if request.Step != "" {
+		path = StepResultPath
+	}
+	req, err := http.NewRequest(http.MethodPost, s.address+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not create report request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(s.username, s.password)
+	return do(s.client, req)
+}
+
+// kafkaSink produces results to a Kafka topic through a REST proxy, keyed
+// by the name of the job
+type kafkaSink struct {
+	client  *http.Client
+	address string
+	topic   string
+}
+
+type kafkaRecord struct {
+	Key   string  `json:"key"`
+	Value Request `json:"value"`
+}
+
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+func (s *kafkaSink) Name() string {
+	return fmt.Sprintf("Kafka topic %s", s.topic)
+}
+
+func (s *kafkaSink) Send(request Request) error {
+	data, err := json.Marshal(kafkaRecords{Records: []kafkaRecord{{Key: request.JobName, Value: request}}})
+	if err != nil {
+		return fmt.Errorf("could not marshal records: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/topics/%s", strings.TrimSuffix(s.address, "/"), s.topic), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not create produce request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	return do(s.client, req)
+}
+
+func do(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("response was not 200: %d %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// bigQuerySink streams results as rows into a BigQuery table, with a column
+// for every field of the request
+type bigQuerySink struct {
+	tables                  *bigquery.TabledataService
+	project, dataset, table string
+}
+
+func newBigQuerySink(ctx context.Context, table string, opts ...option.ClientOption) (*bigQuerySink, error) {
+	parts := strings.Split(table, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("BigQuery table %q must be of the form <project>.<dataset>.<table>", table)
+	}
+	service, err := bigquery.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create BigQuery client: %w", err)
+	}
+	return &bigQuerySink{tables: service.Tabledata, project: parts[0], dataset: parts[1], table: parts[2]}, nil
+}
+
+func (s *bigQuerySink) Name() string {
+	return fmt.Sprintf("BigQuery table %s.%s.%s", s.project, s.dataset, s.table)
+}
+
+func (s *bigQuerySink) Send(request Request) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("could not marshal request: %w", err)
+	}
+	var row map[string]bigquery.JsonValue
+	if err := json.Unmarshal(data, &row); err != nil {
+		return fmt.Errorf("could not convert request to a row: %w", err)
+	}
+	response, err := s.tables.InsertAll(s.project, s.dataset, s.table, &bigquery.TableDataInsertAllRequest{
+		Rows: []*bigquery.TableDataInsertAllRequestRows{{Json: row}},
+	}).Do()
+	if err != nil {
+		return fmt.Errorf("could not insert row: %w", err)
+	}
+	var messages []string
+	for _, insertErr := range response.InsertErrors {
+		for _, rowErr := range insertErr.Errors {
+			messages = append(messages, rowErr.Message)
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("could not insert row: %s", strings.Join(messages, ", "))
+	}
+	return nil
+}
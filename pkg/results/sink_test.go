@@ -0,0 +1,183 @@
+package results
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+
+	v1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+type recordedRequest struct {
+	method, path, contentType, body string
+}
+
+func recordingServer(t *testing.T, response string) (*httptest.Server, *[]recordedRequest) {
+	var requests []recordedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read body: %v", err)
+		}
+		requests = append(requests, recordedRequest{method: r.Method, path: r.URL.Path, contentType: r.Header.Get("Content-Type"), body: string(raw)})
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write([]byte(response)); err != nil {
+			t.Errorf("failed to write response: %v", err)
+		}
+	}))
+	return server, &requests
+}
+
+func TestAggregatorSink(t *testing.T) {
+	server, requests := recordingServer(t, "")
+	defer server.Close()
+
+	sink := &aggregatorSink{client: server.Client(), address: server.URL, username: "user", password: "pass"}
+	for _, request := range []Request{
+		{JobName: "runme", Type: "presubmit", Cluster: "foo.com", State: StateFailed, Reason: "because"},
+		{JobName: "runme", Type: "presubmit", Cluster: "foo.com", State: StateFailed, Reason: "because", Step: "e2e"},
+	} {
+		if err := sink.Send(request); err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+	}
+	expected := []recordedRequest{{
+		method:      http.MethodPost,
+		path:        "/result",
+		contentType: "application/json",
+		body:        `{"job_name":"runme","type":"presubmit","cluster":"foo.com","state":"failed","reason":"because"}`,
+	}, {
+		method:      http.MethodPost,
+		path:        "/step-result",
+		contentType: "application/json",
+		body:        `{"job_name":"runme","type":"presubmit","cluster":"foo.com","state":"failed","reason":"because","step":"e2e"}`,
+	}}
+	if diff := cmp.Diff(expected, *requests, cmp.AllowUnexported(recordedRequest{})); diff != "" {
+		t.Errorf("unexpected requests: %s", diff)
+	}
+}
+
+func TestKafkaSink(t *testing.T) {
+	server, requests := recordingServer(t, `{"offsets":[{"partition":0,"offset":1}]}`)
+	defer server.Close()
+
+	sink := &kafkaSink{client: server.Client(), address: server.URL + "/", topic: "ci-results"}
+	if err := sink.Send(Request{JobName: "runme", Type: "presubmit", Cluster: "foo.com", State: StateFailed, Reason: "because", Step: "e2e"}); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	expected := []recordedRequest{{
+		method:      http.MethodPost,
+		path:        "/topics/ci-results",
+		contentType: "application/vnd.kafka.json.v2+json",
+		body:        `{"records":[{"key":"runme","value":{"job_name":"runme","type":"presubmit","cluster":"foo.com","state":"failed","reason":"because","step":"e2e"}}]}`,
+	}}
+	if diff := cmp.Diff(expected, *requests, cmp.AllowUnexported(recordedRequest{})); diff != "" {
+		t.Errorf("unexpected requests: %s", diff)
+	}
+}
+
+func TestBigQuerySink(t *testing.T) {
+	if _, err := newBigQuerySink(context.Background(), "dataset.table", option.WithoutAuthentication()); err == nil {
+		t.Error("expected an error for a table without a project")
+	}
+
+	for _, testCase := range []struct {
+		name          string
+		response      string
+		expectedError string
+	}{
+		{
+			name:     "row is inserted",
+			response: `{"kind":"bigquery#tableDataInsertAllResponse"}`,
+		},
+		{
+			name:          "row is rejected",
+			response:      `{"insertErrors":[{"index":0,"errors":[{"message":"no such field: step"}]}]}`,
+			expectedError: "could not insert row: no such field: step",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			server, requests := recordingServer(t, testCase.response)
+			defer server.Close()
+
+			sink, err := newBigQuerySink(context.Background(), "project.dataset.table", option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+			if err != nil {
+				t.Fatalf("failed to create sink: %v", err)
+			}
+			var actualError string
+			if err := sink.Send(Request{JobName: "runme", Type: "periodic", Cluster: "foo.com", State: StateSucceeded, Reason: "unknown"}); err != nil {
+				actualError = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedError, actualError); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+			expected := []recordedRequest{{
+				method:      http.MethodPost,
+				path:        "/projects/project/datasets/dataset/tables/table/insertAll",
+				contentType: "application/json",
+				body:        `{"rows":[{"json":{"cluster":"foo.com","job_name":"runme","reason":"unknown","state":"succeeded","type":"periodic"}}]}` + "\n",
+			}}
+			if diff := cmp.Diff(expected, *requests, cmp.AllowUnexported(recordedRequest{})); diff != "" {
+				t.Errorf("unexpected requests: %s", diff)
+			}
+		})
+	}
+}
+
+type fakeSink struct {
+	requests []Request
+}
+
+func (s *fakeSink) Name() string { return "fake" }
+
+func (s *fakeSink) Send(request Request) error {
+	s.requests = append(s.requests, request)
+	return nil
+}
+
+func TestReporterReportStep(t *testing.T) {
+	spec := &api.JobSpec{JobSpec: downwardapi.JobSpec{Job: "runme", Type: v1.PresubmitJob}}
+	for _, testCase := range []struct {
+		name        string
+		reportSteps bool
+		expected    []Request
+	}{
+		{
+			name: "steps are not reported by default",
+			expected: []Request{
+				{JobName: "runme", Type: "presubmit", Cluster: "foo.com", State: StateFailed, Reason: "unknown"},
+			},
+		},
+		{
+			name:        "steps are reported to every sink",
+			reportSteps: true,
+			expected: []Request{
+				{JobName: "runme", Type: "presubmit", Cluster: "foo.com", State: StateSucceeded, Reason: "unknown", Step: "src"},
+				{JobName: "runme", Type: "presubmit", Cluster: "foo.com", State: StateFailed, Reason: "step_failed", Step: "e2e"},
+				{JobName: "runme", Type: "presubmit", Cluster: "foo.com", State: StateFailed, Reason: "unknown"},
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			first, second := &fakeSink{}, &fakeSink{}
+			r := &reporter{sinks: []Sink{first, second}, reportSteps: testCase.reportSteps, spec: spec, consoleHost: "foo.com"}
+			r.ReportStep("src", nil)
+			r.ReportStep("e2e", ForReason("step_failed").ForError(errors.New("oops")))
+			r.Report(errors.New("oops"))
+			for _, sink := range []*fakeSink{first, second} {
+				if diff := cmp.Diff(testCase.expected, sink.requests); diff != "" {
+					t.Errorf("unexpected requests: %s", diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package signing
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+const (
+	// PrivateKey is the key in the signing secret holding the cosign key
+	PrivateKey = "cosign.key"
+	// PasswordKey is the key in the signing secret holding the password of
+	// the cosign key
+	PasswordKey = "cosign.password"
+	// PublicKey is the key in the signing secret holding the public key
+	// signatures are verified with
+	PublicKey = "cosign.pub"
+
+	// IdentityTokenPath is where the token the promotion pod exchanges for a
+	// certificate is mounted with keyless signing
+	IdentityTokenPath = "/var/run/sigstore/token"
+	// IdentityTokenAudience is the audience of that token
+	IdentityTokenAudience = "sigstore"
+)
+
+// SignCommand is the command signing the image, which must be referenced by
+// digest. Signatures are pushed to the repository of the image.
+func SignCommand(reference string, config api.ImageSigningConfiguration) []string {
+	command := []string{"cosign", "sign", "--yes"}
+	if config.Keyless {
+		command = append(command, "--identity-token="+IdentityTokenPath)
+		if config.FulcioURL != "" {
+			command = append(command, "--fulcio-url="+config.FulcioURL)
+		}
+	} else {
+		command = append(command, "--key="+filepath.Join(api.ImageSigningKeySecretMountPath, PrivateKey))
+	}
+	command = append(command, transparencyLogArgs(config, "--tlog-upload=false")...)
+	return append(command, reference)
+}
+
+// VerifyCommand is the command verifying the signature of the image. With
+// signatures made with a key, publicKey is the path of the public key.
+func VerifyCommand(reference string, config api.ImageSigningConfiguration, publicKey string) []string {
+	command := []string{"cosign", "verify"}
+	if config.Keyless {
+		command = append(command, "--certificate-identity-regexp=.*", "--certificate-oidc-issuer="+config.OIDCIssuer)
+	} else {
+		command = append(command, "--key="+publicKey)
+	}
+	command = append(command, transparencyLogArgs(config, "--insecure-ignore-tlog=true")...)
+	return append(command, reference)
+}
+
+// transparencyLogArgs selects the transparency log, which is skipped for
+// signatures made with a key unless one is configured
+func transparencyLogArgs(config api.ImageSigningConfiguration, skip string) []string {
+	switch {
+	case config.RekorURL != "":
+		return []string{"--rekor-url=" + config.RekorURL}
+	case !config.Keyless:
+		return []string{skip}
+	}
+	return nil
+}
+
+// Verify checks the signature of a promoted image with the cosign binary.
+// Tests consuming promoted images use it to make sure they were published
+// by the promotion and not pushed by anyone else. With signatures made with
+// a key, publicKey is the key they are verified with. The registry is
+// accessed with the dockerConfig credentials, if any.
+func Verify(ctx context.Context, reference string, config api.ImageSigningConfiguration, publicKey, dockerConfig []byte) error {
+	dir, err := ioutil.TempDir("", "cosign")
+	if err != nil {
+		return fmt.Errorf("could not create temporary directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			logrus.WithError(err).Warn("Could not remove temporary directory.")
+		}
+	}()
+	keyPath := filepath.Join(dir, PublicKey)
+	if !config.Keyless {
+		if len(publicKey) == 0 {
+			return fmt.Errorf("could not verify signature of %s: no public key", reference)
+		}
+		if err := ioutil.WriteFile(keyPath, publicKey, 0600); err != nil {
+			return fmt.Errorf("could not write public key: %w", err)
+		}
+	}
+	if len(dockerConfig) != 0 {
+		if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), dockerConfig, 0600); err != nil {
+			return fmt.Errorf("could not write registry credentials: %w", err)
+		}
+	}
+	command := VerifyCommand(reference, config, keyPath)
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), "DOCKER_CONFIG="+dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("could not verify signature of %s: %w: %s", reference, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package signing
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestCommands(t *testing.T) {
+	reference := "quay.io/org/repo@sha256:abc"
+	var testCases = []struct {
+		name           string
+		config         api.ImageSigningConfiguration
+		expectedSign   []string
+		expectedVerify []string
+	}{
+		{
+			name:           "key without transparency log",
+			expectedSign:   []string{"cosign", "sign", "--yes", "--key=/etc/signing-key/cosign.key", "--tlog-upload=false", reference},
+			expectedVerify: []string{"cosign", "verify", "--key=/tmp/cosign.pub", "--insecure-ignore-tlog=true", reference},
+		},
+		{
+			name:           "key with transparency log",
+			config:         api.ImageSigningConfiguration{RekorURL: "https://rekor.example.com"},
+			expectedSign:   []string{"cosign", "sign", "--yes", "--key=/etc/signing-key/cosign.key", "--rekor-url=https://rekor.example.com", reference},
+			expectedVerify: []string{"cosign", "verify", "--key=/tmp/cosign.pub", "--rekor-url=https://rekor.example.com", reference},
+		},
+		{
+			name:           "keyless with public instances",
+			config:         api.ImageSigningConfiguration{Keyless: true, OIDCIssuer: "https://oidc.example.com"},
+			expectedSign:   []string{"cosign", "sign", "--yes", "--identity-token=/var/run/sigstore/token", reference},
+			expectedVerify: []string{"cosign", "verify", "--certificate-identity-regexp=.*", "--certificate-oidc-issuer=https://oidc.example.com", reference},
+		},
+		{
+			name:           "keyless with private Fulcio",
+			config:         api.ImageSigningConfiguration{Keyless: true, FulcioURL: "https://fulcio.example.com", RekorURL: "https://rekor.example.com", OIDCIssuer: "https://oidc.example.com"},
+			expectedSign:   []string{"cosign", "sign", "--yes", "--identity-token=/var/run/sigstore/token", "--fulcio-url=https://fulcio.example.com", "--rekor-url=https://rekor.example.com", reference},
+			expectedVerify: []string{"cosign", "verify", "--certificate-identity-regexp=.*", "--certificate-oidc-issuer=https://oidc.example.com", "--rekor-url=https://rekor.example.com", reference},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expectedSign, SignCommand(reference, testCase.config)); diff != "" {
+				t.Errorf("unexpected sign command: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expectedVerify, VerifyCommand(reference, testCase.config, "/tmp/cosign.pub")); diff != "" {
+				t.Errorf("unexpected verify command: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/release"
+	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+const (
+	approvalDefaultTimeout = time.Hour
+	// ApprovalArtifact records the decision in the artifacts of the job
+	ApprovalArtifact = "approval.json"
+)
+
+// Allow tests to accelerate polling
+var approvalInterval = 10 * time.Second
+
+// approvalDecision is the decision on a promotion, as the approval URL
+// responds with it and as it is recorded
+type approvalDecision struct {
+	Decision string `json:"decision"`
+	Approver string `json:"approver,omitempty"`
+	// Manager is the field manager the decision was annotated with, as the
+	// API server recorded it in the managed fields of the config map
+	Manager string    `json:"manager,omitempty"`
+	Source  string    `json:"source,omitempty"`
+	Time    time.Time `json:"time,omitempty"`
+}
+
+// approvalStep waits until the promotion of the job is approved
+type approvalStep struct {
+	config     api.ApprovalConfiguration
+	client     loggingclient.LoggingClient
+	httpClient release.HTTPClient
+	jobSpec    *api.JobSpec
+}
+
+func (s *approvalStep) Inputs() (api.InputDefinition, error) {
+	return nil, nil
+}
+
+func (*approvalStep) Validate() error { return nil }
+
+func (s *approvalStep) Run(ctx context.Context) error {
+	return results.ForReason(results.ReasonWaitingForApproval).ForError(s.run(ctx))
+}
+
+func (s *approvalStep) run(ctx context.Context) error {
+	timeout := approvalDefaultTimeout
+	if s.config.Timeout != nil {
+		timeout = s.config.Timeout.Duration
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	poll := s.pollConfigMap
+	source := fmt.Sprintf("config map %s/%s", s.jobSpec.Namespace(), s.configMapName())
+	if s.config.URL != "" {
+		poll = s.pollURL
+		source = s.config.URL
+	} else if err := s.createConfigMap(ctx); err != nil {
+		return err
+	}
+	Logger(ctx).Infof("Waiting up to %s for the promotion to be approved through %s", timeout, source)
+
+	var decision *approvalDecision
+	err := wait.PollImmediateUntil(approvalInterval, func() (bool, error) {
+		var err error
+		decision, err = poll(ctx)
+		return decision != nil, err
+	}, ctx.Done())
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return fmt.Errorf("the promotion was not approved within %s", timeout)
+	}
+	if err != nil {
+		return err
+	}
+	decision.Source = source
+	if decision.Time.IsZero() {
+		decision.Time = time.Now().UTC()
+	}
+	if err := saveApprovalDecision(ctx, *decision); err != nil {
+		Logger(ctx).WithError(err).Warn("Could not record the approval decision.")
+	}
+	if decision.Decision == api.ApprovalDecisionRejected {
+		return fmt.Errorf("the promotion was rejected by %s", decision.Approver)
+	}
+	if decision.Manager != "" {
+		Logger(ctx).Infof("The promotion was approved by %s, annotated with %s", decision.Approver, decision.Manager)
+	} else {
+		Logger(ctx).Infof("The promotion was approved by %s", decision.Approver)
+	}
+	return nil
+}
+
+// configMapName is the name of the config map of this execution of the job,
+// so that decisions on earlier executions in the namespace do not apply
+func (s *approvalStep) configMapName() string {
+	return fmt.Sprintf("%s-%s", api.ApprovalConfigMap, s.jobSpec.BuildID)
+}
+
+// createConfigMap creates the config map approvers annotate, describing the
+// job that waits for them. A config map that already exists is reset, as
+// decisions on it were not made on this execution.
+func (s *approvalStep) createConfigMap(ctx context.Context) error {
+	configMap := &coreapi.ConfigMap{
+		ObjectMeta: meta.ObjectMeta{
+			Namespace: s.jobSpec.Namespace(),
+			Name:      s.configMapName(),
+		},
+		Data: map[string]string{
+			"job":      s.jobSpec.Job,
+			"build_id": s.jobSpec.BuildID,
+		},
+	}
+	if refs := s.jobSpec.Refs; refs != nil {
+		configMap.Data["refs"] = refs.String()
+	}
+	if owner := s.jobSpec.Owner(); owner != nil {
+		configMap.OwnerReferences = append(configMap.OwnerReferences, *owner)
+	}
+	err := s.client.Create(ctx, configMap.DeepCopy())
+	if err == nil {
+		return nil
+	}
+	if !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not create approval config map: %w", err)
+	}
+	existing := &coreapi.ConfigMap{}
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKeyFromObject(configMap), existing); err != nil {
+		return fmt.Errorf("could not get approval config map: %w", err)
+	}
+	delete(existing.Annotations, api.ApprovedByAnnotation)
+	delete(existing.Annotations, api.RejectedByAnnotation)
+	existing.Data = configMap.Data
+	existing.OwnerReferences = configMap.OwnerReferences
+	if err := s.client.Update(ctx, existing); err != nil {
+		return fmt.Errorf("could not reset approval config map: %w", err)
+	}
+	return nil
+}
+
+// pollConfigMap reads the decision from the annotations of the config map,
+// rejections taking precedence
+func (s *approvalStep) pollConfigMap(ctx context.Context) (*approvalDecision, error) {
+	configMap := &coreapi.ConfigMap{}
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: s.configMapName()}, configMap); err != nil {
+		return nil, fmt.Errorf("could not get approval config map: %w", err)
+	}
+	for _, annotation := range []struct {
+		name     string
+		decision string
+	}{
+		{name: api.RejectedByAnnotation, decision: api.ApprovalDecisionRejected},
+		{name: api.ApprovedByAnnotation, decision: api.ApprovalDecisionApproved},
+	} {
+		approver, ok := configMap.Annotations[annotation.name]
+		if !ok {
+			continue
+		}
+		decision := &approvalDecision{Decision: annotation.decision, Approver: approver}
+		if manager, ok := annotationManager(configMap.ManagedFields, annotation.name); ok {
+			decision.Manager = manager.Manager
+			if manager.Time != nil {
+				decision.Time = manager.Time.UTC()
+			}
+		}
+		return decision, nil
+	}
+	return nil, nil
+}
+
+// annotationManager finds the entry of the managed fields which owns the
+// annotation, naming the client which set it and when
+func annotationManager(entries []meta.ManagedFieldsEntry, annotation string) (meta.ManagedFieldsEntry, bool) {
+	for _, entry := range entries {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+		var fields struct {
+			Metadata struct {
+				Annotations map[string]json.RawMessage `json:"f:annotations"`
+			} `json:"f:metadata"`
+		}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if _, ok := fields.Metadata.Annotations["f:"+annotation]; ok {
+			return entry, true
+		}
+	}
+	return meta.ManagedFieldsEntry{}, false
+}
+
+// pollURL asks the approval URL for the decision. Failures to reach it are
+// retried until the timeout, as the service may be restarting.
+func (s *approvalStep) pollURL(ctx context.Context) (*approvalDecision, error) {
+	target, err := url.Parse(s.config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid approval URL: %w", err)
+	}
+	query := target.Query()
+	query.Set("namespace", s.jobSpec.Namespace())
+	query.Set("job", s.jobSpec.Job)
+	query.Set("build_id", s.jobSpec.BuildID)
+	target.RawQuery = query.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create approval request: %w", err)
+	}
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		Logger(ctx).WithError(err).Debug("Could not reach the approval URL.")
+		return nil, nil
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		Logger(ctx).Debugf("The approval URL responded with %s.", response.Status)
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		Logger(ctx).WithError(err).Debug("Could not read the response of the approval URL.")
+		return nil, nil
+	}
+	var decision approvalDecision
+	if err := json.Unmarshal(body, &decision); err != nil {
+		return nil, fmt.Errorf("invalid response from the approval URL: %w", err)
+	}
+	switch decision.Decision {
+	case api.ApprovalDecisionPending:
+		return nil, nil
+	case api.ApprovalDecisionApproved, api.ApprovalDecisionRejected:
+		if decision.Approver == "" {
+			return nil, fmt.Errorf("the approval URL responded with a decision without an approver")
+		}
+		return &decision, nil
+	default:
+		return nil, fmt.Errorf("the approval URL responded with an unknown decision %q", decision.Decision)
+	}
+}
+
+// saveApprovalDecision records who decided on the promotion, and when
+func saveApprovalDecision(ctx context.Context, decision approvalDecision) error {
+	artifactDir, set := api.ArtifactsFor(ctx)
+	if !set {
+		return nil
+	}
+	if err := os.MkdirAll(artifactDir, 0750); err != nil {
+		return fmt.Errorf("unable to create directory %s: %w", artifactDir, err)
+	}
+	raw, err := json.MarshalIndent(decision, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(artifactDir, ApprovalArtifact), raw, 0640)
+}
+
+func (s *approvalStep) Requires() []api.StepLink {
+	return []api.StepLink{api.AllStepsLink()}
+}
+
+func (s *approvalStep) Creates() []api.StepLink {
+	return []api.StepLink{}
+}
+
+func (s *approvalStep) Provides() api.ParameterMap {
+	return nil
+}
+
+func (s *approvalStep) Name() string { return "[approval]" }
+
+func (s *approvalStep) Description() string {
+	return "Wait for the promotion to be approved"
+}
+
+func (s *approvalStep) Objects() []ctrlruntimeclient.Object {
+	return s.client.Objects()
+}
+
+// ApprovalStep waits until the promotion of the job is approved, failing
+// when it is rejected or no decision is made in time.
+func ApprovalStep(config api.ApprovalConfiguration, client loggingclient.LoggingClient, httpClient release.HTTPClient, jobSpec *api.JobSpec) api.Step {
+	return &approvalStep{
+		config:     config,
+		client:     client,
+		httpClient: httpClient,
+		jobSpec:    jobSpec,
+	}
+}
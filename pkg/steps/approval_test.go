@@ -0,0 +1,145 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/release"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+func TestApprovalStep(t *testing.T) {
+	approvalInterval = 10 * time.Millisecond
+	defer func() { approvalInterval = 10 * time.Second }()
+	configMap := func(annotations map[string]string) *coreapi.ConfigMap {
+		return &coreapi.ConfigMap{ObjectMeta: meta.ObjectMeta{Namespace: "ci-op-1234", Name: "approval-42", Annotations: annotations}}
+	}
+	respond := func(status int, body string) release.HTTPClient {
+		return release.NewFakeHTTPClient(func(request *http.Request) (*http.Response, error) {
+			if request.URL.Query().Get("build_id") != "42" {
+				t.Errorf("unexpected query: %s", request.URL.RawQuery)
+			}
+			return &http.Response{StatusCode: status, Body: ioutil.NopCloser(bytes.NewBufferString(body))}, nil
+		})
+	}
+	timeout := &prowv1.Duration{Duration: 100 * time.Millisecond}
+	var testCases = []struct {
+		name        string
+		config      api.ApprovalConfiguration
+		existing    *coreapi.ConfigMap
+		annotations map[string]string
+		httpClient  release.HTTPClient
+		expectedErr string
+	}{
+		{
+			name:        "approved through the config map",
+			annotations: map[string]string{api.ApprovedByAnnotation: "alice"},
+		},
+		{
+			name:        "rejection takes precedence",
+			annotations: map[string]string{api.ApprovedByAnnotation: "alice", api.RejectedByAnnotation: "bob"},
+			expectedErr: "the promotion was rejected by bob",
+		},
+		{
+			name:        "decision on an existing config map is cleared",
+			config:      api.ApprovalConfiguration{Timeout: timeout},
+			existing:    configMap(map[string]string{api.ApprovedByAnnotation: "mallory"}),
+			expectedErr: "the promotion was not approved within 100ms",
+		},
+		{
+			name:        "no decision in time",
+			config:      api.ApprovalConfiguration{Timeout: timeout},
+			expectedErr: "the promotion was not approved within 100ms",
+		},
+		{
+			name:       "approved through the URL",
+			config:     api.ApprovalConfiguration{URL: "https://approvals.example.com/decision"},
+			httpClient: respond(http.StatusOK, `{"decision": "approved", "approver": "alice"}`),
+		},
+		{
+			name:        "pending on the URL",
+			config:      api.ApprovalConfiguration{URL: "https://approvals.example.com/decision", Timeout: timeout},
+			httpClient:  respond(http.StatusOK, `{"decision": "pending"}`),
+			expectedErr: "the promotion was not approved within 100ms",
+		},
+		{
+			name:        "URL fails to respond",
+			config:      api.ApprovalConfiguration{URL: "https://approvals.example.com/decision", Timeout: timeout},
+			httpClient:  respond(http.StatusServiceUnavailable, ""),
+			expectedErr: "the promotion was not approved within 100ms",
+		},
+		{
+			name:        "URL responds without an approver",
+			config:      api.ApprovalConfiguration{URL: "https://approvals.example.com/decision"},
+			httpClient:  respond(http.StatusOK, `{"decision": "approved"}`),
+			expectedErr: "the approval URL responded with a decision without an approver",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := fakectrlruntimeclient.NewFakeClient()
+			if testCase.existing != nil {
+				if err := client.Create(context.Background(), testCase.existing); err != nil {
+					t.Fatalf("could not create config map: %v", err)
+				}
+			}
+			if testCase.annotations != nil {
+				go func() {
+					if err := wait.PollImmediate(approvalInterval, time.Second, func() (bool, error) {
+						created := &coreapi.ConfigMap{}
+						if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ci-op-1234", Name: "approval-42"}, created); err != nil {
+							return false, nil
+						}
+						created.Annotations = testCase.annotations
+						return true, client.Update(context.Background(), created)
+					}); err != nil {
+						t.Errorf("could not annotate config map: %v", err)
+					}
+				}()
+			}
+			jobSpec := &api.JobSpec{JobSpec: downwardapi.JobSpec{Job: "promote", BuildID: "42"}}
+			jobSpec.SetNamespace("ci-op-1234")
+			step := ApprovalStep(testCase.config, loggingclient.New(client), testCase.httpClient, jobSpec)
+			var actualErr string
+			if err := step.Run(context.Background()); err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+		})
+	}
+}
+
+func TestAnnotationManager(t *testing.T) {
+	approved := meta.NewTime(time.Date(2021, 5, 4, 10, 0, 0, 0, time.UTC))
+	entries := []meta.ManagedFieldsEntry{
+		{Manager: "ci-operator", Operation: meta.ManagedFieldsOperationUpdate, FieldsV1: &meta.FieldsV1{Raw: []byte(`{"f:data":{".":{},"f:build_id":{},"f:job":{}}}`)}},
+		{Manager: "kubectl-annotate", Operation: meta.ManagedFieldsOperationUpdate, Time: &approved, FieldsV1: &meta.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{".":{},"f:ci.openshift.io/approved-by":{}}}}`)}},
+	}
+	entry, ok := annotationManager(entries, api.ApprovedByAnnotation)
+	if !ok {
+		t.Fatal("expected the manager of the annotation to be found")
+	}
+	if diff := cmp.Diff(entries[1], entry); diff != "" {
+		t.Errorf("unexpected manager: %s", diff)
+	}
+	if _, ok := annotationManager(entries, api.RejectedByAnnotation); ok {
+		t.Error("expected no manager for an annotation that is not set")
+	}
+}
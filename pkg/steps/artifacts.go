@@ -4,9 +4,10 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -19,6 +20,7 @@ import (
 
 	coreapi "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -27,6 +29,7 @@ import (
 	"k8s.io/client-go/tools/remotecommand"
 	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/pod-utils/decorate"
+	"k8s.io/test-infra/prow/sidecar"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	buildapi "github.com/openshift/api/build/v1"
@@ -206,8 +209,8 @@ func (c podClient) WithNewLoggingClient() PodClient {
 var intervalLock = &sync.RWMutex{}
 var interval = time.Second
 
-func waitForContainer(podClient PodClient, ns, name, containerName string) error {
-	logrus.WithFields(logrus.Fields{
+func waitForContainer(logger *logrus.Entry, podClient PodClient, ns, name, containerName string) error {
+	logger.WithFields(logrus.Fields{
 		"namespace": ns,
 		"name":      name,
 		"container": containerName,
@@ -219,7 +222,7 @@ func waitForContainer(podClient PodClient, ns, name, containerName string) error
 	return wait.PollImmediate(i, 300*i, func() (bool, error) {
 		pod := &coreapi.Pod{}
 		if err := podClient.Get(context.TODO(), ctrlruntimeclient.ObjectKey{Namespace: ns, Name: name}, pod); err != nil {
-			logrus.WithError(err).Errorf("Waiting for container %s in pod %s in namespace %s", containerName, name, ns)
+			logger.WithError(err).Errorf("Waiting for container %s in pod %s in namespace %s", containerName, name, ns)
 			return false, nil
 		}
 
@@ -236,14 +239,106 @@ func waitForContainer(podClient PodClient, ns, name, containerName string) error
 	})
 }
 
-func copyArtifacts(podClient PodClient, into, ns, name, containerName string, paths []string) error {
-	logrus.Tracef("Copying artifacts from %s into %s", name, into)
+// artifactTruncationReport lists the artifacts of a step which were left
+// out because they did not fit into the size quota of the step
+const artifactTruncationReport = "truncated-artifacts.txt"
+
+// artifactQuota tracks the size of the artifacts stored for a step
+type artifactQuota struct {
+	// limit is the maximum size in bytes, there is no limit if it is zero
+	limit int64
+	used  int64
+}
+
+func (q *artifactQuota) fits(size int64) bool {
+	return q == nil || q.limit == 0 || q.used+size <= q.limit
+}
+
+func (q *artifactQuota) use(size int64) {
+	if q != nil {
+		q.used += size
+	}
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// artifactArchive streams the files of a directory of artifacts into a
+// compressed tarball, so that they never take up their full size on disk
+type artifactArchive struct {
+	file    *os.File
+	counter *countingWriter
+	gzip    *gzip.Writer
+	tar     *tar.Writer
+	// stored is the uncompressed size of the files in the archive
+	stored int64
+}
+
+func newArtifactArchive(name string) (*artifactArchive, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0750); err != nil {
+		return nil, fmt.Errorf("could not create target directory for %s: %w", name, err)
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not create archive %s for artifacts: %w", name, err)
+	}
+	counter := &countingWriter{writer: file}
+	gz := gzip.NewWriter(counter)
+	return &artifactArchive{file: file, counter: counter, gzip: gz, tar: tar.NewWriter(gz)}, nil
+}
+
+func (a *artifactArchive) add(header *tar.Header, content io.Reader) error {
+	if err := a.tar.WriteHeader(header); err != nil {
+		return err
+	}
+	written, err := io.Copy(a.tar, content)
+	a.stored += written
+	return err
+}
+
+func (a *artifactArchive) close() error {
+	var errs []error
+	for _, closer := range []io.Closer{a.tar, a.gzip, a.file} {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// compressedSubtree determines which of the directories to compress holds
+// the file, if any
+func compressedSubtree(compress []string, name string) (string, bool) {
+	for _, subtree := range compress {
+		subtree = path.Clean(subtree)
+		if name == subtree || strings.HasPrefix(name, subtree+"/") {
+			return subtree, true
+		}
+	}
+	return "", false
+}
+
+// copyArtifacts streams the artifacts out of the container into the
+// directory. Files in directories which are to be compressed are streamed
+// into a tarball for every directory instead of being extracted. Files which
+// do not fit into the quota are left out and listed in a truncation report.
+func copyArtifacts(logger *logrus.Entry, podClient PodClient, into, ns, podName, containerName string, paths []string, compress []string, quota *artifactQuota) error {
+	logger.Tracef("Copying artifacts from %s into %s", podName, into)
 	var args []string
 	for _, s := range paths {
 		args = append(args, "-C", s, ".")
 	}
 
-	e, err := podClient.Exec(ns, name, &coreapi.PodExecOptions{
+	e, err := podClient.Exec(ns, podName, &coreapi.PodExecOptions{
 		Container: containerName,
 		Stdout:    true,
 		Stderr:    true,
@@ -255,7 +350,7 @@ func copyArtifacts(podClient PodClient, into, ns, name, containerName string, pa
 	r, w := io.Pipe()
 	defer func() {
 		if err := w.CloseWithError(fmt.Errorf("cancelled")); err != nil {
-			logrus.WithError(err).Error("CloseWithError failed")
+			logger.WithError(err).Error("CloseWithError failed")
 		}
 	}()
 	go func() {
@@ -265,10 +360,20 @@ func copyArtifacts(podClient PodClient, into, ns, name, containerName string, pa
 			Stderr: os.Stderr,
 		})
 		if err := w.CloseWithError(err); err != nil {
-			logrus.WithError(err).Error("CloseWithError failed")
+			logger.WithError(err).Error("CloseWithError failed")
 		}
 	}()
 
+	archives := map[string]*artifactArchive{}
+	defer func() {
+		for subtree, archive := range archives {
+			if err := archive.close(); err != nil {
+				logger.Errorf("could not close archive of %s: %v", subtree, err)
+			}
+		}
+	}()
+	var truncated []string
+
 	size := int64(0)
 	gr, err := gzip.NewReader(r)
 	if err != nil {
@@ -288,7 +393,11 @@ func copyArtifacts(podClient PodClient, into, ns, name, containerName string, pa
 			continue
 		}
 		p := filepath.Join(into, name)
+		subtree, compressed := compressedSubtree(compress, name)
 		if h.FileInfo().IsDir() {
+			if compressed {
+				continue
+			}
 			if err := os.MkdirAll(p, 0750); err != nil {
 				return fmt.Errorf("could not create target directory %s for artifacts: %w", p, err)
 			}
@@ -298,6 +407,32 @@ func copyArtifacts(podClient PodClient, into, ns, name, containerName string, pa
 			fmt.Fprintf(os.Stderr, "warn: ignoring link when copying artifacts to %s: %s\n", into, h.Name)
 			continue
 		}
+		if !quota.fits(h.Size) {
+			truncated = append(truncated, fmt.Sprintf("%s\t%d", name, h.Size))
+			continue
+		}
+		quota.use(h.Size)
+		size += h.Size
+		if compressed {
+			archive, ok := archives[subtree]
+			if !ok {
+				archiveName := filepath.Join(into, subtree+".tar.gz")
+				if _, err := os.Stat(archiveName); err == nil {
+					// another pod of the step stored the directory already
+					archiveName = filepath.Join(into, fmt.Sprintf("%s-%s.tar.gz", subtree, podName))
+				}
+				if archive, err = newArtifactArchive(archiveName); err != nil {
+					return err
+				}
+				archives[subtree] = archive
+			}
+			header := *h
+			header.Name = strings.TrimPrefix(name, subtree+"/")
+			if err := archive.add(&header, tr); err != nil {
+				return fmt.Errorf("could not add %s to the archive of %s: %w", name, subtree, err)
+			}
+			continue
+		}
 		f, err := os.Create(p)
 		if err != nil {
 			return fmt.Errorf("could not create target file %s for artifact: %w", p, err)
@@ -309,19 +444,51 @@ func copyArtifacts(podClient PodClient, into, ns, name, containerName string, pa
 		if err := f.Close(); err != nil {
 			return fmt.Errorf("could not close copied file %s: %w", p, err)
 		}
-		size += h.Size
+	}
+
+	for subtree, archive := range archives {
+		delete(archives, subtree)
+		if err := archive.close(); err != nil {
+			return fmt.Errorf("could not close archive of %s: %w", subtree, err)
+		}
+		// the quota was charged for the uncompressed files
+		quota.use(archive.counter.written - archive.stored)
+	}
+
+	if len(truncated) > 0 {
+		if err := appendTruncationReport(into, truncated); err != nil {
+			return err
+		}
+		logger.Warnf("Left out %d artifacts of %s which did not fit into the size quota of the step, see %s", len(truncated), podName, artifactTruncationReport)
 	}
 
 	// If we're updating a substantial amount of artifacts, let the user know as a way to
 	// indicate why the step took a long amount of time. Conversely, if we just got a small
 	// number of files this is just noise and can be omitted to not distract from other steps.
 	if size > 1*1000*1000 {
-		log.Printf("Copied %0.2fMB of artifacts from %s to %s", float64(size)/1000000, name, into)
+		logger.Infof("Copied %0.2fMB of artifacts from %s to %s", float64(size)/1000000, podName, into)
 	}
 
 	return nil
 }
 
+// appendTruncationReport records the artifacts which were left out, as a
+// line with the name and size of every file
+func appendTruncationReport(into string, truncated []string) error {
+	if err := os.MkdirAll(into, 0750); err != nil {
+		return fmt.Errorf("could not create artifact directory %s: %w", into, err)
+	}
+	report, err := os.OpenFile(filepath.Join(into, artifactTruncationReport), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("could not open truncation report: %w", err)
+	}
+	if _, err := report.WriteString(strings.Join(truncated, "\n") + "\n"); err != nil {
+		report.Close()
+		return fmt.Errorf("could not write truncation report: %w", err)
+	}
+	return report.Close()
+}
+
 func removeFile(podClient PodClient, ns, name, containerName string, paths []string) error {
 	e, err := podClient.Exec(ns, name, &coreapi.PodExecOptions{
 		Container: containerName,
@@ -367,6 +534,70 @@ func addPodUtils(pod *coreapi.Pod, artifactDir string, decorationConfig *prowv1.
 	return nil
 }
 
+// sidecarContainerName is the name decorate gives the sidecar container
+const sidecarContainerName = "sidecar"
+
+// censorSecretsInSidecar has the sidecar of a decorated pod censor the values
+// of the secrets mounted into the other containers of the pod from their logs
+// and artifacts before it uploads them. The secret volumes in skip hold data
+// handed between steps rather than credentials and are not censored.
+func censorSecretsInSidecar(pod *coreapi.Pod, skip ...string) error {
+	index := -1
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == sidecarContainerName {
+			index = i
+		}
+	}
+	if index == -1 {
+		return nil
+	}
+	secretVolumes := sets.NewString()
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Secret != nil {
+			secretVolumes.Insert(volume.Name)
+		}
+	}
+	secretVolumes.Delete(skip...)
+	container := &pod.Spec.Containers[index]
+	mounted := sets.NewString()
+	for _, mount := range container.VolumeMounts {
+		mounted.Insert(mount.MountPath)
+	}
+	var directories []string
+	for _, containers := range [][]coreapi.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, c := range containers {
+			for _, mount := range c.VolumeMounts {
+				if !secretVolumes.Has(mount.Name) || mounted.Has(mount.MountPath) {
+					continue
+				}
+				mounted.Insert(mount.MountPath)
+				container.VolumeMounts = append(container.VolumeMounts, coreapi.VolumeMount{Name: mount.Name, MountPath: mount.MountPath, ReadOnly: true})
+				directories = append(directories, mount.MountPath)
+			}
+		}
+	}
+	if len(directories) == 0 {
+		return nil
+	}
+	for i, env := range container.Env {
+		if env.Name != sidecar.JSONConfigEnvVar {
+			continue
+		}
+		options := sidecar.NewOptions()
+		if err := options.LoadConfig(env.Value); err != nil {
+			return fmt.Errorf("could not load sidecar options: %w", err)
+		}
+		options.SecretDirectories = append(options.SecretDirectories, directories...)
+		encoded, err := sidecar.Encode(*options)
+		if err != nil {
+			return fmt.Errorf("could not encode sidecar options: %w", err)
+		}
+		container.Env[i].Value = encoded
+		return nil
+	}
+	return fmt.Errorf("sidecar has no %s", sidecar.JSONConfigEnvVar)
+}
+
 func artifactsContainer() coreapi.Container {
 	return coreapi.Container{
 		Name:  "artifacts",
@@ -412,6 +643,12 @@ type ArtifactWorker struct {
 	dir       string
 	podClient PodClient
 	namespace string
+	// compress lists the directories of artifacts stored as tarballs
+	compress []string
+	// quota limits the size of the artifacts of all pods
+	quota *artifactQuota
+	// logger attaches the fields of the step the worker gathers for
+	logger *logrus.Entry
 
 	// Processing this requires the lock, so it must not be held
 	// when writing into it.
@@ -423,12 +660,24 @@ type ArtifactWorker struct {
 	hasArtifacts sets.String
 }
 
-func NewArtifactWorker(podClient PodClient, artifactDir, namespace string) *ArtifactWorker {
+func NewArtifactWorker(ctx context.Context, podClient PodClient, artifactDir, namespace string, gathering api.ArtifactGathering) *ArtifactWorker {
+	logger := Logger(ctx)
+	quota := &artifactQuota{}
+	if gathering.MaxSize != "" {
+		if limit, err := resource.ParseQuantity(gathering.MaxSize); err != nil {
+			logger.Warnf("ignoring invalid artifact size quota %q: %v", gathering.MaxSize, err)
+		} else {
+			quota.limit = limit.Value()
+		}
+	}
 	// stream artifacts in the background
 	w := &ArtifactWorker{
 		podClient: podClient,
 		namespace: namespace,
 		dir:       artifactDir,
+		compress:  gathering.Compress,
+		quota:     quota,
+		logger:    logger,
 
 		remaining:    make(podWaitRecord),
 		required:     make(podContainersMap),
@@ -442,11 +691,11 @@ func NewArtifactWorker(podClient PodClient, artifactDir, namespace string) *Arti
 
 func (w *ArtifactWorker) run() {
 	for podName := range w.podsToDownload {
-		logger := logrus.WithField("pod", podName)
+		logger := w.logger.WithField("pod", podName)
 		logger.Trace("Processing Pod to download artifacts.")
 		if err := w.downloadArtifacts(podName, w.hasArtifacts.Has(podName)); err != nil {
 			logger.WithError(err).Trace("Error downloading artifacts.")
-			log.Printf("error: %v", err)
+			logger.Error(err)
 		}
 		// indicate we are done with this pod by removing the map entry
 		w.lock.Lock()
@@ -460,14 +709,14 @@ func (w *ArtifactWorker) run() {
 }
 
 func (w *ArtifactWorker) downloadArtifacts(podName string, hasArtifacts bool) error {
-	logger := logrus.WithFields(logrus.Fields{"pod": podName, "hasArtifacts": hasArtifacts, "dir": w.dir})
+	logger := w.logger.WithFields(logrus.Fields{"pod": podName, "hasArtifacts": hasArtifacts, "dir": w.dir})
 	logger.Trace("Downloading artifacts for Pod.")
 	if err := os.MkdirAll(w.dir, 0750); err != nil {
 		return fmt.Errorf("unable to create artifact directory %s: %w", w.dir, err)
 	}
 	logger.Trace("Downloading container logs for Pod.")
-	if err := gatherContainerLogsOutput(w.podClient, filepath.Join(w.dir, "container-logs"), w.namespace, podName); err != nil {
-		log.Printf("error: unable to gather container logs: %v", err)
+	if err := gatherContainerLogsOutput(logger, w.podClient, filepath.Join(w.dir, "container-logs"), w.namespace, podName); err != nil {
+		logger.Errorf("unable to gather container logs: %v", err)
 	}
 
 	// only pods with an artifacts container should be gathered
@@ -483,16 +732,16 @@ func (w *ArtifactWorker) downloadArtifacts(podName string, hasArtifacts bool) er
 		if err == nil || strings.Contains(err.Error(), `unable to upgrade connection: container not found ("artifacts")`) {
 			return
 		}
-		log.Printf("error: unable to signal to artifacts container to terminate in pod %s, %v", podName, err)
+		logger.Errorf("unable to signal to artifacts container to terminate in pod %s, %v", podName, err)
 	}()
 
 	logger.Trace("Waiting for artifacts container to finish.")
-	if err := waitForContainer(w.podClient, w.namespace, podName, "artifacts"); err != nil {
+	if err := waitForContainer(logger, w.podClient, w.namespace, podName, "artifacts"); err != nil {
 		return fmt.Errorf("artifacts container for pod %s unready: %w", podName, err)
 	}
 
 	logger.Trace("Copying artifacts from Pod.")
-	if err := copyArtifacts(w.podClient, w.dir, w.namespace, podName, "artifacts", []string{"/tmp/artifacts"}); err != nil {
+	if err := copyArtifacts(logger, w.podClient, w.dir, w.namespace, podName, "artifacts", []string{"/tmp/artifacts"}, w.compress, w.quota); err != nil {
 		return fmt.Errorf("unable to retrieve artifacts from pod %s: %w", podName, err)
 	}
 	return nil
@@ -659,8 +908,8 @@ func hasMountsArtifactsVolume(pod *coreapi.Pod) bool {
 	return false
 }
 
-func gatherContainerLogsOutput(podClient PodClient, artifactDir, namespace, podName string) error {
-	logger := logrus.WithFields(logrus.Fields{"pod": podName, "namespace": namespace, "artifactDir": artifactDir})
+func gatherContainerLogsOutput(logger *logrus.Entry, podClient PodClient, artifactDir, namespace, podName string) error {
+	logger = logger.WithFields(logrus.Fields{"pod": podName, "namespace": namespace, "artifactDir": artifactDir})
 	logger.Trace("Gathering container logs.")
 	var validationErrors []error
 	pod := &coreapi.Pod{}
@@ -715,8 +964,8 @@ func gatherContainerLogsOutput(podClient PodClient, artifactDir, namespace, podN
 // from downloadArtifacts and gatherContainerLogsOutput and munges them in conjunction with the build
 // api logging capabilities; also, without needing to inject an artifacts container, some of the complexities
 // around download/copy from the artifacts container's volume mount and multiple pods are avoided.
-func gatherSuccessfulBuildLog(buildClient BuildClient, namespace, buildName string) error {
-	artifactDir, set := api.Artifacts()
+func gatherSuccessfulBuildLog(ctx context.Context, buildClient BuildClient, namespace, buildName string) error {
+	artifactDir, set := api.ArtifactsFor(ctx)
 	if !set {
 		return nil
 	}
@@ -732,14 +981,26 @@ func gatherSuccessfulBuildLog(buildClient BuildClient, namespace, buildName stri
 	defer file.Close()
 	w := gzip.NewWriter(file)
 	defer w.Close()
-	if rc, err := buildClient.Logs(namespace, buildName, &buildapi.BuildLogOptions{}); err == nil {
-		defer rc.Close()
-		if _, err := io.Copy(w, rc); err != nil {
-			return fmt.Errorf("error: Unable to copy log output from pod container %s: %w", buildName, err)
-		}
-	} else {
+	// timestamps let us determine how long each stage of the build took
+	rc, err := buildClient.Logs(namespace, buildName, &buildapi.BuildLogOptions{Timestamps: true})
+	if err != nil {
 		return fmt.Errorf("error: Unable to retrieve logs for build %s: %w", buildName, err)
 	}
+	defer rc.Close()
+	stages, err := splitBuildLog(rc, w)
+	if err != nil {
+		return fmt.Errorf("error: Unable to copy log output from build %s: %w", buildName, err)
+	}
+	if slowest, ok := slowestStage(stages); ok {
+		Logger(ctx).Infof("Slowest stage of build %s was STEP %d: %s (%s)", buildName, slowest.Step, slowest.Instruction, slowest.Duration.Truncate(time.Second))
+	}
+	raw, err := json.MarshalIndent(stages, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal stages of build %s: %w", buildName, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, buildName+"-stages.json"), raw, 0640); err != nil {
+		return fmt.Errorf("cannot write stages of build %s: %w", buildName, err)
+	}
 	return nil
 }
 
@@ -1,25 +1,36 @@
 package steps
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
 
 	coreapi "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/diff"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/remotecommand"
 	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	"github.com/openshift/ci-tools/pkg/api"
 	"github.com/openshift/ci-tools/pkg/junit"
 	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
 	"github.com/openshift/ci-tools/pkg/testhelper"
@@ -568,7 +579,7 @@ func TestArtifactWorker(t *testing.T) {
 		namespace: "namespace",
 		name:      pod,
 	}
-	w := NewArtifactWorker(podClient, tmp, "namespace")
+	w := NewArtifactWorker(context.Background(), podClient, tmp, "namespace", api.ArtifactGathering{})
 	w.CollectFromPod(pod, []string{"container"}, nil)
 	w.Complete(pod)
 	select {
@@ -589,6 +600,140 @@ func TestArtifactWorker(t *testing.T) {
 	}
 }
 
+// tarballPodClient streams a tarball of the files for any command
+type tarballPodClient struct {
+	*fakePodClient
+	files map[string]string
+}
+
+func (c *tarballPodClient) Exec(string, string, *coreapi.PodExecOptions) (remotecommand.Executor, error) {
+	return c, nil
+}
+
+func (c *tarballPodClient) Stream(opts remotecommand.StreamOptions) error {
+	gz := gzip.NewWriter(opts.Stdout)
+	archive := tar.NewWriter(gz)
+	var names []string
+	for name := range c.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	directories := sets.NewString()
+	for _, name := range names {
+		if dir := filepath.Dir(name); dir != "." && !directories.Has(dir) {
+			directories.Insert(dir)
+			if err := archive.WriteHeader(&tar.Header{Name: "./" + dir + "/", Mode: 0755, Typeflag: tar.TypeDir}); err != nil {
+				return err
+			}
+		}
+		if err := archive.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0644, Size: int64(len(c.files[name]))}); err != nil {
+			return err
+		}
+		if _, err := archive.Write([]byte(c.files[name])); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func TestCopyArtifacts(t *testing.T) {
+	files := map[string]string{
+		"junit.xml":                "<testsuite/>",
+		"must-gather/nodes.yaml":   "nodes",
+		"must-gather/pods/log.txt": "log",
+		"large.log":                strings.Repeat("x", 100),
+	}
+	var testCases = []struct {
+		name          string
+		compress      []string
+		quota         int64
+		expected      []string
+		expectedTar   []string
+		expectedTrunc string
+	}{
+		{
+			name:     "everything is extracted",
+			expected: []string{"junit.xml", "large.log", "must-gather/nodes.yaml", "must-gather/pods/log.txt"},
+		},
+		{
+			name:        "directory is compressed",
+			compress:    []string{"must-gather"},
+			expected:    []string{"junit.xml", "large.log", "must-gather.tar.gz"},
+			expectedTar: []string{"nodes.yaml", "pods/log.txt"},
+		},
+		{
+			name:          "files exceeding the quota are left out",
+			quota:         50,
+			expected:      []string{"junit.xml", "must-gather/nodes.yaml", "must-gather/pods/log.txt", "truncated-artifacts.txt"},
+			expectedTrunc: "large.log\t100\n",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "artifacts")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			client := &tarballPodClient{files: files}
+			if err := copyArtifacts(logrus.NewEntry(logrus.StandardLogger()), client, dir, "namespace", "pod", "artifacts", []string{"/tmp/artifacts"}, testCase.compress, &artifactQuota{limit: testCase.quota}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var actual []string
+			if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				relative, err := filepath.Rel(dir, path)
+				actual = append(actual, relative)
+				return err
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected artifacts: %s", diff)
+			}
+			if testCase.expectedTar != nil {
+				raw, err := ioutil.ReadFile(filepath.Join(dir, "must-gather.tar.gz"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				gz, err := gzip.NewReader(bytes.NewReader(raw))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var archived []string
+				archive := tar.NewReader(gz)
+				for {
+					header, err := archive.Next()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					archived = append(archived, header.Name)
+				}
+				if diff := cmp.Diff(testCase.expectedTar, archived); diff != "" {
+					t.Errorf("unexpected archive: %s", diff)
+				}
+			}
+			if testCase.expectedTrunc != "" {
+				raw, err := ioutil.ReadFile(filepath.Join(dir, artifactTruncationReport))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(testCase.expectedTrunc, string(raw)); diff != "" {
+					t.Errorf("unexpected truncation report: %s", diff)
+				}
+			}
+		})
+	}
+}
+
 func TestAddArtifactsToPod(t *testing.T) {
 	testCases := []struct {
 		testID   string
@@ -0,0 +1,351 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+const (
+	attestationsName          = "attestations"
+	attestationsArtifactsPath = "/tmp/artifacts"
+	provenanceMountPath       = "/tmp/provenance"
+	attestationsAuthMountPath = "/tmp/registry-auth"
+
+	// syftImage and orasImage are the upstream releases of the tools
+	syftImage = "docker.io/anchore/syft:v0.98.0"
+	orasImage = "ghcr.io/oras-project/oras:v1.1.0"
+
+	// builderID identifies ci-operator as the builder in provenance
+	builderID = "https://github.com/openshift/ci-tools/ci-operator"
+	// imageBuildType is the type of the builds ci-operator runs for images
+	imageBuildType = "https://github.com/openshift/ci-tools/ci-operator/image@v1"
+
+	provenanceMediaType = "application/vnd.in-toto+json"
+)
+
+var sbomMediaTypes = map[string]string{
+	api.SBOMFormatSPDX:      "application/spdx+json",
+	api.SBOMFormatCycloneDX: "application/vnd.cyclonedx+json",
+}
+
+// inTotoStatement is the envelope of SLSA provenance
+type inTotoStatement struct {
+	Type          string              `json:"_type"`
+	PredicateType string              `json:"predicateType"`
+	Subject       []provenanceSubject `json:"subject"`
+	Predicate     provenance          `json:"predicate"`
+}
+
+type provenanceSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// provenance is a SLSA v0.2 provenance predicate
+type provenance struct {
+	Builder    provenanceBuilder    `json:"builder"`
+	BuildType  string               `json:"buildType"`
+	Invocation provenanceInvocation `json:"invocation"`
+	Metadata   provenanceMetadata   `json:"metadata"`
+	Materials  []provenanceMaterial `json:"materials,omitempty"`
+}
+
+type provenanceBuilder struct {
+	ID string `json:"id"`
+}
+
+type provenanceInvocation struct {
+	ConfigSource provenanceMaterial `json:"configSource"`
+	Parameters   map[string]string  `json:"parameters"`
+}
+
+type provenanceMetadata struct {
+	BuildInvocationID string `json:"buildInvocationId,omitempty"`
+}
+
+type provenanceMaterial struct {
+	URI        string            `json:"uri"`
+	Digest     map[string]string `json:"digest,omitempty"`
+	EntryPoint string            `json:"entryPoint,omitempty"`
+}
+
+// builtImage is an image built by the job, referenced by digest in the
+// pipeline image stream
+type builtImage struct {
+	name       string
+	repository string
+	digest     string
+}
+
+func (i builtImage) reference() string {
+	return fmt.Sprintf("%s@%s", i.repository, i.digest)
+}
+
+// attestationStep generates a bill of materials and provenance for every
+// image built by the job and attaches them to the images
+type attestationStep struct {
+	config  api.AttestationConfiguration
+	images  []api.ProjectDirectoryImageBuildStepConfiguration
+	client  PodClient
+	jobSpec *api.JobSpec
+}
+
+func (s *attestationStep) Inputs() (api.InputDefinition, error) {
+	return nil, nil
+}
+
+func (*attestationStep) Validate() error { return nil }
+
+func (s *attestationStep) Run(ctx context.Context) error {
+	return results.ForReason(results.ReasonGeneratingAttestations).ForError(s.run(ctx))
+}
+
+func (s *attestationStep) run(ctx context.Context) error {
+	pipeline := &imagev1.ImageStream{}
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: api.PipelineImageStream}, pipeline); err != nil {
+		return fmt.Errorf("could not resolve pipeline imagestream: %w", err)
+	}
+	var names []string
+	for _, image := range s.images {
+		names = append(names, string(image.To))
+	}
+	images, err := builtImages(pipeline, names)
+	if err != nil {
+		return err
+	}
+	data := map[string]string{}
+	for _, image := range images {
+		raw, err := json.MarshalIndent(imageProvenance(image, s.jobSpec), "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not marshal provenance for %s: %w", image.name, err)
+		}
+		data[image.name+".provenance.json"] = string(raw)
+	}
+	cm := &coreapi.ConfigMap{
+		ObjectMeta: meta.ObjectMeta{Namespace: s.jobSpec.Namespace(), Name: attestationsName},
+		Data:       data,
+	}
+	if err := s.client.Create(ctx, cm); err != nil {
+		if !kerrors.IsAlreadyExists(err) {
+			return fmt.Errorf("could not create provenance: %w", err)
+		}
+		if err := s.client.Update(ctx, cm); err != nil {
+			return fmt.Errorf("could not update provenance: %w", err)
+		}
+	}
+
+	Logger(ctx).Infof("Generating attestations for %d images", len(images))
+	pod := attestationPod(s.jobSpec.Namespace(), s.config, images)
+	if owner := s.jobSpec.Owner(); owner != nil {
+		pod.OwnerReferences = append(pod.OwnerReferences, *owner)
+	}
+	var notifier ContainerNotifier = NopNotifier
+	if artifactDir, artifactsRequested := api.ArtifactsFor(ctx); artifactsRequested {
+		artifacts := NewArtifactWorker(ctx, s.client, filepath.Join(artifactDir, attestationsName), s.jobSpec.Namespace(), api.ArtifactGathering{})
+		addArtifactsToPod(pod)
+		addArtifactContainersFromPod(pod, artifacts)
+		notifier = artifacts
+	}
+	pod, err = createOrRestartPod(ctx, s.client, pod)
+	if err != nil {
+		return fmt.Errorf("failed to create attestations pod: %w", err)
+	}
+	if _, err := waitForPodCompletion(ctx, s.client, pod.Namespace, pod.Name, notifier, true); err != nil {
+		return fmt.Errorf("attestations pod failed: %w", err)
+	}
+	return nil
+}
+
+// builtImages resolves the digests of images the job built
+func builtImages(pipeline *imagev1.ImageStream, names []string) ([]builtImage, error) {
+	repository := pipeline.Status.DockerImageRepository
+	if repository == "" {
+		return nil, fmt.Errorf("pipeline imagestream has no repository")
+	}
+	var images []builtImage
+	for _, name := range names {
+		var digest string
+		for _, tag := range pipeline.Status.Tags {
+			if tag.Tag == name && len(tag.Items) > 0 {
+				digest = tag.Items[0].Image
+			}
+		}
+		if digest == "" {
+			return nil, fmt.Errorf("image %s was not built", name)
+		}
+		images = append(images, builtImage{name: name, repository: repository, digest: digest})
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].name < images[j].name })
+	return images, nil
+}
+
+// imageProvenance records how ci-operator built the image: from which
+// sources and in which job
+func imageProvenance(image builtImage, jobSpec *api.JobSpec) inTotoStatement {
+	digest := strings.SplitN(image.digest, ":", 2)
+	statement := inTotoStatement{
+		Type:          "https://in-toto.io/Statement/v0.1",
+		PredicateType: "https://slsa.dev/provenance/v0.2",
+		Subject:       []provenanceSubject{{Name: image.repository, Digest: map[string]string{digest[0]: digest[len(digest)-1]}}},
+		Predicate: provenance{
+			Builder:   provenanceBuilder{ID: builderID},
+			BuildType: imageBuildType,
+			Invocation: provenanceInvocation{
+				Parameters: map[string]string{"job": jobSpec.Job, "buildID": jobSpec.BuildID, "image": image.name},
+			},
+			Metadata: provenanceMetadata{BuildInvocationID: jobSpec.ProwJobID},
+		},
+	}
+	var refs []prowapi.Refs
+	if jobSpec.Refs != nil {
+		refs = append(refs, *jobSpec.Refs)
+	}
+	refs = append(refs, jobSpec.ExtraRefs...)
+	for i, ref := range refs {
+		uri := "git+" + api.RepoURL(&refs[i])
+		base := provenanceMaterial{URI: fmt.Sprintf("%s@refs/heads/%s", uri, ref.BaseRef)}
+		if ref.BaseSHA != "" {
+			base.Digest = map[string]string{"sha1": ref.BaseSHA}
+		}
+		if i == 0 {
+			statement.Predicate.Invocation.ConfigSource = provenanceMaterial{URI: base.URI, Digest: base.Digest, EntryPoint: image.name}
+		}
+		statement.Predicate.Materials = append(statement.Predicate.Materials, base)
+		for _, pull := range ref.Pulls {
+			statement.Predicate.Materials = append(statement.Predicate.Materials, provenanceMaterial{
+				URI:    fmt.Sprintf("%s@%s", uri, api.PullRef(pull)),
+				Digest: map[string]string{"sha1": pull.SHA},
+			})
+		}
+	}
+	return statement
+}
+
+// attestationPod generates the bills of materials with syft and attaches
+// them along with the provenance to the images with oras. Both are written
+// to the artifacts.
+func attestationPod(namespace string, config api.AttestationConfiguration, images []builtImage) *coreapi.Pod {
+	format := config.SBOMFormat
+	if format == "" {
+		format = api.SBOMFormatSPDX
+	}
+	var registries []string
+	for _, image := range images {
+		registries = append(registries, strings.SplitN(image.repository, "/", 2)[0])
+	}
+	registryConfig := filepath.Join(attestationsAuthMountPath, "config.json")
+	authMount := coreapi.VolumeMount{Name: "registry-auth", MountPath: attestationsAuthMountPath}
+	artifactsMount := coreapi.VolumeMount{Name: "artifacts", MountPath: attestationsArtifactsPath}
+	initContainers := []coreapi.Container{{
+		Name:         "registry-auth",
+		Image:        clusterToolsImage,
+		Command:      []string{"/bin/bash", "-c"},
+		Args:         []string{strings.Join([]string{"set -euo pipefail", registryAuthScript(registryConfig, registries...)}, "\n")},
+		VolumeMounts: []coreapi.VolumeMount{authMount},
+	}}
+	commands := []string{"set -eu"}
+	for i, image := range images {
+		sbom, provenance := image.name+".sbom.json", image.name+".provenance.json"
+		// the syft image has no shell, the bill of materials is written
+		// to the artifacts directly
+		initContainers = append(initContainers, coreapi.Container{
+			Name:         fmt.Sprintf("sbom-%d", i),
+			Image:        syftImage,
+			Command:      []string{"/syft"},
+			Args:         []string{image.reference(), "--output", fmt.Sprintf("%s=%s", format, filepath.Join(attestationsArtifactsPath, sbom))},
+			Env:          []coreapi.EnvVar{{Name: "DOCKER_CONFIG", Value: attestationsAuthMountPath}},
+			VolumeMounts: []coreapi.VolumeMount{authMount, artifactsMount},
+		})
+		// oras refuses absolute paths, the files are attached from the
+		// artifacts directory
+		commands = append(commands,
+			fmt.Sprintf("cp %s %s", filepath.Join(provenanceMountPath, provenance), provenance),
+			fmt.Sprintf("oras attach --registry-config %s --artifact-type %s %s %s", registryConfig, sbomMediaTypes[format], image.reference(), sbom),
+			fmt.Sprintf("oras attach --registry-config %s --artifact-type %s %s %s", registryConfig, provenanceMediaType, image.reference(), provenance),
+		)
+	}
+	pod := &coreapi.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      attestationsName,
+			Namespace: namespace,
+		},
+		Spec: coreapi.PodSpec{
+			RestartPolicy: coreapi.RestartPolicyNever,
+			// the builder account may push to the pipeline image stream
+			ServiceAccountName: "builder",
+			InitContainers:     initContainers,
+			Containers: []coreapi.Container{{
+				Name:       attestationsName,
+				Image:      orasImage,
+				Command:    []string{"/bin/sh", "-c"},
+				Args:       []string{strings.Join(commands, "\n")},
+				WorkingDir: attestationsArtifactsPath,
+				VolumeMounts: []coreapi.VolumeMount{
+					artifactsMount,
+					authMount,
+					{Name: "provenance", MountPath: provenanceMountPath, ReadOnly: true},
+				},
+			}},
+			Volumes: []coreapi.Volume{
+				{Name: "artifacts", VolumeSource: coreapi.VolumeSource{EmptyDir: &coreapi.EmptyDirVolumeSource{}}},
+				{Name: "registry-auth", VolumeSource: coreapi.VolumeSource{EmptyDir: &coreapi.EmptyDirVolumeSource{}}},
+				{Name: "provenance", VolumeSource: coreapi.VolumeSource{ConfigMap: &coreapi.ConfigMapVolumeSource{
+					LocalObjectReference: coreapi.LocalObjectReference{Name: attestationsName},
+				}}},
+			},
+		},
+	}
+	return pod
+}
+
+func (s *attestationStep) Requires() []api.StepLink {
+	var links []api.StepLink
+	for _, image := range s.images {
+		links = append(links, api.InternalImageLink(image.To))
+	}
+	return links
+}
+
+func (s *attestationStep) Creates() []api.StepLink {
+	return []api.StepLink{api.AttestationsLink()}
+}
+
+func (s *attestationStep) Provides() api.ParameterMap {
+	return nil
+}
+
+func (s *attestationStep) Name() string { return "[attestations]" }
+
+func (s *attestationStep) Description() string {
+	return "Generate a bill of materials and provenance for the built images"
+}
+
+func (s *attestationStep) Objects() []ctrlruntimeclient.Object {
+	return s.client.Objects()
+}
+
+// AttestationStep attaches a bill of materials and SLSA provenance to every
+// image built by the job.
+func AttestationStep(config api.AttestationConfiguration, images []api.ProjectDirectoryImageBuildStepConfiguration, client PodClient, jobSpec *api.JobSpec) api.Step {
+	return &attestationStep{
+		config:  config,
+		images:  images,
+		client:  client,
+		jobSpec: jobSpec,
+	}
+}
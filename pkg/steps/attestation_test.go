@@ -0,0 +1,60 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestImageProvenance(t *testing.T) {
+	jobSpec := &api.JobSpec{JobSpec: downwardapi.JobSpec{
+		Job:       "pull-ci-org-repo-master-images",
+		BuildID:   "1234",
+		ProwJobID: "uuid",
+		Refs: &prowapi.Refs{
+			Org:     "org",
+			Repo:    "repo",
+			BaseRef: "master",
+			BaseSHA: "base",
+			Pulls:   []prowapi.Pull{{Number: 1, SHA: "pull"}},
+		},
+		ExtraRefs: []prowapi.Refs{{Org: "org", Repo: "other", BaseRef: "main"}},
+	}}
+	image := builtImage{name: "cli", repository: "registry.svc:5000/ns/pipeline", digest: "sha256:abc"}
+	expected := inTotoStatement{
+		Type:          "https://in-toto.io/Statement/v0.1",
+		PredicateType: "https://slsa.dev/provenance/v0.2",
+		Subject:       []provenanceSubject{{Name: "registry.svc:5000/ns/pipeline", Digest: map[string]string{"sha256": "abc"}}},
+		Predicate: provenance{
+			Builder:   provenanceBuilder{ID: builderID},
+			BuildType: imageBuildType,
+			Invocation: provenanceInvocation{
+				ConfigSource: provenanceMaterial{URI: "git+https://github.com/org/repo@refs/heads/master", Digest: map[string]string{"sha1": "base"}, EntryPoint: "cli"},
+				Parameters:   map[string]string{"job": "pull-ci-org-repo-master-images", "buildID": "1234", "image": "cli"},
+			},
+			Metadata: provenanceMetadata{BuildInvocationID: "uuid"},
+			Materials: []provenanceMaterial{
+				{URI: "git+https://github.com/org/repo@refs/heads/master", Digest: map[string]string{"sha1": "base"}},
+				{URI: "git+https://github.com/org/repo@refs/pull/1/head", Digest: map[string]string{"sha1": "pull"}},
+				{URI: "git+https://github.com/org/other@refs/heads/main"},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, imageProvenance(image, jobSpec)); diff != "" {
+		t.Errorf("unexpected provenance: %s", diff)
+	}
+}
+
+func TestAttestationPod(t *testing.T) {
+	images := []builtImage{
+		{name: "cli", repository: "image-registry.openshift-image-registry.svc:5000/ci-op-1234/pipeline", digest: "sha256:cli"},
+		{name: "operator", repository: "image-registry.openshift-image-registry.svc:5000/ci-op-1234/pipeline", digest: "sha256:operator"},
+	}
+	testhelper.CompareWithFixture(t, attestationPod("ci-op-1234", api.AttestationConfiguration{SBOMFormat: api.SBOMFormatCycloneDX}, images))
+}
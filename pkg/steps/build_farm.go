@@ -0,0 +1,421 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"sync"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	buildapi "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+	buildclientset "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+// BuildFarm is a cluster image builds may be dispatched to instead of the
+// cluster the tests of the job run on. Builds run in a namespace of the same
+// name on the farm. The farm must be able to pull from the registry of the
+// cluster of the tests and the other way around, as the inputs of builds are
+// imported into the farm and their results are imported back.
+type BuildFarm struct {
+	// Name identifies the farm.
+	Name string `json:"name"`
+	// Kubeconfig is the path to the credentials for the farm.
+	Kubeconfig string `json:"kubeconfig"`
+	// Capacity is the number of builds which may run on the farm at the
+	// same time, counting the builds of every job.
+	Capacity int `json:"capacity"`
+	// Labels describe the farm, so that jobs may select farms.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	client BuildClient
+}
+
+// BuildFarmsConfiguration lists the farms builds may be dispatched to
+type BuildFarmsConfiguration struct {
+	Farms []BuildFarm `json:"farms"`
+}
+
+// LoadBuildFarms reads the configuration of the build farms and connects to
+// every farm
+func LoadBuildFarms(path string) ([]*BuildFarm, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read build farms configuration: %w", err)
+	}
+	var config BuildFarmsConfiguration
+	if err := yaml.UnmarshalStrict(raw, &config); err != nil {
+		return nil, fmt.Errorf("could not parse build farms configuration: %w", err)
+	}
+	seen := sets.NewString()
+	for i, farm := range config.Farms {
+		if farm.Name == "" || seen.Has(farm.Name) {
+			return nil, fmt.Errorf("farms[%d]: name must be set and unique, got %q", i, farm.Name)
+		}
+		seen.Insert(farm.Name)
+		if farm.Capacity <= 0 {
+			return nil, fmt.Errorf("build farm %s: capacity must be positive, got %d", farm.Name, farm.Capacity)
+		}
+	}
+	var farms []*BuildFarm
+	for i := range config.Farms {
+		farm := &config.Farms[i]
+		clusterConfig, err := clientcmd.BuildConfigFromFlags("", farm.Kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("build farm %s: could not load kubeconfig: %w", farm.Name, err)
+		}
+		client, err := ctrlruntimeclient.New(clusterConfig, ctrlruntimeclient.Options{})
+		if err != nil {
+			return nil, fmt.Errorf("build farm %s: could not construct client: %w", farm.Name, err)
+		}
+		buildGetter, err := buildclientset.NewForConfig(clusterConfig)
+		if err != nil {
+			return nil, fmt.Errorf("build farm %s: could not get build client: %w", farm.Name, err)
+		}
+		farm.client = NewBuildClient(loggingclient.New(client), buildGetter.RESTClient())
+		farms = append(farms, farm)
+	}
+	return farms, nil
+}
+
+// BuildDispatcher sends the builds of a job to the least loaded build farm
+// which matches the selector of the job and has spare capacity. Builds run on
+// the cluster of the tests when no farm has.
+type BuildDispatcher struct {
+	farms   []*BuildFarm
+	imports *ImportManager
+	// annotations are set on the namespaces created on the farms
+	annotations map[string]string
+	// random breaks ties between equally loaded farms, so that jobs do not
+	// all pick the first one
+	random func(n int) int
+
+	lock sync.Mutex
+	// running counts the builds of the job reserved on every farm
+	running map[string]int
+
+	prepareLock sync.Mutex
+	// prepared holds the farms the namespace was prepared on
+	prepared sets.String
+	// namespace is the namespace prepared on the farms
+	namespace string
+}
+
+// NewBuildDispatcher dispatches builds to the farms matching the selector
+func NewBuildDispatcher(farms []*BuildFarm, selector labels.Selector, namespaceAnnotations map[string]string) *BuildDispatcher {
+	var selected []*BuildFarm
+	for _, farm := range farms {
+		if selector.Matches(labels.Set(farm.Labels)) {
+			selected = append(selected, farm)
+		}
+	}
+	return &BuildDispatcher{
+		farms:       selected,
+		imports:     NewImportManager(DefaultImportConcurrency, DefaultImportBackoff),
+		annotations: namespaceAnnotations,
+		random:      rand.Intn,
+		running:     map[string]int{},
+		prepared:    sets.NewString(),
+	}
+}
+
+// reserve picks the farm with the lowest share of its capacity in use by
+// the builds of all jobs, preferring the farm with more spare capacity and
+// picking at random among equally loaded ones, and reserves capacity for a
+// build of the job in the namespace on it until release is called. No farm
+// is returned when all are at capacity.
+func (d *BuildDispatcher) reserve(ctx context.Context, namespace string) (farm *BuildFarm, release func()) {
+	others := map[string]int{}
+	for _, candidate := range d.farms {
+		active, err := activeBuilds(ctx, candidate.client, namespace)
+		if err != nil {
+			Logger(ctx).WithError(err).Warnf("Not dispatching to build farm %s, could not determine its load", candidate.Name)
+			continue
+		}
+		others[candidate.Name] = active
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	loads := map[string]int{}
+	var least []*BuildFarm
+	for _, candidate := range d.farms {
+		active, known := others[candidate.Name]
+		if !known {
+			continue
+		}
+		loads[candidate.Name] = active + d.running[candidate.Name]
+		if loads[candidate.Name] >= candidate.Capacity {
+			continue
+		}
+		if len(least) == 0 {
+			least = []*BuildFarm{candidate}
+			continue
+		}
+		switch compareLoad(loads[candidate.Name], candidate.Capacity, loads[least[0].Name], least[0].Capacity) {
+		case -1:
+			least = []*BuildFarm{candidate}
+		case 0:
+			least = append(least, candidate)
+		}
+	}
+	if len(least) == 0 {
+		return nil, func() {}
+	}
+	farm = least[d.random(len(least))]
+	d.running[farm.Name]++
+	return farm, func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		d.running[farm.Name]--
+	}
+}
+
+// compareLoad compares the shares of the capacities of two farms in use,
+// and their spare capacity when the shares are equal
+func compareLoad(load, capacity, otherLoad, otherCapacity int) int {
+	share, otherShare := load*otherCapacity, otherLoad*capacity
+	switch {
+	case share < otherShare:
+		return -1
+	case share > otherShare:
+		return 1
+	case capacity-load > otherCapacity-otherLoad:
+		return -1
+	case capacity-load < otherCapacity-otherLoad:
+		return 1
+	}
+	return 0
+}
+
+// activeBuilds counts the builds of jobs on the farm which are not complete,
+// except those in the namespace of the job, which the dispatcher counts itself
+func activeBuilds(ctx context.Context, client ctrlruntimeclient.Client, namespace string) (int, error) {
+	builds := &buildapi.BuildList{}
+	if err := client.List(ctx, builds, ctrlruntimeclient.MatchingLabels{CreatedByCILabel: "true"}, ctrlruntimeclient.MatchingFieldsSelector{Selector: incompleteBuilds}); err != nil {
+		return 0, fmt.Errorf("could not list builds: %w", err)
+	}
+	var active int
+	for _, build := range builds.Items {
+		if build.Namespace == namespace {
+			continue
+		}
+		switch build.Status.Phase {
+		case buildapi.BuildPhaseNew, buildapi.BuildPhasePending, buildapi.BuildPhaseRunning:
+			active++
+		}
+	}
+	return active, nil
+}
+
+// incompleteBuilds selects the builds which are not complete on the server,
+// the phase of the listed builds is still checked for clients which do not
+// select by fields
+var incompleteBuilds = fields.AndSelectors(
+	fields.OneTermNotEqualSelector("status", string(buildapi.BuildPhaseComplete)),
+	fields.OneTermNotEqualSelector("status", string(buildapi.BuildPhaseFailed)),
+	fields.OneTermNotEqualSelector("status", string(buildapi.BuildPhaseError)),
+	fields.OneTermNotEqualSelector("status", string(buildapi.BuildPhaseCancelled)),
+)
+
+// Cleanup deletes the namespaces prepared on the farms. They only hold the
+// inputs and outputs of builds, which were imported back, so they are not
+// kept like the namespace of the tests may be.
+func (d *BuildDispatcher) Cleanup(ctx context.Context) error {
+	d.prepareLock.Lock()
+	defer d.prepareLock.Unlock()
+	var errs []error
+	for _, farm := range d.farms {
+		if !d.prepared.Has(farm.Name) {
+			continue
+		}
+		ns := &coreapi.Namespace{ObjectMeta: metav1.ObjectMeta{Name: d.namespace}}
+		if err := farm.client.Delete(ctx, ns); err != nil && !kerrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("could not delete namespace %s on build farm %s: %w", d.namespace, farm.Name, err))
+			continue
+		}
+		d.prepared.Delete(farm.Name)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// dispatchingBuildClient marks the build client of a job which dispatches
+// builds to build farms
+type dispatchingBuildClient struct {
+	BuildClient
+	dispatcher *BuildDispatcher
+}
+
+// NewDispatchingBuildClient dispatches the builds created through the
+// client to build farms
+func NewDispatchingBuildClient(client BuildClient, dispatcher *BuildDispatcher) BuildClient {
+	return &dispatchingBuildClient{BuildClient: client, dispatcher: dispatcher}
+}
+
+// handleRemoteBuild runs the build on the farm: the namespace of the build
+// is prepared on the farm, the inputs of the build are imported into it and
+// the output of the build is imported back when it succeeds
+func (d *BuildDispatcher) handleRemoteBuild(ctx context.Context, local BuildClient, farm *BuildFarm, build *buildapi.Build) error {
+	Logger(ctx).Infof("Dispatching build %s to build farm %s", build.Name, farm.Name)
+	if err := d.prepareNamespace(ctx, farm, build.Namespace); err != nil {
+		return fmt.Errorf("could not prepare namespace on build farm %s: %w", farm.Name, err)
+	}
+	if err := copyBuildSecrets(ctx, local, farm.client, build); err != nil {
+		return fmt.Errorf("could not copy secrets to build farm %s: %w", farm.Name, err)
+	}
+	for _, tag := range buildInputTags(build) {
+		if err := d.importPipelineImage(ctx, local, farm.client, build.Namespace, tag); err != nil {
+			return fmt.Errorf("could not import input %s into build farm %s: %w", tag, farm.Name, err)
+		}
+	}
+	remote := build.DeepCopy()
+	// the owner only exists on the cluster of the tests
+	remote.OwnerReferences = nil
+	if err := handleBuild(ctx, farm.client, remote); err != nil {
+		return err
+	}
+	if to := build.Spec.Output.To; to != nil && to.Kind == "ImageStreamTag" {
+		tag := strings.TrimPrefix(to.Name, api.PipelineImageStream+":")
+		if err := d.importPipelineImage(ctx, farm.client, local, build.Namespace, tag); err != nil {
+			return fmt.Errorf("could not import the output of build %s from build farm %s: %w", build.Name, farm.Name, err)
+		}
+	}
+	return nil
+}
+
+// prepareNamespace creates the namespace and the pipeline image stream on
+// the farm the first time a build is dispatched to it
+func (d *BuildDispatcher) prepareNamespace(ctx context.Context, farm *BuildFarm, namespace string) error {
+	d.prepareLock.Lock()
+	defer d.prepareLock.Unlock()
+	if d.prepared.Has(farm.Name) {
+		return nil
+	}
+	ns := &coreapi.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace, Annotations: d.annotations}}
+	if err := farm.client.Create(ctx, ns); err != nil && !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not create namespace: %w", err)
+	}
+	stream := &imagev1.ImageStream{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: api.PipelineImageStream}}
+	if err := farm.client.Create(ctx, stream); err != nil && !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not create %s image stream: %w", api.PipelineImageStream, err)
+	}
+	d.prepared.Insert(farm.Name)
+	d.namespace = namespace
+	return nil
+}
+
+// buildSecretNames lists the secrets the build refers to
+func buildSecretNames(build *buildapi.Build) []string {
+	names := sets.NewString()
+	if secret := build.Spec.Source.SourceSecret; secret != nil {
+		names.Insert(secret.Name)
+	}
+	for _, secret := range build.Spec.Source.Secrets {
+		names.Insert(secret.Secret.Name)
+	}
+	if strategy := build.Spec.Strategy.DockerStrategy; strategy != nil && strategy.PullSecret != nil {
+		names.Insert(strategy.PullSecret.Name)
+	}
+	if secret := build.Spec.Output.PushSecret; secret != nil {
+		names.Insert(secret.Name)
+	}
+	return names.List()
+}
+
+// copyBuildSecrets copies the secrets the build refers to from one cluster
+// to the other
+func copyBuildSecrets(ctx context.Context, from, to ctrlruntimeclient.Client, build *buildapi.Build) error {
+	for _, name := range buildSecretNames(build) {
+		source := &coreapi.Secret{}
+		if err := from.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: build.Namespace, Name: name}, source); err != nil {
+			return fmt.Errorf("could not get secret %s: %w", name, err)
+		}
+		secret := &coreapi.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: build.Namespace, Name: name},
+			Type:       source.Type,
+			Data:       source.Data,
+		}
+		if err := to.Create(ctx, secret); err != nil {
+			if !kerrors.IsAlreadyExists(err) {
+				return fmt.Errorf("could not create secret %s: %w", name, err)
+			}
+			existing := &coreapi.Secret{}
+			if err := to.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: build.Namespace, Name: name}, existing); err != nil {
+				return fmt.Errorf("could not get secret %s: %w", name, err)
+			}
+			existing.Data = source.Data
+			if err := to.Update(ctx, existing); err != nil {
+				return fmt.Errorf("could not update secret %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// buildInputTags lists the tags of the pipeline image stream the build
+// builds from
+func buildInputTags(build *buildapi.Build) []string {
+	refs := []*coreapi.ObjectReference{}
+	if strategy := build.Spec.Strategy.DockerStrategy; strategy != nil && strategy.From != nil {
+		refs = append(refs, strategy.From)
+	}
+	for i := range build.Spec.Source.Images {
+		refs = append(refs, &build.Spec.Source.Images[i].From)
+	}
+	tags := sets.NewString()
+	for _, ref := range refs {
+		if ref.Kind != "ImageStreamTag" || (ref.Namespace != "" && ref.Namespace != build.Namespace) {
+			continue
+		}
+		if tag := strings.TrimPrefix(ref.Name, api.PipelineImageStream+":"); tag != ref.Name {
+			tags.Insert(tag)
+		}
+	}
+	return tags.List()
+}
+
+// importPipelineImage imports the tag of the pipeline image stream from one
+// cluster into the other through the public registry of the former
+func (d *BuildDispatcher) importPipelineImage(ctx context.Context, from, to ctrlruntimeclient.Client, namespace, tag string) error {
+	stream := &imagev1.ImageStream{}
+	if err := from.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: api.PipelineImageStream}, stream); err != nil {
+		return fmt.Errorf("could not get %s image stream: %w", api.PipelineImageStream, err)
+	}
+	if stream.Status.PublicDockerImageRepository == "" {
+		return fmt.Errorf("the registry of the cluster is not exposed publicly")
+	}
+	ist := &imagev1.ImageStreamTag{}
+	if err := from.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: fmt.Sprintf("%s:%s", api.PipelineImageStream, tag)}, ist); err != nil {
+		return fmt.Errorf("could not get %s:%s: %w", api.PipelineImageStream, tag, err)
+	}
+	pullSpec := fmt.Sprintf("%s@%s", stream.Status.PublicDockerImageRepository, ist.Image.Name)
+	streamImport := &imagev1.ImageStreamImport{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: api.PipelineImageStream},
+		Spec: imagev1.ImageStreamImportSpec{
+			Import: true,
+			Images: []imagev1.ImageImportSpec{{
+				To:              &coreapi.LocalObjectReference{Name: tag},
+				From:            coreapi.ObjectReference{Kind: "DockerImage", Name: pullSpec},
+				ReferencePolicy: imagev1.TagReferencePolicy{Type: imagev1.LocalTagReferencePolicy},
+			}},
+		},
+	}
+	if _, err := d.imports.Import(ctx, to, streamImport); err != nil {
+		return fmt.Errorf("could not import %s: %w", pullSpec, err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package steps
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	buildapi "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+func farmWithBuilds(t *testing.T, name string, capacity int, labels map[string]string, builds ...*buildapi.Build) *BuildFarm {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{coreapi.AddToScheme, buildapi.AddToScheme, imagev1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatalf("failed to build scheme: %v", err)
+		}
+	}
+	var objects []runtime.Object
+	for _, build := range builds {
+		objects = append(objects, build)
+	}
+	return &BuildFarm{Name: name, Capacity: capacity, Labels: labels, client: NewBuildClient(loggingclient.New(fakectrlruntimeclient.NewFakeClientWithScheme(scheme, objects...)), nil)}
+}
+
+func farmBuild(namespace, name string, phase buildapi.BuildPhase) *buildapi.Build {
+	return &buildapi.Build{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{CreatedByCILabel: "true"}}, Status: buildapi.BuildStatus{Phase: phase}}
+}
+
+func TestBuildDispatcherReserve(t *testing.T) {
+	farms := []*BuildFarm{
+		farmWithBuilds(t, "small", 1, map[string]string{"cloud": "aws"}),
+		// one build of another job is running, the build of this job, the
+		// completed build and the build not created by a job do not count
+		farmWithBuilds(t, "large", 4, map[string]string{"cloud": "gcp"},
+			farmBuild("other", "running", buildapi.BuildPhaseRunning),
+			farmBuild("other", "complete", buildapi.BuildPhaseComplete),
+			farmBuild("ns", "own", buildapi.BuildPhaseRunning),
+			&buildapi.Build{ObjectMeta: metav1.ObjectMeta{Namespace: "user", Name: "manual"}, Status: buildapi.BuildStatus{Phase: buildapi.BuildPhaseRunning}},
+		),
+		farmWithBuilds(t, "arm", 10, map[string]string{"cloud": "gcp", "arch": "arm64"}),
+	}
+	selector, err := labels.Parse("!arch")
+	if err != nil {
+		t.Fatalf("invalid selector: %v", err)
+	}
+	dispatcher := NewBuildDispatcher(farms, selector, nil)
+	dispatcher.random = func(int) int { return 0 }
+	var actual []string
+	var releases []func()
+	for i := 0; i < 5; i++ {
+		farm, release := dispatcher.reserve(context.Background(), "ns")
+		name := "local"
+		if farm != nil {
+			name = farm.Name
+		}
+		actual = append(actual, name)
+		releases = append(releases, release)
+	}
+	// builds run on the cluster of the tests once all farms are at capacity
+	if diff := cmp.Diff([]string{"small", "large", "large", "large", "local"}, actual); diff != "" {
+		t.Errorf("unexpected farms: %s", diff)
+	}
+	releases[0]()
+	if farm, _ := dispatcher.reserve(context.Background(), "ns"); farm == nil || farm.Name != "small" {
+		t.Errorf("expected the released capacity to be reused, got %v", farm)
+	}
+}
+
+func TestBuildDispatcherReserveTies(t *testing.T) {
+	farms := []*BuildFarm{
+		farmWithBuilds(t, "first", 2, nil),
+		farmWithBuilds(t, "second", 2, nil),
+		farmWithBuilds(t, "larger", 4, nil, farmBuild("other", "running", buildapi.BuildPhasePending), farmBuild("other", "new", buildapi.BuildPhaseNew)),
+	}
+	dispatcher := NewBuildDispatcher(farms, labels.Everything(), nil)
+	var candidates []int
+	dispatcher.random = func(n int) int {
+		candidates = append(candidates, n)
+		return n - 1
+	}
+	farm, _ := dispatcher.reserve(context.Background(), "ns")
+	if farm == nil || farm.Name != "second" {
+		t.Errorf("expected the random pick among the idle farms, got %v", farm)
+	}
+	// the larger farm has more spare capacity at the same share in use
+	farm, _ = dispatcher.reserve(context.Background(), "ns")
+	if farm == nil || farm.Name != "first" {
+		t.Errorf("expected the idle farm, got %v", farm)
+	}
+	farm, _ = dispatcher.reserve(context.Background(), "ns")
+	if farm == nil || farm.Name != "larger" {
+		t.Errorf("expected the farm with more spare capacity, got %v", farm)
+	}
+	if diff := cmp.Diff([]int{2, 1, 1}, candidates); diff != "" {
+		t.Errorf("unexpected ties: %s", diff)
+	}
+}
+
+func TestBuildDispatcherCleanup(t *testing.T) {
+	farms := []*BuildFarm{farmWithBuilds(t, "used", 1, nil), farmWithBuilds(t, "unused", 1, nil)}
+	dispatcher := NewBuildDispatcher(farms, labels.Everything(), nil)
+	if err := dispatcher.prepareNamespace(context.Background(), farms[0], "ns"); err != nil {
+		t.Fatalf("failed to prepare namespace: %v", err)
+	}
+	if err := dispatcher.Cleanup(context.Background()); err != nil {
+		t.Fatalf("failed to clean up: %v", err)
+	}
+	for _, farm := range farms {
+		if err := farm.client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Name: "ns"}, &coreapi.Namespace{}); !kerrors.IsNotFound(err) {
+			t.Errorf("expected the namespace on farm %s to be gone, got %v", farm.Name, err)
+		}
+	}
+}
+
+func TestBuildInputsOnFarm(t *testing.T) {
+	build := &buildapi.Build{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "bin"},
+		Spec: buildapi.BuildSpec{CommonSpec: buildapi.CommonSpec{
+			Source: buildapi.BuildSource{
+				SourceSecret: &coreapi.LocalObjectReference{Name: "clone-auth"},
+				Images: []buildapi.ImageSource{
+					{From: coreapi.ObjectReference{Kind: "ImageStreamTag", Name: "pipeline:root"}},
+					{From: coreapi.ObjectReference{Kind: "ImageStreamTag", Namespace: "ocp", Name: "4.9:cli"}},
+					{From: coreapi.ObjectReference{Kind: "DockerImage", Name: "quay.io/org/image"}},
+				},
+			},
+			Strategy: buildapi.BuildStrategy{DockerStrategy: &buildapi.DockerBuildStrategy{
+				From:       &coreapi.ObjectReference{Kind: "ImageStreamTag", Namespace: "ns", Name: "pipeline:src"},
+				PullSecret: &coreapi.LocalObjectReference{Name: "registry-pull-credentials"},
+			}},
+			Output: buildapi.BuildOutput{To: &coreapi.ObjectReference{Kind: "ImageStreamTag", Namespace: "ns", Name: "pipeline:bin"}},
+		}},
+	}
+	if diff := cmp.Diff([]string{"root", "src"}, buildInputTags(build)); diff != "" {
+		t.Errorf("unexpected input tags: %s", diff)
+	}
+	if diff := cmp.Diff([]string{"clone-auth", "registry-pull-credentials"}, buildSecretNames(build)); diff != "" {
+		t.Errorf("unexpected secrets: %s", diff)
+	}
+
+	local := fakectrlruntimeclient.NewFakeClient(
+		&coreapi.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "clone-auth"}, Data: map[string][]byte{"key": []byte("new")}},
+		&coreapi.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "registry-pull-credentials"}, Type: coreapi.SecretTypeDockerConfigJson, Data: map[string][]byte{".dockerconfigjson": []byte("{}")}},
+	)
+	farm := fakectrlruntimeclient.NewFakeClient(
+		&coreapi.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "clone-auth"}, Data: map[string][]byte{"key": []byte("old")}},
+	)
+	if err := copyBuildSecrets(context.Background(), local, farm, build); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, expected := range map[string]map[string][]byte{
+		"clone-auth":                {"key": []byte("new")},
+		"registry-pull-credentials": {".dockerconfigjson": []byte("{}")},
+	} {
+		secret := &coreapi.Secret{}
+		if err := farm.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: name}, secret); err != nil {
+			t.Fatalf("failed to get secret %s: %v", name, err)
+		}
+		if diff := cmp.Diff(expected, secret.Data); diff != "" {
+			t.Errorf("unexpected data of secret %s: %s", name, diff)
+		}
+	}
+}
+
+func TestLoadBuildFarms(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		config      string
+		expectedErr string
+	}{
+		{
+			name:        "farm without name",
+			config:      "farms:\n- capacity: 1\n",
+			expectedErr: `farms[0]: name must be set and unique, got ""`,
+		},
+		{
+			name:        "duplicate farms",
+			config:      "farms:\n- name: build01\n  capacity: 1\n  kubeconfig: /dev/null\n- name: build01\n  capacity: 1\n",
+			expectedErr: `farms[1]: name must be set and unique, got "build01"`,
+		},
+		{
+			name:        "farm without capacity",
+			config:      "farms:\n- name: build01\n",
+			expectedErr: "build farm build01: capacity must be positive, got 0",
+		},
+		{
+			name:        "unknown field",
+			config:      "farms:\n- name: build01\n  size: 1\n",
+			expectedErr: `could not parse build farms configuration: error unmarshaling JSON: while decoding JSON: json: unknown field "size"`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "farms.yaml")
+			if err := ioutil.WriteFile(path, []byte(testCase.config), 0644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			_, err := LoadBuildFarms(path)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+		})
+	}
+}
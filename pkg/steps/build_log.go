@@ -0,0 +1,102 @@
+package steps
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BuildLogStage is the part of a build log produced by one instruction of the
+// Dockerfile, as delimited by the STEP headers the builder prints
+type BuildLogStage struct {
+	// Step is the index of the instruction, zero for output before the first one
+	Step int `json:"step"`
+	// Total is the number of instructions, if the builder reports it
+	Total int `json:"total,omitempty"`
+	// Instruction is the Dockerfile instruction that was executed
+	Instruction string     `json:"instruction,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	// Duration is only known when the log lines carried timestamps
+	Duration time.Duration `json:"duration,omitempty"`
+	// Lines are the log lines with terminal escape sequences removed
+	Lines []string `json:"lines,omitempty"`
+}
+
+var (
+	// buildah prints `STEP 3/7: RUN make`, older versions omit the total
+	buildStepHeader = regexp.MustCompile(`^STEP (\d+)(?:/(\d+))?: (.*)$`)
+	ansiEscape      = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+)
+
+// splitBuildLog reads a build log requested with timestamps and splits it into
+// stages. The log is copied to raw as it would look without timestamps.
+func splitBuildLog(log io.Reader, raw io.Writer) ([]BuildLogStage, error) {
+	var stages []BuildLogStage
+	current := &BuildLogStage{}
+	var last *time.Time
+	finish := func() {
+		if current.Step == 0 && len(current.Lines) == 0 {
+			return
+		}
+		current.FinishedAt = last
+		if current.StartedAt != nil && last != nil {
+			current.Duration = last.Sub(*current.StartedAt)
+		}
+		stages = append(stages, *current)
+	}
+
+	scanner := bufio.NewScanner(log)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var timestamp *time.Time
+		if idx := strings.IndexByte(line, ' '); idx > 0 {
+			if t, err := time.Parse(time.RFC3339Nano, line[:idx]); err == nil {
+				timestamp = &t
+				line = line[idx+1:]
+			}
+		}
+		if _, err := fmt.Fprintln(raw, line); err != nil {
+			return nil, fmt.Errorf("could not write raw log: %w", err)
+		}
+		line = ansiEscape.ReplaceAllString(line, "")
+		if match := buildStepHeader.FindStringSubmatch(line); match != nil {
+			if timestamp != nil {
+				last = timestamp
+			}
+			finish()
+			step, _ := strconv.Atoi(match[1])
+			total, _ := strconv.Atoi(match[2])
+			current = &BuildLogStage{Step: step, Total: total, Instruction: match[3], StartedAt: timestamp}
+		}
+		if timestamp != nil {
+			last = timestamp
+			if current.StartedAt == nil {
+				current.StartedAt = timestamp
+			}
+		}
+		current.Lines = append(current.Lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read build log: %w", err)
+	}
+	finish()
+	return stages, nil
+}
+
+// slowestStage returns the stage that took the longest, if durations are known
+func slowestStage(stages []BuildLogStage) (BuildLogStage, bool) {
+	var slowest BuildLogStage
+	var found bool
+	for _, stage := range stages {
+		if stage.Step > 0 && stage.Duration > slowest.Duration {
+			slowest, found = stage, true
+		}
+	}
+	return slowest, found
+}
@@ -0,0 +1,80 @@
+package steps
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitBuildLog(t *testing.T) {
+	start := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) *time.Time {
+		t := start.Add(offset)
+		return &t
+	}
+	for _, tc := range []struct {
+		name           string
+		log            string
+		expectedRaw    string
+		expectedStages []BuildLogStage
+	}{
+		{
+			name: "timestamped log with headers",
+			log: `2021-01-01T12:00:00Z Replaced Dockerfile FROM image
+2021-01-01T12:00:01Z STEP 1/3: FROM base
+2021-01-01T12:00:05Z Getting image source signatures
+2021-01-01T12:00:06Z STEP 2/3: RUN make
+2021-01-01T12:00:07Z ` + "\x1b[32mok\x1b[0m" + `
+2021-01-01T12:05:06Z STEP 3/3: COMMIT image
+2021-01-01T12:05:16Z --> abcdef
+`,
+			expectedRaw: `Replaced Dockerfile FROM image
+STEP 1/3: FROM base
+Getting image source signatures
+STEP 2/3: RUN make
+` + "\x1b[32mok\x1b[0m" + `
+STEP 3/3: COMMIT image
+--> abcdef
+`,
+			expectedStages: []BuildLogStage{
+				{Lines: []string{"Replaced Dockerfile FROM image"}, StartedAt: at(0), FinishedAt: at(time.Second), Duration: time.Second},
+				{Step: 1, Total: 3, Instruction: "FROM base", Lines: []string{"STEP 1/3: FROM base", "Getting image source signatures"}, StartedAt: at(time.Second), FinishedAt: at(6 * time.Second), Duration: 5 * time.Second},
+				{Step: 2, Total: 3, Instruction: "RUN make", Lines: []string{"STEP 2/3: RUN make", "ok"}, StartedAt: at(6 * time.Second), FinishedAt: at(5*time.Minute + 6*time.Second), Duration: 5 * time.Minute},
+				{Step: 3, Total: 3, Instruction: "COMMIT image", Lines: []string{"STEP 3/3: COMMIT image", "--> abcdef"}, StartedAt: at(5*time.Minute + 6*time.Second), FinishedAt: at(5*time.Minute + 16*time.Second), Duration: 10 * time.Second},
+			},
+		},
+		{
+			name:        "log without timestamps or totals",
+			log:         "STEP 1: FROM base\nSTEP 2: RUN make\nok\n",
+			expectedRaw: "STEP 1: FROM base\nSTEP 2: RUN make\nok\n",
+			expectedStages: []BuildLogStage{
+				{Step: 1, Instruction: "FROM base", Lines: []string{"STEP 1: FROM base"}},
+				{Step: 2, Instruction: "RUN make", Lines: []string{"STEP 2: RUN make", "ok"}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := &bytes.Buffer{}
+			stages, err := splitBuildLog(strings.NewReader(tc.log), raw)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.expectedRaw, raw.String()); diff != "" {
+				t.Errorf("unexpected raw log: %s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedStages, stages); diff != "" {
+				t.Errorf("unexpected stages: %s", diff)
+			}
+			slowest, ok := slowestStage(stages)
+			if expected := stages[len(stages)-1].StartedAt != nil; ok != expected {
+				t.Errorf("expected slowest stage to be found: %t, got %t", expected, ok)
+			}
+			if ok && slowest.Step != 2 {
+				t.Errorf("expected step 2 to be the slowest, got %d", slowest.Step)
+			}
+		})
+	}
+}
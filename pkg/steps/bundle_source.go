@@ -1,9 +1,16 @@
 package steps
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	coreapi "k8s.io/api/core/v1"
@@ -32,10 +39,16 @@ func (s *bundleSourceStep) Inputs() (api.InputDefinition, error) {
 func (*bundleSourceStep) Validate() error { return nil }
 
 func (s *bundleSourceStep) Run(ctx context.Context) error {
-	return results.ForReason("building_bundle_source").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonBuildingBundleSource).ForError(s.run(ctx))
 }
 
 func (s *bundleSourceStep) run(ctx context.Context) error {
+	for _, sub := range s.config.Substitutions {
+		imageStream, name, _ := s.releaseBuildConfig.DependencyParts(api.StepDependency{Name: sub.With})
+		if api.LinkForImage(imageStream, name) == nil {
+			Logger(ctx).Warnf("unable to resolve image '%s' to be substituted for '%s'", sub.With, sub.PullSpec)
+		}
+	}
 	source := fmt.Sprintf("%s:%s", api.PipelineImageStream, api.PipelineImageStreamTagReferenceSource)
 	workingDir, err := getWorkingDir(s.client, source, s.jobSpec.Namespace())
 	if err != nil {
@@ -67,7 +80,103 @@ func (s *bundleSourceStep) run(ctx context.Context) error {
 		s.resources,
 		s.pullSecret,
 	)
-	return handleBuild(ctx, s.client, build)
+	if err := handleBuild(ctx, s.client, build); err != nil {
+		return err
+	}
+	if len(s.config.PinnedImages) > 0 {
+		if err := s.reportPinnedImages(ctx, build.Namespace, build.Name); err != nil {
+			// the bundles are built from the source regardless
+			Logger(ctx).Infof("could not report the related images pinned in build %s: %v", build.Name, err)
+		}
+	}
+	return nil
+}
+
+// RelatedImageSubstitution records a pullspec in a CSV which was pinned to
+// the digest of an image built by the job
+type RelatedImageSubstitution struct {
+	// Image is the name of the pipeline image the pullspec referenced
+	Image string `json:"image"`
+	// File is the path of the CSV in the source
+	File string `json:"file"`
+	// Line is the number of the line the pullspec was found on
+	Line int `json:"line"`
+	// Original is the line before the substitution
+	Original string `json:"original"`
+	// With is the pullspec by digest the original one was replaced with
+	With string `json:"with"`
+}
+
+// pinnedImageMarker prefixes the lines the build prints for every pullspec
+// it pins
+const pinnedImageMarker = "ci-operator-pinned-image"
+
+// pinCommand pins the pullspecs of the image which are the whole value of
+// `image` and `value` fields of all CSVs to the digest, printing a marked
+// line for each one
+func pinCommand(image, digest string) string {
+	// the field, a pullspec with a registry and an optional tag or digest
+	// whose last part is the image, optionally quoted, and nothing else
+	line := fmt.Sprintf(`^([[:space:]]*(-[[:space:]]*)?(image|value):[[:space:]]*["'\'']?)[a-z0-9.:-]+/([a-z0-9._-]+/)*%s(:[A-Za-z0-9._-]+|@sha256:[a-f0-9]{64})?(["'\'']?[[:space:]]*)$`, regexp.QuoteMeta(image))
+	csvs := `find . -type f -name '*.clusterserviceversion.yaml' -exec`
+	return fmt.Sprintf(`RUN %[1]s grep -HnE '%[2]s' {} + | sed 's?^?%[3]s %[4]s %[5]s ?'; %[1]s sed -E -i 's#%[2]s#\1%[5]s\6#' {} +`, csvs, line, pinnedImageMarker, image, digest)
+}
+
+// parsePinnedImages reads the substitutions from the marked lines of the
+// build log
+func parsePinnedImages(buildLog io.Reader) ([]RelatedImageSubstitution, error) {
+	var substitutions []RelatedImageSubstitution
+	scanner := bufio.NewScanner(buildLog)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 4)
+		if len(fields) != 4 || fields[0] != pinnedImageMarker {
+			continue
+		}
+		location := strings.SplitN(fields[3], ":", 3)
+		if len(location) != 3 {
+			continue
+		}
+		line, err := strconv.Atoi(location[1])
+		if err != nil {
+			continue
+		}
+		substitutions = append(substitutions, RelatedImageSubstitution{
+			Image:    fields[1],
+			File:     strings.TrimPrefix(location[0], "./"),
+			Line:     line,
+			Original: strings.TrimSpace(location[2]),
+			With:     fields[2],
+		})
+	}
+	return substitutions, scanner.Err()
+}
+
+// reportPinnedImages logs the pullspecs the build pinned and saves them to
+// the artifacts
+func (s *bundleSourceStep) reportPinnedImages(ctx context.Context, namespace, name string) error {
+	rc, err := s.client.Logs(namespace, name, &buildapi.BuildLogOptions{})
+	if err != nil {
+		return fmt.Errorf("could not get build log: %w", err)
+	}
+	defer rc.Close()
+	substitutions, err := parsePinnedImages(rc)
+	if err != nil {
+		return fmt.Errorf("could not read build log: %w", err)
+	}
+	Logger(ctx).Infof("Pinned %d pullspecs in the CSVs to the digests of built images", len(substitutions))
+	artifactDir, set := api.ArtifactsFor(ctx)
+	if !set {
+		return nil
+	}
+	raw, err := json.MarshalIndent(substitutions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal substitutions: %w", err)
+	}
+	if err := os.MkdirAll(artifactDir, 0750); err != nil {
+		return fmt.Errorf("could not create artifact directory: %w", err)
+	}
+	return ioutil.WriteFile(filepath.Join(artifactDir, "related-image-substitutions.json"), raw, 0640)
 }
 
 func replaceCommand(pullSpec, with string) string {
@@ -86,17 +195,53 @@ func (s *bundleSourceStep) bundleSourceDockerfile() (string, error) {
 		}
 		dockerCommands = append(dockerCommands, fmt.Sprintf(`RUN %s`, replaceCommand(sub.PullSpec, replaceSpec)))
 	}
+	for _, image := range s.config.PinnedImages {
+		digest, err := utils.ImageDigestFor(s.client, s.jobSpec.Namespace, api.PipelineImageStream, string(image))()
+		if err != nil {
+			return "", fmt.Errorf("failed to get image digest for %s: %w", image, err)
+		}
+		dockerCommands = append(dockerCommands, pinCommand(string(image), digest))
+	}
+	for _, bundle := range s.config.Bundles {
+		dockerCommands = append(dockerCommands, channelCommands(bundle)...)
+	}
 	return strings.Join(dockerCommands, "\n"), nil
 }
 
+const (
+	annotationBundleChannels       = "operators.operatorframework.io.bundle.channels.v1"
+	annotationBundleDefaultChannel = "operators.operatorframework.io.bundle.channel.default.v1"
+)
+
+// setAnnotationCommand sets the annotation in the annotations.yaml file of a
+// bundle, adding it if the bundle does not declare it
+func setAnnotationCommand(path, annotation, value string) string {
+	return fmt.Sprintf(`RUN if grep -q '^\s*%[2]s:' %[1]s; then sed -i 's?^\(\s*%[2]s:\).*?\1 %[3]s?' %[1]s; else echo '  %[2]s: %[3]s' >> %[1]s; fi`, path, annotation, value)
+}
+
+// channelCommands override the channels the bundle declares in its metadata
+// with the configured ones
+func channelCommands(bundle api.Bundle) []string {
+	path := filepath.Join(bundle.ContextDir, "metadata", "annotations.yaml")
+	var commands []string
+	if len(bundle.Channels) > 0 {
+		commands = append(commands, setAnnotationCommand(path, annotationBundleChannels, strings.Join(bundle.Channels, ",")))
+	}
+	if bundle.DefaultChannel != "" {
+		commands = append(commands, setAnnotationCommand(path, annotationBundleDefaultChannel, bundle.DefaultChannel))
+	}
+	return commands
+}
+
 func (s *bundleSourceStep) Requires() []api.StepLink {
 	links := []api.StepLink{api.InternalImageLink(api.PipelineImageStreamTagReferenceSource)}
+	for _, image := range s.config.PinnedImages {
+		links = append(links, api.InternalImageLink(image))
+	}
 	for _, sub := range s.config.Substitutions {
 		imageStream, name, _ := s.releaseBuildConfig.DependencyParts(api.StepDependency{Name: sub.With})
 		if link := api.LinkForImage(imageStream, name); link != nil {
 			links = append(links, link)
-		} else {
-			log.Printf("warning: unable to resolve image '%s' to be substituted for '%s'", sub.With, sub.PullSpec)
 		}
 
 	}
@@ -3,12 +3,15 @@ package steps
 import (
 	"bytes"
 	"io/ioutil"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
@@ -88,6 +91,126 @@ func TestReplaceCommand(t *testing.T) {
 	}
 }
 
+func TestPinCommand(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("skipping test on %s OS", runtime.GOOS)
+	}
+	csv := `spec:
+  relatedImages:
+  - name: operator
+    image: quay.io/org/operator:v1.0
+  - name: operand
+    image: "registry.example.com:5000/org/team/operand@sha256:0000000000000000000000000000000000000000000000000000000000000000"
+  - name: operator-extra
+    image: quay.io/org/operator-extra:v1.0
+  install:
+    spec:
+      deployments:
+      - spec:
+          template:
+            spec:
+              containers:
+              - image: quay.io/org/operator
+                env:
+                - name: OPERAND_IMAGE
+                  value: 'quay.io/org/operand:latest'
+                - name: DESCRIPTION
+                  value: builds quay.io/org/operator:v1.0
+`
+	expected := `spec:
+  relatedImages:
+  - name: operator
+    image: some-reg/ns/pipeline@sha256:operator
+  - name: operand
+    image: "some-reg/ns/pipeline@sha256:operand"
+  - name: operator-extra
+    image: quay.io/org/operator-extra:v1.0
+  install:
+    spec:
+      deployments:
+      - spec:
+          template:
+            spec:
+              containers:
+              - image: some-reg/ns/pipeline@sha256:operator
+                env:
+                - name: OPERAND_IMAGE
+                  value: 'some-reg/ns/pipeline@sha256:operand'
+                - name: DESCRIPTION
+                  value: builds quay.io/org/operator:v1.0
+`
+	temp, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory for unit test: %v", err)
+	}
+	defer os.RemoveAll(temp)
+	path := filepath.Join(temp, "manifests", "operator.clusterserviceversion.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create manifests: %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	var output bytes.Buffer
+	for _, image := range []string{"operator", "operand"} {
+		// docker runs commands using `/bin/sh -c givenCommand`, so we do the same to test here
+		cmd := exec.Command("/bin/sh", "-c", strings.TrimPrefix(pinCommand(image, "some-reg/ns/pipeline@sha256:"+image), "RUN "))
+		cmd.Dir = temp
+		cmd.Stdout = &output
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("Failed to run pin command for %s: %v", image, err)
+		}
+	}
+	updated, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+	if diff := cmp.Diff(expected, string(updated)); diff != "" {
+		t.Errorf("unexpected CSV: %s", diff)
+	}
+	substitutions, err := parsePinnedImages(&output)
+	if err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	expectedSubstitutions := []RelatedImageSubstitution{
+		{Image: "operator", File: "manifests/operator.clusterserviceversion.yaml", Line: 4, Original: "image: quay.io/org/operator:v1.0", With: "some-reg/ns/pipeline@sha256:operator"},
+		{Image: "operator", File: "manifests/operator.clusterserviceversion.yaml", Line: 16, Original: "- image: quay.io/org/operator", With: "some-reg/ns/pipeline@sha256:operator"},
+		{Image: "operand", File: "manifests/operator.clusterserviceversion.yaml", Line: 6, Original: `image: "registry.example.com:5000/org/team/operand@sha256:0000000000000000000000000000000000000000000000000000000000000000"`, With: "some-reg/ns/pipeline@sha256:operand"},
+		{Image: "operand", File: "manifests/operator.clusterserviceversion.yaml", Line: 19, Original: "value: 'quay.io/org/operand:latest'", With: "some-reg/ns/pipeline@sha256:operand"},
+	}
+	if diff := cmp.Diff(expectedSubstitutions, substitutions); diff != "" {
+		t.Errorf("unexpected substitutions: %s", diff)
+	}
+}
+
+func TestChannelCommands(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		bundle   api.Bundle
+		expected []string
+	}{
+		{
+			name:   "channels from the metadata are kept",
+			bundle: api.Bundle{ContextDir: "manifests"},
+		},
+		{
+			name:   "channels and default channel are overridden",
+			bundle: api.Bundle{ContextDir: "manifests", Channels: []string{"alpha", "stable"}, DefaultChannel: "stable"},
+			expected: []string{
+				`RUN if grep -q '^\s*operators.operatorframework.io.bundle.channels.v1:' manifests/metadata/annotations.yaml; then sed -i 's?^\(\s*operators.operatorframework.io.bundle.channels.v1:\).*?\1 alpha,stable?' manifests/metadata/annotations.yaml; else echo '  operators.operatorframework.io.bundle.channels.v1: alpha,stable' >> manifests/metadata/annotations.yaml; fi`,
+				`RUN if grep -q '^\s*operators.operatorframework.io.bundle.channel.default.v1:' manifests/metadata/annotations.yaml; then sed -i 's?^\(\s*operators.operatorframework.io.bundle.channel.default.v1:\).*?\1 stable?' manifests/metadata/annotations.yaml; else echo '  operators.operatorframework.io.bundle.channel.default.v1: stable' >> manifests/metadata/annotations.yaml; fi`,
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, channelCommands(testCase.bundle)); diff != "" {
+				t.Errorf("unexpected commands: %s", diff)
+			}
+		})
+	}
+}
+
 func TestBundleSourceDockerfile(t *testing.T) {
 	var expectedDockerfile = `FROM pipeline:src
 RUN find . -type f -regex ".*\.\(yaml\|yml\)" -exec sed -i s?quay.io/openshift/origin-metering-ansible-operator:4.6?some-reg/target-namespace/pipeline@metering-ansible-operator?g {} +
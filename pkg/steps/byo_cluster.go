@@ -0,0 +1,63 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// BYOClusterLabel must be set to "true" on a kubeconfig secret before
+	// ci-operator will accept it as the target of a bring-your-own-cluster run.
+	// This is a safety interlock that prevents arbitrary secrets from being
+	// used as the target of a test.
+	BYOClusterLabel = "ci.openshift.io/byo-cluster"
+	// BYOClusterAllowDestructiveLabel must be set to "true" on the kubeconfig
+	// secret for `post` steps to run against a bring-your-own cluster. These
+	// steps usually tear down the cluster, so they are skipped by default.
+	BYOClusterAllowDestructiveLabel = "ci.openshift.io/byo-cluster-allow-destructive"
+	// BYOClusterKubeconfigKey is the key in the secret which holds the kubeconfig
+	BYOClusterKubeconfigKey = "kubeconfig"
+)
+
+// BYOClusterConfig identifies a secret holding the kubeconfig for a long-lived
+// cluster. When set, multi-stage tests target this cluster instead of
+// installing or claiming one of their own.
+type BYOClusterConfig struct {
+	Namespace string
+	Name      string
+}
+
+// ParseBYOClusterConfig parses a `namespace/name` reference to a secret
+func ParseBYOClusterConfig(value string) (*BYOClusterConfig, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("must be in the form NAMESPACE/NAME, not %q", value)
+	}
+	return &BYOClusterConfig{Namespace: parts[0], Name: parts[1]}, nil
+}
+
+func (c *BYOClusterConfig) String() string {
+	return fmt.Sprintf("%s/%s", c.Namespace, c.Name)
+}
+
+// resolve reads the kubeconfig from the secret, ensuring that the secret was
+// explicitly marked as a valid target. It returns the kubeconfig and whether
+// destructive steps were allowed to run against the cluster.
+func (c *BYOClusterConfig) resolve(ctx context.Context, client ctrlruntimeclient.Client) ([]byte, bool, error) {
+	secret := &coreapi.Secret{}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: c.Namespace, Name: c.Name}, secret); err != nil {
+		return nil, false, fmt.Errorf("could not read kubeconfig secret %s: %w", c, err)
+	}
+	if secret.Labels[BYOClusterLabel] != "true" {
+		return nil, false, fmt.Errorf("kubeconfig secret %s does not have the %s=true label, refusing to use it as a test target", c, BYOClusterLabel)
+	}
+	kubeconfig, ok := secret.Data[BYOClusterKubeconfigKey]
+	if !ok || len(kubeconfig) == 0 {
+		return nil, false, fmt.Errorf("kubeconfig secret %s has no %q key", c, BYOClusterKubeconfigKey)
+	}
+	return kubeconfig, secret.Labels[BYOClusterAllowDestructiveLabel] == "true", nil
+}
@@ -0,0 +1,128 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+const clonerefsCheckName = "clonerefs-check"
+
+// defaultClonerefsPath is where the tool is in images that supply it
+const defaultClonerefsPath = "/clonerefs"
+
+// DefaultClonerefs is where the source is cloned with unless the job or the
+// repository supplies another image
+var DefaultClonerefs = ClonerefsOverride{
+	Image: api.ImageStreamTagReference{Namespace: "ci", Name: "managed-clonerefs", Tag: "latest"},
+	Path:  defaultClonerefsPath,
+}
+
+// clonerefsOptionsEnv is the variable clonerefs reads its options from, as
+// the source build passes them
+const clonerefsOptionsEnv = "CLONEREFS_OPTIONS"
+
+// clonerefsEmptyOptionsError is what clonerefs of Prow fails with when it
+// reads empty options from clonerefsOptionsEnv
+const clonerefsEmptyOptionsError = "Invalid options: no source root specified"
+
+// ClonerefsOverride replaces the image the clonerefs tool is taken from, so
+// that forks and disconnected installations can supply their own
+type ClonerefsOverride struct {
+	Image api.ImageStreamTagReference
+	// Path is the path of the tool in the image
+	Path string
+}
+
+// ParseClonerefsOverride parses an image in the form NAMESPACE/NAME:TAG and
+// the path of the tool in it, which defaults to /clonerefs
+func ParseClonerefsOverride(image, path string) (*ClonerefsOverride, error) {
+	parts := strings.Split(image, "/")
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, fmt.Errorf("must be in the form NAMESPACE/NAME:TAG, not %q", image)
+	}
+	nameTag := strings.Split(parts[1], ":")
+	if len(nameTag) != 2 || nameTag[0] == "" || nameTag[1] == "" {
+		return nil, fmt.Errorf("must be in the form NAMESPACE/NAME:TAG, not %q", image)
+	}
+	if path == "" {
+		path = defaultClonerefsPath
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("the path of clonerefs must be absolute, not %q", path)
+	}
+	return &ClonerefsOverride{
+		Image: api.ImageStreamTagReference{Namespace: parts[0], Name: nameTag[0], Tag: nameTag[1]},
+		Path:  path,
+	}, nil
+}
+
+// ClonerefsOverrideFromConfig returns the override the configuration of a
+// repository asks for
+func ClonerefsOverrideFromConfig(config api.ClonerefsConfiguration) *ClonerefsOverride {
+	override := &ClonerefsOverride{Image: config.Image, Path: config.Path}
+	if override.Path == "" {
+		override.Path = defaultClonerefsPath
+	}
+	return override
+}
+
+// clonerefsCompatible determines whether the output of the tool run with
+// empty options is the error clonerefs of Prow reports for them
+func clonerefsCompatible(output string) error {
+	if !strings.Contains(output, clonerefsEmptyOptionsError) {
+		return fmt.Errorf("the tool does not read its options from $%s like clonerefs does, it printed %q", clonerefsOptionsEnv, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// checkClonerefs runs the tool from the image with empty options in a pod
+// and fails unless it rejects them like clonerefs does, so that images which
+// do not hold a clonerefs the build can pass its options to are not used.
+// clonerefs has no flag reporting its version, so its age is not checked.
+func checkClonerefs(ctx context.Context, client PodClient, jobSpec *api.JobSpec, image coreapi.ObjectReference, path string, pullSecret *coreapi.Secret) error {
+	pod := &coreapi.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      clonerefsCheckName,
+			Namespace: jobSpec.Namespace(),
+		},
+		Spec: coreapi.PodSpec{
+			RestartPolicy: coreapi.RestartPolicyNever,
+			Containers: []coreapi.Container{{
+				Name:                     clonerefsCheckName,
+				Image:                    image.Name,
+				Command:                  []string{path},
+				Env:                      []coreapi.EnvVar{{Name: clonerefsOptionsEnv, Value: "{}"}},
+				TerminationMessagePolicy: coreapi.TerminationMessageFallbackToLogsOnError,
+			}},
+		},
+	}
+	if pullSecret != nil {
+		pod.Spec.ImagePullSecrets = []coreapi.LocalObjectReference{{Name: PullSecretName}}
+	}
+	if owner := jobSpec.Owner(); owner != nil {
+		pod.OwnerReferences = append(pod.OwnerReferences, *owner)
+	}
+	pod, err := createOrRestartPod(ctx, client, pod)
+	if err != nil {
+		return fmt.Errorf("failed to create clonerefs check pod: %w", err)
+	}
+	// the tool is expected to fail, its output tells whether it is clonerefs
+	_, waitErr := waitForPodCompletion(ctx, client, pod.Namespace, pod.Name, NopNotifier, true)
+	if waitErr == nil {
+		return fmt.Errorf("%s accepted empty options, it is not clonerefs", path)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	output, err := client.GetLogs(pod.Namespace, pod.Name, &coreapi.PodLogOptions{Container: clonerefsCheckName}).DoRaw(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get the output of %s: %v, the image may not be supported: %w", path, err, waitErr)
+	}
+	return clonerefsCompatible(string(output))
+}
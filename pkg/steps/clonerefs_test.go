@@ -0,0 +1,96 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestParseClonerefsOverride(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		image, path string
+		expected    *ClonerefsOverride
+		expectedErr bool
+	}{
+		{
+			name:     "path defaults to the one of the default image",
+			image:    "mirror/clonerefs:v20210401",
+			expected: &ClonerefsOverride{Image: api.ImageStreamTagReference{Namespace: "mirror", Name: "clonerefs", Tag: "v20210401"}, Path: "/clonerefs"},
+		},
+		{
+			name:     "path is set",
+			image:    "mirror/prow-utils:latest",
+			path:     "/usr/bin/clonerefs",
+			expected: &ClonerefsOverride{Image: api.ImageStreamTagReference{Namespace: "mirror", Name: "prow-utils", Tag: "latest"}, Path: "/usr/bin/clonerefs"},
+		},
+		{
+			name:        "missing tag",
+			image:       "mirror/clonerefs",
+			expectedErr: true,
+		},
+		{
+			name:        "missing namespace",
+			image:       "clonerefs:latest",
+			expectedErr: true,
+		},
+		{
+			name:        "relative path",
+			image:       "mirror/clonerefs:latest",
+			path:        "clonerefs",
+			expectedErr: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := ParseClonerefsOverride(testCase.image, testCase.path)
+			if (err != nil) != testCase.expectedErr {
+				t.Fatalf("expected error: %v, got: %v", testCase.expectedErr, err)
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected override: %s", diff)
+			}
+		})
+	}
+}
+
+func TestClonerefsCompatible(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		output      string
+		expectedErr bool
+	}{
+		{
+			name:   "clonerefs rejects the empty options",
+			output: `{"component":"clonerefs","file":"prow/cmd/clonerefs/main.go:36","func":"main.main","level":"fatal","msg":"Invalid options: no source root specified","severity":"fatal","time":"2021-03-09T10:00:00Z"}` + "\n",
+		},
+		{
+			name:        "tool reads options from flags",
+			output:      "flag provided but not defined: -src-root",
+			expectedErr: true,
+		},
+		{
+			name:        "no output",
+			expectedErr: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := clonerefsCompatible(testCase.output); (err != nil) != testCase.expectedErr {
+				t.Errorf("expected error: %v, got: %v", testCase.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestClonerefsOverrideFromConfig(t *testing.T) {
+	image := api.ImageStreamTagReference{Namespace: "mirror", Name: "clonerefs", Tag: "latest"}
+	if diff := cmp.Diff(&ClonerefsOverride{Image: image, Path: "/clonerefs"}, ClonerefsOverrideFromConfig(api.ClonerefsConfiguration{Image: image})); diff != "" {
+		t.Errorf("unexpected override: %s", diff)
+	}
+	if diff := cmp.Diff(&ClonerefsOverride{Image: image, Path: "/usr/bin/clonerefs"}, ClonerefsOverrideFromConfig(api.ClonerefsConfiguration{Image: image, Path: "/usr/bin/clonerefs"})); diff != "" {
+		t.Errorf("unexpected override: %s", diff)
+	}
+}
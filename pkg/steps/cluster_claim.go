@@ -0,0 +1,208 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// clusterClaimTimeout bounds the wait for Hive to assign a cluster from
+	// the pool to a claim
+	clusterClaimTimeout = 60 * time.Minute
+	// defaultClusterClaimRetries is how many unhealthy clusters are replaced
+	// unless the test configures it
+	defaultClusterClaimRetries = 2
+)
+
+var (
+	// Allow tests to accelerate polling
+	clusterClaimInterval = 30 * time.Second
+	// clusterHealthTimeout bounds the wait for a claimed cluster to become
+	// healthy, clusters resumed from hibernation take a while to settle
+	clusterHealthTimeout = 15 * time.Minute
+)
+
+// clusterClaimFor is the claim of an attempt to get a healthy cluster; every
+// attempt uses a new claim, as Hive never assigns another cluster to a claim
+func (s *multiStageTestStep) clusterClaimFor(attempt int) *unstructured.Unstructured {
+	claim := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"clusterPoolName": s.clusterClaim.Pool,
+		},
+	}}
+	claim.SetAPIVersion("hive.openshift.io/v1")
+	claim.SetKind("ClusterClaim")
+	claim.SetNamespace(s.clusterClaim.Namespace)
+	claim.SetName(fmt.Sprintf("%s-%d", s.clusterName(), attempt))
+	claim.SetLabels(map[string]string{
+		MultiStageTestLabel: s.name,
+		ClusterOrgLabel:     s.config.Metadata.Org,
+		ClusterRepoLabel:    s.config.Metadata.Repo,
+	})
+	return claim
+}
+
+// claimCluster claims a healthy cluster from the pool of the test and returns
+// the claim, which the caller has to release, and the credentials for the
+// cluster, which steps expect in the shared secret. Clusters which are not
+// healthy are released and replaced, up to the configured number of retries.
+func (s *multiStageTestStep) claimCluster(ctx context.Context) (*unstructured.Unstructured, map[string][]byte, error) {
+	retries := defaultClusterClaimRetries
+	if s.clusterClaim.Retries != nil {
+		retries = *s.clusterClaim.Retries
+	}
+	var errs []error
+	for attempt := 0; attempt <= retries; attempt++ {
+		claim := s.clusterClaimFor(attempt)
+		data, err := s.claimHealthyCluster(ctx, claim)
+		if err == nil {
+			return claim, data, nil
+		}
+		errs = append(errs, fmt.Errorf("claim %s: %w", claim.GetName(), err))
+		Logger(ctx).Infof("Releasing cluster of claim %s for %s: %v", claim.GetName(), s.name, err)
+		if err := s.releaseCluster(postStepsContext(ctx), claim); err != nil {
+			errs = append(errs, fmt.Errorf("could not release claim %s: %w", claim.GetName(), err))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, nil, fmt.Errorf("no healthy cluster was claimed from pool %s/%s: %w", s.clusterClaim.Namespace, s.clusterClaim.Pool, utilerrors.NewAggregate(errs))
+}
+
+// claimHealthyCluster waits for Hive to assign a cluster to the claim and for
+// the cluster to be healthy, and returns the credentials for it
+func (s *multiStageTestStep) claimHealthyCluster(ctx context.Context, claim *unstructured.Unstructured) (map[string][]byte, error) {
+	Logger(ctx).Infof("Claiming cluster from pool %s/%s for %s", s.clusterClaim.Namespace, s.clusterClaim.Pool, s.name)
+	if err := s.client.Create(ctx, claim); err != nil && !kerrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("could not create ClusterClaim: %w", err)
+	}
+	namespace, err := s.waitForClusterClaim(ctx, claim)
+	if err != nil {
+		return nil, err
+	}
+	// clusters of pools are installed in a namespace named like the
+	// ClusterDeployment
+	cd := &unstructured.Unstructured{}
+	cd.SetAPIVersion("hive.openshift.io/v1")
+	cd.SetKind("ClusterDeployment")
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: namespace}, cd); err != nil {
+		return nil, fmt.Errorf("could not get ClusterDeployment of the claimed cluster: %w", err)
+	}
+	data, err := s.clusterCredentials(ctx, cd)
+	if err != nil {
+		return nil, err
+	}
+	Logger(ctx).Infof("Claimed cluster %s for %s, waiting for it to be healthy", namespace, s.name)
+	if err := waitForClusterHealth(ctx, data["kubeconfig"]); err != nil {
+		return nil, err
+	}
+	Logger(ctx).Infof("Claimed cluster %s for %s is healthy", namespace, s.name)
+	return data, nil
+}
+
+// waitForClusterClaim waits until Hive assigned a cluster to the claim and
+// returns the namespace of the cluster
+func (s *multiStageTestStep) waitForClusterClaim(ctx context.Context, claim *unstructured.Unstructured) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, clusterClaimTimeout)
+	defer cancel()
+	var namespace string
+	err := wait.PollImmediateUntil(clusterClaimInterval, func() (bool, error) {
+		if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: claim.GetNamespace(), Name: claim.GetName()}, claim); err != nil {
+			return false, fmt.Errorf("could not get ClusterClaim: %w", err)
+		}
+		namespace, _, _ = unstructured.NestedString(claim.Object, "spec", "namespace")
+		return namespace != "", nil
+	}, ctx.Done())
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return "", fmt.Errorf("no cluster was assigned to the claim in time: %w", ctx.Err())
+	}
+	return namespace, err
+}
+
+// waitForClusterHealth waits until all nodes of the cluster are ready and all
+// ClusterOperators are available and not degraded
+func waitForClusterHealth(ctx context.Context, kubeconfig []byte) error {
+	client, err := newClusterClient(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("could not create a client for the cluster: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, clusterHealthTimeout)
+	defer cancel()
+	var problems []string
+	err = wait.PollImmediateUntil(clusterClaimInterval, func() (bool, error) {
+		problems, err = clusterHealthProblems(ctx, client)
+		if err != nil {
+			// the API server of a resumed cluster may not be up yet
+			problems = []string{err.Error()}
+		}
+		return len(problems) == 0, nil
+	}, ctx.Done())
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return fmt.Errorf("cluster did not become healthy in time: %s", strings.Join(problems, "; "))
+	}
+	return err
+}
+
+// clusterHealthProblems lists the nodes which are not ready and the
+// ClusterOperators which are not available or degraded
+func clusterHealthProblems(ctx context.Context, client ctrlruntimeclient.Client) ([]string, error) {
+	var problems []string
+	nodes := &coreapi.NodeList{}
+	if err := client.List(ctx, nodes); err != nil {
+		return nil, fmt.Errorf("could not list nodes: %w", err)
+	}
+	if len(nodes.Items) == 0 {
+		problems = append(problems, "cluster has no nodes")
+	}
+	for _, node := range nodes.Items {
+		ready := false
+		for _, c := range node.Status.Conditions {
+			if c.Type == coreapi.NodeReady {
+				ready = c.Status == coreapi.ConditionTrue
+			}
+		}
+		if !ready {
+			problems = append(problems, fmt.Sprintf("node %s is not ready", node.Name))
+		}
+	}
+	operators := &unstructured.UnstructuredList{}
+	operators.SetGroupVersionKind(clusterOperatorListGVK)
+	if err := client.List(ctx, operators); err != nil {
+		return nil, fmt.Errorf("could not list ClusterOperators: %w", err)
+	}
+	if len(operators.Items) == 0 {
+		problems = append(problems, "cluster has no ClusterOperators")
+	}
+	for i := range operators.Items {
+		co := &operators.Items[i]
+		if status, message := condition(co, "Available"); status != string(coreapi.ConditionTrue) {
+			problems = append(problems, fmt.Sprintf("operator %s is not available: %s", co.GetName(), message))
+		}
+		if status, message := condition(co, "Degraded"); status == string(coreapi.ConditionTrue) {
+			problems = append(problems, fmt.Sprintf("operator %s is degraded: %s", co.GetName(), message))
+		}
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// releaseCluster deletes the claim, upon which Hive removes the cluster from
+// the pool and replaces it
+func (s *multiStageTestStep) releaseCluster(ctx context.Context, claim *unstructured.Unstructured) error {
+	if err := s.client.Delete(ctx, claim); err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
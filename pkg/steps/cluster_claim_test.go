@@ -0,0 +1,209 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+func fakeNode(name string, ready bool) *coreapi.Node {
+	status := coreapi.ConditionFalse
+	if ready {
+		status = coreapi.ConditionTrue
+	}
+	return &coreapi.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     coreapi.NodeStatus{Conditions: []coreapi.NodeCondition{{Type: coreapi.NodeReady, Status: status}}},
+	}
+}
+
+// fakePoolCluster is a cluster in the pool which Hive assigned to a claim
+func fakePoolCluster(step *multiStageTestStep, attempt int, namespace string) []runtime.Object {
+	claim := step.clusterClaimFor(attempt)
+	_ = unstructured.SetNestedField(claim.Object, namespace, "spec", "namespace")
+	cd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"installed": true,
+			"clusterMetadata": map[string]interface{}{
+				"adminKubeconfigSecretRef": map[string]interface{}{"name": "admin-kubeconfig"},
+				"adminPasswordSecretRef":   map[string]interface{}{"name": "admin-password"},
+			},
+		},
+	}}
+	cd.SetAPIVersion("hive.openshift.io/v1")
+	cd.SetKind("ClusterDeployment")
+	cd.SetNamespace(namespace)
+	cd.SetName(namespace)
+	return []runtime.Object{
+		claim,
+		cd,
+		&coreapi.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "admin-kubeconfig"}, Data: map[string][]byte{"kubeconfig": []byte(namespace)}},
+		&coreapi.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "admin-password"}, Data: map[string][]byte{"password": []byte("password")}},
+	}
+}
+
+func TestClaimCluster(t *testing.T) {
+	clusterClaimInterval, clusterHealthTimeout = time.Millisecond, 10*time.Millisecond
+	defer func() { clusterClaimInterval, clusterHealthTimeout = 30*time.Second, 15*time.Minute }()
+	// the kubeconfig of every cluster is the namespace it is installed in
+	healthy := fakeClusterClient(fakeNode("master-0", true), fakeClusterOperator("dns", "4.9.0", false))
+	unhealthy := fakeClusterClient(fakeNode("master-0", false), fakeClusterOperator("dns", "4.9.0", true))
+	clusters := map[string]ctrlruntimeclient.Client{"healthy": healthy, "unhealthy-0": unhealthy, "unhealthy-1": unhealthy}
+	original := newClusterClient
+	defer func() { newClusterClient = original }()
+	newClusterClient = func(kubeconfig []byte) (ctrlruntimeclient.Client, error) {
+		client, ok := clusters[string(kubeconfig)]
+		if !ok {
+			return nil, fmt.Errorf("unknown cluster %s", kubeconfig)
+		}
+		return client, nil
+	}
+	one := 1
+	var testCases = []struct {
+		name string
+		// assigned are the namespaces of the clusters Hive assigns to the
+		// claims of the attempts
+		assigned          []string
+		retries           *int
+		expected          map[string][]byte
+		expectedClaim     string
+		expectedErr       string
+		expectedRemaining []string
+	}{
+		{
+			name:              "healthy cluster is claimed",
+			assigned:          []string{"healthy"},
+			expected:          map[string][]byte{"kubeconfig": []byte("healthy"), "kubeadmin-password": []byte("password")},
+			expectedClaim:     "ci-cf4a83dbe747-0",
+			expectedRemaining: []string{"ci-cf4a83dbe747-0"},
+		},
+		{
+			name:              "unhealthy cluster is released and another one is claimed",
+			assigned:          []string{"unhealthy-0", "healthy"},
+			expected:          map[string][]byte{"kubeconfig": []byte("healthy"), "kubeadmin-password": []byte("password")},
+			expectedClaim:     "ci-cf4a83dbe747-1",
+			expectedRemaining: []string{"ci-cf4a83dbe747-1"},
+		},
+		{
+			name:        "retries are bounded",
+			assigned:    []string{"unhealthy-0", "unhealthy-1", "healthy"},
+			retries:     &one,
+			expectedErr: "no healthy cluster was claimed from pool pools/ocp-4.9: [claim ci-cf4a83dbe747-0: cluster did not become healthy in time: node master-0 is not ready; operator dns is degraded: broken, claim ci-cf4a83dbe747-1: cluster did not become healthy in time: node master-0 is not ready; operator dns is degraded: broken]",
+			// the claim of the attempt which was not made is left alone
+			expectedRemaining: []string{"ci-cf4a83dbe747-2"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			jobSpec := api.JobSpec{}
+			jobSpec.SetNamespace("ns")
+			step := newMultiStageTestStep(api.TestStepConfiguration{
+				As: "test",
+				MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+					ClusterClaim: &api.ClusterClaimConfiguration{Namespace: "pools", Pool: "ocp-4.9", Retries: testCase.retries},
+				},
+			}, &api.ReleaseBuildConfiguration{}, nil, nil, &jobSpec, nil, nil, nil, nil)
+			var objects []runtime.Object
+			for attempt, namespace := range testCase.assigned {
+				objects = append(objects, fakePoolCluster(step, attempt, namespace)...)
+			}
+			client := &fakePodClient{fakePodExecutor: &fakePodExecutor{LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClient(objects...))}}
+			step.client = client
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			claim, data, err := step.claimCluster(ctx)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, data); diff != "" {
+				t.Errorf("unexpected shared data: %s", diff)
+			}
+			if claim != nil {
+				if diff := cmp.Diff(testCase.expectedClaim, claim.GetName()); diff != "" {
+					t.Errorf("unexpected claim: %s", diff)
+				}
+			}
+			var remaining []string
+			for attempt := range testCase.assigned {
+				claim := step.clusterClaimFor(attempt)
+				if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "pools", Name: claim.GetName()}, claim); err == nil {
+					remaining = append(remaining, claim.GetName())
+				}
+			}
+			if diff := cmp.Diff(testCase.expectedRemaining, remaining); diff != "" {
+				t.Errorf("unexpected remaining claims: %s", diff)
+			}
+			if claim == nil {
+				return
+			}
+			if err := step.releaseCluster(context.Background(), claim); err != nil {
+				t.Fatalf("failed to release: %v", err)
+			}
+			if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "pools", Name: claim.GetName()}, claim); err == nil {
+				t.Error("expected ClusterClaim to be deleted")
+			}
+		})
+	}
+}
+
+func TestClusterHealthProblems(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		objects  []runtime.Object
+		expected []string
+	}{
+		{
+			name:    "healthy cluster",
+			objects: []runtime.Object{fakeNode("master-0", true), fakeNode("worker-0", true), fakeClusterOperator("dns", "4.9.0", false)},
+		},
+		{
+			name:     "empty cluster",
+			expected: []string{"cluster has no ClusterOperators", "cluster has no nodes"},
+		},
+		{
+			name: "unready node and unavailable operator",
+			objects: []runtime.Object{
+				fakeNode("master-0", true),
+				fakeNode("worker-0", false),
+				fakeClusterOperator("dns", "4.9.0", false),
+				&unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "config.openshift.io/v1",
+					"kind":       "ClusterOperator",
+					"metadata":   map[string]interface{}{"name": "network"},
+					"status": map[string]interface{}{"conditions": []interface{}{
+						map[string]interface{}{"type": "Available", "status": "False", "message": "starting"},
+					}},
+				}},
+			},
+			expected: []string{"node worker-0 is not ready", "operator network is not available: starting"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			problems, err := clusterHealthProblems(context.Background(), fakeClusterClient(testCase.objects...))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testCase.expected, problems); diff != "" {
+				t.Errorf("unexpected problems: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package steps
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// clusterProvisioningTimeout bounds the wait for Hive to install a cluster
+	clusterProvisioningTimeout = 90 * time.Minute
+)
+
+// Allow tests to accelerate polling
+var clusterProvisioningInterval = 30 * time.Second
+
+func (s *multiStageTestStep) clusterDeploymentName() string {
+	return s.name
+}
+
+func (s *multiStageTestStep) hiveCredentialsName() string {
+	return s.name + "-hive-credentials"
+}
+
+func (s *multiStageTestStep) hivePullSecretName() string {
+	return s.name + "-hive-pull-secret"
+}
+
+func (s *multiStageTestStep) installConfigName() string {
+	return s.name + "-install-config"
+}
+
+// clusterName is unique for every test of every job, as clusters of all jobs
+// share the base domain, and short enough to be valid on all platforms
+func (s *multiStageTestStep) clusterName() string {
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(s.jobSpec.Namespace()+"/"+s.name)))
+	return "ci-" + hash[:12]
+}
+
+// hiveInputs derives the platform credentials and the install configuration
+// Hive needs from the cluster profile
+func hiveInputs(profile map[string][]byte, clusterType, clusterName, baseDomain, region string) (map[string][]byte, []byte, error) {
+	var credentials map[string][]byte
+	platform := map[string]interface{}{"region": region}
+	switch clusterType {
+	case "aws":
+		raw, ok := profile[".awscred"]
+		if !ok {
+			return nil, nil, errors.New("cluster profile has no .awscred key")
+		}
+		keys := parseAWSCredentials(raw)
+		credentials = map[string][]byte{}
+		for _, key := range []string{"aws_access_key_id", "aws_secret_access_key"} {
+			if keys[key] == "" {
+				return nil, nil, fmt.Errorf("cluster profile has no %s in .awscred", key)
+			}
+			credentials[key] = []byte(keys[key])
+		}
+	case "gcp":
+		raw, ok := profile["gce.json"]
+		if !ok {
+			return nil, nil, errors.New("cluster profile has no gce.json key")
+		}
+		var serviceAccount struct {
+			ProjectID string `json:"project_id"`
+		}
+		if err := json.Unmarshal(raw, &serviceAccount); err != nil {
+			return nil, nil, fmt.Errorf("could not parse gce.json: %w", err)
+		}
+		platform["projectID"] = serviceAccount.ProjectID
+		credentials = map[string][]byte{"osServiceAccount.json": raw}
+	default:
+		return nil, nil, fmt.Errorf("clusters of type %s cannot be provisioned", clusterType)
+	}
+	installConfig := map[string]interface{}{
+		"apiVersion": "v1",
+		"baseDomain": baseDomain,
+		"metadata":   map[string]interface{}{"name": clusterName},
+		"platform":   map[string]interface{}{clusterType: platform},
+	}
+	if key, ok := profile["ssh-publickey"]; ok {
+		installConfig["sshKey"] = string(key)
+	}
+	raw, err := yaml.Marshal(installConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not marshal install-config.yaml: %w", err)
+	}
+	return credentials, raw, nil
+}
+
+// parseAWSCredentials reads the keys of the default profile in an AWS
+// shared credentials file
+func parseAWSCredentials(raw []byte) map[string]string {
+	keys := map[string]string{}
+	inDefault := true
+	scanner := bufio.NewScanner(strings.NewReader(string(raw)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inDefault = line == "[default]"
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if !inDefault || len(parts) != 2 {
+			continue
+		}
+		keys[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return keys
+}
+
+func (s *multiStageTestStep) clusterDeployment() *unstructured.Unstructured {
+	clusterType := s.profile.ClusterType()
+	cd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"baseDomain":  s.clusterProvisioning.BaseDomain,
+			"clusterName": s.clusterName(),
+			"platform": map[string]interface{}{
+				clusterType: map[string]interface{}{
+					"region":               s.clusterProvisioning.Region,
+					"credentialsSecretRef": map[string]interface{}{"name": s.hiveCredentialsName()},
+				},
+			},
+			"provisioning": map[string]interface{}{
+				"imageSetRef":            map[string]interface{}{"name": s.clusterProvisioning.Version},
+				"installConfigSecretRef": map[string]interface{}{"name": s.installConfigName()},
+			},
+			"pullSecretRef": map[string]interface{}{"name": s.hivePullSecretName()},
+		},
+	}}
+	cd.SetAPIVersion("hive.openshift.io/v1")
+	cd.SetKind("ClusterDeployment")
+	cd.SetNamespace(s.jobSpec.Namespace())
+	cd.SetName(s.clusterDeploymentName())
+	cd.SetLabels(map[string]string{
+		MultiStageTestLabel: s.name,
+		ClusterOrgLabel:     s.config.Metadata.Org,
+		ClusterRepoLabel:    s.config.Metadata.Repo,
+	})
+	// the cluster is garbage collected with the other resources of the job
+	// if ci-operator does not get to deprovision it
+	if owner := s.jobSpec.Owner(); owner != nil {
+		cd.SetOwnerReferences([]meta.OwnerReference{*owner})
+	}
+	return cd
+}
+
+// provisionCluster installs a cluster for the test with Hive and returns the
+// credentials for it, which steps expect in the shared secret
+func (s *multiStageTestStep) provisionCluster(ctx context.Context) (map[string][]byte, error) {
+	profile := &coreapi.Secret{}
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: s.profileSecretName()}, profile); err != nil {
+		return nil, fmt.Errorf("could not read cluster profile secret: %w", err)
+	}
+	credentials, installConfig, err := hiveInputs(profile.Data, s.profile.ClusterType(), s.clusterName(), s.clusterProvisioning.BaseDomain, s.clusterProvisioning.Region)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cluster profile %s: %w", s.profile, err)
+	}
+	pullSecret, ok := profile.Data["pull-secret"]
+	if !ok {
+		return nil, fmt.Errorf("invalid cluster profile %s: no pull-secret key", s.profile)
+	}
+	for name, data := range map[string]map[string][]byte{
+		s.hiveCredentialsName(): credentials,
+		s.hivePullSecretName():  {coreapi.DockerConfigJsonKey: pullSecret},
+		s.installConfigName():   {"install-config.yaml": installConfig},
+	} {
+		if err := s.createSecret(ctx, name, data); err != nil {
+			return nil, fmt.Errorf("could not create secret %s: %w", name, err)
+		}
+	}
+	Logger(ctx).Infof("Provisioning cluster %s (%s in %s) for %s", s.clusterName(), s.clusterProvisioning.Version, s.clusterProvisioning.Region, s.name)
+	if err := s.client.Create(ctx, s.clusterDeployment()); err != nil && !kerrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("could not create ClusterDeployment: %w", err)
+	}
+	cd, err := s.waitForClusterDeployment(ctx)
+	if err != nil {
+		return nil, err
+	}
+	Logger(ctx).Infof("Cluster %s for %s is installed", s.clusterName(), s.name)
+	return s.clusterCredentials(ctx, cd)
+}
+
+// clusterCredentials reads the kubeconfig and the password of the admin of
+// an installed cluster from the secrets referenced by its ClusterDeployment
+func (s *multiStageTestStep) clusterCredentials(ctx context.Context, cd *unstructured.Unstructured) (map[string][]byte, error) {
+	data := map[string][]byte{}
+	for _, ref := range []struct{ field, from, to string }{
+		{field: "adminKubeconfigSecretRef", from: "kubeconfig", to: "kubeconfig"},
+		{field: "adminPasswordSecretRef", from: "password", to: "kubeadmin-password"},
+	} {
+		name, _, _ := unstructured.NestedString(cd.Object, "spec", "clusterMetadata", ref.field, "name")
+		if name == "" {
+			return nil, fmt.Errorf("ClusterDeployment has no %s", ref.field)
+		}
+		secret := &coreapi.Secret{}
+		if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: cd.GetNamespace(), Name: name}, secret); err != nil {
+			return nil, fmt.Errorf("could not read secret %s of the cluster: %w", name, err)
+		}
+		data[ref.to] = secret.Data[ref.from]
+	}
+	return data, nil
+}
+
+// waitForClusterDeployment waits until Hive installed the cluster or gave up
+func (s *multiStageTestStep) waitForClusterDeployment(ctx context.Context) (*unstructured.Unstructured, error) {
+	ctx, cancel := context.WithTimeout(ctx, clusterProvisioningTimeout)
+	defer cancel()
+	cd := s.clusterDeployment()
+	err := wait.PollImmediateUntil(clusterProvisioningInterval, func() (bool, error) {
+		if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: cd.GetNamespace(), Name: cd.GetName()}, cd); err != nil {
+			return false, fmt.Errorf("could not get ClusterDeployment: %w", err)
+		}
+		if installed, _, _ := unstructured.NestedBool(cd.Object, "spec", "installed"); installed {
+			return true, nil
+		}
+		conditions, _, _ := unstructured.NestedSlice(cd.Object, "status", "conditions")
+		for _, raw := range conditions {
+			condition, ok := raw.(map[string]interface{})
+			if !ok || condition["type"] != "ProvisionStopped" || condition["status"] != string(coreapi.ConditionTrue) {
+				continue
+			}
+			return false, fmt.Errorf("provisioning of the cluster was stopped: %v", condition["message"])
+		}
+		return false, nil
+	}, ctx.Done())
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return nil, fmt.Errorf("cluster was not installed in time: %w", ctx.Err())
+	}
+	return cd, err
+}
+
+// deprovisionCluster deletes the ClusterDeployment, upon which Hive destroys
+// the cluster
+func (s *multiStageTestStep) deprovisionCluster(ctx context.Context) error {
+	if err := s.client.Delete(ctx, s.clusterDeployment()); err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
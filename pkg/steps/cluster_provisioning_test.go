@@ -0,0 +1,200 @@
+package steps
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+func TestHiveInputs(t *testing.T) {
+	var testCases = []struct {
+		name                  string
+		clusterType           string
+		profile               map[string][]byte
+		expectedCredentials   map[string][]byte
+		expectedInstallConfig string
+		expectedErr           string
+	}{
+		{
+			name:        "AWS",
+			clusterType: "aws",
+			profile: map[string][]byte{
+				".awscred":      []byte("[other]\naws_access_key_id = other\n[default]\naws_access_key_id = id\naws_secret_access_key = secret\n"),
+				"ssh-publickey": []byte("ssh-rsa key"),
+			},
+			expectedCredentials: map[string][]byte{"aws_access_key_id": []byte("id"), "aws_secret_access_key": []byte("secret")},
+			expectedInstallConfig: `apiVersion: v1
+baseDomain: ci.example.com
+metadata:
+  name: ci-cluster
+platform:
+  aws:
+    region: us-east-1
+sshKey: ssh-rsa key
+`,
+		},
+		{
+			name:                "GCP",
+			clusterType:         "gcp",
+			profile:             map[string][]byte{"gce.json": []byte(`{"project_id":"project"}`)},
+			expectedCredentials: map[string][]byte{"osServiceAccount.json": []byte(`{"project_id":"project"}`)},
+			expectedInstallConfig: `apiVersion: v1
+baseDomain: ci.example.com
+metadata:
+  name: ci-cluster
+platform:
+  gcp:
+    projectID: project
+    region: us-east-1
+`,
+		},
+		{
+			name:        "AWS credentials without secret key",
+			clusterType: "aws",
+			profile:     map[string][]byte{".awscred": []byte("[default]\naws_access_key_id = id\n")},
+			expectedErr: "cluster profile has no aws_secret_access_key in .awscred",
+		},
+		{
+			name:        "unsupported cluster type",
+			clusterType: "vsphere",
+			expectedErr: "clusters of type vsphere cannot be provisioned",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			credentials, installConfig, err := hiveInputs(testCase.profile, testCase.clusterType, "ci-cluster", "ci.example.com", "us-east-1")
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expectedCredentials, credentials); diff != "" {
+				t.Errorf("unexpected credentials: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expectedInstallConfig, string(installConfig)); diff != "" {
+				t.Errorf("unexpected install config: %s", diff)
+			}
+		})
+	}
+}
+
+func TestClusterDeploymentOwner(t *testing.T) {
+	owner := &metav1.OwnerReference{APIVersion: "image.openshift.io/v1", Kind: "ImageStream", Name: "pipeline", UID: "uid"}
+	jobSpec := api.JobSpec{}
+	jobSpec.SetNamespace("ns")
+	jobSpec.SetOwner(owner)
+	step := newMultiStageTestStep(api.TestStepConfiguration{
+		As: "test",
+		MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+			ClusterProfile:      api.ClusterProfileAWS,
+			ClusterProvisioning: &api.ClusterProvisioningConfiguration{Version: "openshift-v4.9.0", Region: "us-east-1", BaseDomain: "ci.example.com"},
+		},
+	}, &api.ReleaseBuildConfiguration{}, nil, nil, &jobSpec, nil, nil, nil, nil)
+	cd := step.clusterDeployment()
+	if diff := cmp.Diff([]metav1.OwnerReference{*owner}, cd.GetOwnerReferences()); diff != "" {
+		t.Errorf("unexpected owner references: %s", diff)
+	}
+	if domain, _, _ := unstructured.NestedString(cd.Object, "spec", "baseDomain"); domain != "ci.example.com" {
+		t.Errorf("expected base domain ci.example.com, got %s", domain)
+	}
+}
+
+func TestProvisionCluster(t *testing.T) {
+	clusterProvisioningInterval = 0
+	defer func() { clusterProvisioningInterval = 30 * time.Second }()
+	var testCases = []struct {
+		name        string
+		status      map[string]interface{}
+		expected    map[string][]byte
+		expectedErr string
+	}{
+		{
+			name:     "cluster is installed",
+			status:   map[string]interface{}{"installed": true},
+			expected: map[string][]byte{"kubeconfig": []byte("kubeconfig"), "kubeadmin-password": []byte("password")},
+		},
+		{
+			name: "provisioning is stopped",
+			status: map[string]interface{}{"conditions": []interface{}{
+				map[string]interface{}{"type": "ProvisionFailed", "status": "True", "message": "install failed"},
+				map[string]interface{}{"type": "ProvisionStopped", "status": "True", "message": "provisioning attempts exhausted"},
+			}},
+			expectedErr: "provisioning of the cluster was stopped: provisioning attempts exhausted",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			jobSpec := api.JobSpec{}
+			jobSpec.SetNamespace("ns")
+			step := newMultiStageTestStep(api.TestStepConfiguration{
+				As: "test",
+				MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+					ClusterProfile:      api.ClusterProfileAWS,
+					ClusterProvisioning: &api.ClusterProvisioningConfiguration{Version: "openshift-v4.9.0", Region: "us-east-1", BaseDomain: "ci.example.com"},
+				},
+			}, &api.ReleaseBuildConfiguration{}, nil, nil, &jobSpec, nil, nil, nil, nil)
+			// the ClusterDeployment is in its final state when we first look
+			cd := step.clusterDeployment()
+			spec := cd.Object["spec"].(map[string]interface{})
+			spec["clusterMetadata"] = map[string]interface{}{
+				"adminKubeconfigSecretRef": map[string]interface{}{"name": "admin-kubeconfig"},
+				"adminPasswordSecretRef":   map[string]interface{}{"name": "admin-password"},
+			}
+			if installed, ok := testCase.status["installed"]; ok {
+				spec["installed"] = installed
+			} else {
+				cd.Object["status"] = testCase.status
+			}
+			client := &fakePodClient{fakePodExecutor: &fakePodExecutor{LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClient(
+				&coreapi.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "test-cluster-profile"},
+					Data: map[string][]byte{
+						".awscred":    []byte("[default]\naws_access_key_id = id\naws_secret_access_key = secret\n"),
+						"pull-secret": []byte("{}"),
+					},
+				},
+				&coreapi.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "admin-kubeconfig"}, Data: map[string][]byte{"kubeconfig": []byte("kubeconfig")}},
+				&coreapi.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "admin-password"}, Data: map[string][]byte{"password": []byte("password")}},
+				cd,
+			))}}
+			step.client = client
+			data, err := step.provisionCluster(context.Background())
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, data); diff != "" {
+				t.Errorf("unexpected shared data: %s", diff)
+			}
+			for _, name := range []string{"test-hive-credentials", "test-hive-pull-secret", "test-install-config"} {
+				if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: name}, &coreapi.Secret{}); err != nil {
+					t.Errorf("expected secret %s to be created: %v", name, err)
+				}
+			}
+			if err := step.deprovisionCluster(context.Background()); err != nil {
+				t.Fatalf("failed to deprovision: %v", err)
+			}
+			remaining := &unstructured.Unstructured{}
+			remaining.SetGroupVersionKind(cd.GroupVersionKind())
+			if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "test"}, remaining); err == nil {
+				t.Error("expected ClusterDeployment to be deleted")
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+const (
+	// ClusterOrgLabel and ClusterRepoLabel are set on the ClusterDeployments
+	// of tests to count the clusters of a repository against its quota
+	ClusterOrgLabel  = "ci.openshift.io/cluster-org"
+	ClusterRepoLabel = "ci.openshift.io/cluster-repo"
+)
+
+// ClusterUsage records how long a test held a cluster it provisioned
+type ClusterUsage struct {
+	Org             string    `json:"org"`
+	Repo            string    `json:"repo"`
+	Branch          string    `json:"branch"`
+	Variant         string    `json:"variant,omitempty"`
+	Test            string    `json:"test"`
+	JobName         string    `json:"job_name"`
+	BuildID         string    `json:"build_id"`
+	ClusterType     string    `json:"cluster_type"`
+	Cluster         string    `json:"cluster"`
+	Started         time.Time `json:"started"`
+	Finished        time.Time `json:"finished"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
+// ClusterUsageSink receives the usage of clusters, for capacity planning
+type ClusterUsageSink interface {
+	// Name identifies the sink in logs
+	Name() string
+	// Send delivers the usage to the sink
+	Send(usage ClusterUsage) error
+}
+
+// httpClusterUsageSink posts the usage of clusters to a server
+type httpClusterUsageSink struct {
+	client  *http.Client
+	address string
+}
+
+// NewHTTPClusterUsageSink creates a sink posting the usage as JSON to the
+// /cluster-usage endpoint of the server at the address
+func NewHTTPClusterUsageSink(address string) ClusterUsageSink {
+	return &httpClusterUsageSink{client: &http.Client{Timeout: 10 * time.Second}, address: strings.TrimSuffix(address, "/")}
+}
+
+func (s *httpClusterUsageSink) Name() string {
+	return fmt.Sprintf("cluster usage server %s", s.address)
+}
+
+func (s *httpClusterUsageSink) Send(usage ClusterUsage) error {
+	data, err := json.Marshal(usage)
+	if err != nil {
+		return fmt.Errorf("could not marshal usage: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/cluster-usage", s.address), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("response was not 200: %d %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// ClusterQuotas limit how many clusters the tests of a repository may hold
+// at once, by org/repo or by org for the repositories without a quota of
+// their own
+type ClusterQuotas map[string]int
+
+// LoadClusterQuotas reads the quotas from a YAML file mapping org/repo or
+// org to the number of clusters
+func LoadClusterQuotas(path string) (ClusterQuotas, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read cluster quotas: %w", err)
+	}
+	var quotas ClusterQuotas
+	if err := yaml.UnmarshalStrict(raw, &quotas); err != nil {
+		return nil, fmt.Errorf("could not parse cluster quotas: %w", err)
+	}
+	for key, quota := range quotas {
+		if parts := strings.Split(key, "/"); len(parts) > 2 || parts[0] == "" || len(parts) == 2 && parts[1] == "" {
+			return nil, fmt.Errorf("quota %q must be for org/repo or org", key)
+		}
+		if quota < 0 {
+			return nil, fmt.Errorf("quota for %s must not be negative, got %d", key, quota)
+		}
+	}
+	return quotas, nil
+}
+
+// quotaFor returns the quota which applies to the repository, the labels
+// selecting the clusters counted against it and whether there is one
+func (q ClusterQuotas) quotaFor(org, repo string) (int, map[string]string, bool) {
+	if quota, ok := q[fmt.Sprintf("%s/%s", org, repo)]; ok {
+		return quota, map[string]string{ClusterOrgLabel: org, ClusterRepoLabel: repo}, true
+	}
+	if quota, ok := q[org]; ok {
+		return quota, map[string]string{ClusterOrgLabel: org}, true
+	}
+	return 0, nil, false
+}
+
+// ClusterAccounting enforces the quotas of repositories when tests provision
+// clusters and reports how long the clusters were held
+type ClusterAccounting struct {
+	Quotas ClusterQuotas
+	Sinks  []ClusterUsageSink
+}
+
+type clusterAccountingKey struct{}
+
+// WithClusterAccounting returns a context in which the provisioning of
+// clusters is accounted for
+func WithClusterAccounting(ctx context.Context, accounting *ClusterAccounting) context.Context {
+	return context.WithValue(ctx, clusterAccountingKey{}, accounting)
+}
+
+func clusterAccountingFrom(ctx context.Context) *ClusterAccounting {
+	accounting, _ := ctx.Value(clusterAccountingKey{}).(*ClusterAccounting)
+	return accounting
+}
+
+// checkClusterQuota refuses to provision a cluster for the test when the
+// clusters of its repository already exhaust its quota. Clusters are counted
+// when they are provisioned, so concurrent tests may both take the last one.
+func (s *multiStageTestStep) checkClusterQuota(ctx context.Context) error {
+	accounting := clusterAccountingFrom(ctx)
+	if accounting == nil {
+		return nil
+	}
+	quota, selector, ok := accounting.Quotas.quotaFor(s.config.Metadata.Org, s.config.Metadata.Repo)
+	if !ok {
+		return nil
+	}
+	clusters := &unstructured.UnstructuredList{}
+	clusters.SetAPIVersion("hive.openshift.io/v1")
+	clusters.SetKind("ClusterDeploymentList")
+	if err := s.client.List(ctx, clusters, ctrlruntimeclient.MatchingLabels(selector)); err != nil {
+		return fmt.Errorf("could not count the clusters in use: %w", err)
+	}
+	var used int
+	for _, cluster := range clusters.Items {
+		if cluster.GetNamespace() != s.jobSpec.Namespace() || cluster.GetName() != s.clusterDeploymentName() {
+			used++
+		}
+	}
+	if used >= quota {
+		return results.ForReason(results.ReasonClusterQuotaExceeded).ForError(fmt.Errorf("%d clusters are in use by the tests of %s, which exhausts its quota of %d", used, strings.Join(selectorValues(selector), "/"), quota))
+	}
+	return nil
+}
+
+func selectorValues(selector map[string]string) []string {
+	values := []string{selector[ClusterOrgLabel]}
+	if repo, ok := selector[ClusterRepoLabel]; ok {
+		values = append(values, repo)
+	}
+	return values
+}
+
+// reportClusterUsage sends how long the test held its cluster to the sinks.
+// Reporting is best-effort, so errors are logged but not exposed.
+func (s *multiStageTestStep) reportClusterUsage(ctx context.Context, started, finished time.Time) {
+	accounting := clusterAccountingFrom(ctx)
+	if accounting == nil {
+		return
+	}
+	usage := ClusterUsage{
+		Org:             s.config.Metadata.Org,
+		Repo:            s.config.Metadata.Repo,
+		Branch:          s.config.Metadata.Branch,
+		Variant:         s.config.Metadata.Variant,
+		Test:            s.name,
+		JobName:         s.jobSpec.Job,
+		BuildID:         s.jobSpec.BuildID,
+		ClusterType:     s.profile.ClusterType(),
+		Cluster:         s.clusterName(),
+		Started:         started,
+		Finished:        finished,
+		DurationSeconds: finished.Sub(started).Seconds(),
+	}
+	for _, sink := range accounting.Sinks {
+		if err := sink.Send(usage); err != nil {
+			Logger(ctx).Infof("Failed to report the usage of cluster %s to %s: %v", usage.Cluster, sink.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+func TestLoadClusterQuotas(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		raw         string
+		expected    ClusterQuotas
+		expectedErr string
+	}{
+		{
+			name:     "quotas for repositories and orgs",
+			raw:      "org/repo: 2\norg: 5\nother: 0\n",
+			expected: ClusterQuotas{"org/repo": 2, "org": 5, "other": 0},
+		},
+		{
+			name:        "quota for a branch",
+			raw:         "org/repo/branch: 2\n",
+			expectedErr: `quota "org/repo/branch" must be for org/repo or org`,
+		},
+		{
+			name:        "negative quota",
+			raw:         "org/repo: -1\n",
+			expectedErr: "quota for org/repo must not be negative, got -1",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "quotas.yaml")
+			if err := ioutil.WriteFile(path, []byte(testCase.raw), 0644); err != nil {
+				t.Fatal(err)
+			}
+			quotas, err := LoadClusterQuotas(path)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, quotas); diff != "" {
+				t.Errorf("unexpected quotas: %s", diff)
+			}
+		})
+	}
+}
+
+func clusterDeploymentFor(namespace, name, org, repo string) *unstructured.Unstructured {
+	cd := &unstructured.Unstructured{}
+	cd.SetAPIVersion("hive.openshift.io/v1")
+	cd.SetKind("ClusterDeployment")
+	cd.SetNamespace(namespace)
+	cd.SetName(name)
+	cd.SetLabels(map[string]string{ClusterOrgLabel: org, ClusterRepoLabel: repo})
+	return cd
+}
+
+func TestCheckClusterQuota(t *testing.T) {
+	clusters := []runtime.Object{
+		clusterDeploymentFor("ci-op-1", "e2e", "org", "repo"),
+		clusterDeploymentFor("ci-op-2", "e2e", "org", "other"),
+		// a cluster of this test does not count against its quota
+		clusterDeploymentFor("ns", "test", "org", "repo"),
+	}
+	var testCases = []struct {
+		name           string
+		accounting     *ClusterAccounting
+		expectedErr    string
+		expectedReason string
+	}{
+		{
+			name: "no accounting",
+		},
+		{
+			name:       "no quota for the repository",
+			accounting: &ClusterAccounting{Quotas: ClusterQuotas{"other-org": 0}},
+		},
+		{
+			name:       "repository is below its quota",
+			accounting: &ClusterAccounting{Quotas: ClusterQuotas{"org/repo": 2, "org": 1}},
+		},
+		{
+			name:           "repository exhausts its quota",
+			accounting:     &ClusterAccounting{Quotas: ClusterQuotas{"org/repo": 1, "org": 5}},
+			expectedErr:    "1 clusters are in use by the tests of org/repo, which exhausts its quota of 1",
+			expectedReason: "cluster_quota_exceeded",
+		},
+		{
+			name:           "org exhausts its quota",
+			accounting:     &ClusterAccounting{Quotas: ClusterQuotas{"org": 2}},
+			expectedErr:    "2 clusters are in use by the tests of org, which exhausts its quota of 2",
+			expectedReason: "cluster_quota_exceeded",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			gv := schema.GroupVersion{Group: "hive.openshift.io", Version: "v1"}
+			scheme.AddKnownTypeWithName(gv.WithKind("ClusterDeployment"), &unstructured.Unstructured{})
+			scheme.AddKnownTypeWithName(gv.WithKind("ClusterDeploymentList"), &unstructured.UnstructuredList{})
+			var objects []runtime.Object
+			for _, cluster := range clusters {
+				objects = append(objects, cluster.DeepCopyObject())
+			}
+			jobSpec := api.JobSpec{}
+			jobSpec.SetNamespace("ns")
+			step := &multiStageTestStep{
+				name:    "test",
+				config:  &api.ReleaseBuildConfiguration{Metadata: api.Metadata{Org: "org", Repo: "repo", Branch: "master"}},
+				jobSpec: &jobSpec,
+				client:  &fakePodClient{fakePodExecutor: &fakePodExecutor{LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClientWithScheme(scheme, objects...))}},
+			}
+			ctx := context.Background()
+			if testCase.accounting != nil {
+				ctx = WithClusterAccounting(ctx, testCase.accounting)
+			}
+			err := step.checkClusterQuota(ctx)
+			var actualErr, actualReason string
+			if err != nil {
+				actualErr, actualReason = err.Error(), results.FullReason(err)
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expectedReason, actualReason); diff != "" {
+				t.Errorf("unexpected reason: %s", diff)
+			}
+		})
+	}
+}
+
+type fakeClusterUsageSink struct {
+	sent []ClusterUsage
+}
+
+func (s *fakeClusterUsageSink) Name() string { return "fake" }
+
+func (s *fakeClusterUsageSink) Send(usage ClusterUsage) error {
+	s.sent = append(s.sent, usage)
+	return nil
+}
+
+func TestReportClusterUsage(t *testing.T) {
+	var received []ClusterUsage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cluster-usage" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var usage ClusterUsage
+		if err := json.NewDecoder(r.Body).Decode(&usage); err != nil {
+			t.Errorf("could not decode usage: %v", err)
+		}
+		received = append(received, usage)
+	}))
+	defer server.Close()
+	sink := &fakeClusterUsageSink{}
+	jobSpec := api.JobSpec{}
+	jobSpec.Job = "job"
+	jobSpec.BuildID = "1"
+	jobSpec.SetNamespace("ns")
+	step := &multiStageTestStep{
+		name:    "e2e",
+		profile: api.ClusterProfileAWS,
+		config:  &api.ReleaseBuildConfiguration{Metadata: api.Metadata{Org: "org", Repo: "repo", Branch: "master"}},
+		jobSpec: &jobSpec,
+	}
+	started := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+	finished := started.Add(90 * time.Minute)
+	ctx := WithClusterAccounting(context.Background(), &ClusterAccounting{Sinks: []ClusterUsageSink{sink, NewHTTPClusterUsageSink(server.URL + "/")}})
+	step.reportClusterUsage(ctx, started, finished)
+	expected := []ClusterUsage{{
+		Org:             "org",
+		Repo:            "repo",
+		Branch:          "master",
+		Test:            "e2e",
+		JobName:         "job",
+		BuildID:         "1",
+		ClusterType:     "aws",
+		Cluster:         step.clusterName(),
+		Started:         started,
+		Finished:        finished,
+		DurationSeconds: 5400,
+	}}
+	if diff := cmp.Diff(expected, sink.sent); diff != "" {
+		t.Errorf("unexpected usage sent to the sink: %s", diff)
+	}
+	if diff := cmp.Diff(expected, received); diff != "" {
+		t.Errorf("unexpected usage received by the server: %s", diff)
+	}
+}
@@ -44,6 +44,7 @@ func E2ETestStep(
 	templateClient steps.TemplateClient,
 	jobSpec *api.JobSpec,
 	resources api.ResourceConfiguration,
+	gathering api.ArtifactGatheringConfiguration,
 ) (api.Step, error) {
 	var template *templateapi.Template
 	if err := yaml.Unmarshal([]byte(installTemplateE2E), &template); err != nil {
@@ -96,7 +97,7 @@ func E2ETestStep(
 		params = api.NewOverrideParameters(params, overrides)
 	}
 
-	step := steps.TemplateExecutionStep(template, params, podClient, templateClient, jobSpec, resources)
+	step := steps.TemplateExecutionStep(template, params, podClient, templateClient, jobSpec, resources, gathering)
 	subTests, ok := step.(nestedSubTests)
 	if !ok {
 		return nil, fmt.Errorf("unexpected %T", step)
@@ -121,12 +122,12 @@ func (s *e2eTestStep) Inputs() (api.InputDefinition, error) {
 func (*e2eTestStep) Validate() error { return nil }
 
 func (s *e2eTestStep) Run(ctx context.Context) error {
-	return results.ForReason("installing_cluster").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonInstallingCluster).ForError(s.run(ctx))
 }
 
 func (s *e2eTestStep) run(ctx context.Context) error {
 	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: fmt.Sprintf("%s-cluster-profile", s.testConfig.As)}, &corev1.Secret{}); err != nil {
-		return results.ForReason("missing_cluster_profile").WithError(err).Errorf("could not find required secret: %v", err)
+		return results.ForReason(results.ReasonMissingClusterProfile).WithError(err).Errorf("could not find required secret: %v", err)
 	}
 	return s.step.Run(ctx)
 }
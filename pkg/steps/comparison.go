@@ -0,0 +1,170 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/junit"
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+const (
+	// ComparisonBaseline is the suffix of the test name for the baseline run
+	ComparisonBaseline = "baseline"
+	// ComparisonCandidate is the suffix of the test name for the candidate run
+	ComparisonCandidate = "candidate"
+	// ComparisonArtifact is the name of the combined artifact written for
+	// comparison runs
+	ComparisonArtifact = "comparison.json"
+)
+
+// ComparisonResult is the outcome of one side of a comparison run
+type ComparisonResult struct {
+	Name     string                         `json:"name"`
+	Passed   bool                           `json:"passed"`
+	Duration time.Duration                  `json:"duration"`
+	Error    string                         `json:"error,omitempty"`
+	Steps    []api.CIOperatorStepDetailInfo `json:"steps,omitempty"`
+}
+
+// Comparison is the combined artifact of a comparison run
+type Comparison struct {
+	Test      string           `json:"test"`
+	Baseline  ComparisonResult `json:"baseline"`
+	Candidate ComparisonResult `json:"candidate"`
+}
+
+// comparisonStep runs the same test against a baseline and a candidate
+// concurrently and records the results of both side by side.
+type comparisonStep struct {
+	name                string
+	baseline, candidate api.Step
+	comparison          *Comparison
+}
+
+// ComparisonStep wraps the baseline and candidate runs of a test. The two
+// steps must not share any resources, so they can run at the same time.
+func ComparisonStep(name string, baseline, candidate api.Step) api.Step {
+	return &comparisonStep{name: name, baseline: baseline, candidate: candidate}
+}
+
+func (s *comparisonStep) Inputs() (api.InputDefinition, error) {
+	return nil, nil
+}
+
+func (s *comparisonStep) Validate() error {
+	return utilerrors.NewAggregate([]error{s.baseline.Validate(), s.candidate.Validate()})
+}
+
+func (s *comparisonStep) Run(ctx context.Context) error {
+	return results.ForReason(results.ReasonExecutingComparison).ForError(s.run(ctx))
+}
+
+func (s *comparisonStep) run(ctx context.Context) error {
+	var baseline, candidate ComparisonResult
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		baseline = runForComparison(ctx, s.baseline)
+	}()
+	go func() {
+		defer wg.Done()
+		candidate = runForComparison(ctx, s.candidate)
+	}()
+	wg.Wait()
+	s.comparison = &Comparison{Test: s.name, Baseline: baseline, Candidate: candidate}
+	var errs []error
+	if err := s.saveComparison(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("could not save comparison: %w", err))
+	}
+	for _, result := range []ComparisonResult{baseline, candidate} {
+		if !result.Passed {
+			errs = append(errs, fmt.Errorf("%s failed: %s", result.Name, result.Error))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func runForComparison(ctx context.Context, step api.Step) ComparisonResult {
+	start := time.Now()
+	err := step.Run(ctx)
+	result := ComparisonResult{
+		Name:     step.Name(),
+		Passed:   err == nil,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if reporter, ok := step.(SubStepReporter); ok {
+		result.Steps = reporter.SubSteps()
+	}
+	return result
+}
+
+func (s *comparisonStep) saveComparison(ctx context.Context) error {
+	artifactDir, set := api.ArtifactsFor(ctx)
+	if !set {
+		return nil
+	}
+	dir := filepath.Join(artifactDir, s.name)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("unable to create directory %s: %w", dir, err)
+	}
+	raw, err := json.MarshalIndent(s.comparison, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, ComparisonArtifact), raw, 0640)
+}
+
+func (s *comparisonStep) Requires() []api.StepLink {
+	return append(s.baseline.Requires(), s.candidate.Requires()...)
+}
+
+func (s *comparisonStep) Creates() []api.StepLink {
+	return append(s.baseline.Creates(), s.candidate.Creates()...)
+}
+
+func (s *comparisonStep) Provides() api.ParameterMap { return nil }
+
+func (s *comparisonStep) Name() string { return s.name }
+
+func (s *comparisonStep) Description() string {
+	return fmt.Sprintf("Compare test %s between %s and %s runs", s.name, ComparisonBaseline, ComparisonCandidate)
+}
+
+func (s *comparisonStep) Objects() []ctrlruntimeclient.Object {
+	return append(s.baseline.Objects(), s.candidate.Objects()...)
+}
+
+func (s *comparisonStep) SubTests() []*junit.TestCase {
+	var ret []*junit.TestCase
+	for _, step := range []api.Step{s.baseline, s.candidate} {
+		if reporter, ok := step.(subtestReporter); ok {
+			ret = append(ret, reporter.SubTests()...)
+		}
+	}
+	return ret
+}
+
+func (s *comparisonStep) SubSteps() []api.CIOperatorStepDetailInfo {
+	var ret []api.CIOperatorStepDetailInfo
+	for _, step := range []api.Step{s.baseline, s.candidate} {
+		if reporter, ok := step.(SubStepReporter); ok {
+			ret = append(ret, reporter.SubSteps()...)
+		}
+	}
+	return ret
+}
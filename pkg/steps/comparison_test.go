@@ -0,0 +1,68 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestComparisonStep(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		candidateErr      error
+		expectedErr       bool
+		expectedCandidate bool
+	}{
+		{
+			name:              "both sides pass",
+			expectedCandidate: true,
+		},
+		{
+			name:         "candidate fails",
+			candidateErr: errors.New("regressed"),
+			expectedErr:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			artifactDir := t.TempDir()
+			previous, set := os.LookupEnv("ARTIFACTS")
+			if err := os.Setenv("ARTIFACTS", artifactDir); err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				if set {
+					os.Setenv("ARTIFACTS", previous)
+				} else {
+					os.Unsetenv("ARTIFACTS")
+				}
+			}()
+			baseline := &fakeStep{name: "perf-baseline"}
+			candidate := &fakeStep{name: "perf-candidate", runErr: tc.candidateErr}
+			err := ComparisonStep("perf", baseline, candidate).Run(context.Background())
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error: %t, got: %v", tc.expectedErr, err)
+			}
+			if baseline.numRuns != 1 || candidate.numRuns != 1 {
+				t.Errorf("expected both sides to run once, got %d and %d", baseline.numRuns, candidate.numRuns)
+			}
+			raw, err := ioutil.ReadFile(filepath.Join(artifactDir, "perf", ComparisonArtifact))
+			if err != nil {
+				t.Fatalf("could not read comparison: %v", err)
+			}
+			var comparison Comparison
+			if err := json.Unmarshal(raw, &comparison); err != nil {
+				t.Fatalf("could not parse comparison: %v", err)
+			}
+			if comparison.Baseline.Name != "perf-baseline" || !comparison.Baseline.Passed {
+				t.Errorf("unexpected baseline result: %#v", comparison.Baseline)
+			}
+			if comparison.Candidate.Name != "perf-candidate" || comparison.Candidate.Passed != tc.expectedCandidate {
+				t.Errorf("unexpected candidate result: %#v", comparison.Candidate)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package steps
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// DependencyOverridesEnv holds dependency overrides passed to a job as a
+// parameter, in the same form as the --dependency-override-param flag and
+// separated by commas or whitespace
+const DependencyOverridesEnv = "DEPENDENCY_OVERRIDES"
+
+// DependencyOverrides replaces the images of dependencies of multi-stage test
+// steps with arbitrary pull specs, keyed by the environment variable the
+// dependency is exposed in
+type DependencyOverrides map[string]string
+
+// ParseDependencyOverrides parses overrides in the form ENV=PULLSPEC
+func ParseDependencyOverrides(values []string) (DependencyOverrides, error) {
+	overrides := DependencyOverrides{}
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("dependency override must be in the form ENV=PULLSPEC, not %q", value)
+		}
+		if existing, ok := overrides[parts[0]]; ok && existing != parts[1] {
+			return nil, fmt.Errorf("dependency override %q: dependency %s is already overridden with %s", value, parts[0], existing)
+		}
+		overrides[parts[0]] = parts[1]
+	}
+	return overrides, nil
+}
+
+// Validate ensures that every override replaces a dependency declared by a
+// step of a multi-stage test in the configuration
+func (o DependencyOverrides) Validate(config *api.ReleaseBuildConfiguration) error {
+	declared := map[string]bool{}
+	for _, test := range config.Tests {
+		literal := test.MultiStageTestConfigurationLiteral
+		if literal == nil {
+			continue
+		}
+		for _, phase := range [][]api.LiteralTestStep{literal.Pre, literal.Test, literal.Post} {
+			for _, step := range phase {
+				for _, dependency := range step.Dependencies {
+					declared[dependency.Env] = true
+				}
+			}
+		}
+	}
+	var undeclared []string
+	for env := range o {
+		if !declared[env] {
+			undeclared = append(undeclared, env)
+		}
+	}
+	if len(undeclared) > 0 {
+		sort.Strings(undeclared)
+		return fmt.Errorf("dependencies %s are overridden, but no step of the job declares them", strings.Join(undeclared, ", "))
+	}
+	return nil
+}
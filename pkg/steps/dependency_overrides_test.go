@@ -0,0 +1,91 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestParseDependencyOverrides(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		values      []string
+		expected    DependencyOverrides
+		expectedErr string
+	}{
+		{
+			name:     "no overrides",
+			expected: DependencyOverrides{},
+		},
+		{
+			name:     "overrides",
+			values:   []string{"OO_INDEX=quay.io/org/index:v1", "INSTALLER=quay.io/org/installer@sha256:abc", "OO_INDEX=quay.io/org/index:v1"},
+			expected: DependencyOverrides{"OO_INDEX": "quay.io/org/index:v1", "INSTALLER": "quay.io/org/installer@sha256:abc"},
+		},
+		{
+			name:        "missing pull spec",
+			values:      []string{"OO_INDEX="},
+			expectedErr: `dependency override must be in the form ENV=PULLSPEC, not "OO_INDEX="`,
+		},
+		{
+			name:        "conflicting overrides",
+			values:      []string{"OO_INDEX=quay.io/org/index:v1", "OO_INDEX=quay.io/org/index:v2"},
+			expectedErr: `dependency override "OO_INDEX=quay.io/org/index:v2": dependency OO_INDEX is already overridden with quay.io/org/index:v1`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			overrides, err := ParseDependencyOverrides(testCase.values)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, overrides); diff != "" {
+				t.Errorf("unexpected overrides: %s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateDependencyOverrides(t *testing.T) {
+	config := &api.ReleaseBuildConfiguration{
+		Tests: []api.TestStepConfiguration{
+			{As: "unit", ContainerTestConfiguration: &api.ContainerTestConfiguration{From: "src"}},
+			{As: "e2e", MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+				Pre:  []api.LiteralTestStep{{As: "install", Dependencies: []api.StepDependency{{Name: "installer", Env: "INSTALLER"}}}},
+				Test: []api.LiteralTestStep{{As: "test", Dependencies: []api.StepDependency{{Name: "ci-index", Env: "OO_INDEX"}}}},
+			}},
+		},
+	}
+	var testCases = []struct {
+		name        string
+		overrides   DependencyOverrides
+		expectedErr string
+	}{
+		{
+			name:      "declared dependencies",
+			overrides: DependencyOverrides{"INSTALLER": "quay.io/org/installer:fix", "OO_INDEX": "quay.io/org/index:v1"},
+		},
+		{
+			name:        "undeclared dependencies",
+			overrides:   DependencyOverrides{"INSTALLER": "quay.io/org/installer:fix", "TESTS": "quay.io/org/tests:v1", "BUNDLE": "quay.io/org/bundle:v1"},
+			expectedErr: "dependencies BUNDLE, TESTS are overridden, but no step of the job declares them",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var actualErr string
+			if err := testCase.overrides.Validate(config); err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+		})
+	}
+}
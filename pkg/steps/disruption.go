@@ -0,0 +1,91 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-tools/pkg/telemetry"
+)
+
+// defaultDisruptionRetries is how many times a pod of a test is re-created
+// after it was disrupted, unless the test configures otherwise
+const defaultDisruptionRetries = 1
+
+// disruptionReasons are the reasons with which the infrastructure terminates
+// pods before their commands complete, either as the reason of the pod or
+// as the reason of its DisruptionTarget condition
+var disruptionReasons = map[string]bool{
+	"Evicted":                true,
+	"NodeLost":               true,
+	"NodeShutdown":           true,
+	"DeletionByTaintManager": true,
+	"EvictionByEvictionAPI":  true,
+	"TerminationByKubelet":   true,
+}
+
+// podDisruptionTarget is the condition set on pods which are terminated by
+// the infrastructure
+const podDisruptionTarget coreapi.PodConditionType = "DisruptionTarget"
+
+// podDisruption determines why the pod was terminated by the infrastructure,
+// if it was. Pods which completed on their own are never disrupted.
+func podDisruption(pod *coreapi.Pod) (string, bool) {
+	if pod == nil || pod.Status.Phase == coreapi.PodSucceeded {
+		return "", false
+	}
+	if disruptionReasons[pod.Status.Reason] {
+		return pod.Status.Reason, true
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == podDisruptionTarget && condition.Status == coreapi.ConditionTrue && disruptionReasons[condition.Reason] {
+			return condition.Reason, true
+		}
+	}
+	return "", false
+}
+
+// podDisruptedError is returned when a pod failed because the infrastructure
+// terminated it, so that it may be re-created
+type podDisruptedError struct {
+	reason  string
+	wrapped error
+}
+
+func (e *podDisruptedError) Error() string {
+	return fmt.Sprintf("%v (the pod was disrupted: %s)", e.wrapped, e.reason)
+}
+
+func (e *podDisruptedError) Unwrap() error {
+	return e.wrapped
+}
+
+// maxDisruptionRetries is how many times a pod of the test is re-created
+// after it was disrupted
+func (s *multiStageTestStep) maxDisruptionRetries() int {
+	if s.disruptionRetries == nil {
+		return defaultDisruptionRetries
+	}
+	return *s.disruptionRetries
+}
+
+// runDisruptablePod runs the pod, re-creating it when it is disrupted before
+// it completes as many times as the test allows. Disruptions are not failures
+// of the step, so they do not count against its retries.
+func (s *multiStageTestStep) runDisruptablePod(ctx context.Context, pod *coreapi.Pod) error {
+	original := pod.DeepCopy()
+	err := s.runPod(ctx, pod, NewTestCaseNotifier(NopNotifier))
+	for disruptions := 1; err != nil && ctx.Err() == nil && disruptions <= s.maxDisruptionRetries(); disruptions++ {
+		var disrupted *podDisruptedError
+		if !errors.As(err, &disrupted) {
+			break
+		}
+		Logger(ctx).Infof("Pod %s was disrupted (%s) before it completed, re-creating it", pod.Name, disrupted.reason)
+		telemetry.RecordRetry(ctx)
+		pod = original.DeepCopy()
+		err = s.runPod(ctx, pod, NewTestCaseNotifier(NopNotifier))
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package steps
+
+import (
+	"testing"
+
+	coreapi "k8s.io/api/core/v1"
+)
+
+func TestPodDisruption(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		status         coreapi.PodStatus
+		expected       bool
+		expectedReason string
+	}{{
+		name:   "pod failed on its own",
+		status: coreapi.PodStatus{Phase: coreapi.PodFailed},
+	}, {
+		name:           "pod evicted",
+		status:         coreapi.PodStatus{Phase: coreapi.PodFailed, Reason: "Evicted"},
+		expected:       true,
+		expectedReason: "Evicted",
+	}, {
+		name:           "node lost",
+		status:         coreapi.PodStatus{Phase: coreapi.PodUnknown, Reason: "NodeLost"},
+		expected:       true,
+		expectedReason: "NodeLost",
+	}, {
+		name: "pod deleted by the taint manager",
+		status: coreapi.PodStatus{Phase: coreapi.PodRunning, Conditions: []coreapi.PodCondition{{
+			Type: podDisruptionTarget, Status: coreapi.ConditionTrue, Reason: "DeletionByTaintManager",
+		}}},
+		expected:       true,
+		expectedReason: "DeletionByTaintManager",
+	}, {
+		name: "pod preempted",
+		status: coreapi.PodStatus{Phase: coreapi.PodFailed, Conditions: []coreapi.PodCondition{{
+			Type: podDisruptionTarget, Status: coreapi.ConditionTrue, Reason: "PreemptionByScheduler",
+		}}},
+	}, {
+		name:   "pod succeeded",
+		status: coreapi.PodStatus{Phase: coreapi.PodSucceeded, Reason: "Evicted"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			reason, disrupted := podDisruption(&coreapi.Pod{Status: tc.status})
+			if disrupted != tc.expected || reason != tc.expectedReason {
+				t.Errorf("expected %t (%q), got %t (%q)", tc.expected, tc.expectedReason, disrupted, reason)
+			}
+		})
+	}
+}
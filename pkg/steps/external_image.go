@@ -0,0 +1,130 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+	"github.com/openshift/ci-tools/pkg/steps/utils"
+)
+
+// externalImageStep imports an image from outside of the
+// CI system into the pipeline ImageStream and verifies
+// that it resolves to the expected digest
+type externalImageStep struct {
+	config  api.ExternalImageDependency
+	client  loggingclient.LoggingClient
+	imports *ImportManager
+	jobSpec *api.JobSpec
+}
+
+func (s *externalImageStep) Inputs() (api.InputDefinition, error) {
+	return api.InputDefinition{s.config.PullSpec, s.config.Digest}, nil
+}
+
+func (*externalImageStep) Validate() error { return nil }
+
+func (s *externalImageStep) Run(ctx context.Context) error {
+	return results.ForReason(results.ReasonImportingExternalImage).ForError(s.run(ctx))
+}
+
+func (s *externalImageStep) run(ctx context.Context) error {
+	tag := s.config.PipelineTag()
+	pullSpec, err := pullSpecByDigest(s.config)
+	if err != nil {
+		return err
+	}
+	Logger(ctx).Infof("Importing %s into %s:%s", pullSpec, api.PipelineImageStream, tag)
+	streamImport := &imagev1.ImageStreamImport{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: s.jobSpec.Namespace(),
+			Name:      api.PipelineImageStream,
+		},
+		Spec: imagev1.ImageStreamImportSpec{
+			Import: true,
+			Images: []imagev1.ImageImportSpec{{
+				To: &coreapi.LocalObjectReference{Name: string(tag)},
+				From: coreapi.ObjectReference{
+					Kind: "DockerImage",
+					Name: pullSpec,
+				},
+				ReferencePolicy: imagev1.TagReferencePolicy{
+					Type: imagev1.LocalTagReferencePolicy,
+				},
+			}},
+		},
+	}
+	image, err := s.imports.Import(ctx, s.client, streamImport)
+	if err != nil {
+		return fmt.Errorf("unable to import external image %s: %w", s.config.PullSpec, err)
+	}
+	digest := image.Image.Name
+	if digest != s.config.Digest {
+		return results.ForReason(results.ReasonExternalImageDigestMismatch).ForError(fmt.Errorf("external image %s resolved to digest %s, expected %s", s.config.PullSpec, digest, s.config.Digest))
+	}
+	return nil
+}
+
+// pullSpecByDigest replaces the tag of the pull spec of the dependency with
+// the expected digest, so that the import cannot resolve to another image
+// when the tag moves
+func pullSpecByDigest(config api.ExternalImageDependency) (string, error) {
+	named, err := reference.ParseNormalizedNamed(config.PullSpec)
+	if err != nil {
+		return "", fmt.Errorf("invalid pull spec %s: %w", config.PullSpec, err)
+	}
+	canonical, err := reference.WithDigest(reference.TrimNamed(named), digest.Digest(config.Digest))
+	if err != nil {
+		return "", fmt.Errorf("invalid digest %s: %w", config.Digest, err)
+	}
+	return canonical.String(), nil
+}
+
+func (s *externalImageStep) Requires() []api.StepLink {
+	return nil
+}
+
+func (s *externalImageStep) Creates() []api.StepLink {
+	return []api.StepLink{api.InternalImageLink(s.config.PipelineTag())}
+}
+
+func (s *externalImageStep) Provides() api.ParameterMap {
+	tag := s.config.PipelineTag()
+	return api.ParameterMap{
+		utils.PipelineImageEnvFor(tag): utils.ImageDigestFor(s.client, s.jobSpec.Namespace, api.PipelineImageStream, string(tag)),
+	}
+}
+
+func (s *externalImageStep) Name() string {
+	return fmt.Sprintf("[external:%s]", s.config.PipelineTag())
+}
+
+func (s *externalImageStep) Description() string {
+	return fmt.Sprintf("Import the external image %s into the pipeline and verify its digest", s.config.PullSpec)
+}
+
+func (s *externalImageStep) Objects() []ctrlruntimeclient.Object {
+	return s.client.Objects()
+}
+
+// ExternalImageStep imports an image a test step depends on, which is not
+// otherwise available in the CI system, into the pipeline ImageStream
+func ExternalImageStep(config api.ExternalImageDependency, client loggingclient.LoggingClient, imports *ImportManager, jobSpec *api.JobSpec) api.Step {
+	return &externalImageStep{
+		config:  config,
+		client:  client,
+		imports: imports,
+		jobSpec: jobSpec,
+	}
+}
@@ -0,0 +1,92 @@
+package steps
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+// importingClient resolves every imported image to the digest
+type importingClient struct {
+	ctrlruntimeclient.Client
+	digest string
+	// imported are the pull specs of the imported images
+	imported []string
+}
+
+func (c *importingClient) Create(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.CreateOption) error {
+	if streamImport, ok := obj.(*imagev1.ImageStreamImport); ok {
+		for _, spec := range streamImport.Spec.Images {
+			c.imported = append(c.imported, spec.From.Name)
+			streamImport.Status.Images = append(streamImport.Status.Images, imagev1.ImageImportStatus{
+				Image: &imagev1.Image{ObjectMeta: metav1.ObjectMeta{Name: c.digest}, DockerImageReference: spec.From.Name},
+			})
+		}
+		return nil
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestExternalImageStep(t *testing.T) {
+	expected := "sha256:" + strings.Repeat("a", 64)
+	config := api.ExternalImageDependency{PullSpec: "quay.io/org/image:tag", Digest: expected, Env: "IMAGE"}
+	var testCases = []struct {
+		name           string
+		digest         string
+		expectedErr    string
+		expectedReason string
+	}{
+		{
+			name:   "image resolves to the expected digest",
+			digest: expected,
+		},
+		{
+			name:           "image resolves to another digest",
+			digest:         "sha256:" + strings.Repeat("b", 64),
+			expectedErr:    "external image quay.io/org/image:tag resolved to digest sha256:" + strings.Repeat("b", 64) + ", expected " + expected,
+			expectedReason: "importing_external_image:external_image_digest_mismatch",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			jobSpec := &api.JobSpec{}
+			jobSpec.SetNamespace("ns")
+			importing := &importingClient{Client: fakectrlruntimeclient.NewFakeClient(), digest: testCase.digest}
+			client := loggingclient.New(importing)
+			step := ExternalImageStep(config, client, NewImportManager(1, wait.Backoff{Steps: 1}), jobSpec)
+			err := step.Run(context.Background())
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if err != nil {
+				if reason := results.FullReason(err); reason != testCase.expectedReason {
+					t.Errorf("expected reason %q, got %q", testCase.expectedReason, reason)
+				}
+			}
+			if diff := cmp.Diff([]string{"quay.io/org/image@" + expected}, importing.imported); diff != "" {
+				t.Errorf("expected the image to be imported by digest: %s", diff)
+			}
+			tag := api.PipelineImageStreamTagReference("external-sha256-" + strings.Repeat("a", 64))
+			if links := step.Creates(); len(links) != 1 || !links[0].SatisfiedBy(api.InternalImageLink(tag)) {
+				t.Errorf("expected the step to create pipeline:%s, got %v", tag, links)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+const (
+	fipsCheckName          = "fips-check"
+	fipsCheckArtifactsPath = "/tmp/artifacts"
+	// fipsCheckExamples is the number of findings of every image reported
+	// in the termination message, which is limited in size; the complete
+	// reports are in the artifacts
+	fipsCheckExamples = 3
+)
+
+// fipsCheckScript defines check_image, which extracts the paths of an image
+// and lists the binaries in them which are not compliant; images which
+// cannot be extracted fail the check instead of passing it unchecked
+const fipsCheckScript = `check_image() {
+  local image="$1" reference="$2" root="/tmp/images/$1"
+  while IFS= read -r path; do
+    mkdir -p "${root}${path}"
+    oc image extract --registry-config "${DOCKER_CONFIG}/config.json" --confirm "${reference}" --path "${path}/:${root}${path}"
+  done <<< "${FIPS_PATHS}"
+  find "${root}" -type f -perm -u+x | sort | while IFS= read -r file; do
+    kind="$(file -b "${file}")"
+    [[ "${kind}" == ELF* ]] || continue
+    while IFS= read -r symbol; do
+      if grep -aqF -e "${symbol}" "${file}"; then
+        echo "${file#"${root}"}: contains forbidden symbol ${symbol}"
+      fi
+    done <<< "${FIPS_FORBIDDEN_SYMBOLS}"
+    if [[ "${FIPS_ALLOW_STATIC}" != "true" && "${kind}" == *"statically linked"* ]]; then
+      echo "${file#"${root}"}: is linked statically and cannot use the OpenSSL library of the system"
+    fi
+  done > "${image}.fips.txt"
+  jq -R . "${image}.fips.txt" | jq -cs --arg image "${image}" --argjson examples "${FIPS_EXAMPLES}" '{($image): {count: length, examples: .[:$examples]}}' >> /tmp/summary.json
+}`
+
+// fipsFindings are the binaries found in each image which are not compliant
+type fipsFindings map[string]struct {
+	Count    int      `json:"count"`
+	Examples []string `json:"examples,omitempty"`
+}
+
+// fipsCheckStep checks the binaries in built images for cryptography which
+// is not FIPS-compliant and fails the job or warns when it finds any
+type fipsCheckStep struct {
+	config  api.FIPSCheckConfiguration
+	client  PodClient
+	jobSpec *api.JobSpec
+}
+
+func (s *fipsCheckStep) Inputs() (api.InputDefinition, error) {
+	return nil, nil
+}
+
+func (*fipsCheckStep) Validate() error { return nil }
+
+func (s *fipsCheckStep) Run(ctx context.Context) error {
+	return results.ForReason(results.ReasonCheckingFIPS).ForError(s.run(ctx))
+}
+
+func (s *fipsCheckStep) run(ctx context.Context) error {
+	pipeline := &imagev1.ImageStream{}
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: api.PipelineImageStream}, pipeline); err != nil {
+		return fmt.Errorf("could not resolve pipeline imagestream: %w", err)
+	}
+	var names []string
+	for _, image := range s.config.Images {
+		names = append(names, string(image))
+	}
+	images, err := builtImages(pipeline, names)
+	if err != nil {
+		return err
+	}
+
+	Logger(ctx).Infof("Checking %d images for cryptography which is not FIPS-compliant", len(images))
+	pod := fipsCheckPod(s.jobSpec.Namespace(), s.config, images)
+	if owner := s.jobSpec.Owner(); owner != nil {
+		pod.OwnerReferences = append(pod.OwnerReferences, *owner)
+	}
+	var notifier ContainerNotifier = NopNotifier
+	if artifactDir, artifactsRequested := api.ArtifactsFor(ctx); artifactsRequested {
+		artifacts := NewArtifactWorker(ctx, s.client, filepath.Join(artifactDir, fipsCheckName), s.jobSpec.Namespace(), api.ArtifactGathering{})
+		addArtifactsToPod(pod)
+		addArtifactContainersFromPod(pod, artifacts)
+		notifier = artifacts
+	}
+	pod, err = createOrRestartPod(ctx, s.client, pod)
+	if err != nil {
+		return fmt.Errorf("failed to create FIPS check pod: %w", err)
+	}
+	pod, err = waitForPodCompletion(ctx, s.client, pod.Namespace, pod.Name, notifier, true)
+	if err != nil {
+		return fmt.Errorf("FIPS check pod failed: %w", err)
+	}
+	findings, err := fipsCheckResults(pod)
+	if err != nil {
+		return err
+	}
+	failing := findings.failing()
+	if len(failing) == 0 {
+		return nil
+	}
+	message := fmt.Sprintf("found binaries which are not FIPS-compliant in images: %s", strings.Join(failing, "; "))
+	if s.config.Action == api.FIPSCheckActionWarn {
+		Logger(ctx).Warnf("%s", message)
+		return nil
+	}
+	return errors.New(message)
+}
+
+// fipsCheckResults reads the findings the check container reports in its
+// termination message
+func fipsCheckResults(pod *coreapi.Pod) (fipsFindings, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != fipsCheckName || status.State.Terminated == nil {
+			continue
+		}
+		findings := fipsFindings{}
+		if err := json.Unmarshal([]byte(status.State.Terminated.Message), &findings); err != nil {
+			return nil, fmt.Errorf("could not parse FIPS check results: %w", err)
+		}
+		return findings, nil
+	}
+	return nil, fmt.Errorf("FIPS check did not report results")
+}
+
+// failing summarizes the findings of the images with binaries which are not
+// compliant
+func (f fipsFindings) failing() []string {
+	var images []string
+	for _, image := range sets.StringKeySet(f).List() {
+		found := f[image]
+		if found.Count == 0 {
+			continue
+		}
+		noun := "findings"
+		if found.Count == 1 {
+			noun = "finding"
+		}
+		summary := fmt.Sprintf("%s (%d %s: %s", image, found.Count, noun, strings.Join(found.Examples, ", "))
+		if found.Count > len(found.Examples) {
+			summary += ", ..."
+		}
+		images = append(images, summary+")")
+	}
+	return images
+}
+
+// fipsCheckPod checks the images, writing the reports to the artifacts and
+// a summary of the findings to the termination message
+func fipsCheckPod(namespace string, config api.FIPSCheckConfiguration, images []builtImage) *coreapi.Pod {
+	paths := config.Paths
+	if len(paths) == 0 {
+		paths = api.FIPSCheckDefaultPaths
+	}
+	symbols := config.ForbiddenSymbols
+	if len(symbols) == 0 {
+		symbols = api.FIPSCheckDefaultForbiddenSymbols
+	}
+	var registries, checks []string
+	for _, image := range images {
+		registries = append(registries, strings.SplitN(image.repository, "/", 2)[0])
+		checks = append(checks, fmt.Sprintf("check_image %s %s", image.name, image.reference()))
+	}
+	commands := []string{
+		"set -euo pipefail",
+		"cd " + fipsCheckArtifactsPath,
+		registryAuthScript(`"${DOCKER_CONFIG}/config.json"`, registries...),
+		fipsCheckScript,
+	}
+	commands = append(commands, checks...)
+	commands = append(commands, `jq -cs 'add // {}' /tmp/summary.json > /dev/termination-log`)
+	return &coreapi.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      fipsCheckName,
+			Namespace: namespace,
+		},
+		Spec: coreapi.PodSpec{
+			RestartPolicy: coreapi.RestartPolicyNever,
+			// the builder account may pull from the pipeline image stream
+			ServiceAccountName: "builder",
+			Containers: []coreapi.Container{{
+				Name:    fipsCheckName,
+				Image:   clusterToolsImage,
+				Command: []string{"/bin/bash", "-c"},
+				Args:    []string{strings.Join(commands, "\n")},
+				Env: []coreapi.EnvVar{
+					{Name: "DOCKER_CONFIG", Value: "/tmp/.docker"},
+					{Name: "FIPS_PATHS", Value: strings.Join(paths, "\n")},
+					{Name: "FIPS_FORBIDDEN_SYMBOLS", Value: strings.Join(symbols, "\n")},
+					{Name: "FIPS_ALLOW_STATIC", Value: strconv.FormatBool(config.AllowStaticBinaries)},
+					{Name: "FIPS_EXAMPLES", Value: strconv.Itoa(fipsCheckExamples)},
+				},
+				VolumeMounts: []coreapi.VolumeMount{
+					{Name: "artifacts", MountPath: fipsCheckArtifactsPath},
+				},
+				TerminationMessagePolicy: coreapi.TerminationMessageReadFile,
+			}},
+			Volumes: []coreapi.Volume{
+				{Name: "artifacts", VolumeSource: coreapi.VolumeSource{EmptyDir: &coreapi.EmptyDirVolumeSource{}}},
+			},
+		},
+	}
+}
+
+func (s *fipsCheckStep) Requires() []api.StepLink {
+	var links []api.StepLink
+	for _, image := range s.config.Images {
+		links = append(links, api.InternalImageLink(image))
+	}
+	return links
+}
+
+func (s *fipsCheckStep) Creates() []api.StepLink {
+	return []api.StepLink{api.FIPSCheckLink()}
+}
+
+func (s *fipsCheckStep) Provides() api.ParameterMap {
+	return nil
+}
+
+func (s *fipsCheckStep) Name() string { return "[fips-check]" }
+
+func (s *fipsCheckStep) Description() string {
+	return "Check the built images for cryptography which is not FIPS-compliant"
+}
+
+func (s *fipsCheckStep) Objects() []ctrlruntimeclient.Object {
+	return s.client.Objects()
+}
+
+// FIPSCheckStep checks images built by the job for cryptography which is
+// not FIPS-compliant.
+func FIPSCheckStep(config api.FIPSCheckConfiguration, client PodClient, jobSpec *api.JobSpec) api.Step {
+	return &fipsCheckStep{
+		config:  config,
+		client:  client,
+		jobSpec: jobSpec,
+	}
+}
@@ -0,0 +1,88 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestFIPSCheckResults(t *testing.T) {
+	var testCases = []struct {
+		name            string
+		message         string
+		expectedFailing []string
+		expectedErr     string
+	}{
+		{
+			name:    "compliant images",
+			message: `{"cli":{"count":0},"operator":{"count":0}}`,
+		},
+		{
+			name:    "images with binaries which are not compliant",
+			message: `{"cli":{"count":1,"examples":["/usr/bin/oc: is linked statically and cannot use the OpenSSL library of the system"]},"operator":{"count":0},"src":{"count":4,"examples":["/usr/bin/a: contains forbidden symbol sym","/usr/bin/b: contains forbidden symbol sym","/usr/bin/c: contains forbidden symbol sym"]}}`,
+			expectedFailing: []string{
+				"cli (1 finding: /usr/bin/oc: is linked statically and cannot use the OpenSSL library of the system)",
+				"src (4 findings: /usr/bin/a: contains forbidden symbol sym, /usr/bin/b: contains forbidden symbol sym, /usr/bin/c: contains forbidden symbol sym, ...)",
+			},
+		},
+		{
+			name:        "missing results",
+			expectedErr: "could not parse FIPS check results: unexpected end of JSON input",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			pod := &coreapi.Pod{Status: coreapi.PodStatus{ContainerStatuses: []coreapi.ContainerStatus{
+				{Name: "artifacts", State: coreapi.ContainerState{Terminated: &coreapi.ContainerStateTerminated{}}},
+				{Name: fipsCheckName, State: coreapi.ContainerState{Terminated: &coreapi.ContainerStateTerminated{Message: testCase.message}}},
+			}}}
+			findings, err := fipsCheckResults(pod)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(testCase.expectedFailing, findings.failing()); diff != "" {
+				t.Errorf("unexpected failing images: %s", diff)
+			}
+		})
+	}
+}
+
+func TestFIPSCheckPod(t *testing.T) {
+	images := []builtImage{
+		{name: "cli", repository: "image-registry.openshift-image-registry.svc:5000/ci-op-1234/pipeline", digest: "sha256:cli"},
+		{name: "operator", repository: "image-registry.openshift-image-registry.svc:5000/ci-op-1234/pipeline", digest: "sha256:operator"},
+	}
+	var testCases = []struct {
+		name   string
+		config api.FIPSCheckConfiguration
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name: "custom paths and symbols",
+			config: api.FIPSCheckConfiguration{
+				Paths:               []string{"/opt/app/bin"},
+				ForbiddenSymbols:    []string{"EVP_md5", "MD5_Init"},
+				AllowStaticBinaries: true,
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testhelper.CompareWithFixture(t, fipsCheckPod("ci-op-1234", testCase.config, images))
+		})
+	}
+}
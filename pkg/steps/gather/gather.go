@@ -0,0 +1,152 @@
+package gather
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+const (
+	// DefaultMaxSize is the size the data collected by a gather step is
+	// trimmed to when the configuration does not limit it
+	DefaultMaxSize = "500Mi"
+	// DefaultTimeout is how long a gather step collects data when the
+	// configuration does not limit it
+	DefaultTimeout = 20 * time.Minute
+
+	// slack is the time given to a step on top of the gather timeout to
+	// trim and compress what it collected
+	slack = 5 * time.Minute
+
+	// MaxSizeEnv holds the size in bytes the collected data is trimmed to
+	MaxSizeEnv = "GATHER_MAX_SIZE"
+	// TimeoutEnv holds how long the data is collected for
+	TimeoutEnv = "GATHER_TIMEOUT"
+)
+
+// prefix holds the name of a built-in step, so that a workflow can replace it
+// with its own step of the same name
+const prefix = "gather-"
+
+// image provides bash and oc. Its image stream exists on every OpenShift
+// cluster, so unlike the cli image of a release it is available to every job.
+var image = api.ImageStreamTagReference{Namespace: "openshift", Name: "tools", Tag: "latest"}
+
+// DefaultSteps are the built-in steps run when the configuration does not
+// select any
+var DefaultSteps = []api.GatherStep{api.GatherStepMustGather, api.GatherStepExtra}
+
+// Known determines whether there is a built-in step of the name
+func Known(step api.GatherStep) bool {
+	_, ok := commands[step]
+	return ok
+}
+
+// Steps creates the built-in gather steps for a test. Steps with the name of
+// a step in the post phase of the test are left out, as the test collects the
+// same data itself.
+func Steps(config *api.GatherConfiguration, post []api.LiteralTestStep) []api.LiteralTestStep {
+	if config == nil {
+		config = &api.GatherConfiguration{}
+	}
+	if config.Disabled {
+		return nil
+	}
+	existing := map[string]bool{}
+	for _, step := range post {
+		existing[step.As] = true
+	}
+	names := config.Steps
+	if len(names) == 0 {
+		names = DefaultSteps
+	}
+	maxSize, err := resource.ParseQuantity(config.MaxSize)
+	if err != nil {
+		maxSize = resource.MustParse(DefaultMaxSize)
+	}
+	timeout := DefaultTimeout
+	if config.Timeout != nil {
+		timeout = config.Timeout.Duration
+	}
+	var steps []api.LiteralTestStep
+	for _, name := range names {
+		commands, ok := commands[name]
+		if !ok {
+			continue
+		}
+		as := prefix + string(name)
+		if existing[as] {
+			continue
+		}
+		maxSizeValue := fmt.Sprintf("%d", maxSize.Value())
+		timeoutValue := fmt.Sprintf("%ds", int64(timeout.Seconds()))
+		from := image
+		steps = append(steps, api.LiteralTestStep{
+			As:        as,
+			FromImage: &from,
+			Commands:  trimFunction + commands,
+			Resources: api.ResourceRequirements{
+				Requests: api.ResourceList{"cpu": "300m", "memory": "300Mi"},
+			},
+			Timeout: &prowv1.Duration{Duration: timeout + slack},
+			Environment: []api.StepParameter{
+				{Name: MaxSizeEnv, Default: &maxSizeValue, Documentation: "The size in bytes the collected data is trimmed to."},
+				{Name: TimeoutEnv, Default: &timeoutValue, Documentation: "How long data is collected for."},
+			},
+		})
+	}
+	return steps
+}
+
+// trimFunction removes the largest files in a directory until it fits in the
+// maximum size of the collected data
+const trimFunction = `trim() {
+	while [[ "$(du -sb "$1" | cut -f1)" -gt "${GATHER_MAX_SIZE}" ]]; do
+		largest="$(find "$1" -type f -printf '%s %p\n' | sort -n | tail -n 1 | cut -d' ' -f2-)"
+		if [[ -z "${largest}" ]]; then
+			break
+		fi
+		echo "Removing ${largest} to stay within ${GATHER_MAX_SIZE} bytes"
+		rm -f "${largest}"
+	done
+}
+if [[ ! -f "${SHARED_DIR}/kubeconfig" ]]; then
+	echo "No kubeconfig in ${SHARED_DIR}, nothing to gather."
+	exit 0
+fi
+export KUBECONFIG="${SHARED_DIR}/kubeconfig"
+`
+
+var commands = map[api.GatherStep]string{
+	api.GatherStepMustGather: `dir="${ARTIFACT_DIR}/must-gather"
+mkdir -p "${dir}"
+if ! timeout "${GATHER_TIMEOUT}" oc adm must-gather --dest-dir "${dir}" > "${dir}/must-gather.log" 2>&1; then
+	echo "must-gather did not finish, keeping what was collected."
+fi
+trim "${dir}"
+tar -czf "${ARTIFACT_DIR}/must-gather.tar.gz" -C "${ARTIFACT_DIR}" must-gather && rm -rf "${dir}"
+exit 0
+`,
+	api.GatherStepExtra: `dir="${ARTIFACT_DIR}/gather-extra"
+mkdir -p "${dir}/pods"
+collect() {
+	for resource in nodes clusteroperators clusterversion machines machinesets events pods services persistentvolumes persistentvolumeclaims; do
+		oc get "${resource}" --all-namespaces --output json > "${dir}/${resource}.json" 2> /dev/null || true
+	done
+	oc get pods --all-namespaces --output jsonpath='{range .items[*]}{.metadata.namespace} {.metadata.name}{"\n"}{end}' | while read -r namespace name; do
+		oc logs --namespace "${namespace}" "${name}" --all-containers > "${dir}/pods/${namespace}_${name}.log" 2>&1 || true
+	done
+}
+export dir
+export -f collect
+if ! timeout "${GATHER_TIMEOUT}" bash -c collect; then
+	echo "Gathering did not finish, keeping what was collected."
+fi
+trim "${dir}"
+exit 0
+`,
+}
@@ -0,0 +1,70 @@
+package gather
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestSteps(t *testing.T) {
+	type summary struct {
+		As      string
+		Timeout time.Duration
+		Env     map[string]string
+	}
+	var testCases = []struct {
+		name     string
+		config   *api.GatherConfiguration
+		post     []api.LiteralTestStep
+		expected []summary
+	}{
+		{
+			name: "no configuration runs all steps with defaults",
+			expected: []summary{
+				{As: "gather-must-gather", Timeout: 25 * time.Minute, Env: map[string]string{MaxSizeEnv: "524288000", TimeoutEnv: "1200s"}},
+				{As: "gather-extra", Timeout: 25 * time.Minute, Env: map[string]string{MaxSizeEnv: "524288000", TimeoutEnv: "1200s"}},
+			},
+		},
+		{
+			name:   "disabled configuration runs nothing",
+			config: &api.GatherConfiguration{Disabled: true},
+		},
+		{
+			name:   "configured steps and limits",
+			config: &api.GatherConfiguration{Steps: []api.GatherStep{api.GatherStepExtra}, MaxSize: "1Gi", Timeout: &prowv1.Duration{Duration: 10 * time.Minute}},
+			expected: []summary{
+				{As: "gather-extra", Timeout: 15 * time.Minute, Env: map[string]string{MaxSizeEnv: "1073741824", TimeoutEnv: "600s"}},
+			},
+		},
+		{
+			name: "steps already in the post phase are left out",
+			post: []api.LiteralTestStep{{As: "gather-must-gather"}, {As: "deprovision"}},
+			expected: []summary{
+				{As: "gather-extra", Timeout: 25 * time.Minute, Env: map[string]string{MaxSizeEnv: "524288000", TimeoutEnv: "1200s"}},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var actual []summary
+			for _, step := range Steps(testCase.config, testCase.post) {
+				if diff := cmp.Diff(&image, step.FromImage); diff != "" {
+					t.Errorf("%s: unexpected image: %s", step.As, diff)
+				}
+				env := map[string]string{}
+				for _, parameter := range step.Environment {
+					env[parameter.Name] = *parameter.Default
+				}
+				actual = append(actual, summary{As: step.As, Timeout: step.Timeout.Duration, Env: env})
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected steps: %s", diff)
+			}
+		})
+	}
+}
@@ -30,15 +30,18 @@ func (s *gitSourceStep) Inputs() (api.InputDefinition, error) {
 func (*gitSourceStep) Validate() error { return nil }
 
 func (s *gitSourceStep) Run(ctx context.Context) error {
-	return results.ForReason("building_image_from_source").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonBuildingImageFromSource).ForError(s.run(ctx))
 }
 
 func (s *gitSourceStep) run(ctx context.Context) error {
 	if refs := determineRefsWorkdir(s.jobSpec.Refs, s.jobSpec.ExtraRefs); refs != nil {
 		cloneURI := fmt.Sprintf("https://github.com/%s/%s.git", refs.Org, refs.Repo)
+		if api.IsGerrit(refs) {
+			cloneURI = api.CloneURI(refs)
+		}
 		var secretName string
 		if s.cloneAuthConfig != nil {
-			cloneURI = s.cloneAuthConfig.getCloneURI(refs.Org, refs.Repo)
+			cloneURI = s.cloneAuthConfig.getCloneURI(refs)
 			secretName = s.cloneAuthConfig.Secret.Name
 		}
 
@@ -0,0 +1,123 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildapi "github.com/openshift/api/build/v1"
+)
+
+// HermeticBuildAnnotation marks builds which may not reach the network. The
+// value lists the CIDRs the build may reach besides the registry and the DNS
+// of the cluster, separated by commas.
+const HermeticBuildAnnotation = "ci.openshift.io/hermetic"
+
+const (
+	// hermeticBuildPodLabel is the label the build controller sets on the
+	// pods of builds
+	hermeticBuildPodLabel = "openshift.io/build.name"
+	// hermeticPipelineRunPodLabel is the label Tekton sets on the pods of
+	// pipeline runs
+	hermeticPipelineRunPodLabel = "tekton.dev/pipelineRun"
+	namespaceNameLabel          = "kubernetes.io/metadata.name"
+)
+
+// hermeticEgress returns the CIDRs the build may reach and whether the build
+// is hermetic
+func hermeticEgress(build *buildapi.Build) ([]string, bool) {
+	value, hermetic := build.Annotations[HermeticBuildAnnotation]
+	if !hermetic || value == "" {
+		return nil, hermetic
+	}
+	return strings.Split(value, ","), true
+}
+
+// hermeticNetworkPolicy only lets the pods of the build reach the DNS and the
+// registry of the cluster and the allowed CIDRs. Policies select pods, so
+// pods of a hermetic build are isolated even when they run on a farm.
+func hermeticNetworkPolicy(build *buildapi.Build, podLabel string, cidrs []string) *networkingv1.NetworkPolicy {
+	udp, tcp := coreapi.ProtocolUDP, coreapi.ProtocolTCP
+	var dnsPorts []networkingv1.NetworkPolicyPort
+	// the DNS of the cluster serves port 53 from 5353 on its pods
+	for _, port := range []int{53, 5353} {
+		port := intstr.FromInt(port)
+		dnsPorts = append(dnsPorts, networkingv1.NetworkPolicyPort{Protocol: &udp, Port: &port}, networkingv1.NetworkPolicyPort{Protocol: &tcp, Port: &port})
+	}
+	namespace := func(name string) networkingv1.NetworkPolicyPeer {
+		return networkingv1.NetworkPolicyPeer{NamespaceSelector: &meta.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: name}}}
+	}
+	egress := []networkingv1.NetworkPolicyEgressRule{
+		{To: []networkingv1.NetworkPolicyPeer{namespace("openshift-dns")}, Ports: dnsPorts},
+		{To: []networkingv1.NetworkPolicyPeer{namespace("openshift-image-registry")}},
+	}
+	if len(cidrs) > 0 {
+		var peers []networkingv1.NetworkPolicyPeer
+		for _, cidr := range cidrs {
+			peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+		}
+		egress = append(egress, networkingv1.NetworkPolicyEgressRule{To: peers})
+	}
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: meta.ObjectMeta{
+			Namespace:       build.Namespace,
+			Name:            fmt.Sprintf("hermetic-%s", build.Name),
+			OwnerReferences: build.OwnerReferences,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: meta.LabelSelector{MatchLabels: map[string]string{podLabel: build.Name}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      egress,
+		},
+	}
+}
+
+// isolateHermeticBuild creates the network policy of a hermetic build before
+// its pods start, selecting them by the label given
+func isolateHermeticBuild(ctx context.Context, client ctrlruntimeclient.Client, build *buildapi.Build, podLabel string) error {
+	cidrs, hermetic := hermeticEgress(build)
+	if !hermetic {
+		return nil
+	}
+	policy := hermeticNetworkPolicy(build, podLabel, cidrs)
+	if err := client.Create(ctx, policy); err != nil {
+		if !kerrors.IsAlreadyExists(err) {
+			return fmt.Errorf("could not isolate hermetic build %s: %w", build.Name, err)
+		}
+		existing := &networkingv1.NetworkPolicy{}
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: policy.Namespace, Name: policy.Name}, existing); err != nil {
+			return fmt.Errorf("could not get network policy of hermetic build %s: %w", build.Name, err)
+		}
+		existing.Spec = policy.Spec
+		if err := client.Update(ctx, existing); err != nil {
+			return fmt.Errorf("could not update network policy of hermetic build %s: %w", build.Name, err)
+		}
+	}
+	Logger(ctx).Infof("Build %s is hermetic, it may only reach the registry of the cluster and %d allowed networks", build.Name, len(cidrs))
+	return nil
+}
+
+// hintsAtNetworkAccess determines whether the build failed reaching for the
+// network, which is an infrastructure failure for other builds
+func hintsAtNetworkAccess(logSnippet string) bool {
+	return hintsAtInfraReason(logSnippet) ||
+		strings.Contains(logSnippet, "Temporary failure in name resolution") ||
+		strings.Contains(logSnippet, "Network is unreachable") ||
+		strings.Contains(logSnippet, "Connection timed out") ||
+		strings.Contains(logSnippet, "i/o timeout") ||
+		strings.Contains(logSnippet, "dial tcp")
+}
+
+// violatesHermeticity determines whether the build is hermetic and failed
+// reaching for the network
+func violatesHermeticity(build *buildapi.Build, logSnippet string) bool {
+	_, hermetic := hermeticEgress(build)
+	return hermetic && hintsAtNetworkAccess(logSnippet)
+}
@@ -0,0 +1,43 @@
+package steps
+
+import (
+	"testing"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	buildapi "github.com/openshift/api/build/v1"
+
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestHermeticNetworkPolicy(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		value    string
+		podLabel string
+	}{
+		{
+			name:     "only the cluster is reachable",
+			podLabel: hermeticBuildPodLabel,
+		},
+		{
+			name:     "allowed networks are reachable",
+			value:    "10.0.0.0/16,192.168.1.10/32",
+			podLabel: hermeticPipelineRunPodLabel,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			build := &buildapi.Build{ObjectMeta: meta.ObjectMeta{
+				Namespace:   "ci-op-1234",
+				Name:        "operator",
+				Annotations: map[string]string{HermeticBuildAnnotation: testCase.value},
+			}}
+			cidrs, hermetic := hermeticEgress(build)
+			if !hermetic {
+				t.Fatal("expected the build to be hermetic")
+			}
+			testhelper.CompareWithFixture(t, hermeticNetworkPolicy(build, testCase.podLabel, cidrs))
+		})
+	}
+}
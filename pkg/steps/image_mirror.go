@@ -0,0 +1,122 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/imagemirror"
+	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+// imageMirrorStep pushes images of the job to external registries
+type imageMirrorStep struct {
+	mirrors []api.ImageMirror
+	client  loggingclient.LoggingClient
+	mirror  imagemirror.Client
+	jobSpec *api.JobSpec
+}
+
+func (s *imageMirrorStep) Inputs() (api.InputDefinition, error) {
+	return nil, nil
+}
+
+func (*imageMirrorStep) Validate() error { return nil }
+
+func (s *imageMirrorStep) Run(ctx context.Context) error {
+	return results.ForReason(results.ReasonMirroringImages).ForError(s.run(ctx))
+}
+
+func (s *imageMirrorStep) run(ctx context.Context) error {
+	if s.mirror == nil {
+		return fmt.Errorf("cannot mirror images without push credentials")
+	}
+	for _, mirror := range s.mirrors {
+		source, err := s.resolve(ctx, mirror.From)
+		if err != nil {
+			return err
+		}
+		digest, err := s.mirror.Mirror(ctx, source, mirror.To)
+		if err != nil {
+			return fmt.Errorf("could not mirror %s to %s: %w", mirror.From, mirror.To, err)
+		}
+		Logger(ctx).Infof("Mirrored %s to %s (%s)", mirror.From, mirror.To, digest)
+	}
+	return nil
+}
+
+// resolve determines the pull spec of the image behind an image stream tag
+// in the job namespace, by digest
+func (s *imageMirrorStep) resolve(ctx context.Context, from string) (string, error) {
+	parts := strings.SplitN(from, ":", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid image %s", from)
+	}
+	stream := &imagev1.ImageStream{}
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: parts[0]}, stream); err != nil {
+		return "", fmt.Errorf("could not resolve imagestream %s: %w", parts[0], err)
+	}
+	repository := stream.Status.PublicDockerImageRepository
+	if repository == "" {
+		repository = stream.Status.DockerImageRepository
+	}
+	for _, tag := range stream.Status.Tags {
+		if tag.Tag == parts[1] && len(tag.Items) > 0 && repository != "" {
+			return fmt.Sprintf("%s@%s", repository, tag.Items[0].Image), nil
+		}
+	}
+	return "", fmt.Errorf("image %s does not exist", from)
+}
+
+func (s *imageMirrorStep) Requires() []api.StepLink {
+	var links []api.StepLink
+	for _, mirror := range s.mirrors {
+		parts := strings.SplitN(mirror.From, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if link := api.LinkForImage(parts[0], parts[1]); link != nil {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+func (s *imageMirrorStep) Creates() []api.StepLink {
+	return []api.StepLink{}
+}
+
+func (s *imageMirrorStep) Provides() api.ParameterMap {
+	return nil
+}
+
+func (s *imageMirrorStep) Name() string { return "[mirror]" }
+
+func (s *imageMirrorStep) Description() string {
+	var destinations []string
+	for _, mirror := range s.mirrors {
+		destinations = append(destinations, mirror.To)
+	}
+	return fmt.Sprintf("Mirror images to %s", strings.Join(destinations, ", "))
+}
+
+func (s *imageMirrorStep) Objects() []ctrlruntimeclient.Object {
+	return s.client.Objects()
+}
+
+// ImageMirrorStep copies images of the job to external registries by
+// digest.
+func ImageMirrorStep(mirrors []api.ImageMirror, client loggingclient.LoggingClient, mirror imagemirror.Client, jobSpec *api.JobSpec) api.Step {
+	return &imageMirrorStep{
+		mirrors: mirrors,
+		client:  client,
+		mirror:  mirror,
+		jobSpec: jobSpec,
+	}
+}
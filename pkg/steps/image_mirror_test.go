@@ -0,0 +1,93 @@
+package steps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+type fakeImageMirrorClient struct {
+	mirrored map[string]string
+}
+
+func (c *fakeImageMirrorClient) Mirror(_ context.Context, source, destination string) (string, error) {
+	c.mirrored[destination] = source
+	return "sha256:abc", nil
+}
+
+func TestImageMirrorStep(t *testing.T) {
+	streams := []*imagev1.ImageStream{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-1234", Name: "pipeline"},
+			Status: imagev1.ImageStreamStatus{
+				DockerImageRepository:       "image-registry.openshift-image-registry.svc:5000/ci-op-1234/pipeline",
+				PublicDockerImageRepository: "registry.build01.ci.openshift.org/ci-op-1234/pipeline",
+				Tags:                        []imagev1.NamedTagEventList{{Tag: "bin", Items: []imagev1.TagEvent{{Image: "sha256:bin"}}}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-1234", Name: "stable"},
+			Status: imagev1.ImageStreamStatus{
+				DockerImageRepository: "image-registry.openshift-image-registry.svc:5000/ci-op-1234/stable",
+				Tags:                  []imagev1.NamedTagEventList{{Tag: "installer", Items: []imagev1.TagEvent{{Image: "sha256:installer"}}}},
+			},
+		},
+	}
+	var testCases = []struct {
+		name             string
+		mirrors          []api.ImageMirror
+		expectedMirrored map[string]string
+		expectedErr      string
+	}{
+		{
+			name: "images of the pipeline and a release",
+			mirrors: []api.ImageMirror{
+				{From: "pipeline:bin", To: "quay.io/org/bin:latest"},
+				{From: "stable:installer", To: "quay.io/org/installer:4.9"},
+			},
+			expectedMirrored: map[string]string{
+				"quay.io/org/bin:latest":    "registry.build01.ci.openshift.org/ci-op-1234/pipeline@sha256:bin",
+				"quay.io/org/installer:4.9": "image-registry.openshift-image-registry.svc:5000/ci-op-1234/stable@sha256:installer",
+			},
+		},
+		{
+			name:             "missing image",
+			mirrors:          []api.ImageMirror{{From: "pipeline:cli", To: "quay.io/org/cli:latest"}},
+			expectedMirrored: map[string]string{},
+			expectedErr:      "image pipeline:cli does not exist",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := fakectrlruntimeclient.NewFakeClient()
+			for _, stream := range streams {
+				if err := client.Create(context.Background(), stream.DeepCopy()); err != nil {
+					t.Fatalf("could not create imagestream: %v", err)
+				}
+			}
+			mirror := &fakeImageMirrorClient{mirrored: map[string]string{}}
+			jobSpec := &api.JobSpec{}
+			jobSpec.SetNamespace("ci-op-1234")
+			step := ImageMirrorStep(testCase.mirrors, loggingclient.New(client), mirror, jobSpec)
+			var actualErr string
+			if err := step.Run(context.Background()); err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Errorf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expectedMirrored, mirror.mirrored); diff != "" {
+				t.Errorf("unexpected mirrored images: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package steps
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildapi "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/telemetry"
+)
+
+const (
+	// SharedImageBuildAnnotation records which build published an image to a
+	// shared image stream
+	SharedImageBuildAnnotation = "ci.openshift.io/shared-by"
+	// SharedImageJobAnnotation records the job and build ID of the run of a job
+	// that published an image to a shared image stream
+	SharedImageJobAnnotation = "ci.openshift.io/shared-by-job"
+	// SharedImageBranchLabelsAnnotation records the labels naming the branch
+	// and remote of the build that published an image to a shared image
+	// stream
+	SharedImageBranchLabelsAnnotation = "ci.openshift.io/shared-branch-labels"
+
+	// BuildCacheStream is the image stream of the cluster-wide build cache
+	BuildCacheStream = "build-cache"
+	// PresubmitBuildCacheStream is the image stream of the build cache of
+	// presubmits, which build untrusted code
+	PresubmitBuildCacheStream = "build-cache-presubmits"
+)
+
+type imageSharingKey struct{}
+
+// ImageSharing shares the images built by jobs, e.g. by those testing the
+// same pull requests: every build publishes its output to a shared image
+// stream, tagged with the digest of its inputs, and builds with the same
+// digest in other jobs import that image instead of building it again.
+type ImageSharing struct {
+	// Namespace holds the image streams images are shared in; it must allow
+	// the namespaces of jobs to pull from it
+	Namespace string
+	// Stream is the image stream images are shared in
+	Stream string
+	// TTL is how long shared images are used for, they are not used nor kept
+	// once they are older; images are kept forever without it
+	TTL time.Duration
+	// ReadOnly imports shared images without publishing the output of builds
+	ReadOnly bool
+
+	// digest identifies the builds that share an image, sharedInputsDigest
+	// is used without it
+	digest func(context.Context, ctrlruntimeclient.Client, *buildapi.Build) (string, error)
+}
+
+// BuildCache shares the images built from the same source tree and base
+// images between all jobs, whichever branch they test; images built for
+// another branch are relabeled for the branch of the job. Presubmits only read
+// from it, as images they built must not be promoted, and share the images
+// they build through PresubmitBuildCache.
+func BuildCache(namespace string, ttl time.Duration, presubmit bool) ImageSharing {
+	return ImageSharing{Namespace: namespace, Stream: BuildCacheStream, TTL: ttl, ReadOnly: presubmit, digest: contentInputsDigest}
+}
+
+// PresubmitBuildCache shares the images built by presubmits between them
+func PresubmitBuildCache(namespace string, ttl time.Duration) ImageSharing {
+	return ImageSharing{Namespace: namespace, Stream: PresubmitBuildCacheStream, TTL: ttl, digest: contentInputsDigest}
+}
+
+// WithImageSharing returns a context in which builds share their output
+// through the image sharing in addition to those of the parent context,
+// which are looked up after it
+func WithImageSharing(ctx context.Context, sharing ImageSharing) context.Context {
+	all := append([]*ImageSharing{}, imageSharingFrom(ctx)...)
+	return context.WithValue(ctx, imageSharingKey{}, append(all, &sharing))
+}
+
+func imageSharingFrom(ctx context.Context) []*ImageSharing {
+	sharing, _ := ctx.Value(imageSharingKey{}).([]*ImageSharing)
+	return sharing
+}
+
+type buildRunner func(context.Context, BuildClient, *buildapi.Build) error
+
+// around returns a runner sharing the output of builds run by the other
+func (s *ImageSharing) around(run buildRunner) buildRunner {
+	return func(ctx context.Context, client BuildClient, build *buildapi.Build) error {
+		return s.handleBuild(ctx, client, build, run)
+	}
+}
+
+var invalidStreamCharacters = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// PullRequestImageStream returns the name of the image stream the images of
+// the pull requests under test are shared in, if the job tests any
+func PullRequestImageStream(refs *prowv1.Refs) (string, bool) {
+	if refs == nil || len(refs.Pulls) == 0 {
+		return "", false
+	}
+	parts := []string{refsLabelValue(refs.Org), refsLabelValue(refs.Repo)}
+	for _, pull := range refs.Pulls {
+		parts = append(parts, strconv.Itoa(pull.Number))
+	}
+	name := strings.Trim(invalidStreamCharacters.ReplaceAllString(strings.ToLower(strings.Join(parts, "-")), "-"), "-.")
+	if len(name) > 63 {
+		name = fmt.Sprintf("%s-%x", name[:46], sha256.Sum256([]byte(name)))[:63]
+	}
+	return name, true
+}
+
+// handleBuild imports the output of the build from the shared image stream
+// if another job built it from the same inputs, or runs the build and
+// publishes its output otherwise. Sharing is best effort: when it fails, the
+// job builds on its own.
+func (s *ImageSharing) handleBuild(ctx context.Context, client BuildClient, build *buildapi.Build, run buildRunner) error {
+	to := build.Spec.Output.To
+	if to == nil || to.Kind != "ImageStreamTag" {
+		return run(ctx, client, build)
+	}
+	namespace := to.Namespace
+	if namespace == "" {
+		namespace = build.Namespace
+	}
+	// a previous run in this namespace is reused by the build itself
+	if exists, err := outputExists(ctx, client, build); err != nil || exists {
+		return run(ctx, client, build)
+	}
+	inputsDigest := s.digest
+	if inputsDigest == nil {
+		inputsDigest = sharedInputsDigest
+	}
+	digest, err := inputsDigest(ctx, client, build)
+	if err != nil {
+		Logger(ctx).WithError(err).Warn("Could not determine the digest of the inputs of the build, not sharing its output.")
+		return run(ctx, client, build)
+	}
+	shared := &imagev1.ImageStreamTag{}
+	err = client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.Namespace, Name: s.tag(digest)}, shared)
+	switch {
+	case err == nil && s.expired(shared):
+		Logger(ctx).Debugf("The output of build %s in %s/%s expired, building it...", build.Name, s.Namespace, shared.Name)
+		if s.ReadOnly {
+			break
+		}
+		if err := client.Delete(ctx, shared); err != nil && !kerrors.IsNotFound(err) {
+			Logger(ctx).WithError(err).Warnf("Could not delete %s/%s.", s.Namespace, shared.Name)
+		}
+	case err == nil:
+		if err := s.importShared(ctx, client, namespace, to.Name, shared); err != nil {
+			Logger(ctx).WithError(err).Warnf("Could not import %s/%s, building it...", s.Namespace, shared.Name)
+			break
+		}
+		Logger(ctx).Infof("Imported the output of build %s from %s/%s, %s built it from the same inputs", build.Name, s.Namespace, shared.Name, sharedBy(shared))
+		telemetry.RecordCacheHit(ctx, s.cacheKind())
+		if shared.Annotations[SharedImageBranchLabelsAnnotation] == branchLabelValues(build) {
+			return nil
+		}
+		Logger(ctx).Infof("The output of build %s was built for another branch, relabeling it...", build.Name)
+		return run(ctx, client, relabelBuild(build))
+	case !kerrors.IsNotFound(err):
+		Logger(ctx).WithError(err).Warnf("Could not look up %s/%s, building it...", s.Namespace, s.tag(digest))
+	}
+	if err := run(ctx, client, build); err != nil || s.ReadOnly {
+		return err
+	}
+	if err := s.publish(ctx, client, namespace, to.Name, digest, build); err != nil {
+		Logger(ctx).WithError(err).Warnf("Could not share the output of build %s.", build.Name)
+	}
+	return nil
+}
+
+// tag is the image stream tag holding the output of builds with the digest
+func (s *ImageSharing) tag(digest string) string {
+	return fmt.Sprintf("%s:%s", s.Stream, digest)
+}
+
+func (s *ImageSharing) cacheKind() string {
+	if s.Stream == BuildCacheStream || s.Stream == PresubmitBuildCacheStream {
+		return "build-cache"
+	}
+	return "shared-build"
+}
+
+// expired determines whether a shared image is too old to be used
+func (s *ImageSharing) expired(shared *imagev1.ImageStreamTag) bool {
+	return s.TTL > 0 && time.Since(shared.CreationTimestamp.Time) > s.TTL
+}
+
+// sharedBy describes the job that shared an image
+func sharedBy(shared *imagev1.ImageStreamTag) string {
+	if job, ok := shared.Annotations[SharedImageJobAnnotation]; ok {
+		return fmt.Sprintf("job %s", job)
+	}
+	return "another job"
+}
+
+// Prune deletes the shared images older than the TTL
+func (s *ImageSharing) Prune(ctx context.Context, client ctrlruntimeclient.Client) error {
+	if s.TTL <= 0 || s.ReadOnly {
+		return nil
+	}
+	stream := &imagev1.ImageStream{}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.Namespace, Name: s.Stream}, stream); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("could not get the shared image stream: %w", err)
+	}
+	var errs []error
+	for _, tag := range stream.Status.Tags {
+		if len(tag.Items) == 0 || time.Since(tag.Items[0].Created.Time) <= s.TTL {
+			continue
+		}
+		ist := &imagev1.ImageStreamTag{ObjectMeta: meta.ObjectMeta{Namespace: s.Namespace, Name: s.tag(tag.Tag)}}
+		if err := client.Delete(ctx, ist); err != nil && !kerrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("could not delete %s/%s: %w", s.Namespace, ist.Name, err))
+			continue
+		}
+		Logger(ctx).Debugf("Pruned %s/%s", s.Namespace, ist.Name)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// importShared tags the shared image into the output of the build and waits
+// for it to be imported
+func (s *ImageSharing) importShared(ctx context.Context, client ctrlruntimeclient.Client, namespace, name string, shared *imagev1.ImageStreamTag) error {
+	ist := &imagev1.ImageStreamTag{
+		ObjectMeta: meta.ObjectMeta{Namespace: namespace, Name: name},
+		Tag: &imagev1.TagReference{
+			ReferencePolicy: imagev1.TagReferencePolicy{Type: imagev1.LocalTagReferencePolicy},
+			From: &coreapi.ObjectReference{
+				Kind:      "ImageStreamImage",
+				Namespace: s.Namespace,
+				Name:      fmt.Sprintf("%s@%s", s.Stream, shared.Image.Name),
+			},
+		},
+	}
+	if err := client.Create(ctx, ist); err != nil {
+		return fmt.Errorf("could not tag %s: %w", name, err)
+	}
+	importCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	return wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		imported := &imagev1.ImageStreamTag{}
+		if err := client.Get(importCtx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: name}, imported); err != nil {
+			if kerrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return imported.Image.Name != "", nil
+	}, importCtx.Done())
+}
+
+// publish tags the output of the build into the shared image stream
+func (s *ImageSharing) publish(ctx context.Context, client ctrlruntimeclient.Client, namespace, name, digest string, build *buildapi.Build) error {
+	output := &imagev1.ImageStreamTag{}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: name}, output); err != nil {
+		return fmt.Errorf("could not get the output %s: %w", name, err)
+	}
+	stream := &imagev1.ImageStream{ObjectMeta: meta.ObjectMeta{Namespace: s.Namespace, Name: s.Stream}}
+	if err := client.Create(ctx, stream); err != nil && !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not create the shared image stream: %w", err)
+	}
+	streamName := strings.SplitN(name, ":", 2)[0]
+	shared := &imagev1.ImageStreamTag{
+		ObjectMeta: meta.ObjectMeta{
+			Namespace:   s.Namespace,
+			Name:        s.tag(digest),
+			Annotations: sharedImageAnnotations(build),
+		},
+		Tag: &imagev1.TagReference{
+			ReferencePolicy: imagev1.TagReferencePolicy{Type: imagev1.LocalTagReferencePolicy},
+			From: &coreapi.ObjectReference{
+				Kind:      "ImageStreamImage",
+				Namespace: namespace,
+				Name:      fmt.Sprintf("%s@%s", streamName, output.Image.Name),
+			},
+		},
+	}
+	if err := client.Create(ctx, shared); err != nil && !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not tag %s/%s: %w", s.Namespace, shared.Name, err)
+	}
+	Logger(ctx).Debugf("Shared the output of build %s as %s/%s", build.Name, s.Namespace, shared.Name)
+	return nil
+}
+
+// sharedImageAnnotations tie a shared image to the build and job run that
+// published it
+func sharedImageAnnotations(build *buildapi.Build) map[string]string {
+	annotations := map[string]string{SharedImageBuildAnnotation: fmt.Sprintf("%s/%s", build.Namespace, build.Name)}
+	if values := branchLabelValues(build); values != "" {
+		annotations[SharedImageBranchLabelsAnnotation] = values
+	}
+	if raw, ok := build.Annotations[JobSpecAnnotation]; ok {
+		if spec, err := api.ParseSpec([]byte(raw)); err == nil {
+			annotations[SharedImageJobAnnotation] = fmt.Sprintf("%s/%s", spec.Job, spec.BuildID)
+		}
+	}
+	return annotations
+}
+
+// branchLabels name the branch and remote a build cloned its source for
+// rather than the source tree
+var branchLabels = sets.NewString("io.openshift.build.commit.ref", "io.openshift.build.source-location", "vcs-url")
+
+// branchLabelValues serializes the branch labels of the output of the build
+func branchLabelValues(build *buildapi.Build) string {
+	var values []string
+	for _, label := range build.Spec.Output.ImageLabels {
+		if branchLabels.Has(label.Name) {
+			values = append(values, fmt.Sprintf("%s=%s", label.Name, label.Value))
+		}
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
+
+// relabelBuild replaces the build with one which builds its output from the
+// image already imported into it, only setting the labels of the build
+func relabelBuild(build *buildapi.Build) *buildapi.Build {
+	relabel := build.DeepCopy()
+	dockerfile := "FROM scratch"
+	relabel.Spec.Source = buildapi.BuildSource{Type: buildapi.BuildSourceDockerfile, Dockerfile: &dockerfile}
+	strategy := &buildapi.DockerBuildStrategy{From: build.Spec.Output.To.DeepCopy()}
+	if build.Spec.Strategy.DockerStrategy != nil {
+		strategy.PullSecret = build.Spec.Strategy.DockerStrategy.PullSecret
+	}
+	relabel.Spec.Strategy = buildapi.BuildStrategy{Type: buildapi.DockerBuildStrategyType, DockerStrategy: strategy}
+	return relabel
+}
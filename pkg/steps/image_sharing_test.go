@@ -0,0 +1,348 @@
+package steps
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/clonerefs"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	buildapi "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+func TestPullRequestImageStream(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		refs     *prowapi.Refs
+		expected string
+	}{{
+		name: "no refs",
+	}, {
+		name: "postsubmit",
+		refs: &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master"},
+	}, {
+		name:     "pull request",
+		refs:     &prowapi.Refs{Org: "Org", Repo: "repo_name", Pulls: []prowapi.Pull{{Number: 1}}},
+		expected: "org-repo-name-1",
+	}, {
+		name:     "several pull requests",
+		refs:     &prowapi.Refs{Org: "org", Repo: "repo", Pulls: []prowapi.Pull{{Number: 1}, {Number: 22}}},
+		expected: "org-repo-1-22",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			name, ok := PullRequestImageStream(tc.refs)
+			if ok != (tc.expected != "") {
+				t.Errorf("expected a stream: %t, got %t", tc.expected != "", ok)
+			}
+			if diff := cmp.Diff(tc.expected, name); diff != "" {
+				t.Errorf("unexpected stream: %s", diff)
+			}
+		})
+	}
+	long := &prowapi.Refs{Org: "org", Repo: strings.Repeat("r", 70), Pulls: []prowapi.Pull{{Number: 1}}}
+	if name, _ := PullRequestImageStream(long); len(name) != 63 || !strings.HasPrefix(name, "org-"+strings.Repeat("r", 42)+"-") {
+		t.Errorf("expected a long name to be truncated and hashed, got %s", name)
+	}
+}
+
+// taggingClient imports the images tagged into image streams immediately
+type taggingClient struct {
+	ctrlruntimeclient.Client
+}
+
+func (c *taggingClient) Create(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.CreateOption) error {
+	if ist, ok := obj.(*imagev1.ImageStreamTag); ok && ist.Tag != nil && ist.Tag.From != nil {
+		ist.Image.Name = strings.SplitN(ist.Tag.From.Name, "@", 2)[1]
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestImageSharing(t *testing.T) {
+	newBuild := func(namespace string) *buildapi.Build {
+		dockerfile := "FROM root"
+		return &buildapi.Build{
+			ObjectMeta: meta.ObjectMeta{Name: "src", Namespace: namespace},
+			Spec: buildapi.BuildSpec{CommonSpec: buildapi.CommonSpec{
+				Source: buildapi.BuildSource{Dockerfile: &dockerfile},
+				Strategy: buildapi.BuildStrategy{DockerStrategy: &buildapi.DockerBuildStrategy{
+					From: &coreapi.ObjectReference{Kind: "ImageStreamTag", Name: "pipeline:root"},
+				}},
+				Output: buildapi.BuildOutput{To: &coreapi.ObjectReference{Kind: "ImageStreamTag", Name: "pipeline:src"}},
+			}},
+		}
+	}
+	root := func(namespace string) *imagev1.ImageStreamTag {
+		return &imagev1.ImageStreamTag{
+			ObjectMeta: meta.ObjectMeta{Name: "pipeline:root", Namespace: namespace},
+			Image:      imagev1.Image{ObjectMeta: meta.ObjectMeta{Name: "sha256:root"}},
+		}
+	}
+	client := NewBuildClient(loggingclient.New(&taggingClient{Client: fakectrlruntimeclient.NewFakeClient(root("first"), root("second"))}), nil)
+	sharing := ImageSharing{Namespace: "shared", Stream: "org-repo-1"}
+	var built []string
+	run := func(ctx context.Context, client BuildClient, build *buildapi.Build) error {
+		built = append(built, build.Namespace)
+		return client.Create(ctx, &imagev1.ImageStreamTag{
+			ObjectMeta: meta.ObjectMeta{Namespace: build.Namespace, Name: build.Spec.Output.To.Name},
+			Tag:        &imagev1.TagReference{From: &coreapi.ObjectReference{Name: "pipeline@sha256:src"}},
+		})
+	}
+	ctx := context.Background()
+
+	first, second := newBuild("first"), newBuild("second")
+	firstDigest, err := sharedInputsDigest(ctx, client, first)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	secondDigest, err := sharedInputsDigest(ctx, client, second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if firstDigest != secondDigest {
+		t.Errorf("expected builds with the same inputs in different namespaces to have the same shared digest")
+	}
+	if a, _ := buildInputsDigest(ctx, client, first); a == firstDigest {
+		t.Errorf("expected the shared digest to differ from the digest of the namespace")
+	}
+
+	for _, build := range []*buildapi.Build{first, second} {
+		if err := sharing.handleBuild(ctx, client, build, run); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if diff := cmp.Diff([]string{"first"}, built); diff != "" {
+		t.Errorf("expected only the first build to run: %s", diff)
+	}
+
+	shared := &imagev1.ImageStreamTag{}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: "shared", Name: sharing.tag(firstDigest)}, shared); err != nil {
+		t.Fatalf("expected the output to be shared: %v", err)
+	}
+	if diff := cmp.Diff("first/src", shared.Annotations[SharedImageBuildAnnotation]); diff != "" {
+		t.Errorf("unexpected annotation: %s", diff)
+	}
+	imported := &imagev1.ImageStreamTag{}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: "second", Name: "pipeline:src"}, imported); err != nil {
+		t.Fatalf("expected the output to be imported: %v", err)
+	}
+	if diff := cmp.Diff(&coreapi.ObjectReference{Kind: "ImageStreamImage", Namespace: "shared", Name: "org-repo-1@sha256:src"}, imported.Tag.From); diff != "" {
+		t.Errorf("unexpected import: %s", diff)
+	}
+}
+
+func TestContentInputsDigest(t *testing.T) {
+	newBuild := func(namespace string, refs prowapi.Refs) *buildapi.Build {
+		options, err := clonerefs.Encode(clonerefs.Options{GitRefs: []prowapi.Refs{refs}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return &buildapi.Build{
+			ObjectMeta: meta.ObjectMeta{Name: "src", Namespace: namespace},
+			Spec: buildapi.BuildSpec{CommonSpec: buildapi.CommonSpec{
+				Strategy: buildapi.BuildStrategy{DockerStrategy: &buildapi.DockerBuildStrategy{
+					Env: []coreapi.EnvVar{{Name: clonerefs.JSONConfigEnvVar, Value: options}},
+				}},
+			}},
+		}
+	}
+	digest := func(build *buildapi.Build) string {
+		d, err := contentInputsDigest(context.Background(), fakectrlruntimeclient.NewFakeClient(), build)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return d
+	}
+	pull := prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "base", Pulls: []prowapi.Pull{{Number: 1, Author: "author", SHA: "head"}}}
+	base := digest(newBuild("first", pull))
+
+	otherBranch := pull
+	otherBranch.BaseRef, otherBranch.Pulls = "release-4.8", []prowapi.Pull{{Number: 2, Author: "other", SHA: "head"}}
+	if d := digest(newBuild("second", otherBranch)); d != base {
+		t.Errorf("expected the same commits on another branch to have the same digest")
+	}
+	otherCommit := pull
+	otherCommit.Pulls = []prowapi.Pull{{Number: 1, Author: "author", SHA: "amended"}}
+	if d := digest(newBuild("first", otherCommit)); d == base {
+		t.Errorf("expected other commits to change the digest")
+	}
+	if d, _ := sharedInputsDigest(context.Background(), fakectrlruntimeclient.NewFakeClient(), newBuild("second", otherBranch)); d == base {
+		t.Errorf("expected the shared digest to depend on the pull requests")
+	}
+	labeled := newBuild("first", pull)
+	labeled.Spec.Output.ImageLabels = []buildapi.ImageLabel{{Name: "vcs-ref", Value: "merge"}}
+	if d := digest(labeled); d == base {
+		t.Errorf("expected the labels of the image to change the digest")
+	}
+	branchLabeled := newBuild("second", otherBranch)
+	branchLabeled.Spec.Output.ImageLabels = []buildapi.ImageLabel{{Name: "io.openshift.build.commit.ref", Value: "release-4.8"}, {Name: "vcs-url", Value: "https://github.com/fork/repo"}}
+	if d := digest(branchLabeled); d != base {
+		t.Errorf("expected the labels naming the branch not to change the digest")
+	}
+}
+
+func TestBuildCache(t *testing.T) {
+	build := &buildapi.Build{
+		ObjectMeta: meta.ObjectMeta{
+			Name:        "src",
+			Namespace:   "ns",
+			Annotations: map[string]string{JobSpecAnnotation: `{"type":"periodic","job":"job","buildid":"1"}`},
+		},
+		Spec: buildapi.BuildSpec{CommonSpec: buildapi.CommonSpec{
+			Output: buildapi.BuildOutput{To: &coreapi.ObjectReference{Kind: "ImageStreamTag", Name: "pipeline:src"}},
+		}},
+	}
+	cache := BuildCache("cache", time.Hour, false)
+	digest, err := contentInputsDigest(context.Background(), fakectrlruntimeclient.NewFakeClient(), build)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expired := &imagev1.ImageStreamTag{
+		ObjectMeta: meta.ObjectMeta{Namespace: "cache", Name: cache.tag(digest), CreationTimestamp: meta.NewTime(time.Now().Add(-2 * time.Hour))},
+		Image:      imagev1.Image{ObjectMeta: meta.ObjectMeta{Name: "sha256:old"}},
+	}
+	client := NewBuildClient(loggingclient.New(&taggingClient{Client: fakectrlruntimeclient.NewFakeClient(expired)}), nil)
+	var built int
+	run := func(ctx context.Context, client BuildClient, build *buildapi.Build) error {
+		built++
+		return client.Create(ctx, &imagev1.ImageStreamTag{
+			ObjectMeta: meta.ObjectMeta{Namespace: build.Namespace, Name: build.Spec.Output.To.Name},
+			Tag:        &imagev1.TagReference{From: &coreapi.ObjectReference{Name: "pipeline@sha256:new"}},
+		})
+	}
+	if err := cache.handleBuild(context.Background(), client, build, run); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if built != 1 {
+		t.Errorf("expected an expired image to be built again")
+	}
+	cached := &imagev1.ImageStreamTag{}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "cache", Name: cache.tag(digest)}, cached); err != nil {
+		t.Fatalf("expected the output to be cached: %v", err)
+	}
+	if diff := cmp.Diff(map[string]string{SharedImageBuildAnnotation: "ns/src", SharedImageJobAnnotation: "job/1"}, cached.Annotations); diff != "" {
+		t.Errorf("unexpected annotations: %s", diff)
+	}
+	if diff := cmp.Diff("pipeline@sha256:new", cached.Tag.From.Name); diff != "" {
+		t.Errorf("expected the new output to be cached: %s", diff)
+	}
+}
+
+func TestBuildCacheRelabels(t *testing.T) {
+	newBuild := func(namespace, branch string) *buildapi.Build {
+		return &buildapi.Build{
+			ObjectMeta: meta.ObjectMeta{Name: "src", Namespace: namespace},
+			Spec: buildapi.BuildSpec{CommonSpec: buildapi.CommonSpec{
+				Output: buildapi.BuildOutput{
+					To:          &coreapi.ObjectReference{Kind: "ImageStreamTag", Name: "pipeline:src"},
+					ImageLabels: []buildapi.ImageLabel{{Name: "io.openshift.build.commit.ref", Value: branch}, {Name: "vcs-ref", Value: "base"}},
+				},
+			}},
+		}
+	}
+	// the fake client does not set the creation time the TTL is checked against
+	cache := BuildCache("cache", 0, false)
+	client := NewBuildClient(loggingclient.New(&taggingClient{Client: fakectrlruntimeclient.NewFakeClient()}), nil)
+	var built []*buildapi.Build
+	run := func(ctx context.Context, client BuildClient, build *buildapi.Build) error {
+		built = append(built, build)
+		if build.Spec.Strategy.DockerStrategy != nil && build.Spec.Strategy.DockerStrategy.From != nil {
+			return nil
+		}
+		return client.Create(ctx, &imagev1.ImageStreamTag{
+			ObjectMeta: meta.ObjectMeta{Namespace: build.Namespace, Name: build.Spec.Output.To.Name},
+			Tag:        &imagev1.TagReference{From: &coreapi.ObjectReference{Name: "pipeline@sha256:src"}},
+		})
+	}
+	for _, build := range []*buildapi.Build{newBuild("first", "master"), newBuild("second", "master"), newBuild("third", "release-4.8")} {
+		if err := cache.handleBuild(context.Background(), client, build, run); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	var namespaces []string
+	for _, build := range built {
+		namespaces = append(namespaces, build.Namespace)
+	}
+	if diff := cmp.Diff([]string{"first", "third"}, namespaces); diff != "" {
+		t.Fatalf("expected the first build to run and the one for another branch to be relabeled: %s", diff)
+	}
+	relabel := built[1]
+	if diff := cmp.Diff(&coreapi.ObjectReference{Kind: "ImageStreamTag", Name: "pipeline:src"}, relabel.Spec.Strategy.DockerStrategy.From); diff != "" {
+		t.Errorf("expected the relabel build to build from the imported image: %s", diff)
+	}
+	if diff := cmp.Diff(newBuild("third", "release-4.8").Spec.Output, relabel.Spec.Output); diff != "" {
+		t.Errorf("expected the relabel build to set the labels of the build: %s", diff)
+	}
+}
+
+func TestPresubmitBuildCache(t *testing.T) {
+	build := &buildapi.Build{
+		ObjectMeta: meta.ObjectMeta{Name: "src", Namespace: "ns"},
+		Spec: buildapi.BuildSpec{CommonSpec: buildapi.CommonSpec{
+			Output: buildapi.BuildOutput{To: &coreapi.ObjectReference{Kind: "ImageStreamTag", Name: "pipeline:src"}},
+		}},
+	}
+	trusted, presubmits := BuildCache("cache", time.Hour, true), PresubmitBuildCache("cache", time.Hour)
+	digest, err := contentInputsDigest(context.Background(), fakectrlruntimeclient.NewFakeClient(), build)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := NewBuildClient(loggingclient.New(&taggingClient{Client: fakectrlruntimeclient.NewFakeClient()}), nil)
+	run := func(ctx context.Context, client BuildClient, build *buildapi.Build) error {
+		return client.Create(ctx, &imagev1.ImageStreamTag{
+			ObjectMeta: meta.ObjectMeta{Namespace: build.Namespace, Name: build.Spec.Output.To.Name},
+			Tag:        &imagev1.TagReference{From: &coreapi.ObjectReference{Name: "pipeline@sha256:new"}},
+		})
+	}
+	if err := trusted.handleBuild(context.Background(), client, build, presubmits.around(run)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "cache", Name: presubmits.tag(digest)}, &imagev1.ImageStreamTag{}); err != nil {
+		t.Errorf("expected the output to be shared with presubmits: %v", err)
+	}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "cache", Name: trusted.tag(digest)}, &imagev1.ImageStreamTag{}); !kerrors.IsNotFound(err) {
+		t.Errorf("expected the output of a presubmit not to be shared with other jobs, got %v", err)
+	}
+}
+
+func TestPruneSharedImages(t *testing.T) {
+	tag := func(name string, age time.Duration) imagev1.NamedTagEventList {
+		return imagev1.NamedTagEventList{Tag: name, Items: []imagev1.TagEvent{{Created: meta.NewTime(time.Now().Add(-age))}}}
+	}
+	stream := &imagev1.ImageStream{
+		ObjectMeta: meta.ObjectMeta{Namespace: "cache", Name: BuildCacheStream},
+		Status:     imagev1.ImageStreamStatus{Tags: []imagev1.NamedTagEventList{tag("old", 2*time.Hour), tag("new", time.Minute)}},
+	}
+	var objects []runtime.Object
+	for _, name := range []string{"old", "new"} {
+		objects = append(objects, &imagev1.ImageStreamTag{ObjectMeta: meta.ObjectMeta{Namespace: "cache", Name: BuildCacheStream + ":" + name}})
+	}
+	client := fakectrlruntimeclient.NewFakeClient(append(objects, stream)...)
+	cache := BuildCache("cache", time.Hour, false)
+	if err := cache.Prune(context.Background(), client); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tags := &imagev1.ImageStreamTagList{}
+	if err := client.List(context.Background(), tags); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var remaining []string
+	for _, ist := range tags.Items {
+		remaining = append(remaining, ist.Name)
+	}
+	if diff := cmp.Diff([]string{BuildCacheStream + ":new"}, remaining); diff != "" {
+		t.Errorf("unexpected tags after pruning: %s", diff)
+	}
+}
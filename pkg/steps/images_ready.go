@@ -8,6 +8,9 @@ import (
 	"github.com/openshift/ci-tools/pkg/api"
 )
 
+// ImagesTarget is the target building all images of the configuration
+const ImagesTarget = "[images]"
+
 type imagesReadyStep struct {
 	links []api.StepLink
 }
@@ -34,7 +37,7 @@ func (s *imagesReadyStep) Provides() api.ParameterMap {
 	return nil
 }
 
-func (s *imagesReadyStep) Name() string { return "[images]" }
+func (s *imagesReadyStep) Name() string { return ImagesTarget }
 
 func (s *imagesReadyStep) Objects() []ctrlruntimeclient.Object {
 	return nil
@@ -0,0 +1,200 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/reference"
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+const (
+	// ReasonImportUnauthorized is used when the registry refused the
+	// credentials of the import
+	ReasonImportUnauthorized = results.ReasonUnauthorized
+	// ReasonImportNotFound is used when the imported image does not exist
+	ReasonImportNotFound = results.ReasonNotFound
+	// ReasonImportThrottled is used when the registry kept throttling the
+	// import until it was given up
+	ReasonImportThrottled = results.ReasonThrottled
+	// ReasonImportFailed is used when the import failed for any other
+	// reason
+	ReasonImportFailed = results.ReasonImportFailed
+
+	// DefaultImportConcurrency is the number of images imported from a
+	// registry at the same time
+	DefaultImportConcurrency = 4
+)
+
+// DefaultImportBackoff is used to retry imports which failed for reasons
+// that may go away, like throttling
+var DefaultImportBackoff = wait.Backoff{
+	Steps:    6,
+	Duration: 2 * time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+}
+
+// ClassifyImportFailure determines why an import failed from the status the
+// image stream reports for it
+func ClassifyImportFailure(reason metav1.StatusReason, code int32, message string) results.Reason {
+	message = strings.ToLower(message)
+	contains := func(substrings ...string) bool {
+		for _, substring := range substrings {
+			if strings.Contains(message, substring) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case reason == metav1.StatusReasonTooManyRequests || code == http.StatusTooManyRequests || contains("toomanyrequests", "too many requests", "rate limit"):
+		return ReasonImportThrottled
+	case reason == metav1.StatusReasonUnauthorized || reason == metav1.StatusReasonForbidden || code == http.StatusUnauthorized || code == http.StatusForbidden || contains("unauthorized", "authentication required", "access denied", "denied:"):
+		return ReasonImportUnauthorized
+	case reason == metav1.StatusReasonNotFound || code == http.StatusNotFound || contains("not found", "manifest unknown", "does not exist"):
+		return ReasonImportNotFound
+	default:
+		return ReasonImportFailed
+	}
+}
+
+// ImportManager runs the imports of external and base images into image
+// streams for all steps of a job. It caps the number of imports running at
+// the same time for every registry, so that the job does not get throttled,
+// and retries imports which were throttled anyway with exponential backoff.
+type ImportManager struct {
+	concurrency int
+	backoff     wait.Backoff
+
+	lock sync.Mutex
+	// slots holds a semaphore for every registry
+	slots map[string]chan struct{}
+}
+
+// NewImportManager creates a manager running as many imports per registry
+// as the concurrency allows and retrying them with the backoff
+func NewImportManager(concurrency int, backoff wait.Backoff) *ImportManager {
+	return &ImportManager{
+		concurrency: concurrency,
+		backoff:     backoff,
+		slots:       map[string]chan struct{}{},
+	}
+}
+
+func (m *ImportManager) slot(registry string) chan struct{} {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	slot, ok := m.slots[registry]
+	if !ok {
+		slot = make(chan struct{}, m.concurrency)
+		m.slots[registry] = slot
+	}
+	return slot
+}
+
+// acquire waits for a slot to import the image from its registry, which
+// is given back by calling the returned function
+func (m *ImportManager) acquire(ctx context.Context, pullSpec string) (func(), error) {
+	registry := pullSpec
+	if named, err := reference.ParseNormalizedNamed(pullSpec); err == nil {
+		registry = reference.Domain(named)
+	}
+	slot := m.slot(registry)
+	select {
+	case slot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return func() { <-slot }, nil
+}
+
+// Import imports the single image of the stream import and returns its
+// status once the image was imported. Failures carry the reason the import
+// failed for.
+func (m *ImportManager) Import(ctx context.Context, client ctrlruntimeclient.Client, streamImport *imagev1.ImageStreamImport) (*imagev1.ImageImportStatus, error) {
+	if len(streamImport.Spec.Images) != 1 {
+		return nil, fmt.Errorf("expected a single image to import, got %d", len(streamImport.Spec.Images))
+	}
+	pullSpec := streamImport.Spec.Images[0].From.Name
+	release, err := m.acquire(ctx, pullSpec)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var status *imagev1.ImageImportStatus
+	reason, lastErr := ReasonImportFailed, fmt.Errorf("no status was reported")
+	err = wait.ExponentialBackoff(m.backoff, func() (bool, error) {
+		attempt := streamImport.DeepCopy()
+		if err := client.Create(ctx, attempt); err != nil {
+			switch {
+			case kerrors.IsConflict(err):
+				return false, nil
+			case kerrors.IsForbidden(err):
+				// we might race against establishing the roles allowing
+				// the import in the namespace of the job
+				reason, lastErr = ReasonImportUnauthorized, err
+				return false, nil
+			case kerrors.IsTooManyRequests(err):
+				reason, lastErr = ReasonImportThrottled, err
+				return false, nil
+			}
+			return false, err
+		}
+		streamImport.Status = attempt.Status
+		if len(attempt.Status.Images) == 0 {
+			return false, nil
+		}
+		image := attempt.Status.Images[0]
+		if image.Image != nil {
+			status = &image
+			return true, nil
+		}
+		if image.Status.Message == "" {
+			return false, nil
+		}
+		reason, lastErr = ClassifyImportFailure(image.Status.Reason, image.Status.Code, image.Status.Message), fmt.Errorf("%s", image.Status.Message)
+		switch reason {
+		case ReasonImportNotFound, ReasonImportUnauthorized:
+			return false, lastErr
+		}
+		Logger(ctx).Infof("Import of %s failed, retrying: %s", pullSpec, image.Status.Message)
+		return false, nil
+	})
+	switch {
+	case err == nil:
+		return status, nil
+	case err == wait.ErrWaitTimeout:
+		err = lastErr
+	}
+	return nil, results.ForReason(reason).WithError(err).Errorf("could not import %s: %v", pullSpec, err)
+}
+
+// tagImportFailure determines why the last import of the tag in the stream
+// failed, if it did
+func tagImportFailure(stream *imagev1.ImageStream, tag string) (results.Reason, string, bool) {
+	for _, tags := range stream.Status.Tags {
+		if tags.Tag != tag {
+			continue
+		}
+		for _, condition := range tags.Conditions {
+			if condition.Type == imagev1.ImportSuccess && condition.Status == coreapi.ConditionFalse {
+				return ClassifyImportFailure(metav1.StatusReason(condition.Reason), 0, condition.Message), condition.Message, true
+			}
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,167 @@
+package steps
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+func TestClassifyImportFailure(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		reason   metav1.StatusReason
+		code     int32
+		message  string
+		expected results.Reason
+	}{
+		{
+			name:     "throttled by status code",
+			code:     http.StatusTooManyRequests,
+			expected: ReasonImportThrottled,
+		},
+		{
+			name:     "throttled by message",
+			message:  "toomanyrequests: You have reached your pull rate limit",
+			expected: ReasonImportThrottled,
+		},
+		{
+			name:     "unauthorized by reason",
+			reason:   metav1.StatusReasonUnauthorized,
+			expected: ReasonImportUnauthorized,
+		},
+		{
+			name:     "unauthorized by message",
+			message:  "you may not have access to the container image \"quay.io/org/private:tag\": unauthorized: access to the requested resource is not authorized",
+			expected: ReasonImportUnauthorized,
+		},
+		{
+			name:     "not found by reason",
+			reason:   metav1.StatusReasonNotFound,
+			expected: ReasonImportNotFound,
+		},
+		{
+			name:     "not found by message",
+			message:  "manifest unknown: manifest unknown",
+			expected: ReasonImportNotFound,
+		},
+		{
+			name:     "anything else",
+			reason:   metav1.StatusReasonInternalError,
+			message:  "Internal error occurred: connection reset by peer",
+			expected: ReasonImportFailed,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := ClassifyImportFailure(testCase.reason, testCase.code, testCase.message); actual != testCase.expected {
+				t.Errorf("expected %q, got %q", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+// scriptedImportClient answers imports with the statuses or errors in order
+type scriptedImportClient struct {
+	ctrlruntimeclient.Client
+	responses []interface{}
+	attempts  int
+}
+
+func (c *scriptedImportClient) Create(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.CreateOption) error {
+	streamImport, ok := obj.(*imagev1.ImageStreamImport)
+	if !ok {
+		return c.Client.Create(ctx, obj, opts...)
+	}
+	response := c.responses[c.attempts]
+	if c.attempts < len(c.responses)-1 {
+		c.attempts++
+	}
+	switch r := response.(type) {
+	case error:
+		return r
+	case imagev1.ImageImportStatus:
+		streamImport.Status.Images = []imagev1.ImageImportStatus{r}
+	}
+	return nil
+}
+
+func TestImportManager(t *testing.T) {
+	imported := imagev1.ImageImportStatus{Image: &imagev1.Image{DockerImageReference: "quay.io/org/image@sha256:abc"}}
+	failed := func(reason metav1.StatusReason, message string) imagev1.ImageImportStatus {
+		return imagev1.ImageImportStatus{Status: metav1.Status{Status: metav1.StatusFailure, Reason: reason, Message: message}}
+	}
+	var testCases = []struct {
+		name           string
+		responses      []interface{}
+		expectedErr    string
+		expectedReason string
+	}{
+		{
+			name:      "throttled import is retried",
+			responses: []interface{}{failed("", "toomanyrequests: slow down"), imported},
+		},
+		{
+			name:      "forbidden import is retried",
+			responses: []interface{}{kerrors.NewForbidden(schema.GroupResource{Group: "image.openshift.io", Resource: "imagestreamimports"}, "pipeline", nil), imported},
+		},
+		{
+			name:           "missing image fails immediately",
+			responses:      []interface{}{failed(metav1.StatusReasonNotFound, "manifest unknown"), imported},
+			expectedErr:    "could not import quay.io/org/image:tag: manifest unknown",
+			expectedReason: "not_found",
+		},
+		{
+			name:           "throttled until giving up",
+			responses:      []interface{}{kerrors.NewTooManyRequests("slow down", 1)},
+			expectedErr:    "could not import quay.io/org/image:tag: slow down",
+			expectedReason: "throttled",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := &scriptedImportClient{Client: fakectrlruntimeclient.NewFakeClient(), responses: testCase.responses}
+			manager := NewImportManager(1, wait.Backoff{Steps: 3, Duration: time.Millisecond})
+			streamImport := &imagev1.ImageStreamImport{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+				Spec: imagev1.ImageStreamImportSpec{
+					Import: true,
+					Images: []imagev1.ImageImportSpec{{
+						From: coreapi.ObjectReference{Kind: "DockerImage", Name: "quay.io/org/image:tag"},
+					}},
+				},
+			}
+			status, err := manager.Import(context.Background(), client, streamImport)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if err != nil {
+				if reason := results.FullReason(err); reason != testCase.expectedReason {
+					t.Errorf("expected reason %q, got %q", testCase.expectedReason, reason)
+				}
+				return
+			}
+			if status.Image.DockerImageReference != imported.Image.DockerImageReference {
+				t.Errorf("expected %s to be imported, got %s", imported.Image.DockerImageReference, status.Image.DockerImageReference)
+			}
+		})
+	}
+}
@@ -34,7 +34,7 @@ func (s *indexGeneratorStep) Inputs() (api.InputDefinition, error) {
 func (*indexGeneratorStep) Validate() error { return nil }
 
 func (s *indexGeneratorStep) Run(ctx context.Context) error {
-	return results.ForReason("building_index_generator").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonBuildingIndexGenerator).ForError(s.run(ctx))
 }
 
 func (s *indexGeneratorStep) run(ctx context.Context) error {
@@ -74,7 +74,7 @@ func (s *indexGeneratorStep) run(ctx context.Context) error {
 	)
 	err = handleBuild(ctx, s.client, build)
 	if err != nil && strings.Contains(err.Error(), "error checking provided apis") {
-		return results.ForReason("generating_index").WithError(err).Errorf("failed to generate operator index due to invalid bundle info: %v", err)
+		return results.ForReason(results.ReasonGeneratingIndex).WithError(err).Errorf("failed to generate operator index due to invalid bundle info: %v", err)
 	}
 	return err
 }
@@ -93,7 +93,15 @@ func (s *indexGeneratorStep) indexGenDockerfile() (string, error) {
 		}
 		bundles = append(bundles, fullSpec)
 	}
-	dockerCommands = append(dockerCommands, fmt.Sprintf(`RUN ["opm", "index", "add", "--mode", "semver", "--bundles", "%s", "--out-dockerfile", "%s", "--generate"]`, strings.Join(bundles, ","), IndexDockerfileName))
+	mode := s.config.UpdateGraph
+	if mode == "" {
+		mode = api.IndexUpdateGraphSemver
+	}
+	var fromIndex string
+	if s.config.BaseIndex != "" {
+		fromIndex = fmt.Sprintf(`, "--from-index", "%s"`, s.config.BaseIndex)
+	}
+	dockerCommands = append(dockerCommands, fmt.Sprintf(`RUN ["opm", "index", "add", "--mode", "%s"%s, "--bundles", "%s", "--out-dockerfile", "%s", "--generate"]`, mode, fromIndex, strings.Join(bundles, ","), IndexDockerfileName))
 	dockerCommands = append(dockerCommands, fmt.Sprintf("FROM %s:%s", api.PipelineImageStream, api.PipelineImageStreamTagReferenceSource))
 	dockerCommands = append(dockerCommands, fmt.Sprintf("WORKDIR %s", IndexDataDirectory))
 	dockerCommands = append(dockerCommands, fmt.Sprintf("COPY --from=builder %s %s", IndexDockerfileName, IndexDockerfileName))
@@ -84,4 +84,30 @@ COPY --from=builder /database/ database`
 	if expectedDockerfileMultiBundle != generatedDockerfile {
 		t.Errorf("Generated opm index dockerfile does not equal expected:\n%s", cmp.Diff(expectedDockerfileMultiBundle, generatedDockerfile))
 	}
+
+	var expectedDockerfileBaseIndex = `FROM quay.io/operator-framework/upstream-opm-builder AS builder
+COPY .dockerconfigjson .
+RUN mkdir $HOME/.docker && mv .dockerconfigjson $HOME/.docker/config.json
+RUN ["opm", "index", "add", "--mode", "replaces", "--from-index", "quay.io/org/index:v4.9", "--bundles", "some-reg/target-namespace/pipeline@ci-bundle0", "--out-dockerfile", "index.Dockerfile", "--generate"]
+FROM pipeline:src
+WORKDIR /index-data
+COPY --from=builder index.Dockerfile index.Dockerfile
+COPY --from=builder /database/ database`
+	stepBaseIndex := indexGeneratorStep{
+		config: api.IndexGeneratorStepConfiguration{
+			OperatorIndex: []string{"ci-bundle0"},
+			BaseIndex:     "quay.io/org/index:v4.9",
+			UpdateGraph:   api.IndexUpdateGraphReplaces,
+		},
+		jobSpec: &api.JobSpec{},
+		client:  &buildClient{LoggingClient: loggingclient.New(fakeClientSet)},
+	}
+	stepBaseIndex.jobSpec.SetNamespace("target-namespace")
+	generatedDockerfile, err = stepBaseIndex.indexGenDockerfile()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expectedDockerfileBaseIndex != generatedDockerfile {
+		t.Errorf("Generated opm index dockerfile does not equal expected:\n%s", cmp.Diff(expectedDockerfileBaseIndex, generatedDockerfile))
+	}
 }
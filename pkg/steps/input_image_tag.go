@@ -3,7 +3,6 @@ package steps
 import (
 	"context"
 	"fmt"
-	"log"
 	"time"
 
 	coreapi "k8s.io/api/core/v1"
@@ -16,6 +15,7 @@ import (
 
 	"github.com/openshift/ci-tools/pkg/api"
 	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/signing"
 	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
 	"github.com/openshift/ci-tools/pkg/steps/utils"
 	"github.com/openshift/ci-tools/pkg/util"
@@ -31,9 +31,19 @@ var (
 type inputImageTagStep struct {
 	config  api.InputImageTagStepConfiguration
 	client  loggingclient.LoggingClient
+	imports *ImportManager
 	jobSpec *api.JobSpec
 
 	imageName string
+	// pinned is set when the image was recorded by a previous run instead
+	// of being resolved
+	pinned bool
+	// pullSpec is where the base image is imported from
+	pullSpec string
+
+	// pullSecret and signingSecret provide the credentials and the
+	// public key signatures are verified with
+	pullSecret, signingSecret *coreapi.Secret
 }
 
 func (s *inputImageTagStep) Inputs() (api.InputDefinition, error) {
@@ -48,23 +58,55 @@ func (s *inputImageTagStep) Inputs() (api.InputDefinition, error) {
 		return nil, fmt.Errorf("could not resolve base image: %w", err)
 	}
 
-	log.Printf("Resolved %s/%s:%s to %s", s.config.BaseImage.Namespace, s.config.BaseImage.Name, s.config.BaseImage.Tag, from.Image.Name)
 	s.imageName = from.Image.Name
+	s.pullSpec = from.Image.DockerImageReference
 	return api.InputDefinition{from.Image.Name}, nil
 }
 
+func baseImageKey(image api.ImageStreamTagReference) string {
+	return fmt.Sprintf("%s/%s:%s", image.Namespace, image.Name, image.Tag)
+}
+
+func (s *inputImageTagStep) LockInputs(lock *InputsLock) {
+	if s.imageName == "" {
+		return
+	}
+	if lock.BaseImages == nil {
+		lock.BaseImages = map[string]string{}
+	}
+	lock.BaseImages[baseImageKey(s.config.BaseImage)] = s.imageName
+}
+
+func (s *inputImageTagStep) PinInputs(lock *InputsLock) {
+	if digest, ok := lock.BaseImages[baseImageKey(s.config.BaseImage)]; ok {
+		s.imageName = digest
+		s.pinned = true
+	}
+}
+
 func (*inputImageTagStep) Validate() error { return nil }
 
 func (s *inputImageTagStep) Run(ctx context.Context) error {
-	return results.ForReason("tagging_input_image").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonTaggingInputImage).ForError(s.run(ctx))
 }
 
 func (s *inputImageTagStep) run(ctx context.Context) error {
-	log.Printf("Tagging %s/%s:%s into %s:%s", s.config.BaseImage.Namespace, s.config.BaseImage.Name, s.config.BaseImage.Tag, api.PipelineImageStream, s.config.To)
+	Logger(ctx).Infof("Tagging %s/%s:%s into %s:%s", s.config.BaseImage.Namespace, s.config.BaseImage.Name, s.config.BaseImage.Tag, api.PipelineImageStream, s.config.To)
 
 	if _, err := s.Inputs(); err != nil {
 		return fmt.Errorf("could not resolve inputs for image tag step: %w", err)
 	}
+	if s.pinned {
+		Logger(ctx).Infof("Pinned %s to %s", baseImageKey(s.config.BaseImage), s.imageName)
+	} else {
+		Logger(ctx).Infof("Resolved %s to %s", baseImageKey(s.config.BaseImage), s.imageName)
+	}
+
+	if s.config.Signature != nil {
+		if err := s.verify(ctx); err != nil {
+			return err
+		}
+	}
 
 	ist := &imagev1.ImageStreamTag{
 		ObjectMeta: metav1.ObjectMeta{
@@ -83,6 +125,18 @@ func (s *inputImageTagStep) run(ctx context.Context) error {
 		},
 	}
 
+	// the image is imported like external images are, so that all imports
+	// from a registry share its limit
+	pullSpec := s.pullSpec
+	if pullSpec == "" {
+		pullSpec = fmt.Sprintf("%s/%s/%s@%s", ciRegistry, s.config.BaseImage.Namespace, s.config.BaseImage.Name, s.imageName)
+	}
+	release, err := s.imports.acquire(ctx, pullSpec)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	if err := s.client.Create(ctx, ist); err != nil && !kerrors.IsAlreadyExists(err) {
 		return fmt.Errorf("failed to create imagestreamtag for input image: %w", err)
 	}
@@ -96,17 +150,40 @@ func (s *inputImageTagStep) run(ctx context.Context) error {
 			return false, err
 		}
 		_, exists := util.ResolvePullSpec(pipeline, string(s.config.To), true)
+		if reason, message, failed := tagImportFailure(pipeline, string(s.config.To)); !exists && failed {
+			// retrying will not help when the image is gone or we may not pull it
+			if reason == ReasonImportNotFound || reason == ReasonImportUnauthorized {
+				return false, results.ForReason(reason).ForError(fmt.Errorf("could not import %s: %s", ist.ObjectMeta.Name, message))
+			}
+		}
 		if !exists {
-			log.Printf("waiting for importing %s ...", ist.ObjectMeta.Name)
+			Logger(ctx).Infof("waiting for importing %s ...", ist.ObjectMeta.Name)
 		}
 		return exists, nil
 	}, importCtx.Done()); err != nil {
-		log.Printf("could not resolve tag %s in imagestream %s: %v", s.config.To, api.PipelineImageStream, err)
+		Logger(ctx).Infof("could not resolve tag %s in imagestream %s: %v", s.config.To, api.PipelineImageStream, err)
 		return err
 	}
 	return nil
 }
 
+// verify checks the signature the promotion pushed next to the base image
+func (s *inputImageTagStep) verify(ctx context.Context) error {
+	reference := fmt.Sprintf("%s/%s/%s@%s", ciRegistry, s.config.BaseImage.Namespace, s.config.BaseImage.Name, s.imageName)
+	Logger(ctx).Infof("Verifying the signature of %s", reference)
+	var publicKey, dockerConfig []byte
+	if s.signingSecret != nil {
+		publicKey = s.signingSecret.Data[signing.PublicKey]
+	}
+	if s.pullSecret != nil {
+		dockerConfig = s.pullSecret.Data[coreapi.DockerConfigJsonKey]
+	}
+	return verifySignature(ctx, reference, *s.config.Signature, publicKey, dockerConfig)
+}
+
+// verifySignature is replaced in tests, which have no cosign binary
+var verifySignature = signing.Verify
+
 func (s *inputImageTagStep) Requires() []api.StepLink {
 	return nil
 }
@@ -132,11 +209,14 @@ func (s *inputImageTagStep) Objects() []ctrlruntimeclient.Object {
 	return s.client.Objects()
 }
 
-func InputImageTagStep(config api.InputImageTagStepConfiguration, client loggingclient.LoggingClient, jobSpec *api.JobSpec) api.Step {
+func InputImageTagStep(config api.InputImageTagStepConfiguration, client loggingclient.LoggingClient, imports *ImportManager, jobSpec *api.JobSpec, pullSecret, signingSecret *coreapi.Secret) api.Step {
 	// when source and destination client are the same, we don't need to use external imports
 	return &inputImageTagStep{
-		config:  config,
-		client:  client,
-		jobSpec: jobSpec,
+		config:        config,
+		client:        client,
+		imports:       imports,
+		jobSpec:       jobSpec,
+		pullSecret:    pullSecret,
+		signingSecret: signingSecret,
 	}
 }
@@ -2,11 +2,14 @@ package steps
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/diff"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -15,6 +18,7 @@ import (
 	imagev1 "github.com/openshift/api/image/v1"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/signing"
 	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
 )
 
@@ -66,7 +70,7 @@ func TestInputImageTagStep(t *testing.T) {
 	// Make a step instance
 	jobspec := &api.JobSpec{}
 	jobspec.SetNamespace("target-namespace")
-	iits := InputImageTagStep(config, client, jobspec)
+	iits := InputImageTagStep(config, client, NewImportManager(DefaultImportConcurrency, DefaultImportBackoff), jobspec, nil, nil)
 
 	// Set up expectations for the step methods
 	specification := stepExpectation{
@@ -129,3 +133,84 @@ func TestInputImageTagStep(t *testing.T) {
 		t.Errorf("Different ImageStreamTag 'pipeline:TO' after step execution:\n%s", diff.ObjectReflectDiff(expectedImageStreamTag, targetImageStreamTag))
 	}
 }
+
+func TestInputImageTagStepSharesImportLimit(t *testing.T) {
+	config := api.InputImageTagStepConfiguration{
+		To:        "TO",
+		BaseImage: api.ImageStreamTagReference{Namespace: "ocp", Name: "base", Tag: "latest"},
+	}
+	client := loggingclient.New(fakectrlruntimeclient.NewFakeClient(&imagev1.ImageStreamTag{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ocp", Name: "base:latest"},
+		Image:      imagev1.Image{ObjectMeta: metav1.ObjectMeta{Name: "sha256:base"}, DockerImageReference: "quay.io/org/base@sha256:47e2f82dbede8ff990e6e240f82d78830e7558f7b30df7bd8c0693992018b1e3"},
+	}))
+	jobSpec := &api.JobSpec{}
+	jobSpec.SetNamespace("ns")
+	imports := NewImportManager(1, DefaultImportBackoff)
+	// an import of an external image from the same registry is running
+	release, err := imports.acquire(context.Background(), "quay.io/org/other:latest")
+	if err != nil {
+		t.Fatalf("failed to acquire slot: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := InputImageTagStep(config, client, imports, jobSpec, nil, nil).Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the step to wait for the import, got %v", err)
+	}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "pipeline:TO"}, &imagev1.ImageStreamTag{}); !kerrors.IsNotFound(err) {
+		t.Errorf("expected no tag to be created while the registry is at its limit, got %v", err)
+	}
+}
+
+func TestInputImageTagStepVerifiesSignature(t *testing.T) {
+	config := api.InputImageTagStepConfiguration{
+		To:        "TO",
+		BaseImage: api.ImageStreamTagReference{Namespace: "ocp", Name: "base", Tag: "latest"},
+		Signature: &api.ImageSigningConfiguration{},
+	}
+	signingSecret := &corev1.Secret{Data: map[string][]byte{signing.PublicKey: []byte("public")}}
+	pullSecret := &corev1.Secret{Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte("{}")}}
+	for _, tc := range []struct {
+		name   string
+		verify error
+	}{
+		{name: "valid signature"},
+		{name: "invalid signature", verify: errors.New("no matching signatures")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var verified string
+			verifySignature = func(_ context.Context, reference string, _ api.ImageSigningConfiguration, publicKey, dockerConfig []byte) error {
+				if string(publicKey) != "public" || string(dockerConfig) != "{}" {
+					t.Errorf("unexpected public key %q or credentials %q", publicKey, dockerConfig)
+				}
+				verified = reference
+				return tc.verify
+			}
+			defer func() { verifySignature = signing.Verify }()
+			client := loggingclient.New(fakectrlruntimeclient.NewFakeClient(&imagev1.ImageStreamTag{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ocp", Name: "base:latest"},
+				Image:      imagev1.Image{ObjectMeta: metav1.ObjectMeta{Name: "sha256:base"}},
+			}))
+			jobSpec := &api.JobSpec{}
+			jobSpec.SetNamespace("ns")
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			err := InputImageTagStep(config, client, NewImportManager(DefaultImportConcurrency, DefaultImportBackoff), jobSpec, pullSecret, signingSecret).Run(ctx)
+			if expected := fmt.Sprintf("%s/ocp/base@sha256:base", ciRegistry); verified != expected {
+				t.Errorf("expected %s to be verified, got %q", expected, verified)
+			}
+			created := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "pipeline:TO"}, &imagev1.ImageStreamTag{})
+			if tc.verify != nil {
+				if !errors.Is(err, tc.verify) {
+					t.Errorf("expected the verification to fail the step, got %v", err)
+				}
+				if !kerrors.IsNotFound(created) {
+					t.Errorf("expected no tag to be created for an unverified image, got %v", created)
+				}
+			} else if created != nil {
+				t.Errorf("expected the tag to be created, got %v", created)
+			}
+		})
+	}
+}
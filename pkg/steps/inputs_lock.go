@@ -0,0 +1,157 @@
+package steps
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps/utils"
+)
+
+// clusterProfileSecretSuffix is the suffix of the names of secrets that hold
+// the cluster profiles of tests
+const clusterProfileSecretSuffix = "-cluster-profile"
+
+// InputsLock records the inputs a job resolved at runtime, so that a later
+// run can pin them and reproduce the job
+type InputsLock struct {
+	// Configuration is the resolved configuration of the job, which holds
+	// the content of every step registry component the job used
+	Configuration *api.ReleaseBuildConfiguration `json:"configuration,omitempty"`
+	// RegistryCommit is the commit of the step registry checkout the
+	// configuration was resolved against, when it is known
+	RegistryCommit string `json:"registry_commit,omitempty"`
+	// BaseImages maps base images, as namespace/name:tag, to the digests
+	// they resolved to
+	BaseImages map[string]string `json:"base_images,omitempty"`
+	// Releases maps the names of imported releases to the payloads they
+	// resolved to
+	Releases map[string]LockedRelease `json:"releases,omitempty"`
+	// StableTags maps the tags of the tag specification to the images they
+	// resolved to when the job started
+	StableTags map[string]string `json:"stable_tags,omitempty"`
+	// ClusterProfiles maps the names of cluster profile secrets to digests
+	// of their content, which is never recorded
+	ClusterProfiles map[string]string `json:"cluster_profiles,omitempty"`
+}
+
+// LockedRelease is a release payload recorded in an inputs lock
+type LockedRelease struct {
+	PullSpec string `json:"pull_spec"`
+	Version  string `json:"version,omitempty"`
+}
+
+// InputsLocker is implemented by steps that resolve inputs at runtime
+type InputsLocker interface {
+	// LockInputs records the resolved inputs of the step, which must
+	// have been resolved already
+	LockInputs(lock *InputsLock)
+	// PinInputs makes the step use the inputs recorded in the lock, if
+	// there are any, instead of resolving them
+	PinInputs(lock *InputsLock)
+}
+
+// ReadInputsLock loads an inputs lock from a file
+func ReadInputsLock(path string) (*InputsLock, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read inputs lock: %w", err)
+	}
+	var lock InputsLock
+	if err := json.Unmarshal(raw, &lock); err != nil {
+		return nil, fmt.Errorf("could not parse inputs lock %s: %w", path, err)
+	}
+	return &lock, nil
+}
+
+// Write stores the inputs lock in a file
+func (l *InputsLock) Write(path string) error {
+	raw, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal inputs lock: %w", err)
+	}
+	if err := ioutil.WriteFile(path, append(raw, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write inputs lock: %w", err)
+	}
+	return nil
+}
+
+// LockSteps records the resolved inputs of every step that supports it
+func (l *InputsLock) LockSteps(steps []api.Step) {
+	for _, step := range steps {
+		if locker, ok := step.(InputsLocker); ok {
+			locker.LockInputs(l)
+		}
+	}
+}
+
+// PinSteps makes every step that supports it use the recorded inputs
+func (l *InputsLock) PinSteps(steps []api.Step) {
+	for _, step := range steps {
+		if locker, ok := step.(InputsLocker); ok {
+			locker.PinInputs(l)
+		}
+	}
+}
+
+// ReleaseInputs returns the recorded release payloads as the explicit pull
+// specs the releases are imported from instead of being resolved
+func (l *InputsLock) ReleaseInputs() (map[string]string, error) {
+	inputs := map[string]string{}
+	for name, release := range l.Releases {
+		env := utils.ReleaseImageEnv(name)
+		if value, ok := os.LookupEnv(env); ok && value != release.PullSpec {
+			return nil, fmt.Errorf("release %s is pinned to %s, but %s is set to %s", name, release.PullSpec, env, value)
+		}
+		inputs[env] = release.PullSpec
+	}
+	return inputs, nil
+}
+
+// LockClusterProfiles records digests of the cluster profile secrets
+func (l *InputsLock) LockClusterProfiles(secrets []*coreapi.Secret) {
+	for _, secret := range secrets {
+		if !strings.HasSuffix(secret.Name, clusterProfileSecretSuffix) {
+			continue
+		}
+		if l.ClusterProfiles == nil {
+			l.ClusterProfiles = map[string]string{}
+		}
+		l.ClusterProfiles[secret.Name] = secretDigest(secret)
+	}
+}
+
+// CheckClusterProfiles reports the cluster profile secrets whose content
+// differs from the recorded one; their content cannot be restored, so a
+// replay using them is not exact
+func (l *InputsLock) CheckClusterProfiles(secrets []*coreapi.Secret) []string {
+	var changed []string
+	for _, secret := range secrets {
+		if recorded, ok := l.ClusterProfiles[secret.Name]; ok && recorded != secretDigest(secret) {
+			changed = append(changed, secret.Name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func secretDigest(secret *coreapi.Secret) string {
+	var keys []string
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%s\x00%d\x00", key, len(secret.Data[key]))
+		hash.Write(secret.Data[key])
+	}
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil))
+}
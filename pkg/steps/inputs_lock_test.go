@@ -0,0 +1,89 @@
+package steps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestInputsLockRoundTrip(t *testing.T) {
+	base := api.InputImageTagStepConfiguration{
+		BaseImage: api.ImageStreamTagReference{Namespace: "ocp", Name: "builder", Tag: "golang-1.16"},
+		To:        "root",
+	}
+	profile := &coreapi.Secret{ObjectMeta: meta.ObjectMeta{Name: "e2e-cluster-profile"}, Data: map[string][]byte{"key": []byte("value")}}
+	other := &coreapi.Secret{ObjectMeta: meta.ObjectMeta{Name: "pull-secret"}, Data: map[string][]byte{"key": []byte("value")}}
+
+	lock := &InputsLock{Configuration: &api.ReleaseBuildConfiguration{Tests: []api.TestStepConfiguration{{As: "e2e"}}}}
+	lock.LockSteps([]api.Step{&inputImageTagStep{config: base, imageName: "sha256:abc"}, &inputImageTagStep{config: api.InputImageTagStepConfiguration{}}})
+	lock.LockClusterProfiles([]*coreapi.Secret{profile, other})
+	path := filepath.Join(t.TempDir(), "inputs.json")
+	if err := lock.Write(path); err != nil {
+		t.Fatalf("failed to write lock: %v", err)
+	}
+	read, err := ReadInputsLock(path)
+	if err != nil {
+		t.Fatalf("failed to read lock: %v", err)
+	}
+	if diff := cmp.Diff(lock, read); diff != "" {
+		t.Errorf("lock changed after a round trip: %s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"ocp/builder:golang-1.16": "sha256:abc"}, read.BaseImages); diff != "" {
+		t.Errorf("unexpected base images: %s", diff)
+	}
+
+	pinned := &inputImageTagStep{config: base}
+	read.PinSteps([]api.Step{pinned})
+	inputs, err := pinned.Inputs()
+	if err != nil {
+		t.Fatalf("pinned step failed to resolve inputs: %v", err)
+	}
+	if diff := cmp.Diff(api.InputDefinition{"sha256:abc"}, inputs); diff != "" {
+		t.Errorf("unexpected inputs of pinned step: %s", diff)
+	}
+
+	if changed := read.CheckClusterProfiles([]*coreapi.Secret{profile, other}); len(changed) != 0 {
+		t.Errorf("expected no changed cluster profiles, got %v", changed)
+	}
+	rotated := &coreapi.Secret{ObjectMeta: meta.ObjectMeta{Name: "e2e-cluster-profile"}, Data: map[string][]byte{"key": []byte("rotated")}}
+	if diff := cmp.Diff([]string{"e2e-cluster-profile"}, read.CheckClusterProfiles([]*coreapi.Secret{rotated})); diff != "" {
+		t.Errorf("unexpected changed cluster profiles: %s", diff)
+	}
+}
+
+func TestReleaseInputs(t *testing.T) {
+	lock := &InputsLock{Releases: map[string]LockedRelease{"latest": {PullSpec: "quay.io/openshift-release-dev/ocp-release:4.10.0-x86_64", Version: "4.10.0"}}}
+	for _, env := range []string{"RELEASE_IMAGE_INITIAL", "RELEASE_IMAGE_LATEST"} {
+		defer os.Unsetenv(env)
+	}
+	if err := os.Setenv("RELEASE_IMAGE_INITIAL", "quay.io/openshift-release-dev/ocp-release:4.9.0-x86_64"); err != nil {
+		t.Fatal(err)
+	}
+	inputs, err := lock.ReleaseInputs()
+	if err != nil {
+		t.Fatalf("failed to pin releases: %v", err)
+	}
+	if diff := cmp.Diff(map[string]string{"RELEASE_IMAGE_LATEST": "quay.io/openshift-release-dev/ocp-release:4.10.0-x86_64"}, inputs); diff != "" {
+		t.Errorf("unexpected inputs: %s", diff)
+	}
+	if _, ok := os.LookupEnv("RELEASE_IMAGE_LATEST"); ok {
+		t.Error("expected the pinned release not to be exposed in the environment of the process")
+	}
+	if err := os.Setenv("RELEASE_IMAGE_LATEST", "quay.io/openshift-release-dev/ocp-release:4.11.0-x86_64"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "release latest is pinned to quay.io/openshift-release-dev/ocp-release:4.10.0-x86_64, but RELEASE_IMAGE_LATEST is set to quay.io/openshift-release-dev/ocp-release:4.11.0-x86_64"
+	var actual string
+	if _, err := lock.ReleaseInputs(); err != nil {
+		actual = err.Error()
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("unexpected error: %s", diff)
+	}
+}
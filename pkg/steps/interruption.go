@@ -0,0 +1,126 @@
+package steps
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/telemetry"
+)
+
+// DefaultPostStepsGracePeriod is how long post steps keep running after an
+// interruption unless configured otherwise, enough to deprovision a cluster
+const DefaultPostStepsGracePeriod = 15 * time.Minute
+
+type interruptionKey struct{}
+
+type postStepsKey struct{}
+
+// Interruption coordinates the shutdown of an interrupted job: the graph is
+// cancelled right away, while the post steps of multi-stage tests, which
+// clean up and deprovision, keep running until the grace budget runs out
+type Interruption struct {
+	budget time.Duration
+
+	postCtx    context.Context
+	cancelPost context.CancelFunc
+
+	lock        sync.Mutex
+	interrupted bool
+	timer       *time.Timer
+	ran         []string
+	skipped     []string
+}
+
+// NewInterruption creates an interruption that grants post steps the budget
+// after the job is interrupted; without a budget, post steps are cancelled
+// along with the graph
+func NewInterruption(budget time.Duration) *Interruption {
+	i := &Interruption{budget: budget}
+	i.postCtx, i.cancelPost = context.WithCancel(context.WithValue(context.Background(), postStepsKey{}, i))
+	return i
+}
+
+// Context returns a context for the execution of the graph, through which
+// steps find the context to run post steps in
+func (i *Interruption) Context(ctx context.Context) context.Context {
+	return context.WithValue(ctx, interruptionKey{}, i)
+}
+
+// Interrupt starts the grace budget of post steps; the caller is expected
+// to cancel the context of the graph
+func (i *Interruption) Interrupt() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.interrupted {
+		return
+	}
+	i.interrupted = true
+	if i.budget <= 0 {
+		i.cancelPost()
+		return
+	}
+	i.timer = time.AfterFunc(i.budget, i.cancelPost)
+}
+
+// Stop releases the resources of the interruption
+func (i *Interruption) Stop() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.timer != nil {
+		i.timer.Stop()
+	}
+	i.cancelPost()
+}
+
+// Report returns the post steps that ran and the ones that were skipped
+// because the budget ran out, after the job was interrupted
+func (i *Interruption) Report() (interrupted bool, ran, skipped []string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.interrupted, append([]string{}, i.ran...), append([]string{}, i.skipped...)
+}
+
+func (i *Interruption) record(name string, ran bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if !i.interrupted {
+		return
+	}
+	if ran {
+		i.ran = append(i.ran, name)
+	} else {
+		i.skipped = append(i.skipped, name)
+	}
+}
+
+// postStepsContext returns the context post steps run in, which is not
+// cancelled with the graph but keeps its logger, scheduling timeout,
+// artifacts and usage
+func postStepsContext(ctx context.Context) context.Context {
+	postCtx := context.Background()
+	if i, ok := ctx.Value(interruptionKey{}).(*Interruption); ok {
+		postCtx = i.postCtx
+	}
+	if logger, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		postCtx = context.WithValue(postCtx, loggerKey{}, logger)
+	}
+	if timeout, ok := ctx.Value(schedulingTimeoutKey{}).(time.Duration); ok {
+		postCtx = WithSchedulingTimeout(postCtx, timeout)
+	}
+	if dir, set := api.ArtifactsFor(ctx); set {
+		postCtx = api.WithArtifacts(postCtx, dir)
+	}
+	postCtx = telemetry.CopyUsage(postCtx, ctx)
+	return postCtx
+}
+
+// recordPostStep records whether a post step ran after an interruption
+func recordPostStep(ctx context.Context, name string, ran bool) {
+	if i, ok := ctx.Value(postStepsKey{}).(*Interruption); ok {
+		i.record(name, ran)
+	}
+}
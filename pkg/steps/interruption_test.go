@@ -0,0 +1,58 @@
+package steps
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInterruption(t *testing.T) {
+	if ctx := postStepsContext(context.Background()); ctx != context.Background() {
+		t.Errorf("expected post steps to run in the background context without an interruption")
+	}
+
+	interruption := NewInterruption(50 * time.Millisecond)
+	defer interruption.Stop()
+	ctx, cancel := context.WithCancel(interruption.Context(context.Background()))
+	postCtx := postStepsContext(ctx)
+	recordPostStep(postCtx, "before", true)
+
+	interruption.Interrupt()
+	cancel()
+	if err := postCtx.Err(); err != nil {
+		t.Fatalf("post steps were cancelled with the graph: %v", err)
+	}
+	recordPostStep(postCtx, "gather", true)
+	select {
+	case <-postCtx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("post steps were not cancelled after the grace budget")
+	}
+	recordPostStep(postCtx, "deprovision", false)
+
+	interrupted, ran, skipped := interruption.Report()
+	if !interrupted {
+		t.Error("expected the interruption to be reported")
+	}
+	if diff := cmp.Diff([]string{"gather"}, ran); diff != "" {
+		t.Errorf("unexpected post steps that ran: %s", diff)
+	}
+	if diff := cmp.Diff([]string{"deprovision"}, skipped); diff != "" {
+		t.Errorf("unexpected skipped post steps: %s", diff)
+	}
+}
+
+func TestInterruptionWithoutGracePeriod(t *testing.T) {
+	interruption := NewInterruption(0)
+	defer interruption.Stop()
+	postCtx := postStepsContext(interruption.Context(context.Background()))
+	if err := postCtx.Err(); err != nil {
+		t.Fatalf("post steps were cancelled before the interruption: %v", err)
+	}
+	interruption.Interrupt()
+	if postCtx.Err() == nil {
+		t.Error("expected post steps to be cancelled right away without a grace period")
+	}
+}
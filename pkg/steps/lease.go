@@ -4,11 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"sort"
 	"strings"
+	"time"
 
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/openshift/ci-tools/pkg/api"
@@ -21,9 +22,24 @@ const DefaultLeaseEnv = "LEASED_RESOURCE"
 
 var NoLeaseClientErr = errors.New("step needs a lease but no lease client provided")
 
+// Allow tests to accelerate retries
+var (
+	// leaseAttemptTimeout bounds how long leases already acquired are held
+	// while waiting for the remaining ones, so that steps needing several
+	// kinds of resources do not keep others from those they already hold
+	leaseAttemptTimeout = 5 * time.Minute
+	// leaseBackoff spaces attempts to acquire all leases of a step
+	leaseBackoff = wait.Backoff{Duration: 30 * time.Second, Factor: 2, Jitter: 0.1, Steps: 6, Cap: 10 * time.Minute}
+)
+
 type stepLease struct {
 	api.StepLease
 	resources []string
+
+	// for reporting contention on the resource
+	waitStarted *time.Time
+	waited      time.Duration
+	failed      bool
 }
 
 // leaseStep wraps another step and acquires/releases one or more leases.
@@ -102,20 +118,47 @@ func (s *leaseStep) SubTests() []*junit.TestCase {
 	return nil
 }
 
+// SubSteps reports the time spent waiting for each lease, so that owners of
+// the resources can see how contended they are, in addition to the sub-steps
+// of the wrapped step
+func (s *leaseStep) SubSteps() []api.CIOperatorStepDetailInfo {
+	var ret []api.CIOperatorStepDetailInfo
+	for _, l := range s.leases {
+		if l.waitStarted == nil {
+			continue
+		}
+		waited := l.waited
+		finished := l.waitStarted.Add(waited)
+		failed := l.failed
+		ret = append(ret, api.CIOperatorStepDetailInfo{
+			StepName:    fmt.Sprintf("%s-lease-%s", s.Name(), l.ResourceType),
+			Description: fmt.Sprintf("Wait for %d lease(s) of %s", l.Count, l.ResourceType),
+			StartedAt:   l.waitStarted,
+			FinishedAt:  &finished,
+			Duration:    &waited,
+			Failed:      &failed,
+		})
+	}
+	if subSteps, ok := s.wrapped.(SubStepReporter); ok {
+		ret = append(ret, subSteps.SubSteps()...)
+	}
+	return ret
+}
+
 func (s *leaseStep) Run(ctx context.Context) error {
-	return results.ForReason("utilizing_lease").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonUtilizingLease).ForError(s.run(ctx))
 }
 
 func (s *leaseStep) run(ctx context.Context) error {
-	log.Printf("Acquiring leases for %q", s.Name())
+	Logger(ctx).Infof("Acquiring leases for %q", s.Name())
 	client := *s.client
 	ctx, cancel := context.WithCancel(ctx)
 	if err := acquireLeases(client, ctx, cancel, s.leases); err != nil {
 		return err
 	}
-	wrappedErr := results.ForReason("executing_test").ForError(s.wrapped.Run(ctx))
-	log.Printf("Releasing leases for %q", s.Name())
-	releaseErr := results.ForReason("releasing_lease").ForError(releaseLeases(client, s.leases))
+	wrappedErr := results.ForReason(results.ReasonExecutingTest).ForError(s.wrapped.Run(ctx))
+	Logger(ctx).Infof("Releasing leases for %q", s.Name())
+	releaseErr := results.ForReason(results.ReasonReleasingLease).ForError(releaseLeases(client, ctx, s.leases))
 
 	// we want a sensible output error for reporting, so we bubble up these individually
 	//if we can, as this is the only step that can have multiple errors
@@ -128,6 +171,10 @@ func (s *leaseStep) run(ctx context.Context) error {
 	}
 }
 
+// acquireLeases acquires all leases or none. Leases are acquired one kind
+// at a time, so when the resources of a later kind are not available, all
+// leases acquired so far are released and acquisition is retried with a
+// backoff instead of holding them indefinitely.
 func acquireLeases(
 	client lease.Client,
 	ctx context.Context,
@@ -140,39 +187,81 @@ func acquireLeases(
 		sorted = append(sorted, i)
 	}
 	sort.Slice(sorted, func(i, j int) bool {
-		return leases[i].ResourceType < leases[j].ResourceType
+		return leases[sorted[i]].ResourceType < leases[sorted[j]].ResourceType
 	})
-	var errs []error
-	for _, i := range sorted {
+	backoff := leaseBackoff
+	for {
+		partial, err := tryAcquireLeases(client, ctx, cancel, leases, sorted)
+		if err == nil {
+			return nil
+		}
+		errs := []error{err}
+		if err := releaseLeases(client, ctx, leases); err != nil {
+			errs = append(errs, fmt.Errorf("failed to release leases after acquisition failure: %w", err))
+			return utilerrors.NewAggregate(errs)
+		}
+		for i := range leases {
+			leases[i].resources = nil
+		}
+		if !partial || backoff.Steps < 1 {
+			return utilerrors.NewAggregate(errs)
+		}
+		delay := backoff.Step()
+		Logger(ctx).Infof("Could not acquire all leases for the step, released them and retrying in %s", delay.Round(time.Second))
+		select {
+		case <-ctx.Done():
+			return utilerrors.NewAggregate(append(errs, ctx.Err()))
+		case <-time.After(delay):
+		}
+	}
+}
+
+// tryAcquireLeases makes one attempt at acquiring all leases, returning
+// whether it failed after some of them were acquired
+func tryAcquireLeases(
+	client lease.Client,
+	ctx context.Context,
+	cancel context.CancelFunc,
+	leases []stepLease,
+	sorted []int,
+) (bool, error) {
+	for n, i := range sorted {
 		l := &leases[i]
-		log.Printf("Acquiring %d lease(s) for %q", l.Count, l.ResourceType)
-		names, err := client.Acquire(l.ResourceType, l.Count, ctx, cancel)
+		acquireCtx := ctx
+		if n > 0 {
+			var cancelAttempt context.CancelFunc
+			acquireCtx, cancelAttempt = context.WithTimeout(ctx, leaseAttemptTimeout)
+			defer cancelAttempt()
+		}
+		Logger(ctx).Infof("Acquiring %d lease(s) for %q", l.Count, l.ResourceType)
+		start := time.Now()
+		if l.waitStarted == nil {
+			l.waitStarted = &start
+		}
+		names, err := client.Acquire(l.ResourceType, l.Count, acquireCtx, cancel)
+		l.waited += time.Since(start)
+		l.failed = err != nil
 		if err != nil {
 			if err == lease.ErrNotFound {
-				printResourceMetrics(client, l.ResourceType)
+				printResourceMetrics(client, ctx, l.ResourceType)
 			}
-			errs = append(errs, results.ForReason(results.Reason("acquiring_lease:"+l.ResourceType)).WithError(err).Errorf("failed to acquire lease: %v", err))
-			break
+			partial := n > 0 && err == lease.ErrNotFound && ctx.Err() == nil
+			return partial, results.ForReason(results.Reason(fmt.Sprintf("%s:%s", results.ReasonAcquiringLease, l.ResourceType))).WithError(err).Errorf("failed to acquire lease: %v", err)
 		}
-		log.Printf("Acquired lease(s) for %q: %v", l.ResourceType, names)
+		Logger(ctx).Infof("Acquired lease(s) for %q after %s: %v", l.ResourceType, time.Since(start).Round(time.Second), names)
 		l.resources = names
 	}
-	if errs != nil {
-		if err := releaseLeases(client, leases); err != nil {
-			errs = append(errs, fmt.Errorf("failed to release leases after acquisition failure: %w", err))
-		}
-	}
-	return utilerrors.NewAggregate(errs)
+	return false, nil
 }
 
-func releaseLeases(client lease.Client, leases []stepLease) error {
+func releaseLeases(client lease.Client, ctx context.Context, leases []stepLease) error {
 	var errs []error
 	for _, l := range leases {
 		for _, r := range l.resources {
 			if r == "" {
 				continue
 			}
-			log.Printf("Releasing lease for %q: %v", l.ResourceType, r)
+			Logger(ctx).Infof("Releasing lease for %q: %v", l.ResourceType, r)
 			if err := client.Release(r); err != nil {
 				errs = append(errs, err)
 			}
@@ -181,11 +270,11 @@ func releaseLeases(client lease.Client, leases []stepLease) error {
 	return utilerrors.NewAggregate(errs)
 }
 
-func printResourceMetrics(client lease.Client, rtype string) {
+func printResourceMetrics(client lease.Client, ctx context.Context, rtype string) {
 	m, err := client.Metrics(rtype)
 	if err != nil {
-		log.Printf("warning: Could not get resource metrics: %v", err)
+		Logger(ctx).Warnf("Could not get resource metrics: %v", err)
 		return
 	}
-	log.Printf("error: Failed to acquire resource, current capacity: %d free, %d leased", m.Free, m.Leased)
+	Logger(ctx).Errorf("Failed to acquire resource, current capacity: %d free, %d leased", m.Free, m.Leased)
 }
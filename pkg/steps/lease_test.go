@@ -224,3 +224,122 @@ func TestAcquireRelease(t *testing.T) {
 		t.Fatalf("wrong calls to the lease client: %s", diff.ObjectDiff(calls, expected))
 	}
 }
+
+// contendedLeaseClient fails to acquire resources of a type a number of times
+// before succeeding
+type contendedLeaseClient struct {
+	lease.Client
+	unavailable map[string]int
+	calls       []string
+}
+
+func (c *contendedLeaseClient) Acquire(rtype string, n uint, ctx context.Context, cancel context.CancelFunc) ([]string, error) {
+	c.calls = append(c.calls, "acquire "+rtype)
+	if c.unavailable[rtype] > 0 {
+		c.unavailable[rtype]--
+		return nil, lease.ErrNotFound
+	}
+	return []string{rtype + "--01"}, nil
+}
+
+func (c *contendedLeaseClient) Release(name string) error {
+	c.calls = append(c.calls, "release "+name)
+	return nil
+}
+
+func (c *contendedLeaseClient) Metrics(string) (lease.Metrics, error) {
+	return lease.Metrics{}, nil
+}
+
+func TestAcquireAllOrNothing(t *testing.T) {
+	backoff := leaseBackoff
+	leaseBackoff.Duration, leaseBackoff.Steps = 0, 2
+	defer func() { leaseBackoff = backoff }()
+	leases := []api.StepLease{
+		{ResourceType: "rtype1", Env: "RTYPE1", Count: 1},
+		{ResourceType: "rtype0", Env: "RTYPE0", Count: 1},
+	}
+	for _, tc := range []struct {
+		name          string
+		unavailable   map[string]int
+		expectedErr   bool
+		expectedCalls []string
+		expectedEnv   map[string]string
+		expectedWaits []string
+	}{{
+		name:        "first kind of resource is never retried",
+		unavailable: map[string]int{"rtype0": 1},
+		expectedErr: true,
+		expectedCalls: []string{
+			"acquire rtype0",
+		},
+		expectedWaits: []string{"needs_lease-lease-rtype0"},
+	}, {
+		name:        "leases are released and acquired again when the second kind is not available",
+		unavailable: map[string]int{"rtype1": 2},
+		expectedCalls: []string{
+			"acquire rtype0",
+			"acquire rtype1",
+			"release rtype0--01",
+			"acquire rtype0",
+			"acquire rtype1",
+			"release rtype0--01",
+			"acquire rtype0",
+			"acquire rtype1",
+			"release rtype1--01",
+			"release rtype0--01",
+		},
+		expectedEnv:   map[string]string{"RTYPE0": "rtype0", "RTYPE1": "rtype1"},
+		expectedWaits: []string{"needs_lease-lease-rtype1", "needs_lease-lease-rtype0"},
+	}, {
+		name:        "acquisition fails when retries are exhausted",
+		unavailable: map[string]int{"rtype1": 3},
+		expectedErr: true,
+		expectedCalls: []string{
+			"acquire rtype0",
+			"acquire rtype1",
+			"release rtype0--01",
+			"acquire rtype0",
+			"acquire rtype1",
+			"release rtype0--01",
+			"acquire rtype0",
+			"acquire rtype1",
+			"release rtype0--01",
+		},
+		expectedWaits: []string{"needs_lease-lease-rtype1", "needs_lease-lease-rtype0"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &contendedLeaseClient{unavailable: tc.unavailable}
+			var leaseClient lease.Client = client
+			withLease := LeaseStep(&leaseClient, leases, &stepNeedsLease{}, emptyNamespace)
+			err := withLease.Run(context.Background())
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error: %v, got: %v", tc.expectedErr, err)
+			}
+			if !reflect.DeepEqual(client.calls, tc.expectedCalls) {
+				t.Errorf("wrong calls to the lease client: %s", diff.ObjectDiff(client.calls, tc.expectedCalls))
+			}
+			if tc.expectedEnv != nil {
+				env := map[string]string{}
+				for name, value := range withLease.Provides() {
+					if name == "parameter" {
+						continue
+					}
+					if env[name], err = value(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if !reflect.DeepEqual(env, tc.expectedEnv) {
+					t.Errorf("wrong parameters: %s", diff.ObjectDiff(env, tc.expectedEnv))
+				}
+			}
+			var waits []string
+			for _, subStep := range withLease.(SubStepReporter).SubSteps() {
+				waits = append(waits, subStep.StepName)
+			}
+			if !reflect.DeepEqual(waits, tc.expectedWaits) {
+				t.Errorf("wrong lease waits reported: %s", diff.ObjectDiff(waits, tc.expectedWaits))
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package steps
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/junit"
+)
+
+const (
+	// LocalArtifactMountPath is where the artifact directory of a step is
+	// mounted when running locally
+	LocalArtifactMountPath = "/tmp/artifacts"
+)
+
+// LocalContainer describes a container to run on the developer's machine
+type LocalContainer struct {
+	// Name is the name of the container
+	Name string
+	// Image is the local image ID to run
+	Image string
+	// Command is the command to execute in the container
+	Command []string
+	// Env holds the environment for the container
+	Env map[string]string
+	// Mounts maps directories on the host to paths in the container
+	Mounts map[string]string
+}
+
+// LocalRuntime runs containers on the developer's machine
+type LocalRuntime interface {
+	// Pull pulls the image and returns the ID of the local copy
+	Pull(ctx context.Context, pullSpec string) (string, error)
+	// Run runs the container to completion
+	Run(ctx context.Context, container LocalContainer) error
+}
+
+type cliRuntime struct {
+	binary string
+}
+
+// NewLocalRuntime returns a runtime using a docker-compatible CLI, like
+// `podman` or `docker`
+func NewLocalRuntime(binary string) LocalRuntime {
+	return &cliRuntime{binary: binary}
+}
+
+func (r *cliRuntime) Pull(ctx context.Context, pullSpec string) (string, error) {
+	pull := exec.CommandContext(ctx, r.binary, "pull", pullSpec)
+	pull.Stderr = os.Stderr
+	if err := pull.Run(); err != nil {
+		return "", fmt.Errorf("could not pull %s: %w", pullSpec, err)
+	}
+	out, err := exec.CommandContext(ctx, r.binary, "image", "inspect", "--format", "{{.Id}}", pullSpec).Output()
+	if err != nil {
+		return "", fmt.Errorf("could not inspect %s: %w", pullSpec, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (r *cliRuntime) Run(ctx context.Context, container LocalContainer) error {
+	args := []string{"run", "--rm", "--name", container.Name}
+	var env []string
+	for name, value := range container.Env {
+		env = append(env, fmt.Sprintf("%s=%s", name, value))
+	}
+	sort.Strings(env)
+	for _, e := range env {
+		args = append(args, "--env", e)
+	}
+	var mounts []string
+	for host, path := range container.Mounts {
+		mounts = append(mounts, fmt.Sprintf("%s:%s:z", host, path))
+	}
+	sort.Strings(mounts)
+	for _, m := range mounts {
+		args = append(args, "--volume", m)
+	}
+	args = append(args, container.Image)
+	args = append(args, container.Command...)
+	cmd := exec.CommandContext(ctx, r.binary, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// LocalTestRunner executes multi-stage tests on the developer's machine
+// instead of in a namespace on the build farm. Steps share a directory on
+// the host which is exposed to them as $SHARED_DIR.
+type LocalTestRunner struct {
+	config  *api.ReleaseBuildConfiguration
+	runtime LocalRuntime
+	// images maps pipeline image names to pull specs
+	images map[string]string
+	// pulled caches local image IDs by pull spec
+	pulled  map[string]string
+	workDir string
+}
+
+// NewLocalTestRunner creates a runner which keeps shared and artifact
+// directories under workDir. Pipeline images which the job would build are
+// not available locally, so they must be provided in images.
+func NewLocalTestRunner(config *api.ReleaseBuildConfiguration, runtime LocalRuntime, images map[string]string, workDir string) *LocalTestRunner {
+	return &LocalTestRunner{
+		config:  config,
+		runtime: runtime,
+		images:  images,
+		pulled:  map[string]string{},
+		workDir: workDir,
+	}
+}
+
+// pullSpecFor determines which image to pull for a step
+func (r *LocalTestRunner) pullSpecFor(step api.LiteralTestStep) (string, error) {
+	if step.FromImage != nil {
+		name := fmt.Sprintf("%s/%s:%s", step.FromImage.Namespace, step.FromImage.Name, step.FromImage.Tag)
+		if pullSpec, ok := r.images[name]; ok {
+			return pullSpec, nil
+		}
+		return fmt.Sprintf("%s/%s", api.ServiceDomainAPPCIRegistry, name), nil
+	}
+	if pullSpec, ok := r.images[step.From]; ok {
+		return pullSpec, nil
+	}
+	if base, ok := r.config.BaseImages[step.From]; ok {
+		return fmt.Sprintf("%s/%s/%s:%s", api.ServiceDomainAPPCIRegistry, base.Namespace, base.Name, base.Tag), nil
+	}
+	return "", fmt.Errorf("step %s runs in image %q which is not available locally, provide a pull spec for it with --local-image %s=PULLSPEC", step.As, step.From, step.From)
+}
+
+// imageFor resolves the image for a step to the ID of a locally pulled copy,
+// so that every step using the same reference runs the same digest
+func (r *LocalTestRunner) imageFor(ctx context.Context, step api.LiteralTestStep) (string, error) {
+	pullSpec, err := r.pullSpecFor(step)
+	if err != nil {
+		return "", err
+	}
+	if id, ok := r.pulled[pullSpec]; ok {
+		return id, nil
+	}
+	Logger(ctx).Infof("Pulling %s for step %s", pullSpec, step.As)
+	id, err := r.runtime.Pull(ctx, pullSpec)
+	if err != nil {
+		return "", err
+	}
+	r.pulled[pullSpec] = id
+	return id, nil
+}
+
+// Run executes the test. Like on the build farm, `post` steps run even when
+// `pre` or `test` steps fail.
+func (r *LocalTestRunner) Run(ctx context.Context, test api.TestStepConfiguration) error {
+	literal := test.MultiStageTestConfigurationLiteral
+	if literal == nil {
+		return fmt.Errorf("test %s is not a multi-stage test, only multi-stage tests can be run locally", test.As)
+	}
+	sharedDir := filepath.Join(r.workDir, test.As, "shared")
+	if err := os.MkdirAll(sharedDir, 0755); err != nil {
+		return fmt.Errorf("could not create shared directory: %w", err)
+	}
+	sealedDir := filepath.Join(r.workDir, test.As, "sealed")
+	if err := os.MkdirAll(sealedDir, 0700); err != nil {
+		return fmt.Errorf("could not create sealed directory: %w", err)
+	}
+	var dataDir string
+	if literal.DataDir != nil {
+		dataDir = filepath.Join(r.workDir, test.As, "data")
+		if err := os.MkdirAll(dataDir, 0755); err != nil {
+			return fmt.Errorf("could not create data directory: %w", err)
+		}
+	}
+	var errs []error
+	runPhase := func(steps []api.LiteralTestStep) {
+		for _, step := range steps {
+			if len(errs) > 0 {
+				return
+			}
+			if err := r.runStep(ctx, test.As, literal.Environment, sharedDir, sealedDir, dataDir, step); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	runPhase(literal.Pre)
+	runPhase(literal.Test)
+	for _, step := range literal.Post {
+		if err := r.runStep(ctx, test.As, literal.Environment, sharedDir, sealedDir, dataDir, step); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := r.aggregateJUnit(test.As); err != nil {
+		Logger(ctx).Warnf("Could not aggregate the jUnit results of the steps of %s: %v", test.As, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// aggregateJUnit merges the jUnit results of all steps of the test into a
+// single file in the artifact directory of the test
+func (r *LocalTestRunner) aggregateJUnit(testName string) error {
+	dir := filepath.Join(r.workDir, testName, "artifacts")
+	results, err := junit.CollectStepResults(dir)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	suites := &junit.TestSuites{Suites: []*junit.TestSuite{junit.Aggregate(testName, results)}}
+	out, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal jUnit XML: %w", err)
+	}
+	return ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("junit_%s.xml", testName)), out, 0640)
+}
+
+func (r *LocalTestRunner) runStep(ctx context.Context, testName string, testEnv api.TestEnvironment, sharedDir, sealedDir, dataDir string, step api.LiteralTestStep) error {
+	name := fmt.Sprintf("%s-%s", testName, step.As)
+	if step.Upgrade != nil {
+		return fmt.Errorf("step %s upgrades the cluster of the test, which is not supported locally", step.As)
+	}
+	image, err := r.imageFor(ctx, step)
+	if err != nil {
+		return err
+	}
+	artifactDir := filepath.Join(r.workDir, testName, "artifacts", step.As)
+	if err := os.MkdirAll(artifactDir, 0755); err != nil {
+		return fmt.Errorf("could not create artifact directory for step %s: %w", name, err)
+	}
+	home, err := ioutil.TempDir(r.workDir, name+"-home-")
+	if err != nil {
+		return fmt.Errorf("could not create home directory for step %s: %w", name, err)
+	}
+	defer os.RemoveAll(home)
+	env := map[string]string{
+		"JOB_NAME_SAFE":   strings.Replace(testName, "_", "-", -1),
+		SecretMountEnv:    SecretMountPath,
+		SealedMountEnv:    SealedMountPath,
+		"ARTIFACT_DIR":    LocalArtifactMountPath,
+		"HOME":            "/alabama",
+		"CI":              "true",
+		"OPENSHIFT_CI":    "true",
+		"LOCAL_EXECUTION": "true",
+	}
+	for _, parameter := range step.Environment {
+		value := ""
+		if parameter.Default != nil {
+			value = *parameter.Default
+		}
+		if v, ok := testEnv[parameter.Name]; ok {
+			value = v
+		}
+		env[parameter.Name] = value
+	}
+	mounts := map[string]string{
+		sharedDir:   SecretMountPath,
+		sealedDir:   SealedMountPath,
+		artifactDir: LocalArtifactMountPath,
+		home:        "/alabama",
+	}
+	if dataDir != "" {
+		mounts[dataDir] = DataDirMountPath
+		env[DataDirMountEnv] = DataDirMountPath
+	}
+	Logger(ctx).Infof("Running step %s locally", name)
+	if err := r.runtime.Run(ctx, LocalContainer{
+		Name:    name,
+		Image:   image,
+		Command: []string{"/bin/bash", "-c", CommandPrefix + step.Commands},
+		Env:     env,
+		Mounts:  mounts,
+	}); err != nil {
+		return fmt.Errorf("step %s failed: %w", name, err)
+	}
+	Logger(ctx).Infof("Step %s succeeded", name)
+	return nil
+}
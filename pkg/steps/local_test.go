@@ -0,0 +1,121 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+type fakeLocalRuntime struct {
+	pulled []string
+	ran    []LocalContainer
+	fail   string
+}
+
+func (r *fakeLocalRuntime) Pull(_ context.Context, pullSpec string) (string, error) {
+	r.pulled = append(r.pulled, pullSpec)
+	return fmt.Sprintf("id-%d", len(r.pulled)), nil
+}
+
+func (r *fakeLocalRuntime) Run(_ context.Context, container LocalContainer) error {
+	r.ran = append(r.ran, container)
+	if container.Name == r.fail {
+		return errors.New("oops")
+	}
+	return nil
+}
+
+func TestLocalTestRunner(t *testing.T) {
+	defaultValue := "default"
+	config := &api.ReleaseBuildConfiguration{
+		InputConfiguration: api.InputConfiguration{
+			BaseImages: map[string]api.ImageStreamTagReference{
+				"cli": {Namespace: "ocp", Name: "4.7", Tag: "cli"},
+			},
+		},
+	}
+	test := api.TestStepConfiguration{
+		As: "e2e",
+		MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+			Pre:         []api.LiteralTestStep{{As: "setup", From: "cli"}},
+			Test:        []api.LiteralTestStep{{As: "test", From: "src", Environment: []api.StepParameter{{Name: "A", Default: &defaultValue}, {Name: "B", Default: &defaultValue}}}},
+			Post:        []api.LiteralTestStep{{As: "teardown", FromImage: &api.ImageStreamTagReference{Namespace: "ci", Name: "tools", Tag: "latest"}}},
+			Environment: api.TestEnvironment{"B": "override"},
+		},
+	}
+	for _, tc := range []struct {
+		name        string
+		images      map[string]string
+		fail        string
+		expectedRan []string
+		expectedErr bool
+	}{
+		{
+			name:        "image built by the job is not available",
+			expectedRan: []string{"e2e-setup", "e2e-teardown"},
+			expectedErr: true,
+		},
+		{
+			name:        "all steps run",
+			images:      map[string]string{"src": "quay.io/me/src:dev"},
+			expectedRan: []string{"e2e-setup", "e2e-test", "e2e-teardown"},
+		},
+		{
+			name:        "post steps run after a failure",
+			images:      map[string]string{"src": "quay.io/me/src:dev"},
+			fail:        "e2e-setup",
+			expectedRan: []string{"e2e-setup", "e2e-teardown"},
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := &fakeLocalRuntime{fail: tc.fail}
+			err := NewLocalTestRunner(config, runtime, tc.images, t.TempDir()).Run(context.Background(), test)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error: %t, got: %v", tc.expectedErr, err)
+			}
+			var ran []string
+			for _, container := range runtime.ran {
+				ran = append(ran, container.Name)
+				if container.Name != "e2e-test" {
+					continue
+				}
+				if diff := cmp.Diff("override", container.Env["B"]); diff != "" {
+					t.Errorf("test environment was not applied: %s", diff)
+				}
+				if diff := cmp.Diff("default", container.Env["A"]); diff != "" {
+					t.Errorf("default was not applied: %s", diff)
+				}
+			}
+			if diff := cmp.Diff(tc.expectedRan, ran); diff != "" {
+				t.Errorf("unexpected steps ran: %s", diff)
+			}
+		})
+	}
+}
+
+func TestLocalTestRunnerPullsImagesOnce(t *testing.T) {
+	runtime := &fakeLocalRuntime{}
+	test := api.TestStepConfiguration{
+		As: "e2e",
+		MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+			Test: []api.LiteralTestStep{{As: "a", From: "src"}, {As: "b", From: "src"}},
+		},
+	}
+	if err := NewLocalTestRunner(&api.ReleaseBuildConfiguration{}, runtime, map[string]string{"src": "quay.io/me/src:dev"}, t.TempDir()).Run(context.Background(), test); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"quay.io/me/src:dev"}, runtime.pulled); diff != "" {
+		t.Errorf("unexpected pulls: %s", diff)
+	}
+	for _, container := range runtime.ran {
+		if container.Image != "id-1" {
+			t.Errorf("step %s did not run the locally pulled image: %s", container.Name, container.Image)
+		}
+	}
+}
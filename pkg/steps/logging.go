@@ -0,0 +1,99 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type loggerKey struct{}
+
+// correlationFields are attached to every line logged through the logger of
+// a context and are only written to the machine-readable log
+var correlationFields = sets.NewString("step", "build", "namespace")
+
+// WithLogFields returns a context whose logger attaches the fields to every
+// line, in addition to the fields of the logger of the parent context
+func WithLogFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return context.WithValue(ctx, loggerKey{}, Logger(ctx).WithFields(fields))
+}
+
+// WithLogger returns a context whose steps log to the logger instead of the
+// standard one, so that jobs executed by the same process keep their logs
+// apart
+func WithLogger(ctx context.Context, logger *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the logger for the context, which attaches the step, the
+// build and the namespace the context belongs to to every line
+func Logger(ctx context.Context) *logrus.Entry {
+	if logger, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return logger
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// HumanFormatter formats log lines like the standard library logger does,
+// leaving out the correlation fields, and prefixes warnings and errors with
+// their level
+type HumanFormatter struct{}
+
+func (HumanFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	var prefix string
+	switch entry.Level {
+	case logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel:
+		prefix = "error: "
+	case logrus.WarnLevel:
+		prefix = "warning: "
+	}
+	line := bytes.NewBufferString(fmt.Sprintf("%s %s%s", entry.Time.Format("2006/01/02 15:04:05"), prefix, strings.TrimRight(entry.Message, "\n")))
+	var keys []string
+	for key := range entry.Data {
+		if !correlationFields.Has(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(line, " %s=%v", key, entry.Data[key])
+	}
+	line.WriteByte('\n')
+	return line.Bytes(), nil
+}
+
+// JSONLogHook writes every log line with its fields as JSON, producing a
+// machine-readable stream next to the human-readable log
+type JSONLogHook struct {
+	lock      sync.Mutex
+	out       io.Writer
+	formatter logrus.Formatter
+}
+
+// NewJSONLogHook creates a hook that writes JSON lines to the writer
+func NewJSONLogHook(out io.Writer) *JSONLogHook {
+	return &JSONLogHook{out: out, formatter: &logrus.JSONFormatter{}}
+}
+
+func (h *JSONLogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *JSONLogHook) Fire(entry *logrus.Entry) error {
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	_, err = h.out.Write(line)
+	return err
+}
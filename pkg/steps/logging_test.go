@@ -0,0 +1,56 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+)
+
+func TestLogger(t *testing.T) {
+	human, machine := &bytes.Buffer{}, &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(human)
+	logger.SetFormatter(HumanFormatter{})
+	logger.AddHook(NewJSONLogHook(machine))
+
+	ctx := context.WithValue(context.Background(), loggerKey{}, logrus.NewEntry(logger))
+	ctx = WithLogFields(ctx, logrus.Fields{"namespace": "ci-op-1234"})
+	ctx = WithLogFields(ctx, logrus.Fields{"step": "src"})
+	ctx = WithLogFields(ctx, logrus.Fields{"build": "src-amd64"})
+	when := time.Date(2021, 11, 3, 10, 30, 0, 0, time.UTC)
+	Logger(ctx).WithTime(when).Infof("Building %s", "src")
+	Logger(ctx).WithTime(when).WithField("attempt", 2).Warn("Build failed\n")
+	Logger(postStepsContext(ctx)).WithTime(when).Error("Deprovisioning cluster")
+
+	expectedHuman := `2021/11/03 10:30:00 Building src
+2021/11/03 10:30:00 warning: Build failed attempt=2
+2021/11/03 10:30:00 error: Deprovisioning cluster
+`
+	if diff := cmp.Diff(expectedHuman, human.String()); diff != "" {
+		t.Errorf("unexpected human log: %s", diff)
+	}
+
+	var lines []map[string]interface{}
+	decoder := json.NewDecoder(machine)
+	for decoder.More() {
+		var line map[string]interface{}
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatalf("failed to decode JSON log: %v", err)
+		}
+		delete(line, "time")
+		lines = append(lines, line)
+	}
+	expectedMachine := []map[string]interface{}{
+		{"level": "info", "msg": "Building src", "namespace": "ci-op-1234", "step": "src", "build": "src-amd64"},
+		{"level": "warning", "msg": "Build failed\n", "namespace": "ci-op-1234", "step": "src", "build": "src-amd64", "attempt": float64(2)},
+		{"level": "error", "msg": "Deprovisioning cluster", "namespace": "ci-op-1234", "step": "src", "build": "src-amd64"},
+	}
+	if diff := cmp.Diff(expectedMachine, lines); diff != "" {
+		t.Errorf("unexpected JSON log: %s", diff)
+	}
+}
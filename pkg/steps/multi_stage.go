@@ -2,20 +2,24 @@ package steps
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
-	"log"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	coreapi "k8s.io/api/core/v1"
 	rbacapi "k8s.io/api/rbac/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/entrypoint"
+	"k8s.io/test-infra/prow/pod-utils/decorate"
 	utilpointer "k8s.io/utils/pointer"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
@@ -24,7 +28,9 @@ import (
 	"github.com/openshift/ci-tools/pkg/junit"
 	"github.com/openshift/ci-tools/pkg/results"
 	"github.com/openshift/ci-tools/pkg/steps/utils"
+	"github.com/openshift/ci-tools/pkg/telemetry"
 	"github.com/openshift/ci-tools/pkg/util"
+	"github.com/openshift/ci-tools/pkg/vaultclient"
 )
 
 const (
@@ -36,6 +42,29 @@ const (
 	SecretMountPath = "/var/run/secrets/ci.openshift.io/multi-stage"
 	// SecretMountEnv is the env we use to expose the shared dir
 	SecretMountEnv = "SHARED_DIR"
+	// WorkspaceMountPath is where we mount a step's workspace volume by default
+	WorkspaceMountPath = "/workspace"
+	// WorkspaceMountEnv is the env we use to expose the workspace dir
+	WorkspaceMountEnv = "WORKSPACE_DIR"
+	// SharedDirScratchPath is where steps keep the writable copy of the
+	// shared dir when its volume is configured
+	SharedDirScratchPath = "/var/run/ci.openshift.io/shared-dir"
+	// DataDirMountPath is where we mount the data dir volume of a test
+	DataDirMountPath = "/var/run/ci.openshift.io/data"
+	// DataDirMountEnv is the env we use to expose the data dir
+	DataDirMountEnv = "DATA_DIR"
+	// SealedMountPath is where we mount the sealed dir secret
+	SealedMountPath = "/var/run/secrets/ci.openshift.io/sealed"
+	// SealedMountEnv is the env we use to expose the sealed dir, which is
+	// shared between steps like the shared dir but is never exposed in
+	// artifacts and has its contents censored from the output of steps
+	SealedMountEnv = "SEALED_DIR"
+	// SealedKeyEnv is the env we use to pass the key the sealed dir secret
+	// is encrypted with to the entrypoint wrapper, which does not pass it on
+	// to the command of the step
+	SealedKeyEnv = "SEALED_KEY"
+	// sealedKeySecretKey is the key of the secret holding the sealing key
+	sealedKeySecretKey = "key"
 	// ClusterProfileMountEnv is the env we use to expose the cluster profile dir
 	ClusterProfileMountEnv = "CLUSTER_PROFILE_DIR"
 	// CliMountPath is where we mount the cli in a pod
@@ -61,11 +90,27 @@ type multiStageTestStep struct {
 	client                   PodClient
 	jobSpec                  *api.JobSpec
 	pre, test, post          []api.LiteralTestStep
+	observers                []api.Observer
 	subTests                 []*junit.TestCase
 	subSteps                 []api.CIOperatorStepDetailInfo
+	resourceUsage            map[string]map[string]api.ContainerResourceUsage
 	allowSkipOnSuccess       *bool
 	allowBestEffortPostSteps *bool
 	leases                   []api.StepLease
+	byoCluster               *BYOClusterConfig
+	vault                    VaultClient
+	sharedDir                *api.SharedDirConfiguration
+	dataDir                  *api.DataDirConfiguration
+	clusterProvisioning      *api.ClusterProvisioningConfiguration
+	clusterClaim             *api.ClusterClaimConfiguration
+	profileOverlay           *api.ClusterProfileOverlay
+	// workloadIdentity is assumed by steps instead of using long-lived
+	// credentials from the cluster profile, if the profile configures it
+	workloadIdentity *WorkloadIdentity
+	// dependencyOverrides replace the images of dependencies of steps
+	dependencyOverrides DependencyOverrides
+	// disruptionRetries limits how often disrupted pods are re-created
+	disruptionRetries *int
 }
 
 func MultiStageTestStep(
@@ -75,8 +120,11 @@ func MultiStageTestStep(
 	client PodClient,
 	jobSpec *api.JobSpec,
 	leases []api.StepLease,
+	byoCluster *BYOClusterConfig,
+	vault VaultClient,
+	dependencyOverrides DependencyOverrides,
 ) api.Step {
-	return newMultiStageTestStep(testConfig, config, params, client, jobSpec, leases)
+	return newMultiStageTestStep(testConfig, config, params, client, jobSpec, leases, byoCluster, vault, dependencyOverrides)
 }
 
 func newMultiStageTestStep(
@@ -86,6 +134,9 @@ func newMultiStageTestStep(
 	client PodClient,
 	jobSpec *api.JobSpec,
 	leases []api.StepLease,
+	byoCluster *BYOClusterConfig,
+	vault VaultClient,
+	dependencyOverrides DependencyOverrides,
 ) *multiStageTestStep {
 	ms := testConfig.MultiStageTestConfigurationLiteral
 	return &multiStageTestStep{
@@ -99,9 +150,19 @@ func newMultiStageTestStep(
 		pre:                      ms.Pre,
 		test:                     ms.Test,
 		post:                     ms.Post,
+		observers:                ms.Observers,
 		allowSkipOnSuccess:       ms.AllowSkipOnSuccess,
 		allowBestEffortPostSteps: ms.AllowBestEffortPostSteps,
 		leases:                   leases,
+		byoCluster:               byoCluster,
+		vault:                    vault,
+		sharedDir:                ms.SharedDir,
+		dataDir:                  ms.DataDir,
+		clusterProvisioning:      ms.ClusterProvisioning,
+		clusterClaim:             ms.ClusterClaim,
+		profileOverlay:           ms.ClusterProfileOverlay,
+		dependencyOverrides:      dependencyOverrides,
+		disruptionRetries:        ms.DisruptionRetries,
 	}
 }
 
@@ -109,6 +170,31 @@ func (s *multiStageTestStep) profileSecretName() string {
 	return s.name + "-cluster-profile"
 }
 
+func (s *multiStageTestStep) profileOverlaySecretName() string {
+	return s.name + "-cluster-profile-overlay"
+}
+
+// mountedProfileSecretName is the secret mounted as the cluster profile,
+// which holds the overlay of the test on top of the profile if it has one
+func (s *multiStageTestStep) mountedProfileSecretName() string {
+	if s.profileOverlay != nil {
+		return s.profileOverlaySecretName()
+	}
+	return s.profileSecretName()
+}
+
+func (s *multiStageTestStep) sealedSecretName() string {
+	return s.name + "-sealed"
+}
+
+func (s *multiStageTestStep) sealedKeySecretName() string {
+	return s.name + "-sealed-key"
+}
+
+func (s *multiStageTestStep) parametersSecretName() string {
+	return s.name + "-parameters"
+}
+
 func (s *multiStageTestStep) Inputs() (api.InputDefinition, error) {
 	return nil, nil
 }
@@ -116,7 +202,7 @@ func (s *multiStageTestStep) Inputs() (api.InputDefinition, error) {
 func (*multiStageTestStep) Validate() error { return nil }
 
 func (s *multiStageTestStep) Run(ctx context.Context) error {
-	return results.ForReason("executing_multi_stage_test").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonExecutingMultiStageTest).ForError(s.run(ctx))
 }
 
 func (s *multiStageTestStep) run(ctx context.Context) error {
@@ -124,23 +210,114 @@ func (s *multiStageTestStep) run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	if err := s.createSecret(ctx); err != nil {
+	if s.profile != "" && s.profileOverlay != nil {
+		if err := s.createProfileOverlay(ctx); err != nil {
+			return fmt.Errorf("failed to create cluster profile overlay: %w", err)
+		}
+	}
+	pre, post := s.pre, s.post
+	var sharedData map[string][]byte
+	if s.byoCluster != nil {
+		kubeconfig, allowDestructive, err := s.byoCluster.resolve(ctx, s.client)
+		if err != nil {
+			return err
+		}
+		sharedData = map[string][]byte{BYOClusterKubeconfigKey: kubeconfig}
+		Logger(ctx).Infof("Targeting user-supplied cluster from secret %s, skipping %d pre step(s)", s.byoCluster, len(pre))
+		pre = nil
+		if !allowDestructive {
+			Logger(ctx).Infof("Skipping %d post step(s), label the secret with %s=true to run them", len(post), BYOClusterAllowDestructiveLabel)
+			post = nil
+		}
+	} else if s.clusterProvisioning != nil {
+		if err := s.checkClusterQuota(ctx); err != nil {
+			return results.ForReason(results.ReasonProvisioningCluster).ForError(fmt.Errorf("failed to provision cluster: %w", err))
+		}
+		provisioned := time.Now()
+		// the cluster is torn down after the post steps, when we return
+		defer func() {
+			Logger(ctx).Infof("Deprovisioning cluster %s for %s", s.clusterName(), s.name)
+			if err := s.deprovisionCluster(postStepsContext(ctx)); err != nil {
+				Logger(ctx).Infof("failed to deprovision the cluster of %s: %v", s.name, err)
+			}
+			s.reportClusterUsage(ctx, provisioned, time.Now())
+		}()
+		if sharedData, err = s.provisionCluster(ctx); err != nil {
+			return results.ForReason(results.ReasonProvisioningCluster).ForError(fmt.Errorf("failed to provision cluster: %w", err))
+		}
+	} else if s.clusterClaim != nil {
+		claim, data, err := s.claimCluster(ctx)
+		if err != nil {
+			return results.ForReason(results.ReasonProvisioningCluster).ForError(fmt.Errorf("failed to claim cluster: %w", err))
+		}
+		// the cluster goes back to Hive after the post steps, when we return
+		defer func() {
+			Logger(ctx).Infof("Releasing cluster claimed by %s", s.name)
+			if err := s.releaseCluster(postStepsContext(ctx), claim); err != nil {
+				Logger(ctx).Infof("failed to release the cluster of %s: %v", s.name, err)
+			}
+		}()
+		sharedData = data
+	}
+	switch {
+	case s.byoCluster != nil:
+		telemetry.RecordBackend(ctx, "byo-cluster")
+	case s.profile != "":
+		telemetry.RecordBackend(ctx, string(s.profile))
+	}
+	if err := s.createSecret(ctx, s.name, sharedData); err != nil {
 		return fmt.Errorf("failed to create secret: %w", err)
 	}
-	if err := s.createCredentials(); err != nil {
+	parameters := s.secretParameters(pre, post)
+	if err := s.createSecret(ctx, s.parametersSecretName(), parameters); err != nil {
+		return fmt.Errorf("failed to create secret parameters: %w", err)
+	}
+	if err := s.createSealedSecret(ctx, parameters); err != nil {
+		return err
+	}
+	vaultCredentials, err := s.createCredentials(ctx)
+	defer func() {
+		if err := s.deleteVaultCredentials(vaultCredentials); err != nil {
+			Logger(ctx).Infof("failed to delete credentials of %s from Vault: %v", s.name, err)
+		}
+	}()
+	if err != nil {
 		return fmt.Errorf("failed to create credentials: %w", err)
 	}
 	if err := s.setupRBAC(ctx); err != nil {
 		return fmt.Errorf("failed to create RBAC objects: %w", err)
 	}
+	if err := s.setupWorkloadIdentity(ctx); err != nil {
+		return fmt.Errorf("failed to set up workload identity: %w", err)
+	}
 	var errs []error
-	if err := s.runSteps(ctx, s.pre, env, true, false); err != nil {
-		errs = append(errs, fmt.Errorf("%q pre steps failed: %w", s.name, err))
+	workspaces, err := s.createWorkspaces(ctx, append(pre, append(s.test, post...)...))
+	defer func() {
+		if err := s.deleteWorkspaces(workspaces); err != nil {
+			Logger(ctx).Infof("failed to delete workspaces of %s: %v", s.name, err)
+		}
+	}()
+	if err != nil {
+		return fmt.Errorf("failed to create workspaces: %w", err)
+	}
+	stopObservers, err := s.runObservers(ctx, env)
+	if err != nil {
+		return fmt.Errorf("failed to start observers: %w", err)
+	}
+	if err := s.runSteps(ctx, pre, env, true, false); err != nil {
+		errs = append(errs, results.ForReason(results.ReasonPreStepsFailed).ForError(fmt.Errorf("%q pre steps failed: %w", s.name, err)))
 	} else if err := s.runSteps(ctx, s.test, env, true, len(errs) != 0); err != nil {
-		errs = append(errs, fmt.Errorf("%q test steps failed: %w", s.name, err))
+		errs = append(errs, results.ForReason(results.ReasonTestStepsFailed).ForError(fmt.Errorf("%q test steps failed: %w", s.name, err)))
 	}
-	if err := s.runSteps(context.Background(), s.post, env, false, len(errs) != 0); err != nil {
-		errs = append(errs, fmt.Errorf("%q post steps failed: %w", s.name, err))
+	// post steps are not cancelled with the graph, so that they can clean
+	// up after an interruption within its grace budget
+	if err := s.runSteps(postStepsContext(ctx), post, env, false, len(errs) != 0); err != nil {
+		errs = append(errs, results.ForReason(results.ReasonPostStepsFailed).ForError(fmt.Errorf("%q post steps failed: %w", s.name, err)))
+	}
+	stopObservers()
+	if len(errs) == 1 {
+		// a single failure keeps its reason, which an aggregate would hide
+		return errs[0]
 	}
 	return utilerrors.NewAggregate(errs)
 }
@@ -159,7 +336,16 @@ func (s *multiStageTestStep) SubSteps() []api.CIOperatorStepDetailInfo {
 
 func (s *multiStageTestStep) Requires() (ret []api.StepLink) {
 	var needsReleaseImage, needsReleasePayload bool
-	for _, step := range append(append(s.pre, s.test...), s.post...) {
+	var steps []api.LiteralTestStep
+	steps = append(steps, append(append(s.pre, s.test...), s.post...)...)
+	for _, observer := range s.observers {
+		steps = append(steps, observer.LiteralTestStep())
+	}
+	for _, step := range steps {
+		if step.Upgrade != nil {
+			ret = append(ret, api.ReleasePayloadImageLink(step.Upgrade.From), api.ReleasePayloadImageLink(step.Upgrade.To))
+			continue
+		}
 		dependency := api.StepDependency{Name: step.From}
 		imageStream, name, explicit := s.config.DependencyParts(dependency)
 		if explicit {
@@ -175,17 +361,33 @@ func (s *multiStageTestStep) Requires() (ret []api.StepLink) {
 		}
 
 		for _, dependency := range step.Dependencies {
+			if _, overridden := s.dependencyOverrides[dependency.Env]; overridden {
+				continue
+			}
 			// we validate that the link will exist at config load time
 			// so we can safely ignore the case where !ok
 			imageStream, name, _ := s.config.DependencyParts(dependency)
 			ret = append(ret, api.LinkForImage(imageStream, name))
 		}
 
+		for _, dependency := range step.ExternalDependencies {
+			ret = append(ret, api.InternalImageLink(dependency.PipelineTag()))
+		}
+
 		if step.Cli != "" {
 			dependency := api.StepDependency{Name: fmt.Sprintf("%s:cli", api.ReleaseStreamFor(step.Cli))}
 			imageStream, name, _ := s.config.DependencyParts(dependency)
 			ret = append(ret, api.LinkForImage(imageStream, name))
 		}
+
+		for _, sidecar := range step.Sidecars {
+			imageStream, name, explicit := s.config.DependencyParts(api.StepDependency{Name: sidecar.From})
+			if explicit {
+				ret = append(ret, api.LinkForImage(imageStream, name))
+			} else {
+				needsReleaseImage = true
+			}
+		}
 	}
 	if s.profile != "" {
 		needsReleasePayload = true
@@ -211,22 +413,24 @@ func (s *multiStageTestStep) setupRBAC(ctx context.Context) error {
 	labels := map[string]string{MultiStageTestLabel: s.name}
 	m := meta.ObjectMeta{Namespace: s.jobSpec.Namespace(), Name: s.name, Labels: labels}
 	sa := &coreapi.ServiceAccount{ObjectMeta: m}
+	// every step needs to read and update the shared secrets and pull images
+	stepRules := []rbacapi.PolicyRule{{
+		APIGroups:     []string{""},
+		Resources:     []string{"secrets"},
+		ResourceNames: []string{s.name, s.sealedSecretName()},
+		Verbs:         []string{"get", "update"},
+	}, {
+		APIGroups: []string{"", "image.openshift.io"},
+		Resources: []string{"imagestreams/layers"},
+		Verbs:     []string{"get"},
+	}}
 	role := &rbacapi.Role{
 		ObjectMeta: m,
-		Rules: []rbacapi.PolicyRule{{
+		Rules: append([]rbacapi.PolicyRule{{
 			APIGroups: []string{"rbac.authorization.k8s.io"},
 			Resources: []string{"rolebindings", "roles"},
 			Verbs:     []string{"create", "list"},
-		}, {
-			APIGroups:     []string{""},
-			Resources:     []string{"secrets"},
-			ResourceNames: []string{s.name},
-			Verbs:         []string{"get", "update"},
-		}, {
-			APIGroups: []string{"", "image.openshift.io"},
-			Resources: []string{"imagestreams/layers"},
-			Verbs:     []string{"get"},
-		}},
+		}}, stepRules...),
 	}
 	subj := []rbacapi.Subject{{Kind: "ServiceAccount", Name: s.name}}
 	bindings := []rbacapi.RoleBinding{
@@ -246,9 +450,48 @@ func (s *multiStageTestStep) setupRBAC(ctx context.Context) error {
 		return err
 	}
 
+	for _, steps := range [][]api.LiteralTestStep{s.pre, s.test, s.post} {
+		for _, step := range steps {
+			if step.ServiceAccount == nil {
+				continue
+			}
+			if err := s.setupStepRBAC(ctx, step, labels, stepRules); err != nil {
+				return fmt.Errorf("failed to create RBAC objects for step %s: %w", step.As, err)
+			}
+		}
+	}
+
 	return nil
 }
 
+// stepServiceAccountName is the name of the service account of a step that
+// declares its own permissions
+func (s *multiStageTestStep) stepServiceAccountName(step api.LiteralTestStep) string {
+	return fmt.Sprintf("%s-%s", s.name, step.As)
+}
+
+// setupStepRBAC creates a service account for the step which is only bound to
+// the rules the step declared, on top of those every step needs
+func (s *multiStageTestStep) setupStepRBAC(ctx context.Context, step api.LiteralTestStep, labels map[string]string, stepRules []rbacapi.PolicyRule) error {
+	name := s.stepServiceAccountName(step)
+	m := meta.ObjectMeta{Namespace: s.jobSpec.Namespace(), Name: name, Labels: labels}
+	rules := append([]rbacapi.PolicyRule{}, stepRules...)
+	for _, rule := range step.ServiceAccount.Rules {
+		rules = append(rules, rbacapi.PolicyRule{
+			APIGroups:     rule.APIGroups,
+			Resources:     rule.Resources,
+			ResourceNames: rule.ResourceNames,
+			Verbs:         rule.Verbs,
+		})
+	}
+	bindings := []rbacapi.RoleBinding{{
+		ObjectMeta: m,
+		RoleRef:    rbacapi.RoleRef{Kind: "Role", Name: name},
+		Subjects:   []rbacapi.Subject{{Kind: "ServiceAccount", Name: name}},
+	}}
+	return util.CreateRBACs(ctx, &coreapi.ServiceAccount{ObjectMeta: m}, &rbacapi.Role{ObjectMeta: m, Rules: rules}, bindings, s.client, 1*time.Second, 1*time.Minute)
+}
+
 func (s *multiStageTestStep) environment(ctx context.Context) ([]coreapi.EnvVar, error) {
 	var ret []coreapi.EnvVar
 	for _, l := range s.leases {
@@ -275,32 +518,129 @@ func (s *multiStageTestStep) environment(ctx context.Context) ([]coreapi.EnvVar,
 		} else if optionalOperator != nil {
 			ret = append(ret, optionalOperator.asEnv()...)
 		}
+		if s.profileOverlay != nil {
+			for _, name := range sets.StringKeySet(s.profileOverlay.Environment).List() {
+				ret = append(ret, coreapi.EnvVar{Name: name, Value: s.profileOverlay.Environment[name]})
+			}
+		}
 	}
 	return ret, nil
 }
 
-func (s *multiStageTestStep) createSecret(ctx context.Context) error {
-	log.Printf("Creating multi-stage test secret %q", s.name)
-	secret := &coreapi.Secret{ObjectMeta: meta.ObjectMeta{Namespace: s.jobSpec.Namespace(), Name: s.name}}
+// createProfileOverlay merges the cluster profile with the secrets and config
+// maps of the overlay into the secret mounted as the profile of the test
+func (s *multiStageTestStep) createProfileOverlay(ctx context.Context) error {
+	profile := &coreapi.Secret{}
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: s.profileSecretName()}, profile); err != nil {
+		return fmt.Errorf("could not get the cluster profile: %w", err)
+	}
+	data := map[string][]byte{}
+	for key, value := range profile.Data {
+		data[key] = value
+	}
+	for _, source := range s.profileOverlay.Secrets {
+		secret := &coreapi.Secret{}
+		if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: source.Namespace, Name: source.Name}, secret); err != nil {
+			return fmt.Errorf("could not read secret %s/%s: %w", source.Namespace, source.Name, err)
+		}
+		for key, value := range secret.Data {
+			data[key] = value
+		}
+	}
+	for _, source := range s.profileOverlay.ConfigMaps {
+		configMap := &coreapi.ConfigMap{}
+		if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: source.Namespace, Name: source.Name}, configMap); err != nil {
+			return fmt.Errorf("could not read config map %s/%s: %w", source.Namespace, source.Name, err)
+		}
+		for key, value := range configMap.Data {
+			data[key] = []byte(value)
+		}
+		for key, value := range configMap.BinaryData {
+			data[key] = value
+		}
+	}
+	return s.createSecret(ctx, s.profileOverlaySecretName(), data)
+}
+
+// createSealedSecret creates the sealed dir secret, seeded with the values of
+// secret parameters so they are censored, and the key the entrypoint wrapper
+// decrypts and encrypts it with. Values steps pass on to later steps are thus
+// not readable from the secret itself.
+func (s *multiStageTestStep) createSealedSecret(ctx context.Context, values map[string][]byte) error {
+	key, err := util.NewSealingKey()
+	if err != nil {
+		return err
+	}
+	if err := s.createSecret(ctx, s.sealedKeySecretName(), map[string][]byte{sealedKeySecretKey: []byte(key)}); err != nil {
+		return fmt.Errorf("failed to create sealing key: %w", err)
+	}
+	sealed, err := util.SealValues(key, values)
+	if err != nil {
+		return fmt.Errorf("failed to seal secret parameters: %w", err)
+	}
+	if err := s.createSecret(ctx, s.sealedSecretName(), sealed); err != nil {
+		return fmt.Errorf("failed to create sealed secret: %w", err)
+	}
+	return nil
+}
+
+func (s *multiStageTestStep) createSecret(ctx context.Context, name string, data map[string][]byte) error {
+	Logger(ctx).Infof("Creating multi-stage test secret %q", name)
+	secret := &coreapi.Secret{ObjectMeta: meta.ObjectMeta{Namespace: s.jobSpec.Namespace(), Name: name}, Data: data}
 	if err := s.client.Delete(ctx, secret); err != nil && !kerrors.IsNotFound(err) {
-		return fmt.Errorf("cannot delete secret %q: %w", s.name, err)
+		return fmt.Errorf("cannot delete secret %q: %w", name, err)
 	}
 	return s.client.Create(ctx, secret)
 }
 
-func (s *multiStageTestStep) createCredentials() error {
-	log.Printf("Creating multi-stage test credentials for %q", s.name)
+// VaultClient reads the credentials which steps request from Vault
+type VaultClient interface {
+	GetKV(path string) (*vaultclient.KVData, error)
+}
+
+// credentialSecretName determines the name of the secret in the test
+// namespace that holds the credential
+func credentialSecretName(test string, credential api.CredentialReference) string {
+	if credential.VaultPath != "" {
+		// credentials from Vault are deleted when the test finishes, so
+		// they must not be shared with other tests
+		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(credential.VaultPath)))
+		return fmt.Sprintf("%s-vault-%s", test, hash[:10])
+	}
+	// we don't want secrets imported from separate namespaces to collide
+	// but we want to keep them generally recognizable for debugging, and the
+	// chance we get a second-level collision (ns-a, name) and (ns, a-name) is
+	// small, so we can get away with this string prefixing
+	return fmt.Sprintf("%s-%s", credential.Namespace, credential.Name)
+}
+
+// createCredentials copies the credentials of the steps into the test
+// namespace and returns the secrets materialized from Vault, which need
+// to be deleted when the test finishes
+func (s *multiStageTestStep) createCredentials(ctx context.Context) ([]*coreapi.Secret, error) {
+	Logger(ctx).Infof("Creating multi-stage test credentials for %q", s.name)
 	toCreate := map[string]*coreapi.Secret{}
 	for _, step := range append(s.pre, append(s.test, s.post...)...) {
-		for _, credential := range step.Credentials {
-			// we don't want secrets imported from separate namespaces to collide
-			// but we want to keep them generally recognizable for debugging, and the
-			// chance we get a second-level collision (ns-a, name) and (ns, a-name) is
-			// small, so we can get away with this string prefixing
-			name := fmt.Sprintf("%s-%s", credential.Namespace, credential.Name)
+		credentials := step.Credentials
+		if step.ArtifactsToRegistry != nil {
+			credentials = append(credentials[:len(credentials):len(credentials)], step.ArtifactsToRegistry.Credentials)
+		}
+		for _, credential := range credentials {
+			name := credentialSecretName(s.name, credential)
+			if credential.VaultPath != "" {
+				if _, seen := toCreate[name]; seen {
+					continue
+				}
+				secret, err := s.vaultCredential(name, credential.VaultPath)
+				if err != nil {
+					return nil, err
+				}
+				toCreate[name] = secret
+				continue
+			}
 			raw := &coreapi.Secret{}
 			if err := s.client.Get(context.TODO(), ctrlruntimeclient.ObjectKey{Namespace: credential.Namespace, Name: credential.Name}, raw); err != nil {
-				return fmt.Errorf("could not read source credential: %w", err)
+				return nil, fmt.Errorf("could not read source credential: %w", err)
 			}
 			toCreate[name] = &coreapi.Secret{
 				TypeMeta: raw.TypeMeta,
@@ -315,14 +655,68 @@ func (s *multiStageTestStep) createCredentials() error {
 		}
 	}
 
-	for name := range toCreate {
-		if err := s.client.Create(context.TODO(), toCreate[name]); err != nil && !kerrors.IsAlreadyExists(err) {
-			return fmt.Errorf("could not create source credential: %w", err)
+	var fromVault []*coreapi.Secret
+	for name, secret := range toCreate {
+		if err := s.client.Create(context.TODO(), secret); err != nil {
+			if !kerrors.IsAlreadyExists(err) {
+				return fromVault, fmt.Errorf("could not create source credential: %w", err)
+			}
+			if secret.Labels[vaultCredentialLabel] != "" {
+				// a previous execution of the test left its copy behind
+				if err := s.client.Update(context.TODO(), secret); err != nil {
+					return fromVault, fmt.Errorf("could not update credential %s from Vault: %w", name, err)
+				}
+			}
+		}
+		if secret.Labels[vaultCredentialLabel] != "" {
+			fromVault = append(fromVault, secret)
 		}
 	}
-	return nil
+	return fromVault, nil
 }
 
+// vaultCredentialLabel marks secrets materialized from Vault
+const vaultCredentialLabel = "ci.openshift.io/vault-credential"
+
+func (s *multiStageTestStep) vaultCredential(name, path string) (*coreapi.Secret, error) {
+	if s.vault == nil {
+		return nil, fmt.Errorf("credential from Vault path %s was requested, but no Vault access is configured", path)
+	}
+	kv, err := s.vault.GetKV(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read credential %s from Vault: %w", path, err)
+	}
+	secret := &coreapi.Secret{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      name,
+			Namespace: s.jobSpec.Namespace(),
+			Labels:    map[string]string{MultiStageTestLabel: s.name, vaultCredentialLabel: "true"},
+		},
+		Type: coreapi.SecretTypeOpaque,
+		Data: map[string][]byte{},
+	}
+	for key, value := range kv.Data {
+		secret.Data[key] = []byte(value)
+	}
+	// the secret is garbage-collected with the owner if we die before cleaning up
+	if owner := s.jobSpec.Owner(); owner != nil {
+		secret.OwnerReferences = append(secret.OwnerReferences, *owner)
+	}
+	return secret, nil
+}
+
+func (s *multiStageTestStep) deleteVaultCredentials(secrets []*coreapi.Secret) error {
+	var errs []error
+	for _, secret := range secrets {
+		if err := s.client.Delete(cleanupCtx, secret); err != nil && !kerrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("could not delete credential %s: %w", secret.Name, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// runSteps runs the steps in order. Upgrade steps run in ci-operator between
+// the pods of the steps around them.
 func (s *multiStageTestStep) runSteps(
 	ctx context.Context,
 	steps []api.LiteralTestStep,
@@ -330,17 +724,87 @@ func (s *multiStageTestStep) runSteps(
 	shortCircuit bool,
 	hasPrevErrs bool,
 ) error {
-	pods, isBestEffort, err := s.generatePods(steps, env, hasPrevErrs)
+	var errs []error
+	for len(steps) > 0 {
+		next := len(steps)
+		for i, step := range steps {
+			if step.Upgrade != nil {
+				next = i
+				break
+			}
+		}
+		if next > 0 {
+			if err := s.runPodSteps(ctx, steps[:next], env, shortCircuit, hasPrevErrs); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if next == len(steps) || shortCircuit && len(errs) != 0 {
+			break
+		}
+		if err := s.runUpgradeStep(ctx, steps[next], env, hasPrevErrs); err != nil {
+			errs = append(errs, err)
+		}
+		steps = steps[next+1:]
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// runUpgradeStep runs the upgrade step unless it is skipped, ignoring its
+// failure if it is best-effort
+func (s *multiStageTestStep) runUpgradeStep(ctx context.Context, step api.LiteralTestStep, env []coreapi.EnvVar, hasPrevErrs bool) error {
+	name := fmt.Sprintf("%s-%s", s.name, step.As)
+	if ctx.Err() != nil {
+		Logger(ctx).Infof("Skipping step %s, the execution was cancelled", name)
+		recordPostStep(ctx, name, false)
+		return fmt.Errorf("cancelled")
+	}
+	if skip, err := s.skipStep(ctx, step, env, hasPrevErrs); err != nil || skip {
+		return err
+	}
+	recordPostStep(ctx, name, true)
+	if err := s.runUpgrade(ctx, step); err != nil {
+		if s.isBestEffort(step) {
+			Logger(ctx).Info(fmt.Sprintf("Step %s is running in best-effort mode, ignoring the failure...", name))
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *multiStageTestStep) runPodSteps(
+	ctx context.Context,
+	steps []api.LiteralTestStep,
+	env []coreapi.EnvVar,
+	shortCircuit bool,
+	hasPrevErrs bool,
+) error {
+	pods, isBestEffort, err := s.generatePods(ctx, steps, env, hasPrevErrs)
 	if err != nil {
 		return err
 	}
+	stepsByPod := map[string]api.LiteralTestStep{}
+	for _, step := range steps {
+		stepsByPod[fmt.Sprintf("%s-%s", s.name, step.As)] = step
+	}
+	retry := func(podName string, attempt int, started time.Time) (*coreapi.Pod, error) {
+		step, ok := stepsByPod[podName]
+		if !ok || !retryAllowed(step.Retries, attempt, started, time.Now()) {
+			return nil, nil
+		}
+		telemetry.RecordRetry(ctx)
+		return s.generatePod(step, env, attempt+1)
+	}
 	var errs []error
-	if err := s.runPods(ctx, pods, shortCircuit, isBestEffort); err != nil {
+	if err := s.runPods(ctx, pods, shortCircuit, isBestEffort, retry); err != nil {
 		errs = append(errs, err)
 	}
 	select {
 	case <-ctx.Done():
-		log.Printf("cleanup: Deleting pods with label %s=%s", MultiStageTestLabel, s.name)
+		Logger(ctx).Infof("cleanup: Deleting pods with label %s=%s", MultiStageTestLabel, s.name)
 		if err := s.client.DeleteAllOf(cleanupCtx, &coreapi.Pod{}, ctrlruntimeclient.InNamespace(s.jobSpec.Namespace()), ctrlruntimeclient.MatchingLabels{MultiStageTestLabel: s.name}); err != nil && !kerrors.IsNotFound(err) {
 			errs = append(errs, fmt.Errorf("failed to delete pods with label %s=%s: %w", MultiStageTestLabel, s.name, err))
 		}
@@ -353,117 +817,395 @@ func (s *multiStageTestStep) runSteps(
 
 const multiStageTestStepContainerName = "test"
 
-func (s *multiStageTestStep) generatePods(steps []api.LiteralTestStep, env []coreapi.EnvVar,
+const (
+	// annotationStepTimeout records the timeout of the commands of a step,
+	// so failures caused by it can be reported as such
+	annotationStepTimeout = "ci-operator.openshift.io/step-timeout"
+	// activeDeadlineSlack is added to the timeout and grace period of a step
+	// to get the deadline of its pod, leaving time to start the pod and to
+	// upload the artifacts
+	activeDeadlineSlack = 15 * time.Minute
+)
+
+func (s *multiStageTestStep) generatePods(ctx context.Context, steps []api.LiteralTestStep, env []coreapi.EnvVar,
 	hasPrevErrs bool) ([]coreapi.Pod, func(string) bool, error) {
 	bestEffort := sets.NewString()
 	isBestEffort := func(podName string) bool {
-		if s.allowBestEffortPostSteps == nil || !*s.allowBestEffortPostSteps {
-			// the user has not requested best-effort steps or they've explicitly disabled them
-			return false
-		}
 		return bestEffort.Has(podName)
 	}
 	var ret []coreapi.Pod
 	var errs []error
 	for _, step := range steps {
 		name := fmt.Sprintf("%s-%s", s.name, step.As)
-		if s.allowSkipOnSuccess != nil && *s.allowSkipOnSuccess &&
-			step.OptionalOnSuccess != nil && *step.OptionalOnSuccess &&
-			!hasPrevErrs {
-			log.Println(fmt.Sprintf("Skipping optional step %q", name))
-			continue
-		}
-		image := step.From
-		if link, ok := step.FromImageTag(); ok {
-			image = fmt.Sprintf("%s:%s", api.PipelineImageStream, link)
-		} else {
-			dep := api.StepDependency{Name: image}
-			stream, tag, _ := s.config.DependencyParts(dep)
-			image = fmt.Sprintf("%s:%s", stream, tag)
-		}
-		resources, err := resourcesFor(step.Resources)
-		if err != nil {
+		if skip, err := s.skipStep(ctx, step, env, hasPrevErrs); err != nil {
 			errs = append(errs, err)
 			continue
+		} else if skip {
+			continue
 		}
-		if step.BestEffort != nil && *step.BestEffort {
+		if s.isBestEffort(step) {
 			bestEffort.Insert(name)
 		}
-		p := func(i int64) *int64 {
-			return &i
-		}
-		artifactDir := fmt.Sprintf("%s/%s", s.name, step.As)
-		timeout := entrypoint.DefaultTimeout
-		if step.Timeout != nil {
-			timeout = step.Timeout.Duration
-		}
-		s.jobSpec.DecorationConfig.Timeout = &prowapi.Duration{Duration: timeout}
-		gracePeriod := entrypoint.DefaultGracePeriod
-		if step.GracePeriod != nil {
-			gracePeriod = step.GracePeriod.Duration
-		}
-		s.jobSpec.DecorationConfig.GracePeriod = &prowapi.Duration{Duration: gracePeriod}
-		// We want upload to have some time to do what it needs to do, so set
-		// the grace period for the Pod to be just larger than the grace period
-		// for the process, assuming an 80/20 distribution of work.
-		terminationGracePeriodSeconds := p(int64(gracePeriod.Seconds() * 5 / 4))
-		pod, err := generateBasePod(s.jobSpec, name, multiStageTestStepContainerName, []string{"/bin/bash", "-c", CommandPrefix + step.Commands}, image, resources, artifactDir, s.jobSpec.DecorationConfig, s.jobSpec.RawSpec())
+		pod, err := s.generatePod(step, env, 1)
 		if err != nil {
 			errs = append(errs, err)
 			continue
 		}
-		delete(pod.Labels, ProwJobIdLabel)
-		pod.Annotations[annotationSaveContainerLogs] = "true"
-		pod.Labels[MultiStageTestLabel] = s.name
-		pod.Spec.ServiceAccountName = s.name
-		pod.Spec.TerminationGracePeriodSeconds = terminationGracePeriodSeconds
-		pod.Spec.Volumes = append(pod.Spec.Volumes, coreapi.Volume{Name: homeVolumeName, VolumeSource: coreapi.VolumeSource{EmptyDir: &coreapi.EmptyDirVolumeSource{}}})
-		for idx := range pod.Spec.Containers {
-			if pod.Spec.Containers[idx].Name != multiStageTestStepContainerName {
-				continue
-			}
-			pod.Spec.Containers[idx].VolumeMounts = append(pod.Spec.Containers[idx].VolumeMounts, coreapi.VolumeMount{Name: homeVolumeName, MountPath: "/alabama"})
+		ret = append(ret, *pod)
+	}
+	return ret, isBestEffort, utilerrors.NewAggregate(errs)
+}
+
+// skipStep determines whether the step is optional and not needed or its
+// condition does not hold
+func (s *multiStageTestStep) skipStep(ctx context.Context, step api.LiteralTestStep, env []coreapi.EnvVar, hasPrevErrs bool) (bool, error) {
+	name := fmt.Sprintf("%s-%s", s.name, step.As)
+	if s.allowSkipOnSuccess != nil && *s.allowSkipOnSuccess &&
+		step.OptionalOnSuccess != nil && *step.OptionalOnSuccess &&
+		!hasPrevErrs {
+		Logger(ctx).Infof("Skipping optional step %q", name)
+		return true, nil
+	}
+	if step.If != "" {
+		holds, err := s.conditionHolds(step, env)
+		if err != nil {
+			return false, err
+		}
+		if !holds {
+			Logger(ctx).Infof("Skipping step %q, its condition %q does not hold", name, step.If)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// conditionHolds evaluates the `if` expression of the step against the
+// values the step's environment would have
+func (s *multiStageTestStep) conditionHolds(step api.LiteralTestStep, env []coreapi.EnvVar) (bool, error) {
+	condition, err := api.ParseCondition(step.If)
+	if err != nil {
+		return false, fmt.Errorf("invalid condition of step %s: %w", step.As, err)
+	}
+	variables := map[string]string{}
+	if s.profile != "" {
+		variables["CLUSTER_TYPE"] = s.profile.ClusterType()
+	}
+	for _, e := range env {
+		variables[e.Name] = e.Value
+	}
+	for _, param := range step.Environment {
+		variables[param.Name] = s.parameterValue(param)
+	}
+	return condition.Evaluate(variables), nil
+}
+
+// generatePod creates the pod for an attempt to run the step, counting from 1
+func (s *multiStageTestStep) generatePod(step api.LiteralTestStep, env []coreapi.EnvVar, attempt int) (*coreapi.Pod, error) {
+	var image string
+	if link, ok := step.FromImageTag(); ok {
+		image = fmt.Sprintf("%s:%s", api.PipelineImageStream, link)
+	} else {
+		image = s.imageFor(step.From)
+	}
+	resources, err := resourcesFor(step.Resources)
+	if err != nil {
+		return nil, err
+	}
+	p := func(i int64) *int64 {
+		return &i
+	}
+	name, artifactDir := s.attemptNames(step, attempt)
+	timeout := entrypoint.DefaultTimeout
+	if step.Timeout != nil {
+		timeout = step.Timeout.Duration
+	}
+	s.jobSpec.DecorationConfig.Timeout = &prowapi.Duration{Duration: timeout}
+	gracePeriod := entrypoint.DefaultGracePeriod
+	if step.GracePeriod != nil {
+		gracePeriod = step.GracePeriod.Duration
+	}
+	s.jobSpec.DecorationConfig.GracePeriod = &prowapi.Duration{Duration: gracePeriod}
+	// We want upload to have some time to do what it needs to do, so set
+	// the grace period for the Pod to be just larger than the grace period
+	// for the process, assuming an 80/20 distribution of work.
+	terminationGracePeriodSeconds := p(int64(gracePeriod.Seconds() * 5 / 4))
+	pod, err := generateBasePod(s.jobSpec, name, multiStageTestStepContainerName, []string{"/bin/bash", "-c", CommandPrefix + step.Commands}, image, resources, artifactDir, s.jobSpec.DecorationConfig, s.jobSpec.RawSpec())
+	if err != nil {
+		return nil, err
+	}
+	delete(pod.Labels, ProwJobIdLabel)
+	pod.Annotations[annotationSaveContainerLogs] = "true"
+	pod.Annotations[annotationStepTimeout] = timeout.String()
+	pod.Spec.ActiveDeadlineSeconds = p(int64((timeout + gracePeriod + activeDeadlineSlack).Seconds()))
+	pod.Labels[MultiStageTestLabel] = s.name
+	pod.Spec.ServiceAccountName = s.name
+	if step.ServiceAccount != nil {
+		pod.Spec.ServiceAccountName = s.stepServiceAccountName(step)
+	}
+	pod.Spec.TerminationGracePeriodSeconds = terminationGracePeriodSeconds
+	pod.Spec.Volumes = append(pod.Spec.Volumes, coreapi.Volume{Name: homeVolumeName, VolumeSource: coreapi.VolumeSource{EmptyDir: &coreapi.EmptyDirVolumeSource{}}})
+	for idx := range pod.Spec.Containers {
+		if pod.Spec.Containers[idx].Name != multiStageTestStepContainerName {
+			continue
 		}
+		pod.Spec.Containers[idx].VolumeMounts = append(pod.Spec.Containers[idx].VolumeMounts, coreapi.VolumeMount{Name: homeVolumeName, MountPath: "/alabama"})
+	}
 
-		addSecretWrapper(pod)
-		container := &pod.Spec.Containers[0]
+	addSecretWrapper(pod)
+	container := &pod.Spec.Containers[0]
+	container.Env = append(container.Env, []coreapi.EnvVar{
+		{Name: "NAMESPACE", Value: s.jobSpec.Namespace()},
+		{Name: "JOB_NAME_SAFE", Value: strings.Replace(s.name, "_", "-", -1)},
+		{Name: "JOB_NAME_HASH", Value: s.jobSpec.JobNameHash()},
+	}...)
+	container.Env = append(container.Env, env...)
+	container.Env = append(container.Env, s.generateParams(step)...)
+	depEnv, depErrs := s.envForDependencies(step)
+	if len(depErrs) != 0 {
+		return nil, utilerrors.NewAggregate(depErrs)
+	}
+	container.Env = append(container.Env, depEnv...)
+	if owner := s.jobSpec.Owner(); owner != nil {
+		pod.OwnerReferences = append(pod.OwnerReferences, *owner)
+	}
+	if s.profile != "" {
+		if s.workloadIdentity != nil {
+			addWorkloadIdentity(s.workloadIdentityName(), s.profile, s.workloadIdentity, pod)
+		} else {
+			addProfile(s.mountedProfileSecretName(), s.profile, pod)
+		}
 		container.Env = append(container.Env, []coreapi.EnvVar{
-			{Name: "NAMESPACE", Value: s.jobSpec.Namespace()},
-			{Name: "JOB_NAME_SAFE", Value: strings.Replace(s.name, "_", "-", -1)},
-			{Name: "JOB_NAME_HASH", Value: s.jobSpec.JobNameHash()},
+			{Name: "KUBECONFIG", Value: filepath.Join(SecretMountPath, "kubeconfig")},
+			{Name: "KUBEADMIN_PASSWORD_FILE", Value: filepath.Join(SecretMountPath, "kubeadmin-password")},
 		}...)
-		container.Env = append(container.Env, env...)
-		container.Env = append(container.Env, s.generateParams(step.Environment)...)
-		depEnv, depErrs := s.envForDependencies(step)
-		if len(depErrs) != 0 {
-			errs = append(errs, depErrs...)
-			continue
+	} else if s.byoCluster != nil {
+		container.Env = append(container.Env, coreapi.EnvVar{Name: "KUBECONFIG", Value: filepath.Join(SecretMountPath, BYOClusterKubeconfigKey)})
+	}
+	if step.Cli != "" {
+		if err := addCliInjector(step.Cli, pod); err != nil {
+			return nil, err
 		}
-		container.Env = append(container.Env, depEnv...)
-		if owner := s.jobSpec.Owner(); owner != nil {
-			pod.OwnerReferences = append(pod.OwnerReferences, *owner)
+	}
+	addSecret(s.name, pod)
+	addSealedSecret(s.sealedSecretName(), s.sealedKeySecretName(), pod)
+	if s.sharedDir != nil {
+		if err := addSharedDir(s.sharedDir, pod); err != nil {
+			return nil, err
 		}
-		if s.profile != "" {
-			addProfile(s.profileSecretName(), s.profile, pod)
-			container.Env = append(container.Env, []coreapi.EnvVar{
-				{Name: "KUBECONFIG", Value: filepath.Join(SecretMountPath, "kubeconfig")},
-				{Name: "KUBEADMIN_PASSWORD_FILE", Value: filepath.Join(SecretMountPath, "kubeadmin-password")},
-			}...)
+	}
+	if step.Workspace != nil {
+		addWorkspace(workspaceName(fmt.Sprintf("%s-%s", s.name, step.As)), step.Workspace, pod)
+	}
+	// observers run alongside the steps, so they cannot share the volume
+	if s.dataDir != nil && !s.isObserver(step.As) {
+		addDataDir(dataDirName(s.name), pod)
+	}
+	if err := s.addSidecars(step, pod); err != nil {
+		return nil, err
+	}
+	if step.ArtifactsToRegistry != nil {
+		s.addArtifactsPusher(step, pod)
+	}
+	addCredentials(s.name, step.Credentials, pod)
+	// the shared secret holds data written by steps, which is not censored
+	if err := censorSecretsInSidecar(pod, s.name); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// attemptNames returns the names of the pod and of the artifact directory
+// for an attempt to run the step. Artifacts of steps that are retried are
+// stored in a directory for each attempt, so they are not overwritten.
+func (s *multiStageTestStep) attemptNames(step api.LiteralTestStep, attempt int) (string, string) {
+	name, artifactDir := fmt.Sprintf("%s-%s", s.name, step.As), fmt.Sprintf("%s/%s", s.name, step.As)
+	if step.Retries == nil {
+		return name, artifactDir
+	}
+	if attempt > 1 {
+		name = fmt.Sprintf("%s-attempt-%d", name, attempt)
+	}
+	return name, fmt.Sprintf("%s/attempt-%d", artifactDir, attempt)
+}
+
+// isBestEffort determines whether a failure of the step is ignored. Post
+// steps are only best-effort if the test allows it, as they often tear down
+// resources and ignoring their failures can leak them.
+func (s *multiStageTestStep) isBestEffort(step api.LiteralTestStep) bool {
+	if step.BestEffort == nil || !*step.BestEffort {
+		return false
+	}
+	for _, post := range s.post {
+		if post.As == step.As {
+			return s.allowBestEffortPostSteps != nil && *s.allowBestEffortPostSteps
 		}
-		if step.Cli != "" {
-			errs = append(errs, addCliInjector(step.Cli, pod))
+	}
+	return true
+}
+
+func (s *multiStageTestStep) imageFor(from string) string {
+	stream, tag, _ := s.config.DependencyParts(api.StepDependency{Name: from})
+	return fmt.Sprintf("%s:%s", stream, tag)
+}
+
+// sidecarScript runs the commands of a sidecar in the background until the
+// entrypoint of the step's container writes its marker file, so the pod can
+// complete. A sidecar that exits early fails with its exit code.
+const sidecarScript = `#!/bin/sh
+"$@" &
+pid=$!
+while [ ! -e %q ]; do
+	if ! kill -0 "${pid}" 2>/dev/null; then
+		wait "${pid}"
+		exit $?
+	fi
+	sleep 1
+done
+kill "${pid}" 2>/dev/null
+exit 0
+`
+
+func (s *multiStageTestStep) addSidecars(step api.LiteralTestStep, pod *coreapi.Pod) error {
+	if len(step.Sidecars) == 0 {
+		return nil
+	}
+	logMount, _ := decorate.LogMountAndVolume()
+	marker := filepath.Join(logMount.MountPath, "marker-file.txt")
+	sharedVolumes := sets.NewString(logMount.Name, s.name, workspaceVolumeName, dataDirVolumeName)
+	sharedEnv := sets.NewString("NAMESPACE", SecretMountEnv, WorkspaceMountEnv, DataDirMountEnv)
+	var mounts []coreapi.VolumeMount
+	for _, mount := range pod.Spec.Containers[0].VolumeMounts {
+		if sharedVolumes.Has(mount.Name) {
+			mounts = append(mounts, mount)
 		}
-		addSecret(s.name, pod)
-		addCredentials(step.Credentials, pod)
-		ret = append(ret, *pod)
 	}
-	return ret, isBestEffort, utilerrors.NewAggregate(errs)
+	var env []coreapi.EnvVar
+	for _, e := range pod.Spec.Containers[0].Env {
+		if sharedEnv.Has(e.Name) {
+			env = append(env, e)
+		}
+	}
+	env = append(env, s.generateParams(step)...)
+	for _, sidecar := range step.Sidecars {
+		resources, err := resourcesFor(sidecar.Resources)
+		if err != nil {
+			return fmt.Errorf("invalid resources for sidecar %s of step %s: %w", sidecar.Name, step.As, err)
+		}
+		pod.Spec.Containers = append(pod.Spec.Containers, coreapi.Container{
+			Name:                     sidecar.Name,
+			Image:                    s.imageFor(sidecar.From),
+			Command:                  []string{"/bin/sh", "-c", fmt.Sprintf(sidecarScript, marker), sidecar.Name, "/bin/sh", "-c", sidecar.Commands},
+			Env:                      env,
+			Resources:                resources,
+			VolumeMounts:             mounts,
+			TerminationMessagePolicy: coreapi.TerminationMessageFallbackToLogsOnError,
+		})
+	}
+	return nil
+}
+
+// artifactsPusherContainerName is the name of the container pushing the
+// artifacts of a step to a registry
+const artifactsPusherContainerName = "push-artifacts"
+
+// artifactsPushSecretMountPath is where the credentials pushing the artifacts
+// of a step are mounted in the pushing container
+const artifactsPushSecretMountPath = "/etc/push-secret"
+
+// artifactsPusherScript waits for the entrypoint of the step's container to
+// write its marker file and pushes the files, given after the script, with
+// the flags and reference given as arguments, if the step succeeded.
+const artifactsPusherScript = `#!/bin/bash
+set -euo pipefail
+shopt -s failglob
+while [[ ! -e %[1]q ]]; do
+	sleep 1
+done
+if [[ "$(cat %[1]q)" != "0" ]]; then
+	echo "The step failed, not pushing its artifacts"
+	exit 0
+fi
+cd %[3]q
+oras push --registry-config %[2]q "$@" %[4]s
+`
+
+// invalidTagCharacters are replaced in the default tags of artifacts
+var invalidTagCharacters = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// artifactsTag is the tag of the artifact pushed from the step
+func (s *multiStageTestStep) artifactsTag(step api.LiteralTestStep) string {
+	if step.ArtifactsToRegistry.Tag != "" {
+		return step.ArtifactsToRegistry.Tag
+	}
+	tag := invalidTagCharacters.ReplaceAllString(fmt.Sprintf("%s-%s-%s", s.name, step.As, s.jobSpec.BuildID), "-")
+	if len(tag) > 128 {
+		tag = tag[:128]
+	}
+	return tag
+}
+
+// addArtifactsPusher adds the container pushing the declared artifacts of
+// the step once its commands finish
+func (s *multiStageTestStep) addArtifactsPusher(step api.LiteralTestStep, pod *coreapi.Pod) {
+	config := step.ArtifactsToRegistry
+	logMount, _ := decorate.LogMountAndVolume()
+	annotations := map[string]string{
+		"io.openshift.ci.job":      s.jobSpec.Job,
+		"io.openshift.ci.build-id": s.jobSpec.BuildID,
+		"io.openshift.ci.test":     s.name,
+		"io.openshift.ci.step":     step.As,
+	}
+	if refs := s.jobSpec.Refs; refs != nil {
+		annotations["org.opencontainers.image.source"] = api.RepoURL(refs)
+		annotations["org.opencontainers.image.revision"] = refs.BaseSHA
+	}
+	var args []string
+	for _, key := range sets.StringKeySet(annotations).List() {
+		args = append(args, "--annotation", fmt.Sprintf("%s=%s", key, annotations[key]))
+	}
+	if config.ArtifactType != "" {
+		args = append(args, "--artifact-type", config.ArtifactType)
+	}
+	args = append(args, fmt.Sprintf("%s:%s", config.Repository, s.artifactsTag(step)))
+	// the files are validated not to contain anything but path characters
+	// and globs, which the shell expands
+	script := fmt.Sprintf(artifactsPusherScript,
+		filepath.Join(logMount.MountPath, "marker-file.txt"),
+		filepath.Join(artifactsPushSecretMountPath, coreapi.DockerConfigJsonKey),
+		filepath.Join(logMount.MountPath, "artifacts"),
+		strings.Join(config.Files, " "),
+	)
+	pod.Spec.Containers = append(pod.Spec.Containers, coreapi.Container{
+		Name:    artifactsPusherContainerName,
+		Image:   fmt.Sprintf("%s/ci/oras:latest", api.DomainForService(api.ServiceRegistry)),
+		Command: append([]string{"/bin/bash", "-c", script, artifactsPusherContainerName}, args...),
+		Resources: coreapi.ResourceRequirements{
+			Requests: coreapi.ResourceList{coreapi.ResourceCPU: resource.MustParse("10m"), coreapi.ResourceMemory: resource.MustParse("100Mi")},
+		},
+		VolumeMounts: []coreapi.VolumeMount{
+			logMount,
+			{Name: "push-secret", MountPath: artifactsPushSecretMountPath, ReadOnly: true},
+		},
+		TerminationMessagePolicy: coreapi.TerminationMessageFallbackToLogsOnError,
+	})
+	// only the credentials of the repository's owners are mounted, the
+	// credentials of CI could push anywhere
+	pod.Spec.Volumes = append(pod.Spec.Volumes, coreapi.Volume{
+		Name: "push-secret",
+		VolumeSource: coreapi.VolumeSource{
+			Secret: &coreapi.SecretVolumeSource{SecretName: credentialSecretName(s.name, config.Credentials)},
+		},
+	})
 }
 
 func (s *multiStageTestStep) envForDependencies(step api.LiteralTestStep) ([]coreapi.EnvVar, []error) {
 	var env []coreapi.EnvVar
 	var errs []error
 	for _, dependency := range step.Dependencies {
+		if pullSpec, overridden := s.dependencyOverrides[dependency.Env]; overridden {
+			env = append(env, coreapi.EnvVar{Name: dependency.Env, Value: pullSpec})
+			continue
+		}
 		imageStream, name, _ := s.config.DependencyParts(dependency)
 		ref, err := utils.ImageDigestFor(s.client, s.jobSpec.Namespace, imageStream, name)()
 		if err != nil {
@@ -474,6 +1216,16 @@ func (s *multiStageTestStep) envForDependencies(step api.LiteralTestStep) ([]cor
 			Name: dependency.Env, Value: ref,
 		})
 	}
+	for _, dependency := range step.ExternalDependencies {
+		ref, err := utils.ImageDigestFor(s.client, s.jobSpec.Namespace, api.PipelineImageStream, string(dependency.PipelineTag()))()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not determine image pull spec for external image %s on step %s", dependency.PullSpec, step.As))
+			continue
+		}
+		env = append(env, coreapi.EnvVar{
+			Name: dependency.Env, Value: ref,
+		})
+	}
 	return env, errs
 }
 
@@ -502,22 +1254,238 @@ func addSecretWrapper(pod *coreapi.Pod) {
 	container.VolumeMounts = append(container.VolumeMounts, mount)
 }
 
-func (s *multiStageTestStep) generateParams(env []api.StepParameter) []coreapi.EnvVar {
+// generateParams exposes the parameters of the step to its containers. The
+// values of secret parameters are read from a secret, so they are not part
+// of the pod definition.
+func (s *multiStageTestStep) generateParams(step api.LiteralTestStep) []coreapi.EnvVar {
 	var ret []coreapi.EnvVar
-	for _, env := range env {
-		value := ""
-		if env.Default != nil {
-			value = *env.Default
+	for _, param := range step.Environment {
+		if param.Secret {
+			ret = append(ret, coreapi.EnvVar{Name: param.Name, ValueFrom: &coreapi.EnvVarSource{
+				SecretKeyRef: &coreapi.SecretKeySelector{
+					LocalObjectReference: coreapi.LocalObjectReference{Name: s.parametersSecretName()},
+					Key:                  secretParameterKey(step.As, param.Name),
+				},
+			}})
+			continue
 		}
-		if v, ok := s.env[env.Name]; ok {
-			value = v
+		ret = append(ret, coreapi.EnvVar{Name: param.Name, Value: s.parameterValue(param)})
+	}
+	return ret
+}
+
+// parameterValue determines the value of the parameter, preferring the one
+// set in the test over the default of the step
+func (s *multiStageTestStep) parameterValue(param api.StepParameter) string {
+	if v, ok := s.env[param.Name]; ok {
+		return v
+	}
+	if param.Default != nil {
+		return *param.Default
+	}
+	return ""
+}
+
+// secretParameterKey is the key in the parameters secret holding the value of
+// a secret parameter of a step
+func secretParameterKey(step, name string) string {
+	return fmt.Sprintf("parameter.%s.%s", step, name)
+}
+
+// secretParameters collects the values of the secret parameters of all steps
+// of the test
+func (s *multiStageTestStep) secretParameters(pre, post []api.LiteralTestStep) map[string][]byte {
+	steps := append(append(append([]api.LiteralTestStep{}, pre...), s.test...), post...)
+	for _, observer := range s.observers {
+		steps = append(steps, observer.LiteralTestStep())
+	}
+	var ret map[string][]byte
+	for _, step := range steps {
+		for _, param := range step.Environment {
+			if !param.Secret {
+				continue
+			}
+			if ret == nil {
+				ret = map[string][]byte{}
+			}
+			ret[secretParameterKey(step.As, param.Name)] = []byte(s.parameterValue(param))
 		}
-		ret = append(ret, coreapi.EnvVar{Name: env.Name, Value: value})
 	}
 	return ret
 }
 
+const workspaceVolumeName = "workspace"
+
+func workspaceName(podName string) string {
+	return podName + "-workspace"
+}
+
+const dataDirVolumeName = "data-dir"
+
+func dataDirName(test string) string {
+	return test + "-data"
+}
+
+// createWorkspaces provisions the volumes requested by steps and the data dir
+// of the test and returns the claims that were created, which need to be
+// deleted when the test finishes
+func (s *multiStageTestStep) createWorkspaces(ctx context.Context, steps []api.LiteralTestStep) ([]*coreapi.PersistentVolumeClaim, error) {
+	var created []*coreapi.PersistentVolumeClaim
+	if s.dataDir != nil {
+		Logger(ctx).Infof("Creating data dir %q for test %s", dataDirName(s.name), s.name)
+		claim, err := s.createClaim(ctx, dataDirName(s.name), s.dataDir.Size, s.dataDir.StorageClass)
+		if err != nil {
+			return created, fmt.Errorf("could not create data dir: %w", err)
+		}
+		created = append(created, claim)
+	}
+	for _, step := range steps {
+		if step.Workspace == nil {
+			continue
+		}
+		Logger(ctx).Infof("Creating workspace %q for step %s", workspaceName(fmt.Sprintf("%s-%s", s.name, step.As)), step.As)
+		claim, err := s.createClaim(ctx, workspaceName(fmt.Sprintf("%s-%s", s.name, step.As)), step.Workspace.Size, step.Workspace.StorageClass)
+		if err != nil {
+			return created, fmt.Errorf("could not create workspace for step %s: %w", step.As, err)
+		}
+		created = append(created, claim)
+	}
+	return created, nil
+}
+
+func (s *multiStageTestStep) createClaim(ctx context.Context, name, rawSize, storageClass string) (*coreapi.PersistentVolumeClaim, error) {
+	size, err := resource.ParseQuantity(rawSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid size: %w", err)
+	}
+	claim := &coreapi.PersistentVolumeClaim{
+		ObjectMeta: meta.ObjectMeta{
+			Namespace: s.jobSpec.Namespace(),
+			Name:      name,
+			Labels:    map[string]string{MultiStageTestLabel: s.name},
+		},
+		Spec: coreapi.PersistentVolumeClaimSpec{
+			AccessModes: []coreapi.PersistentVolumeAccessMode{coreapi.ReadWriteOnce},
+			Resources: coreapi.ResourceRequirements{
+				Requests: coreapi.ResourceList{coreapi.ResourceStorage: size},
+			},
+		},
+	}
+	if storageClass != "" {
+		claim.Spec.StorageClassName = &storageClass
+	}
+	// the claim is garbage-collected with the owner if we die before cleaning up
+	if owner := s.jobSpec.Owner(); owner != nil {
+		claim.OwnerReferences = append(claim.OwnerReferences, *owner)
+	}
+	if err := s.client.Create(ctx, claim); err != nil && !kerrors.IsAlreadyExists(err) {
+		return nil, err
+	}
+	return claim, nil
+}
+
+func (s *multiStageTestStep) isObserver(name string) bool {
+	for _, observer := range s.observers {
+		if observer.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *multiStageTestStep) deleteWorkspaces(claims []*coreapi.PersistentVolumeClaim) error {
+	var errs []error
+	for _, claim := range claims {
+		if err := s.client.Delete(cleanupCtx, claim); err != nil && !kerrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("could not delete workspace %s: %w", claim.Name, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func addWorkspace(claim string, workspace *api.WorkspaceConfiguration, pod *coreapi.Pod) {
+	mountPath := workspace.MountPath
+	if mountPath == "" {
+		mountPath = WorkspaceMountPath
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, coreapi.Volume{
+		Name: workspaceVolumeName,
+		VolumeSource: coreapi.VolumeSource{
+			PersistentVolumeClaim: &coreapi.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+		},
+	})
+	pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, coreapi.VolumeMount{
+		Name:      workspaceVolumeName,
+		MountPath: mountPath,
+	})
+	pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, coreapi.EnvVar{
+		Name:  WorkspaceMountEnv,
+		Value: mountPath,
+	})
+}
+
+func addDataDir(claim string, pod *coreapi.Pod) {
+	pod.Spec.Volumes = append(pod.Spec.Volumes, coreapi.Volume{
+		Name: dataDirVolumeName,
+		VolumeSource: coreapi.VolumeSource{
+			PersistentVolumeClaim: &coreapi.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+		},
+	})
+	pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, coreapi.VolumeMount{
+		Name:      dataDirVolumeName,
+		MountPath: DataDirMountPath,
+	})
+	pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, coreapi.EnvVar{
+		Name:  DataDirMountEnv,
+		Value: DataDirMountPath,
+	})
+}
+
+// addSharedDir backs the writable copy of the shared dir which the
+// entrypoint wrapper makes with a volume, instead of the container's
+// filesystem
+func addSharedDir(config *api.SharedDirConfiguration, pod *coreapi.Pod) error {
+	volume := "shared-dir"
+	emptyDir := &coreapi.EmptyDirVolumeSource{}
+	if config.Medium == api.SharedDirMediumMemory {
+		emptyDir.Medium = coreapi.StorageMediumMemory
+	}
+	if config.SizeLimit != "" {
+		limit, err := resource.ParseQuantity(config.SizeLimit)
+		if err != nil {
+			return fmt.Errorf("invalid shared dir size limit: %w", err)
+		}
+		emptyDir.SizeLimit = &limit
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, coreapi.Volume{
+		Name:         volume,
+		VolumeSource: coreapi.VolumeSource{EmptyDir: emptyDir},
+	})
+	container := &pod.Spec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, coreapi.VolumeMount{
+		Name:      volume,
+		MountPath: SharedDirScratchPath,
+	})
+	container.Args = append([]string{"--shared-dir=" + SharedDirScratchPath}, container.Args...)
+	return nil
+}
+
 func addSecret(secret string, pod *coreapi.Pod) {
+	mountSecret(secret, SecretMountPath, SecretMountEnv, pod)
+}
+
+func addSealedSecret(secret, keySecret string, pod *coreapi.Pod) {
+	mountSecret(secret, SealedMountPath, SealedMountEnv, pod)
+	pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, coreapi.EnvVar{
+		Name: SealedKeyEnv,
+		ValueFrom: &coreapi.EnvVarSource{SecretKeyRef: &coreapi.SecretKeySelector{
+			LocalObjectReference: coreapi.LocalObjectReference{Name: keySecret},
+			Key:                  sealedKeySecretKey,
+		}},
+	})
+}
+
+func mountSecret(secret, mountPath, env string, pod *coreapi.Pod) {
 	pod.Spec.Volumes = append(pod.Spec.Volumes, coreapi.Volume{
 		Name: secret,
 		VolumeSource: coreapi.VolumeSource{
@@ -526,17 +1494,17 @@ func addSecret(secret string, pod *coreapi.Pod) {
 	})
 	pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, coreapi.VolumeMount{
 		Name:      secret,
-		MountPath: SecretMountPath,
+		MountPath: mountPath,
 	})
 	pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, coreapi.EnvVar{
-		Name:  SecretMountEnv,
-		Value: SecretMountPath,
+		Name:  env,
+		Value: mountPath,
 	})
 }
 
-func addCredentials(credentials []api.CredentialReference, pod *coreapi.Pod) {
+func addCredentials(test string, credentials []api.CredentialReference, pod *coreapi.Pod) {
 	for _, credential := range credentials {
-		name := fmt.Sprintf("%s-%s", credential.Namespace, credential.Name)
+		name := credentialSecretName(test, credential)
 		pod.Spec.Volumes = append(pod.Spec.Volumes, coreapi.Volume{
 			Name: name,
 			VolumeSource: coreapi.VolumeSource{
@@ -633,13 +1601,36 @@ func addCliInjector(release string, pod *coreapi.Pod) error {
 	return nil
 }
 
-func (s *multiStageTestStep) runPods(ctx context.Context, pods []coreapi.Pod, shortCircuit bool, isBestEffort func(string) bool) error {
+// retryFunc returns the pod for the attempt following the failed one, or nil
+// if the step is not retried
+type retryFunc func(podName string, attempt int, started time.Time) (*coreapi.Pod, error)
+
+func (s *multiStageTestStep) runPods(ctx context.Context, pods []coreapi.Pod, shortCircuit bool, isBestEffort func(string) bool, retry retryFunc) error {
 	var errs []error
 	for _, pod := range pods {
-		err := s.runPod(ctx, &pod, NewTestCaseNotifier(NopNotifier))
+		if ctx.Err() != nil {
+			Logger(ctx).Infof("Skipping pod %s, the execution was cancelled", pod.Name)
+			recordPostStep(ctx, pod.Name, false)
+			continue
+		}
+		recordPostStep(ctx, pod.Name, true)
+		started := time.Now()
+		err := s.runDisruptablePod(ctx, &pod)
+		for attempt := 1; err != nil && ctx.Err() == nil; attempt++ {
+			next, retryErr := retry(pod.Name, attempt, started)
+			if retryErr != nil {
+				err = fmt.Errorf("%w, failed to generate pod to retry: %v", err, retryErr)
+				break
+			}
+			if next == nil {
+				break
+			}
+			Logger(ctx).Infof("Pod %s failed, retrying in pod %s", pod.Name, next.Name)
+			err = s.runDisruptablePod(ctx, next)
+		}
 		if err != nil {
 			if isBestEffort(pod.Name) {
-				log.Println(fmt.Sprintf("Pod %s is running in best-effort mode, ignoring the failure...", pod.Name))
+				Logger(ctx).Info(fmt.Sprintf("Pod %s is running in best-effort mode, ignoring the failure...", pod.Name))
 				continue
 			}
 			errs = append(errs, err)
@@ -651,27 +1642,145 @@ func (s *multiStageTestStep) runPods(ctx context.Context, pods []coreapi.Pod, sh
 	return utilerrors.NewAggregate(errs)
 }
 
+// stepTimedOut determines whether the commands of the step were interrupted
+// because they ran for longer than the timeout of the step
+func stepTimedOut(pod *coreapi.Pod) (time.Duration, bool) {
+	timeout, err := time.ParseDuration(pod.Annotations[annotationStepTimeout])
+	if err != nil {
+		return 0, false
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != multiStageTestStepContainerName || status.State.Terminated == nil {
+			continue
+		}
+		terminated := status.State.Terminated
+		return timeout, terminated.ExitCode == entrypoint.InternalErrorCode && terminated.FinishedAt.Sub(terminated.StartedAt.Time) >= timeout
+	}
+	return timeout, false
+}
+
+// retryAllowed determines whether a step that failed the attempt, counting
+// from 1, which started at the time, is run again
+func retryAllowed(retries *api.StepRetries, attempt int, started, now time.Time) bool {
+	if retries == nil || attempt > retries.Count {
+		return false
+	}
+	return retries.Until == nil || now.Sub(started) < retries.Until.Duration
+}
+
+// observerResources are requested for observer pods, which have no resource
+// requirements of their own
+var observerResources = api.ResourceRequirements{Requests: api.ResourceList{"cpu": "10m", "memory": "100Mi"}}
+
+// observerTimeout is long enough for observers to outlive any test
+const observerTimeout = 24 * time.Hour
+
+// runObservers starts the observer pods, which keep running while the test
+// steps execute. The returned function stops the observers and waits for
+// their pods to terminate, so their artifacts are uploaded with the test's.
+// Observers do not affect the result of the test.
+func (s *multiStageTestStep) runObservers(ctx context.Context, env []coreapi.EnvVar) (func(), error) {
+	var steps []api.LiteralTestStep
+	for _, observer := range s.observers {
+		step := observer.LiteralTestStep()
+		step.Resources = observerResources
+		// observers are stopped when the test finishes
+		step.Timeout = &prowapi.Duration{Duration: observerTimeout}
+		steps = append(steps, step)
+	}
+	pods, _, err := s.generatePods(ctx, steps, env, false)
+	if err != nil {
+		return nil, err
+	}
+	observerCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for i := range pods {
+		wg.Add(1)
+		go func(pod *coreapi.Pod) {
+			defer wg.Done()
+			if err := s.runObserver(observerCtx, pod); err != nil {
+				Logger(ctx).Infof("Observer pod %s failed: %v", pod.Name, err)
+			}
+		}(&pods[i])
+	}
+	return func() {
+		cancel()
+		wg.Wait()
+	}, nil
+}
+
+func (s *multiStageTestStep) runObserver(ctx context.Context, pod *coreapi.Pod) error {
+	if _, err := createOrRestartPod(ctx, s.client, pod); err != nil {
+		return fmt.Errorf("failed to create pod: %w", err)
+	}
+	_, err := waitForPodCompletion(ctx, s.client, pod.Namespace, pod.Name, nil, false)
+	if ctx.Err() == nil {
+		if err != nil {
+			return err
+		}
+		Logger(ctx).Infof("Observer pod %s finished before the test", pod.Name)
+		return nil
+	}
+	Logger(ctx).Infof("Stopping observer pod %s", pod.Name)
+	running := &coreapi.Pod{}
+	if err := s.client.Get(cleanupCtx, ctrlruntimeclient.ObjectKey{Namespace: pod.Namespace, Name: pod.Name}, running); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get pod: %w", err)
+	}
+	if err := s.client.Delete(cleanupCtx, running); err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete pod: %w", err)
+	}
+	return waitForPodDeletion(ctx, s.client, pod.Namespace, pod.Name, running.UID)
+}
+
 func (s *multiStageTestStep) runPod(ctx context.Context, pod *coreapi.Pod, notifier *TestCaseNotifier) error {
 	start := time.Now()
 	client := s.client.WithNewLoggingClient()
-	if _, err := createOrRestartPod(client, pod); err != nil {
+	if _, err := createOrRestartPod(ctx, client, pod); err != nil {
 		return fmt.Errorf("failed to create or restart %q pod: %w", pod.Name, err)
 	}
+	var collector *resourceUsageCollector
+	collectCtx, stopCollecting := context.WithCancel(ctx)
+	if metricsClient, ok := s.client.(PodMetricsClient); ok {
+		collector = newResourceUsageCollector(metricsClient, pod.Namespace, pod.Name)
+		go collector.run(collectCtx, resourceUsageInterval)
+	}
 	newPod, err := waitForPodCompletion(ctx, client, pod.Namespace, pod.Name, notifier, false)
+	stopCollecting()
 	if newPod != nil {
 		pod = newPod
 	}
+	if reason, disrupted := podDisruption(pod); err != nil && disrupted {
+		err = &podDisruptedError{reason: reason, wrapped: err}
+	}
 	finished := time.Now()
 	duration := finished.Sub(start)
+	var usage map[string]api.ContainerResourceUsage
+	if collector != nil {
+		usage = collector.usage(pod)
+	}
 	s.subSteps = append(s.subSteps, api.CIOperatorStepDetailInfo{
-		StepName:    pod.Name,
-		Description: fmt.Sprintf("Run pod %s", pod.Name),
-		StartedAt:   &start,
-		FinishedAt:  &finished,
-		Duration:    &duration,
-		Failed:      utilpointer.BoolPtr(err != nil),
-		Manifests:   client.Objects(),
+		StepName:      pod.Name,
+		Description:   fmt.Sprintf("Run pod %s", pod.Name),
+		StartedAt:     &start,
+		FinishedAt:    &finished,
+		Duration:      &duration,
+		Failed:        utilpointer.BoolPtr(err != nil),
+		Manifests:     client.Objects(),
+		ResourceUsage: usage,
 	})
+	if usage != nil {
+		logResourceSuggestions(ctx, pod.Name, usage)
+		if s.resourceUsage == nil {
+			s.resourceUsage = map[string]map[string]api.ContainerResourceUsage{}
+		}
+		s.resourceUsage[pod.Name] = usage
+		if err := saveResourceUsage(ctx, s.name, s.resourceUsage); err != nil {
+			Logger(ctx).Infof("Failed to save resource usage of %s: %v", s.name, err)
+		}
+	}
 	s.subTests = append(s.subTests, notifier.SubTests(fmt.Sprintf("%s - %s ", s.Description(), pod.Name))...)
 	if err != nil {
 		linksText := strings.Builder{}
@@ -681,7 +1790,9 @@ func (s *multiStageTestStep) runPod(ctx context.Context, pod *coreapi.Pod, notif
 			linksText.WriteString(fmt.Sprintf("&variant=%s", s.config.Metadata.Variant))
 		}
 		status := "failed"
-		if pod.Status.Phase == coreapi.PodFailed && pod.Status.Reason == "DeadlineExceeded" {
+		if timeout, timedOut := stepTimedOut(pod); timedOut {
+			status = fmt.Sprintf("timed out after %s", timeout)
+		} else if pod.Status.Phase == coreapi.PodFailed && pod.Status.Reason == "DeadlineExceeded" {
 			status = "exceeded the configured timeout"
 			if pod.Spec.ActiveDeadlineSeconds != nil {
 				status = fmt.Sprintf("%s activeDeadlineSeconds=%d", status, *pod.Spec.ActiveDeadlineSeconds)
@@ -3,7 +3,9 @@ package steps
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -11,11 +13,15 @@ import (
 
 	coreapi "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	rbacapi "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/diff"
 	"k8s.io/apimachinery/pkg/util/sets"
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/entrypoint"
 	prowdapi "k8s.io/test-infra/prow/pod-utils/downwardapi"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -23,6 +29,7 @@ import (
 	"github.com/openshift/ci-tools/pkg/api"
 	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
 	"github.com/openshift/ci-tools/pkg/testhelper"
+	"github.com/openshift/ci-tools/pkg/vaultclient"
 )
 
 // the multiStageTestStep implements the subStepReporter interface
@@ -30,10 +37,11 @@ var _ SubStepReporter = &multiStageTestStep{}
 
 func TestRequires(t *testing.T) {
 	for _, tc := range []struct {
-		name   string
-		config api.ReleaseBuildConfiguration
-		steps  api.MultiStageTestConfigurationLiteral
-		req    []api.StepLink
+		name      string
+		config    api.ReleaseBuildConfiguration
+		steps     api.MultiStageTestConfigurationLiteral
+		overrides DependencyOverrides
+		req       []api.StepLink
 	}{{
 		name: "step has a cluster profile and requires a release image, should not have ReleaseImagesLink",
 		steps: api.MultiStageTestConfigurationLiteral{
@@ -81,11 +89,49 @@ func TestRequires(t *testing.T) {
 			api.InternalImageLink(
 				api.PipelineImageStreamTagReferenceSource),
 		},
+	}, {
+		name: "step needs external image, should have InternalImageLink",
+		steps: api.MultiStageTestConfigurationLiteral{
+			Test: []api.LiteralTestStep{{
+				From:                 "pipeline:src",
+				ExternalDependencies: []api.ExternalImageDependency{{PullSpec: "quay.io/org/image:tag", Digest: "sha256:abc", Env: "IMAGE"}},
+			}},
+		},
+		req: []api.StepLink{
+			api.InternalImageLink(
+				api.PipelineImageStreamTagReferenceSource),
+			api.InternalImageLink("external-sha256-abc"),
+		},
+	}, {
+		name: "upgrade step needs the payloads of both releases",
+		steps: api.MultiStageTestConfigurationLiteral{
+			Test: []api.LiteralTestStep{{
+				As:      "upgrade",
+				Upgrade: &api.UpgradeStep{From: api.InitialReleaseName, To: api.LatestReleaseName},
+			}},
+		},
+		req: []api.StepLink{
+			api.ReleasePayloadImageLink(api.InitialReleaseName),
+			api.ReleasePayloadImageLink(api.LatestReleaseName),
+		},
+	}, {
+		name: "overridden dependency is not required",
+		steps: api.MultiStageTestConfigurationLiteral{
+			Test: []api.LiteralTestStep{{
+				From:         "pipeline:src",
+				Dependencies: []api.StepDependency{{Name: "pipeline:bin", Env: "BIN"}, {Name: "pipeline:installer", Env: "INSTALLER"}},
+			}},
+		},
+		overrides: DependencyOverrides{"INSTALLER": "quay.io/org/installer:fix"},
+		req: []api.StepLink{
+			api.InternalImageLink(api.PipelineImageStreamTagReferenceSource),
+			api.InternalImageLink(api.PipelineImageStreamTagReferenceBinaries),
+		},
 	}} {
 		t.Run(tc.name, func(t *testing.T) {
 			step := MultiStageTestStep(api.TestStepConfiguration{
 				MultiStageTestConfigurationLiteral: &tc.steps,
-			}, &tc.config, api.NewDeferredParameters(nil), nil, nil, nil)
+			}, &tc.config, api.NewDeferredParameters(nil), nil, nil, nil, nil, nil, tc.overrides)
 			ret := step.Requires()
 			if len(ret) == len(tc.req) {
 				matches := true
@@ -104,25 +150,8 @@ func TestRequires(t *testing.T) {
 	}
 }
 
-func TestGeneratePods(t *testing.T) {
-	config := api.ReleaseBuildConfiguration{
-		Tests: []api.TestStepConfiguration{{
-			As: "test",
-			MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
-				ClusterProfile: api.ClusterProfileAWS,
-				Test: []api.LiteralTestStep{{
-					As: "step0", From: "src", Commands: "command0",
-				}, {
-					As:       "step1",
-					From:     "image1",
-					Commands: "command1",
-				}, {
-					As: "step2", From: "stable-initial:installer", Commands: "command2",
-				}},
-			},
-		}},
-	}
-
+// generatePodsJobSpec is the job the pods of steps are generated for
+func generatePodsJobSpec() api.JobSpec {
 	jobSpec := api.JobSpec{
 		JobSpec: prowdapi.JobSpec{
 			Job:       "job",
@@ -146,19 +175,273 @@ func TestGeneratePods(t *testing.T) {
 		},
 	}
 	jobSpec.SetNamespace("namespace")
-	step := newMultiStageTestStep(config.Tests[0], &config, nil, nil, &jobSpec, nil)
+	return jobSpec
+}
+
+func TestGeneratePods(t *testing.T) {
+	config := api.ReleaseBuildConfiguration{
+		Tests: []api.TestStepConfiguration{{
+			As: "test",
+			MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+				ClusterProfile: api.ClusterProfileAWS,
+				Test: []api.LiteralTestStep{{
+					As: "step0", From: "src", Commands: "command0",
+				}, {
+					As:       "step1",
+					From:     "image1",
+					Commands: "command1",
+				}, {
+					As: "step2", From: "stable-initial:installer", Commands: "command2",
+				}},
+			},
+		}},
+	}
+
+	jobSpec := generatePodsJobSpec()
+	step := newMultiStageTestStep(config.Tests[0], &config, nil, nil, &jobSpec, nil, nil, nil, nil)
 	env := []coreapi.EnvVar{
 		{Name: "RELEASE_IMAGE_INITIAL", Value: "release:initial"},
 		{Name: "RELEASE_IMAGE_LATEST", Value: "release:latest"},
 		{Name: "LEASED_RESOURCE", Value: "uuid"},
 	}
-	ret, _, err := step.generatePods(config.Tests[0].MultiStageTestConfigurationLiteral.Test, env, false)
+	ret, _, err := step.generatePods(context.Background(), config.Tests[0].MultiStageTestConfigurationLiteral.Test, env, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testhelper.CompareWithFixture(t, ret)
+}
+
+func TestGenerateArtifactsPusher(t *testing.T) {
+	credentials := api.CredentialReference{Namespace: "org", Name: "bundles-push"}
+	config := api.ReleaseBuildConfiguration{
+		Tests: []api.TestStepConfiguration{{
+			As: "test",
+			MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+				Test: []api.LiteralTestStep{{
+					As: "step0", From: "src", Commands: "command0",
+					ArtifactsToRegistry: &api.ArtifactsToRegistry{
+						Repository:   "quay.io/org/bundles",
+						ArtifactType: "application/vnd.example.bundle",
+						Files:        []string{"bundle.tar.gz", "bin/*"},
+						Credentials:  credentials,
+					},
+				}},
+			},
+		}},
+	}
+	jobSpec := generatePodsJobSpec()
+	source := &coreapi.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "org", Name: "bundles-push"},
+		Type:       coreapi.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{coreapi.DockerConfigJsonKey: []byte("{}")},
+	}
+	client := &fakePodClient{fakePodExecutor: &fakePodExecutor{LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClient(source))}}
+	step := newMultiStageTestStep(config.Tests[0], &config, nil, client, &jobSpec, nil, nil, nil, nil)
+	if _, err := step.createCredentials(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	copied := &coreapi.Secret{}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "namespace", Name: credentialSecretName("test", credentials)}, copied); err != nil {
+		t.Fatalf("the push credentials of the repository were not copied: %v", err)
+	}
+	ret, _, err := step.generatePods(context.Background(), config.Tests[0].MultiStageTestConfigurationLiteral.Test, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testhelper.CompareWithFixture(t, ret)
+}
+
+func TestGeneratePodsFeatures(t *testing.T) {
+	for _, testCase := range []struct {
+		name   string
+		config api.MultiStageTestConfigurationLiteral
+	}{
+		{
+			name: "shared dir",
+			config: api.MultiStageTestConfigurationLiteral{
+				SharedDir: &api.SharedDirConfiguration{Medium: api.SharedDirMediumMemory, SizeLimit: "10Mi"},
+				Test:      []api.LiteralTestStep{{As: "step0", From: "src", Commands: "command0"}},
+			},
+		},
+		{
+			name: "data dir",
+			config: api.MultiStageTestConfigurationLiteral{
+				DataDir: &api.DataDirConfiguration{Size: "1Ti"},
+				Test:    []api.LiteralTestStep{{As: "step0", From: "src", Commands: "command0"}},
+			},
+		},
+		{
+			name: "workspace",
+			config: api.MultiStageTestConfigurationLiteral{
+				Test: []api.LiteralTestStep{{
+					As:        "step0",
+					From:      "src",
+					Commands:  "command0",
+					Workspace: &api.WorkspaceConfiguration{Size: "100Gi"},
+				}},
+			},
+		},
+		{
+			name: "sidecars",
+			config: api.MultiStageTestConfigurationLiteral{
+				Test: []api.LiteralTestStep{{
+					As:       "step0",
+					From:     "src",
+					Commands: "command0",
+					Sidecars: []api.Sidecar{{
+						Name:      "db",
+						From:      "postgres",
+						Commands:  "postgres -D /tmp/data",
+						Resources: api.ResourceRequirements{Requests: api.ResourceList{"cpu": "100m"}},
+					}},
+				}},
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			config := api.ReleaseBuildConfiguration{
+				Tests: []api.TestStepConfiguration{{As: "test", MultiStageTestConfigurationLiteral: &testCase.config}},
+			}
+			jobSpec := generatePodsJobSpec()
+			step := newMultiStageTestStep(config.Tests[0], &config, nil, nil, &jobSpec, nil, nil, nil, nil)
+			ret, _, err := step.generatePods(context.Background(), testCase.config.Test, nil, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testhelper.CompareWithFixture(t, ret)
+		})
+	}
+}
+
+func TestGenerateScopedServiceAccountPod(t *testing.T) {
+	config := api.ReleaseBuildConfiguration{
+		Tests: []api.TestStepConfiguration{{
+			As: "test",
+			MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+				Test: []api.LiteralTestStep{{
+					As:             "step0",
+					From:           "src",
+					Commands:       "command0",
+					ServiceAccount: &api.StepServiceAccount{},
+				}},
+			},
+		}},
+	}
+	jobSpec := generatePodsJobSpec()
+	step := newMultiStageTestStep(config.Tests[0], &config, nil, nil, &jobSpec, nil, nil, nil, nil)
+	ret, _, err := step.generatePods(context.Background(), config.Tests[0].MultiStageTestConfigurationLiteral.Test, nil, false)
 	if err != nil {
 		t.Fatal(err)
 	}
 	testhelper.CompareWithFixture(t, ret)
 }
 
+func TestWorkspaces(t *testing.T) {
+	jobSpec := api.JobSpec{}
+	jobSpec.SetNamespace("ns")
+	storageClass := "fast"
+	client := &fakePodClient{fakePodExecutor: &fakePodExecutor{LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClient())}}
+	step := newMultiStageTestStep(api.TestStepConfiguration{
+		As: "test",
+		MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+			DataDir: &api.DataDirConfiguration{Size: "1Ti"},
+		},
+	}, &api.ReleaseBuildConfiguration{}, nil, client, &jobSpec, nil, nil, nil, nil)
+	claims, err := step.createWorkspaces(context.Background(), []api.LiteralTestStep{
+		{As: "without"},
+		{As: "with", Workspace: &api.WorkspaceConfiguration{Size: "10Gi", StorageClass: storageClass}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(claims) != 2 {
+		t.Fatalf("expected two claims, got %d", len(claims))
+	}
+	claim := &coreapi.PersistentVolumeClaim{}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "test-data"}, claim); err != nil {
+		t.Fatalf("failed to get data dir claim: %v", err)
+	}
+	if size := claim.Spec.Resources.Requests[coreapi.ResourceStorage]; size.String() != "1Ti" {
+		t.Errorf("expected a 1Ti data dir claim, got %s", size.String())
+	}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "test-with-workspace"}, claim); err != nil {
+		t.Fatalf("failed to get claim: %v", err)
+	}
+	if size := claim.Spec.Resources.Requests[coreapi.ResourceStorage]; size.String() != "10Gi" {
+		t.Errorf("expected a 10Gi claim, got %s", size.String())
+	}
+	if claim.Spec.StorageClassName == nil || *claim.Spec.StorageClassName != storageClass {
+		t.Errorf("expected storage class %s, got %v", storageClass, claim.Spec.StorageClassName)
+	}
+	if err := step.deleteWorkspaces(claims); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "test-with-workspace"}, claim); !kerrors.IsNotFound(err) {
+		t.Errorf("expected claim to be deleted, got %v", err)
+	}
+}
+
+func TestSetupStepRBAC(t *testing.T) {
+	jobSpec := api.JobSpec{}
+	jobSpec.SetNamespace("ns")
+	var objects []runtime.Object
+	for _, name := range []string{"test", "test-scoped"} {
+		// the service accounts already exist, so creating them does not wait for pull secrets
+		objects = append(objects, &coreapi.ServiceAccount{
+			ObjectMeta:       metav1.ObjectMeta{Name: name, Namespace: "ns"},
+			ImagePullSecrets: []v1.LocalObjectReference{{Name: name + "-dockercfg-12345"}},
+		})
+	}
+	client := &fakePodClient{fakePodExecutor: &fakePodExecutor{LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClient(objects...))}}
+	step := newMultiStageTestStep(api.TestStepConfiguration{
+		As: "test",
+		MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+			Test: []api.LiteralTestStep{{As: "shared"}, {
+				As: "scoped",
+				ServiceAccount: &api.StepServiceAccount{Rules: []api.PolicyRule{{
+					APIGroups: []string{""},
+					Resources: []string{"configmaps"},
+					Verbs:     []string{"get", "list"},
+				}}},
+			}},
+		},
+	}, &api.ReleaseBuildConfiguration{}, nil, client, &jobSpec, nil, nil, nil, nil)
+	if err := step.setupRBAC(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	role := &rbacapi.Role{}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "test-scoped"}, role); err != nil {
+		t.Fatalf("failed to get role: %v", err)
+	}
+	expected := []rbacapi.PolicyRule{{
+		APIGroups:     []string{""},
+		Resources:     []string{"secrets"},
+		ResourceNames: []string{"test", "test-sealed"},
+		Verbs:         []string{"get", "update"},
+	}, {
+		APIGroups: []string{"", "image.openshift.io"},
+		Resources: []string{"imagestreams/layers"},
+		Verbs:     []string{"get"},
+	}, {
+		APIGroups: []string{""},
+		Resources: []string{"configmaps"},
+		Verbs:     []string{"get", "list"},
+	}}
+	if diff := cmp.Diff(expected, role.Rules); diff != "" {
+		t.Errorf("unexpected rules: %s", diff)
+	}
+	binding := &rbacapi.RoleBinding{}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "test-scoped"}, binding); err != nil {
+		t.Fatalf("failed to get role binding: %v", err)
+	}
+	if subjects := binding.Subjects; len(subjects) != 1 || subjects[0].Name != "test-scoped" {
+		t.Errorf("expected the role to be bound to the step's service account, got %v", subjects)
+	}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "test-shared"}, &rbacapi.Role{}); !kerrors.IsNotFound(err) {
+		t.Errorf("expected no role for a step without a service account, got %v", err)
+	}
+}
+
 func TestGeneratePodsEnvironment(t *testing.T) {
 	value := "test"
 	defValue := "default"
@@ -224,8 +507,8 @@ func TestGeneratePodsEnvironment(t *testing.T) {
 					Test:        test,
 					Environment: tc.env,
 				},
-			}, &api.ReleaseBuildConfiguration{}, nil, nil, &jobSpec, nil)
-			pods, _, err := step.(*multiStageTestStep).generatePods(test, nil, false)
+			}, &api.ReleaseBuildConfiguration{}, nil, nil, &jobSpec, nil, nil, nil, nil)
+			pods, _, err := step.(*multiStageTestStep).generatePods(context.Background(), test, nil, false)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -242,6 +525,77 @@ func TestGeneratePodsEnvironment(t *testing.T) {
 	}
 }
 
+func TestSecretParameters(t *testing.T) {
+	defValue := "default"
+	jobSpec := api.JobSpec{
+		JobSpec: prowdapi.JobSpec{
+			Job:       "job",
+			BuildID:   "build_id",
+			ProwJobID: "prow_job_id",
+			Type:      prowapi.PeriodicJob,
+			DecorationConfig: &prowapi.DecorationConfig{
+				Timeout:     &prowapi.Duration{Duration: time.Minute},
+				GracePeriod: &prowapi.Duration{Duration: time.Second},
+				UtilityImages: &prowapi.UtilityImages{
+					Sidecar:    "sidecar",
+					Entrypoint: "entrypoint",
+				},
+			},
+		},
+	}
+	jobSpec.SetNamespace("ns")
+	test := []api.LiteralTestStep{{
+		As: "step",
+		Environment: []api.StepParameter{
+			{Name: "TOKEN", Secret: true},
+			{Name: "MODE", Default: &defValue},
+		},
+	}}
+	post := []api.LiteralTestStep{{
+		As:          "post",
+		Environment: []api.StepParameter{{Name: "PASSWORD", Secret: true, Default: &defValue}},
+	}}
+	step := newMultiStageTestStep(api.TestStepConfiguration{
+		As: "test",
+		MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+			Test:        test,
+			Post:        post,
+			Environment: api.TestEnvironment{"TOKEN": "hunter2"},
+		},
+	}, &api.ReleaseBuildConfiguration{}, nil, nil, &jobSpec, nil, nil, nil, nil)
+	pods, _, err := step.generatePods(context.Background(), test, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]coreapi.EnvVar{}
+	for _, v := range pods[0].Spec.Containers[0].Env {
+		env[v.Name] = v
+	}
+	expectedEnv := map[string]coreapi.EnvVar{
+		"TOKEN": {Name: "TOKEN", ValueFrom: &coreapi.EnvVarSource{SecretKeyRef: &coreapi.SecretKeySelector{
+			LocalObjectReference: coreapi.LocalObjectReference{Name: "test-parameters"},
+			Key:                  "parameter.step.TOKEN",
+		}}},
+		"MODE": {Name: "MODE", Value: "default"},
+		"SEALED_KEY": {Name: "SEALED_KEY", ValueFrom: &coreapi.EnvVarSource{SecretKeyRef: &coreapi.SecretKeySelector{
+			LocalObjectReference: coreapi.LocalObjectReference{Name: "test-sealed-key"},
+			Key:                  "key",
+		}}},
+	}
+	for name, expected := range expectedEnv {
+		if diff := cmp.Diff(expected, env[name]); diff != "" {
+			t.Errorf("incorrect variable %s: %s", name, diff)
+		}
+	}
+	expectedParameters := map[string][]byte{
+		"parameter.step.TOKEN":    []byte("hunter2"),
+		"parameter.post.PASSWORD": []byte("default"),
+	}
+	if diff := cmp.Diff(expectedParameters, step.secretParameters(nil, post)); diff != "" {
+		t.Errorf("incorrect secret parameters: %s", diff)
+	}
+}
+
 func TestGeneratePodBestEffort(t *testing.T) {
 	yes := true
 	no := false
@@ -254,6 +608,11 @@ func TestGeneratePodBestEffort(t *testing.T) {
 					As:       "step0",
 					From:     "src",
 					Commands: "command0",
+				}, {
+					As:         "step3",
+					From:       "src",
+					Commands:   "command3",
+					BestEffort: &yes,
 				}},
 				Post: []api.LiteralTestStep{{
 					As:         "step1",
@@ -292,8 +651,8 @@ func TestGeneratePodBestEffort(t *testing.T) {
 		},
 	}
 	jobSpec.SetNamespace("namespace")
-	step := newMultiStageTestStep(config.Tests[0], &config, nil, nil, &jobSpec, nil)
-	_, isBestEffort, err := step.generatePods(config.Tests[0].MultiStageTestConfigurationLiteral.Post, nil, false)
+	step := newMultiStageTestStep(config.Tests[0], &config, nil, nil, &jobSpec, nil, nil, nil, nil)
+	_, isBestEffort, err := step.generatePods(context.Background(), config.Tests[0].MultiStageTestConfigurationLiteral.Post, nil, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -306,11 +665,105 @@ func TestGeneratePodBestEffort(t *testing.T) {
 			t.Errorf("didn't check best-effort status of Pod %s correctly, expected %v", pod, bestEffort)
 		}
 	}
+	_, isBestEffort, err = step.generatePods(context.Background(), config.Tests[0].MultiStageTestConfigurationLiteral.Test, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isBestEffort("test-step3") {
+		t.Error("expected test step to be best-effort")
+	}
+
+	// post steps are only best-effort when the test allows it
+	config.Tests[0].MultiStageTestConfigurationLiteral.AllowBestEffortPostSteps = nil
+	step = newMultiStageTestStep(config.Tests[0], &config, nil, nil, &jobSpec, nil, nil, nil, nil)
+	_, isBestEffort, err = step.generatePods(context.Background(), config.Tests[0].MultiStageTestConfigurationLiteral.Post, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isBestEffort("test-step1") {
+		t.Error("expected post step not to be best-effort")
+	}
+	_, isBestEffort, err = step.generatePods(context.Background(), config.Tests[0].MultiStageTestConfigurationLiteral.Test, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isBestEffort("test-step3") {
+		t.Error("expected test step to be best-effort regardless of the post step setting")
+	}
+}
+
+func TestGeneratePodsCondition(t *testing.T) {
+	fips := "false"
+	config := api.ReleaseBuildConfiguration{
+		Tests: []api.TestStepConfiguration{{
+			As: "test",
+			MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+				ClusterProfile: api.ClusterProfileAWS,
+				Environment:    api.TestEnvironment{"FIPS_ENABLED": "true"},
+				Test: []api.LiteralTestStep{{
+					As:       "aws",
+					From:     "src",
+					Commands: "command",
+					If:       "CLUSTER_TYPE == aws",
+				}, {
+					As:       "gcp",
+					From:     "src",
+					Commands: "command",
+					If:       "CLUSTER_TYPE == gcp",
+				}, {
+					As:          "fips",
+					From:        "src",
+					Commands:    "command",
+					Environment: []api.StepParameter{{Name: "FIPS_ENABLED", Default: &fips}},
+					If:          "FIPS_ENABLED == true",
+				}, {
+					As:       "unconditional",
+					From:     "src",
+					Commands: "command",
+				}},
+			},
+		}},
+	}
+	jobSpec := api.JobSpec{
+		JobSpec: prowdapi.JobSpec{
+			Job:       "job",
+			BuildID:   "build id",
+			ProwJobID: "prow job id",
+			Type:      "periodic",
+			DecorationConfig: &prowapi.DecorationConfig{
+				Timeout:     &prowapi.Duration{Duration: time.Minute},
+				GracePeriod: &prowapi.Duration{Duration: time.Second},
+				UtilityImages: &prowapi.UtilityImages{
+					Sidecar:    "sidecar",
+					Entrypoint: "entrypoint",
+				},
+			},
+		},
+	}
+	jobSpec.SetNamespace("namespace")
+	step := newMultiStageTestStep(config.Tests[0], &config, nil, nil, &jobSpec, nil, nil, nil, nil)
+	pods, _, err := step.generatePods(context.Background(), config.Tests[0].MultiStageTestConfigurationLiteral.Test, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	if diff := cmp.Diff([]string{"test-aws", "test-fips", "test-unconditional"}, names); diff != "" {
+		t.Errorf("unexpected pods: %s", diff)
+	}
 }
 
 type fakePodExecutor struct {
 	loggingclient.LoggingClient
-	failures    sets.String
+	failures sets.String
+	// running pods never complete
+	running sets.String
+	// evictions is how many times pods are evicted before they complete
+	evictions   map[string]int
+	evicted     sets.String
+	lock        sync.Mutex
 	createdPods []*coreapi.Pod
 }
 
@@ -319,7 +772,15 @@ func (f *fakePodExecutor) Create(ctx context.Context, o ctrlruntimeclient.Object
 		if pod.Namespace == "" {
 			return errors.New("pod had no namespace set")
 		}
+		f.lock.Lock()
 		f.createdPods = append(f.createdPods, pod.DeepCopy())
+		if f.evictions[pod.Name] > 0 {
+			f.evictions[pod.Name]--
+			f.evicted.Insert(pod.Name)
+		} else {
+			f.evicted.Delete(pod.Name)
+		}
+		f.lock.Unlock()
 		pod.Status.Phase = coreapi.PodPending
 	}
 	return f.LoggingClient.Create(ctx, o, opts...)
@@ -329,9 +790,17 @@ func (f *fakePodExecutor) Get(ctx context.Context, n ctrlruntimeclient.ObjectKey
 	if err := f.LoggingClient.Get(ctx, n, o); err != nil {
 		return err
 	}
-	if pod, ok := o.(*coreapi.Pod); ok {
-		fail := f.failures.Has(n.Name)
-		if fail {
+	if pod, ok := o.(*coreapi.Pod); ok && f.running.Has(n.Name) {
+		pod.Status.Phase = coreapi.PodRunning
+	} else if ok {
+		f.lock.Lock()
+		evicted := f.evicted.Has(n.Name)
+		f.lock.Unlock()
+		fail := f.failures.Has(n.Name) || evicted
+		if evicted {
+			pod.Status.Phase = coreapi.PodFailed
+			pod.Status.Reason = "Evicted"
+		} else if fail {
 			pod.Status.Phase = coreapi.PodFailed
 		} else {
 			pod.Status.Phase = coreapi.PodSucceeded
@@ -351,11 +820,21 @@ func (f *fakePodExecutor) Get(ctx context.Context, n ctrlruntimeclient.ObjectKey
 }
 
 func TestRun(t *testing.T) {
-	yes := true
+	yes, no := true, 0
 	for _, tc := range []struct {
 		name     string
 		failures sets.String
 		expected []string
+		// bestEffort adds a best-effort test step
+		bestEffort bool
+		// retries lets the second pre step be retried twice
+		retries bool
+		// recovers is set when a retry of the failed step succeeds
+		recovers bool
+		// evictions is how many times pods are evicted
+		evictions map[string]int
+		// disruptionRetries limits how often evicted pods are re-created
+		disruptionRetries *int
 	}{{
 		name: "no step fails, no error",
 		expected: []string{
@@ -386,6 +865,70 @@ func TestRun(t *testing.T) {
 			"test-test0", "test-test1",
 			"test-post0",
 		},
+	}, {
+		name:     "failure in a best-effort test step, following steps should run",
+		failures: sets.NewString("test-test1"),
+		expected: []string{
+			"test-pre0", "test-pre1",
+			"test-test0", "test-test1", "test-test2",
+			"test-post0",
+		},
+		bestEffort: true,
+	}, {
+		name:     "failure in a retried step, the retry succeeds",
+		failures: sets.NewString("test-pre1"),
+		expected: []string{
+			"test-pre0", "test-pre1", "test-pre1-attempt-2",
+			"test-test0", "test-test1",
+			"test-post0",
+		},
+		retries:  true,
+		recovers: true,
+	}, {
+		name:     "failure in all attempts of a retried step, test should not run, post should",
+		failures: sets.NewString("test-pre1", "test-pre1-attempt-2", "test-pre1-attempt-3"),
+		expected: []string{
+			"test-pre0", "test-pre1", "test-pre1-attempt-2", "test-pre1-attempt-3",
+			"test-post0", "test-post1",
+		},
+		retries: true,
+	}, {
+		name:      "evicted pod is re-created once by default",
+		evictions: map[string]int{"test-test0": 1},
+		expected: []string{
+			"test-pre0", "test-pre1",
+			"test-test0", "test-test0", "test-test1",
+			"test-post0",
+		},
+		recovers: true,
+	}, {
+		name:      "pod evicted more often than allowed fails",
+		evictions: map[string]int{"test-test0": 2},
+		expected: []string{
+			"test-pre0", "test-pre1",
+			"test-test0", "test-test0",
+			"test-post0", "test-post1",
+		},
+	}, {
+		name:              "pod evicted with re-creation disabled fails",
+		evictions:         map[string]int{"test-test0": 1},
+		disruptionRetries: &no,
+		expected: []string{
+			"test-pre0", "test-pre1",
+			"test-test0",
+			"test-post0", "test-post1",
+		},
+	}, {
+		name:      "evicted pods do not count against retries",
+		evictions: map[string]int{"test-pre1": 1},
+		failures:  sets.NewString("test-pre1"),
+		expected: []string{
+			"test-pre0", "test-pre1", "test-pre1", "test-pre1-attempt-2",
+			"test-test0", "test-test1",
+			"test-post0",
+		},
+		retries:  true,
+		recovers: true,
 	}} {
 		t.Run(tc.name, func(t *testing.T) {
 			sa := &coreapi.ServiceAccount{
@@ -393,7 +936,7 @@ func TestRun(t *testing.T) {
 				ImagePullSecrets: []v1.LocalObjectReference{{Name: "ci-operator-dockercfg-12345"}},
 			}
 			name := "test"
-			crclient := &fakePodExecutor{LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClient(sa.DeepCopyObject())), failures: tc.failures}
+			crclient := &fakePodExecutor{LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClient(sa.DeepCopyObject())), failures: tc.failures, evictions: tc.evictions, evicted: sets.NewString()}
 			jobSpec := api.JobSpec{
 				JobSpec: prowdapi.JobSpec{
 					Job:       "job",
@@ -411,24 +954,38 @@ func TestRun(t *testing.T) {
 				},
 			}
 			jobSpec.SetNamespace("ns")
+			test := []api.LiteralTestStep{{As: "test0"}, {As: "test1"}}
+			if tc.bestEffort {
+				test = []api.LiteralTestStep{{As: "test0"}, {As: "test1", BestEffort: &yes}, {As: "test2"}}
+			}
+			pre := []api.LiteralTestStep{{As: "pre0"}, {As: "pre1"}}
+			if tc.retries {
+				pre[1].Retries = &api.StepRetries{Count: 2}
+			}
 			step := MultiStageTestStep(api.TestStepConfiguration{
 				As: name,
 				MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
-					Pre:                []api.LiteralTestStep{{As: "pre0"}, {As: "pre1"}},
-					Test:               []api.LiteralTestStep{{As: "test0"}, {As: "test1"}},
+					Pre:                pre,
+					Test:               test,
 					Post:               []api.LiteralTestStep{{As: "post0"}, {As: "post1", OptionalOnSuccess: &yes}},
 					AllowSkipOnSuccess: &yes,
+					DisruptionRetries:  tc.disruptionRetries,
 				},
-			}, &api.ReleaseBuildConfiguration{}, nil, &fakePodClient{fakePodExecutor: crclient}, &jobSpec, nil)
-			if err := step.Run(context.Background()); (err != nil) != (tc.failures != nil) {
-				t.Errorf("expected error: %t, got error: %v", (tc.failures != nil), err)
+			}, &api.ReleaseBuildConfiguration{}, nil, &fakePodClient{fakePodExecutor: crclient}, &jobSpec, nil, nil, nil, nil)
+			expectedErr := (tc.failures != nil || tc.evictions != nil) && !tc.bestEffort && !tc.recovers
+			if err := step.Run(context.Background()); (err != nil) != expectedErr {
+				t.Errorf("expected error: %t, got error: %v", expectedErr, err)
 			}
 			secrets := &coreapi.SecretList{}
 			if err := crclient.List(context.TODO(), secrets, ctrlruntimeclient.InNamespace(jobSpec.Namespace())); err != nil {
 				t.Fatal(err)
 			}
-			if l := secrets.Items; len(l) != 1 || l[0].ObjectMeta.Name != name {
-				t.Errorf("unexpected secrets: %#v", l)
+			var secretNames []string
+			for _, secret := range secrets.Items {
+				secretNames = append(secretNames, secret.Name)
+			}
+			if diff := cmp.Diff([]string{name, name + "-parameters", name + "-sealed", name + "-sealed-key"}, secretNames); diff != "" {
+				t.Errorf("unexpected secrets: %s", diff)
 			}
 			var names []string
 			for _, pod := range crclient.createdPods {
@@ -444,6 +1001,258 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestStepTimedOut(t *testing.T) {
+	start := metav1.NewTime(time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC))
+	pod := func(annotation string, exitCode int32, ran time.Duration) *coreapi.Pod {
+		return &coreapi.Pod{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{annotationStepTimeout: annotation}},
+			Status: coreapi.PodStatus{ContainerStatuses: []coreapi.ContainerStatus{{
+				Name: "sidecar",
+			}, {
+				Name: multiStageTestStepContainerName,
+				State: coreapi.ContainerState{Terminated: &coreapi.ContainerStateTerminated{
+					ExitCode:   exitCode,
+					StartedAt:  start,
+					FinishedAt: metav1.NewTime(start.Add(ran)),
+				}},
+			}}},
+		}
+	}
+	for _, tc := range []struct {
+		name     string
+		pod      *coreapi.Pod
+		expected bool
+	}{{
+		name:     "commands interrupted at the timeout",
+		pod:      pod("1h0m0s", entrypoint.InternalErrorCode, time.Hour+time.Second),
+		expected: true,
+	}, {
+		name: "commands failed before the timeout",
+		pod:  pod("1h0m0s", entrypoint.InternalErrorCode, time.Minute),
+	}, {
+		name: "commands failed after the timeout with another exit code",
+		pod:  pod("1h0m0s", 1, time.Hour+time.Second),
+	}, {
+		name: "pod without a timeout",
+		pod:  pod("", entrypoint.InternalErrorCode, time.Hour+time.Second),
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, actual := stepTimedOut(tc.pod); actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRetryAllowed(t *testing.T) {
+	started := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		retries  *api.StepRetries
+		attempt  int
+		now      time.Time
+		expected bool
+	}{{
+		name:    "step without retries",
+		attempt: 1,
+		now:     started,
+	}, {
+		name:     "attempts left",
+		retries:  &api.StepRetries{Count: 2},
+		attempt:  2,
+		now:      started.Add(time.Hour),
+		expected: true,
+	}, {
+		name:    "no attempts left",
+		retries: &api.StepRetries{Count: 2},
+		attempt: 3,
+		now:     started,
+	}, {
+		name:     "attempts left before the deadline",
+		retries:  &api.StepRetries{Count: 2, Until: &prowapi.Duration{Duration: time.Hour}},
+		attempt:  1,
+		now:      started.Add(time.Minute),
+		expected: true,
+	}, {
+		name:    "attempts left after the deadline",
+		retries: &api.StepRetries{Count: 2, Until: &prowapi.Duration{Duration: time.Hour}},
+		attempt: 1,
+		now:     started.Add(time.Hour),
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := retryAllowed(tc.retries, tc.attempt, started, tc.now); actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAttemptNames(t *testing.T) {
+	step := &multiStageTestStep{name: "test"}
+	for _, tc := range []struct {
+		name                string
+		retries             *api.StepRetries
+		attempt             int
+		expectedName        string
+		expectedArtifactDir string
+	}{{
+		name:                "step without retries",
+		attempt:             1,
+		expectedName:        "test-step",
+		expectedArtifactDir: "test/step",
+	}, {
+		name:                "first attempt of a retried step",
+		retries:             &api.StepRetries{Count: 1},
+		attempt:             1,
+		expectedName:        "test-step",
+		expectedArtifactDir: "test/step/attempt-1",
+	}, {
+		name:                "second attempt of a retried step",
+		retries:             &api.StepRetries{Count: 1},
+		attempt:             2,
+		expectedName:        "test-step-attempt-2",
+		expectedArtifactDir: "test/step/attempt-2",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			name, artifactDir := step.attemptNames(api.LiteralTestStep{As: "step", Retries: tc.retries}, tc.attempt)
+			if name != tc.expectedName {
+				t.Errorf("expected pod %s, got %s", tc.expectedName, name)
+			}
+			if artifactDir != tc.expectedArtifactDir {
+				t.Errorf("expected artifact directory %s, got %s", tc.expectedArtifactDir, artifactDir)
+			}
+		})
+	}
+}
+
+func TestRunObservers(t *testing.T) {
+	sa := &coreapi.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "test", Namespace: "ns", Labels: map[string]string{"ci.openshift.io/multi-stage-test": "test"}},
+		ImagePullSecrets: []v1.LocalObjectReference{{Name: "ci-operator-dockercfg-12345"}},
+	}
+	crclient := &fakePodExecutor{
+		LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClient(sa.DeepCopyObject())),
+		running:       sets.NewString("test-must-gather"),
+	}
+	jobSpec := api.JobSpec{
+		JobSpec: prowdapi.JobSpec{
+			Job:       "job",
+			BuildID:   "build_id",
+			ProwJobID: "prow_job_id",
+			Type:      prowapi.PeriodicJob,
+			DecorationConfig: &prowapi.DecorationConfig{
+				Timeout:     &prowapi.Duration{Duration: time.Minute},
+				GracePeriod: &prowapi.Duration{Duration: time.Second},
+				UtilityImages: &prowapi.UtilityImages{
+					Sidecar:    "sidecar",
+					Entrypoint: "entrypoint",
+				},
+			},
+		},
+	}
+	jobSpec.SetNamespace("ns")
+	step := MultiStageTestStep(api.TestStepConfiguration{
+		As: "test",
+		MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+			Pre:       []api.LiteralTestStep{{As: "pre0"}},
+			Test:      []api.LiteralTestStep{{As: "test0"}},
+			Post:      []api.LiteralTestStep{{As: "post0"}},
+			Observers: []api.Observer{{Name: "must-gather", From: "src", Commands: "gather"}},
+		},
+	}, &api.ReleaseBuildConfiguration{}, nil, &fakePodClient{fakePodExecutor: crclient}, &jobSpec, nil, nil, nil, nil)
+	if err := step.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	names := sets.NewString()
+	for _, pod := range crclient.createdPods {
+		names.Insert(pod.Name)
+	}
+	if diff := cmp.Diff(sets.NewString("test-must-gather", "test-pre0", "test-test0", "test-post0"), names); diff != "" {
+		t.Errorf("did not execute correct pods: %s", diff)
+	}
+	if err := crclient.Get(context.TODO(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "test-must-gather"}, &coreapi.Pod{}); !kerrors.IsNotFound(err) {
+		t.Errorf("expected the observer pod to be deleted when the test finished, got: %v", err)
+	}
+}
+
+func TestRunBYOCluster(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		labels      map[string]string
+		expected    []string
+		expectedErr bool
+	}{{
+		name:        "secret without the interlock label is refused",
+		expectedErr: true,
+	}, {
+		name:     "pre and post steps are skipped",
+		labels:   map[string]string{BYOClusterLabel: "true"},
+		expected: []string{"test-test0"},
+	}, {
+		name:     "post steps run when destructive steps are allowed",
+		labels:   map[string]string{BYOClusterLabel: "true", BYOClusterAllowDestructiveLabel: "true"},
+		expected: []string{"test-test0", "test-post0"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			sa := &coreapi.ServiceAccount{
+				ObjectMeta:       metav1.ObjectMeta{Name: "test", Namespace: "ns", Labels: map[string]string{"ci.openshift.io/multi-stage-test": "test"}},
+				ImagePullSecrets: []v1.LocalObjectReference{{Name: "ci-operator-dockercfg-12345"}},
+			}
+			kubeconfig := &coreapi.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "dev-cluster", Namespace: "team", Labels: tc.labels},
+				Data:       map[string][]byte{BYOClusterKubeconfigKey: []byte("kubeconfig")},
+			}
+			crclient := &fakePodExecutor{LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClient(sa.DeepCopyObject(), kubeconfig))}
+			jobSpec := api.JobSpec{
+				JobSpec: prowdapi.JobSpec{
+					Job:       "job",
+					BuildID:   "build_id",
+					ProwJobID: "prow_job_id",
+					Type:      prowapi.PeriodicJob,
+					DecorationConfig: &prowapi.DecorationConfig{
+						Timeout:     &prowapi.Duration{Duration: time.Minute},
+						GracePeriod: &prowapi.Duration{Duration: time.Second},
+						UtilityImages: &prowapi.UtilityImages{
+							Sidecar:    "sidecar",
+							Entrypoint: "entrypoint",
+						},
+					},
+				},
+			}
+			jobSpec.SetNamespace("ns")
+			step := MultiStageTestStep(api.TestStepConfiguration{
+				As: "test",
+				MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+					Pre:  []api.LiteralTestStep{{As: "pre0"}},
+					Test: []api.LiteralTestStep{{As: "test0"}},
+					Post: []api.LiteralTestStep{{As: "post0"}},
+				},
+			}, &api.ReleaseBuildConfiguration{}, nil, &fakePodClient{fakePodExecutor: crclient}, &jobSpec, nil, &BYOClusterConfig{Namespace: "team", Name: "dev-cluster"}, nil, nil)
+			err := step.Run(context.Background())
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error: %t, got error: %v", tc.expectedErr, err)
+			}
+			if tc.expectedErr {
+				return
+			}
+			shared := &coreapi.Secret{}
+			if err := crclient.Get(context.TODO(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "test"}, shared); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(map[string][]byte{BYOClusterKubeconfigKey: []byte("kubeconfig")}, shared.Data); diff != "" {
+				t.Errorf("unexpected shared directory contents: %s", diff)
+			}
+			var names []string
+			for _, pod := range crclient.createdPods {
+				names = append(names, pod.Name)
+			}
+			if diff := cmp.Diff(tc.expected, names); diff != "" {
+				t.Errorf("did not execute correct pods: %s", diff)
+			}
+		})
+	}
+}
+
 func TestJUnit(t *testing.T) {
 	for _, tc := range []struct {
 		name     string
@@ -517,7 +1326,7 @@ func TestJUnit(t *testing.T) {
 					Test: []api.LiteralTestStep{{As: "test0"}, {As: "test1"}},
 					Post: []api.LiteralTestStep{{As: "post0"}, {As: "post1"}},
 				},
-			}, &api.ReleaseBuildConfiguration{}, nil, &fakePodClient{fakePodExecutor: client}, &jobSpec, nil)
+			}, &api.ReleaseBuildConfiguration{}, nil, &fakePodClient{fakePodExecutor: client}, &jobSpec, nil, nil, nil, nil)
 			if err := step.Run(context.Background()); tc.failures == nil && err != nil {
 				t.Error(err)
 				return
@@ -583,10 +1392,156 @@ func TestAddCredentials(t *testing.T) {
 
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			addCredentials(testCase.credentials, &testCase.pod)
+			addCredentials("test", testCase.credentials, &testCase.pod)
 			if !equality.Semantic.DeepEqual(testCase.pod, testCase.expected) {
 				t.Errorf("%s: got incorrect Pod: %s", testCase.name, cmp.Diff(testCase.pod, testCase.expected))
 			}
 		})
 	}
 }
+
+type fakeVault map[string]map[string]string
+
+func (v fakeVault) GetKV(path string) (*vaultclient.KVData, error) {
+	data, ok := v[path]
+	if !ok {
+		return nil, fmt.Errorf("no secret at %s", path)
+	}
+	return &vaultclient.KVData{Data: data}, nil
+}
+
+func TestVaultCredentials(t *testing.T) {
+	credential := api.CredentialReference{VaultPath: "kv/team/aws", MountPath: "/aws"}
+	var testCases = []struct {
+		name        string
+		vault       VaultClient
+		expectedErr string
+	}{
+		{
+			name:  "credential is materialized from Vault",
+			vault: fakeVault{"kv/team/aws": {"key": "value"}},
+		},
+		{
+			name:        "credential missing from Vault",
+			vault:       fakeVault{},
+			expectedErr: "could not read credential kv/team/aws from Vault: no secret at kv/team/aws",
+		},
+		{
+			name:        "no Vault access",
+			expectedErr: "credential from Vault path kv/team/aws was requested, but no Vault access is configured",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			jobSpec := api.JobSpec{}
+			jobSpec.SetNamespace("ns")
+			client := &fakePodClient{fakePodExecutor: &fakePodExecutor{LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClient())}}
+			step := newMultiStageTestStep(api.TestStepConfiguration{
+				As: "test",
+				MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+					Pre:  []api.LiteralTestStep{{As: "pre", Credentials: []api.CredentialReference{credential}}},
+					Test: []api.LiteralTestStep{{As: "test", Credentials: []api.CredentialReference{credential}}},
+				},
+			}, &api.ReleaseBuildConfiguration{}, nil, client, &jobSpec, nil, nil, testCase.vault, nil)
+			secrets, err := step.createCredentials(context.Background())
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if len(secrets) != 1 {
+				t.Fatalf("expected one secret from Vault, got %d", len(secrets))
+			}
+			name := credentialSecretName("test", credential)
+			secret := &coreapi.Secret{}
+			if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: name}, secret); err != nil {
+				t.Fatalf("failed to get secret: %v", err)
+			}
+			if diff := cmp.Diff(map[string][]byte{"key": []byte("value")}, secret.Data); diff != "" {
+				t.Errorf("unexpected secret data: %s", diff)
+			}
+			if err := step.deleteVaultCredentials(secrets); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: name}, secret); !kerrors.IsNotFound(err) {
+				t.Errorf("expected secret to be deleted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateProfileOverlay(t *testing.T) {
+	jobSpec := api.JobSpec{
+		JobSpec: prowdapi.JobSpec{
+			Job:       "job",
+			BuildID:   "build id",
+			ProwJobID: "prow job id",
+			Type:      "periodic",
+			DecorationConfig: &prowapi.DecorationConfig{
+				UtilityImages: &prowapi.UtilityImages{
+					Sidecar:    "sidecar",
+					Entrypoint: "entrypoint",
+				},
+			},
+		},
+	}
+	jobSpec.SetNamespace("ns")
+	client := &fakePodClient{fakePodExecutor: &fakePodExecutor{LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClient(
+		&coreapi.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "test-cluster-profile"}, Data: map[string][]byte{"pull-secret": []byte("profile"), "ssh-publickey": []byte("key")}},
+		&coreapi.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "pull-secret"}, Data: map[string][]byte{"pull-secret": []byte("custom")}},
+		&coreapi.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "accounts"}, Data: map[string]string{"extra-account": "123"}},
+	))}}
+	params := api.NewDeferredParameters(nil)
+	for _, env := range envForProfile {
+		params.Set(env, "value")
+	}
+	step := newMultiStageTestStep(api.TestStepConfiguration{
+		As: "test",
+		MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+			ClusterProfile: api.ClusterProfileAWS,
+			ClusterProfileOverlay: &api.ClusterProfileOverlay{
+				Secrets:     []api.ClusterProfileOverlaySource{{Namespace: "team", Name: "pull-secret"}},
+				ConfigMaps:  []api.ClusterProfileOverlaySource{{Namespace: "team", Name: "accounts"}},
+				Environment: api.TestEnvironment{"EXTRA_ACCOUNT": "true"},
+			},
+			Test: []api.LiteralTestStep{{As: "step", From: "src", Commands: "command"}},
+		},
+	}, &api.ReleaseBuildConfiguration{}, params, client, &jobSpec, nil, nil, nil, nil)
+	if err := step.createProfileOverlay(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	overlay := &coreapi.Secret{}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "test-cluster-profile-overlay"}, overlay); err != nil {
+		t.Fatalf("failed to get the overlay: %v", err)
+	}
+	expected := map[string][]byte{"pull-secret": []byte("custom"), "ssh-publickey": []byte("key"), "extra-account": []byte("123")}
+	if diff := cmp.Diff(expected, overlay.Data); diff != "" {
+		t.Errorf("unexpected overlay data: %s", diff)
+	}
+
+	env, err := step.environment(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(coreapi.EnvVar{Name: "EXTRA_ACCOUNT", Value: "true"}, env[len(env)-1]); diff != "" {
+		t.Errorf("expected the overlay parameters in the environment: %s", diff)
+	}
+	pod, err := step.generatePod(step.test[0], env, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var mounted string
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == "cluster-profile" {
+			mounted = volume.Secret.SecretName
+		}
+	}
+	if mounted != "test-cluster-profile-overlay" {
+		t.Errorf("expected the overlay to be mounted as the cluster profile, got %q", mounted)
+	}
+}
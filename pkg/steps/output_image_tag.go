@@ -3,7 +3,6 @@ package steps
 import (
 	"context"
 	"fmt"
-	"log"
 
 	coreapi "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -35,15 +34,15 @@ func (s *outputImageTagStep) Inputs() (api.InputDefinition, error) {
 func (*outputImageTagStep) Validate() error { return nil }
 
 func (s *outputImageTagStep) Run(ctx context.Context) error {
-	return results.ForReason("tagging_output_image").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonTaggingOutputImage).ForError(s.run(ctx))
 }
 
 func (s *outputImageTagStep) run(ctx context.Context) error {
 	toNamespace := s.namespace()
 	if string(s.config.From) == s.config.To.Tag && toNamespace == s.jobSpec.Namespace() && s.config.To.Name == api.StableImageStream {
-		log.Printf("Tagging %s into %s", s.config.From, s.config.To.Name)
+		Logger(ctx).Infof("Tagging %s into %s", s.config.From, s.config.To.Name)
 	} else {
-		log.Printf("Tagging %s into %s/%s:%s", s.config.From, toNamespace, s.config.To.Name, s.config.To.Tag)
+		Logger(ctx).Infof("Tagging %s into %s/%s:%s", s.config.From, toNamespace, s.config.To.Name, s.config.To.Tag)
 	}
 	from := &imagev1.ImageStreamTag{}
 	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{
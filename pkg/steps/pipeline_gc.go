@@ -0,0 +1,162 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildapi "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// SharedBuildAnnotation is set on builds an execution reused instead of
+// creating them, so the execution which built them keeps their output
+const SharedBuildAnnotation = "ci.openshift.io/shared-build"
+
+// PipelineGarbageCollector deletes tags of the pipeline image stream as soon
+// as no step which has yet to finish requires them, so long executions do not
+// keep every intermediate image around until the namespace is deleted.
+// The namespace is shared by every job with the same inputs, so only tags
+// this execution built are deleted, and only while no other execution has
+// reused the build.
+type PipelineGarbageCollector struct {
+	client    ctrlruntimeclient.Client
+	namespace string
+
+	lock sync.Mutex
+	// pending are the steps which have not finished yet
+	pending map[string]api.Step
+	// built maps the tags built by this execution to their builds
+	built     map[string]string
+	collected sets.String
+}
+
+// NewPipelineGarbageCollector tracks the steps of the graph and the post
+// steps. Tags the post steps require are kept until they finish.
+func NewPipelineGarbageCollector(client ctrlruntimeclient.Client, namespace string, nodes []*api.StepNode, postSteps []api.Step) *PipelineGarbageCollector {
+	pending := map[string]api.Step{}
+	api.IterateAllEdges(nodes, func(n *api.StepNode) {
+		pending[n.Step.Name()] = n.Step
+	})
+	for _, step := range postSteps {
+		pending[step.Name()] = step
+	}
+	return &PipelineGarbageCollector{
+		client:    client,
+		namespace: namespace,
+		pending:   pending,
+		built:     map[string]string{},
+		collected: sets.NewString(),
+	}
+}
+
+type pipelineGarbageCollectorKey struct{}
+
+// WithPipelineGarbageCollector returns a context in which the builds created
+// by the steps are recorded for the collector
+func WithPipelineGarbageCollector(ctx context.Context, collector *PipelineGarbageCollector) context.Context {
+	return context.WithValue(ctx, pipelineGarbageCollectorKey{}, collector)
+}
+
+func pipelineGarbageCollectorFrom(ctx context.Context) *PipelineGarbageCollector {
+	collector, _ := ctx.Value(pipelineGarbageCollectorKey{}).(*PipelineGarbageCollector)
+	return collector
+}
+
+// recordBuilt records that this execution created the build, making its
+// output in the pipeline image stream eligible for deletion
+func recordBuilt(ctx context.Context, build *buildapi.Build) {
+	collector := pipelineGarbageCollectorFrom(ctx)
+	if collector == nil || build.Spec.Output.To == nil || build.Spec.Output.To.Kind != "ImageStreamTag" {
+		return
+	}
+	parts := strings.SplitN(build.Spec.Output.To.Name, ":", 2)
+	if len(parts) != 2 || parts[0] != api.PipelineImageStream {
+		return
+	}
+	collector.lock.Lock()
+	defer collector.lock.Unlock()
+	collector.built[parts[1]] = build.Name
+}
+
+// markShared records on a build created by another execution that this one
+// relies on its output
+func markShared(ctx context.Context, client ctrlruntimeclient.Client, build *buildapi.Build) error {
+	if build.Annotations[SharedBuildAnnotation] == "true" {
+		return nil
+	}
+	original := build.DeepCopy()
+	if build.Annotations == nil {
+		build.Annotations = map[string]string{}
+	}
+	build.Annotations[SharedBuildAnnotation] = "true"
+	return client.Patch(ctx, build, ctrlruntimeclient.MergeFrom(original))
+}
+
+// StepFinished deletes the tags the step was the last to require
+func (c *PipelineGarbageCollector) StepFinished(name string, _ error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.pending[name]; !ok {
+		return
+	}
+	delete(c.pending, name)
+	ctx := context.Background()
+	if err := c.collect(ctx); err != nil {
+		Logger(ctx).Warnf("Could not delete pipeline images no longer required: %v", err)
+	}
+}
+
+func (c *PipelineGarbageCollector) collect(ctx context.Context) error {
+	var required []api.StepLink
+	for _, step := range c.pending {
+		required = append(required, step.Requires()...)
+	}
+	// steps waiting for all images may use any of them through parameters
+	for _, link := range required {
+		if link.SatisfiedBy(api.ImagesReadyLink()) {
+			return nil
+		}
+	}
+	stream := &imagev1.ImageStream{}
+	if err := c.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: c.namespace, Name: api.PipelineImageStream}, stream); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("could not get the %s image stream: %w", api.PipelineImageStream, err)
+	}
+	for _, tag := range stream.Status.Tags {
+		buildName, built := c.built[tag.Tag]
+		if !built || c.collected.Has(tag.Tag) {
+			continue
+		}
+		link := api.InternalImageLink(api.PipelineImageStreamTagReference(tag.Tag))
+		if api.HasAnyLinks(required, []api.StepLink{link}) {
+			continue
+		}
+		build := &buildapi.Build{}
+		if err := c.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: c.namespace, Name: buildName}, build); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("could not get build %s: %w", buildName, err)
+		}
+		if build.Annotations[SharedBuildAnnotation] == "true" {
+			Logger(ctx).Debugf("Keeping %s:%s, another execution relies on it", api.PipelineImageStream, tag.Tag)
+			c.collected.Insert(tag.Tag)
+			continue
+		}
+		Logger(ctx).Infof("Deleting %s:%s, no remaining step requires it", api.PipelineImageStream, tag.Tag)
+		ist := &imagev1.ImageStreamTag{ObjectMeta: metav1.ObjectMeta{Namespace: c.namespace, Name: fmt.Sprintf("%s:%s", api.PipelineImageStream, tag.Tag)}}
+		if err := c.client.Delete(ctx, ist); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("could not delete %s: %w", ist.Name, err)
+		}
+		c.collected.Insert(tag.Tag)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package steps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	buildapi "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+type deleteRecordingClient struct {
+	ctrlruntimeclient.Client
+	deleted []string
+}
+
+func (c *deleteRecordingClient) Delete(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.DeleteOption) error {
+	c.deleted = append(c.deleted, obj.GetName())
+	return nil
+}
+
+func TestPipelineGarbageCollector(t *testing.T) {
+	link := func(tag string) api.StepLink {
+		return api.InternalImageLink(api.PipelineImageStreamTagReference(tag))
+	}
+	graph := func() []*fakeStep {
+		return []*fakeStep{
+			{name: "root", creates: []api.StepLink{link("root")}},
+			{name: "src", requires: []api.StepLink{link("root")}, creates: []api.StepLink{link("src")}},
+			{name: "bin", requires: []api.StepLink{link("src")}, creates: []api.StepLink{link("bin")}},
+			{name: "unit", requires: []api.StepLink{link("src")}},
+			{name: "e2e", requires: []api.StepLink{link("bin")}},
+		}
+	}
+	var testCases = []struct {
+		name      string
+		steps     []*fakeStep
+		postSteps []api.Step
+		finished  []string
+		// shared are the builds other executions reuse
+		shared   []string
+		expected [][]string
+	}{
+		{
+			name:     "tags are deleted once the last step requiring them finishes",
+			steps:    graph(),
+			finished: []string{"root", "src", "bin", "unit", "e2e"},
+			expected: [][]string{nil, {"pipeline:root"}, {"pipeline:root"}, {"pipeline:root", "pipeline:src"}, {"pipeline:root", "pipeline:src", "pipeline:bin"}},
+		},
+		{
+			name:      "tags required by post steps are kept until they finish",
+			steps:     graph(),
+			postSteps: []api.Step{&fakeStep{name: "promotion", requires: []api.StepLink{link("bin")}}},
+			finished:  []string{"root", "src", "bin", "unit", "e2e", "promotion"},
+			expected:  [][]string{nil, {"pipeline:root"}, {"pipeline:root"}, {"pipeline:root", "pipeline:src"}, {"pipeline:root", "pipeline:src"}, {"pipeline:root", "pipeline:src", "pipeline:bin"}},
+		},
+		{
+			name:     "no tags are deleted while a step waits for all images",
+			steps:    append(graph(), &fakeStep{name: "template", requires: []api.StepLink{api.ImagesReadyLink()}}),
+			finished: []string{"root", "src", "template"},
+			expected: [][]string{nil, nil, {"pipeline:root"}},
+		},
+		{
+			name:     "tags of builds other executions reuse are kept",
+			steps:    graph(),
+			shared:   []string{"src"},
+			finished: []string{"root", "src", "bin", "unit", "e2e"},
+			expected: [][]string{nil, {"pipeline:root"}, {"pipeline:root"}, {"pipeline:root"}, {"pipeline:root", "pipeline:bin"}},
+		},
+		{
+			name:     "steps which are not in the graph are ignored",
+			steps:    graph(),
+			finished: []string{"root", "other"},
+			expected: [][]string{nil, nil},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			stream := &imagev1.ImageStream{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: api.PipelineImageStream},
+				Status: imagev1.ImageStreamStatus{
+					// base was not built by this execution
+					Tags: []imagev1.NamedTagEventList{{Tag: "base"}, {Tag: "root"}, {Tag: "src"}, {Tag: "bin"}},
+				},
+			}
+			objects := []runtime.Object{stream}
+			for _, name := range testCase.shared {
+				objects = append(objects, &buildapi.Build{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name, Annotations: map[string]string{SharedBuildAnnotation: "true"}}})
+			}
+			scheme := runtime.NewScheme()
+			for _, add := range []func(*runtime.Scheme) error{imagev1.AddToScheme, buildapi.AddToScheme} {
+				if err := add(scheme); err != nil {
+					t.Fatal(err)
+				}
+			}
+			client := &deleteRecordingClient{Client: fakectrlruntimeclient.NewFakeClientWithScheme(scheme, objects...)}
+			var nodes []*api.StepNode
+			for _, step := range testCase.steps {
+				nodes = append(nodes, &api.StepNode{Step: step})
+			}
+			collector := NewPipelineGarbageCollector(client, "ns", nodes, testCase.postSteps)
+			ctx := WithPipelineGarbageCollector(context.Background(), collector)
+			for _, tag := range []string{"root", "src", "bin"} {
+				recordBuilt(ctx, &buildapi.Build{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: tag},
+					Spec: buildapi.BuildSpec{CommonSpec: buildapi.CommonSpec{Output: buildapi.BuildOutput{
+						To: &coreapi.ObjectReference{Kind: "ImageStreamTag", Name: "pipeline:" + tag},
+					}}},
+				})
+			}
+			var actual [][]string
+			for _, name := range testCase.finished {
+				collector.StepFinished(name, nil)
+				actual = append(actual, append([]string(nil), client.deleted...))
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected deletions: %s", diff)
+			}
+		})
+	}
+}
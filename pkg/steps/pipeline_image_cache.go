@@ -35,7 +35,7 @@ func (s *pipelineImageCacheStep) Inputs() (api.InputDefinition, error) {
 func (*pipelineImageCacheStep) Validate() error { return nil }
 
 func (s *pipelineImageCacheStep) Run(ctx context.Context) error {
-	return results.ForReason("building_cache_image").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonBuildingCacheImage).ForError(s.run(ctx))
 }
 
 func (s *pipelineImageCacheStep) run(ctx context.Context) error {
@@ -3,13 +3,14 @@ package steps
 import (
 	"context"
 	"fmt"
-	"log"
+	"path/filepath"
 
 	coreapi "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/clonerefs"
 	"k8s.io/test-infra/prow/pod-utils/decorate"
 	"k8s.io/test-infra/prow/pod-utils/downwardapi"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -23,6 +24,10 @@ const (
 	testSecretVolumePrefix = "test-secret"
 	testSecretDefaultPath  = "/usr/test-secrets"
 	homeVolumeName         = "home"
+	codeVolumeName         = "code"
+	cloneAuthVolumeName    = "clone-auth"
+	cloneAuthMountPath     = "/etc/clone-auth"
+	cloneRefsContainerName = "clonerefs"
 
 	openshiftCIEnv = "OPENSHIFT_CI"
 )
@@ -47,6 +52,12 @@ type PodStepConfiguration struct {
 	ServiceAccountName string
 	Secrets            []*api.Secret
 	MemoryBackedVolume *api.MemoryBackedVolume
+	// Clone clones the source of the job into the pod before the test
+	// starts, for pods which run from an image without the source
+	Clone           bool
+	CloneAuthConfig *CloneAuthConfig
+	// Clonerefs is the image and path of the tool the source is cloned with
+	Clonerefs ClonerefsOverride
 }
 
 type podStep struct {
@@ -66,12 +77,12 @@ func (s *podStep) Inputs() (api.InputDefinition, error) {
 func (*podStep) Validate() error { return nil }
 
 func (s *podStep) Run(ctx context.Context) error {
-	return results.ForReason("running_pod").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonRunningPod).ForError(s.run(ctx))
 }
 
 func (s *podStep) run(ctx context.Context) error {
 	if !s.config.SkipLogs {
-		log.Printf("Executing %s %s", s.name, s.config.As)
+		Logger(ctx).Infof("Executing %s %s", s.name, s.config.As)
 	}
 	containerResources, err := resourcesFor(s.resources.RequirementsForStep(s.config.As))
 	if err != nil {
@@ -83,7 +94,16 @@ func (s *podStep) run(ctx context.Context) error {
 	}
 	image := fmt.Sprintf("%s:%s", s.config.From.Name, s.config.From.Tag)
 
-	pod, err := s.generatePodForStep(image, containerResources)
+	var clonerefsImage string
+	if s.config.Clone {
+		clonerefsRef, err := istObjectReference(ctx, s.client, s.config.Clonerefs.Image)
+		if err != nil {
+			return fmt.Errorf("could not resolve clonerefs source: %w", err)
+		}
+		clonerefsImage = clonerefsRef.Name
+	}
+
+	pod, err := s.generatePodForStep(image, clonerefsImage, containerResources)
 	if err != nil {
 		return fmt.Errorf("pod step was invalid: %w", err)
 	}
@@ -95,13 +115,13 @@ func (s *podStep) run(ctx context.Context) error {
 
 	go func() {
 		<-ctx.Done()
-		log.Printf("cleanup: Deleting %s pod %s", s.name, s.config.As)
+		Logger(ctx).Infof("cleanup: Deleting %s pod %s", s.name, s.config.As)
 		if err := s.client.Delete(cleanupCtx, &coreapi.Pod{ObjectMeta: meta.ObjectMeta{Namespace: s.jobSpec.Namespace(), Name: s.config.As}}); err != nil && !kerrors.IsNotFound(err) {
-			log.Printf("error: Could not delete %s pod: %v", s.name, err)
+			Logger(ctx).Errorf("Could not delete %s pod: %v", s.name, err)
 		}
 	}()
 
-	pod, err = createOrRestartPod(s.client, pod)
+	pod, err = createOrRestartPod(ctx, s.client, pod)
 	if err != nil {
 		return fmt.Errorf("failed to create or restart %s pod: %w", s.name, err)
 	}
@@ -161,6 +181,30 @@ func TestStep(config api.TestStepConfiguration, resources api.ResourceConfigurat
 	)
 }
 
+// ClonedTestStep runs a container test from the build root with the source
+// cloned into the pod, instead of from the "src" image
+func ClonedTestStep(config api.TestStepConfiguration, resources api.ResourceConfiguration, client PodClient, jobSpec *api.JobSpec, cloneAuthConfig *CloneAuthConfig, clonerefs *ClonerefsOverride) api.Step {
+	if clonerefs == nil {
+		clonerefs = &DefaultClonerefs
+	}
+	return PodStep(
+		"test",
+		PodStepConfiguration{
+			As:                 config.As,
+			From:               api.ImageStreamTagReference{Name: api.PipelineImageStream, Tag: string(api.PipelineImageStreamTagReferenceRoot)},
+			Commands:           config.Commands,
+			Secrets:            config.Secrets,
+			MemoryBackedVolume: config.ContainerTestConfiguration.MemoryBackedVolume,
+			Clone:              true,
+			CloneAuthConfig:    cloneAuthConfig,
+			Clonerefs:          *clonerefs,
+		},
+		resources,
+		client,
+		jobSpec,
+	)
+}
+
 func PodStep(name string, config PodStepConfiguration, resources api.ResourceConfiguration, client PodClient, jobSpec *api.JobSpec) api.Step {
 	return &podStep{
 		name:      name,
@@ -187,6 +231,9 @@ func generateBasePod(
 	if err != nil {
 		return nil, err
 	}
+	for name, value := range api.GerritEnv(jobSpec.Refs) {
+		envMap[name] = value
+	}
 	pod := &coreapi.Pod{
 		ObjectMeta: meta.ObjectMeta{
 			Namespace: jobSpec.Namespace(),
@@ -218,7 +265,7 @@ func generateBasePod(
 	return pod, nil
 }
 
-func (s *podStep) generatePodForStep(image string, containerResources coreapi.ResourceRequirements) (*coreapi.Pod, error) {
+func (s *podStep) generatePodForStep(image, clonerefsImage string, containerResources coreapi.ResourceRequirements) (*coreapi.Pod, error) {
 	artifactDir := s.name
 	pod, err := generateBasePod(s.jobSpec, s.config.As, s.name, []string{"/bin/bash", "-c", "#!/bin/bash\nset -eu\n" + s.config.Commands}, image, containerResources, artifactDir, s.jobSpec.DecorationConfig, s.jobSpec.RawSpec())
 	if err != nil {
@@ -252,9 +299,75 @@ func (s *podStep) generatePodForStep(image string, containerResources coreapi.Re
 		})
 	}
 
+	if s.config.Clone {
+		if err := addCloneRefs(pod, s.jobSpec, s.config.CloneAuthConfig, clonerefsImage, s.config.Clonerefs.Path); err != nil {
+			return nil, err
+		}
+	}
+	if err := censorSecretsInSidecar(pod); err != nil {
+		return nil, err
+	}
+
 	return pod, nil
 }
 
+// addCloneRefs clones the refs of the job into the pod with an init
+// container running the tool from the image, so the test starts in the
+// repository like it would in the "src" image
+func addCloneRefs(pod *coreapi.Pod, jobSpec *api.JobSpec, cloneAuthConfig *CloneAuthConfig, image, path string) error {
+	var hostFingerprints []string
+	if jobSpec.DecorationConfig != nil {
+		hostFingerprints = jobSpec.DecorationConfig.SSHHostFingerprints
+	}
+	refs := refsToClone(jobSpec, cloneAuthConfig)
+	options := clonerefs.Options{
+		SrcRoot:          gopath,
+		Log:              "/dev/null",
+		GitUserName:      "ci-robot",
+		GitUserEmail:     "ci-robot@openshift.io",
+		GitRefs:          refs,
+		HostFingerprints: hostFingerprints,
+		Fail:             true,
+	}
+	codeMount := coreapi.VolumeMount{Name: codeVolumeName, MountPath: filepath.Join(gopath, "src")}
+	mounts := []coreapi.VolumeMount{codeMount}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, coreapi.Volume{
+		Name:         codeVolumeName,
+		VolumeSource: coreapi.VolumeSource{EmptyDir: &coreapi.EmptyDirVolumeSource{}},
+	})
+	if cloneAuthConfig != nil && cloneAuthConfig.Secret != nil {
+		// ssh refuses keys which others may read
+		mode := int32(0400)
+		mounts = append(mounts, coreapi.VolumeMount{Name: cloneAuthVolumeName, MountPath: cloneAuthMountPath, ReadOnly: true})
+		pod.Spec.Volumes = append(pod.Spec.Volumes, coreapi.Volume{
+			Name:         cloneAuthVolumeName,
+			VolumeSource: coreapi.VolumeSource{Secret: &coreapi.SecretVolumeSource{SecretName: cloneAuthConfig.Secret.Name, DefaultMode: &mode}},
+		})
+		if cloneAuthConfig.Type == CloneAuthTypeSSH {
+			options.KeyFiles = append(options.KeyFiles, filepath.Join(cloneAuthMountPath, coreapi.SSHAuthPrivateKey))
+		} else {
+			options.OauthTokenFile = filepath.Join(cloneAuthMountPath, OauthSecretKey)
+		}
+	}
+	optionsJSON, err := clonerefs.Encode(options)
+	if err != nil {
+		return fmt.Errorf("couldn't create JSON spec for clonerefs: %w", err)
+	}
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, coreapi.Container{
+		Name:                     cloneRefsContainerName,
+		Image:                    image,
+		Command:                  []string{path},
+		Env:                      []coreapi.EnvVar{{Name: clonerefs.JSONConfigEnvVar, Value: optionsJSON}},
+		VolumeMounts:             mounts,
+		TerminationMessagePolicy: coreapi.TerminationMessageFallbackToLogsOnError,
+	})
+	container := &pod.Spec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, codeMount)
+	container.WorkingDir = decorate.DetermineWorkDir(gopath, refs)
+	container.Env = append(container.Env, coreapi.EnvVar{Name: "GOPATH", Value: gopath})
+	return nil
+}
+
 func getVolumeFromSecret(secretName string, secretIndex int) []coreapi.Volume {
 	volumeName := testSecretVolumePrefix
 	if secretIndex > 0 {
@@ -301,7 +414,7 @@ func getSecretVolumeMountFromSecret(secretMountPath string, secretIndex int) []c
 // This pod will not be able to gather artifacts, nor will it report log messages
 // unless it fails.
 func RunPod(ctx context.Context, podClient PodClient, pod *coreapi.Pod) (*coreapi.Pod, error) {
-	pod, err := createOrRestartPod(podClient, pod)
+	pod, err := createOrRestartPod(ctx, podClient, pod)
 	if err != nil {
 		return pod, err
 	}
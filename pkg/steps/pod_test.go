@@ -5,7 +5,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
+
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/pod-utils/downwardapi"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -209,7 +212,7 @@ func TestGetPodObjectMounts(t *testing.T) {
 			podStepTemplate := expectedPodStepTemplate()
 			tc.podStep(podStepTemplate)
 
-			pod, err := podStepTemplate.generatePodForStep("", corev1.ResourceRequirements{})
+			pod, err := podStepTemplate.generatePodForStep("", "", corev1.ResourceRequirements{})
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
 			}
@@ -221,6 +224,68 @@ func TestGetPodObjectMounts(t *testing.T) {
 
 }
 
+func TestGeneratePodForStepWithClone(t *testing.T) {
+	testCases := []struct {
+		name            string
+		cloneAuthConfig *CloneAuthConfig
+		clonerefs       ClonerefsOverride
+	}{
+		{
+			name:      "without authentication",
+			clonerefs: DefaultClonerefs,
+		},
+		{
+			name:            "with OAuth authentication",
+			cloneAuthConfig: &CloneAuthConfig{Type: CloneAuthTypeOAuth, Secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "oauth-secret"}}},
+			clonerefs:       DefaultClonerefs,
+		},
+		{
+			name:            "with SSH authentication",
+			cloneAuthConfig: &CloneAuthConfig{Type: CloneAuthTypeSSH, Secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "ssh-secret"}}},
+			clonerefs:       DefaultClonerefs,
+		},
+		{
+			name:      "with a clonerefs override",
+			clonerefs: ClonerefsOverride{Image: api.ImageStreamTagReference{Namespace: "mirror", Name: "prow-utils", Tag: "latest"}, Path: "/usr/bin/clonerefs"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := expectedPodStepTemplate()
+			s.jobSpec.Refs = &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "base-sha"}
+			s.config.Clone = true
+			s.config.CloneAuthConfig = tc.cloneAuthConfig
+			s.config.Clonerefs = tc.clonerefs
+
+			pod, err := s.generatePodForStep("", "registry.ci.openshift.org/"+tc.clonerefs.Image.Namespace+"/"+tc.clonerefs.Image.Name+"@sha256:clonerefs", corev1.ResourceRequirements{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			testhelper.CompareWithFixture(t, pod)
+		})
+	}
+}
+
+func TestClonedTestStep(t *testing.T) {
+	override := &ClonerefsOverride{Image: api.ImageStreamTagReference{Namespace: "mirror", Name: "clonerefs", Tag: "latest"}, Path: "/clonerefs"}
+	for _, tc := range []struct {
+		name      string
+		clonerefs *ClonerefsOverride
+		expected  ClonerefsOverride
+	}{
+		{name: "default image", expected: DefaultClonerefs},
+		{name: "overridden image", clonerefs: override, expected: *override},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := api.TestStepConfiguration{As: "unit", ContainerTestConfiguration: &api.ContainerTestConfiguration{From: api.PipelineImageStreamTagReferenceSource}}
+			step := ClonedTestStep(config, api.ResourceConfiguration{}, nil, &api.JobSpec{}, nil, tc.clonerefs).(*podStep)
+			if diff := cmp.Diff(tc.expected, step.config.Clonerefs); diff != "" {
+				t.Errorf("unexpected clonerefs: %s", diff)
+			}
+		})
+	}
+}
+
 func expectedPodStepTemplate() *podStep {
 	s := &podStep{
 		jobSpec: &api.JobSpec{
@@ -0,0 +1,27 @@
+package steps
+
+import (
+	"context"
+
+	coreapi "k8s.io/api/core/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewPriorityClassClient returns a client that sets the priority class on
+// the pods it creates which do not request one, so that every workload of a
+// job is scheduled with the priority configured for it.
+func NewPriorityClassClient(upstream ctrlruntimeclient.Client, priorityClassName string) ctrlruntimeclient.Client {
+	return &priorityClassClient{Client: upstream, priorityClassName: priorityClassName}
+}
+
+type priorityClassClient struct {
+	ctrlruntimeclient.Client
+	priorityClassName string
+}
+
+func (c *priorityClassClient) Create(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.CreateOption) error {
+	if pod, ok := obj.(*coreapi.Pod); ok && pod.Spec.PriorityClassName == "" && pod.Spec.Priority == nil {
+		pod.Spec.PriorityClassName = c.priorityClassName
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
@@ -0,0 +1,50 @@
+package steps
+
+import (
+	"context"
+	"testing"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPriorityClassClient(t *testing.T) {
+	priority := int32(1000)
+	var testCases = []struct {
+		name     string
+		spec     coreapi.PodSpec
+		expected string
+	}{
+		{
+			name:     "pod without priority gets the configured class",
+			expected: "release-blocking",
+		},
+		{
+			name:     "pod requesting a class keeps it",
+			spec:     coreapi.PodSpec{PriorityClassName: "system-node-critical"},
+			expected: "system-node-critical",
+		},
+		{
+			name: "pod with an explicit priority is left alone",
+			spec: coreapi.PodSpec{Priority: &priority},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := NewPriorityClassClient(fakectrlruntimeclient.NewClientBuilder().Build(), "release-blocking")
+			pod := &coreapi.Pod{ObjectMeta: meta.ObjectMeta{Namespace: "ns", Name: "pod"}, Spec: testCase.spec}
+			if err := client.Create(context.Background(), pod); err != nil {
+				t.Fatalf("failed to create pod: %v", err)
+			}
+			created := &coreapi.Pod{}
+			if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "pod"}, created); err != nil {
+				t.Fatalf("failed to get pod: %v", err)
+			}
+			if created.Spec.PriorityClassName != testCase.expected {
+				t.Errorf("expected priority class %q, got %q", testCase.expected, created.Spec.PriorityClassName)
+			}
+		})
+	}
+}
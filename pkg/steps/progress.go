@@ -0,0 +1,72 @@
+package steps
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/api/nsttl"
+)
+
+// Progress tracks the execution of the graph to report it in heartbeats
+type Progress struct {
+	lock     sync.Mutex
+	steps    []api.Step
+	finished sets.String
+	seen     []api.StepLink
+	started  time.Time
+}
+
+// NewProgress tracks the execution of the graph, which started at the time
+func NewProgress(nodes []*api.StepNode, started time.Time) *Progress {
+	p := &Progress{finished: sets.NewString(), started: started}
+	api.IterateAllEdges(nodes, func(n *api.StepNode) {
+		p.steps = append(p.steps, n.Step)
+	})
+	return p
+}
+
+// StepFinished is notified when a step of the graph finishes
+func (p *Progress) StepFinished(name string, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.finished.Insert(name)
+	if err != nil {
+		return
+	}
+	for _, step := range p.steps {
+		if step.Name() == name {
+			p.seen = append(p.seen, step.Creates()...)
+		}
+	}
+}
+
+// Heartbeat reports the steps running at the time, which are the ones whose
+// requirements were all created, and estimates when the graph finishes by
+// the pace at which steps finished so far
+func (p *Progress) Heartbeat(now time.Time, interval time.Duration) nsttl.Heartbeat {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	heartbeat := nsttl.Heartbeat{
+		Time:     metav1.NewTime(now),
+		Interval: metav1.Duration{Duration: interval},
+		Finished: p.finished.Len(),
+		Total:    len(p.steps),
+	}
+	for _, step := range p.steps {
+		if !p.finished.Has(step.Name()) && api.HasAllLinks(step.Requires(), p.seen) {
+			heartbeat.Steps = append(heartbeat.Steps, step.Name())
+		}
+	}
+	sort.Strings(heartbeat.Steps)
+	if finished := heartbeat.Finished; finished > 0 && finished < heartbeat.Total {
+		elapsed := now.Sub(p.started)
+		eta := metav1.NewTime(now.Add(elapsed * time.Duration(heartbeat.Total-finished) / time.Duration(finished)).Truncate(time.Second))
+		heartbeat.ETA = &eta
+	}
+	return heartbeat
+}
@@ -0,0 +1,49 @@
+package steps
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/api/nsttl"
+)
+
+func TestProgressHeartbeat(t *testing.T) {
+	root := &fakeStep{name: "root", creates: []api.StepLink{api.InternalImageLink(api.PipelineImageStreamTagReferenceRoot)}}
+	src := &fakeStep{name: "src", requires: []api.StepLink{api.InternalImageLink(api.PipelineImageStreamTagReferenceRoot)}, creates: []api.StepLink{api.InternalImageLink(api.PipelineImageStreamTagReferenceSource)}}
+	unit := &fakeStep{name: "unit", requires: []api.StepLink{api.InternalImageLink(api.PipelineImageStreamTagReferenceSource)}}
+	lint := &fakeStep{name: "lint", requires: []api.StepLink{api.InternalImageLink(api.PipelineImageStreamTagReferenceSource)}}
+	started := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	progress := NewProgress(api.BuildGraph([]api.Step{root, src, unit, lint}), started)
+	interval := metav1.Duration{Duration: 10 * time.Minute}
+
+	now := started.Add(5 * time.Minute)
+	if diff := cmp.Diff(nsttl.Heartbeat{
+		Time:     metav1.NewTime(now),
+		Interval: interval,
+		Steps:    []string{"root"},
+		Total:    4,
+	}, progress.Heartbeat(now, interval.Duration)); diff != "" {
+		t.Errorf("unexpected heartbeat before any step finished: %s", diff)
+	}
+
+	progress.StepFinished("root", nil)
+	progress.StepFinished("src", nil)
+	progress.StepFinished("lint", errors.New("oops"))
+	now = started.Add(20 * time.Minute)
+	eta := metav1.NewTime(started.Add(26*time.Minute + 40*time.Second))
+	if diff := cmp.Diff(nsttl.Heartbeat{
+		Time:     metav1.NewTime(now),
+		Interval: interval,
+		Steps:    []string{"unit"},
+		Finished: 3,
+		Total:    4,
+		ETA:      &eta,
+	}, progress.Heartbeat(now, interval.Duration)); diff != "" {
+		t.Errorf("unexpected heartbeat after steps finished: %s", diff)
+	}
+}
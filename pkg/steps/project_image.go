@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	coreapi "k8s.io/api/core/v1"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -21,8 +23,11 @@ type projectDirectoryImageBuildStep struct {
 	config     api.ProjectDirectoryImageBuildStepConfiguration
 	resources  api.ResourceConfiguration
 	client     BuildClient
+	params     api.Parameters
 	jobSpec    *api.JobSpec
 	pullSecret *coreapi.Secret
+	// hermeticEgress lists the networks hermetic builds may reach
+	hermeticEgress []string
 }
 
 func (s *projectDirectoryImageBuildStep) Inputs() (api.InputDefinition, error) {
@@ -32,7 +37,7 @@ func (s *projectDirectoryImageBuildStep) Inputs() (api.InputDefinition, error) {
 func (*projectDirectoryImageBuildStep) Validate() error { return nil }
 
 func (s *projectDirectoryImageBuildStep) Run(ctx context.Context) error {
-	return results.ForReason("building_project_image").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonBuildingProjectImage).ForError(s.run(ctx))
 }
 
 func (s *projectDirectoryImageBuildStep) run(ctx context.Context) error {
@@ -88,6 +93,10 @@ func (s *projectDirectoryImageBuildStep) run(ctx context.Context) error {
 			}},
 		})
 	}
+	buildArgs, err := resolveBuildArgs(s.config.BuildArgs, s.jobSpec, s.params)
+	if err != nil {
+		return err
+	}
 	build := buildFromSource(
 		s.jobSpec, s.config.From, s.config.To,
 		buildapi.BuildSource{
@@ -99,9 +108,68 @@ func (s *projectDirectoryImageBuildStep) run(ctx context.Context) error {
 		s.resources,
 		s.pullSecret,
 	)
+	build.Spec.Strategy.DockerStrategy.BuildArgs = buildArgs
+	if s.config.InjectSourceEnv {
+		build.Spec.Strategy.DockerStrategy.Env = append(build.Spec.Strategy.DockerStrategy.Env, sourceEnv(s.jobSpec.Refs, s.jobSpec.MergeSHA())...)
+	}
+	if s.config.Hermetic {
+		build.Annotations[HermeticBuildAnnotation] = strings.Join(s.hermeticEgress, ",")
+	}
 	return handleBuild(ctx, s.client, build)
 }
 
+// resolveBuildArgs determines the values of the build arguments of an image
+func resolveBuildArgs(args []api.BuildArg, jobSpec *api.JobSpec, params api.Parameters) ([]coreapi.EnvVar, error) {
+	var resolved []coreapi.EnvVar
+	for _, arg := range args {
+		value := arg.Value
+		if arg.ValueFrom != nil {
+			switch {
+			case arg.ValueFrom.Parameter != "":
+				var err error
+				if value, err = params.Get(arg.ValueFrom.Parameter); err != nil {
+					return nil, fmt.Errorf("could not resolve build argument %s from parameter %s: %w", arg.Name, arg.ValueFrom.Parameter, err)
+				}
+			default:
+				value = jobMetadata(jobSpec, arg.ValueFrom.JobMetadata)
+			}
+		}
+		resolved = append(resolved, coreapi.EnvVar{Name: arg.Name, Value: value})
+	}
+	return resolved, nil
+}
+
+// jobMetadata determines a property of the job, which is empty when the job
+// does not have it
+func jobMetadata(jobSpec *api.JobSpec, property api.BuildArgJobMetadata) string {
+	switch property {
+	case api.BuildArgJobName:
+		return jobSpec.Job
+	case api.BuildArgBuildID:
+		return jobSpec.BuildID
+	}
+	refs := jobSpec.Refs
+	if refs == nil {
+		return ""
+	}
+	switch property {
+	case api.BuildArgCommit:
+		if len(refs.Pulls) == 1 {
+			return refs.Pulls[0].SHA
+		}
+		return refs.BaseSHA
+	case api.BuildArgBaseRef:
+		return refs.BaseRef
+	case api.BuildArgBaseSHA:
+		return refs.BaseSHA
+	case api.BuildArgPullNumber:
+		if len(refs.Pulls) == 1 {
+			return strconv.Itoa(refs.Pulls[0].Number)
+		}
+	}
+	return ""
+}
+
 func getWorkingDir(client ctrlruntimeclient.Client, source, namespace string) (string, error) {
 	ist := &imagev1.ImageStreamTag{}
 	if err := client.Get(context.TODO(), ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: source}, ist); err != nil {
@@ -133,6 +201,14 @@ func (s *projectDirectoryImageBuildStep) Requires() []api.StepLink {
 	for name := range s.config.Inputs {
 		links = append(links, api.InternalImageLink(api.PipelineImageStreamTagReference(name), api.StepLinkWithUnsatisfiableErrorMessage(fmt.Sprintf("%q is neither an imported nor a built image", name))))
 	}
+	for _, arg := range s.config.BuildArgs {
+		if arg.ValueFrom == nil {
+			continue
+		}
+		if link, ok := utils.LinkForEnv(arg.ValueFrom.Parameter); ok {
+			links = append(links, link)
+		}
+	}
 	return links
 }
 
@@ -159,12 +235,14 @@ func (s *projectDirectoryImageBuildStep) Objects() []ctrlruntimeclient.Object {
 	return s.client.Objects()
 }
 
-func ProjectDirectoryImageBuildStep(config api.ProjectDirectoryImageBuildStepConfiguration, resources api.ResourceConfiguration, buildClient BuildClient, jobSpec *api.JobSpec, pullSecret *coreapi.Secret) api.Step {
+func ProjectDirectoryImageBuildStep(config api.ProjectDirectoryImageBuildStepConfiguration, resources api.ResourceConfiguration, buildClient BuildClient, params api.Parameters, jobSpec *api.JobSpec, pullSecret *coreapi.Secret, hermeticEgress []string) api.Step {
 	return &projectDirectoryImageBuildStep{
-		config:     config,
-		resources:  resources,
-		client:     buildClient,
-		jobSpec:    jobSpec,
-		pullSecret: pullSecret,
+		config:         config,
+		resources:      resources,
+		client:         buildClient,
+		params:         params,
+		jobSpec:        jobSpec,
+		pullSecret:     pullSecret,
+		hermeticEgress: hermeticEgress,
 	}
 }
@@ -0,0 +1,79 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestResolveBuildArgs(t *testing.T) {
+	args := []api.BuildArg{
+		{Name: "VERSION", Value: "4.9"},
+		{Name: "GIT_COMMIT", ValueFrom: &api.BuildArgSource{JobMetadata: api.BuildArgCommit}},
+		{Name: "BASE_REF", ValueFrom: &api.BuildArgSource{JobMetadata: api.BuildArgBaseRef}},
+		{Name: "PULL", ValueFrom: &api.BuildArgSource{JobMetadata: api.BuildArgPullNumber}},
+		{Name: "BUILD", ValueFrom: &api.BuildArgSource{JobMetadata: api.BuildArgBuildID}},
+		{Name: "RELEASE", ValueFrom: &api.BuildArgSource{Parameter: "RELEASE_IMAGE_LATEST"}},
+	}
+	params := api.NewDeferredParameters(nil)
+	params.Add("RELEASE_IMAGE_LATEST", func() (string, error) { return "registry/release:latest", nil })
+	var testCases = []struct {
+		name     string
+		refs     *prowapi.Refs
+		expected []coreapi.EnvVar
+	}{
+		{
+			name: "presubmit",
+			refs: &prowapi.Refs{BaseRef: "master", BaseSHA: "base", Pulls: []prowapi.Pull{{Number: 123, SHA: "head"}}},
+			expected: []coreapi.EnvVar{
+				{Name: "VERSION", Value: "4.9"},
+				{Name: "GIT_COMMIT", Value: "head"},
+				{Name: "BASE_REF", Value: "master"},
+				{Name: "PULL", Value: "123"},
+				{Name: "BUILD", Value: "1"},
+				{Name: "RELEASE", Value: "registry/release:latest"},
+			},
+		},
+		{
+			name: "postsubmit",
+			refs: &prowapi.Refs{BaseRef: "master", BaseSHA: "base"},
+			expected: []coreapi.EnvVar{
+				{Name: "VERSION", Value: "4.9"},
+				{Name: "GIT_COMMIT", Value: "base"},
+				{Name: "BASE_REF", Value: "master"},
+				{Name: "PULL"},
+				{Name: "BUILD", Value: "1"},
+				{Name: "RELEASE", Value: "registry/release:latest"},
+			},
+		},
+		{
+			name: "periodic without refs",
+			expected: []coreapi.EnvVar{
+				{Name: "VERSION", Value: "4.9"},
+				{Name: "GIT_COMMIT"},
+				{Name: "BASE_REF"},
+				{Name: "PULL"},
+				{Name: "BUILD", Value: "1"},
+				{Name: "RELEASE", Value: "registry/release:latest"},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			jobSpec := &api.JobSpec{JobSpec: downwardapi.JobSpec{BuildID: "1", Refs: testCase.refs}}
+			actual, err := resolveBuildArgs(args, jobSpec, params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected build arguments: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	tektonapi "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildapi "github.com/openshift/api/build/v1"
+)
+
+const (
+	// TrustedCABundleConfigMap holds the copy of the trusted certificate
+	// authorities in the namespace of the job
+	TrustedCABundleConfigMap = "ci-operator-trusted-ca-bundle"
+	// TrustedCABundleKey is the key of the bundle in the config map, the
+	// same the cluster network operator injects bundles under
+	TrustedCABundleKey = "ca-bundle.crt"
+
+	trustedCAVolume = "trusted-ca-bundle"
+	// trustedCAMountPath replaces the extracted trust of RHEL-based images,
+	// so the bundle must hold every authority containers need to trust
+	trustedCAMountPath = "/etc/pki/ca-trust/extracted/pem"
+	trustedCAFile      = "tls-ca-bundle.pem"
+)
+
+// ProxyConfiguration describes the proxy and the certificate authorities the
+// workloads of a job need to reach the network in disconnected or proxied
+// environments
+type ProxyConfiguration struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+	// TrustedCABundle is the config map holding the bundle of certificate
+	// authorities to trust under TrustedCABundleKey
+	TrustedCABundle *ctrlruntimeclient.ObjectKey
+}
+
+// ParseTrustedCABundle parses a config map in the form NAMESPACE/NAME
+func ParseTrustedCABundle(value string) (*ctrlruntimeclient.ObjectKey, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("must be in the form NAMESPACE/NAME, not %q", value)
+	}
+	return &ctrlruntimeclient.ObjectKey{Namespace: parts[0], Name: parts[1]}, nil
+}
+
+// env lists the proxy variables, in both cases as tools disagree on which
+// they read
+func (c ProxyConfiguration) env() []coreapi.EnvVar {
+	var env []coreapi.EnvVar
+	for _, variable := range []struct{ name, value string }{
+		{name: "HTTP_PROXY", value: c.HTTPProxy},
+		{name: "HTTPS_PROXY", value: c.HTTPSProxy},
+		{name: "NO_PROXY", value: c.NoProxy},
+	} {
+		if variable.value == "" {
+			continue
+		}
+		env = append(env, coreapi.EnvVar{Name: variable.name, Value: variable.value}, coreapi.EnvVar{Name: strings.ToLower(variable.name), Value: variable.value})
+	}
+	return env
+}
+
+// NewProxyClient returns a client that injects the proxy configuration into
+// the pods, builds and pipeline runs it creates: the proxy variables are set
+// in every container and build and the trusted certificate authorities are
+// mounted into every container. Builds trust the authorities of the cluster.
+func NewProxyClient(upstream ctrlruntimeclient.Client, config ProxyConfiguration) ctrlruntimeclient.Client {
+	return &proxyClient{Client: upstream, config: config, copied: sets.NewString()}
+}
+
+type proxyClient struct {
+	ctrlruntimeclient.Client
+	config ProxyConfiguration
+
+	lock sync.Mutex
+	// copied holds the namespaces the bundle was copied into
+	copied sets.String
+}
+
+func (c *proxyClient) Create(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.CreateOption) error {
+	switch o := obj.(type) {
+	case *coreapi.Pod:
+		if err := c.copyTrustedCABundle(ctx, o.Namespace); err != nil {
+			return err
+		}
+		c.injectIntoPodSpec(&o.Spec)
+	case *tektonapi.PipelineRun:
+		if err := c.copyTrustedCABundle(ctx, o.Namespace); err != nil {
+			return err
+		}
+		c.injectIntoPipelineRun(o)
+	case *buildapi.Build:
+		c.injectIntoBuild(o)
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+// copyTrustedCABundle copies the bundle into the namespace the first time a
+// workload is created in it
+func (c *proxyClient) copyTrustedCABundle(ctx context.Context, namespace string) error {
+	if c.config.TrustedCABundle == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.copied.Has(namespace) {
+		return nil
+	}
+	source := &coreapi.ConfigMap{}
+	if err := c.Client.Get(ctx, *c.config.TrustedCABundle, source); err != nil {
+		return fmt.Errorf("could not get trusted CA bundle %s: %w", c.config.TrustedCABundle, err)
+	}
+	bundle, ok := source.Data[TrustedCABundleKey]
+	if !ok {
+		return fmt.Errorf("trusted CA bundle %s has no %s key", c.config.TrustedCABundle, TrustedCABundleKey)
+	}
+	copied := &coreapi.ConfigMap{
+		ObjectMeta: meta.ObjectMeta{Namespace: namespace, Name: TrustedCABundleConfigMap},
+		Data:       map[string]string{TrustedCABundleKey: bundle},
+	}
+	if err := c.Client.Create(ctx, copied); err != nil && !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not copy trusted CA bundle: %w", err)
+	}
+	c.copied.Insert(namespace)
+	return nil
+}
+
+func (c *proxyClient) trustedCAVolume() coreapi.Volume {
+	return coreapi.Volume{
+		Name: trustedCAVolume,
+		VolumeSource: coreapi.VolumeSource{ConfigMap: &coreapi.ConfigMapVolumeSource{
+			LocalObjectReference: coreapi.LocalObjectReference{Name: TrustedCABundleConfigMap},
+			Items:                []coreapi.KeyToPath{{Key: TrustedCABundleKey, Path: trustedCAFile}},
+		}},
+	}
+}
+
+// injectIntoContainer sets the proxy variables the container does not set
+// itself and mounts the bundle unless the container mounts something else
+// over the trust
+func (c *proxyClient) injectIntoContainer(container *coreapi.Container) {
+	set := sets.NewString()
+	for _, variable := range container.Env {
+		set.Insert(variable.Name)
+	}
+	for _, variable := range c.config.env() {
+		if !set.Has(variable.Name) {
+			container.Env = append(container.Env, variable)
+		}
+	}
+	if c.config.TrustedCABundle == nil {
+		return
+	}
+	for _, mount := range container.VolumeMounts {
+		if mount.MountPath == trustedCAMountPath {
+			return
+		}
+	}
+	container.VolumeMounts = append(container.VolumeMounts, coreapi.VolumeMount{Name: trustedCAVolume, MountPath: trustedCAMountPath, ReadOnly: true})
+}
+
+func (c *proxyClient) injectIntoPodSpec(spec *coreapi.PodSpec) {
+	for i := range spec.InitContainers {
+		c.injectIntoContainer(&spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		c.injectIntoContainer(&spec.Containers[i])
+	}
+	if c.config.TrustedCABundle != nil {
+		spec.Volumes = append(spec.Volumes, c.trustedCAVolume())
+	}
+}
+
+func (c *proxyClient) injectIntoPipelineRun(run *tektonapi.PipelineRun) {
+	if run.Spec.PipelineSpec == nil {
+		return
+	}
+	for i := range run.Spec.PipelineSpec.Tasks {
+		task := run.Spec.PipelineSpec.Tasks[i].TaskSpec
+		if task == nil {
+			continue
+		}
+		if task.StepTemplate == nil {
+			task.StepTemplate = &coreapi.Container{}
+		}
+		c.injectIntoContainer(task.StepTemplate)
+		if c.config.TrustedCABundle != nil {
+			task.Volumes = append(task.Volumes, c.trustedCAVolume())
+		}
+	}
+}
+
+// injectIntoBuild passes the proxy variables as build arguments, which the
+// Docker strategy predefines and does not persist in the image, and has git
+// sources cloned through the proxy
+func (c *proxyClient) injectIntoBuild(build *buildapi.Build) {
+	if strategy := build.Spec.Strategy.DockerStrategy; strategy != nil {
+		set := sets.NewString()
+		for _, arg := range strategy.BuildArgs {
+			set.Insert(arg.Name)
+		}
+		for _, variable := range c.config.env() {
+			if !set.Has(variable.Name) {
+				strategy.BuildArgs = append(strategy.BuildArgs, variable)
+			}
+		}
+	}
+	if git := build.Spec.Source.Git; git != nil {
+		for _, field := range []struct {
+			target **string
+			value  string
+		}{
+			{target: &git.HTTPProxy, value: c.config.HTTPProxy},
+			{target: &git.HTTPSProxy, value: c.config.HTTPSProxy},
+			{target: &git.NoProxy, value: c.config.NoProxy},
+		} {
+			if *field.target == nil && field.value != "" {
+				value := field.value
+				*field.target = &value
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package steps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	buildapi "github.com/openshift/api/build/v1"
+)
+
+func TestProxyClient(t *testing.T) {
+	config := ProxyConfiguration{
+		HTTPSProxy:      "http://proxy:3128",
+		NoProxy:         ".svc",
+		TrustedCABundle: &ctrlruntimeclient.ObjectKey{Namespace: "openshift-config", Name: "user-ca-bundle"},
+	}
+	bundle := &coreapi.ConfigMap{
+		ObjectMeta: meta.ObjectMeta{Namespace: "openshift-config", Name: "user-ca-bundle"},
+		Data:       map[string]string{TrustedCABundleKey: "CERTIFICATE"},
+	}
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{coreapi.AddToScheme, buildapi.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatalf("failed to build scheme: %v", err)
+		}
+	}
+	client := NewProxyClient(fakectrlruntimeclient.NewClientBuilder().WithScheme(scheme).WithObjects(bundle).Build(), config)
+	ctx := context.Background()
+
+	pod := &coreapi.Pod{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ns", Name: "pod"},
+		Spec: coreapi.PodSpec{
+			InitContainers: []coreapi.Container{{Name: "init"}},
+			Containers:     []coreapi.Container{{Name: "test", Env: []coreapi.EnvVar{{Name: "NO_PROXY", Value: "example.com"}}}},
+		},
+	}
+	if err := client.Create(ctx, pod); err != nil {
+		t.Fatalf("failed to create pod: %v", err)
+	}
+	mounts := []coreapi.VolumeMount{{Name: trustedCAVolume, MountPath: trustedCAMountPath, ReadOnly: true}}
+	expectedContainers := []coreapi.Container{{
+		Name: "test",
+		Env: []coreapi.EnvVar{
+			{Name: "NO_PROXY", Value: "example.com"},
+			{Name: "HTTPS_PROXY", Value: "http://proxy:3128"},
+			{Name: "https_proxy", Value: "http://proxy:3128"},
+			{Name: "no_proxy", Value: ".svc"},
+		},
+		VolumeMounts: mounts,
+	}}
+	if diff := cmp.Diff(expectedContainers, pod.Spec.Containers); diff != "" {
+		t.Errorf("unexpected containers: %s", diff)
+	}
+	if diff := cmp.Diff(mounts, pod.Spec.InitContainers[0].VolumeMounts); diff != "" {
+		t.Errorf("unexpected init container mounts: %s", diff)
+	}
+	copied := &coreapi.ConfigMap{}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: TrustedCABundleConfigMap}, copied); err != nil {
+		t.Fatalf("failed to get the copied bundle: %v", err)
+	}
+	if diff := cmp.Diff(bundle.Data, copied.Data); diff != "" {
+		t.Errorf("unexpected copied bundle: %s", diff)
+	}
+
+	build := &buildapi.Build{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ns", Name: "build"},
+		Spec: buildapi.BuildSpec{CommonSpec: buildapi.CommonSpec{
+			Source:   buildapi.BuildSource{Git: &buildapi.GitBuildSource{URI: "https://github.com/org/repo"}},
+			Strategy: buildapi.BuildStrategy{DockerStrategy: &buildapi.DockerBuildStrategy{}},
+		}},
+	}
+	if err := client.Create(ctx, build); err != nil {
+		t.Fatalf("failed to create build: %v", err)
+	}
+	expectedArgs := []coreapi.EnvVar{
+		{Name: "HTTPS_PROXY", Value: "http://proxy:3128"},
+		{Name: "https_proxy", Value: "http://proxy:3128"},
+		{Name: "NO_PROXY", Value: ".svc"},
+		{Name: "no_proxy", Value: ".svc"},
+	}
+	if diff := cmp.Diff(expectedArgs, build.Spec.Strategy.DockerStrategy.BuildArgs); diff != "" {
+		t.Errorf("unexpected build args: %s", diff)
+	}
+	if git := build.Spec.Source.Git; git.HTTPProxy != nil || git.HTTPSProxy == nil || *git.HTTPSProxy != "http://proxy:3128" || git.NoProxy == nil || *git.NoProxy != ".svc" {
+		t.Errorf("unexpected git proxy: %+v", git)
+	}
+}
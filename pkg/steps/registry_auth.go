@@ -0,0 +1,33 @@
+package steps
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// clusterToolsImage provides bash, git, oc, jq and file; the image stream
+	// exists on every OpenShift cluster, so pods of jobs may always pull it
+	clusterToolsImage = "image-registry.openshift-image-registry.svc:5000/openshift/tools:latest"
+	// serviceAccountTokenFile is where the token of the service account a
+	// pod runs as is mounted
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// registryAuthScript writes a registry configuration to the file which
+// authenticates to the registries as the service account the pod runs as,
+// the way the image registry of the cluster accepts. The file is a shell
+// word, so it may refer to variables of the pod.
+func registryAuthScript(file string, registries ...string) string {
+	var auths []string
+	for _, registry := range sets.NewString(registries...).List() {
+		auths = append(auths, fmt.Sprintf(`"%s":{"auth":"'"${auth}"'"}`, registry))
+	}
+	return strings.Join([]string{
+		fmt.Sprintf(`auth="$(printf 'serviceaccount:%%s' "$(cat %s)" | base64 -w0)"`, serviceAccountTokenFile),
+		fmt.Sprintf(`mkdir -p "$(dirname %s)"`, file),
+		fmt.Sprintf(`echo '{"auths":{%s}}' > %s`, strings.Join(auths, ","), file),
+	}, "\n")
+}
@@ -4,20 +4,27 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
 	"time"
 
 	coreapi "k8s.io/api/core/v1"
 	rbacapi "k8s.io/api/rbac/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	imageapi "github.com/openshift/api/image/v1"
 	imagev1 "github.com/openshift/api/image/v1"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/imagemirror"
 	"github.com/openshift/ci-tools/pkg/results"
 	"github.com/openshift/ci-tools/pkg/steps"
 	"github.com/openshift/ci-tools/pkg/steps/utils"
@@ -26,8 +33,8 @@ import (
 
 // assembleReleaseStep is responsible for creating release images from
 // the stable or stable-initial image streams for use with tests that need
-// to install or upgrade a cluster. It uses the `cli` image within the
-// image stream to create the image and pushes it to a `release` image stream
+// to install or upgrade a cluster. It assembles the image like
+// `oc adm release new` does and pushes it to a `release` image stream
 // at the `latest` or `initial` tags. As output it provides the environment
 // variables RELEASE_IMAGE_(LATEST|INITIAL) which can be used by templates
 // that invoke the installer.
@@ -45,21 +52,26 @@ import (
 // inject the images from a known historic release for the purposes of building
 // branches of those releases.
 type assembleReleaseStep struct {
-	config    *api.ReleaseTagConfiguration
+	config *api.ReleaseTagConfiguration
+	// assembled is set when the payload is assembled from a subset of the
+	// images of another release instead of the whole stream of this one
+	assembled *api.AssembledRelease
 	name      string
-	resources api.ResourceConfiguration
+	overrides map[string]string
 	client    steps.PodClient
 	jobSpec   *api.JobSpec
+	// newRegistry creates the client the payload is assembled with
+	newRegistry func(imagemirror.Credentials) imagemirror.Registry
 }
 
 func (s *assembleReleaseStep) Inputs() (api.InputDefinition, error) {
-	return nil, nil
+	return overrideArgs(s.overrides), nil
 }
 
 func (*assembleReleaseStep) Validate() error { return nil }
 
 func (s *assembleReleaseStep) Run(ctx context.Context) error {
-	return results.ForReason("assembling_release").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonAssemblingRelease).ForError(s.run(ctx))
 }
 
 func setupReleaseImageStream(ctx context.Context, namespace string, client ctrlruntimeclient.Client) (string, error) {
@@ -126,7 +138,7 @@ func setupReleaseImageStream(ctx context.Context, namespace string, client ctrlr
 			return "", err
 		}
 		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: "release"}, release); err != nil {
-			return "", results.ForReason("creating_release_stream").ForError(err)
+			return "", results.ForReason(results.ReasonCreatingReleaseStream).ForError(err)
 		}
 	}
 	return release.Status.PublicDockerImageRepository, nil
@@ -137,12 +149,16 @@ func (s *assembleReleaseStep) run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	if s.assembled != nil {
+		if err := s.assembleStream(ctx); err != nil {
+			return results.ForReason(results.ReasonAssemblingReleaseStream).ForError(err)
+		}
+	}
 
 	streamName := api.ReleaseStreamFor(s.name)
 	stable := &imageapi.ImageStream{}
 	var cvo string
 	cvoExists := false
-	cliExists := false
 	// waiting for importing the images
 	// 2~3 mins: build01 on aws imports images from api.ci on gcp
 	importCtx, cancel := context.WithTimeout(ctx, 15*time.Minute)
@@ -152,26 +168,24 @@ func (s *assembleReleaseStep) run(ctx context.Context) error {
 			return false, err
 		}
 		cvo, cvoExists = util.ResolvePullSpec(stable, "cluster-version-operator", true)
-		_, cliExists = util.ResolvePullSpec(stable, "cli", true)
-		ret := cvoExists && cliExists
-		if !ret {
-			log.Printf("waiting for importing cluster-version-operator and cli ...")
+		if !cvoExists {
+			steps.Logger(ctx).Infof("waiting for importing cluster-version-operator ...")
 		}
-		return ret, nil
+		return cvoExists, nil
 	}, importCtx.Done()); err != nil {
 		if wait.ErrWaitTimeout == err {
-			if !cliExists {
-				return results.ForReason("missing_cli").WithError(err).Errorf("no 'cli' image was tagged into the %s stream, that image is required for building a release", streamName)
+			if s.assembled != nil {
+				return results.ForReason(results.ReasonMissingCVO).WithError(err).Errorf("no 'cluster-version-operator' image was assembled into the %s stream, that image is required for building a release", streamName)
 			}
-			log.Printf("No %s release image necessary, %s image stream does not include a cluster-version-operator image", s.name, streamName)
+			steps.Logger(ctx).Infof("No %s release image necessary, %s image stream does not include a cluster-version-operator image", s.name, streamName)
 			return nil
 		} else if kerrors.IsNotFound(err) {
 			// if a user sets IMAGE_FORMAT=... we skip importing the image stream contents, which prevents us from
 			// generating a release image.
-			log.Printf("No %s release image can be generated when the %s image stream was skipped", s.name, streamName)
+			steps.Logger(ctx).Infof("No %s release image can be generated when the %s image stream was skipped", s.name, streamName)
 			return nil
 		}
-		return results.ForReason("missing_release").WithError(err).Errorf("could not resolve imagestream %s: %v", streamName, err)
+		return results.ForReason(results.ReasonMissingRelease).WithError(err).Errorf("could not resolve imagestream %s: %v", streamName, err)
 	}
 
 	// we want to expose the release payload as a CI version that looks just like
@@ -182,7 +196,7 @@ func (s *assembleReleaseStep) run(ctx context.Context) error {
 			Name string `json:"name"`
 		}
 		if err := json.Unmarshal([]byte(raw), &releaseConfig); err != nil {
-			return results.ForReason("invalid_release").WithError(err).Errorf("could not resolve release configuration on imagestream %s: %v", streamName, err)
+			return results.ForReason(results.ReasonInvalidRelease).WithError(err).Errorf("could not resolve release configuration on imagestream %s: %v", streamName, err)
 		}
 		prefix = releaseConfig.Name
 	}
@@ -190,42 +204,189 @@ func (s *assembleReleaseStep) run(ctx context.Context) error {
 	version := fmt.Sprintf("%s.test-%s-%s", prefix, now.Format("2006-01-02-150405"), s.jobSpec.Namespace())
 
 	destination := fmt.Sprintf("%s:%s", releaseImageStreamRepo, s.name)
-	log.Printf("Create release image %s", destination)
-	podConfig := steps.PodStepConfiguration{
-		SkipLogs: true,
-		As:       fmt.Sprintf("release-%s", s.name),
-		From: api.ImageStreamTagReference{
-			Name: streamName,
-			Tag:  "cli",
-		},
-		ServiceAccountName: "ci-operator",
-		Commands: fmt.Sprintf(`
-set -xeuo pipefail
-export HOME=/tmp
-oc registry login
-oc adm release new --max-per-registry=32 -n %q --from-image-stream %q --to-image-base %q --to-image %q --name %q
-oc adm release extract --from=%q --to=${ARTIFACT_DIR}/release-payload-%s
-`, s.jobSpec.Namespace(), streamName, cvo, destination, version, destination, s.name),
+	steps.Logger(ctx).Infof("Create release image %s", destination)
+	logOverrides(ctx, s.name, s.overrides)
+	credentials, err := registryCredentials(ctx, s.client, s.jobSpec.Namespace())
+	if err != nil {
+		return results.ForReason(results.ReasonCreatingRelease).ForError(err)
+	}
+	assembler := &payloadAssembler{registry: s.newRegistry(credentials)}
+	files, digest, err := assembler.assemble(ctx, payload{
+		version:     version,
+		namespace:   s.jobSpec.Namespace(),
+		stream:      streamName,
+		images:      payloadImages(stable, s.overrides),
+		base:        cvo,
+		destination: destination,
+	})
+	if err != nil {
+		return results.ForReason(results.ReasonCreatingRelease).WithError(err).Errorf("could not assemble release image %s: %v", destination, err)
+	}
+	steps.Logger(ctx).Infof("Created release image %s@%s", releaseImageStreamRepo, digest)
+	if err := savePayload(ctx, s.name, files); err != nil {
+		steps.Logger(ctx).WithError(err).Warn("Could not save the manifests of the release payload.")
+	}
+	return results.ForReason(results.ReasonOverridingComponents).ForError(tagOverrides(ctx, s.client, s.jobSpec.Namespace(), streamName, s.overrides))
+}
+
+// registryCredentials reads the credentials of the service account the
+// payload is pushed with, which can push to the image streams of the namespace
+func registryCredentials(ctx context.Context, client ctrlruntimeclient.Client, namespace string) (imagemirror.Credentials, error) {
+	sa := &coreapi.ServiceAccount{}
+	if err := wait.PollImmediate(time.Second, time.Minute, func() (bool, error) {
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: "ci-operator"}, sa); err != nil {
+			return false, err
+		}
+		return len(sa.ImagePullSecrets) > 0, nil
+	}); err != nil {
+		return nil, fmt.Errorf("could not get the registry credentials of service account ci-operator: %w", err)
 	}
+	secret := &coreapi.Secret{}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: sa.ImagePullSecrets[0].Name}, secret); err != nil {
+		return nil, fmt.Errorf("could not get the registry credentials of service account ci-operator: %w", err)
+	}
+	raw, ok := secret.Data[coreapi.DockerConfigJsonKey]
+	if legacy, isLegacy := secret.Data[coreapi.DockerConfigKey]; !ok && isLegacy {
+		raw = []byte(fmt.Sprintf(`{"auths":%s}`, legacy))
+	}
+	return imagemirror.CredentialsFromDockerConfig(raw)
+}
 
-	// set an explicit default for release-latest resources, but allow customization if necessary
-	resources := s.resources
-	if _, ok := resources[podConfig.As]; !ok {
-		copied := make(api.ResourceConfiguration)
-		for k, v := range resources {
-			copied[k] = v
+// savePayload stores the manifests of the payload in the artifacts
+func savePayload(ctx context.Context, name string, files map[string][]byte) error {
+	artifactDir, set := api.ArtifactsFor(ctx)
+	if !set {
+		return nil
+	}
+	dir := filepath.Join(artifactDir, fmt.Sprintf("release-payload-%s", name), payloadDir)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("unable to create directory %s: %w", dir, err)
+	}
+	for file, raw := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), raw, 0640); err != nil {
+			return err
 		}
-		// max cpu observed at 0.1 core, most memory ~ 420M
-		copied[podConfig.As] = api.ResourceRequirements{Requests: api.ResourceList{"cpu": "50m", "memory": "400Mi"}}
-		resources = copied
 	}
+	return nil
+}
+
+func defaultRegistry(credentials imagemirror.Credentials) imagemirror.Registry {
+	return imagemirror.NewRegistry(credentials, http.DefaultTransport)
+}
 
-	step := steps.PodStep("release", podConfig, resources, s.client, s.jobSpec)
+// assembleStream populates the stream of the release with the images of the
+// release it is assembled from which pass the filters and with the images
+// built by the job which replace them
+func (s *assembleReleaseStep) assembleStream(ctx context.Context) error {
+	sourceName := api.ReleaseStreamFor(s.assembled.SourceRelease())
+	source := &imagev1.ImageStream{}
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: sourceName}, source); err != nil {
+		return fmt.Errorf("could not resolve imagestream %s: %w", sourceName, err)
+	}
+	pipeline := &imagev1.ImageStream{}
+	if len(s.assembled.Overrides) > 0 {
+		if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: api.PipelineImageStream}, pipeline); err != nil {
+			return fmt.Errorf("could not resolve imagestream %s: %w", api.PipelineImageStream, err)
+		}
+	}
+	tags, err := assembledTags(source, pipeline, s.assembled)
+	if err != nil {
+		return err
+	}
+	steps.Logger(ctx).Infof("Assembling release %s from %d images of release %s", s.name, len(tags), s.assembled.SourceRelease())
+	streamName := api.ReleaseStreamFor(s.name)
+	stream := &imagev1.ImageStream{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: s.jobSpec.Namespace(),
+			Name:      streamName,
+		},
+		Spec: imagev1.ImageStreamSpec{
+			LookupPolicy: imagev1.ImageLookupPolicy{
+				Local: true,
+			},
+			Tags: tags,
+		},
+	}
+	if raw, ok := source.Annotations[releaseConfigAnnotation]; ok {
+		stream.Annotations = map[string]string{releaseConfigAnnotation: raw}
+	}
+	if err := s.client.Create(ctx, stream); err != nil {
+		if !kerrors.IsAlreadyExists(err) {
+			return fmt.Errorf("could not create imagestream %s: %w", streamName, err)
+		}
+		if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			existing := &imagev1.ImageStream{}
+			if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: streamName}, existing); err != nil {
+				return err
+			}
+			existing.Spec.Tags = tags
+			return s.client.Update(ctx, existing)
+		}); err != nil {
+			return fmt.Errorf("could not update imagestream %s: %w", streamName, err)
+		}
+	}
+	return nil
+}
 
-	return results.ForReason("creating_release").ForError(step.Run(ctx))
+// assembledTags selects the tags of the source stream which are part of the
+// assembled release and replaces the overridden ones with pipeline images
+func assembledTags(source, pipeline *imagev1.ImageStream, assembled *api.AssembledRelease) ([]imagev1.TagReference, error) {
+	var include, exclude *regexp.Regexp
+	var err error
+	if assembled.Include != "" {
+		if include, err = regexp.Compile(assembled.Include); err != nil {
+			return nil, fmt.Errorf("invalid include expression: %w", err)
+		}
+	}
+	if assembled.Exclude != "" {
+		if exclude, err = regexp.Compile(assembled.Exclude); err != nil {
+			return nil, fmt.Errorf("invalid exclude expression: %w", err)
+		}
+	}
+	tag := func(name, pullSpec string) imagev1.TagReference {
+		return imagev1.TagReference{
+			Name:            name,
+			From:            &coreapi.ObjectReference{Kind: "DockerImage", Name: pullSpec},
+			ReferencePolicy: imagev1.TagReferencePolicy{Type: imagev1.LocalTagReferencePolicy},
+		}
+	}
+	var tags []imagev1.TagReference
+	for _, event := range source.Status.Tags {
+		name := event.Tag
+		if _, overridden := assembled.Overrides[name]; overridden {
+			continue
+		}
+		if include != nil && !include.MatchString(name) || exclude != nil && exclude.MatchString(name) {
+			continue
+		}
+		if pullSpec, ok := util.ResolvePullSpec(source, name, true); ok {
+			tags = append(tags, tag(name, pullSpec))
+		}
+	}
+	for _, name := range sets.StringKeySet(assembled.Overrides).List() {
+		image := assembled.Overrides[name]
+		pullSpec, ok := util.ResolvePullSpec(pipeline, image, true)
+		if !ok {
+			return nil, fmt.Errorf("image %s replacing %s was not built", image, name)
+		}
+		tags = append(tags, tag(name, pullSpec))
+	}
+	return tags, nil
 }
 
 func (s *assembleReleaseStep) Requires() []api.StepLink {
+	if s.assembled != nil {
+		var links []api.StepLink
+		if from := s.assembled.SourceRelease(); from == api.LatestReleaseName {
+			links = append(links, api.ImagesReadyLink())
+		} else {
+			links = append(links, api.ReleaseImagesLink(from))
+		}
+		for _, image := range sets.StringKeySet(s.assembled.Overrides).List() {
+			links = append(links, api.InternalImageLink(api.PipelineImageStreamTagReference(s.assembled.Overrides[image])))
+		}
+		return links
+	}
 	if s.name == api.LatestReleaseName {
 		return []api.StepLink{api.ImagesReadyLink()}
 	}
@@ -233,6 +394,9 @@ func (s *assembleReleaseStep) Requires() []api.StepLink {
 }
 
 func (s *assembleReleaseStep) Creates() []api.StepLink {
+	if s.assembled != nil {
+		return []api.StepLink{api.ReleaseImagesLink(s.name), api.ReleasePayloadImageLink(s.name)}
+	}
 	return []api.StepLink{api.ReleasePayloadImageLink(s.name)}
 }
 
@@ -247,6 +411,9 @@ func (s *assembleReleaseStep) Name() string {
 }
 
 func (s *assembleReleaseStep) Description() string {
+	if s.assembled != nil {
+		return fmt.Sprintf("Assemble the release image %q from images of release %q", s.name, s.assembled.SourceRelease())
+	}
 	return fmt.Sprintf("Create the release image %q containing all images built by this job", s.name)
 }
 
@@ -256,13 +423,28 @@ func (s *assembleReleaseStep) Objects() []ctrlruntimeclient.Object {
 
 // AssembleReleaseStep builds a new update payload image based on the cluster version operator
 // and the operators defined in the release configuration.
-func AssembleReleaseStep(name string, config *api.ReleaseTagConfiguration, resources api.ResourceConfiguration,
+func AssembleReleaseStep(name string, config *api.ReleaseTagConfiguration, overrides map[string]string,
+	client steps.PodClient, jobSpec *api.JobSpec) api.Step {
+	return &assembleReleaseStep{
+		config:      config,
+		name:        name,
+		overrides:   overrides,
+		client:      client,
+		jobSpec:     jobSpec,
+		newRegistry: defaultRegistry,
+	}
+}
+
+// AssembledReleaseStep builds a new update payload image from a subset of the
+// images of another release and images built by the job.
+func AssembledReleaseStep(name string, assembled *api.AssembledRelease, overrides map[string]string,
 	client steps.PodClient, jobSpec *api.JobSpec) api.Step {
 	return &assembleReleaseStep{
-		config:    config,
-		name:      name,
-		resources: resources,
-		client:    client,
-		jobSpec:   jobSpec,
+		assembled:   assembled,
+		name:        name,
+		overrides:   overrides,
+		client:      client,
+		jobSpec:     jobSpec,
+		newRegistry: defaultRegistry,
 	}
 }
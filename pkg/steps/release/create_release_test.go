@@ -0,0 +1,86 @@
+package release
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestAssembledTags(t *testing.T) {
+	stream := func(repository string, tags ...string) *imagev1.ImageStream {
+		is := &imagev1.ImageStream{Status: imagev1.ImageStreamStatus{PublicDockerImageRepository: repository}}
+		for _, tag := range tags {
+			is.Status.Tags = append(is.Status.Tags, imagev1.NamedTagEventList{
+				Tag:   tag,
+				Items: []imagev1.TagEvent{{Image: "sha256:" + tag}},
+			})
+		}
+		return is
+	}
+	tag := func(name, pullSpec string) imagev1.TagReference {
+		return imagev1.TagReference{
+			Name:            name,
+			From:            &coreapi.ObjectReference{Kind: "DockerImage", Name: pullSpec},
+			ReferencePolicy: imagev1.TagReferencePolicy{Type: imagev1.LocalTagReferencePolicy},
+		}
+	}
+	source := stream("registry/ns/stable", "cli", "cluster-version-operator", "machine-config-operator", "baremetal-operator", "ovirt-csi-driver")
+	pipeline := stream("registry/ns/pipeline", "mco")
+	var testCases = []struct {
+		name        string
+		assembled   api.AssembledRelease
+		expected    []imagev1.TagReference
+		expectedErr string
+	}{
+		{
+			name:      "all images without filters",
+			assembled: api.AssembledRelease{},
+			expected: []imagev1.TagReference{
+				tag("cli", "registry/ns/stable@sha256:cli"),
+				tag("cluster-version-operator", "registry/ns/stable@sha256:cluster-version-operator"),
+				tag("machine-config-operator", "registry/ns/stable@sha256:machine-config-operator"),
+				tag("baremetal-operator", "registry/ns/stable@sha256:baremetal-operator"),
+				tag("ovirt-csi-driver", "registry/ns/stable@sha256:ovirt-csi-driver"),
+			},
+		},
+		{
+			name: "filtered images with override",
+			assembled: api.AssembledRelease{
+				Include:   "-operator$|^cli$",
+				Exclude:   "^baremetal-",
+				Overrides: map[string]string{"machine-config-operator": "mco"},
+			},
+			expected: []imagev1.TagReference{
+				tag("cli", "registry/ns/stable@sha256:cli"),
+				tag("cluster-version-operator", "registry/ns/stable@sha256:cluster-version-operator"),
+				tag("machine-config-operator", "registry/ns/pipeline@sha256:mco"),
+			},
+		},
+		{
+			name:        "override with image which was not built",
+			assembled:   api.AssembledRelease{Overrides: map[string]string{"cli": "missing"}},
+			expectedErr: "image missing replacing cli was not built",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := assembledTags(source, pipeline, &testCase.assembled)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected tags: %s", diff)
+			}
+		})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"sort"
 	"strings"
 	"time"
@@ -48,33 +47,57 @@ type importReleaseStep struct {
 	name string
 	// pullSpec is the fully-resolved pull spec of the release payload image we are importing
 	pullSpec string
+	// version is the version of the payload, if it was resolved from one
+	version string
+	// overrides replace components of the imported payload
+	overrides map[string]string
 	// append determines if we wait for other processes to create images first
 	append     bool
 	resources  api.ResourceConfiguration
 	client     steps.PodClient
+	imports    *steps.ImportManager
 	jobSpec    *api.JobSpec
 	pullSecret *coreapi.Secret
 }
 
 func (s *importReleaseStep) Inputs() (api.InputDefinition, error) {
-	return api.InputDefinition{s.pullSpec}, nil
+	inputs := api.InputDefinition{s.pullSpec}
+	if s.version != "" {
+		inputs = append(inputs, s.version)
+	}
+	return append(inputs, overrideArgs(s.overrides)...), nil
+}
+
+func (s *importReleaseStep) LockInputs(lock *steps.InputsLock) {
+	if lock.Releases == nil {
+		lock.Releases = map[string]steps.LockedRelease{}
+	}
+	lock.Releases[s.name] = steps.LockedRelease{PullSpec: s.pullSpec, Version: s.version}
+}
+
+// PinInputs restores the version of a pinned release, which is not known
+// when the release is imported from an explicit pull spec
+func (s *importReleaseStep) PinInputs(lock *steps.InputsLock) {
+	if release, ok := lock.Releases[s.name]; ok && release.PullSpec == s.pullSpec {
+		s.version = release.Version
+	}
 }
 
 func (*importReleaseStep) Validate() error { return nil }
 
 func (s *importReleaseStep) Run(ctx context.Context) error {
-	return results.ForReason("importing_release").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonImportingRelease).ForError(s.run(ctx))
 }
 
 func (s *importReleaseStep) run(ctx context.Context) error {
-	_, err := setupReleaseImageStream(ctx, s.jobSpec.Namespace(), s.client)
+	releaseImageStreamRepo, err := setupReleaseImageStream(ctx, s.jobSpec.Namespace(), s.client)
 	if err != nil {
 		return err
 	}
 
 	streamName := api.ReleaseStreamFor(s.name)
 
-	log.Printf("Importing release image %s", s.name)
+	steps.Logger(ctx).Infof("Importing release image %s", s.name)
 
 	// create the stable image stream with lookup policy so we have a place to put our imported images
 	err = s.client.Create(ctx, &imagev1.ImageStream{
@@ -93,10 +116,6 @@ func (s *importReleaseStep) run(ctx context.Context) error {
 	}
 
 	// tag the release image in and let it import
-	var pullSpec string
-
-	// retry importing the image a few times because we might race against establishing credentials/roles
-	// and be unable to import images on the same cluster
 	streamImport := &imagev1.ImageStreamImport{
 		ObjectMeta: meta.ObjectMeta{
 			Namespace: s.jobSpec.Namespace(),
@@ -120,27 +139,11 @@ func (s *importReleaseStep) run(ctx context.Context) error {
 			},
 		},
 	}
-	if err := wait.ExponentialBackoff(wait.Backoff{Steps: 4, Duration: 1 * time.Second, Factor: 2}, func() (bool, error) {
-		if err := s.client.Create(ctx, streamImport); err != nil {
-			if kerrors.IsConflict(err) {
-				return false, nil
-			}
-			if kerrors.IsForbidden(err) {
-				// the ci-operator expects to have POST /imagestreamimports in the namespace of the job
-				log.Printf("warning: Unable to lock %s to an image digest pull spec, you don't have permission to access the necessary API.", utils.ReleaseImageEnv(s.name))
-				return false, nil
-			}
-			return false, err
-		}
-		image := streamImport.Status.Images[0]
-		if image.Image == nil {
-			return false, nil
-		}
-		pullSpec = streamImport.Status.Images[0].Image.DockerImageReference
-		return true, nil
-	}); err != nil {
+	image, err := s.imports.Import(ctx, s.client, streamImport)
+	if err != nil {
 		return fmt.Errorf("unable to import %s release image: %w", s.name, err)
 	}
+	pullSpec := image.Image.DockerImageReference
 
 	// override anything in stable with the contents of the release image
 	// TODO: should we allow underride for things we built in pipeline?
@@ -202,6 +205,15 @@ func (s *importReleaseStep) run(ctx context.Context) error {
 			MountPath: "/pull",
 		}}
 	}
+	// a payload with overridden components replaces the imported one, its
+	// image references then populate the stable stream as usual
+	var override string
+	if len(s.overrides) > 0 {
+		logOverrides(ctx, s.name, s.overrides)
+		destination := fmt.Sprintf("%s:%s", releaseImageStreamRepo, s.name)
+		override = fmt.Sprintf("oc adm release new --from-release=%q --to-image=%q %s", pullSpec, destination, quotedOverrideArgs(s.overrides))
+		pullSpec = destination
+	}
 	commands := fmt.Sprintf(`
 set -euo pipefail
 export HOME=/tmp
@@ -210,6 +222,7 @@ if [[ -d /pull ]]; then
 	cp /pull/.dockerconfigjson $HOME/.docker/config.json
 fi
 oc registry login
+%s
 oc adm release extract --from=%q --file=image-references > ${ARTIFACT_DIR}/%s
 # while release creation may happen more than once in the lifetime of a test
 # namespace, only one release creation Pod will ever run at once. Therefore,
@@ -221,7 +234,7 @@ if oc get configmap release-%s; then
 	oc delete configmap release-%s
 fi
 oc create configmap release-%s --from-file=%s.yaml=${ARTIFACT_DIR}/%s
-`, pullSpec, target, target, target, target, target, target)
+`, override, pullSpec, target, target, target, target, target, target)
 
 	// run adm release extract and grab the raw image-references from the payload
 	podConfig := steps.PodStepConfiguration{
@@ -332,7 +345,7 @@ oc create configmap release-%s --from-file=%s.yaml=${ARTIFACT_DIR}/%s
 		}
 		if updates {
 			if err = s.client.Update(ctx, stable); err != nil {
-				log.Printf("error requesting re-import of failed release image stream: %v", err)
+				steps.Logger(ctx).Infof("error requesting re-import of failed release image stream: %v", err)
 			}
 			return false, nil
 		}
@@ -357,7 +370,7 @@ oc create configmap release-%s --from-file=%s.yaml=${ARTIFACT_DIR}/%s
 		return fmt.Errorf("the following tags from the release could not be imported to %s after five minutes:\n%s", streamName, strings.Join(tagImportErrorMessages, "\n"))
 	}
 
-	log.Printf("Imported release %s created at %s with %d images to tag release:%s", releaseIS.Name, releaseIS.CreationTimestamp, len(releaseIS.Spec.Tags), s.name)
+	steps.Logger(ctx).Infof("Imported release %s created at %s with %d images to tag release:%s", releaseIS.Name, releaseIS.CreationTimestamp, len(releaseIS.Spec.Tags), s.name)
 	return nil
 }
 
@@ -418,6 +431,9 @@ func (s *importReleaseStep) Name() string {
 }
 
 func (s *importReleaseStep) Description() string {
+	if s.version != "" {
+		return fmt.Sprintf("Import the release payload %q (version %s) from an external source", s.name, s.version)
+	}
 	return fmt.Sprintf("Import the release payload %q from an external source", s.name)
 }
 
@@ -426,15 +442,18 @@ func (s *importReleaseStep) Objects() []ctrlruntimeclient.Object {
 }
 
 // ImportReleaseStep imports an existing update payload image
-func ImportReleaseStep(name, pullSpec string, append bool, resources api.ResourceConfiguration,
-	client steps.PodClient,
+func ImportReleaseStep(name, pullSpec, version string, overrides map[string]string, append bool, resources api.ResourceConfiguration,
+	client steps.PodClient, imports *steps.ImportManager,
 	jobSpec *api.JobSpec, pullSecret *coreapi.Secret) api.Step {
 	return &importReleaseStep{
 		name:       name,
 		pullSpec:   pullSpec,
+		version:    version,
+		overrides:  overrides,
 		append:     append,
 		resources:  resources,
 		client:     client,
+		imports:    imports,
 		jobSpec:    jobSpec,
 		pullSecret: pullSecret,
 	}
@@ -0,0 +1,492 @@
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/imagemirror"
+	"github.com/openshift/ci-tools/pkg/steps"
+	"github.com/openshift/ci-tools/pkg/util"
+)
+
+const (
+	// releaseOperatorLabel marks the images whose manifests are part of the
+	// payload of a release
+	releaseOperatorLabel = "io.openshift.release.operator"
+	// releaseLabel holds the version of a release on its payload image
+	releaseLabel = "io.openshift.release"
+	// releaseBaseImageDigestLabel holds the digest of the image the payload
+	// image of a release is built on
+	releaseBaseImageDigestLabel = "io.openshift.release.base-image-digest"
+	// fromImageStreamAnnotation records the image stream a payload is
+	// assembled from on its image references
+	fromImageStreamAnnotation = "release.openshift.io/from-image-stream"
+
+	// payloadDir holds the manifests of a payload image
+	payloadDir = "release-manifests"
+	// imageReferencesFile lists the images of a payload, or the images the
+	// manifests of an operator refer to
+	imageReferencesFile = "image-references"
+	// releaseMetadataFile holds the version of a payload and its upgrade edges
+	releaseMetadataFile = "release-metadata"
+
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerConfig       = "application/vnd.docker.container.image.v1+json"
+	mediaTypeDockerLayer        = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	mediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCILayer           = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Size      int64  `json:"size"`
+	Digest    string `json:"digest"`
+	Platform  *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+type imageManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Config        descriptor        `json:"config"`
+	Layers        []descriptor      `json:"layers"`
+	Manifests     []descriptor      `json:"manifests,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// payloadImage is an image of a payload with its manifest and configuration
+type payloadImage struct {
+	pullSpec  string
+	manifest  imageManifest
+	mediaType string
+	digest    string
+	config    []byte
+	labels    map[string]string
+}
+
+// payloadAssembler assembles payload images the way `oc adm release new`
+// does, reading and writing images through the registry
+type payloadAssembler struct {
+	registry imagemirror.Registry
+}
+
+// payload describes a payload to assemble
+type payload struct {
+	// version is the name of the release
+	version string
+	// namespace and stream hold the images of the release
+	namespace, stream string
+	// images maps the name of every image of the release to its pull spec
+	images map[string]string
+	// base is the pull spec of the image the payload is built on
+	base string
+	// destination is where the payload is pushed to
+	destination string
+}
+
+// payloadImages lists the images of the release in the stream, replacing the
+// overridden ones
+func payloadImages(stream *imagev1.ImageStream, overrides map[string]string) map[string]string {
+	images := map[string]string{}
+	for _, tag := range stream.Status.Tags {
+		if pullSpec, ok := util.ResolvePullSpec(stream, tag.Tag, true); ok {
+			images[tag.Tag] = pullSpec
+		}
+	}
+	for component, pullSpec := range overrides {
+		images[component] = pullSpec
+	}
+	return images
+}
+
+// assemble composes the payload image from the manifests of the operators
+// of the release, pushes it and returns its manifests and its digest
+func (a *payloadAssembler) assemble(ctx context.Context, p payload) (map[string][]byte, string, error) {
+	files := map[string][]byte{}
+	var names []string
+	for name := range p.images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	images := map[string]*payloadImage{}
+	resolved := map[string]string{}
+	for _, name := range names {
+		image, err := a.image(ctx, p.images[name])
+		if err != nil {
+			return nil, "", fmt.Errorf("could not read image %s: %w", name, err)
+		}
+		images[name] = image
+		resolved[name] = image.pullSpec
+	}
+	for _, name := range names {
+		if images[name].labels[releaseOperatorLabel] != "true" {
+			continue
+		}
+		manifests, err := a.extractManifests(ctx, images[name])
+		if err != nil {
+			return nil, "", fmt.Errorf("could not extract the manifests of %s: %w", name, err)
+		}
+		steps.Logger(ctx).Debugf("Adding %d manifests of %s to the payload", len(manifests), name)
+		if err := addOperatorManifests(files, name, manifests, resolved); err != nil {
+			return nil, "", err
+		}
+	}
+	references, err := imageReferences(p.version, p.namespace, p.stream, resolved)
+	if err != nil {
+		return nil, "", err
+	}
+	files[imageReferencesFile] = references
+	if files[releaseMetadataFile], err = releaseMetadata(p.version); err != nil {
+		return nil, "", err
+	}
+
+	base, err := a.image(ctx, p.base)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not read the base image: %w", err)
+	}
+	layer, diffID, err := payloadLayer(files)
+	if err != nil {
+		return nil, "", err
+	}
+	config, err := payloadConfig(base.config, diffID, p.version, base.digest, time.Now().UTC())
+	if err != nil {
+		return nil, "", err
+	}
+	for _, blob := range base.manifest.Layers {
+		if err := a.registry.CopyBlob(ctx, base.pullSpec, p.destination, blob.Digest); err != nil {
+			return nil, "", fmt.Errorf("could not copy layer %s of the base image: %w", blob.Digest, err)
+		}
+	}
+	layerDigest, err := a.registry.PushBlob(ctx, p.destination, layer)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not push the payload layer: %w", err)
+	}
+	configDigest, err := a.registry.PushBlob(ctx, p.destination, config)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not push the payload configuration: %w", err)
+	}
+	manifest, err := payloadManifest(base.manifest, base.mediaType, descriptor{Size: int64(len(config)), Digest: configDigest}, descriptor{Size: int64(len(layer)), Digest: layerDigest})
+	if err != nil {
+		return nil, "", err
+	}
+	digest, err := a.registry.PushManifest(ctx, p.destination, base.mediaType, manifest)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not push the payload: %w", err)
+	}
+	return files, digest, nil
+}
+
+// image reads the manifest and the configuration of the image, choosing the
+// linux/amd64 image of manifest lists
+func (a *payloadAssembler) image(ctx context.Context, pullSpec string) (*payloadImage, error) {
+	raw, mediaType, digest, err := a.registry.Manifest(ctx, pullSpec)
+	if err != nil {
+		return nil, err
+	}
+	var manifest imageManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("could not parse manifest: %w", err)
+	}
+	if mediaType == "" {
+		mediaType = manifest.MediaType
+	}
+	repository := strings.SplitN(pullSpec, "@", 2)[0]
+	if mediaType == mediaTypeDockerManifestList || mediaType == mediaTypeOCIIndex {
+		for _, child := range manifest.Manifests {
+			if child.Platform == nil || child.Platform.OS == "linux" && child.Platform.Architecture == "amd64" {
+				return a.image(ctx, fmt.Sprintf("%s@%s", repositoryOf(repository), child.Digest))
+			}
+		}
+		return nil, fmt.Errorf("manifest list %s has no linux/amd64 image", pullSpec)
+	}
+	if mediaType != mediaTypeDockerManifest && mediaType != mediaTypeOCIManifest {
+		return nil, fmt.Errorf("manifest %s has unsupported media type %q", pullSpec, mediaType)
+	}
+	blob, err := a.registry.Blob(ctx, pullSpec, manifest.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+	config, err := ioutil.ReadAll(blob)
+	if err != nil {
+		return nil, fmt.Errorf("could not read configuration: %w", err)
+	}
+	var labels struct {
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(config, &labels); err != nil {
+		return nil, fmt.Errorf("could not parse configuration: %w", err)
+	}
+	return &payloadImage{
+		pullSpec:  fmt.Sprintf("%s@%s", repositoryOf(repository), digest),
+		manifest:  manifest,
+		mediaType: mediaType,
+		digest:    digest,
+		config:    config,
+		labels:    labels.Config.Labels,
+	}, nil
+}
+
+// repositoryOf strips the tag from a pull spec without a digest
+func repositoryOf(pullSpec string) string {
+	if slash, colon := strings.LastIndex(pullSpec, "/"), strings.LastIndex(pullSpec, ":"); colon > slash {
+		return pullSpec[:colon]
+	}
+	return pullSpec
+}
+
+// extractManifests reads the files in the manifests directory of the image
+func (a *payloadAssembler) extractManifests(ctx context.Context, image *payloadImage) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for _, layer := range image.manifest.Layers {
+		blob, err := a.registry.Blob(ctx, image.pullSpec, layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		err = readManifests(blob, files)
+		blob.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not read layer %s: %w", layer.Digest, err)
+		}
+	}
+	return files, nil
+}
+
+// readManifests applies the changes a compressed layer makes to the files
+// in the manifests directory
+func readManifests(layer io.Reader, files map[string][]byte) error {
+	uncompressed, err := gzip.NewReader(layer)
+	if err != nil {
+		return err
+	}
+	defer uncompressed.Close()
+	reader := tar.NewReader(uncompressed)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+		dir, file := path.Split(name)
+		if path.Clean(dir) != "manifests" {
+			if name == "manifests" && header.Typeflag != tar.TypeDir {
+				return fmt.Errorf("manifests is not a directory")
+			}
+			continue
+		}
+		switch {
+		case file == ".wh..wh..opq":
+			for existing := range files {
+				delete(files, existing)
+			}
+		case strings.HasPrefix(file, ".wh."):
+			delete(files, strings.TrimPrefix(file, ".wh."))
+		case header.Typeflag == tar.TypeReg:
+			raw, err := ioutil.ReadAll(reader)
+			if err != nil {
+				return err
+			}
+			files[file] = raw
+		}
+	}
+}
+
+// addOperatorManifests adds the manifests of an operator to the payload,
+// pointing the images they refer to at the images of the release
+func addOperatorManifests(files map[string][]byte, operator string, manifests map[string][]byte, images map[string]string) error {
+	replacements := map[string]string{}
+	if raw, ok := manifests[imageReferencesFile]; ok {
+		references := &imagev1.ImageStream{}
+		if err := json.Unmarshal(raw, references); err != nil {
+			return fmt.Errorf("could not parse the image references of %s: %w", operator, err)
+		}
+		for _, tag := range references.Spec.Tags {
+			if tag.From == nil || tag.From.Kind != "DockerImage" {
+				continue
+			}
+			target, ok := images[tag.Name]
+			if !ok {
+				return fmt.Errorf("manifests of %s refer to image %s which is not part of the release", operator, tag.Name)
+			}
+			replacements[tag.From.Name] = target
+		}
+	}
+	var sources []string
+	for source := range replacements {
+		sources = append(sources, source)
+	}
+	// longer references are replaced first so none is replaced in another
+	sort.Slice(sources, func(i, j int) bool { return len(sources[i]) > len(sources[j]) })
+	for name, raw := range manifests {
+		if name == imageReferencesFile {
+			continue
+		}
+		for _, source := range sources {
+			raw = bytes.ReplaceAll(raw, []byte(source), []byte(replacements[source]))
+		}
+		if existing, ok := files[name]; ok && !bytes.Equal(existing, raw) {
+			return fmt.Errorf("manifest %s of %s conflicts with a manifest of another operator", name, operator)
+		}
+		files[name] = raw
+	}
+	return nil
+}
+
+// imageReferences lists the images of the release as an image stream
+func imageReferences(version, namespace, stream string, images map[string]string) ([]byte, error) {
+	references := &imagev1.ImageStream{
+		TypeMeta: metav1.TypeMeta{Kind: "ImageStream", APIVersion: "image.openshift.io/v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        version,
+			Annotations: map[string]string{fromImageStreamAnnotation: fmt.Sprintf("%s/%s", namespace, stream)},
+		},
+	}
+	for name, pullSpec := range images {
+		references.Spec.Tags = append(references.Spec.Tags, imagev1.TagReference{
+			Name: name,
+			From: &coreapi.ObjectReference{Kind: "DockerImage", Name: pullSpec},
+		})
+	}
+	sort.Slice(references.Spec.Tags, func(i, j int) bool { return references.Spec.Tags[i].Name < references.Spec.Tags[j].Name })
+	raw, err := json.MarshalIndent(references, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize the image references: %w", err)
+	}
+	return raw, nil
+}
+
+// releaseMetadata describes the release in the format update graphs read
+func releaseMetadata(version string) ([]byte, error) {
+	raw, err := json.MarshalIndent(map[string]interface{}{
+		"kind":     "cincinnati-metadata-v0",
+		"version":  version,
+		"previous": []string{},
+		"metadata": map[string]string{},
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize the release metadata: %w", err)
+	}
+	return raw, nil
+}
+
+// payloadLayer archives the manifests of the payload and returns the
+// compressed layer and the digest of the archive
+func payloadLayer(files map[string][]byte) ([]byte, string, error) {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	archive := &bytes.Buffer{}
+	writer := tar.NewWriter(archive)
+	// the layer only depends on the manifests so that it has the same digest
+	// whenever they are the same
+	modified := time.Unix(0, 0).UTC()
+	if err := writer.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: payloadDir + "/", Mode: 0755, ModTime: modified}); err != nil {
+		return nil, "", err
+	}
+	for _, name := range names {
+		header := &tar.Header{Typeflag: tar.TypeReg, Name: path.Join(payloadDir, name), Mode: 0644, Size: int64(len(files[name])), ModTime: modified}
+		if err := writer.WriteHeader(header); err != nil {
+			return nil, "", err
+		}
+		if _, err := writer.Write(files[name]); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	diffID := fmt.Sprintf("sha256:%x", sha256.Sum256(archive.Bytes()))
+	compressed := &bytes.Buffer{}
+	gz := gzip.NewWriter(compressed)
+	if _, err := gz.Write(archive.Bytes()); err != nil {
+		return nil, "", err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, "", err
+	}
+	return compressed.Bytes(), diffID, nil
+}
+
+// payloadConfig adds the payload layer and the release labels to the
+// configuration of the base image, keeping the fields it does not know
+func payloadConfig(base []byte, diffID, version, baseDigest string, created time.Time) ([]byte, error) {
+	var config map[string]interface{}
+	if err := json.Unmarshal(base, &config); err != nil {
+		return nil, fmt.Errorf("could not parse the configuration of the base image: %w", err)
+	}
+	object := func(parent map[string]interface{}, key string) map[string]interface{} {
+		child, ok := parent[key].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			parent[key] = child
+		}
+		return child
+	}
+	labels := object(object(config, "config"), "Labels")
+	labels[releaseLabel] = version
+	labels[releaseBaseImageDigestLabel] = baseDigest
+	rootfs := object(config, "rootfs")
+	rootfs["type"] = "layers"
+	diffIDs, _ := rootfs["diff_ids"].([]interface{})
+	rootfs["diff_ids"] = append(diffIDs, diffID)
+	history, _ := config["history"].([]interface{})
+	config["history"] = append(history, map[string]interface{}{
+		"created":    created.Format(time.RFC3339),
+		"created_by": fmt.Sprintf("release payload %s", version),
+	})
+	config["created"] = created.Format(time.RFC3339)
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize the payload configuration: %w", err)
+	}
+	return raw, nil
+}
+
+// payloadManifest adds the payload layer to the manifest of the base image
+// and points it at the payload configuration
+func payloadManifest(base imageManifest, mediaType string, config, layer descriptor) ([]byte, error) {
+	manifest := base
+	manifest.Layers = append([]descriptor{}, base.Layers...)
+	manifest.Config = config
+	switch mediaType {
+	case mediaTypeDockerManifest:
+		manifest.Config.MediaType, layer.MediaType = mediaTypeDockerConfig, mediaTypeDockerLayer
+	case mediaTypeOCIManifest:
+		manifest.Config.MediaType, layer.MediaType = base.Config.MediaType, mediaTypeOCILayer
+	default:
+		return nil, fmt.Errorf("cannot build a payload on an image with manifest type %q", mediaType)
+	}
+	manifest.Layers = append(manifest.Layers, layer)
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize the payload manifest: %w", err)
+	}
+	return raw, nil
+}
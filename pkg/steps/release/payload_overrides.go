@@ -0,0 +1,112 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/retry"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps"
+)
+
+// PayloadOverridesEnv holds payload component overrides passed to a job as a
+// parameter, in the same form as the --payload-override flag and separated
+// by commas or whitespace
+const PayloadOverridesEnv = "PAYLOAD_OVERRIDES"
+
+// PayloadOverrides maps the name of a release to the components of its payload
+// that are replaced with another pull spec, keyed by the component name
+type PayloadOverrides map[string]map[string]string
+
+// ParsePayloadOverrides parses overrides in the form [RELEASE:]COMPONENT=PULLSPEC,
+// where the release defaults to latest
+func ParsePayloadOverrides(values []string) (PayloadOverrides, error) {
+	overrides := PayloadOverrides{}
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("payload override must be in the form [RELEASE:]COMPONENT=PULLSPEC, not %q", value)
+		}
+		release, component := api.LatestReleaseName, parts[0]
+		if idx := strings.Index(component, ":"); idx != -1 {
+			release, component = component[:idx], component[idx+1:]
+		}
+		if release == "" {
+			return nil, fmt.Errorf("payload override %q: release name cannot be empty", value)
+		}
+		if msgs := validation.IsDNS1123Subdomain(component); len(msgs) != 0 {
+			return nil, fmt.Errorf("payload override %q: invalid component name %q: %s", value, component, strings.Join(msgs, ", "))
+		}
+		if overrides[release] == nil {
+			overrides[release] = map[string]string{}
+		}
+		if existing, ok := overrides[release][component]; ok && existing != parts[1] {
+			return nil, fmt.Errorf("payload override %q: component %s of release %s is already overridden with %s", value, component, release, existing)
+		}
+		overrides[release][component] = parts[1]
+	}
+	return overrides, nil
+}
+
+// overrideArgs formats the overrides as arguments for `oc adm release new`,
+// in a stable order so they can be part of the inputs of a step
+func overrideArgs(overrides map[string]string) []string {
+	var args []string
+	for component, pullSpec := range overrides {
+		args = append(args, fmt.Sprintf("%s=%s", component, pullSpec))
+	}
+	sort.Strings(args)
+	return args
+}
+
+func quotedOverrideArgs(overrides map[string]string) string {
+	var quoted []string
+	for _, arg := range overrideArgs(overrides) {
+		quoted = append(quoted, fmt.Sprintf("%q", arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+func logOverrides(ctx context.Context, release string, overrides map[string]string) {
+	for _, arg := range overrideArgs(overrides) {
+		parts := strings.SplitN(arg, "=", 2)
+		steps.Logger(ctx).Infof("Overriding component %s of release %s with %s", parts[0], release, parts[1])
+	}
+}
+
+// tagOverrides points the tags of the overridden components in the stable
+// stream to their replacements, so tests consuming them directly see the
+// same images as the payload
+func tagOverrides(ctx context.Context, client ctrlruntimeclient.Client, namespace, streamName string, overrides map[string]string) error {
+	for component, pullSpec := range overrides {
+		if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			return client.Update(ctx, &imagev1.ImageStreamTag{
+				ObjectMeta: meta.ObjectMeta{
+					Namespace: namespace,
+					Name:      fmt.Sprintf("%s:%s", streamName, component),
+				},
+				Tag: &imagev1.TagReference{
+					ReferencePolicy: imagev1.TagReferencePolicy{
+						Type: imagev1.LocalTagReferencePolicy,
+					},
+					From: &coreapi.ObjectReference{
+						Kind: "DockerImage",
+						Name: pullSpec,
+					},
+				},
+			})
+		}); err != nil {
+			return fmt.Errorf("unable to tag the override for %s into the %s stream: %w", component, streamName, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package release
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestParsePayloadOverrides(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		values      []string
+		expected    PayloadOverrides
+		expectedErr error
+	}{
+		{
+			name:     "no overrides",
+			expected: PayloadOverrides{},
+		},
+		{
+			name:   "release defaults to latest",
+			values: []string{"installer=quay.io/org/installer@sha256:abc", "initial:cli=quay.io/org/cli:fix"},
+			expected: PayloadOverrides{
+				"latest":  {"installer": "quay.io/org/installer@sha256:abc"},
+				"initial": {"cli": "quay.io/org/cli:fix"},
+			},
+		},
+		{
+			name:     "repeated override is accepted",
+			values:   []string{"installer=quay.io/org/installer:fix", "latest:installer=quay.io/org/installer:fix"},
+			expected: PayloadOverrides{"latest": {"installer": "quay.io/org/installer:fix"}},
+		},
+		{
+			name:        "conflicting override is rejected",
+			values:      []string{"installer=quay.io/org/installer:fix", "installer=quay.io/org/installer:other"},
+			expectedErr: errors.New(`payload override "installer=quay.io/org/installer:other": component installer of release latest is already overridden with quay.io/org/installer:fix`),
+		},
+		{
+			name:        "missing pull spec is rejected",
+			values:      []string{"installer"},
+			expectedErr: errors.New(`payload override must be in the form [RELEASE:]COMPONENT=PULLSPEC, not "installer"`),
+		},
+		{
+			name:        "empty release is rejected",
+			values:      []string{":installer=quay.io/org/installer:fix"},
+			expectedErr: errors.New(`payload override ":installer=quay.io/org/installer:fix": release name cannot be empty`),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := ParsePayloadOverrides(testCase.values)
+			if diff := cmp.Diff(testCase.expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected overrides: %s", diff)
+			}
+		})
+	}
+}
+
+func TestOverrideArgs(t *testing.T) {
+	overrides := map[string]string{"machine-os-content": "quay.io/org/rhcos:fix", "installer": "quay.io/org/installer:fix"}
+	expected := []string{"installer=quay.io/org/installer:fix", "machine-os-content=quay.io/org/rhcos:fix"}
+	if diff := cmp.Diff(expected, overrideArgs(overrides)); diff != "" {
+		t.Errorf("unexpected arguments: %s", diff)
+	}
+	if diff := cmp.Diff(`"installer=quay.io/org/installer:fix" "machine-os-content=quay.io/org/rhcos:fix"`, quotedOverrideArgs(overrides)); diff != "" {
+		t.Errorf("unexpected quoted arguments: %s", diff)
+	}
+}
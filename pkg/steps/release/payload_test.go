@@ -0,0 +1,385 @@
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	imagev1 "github.com/openshift/api/image/v1"
+)
+
+// layerOf archives the files as a compressed layer, empty contents are
+// written as directories
+func layerOf(t *testing.T, files ...string) []byte {
+	archive := &bytes.Buffer{}
+	gz := gzip.NewWriter(archive)
+	writer := tar.NewWriter(gz)
+	for i := 0; i < len(files); i += 2 {
+		header := &tar.Header{Typeflag: tar.TypeReg, Name: files[i], Mode: 0644, Size: int64(len(files[i+1]))}
+		if files[i+1] == "" {
+			header.Typeflag, header.Size = tar.TypeDir, 0
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(files[i+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+func TestReadManifests(t *testing.T) {
+	files := map[string][]byte{}
+	for _, layer := range [][]byte{
+		layerOf(t, "manifests/", "", "manifests/0000_50_operator.yaml", "operator", "manifests/removed.yaml", "removed", "usr/bin/operator", "binary"),
+		layerOf(t, "./manifests/image-references", "references", "manifests/.wh.removed.yaml", "", "manifests/nested/ignored.yaml", "nested"),
+	} {
+		if err := readManifests(bytes.NewReader(layer), files); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	expected := map[string][]byte{"0000_50_operator.yaml": []byte("operator"), "image-references": []byte("references")}
+	if diff := cmp.Diff(expected, files); diff != "" {
+		t.Errorf("unexpected manifests: %s", diff)
+	}
+	if err := readManifests(bytes.NewReader(layerOf(t, "manifests/.wh..wh..opq", "")), files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected an opaque whiteout to remove the manifests, got %v", files)
+	}
+}
+
+func TestAddOperatorManifests(t *testing.T) {
+	references := `{"kind":"ImageStream","apiVersion":"image.openshift.io/v1","spec":{"tags":[
+		{"name":"operator","from":{"kind":"DockerImage","name":"quay.io/org/operator:latest"}},
+		{"name":"operand","from":{"kind":"DockerImage","name":"quay.io/org/operator:latest-operand"}}]}}`
+	images := map[string]string{
+		"operator": "registry/ns/stable@sha256:operator",
+		"operand":  "registry/ns/stable@sha256:operand",
+	}
+	var testCases = []struct {
+		name        string
+		existing    map[string][]byte
+		manifests   map[string][]byte
+		images      map[string]string
+		expected    map[string][]byte
+		expectedErr string
+	}{
+		{
+			name: "images are replaced with those of the release",
+			manifests: map[string][]byte{
+				"image-references":            []byte(references),
+				"0000_50_deployment.yaml":     []byte("image: quay.io/org/operator:latest\nOPERAND: quay.io/org/operator:latest-operand"),
+				"0000_50_namespace.yaml":      []byte("kind: Namespace"),
+				"0000_90_servicemonitor.yaml": []byte("kind: ServiceMonitor"),
+			},
+			images: images,
+			expected: map[string][]byte{
+				"0000_50_deployment.yaml":     []byte("image: registry/ns/stable@sha256:operator\nOPERAND: registry/ns/stable@sha256:operand"),
+				"0000_50_namespace.yaml":      []byte("kind: Namespace"),
+				"0000_90_servicemonitor.yaml": []byte("kind: ServiceMonitor"),
+			},
+		},
+		{
+			name:        "image missing from the release",
+			manifests:   map[string][]byte{"image-references": []byte(references)},
+			images:      map[string]string{"operator": "registry/ns/stable@sha256:operator"},
+			expectedErr: "manifests of operator refer to image operand which is not part of the release",
+		},
+		{
+			name:        "conflicting manifest",
+			existing:    map[string][]byte{"0000_50_namespace.yaml": []byte("kind: Namespace\nname: other")},
+			manifests:   map[string][]byte{"0000_50_namespace.yaml": []byte("kind: Namespace")},
+			images:      images,
+			expectedErr: "manifest 0000_50_namespace.yaml of operator conflicts with a manifest of another operator",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			files := map[string][]byte{}
+			for name, raw := range testCase.existing {
+				files[name] = raw
+			}
+			err := addOperatorManifests(files, "operator", testCase.manifests, testCase.images)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(testCase.expected, files); diff != "" {
+				t.Errorf("unexpected manifests: %s", diff)
+			}
+		})
+	}
+}
+
+func TestImageReferences(t *testing.T) {
+	raw, err := imageReferences("4.9.0-0.ci", "ns", "stable", map[string]string{"cli": "registry/ns/stable@sha256:cli", "cluster-version-operator": "registry/ns/stable@sha256:cvo"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	references := &imagev1.ImageStream{}
+	if err := json.Unmarshal(raw, references); err != nil {
+		t.Fatalf("invalid image references: %v", err)
+	}
+	if references.Kind != "ImageStream" || references.Name != "4.9.0-0.ci" || references.Annotations[fromImageStreamAnnotation] != "ns/stable" {
+		t.Errorf("unexpected metadata: %s", raw)
+	}
+	var tags []string
+	for _, tag := range references.Spec.Tags {
+		tags = append(tags, fmt.Sprintf("%s=%s", tag.Name, tag.From.Name))
+	}
+	if diff := cmp.Diff([]string{"cli=registry/ns/stable@sha256:cli", "cluster-version-operator=registry/ns/stable@sha256:cvo"}, tags); diff != "" {
+		t.Errorf("unexpected tags: %s", diff)
+	}
+}
+
+func TestPayloadLayerAndConfig(t *testing.T) {
+	files := map[string][]byte{"image-references": []byte("references"), "0000_50_operator.yaml": []byte("operator")}
+	layer, diffID, err := payloadLayer(files)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again, _, _ := payloadLayer(files); !bytes.Equal(layer, again) {
+		t.Errorf("expected the layer to only depend on the manifests")
+	}
+	uncompressed, err := gzip.NewReader(bytes.NewReader(layer))
+	if err != nil {
+		t.Fatalf("invalid layer: %v", err)
+	}
+	archive, _ := ioutil.ReadAll(uncompressed)
+	if actual := fmt.Sprintf("sha256:%x", sha256.Sum256(archive)); actual != diffID {
+		t.Errorf("expected diff ID %s, got %s", actual, diffID)
+	}
+	var names []string
+	reader := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid layer: %v", err)
+		}
+		names = append(names, header.Name)
+	}
+	if diff := cmp.Diff([]string{"release-manifests/", "release-manifests/0000_50_operator.yaml", "release-manifests/image-references"}, names); diff != "" {
+		t.Errorf("unexpected layer contents: %s", diff)
+	}
+
+	base := `{"architecture":"amd64","config":{"Entrypoint":["/usr/bin/cvo"],"Labels":{"vendor":"Red Hat"}},"rootfs":{"type":"layers","diff_ids":["sha256:base"]},"history":[{"created_by":"base"}]}`
+	created := time.Date(2021, 5, 4, 3, 2, 1, 0, time.UTC)
+	raw, err := payloadConfig([]byte(base), diffID, "4.9.0-0.ci", "sha256:cvo", created)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var config struct {
+		Architecture string `json:"architecture"`
+		Created      string `json:"created"`
+		Config       struct {
+			Entrypoint []string          `json:"Entrypoint"`
+			Labels     map[string]string `json:"Labels"`
+		} `json:"config"`
+		RootFS struct {
+			DiffIDs []string `json:"diff_ids"`
+		} `json:"rootfs"`
+		History []map[string]string `json:"history"`
+	}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		t.Fatalf("invalid configuration: %v", err)
+	}
+	if config.Architecture != "amd64" || config.Created != "2021-05-04T03:02:01Z" || len(config.Config.Entrypoint) != 1 || len(config.History) != 2 {
+		t.Errorf("expected the configuration of the base image to be kept: %s", raw)
+	}
+	if diff := cmp.Diff(map[string]string{"vendor": "Red Hat", releaseLabel: "4.9.0-0.ci", releaseBaseImageDigestLabel: "sha256:cvo"}, config.Config.Labels); diff != "" {
+		t.Errorf("unexpected labels: %s", diff)
+	}
+	if diff := cmp.Diff([]string{"sha256:base", diffID}, config.RootFS.DiffIDs); diff != "" {
+		t.Errorf("unexpected layers: %s", diff)
+	}
+}
+
+// fakeRegistry stores images in memory
+type fakeRegistry struct {
+	manifests map[string][]byte
+	blobs     map[string][]byte
+}
+
+func digestOf(raw []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(raw))
+}
+
+func repository(pullSpec string) string {
+	return repositoryOf(strings.SplitN(pullSpec, "@", 2)[0])
+}
+
+func (r *fakeRegistry) Mirror(context.Context, string, string) (string, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
+func (r *fakeRegistry) Manifest(_ context.Context, pullSpec string) ([]byte, string, string, error) {
+	raw, ok := r.manifests[pullSpec]
+	if !ok {
+		return nil, "", "", fmt.Errorf("manifest %s not found", pullSpec)
+	}
+	return raw, "", digestOf(raw), nil
+}
+
+func (r *fakeRegistry) Blob(_ context.Context, pullSpec, digest string) (io.ReadCloser, error) {
+	raw, ok := r.blobs[repository(pullSpec)+"@"+digest]
+	if !ok {
+		return nil, fmt.Errorf("blob %s not found in %s", digest, repository(pullSpec))
+	}
+	return ioutil.NopCloser(bytes.NewReader(raw)), nil
+}
+
+func (r *fakeRegistry) CopyBlob(_ context.Context, source, destination, digest string) error {
+	raw, ok := r.blobs[repository(source)+"@"+digest]
+	if !ok {
+		return fmt.Errorf("blob %s not found in %s", digest, repository(source))
+	}
+	r.blobs[repository(destination)+"@"+digest] = raw
+	return nil
+}
+
+func (r *fakeRegistry) PushBlob(_ context.Context, pullSpec string, raw []byte) (string, error) {
+	r.blobs[repository(pullSpec)+"@"+digestOf(raw)] = raw
+	return digestOf(raw), nil
+}
+
+func (r *fakeRegistry) PushManifest(_ context.Context, pullSpec, _ string, raw []byte) (string, error) {
+	r.manifests[pullSpec] = raw
+	r.manifests[repository(pullSpec)+"@"+digestOf(raw)] = raw
+	return digestOf(raw), nil
+}
+
+// add stores an image with the labels and layers, returning its pull spec
+func (r *fakeRegistry) add(t *testing.T, labels map[string]string, layers ...[]byte) string {
+	config, err := json.Marshal(map[string]interface{}{"config": map[string]interface{}{"Labels": labels}, "rootfs": map[string]interface{}{"type": "layers"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := imageManifest{SchemaVersion: 2, MediaType: mediaTypeDockerManifest, Config: descriptor{MediaType: mediaTypeDockerConfig, Size: int64(len(config)), Digest: digestOf(config)}}
+	r.blobs["registry/ns/stable@"+digestOf(config)] = config
+	for _, layer := range layers {
+		manifest.Layers = append(manifest.Layers, descriptor{MediaType: mediaTypeDockerLayer, Size: int64(len(layer)), Digest: digestOf(layer)})
+		r.blobs["registry/ns/stable@"+digestOf(layer)] = layer
+	}
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pullSpec := "registry/ns/stable@" + digestOf(raw)
+	r.manifests[pullSpec] = raw
+	return pullSpec
+}
+
+func TestAssemblePayload(t *testing.T) {
+	registry := &fakeRegistry{manifests: map[string][]byte{}, blobs: map[string][]byte{}}
+	operator := map[string]string{releaseOperatorLabel: "true"}
+	cvo := registry.add(t, operator, layerOf(t, "manifests/0000_00_cvo.yaml", "kind: Deployment"))
+	references := `{"kind":"ImageStream","apiVersion":"image.openshift.io/v1","spec":{"tags":[{"name":"machine-config-operator","from":{"kind":"DockerImage","name":"quay.io/mco:latest"}}]}}`
+	// the manifests of the overridden image are part of the payload
+	override := registry.add(t, operator, layerOf(t, "manifests/0000_80_mco.yaml", "image: quay.io/mco:latest", "manifests/image-references", references, "manifests/0000_80_new.yaml", "kind: ConfigMap"))
+	cli := registry.add(t, nil, layerOf(t, "usr/bin/oc", "oc"))
+
+	assembler := &payloadAssembler{registry: registry}
+	files, digest, err := assembler.assemble(context.Background(), payload{
+		version:     "4.9.0-0.ci",
+		namespace:   "ns",
+		stream:      "stable",
+		images:      map[string]string{"cluster-version-operator": cvo, "machine-config-operator": override, "cli": cli},
+		base:        cvo,
+		destination: "registry/ns/release:latest",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if diff := cmp.Diff([]string{"0000_00_cvo.yaml", "0000_80_mco.yaml", "0000_80_new.yaml", "image-references", "release-metadata"}, names); diff != "" {
+		t.Errorf("unexpected manifests: %s", diff)
+	}
+	if diff := cmp.Diff("image: "+override, string(files["0000_80_mco.yaml"])); diff != "" {
+		t.Errorf("expected the overridden image to be referenced: %s", diff)
+	}
+
+	raw, ok := registry.manifests["registry/ns/release:latest"]
+	if !ok || digestOf(raw) != digest {
+		t.Fatalf("expected the payload to be pushed with digest %s", digest)
+	}
+	var manifest imageManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+	if len(manifest.Layers) != 2 || manifest.Layers[1].MediaType != mediaTypeDockerLayer {
+		t.Fatalf("expected the payload layer to be added to the base image: %s", raw)
+	}
+	for _, blob := range append([]descriptor{manifest.Config}, manifest.Layers...) {
+		if _, ok := registry.blobs["registry/ns/release@"+blob.Digest]; !ok {
+			t.Errorf("blob %s was not pushed", blob.Digest)
+		}
+	}
+	extracted := map[string][]byte{}
+	layer := registry.blobs["registry/ns/release@"+manifest.Layers[1].Digest]
+	if err := readPayload(layer, extracted); err != nil {
+		t.Fatalf("invalid payload layer: %v", err)
+	}
+	if diff := cmp.Diff(files, extracted); diff != "" {
+		t.Errorf("unexpected payload layer: %s", diff)
+	}
+}
+
+// readPayload reads the manifests of a payload layer
+func readPayload(layer []byte, files map[string][]byte) error {
+	uncompressed, err := gzip.NewReader(bytes.NewReader(layer))
+	if err != nil {
+		return err
+	}
+	reader := tar.NewReader(uncompressed)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		raw, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		files[strings.TrimPrefix(header.Name, payloadDir+"/")] = raw
+	}
+}
@@ -3,7 +3,6 @@ package release
 import (
 	"context"
 	"fmt"
-	"log"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -20,7 +19,9 @@ import (
 	imagev1 "github.com/openshift/api/image/v1"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/quay"
 	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/signing"
 	"github.com/openshift/ci-tools/pkg/steps"
 	"github.com/openshift/ci-tools/pkg/steps/utils"
 )
@@ -29,18 +30,52 @@ import (
 // of images out to the configured namespace.
 type promotionStep struct {
 	config         api.PromotionConfiguration
+	metadata       api.Metadata
 	images         []api.ProjectDirectoryImageBuildStepConfiguration
 	requiredImages sets.String
 	jobSpec        *api.JobSpec
 	client         steps.PodClient
 	pushSecret     *coreapi.Secret
+	signingSecret  *coreapi.Secret
+	quay           quay.Client
 }
 
-func targetName(config api.PromotionConfiguration) string {
-	if len(config.Name) > 0 {
-		return fmt.Sprintf("%s/%s:${component}", config.Namespace, config.Name)
+func targetName(target api.PromotionTarget) string {
+	ref := promotedTag(target, api.Metadata{}, "${component}")
+	if len(target.Name) > 0 && len(target.TagTemplate) > 0 {
+		ref.Tag = target.TagTemplate
 	}
-	return fmt.Sprintf("%s/${component}:%s", config.Namespace, config.Tag)
+	if target.Quay != nil {
+		return fmt.Sprintf("%s/%s/%s:%s", quay.Host, ref.Namespace, ref.Name, ref.Tag)
+	}
+	return fmt.Sprintf("%s/%s:%s", ref.Namespace, ref.Name, ref.Tag)
+}
+
+func targetNames(targets []api.PromotionTarget) string {
+	var names []string
+	for _, target := range targets {
+		names = append(names, targetName(target))
+	}
+	return strings.Join(names, ", ")
+}
+
+// promotedTag determines the image stream tag a component is promoted to
+// in the target
+func promotedTag(target api.PromotionTarget, metadata api.Metadata, component string) api.ImageStreamTagReference {
+	if len(target.Name) == 0 {
+		return api.ImageStreamTagReference{Namespace: target.Namespace, Name: component, Tag: target.Tag}
+	}
+	tag := component
+	if len(target.TagTemplate) > 0 {
+		tag = strings.NewReplacer(
+			"${component}", component,
+			"${org}", metadata.Org,
+			"${repo}", metadata.Repo,
+			"${branch}", metadata.Branch,
+			"${variant}", metadata.Variant,
+		).Replace(target.TagTemplate)
+	}
+	return api.ImageStreamTagReference{Namespace: target.Namespace, Name: target.Name, Tag: tag}
 }
 
 func (s *promotionStep) Inputs() (api.InputDefinition, error) {
@@ -57,17 +92,18 @@ var promotionRetry = wait.Backoff{
 }
 
 func (s *promotionStep) Run(ctx context.Context) error {
-	return results.ForReason("promoting_images").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonPromotingImages).ForError(s.run(ctx))
 }
 
 func (s *promotionStep) run(ctx context.Context) error {
 	tags, names := toPromote(s.config, s.images, s.requiredImages)
 	if len(names) == 0 {
-		log.Println("Nothing to promote, skipping...")
+		steps.Logger(ctx).Info("Nothing to promote, skipping...")
 		return nil
 	}
 
-	log.Printf("Promoting tags to %s: %s", targetName(s.config), strings.Join(names.List(), ", "))
+	targets := s.config.EnabledTargets()
+	steps.Logger(ctx).Infof("Promoting tags to %s: %s", targetNames(targets), strings.Join(names.List(), ", "))
 	pipeline := &imagev1.ImageStream{}
 	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{
 		Namespace: s.jobSpec.Namespace(),
@@ -77,25 +113,106 @@ func (s *promotionStep) run(ctx context.Context) error {
 	}
 
 	if s.pushSecret != nil {
-		imageMirrorTarget := getImageMirrorTarget(s.config, tags, pipeline)
-		if len(imageMirrorTarget) == 0 {
-			log.Println("Nothing to promote, skipping...")
+		if s.config.Signing != nil && !s.config.Signing.Keyless && s.signingSecret == nil {
+			return fmt.Errorf("cannot sign promoted images without a signing key")
+		}
+		if err := s.ensureQuayRepositories(targets, tags, pipeline); err != nil {
+			return err
+		}
+		var imageMirrorTargets []map[string]string
+		for _, target := range targets {
+			if imageMirrorTarget := getImageMirrorTarget(ctx, target, s.metadata, tags, pipeline); len(imageMirrorTarget) > 0 {
+				imageMirrorTargets = append(imageMirrorTargets, imageMirrorTarget)
+			}
+		}
+		if len(imageMirrorTargets) == 0 {
+			steps.Logger(ctx).Info("Nothing to promote, skipping...")
 			return nil
 		}
 
-		if _, err := steps.RunPod(ctx, s.client, getPromotionPod(imageMirrorTarget, s.jobSpec.Namespace())); err != nil {
+		if _, err := steps.RunPod(ctx, s.client, getPromotionPod(imageMirrorTargets, s.jobSpec.Namespace(), s.config.Signing)); err != nil {
 			return fmt.Errorf("unable to run promotion pod: %w", err)
 		}
+		for _, image := range quayPushedImages(targets, s.metadata, tags, pipeline) {
+			steps.Logger(ctx).Infof("Pushed %s", image)
+		}
 		return nil
 	}
 
-	if len(s.config.Name) > 0 {
+	for _, target := range targets {
+		if err := s.promoteToTarget(ctx, target, tags, pipeline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureQuayRepositories creates the repositories images are pushed to on
+// Quay and grants the configured robot accounts access to them
+func (s *promotionStep) ensureQuayRepositories(targets []api.PromotionTarget, tags map[string]string, pipeline *imagev1.ImageStream) error {
+	for _, target := range targets {
+		if target.Quay == nil {
+			continue
+		}
+		if s.quay == nil {
+			return fmt.Errorf("cannot promote to %s without a Quay API token", targetName(target))
+		}
+		repositories := sets.NewString()
+		for dst, src := range tags {
+			if findDockerImageReference(pipeline, src) != "" {
+				repositories.Insert(promotedTag(target, s.metadata, dst).Name)
+			}
+		}
+		robots := sets.StringKeySet(target.Quay.RobotPermissions).List()
+		for _, repository := range repositories.List() {
+			if err := s.quay.EnsureRepository(target.Namespace, repository, target.Quay.Public); err != nil {
+				return fmt.Errorf("could not ensure Quay repository: %w", err)
+			}
+			for _, robot := range robots {
+				if err := s.quay.SetRobotPermission(target.Namespace, repository, robot, quay.Role(target.Quay.RobotPermissions[robot])); err != nil {
+					return fmt.Errorf("could not set permissions on Quay repository: %w", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// quayPushedImages lists the images pushed to Quay by tag and digest
+func quayPushedImages(targets []api.PromotionTarget, metadata api.Metadata, tags map[string]string, pipeline *imagev1.ImageStream) []string {
+	var images []string
+	for _, target := range targets {
+		if target.Quay == nil {
+			continue
+		}
+		for _, dst := range sets.StringKeySet(tags).List() {
+			dockerImageReference := findDockerImageReference(pipeline, tags[dst])
+			parts := strings.SplitN(dockerImageReference, "@", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			ref := promotedTag(target, metadata, dst)
+			repository := fmt.Sprintf("%s/%s/%s", quay.Host, ref.Namespace, ref.Name)
+			images = append(images, fmt.Sprintf("%s:%s (%s@%s)", repository, ref.Tag, repository, parts[1]))
+		}
+	}
+	return images
+}
+
+func (s *promotionStep) promoteToTarget(ctx context.Context, target api.PromotionTarget, tags map[string]string, pipeline *imagev1.ImageStream) error {
+	if target.Quay != nil {
+		return fmt.Errorf("cannot promote to %s without push credentials", targetName(target))
+	}
+	if s.config.Signing != nil {
+		return fmt.Errorf("cannot sign images promoted to %s without push credentials", targetName(target))
+	}
+	if len(target.Name) > 0 {
 		return retry.RetryOnConflict(promotionRetry, func() error {
 			is := &imagev1.ImageStream{}
-			err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.config.Namespace, Name: s.config.Name}, is)
+			err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: target.Namespace, Name: target.Name}, is)
 			if errors.IsNotFound(err) {
-				is.Namespace = s.config.Namespace
-				is.Name = s.config.Name
+				is.Namespace = target.Namespace
+				is.Name = target.Name
 				if err := s.client.Create(ctx, is); err != nil {
 					return fmt.Errorf("could not retrieve target imagestream: %w", err)
 				}
@@ -104,7 +221,7 @@ func (s *promotionStep) run(ctx context.Context) error {
 			for dst, src := range tags {
 				if valid, _ := utils.FindStatusTag(pipeline, src); valid != nil {
 					is.Spec.Tags = append(is.Spec.Tags, imagev1.TagReference{
-						Name: dst,
+						Name: promotedTag(target, s.metadata, dst).Tag,
 						From: valid,
 					})
 				}
@@ -127,12 +244,12 @@ func (s *promotionStep) run(ctx context.Context) error {
 		}
 
 		err := retry.RetryOnConflict(promotionRetry, func() error {
-			err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.config.Namespace, Name: dst}, &imagev1.ImageStream{})
+			err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: target.Namespace, Name: dst}, &imagev1.ImageStream{})
 			if errors.IsNotFound(err) {
 				err = s.client.Create(ctx, &imagev1.ImageStream{
 					ObjectMeta: meta.ObjectMeta{
 						Name:      dst,
-						Namespace: s.config.Namespace,
+						Namespace: target.Namespace,
 					},
 					Spec: imagev1.ImageStreamSpec{
 						LookupPolicy: imagev1.ImageLookupPolicy{
@@ -147,11 +264,11 @@ func (s *promotionStep) run(ctx context.Context) error {
 
 			ist := &imagev1.ImageStreamTag{
 				ObjectMeta: meta.ObjectMeta{
-					Name:      fmt.Sprintf("%s:%s", dst, s.config.Tag),
-					Namespace: s.config.Namespace,
+					Name:      fmt.Sprintf("%s:%s", dst, target.Tag),
+					Namespace: target.Namespace,
 				},
 				Tag: &imagev1.TagReference{
-					Name: s.config.Tag,
+					Name: target.Tag,
 					From: valid,
 				},
 			}
@@ -170,29 +287,23 @@ func (s *promotionStep) run(ctx context.Context) error {
 	return nil
 }
 
-func getImageMirrorTarget(config api.PromotionConfiguration, tags map[string]string, pipeline *imagev1.ImageStream) map[string]string {
+func getImageMirrorTarget(ctx context.Context, target api.PromotionTarget, metadata api.Metadata, tags map[string]string, pipeline *imagev1.ImageStream) map[string]string {
 	if pipeline == nil {
 		return nil
 	}
+	registry := api.DomainForService(api.ServiceRegistry)
+	if target.Quay != nil {
+		registry = quay.Host
+	}
 	imageMirror := map[string]string{}
-	if len(config.Name) > 0 {
-		for dst, src := range tags {
-			dockerImageReference := findDockerImageReference(pipeline, src)
-			if dockerImageReference == "" {
-				continue
-			}
-			dockerImageReference = getPublicImageReference(dockerImageReference, pipeline.Status.PublicDockerImageRepository)
-			imageMirror[dockerImageReference] = fmt.Sprintf("%s/%s/%s:%s", api.DomainForService(api.ServiceRegistry), config.Namespace, config.Name, dst)
-		}
-	} else {
-		for dst, src := range tags {
-			dockerImageReference := findDockerImageReference(pipeline, src)
-			if dockerImageReference == "" {
-				continue
-			}
-			dockerImageReference = getPublicImageReference(dockerImageReference, pipeline.Status.PublicDockerImageRepository)
-			imageMirror[dockerImageReference] = fmt.Sprintf("%s/%s/%s:%s", api.DomainForService(api.ServiceRegistry), config.Namespace, dst, config.Tag)
+	for dst, src := range tags {
+		dockerImageReference := findDockerImageReference(pipeline, src)
+		if dockerImageReference == "" {
+			continue
 		}
+		dockerImageReference = getPublicImageReference(ctx, dockerImageReference, pipeline.Status.PublicDockerImageRepository)
+		ref := promotedTag(target, metadata, dst)
+		imageMirror[dockerImageReference] = fmt.Sprintf("%s/%s/%s:%s", registry, ref.Namespace, ref.Name, ref.Tag)
 	}
 	if len(imageMirror) == 0 {
 		return nil
@@ -200,42 +311,160 @@ func getImageMirrorTarget(config api.PromotionConfiguration, tags map[string]str
 	return imageMirror
 }
 
-func getPublicImageReference(dockerImageReference, publicDockerImageRepository string) string {
+func getPublicImageReference(ctx context.Context, dockerImageReference, publicDockerImageRepository string) string {
 	if !strings.Contains(dockerImageReference, ":5000") {
 		return dockerImageReference
 	}
 	splits := strings.Split(publicDockerImageRepository, "/")
 	if len(splits) < 2 {
 		// This should never happen
-		log.Println(fmt.Sprintf("Failed to get hostname from publicDockerImageRepository: %s.", publicDockerImageRepository))
+		steps.Logger(ctx).Warnf("Failed to get hostname from publicDockerImageRepository: %s.", publicDockerImageRepository)
 		return dockerImageReference
 	}
 	publicHost := splits[0]
 	splits = strings.Split(dockerImageReference, "/")
 	if len(splits) < 2 {
 		// This should never happen
-		log.Println(fmt.Sprintf("Failed to get hostname from dockerImageReference: %s.", dockerImageReference))
+		steps.Logger(ctx).Warnf("Failed to get hostname from dockerImageReference: %s.", dockerImageReference)
 		return dockerImageReference
 	}
 	return strings.Replace(dockerImageReference, splits[0], publicHost, 1)
 }
 
-func getPromotionPod(imageMirrorTarget map[string]string, namespace string) *coreapi.Pod {
-	var ocCommands []string
-	keys := make([]string, 0, len(imageMirrorTarget))
-	for k := range imageMirrorTarget {
-		keys = append(keys, k)
+// signedReferences lists the promoted images by digest, as they are signed
+func signedReferences(imageMirrorTargets []map[string]string) []string {
+	references := sets.NewString()
+	for _, imageMirrorTarget := range imageMirrorTargets {
+		for src, dst := range imageMirrorTarget {
+			parts := strings.SplitN(src, "@", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			repository := dst
+			if i := strings.LastIndex(dst, ":"); i > strings.LastIndex(dst, "/") {
+				repository = dst[:i]
+			}
+			references.Insert(fmt.Sprintf("%s@%s", repository, parts[1]))
+		}
 	}
-	sort.Strings(keys)
+	return references.List()
+}
 
-	var images []string
-	for _, k := range keys {
-		images = append(images, fmt.Sprintf("%s=%s", k, imageMirrorTarget[k]))
+const (
+	// cosignImage is the upstream release of cosign
+	cosignImage = "gcr.io/projectsigstore/cosign:v2.2.4"
+	// signingDockerConfigPath is where the credentials for pushing
+	// signatures are mounted
+	signingDockerConfigPath = "/tmp/.docker"
+)
+
+// getSigningContainers signs the promoted images once they are mirrored,
+// pushing the signatures to the repositories of the images. The cosign image
+// has no shell, so every image is signed by its own container.
+func getSigningContainers(imageMirrorTargets []map[string]string, config api.ImageSigningConfiguration) ([]coreapi.Container, []coreapi.Volume) {
+	mounts := []coreapi.VolumeMount{{
+		Name:      "push-secret-config",
+		MountPath: signingDockerConfigPath,
+		ReadOnly:  true,
+	}}
+	env := []coreapi.EnvVar{{Name: "DOCKER_CONFIG", Value: signingDockerConfigPath}}
+	// cosign reads the credentials from a config.json
+	volumes := []coreapi.Volume{{
+		Name: "push-secret-config",
+		VolumeSource: coreapi.VolumeSource{
+			Secret: &coreapi.SecretVolumeSource{
+				SecretName: api.RegistryPushCredentialsCICentralSecret,
+				Items:      []coreapi.KeyToPath{{Key: coreapi.DockerConfigJsonKey, Path: "config.json"}},
+			},
+		},
+	}}
+	if config.Keyless {
+		expiration := int64(600)
+		mounts = append(mounts, coreapi.VolumeMount{
+			Name:      "sigstore-token",
+			MountPath: filepath.Dir(signing.IdentityTokenPath),
+			ReadOnly:  true,
+		})
+		volumes = append(volumes, coreapi.Volume{
+			Name: "sigstore-token",
+			VolumeSource: coreapi.VolumeSource{
+				Projected: &coreapi.ProjectedVolumeSource{
+					Sources: []coreapi.VolumeProjection{{
+						ServiceAccountToken: &coreapi.ServiceAccountTokenProjection{
+							Audience:          signing.IdentityTokenAudience,
+							ExpirationSeconds: &expiration,
+							Path:              filepath.Base(signing.IdentityTokenPath),
+						},
+					}},
+				},
+			},
+		})
+	} else {
+		optional := true
+		env = append(env, coreapi.EnvVar{
+			Name: "COSIGN_PASSWORD",
+			ValueFrom: &coreapi.EnvVarSource{
+				SecretKeyRef: &coreapi.SecretKeySelector{
+					LocalObjectReference: coreapi.LocalObjectReference{Name: api.ImageSigningKeySecret},
+					Key:                  signing.PasswordKey,
+					Optional:             &optional,
+				},
+			},
+		})
+		mounts = append(mounts, coreapi.VolumeMount{
+			Name:      "signing-key",
+			MountPath: api.ImageSigningKeySecretMountPath,
+			ReadOnly:  true,
+		})
+		volumes = append(volumes, coreapi.Volume{
+			Name: "signing-key",
+			VolumeSource: coreapi.VolumeSource{
+				Secret: &coreapi.SecretVolumeSource{SecretName: api.ImageSigningKeySecret},
+			},
+		})
+	}
+	var containers []coreapi.Container
+	for i, reference := range signedReferences(imageMirrorTargets) {
+		containers = append(containers, coreapi.Container{
+			Name:  fmt.Sprintf("signing-%d", i),
+			Image: cosignImage,
+			// the entrypoint of the image is cosign
+			Args:         signing.SignCommand(reference, config)[1:],
+			Env:          env,
+			VolumeMounts: mounts,
+		})
+	}
+	return containers, volumes
+}
+
+// getPromotionPod mirrors the images to all targets, in parallel if there
+// are more than one. When images are signed, the mirroring runs before the
+// signing.
+func getPromotionPod(imageMirrorTargets []map[string]string, namespace string, signingConfig *api.ImageSigningConfiguration) *coreapi.Pod {
+	var ocCommands []string
+	for _, imageMirrorTarget := range imageMirrorTargets {
+		keys := make([]string, 0, len(imageMirrorTarget))
+		for k := range imageMirrorTarget {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var images []string
+		for _, k := range keys {
+			images = append(images, fmt.Sprintf("%s=%s", k, imageMirrorTarget[k]))
+		}
+		command := fmt.Sprintf("retry oc image mirror --registry-config=%s --continue-on-error=true --max-per-registry=20 %s", filepath.Join(api.RegistryPushCredentialsCICentralSecretMountPath, coreapi.DockerConfigJsonKey), strings.Join(images, " "))
+		if len(imageMirrorTargets) > 1 {
+			command += " &\npids=\"$pids $!\""
+		}
+		ocCommands = append(ocCommands, command)
+	}
+	if len(imageMirrorTargets) > 1 {
+		ocCommands = append(ocCommands, `for pid in $pids; do wait "$pid"; done`)
 	}
-	ocCommands = append(ocCommands, fmt.Sprintf("retry oc image mirror --registry-config=%s --continue-on-error=true --max-per-registry=20 %s", filepath.Join(api.RegistryPushCredentialsCICentralSecretMountPath, coreapi.DockerConfigJsonKey), strings.Join(images, " ")))
 	command := []string{"/bin/sh", "-c"}
 	args := []string{"set -e\n" + bashRetryFn + "\n" + strings.Join(ocCommands, "\n")}
-	return &coreapi.Pod{
+	pod := &coreapi.Pod{
 		ObjectMeta: meta.ObjectMeta{
 			Name:      "promotion",
 			Namespace: namespace,
@@ -267,6 +496,13 @@ func getPromotionPod(imageMirrorTarget map[string]string, namespace string) *cor
 			},
 		},
 	}
+	if signingConfig != nil {
+		containers, volumes := getSigningContainers(imageMirrorTargets, *signingConfig)
+		pod.Spec.InitContainers = pod.Spec.Containers
+		pod.Spec.Containers = containers
+		pod.Spec.Volumes = append(pod.Spec.Volumes, volumes...)
+	}
+	return pod
 }
 
 const bashRetryFn = `retry() {
@@ -340,22 +576,14 @@ func PromotedTags(configuration *api.ReleaseBuildConfiguration) []api.ImageStrea
 	}
 	tags, _ := toPromote(*configuration.PromotionConfiguration, configuration.Images, sets.NewString())
 	var promotedTags []api.ImageStreamTagReference
-	for dst := range tags {
-		var tag api.ImageStreamTagReference
-		if configuration.PromotionConfiguration.Name != "" {
-			tag = api.ImageStreamTagReference{
-				Namespace: configuration.PromotionConfiguration.Namespace,
-				Name:      configuration.PromotionConfiguration.Name,
-				Tag:       dst,
-			}
-		} else { // promotion.Tag must be set
-			tag = api.ImageStreamTagReference{
-				Namespace: configuration.PromotionConfiguration.Namespace,
-				Name:      dst,
-				Tag:       configuration.PromotionConfiguration.Tag,
-			}
+	for _, target := range configuration.PromotionConfiguration.EnabledTargets() {
+		if target.Quay != nil {
+			// images pushed to Quay are not tagged into image streams
+			continue
+		}
+		for dst := range tags {
+			promotedTags = append(promotedTags, promotedTag(target, configuration.Metadata, dst))
 		}
-		promotedTags = append(promotedTags, tag)
 	}
 	return promotedTags
 }
@@ -375,7 +603,11 @@ func (s *promotionStep) Provides() api.ParameterMap {
 func (s *promotionStep) Name() string { return "[promotion]" }
 
 func (s *promotionStep) Description() string {
-	return fmt.Sprintf("Promote built images into the release image stream %s", targetName(s.config))
+	targets := s.config.EnabledTargets()
+	if len(targets) == 0 {
+		targets = []api.PromotionTarget{{Namespace: s.config.Namespace, Name: s.config.Name, Tag: s.config.Tag}}
+	}
+	return fmt.Sprintf("Promote built images into the release image stream %s", targetNames(targets))
 }
 
 func (s *promotionStep) Objects() []ctrlruntimeclient.Object {
@@ -384,13 +616,16 @@ func (s *promotionStep) Objects() []ctrlruntimeclient.Object {
 
 // PromotionStep copies tags from the pipeline image stream to the destination defined in the promotion config.
 // If the source tag does not exist it is silently skipped.
-func PromotionStep(config api.PromotionConfiguration, images []api.ProjectDirectoryImageBuildStepConfiguration, requiredImages sets.String, jobSpec *api.JobSpec, client steps.PodClient, pushSecret *coreapi.Secret) api.Step {
+func PromotionStep(config api.PromotionConfiguration, metadata api.Metadata, images []api.ProjectDirectoryImageBuildStepConfiguration, requiredImages sets.String, jobSpec *api.JobSpec, client steps.PodClient, pushSecret, signingSecret *coreapi.Secret, quayClient quay.Client) api.Step {
 	return &promotionStep{
 		config:         config,
+		metadata:       metadata,
 		images:         images,
 		requiredImages: requiredImages,
 		jobSpec:        jobSpec,
 		client:         client,
 		pushSecret:     pushSecret,
+		signingSecret:  signingSecret,
+		quay:           quayClient,
 	}
 }
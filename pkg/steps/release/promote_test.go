@@ -1,9 +1,13 @@
 package release
 
 import (
+	"context"
+	"fmt"
 	"reflect"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+
 	coreapi "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/utils/diff"
@@ -11,6 +15,7 @@ import (
 	imageapi "github.com/openshift/api/image/v1"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/quay"
 	"github.com/openshift/ci-tools/pkg/testhelper"
 )
 
@@ -186,6 +191,27 @@ func TestPromotedTags(t *testing.T) {
 				Tag:       "fred",
 			}},
 		},
+		{
+			name: "promoted image to additional targets means output tags for enabled targets",
+			input: &api.ReleaseBuildConfiguration{
+				Metadata: api.Metadata{Org: "org", Repo: "repo", Branch: "release-4.9"},
+				Images: []api.ProjectDirectoryImageBuildStepConfiguration{
+					{To: api.PipelineImageStreamTagReference("foo")},
+				},
+				PromotionConfiguration: &api.PromotionConfiguration{
+					Namespace: "roger",
+					Tag:       "fred",
+					Targets: []api.PromotionTarget{
+						{Namespace: "ocp", Name: "components", TagTemplate: "${component}-${branch}"},
+						{Namespace: "disabled", Tag: "latest", Disabled: true},
+					},
+				},
+			},
+			expected: []api.ImageStreamTagReference{
+				{Namespace: "roger", Name: "foo", Tag: "fred"},
+				{Namespace: "ocp", Name: "components", Tag: "foo-release-4.9"},
+			},
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -200,23 +226,50 @@ func TestPromotedTags(t *testing.T) {
 func TestGetPromotionPod(t *testing.T) {
 	var testCases = []struct {
 		name        string
-		imageMirror map[string]string
+		imageMirror []map[string]string
 		namespace   string
+		signing     *api.ImageSigningConfiguration
 		expected    *coreapi.Pod
 	}{
 		{
 			name: "basic case",
-			imageMirror: map[string]string{
+			imageMirror: []map[string]string{{
 				"docker-registry.default.svc:5000/ci-op-y2n8rsh3/pipeline@sha256:afd71aa3cbbf7d2e00cd8696747b2abf164700147723c657919c20b13d13ec62": "registy.ci.openshift.org/ci/applyconfig:latest",
 				"docker-registry.default.svc:5000/ci-op-y2n8rsh3/pipeline@sha256:bbb":                                                              "registy.ci.openshift.org/ci/bin:latest",
+			}},
+			namespace: "ci-op-zyvwvffx",
+		},
+		{
+			name: "multiple targets",
+			imageMirror: []map[string]string{
+				{"docker-registry.default.svc:5000/ci-op-y2n8rsh3/pipeline@sha256:bbb": "registy.ci.openshift.org/ci/bin:latest"},
+				{"docker-registry.default.svc:5000/ci-op-y2n8rsh3/pipeline@sha256:bbb": "registy.ci.openshift.org/other/components:bin-master"},
 			},
 			namespace: "ci-op-zyvwvffx",
 		},
+		{
+			name: "signing with a key",
+			imageMirror: []map[string]string{
+				{"docker-registry.default.svc:5000/ci-op-y2n8rsh3/pipeline@sha256:bbb": "registy.ci.openshift.org/ci/bin:latest"},
+				{"docker-registry.default.svc:5000/ci-op-y2n8rsh3/pipeline@sha256:bbb": "quay.io/openshift/bin:latest"},
+			},
+			namespace: "ci-op-zyvwvffx",
+			signing:   &api.ImageSigningConfiguration{},
+		},
+		{
+			name: "keyless signing",
+			imageMirror: []map[string]string{{
+				"docker-registry.default.svc:5000/ci-op-y2n8rsh3/pipeline@sha256:bbb": "registy.ci.openshift.org/ci/bin:latest",
+				"docker-registry.default.svc:5000/ci-op-y2n8rsh3/pipeline@sha256:ccc": "registy.ci.openshift.org/ci/cli:latest",
+			}},
+			namespace: "ci-op-zyvwvffx",
+			signing:   &api.ImageSigningConfiguration{Keyless: true, OIDCIssuer: "https://oidc.example.com"},
+		},
 	}
 
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			testhelper.CompareWithFixture(t, getPromotionPod(testCase.imageMirror, testCase.namespace))
+			testhelper.CompareWithFixture(t, getPromotionPod(testCase.imageMirror, testCase.namespace, testCase.signing))
 		})
 	}
 }
@@ -224,7 +277,8 @@ func TestGetPromotionPod(t *testing.T) {
 func TestGetImageMirror(t *testing.T) {
 	var testCases = []struct {
 		name     string
-		config   api.PromotionConfiguration
+		target   api.PromotionTarget
+		metadata api.Metadata
 		tags     map[string]string
 		pipeline *imageapi.ImageStream
 		expected map[string]string
@@ -238,7 +292,7 @@ func TestGetImageMirror(t *testing.T) {
 		},
 		{
 			name: "basic case: empty config.Name",
-			config: api.PromotionConfiguration{
+			target: api.PromotionTarget{
 				Namespace: "ci",
 				Tag:       "latest",
 			},
@@ -273,7 +327,7 @@ func TestGetImageMirror(t *testing.T) {
 		},
 		{
 			name: "basic case: config.Name",
-			config: api.PromotionConfiguration{
+			target: api.PromotionTarget{
 				Namespace: "ci",
 				Name:      "name",
 				Tag:       "latest",
@@ -307,11 +361,30 @@ func TestGetImageMirror(t *testing.T) {
 			},
 			expected: map[string]string{"docker-registry.default.svc:5000/ci-op-y2n8rsh3/pipeline@sha256:bbb": "registry.ci.openshift.org/ci/name:a", "docker-registry.default.svc:5000/ci-op-y2n8rsh3/pipeline@sha256:ddd": "registry.ci.openshift.org/ci/name:c"},
 		},
+		{
+			name: "tag template",
+			target: api.PromotionTarget{
+				Namespace:   "ci",
+				Name:        "name",
+				TagTemplate: "${component}-${branch}",
+			},
+			metadata: api.Metadata{Org: "org", Repo: "repo", Branch: "master"},
+			tags:     map[string]string{"a": "b"},
+			pipeline: &imageapi.ImageStream{
+				Status: imageapi.ImageStreamStatus{
+					Tags: []imageapi.NamedTagEventList{{
+						Tag:   "b",
+						Items: []imageapi.TagEvent{{DockerImageReference: "docker-registry.default.svc:5000/ci-op-y2n8rsh3/pipeline@sha256:bbb"}},
+					}},
+				},
+			},
+			expected: map[string]string{"docker-registry.default.svc:5000/ci-op-y2n8rsh3/pipeline@sha256:bbb": "registry.ci.openshift.org/ci/name:a-master"},
+		},
 	}
 
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			if actual, expected := getImageMirrorTarget(testCase.config, testCase.tags, testCase.pipeline), testCase.expected; !reflect.DeepEqual(actual, expected) {
+			if actual, expected := getImageMirrorTarget(context.Background(), testCase.target, testCase.metadata, testCase.tags, testCase.pipeline), testCase.expected; !reflect.DeepEqual(actual, expected) {
 				t.Errorf("%s: got incorrect ImageMirror mapping: %v", testCase.name, diff.ObjectDiff(actual, expected))
 			}
 		})
@@ -335,9 +408,70 @@ func TestGetPublicImageReference(t *testing.T) {
 
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			if actual, expected := getPublicImageReference(testCase.dockerImageReference, testCase.publicDockerImageRepository), testCase.expected; !reflect.DeepEqual(actual, expected) {
+			if actual, expected := getPublicImageReference(context.Background(), testCase.dockerImageReference, testCase.publicDockerImageRepository), testCase.expected; !reflect.DeepEqual(actual, expected) {
 				t.Errorf("%s: got incorrect public image reference: %v", testCase.name, diff.ObjectDiff(actual, expected))
 			}
 		})
 	}
 }
+
+type fakeQuayClient struct {
+	calls []string
+}
+
+func (f *fakeQuayClient) EnsureRepository(organization, repository string, public bool) error {
+	f.calls = append(f.calls, fmt.Sprintf("ensure %s/%s public=%t", organization, repository, public))
+	return nil
+}
+
+func (f *fakeQuayClient) SetRobotPermission(organization, repository, robot string, role quay.Role) error {
+	f.calls = append(f.calls, fmt.Sprintf("grant %s on %s/%s to %s", role, organization, repository, robot))
+	return nil
+}
+
+func TestQuayPromotion(t *testing.T) {
+	pipeline := &imageapi.ImageStream{
+		Status: imageapi.ImageStreamStatus{
+			Tags: []imageapi.NamedTagEventList{
+				{Tag: "cli", Items: []imageapi.TagEvent{{DockerImageReference: "registry.ci.openshift.org/ci-op-y2n8rsh3/pipeline@sha256:cli"}}},
+				{Tag: "operator", Items: []imageapi.TagEvent{{DockerImageReference: "registry.ci.openshift.org/ci-op-y2n8rsh3/pipeline@sha256:operator"}}},
+			},
+		},
+	}
+	tags := map[string]string{"cli": "cli", "operator": "operator", "missing": "missing"}
+	targets := []api.PromotionTarget{
+		{Namespace: "ci", Tag: "latest"},
+		{Namespace: "openshift", Name: "components", TagTemplate: "${component}-${branch}", Quay: &api.QuayPromotion{
+			RobotPermissions: map[string]string{"mirror": "read", "ci": "write"},
+		}},
+	}
+	metadata := api.Metadata{Branch: "main"}
+	client := &fakeQuayClient{}
+	step := &promotionStep{metadata: metadata, quay: client}
+	if err := step.ensureQuayRepositories(targets, tags, pipeline); err != nil {
+		t.Fatalf("failed to ensure repositories: %v", err)
+	}
+	if diff := cmp.Diff([]string{
+		"ensure openshift/components public=false",
+		"grant write on openshift/components to ci",
+		"grant read on openshift/components to mirror",
+	}, client.calls); diff != "" {
+		t.Errorf("unexpected calls to Quay: %s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{
+		"registry.ci.openshift.org/ci-op-y2n8rsh3/pipeline@sha256:cli":      "quay.io/openshift/components:cli-main",
+		"registry.ci.openshift.org/ci-op-y2n8rsh3/pipeline@sha256:operator": "quay.io/openshift/components:operator-main",
+	}, getImageMirrorTarget(context.Background(), targets[1], metadata, tags, pipeline)); diff != "" {
+		t.Errorf("unexpected mirror targets: %s", diff)
+	}
+	if diff := cmp.Diff([]string{
+		"quay.io/openshift/components:cli-main (quay.io/openshift/components@sha256:cli)",
+		"quay.io/openshift/components:operator-main (quay.io/openshift/components@sha256:operator)",
+	}, quayPushedImages(targets, metadata, tags, pipeline)); diff != "" {
+		t.Errorf("unexpected pushed images: %s", diff)
+	}
+	step.quay = nil
+	if err := step.ensureQuayRepositories(targets, tags, pipeline); err == nil {
+		t.Error("expected an error without a Quay client")
+	}
+}
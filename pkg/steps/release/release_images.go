@@ -3,17 +3,19 @@ package release
 import (
 	"context"
 	"fmt"
-	"log"
 	"strings"
 
+	coreapi "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	imagev1 "github.com/openshift/api/image/v1"
 
 	"github.com/openshift/ci-tools/pkg/api"
 	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/steps"
 	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
 	"github.com/openshift/ci-tools/pkg/steps/utils"
 	"github.com/openshift/ci-tools/pkg/util"
@@ -35,11 +37,11 @@ func StableImagesTagStep(client loggingclient.LoggingClient, jobSpec *api.JobSpe
 }
 
 func (s *stableImagesTagStep) Run(ctx context.Context) error {
-	return results.ForReason("creating_stable_images").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonCreatingStableImages).ForError(s.run(ctx))
 }
 
 func (s *stableImagesTagStep) run(ctx context.Context) error {
-	log.Printf("Will output images to %s:%s", api.StableImageStream, api.ComponentFormatReplacement)
+	steps.Logger(ctx).Infof("Will output images to %s:%s", api.StableImageStream, api.ComponentFormatReplacement)
 
 	newIS := &imagev1.ImageStream{
 		ObjectMeta: meta.ObjectMeta{
@@ -92,6 +94,9 @@ type releaseImagesTagStep struct {
 	client  loggingclient.LoggingClient
 	params  *api.DeferredParameters
 	jobSpec *api.JobSpec
+	// snapshot pins the tags to the images they resolved to when the
+	// job started, if set
+	snapshot *TagSnapshot
 }
 
 func (s *releaseImagesTagStep) Inputs() (api.InputDefinition, error) {
@@ -105,14 +110,14 @@ func sourceName(config api.ReleaseTagConfiguration) string {
 }
 
 func (s *releaseImagesTagStep) Run(ctx context.Context) error {
-	return results.ForReason("creating_release_images").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonCreatingReleaseImages).ForError(s.run(ctx))
 }
 
 func (s *releaseImagesTagStep) run(ctx context.Context) error {
 	if format, err := s.imageFormat(); err == nil {
-		log.Printf("Tagged shared images from %s, images will be pullable from %s", sourceName(s.config), format)
+		steps.Logger(ctx).Infof("Tagged shared images from %s, images will be pullable from %s", sourceName(s.config), format)
 	} else {
-		log.Printf("Tagged shared images from %s", sourceName(s.config))
+		steps.Logger(ctx).Infof("Tagged shared images from %s", sourceName(s.config))
 	}
 
 	is := &imagev1.ImageStream{}
@@ -136,10 +141,11 @@ func (s *releaseImagesTagStep) run(ctx context.Context) error {
 	if raw, ok := is.ObjectMeta.Annotations[releaseConfigAnnotation]; ok {
 		newIS.ObjectMeta.Annotations[releaseConfigAnnotation] = raw
 	}
-	for _, tag := range is.Status.Tags {
-		if valid, _ := utils.FindStatusTag(is, tag.Tag); valid != nil {
+	pinned := s.pinnedImages()
+	for _, tag := range tagsFor(is, pinned) {
+		if valid := findTag(is, tag, pinned); valid != nil {
 			newIS.Spec.Tags = append(newIS.Spec.Tags, imagev1.TagReference{
-				Name:            tag.Tag,
+				Name:            tag,
 				From:            valid,
 				ReferencePolicy: imagev1.TagReferencePolicy{Type: imagev1.LocalTagReferencePolicy},
 			})
@@ -158,7 +164,13 @@ func (s *releaseImagesTagStep) run(ctx context.Context) error {
 	}
 
 	for _, tag := range is.Spec.Tags {
-		spec, ok := util.ResolvePullSpec(is, tag.Name, false)
+		if importedAfterSnapshot(is, tag.Name, pinned) {
+			continue
+		}
+		spec, ok := pinnedPullSpec(is, tag.Name, pinned)
+		if !ok {
+			spec, ok = util.ResolvePullSpec(is, tag.Name, false)
+		}
 		if !ok {
 			continue
 		}
@@ -168,7 +180,80 @@ func (s *releaseImagesTagStep) run(ctx context.Context) error {
 	return nil
 }
 
+// pinnedImages returns the snapshot of the tags, which is nil when the tags
+// are not pinned
+func (s *releaseImagesTagStep) pinnedImages() map[string]string {
+	if s.snapshot == nil {
+		return nil
+	}
+	return s.snapshot.Images()
+}
+
+// tagsFor lists the tags of the stream, followed by the pinned tags which
+// were removed from it since the snapshot was taken
+func tagsFor(is *imagev1.ImageStream, pinned map[string]string) []string {
+	var tags []string
+	seen := sets.NewString()
+	for _, tag := range is.Status.Tags {
+		tags = append(tags, tag.Tag)
+		seen.Insert(tag.Tag)
+	}
+	for _, tag := range sets.StringKeySet(pinned).List() {
+		if !seen.Has(tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// importedAfterSnapshot determines whether the tag was imported after the
+// snapshot was taken, in which case the job does not use it
+func importedAfterSnapshot(is *imagev1.ImageStream, tag string, pinned map[string]string) bool {
+	if pinned == nil {
+		return false
+	}
+	if _, ok := pinned[tag]; ok {
+		return false
+	}
+	_, image := utils.FindStatusTag(is, tag)
+	return image != ""
+}
+
+// findTag resolves the tag to the image it was pinned to, or to its current
+// image when it was not
+func findTag(is *imagev1.ImageStream, tag string, pinned map[string]string) *coreapi.ObjectReference {
+	if importedAfterSnapshot(is, tag, pinned) {
+		return nil
+	}
+	if image, ok := pinned[tag]; ok {
+		return &coreapi.ObjectReference{
+			Kind:      "ImageStreamImage",
+			Namespace: is.Namespace,
+			Name:      fmt.Sprintf("%s@%s", is.Name, image),
+		}
+	}
+	valid, _ := utils.FindStatusTag(is, tag)
+	return valid
+}
+
+// pinnedPullSpec returns the pull spec of the image the tag was pinned to
+func pinnedPullSpec(is *imagev1.ImageStream, tag string, pinned map[string]string) (string, bool) {
+	image, ok := pinned[tag]
+	if !ok {
+		return "", false
+	}
+	for _, repository := range []string{is.Status.PublicDockerImageRepository, is.Status.DockerImageRepository} {
+		if repository != "" {
+			return fmt.Sprintf("%s@%s", repository, image), true
+		}
+	}
+	return "", false
+}
+
 func (s *releaseImagesTagStep) Requires() []api.StepLink {
+	if s.snapshot != nil {
+		return []api.StepLink{api.TagSpecificationSnapshotLink()}
+	}
 	return []api.StepLink{}
 }
 
@@ -219,11 +304,12 @@ func (s *releaseImagesTagStep) Objects() []ctrlruntimeclient.Object {
 	return s.client.Objects()
 }
 
-func ReleaseImagesTagStep(config api.ReleaseTagConfiguration, client loggingclient.LoggingClient, params *api.DeferredParameters, jobSpec *api.JobSpec) api.Step {
+func ReleaseImagesTagStep(config api.ReleaseTagConfiguration, client loggingclient.LoggingClient, params *api.DeferredParameters, jobSpec *api.JobSpec, snapshot *TagSnapshot) api.Step {
 	return &releaseImagesTagStep{
-		config:  config,
-		client:  client,
-		params:  params,
-		jobSpec: jobSpec,
+		config:   config,
+		client:   client,
+		params:   params,
+		jobSpec:  jobSpec,
+		snapshot: snapshot,
 	}
 }
@@ -0,0 +1,168 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/steps"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+// TagSpecificationSnapshotConfigMap holds the snapshot in the namespace of the
+// job, so later executions in the namespace use the same images
+const TagSpecificationSnapshotConfigMap = "tag-specification-snapshot"
+
+// TagSnapshot maps the tags of the tag specification to the images they
+// resolved to when the job started. The zero value holds no snapshot.
+type TagSnapshot struct {
+	lock   sync.Mutex
+	images map[string]string
+}
+
+func (s *TagSnapshot) set(images map[string]string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.images = images
+}
+
+// Images returns a copy of the snapshot
+func (s *TagSnapshot) Images() map[string]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.images == nil {
+		return nil
+	}
+	images := make(map[string]string, len(s.images))
+	for tag, image := range s.images {
+		images[tag] = image
+	}
+	return images
+}
+
+// tagSpecificationSnapshotStep resolves the tags of the tag specification
+// to digests when the job starts. The stable streams of the job are created
+// from the snapshot, so that tags updated in the tag specification while the
+// job runs do not end up in its payloads.
+type tagSpecificationSnapshotStep struct {
+	config   api.ReleaseTagConfiguration
+	client   loggingclient.LoggingClient
+	jobSpec  *api.JobSpec
+	snapshot *TagSnapshot
+	// pinned holds the snapshot recorded in an inputs lock
+	pinned map[string]string
+}
+
+func (s *tagSpecificationSnapshotStep) Inputs() (api.InputDefinition, error) {
+	return nil, nil
+}
+
+func (*tagSpecificationSnapshotStep) Validate() error { return nil }
+
+func (s *tagSpecificationSnapshotStep) Run(ctx context.Context) error {
+	return results.ForReason(results.ReasonCreatingReleaseImages).ForError(s.run(ctx))
+}
+
+func (s *tagSpecificationSnapshotStep) run(ctx context.Context) error {
+	existing := &coreapi.ConfigMap{}
+	err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: TagSpecificationSnapshotConfigMap}, existing)
+	switch {
+	case err == nil:
+		steps.Logger(ctx).Infof("Using the snapshot of %d tags of %s/%s taken by an earlier execution", len(existing.Data), s.config.Namespace, s.config.Name)
+		s.snapshot.set(existing.Data)
+		return nil
+	case !kerrors.IsNotFound(err):
+		return fmt.Errorf("could not get the tag specification snapshot: %w", err)
+	}
+
+	images := s.pinned
+	if images == nil {
+		if images, err = s.resolve(ctx); err != nil {
+			return err
+		}
+	}
+	snapshot := &coreapi.ConfigMap{
+		ObjectMeta: meta.ObjectMeta{Namespace: s.jobSpec.Namespace(), Name: TagSpecificationSnapshotConfigMap},
+		Data:       images,
+	}
+	if err := s.client.Create(ctx, snapshot); err != nil {
+		if !kerrors.IsAlreadyExists(err) {
+			return fmt.Errorf("could not store the tag specification snapshot: %w", err)
+		}
+		// a concurrent execution took the snapshot first
+		if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: TagSpecificationSnapshotConfigMap}, snapshot); err != nil {
+			return fmt.Errorf("could not get the tag specification snapshot: %w", err)
+		}
+	}
+	steps.Logger(ctx).Infof("Pinned %d tags of %s/%s to the images they resolve to", len(snapshot.Data), s.config.Namespace, s.config.Name)
+	s.snapshot.set(snapshot.Data)
+	return nil
+}
+
+// resolve records the images the imported tags of the tag specification
+// point to; tags which were not imported cannot be pinned
+func (s *tagSpecificationSnapshotStep) resolve(ctx context.Context) (map[string]string, error) {
+	is := &imagev1.ImageStream{}
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.config.Namespace, Name: s.config.Name}, is); err != nil {
+		return nil, fmt.Errorf("could not resolve stable imagestream: %w", err)
+	}
+	images := map[string]string{}
+	for _, tag := range is.Status.Tags {
+		if len(tag.Items) > 0 && tag.Items[0].Image != "" {
+			images[tag.Tag] = tag.Items[0].Image
+		}
+	}
+	return images, nil
+}
+
+func (s *tagSpecificationSnapshotStep) LockInputs(lock *steps.InputsLock) {
+	if images := s.snapshot.Images(); images != nil {
+		lock.StableTags = images
+	}
+}
+
+func (s *tagSpecificationSnapshotStep) PinInputs(lock *steps.InputsLock) {
+	if lock.StableTags != nil {
+		s.pinned = lock.StableTags
+	}
+}
+
+func (s *tagSpecificationSnapshotStep) Requires() []api.StepLink {
+	return []api.StepLink{}
+}
+
+func (s *tagSpecificationSnapshotStep) Creates() []api.StepLink {
+	return []api.StepLink{api.TagSpecificationSnapshotLink()}
+}
+
+func (s *tagSpecificationSnapshotStep) Provides() api.ParameterMap { return nil }
+
+func (s *tagSpecificationSnapshotStep) Name() string { return "[tag-specification-snapshot]" }
+
+func (s *tagSpecificationSnapshotStep) Description() string {
+	return fmt.Sprintf("Pin the tags of %s/%s to the images they resolve to when the job starts", s.config.Namespace, s.config.Name)
+}
+
+func (s *tagSpecificationSnapshotStep) Objects() []ctrlruntimeclient.Object {
+	return s.client.Objects()
+}
+
+// TagSpecificationSnapshotStep takes the snapshot the release inputs step is
+// created with
+func TagSpecificationSnapshotStep(config api.ReleaseTagConfiguration, client loggingclient.LoggingClient, jobSpec *api.JobSpec, snapshot *TagSnapshot) api.Step {
+	return &tagSpecificationSnapshotStep{
+		config:   config,
+		client:   client,
+		jobSpec:  jobSpec,
+		snapshot: snapshot,
+	}
+}
@@ -0,0 +1,148 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+	"github.com/openshift/ci-tools/pkg/steps/utils"
+)
+
+func init() {
+	if err := imagev1.AddToScheme(scheme.Scheme); err != nil {
+		panic(fmt.Sprintf("failed to add imagev1 to scheme: %v", err))
+	}
+}
+
+func stableStream(tags map[string]string) *imagev1.ImageStream {
+	is := &imagev1.ImageStream{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ocp", Name: "4.9"},
+		Status:     imagev1.ImageStreamStatus{PublicDockerImageRepository: "registry/ocp/4.9"},
+	}
+	for _, tag := range []string{"cli", "installer", "tests", "unimported"} {
+		image, ok := tags[tag]
+		if !ok {
+			continue
+		}
+		is.Spec.Tags = append(is.Spec.Tags, imagev1.TagReference{Name: tag})
+		is.Status.Tags = append(is.Status.Tags, imagev1.NamedTagEventList{Tag: tag, Items: []imagev1.TagEvent{{Image: image, DockerImageReference: "quay.io/ocp/" + tag}}})
+	}
+	return is
+}
+
+func TestTagSpecificationSnapshotStep(t *testing.T) {
+	config := api.ReleaseTagConfiguration{Namespace: "ocp", Name: "4.9"}
+	var testCases = []struct {
+		name     string
+		objects  []runtime.Object
+		pinned   *steps.InputsLock
+		expected map[string]string
+	}{
+		{
+			name:     "imported tags are resolved",
+			objects:  []runtime.Object{stableStream(map[string]string{"cli": "sha256:cli", "installer": "sha256:installer", "unimported": ""})},
+			expected: map[string]string{"cli": "sha256:cli", "installer": "sha256:installer"},
+		},
+		{
+			name: "the snapshot of an earlier execution is used",
+			objects: []runtime.Object{
+				stableStream(map[string]string{"cli": "sha256:cli"}),
+				&coreapi.ConfigMap{ObjectMeta: meta.ObjectMeta{Namespace: "ns", Name: TagSpecificationSnapshotConfigMap}, Data: map[string]string{"cli": "sha256:old"}},
+			},
+			expected: map[string]string{"cli": "sha256:old"},
+		},
+		{
+			name:     "the snapshot of an inputs lock is used",
+			objects:  []runtime.Object{stableStream(map[string]string{"cli": "sha256:cli"})},
+			pinned:   &steps.InputsLock{StableTags: map[string]string{"cli": "sha256:locked"}},
+			expected: map[string]string{"cli": "sha256:locked"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			jobSpec := &api.JobSpec{}
+			jobSpec.SetNamespace("ns")
+			client := loggingclient.New(fakectrlruntimeclient.NewFakeClient(testCase.objects...))
+			snapshot := &TagSnapshot{}
+			step := TagSpecificationSnapshotStep(config, client, jobSpec, snapshot)
+			if testCase.pinned != nil {
+				testCase.pinned.PinSteps([]api.Step{step})
+			}
+			if err := step.Run(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testCase.expected, snapshot.Images()); diff != "" {
+				t.Errorf("unexpected snapshot: %s", diff)
+			}
+			stored := &coreapi.ConfigMap{}
+			if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: TagSpecificationSnapshotConfigMap}, stored); err != nil {
+				t.Fatalf("failed to get the stored snapshot: %v", err)
+			}
+			if diff := cmp.Diff(testCase.expected, stored.Data); diff != "" {
+				t.Errorf("unexpected stored snapshot: %s", diff)
+			}
+			lock := &steps.InputsLock{}
+			lock.LockSteps([]api.Step{step})
+			if diff := cmp.Diff(testCase.expected, lock.StableTags); diff != "" {
+				t.Errorf("unexpected locked snapshot: %s", diff)
+			}
+		})
+	}
+}
+
+func TestReleaseImagesTagStepUsesSnapshot(t *testing.T) {
+	config := api.ReleaseTagConfiguration{Namespace: "ocp", Name: "4.9"}
+	// the stream changed since the snapshot was taken: cli was updated,
+	// installer was removed and tests was added
+	client := loggingclient.New(fakectrlruntimeclient.NewFakeClient(stableStream(map[string]string{"cli": "sha256:new", "tests": "sha256:tests", "unimported": ""})))
+	snapshot := &TagSnapshot{}
+	snapshot.set(map[string]string{"cli": "sha256:cli", "installer": "sha256:installer"})
+	jobSpec := &api.JobSpec{}
+	jobSpec.SetNamespace("ns")
+	params := api.NewDeferredParameters(nil)
+	step := ReleaseImagesTagStep(config, client, params, jobSpec, snapshot)
+	if diff := cmp.Diff([]api.StepLink{api.TagSpecificationSnapshotLink()}, step.Requires()); diff != "" {
+		t.Errorf("unexpected requirements: %s", diff)
+	}
+	if err := step.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stable := &imagev1.ImageStream{}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: api.StableImageStream}, stable); err != nil {
+		t.Fatalf("failed to get the stable stream: %v", err)
+	}
+	expected := []imagev1.TagReference{
+		{Name: "cli", From: &coreapi.ObjectReference{Kind: "ImageStreamImage", Namespace: "ocp", Name: "4.9@sha256:cli"}, ReferencePolicy: imagev1.TagReferencePolicy{Type: imagev1.LocalTagReferencePolicy}},
+		{Name: "unimported", From: &coreapi.ObjectReference{Kind: "DockerImage", Name: "quay.io/ocp/unimported"}, ReferencePolicy: imagev1.TagReferencePolicy{Type: imagev1.LocalTagReferencePolicy}},
+		{Name: "installer", From: &coreapi.ObjectReference{Kind: "ImageStreamImage", Namespace: "ocp", Name: "4.9@sha256:installer"}, ReferencePolicy: imagev1.TagReferencePolicy{Type: imagev1.LocalTagReferencePolicy}},
+	}
+	if diff := cmp.Diff(expected, stable.Spec.Tags); diff != "" {
+		t.Errorf("unexpected stable tags: %s", diff)
+	}
+
+	// tests was imported after the snapshot and is not used by the job
+	for tag, expected := range map[string]string{"cli": "registry/ocp/4.9@sha256:cli", "tests": ""} {
+		actual, err := params.Get(utils.StableImageEnv(tag))
+		if err != nil {
+			t.Fatalf("failed to get the pull spec of %s: %v", tag, err)
+		}
+		if actual != expected {
+			t.Errorf("expected %s to be pulled from %q, got %q", tag, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/client-go/util/retry"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// APIClientMetrics record the latency and errors of the requests the steps
+// of one job make to the API server, apart from those of other jobs executed
+// by the same process
+type APIClientMetrics struct {
+	requestDuration *prometheus.HistogramVec
+	requestErrors   *prometheus.CounterVec
+	requestRetries  *prometheus.CounterVec
+}
+
+// NewAPIClientMetrics returns metrics without any recorded requests
+func NewAPIClientMetrics() *APIClientMetrics {
+	return &APIClientMetrics{
+		requestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "ci_operator_api_request_duration_seconds",
+				Help:    "latency of the requests steps make to the API server by verb and kind, including retries",
+				Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+			},
+			[]string{"verb", "kind"},
+		),
+		requestErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ci_operator_api_request_errors_total",
+				Help: "failed requests steps made to the API server by verb, kind and HTTP status code",
+			},
+			[]string{"verb", "kind", "code"},
+		),
+		requestRetries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ci_operator_api_request_retries_total",
+				Help: "requests to the API server retried after transient errors by verb and kind",
+			},
+			[]string{"verb", "kind"},
+		),
+	}
+}
+
+// DefaultAPIBackoff is used to retry requests which failed with transient
+// errors, e.g. when the API server is throttling or restarting
+var DefaultAPIBackoff = wait.Backoff{Duration: 500 * time.Millisecond, Factor: 2, Jitter: 0.1, Steps: 5}
+
+// WithRateLimiter returns a copy of the configuration whose clients share
+// one client-side rate limiter, so that all clients of a job together do not
+// exceed the rate instead of each client on its own
+func WithRateLimiter(config *rest.Config, qps float32, burst int) *rest.Config {
+	limited := rest.CopyConfig(config)
+	limited.QPS, limited.Burst = qps, burst
+	limited.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+	return limited
+}
+
+// NewResilientClient returns a client that retries reads which failed with
+// transient errors and writes which the API server did not process, and
+// records the latency and errors of requests in the metrics
+func NewResilientClient(upstream ctrlruntimeclient.Client, backoff wait.Backoff, metrics *APIClientMetrics) ctrlruntimeclient.Client {
+	return &resilientClient{Client: upstream, backoff: backoff, metrics: metrics}
+}
+
+type resilientClient struct {
+	ctrlruntimeclient.Client
+	backoff wait.Backoff
+	metrics *APIClientMetrics
+}
+
+// isTransientAPIError determines whether a request may succeed when it is
+// made again
+func isTransientAPIError(err error) bool {
+	return kerrors.IsServerTimeout(err) ||
+		kerrors.IsTimeout(err) ||
+		kerrors.IsTooManyRequests(err) ||
+		kerrors.IsServiceUnavailable(err) ||
+		kerrors.IsInternalError(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsConnectionRefused(err) ||
+		utilnet.IsProbableEOF(err) ||
+		utilnet.IsTimeout(err)
+}
+
+// isUnprocessedAPIError determines whether the API server rejected a request
+// before processing it. Only such writes are retried, as other transient
+// errors, e.g. timeouts, leave it open whether the write happened and a retry
+// could fail with a conflict or apply the change twice.
+func isUnprocessedAPIError(err error) bool {
+	return kerrors.IsTooManyRequests(err) || utilnet.IsConnectionRefused(err)
+}
+
+// isExpectedAPIError determines whether the error is a routine outcome of a
+// request which callers handle, e.g. looking up an object which does not
+// exist, rather than a failure of the request
+func isExpectedAPIError(verb string, err error) bool {
+	switch verb {
+	case "get", "delete":
+		return kerrors.IsNotFound(err)
+	case "create":
+		return kerrors.IsAlreadyExists(err)
+	}
+	return false
+}
+
+// errorCode is the HTTP status code of the error, or "network" when the
+// request did not get a response
+func errorCode(err error) string {
+	var status kerrors.APIStatus
+	if errors.As(err, &status) {
+		return strconv.Itoa(int(status.Status().Code))
+	}
+	return "network"
+}
+
+// do makes the request, retrying it after transient errors if it is a read
+// and only if it was not processed if it is a write
+func (c *resilientClient) do(ctx context.Context, verb string, obj interface{}, request func() error) error {
+	kind := "unknown"
+	if object, ok := obj.(ctrlruntimeclient.Object); ok {
+		if gvk, err := apiutil.GVKForObject(object, c.Scheme()); err == nil {
+			kind = gvk.Kind
+		}
+	} else if list, ok := obj.(ctrlruntimeclient.ObjectList); ok {
+		if gvk, err := apiutil.GVKForObject(list, c.Scheme()); err == nil {
+			kind = gvk.Kind
+		}
+	}
+	retriable := isTransientAPIError
+	if verb != "get" && verb != "list" {
+		retriable = isUnprocessedAPIError
+	}
+	start := time.Now()
+	attempt := 0
+	err := retry.OnError(c.backoff, func(err error) bool {
+		if ctx.Err() != nil || !retriable(err) {
+			return false
+		}
+		c.metrics.requestRetries.WithLabelValues(verb, kind).Inc()
+		Logger(ctx).WithError(err).Debugf("Retrying %s of %s after a transient error.", verb, kind)
+		return true
+	}, func() error {
+		attempt++
+		err := request()
+		if err != nil && !isExpectedAPIError(verb, err) {
+			c.metrics.requestErrors.WithLabelValues(verb, kind, errorCode(err)).Inc()
+		}
+		return err
+	})
+	c.metrics.requestDuration.WithLabelValues(verb, kind).Observe(time.Since(start).Seconds())
+	if err != nil && attempt > 1 && retriable(err) {
+		return fmt.Errorf("%s of %s failed after %d attempts: %w", verb, kind, attempt, err)
+	}
+	return err
+}
+
+func (c *resilientClient) Get(ctx context.Context, key ctrlruntimeclient.ObjectKey, obj ctrlruntimeclient.Object) error {
+	return c.do(ctx, "get", obj, func() error { return c.Client.Get(ctx, key, obj) })
+}
+
+func (c *resilientClient) List(ctx context.Context, list ctrlruntimeclient.ObjectList, opts ...ctrlruntimeclient.ListOption) error {
+	return c.do(ctx, "list", list, func() error { return c.Client.List(ctx, list, opts...) })
+}
+
+func (c *resilientClient) Create(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.CreateOption) error {
+	return c.do(ctx, "create", obj, func() error { return c.Client.Create(ctx, obj, opts...) })
+}
+
+func (c *resilientClient) Delete(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.DeleteOption) error {
+	return c.do(ctx, "delete", obj, func() error { return c.Client.Delete(ctx, obj, opts...) })
+}
+
+func (c *resilientClient) Update(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.UpdateOption) error {
+	return c.do(ctx, "update", obj, func() error { return c.Client.Update(ctx, obj, opts...) })
+}
+
+func (c *resilientClient) Patch(ctx context.Context, obj ctrlruntimeclient.Object, patch ctrlruntimeclient.Patch, opts ...ctrlruntimeclient.PatchOption) error {
+	return c.do(ctx, "patch", obj, func() error { return c.Client.Patch(ctx, obj, patch, opts...) })
+}
+
+func (c *resilientClient) DeleteAllOf(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.DeleteAllOfOption) error {
+	return c.do(ctx, "deletecollection", obj, func() error { return c.Client.DeleteAllOf(ctx, obj, opts...) })
+}
+
+// Write writes the metrics of the requests steps made to the API server in
+// the Prometheus text format
+func (m *APIClientMetrics) Write(out io.Writer) error {
+	registry := prometheus.NewRegistry()
+	for _, collector := range []prometheus.Collector{m.requestDuration, m.requestErrors, m.requestRetries} {
+		if err := registry.Register(collector); err != nil {
+			return err
+		}
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return fmt.Errorf("could not gather API client metrics: %w", err)
+	}
+	encoder := expfmt.NewEncoder(out, expfmt.FmtText)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return fmt.Errorf("could not write API client metrics: %w", err)
+		}
+	}
+	return nil
+}
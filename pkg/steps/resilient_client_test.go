@@ -0,0 +1,161 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// flakyClient fails the first requests with the given error
+type flakyClient struct {
+	ctrlruntimeclient.Client
+	failures int
+	err      error
+	calls    int
+}
+
+func (c *flakyClient) fail() error {
+	c.calls++
+	if c.calls <= c.failures {
+		return c.err
+	}
+	return nil
+}
+
+func (c *flakyClient) Get(ctx context.Context, key ctrlruntimeclient.ObjectKey, obj ctrlruntimeclient.Object) error {
+	if err := c.fail(); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *flakyClient) Create(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.CreateOption) error {
+	if err := c.fail(); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestResilientClient(t *testing.T) {
+	resource := schema.GroupResource{Resource: "pods"}
+	for _, tc := range []struct {
+		name          string
+		read          bool
+		failures      int
+		err           error
+		expectedCalls int
+		expectedErr   bool
+	}{{
+		name:          "request succeeds",
+		expectedCalls: 1,
+	}, {
+		name:          "throttled write is retried",
+		failures:      2,
+		err:           kerrors.NewTooManyRequests("slow down", 1),
+		expectedCalls: 3,
+	}, {
+		name:          "refused write is retried",
+		failures:      1,
+		err:           &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+		expectedCalls: 2,
+	}, {
+		name:          "write which may have been processed is not retried",
+		failures:      1,
+		err:           kerrors.NewServerTimeout(resource, "create", 1),
+		expectedCalls: 1,
+		expectedErr:   true,
+	}, {
+		name:          "read from an unavailable server is retried",
+		read:          true,
+		failures:      1,
+		err:           kerrors.NewServiceUnavailable("restarting"),
+		expectedCalls: 2,
+	}, {
+		name:          "persistent transient errors fail after the backoff",
+		read:          true,
+		failures:      10,
+		err:           kerrors.NewServerTimeout(resource, "get", 1),
+		expectedCalls: 3,
+		expectedErr:   true,
+	}, {
+		name:          "other errors are not retried",
+		failures:      1,
+		err:           kerrors.NewForbidden(resource, "pod", errors.New("no")),
+		expectedCalls: 1,
+		expectedErr:   true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := &coreapi.Pod{ObjectMeta: meta.ObjectMeta{Namespace: "ns", Name: "pod"}}
+			upstream := &flakyClient{Client: fakectrlruntimeclient.NewFakeClient(pod.DeepCopy()), failures: tc.failures, err: tc.err}
+			client := NewResilientClient(upstream, wait.Backoff{Steps: 3}, NewAPIClientMetrics())
+			var err error
+			if tc.read {
+				err = client.Get(context.Background(), ctrlruntimeclient.ObjectKeyFromObject(pod), pod)
+			} else {
+				pod.Name = "new"
+				err = client.Create(context.Background(), pod)
+			}
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("expected error: %t, got %v", tc.expectedErr, err)
+			}
+			if upstream.calls != tc.expectedCalls {
+				t.Errorf("expected %d calls, got %d", tc.expectedCalls, upstream.calls)
+			}
+		})
+	}
+}
+
+func TestResilientClientErrorMetrics(t *testing.T) {
+	metrics := NewAPIClientMetrics()
+	client := NewResilientClient(fakectrlruntimeclient.NewFakeClient(), wait.Backoff{Steps: 1}, metrics)
+	pod := &coreapi.Pod{ObjectMeta: meta.ObjectMeta{Namespace: "ns", Name: "pod"}}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKeyFromObject(pod), pod); !kerrors.IsNotFound(err) {
+		t.Fatalf("expected the pod not to be found, got %v", err)
+	}
+	if err := client.Update(context.Background(), pod); !kerrors.IsNotFound(err) {
+		t.Fatalf("expected the pod not to be found, got %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := metrics.Write(buf); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	if strings.Contains(buf.String(), `ci_operator_api_request_errors_total{code="404",kind="Pod",verb="get"}`) {
+		t.Errorf("expected the missing pod not to be recorded as an error of get, got metrics:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `ci_operator_api_request_errors_total{code="404",kind="Pod",verb="update"} 1`) {
+		t.Errorf("expected the failed update to be recorded as an error, got metrics:\n%s", buf.String())
+	}
+}
+
+func TestAPIClientMetricsArePerJob(t *testing.T) {
+	first, second := NewAPIClientMetrics(), NewAPIClientMetrics()
+	client := NewResilientClient(fakectrlruntimeclient.NewFakeClient(), wait.Backoff{Steps: 1}, first)
+	if err := client.Create(context.Background(), &coreapi.Pod{ObjectMeta: meta.ObjectMeta{Namespace: "ns", Name: "pod"}}); err != nil {
+		t.Fatalf("failed to create pod: %v", err)
+	}
+	for _, tc := range []struct {
+		metrics  *APIClientMetrics
+		expected bool
+	}{{metrics: first, expected: true}, {metrics: second}} {
+		buf := &bytes.Buffer{}
+		if err := tc.metrics.Write(buf); err != nil {
+			t.Fatalf("failed to write metrics: %v", err)
+		}
+		if recorded := strings.Contains(buf.String(), `ci_operator_api_request_duration_seconds_count{kind="Pod",verb="create"} 1`); recorded != tc.expected {
+			t.Errorf("expected the request to be recorded: %t, got metrics:\n%s", tc.expected, buf.String())
+		}
+	}
+}
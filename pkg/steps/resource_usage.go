@@ -0,0 +1,199 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+const (
+	// ResourceUsageArtifact is the name of the artifact recording the resource
+	// usage of the pods of a multi-stage test
+	ResourceUsageArtifact = "resource-usage.json"
+	// resourceUsageHeadroom is the factor applied to the observed peak when
+	// suggesting new requests
+	resourceUsageHeadroom = 1.2
+)
+
+// Allow tests to accelerate sampling
+var resourceUsageInterval = 30 * time.Second
+
+// PodMetricsClient reads the current resource usage of the containers of a
+// pod, as reported by metrics-server
+type PodMetricsClient interface {
+	PodMetrics(ctx context.Context, namespace, name string) (map[string]coreapi.ResourceList, error)
+}
+
+// podMetrics is the subset of metrics.k8s.io/v1beta1 PodMetrics we read
+type podMetrics struct {
+	Containers []struct {
+		Name  string               `json:"name"`
+		Usage coreapi.ResourceList `json:"usage"`
+	} `json:"containers"`
+}
+
+func (c podClient) PodMetrics(ctx context.Context, namespace, name string) (map[string]coreapi.ResourceList, error) {
+	raw, err := c.client.Get().AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", namespace, "pods", name).Do(ctx).Raw()
+	if err != nil {
+		return nil, fmt.Errorf("could not get metrics for pod %s/%s: %w", namespace, name, err)
+	}
+	var metrics podMetrics
+	if err := json.Unmarshal(raw, &metrics); err != nil {
+		return nil, fmt.Errorf("could not parse metrics for pod %s/%s: %w", namespace, name, err)
+	}
+	usage := map[string]coreapi.ResourceList{}
+	for _, container := range metrics.Containers {
+		usage[container.Name] = container.Usage
+	}
+	return usage, nil
+}
+
+// resourceUsageCollector samples the usage of a pod while it runs and keeps
+// the peak value of every resource per container
+type resourceUsageCollector struct {
+	client          PodMetricsClient
+	namespace, name string
+
+	lock  sync.Mutex
+	peaks map[string]coreapi.ResourceList
+}
+
+func newResourceUsageCollector(client PodMetricsClient, namespace, name string) *resourceUsageCollector {
+	return &resourceUsageCollector{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+		peaks:     map[string]coreapi.ResourceList{},
+	}
+}
+
+// run samples until the context is cancelled
+func (c *resourceUsageCollector) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.sample(ctx)
+		}
+	}
+}
+
+func (c *resourceUsageCollector) sample(ctx context.Context) {
+	usage, err := c.client.PodMetrics(ctx, c.namespace, c.name)
+	if err != nil {
+		// metrics are not reported until the pod runs and metrics-server may
+		// not be installed at all, neither should fail the test
+		Logger(ctx).WithError(err).Debug("Failed to sample resource usage.")
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for container, resources := range usage {
+		if c.peaks[container] == nil {
+			c.peaks[container] = coreapi.ResourceList{}
+		}
+		for name, quantity := range resources {
+			if peak, ok := c.peaks[container][name]; !ok || quantity.Cmp(peak) > 0 {
+				c.peaks[container][name] = quantity
+			}
+		}
+	}
+}
+
+// usage combines the observed peaks with the requests of the pod
+func (c *resourceUsageCollector) usage(pod *coreapi.Pod) map[string]api.ContainerResourceUsage {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.peaks) == 0 {
+		return nil
+	}
+	requests := map[string]coreapi.ResourceList{}
+	for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		requests[container.Name] = container.Resources.Requests
+	}
+	usage := map[string]api.ContainerResourceUsage{}
+	for container, peaks := range c.peaks {
+		u := api.ContainerResourceUsage{Peak: api.ResourceList{}, Suggested: api.ResourceList{}}
+		for name, peak := range peaks {
+			u.Peak[string(name)] = peak.String()
+			suggested := suggestRequest(name, peak)
+			u.Suggested[string(name)] = suggested.String()
+		}
+		if len(requests[container]) > 0 {
+			u.Requested = api.ResourceList{}
+			for name, request := range requests[container] {
+				u.Requested[string(name)] = request.String()
+			}
+		}
+		usage[container] = u
+	}
+	return usage
+}
+
+// suggestRequest adds headroom to the peak usage and rounds it to a value
+// that reads well in a configuration
+func suggestRequest(name coreapi.ResourceName, peak resource.Quantity) resource.Quantity {
+	switch name {
+	case coreapi.ResourceCPU:
+		const step = 10
+		milli := int64(float64(peak.MilliValue()) * resourceUsageHeadroom)
+		return *resource.NewMilliQuantity((milli+step-1)/step*step, resource.DecimalSI)
+	case coreapi.ResourceMemory:
+		const step = 1024 * 1024
+		bytes := int64(float64(peak.Value()) * resourceUsageHeadroom)
+		return *resource.NewQuantity((bytes+step-1)/step*step, resource.BinarySI)
+	default:
+		return peak
+	}
+}
+
+// logResourceSuggestions points out containers whose requests are far from
+// what they actually used
+func logResourceSuggestions(ctx context.Context, pod string, usage map[string]api.ContainerResourceUsage) {
+	for container, u := range usage {
+		for name, requested := range u.Requested {
+			request, err := resource.ParseQuantity(requested)
+			if err != nil {
+				continue
+			}
+			peak, err := resource.ParseQuantity(u.Peak[name])
+			if err != nil {
+				continue
+			}
+			half := resource.NewMilliQuantity(request.MilliValue()/2, request.Format)
+			if peak.Cmp(request) > 0 || peak.Cmp(*half) < 0 {
+				Logger(ctx).Infof("Container %s in pod %s used at most %s %s but requested %s, consider requesting %s", container, pod, u.Peak[name], name, requested, u.Suggested[name])
+			}
+		}
+	}
+}
+
+// saveResourceUsage records the usage of all pods of a test in its artifacts
+func saveResourceUsage(ctx context.Context, test string, usage map[string]map[string]api.ContainerResourceUsage) error {
+	artifactDir, set := api.ArtifactsFor(ctx)
+	if !set {
+		return nil
+	}
+	dir := filepath.Join(artifactDir, test)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("unable to create directory %s: %w", dir, err)
+	}
+	raw, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, ResourceUsageArtifact), raw, 0640)
+}
@@ -0,0 +1,71 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+type fakeMetricsClient struct {
+	samples []map[string]coreapi.ResourceList
+}
+
+func (f *fakeMetricsClient) PodMetrics(context.Context, string, string) (map[string]coreapi.ResourceList, error) {
+	if len(f.samples) == 0 {
+		return nil, errors.New("no metrics")
+	}
+	sample := f.samples[0]
+	f.samples = f.samples[1:]
+	return sample, nil
+}
+
+func TestResourceUsageCollector(t *testing.T) {
+	usage := func(cpu, memory string) coreapi.ResourceList {
+		return coreapi.ResourceList{
+			coreapi.ResourceCPU:    resource.MustParse(cpu),
+			coreapi.ResourceMemory: resource.MustParse(memory),
+		}
+	}
+	client := &fakeMetricsClient{samples: []map[string]coreapi.ResourceList{
+		{"test": usage("100m", "200Mi")},
+		{"test": usage("500m", "100Mi"), "sidecar": usage("10m", "10Mi")},
+		{"test": usage("200m", "150Mi")},
+	}}
+	collector := newResourceUsageCollector(client, "ns", "pod")
+	for i := 0; i < 4; i++ {
+		collector.sample(context.Background())
+	}
+	pod := &coreapi.Pod{Spec: coreapi.PodSpec{Containers: []coreapi.Container{{
+		Name:      "test",
+		Resources: coreapi.ResourceRequirements{Requests: usage("1", "100Mi")},
+	}}}}
+	expected := map[string]api.ContainerResourceUsage{
+		"test": {
+			Peak:      api.ResourceList{"cpu": "500m", "memory": "200Mi"},
+			Requested: api.ResourceList{"cpu": "1", "memory": "100Mi"},
+			Suggested: api.ResourceList{"cpu": "600m", "memory": "240Mi"},
+		},
+		"sidecar": {
+			Peak:      api.ResourceList{"cpu": "10m", "memory": "10Mi"},
+			Suggested: api.ResourceList{"cpu": "20m", "memory": "12Mi"},
+		},
+	}
+	if diff := cmp.Diff(expected, collector.usage(pod)); diff != "" {
+		t.Errorf("unexpected usage: %s", diff)
+	}
+}
+
+func TestResourceUsageCollectorWithoutMetrics(t *testing.T) {
+	collector := newResourceUsageCollector(&fakeMetricsClient{}, "ns", "pod")
+	collector.sample(context.Background())
+	if usage := collector.usage(&coreapi.Pod{}); usage != nil {
+		t.Errorf("expected no usage without metrics, got %v", usage)
+	}
+}
@@ -34,7 +34,7 @@ func (s *rpmImageInjectionStep) Inputs() (api.InputDefinition, error) {
 func (*rpmImageInjectionStep) Validate() error { return nil }
 
 func (s *rpmImageInjectionStep) Run(ctx context.Context) error {
-	return results.ForReason("injecting_rpms").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonInjectingRPMs).ForError(s.run(ctx))
 }
 
 func (s *rpmImageInjectionStep) run(ctx context.Context) error {
@@ -3,15 +3,12 @@ package steps
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"net/url"
 	"path"
 	"strings"
 	"time"
 
-	"github.com/sirupsen/logrus"
-
 	appsapi "k8s.io/api/apps/v1"
 	coreapi "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
@@ -49,7 +46,7 @@ func (s *rpmServerStep) Inputs() (api.InputDefinition, error) {
 func (*rpmServerStep) Validate() error { return nil }
 
 func (s *rpmServerStep) Run(ctx context.Context) error {
-	return results.ForReason("serving_rpms").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonServingRPMs).ForError(s.run(ctx))
 }
 
 func (s *rpmServerStep) run(ctx context.Context) error {
@@ -292,7 +289,7 @@ func waitForDeploymentOrTimeout(ctx context.Context, client ctrlruntimeclient.Cl
 		case <-ticker.C:
 			deployment := &appsapi.Deployment{}
 			if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Name: name}, deployment); err != nil {
-				logrus.WithError(err).Error("Failed to get deployment")
+				Logger(ctx).WithError(err).Error("Failed to get deployment")
 			}
 			if deploymentOK(deployment) {
 				return nil
@@ -336,7 +333,7 @@ func waitForRouteReachable(ctx context.Context, client ctrlruntimeclient.Client,
 		}
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
-			log.Printf("Waiting for route to become available: %v", err)
+			Logger(ctx).Infof("Waiting for route to become available: %v", err)
 			select {
 			case <-done:
 				return ctx.Err()
@@ -346,7 +343,7 @@ func waitForRouteReachable(ctx context.Context, client ctrlruntimeclient.Client,
 		}
 		resp.Body.Close()
 		if resp.StatusCode >= 400 {
-			log.Printf("Waiting for route to become available: %d", resp.StatusCode)
+			Logger(ctx).Infof("Waiting for route to become available: %d", resp.StatusCode)
 			select {
 			case <-done:
 				return ctx.Err()
@@ -354,7 +351,7 @@ func waitForRouteReachable(ctx context.Context, client ctrlruntimeclient.Client,
 				continue
 			}
 		}
-		log.Printf("RPMs being served at %s", u)
+		Logger(ctx).Infof("RPMs being served at %s", u)
 		return nil
 	}
 }
@@ -6,9 +6,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/sirupsen/logrus"
+
 	"github.com/openshift/ci-tools/pkg/api"
 	"github.com/openshift/ci-tools/pkg/junit"
 	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/telemetry"
 )
 
 type message struct {
@@ -19,7 +22,12 @@ type message struct {
 	stepDetails     api.CIOperatorStepDetails
 }
 
-func Run(ctx context.Context, graph []*api.StepNode) (*junit.TestSuites, []api.CIOperatorStepDetails, []error) {
+// StepFinishedFunc is notified when a step of the graph finishes
+type StepFinishedFunc func(name string, err error)
+
+// Run executes the graph. The optional onFinished function is notified
+// whenever a step finishes.
+func Run(ctx context.Context, graph []*api.StepNode, onFinished StepFinishedFunc) (*junit.TestSuites, []api.CIOperatorStepDetails, []error) {
 	var seen []api.StepLink
 	executionResults := make(chan message)
 	done := make(chan bool)
@@ -48,16 +56,19 @@ func Run(ctx context.Context, graph []*api.StepNode) (*junit.TestSuites, []api.C
 	for {
 		select {
 		case <-ctxDone:
-			executionErrors = append(executionErrors, results.ForReason("interrupted").ForError(errors.New("execution cancelled")))
+			executionErrors = append(executionErrors, results.ForReason(results.ReasonInterrupted).ForError(errors.New("execution cancelled")))
 			interrupted = true
 			ctxDone = nil
 		case out := <-executionResults:
 			testCase := &junit.TestCase{Name: out.node.Step.Description(), Duration: out.duration.Seconds()}
 			stepDetails = append(stepDetails, out.stepDetails)
+			if onFinished != nil {
+				onFinished(out.node.Step.Name(), out.err)
+			}
 			if out.err != nil {
 				testCase.FailureOutput = &junit.FailureOutput{Output: out.err.Error()}
 				if out.err != context.Canceled {
-					executionErrors = append(executionErrors, results.ForReason("step_failed").WithError(out.err).Errorf("step %s failed: %v", out.node.Step.Name(), out.err))
+					executionErrors = append(executionErrors, results.ForReason(results.ReasonStepFailed).WithError(out.err).Errorf("step %s failed: %v", out.node.Step.Name(), out.err))
 				}
 			} else {
 				seen = append(seen, out.node.Step.Creates()...)
@@ -118,7 +129,8 @@ type SubStepReporter interface {
 
 func runStep(ctx context.Context, node *api.StepNode, out chan<- message) {
 	start := time.Now()
-	err := node.Step.Run(ctx)
+	err := node.Step.Run(WithLogFields(ctx, logrus.Fields{"step": node.Step.Name()}))
+	telemetry.RecordStep(ctx, node.Step)
 	var additionalTests []*junit.TestCase
 	if reporter, ok := node.Step.(subtestReporter); ok {
 		additionalTests = reporter.SubTests()
@@ -293,7 +293,10 @@ func TestStepsRun(t *testing.T) {
 			if tc.cancelled {
 				cancel()
 			}
-			suites, _, errs := Run(ctx, api.BuildGraph(steps))
+			finished := map[string]int{}
+			suites, _, errs := Run(ctx, api.BuildGraph(steps), func(name string, _ error) {
+				finished[name]++
+			})
 			if errs == nil && len(tc.errExpected) > 0 {
 				t.Error("got no error but expected one")
 			}
@@ -325,6 +328,9 @@ func TestStepsRun(t *testing.T) {
 					if step.shouldRun && step.numRuns != 1 {
 						t.Errorf("step %s did not run just once, but %d times", step.name, step.numRuns)
 					}
+					if step.shouldRun && finished[step.name] != 1 {
+						t.Errorf("step %s was not reported as finished just once, but %d times", step.name, finished[step.name])
+					}
 					if !step.shouldRun && step.numRuns != 0 {
 						t.Errorf("step %s expected to never run, but ran %d times", step.name, step.numRuns)
 					}
@@ -0,0 +1,103 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type schedulingTimeoutKey struct{}
+
+// WithSchedulingTimeout returns a context in which steps fail once one of
+// their pods could not be scheduled for the timeout, instead of waiting for
+// it to start until the job times out. A zero timeout disables failing
+// early.
+func WithSchedulingTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, schedulingTimeoutKey{}, timeout)
+}
+
+// schedulingTimeout returns the scheduling timeout of the context, which is
+// zero unless one was set, as pods may wait for the cluster to scale up
+func schedulingTimeout(ctx context.Context) time.Duration {
+	timeout, _ := ctx.Value(schedulingTimeoutKey{}).(time.Duration)
+	return timeout
+}
+
+// podUnschedulableFor determines whether the scheduler has been unable to
+// place the pod for at least the timeout, returning its condition if so
+func podUnschedulableFor(pod *coreapi.Pod, timeout time.Duration, now time.Time) (*coreapi.PodCondition, bool) {
+	if timeout == 0 || pod.Spec.NodeName != "" {
+		return nil, false
+	}
+	for i, condition := range pod.Status.Conditions {
+		if condition.Type != coreapi.PodScheduled || condition.Status != coreapi.ConditionFalse || condition.Reason != coreapi.PodReasonUnschedulable {
+			continue
+		}
+		since := condition.LastTransitionTime.Time
+		if since.IsZero() {
+			since = pod.CreationTimestamp.Time
+		}
+		return &pod.Status.Conditions[i], now.Sub(since) >= timeout
+	}
+	return nil, false
+}
+
+// schedulingDiagnostics explains why the pod could not be scheduled: what
+// the scheduler reported about the capacity of the cluster, what the pod
+// asks for and the events of the pod
+func schedulingDiagnostics(ctx context.Context, pod *coreapi.Pod, condition *coreapi.PodCondition, client ctrlruntimeclient.Client) string {
+	builder := &strings.Builder{}
+	_, _ = builder.WriteString(fmt.Sprintf("Scheduler: %s", condition.Message))
+	_, _ = builder.WriteString(fmt.Sprintf("\nPod requests: %s", podRequestsSummary(pod)))
+	if len(pod.Spec.NodeSelector) > 0 {
+		var selectors []string
+		for key, value := range pod.Spec.NodeSelector {
+			selectors = append(selectors, fmt.Sprintf("%s=%s", key, value))
+		}
+		sort.Strings(selectors)
+		_, _ = builder.WriteString(fmt.Sprintf("\nNode selector: %s", strings.Join(selectors, ", ")))
+	}
+	if events := getEventsForPod(ctx, pod, client); events != "" {
+		_, _ = builder.WriteString("\n" + events)
+	}
+	return builder.String()
+}
+
+// podRequestsSummary sums up the resources requested by the containers of
+// the pod, counting init containers as the scheduler does
+func podRequestsSummary(pod *coreapi.Pod) string {
+	total := coreapi.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		for name, quantity := range container.Resources.Requests {
+			sum := total[name]
+			sum.Add(quantity)
+			total[name] = sum
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		for name, quantity := range container.Resources.Requests {
+			if current, ok := total[name]; !ok || quantity.Cmp(current) > 0 {
+				total[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	if len(total) == 0 {
+		return "none"
+	}
+	var names []string
+	for name := range total {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	var requests []string
+	for _, name := range names {
+		quantity := total[coreapi.ResourceName(name)]
+		requests = append(requests, fmt.Sprintf("%s=%s", name, quantity.String()))
+	}
+	return strings.Join(requests, ", ")
+}
@@ -0,0 +1,89 @@
+package steps
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSchedulingTimeout(t *testing.T) {
+	if actual := schedulingTimeout(context.Background()); actual != 0 {
+		t.Errorf("expected the timeout to be disabled by default, got %s", actual)
+	}
+	if actual := schedulingTimeout(WithSchedulingTimeout(context.Background(), time.Hour)); actual != time.Hour {
+		t.Errorf("expected the configured timeout, got %s", actual)
+	}
+}
+
+func TestPodUnschedulableFor(t *testing.T) {
+	now := time.Date(2021, time.January, 1, 1, 0, 0, 0, time.UTC)
+	unschedulable := func(since time.Duration) coreapi.PodCondition {
+		return coreapi.PodCondition{
+			Type:               coreapi.PodScheduled,
+			Status:             coreapi.ConditionFalse,
+			Reason:             coreapi.PodReasonUnschedulable,
+			LastTransitionTime: meta.NewTime(now.Add(-since)),
+			Message:            "0/3 nodes are available: 3 Insufficient cpu.",
+		}
+	}
+	for _, tc := range []struct {
+		name       string
+		conditions []coreapi.PodCondition
+		nodeName   string
+		timeout    time.Duration
+		expected   bool
+	}{{
+		name:       "unschedulable for longer than the timeout",
+		conditions: []coreapi.PodCondition{unschedulable(15 * time.Minute)},
+		timeout:    10 * time.Minute,
+		expected:   true,
+	}, {
+		name:       "unschedulable for less than the timeout",
+		conditions: []coreapi.PodCondition{unschedulable(5 * time.Minute)},
+		timeout:    10 * time.Minute,
+	}, {
+		name:       "watchdog disabled",
+		conditions: []coreapi.PodCondition{unschedulable(time.Hour)},
+	}, {
+		name:       "pod already scheduled",
+		conditions: []coreapi.PodCondition{unschedulable(15 * time.Minute)},
+		nodeName:   "node",
+		timeout:    10 * time.Minute,
+	}, {
+		name:       "pod scheduled",
+		conditions: []coreapi.PodCondition{{Type: coreapi.PodScheduled, Status: coreapi.ConditionTrue}},
+		timeout:    10 * time.Minute,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := &coreapi.Pod{Spec: coreapi.PodSpec{NodeName: tc.nodeName}, Status: coreapi.PodStatus{Conditions: tc.conditions}}
+			if _, actual := podUnschedulableFor(pod, tc.timeout, now); actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPodRequestsSummary(t *testing.T) {
+	requests := func(cpu, memory string) coreapi.ResourceRequirements {
+		return coreapi.ResourceRequirements{Requests: coreapi.ResourceList{
+			coreapi.ResourceCPU:    resource.MustParse(cpu),
+			coreapi.ResourceMemory: resource.MustParse(memory),
+		}}
+	}
+	pod := &coreapi.Pod{Spec: coreapi.PodSpec{
+		InitContainers: []coreapi.Container{{Resources: requests("4", "1Gi")}},
+		Containers:     []coreapi.Container{{Resources: requests("1", "2Gi")}, {Resources: requests("500m", "1Gi")}},
+	}}
+	if diff := cmp.Diff("cpu=4, memory=3Gi", podRequestsSummary(pod)); diff != "" {
+		t.Errorf("unexpected summary: %s", diff)
+	}
+	if diff := cmp.Diff("none", podRequestsSummary(&coreapi.Pod{})); diff != "" {
+		t.Errorf("unexpected summary: %s", diff)
+	}
+}
@@ -1,23 +1,33 @@
 package steps
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"io/ioutil"
+	"net/url"
 	"os"
+	"os/exec"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/wait"
 	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/clonerefs"
+	"k8s.io/test-infra/prow/pod-utils/clone"
 	"k8s.io/test-infra/prow/pod-utils/decorate"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -27,6 +37,7 @@ import (
 	"github.com/openshift/ci-tools/pkg/api"
 	"github.com/openshift/ci-tools/pkg/results"
 	"github.com/openshift/ci-tools/pkg/steps/utils"
+	"github.com/openshift/ci-tools/pkg/telemetry"
 )
 
 const (
@@ -46,6 +57,11 @@ const (
 	OauthSecretKey = "oauth-token"
 
 	PullSecretName = "registry-pull-credentials"
+
+	// BuildInputsDigestAnnotation records a digest of everything that determines
+	// the output of a build, so that a later run in the same namespace can tell
+	// whether the build may be reused.
+	BuildInputsDigestAnnotation = "ci.openshift.io/inputs-digest"
 )
 
 type CloneAuthType string
@@ -60,18 +76,22 @@ type CloneAuthConfig struct {
 	Type   CloneAuthType
 }
 
-func (c *CloneAuthConfig) getCloneURI(org, repo string) string {
+func (c *CloneAuthConfig) getCloneURI(refs *prowv1.Refs) string {
+	if api.IsGerrit(refs) {
+		// Gerrit is cloned over HTTPS, with the token when OAuth is configured
+		return api.CloneURI(refs)
+	}
 	if c.Type == CloneAuthTypeSSH {
-		return fmt.Sprintf("ssh://git@github.com/%s/%s.git", org, repo)
+		return fmt.Sprintf("ssh://git@github.com/%s/%s.git", refs.Org, refs.Repo)
 	}
-	return fmt.Sprintf("https://github.com/%s/%s.git", org, repo)
+	return fmt.Sprintf("https://github.com/%s/%s.git", refs.Org, refs.Repo)
 }
 
 var (
 	JobSpecAnnotation = fmt.Sprintf("%s/%s", CiAnnotationPrefix, "job-spec")
 )
 
-func sourceDockerfile(fromTag api.PipelineImageStreamTagReference, workingDir string, cloneAuthConfig *CloneAuthConfig) string {
+func sourceDockerfile(fromTag api.PipelineImageStreamTagReference, workingDir string, refs []prowv1.Refs, cloneAuthConfig *CloneAuthConfig) string {
 	var dockerCommands []string
 	var secretPath string
 
@@ -92,6 +112,12 @@ func sourceDockerfile(fromTag api.PipelineImageStreamTagReference, workingDir st
 	}
 
 	dockerCommands = append(dockerCommands, fmt.Sprintf("RUN umask 0002 && /clonerefs && find %s/src -type d -not -perm -0775 | xargs --max-procs 10 --max-args 100 --no-run-if-empty chmod g+xw", gopath))
+	if verify := verifyClonedRefsCommand(refs); verify != "" {
+		dockerCommands = append(dockerCommands, fmt.Sprintf("RUN %s", verify))
+	}
+	if len(refs) > 0 && len(refs[0].Pulls) > 0 {
+		dockerCommands = append(dockerCommands, fmt.Sprintf(`RUN echo "%s $(git -C %s rev-parse HEAD)"`, mergeSHAMarker, clone.PathForRefs(gopath, refs[0])))
+	}
 	dockerCommands = append(dockerCommands, fmt.Sprintf("WORKDIR %s/", workingDir))
 	dockerCommands = append(dockerCommands, fmt.Sprintf("ENV GOPATH=%s", gopath))
 
@@ -106,6 +132,64 @@ func sourceDockerfile(fromTag api.PipelineImageStreamTagReference, workingDir st
 	return strings.Join(dockerCommands, "\n")
 }
 
+// cloneDriftMarker prefixes the lines the source build prints when a clone
+// does not contain the revisions the job asked for, which happens when
+// mirrors or forks of a remote are not consistent with each other
+const cloneDriftMarker = "ci-operator-clone-drift"
+
+var cloneDriftLine = regexp.MustCompile(`(?m)^` + cloneDriftMarker + ` [^ ]+/[^ ]+ [^ ]+$`)
+
+// mergeSHAMarker prefixes the line of the source build log recording the
+// commit the pull requests were merged as
+const mergeSHAMarker = "ci-operator-merge-sha"
+
+var mergeSHALine = regexp.MustCompile(`^` + mergeSHAMarker + ` ([0-9a-f]{40})$`)
+
+// parseMergeSHA finds the commit the pull requests were merged as in the log
+// of the source build
+func parseMergeSHA(buildLog io.Reader) (string, error) {
+	scanner := bufio.NewScanner(buildLog)
+	for scanner.Scan() {
+		if match := mergeSHALine.FindStringSubmatch(strings.TrimSpace(scanner.Text())); match != nil {
+			return match[1], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("the merge commit was not recorded")
+}
+
+// verifyClonedRefsCommand returns a shell command that fails if any of the
+// clones does not match the SHAs in the refs. Without pulls, HEAD must be the
+// base SHA; with pulls merged in, every SHA must be an ancestor of HEAD.
+func verifyClonedRefsCommand(refs []prowv1.Refs) string {
+	var checks []string
+	for _, ref := range refs {
+		dir := clone.PathForRefs(gopath, ref)
+		fail := func(sha string) string {
+			return fmt.Sprintf(`{ echo "%s %s/%s %s" >&2; exit 1; }`, cloneDriftMarker, ref.Org, ref.Repo, sha)
+		}
+		if len(ref.Pulls) == 0 {
+			if ref.BaseSHA != "" {
+				checks = append(checks, fmt.Sprintf(`[ "$(git -C %s rev-parse HEAD)" = %s ] || %s`, dir, ref.BaseSHA, fail(ref.BaseSHA)))
+			}
+			continue
+		}
+		shas := []string{ref.BaseSHA}
+		for _, pull := range ref.Pulls {
+			shas = append(shas, pull.SHA)
+		}
+		for _, sha := range shas {
+			if sha == "" {
+				continue
+			}
+			checks = append(checks, fmt.Sprintf("git -C %s merge-base --is-ancestor %s HEAD || %s", dir, sha, fail(sha)))
+		}
+	}
+	return strings.Join(checks, " && ")
+}
+
 func defaultPodLabels(jobSpec *api.JobSpec) map[string]string {
 	if refs := jobSpec.JobSpec.Refs; refs != nil {
 		return trimLabels(map[string]string{
@@ -114,8 +198,8 @@ func defaultPodLabels(jobSpec *api.JobSpec) map[string]string {
 			ProwJobIdLabel:   jobSpec.ProwJobID,
 			CreatedByCILabel: "true",
 			openshiftCIEnv:   "true",
-			RefsOrgLabel:     refs.Org,
-			RefsRepoLabel:    refs.Repo,
+			RefsOrgLabel:     refsLabelValue(refs.Org),
+			RefsRepoLabel:    refsLabelValue(refs.Repo),
 			RefsBranchLabel:  refs.BaseRef,
 		})
 	}
@@ -127,8 +211,8 @@ func defaultPodLabels(jobSpec *api.JobSpec) map[string]string {
 			ProwJobIdLabel:   jobSpec.ProwJobID,
 			CreatedByCILabel: "true",
 			openshiftCIEnv:   "true",
-			RefsOrgLabel:     extraRefs[0].Org,
-			RefsRepoLabel:    extraRefs[0].Repo,
+			RefsOrgLabel:     refsLabelValue(extraRefs[0].Org),
+			RefsRepoLabel:    refsLabelValue(extraRefs[0].Repo),
 			RefsBranchLabel:  extraRefs[0].BaseRef,
 		})
 	}
@@ -149,6 +233,8 @@ type sourceStep struct {
 	jobSpec         *api.JobSpec
 	cloneAuthConfig *CloneAuthConfig
 	pullSecret      *corev1.Secret
+	podClient       PodClient
+	clonerefs       *ClonerefsOverride
 }
 
 func (s *sourceStep) Inputs() (api.InputDefinition, error) {
@@ -158,36 +244,174 @@ func (s *sourceStep) Inputs() (api.InputDefinition, error) {
 func (*sourceStep) Validate() error { return nil }
 
 func (s *sourceStep) Run(ctx context.Context) error {
-	return results.ForReason("cloning_source").ForError(s.run(ctx))
+	err := s.run(ctx)
+	if results.HasReason(err, results.ReasonCloneDrift) {
+		return results.ForReason(results.ReasonCloningSource).ForError(fmt.Errorf("the cloned source does not match the requested refs, the remote may be serving stale or inconsistent data: %w", err))
+	}
+	return results.ForReason(results.ReasonCloningSource).ForError(err)
 }
 
 func (s *sourceStep) run(ctx context.Context) error {
-	clonerefsRef, err := istObjectReference(ctx, s.client, s.config.ClonerefsImage)
+	config := s.config
+	if s.clonerefs != nil {
+		config.ClonerefsImage = s.clonerefs.Image
+		config.ClonerefsPath = s.clonerefs.Path
+	}
+	clonerefsRef, err := istObjectReference(ctx, s.client, config.ClonerefsImage)
 	if err != nil {
 		return fmt.Errorf("could not resolve clonerefs source: %w", err)
 	}
+	// the default image is kept compatible, images supplied for the job
+	// may be arbitrarily old
+	if s.clonerefs != nil {
+		image := config.ClonerefsImage
+		Logger(ctx).Infof("Checking that clonerefs from %s/%s:%s is compatible", image.Namespace, image.Name, image.Tag)
+		if err := checkClonerefs(ctx, s.podClient, s.jobSpec, clonerefsRef, config.ClonerefsPath, s.pullSecret); err != nil {
+			return fmt.Errorf("clonerefs from %s/%s:%s cannot be used: %w", image.Namespace, image.Name, image.Tag, err)
+		}
+	}
+
+	pinned, err := pinRefs(ctx, s.jobSpec, s.cloneAuthConfig)
+	if err != nil {
+		return fmt.Errorf("could not pin the refs to clone: %w", err)
+	}
+	if err := handleBuild(ctx, s.client, createBuild(config, pinned, clonerefsRef, s.resources, s.cloneAuthConfig, s.pullSecret)); err != nil {
+		return err
+	}
+	if refs := s.jobSpec.Refs; refs != nil && len(refs.Pulls) > 0 {
+		if err := s.recordMergeSHA(); err != nil {
+			Logger(ctx).Warnf("Could not determine the merge commit of the pull requests, images will not be labeled with it: %v", err)
+		}
+	}
+	return nil
+}
 
-	return handleBuild(ctx, s.client, createBuild(s.config, s.jobSpec, clonerefsRef, s.resources, s.cloneAuthConfig, s.pullSecret))
+// recordMergeSHA stores the commit the pull requests were merged as in the
+// job, so that images built from the source can be labeled with it
+func (s *sourceStep) recordMergeSHA() error {
+	rc, err := s.client.Logs(s.jobSpec.Namespace(), string(s.config.To), &buildapi.BuildLogOptions{})
+	if err != nil {
+		return fmt.Errorf("could not get build log: %w", err)
+	}
+	defer rc.Close()
+	sha, err := parseMergeSHA(rc)
+	if err != nil {
+		return fmt.Errorf("could not read build log: %w", err)
+	}
+	s.jobSpec.SetMergeSHA(sha)
+	return nil
 }
 
-func createBuild(config api.SourceStepConfiguration, jobSpec *api.JobSpec, clonerefsRef corev1.ObjectReference, resources api.ResourceConfiguration, cloneAuthConfig *CloneAuthConfig, pullSecret *corev1.Secret) *buildapi.Build {
-	var refs []prowv1.Refs
-	if jobSpec.Refs != nil {
-		r := *jobSpec.Refs
-		if cloneAuthConfig != nil {
-			r.CloneURI = cloneAuthConfig.getCloneURI(r.Org, r.Repo)
+// resolveBaseSHA determines the commit the branch of the remote points to,
+// authenticating like the clone does; tests replace it to not reach out to
+// remotes
+var resolveBaseSHA = func(ctx context.Context, ref prowv1.Refs, cloneAuthConfig *CloneAuthConfig) (string, error) {
+	cloneURI := api.CloneURI(&ref)
+	remote := cloneURI
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if cloneAuthConfig != nil && cloneAuthConfig.Secret != nil {
+		remote = cloneAuthConfig.getCloneURI(&ref)
+		switch cloneAuthConfig.Type {
+		case CloneAuthTypeOAuth:
+			u, err := url.Parse(remote)
+			if err != nil {
+				return "", fmt.Errorf("invalid clone URI %s: %w", remote, err)
+			}
+			u.User = url.UserPassword(string(cloneAuthConfig.Secret.Data[OauthSecretKey]), "x-oauth-basic")
+			remote = u.String()
+		case CloneAuthTypeSSH:
+			key, err := ioutil.TempFile("", "ssh-privatekey-*")
+			if err != nil {
+				return "", fmt.Errorf("could not create key file: %w", err)
+			}
+			defer os.Remove(key.Name())
+			_, err = key.Write(cloneAuthConfig.Secret.Data[corev1.SSHAuthPrivateKey])
+			if closeErr := key.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return "", fmt.Errorf("could not write key file: %w", err)
+			}
+			env = append(env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %s -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null", key.Name()))
 		}
-		refs = append(refs, r)
 	}
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", remote, "refs/heads/"+ref.BaseRef)
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		// the remote may hold credentials, so it is not part of the error
+		return "", fmt.Errorf("'git ls-remote %s %s' failed: %w", cloneURI, ref.BaseRef, err)
+	}
+	sha := strings.Split(strings.TrimSpace(string(out)), "\t")[0]
+	if sha == "" {
+		return "", fmt.Errorf("branch %s does not exist in %s", ref.BaseRef, cloneURI)
+	}
+	return sha, nil
+}
+
+// pinRefs returns a copy of the job whose refs name the commits clonerefs
+// checks out, so that the clone is verified against them. Refs which only
+// name a branch, like the extra refs of periodics, are resolved; refs which
+// cannot be resolved fail the clone, as it could not be verified.
+func pinRefs(ctx context.Context, jobSpec *api.JobSpec, cloneAuthConfig *CloneAuthConfig) (*api.JobSpec, error) {
+	pinned := *jobSpec
+	var errs []error
+	pin := func(ref prowv1.Refs) prowv1.Refs {
+		if ref.BaseSHA != "" || ref.BaseRef == "" {
+			return ref
+		}
+		sha, err := resolveBaseSHA(ctx, ref, cloneAuthConfig)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not resolve %s/%s@%s: %w", ref.Org, ref.Repo, ref.BaseRef, err))
+			return ref
+		}
+		Logger(ctx).Debugf("Resolved %s/%s@%s to %s", ref.Org, ref.Repo, ref.BaseRef, sha)
+		ref.BaseSHA = sha
+		return ref
+	}
+	if jobSpec.Refs != nil {
+		refs := pin(*jobSpec.Refs)
+		pinned.Refs = &refs
+	}
+	pinned.ExtraRefs = nil
+	for _, ref := range jobSpec.ExtraRefs {
+		pinned.ExtraRefs = append(pinned.ExtraRefs, pin(ref))
+	}
+	if len(errs) > 0 {
+		return nil, utilerrors.NewAggregate(errs)
+	}
+	return &pinned, nil
+}
 
-	for _, r := range jobSpec.ExtraRefs {
+// refsToClone lists the refs of the job, cloned with the configured
+// authentication. Gerrit projects are cloned from their instance into a
+// directory named after it.
+func refsToClone(jobSpec *api.JobSpec, cloneAuthConfig *CloneAuthConfig) []prowv1.Refs {
+	var all []prowv1.Refs
+	if jobSpec.Refs != nil {
+		all = append(all, *jobSpec.Refs)
+	}
+	all = append(all, jobSpec.ExtraRefs...)
+	var refs []prowv1.Refs
+	for _, r := range all {
+		if api.IsGerrit(&r) {
+			r.CloneURI = api.CloneURI(&r)
+			if r.RepoLink == "" {
+				r.RepoLink = api.RepoURL(&r)
+			}
+		}
 		if cloneAuthConfig != nil {
-			r.CloneURI = cloneAuthConfig.getCloneURI(r.Org, r.Repo)
+			r.CloneURI = cloneAuthConfig.getCloneURI(&r)
 		}
 		refs = append(refs, r)
 	}
+	return refs
+}
+
+func createBuild(config api.SourceStepConfiguration, jobSpec *api.JobSpec, clonerefsRef corev1.ObjectReference, resources api.ResourceConfiguration, cloneAuthConfig *CloneAuthConfig, pullSecret *corev1.Secret) *buildapi.Build {
+	refs := refsToClone(jobSpec, cloneAuthConfig)
 
-	dockerfile := sourceDockerfile(config.From, decorate.DetermineWorkDir(gopath, refs), cloneAuthConfig)
+	dockerfile := sourceDockerfile(config.From, decorate.DetermineWorkDir(gopath, refs), refs, cloneAuthConfig)
 	buildSource := buildapi.BuildSource{
 		Type:       buildapi.BuildSourceDockerfile,
 		Dockerfile: &dockerfile,
@@ -248,7 +472,6 @@ func createBuild(config api.SourceStepConfiguration, jobSpec *api.JobSpec, clone
 }
 
 func buildFromSource(jobSpec *api.JobSpec, fromTag, toTag api.PipelineImageStreamTagReference, source buildapi.BuildSource, dockerfilePath string, resources api.ResourceConfiguration, pullSecret *corev1.Secret) *buildapi.Build {
-	log.Printf("Building %s", toTag)
 	buildResources, err := resourcesFor(resources.RequirementsForStep(string(toTag)))
 	if err != nil {
 		panic(fmt.Errorf("unable to parse resource requirement for build %s: %w", toTag, err))
@@ -306,7 +529,7 @@ func buildFromSource(jobSpec *api.JobSpec, fromTag, toTag api.PipelineImageStrea
 		build.OwnerReferences = append(build.OwnerReferences, *owner)
 	}
 
-	addLabelsToBuild(jobSpec.Refs, build, source.ContextDir)
+	addLabelsToBuild(jobSpec.Refs, jobSpec.MergeSHA(), build, source.ContextDir)
 	return build
 }
 
@@ -348,8 +571,212 @@ func isBuildPhaseTerminated(phase buildapi.BuildPhase) bool {
 	return true
 }
 
+// buildInputsDigest hashes the source and strategy of a build together with
+// the images currently behind the image stream tags it builds from. Two builds
+// with the same digest produce the same output.
+func buildInputsDigest(ctx context.Context, client ctrlruntimeclient.Client, build *buildapi.Build) (string, error) {
+	return inputsDigest(ctx, client, build, namespaceDigest)
+}
+
+// sharedInputsDigest is like buildInputsDigest but does not depend on the
+// namespace the build runs in, so that builds with the same inputs in the
+// namespaces of different jobs have the same digest
+func sharedInputsDigest(ctx context.Context, client ctrlruntimeclient.Client, build *buildapi.Build) (string, error) {
+	return inputsDigest(ctx, client, build, sharedDigest)
+}
+
+// contentInputsDigest is like sharedInputsDigest but only depends on the
+// commits a build clones, not on the branch or remote they were cloned for,
+// so that builds of the same source tree have the same digest in every job.
+// The labels naming the branch and remote are left out as well, the build
+// cache sets them on the images it hands to builds for other branches.
+// Builds which see the branch in their environment, like those of images
+// built from the source, still only share their output within the branch.
+func contentInputsDigest(ctx context.Context, client ctrlruntimeclient.Client, build *buildapi.Build) (string, error) {
+	return inputsDigest(ctx, client, build, contentDigest)
+}
+
+// digestScope determines which builds have the same digest
+type digestScope int
+
+const (
+	namespaceDigest digestScope = iota
+	sharedDigest
+	contentDigest
+)
+
+func inputsDigest(ctx context.Context, client ctrlruntimeclient.Client, build *buildapi.Build, scope digestScope) (string, error) {
+	spec := build.Spec.DeepCopy()
+	if scope == contentDigest {
+		if err := cloneOnlyCommits(spec); err != nil {
+			return "", err
+		}
+	}
+	var refs []*corev1.ObjectReference
+	if strategy := spec.Strategy.DockerStrategy; strategy != nil && strategy.From != nil {
+		refs = append(refs, strategy.From)
+	}
+	for i := range spec.Source.Images {
+		refs = append(refs, &spec.Source.Images[i].From)
+	}
+	images := map[string]string{}
+	for _, ref := range refs {
+		if ref.Kind != "ImageStreamTag" {
+			continue
+		}
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = build.Namespace
+		}
+		key := fmt.Sprintf("%s/%s", namespace, ref.Name)
+		if scope != namespaceDigest && namespace == build.Namespace {
+			ref.Namespace, key = "", ref.Name
+		}
+		ist := &imagev1.ImageStreamTag{}
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: ref.Name}, ist); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return "", fmt.Errorf("could not resolve build input %s/%s: %w", namespace, ref.Name, err)
+		}
+		images[key] = ist.Image.Name
+	}
+	// the labels describe the commits and pull requests the image was built
+	// from, images with other labels must not be shared
+	labels := spec.Output.ImageLabels
+	if scope == contentDigest {
+		labels = nil
+		for _, label := range spec.Output.ImageLabels {
+			if !branchLabels.Has(label.Name) {
+				labels = append(labels, label)
+			}
+		}
+	}
+	raw, err := json.Marshal(struct {
+		Source   buildapi.BuildSource
+		Strategy buildapi.BuildStrategy
+		Labels   []buildapi.ImageLabel
+		Images   map[string]string
+	}{
+		Source:   spec.Source,
+		Strategy: spec.Strategy,
+		Labels:   labels,
+		Images:   images,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not serialize build inputs: %w", err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(raw)), nil
+}
+
+// cloneOnlyCommits reduces the refs a source build clones to the commits
+// that make up the tree it clones
+func cloneOnlyCommits(spec *buildapi.BuildSpec) error {
+	strategy := spec.Strategy.DockerStrategy
+	if strategy == nil {
+		return nil
+	}
+	for i, env := range strategy.Env {
+		if env.Name != clonerefs.JSONConfigEnvVar {
+			continue
+		}
+		var options clonerefs.Options
+		if err := options.LoadConfig(env.Value); err != nil {
+			return fmt.Errorf("could not parse the clonerefs options of the build: %w", err)
+		}
+		for j, refs := range options.GitRefs {
+			commits := prowv1.Refs{Org: refs.Org, Repo: refs.Repo, PathAlias: refs.PathAlias, BaseSHA: refs.BaseSHA}
+			for _, pull := range refs.Pulls {
+				commits.Pulls = append(commits.Pulls, prowv1.Pull{SHA: pull.SHA})
+			}
+			options.GitRefs[j] = commits
+		}
+		raw, err := clonerefs.Encode(options)
+		if err != nil {
+			return fmt.Errorf("could not serialize the clonerefs options of the build: %w", err)
+		}
+		strategy.Env[i].Value = raw
+	}
+	return nil
+}
+
+// outputExists determines if the image stream tag a build pushes to exists
+func outputExists(ctx context.Context, client ctrlruntimeclient.Client, build *buildapi.Build) (bool, error) {
+	to := build.Spec.Output.To
+	if to == nil || to.Kind != "ImageStreamTag" {
+		return true, nil
+	}
+	namespace := to.Namespace
+	if namespace == "" {
+		namespace = build.Namespace
+	}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: to.Name}, &imagev1.ImageStreamTag{}); err != nil {
+		if kerrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// recreateBuild deletes the existing build and creates it anew
+func recreateBuild(ctx context.Context, buildClient BuildClient, existing, build *buildapi.Build) error {
+	zero := int64(0)
+	foreground := metav1.DeletePropagationForeground
+	opts := metav1.DeleteOptions{
+		GracePeriodSeconds: &zero,
+		Preconditions:      &metav1.Preconditions{UID: &existing.UID},
+		PropagationPolicy:  &foreground,
+	}
+	if err := buildClient.Delete(ctx, build, &ctrlruntimeclient.DeleteOptions{Raw: &opts}); err != nil && !kerrors.IsNotFound(err) && !kerrors.IsConflict(err) {
+		return fmt.Errorf("could not delete build %s: %w", build.Name, err)
+	}
+	if err := waitForBuildDeletion(ctx, buildClient, build.Namespace, build.Name); err != nil {
+		return fmt.Errorf("could not wait for build %s to be deleted: %w", build.Name, err)
+	}
+	if err := buildClient.Create(ctx, build); err != nil && !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not recreate build %s: %w", build.Name, err)
+	}
+	return nil
+}
+
 func handleBuild(ctx context.Context, buildClient BuildClient, build *buildapi.Build) error {
-	if err := buildClient.Create(ctx, build); err != nil {
+	ctx = WithLogFields(ctx, logrus.Fields{"build": build.Name})
+	run := runBuild
+	for _, sharing := range imageSharingFrom(ctx) {
+		run = sharing.around(run)
+	}
+	return run(ctx, buildClient, build)
+}
+
+// runBuild builds in the namespace of the job, reusing a build from a
+// previous run when its inputs are unchanged
+func runBuild(ctx context.Context, buildClient BuildClient, build *buildapi.Build) error {
+	if dispatching, ok := buildClient.(*dispatchingBuildClient); ok {
+		buildClient = dispatching.BuildClient
+		if farm, release := dispatching.dispatcher.reserve(ctx, build.Namespace); farm != nil {
+			defer release()
+			return dispatching.dispatcher.handleRemoteBuild(ctx, buildClient, farm, build)
+		}
+	}
+	if tekton, ok := buildClient.(*tektonBuildClient); ok {
+		return handleTektonBuild(ctx, tekton, build)
+	}
+	if err := isolateHermeticBuild(ctx, buildClient, build, hermeticBuildPodLabel); err != nil {
+		return err
+	}
+	Logger(ctx).Infof("Building %s", build.Name)
+	digest, err := buildInputsDigest(ctx, buildClient, build)
+	if err != nil {
+		return err
+	}
+	if build.Annotations == nil {
+		build.Annotations = map[string]string{}
+	}
+	build.Annotations[BuildInputsDigestAnnotation] = digest
+	if err := buildClient.Create(ctx, build); err == nil {
+		recordBuilt(ctx, build)
+	} else {
 		if !kerrors.IsAlreadyExists(err) {
 			return fmt.Errorf("could not create build %s: %w", build.Name, err)
 		}
@@ -358,32 +785,54 @@ func handleBuild(ctx context.Context, buildClient BuildClient, build *buildapi.B
 			return fmt.Errorf("could not get build %s: %w", build.Name, err)
 		}
 
-		if isBuildPhaseTerminated(b.Status.Phase) &&
-			(isInfraReason(b.Status.Reason) || hintsAtInfraReason(b.Status.LogSnippet)) {
-			log.Printf("Build %s previously failed from an infrastructure error (%s), retrying...\n", b.Name, b.Status.Reason)
-			zero := int64(0)
-			foreground := metav1.DeletePropagationForeground
-			opts := metav1.DeleteOptions{
-				GracePeriodSeconds: &zero,
-				Preconditions:      &metav1.Preconditions{UID: &b.UID},
-				PropagationPolicy:  &foreground,
+		// builds from older versions carry no digest and are assumed to match
+		previous, recorded := b.Annotations[BuildInputsDigestAnnotation]
+		switch {
+		case recorded && previous != digest:
+			Logger(ctx).Infof("Build %s was created from different inputs, rebuilding...\n", b.Name)
+			if err := recreateBuild(ctx, buildClient, b, build); err != nil {
+				return err
+			}
+			recordBuilt(ctx, build)
+		case isBuildPhaseTerminated(b.Status.Phase) &&
+			(isInfraReason(b.Status.Reason) || hintsAtInfraReason(b.Status.LogSnippet)) &&
+			!violatesHermeticity(b, b.Status.LogSnippet):
+			Logger(ctx).Infof("Build %s previously failed from an infrastructure error (%s), retrying...\n", b.Name, b.Status.Reason)
+			if err := recreateBuild(ctx, buildClient, b, build); err != nil {
+				return err
 			}
-			if err := buildClient.Delete(ctx, build, &ctrlruntimeclient.DeleteOptions{Raw: &opts}); err != nil && !kerrors.IsNotFound(err) && !kerrors.IsConflict(err) {
-				return fmt.Errorf("could not delete build %s: %w", build.Name, err)
+			recordBuilt(ctx, build)
+		case b.Status.Phase == buildapi.BuildPhaseComplete:
+			exists, err := outputExists(ctx, buildClient, b)
+			if err != nil {
+				return fmt.Errorf("could not determine if the output of build %s exists: %w", b.Name, err)
 			}
-			if err := waitForBuildDeletion(ctx, buildClient, build.Namespace, build.Name); err != nil {
-				return fmt.Errorf("could not wait for build %s to be deleted: %w", build.Name, err)
+			if !exists {
+				Logger(ctx).Infof("Output of build %s no longer exists, rebuilding...\n", b.Name)
+				if err := recreateBuild(ctx, buildClient, b, build); err != nil {
+					return err
+				}
+				recordBuilt(ctx, build)
+			} else {
+				Logger(ctx).Infof("Reusing build %s from a previous run, its inputs are unchanged\n", b.Name)
+				telemetry.RecordCacheHit(ctx, "build")
+				if err := markShared(ctx, buildClient, b); err != nil {
+					return fmt.Errorf("could not mark build %s as shared: %w", b.Name, err)
+				}
 			}
-			if err := buildClient.Create(ctx, build); err != nil && !kerrors.IsAlreadyExists(err) {
-				return fmt.Errorf("could not recreate build %s: %w", build.Name, err)
+		default:
+			// another execution runs the build, whose output it may delete
+			// unless it knows this one relies on it
+			if err := markShared(ctx, buildClient, b); err != nil {
+				return fmt.Errorf("could not mark build %s as shared: %w", b.Name, err)
 			}
 		}
 	}
-	err := waitForBuildOrTimeout(ctx, buildClient, build.Namespace, build.Name)
+	err = waitForBuildOrTimeout(ctx, buildClient, build.Namespace, build.Name)
 	if err == nil {
-		if err := gatherSuccessfulBuildLog(buildClient, build.Namespace, build.Name); err != nil {
+		if err := gatherSuccessfulBuildLog(ctx, buildClient, build.Namespace, build.Name); err != nil {
 			// log error but do not fail successful build
-			log.Printf("problem gathering successful build %s logs into artifacts: %v", build.Name, err)
+			Logger(ctx).Infof("problem gathering successful build %s logs into artifacts: %v", build.Name, err)
 		}
 	}
 	// this will still be the err from waitForBuild
@@ -448,6 +897,45 @@ func hintsAtInfraReason(logSnippet string) bool {
 		strings.Contains(logSnippet, "connection reset by peer")
 }
 
+// hintsAtCloneAuthFailure determines whether git failed to clone the source
+// because the credentials were missing or rejected
+func hintsAtCloneAuthFailure(logSnippet string) bool {
+	return strings.Contains(logSnippet, "could not read Username") ||
+		strings.Contains(logSnippet, "terminal prompts disabled") ||
+		strings.Contains(logSnippet, "Authentication failed for") ||
+		strings.Contains(logSnippet, "Permission denied (publickey)") ||
+		strings.Contains(logSnippet, "HTTP Basic: Access denied")
+}
+
+// classifyBuildFailure determines the failure mode of a failed build from the
+// reason the build reports and its logs
+func classifyBuildFailure(build *buildapi.Build) results.Reason {
+	switch {
+	case cloneDriftLine.MatchString(build.Status.LogSnippet):
+		return results.ReasonCloneDrift
+	case hintsAtCloneAuthFailure(build.Status.LogSnippet):
+		return results.ReasonCloneAuth
+	case build.Status.Phase == buildapi.BuildPhaseCancelled:
+		return results.ReasonCancelled
+	case violatesHermeticity(build, build.Status.LogSnippet):
+		return results.ReasonHermeticViolation
+	}
+	switch build.Status.Reason {
+	case buildapi.StatusReasonFetchSourceFailed:
+		return results.ReasonCloneFailed
+	case buildapi.StatusReasonPullBuilderImageFailed, buildapi.StatusReasonFetchImageContentFailed:
+		return results.ReasonPullFailed
+	case buildapi.StatusReasonPushImageToRegistryFailed:
+		return results.ReasonPushFailed
+	case buildapi.StatusReasonOutOfMemoryKilled:
+		return results.ReasonOutOfMemory
+	}
+	if isInfraReason(build.Status.Reason) || hintsAtInfraReason(build.Status.LogSnippet) {
+		return results.ReasonInfrastructure
+	}
+	return results.ReasonBuildFailed
+}
+
 func waitForBuildOrTimeout(ctx context.Context, buildClient BuildClient, namespace, name string) error {
 	isOK := func(b *buildapi.Build) bool {
 		return b.Status.Phase == buildapi.BuildPhaseComplete
@@ -466,13 +954,13 @@ func waitForBuildOrTimeout(ctx context.Context, buildClient BuildClient, namespa
 		return fmt.Errorf("could not get build: %w", err)
 	}
 	if isOK(build) {
-		log.Printf("Build %s already succeeded in %s", build.Name, buildDuration(build))
+		Logger(ctx).Infof("Build %s already succeeded in %s", build.Name, buildDuration(build))
 		return nil
 	}
 	if isFailed(build) {
-		log.Printf("Build %s failed, printing logs:", build.Name)
-		printBuildLogs(buildClient, build.Namespace, build.Name)
-		return appendLogToError(fmt.Errorf("the build %s failed with reason %s: %s", build.Name, build.Status.Reason, build.Status.Message), build.Status.LogSnippet)
+		Logger(ctx).Infof("Build %s failed, printing logs:", build.Name)
+		printBuildLogs(ctx, buildClient, build.Namespace, build.Name)
+		return results.ForReason(classifyBuildFailure(build)).ForError(appendLogToError(fmt.Errorf("the build %s failed with reason %s: %s", build.Name, build.Status.Reason, build.Status.Message), build.Status.LogSnippet))
 	}
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
@@ -482,17 +970,17 @@ func waitForBuildOrTimeout(ctx context.Context, buildClient BuildClient, namespa
 			return ctx.Err()
 		case <-ticker.C:
 			if err := buildClient.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: name}, build); err != nil {
-				log.Printf("Failed to get build %s: %v", name, err)
+				Logger(ctx).Infof("Failed to get build %s: %v", name, err)
 				continue
 			}
 			if isOK(build) {
-				log.Printf("Build %s succeeded after %s", build.Name, buildDuration(build).Truncate(time.Second))
+				Logger(ctx).Infof("Build %s succeeded after %s", build.Name, buildDuration(build).Truncate(time.Second))
 				return nil
 			}
 			if isFailed(build) {
-				log.Printf("Build %s failed, printing logs:", build.Name)
-				printBuildLogs(buildClient, build.Namespace, build.Name)
-				return appendLogToError(fmt.Errorf("the build %s failed after %s with reason %s: %s", build.Name, buildDuration(build).Truncate(time.Second), build.Status.Reason, build.Status.Message), build.Status.LogSnippet)
+				Logger(ctx).Infof("Build %s failed, printing logs:", build.Name)
+				printBuildLogs(ctx, buildClient, build.Namespace, build.Name)
+				return results.ForReason(classifyBuildFailure(build)).ForError(appendLogToError(fmt.Errorf("the build %s failed after %s with reason %s: %s", build.Name, buildDuration(build).Truncate(time.Second), build.Status.Reason, build.Status.Message), build.Status.LogSnippet))
 			}
 		}
 	}
@@ -519,16 +1007,16 @@ func buildDuration(build *buildapi.Build) time.Duration {
 	return duration
 }
 
-func printBuildLogs(buildClient BuildClient, namespace, name string) {
+func printBuildLogs(ctx context.Context, buildClient BuildClient, namespace, name string) {
 	if s, err := buildClient.Logs(namespace, name, &buildapi.BuildLogOptions{
 		NoWait: true,
 	}); err == nil {
 		defer s.Close()
 		if _, err := io.Copy(os.Stdout, s); err != nil {
-			log.Printf("error: Unable to copy log output from failed build: %v", err)
+			Logger(ctx).Errorf("Unable to copy log output from failed build: %v", err)
 		}
 	} else {
-		log.Printf("error: Unable to retrieve logs from failed build: %v", err)
+		Logger(ctx).Errorf("Unable to retrieve logs from failed build: %v", err)
 	}
 }
 
@@ -582,7 +1070,7 @@ func (s *sourceStep) Objects() []ctrlruntimeclient.Object {
 }
 
 func SourceStep(config api.SourceStepConfiguration, resources api.ResourceConfiguration, buildClient BuildClient,
-	jobSpec *api.JobSpec, cloneAuthConfig *CloneAuthConfig, pullSecret *corev1.Secret) api.Step {
+	jobSpec *api.JobSpec, cloneAuthConfig *CloneAuthConfig, pullSecret *corev1.Secret, podClient PodClient, clonerefs *ClonerefsOverride) api.Step {
 	return &sourceStep{
 		config:          config,
 		resources:       resources,
@@ -590,11 +1078,22 @@ func SourceStep(config api.SourceStepConfiguration, resources api.ResourceConfig
 		jobSpec:         jobSpec,
 		cloneAuthConfig: cloneAuthConfig,
 		pullSecret:      pullSecret,
+		podClient:       podClient,
+		clonerefs:       clonerefs,
 	}
 }
 
 // trimLabels ensures that all label values are less than 64 characters
 // in length and thus valid.
+// refsLabelValue makes the org or repo of refs valid as a label value: the
+// org of Gerrit refs is the URL of the instance and projects may be nested
+func refsLabelValue(value string) string {
+	if parts := strings.SplitN(value, "://", 2); len(parts) == 2 {
+		value = parts[1]
+	}
+	return strings.NewReplacer("/", "_", ":", "_").Replace(strings.Trim(value, "/"))
+}
+
 func trimLabels(labels map[string]string) map[string]string {
 	for k, v := range labels {
 		if len(v) > 63 {
@@ -647,7 +1146,7 @@ func getEventsForPod(ctx context.Context, pod *corev1.Pod, client ctrlruntimecli
 		FieldSelector: fields.OneTermEqualSelector("involvedObject.uid", string(pod.GetUID())),
 	}
 	if err := client.List(ctx, events, listOpts); err != nil {
-		log.Printf("Could not fetch events: %v", err)
+		Logger(ctx).Infof("Could not fetch events: %v", err)
 		return ""
 	}
 	builder := &strings.Builder{}
@@ -658,12 +1157,81 @@ func getEventsForPod(ctx context.Context, pod *corev1.Pod, client ctrlruntimecli
 	return builder.String()
 }
 
-func addLabelsToBuild(refs *prowv1.Refs, build *buildapi.Build, contextDir string) {
+const (
+	// BaseSHALabel is the base commit pull requests were merged into
+	BaseSHALabel = "io.openshift.ci.base-sha"
+	// PullsLabel lists the pull requests merged into the source of an
+	// image with their commits, like 123:abc,456:def
+	PullsLabel = "io.openshift.ci.pulls"
+	// GerritChangesLabel lists the Gerrit changes merged into the source of
+	// an image with their patchsets, like 1234/5,1235/1
+	GerritChangesLabel = "io.openshift.ci.gerrit-changes"
+)
+
+// sourceLabels describes the source an image was built from, so that it can
+// be reconstructed. Images built from pull requests are labeled with the
+// commit the pulls were merged as when it is known, the base commit and the
+// commits of the pulls, which is everything needed to recreate that merge.
+func sourceLabels(refs *prowv1.Refs, mergeSHA, contextDir string) map[string]string {
+	labels := map[string]string{}
+	if refs == nil {
+		return labels
+	}
+	commit := refs.BaseSHA
+	if len(refs.Pulls) > 0 {
+		commit = mergeSHA
+		var pulls []string
+		for _, pull := range refs.Pulls {
+			pulls = append(pulls, fmt.Sprintf("%d:%s", pull.Number, pull.SHA))
+		}
+		labels[BaseSHALabel] = refs.BaseSHA
+		labels[PullsLabel] = strings.Join(pulls, ",")
+		var changes []string
+		for _, change := range api.GerritChanges(refs) {
+			changes = append(changes, fmt.Sprintf("%d/%d", change.Number, change.Patchset))
+		}
+		if len(changes) > 0 {
+			labels[GerritChangesLabel] = strings.Join(changes, ",")
+		}
+	}
+	labels["vcs-type"] = "git"
+	// the commit is not known for pull requests until the source is built
+	if commit != "" {
+		labels["vcs-ref"] = commit
+		labels["io.openshift.build.commit.id"] = commit
+	}
+	labels["io.openshift.build.commit.ref"] = refs.BaseRef
+	labels["vcs-url"] = api.RepoURL(refs)
+	labels["io.openshift.build.source-location"] = labels["vcs-url"]
+	labels["io.openshift.build.source-context-dir"] = contextDir
+	return labels
+}
+
+// sourceEnv exposes the source an image was built from to the build and in
+// the image itself
+func sourceEnv(refs *prowv1.Refs, mergeSHA string) []corev1.EnvVar {
+	labels := sourceLabels(refs, mergeSHA, "")
+	var env []corev1.EnvVar
+	for _, item := range []struct{ name, label string }{
+		{name: "SOURCE_GIT_URL", label: "vcs-url"},
+		{name: "SOURCE_GIT_COMMIT", label: "io.openshift.build.commit.id"},
+		{name: "SOURCE_GIT_REF", label: "io.openshift.build.commit.ref"},
+		{name: "SOURCE_GIT_BASE_COMMIT", label: BaseSHALabel},
+		{name: "SOURCE_GIT_PULLS", label: PullsLabel},
+		{name: "SOURCE_GERRIT_CHANGES", label: GerritChangesLabel},
+	} {
+		if value := labels[item.label]; value != "" {
+			env = append(env, corev1.EnvVar{Name: item.name, Value: value})
+		}
+	}
+	return env
+}
+
+func addLabelsToBuild(refs *prowv1.Refs, mergeSHA string, build *buildapi.Build, contextDir string) {
 	labels := make(map[string]string)
 	// reset all labels that may be set by a lower level
 	for _, key := range []string{
 		"vcs-type",
-		"vcs-ref",
 		"vcs-url",
 		"io.openshift.build.name",
 		"io.openshift.build.namespace",
@@ -674,21 +1242,13 @@ func addLabelsToBuild(refs *prowv1.Refs, build *buildapi.Build, contextDir strin
 		"io.openshift.build.commit.date",
 		"io.openshift.build.source-location",
 		"io.openshift.build.source-context-dir",
+		BaseSHALabel,
+		PullsLabel,
 	} {
 		labels[key] = ""
 	}
-	if refs != nil {
-		if len(refs.Pulls) == 0 {
-			labels["vcs-type"] = "git"
-			labels["vcs-ref"] = refs.BaseSHA
-			labels["io.openshift.build.commit.id"] = refs.BaseSHA
-			labels["io.openshift.build.commit.ref"] = refs.BaseRef
-			labels["vcs-url"] = fmt.Sprintf("https://github.com/%s/%s", refs.Org, refs.Repo)
-			labels["io.openshift.build.source-location"] = labels["vcs-url"]
-			labels["io.openshift.build.source-context-dir"] = contextDir
-		}
-		// TODO: we should consider setting enough info for a caller to reconstruct pulls to support
-		// oc adm release info tooling
+	for k, v := range sourceLabels(refs, mergeSHA, contextDir) {
+		labels[k] = v
 	}
 
 	for k, v := range labels {
@@ -1,7 +1,10 @@
 package steps
 
 import (
+	"context"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 
 	coreapi "k8s.io/api/core/v1"
@@ -9,8 +12,13 @@ import (
 	"k8s.io/apimachinery/pkg/util/diff"
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	buildapi "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/results"
 	"github.com/openshift/ci-tools/pkg/testhelper"
 )
 
@@ -322,6 +330,28 @@ func TestDefaultPodLabels(t *testing.T) {
 				"ci.openshift.io/refs.branch": "master",
 			},
 		},
+		{
+			id: "Gerrit refs, expected the instance and project as valid label values",
+			jobSpec: &api.JobSpec{
+				JobSpec: downwardapi.JobSpec{
+					Refs: &prowapi.Refs{
+						Org:     "https://review.example.com",
+						Repo:    "platform/build",
+						BaseRef: "main",
+					},
+				},
+			},
+			expectedLabels: map[string]string{
+				"OPENSHIFT_CI":                "true",
+				"created-by-ci":               "true",
+				"prow.k8s.io/id":              "",
+				"build-id":                    "",
+				"job":                         "",
+				"ci.openshift.io/refs.org":    "review.example.com",
+				"ci.openshift.io/refs.repo":   "platform_build",
+				"ci.openshift.io/refs.branch": "main",
+			},
+		},
 		{
 			id: "nil Refs, expected labels without org/repo/branch information",
 			jobSpec: &api.JobSpec{
@@ -402,3 +432,336 @@ func TestDefaultPodLabels(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildInputsDigest(t *testing.T) {
+	newBuild := func(dockerfile string) *buildapi.Build {
+		return &buildapi.Build{
+			ObjectMeta: meta.ObjectMeta{
+				Name:        "src",
+				Namespace:   "ns",
+				Annotations: map[string]string{JobSpecAnnotation: "spec"},
+			},
+			Spec: buildapi.BuildSpec{CommonSpec: buildapi.CommonSpec{
+				Source: buildapi.BuildSource{Dockerfile: &dockerfile},
+				Strategy: buildapi.BuildStrategy{DockerStrategy: &buildapi.DockerBuildStrategy{
+					From: &coreapi.ObjectReference{Kind: "ImageStreamTag", Name: "pipeline:root"},
+				}},
+			}},
+		}
+	}
+	root := func(image string) *imagev1.ImageStreamTag {
+		return &imagev1.ImageStreamTag{
+			ObjectMeta: meta.ObjectMeta{Name: "pipeline:root", Namespace: "ns"},
+			Image:      imagev1.Image{ObjectMeta: meta.ObjectMeta{Name: image}},
+		}
+	}
+	digest := func(build *buildapi.Build, ist *imagev1.ImageStreamTag) string {
+		d, err := buildInputsDigest(context.Background(), fakectrlruntimeclient.NewFakeClient(ist), build)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return d
+	}
+	base := digest(newBuild("FROM root"), root("sha256:1"))
+
+	otherSpec := newBuild("FROM root")
+	otherSpec.Annotations[JobSpecAnnotation] = "other"
+	if d := digest(otherSpec, root("sha256:1")); d != base {
+		t.Errorf("expected the job spec not to affect the digest")
+	}
+	if d := digest(newBuild("FROM root\nRUN true"), root("sha256:1")); d == base {
+		t.Errorf("expected a different build source to change the digest")
+	}
+	if d := digest(newBuild("FROM root"), root("sha256:2")); d == base {
+		t.Errorf("expected a different base image to change the digest")
+	}
+}
+
+func TestPinRefs(t *testing.T) {
+	original := resolveBaseSHA
+	defer func() { resolveBaseSHA = original }()
+	auth := &CloneAuthConfig{Type: CloneAuthTypeOAuth, Secret: &coreapi.Secret{}}
+	resolveBaseSHA = func(_ context.Context, ref prowapi.Refs, cloneAuthConfig *CloneAuthConfig) (string, error) {
+		if ref.Repo == "missing" {
+			return "", errors.New("not found")
+		}
+		if cloneAuthConfig != auth {
+			t.Errorf("expected the refs to be resolved with the clone credentials")
+		}
+		return api.CloneURI(&ref) + "@" + ref.BaseRef, nil
+	}
+	jobSpec := &api.JobSpec{JobSpec: downwardapi.JobSpec{
+		Refs: &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "base"},
+		ExtraRefs: []prowapi.Refs{
+			{Org: "org", Repo: "other", BaseRef: "release"},
+		},
+	}}
+	pinned, err := pinRefs(context.Background(), jobSpec, auth)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []prowapi.Refs{
+		{Org: "org", Repo: "other", BaseRef: "release", BaseSHA: "https://github.com/org/other.git@release"},
+	}
+	if !reflect.DeepEqual(pinned.ExtraRefs, expected) {
+		t.Errorf("unexpected extra refs: %s", diff.ObjectReflectDiff(expected, pinned.ExtraRefs))
+	}
+	if pinned.Refs.BaseSHA != "base" {
+		t.Errorf("refs with a SHA were changed to %s", pinned.Refs.BaseSHA)
+	}
+	if jobSpec.ExtraRefs[0].BaseSHA != "" {
+		t.Error("the refs of the job were changed")
+	}
+	jobSpec.ExtraRefs = append(jobSpec.ExtraRefs, prowapi.Refs{Org: "org", Repo: "missing", BaseRef: "master"})
+	if _, err := pinRefs(context.Background(), jobSpec, auth); err == nil {
+		t.Error("expected refs which cannot be resolved to fail")
+	}
+}
+
+func TestVerifyClonedRefsCommand(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		refs     []prowapi.Refs
+		expected string
+	}{
+		{
+			name: "no refs",
+		},
+		{
+			name:     "postsubmit must be at the base SHA",
+			refs:     []prowapi.Refs{{Org: "org", Repo: "repo", BaseSHA: "base"}},
+			expected: `[ "$(git -C /go/src/github.com/org/repo rev-parse HEAD)" = base ] || { echo "ci-operator-clone-drift org/repo base" >&2; exit 1; }`,
+		},
+		{
+			name:     "periodic without SHAs is not verified",
+			refs:     []prowapi.Refs{{Org: "org", Repo: "repo", BaseRef: "master"}},
+			expected: "",
+		},
+		{
+			name:     "presubmit must contain the base and pull SHAs",
+			refs:     []prowapi.Refs{{Org: "org", Repo: "repo", PathAlias: "example.com/repo", BaseSHA: "base", Pulls: []prowapi.Pull{{SHA: "pull"}}}},
+			expected: `git -C /go/src/example.com/repo merge-base --is-ancestor base HEAD || { echo "ci-operator-clone-drift org/repo base" >&2; exit 1; } && git -C /go/src/example.com/repo merge-base --is-ancestor pull HEAD || { echo "ci-operator-clone-drift org/repo pull" >&2; exit 1; }`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := verifyClonedRefsCommand(tc.refs); actual != tc.expected {
+				t.Errorf("expected\n%s\ngot\n%s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSourceLabelsAndEnv(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		refs           *prowapi.Refs
+		mergeSHA       string
+		expectedLabels map[string]string
+		expectedEnv    []coreapi.EnvVar
+	}{
+		{
+			name:           "no refs",
+			expectedLabels: map[string]string{},
+		},
+		{
+			name: "postsubmit",
+			refs: &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "base"},
+			expectedLabels: map[string]string{
+				"vcs-type":                              "git",
+				"vcs-ref":                               "base",
+				"vcs-url":                               "https://github.com/org/repo",
+				"io.openshift.build.commit.id":          "base",
+				"io.openshift.build.commit.ref":         "master",
+				"io.openshift.build.source-location":    "https://github.com/org/repo",
+				"io.openshift.build.source-context-dir": "images/operator",
+			},
+			expectedEnv: []coreapi.EnvVar{
+				{Name: "SOURCE_GIT_URL", Value: "https://github.com/org/repo"},
+				{Name: "SOURCE_GIT_COMMIT", Value: "base"},
+				{Name: "SOURCE_GIT_REF", Value: "master"},
+			},
+		},
+		{
+			name:     "presubmit with multiple pulls",
+			refs:     &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "base", Pulls: []prowapi.Pull{{Number: 1, SHA: "first"}, {Number: 2, SHA: "second"}}},
+			mergeSHA: "merged",
+			expectedLabels: map[string]string{
+				"vcs-type":                              "git",
+				"vcs-ref":                               "merged",
+				"vcs-url":                               "https://github.com/org/repo",
+				"io.openshift.build.commit.id":          "merged",
+				"io.openshift.build.commit.ref":         "master",
+				"io.openshift.build.source-location":    "https://github.com/org/repo",
+				"io.openshift.build.source-context-dir": "images/operator",
+				"io.openshift.ci.base-sha":              "base",
+				"io.openshift.ci.pulls":                 "1:first,2:second",
+			},
+			expectedEnv: []coreapi.EnvVar{
+				{Name: "SOURCE_GIT_URL", Value: "https://github.com/org/repo"},
+				{Name: "SOURCE_GIT_COMMIT", Value: "merged"},
+				{Name: "SOURCE_GIT_REF", Value: "master"},
+				{Name: "SOURCE_GIT_BASE_COMMIT", Value: "base"},
+				{Name: "SOURCE_GIT_PULLS", Value: "1:first,2:second"},
+			},
+		},
+		{
+			name: "presubmit before the merge commit is known",
+			refs: &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "base", Pulls: []prowapi.Pull{{Number: 1, SHA: "first"}}},
+			expectedLabels: map[string]string{
+				"vcs-type":                              "git",
+				"vcs-url":                               "https://github.com/org/repo",
+				"io.openshift.build.commit.ref":         "master",
+				"io.openshift.build.source-location":    "https://github.com/org/repo",
+				"io.openshift.build.source-context-dir": "images/operator",
+				"io.openshift.ci.base-sha":              "base",
+				"io.openshift.ci.pulls":                 "1:first",
+			},
+			expectedEnv: []coreapi.EnvVar{
+				{Name: "SOURCE_GIT_URL", Value: "https://github.com/org/repo"},
+				{Name: "SOURCE_GIT_REF", Value: "master"},
+				{Name: "SOURCE_GIT_BASE_COMMIT", Value: "base"},
+				{Name: "SOURCE_GIT_PULLS", Value: "1:first"},
+			},
+		},
+		{
+			name: "Gerrit change",
+			refs: &prowapi.Refs{
+				Org: "https://review.example.com", Repo: "platform/build", RepoLink: "https://example.com/platform/build", BaseRef: "main", BaseSHA: "base",
+				Pulls: []prowapi.Pull{{Number: 1234, SHA: "first", Ref: "refs/changes/34/1234/5"}},
+			},
+			mergeSHA: "merged",
+			expectedLabels: map[string]string{
+				"vcs-type":                              "git",
+				"vcs-ref":                               "merged",
+				"vcs-url":                               "https://example.com/platform/build",
+				"io.openshift.build.commit.id":          "merged",
+				"io.openshift.build.commit.ref":         "main",
+				"io.openshift.build.source-location":    "https://example.com/platform/build",
+				"io.openshift.build.source-context-dir": "images/operator",
+				"io.openshift.ci.base-sha":              "base",
+				"io.openshift.ci.pulls":                 "1234:first",
+				"io.openshift.ci.gerrit-changes":        "1234/5",
+			},
+			expectedEnv: []coreapi.EnvVar{
+				{Name: "SOURCE_GIT_URL", Value: "https://example.com/platform/build"},
+				{Name: "SOURCE_GIT_COMMIT", Value: "merged"},
+				{Name: "SOURCE_GIT_REF", Value: "main"},
+				{Name: "SOURCE_GIT_BASE_COMMIT", Value: "base"},
+				{Name: "SOURCE_GIT_PULLS", Value: "1234:first"},
+				{Name: "SOURCE_GERRIT_CHANGES", Value: "1234/5"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := sourceLabels(tc.refs, tc.mergeSHA, "images/operator"); !reflect.DeepEqual(actual, tc.expectedLabels) {
+				t.Errorf("unexpected labels: %s", diff.ObjectReflectDiff(tc.expectedLabels, actual))
+			}
+			if actual := sourceEnv(tc.refs, tc.mergeSHA); !reflect.DeepEqual(actual, tc.expectedEnv) {
+				t.Errorf("unexpected env: %s", diff.ObjectReflectDiff(tc.expectedEnv, actual))
+			}
+		})
+	}
+}
+
+func TestParseMergeSHA(t *testing.T) {
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	for _, tc := range []struct {
+		name        string
+		log         string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "merge commit is recorded",
+			log:      "STEP 5/8: RUN echo \"ci-operator-merge-sha $(git -C /go/src/github.com/org/repo rev-parse HEAD)\"\nci-operator-merge-sha " + sha + "\nSTEP 6/8: WORKDIR /go/src/github.com/org/repo/\n",
+			expected: sha,
+		},
+		{
+			name:        "merge commit is missing",
+			log:         "STEP 5/8: RUN echo \"ci-operator-merge-sha $(git -C /go/src/github.com/org/repo rev-parse HEAD)\"\n",
+			expectedErr: "the merge commit was not recorded",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parseMergeSHA(strings.NewReader(tc.log))
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if actualErr != tc.expectedErr {
+				t.Fatalf("expected error %q, got %q", tc.expectedErr, actualErr)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestClassifyBuildFailure(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		status      buildapi.BuildStatus
+		expected    string
+	}{
+		{
+			name:     "clone drift is detected from the log",
+			status:   buildapi.BuildStatus{Phase: buildapi.BuildPhaseFailed, Reason: buildapi.StatusReasonGenericBuildFailed, LogSnippet: "fatal: bad object\n" + cloneDriftMarker + " org/repo abc"},
+			expected: "cloning_source:clone_drift",
+		},
+		{
+			name:     "rejected credentials fail the clone",
+			status:   buildapi.BuildStatus{Phase: buildapi.BuildPhaseFailed, Reason: buildapi.StatusReasonGenericBuildFailed, LogSnippet: "fatal: could not read Username for 'https://github.com': terminal prompts disabled"},
+			expected: "cloning_source:clone_auth",
+		},
+		{
+			name:     "source could not be fetched",
+			status:   buildapi.BuildStatus{Phase: buildapi.BuildPhaseFailed, Reason: buildapi.StatusReasonFetchSourceFailed},
+			expected: "cloning_source:clone_failed",
+		},
+		{
+			name:     "output could not be pushed",
+			status:   buildapi.BuildStatus{Phase: buildapi.BuildPhaseFailed, Reason: buildapi.StatusReasonPushImageToRegistryFailed},
+			expected: "cloning_source:push_failed",
+		},
+		{
+			name:     "build was evicted",
+			status:   buildapi.BuildStatus{Phase: buildapi.BuildPhaseError, Reason: buildapi.StatusReason("BuildPodEvicted")},
+			expected: "cloning_source:infrastructure",
+		},
+		{
+			name:     "build was cancelled",
+			status:   buildapi.BuildStatus{Phase: buildapi.BuildPhaseCancelled},
+			expected: "cloning_source:cancelled",
+		},
+		{
+			name:     "Dockerfile failed",
+			status:   buildapi.BuildStatus{Phase: buildapi.BuildPhaseFailed, Reason: buildapi.StatusReasonDockerBuildFailed, LogSnippet: "error: make: *** [build] Error 2"},
+			expected: "cloning_source:build_failed",
+		},
+		{
+			name:     "network failure of a build is infrastructure",
+			status:   buildapi.BuildStatus{Phase: buildapi.BuildPhaseFailed, Reason: buildapi.StatusReasonDockerBuildFailed, LogSnippet: "curl: (6) Could not resolve host: example.com"},
+			expected: "cloning_source:infrastructure",
+		},
+		{
+			name:        "network access of a hermetic build violates hermeticity",
+			annotations: map[string]string{HermeticBuildAnnotation: ""},
+			status:      buildapi.BuildStatus{Phase: buildapi.BuildPhaseFailed, Reason: buildapi.StatusReasonDockerBuildFailed, LogSnippet: "curl: (6) Could not resolve host: example.com"},
+			expected:    "cloning_source:hermetic_violation",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			build := &buildapi.Build{ObjectMeta: meta.ObjectMeta{Annotations: tc.annotations}, Status: tc.status}
+			err := results.ForReason(results.ReasonCloningSource).ForError(results.ForReason(classifyBuildFailure(build)).ForError(errors.New("failed")))
+			actual := results.FullReason(err)
+			if actual != tc.expected {
+				t.Errorf("expected reason %q, got %q", tc.expected, actual)
+			}
+			if err := results.ValidateReason(actual); err != nil {
+				t.Errorf("reason does not follow the taxonomy: %v", err)
+			}
+		})
+	}
+}
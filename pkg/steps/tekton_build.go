@@ -0,0 +1,564 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tektonapi "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"knative.dev/pkg/apis"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildapi "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/steps/utils"
+	"github.com/openshift/ci-tools/pkg/telemetry"
+)
+
+// BuildBackend determines what image builds are executed as
+type BuildBackend string
+
+const (
+	// BuildBackendOpenShift executes image builds as OpenShift Builds
+	BuildBackendOpenShift BuildBackend = "openshift"
+	// BuildBackendTekton executes image builds as Tekton PipelineRuns
+	// running buildah
+	BuildBackendTekton BuildBackend = "tekton"
+)
+
+// ParseBuildBackend validates the name of a build backend
+func ParseBuildBackend(name string) (BuildBackend, error) {
+	switch backend := BuildBackend(name); backend {
+	case BuildBackendOpenShift, BuildBackendTekton:
+		return backend, nil
+	}
+	return "", fmt.Errorf("unknown build backend %q, expected %q or %q", name, BuildBackendOpenShift, BuildBackendTekton)
+}
+
+// tektonBuildahImage is where buildah is mirrored to on the build clusters;
+// the tag is resolved to a digest for every build, so that the pipeline run
+// records which buildah ran and a build which is retried runs the same one
+var tektonBuildahImage = api.ImageStreamTagReference{Namespace: "ci", Name: "buildah", Tag: "stable"}
+
+const (
+	tektonWorkspace   = "/workspace"
+	tektonSourceDir   = tektonWorkspace + "/source"
+	tektonAuthFile    = tektonWorkspace + "/auth.json"
+	tektonCertDir     = tektonWorkspace + "/certs"
+	tektonPullSecret  = "/var/run/pull-secret"
+	tektonCloneSecret = "/var/run/clone-secret"
+)
+
+// tektonPullSecretScript merges the pull secret, when one is set, into the
+// configuration authenticating to the registry of the cluster
+const tektonPullSecretScript = `if [[ -f ` + tektonPullSecret + `/.dockerconfigjson ]]; then
+  jq -s '.[0] * .[1]' ` + tektonPullSecret + `/.dockerconfigjson ` + tektonAuthFile + ` > ` + tektonAuthFile + `.merged
+  mv ` + tektonAuthFile + `.merged ` + tektonAuthFile + `
+fi
+mkdir -p ` + tektonCertDir + `
+cp /var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt ` + tektonCertDir + `/service-ca.crt`
+
+// tektonCloneAuthScript configures git to authenticate with the clone secret
+const tektonCloneAuthScript = `if [[ -f ` + tektonCloneSecret + `/` + coreapi.SSHAuthPrivateKey + ` ]]; then
+  export GIT_SSH_COMMAND="ssh -i ` + tektonCloneSecret + `/` + coreapi.SSHAuthPrivateKey + ` -o StrictHostKeyChecking=no"
+fi
+if [[ -f ` + tektonCloneSecret + `/` + OauthSecretKey + ` ]]; then
+  git config --global credential.helper '!f() { echo username=oauth2; echo "password=$(cat ` + tektonCloneSecret + `/` + OauthSecretKey + `)"; }; f'
+fi`
+
+// tektonRewriteScript does what the OpenShift build strategy does to the
+// Dockerfile: the last FROM is replaced with the image the build is from,
+// FROMs of aliased images with the images and the environment of the build
+// is set after the last FROM
+const tektonRewriteScript = `awk -v from="${FROM_IMAGE}" -v aliases="${IMAGE_ALIASES}" -v env="${BUILD_ENV}" '
+  BEGIN {
+    n = split(aliases, pairs, "\n")
+    for (i = 1; i <= n; i++) {
+      eq = index(pairs[i], "=")
+      if (eq > 0) image[substr(pairs[i], 1, eq - 1)] = substr(pairs[i], eq + 1)
+    }
+  }
+  { line[NR] = $0; if (toupper($1) == "FROM") last = NR }
+  END {
+    for (i = 1; i <= NR; i++) {
+      $0 = line[i]
+      if (toupper($1) == "FROM") {
+        if (i == last && from != "") { $2 = from } else if ($2 in image) { $2 = image[$2] }
+        line[i] = $0
+      }
+      print line[i]
+      if (i == last && env != "") print env
+    }
+  }' "${DOCKERFILE_PATH}" > "${DOCKERFILE_PATH}.rewritten"
+mv "${DOCKERFILE_PATH}.rewritten" "${DOCKERFILE_PATH}"`
+
+// tektonBuildClient marks the build client of a job which executes builds as
+// Tekton PipelineRuns; the pod client reads the logs of the runs
+type tektonBuildClient struct {
+	BuildClient
+	pods PodClient
+}
+
+// NewTektonBuildClient executes the builds created through the client as
+// Tekton PipelineRuns. Builds are still described as OpenShift Builds and
+// translated, so every build step works with either backend.
+func NewTektonBuildClient(client BuildClient, pods PodClient) BuildClient {
+	return &tektonBuildClient{BuildClient: client, pods: pods}
+}
+
+// handleTektonBuild runs the build as a PipelineRun of the same name. Runs
+// are reused and retried the way builds are.
+func handleTektonBuild(ctx context.Context, tekton *tektonBuildClient, build *buildapi.Build) error {
+	client := tekton.BuildClient
+	Logger(ctx).Infof("Building %s with Tekton", build.Name)
+	digest, err := buildInputsDigest(ctx, client, build)
+	if err != nil {
+		return err
+	}
+	resolved, err := resolveTektonImages(ctx, client, build)
+	if err != nil {
+		return err
+	}
+	if err := isolateHermeticBuild(ctx, client, build, hermeticPipelineRunPodLabel); err != nil {
+		return err
+	}
+	run := pipelineRunForBuild(build, resolved)
+	run.Annotations[BuildInputsDigestAnnotation] = digest
+	if err := client.Create(ctx, run); err != nil {
+		if !kerrors.IsAlreadyExists(err) {
+			return fmt.Errorf("could not create pipeline run %s: %w", run.Name, err)
+		}
+		existing := &tektonapi.PipelineRun{}
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: run.Namespace, Name: run.Name}, existing); err != nil {
+			return fmt.Errorf("could not get pipeline run %s: %w", run.Name, err)
+		}
+		condition := existing.Status.GetCondition(apis.ConditionSucceeded)
+		switch {
+		case existing.Annotations[BuildInputsDigestAnnotation] != digest:
+			Logger(ctx).Infof("Pipeline run %s was created from different inputs, rebuilding...", run.Name)
+			if err := recreatePipelineRun(ctx, client, existing, run); err != nil {
+				return err
+			}
+		case condition != nil && condition.IsFalse() && classifyPipelineRunFailure(existing, condition) == results.ReasonInfrastructure:
+			Logger(ctx).Infof("Pipeline run %s previously failed from an infrastructure error (%s), retrying...", run.Name, condition.Reason)
+			if err := recreatePipelineRun(ctx, client, existing, run); err != nil {
+				return err
+			}
+		case condition != nil && condition.IsTrue():
+			exists, err := outputExists(ctx, client, build)
+			if err != nil {
+				return fmt.Errorf("could not determine if the output of pipeline run %s exists: %w", run.Name, err)
+			}
+			if !exists {
+				Logger(ctx).Infof("Output of pipeline run %s no longer exists, rebuilding...", run.Name)
+				if err := recreatePipelineRun(ctx, client, existing, run); err != nil {
+					return err
+				}
+			} else {
+				Logger(ctx).Infof("Reusing pipeline run %s from a previous run, its inputs are unchanged", run.Name)
+				telemetry.RecordCacheHit(ctx, "build")
+			}
+		}
+	}
+	if err := waitForPipelineRun(ctx, client, run.Namespace, run.Name); err != nil {
+		if ctx.Err() == nil {
+			printPipelineRunLogs(ctx, client, tekton.pods, run.Namespace, run.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// printPipelineRunLogs prints the logs of the steps of every task run of the
+// pipeline run, like the logs of failed builds are printed
+func printPipelineRunLogs(ctx context.Context, client ctrlruntimeclient.Client, pods PodClient, namespace, name string) {
+	run := &tektonapi.PipelineRun{}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: name}, run); err != nil {
+		Logger(ctx).Errorf("Unable to retrieve logs from failed pipeline run: %v", err)
+		return
+	}
+	for _, taskRun := range sets.StringKeySet(run.Status.TaskRuns).List() {
+		status := run.Status.TaskRuns[taskRun].Status
+		if status == nil || status.PodName == "" {
+			continue
+		}
+		for _, step := range status.Steps {
+			s, err := pods.GetLogs(namespace, status.PodName, &coreapi.PodLogOptions{Container: step.ContainerName}).Stream(ctx)
+			if err != nil {
+				Logger(ctx).Errorf("Unable to retrieve logs of step %s from failed pipeline run: %v", step.Name, err)
+				continue
+			}
+			if _, err := io.Copy(os.Stdout, s); err != nil {
+				Logger(ctx).Errorf("Unable to copy log output of step %s from failed pipeline run: %v", step.Name, err)
+			}
+			s.Close()
+		}
+	}
+}
+
+// tektonImages holds the pull specs the image references of a build resolve
+// to, keyed by the name of the reference, the repository the output of the
+// build is pushed to and the pull spec of buildah
+type tektonImages struct {
+	refs    map[string]string
+	output  string
+	buildah string
+}
+
+// resolveTektonImages resolves the image stream tags the build refers to, as
+// buildah pulls and pushes images directly from and to the registry
+func resolveTektonImages(ctx context.Context, client ctrlruntimeclient.Client, build *buildapi.Build) (tektonImages, error) {
+	resolved := tektonImages{refs: map[string]string{}}
+	var refs []coreapi.ObjectReference
+	if strategy := build.Spec.Strategy.DockerStrategy; strategy != nil && strategy.From != nil {
+		refs = append(refs, *strategy.From)
+	}
+	for _, image := range build.Spec.Source.Images {
+		refs = append(refs, image.From)
+	}
+	for _, ref := range refs {
+		switch ref.Kind {
+		case "DockerImage":
+			resolved.refs[ref.Name] = ref.Name
+		case "ImageStreamTag":
+			namespace := ref.Namespace
+			if namespace == "" {
+				namespace = build.Namespace
+			}
+			ist := &imagev1.ImageStreamTag{}
+			if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: ref.Name}, ist); err != nil {
+				return resolved, fmt.Errorf("could not resolve build input %s/%s: %w", namespace, ref.Name, err)
+			}
+			resolved.refs[ref.Name] = ist.Image.DockerImageReference
+		default:
+			return resolved, fmt.Errorf("cannot build from %s %s with Tekton", ref.Kind, ref.Name)
+		}
+	}
+	buildah := &imagev1.ImageStream{}
+	buildahTag := fmt.Sprintf("%s/%s:%s", tektonBuildahImage.Namespace, tektonBuildahImage.Name, tektonBuildahImage.Tag)
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: tektonBuildahImage.Namespace, Name: tektonBuildahImage.Name}, buildah); err != nil {
+		return resolved, fmt.Errorf("could not resolve buildah image %s: %w", buildahTag, err)
+	}
+	_, digest := utils.FindStatusTag(buildah, tektonBuildahImage.Tag)
+	if digest == "" || buildah.Status.DockerImageRepository == "" {
+		return resolved, fmt.Errorf("buildah image %s has not been mirrored into the registry", buildahTag)
+	}
+	resolved.buildah = fmt.Sprintf("%s@%s", buildah.Status.DockerImageRepository, digest)
+	if to := build.Spec.Output.To; to != nil {
+		if to.Kind != "ImageStreamTag" {
+			return resolved, fmt.Errorf("cannot push to %s %s with Tekton", to.Kind, to.Name)
+		}
+		namespace := to.Namespace
+		if namespace == "" {
+			namespace = build.Namespace
+		}
+		parts := strings.SplitN(to.Name, ":", 2)
+		if len(parts) != 2 {
+			return resolved, fmt.Errorf("invalid output image stream tag %s", to.Name)
+		}
+		stream := &imagev1.ImageStream{}
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: parts[0]}, stream); err != nil {
+			return resolved, fmt.Errorf("could not get %s image stream: %w", parts[0], err)
+		}
+		if stream.Status.DockerImageRepository == "" {
+			return resolved, fmt.Errorf("the %s image stream has no repository in the registry", parts[0])
+		}
+		resolved.output = fmt.Sprintf("%s:%s", stream.Status.DockerImageRepository, parts[1])
+	}
+	return resolved, nil
+}
+
+// pipelineRunForBuild translates the build into a PipelineRun with one task:
+// the build context is prepared the way the OpenShift build strategy does it
+// and built and pushed with buildah
+func pipelineRunForBuild(build *buildapi.Build, images tektonImages) *tektonapi.PipelineRun {
+	source := build.Spec.Source
+	strategy := build.Spec.Strategy.DockerStrategy
+	if strategy == nil {
+		strategy = &buildapi.DockerBuildStrategy{}
+	}
+	contextDir := path.Join(tektonSourceDir, source.ContextDir)
+	dockerfile := path.Join(contextDir, "Dockerfile")
+	if source.Dockerfile == nil && strategy.DockerfilePath != "" {
+		dockerfile = path.Join(contextDir, strategy.DockerfilePath)
+	}
+	registry := strings.SplitN(images.output, "/", 2)[0]
+
+	volumes := []coreapi.Volume{{Name: "workspace", VolumeSource: coreapi.VolumeSource{EmptyDir: &coreapi.EmptyDirVolumeSource{}}}}
+	mounts := []coreapi.VolumeMount{{Name: "workspace", MountPath: tektonWorkspace}}
+	mountSecret := func(volume, secret, mountPath string) {
+		volumes = append(volumes, coreapi.Volume{Name: volume, VolumeSource: coreapi.VolumeSource{Secret: &coreapi.SecretVolumeSource{SecretName: secret}}})
+		mounts = append(mounts, coreapi.VolumeMount{Name: volume, MountPath: mountPath, ReadOnly: true})
+	}
+	if strategy.PullSecret != nil {
+		mountSecret("pull-secret", strategy.PullSecret.Name, tektonPullSecret)
+	}
+
+	prepare := []string{"set -euo pipefail", registryAuthScript(tektonAuthFile, registry), tektonPullSecretScript}
+	if source.Git != nil {
+		if source.SourceSecret != nil {
+			mountSecret("clone-secret", source.SourceSecret.Name, tektonCloneSecret)
+			prepare = append(prepare, tektonCloneAuthScript)
+		}
+		prepare = append(prepare, fmt.Sprintf("git clone %s %s", shellQuote(source.Git.URI), tektonSourceDir))
+		if source.Git.Ref != "" {
+			prepare = append(prepare, fmt.Sprintf("git -C %s checkout %s", tektonSourceDir, shellQuote(source.Git.Ref)))
+		}
+	}
+	prepare = append(prepare, fmt.Sprintf("mkdir -p %s", contextDir))
+	if source.Dockerfile != nil {
+		prepare = append(prepare, `printf '%s\n' "${DOCKERFILE}" > "${DOCKERFILE_PATH}"`)
+	}
+	var aliases []string
+	for _, image := range source.Images {
+		ref := images.refs[image.From.Name]
+		for _, as := range image.As {
+			aliases = append(aliases, fmt.Sprintf("%s=%s", as, ref))
+		}
+		for _, sourcePath := range image.Paths {
+			destination := path.Join(contextDir, sourcePath.DestinationDir)
+			if destination != contextDir {
+				prepare = append(prepare, fmt.Sprintf("mkdir -p %s", shellQuote(destination)))
+			}
+			prepare = append(prepare, fmt.Sprintf("oc image extract --registry-config %s --confirm %s --path %s", tektonAuthFile, shellQuote(ref), shellQuote(imageExtractPath(sourcePath.SourcePath, destination))))
+		}
+	}
+	for i, secret := range source.Secrets {
+		mountPath := fmt.Sprintf("/var/run/build-secrets/%d", i)
+		mountSecret(fmt.Sprintf("build-secret-%d", i), secret.Secret.Name, mountPath)
+		destination := path.Join(contextDir, secret.DestinationDir)
+		prepare = append(prepare, fmt.Sprintf("mkdir -p %s", shellQuote(destination)), fmt.Sprintf("cp -L %s/* %s/", mountPath, shellQuote(destination)))
+	}
+	prepare = append(prepare, tektonRewriteScript)
+
+	var from string
+	if strategy.From != nil {
+		from = images.refs[strategy.From.Name]
+	}
+	var env []string
+	for _, variable := range strategy.Env {
+		// the log level is only understood by the OpenShift builder
+		if variable.Name == "BUILD_LOGLEVEL" {
+			continue
+		}
+		env = append(env, fmt.Sprintf("ENV %s=%s", variable.Name, strconv.Quote(variable.Value)))
+	}
+	var dockerfileContent string
+	if source.Dockerfile != nil {
+		dockerfileContent = *source.Dockerfile
+	}
+
+	bud := []string{"buildah", "bud", "--storage-driver=vfs", "--authfile", tektonAuthFile, "--cert-dir", tektonCertDir, "--layers=false", "--file", dockerfile}
+	if strategy.NoCache {
+		bud = append(bud, "--no-cache")
+	}
+	if strategy.ForcePull {
+		bud = append(bud, "--pull-always")
+	}
+	// the build itself runs without network, while buildah still pulls
+	// the images the build is from
+	if _, hermetic := hermeticEgress(build); hermetic {
+		bud = append(bud, "--network=none")
+	}
+	for _, arg := range strategy.BuildArgs {
+		bud = append(bud, "--build-arg", shellQuote(fmt.Sprintf("%s=%s", arg.Name, arg.Value)))
+	}
+	labels := append([]buildapi.ImageLabel(nil), build.Spec.Output.ImageLabels...)
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	for _, label := range labels {
+		bud = append(bud, "--label", shellQuote(fmt.Sprintf("%s=%s", label.Name, label.Value)))
+	}
+	buildScript := []string{"set -euo pipefail"}
+	if images.output != "" {
+		bud = append(bud, "--tag", shellQuote(images.output))
+	}
+	buildScript = append(buildScript, strings.Join(append(bud, contextDir), " "))
+	if images.output != "" {
+		buildScript = append(buildScript, fmt.Sprintf("buildah push --storage-driver=vfs --authfile %s --cert-dir %s %s docker://%s", tektonAuthFile, tektonCertDir, shellQuote(images.output), shellQuote(images.output)))
+	}
+
+	run := &tektonapi.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            build.Name,
+			Namespace:       build.Namespace,
+			Labels:          map[string]string{},
+			Annotations:     map[string]string{},
+			OwnerReferences: build.OwnerReferences,
+		},
+		Spec: tektonapi.PipelineRunSpec{
+			// the builder account may pull from and push to the image
+			// streams of the namespace
+			ServiceAccountName: "builder",
+			PipelineSpec: &tektonapi.PipelineSpec{
+				Tasks: []tektonapi.PipelineTask{{
+					Name: "build",
+					TaskSpec: &tektonapi.TaskSpec{
+						Volumes: volumes,
+						StepTemplate: &coreapi.Container{
+							Env: []coreapi.EnvVar{
+								{Name: "DOCKERFILE_PATH", Value: dockerfile},
+							},
+							VolumeMounts: mounts,
+						},
+						Steps: []tektonapi.Step{
+							{
+								Container: coreapi.Container{
+									Name:  "prepare",
+									Image: clusterToolsImage,
+									Env: []coreapi.EnvVar{
+										{Name: "DOCKERFILE", Value: dockerfileContent},
+										{Name: "FROM_IMAGE", Value: from},
+										{Name: "IMAGE_ALIASES", Value: strings.Join(aliases, "\n")},
+										{Name: "BUILD_ENV", Value: strings.Join(env, "\n")},
+									},
+								},
+								Script: strings.Join(prepare, "\n"),
+							},
+							{
+								Container: coreapi.Container{
+									Name:      "build",
+									Image:     images.buildah,
+									Resources: build.Spec.Resources,
+									SecurityContext: &coreapi.SecurityContext{
+										Capabilities: &coreapi.Capabilities{Add: []coreapi.Capability{"SETFCAP"}},
+									},
+								},
+								Script: strings.Join(buildScript, "\n"),
+							},
+						},
+					},
+				}},
+			},
+		},
+	}
+	for key, value := range build.Labels {
+		run.Labels[key] = value
+	}
+	for key, value := range build.Annotations {
+		run.Annotations[key] = value
+	}
+	return run
+}
+
+// imageExtractPath translates the path of an image source into the argument
+// of `oc image extract`: a source path ending in /. copies the contents of
+// the directory, like it does for builds
+func imageExtractPath(sourcePath, destination string) string {
+	if strings.HasSuffix(sourcePath, "/.") {
+		return fmt.Sprintf("%s/:%s", strings.TrimSuffix(sourcePath, "/."), destination)
+	}
+	return fmt.Sprintf("%s:%s/%s", sourcePath, destination, path.Base(sourcePath))
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
+// recreatePipelineRun deletes the existing pipeline run and creates it anew
+func recreatePipelineRun(ctx context.Context, client ctrlruntimeclient.Client, existing, run *tektonapi.PipelineRun) error {
+	zero := int64(0)
+	foreground := metav1.DeletePropagationForeground
+	opts := metav1.DeleteOptions{
+		GracePeriodSeconds: &zero,
+		Preconditions:      &metav1.Preconditions{UID: &existing.UID},
+		PropagationPolicy:  &foreground,
+	}
+	if err := client.Delete(ctx, existing, &ctrlruntimeclient.DeleteOptions{Raw: &opts}); err != nil && !kerrors.IsNotFound(err) && !kerrors.IsConflict(err) {
+		return fmt.Errorf("could not delete pipeline run %s: %w", run.Name, err)
+	}
+	if err := wait.ExponentialBackoff(wait.Backoff{Duration: 10 * time.Millisecond, Factor: 2, Steps: 10}, func() (bool, error) {
+		err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: run.Namespace, Name: run.Name}, &tektonapi.PipelineRun{})
+		if kerrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}); err != nil {
+		return fmt.Errorf("could not wait for pipeline run %s to be deleted: %w", run.Name, err)
+	}
+	if err := client.Create(ctx, run); err != nil && !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not recreate pipeline run %s: %w", run.Name, err)
+	}
+	return nil
+}
+
+// classifyPipelineRunFailure determines the failure mode of a failed
+// pipeline run from its condition
+func classifyPipelineRunFailure(run *tektonapi.PipelineRun, condition *apis.Condition) results.Reason {
+	_, hermetic := run.Annotations[HermeticBuildAnnotation]
+	switch {
+	case condition.Reason == tektonapi.PipelineRunSpecStatusCancelled:
+		return results.ReasonCancelled
+	case hermetic && hintsAtNetworkAccess(condition.Message):
+		return results.ReasonHermeticViolation
+	case hintsAtCloneAuthFailure(condition.Message):
+		return results.ReasonCloneAuth
+	case hintsAtInfraReason(condition.Message):
+		return results.ReasonInfrastructure
+	}
+	return results.ReasonBuildFailed
+}
+
+func pipelineRunDuration(run *tektonapi.PipelineRun) time.Duration {
+	start := run.Status.StartTime
+	if start == nil {
+		start = &run.CreationTimestamp
+	}
+	end := run.Status.CompletionTime
+	if end == nil {
+		end = &metav1.Time{Time: time.Now()}
+	}
+	return end.Sub(start.Time)
+}
+
+// waitForPipelineRun waits for the pipeline run to succeed or fail
+func waitForPipelineRun(ctx context.Context, client ctrlruntimeclient.Client, namespace, name string) error {
+	run := &tektonapi.PipelineRun{}
+	check := func() (bool, error) {
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: name}, run); err != nil {
+			if kerrors.IsNotFound(err) {
+				return false, fmt.Errorf("could not find pipeline run %s", name)
+			}
+			Logger(ctx).Infof("Failed to get pipeline run %s: %v", name, err)
+			return false, nil
+		}
+		condition := run.Status.GetCondition(apis.ConditionSucceeded)
+		switch {
+		case condition == nil || condition.IsUnknown():
+			return false, nil
+		case condition.IsTrue():
+			Logger(ctx).Infof("Pipeline run %s succeeded after %s", name, pipelineRunDuration(run).Truncate(time.Second))
+			return true, nil
+		default:
+			return false, results.ForReason(classifyPipelineRunFailure(run, condition)).ForError(fmt.Errorf("the pipeline run %s failed after %s with reason %s: %s", name, pipelineRunDuration(run).Truncate(time.Second), condition.Reason, condition.Message))
+		}
+	}
+	if done, err := check(); done || err != nil {
+		return err
+	}
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if done, err := check(); done || err != nil {
+				return err
+			}
+		}
+	}
+}
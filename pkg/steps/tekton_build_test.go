@@ -0,0 +1,214 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	tektonapi "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"knative.dev/pkg/apis"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	buildapi "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func init() {
+	if err := tektonapi.AddToScheme(scheme.Scheme); err != nil {
+		panic(fmt.Sprintf("failed to add tekton v1beta1 to scheme: %v", err))
+	}
+}
+
+func tektonTestBuild() *buildapi.Build {
+	return &buildapi.Build{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ci-op-1234",
+			Name:        "operator",
+			Labels:      map[string]string{CreatesLabel: "operator"},
+			Annotations: map[string]string{JobSpecAnnotation: "{}"},
+		},
+		Spec: buildapi.BuildSpec{
+			CommonSpec: buildapi.CommonSpec{
+				Source: buildapi.BuildSource{
+					Type: buildapi.BuildSourceImage,
+					Images: []buildapi.ImageSource{{
+						From:  coreapi.ObjectReference{Kind: "ImageStreamTag", Name: "pipeline:bin"},
+						As:    []string{"registry.ci.openshift.org/ocp/builder:golang-1.16"},
+						Paths: []buildapi.ImageSourcePath{{SourcePath: "/go/src/github.com/openshift/operator/.", DestinationDir: "."}},
+					}},
+				},
+				Strategy: buildapi.BuildStrategy{
+					Type: buildapi.DockerBuildStrategyType,
+					DockerStrategy: &buildapi.DockerBuildStrategy{
+						DockerfilePath: "images/Dockerfile",
+						From:           &coreapi.ObjectReference{Kind: "ImageStreamTag", Namespace: "ci-op-1234", Name: "pipeline:base"},
+						ForcePull:      true,
+						NoCache:        true,
+						PullSecret:     &coreapi.LocalObjectReference{Name: PullSecretName},
+						Env:            []coreapi.EnvVar{{Name: "BUILD_LOGLEVEL", Value: "0"}, {Name: "OPENSHIFT_BUILD_NAME", Value: "operator"}},
+						BuildArgs:      []coreapi.EnvVar{{Name: "VERSION", Value: "4.9"}},
+					},
+				},
+				Output: buildapi.BuildOutput{
+					To:          &coreapi.ObjectReference{Kind: "ImageStreamTag", Namespace: "ci-op-1234", Name: "pipeline:operator"},
+					ImageLabels: []buildapi.ImageLabel{{Name: "vcs-ref", Value: "abcdef"}, {Name: "io.openshift.build.name", Value: ""}},
+				},
+			},
+		},
+	}
+}
+
+func tektonTestObjects() []runtime.Object {
+	objects := []runtime.Object{
+		&imagev1.ImageStream{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-1234", Name: api.PipelineImageStream},
+			Status:     imagev1.ImageStreamStatus{DockerImageRepository: "image-registry.openshift-image-registry.svc:5000/ci-op-1234/pipeline"},
+		},
+		&imagev1.ImageStream{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "buildah"},
+			Status: imagev1.ImageStreamStatus{
+				DockerImageRepository: "image-registry.openshift-image-registry.svc:5000/ci/buildah",
+				Tags:                  []imagev1.NamedTagEventList{{Tag: "stable", Items: []imagev1.TagEvent{{Image: "sha256:buildah"}}}},
+			},
+		},
+	}
+	for _, tag := range []string{"base", "bin"} {
+		objects = append(objects, &imagev1.ImageStreamTag{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-1234", Name: "pipeline:" + tag},
+			Image: imagev1.Image{
+				ObjectMeta:           metav1.ObjectMeta{Name: "sha256:" + tag},
+				DockerImageReference: "image-registry.openshift-image-registry.svc:5000/ci-op-1234/pipeline@sha256:" + tag,
+			},
+		})
+	}
+	return objects
+}
+
+func TestPipelineRunForBuild(t *testing.T) {
+	dockerfile := "FROM base\nRUN make"
+	gitBuild := tektonTestBuild()
+	gitBuild.Spec.Source = buildapi.BuildSource{
+		Type:         buildapi.BuildSourceGit,
+		Dockerfile:   &dockerfile,
+		ContextDir:   "images/operator",
+		SourceSecret: &coreapi.LocalObjectReference{Name: "clone-auth"},
+		Git:          &buildapi.GitBuildSource{URI: "https://github.com/openshift/operator.git", Ref: "master"},
+	}
+	var testCases = []struct {
+		name  string
+		build *buildapi.Build
+	}{
+		{
+			name:  "build from image sources",
+			build: tektonTestBuild(),
+		},
+		{
+			name:  "build from git",
+			build: gitBuild,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := loggingclient.New(fakectrlruntimeclient.NewFakeClient(tektonTestObjects()...))
+			images, err := resolveTektonImages(context.Background(), client, testCase.build)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			testhelper.CompareWithFixture(t, pipelineRunForBuild(testCase.build, images))
+		})
+	}
+}
+
+func TestResolveTektonImagesWithoutBuildah(t *testing.T) {
+	var objects []runtime.Object
+	for _, object := range tektonTestObjects() {
+		if stream, ok := object.(*imagev1.ImageStream); ok && stream.Name == "buildah" {
+			stream.Status.Tags = nil
+		}
+		objects = append(objects, object)
+	}
+	client := loggingclient.New(fakectrlruntimeclient.NewFakeClient(objects...))
+	_, err := resolveTektonImages(context.Background(), client, tektonTestBuild())
+	if expected := "buildah image ci/buildah:stable has not been mirrored into the registry"; err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestHandleTektonBuild(t *testing.T) {
+	var testCases = []struct {
+		name           string
+		condition      *apis.Condition
+		digest         string
+		outputExists   bool
+		expectedReason string
+	}{
+		{
+			name:         "a run with the same inputs is reused",
+			condition:    &apis.Condition{Type: apis.ConditionSucceeded, Status: coreapi.ConditionTrue},
+			outputExists: true,
+		},
+		{
+			name:           "a failed run fails the build",
+			condition:      &apis.Condition{Type: apis.ConditionSucceeded, Status: coreapi.ConditionFalse, Reason: "Failed", Message: "step build exited with code 1"},
+			expectedReason: string(results.ReasonBuildFailed),
+		},
+		{
+			name:           "a cancelled run fails the build",
+			condition:      &apis.Condition{Type: apis.ConditionSucceeded, Status: coreapi.ConditionFalse, Reason: tektonapi.PipelineRunSpecStatusCancelled},
+			expectedReason: string(results.ReasonCancelled),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			objects := tektonTestObjects()
+			if testCase.outputExists {
+				objects = append(objects, &imagev1.ImageStreamTag{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-1234", Name: "pipeline:operator"}})
+			}
+			client := NewBuildClient(loggingclient.New(fakectrlruntimeclient.NewFakeClient(objects...)), nil)
+			build := tektonTestBuild()
+			digest, err := buildInputsDigest(context.Background(), client, build)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			existing := &tektonapi.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "ci-op-1234",
+				Name:        "operator",
+				Annotations: map[string]string{BuildInputsDigestAnnotation: digest},
+			}}
+			existing.Status.SetCondition(testCase.condition)
+			existing.Status.TaskRuns = map[string]*tektonapi.PipelineRunTaskRunStatus{
+				"operator-build": {PipelineTaskName: "build", Status: &tektonapi.TaskRunStatus{TaskRunStatusFields: tektonapi.TaskRunStatusFields{
+					PodName: "operator-build-pod",
+					Steps:   []tektonapi.StepState{{Name: "build", ContainerName: "step-build"}},
+				}}},
+			}
+			if err := client.Create(context.Background(), existing); err != nil {
+				t.Fatalf("failed to create pipeline run: %v", err)
+			}
+
+			pods := &fakePodClient{fakePodExecutor: &fakePodExecutor{LoggingClient: client}}
+			err = handleBuild(context.Background(), NewTektonBuildClient(client, pods), build)
+			if testCase.expectedReason == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error, got none")
+			}
+			if reason := results.FullReason(err); reason != testCase.expectedReason {
+				t.Errorf("expected reason %s, got %s", testCase.expectedReason, reason)
+			}
+		})
+	}
+}
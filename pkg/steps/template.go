@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"sort"
@@ -54,6 +53,7 @@ const (
 type templateExecutionStep struct {
 	template  *templateapi.Template
 	resources api.ResourceConfiguration
+	gathering api.ArtifactGatheringConfiguration
 	params    api.Parameters
 	podClient PodClient
 	client    TemplateClient
@@ -69,11 +69,11 @@ func (s *templateExecutionStep) Inputs() (api.InputDefinition, error) {
 func (*templateExecutionStep) Validate() error { return nil }
 
 func (s *templateExecutionStep) Run(ctx context.Context) error {
-	return results.ForReason("executing_template").ForError(s.run(ctx))
+	return results.ForReason(results.ReasonExecutingTemplate).ForError(s.run(ctx))
 }
 
 func (s *templateExecutionStep) run(ctx context.Context) error {
-	log.Printf("Executing template %s", s.template.Name)
+	Logger(ctx).Infof("Executing template %s", s.template.Name)
 
 	if len(s.template.Objects) == 0 {
 		return fmt.Errorf("template %s has no objects", s.template.Name)
@@ -105,7 +105,7 @@ func (s *templateExecutionStep) run(ctx context.Context) error {
 		}
 	}
 
-	operateOnTemplatePods(s.template, s.resources)
+	operateOnTemplatePods(ctx, s.template, s.resources)
 	injectLabelsToTemplate(s.jobSpec, s.template)
 
 	// TODO: enforce single namespace behavior
@@ -124,19 +124,19 @@ func (s *templateExecutionStep) run(ctx context.Context) error {
 
 	go func() {
 		<-ctx.Done()
-		log.Printf("cleanup: Deleting template %s", s.template.Name)
+		Logger(ctx).Infof("cleanup: Deleting template %s", s.template.Name)
 		if err := s.client.Delete(cleanupCtx, &templateapi.TemplateInstance{ObjectMeta: meta.ObjectMeta{Namespace: s.jobSpec.Namespace(), Name: s.template.Name}}, ctrlruntimeclient.PropagationPolicy(meta.DeletePropagationForeground)); err != nil && !kerrors.IsNotFound(err) {
-			log.Printf("error: Could not delete template instance: %v", err)
+			Logger(ctx).Errorf("Could not delete template instance: %v", err)
 		}
 	}()
 
-	log.Printf("Creating or restarting template instance")
-	_, err := createOrRestartTemplateInstance(s.client, instance)
+	Logger(ctx).Infof("Creating or restarting template instance")
+	_, err := createOrRestartTemplateInstance(ctx, s.client, instance)
 	if err != nil {
 		return fmt.Errorf("could not create or restart template instance: %w", err)
 	}
 
-	log.Printf("Waiting for template instance to be ready")
+	Logger(ctx).Infof("Waiting for template instance to be ready")
 	instance, err = waitForTemplateInstanceReady(ctrlruntimeclient.NewNamespacedClient(s.client, s.jobSpec.Namespace()), s.template.Name)
 	if err != nil {
 		return fmt.Errorf("could not wait for template instance to be ready: %w", err)
@@ -144,8 +144,8 @@ func (s *templateExecutionStep) run(ctx context.Context) error {
 
 	// now that the pods have been resolved by the template, add them to the artifact map
 	var notifier ContainerNotifier = NopNotifier
-	if artifactDir, artifactsRequested := api.Artifacts(); artifactsRequested {
-		artifacts := NewArtifactWorker(s.podClient, filepath.Join(artifactDir, s.template.Name), s.jobSpec.Namespace())
+	if artifactDir, artifactsRequested := api.ArtifactsFor(ctx); artifactsRequested {
+		artifacts := NewArtifactWorker(ctx, s.podClient, filepath.Join(artifactDir, s.template.Name), s.jobSpec.Namespace(), s.gathering.ForStep(s.template.Name))
 		for _, ref := range instance.Status.Objects {
 			switch {
 			case ref.Ref.Kind == "Pod" && ref.Ref.APIVersion == "v1":
@@ -162,7 +162,7 @@ func (s *templateExecutionStep) run(ctx context.Context) error {
 	for _, ref := range instance.Status.Objects {
 		switch {
 		case ref.Ref.Kind == "Pod" && ref.Ref.APIVersion == "v1":
-			log.Printf("Running pod %s", ref.Ref.Name)
+			Logger(ctx).Infof("Running pod %s", ref.Ref.Name)
 		}
 	}
 
@@ -192,8 +192,8 @@ func injectLabelsToTemplate(jobSpec *api.JobSpec, template *templateapi.Template
 		if template.ObjectLabels == nil {
 			template.ObjectLabels = make(map[string]string)
 		}
-		template.ObjectLabels[RefsOrgLabel] = refs.Org
-		template.ObjectLabels[RefsRepoLabel] = refs.Repo
+		template.ObjectLabels[RefsOrgLabel] = refsLabelValue(refs.Org)
+		template.ObjectLabels[RefsRepoLabel] = refsLabelValue(refs.Repo)
 		template.ObjectLabels[RefsBranchLabel] = refs.BaseRef
 	}
 }
@@ -228,14 +228,14 @@ func injectResourcesToPod(pod *coreapi.Pod, templateName string, resources api.R
 	return nil
 }
 
-func operateOnTemplatePods(template *templateapi.Template, resources api.ResourceConfiguration) {
+func operateOnTemplatePods(ctx context.Context, template *templateapi.Template, resources api.ResourceConfiguration) {
 	for index, object := range template.Objects {
 		if pod := getPodFromObject(object); pod != nil {
 			addArtifactsToPod(pod)
 
 			if resources != nil && !hasTestContainerWithResources(pod) {
 				if err := injectResourcesToPod(pod, template.Name, resources); err != nil {
-					log.Printf("couldn't inject resources to pod: %v", err)
+					Logger(ctx).Warnf("couldn't inject resources to pod: %v", err)
 				}
 			}
 
@@ -277,10 +277,11 @@ func (s *templateExecutionStep) Objects() []ctrlruntimeclient.Object {
 	return s.client.Objects()
 }
 
-func TemplateExecutionStep(template *templateapi.Template, params api.Parameters, podClient PodClient, templateClient TemplateClient, jobSpec *api.JobSpec, resources api.ResourceConfiguration) api.Step {
+func TemplateExecutionStep(template *templateapi.Template, params api.Parameters, podClient PodClient, templateClient TemplateClient, jobSpec *api.JobSpec, resources api.ResourceConfiguration, gathering api.ArtifactGatheringConfiguration) api.Step {
 	return &templateExecutionStep{
 		template:  template,
 		resources: resources,
+		gathering: gathering,
 		params:    params,
 		podClient: podClient,
 		client:    templateClient,
@@ -329,9 +330,9 @@ func waitForTemplateInstanceReady(client ctrlruntimeclient.Client, name string)
 	return instance, err
 }
 
-func createOrRestartTemplateInstance(client ctrlruntimeclient.Client, instance *templateapi.TemplateInstance) (*templateapi.TemplateInstance, error) {
+func createOrRestartTemplateInstance(ctx context.Context, client ctrlruntimeclient.Client, instance *templateapi.TemplateInstance) (*templateapi.TemplateInstance, error) {
 	namespace, name := instance.Namespace, instance.Name
-	if err := waitForCompletedTemplateInstanceDeletion(client, namespace, name); err != nil {
+	if err := waitForCompletedTemplateInstanceDeletion(ctx, client, namespace, name); err != nil {
 		return nil, fmt.Errorf("unable to delete completed template instance: %w", err)
 	}
 	err := client.Create(context.TODO(), instance)
@@ -342,16 +343,16 @@ func createOrRestartTemplateInstance(client ctrlruntimeclient.Client, instance *
 		if err := client.Get(context.TODO(), ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: instance.Name}, instance); err != nil {
 			return nil, fmt.Errorf("unable to retrieve pod: %w", err)
 		}
-		log.Printf("Waiting for running template %s to finish", instance.Name)
+		Logger(ctx).Infof("Waiting for running template %s to finish", instance.Name)
 	}
 	return instance, nil
 }
 
-func waitForCompletedTemplateInstanceDeletion(client ctrlruntimeclient.Client, namespace, name string) error {
+func waitForCompletedTemplateInstanceDeletion(ctx context.Context, client ctrlruntimeclient.Client, namespace, name string) error {
 	instance := &templateapi.TemplateInstance{}
 	err := client.Get(context.TODO(), ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: name}, instance)
 	if kerrors.IsNotFound(err) {
-		log.Printf("Template instance %s already deleted, do not need to wait any longer", name)
+		Logger(ctx).Infof("Template instance %s already deleted, do not need to wait any longer", name)
 		return nil
 	}
 
@@ -364,7 +365,7 @@ func waitForCompletedTemplateInstanceDeletion(client ctrlruntimeclient.Client, n
 	}}
 	err = client.Delete(context.TODO(), instance, opts)
 	if kerrors.IsNotFound(err) {
-		log.Printf("After initial existence check, a delete of template %s and instance %s received a not found error ",
+		Logger(ctx).Infof("After initial existence check, a delete of template %s and instance %s received a not found error ",
 			name, string(instance.UID))
 		return nil
 	}
@@ -385,10 +386,10 @@ func waitForCompletedTemplateInstanceDeletion(client ctrlruntimeclient.Client, n
 		}
 		if i == 1800 {
 			data, _ := json.MarshalIndent(instance.Status, "", "  ")
-			log.Printf("Template instance %s has not completed deletion after 30 minutes, possible error in controller:\n%s", name, string(data))
+			Logger(ctx).Infof("Template instance %s has not completed deletion after 30 minutes, possible error in controller:\n%s", name, string(data))
 		}
 
-		log.Printf("Waiting for template instance %s to be deleted ...", name)
+		Logger(ctx).Infof("Waiting for template instance %s to be deleted ...", name)
 		time.Sleep(2 * time.Second)
 	}
 
@@ -396,7 +397,7 @@ func waitForCompletedTemplateInstanceDeletion(client ctrlruntimeclient.Client, n
 	for _, ref := range instance.Status.Objects {
 		switch {
 		case ref.Ref.Kind == "Pod" && ref.Ref.APIVersion == "v1":
-			if err := waitForPodDeletion(client, namespace, ref.Ref.Name, ref.Ref.UID); err != nil {
+			if err := waitForPodDeletion(ctx, client, namespace, ref.Ref.Name, ref.Ref.UID); err != nil {
 				return err
 			}
 		}
@@ -404,15 +405,15 @@ func waitForCompletedTemplateInstanceDeletion(client ctrlruntimeclient.Client, n
 	return nil
 }
 
-func createOrRestartPod(podClient ctrlruntimeclient.Client, pod *coreapi.Pod) (*coreapi.Pod, error) {
+func createOrRestartPod(ctx context.Context, podClient ctrlruntimeclient.Client, pod *coreapi.Pod) (*coreapi.Pod, error) {
 	namespace, name := pod.Namespace, pod.Name
-	if err := waitForCompletedPodDeletion(podClient, namespace, name); err != nil {
+	if err := waitForCompletedPodDeletion(ctx, podClient, namespace, name); err != nil {
 		return nil, fmt.Errorf("unable to delete completed pod: %w", err)
 	}
 	if pod.Spec.ActiveDeadlineSeconds == nil {
-		log.Printf("Executing pod %q running image %q", pod.Name, pod.Spec.Containers[0].Image)
+		Logger(ctx).Infof("Executing pod %q running image %q", pod.Name, pod.Spec.Containers[0].Image)
 	} else {
-		log.Printf("Executing pod %q with activeDeadlineSeconds=%d", pod.Name, *pod.Spec.ActiveDeadlineSeconds)
+		Logger(ctx).Infof("Executing pod %q with activeDeadlineSeconds=%d", pod.Name, *pod.Spec.ActiveDeadlineSeconds)
 	}
 	// creating a pod in close proximity to namespace creation can result in forbidden errors due to
 	// initializing secrets or policy - use a short backoff to mitigate flakes
@@ -420,7 +421,7 @@ func createOrRestartPod(podClient ctrlruntimeclient.Client, pod *coreapi.Pod) (*
 		err := podClient.Create(context.TODO(), pod)
 		if err != nil {
 			if kerrors.IsForbidden(err) {
-				log.Printf("Unable to create pod %s, may be temporary: %v", name, err)
+				Logger(ctx).Infof("Unable to create pod %s, may be temporary: %v", name, err)
 				return false, nil
 			}
 			if !kerrors.IsAlreadyExists(err) {
@@ -438,7 +439,7 @@ func createOrRestartPod(podClient ctrlruntimeclient.Client, pod *coreapi.Pod) (*
 	return pod, nil
 }
 
-func waitForPodDeletion(podClient ctrlruntimeclient.Client, namespace, name string, uid types.UID) error {
+func waitForPodDeletion(ctx context.Context, podClient ctrlruntimeclient.Client, namespace, name string, uid types.UID) error {
 	timeout := 600
 	for i := 0; i < timeout; i += 2 {
 		pod := &coreapi.Pod{}
@@ -452,14 +453,14 @@ func waitForPodDeletion(podClient ctrlruntimeclient.Client, namespace, name stri
 		if pod.UID != uid {
 			return nil
 		}
-		log.Printf("Waiting for pod %s to be deleted ... (%ds/%d)", name, i, timeout)
+		Logger(ctx).Infof("Waiting for pod %s to be deleted ... (%ds/%d)", name, i, timeout)
 		time.Sleep(2 * time.Second)
 	}
 
 	return fmt.Errorf("waited for pod %s deletion for %ds, was not deleted", name, timeout)
 }
 
-func waitForCompletedPodDeletion(podClient ctrlruntimeclient.Client, namespace, name string) error {
+func waitForCompletedPodDeletion(ctx context.Context, podClient ctrlruntimeclient.Client, namespace, name string) error {
 	pod := &coreapi.Pod{}
 	if err := podClient.Get(context.TODO(), ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: name}, pod); kerrors.IsNotFound(err) {
 		return nil
@@ -479,7 +480,7 @@ func waitForCompletedPodDeletion(podClient ctrlruntimeclient.Client, namespace,
 		return fmt.Errorf("could not delete completed pod: %w", err)
 	}
 
-	return waitForPodDeletion(podClient, namespace, name, uid)
+	return waitForPodDeletion(ctx, podClient, namespace, name, uid)
 }
 
 func waitForPodCompletion(ctx context.Context, podClient PodClient, namespace, name string, notifier ContainerNotifier, skipLogs bool) (*coreapi.Pod, error) {
@@ -534,7 +535,7 @@ func waitForPodCompletionOrTimeout(ctx context.Context, podClient PodClient, nam
 	if err := podClient.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: name}, pod); err != nil {
 		if kerrors.IsNotFound(err) {
 			notifier.Complete(name)
-			log.Printf("error: could not wait for pod '%s': it is no longer present on the cluster"+
+			Logger(ctx).Errorf("could not wait for pod '%s': it is no longer present on the cluster"+
 				" (usually a result of a race or resource pressure. re-running the job should help)", name)
 			return nil, fmt.Errorf("pod was deleted while ci-operator step was waiting for it")
 		}
@@ -544,10 +545,10 @@ func waitForPodCompletionOrTimeout(ctx context.Context, podClient PodClient, nam
 	if pod.Spec.RestartPolicy == coreapi.RestartPolicyAlways {
 		return pod, nil
 	}
-	podLogNewFailedContainers(podClient, pod, completed, notifier, skipLogs)
+	podLogNewFailedContainers(ctx, podClient, pod, completed, notifier, skipLogs)
 	if podJobIsOK(pod) {
 		if !skipLogs {
-			log.Printf("Pod %s already succeeded in %s", pod.Name, podDuration(pod).Truncate(time.Second))
+			Logger(ctx).Infof("Pod %s already succeeded in %s", pod.Name, podDuration(pod).Truncate(time.Second))
 		}
 		return pod, nil
 	}
@@ -570,24 +571,29 @@ func waitForPodCompletionOrTimeout(ctx context.Context, podClient PodClient, nam
 				if kerrors.IsNotFound(err) {
 					return pod, appendLogToError(fmt.Errorf("the pod %s/%s was deleted without completing after %s (failed containers: %s)", pod.Namespace, pod.Name, podDuration(pod).Truncate(time.Second), strings.Join(failedContainerNames(pod), ", ")), podMessages(pod))
 				}
-				log.Printf("warning: failed to get pod %s: %v", name, err)
+				Logger(ctx).Warnf("failed to get pod %s: %v", name, err)
 				continue
 			}
 
 			if !podSeenRunning {
 				if podHasStarted(pod) {
 					podSeenRunning = true
+				} else if condition, unschedulable := podUnschedulableFor(pod, schedulingTimeout(ctx), time.Now()); unschedulable {
+					message := fmt.Sprintf("pod could not be scheduled within %s:\n%s", schedulingTimeout(ctx), schedulingDiagnostics(ctx, pod, condition, podClient))
+					Logger(ctx).Info(message)
+					notifier.Complete(name)
+					return pod, errors.New(message)
 				} else if time.Since(pod.CreationTimestamp.Time) > podStartTimeout {
 					message := fmt.Sprintf("pod didn't start running within %s: %s\n%s", podStartTimeout, getReasonsForUnreadyContainers(pod), getEventsForPod(ctx, pod, podClient))
-					log.Print(message)
+					Logger(ctx).Info(message)
 					notifier.Complete(name)
 					return pod, errors.New(message)
 				}
 			}
-			podLogNewFailedContainers(podClient, pod, completed, notifier, skipLogs)
+			podLogNewFailedContainers(ctx, podClient, pod, completed, notifier, skipLogs)
 			if podJobIsOK(pod) {
 				if !skipLogs {
-					log.Printf("Pod %s succeeded after %s", pod.Name, podDuration(pod).Truncate(time.Second))
+					Logger(ctx).Infof("Pod %s succeeded after %s", pod.Name, podDuration(pod).Truncate(time.Second))
 				}
 				return pod, nil
 			}
@@ -764,7 +770,7 @@ func failedContainerNames(pod *coreapi.Pod) []string {
 	return names
 }
 
-func podLogNewFailedContainers(podClient PodClient, pod *coreapi.Pod, completed map[string]time.Time, notifier ContainerNotifier, skipLogs bool) {
+func podLogNewFailedContainers(ctx context.Context, podClient PodClient, pod *coreapi.Pod, completed map[string]time.Time, notifier ContainerNotifier, skipLogs bool) {
 	var statuses []coreapi.ContainerStatus
 	statuses = append(statuses, pod.Status.InitContainerStatuses...)
 	statuses = append(statuses, pod.Status.ContainerStatuses...)
@@ -782,7 +788,7 @@ func podLogNewFailedContainers(podClient PodClient, pod *coreapi.Pod, completed
 
 		if s.ExitCode == 0 {
 			if !skipLogs {
-				log.Printf("Container %s in pod %s completed successfully", status.Name, pod.Name)
+				Logger(ctx).Infof("Container %s in pod %s completed successfully", status.Name, pod.Name)
 			}
 			continue
 		}
@@ -791,14 +797,14 @@ func podLogNewFailedContainers(podClient PodClient, pod *coreapi.Pod, completed
 			Container: status.Name,
 		}).Stream(context.TODO()); err == nil {
 			if _, err := io.Copy(os.Stdout, s); err != nil {
-				log.Printf("error: Unable to copy log output from failed pod container %s: %v", status.Name, err)
+				Logger(ctx).Errorf("Unable to copy log output from failed pod container %s: %v", status.Name, err)
 			}
 			s.Close()
 		} else {
-			log.Printf("error: Unable to retrieve logs from failed pod container %s: %v", status.Name, err)
+			Logger(ctx).Errorf("Unable to retrieve logs from failed pod container %s: %v", status.Name, err)
 		}
 
-		log.Printf("Container %s in pod %s failed, exit code %d, reason %s", status.Name, pod.Name, status.State.Terminated.ExitCode, status.State.Terminated.Reason)
+		Logger(ctx).Infof("Container %s in pod %s failed, exit code %d, reason %s", status.Name, pod.Name, status.State.Terminated.ExitCode, status.State.Terminated.Reason)
 	}
 	// Workaround for https://github.com/kubernetes/kubernetes/issues/88611
 	// Pods may be terminated with DeadlineExceeded with spec.ActiveDeadlineSeconds is set.
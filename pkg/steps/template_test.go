@@ -1,6 +1,7 @@
 package steps
 
 import (
+	"context"
 	"testing"
 
 	coreapi "k8s.io/api/core/v1"
@@ -235,7 +236,7 @@ func TestOperateOnTemplatePods(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.testID, func(t *testing.T) {
-			operateOnTemplatePods(tc.template, tc.resources)
+			operateOnTemplatePods(context.Background(), tc.template, tc.resources)
 			testhelper.CompareWithFixture(t, tc.template)
 		})
 	}
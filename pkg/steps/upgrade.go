@@ -0,0 +1,439 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/clientcmd"
+	utilpointer "k8s.io/utils/pointer"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/junit"
+	"github.com/openshift/ci-tools/pkg/steps/utils"
+)
+
+const (
+	// defaultUpgradeTimeout bounds upgrade steps which set no timeout
+	defaultUpgradeTimeout = 2 * time.Hour
+	// UpgradeOperatorsArtifact records every change of the status of the
+	// ClusterOperators seen during an upgrade
+	UpgradeOperatorsArtifact = "clusteroperators.json"
+	// clusterVersionName is the name of the singleton ClusterVersion
+	clusterVersionName = "version"
+)
+
+var (
+	clusterVersionGVK      = schema.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "ClusterVersion"}
+	clusterOperatorListGVK = schema.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "ClusterOperatorList"}
+)
+
+// Allow tests to accelerate polling
+var upgradeInterval = 30 * time.Second
+
+// newClusterClient creates a client for the cluster of a test from its
+// kubeconfig; tests replace it with a fake
+var newClusterClient = func(kubeconfig []byte) (ctrlruntimeclient.Client, error) {
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not load kubeconfig: %w", err)
+	}
+	return ctrlruntimeclient.New(config, ctrlruntimeclient.Options{})
+}
+
+// operatorStatus is the state of a ClusterOperator at some point of the
+// upgrade
+type operatorStatus struct {
+	Version     string `json:"version,omitempty"`
+	Available   string `json:"available,omitempty"`
+	Progressing string `json:"progressing,omitempty"`
+	Degraded    string `json:"degraded,omitempty"`
+	Message     string `json:"message,omitempty"`
+}
+
+func (s operatorStatus) String() string {
+	ret := fmt.Sprintf("version=%s available=%s progressing=%s degraded=%s", s.Version, s.Available, s.Progressing, s.Degraded)
+	if s.Message != "" {
+		ret += ": " + s.Message
+	}
+	return ret
+}
+
+// settled determines whether the operator runs the version and is healthy
+func (s operatorStatus) settled(version string) bool {
+	return s.Version == version && s.Available == string(coreapi.ConditionTrue) && s.Degraded != string(coreapi.ConditionTrue)
+}
+
+// operatorTransition records that a ClusterOperator changed its status
+type operatorTransition struct {
+	Time     time.Time      `json:"time"`
+	Operator string         `json:"operator"`
+	Status   operatorStatus `json:"status"`
+}
+
+// clusterUpgrade drives an upgrade through the cluster-version operator
+// and keeps track of what happened for the junit and the artifacts
+type clusterUpgrade struct {
+	client   ctrlruntimeclient.Client
+	from, to string
+	// prefix names the junit tests of the phases of the upgrade
+	prefix  string
+	started time.Time
+	tests   []*junit.TestCase
+	// operators holds the last status seen for every ClusterOperator
+	operators   map[string]operatorStatus
+	transitions []operatorTransition
+	// upgraded records when the operators settled on the new version
+	upgraded map[string]time.Time
+	version  string
+}
+
+// phase runs a phase of the upgrade, recording a junit test for it
+func (u *clusterUpgrade) phase(name string, f func() error) error {
+	start := time.Now()
+	err := f()
+	test := &junit.TestCase{Name: u.prefix + name, Duration: time.Since(start).Seconds()}
+	if err != nil {
+		test.FailureOutput = &junit.FailureOutput{Output: err.Error()}
+	}
+	u.tests = append(u.tests, test)
+	return err
+}
+
+// run upgrades the cluster, stopping at the first phase which fails
+func (u *clusterUpgrade) run(ctx context.Context) error {
+	u.started = time.Now()
+	if err := u.phase("verify the cluster runs the initial release", func() error { return u.verifyInitial(ctx) }); err != nil {
+		return err
+	}
+	if err := u.phase("request the upgrade", func() error { return u.request(ctx) }); err != nil {
+		return err
+	}
+	if err := u.phase("cluster version is upgraded", func() error { return u.waitForClusterVersion(ctx) }); err != nil {
+		return err
+	}
+	err := u.phase("cluster operators are upgraded", func() error { return u.waitForOperators(ctx) })
+	u.operatorTests()
+	return err
+}
+
+func (u *clusterUpgrade) clusterVersion(ctx context.Context) (*unstructured.Unstructured, error) {
+	cv := &unstructured.Unstructured{}
+	cv.SetGroupVersionKind(clusterVersionGVK)
+	if err := u.client.Get(ctx, ctrlruntimeclient.ObjectKey{Name: clusterVersionName}, cv); err != nil {
+		return nil, fmt.Errorf("could not get ClusterVersion: %w", err)
+	}
+	return cv, nil
+}
+
+func (u *clusterUpgrade) verifyInitial(ctx context.Context) error {
+	cv, err := u.clusterVersion(ctx)
+	if err != nil {
+		return err
+	}
+	image, _, _ := unstructured.NestedString(cv.Object, "status", "desired", "image")
+	if !sameRelease(image, u.from) {
+		return fmt.Errorf("the cluster runs %s instead of the initial release %s", image, u.from)
+	}
+	if update, _ := lastUpdate(cv); update["state"] != "Completed" {
+		return fmt.Errorf("the cluster has not finished installing %s", u.from)
+	}
+	return nil
+}
+
+func (u *clusterUpgrade) request(ctx context.Context) error {
+	cv, err := u.clusterVersion(ctx)
+	if err != nil {
+		return err
+	}
+	// the payload is built by the job and not signed, so the update has to
+	// be forced
+	update := map[string]interface{}{"image": u.to, "force": true}
+	if err := unstructured.SetNestedMap(cv.Object, update, "spec", "desiredUpdate"); err != nil {
+		return err
+	}
+	if err := u.client.Update(ctx, cv); err != nil {
+		return fmt.Errorf("could not update ClusterVersion: %w", err)
+	}
+	return nil
+}
+
+// waitForClusterVersion waits until the cluster-version operator finished
+// applying the new release. The API server restarts during the upgrade, so
+// errors talking to it are not fatal.
+func (u *clusterUpgrade) waitForClusterVersion(ctx context.Context) error {
+	var failing string
+	err := wait.PollImmediateUntil(upgradeInterval, func() (bool, error) {
+		cv, err := u.clusterVersion(ctx)
+		if err != nil {
+			Logger(ctx).Infof("Failed to check the progress of the upgrade: %v", err)
+			return false, nil
+		}
+		// operators only count as upgraded once the new release is desired
+		if image, _, _ := unstructured.NestedString(cv.Object, "status", "desired", "image"); sameRelease(image, u.to) {
+			u.version, _, _ = unstructured.NestedString(cv.Object, "status", "desired", "version")
+		}
+		u.captureOperators(ctx)
+		if status, message := condition(cv, "Failing"); status == string(coreapi.ConditionTrue) {
+			if message != failing {
+				Logger(ctx).Infof("Upgrade is failing: %s", message)
+			}
+			failing = message
+		} else {
+			failing = ""
+		}
+		update, ok := lastUpdate(cv)
+		image, _ := update["image"].(string)
+		return ok && sameRelease(image, u.to) && update["state"] == "Completed", nil
+	}, ctx.Done())
+	if err != nil {
+		message := fmt.Sprintf("cluster version did not reach %s", u.to)
+		if errors.Is(err, wait.ErrWaitTimeout) {
+			message += " in time"
+		}
+		if failing != "" {
+			message += ": " + failing
+		}
+		return errors.New(message)
+	}
+	Logger(ctx).Infof("Cluster version is upgraded to %s", u.version)
+	return nil
+}
+
+// waitForOperators waits until every ClusterOperator runs the new version
+// and is available without being degraded
+func (u *clusterUpgrade) waitForOperators(ctx context.Context) error {
+	err := wait.PollImmediateUntil(upgradeInterval, func() (bool, error) {
+		if !u.captureOperators(ctx) {
+			return false, nil
+		}
+		for _, status := range u.operators {
+			if !status.settled(u.version) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}, ctx.Done())
+	if err != nil {
+		var pending []string
+		for name, status := range u.operators {
+			if !status.settled(u.version) {
+				pending = append(pending, name)
+			}
+		}
+		sort.Strings(pending)
+		return fmt.Errorf("cluster operators did not settle on version %s: %s", u.version, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// captureOperators records the changes of the status of the ClusterOperators
+// and returns whether they could be read
+func (u *clusterUpgrade) captureOperators(ctx context.Context) bool {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(clusterOperatorListGVK)
+	if err := u.client.List(ctx, list); err != nil {
+		Logger(ctx).Infof("Failed to list cluster operators: %v", err)
+		return false
+	}
+	now := time.Now()
+	for i := range list.Items {
+		co := &list.Items[i]
+		status := operatorStatusFor(co)
+		if previous, seen := u.operators[co.GetName()]; seen && previous == status {
+			continue
+		}
+		u.operators[co.GetName()] = status
+		u.transitions = append(u.transitions, operatorTransition{Time: now, Operator: co.GetName(), Status: status})
+		Logger(ctx).Debugf("Cluster operator %s: %s", co.GetName(), status)
+		if _, done := u.upgraded[co.GetName()]; !done && u.version != "" && status.settled(u.version) {
+			u.upgraded[co.GetName()] = now
+		}
+	}
+	return true
+}
+
+// operatorTests adds a junit test for every ClusterOperator, passing if it
+// settled on the new version
+func (u *clusterUpgrade) operatorTests() {
+	var names []string
+	for name := range u.operators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		test := &junit.TestCase{Name: fmt.Sprintf("%scluster operator %s is upgraded", u.prefix, name)}
+		if upgraded, ok := u.upgraded[name]; ok {
+			test.Duration = upgraded.Sub(u.started).Seconds()
+		} else {
+			test.Duration = time.Since(u.started).Seconds()
+			test.FailureOutput = &junit.FailureOutput{Output: fmt.Sprintf("cluster operator %s did not settle on version %s: %s", name, u.version, u.operators[name])}
+		}
+		u.tests = append(u.tests, test)
+	}
+}
+
+// sameRelease determines whether two pull specs refer to the same release.
+// The cluster may report the release through a mirror, so pull specs by
+// digest are compared by their digests only.
+func sameRelease(a, b string) bool {
+	digestA, digestB := pullSpecDigest(a), pullSpecDigest(b)
+	if digestA != "" && digestB != "" {
+		return digestA == digestB
+	}
+	return a == b
+}
+
+// pullSpecDigest returns the digest of a pull spec, if it refers to one
+func pullSpecDigest(pullSpec string) string {
+	if i := strings.LastIndex(pullSpec, "@"); i != -1 {
+		return pullSpec[i+1:]
+	}
+	return ""
+}
+
+// lastUpdate returns the most recent entry in the history of the cluster
+func lastUpdate(cv *unstructured.Unstructured) (map[string]interface{}, bool) {
+	history, _, _ := unstructured.NestedSlice(cv.Object, "status", "history")
+	if len(history) == 0 {
+		return nil, false
+	}
+	update, ok := history[0].(map[string]interface{})
+	return update, ok
+}
+
+// condition returns the status and message of a condition of a ClusterVersion
+// or ClusterOperator
+func condition(obj *unstructured.Unstructured, conditionType string) (string, string) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, raw := range conditions {
+		c, ok := raw.(map[string]interface{})
+		if !ok || c["type"] != conditionType {
+			continue
+		}
+		status, _ := c["status"].(string)
+		message, _ := c["message"].(string)
+		return status, message
+	}
+	return "", ""
+}
+
+func operatorStatusFor(co *unstructured.Unstructured) operatorStatus {
+	var status operatorStatus
+	versions, _, _ := unstructured.NestedSlice(co.Object, "status", "versions")
+	for _, raw := range versions {
+		if version, ok := raw.(map[string]interface{}); ok && version["name"] == "operator" {
+			status.Version, _ = version["version"].(string)
+		}
+	}
+	var available, degraded string
+	status.Available, available = condition(co, "Available")
+	status.Progressing, _ = condition(co, "Progressing")
+	status.Degraded, degraded = condition(co, "Degraded")
+	switch {
+	case status.Degraded == string(coreapi.ConditionTrue):
+		status.Message = degraded
+	case status.Available != string(coreapi.ConditionTrue):
+		status.Message = available
+	}
+	return status
+}
+
+// runUpgrade runs an upgrade step against the cluster whose kubeconfig the
+// earlier steps stored in the shared directory
+func (s *multiStageTestStep) runUpgrade(ctx context.Context, step api.LiteralTestStep) error {
+	name := fmt.Sprintf("%s-%s", s.name, step.As)
+	start := time.Now()
+	u, err := s.clusterUpgrade(ctx, step)
+	if err == nil {
+		timeout := defaultUpgradeTimeout
+		if step.Timeout != nil {
+			timeout = step.Timeout.Duration
+		}
+		upgradeCtx, cancel := context.WithTimeout(ctx, timeout)
+		Logger(ctx).Infof("Upgrading the cluster of %s from release %s to %s", s.name, step.Upgrade.From, step.Upgrade.To)
+		err = u.run(upgradeCtx)
+		cancel()
+		s.subTests = append(s.subTests, u.tests...)
+		if saveErr := saveUpgradeTransitions(ctx, s.name, step.As, u.transitions); saveErr != nil {
+			Logger(ctx).Infof("Failed to save the status of the cluster operators of %s: %v", name, saveErr)
+		}
+	}
+	finished := time.Now()
+	duration := finished.Sub(start)
+	s.subSteps = append(s.subSteps, api.CIOperatorStepDetailInfo{
+		StepName:    name,
+		Description: fmt.Sprintf("Upgrade the cluster from release %s to %s", step.Upgrade.From, step.Upgrade.To),
+		StartedAt:   &start,
+		FinishedAt:  &finished,
+		Duration:    &duration,
+		Failed:      utilpointer.BoolPtr(err != nil),
+	})
+	if err != nil {
+		return fmt.Errorf("%q step %q failed to upgrade the cluster from release %s to %s: %w", s.name, name, step.Upgrade.From, step.Upgrade.To, err)
+	}
+	return nil
+}
+
+// clusterUpgrade prepares the upgrade of the cluster of the test between
+// the payloads of the releases of the step
+func (s *multiStageTestStep) clusterUpgrade(ctx context.Context, step api.LiteralTestStep) (*clusterUpgrade, error) {
+	from, err := s.params.Get(utils.ReleaseImageEnv(step.Upgrade.From))
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve the payload of release %s: %w", step.Upgrade.From, err)
+	}
+	to, err := s.params.Get(utils.ReleaseImageEnv(step.Upgrade.To))
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve the payload of release %s: %w", step.Upgrade.To, err)
+	}
+	secret := &coreapi.Secret{}
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: s.name}, secret); err != nil {
+		return nil, fmt.Errorf("could not read the shared directory: %w", err)
+	}
+	kubeconfig, ok := secret.Data[BYOClusterKubeconfigKey]
+	if !ok || len(kubeconfig) == 0 {
+		return nil, fmt.Errorf("the shared directory has no %q, a previous step must install the cluster", BYOClusterKubeconfigKey)
+	}
+	client, err := newClusterClient(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not create a client for the cluster: %w", err)
+	}
+	return &clusterUpgrade{
+		client:    client,
+		from:      from,
+		to:        to,
+		prefix:    fmt.Sprintf("%s - %s-%s ", s.Description(), s.name, step.As),
+		operators: map[string]operatorStatus{},
+		upgraded:  map[string]time.Time{},
+	}, nil
+}
+
+// saveUpgradeTransitions writes the changes of the status of the cluster
+// operators to the artifacts of the step
+func saveUpgradeTransitions(ctx context.Context, test, step string, transitions []operatorTransition) error {
+	artifactDir, set := api.ArtifactsFor(ctx)
+	if !set {
+		return nil
+	}
+	dir := filepath.Join(artifactDir, test, step)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("unable to create directory %s: %w", dir, err)
+	}
+	raw, err := json.MarshalIndent(transitions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, UpgradeOperatorsArtifact), raw, 0640)
+}
@@ -0,0 +1,293 @@
+package steps
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+	"github.com/openshift/ci-tools/pkg/steps/utils"
+)
+
+func fakeClusterVersion(image, version, state string, failing string) *unstructured.Unstructured {
+	cv := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "version"},
+		"status": map[string]interface{}{
+			"desired": map[string]interface{}{"image": image, "version": version},
+			"history": []interface{}{map[string]interface{}{"image": image, "version": version, "state": state}},
+		},
+	}}
+	if failing != "" {
+		_ = unstructured.SetNestedSlice(cv.Object, []interface{}{
+			map[string]interface{}{"type": "Failing", "status": "True", "message": failing},
+		}, "status", "conditions")
+	}
+	cv.SetGroupVersionKind(clusterVersionGVK)
+	return cv
+}
+
+func fakeClusterOperator(name, version string, degraded bool) *unstructured.Unstructured {
+	degradedStatus := "False"
+	if degraded {
+		degradedStatus = "True"
+	}
+	co := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": name},
+		"status": map[string]interface{}{
+			"versions": []interface{}{map[string]interface{}{"name": "operator", "version": version}},
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Available", "status": "True"},
+				map[string]interface{}{"type": "Progressing", "status": "False"},
+				map[string]interface{}{"type": "Degraded", "status": degradedStatus, "message": "broken"},
+			},
+		},
+	}}
+	co.SetGroupVersionKind(schema.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "ClusterOperator"})
+	return co
+}
+
+// fakeClusterClient serves core objects along with the ClusterVersion and
+// ClusterOperators, which are not registered in the default scheme
+func fakeClusterClient(objects ...runtime.Object) ctrlruntimeclient.Client {
+	scheme := runtime.NewScheme()
+	_ = coreapi.AddToScheme(scheme)
+	for _, kind := range []string{"ClusterVersion", "ClusterOperator"} {
+		gv := clusterVersionGVK.GroupVersion()
+		scheme.AddKnownTypeWithName(gv.WithKind(kind), &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(gv.WithKind(kind+"List"), &unstructured.UnstructuredList{})
+	}
+	return fakectrlruntimeclient.NewFakeClientWithScheme(scheme, objects...)
+}
+
+// upgradingClient acts like the cluster-version operator, upgrading the
+// cluster and its operators once an update is requested
+type upgradingClient struct {
+	ctrlruntimeclient.Client
+	version string
+	// stuck operators do not upgrade and become degraded
+	stuck sets.String
+	// failing prevents the upgrade with the message
+	failing string
+}
+
+func (c *upgradingClient) Update(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.UpdateOption) error {
+	if err := c.Client.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	cv, ok := obj.(*unstructured.Unstructured)
+	if !ok || cv.GetKind() != "ClusterVersion" {
+		return nil
+	}
+	image, _, _ := unstructured.NestedString(cv.Object, "spec", "desiredUpdate", "image")
+	status := fakeClusterVersion(image, c.version, "Completed", "")
+	if c.failing != "" {
+		status = fakeClusterVersion("old-payload", "4.1.0", "Completed", c.failing)
+	}
+	cv.Object["status"] = status.Object["status"]
+	if err := c.Client.Update(ctx, cv); err != nil || c.failing != "" {
+		return err
+	}
+	operators := &unstructured.UnstructuredList{}
+	operators.SetGroupVersionKind(clusterOperatorListGVK)
+	if err := c.Client.List(ctx, operators); err != nil {
+		return err
+	}
+	for _, co := range operators.Items {
+		updated := fakeClusterOperator(co.GetName(), c.version, c.stuck.Has(co.GetName()))
+		updated.SetResourceVersion(co.GetResourceVersion())
+		if err := c.Client.Update(ctx, updated); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestClusterUpgrade(t *testing.T) {
+	upgradeInterval = time.Millisecond
+	defer func() { upgradeInterval = 30 * time.Second }()
+	for _, tc := range []struct {
+		name      string
+		from      string
+		installed string
+		stuck     sets.String
+		failing   string
+		expected  []string
+		failures  map[string]string
+	}{{
+		name:      "cluster is upgraded",
+		installed: "old-payload",
+		expected: []string{
+			"verify the cluster runs the initial release",
+			"request the upgrade",
+			"cluster version is upgraded",
+			"cluster operators are upgraded",
+			"cluster operator dns is upgraded",
+			"cluster operator network is upgraded",
+		},
+	}, {
+		name:      "cluster reports the initial release through a mirror",
+		from:      "registry.ci/ci-op/release@sha256:old",
+		installed: "mirror.example.com/release@sha256:old",
+		expected: []string{
+			"verify the cluster runs the initial release",
+			"request the upgrade",
+			"cluster version is upgraded",
+			"cluster operators are upgraded",
+			"cluster operator dns is upgraded",
+			"cluster operator network is upgraded",
+		},
+	}, {
+		name:      "cluster runs another release by digest",
+		from:      "registry.ci/ci-op/release@sha256:old",
+		installed: "registry.ci/ci-op/release@sha256:other",
+		expected:  []string{"verify the cluster runs the initial release"},
+		failures: map[string]string{
+			"verify the cluster runs the initial release": "the cluster runs registry.ci/ci-op/release@sha256:other instead of the initial release registry.ci/ci-op/release@sha256:old",
+		},
+	}, {
+		name:      "cluster does not run the initial release",
+		installed: "other-payload",
+		expected:  []string{"verify the cluster runs the initial release"},
+		failures: map[string]string{
+			"verify the cluster runs the initial release": "the cluster runs other-payload instead of the initial release old-payload",
+		},
+	}, {
+		name:      "upgrade fails",
+		installed: "old-payload",
+		failing:   "Could not verify the payload",
+		expected: []string{
+			"verify the cluster runs the initial release",
+			"request the upgrade",
+			"cluster version is upgraded",
+		},
+		failures: map[string]string{
+			"cluster version is upgraded": "cluster version did not reach new-payload in time: Could not verify the payload",
+		},
+	}, {
+		name:      "operator does not upgrade",
+		installed: "old-payload",
+		stuck:     sets.NewString("network"),
+		expected: []string{
+			"verify the cluster runs the initial release",
+			"request the upgrade",
+			"cluster version is upgraded",
+			"cluster operators are upgraded",
+			"cluster operator dns is upgraded",
+			"cluster operator network is upgraded",
+		},
+		failures: map[string]string{
+			"cluster operators are upgraded":       "cluster operators did not settle on version 4.2.0: network",
+			"cluster operator network is upgraded": "cluster operator network did not settle on version 4.2.0: version=4.2.0 available=True progressing=False degraded=True: broken",
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &upgradingClient{
+				Client: fakeClusterClient(
+					fakeClusterVersion(tc.installed, "4.1.0", "Completed", ""),
+					fakeClusterOperator("dns", "4.1.0", false),
+					fakeClusterOperator("network", "4.1.0", false),
+				),
+				version: "4.2.0",
+				stuck:   tc.stuck,
+				failing: tc.failing,
+			}
+			if tc.from == "" {
+				tc.from = "old-payload"
+			}
+			u := &clusterUpgrade{
+				client:    client,
+				from:      tc.from,
+				to:        "new-payload",
+				operators: map[string]operatorStatus{},
+				upgraded:  map[string]time.Time{},
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			err := u.run(ctx)
+			if (err != nil) != (len(tc.failures) != 0) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			var names []string
+			failures := map[string]string{}
+			for _, test := range u.tests {
+				names = append(names, test.Name)
+				if test.FailureOutput != nil {
+					failures[test.Name] = test.FailureOutput.Output
+				}
+			}
+			if diff := cmp.Diff(tc.expected, names); diff != "" {
+				t.Errorf("unexpected tests: %s", diff)
+			}
+			if len(tc.failures) == 0 {
+				tc.failures = map[string]string{}
+			}
+			if diff := cmp.Diff(tc.failures, failures); diff != "" {
+				t.Errorf("unexpected failures: %s", diff)
+			}
+		})
+	}
+}
+
+func TestRunUpgradeStep(t *testing.T) {
+	upgradeInterval = time.Millisecond
+	defer func() { upgradeInterval = 30 * time.Second }()
+	cluster := &upgradingClient{
+		Client: fakeClusterClient(
+			fakeClusterVersion("old-payload", "4.1.0", "Completed", ""),
+			fakeClusterOperator("dns", "4.1.0", false),
+		),
+		version: "4.2.0",
+	}
+	var kubeconfig []byte
+	original := newClusterClient
+	defer func() { newClusterClient = original }()
+	newClusterClient = func(raw []byte) (ctrlruntimeclient.Client, error) {
+		kubeconfig = raw
+		return cluster, nil
+	}
+	shared := &coreapi.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns"},
+		Data:       map[string][]byte{"kubeconfig": []byte("kubeconfig")},
+	}
+	crclient := &fakePodExecutor{LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClient([]runtime.Object{shared}...)), evicted: sets.NewString()}
+	jobSpec := &api.JobSpec{}
+	jobSpec.SetNamespace("ns")
+	params := api.NewDeferredParameters(nil)
+	params.Add(utils.ReleaseImageEnv(api.InitialReleaseName), func() (string, error) { return "old-payload", nil })
+	params.Add(utils.ReleaseImageEnv(api.LatestReleaseName), func() (string, error) { return "new-payload", nil })
+	s := &multiStageTestStep{name: "test", params: params, client: &fakePodClient{fakePodExecutor: crclient}, jobSpec: jobSpec}
+	steps := []api.LiteralTestStep{
+		{As: "upgrade", Upgrade: &api.UpgradeStep{From: api.InitialReleaseName, To: api.LatestReleaseName}},
+	}
+	if err := s.runSteps(context.Background(), steps, nil, true, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(kubeconfig) != "kubeconfig" {
+		t.Errorf("expected the kubeconfig from the shared directory, got %q", string(kubeconfig))
+	}
+	if len(s.subSteps) != 1 || s.subSteps[0].StepName != "test-upgrade" || *s.subSteps[0].Failed {
+		t.Errorf("unexpected sub-steps: %#v", s.subSteps)
+	}
+	if len(s.subTests) != 5 || s.subTests[0].Name != "Run multi-stage test test - test-upgrade verify the cluster runs the initial release" {
+		t.Errorf("unexpected sub-tests: %#v", s.subTests)
+	}
+	cv := fakeClusterVersion("", "", "", "")
+	if err := cluster.Get(context.Background(), ctrlruntimeclient.ObjectKey{Name: "version"}, cv); err != nil {
+		t.Fatal(err)
+	}
+	if update, _, _ := unstructured.NestedMap(cv.Object, "spec", "desiredUpdate"); !cmp.Equal(update, map[string]interface{}{"image": "new-payload", "force": true}) {
+		t.Errorf("unexpected desired update: %v", update)
+	}
+}
@@ -0,0 +1,310 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/retry"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+const (
+	vulnerabilityScanName          = "vulnerability-scan"
+	vulnerabilityScanArtifactsPath = "/tmp/artifacts"
+	vulnerabilityScanAuthMountPath = "/tmp/registry-auth"
+
+	// trivyImage and clairImage are the upstream releases of the scanners
+	trivyImage = "docker.io/aquasec/trivy:0.48.3"
+	clairImage = "quay.io/projectquay/clair:4.7.2"
+
+	// VulnerabilitiesAnnotationPrefix prefixes the annotations recording
+	// the vulnerabilities found in each image on the pipeline image stream
+	VulnerabilitiesAnnotationPrefix = "vulnerabilities.ci.openshift.io/"
+)
+
+// vulnerabilityCountFilters count the vulnerabilities by severity in the
+// reports of each scanner
+var vulnerabilityCountFilters = map[string]string{
+	api.VulnerabilityScannerTrivy: `[.Results[]?.Vulnerabilities[]?.Severity]`,
+	api.VulnerabilityScannerClair: `[.vulnerabilities[]?.normalized_severity | ascii_upcase]`,
+}
+
+// vulnerabilityCounts are the numbers of vulnerabilities found in each
+// image by severity
+type vulnerabilityCounts map[string]map[string]int
+
+// vulnerabilityScanStep scans built images for known vulnerabilities and
+// fails the job or annotates the images when they are above the threshold
+type vulnerabilityScanStep struct {
+	config  api.VulnerabilityScanConfiguration
+	images  []api.ProjectDirectoryImageBuildStepConfiguration
+	client  PodClient
+	jobSpec *api.JobSpec
+}
+
+func (s *vulnerabilityScanStep) Inputs() (api.InputDefinition, error) {
+	return nil, nil
+}
+
+func (*vulnerabilityScanStep) Validate() error { return nil }
+
+func (s *vulnerabilityScanStep) Run(ctx context.Context) error {
+	return results.ForReason(results.ReasonScanningImages).ForError(s.run(ctx))
+}
+
+func (s *vulnerabilityScanStep) run(ctx context.Context) error {
+	pipeline := &imagev1.ImageStream{}
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: api.PipelineImageStream}, pipeline); err != nil {
+		return fmt.Errorf("could not resolve pipeline imagestream: %w", err)
+	}
+	images, err := builtImages(pipeline, s.scannedImages())
+	if err != nil {
+		return err
+	}
+
+	Logger(ctx).Infof("Scanning %d images for vulnerabilities", len(images))
+	pod := vulnerabilityScanPod(s.jobSpec.Namespace(), s.config, images)
+	if owner := s.jobSpec.Owner(); owner != nil {
+		pod.OwnerReferences = append(pod.OwnerReferences, *owner)
+	}
+	var notifier ContainerNotifier = NopNotifier
+	if artifactDir, artifactsRequested := api.ArtifactsFor(ctx); artifactsRequested {
+		artifacts := NewArtifactWorker(ctx, s.client, filepath.Join(artifactDir, vulnerabilityScanName), s.jobSpec.Namespace(), api.ArtifactGathering{})
+		addArtifactsToPod(pod)
+		addArtifactContainersFromPod(pod, artifacts)
+		notifier = artifacts
+	}
+	pod, err = createOrRestartPod(ctx, s.client, pod)
+	if err != nil {
+		return fmt.Errorf("failed to create vulnerability scan pod: %w", err)
+	}
+	pod, err = waitForPodCompletion(ctx, s.client, pod.Namespace, pod.Name, notifier, true)
+	if err != nil {
+		return fmt.Errorf("vulnerability scan pod failed: %w", err)
+	}
+	counts, err := scanResults(pod)
+	if err != nil {
+		return err
+	}
+	if err := s.annotate(ctx, counts); err != nil {
+		return err
+	}
+	threshold := s.config.SeverityThreshold
+	if threshold == "" {
+		threshold = "HIGH"
+	}
+	vulnerable := counts.above(threshold)
+	if len(vulnerable) == 0 {
+		return nil
+	}
+	message := fmt.Sprintf("found vulnerabilities of severity %s or higher in images: %s", threshold, strings.Join(vulnerable, ", "))
+	if s.config.Action == api.VulnerabilityScanActionAnnotate {
+		Logger(ctx).Warnf("%s", message)
+		return nil
+	}
+	return errors.New(message)
+}
+
+func (s *vulnerabilityScanStep) scannedImages() []string {
+	var names []string
+	for _, image := range s.config.Images {
+		names = append(names, string(image))
+	}
+	if len(names) > 0 {
+		return names
+	}
+	for _, image := range s.images {
+		names = append(names, string(image.To))
+	}
+	return names
+}
+
+// annotate records the vulnerabilities found in each image on the pipeline
+// image stream
+func (s *vulnerabilityScanStep) annotate(ctx context.Context, counts vulnerabilityCounts) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pipeline := &imagev1.ImageStream{}
+		if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: api.PipelineImageStream}, pipeline); err != nil {
+			return fmt.Errorf("could not resolve pipeline imagestream: %w", err)
+		}
+		if pipeline.Annotations == nil {
+			pipeline.Annotations = map[string]string{}
+		}
+		for image := range counts {
+			pipeline.Annotations[VulnerabilitiesAnnotationPrefix+image] = counts.summary(image)
+		}
+		return s.client.Update(ctx, pipeline)
+	})
+}
+
+// scanResults reads the vulnerability counts the scan container reports in
+// its termination message
+func scanResults(pod *coreapi.Pod) (vulnerabilityCounts, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != vulnerabilityScanName || status.State.Terminated == nil {
+			continue
+		}
+		counts := vulnerabilityCounts{}
+		if err := json.Unmarshal([]byte(status.State.Terminated.Message), &counts); err != nil {
+			return nil, fmt.Errorf("could not parse vulnerability scan results: %w", err)
+		}
+		return counts, nil
+	}
+	return nil, fmt.Errorf("vulnerability scan did not report results")
+}
+
+// summary formats the vulnerabilities found in the image from the highest
+// severity to the lowest
+func (c vulnerabilityCounts) summary(image string) string {
+	var parts []string
+	for i := len(api.VulnerabilitySeverities) - 1; i >= 0; i-- {
+		severity := api.VulnerabilitySeverities[i]
+		if count := c[image][severity]; count > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", severity, count))
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ",")
+}
+
+// above lists the images with vulnerabilities at or above the threshold
+func (c vulnerabilityCounts) above(threshold string) []string {
+	var severities []string
+	for i, severity := range api.VulnerabilitySeverities {
+		if severity == threshold {
+			severities = api.VulnerabilitySeverities[i:]
+		}
+	}
+	var images []string
+	for _, image := range sets.StringKeySet(c).List() {
+		for _, severity := range severities {
+			if c[image][severity] > 0 {
+				images = append(images, fmt.Sprintf("%s (%s)", image, c.summary(image)))
+				break
+			}
+		}
+	}
+	return images
+}
+
+// vulnerabilityScanPod scans the images, writing the reports to the
+// artifacts and the number of vulnerabilities by severity to the
+// termination message
+func vulnerabilityScanPod(namespace string, config api.VulnerabilityScanConfiguration, images []builtImage) *coreapi.Pod {
+	scanner := config.Scanner
+	if scanner == "" {
+		scanner = api.VulnerabilityScannerTrivy
+	}
+	var registries []string
+	for _, image := range images {
+		registries = append(registries, strings.SplitN(image.repository, "/", 2)[0])
+	}
+	authMount := coreapi.VolumeMount{Name: "registry-auth", MountPath: vulnerabilityScanAuthMountPath}
+	artifactsMount := coreapi.VolumeMount{Name: "artifacts", MountPath: vulnerabilityScanArtifactsPath}
+	initContainers := []coreapi.Container{{
+		Name:         "registry-auth",
+		Image:        clusterToolsImage,
+		Command:      []string{"/bin/bash", "-c"},
+		Args:         []string{strings.Join([]string{"set -euo pipefail", registryAuthScript(filepath.Join(vulnerabilityScanAuthMountPath, "config.json"), registries...)}, "\n")},
+		VolumeMounts: []coreapi.VolumeMount{authMount},
+	}}
+	// the scanners run in their own images, the reports are summarized
+	// with jq from the cluster tools once they are all written
+	commands := []string{"set -euo pipefail", "cd " + vulnerabilityScanArtifactsPath}
+	for i, image := range images {
+		report := filepath.Join(vulnerabilityScanArtifactsPath, image.name+".vulnerabilities.json")
+		container := coreapi.Container{
+			Name:         fmt.Sprintf("scan-%d", i),
+			Env:          []coreapi.EnvVar{{Name: "DOCKER_CONFIG", Value: vulnerabilityScanAuthMountPath}},
+			VolumeMounts: []coreapi.VolumeMount{authMount, artifactsMount},
+		}
+		switch scanner {
+		case api.VulnerabilityScannerClair:
+			container.Image = clairImage
+			container.Command = []string{"/bin/sh", "-c"}
+			container.Args = []string{fmt.Sprintf("clairctl --host %s report --out json %s > %s", config.ClairURL, image.reference(), report)}
+		default:
+			container.Image = trivyImage
+			container.Command = []string{"trivy"}
+			container.Args = []string{"image", "--quiet", "--format", "json", "--output", report, image.reference()}
+		}
+		initContainers = append(initContainers, container)
+		commands = append(commands, fmt.Sprintf(`jq -c --arg image %s '{($image): (%s | group_by(.) | map({key: .[0], value: length}) | from_entries)}' %s >> /tmp/summary.json`, image.name, vulnerabilityCountFilters[scanner], filepath.Base(report)))
+	}
+	commands = append(commands, `jq -cs 'add // {}' /tmp/summary.json > /dev/termination-log`)
+	return &coreapi.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      vulnerabilityScanName,
+			Namespace: namespace,
+		},
+		Spec: coreapi.PodSpec{
+			RestartPolicy: coreapi.RestartPolicyNever,
+			// the builder account may pull from the pipeline image stream
+			ServiceAccountName: "builder",
+			InitContainers:     initContainers,
+			Containers: []coreapi.Container{{
+				Name:                     vulnerabilityScanName,
+				Image:                    clusterToolsImage,
+				Command:                  []string{"/bin/bash", "-c"},
+				Args:                     []string{strings.Join(commands, "\n")},
+				VolumeMounts:             []coreapi.VolumeMount{artifactsMount},
+				TerminationMessagePolicy: coreapi.TerminationMessageReadFile,
+			}},
+			Volumes: []coreapi.Volume{
+				{Name: "artifacts", VolumeSource: coreapi.VolumeSource{EmptyDir: &coreapi.EmptyDirVolumeSource{}}},
+				{Name: "registry-auth", VolumeSource: coreapi.VolumeSource{EmptyDir: &coreapi.EmptyDirVolumeSource{}}},
+			},
+		},
+	}
+}
+
+func (s *vulnerabilityScanStep) Requires() []api.StepLink {
+	var links []api.StepLink
+	for _, image := range s.scannedImages() {
+		links = append(links, api.InternalImageLink(api.PipelineImageStreamTagReference(image)))
+	}
+	return links
+}
+
+func (s *vulnerabilityScanStep) Creates() []api.StepLink {
+	return []api.StepLink{api.VulnerabilityScanLink()}
+}
+
+func (s *vulnerabilityScanStep) Provides() api.ParameterMap {
+	return nil
+}
+
+func (s *vulnerabilityScanStep) Name() string { return "[vulnerability-scan]" }
+
+func (s *vulnerabilityScanStep) Description() string {
+	return "Scan the built images for known vulnerabilities"
+}
+
+func (s *vulnerabilityScanStep) Objects() []ctrlruntimeclient.Object {
+	return s.client.Objects()
+}
+
+// VulnerabilityScanStep scans images built by the job for known
+// vulnerabilities.
+func VulnerabilityScanStep(config api.VulnerabilityScanConfiguration, images []api.ProjectDirectoryImageBuildStepConfiguration, client PodClient, jobSpec *api.JobSpec) api.Step {
+	return &vulnerabilityScanStep{
+		config:  config,
+		images:  images,
+		client:  client,
+		jobSpec: jobSpec,
+	}
+}
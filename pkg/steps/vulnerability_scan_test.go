@@ -0,0 +1,94 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestScanResults(t *testing.T) {
+	var testCases = []struct {
+		name              string
+		message           string
+		threshold         string
+		expectedSummaries map[string]string
+		expectedAbove     []string
+		expectedErr       string
+	}{
+		{
+			name:              "vulnerabilities below the threshold",
+			message:           `{"cli":{"LOW":3,"MEDIUM":1},"operator":{}}`,
+			threshold:         "HIGH",
+			expectedSummaries: map[string]string{"cli": "MEDIUM=1,LOW=3", "operator": "none"},
+		},
+		{
+			name:              "vulnerabilities at and above the threshold",
+			message:           `{"cli":{"CRITICAL":1,"LOW":3},"operator":{"MEDIUM":2},"src":{"UNKNOWN":4}}`,
+			threshold:         "MEDIUM",
+			expectedSummaries: map[string]string{"cli": "CRITICAL=1,LOW=3", "operator": "MEDIUM=2", "src": "UNKNOWN=4"},
+			expectedAbove:     []string{"cli (CRITICAL=1,LOW=3)", "operator (MEDIUM=2)"},
+		},
+		{
+			name:        "missing results",
+			expectedErr: "could not parse vulnerability scan results: unexpected end of JSON input",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			pod := &coreapi.Pod{Status: coreapi.PodStatus{ContainerStatuses: []coreapi.ContainerStatus{
+				{Name: "artifacts", State: coreapi.ContainerState{Terminated: &coreapi.ContainerStateTerminated{}}},
+				{Name: vulnerabilityScanName, State: coreapi.ContainerState{Terminated: &coreapi.ContainerStateTerminated{Message: testCase.message}}},
+			}}}
+			counts, err := scanResults(pod)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if err != nil {
+				return
+			}
+			summaries := map[string]string{}
+			for image := range counts {
+				summaries[image] = counts.summary(image)
+			}
+			if diff := cmp.Diff(testCase.expectedSummaries, summaries); diff != "" {
+				t.Errorf("unexpected summaries: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expectedAbove, counts.above(testCase.threshold)); diff != "" {
+				t.Errorf("unexpected images above the threshold: %s", diff)
+			}
+		})
+	}
+}
+
+func TestVulnerabilityScanPod(t *testing.T) {
+	images := []builtImage{
+		{name: "cli", repository: "image-registry.openshift-image-registry.svc:5000/ci-op-1234/pipeline", digest: "sha256:cli"},
+		{name: "operator", repository: "image-registry.openshift-image-registry.svc:5000/ci-op-1234/pipeline", digest: "sha256:operator"},
+	}
+	var testCases = []struct {
+		name   string
+		config api.VulnerabilityScanConfiguration
+	}{
+		{
+			name: "trivy",
+		},
+		{
+			name:   "clair",
+			config: api.VulnerabilityScanConfiguration{Scanner: api.VulnerabilityScannerClair, ClairURL: "https://clair.example.com"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testhelper.CompareWithFixture(t, vulnerabilityScanPod("ci-op-1234", testCase.config, images))
+		})
+	}
+}
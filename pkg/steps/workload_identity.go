@@ -0,0 +1,186 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+const (
+	// WorkloadIdentityKey is the key in the secret of a cluster profile which
+	// configures the cloud identities steps of tests using the profile assume.
+	// Steps then get short-lived credentials for the identities and the
+	// secret with the long-lived keys is not mounted into them.
+	WorkloadIdentityKey = "workload-identity.yaml"
+	// WorkloadIdentityMountPath is where the tokens and credential
+	// configuration for workload identities are mounted
+	WorkloadIdentityMountPath = "/var/run/secrets/ci.openshift.io/workload-identity"
+
+	// workloadIdentityTokenExpiration is the validity of the service account
+	// tokens exchanged for cloud credentials. The kubelet refreshes projected
+	// tokens before they expire, so steps running for longer keep working.
+	workloadIdentityTokenExpiration = 3600
+	awsTokenFile                    = "aws-token"
+	awsAudience                     = "sts.amazonaws.com"
+	gcpTokenFile                    = "gcp-token"
+	gcpCredentialsFile              = "gcp-credentials.json"
+)
+
+// WorkloadIdentity configures the cloud identities steps assume using
+// tokens of the service account they run as
+type WorkloadIdentity struct {
+	AWS *AWSWorkloadIdentity `json:"aws,omitempty"`
+	GCP *GCPWorkloadIdentity `json:"gcp,omitempty"`
+}
+
+// AWSWorkloadIdentity is an IAM role assumed with AssumeRoleWithWebIdentity.
+// The role must trust the OIDC provider of the build farm cluster.
+type AWSWorkloadIdentity struct {
+	RoleARN string `json:"role_arn"`
+}
+
+// GCPWorkloadIdentity is a provider of a workload identity pool which trusts
+// the build farm cluster, optionally with a service account to impersonate.
+type GCPWorkloadIdentity struct {
+	// Audience is the full resource name of the provider, e.g.
+	// //iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/ci/providers/build01
+	Audience       string `json:"audience"`
+	ServiceAccount string `json:"service_account,omitempty"`
+}
+
+func parseWorkloadIdentity(raw []byte) (*WorkloadIdentity, error) {
+	identity := &WorkloadIdentity{}
+	if err := yaml.UnmarshalStrict(raw, identity); err != nil {
+		return nil, err
+	}
+	var errs []string
+	if identity.AWS == nil && identity.GCP == nil {
+		errs = append(errs, "no identity configured")
+	}
+	if identity.AWS != nil && identity.AWS.RoleARN == "" {
+		errs = append(errs, "aws.role_arn cannot be empty")
+	}
+	if identity.GCP != nil && identity.GCP.Audience == "" {
+		errs = append(errs, "gcp.audience cannot be empty")
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, ", "))
+	}
+	return identity, nil
+}
+
+// gcpCredentials generates the configuration client libraries use to
+// exchange the token for credentials of the identity
+func gcpCredentials(identity *GCPWorkloadIdentity) ([]byte, error) {
+	config := map[string]interface{}{
+		"type":               "external_account",
+		"audience":           identity.Audience,
+		"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+		"token_url":          "https://sts.googleapis.com/v1/token",
+		"credential_source":  map[string]string{"file": filepath.Join(WorkloadIdentityMountPath, gcpTokenFile)},
+	}
+	if identity.ServiceAccount != "" {
+		config["service_account_impersonation_url"] = fmt.Sprintf("https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:generateAccessToken", identity.ServiceAccount)
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
+
+func (s *multiStageTestStep) workloadIdentityName() string {
+	return s.name + "-workload-identity"
+}
+
+// setupWorkloadIdentity loads the workload identity of the cluster profile,
+// if it has one, and creates the configuration steps need to assume it
+func (s *multiStageTestStep) setupWorkloadIdentity(ctx context.Context) error {
+	if s.profile == "" {
+		return nil
+	}
+	secret := &coreapi.Secret{}
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: s.profileSecretName()}, secret); err != nil {
+		return fmt.Errorf("could not read cluster profile secret: %w", err)
+	}
+	raw, ok := secret.Data[WorkloadIdentityKey]
+	if !ok {
+		return nil
+	}
+	identity, err := parseWorkloadIdentity(raw)
+	if err != nil {
+		return fmt.Errorf("invalid %s in cluster profile %s: %w", WorkloadIdentityKey, s.profile, err)
+	}
+	Logger(ctx).Infof("Steps of %s will assume the workload identity of cluster profile %s", s.name, s.profile)
+	if identity.GCP != nil {
+		credentials, err := gcpCredentials(identity.GCP)
+		if err != nil {
+			return fmt.Errorf("could not generate GCP credentials configuration: %w", err)
+		}
+		cm := &coreapi.ConfigMap{
+			ObjectMeta: meta.ObjectMeta{
+				Namespace: s.jobSpec.Namespace(),
+				Name:      s.workloadIdentityName(),
+				Labels:    map[string]string{MultiStageTestLabel: s.name},
+			},
+			Data: map[string]string{gcpCredentialsFile: string(credentials)},
+		}
+		if err := s.client.Create(ctx, cm); err != nil {
+			if !kerrors.IsAlreadyExists(err) {
+				return fmt.Errorf("could not create GCP credentials configuration: %w", err)
+			}
+			if err := s.client.Update(ctx, cm); err != nil {
+				return fmt.Errorf("could not update GCP credentials configuration: %w", err)
+			}
+		}
+	}
+	s.workloadIdentity = identity
+	return nil
+}
+
+// addWorkloadIdentity projects tokens of the pod's service account for the
+// configured identities and points the cloud SDKs at them. It replaces the
+// cluster profile secret, so only the type of the cluster is exposed from it.
+func addWorkloadIdentity(configMap string, profile api.ClusterProfile, identity *WorkloadIdentity, pod *coreapi.Pod) {
+	volumeName := "workload-identity"
+	expiration := int64(workloadIdentityTokenExpiration)
+	projected := &coreapi.ProjectedVolumeSource{}
+	container := &pod.Spec.Containers[0]
+	container.Env = append(container.Env, coreapi.EnvVar{Name: "CLUSTER_TYPE", Value: profile.ClusterType()})
+	if identity.AWS != nil {
+		projected.Sources = append(projected.Sources, coreapi.VolumeProjection{
+			ServiceAccountToken: &coreapi.ServiceAccountTokenProjection{Audience: awsAudience, ExpirationSeconds: &expiration, Path: awsTokenFile},
+		})
+		container.Env = append(container.Env, []coreapi.EnvVar{
+			{Name: "AWS_ROLE_ARN", Value: identity.AWS.RoleARN},
+			{Name: "AWS_WEB_IDENTITY_TOKEN_FILE", Value: filepath.Join(WorkloadIdentityMountPath, awsTokenFile)},
+			{Name: "AWS_ROLE_SESSION_NAME", Value: pod.Name},
+		}...)
+	}
+	if identity.GCP != nil {
+		projected.Sources = append(projected.Sources, coreapi.VolumeProjection{
+			ServiceAccountToken: &coreapi.ServiceAccountTokenProjection{Audience: identity.GCP.Audience, ExpirationSeconds: &expiration, Path: gcpTokenFile},
+		}, coreapi.VolumeProjection{
+			ConfigMap: &coreapi.ConfigMapProjection{LocalObjectReference: coreapi.LocalObjectReference{Name: configMap}},
+		})
+		container.Env = append(container.Env, coreapi.EnvVar{
+			Name:  "GOOGLE_APPLICATION_CREDENTIALS",
+			Value: filepath.Join(WorkloadIdentityMountPath, gcpCredentialsFile),
+		})
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, coreapi.Volume{
+		Name:         volumeName,
+		VolumeSource: coreapi.VolumeSource{Projected: projected},
+	})
+	container.VolumeMounts = append(container.VolumeMounts, coreapi.VolumeMount{
+		Name:      volumeName,
+		MountPath: WorkloadIdentityMountPath,
+	})
+}
@@ -0,0 +1,125 @@
+package steps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+func TestSetupWorkloadIdentity(t *testing.T) {
+	var testCases = []struct {
+		name              string
+		data              map[string][]byte
+		expected          *WorkloadIdentity
+		expectedConfigMap bool
+		expectedErr       string
+	}{
+		{
+			name: "profile without workload identity",
+			data: map[string][]byte{"ssh-publickey": []byte("key")},
+		},
+		{
+			name:     "AWS role",
+			data:     map[string][]byte{WorkloadIdentityKey: []byte("aws:\n  role_arn: arn:aws:iam::123:role/ci\n")},
+			expected: &WorkloadIdentity{AWS: &AWSWorkloadIdentity{RoleARN: "arn:aws:iam::123:role/ci"}},
+		},
+		{
+			name:              "GCP provider needs credentials configuration",
+			data:              map[string][]byte{WorkloadIdentityKey: []byte("gcp:\n  audience: //iam.googleapis.com/pool\n  service_account: ci@project.iam.gserviceaccount.com\n")},
+			expected:          &WorkloadIdentity{GCP: &GCPWorkloadIdentity{Audience: "//iam.googleapis.com/pool", ServiceAccount: "ci@project.iam.gserviceaccount.com"}},
+			expectedConfigMap: true,
+		},
+		{
+			name:        "invalid configuration",
+			data:        map[string][]byte{WorkloadIdentityKey: []byte("aws: {}\n")},
+			expectedErr: "invalid workload-identity.yaml in cluster profile aws: aws.role_arn cannot be empty",
+		},
+		{
+			name:        "unknown fields are rejected",
+			data:        map[string][]byte{WorkloadIdentityKey: []byte("azure: {}\n")},
+			expectedErr: `invalid workload-identity.yaml in cluster profile aws: error unmarshaling JSON: while decoding JSON: json: unknown field "azure"`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			jobSpec := api.JobSpec{}
+			jobSpec.SetNamespace("ns")
+			secret := &coreapi.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "test-cluster-profile"},
+				Data:       testCase.data,
+			}
+			client := &fakePodClient{fakePodExecutor: &fakePodExecutor{LoggingClient: loggingclient.New(fakectrlruntimeclient.NewFakeClient(secret))}}
+			step := newMultiStageTestStep(api.TestStepConfiguration{
+				As: "test",
+				MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+					ClusterProfile: api.ClusterProfileAWS,
+				},
+			}, &api.ReleaseBuildConfiguration{}, nil, client, &jobSpec, nil, nil, nil, nil)
+			err := step.setupWorkloadIdentity(context.Background())
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if diff := cmp.Diff(testCase.expectedErr, actualErr); diff != "" {
+				t.Fatalf("unexpected error: %s", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, step.workloadIdentity); diff != "" {
+				t.Errorf("unexpected workload identity: %s", diff)
+			}
+			err = client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: "test-workload-identity"}, &coreapi.ConfigMap{})
+			if testCase.expectedConfigMap && err != nil {
+				t.Errorf("expected credentials configuration to be created: %v", err)
+			} else if !testCase.expectedConfigMap && err == nil {
+				t.Error("expected no credentials configuration to be created")
+			}
+		})
+	}
+}
+
+func TestAddWorkloadIdentity(t *testing.T) {
+	expiration := int64(workloadIdentityTokenExpiration)
+	pod := &coreapi.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-step"},
+		Spec:       coreapi.PodSpec{Containers: []coreapi.Container{{Name: "test"}}},
+	}
+	addWorkloadIdentity("test-workload-identity", api.ClusterProfileAWS, &WorkloadIdentity{
+		AWS: &AWSWorkloadIdentity{RoleARN: "arn"},
+		GCP: &GCPWorkloadIdentity{Audience: "//iam.googleapis.com/pool"},
+	}, pod)
+	expected := &coreapi.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-step"},
+		Spec: coreapi.PodSpec{
+			Containers: []coreapi.Container{{
+				Name: "test",
+				Env: []coreapi.EnvVar{
+					{Name: "CLUSTER_TYPE", Value: "aws"},
+					{Name: "AWS_ROLE_ARN", Value: "arn"},
+					{Name: "AWS_WEB_IDENTITY_TOKEN_FILE", Value: "/var/run/secrets/ci.openshift.io/workload-identity/aws-token"},
+					{Name: "AWS_ROLE_SESSION_NAME", Value: "test-step"},
+					{Name: "GOOGLE_APPLICATION_CREDENTIALS", Value: "/var/run/secrets/ci.openshift.io/workload-identity/gcp-credentials.json"},
+				},
+				VolumeMounts: []coreapi.VolumeMount{{Name: "workload-identity", MountPath: WorkloadIdentityMountPath}},
+			}},
+			Volumes: []coreapi.Volume{{
+				Name: "workload-identity",
+				VolumeSource: coreapi.VolumeSource{Projected: &coreapi.ProjectedVolumeSource{Sources: []coreapi.VolumeProjection{
+					{ServiceAccountToken: &coreapi.ServiceAccountTokenProjection{Audience: "sts.amazonaws.com", ExpirationSeconds: &expiration, Path: "aws-token"}},
+					{ServiceAccountToken: &coreapi.ServiceAccountTokenProjection{Audience: "//iam.googleapis.com/pool", ExpirationSeconds: &expiration, Path: "gcp-token"}},
+					{ConfigMap: &coreapi.ConfigMapProjection{LocalObjectReference: coreapi.LocalObjectReference{Name: "test-workload-identity"}}},
+				}}},
+			}},
+		},
+	}
+	if diff := cmp.Diff(expected, pod); diff != "" {
+		t.Errorf("unexpected pod: %s", diff)
+	}
+}
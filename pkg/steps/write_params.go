@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"regexp"
 	"sort"
 	"strings"
@@ -28,12 +27,12 @@ func (s *writeParametersStep) Inputs() (api.InputDefinition, error) {
 
 func (*writeParametersStep) Validate() error { return nil }
 
-func (s *writeParametersStep) Run(_ context.Context) error {
-	return results.ForReason("writing_parameters").ForError(s.run())
+func (s *writeParametersStep) Run(ctx context.Context) error {
+	return results.ForReason(results.ReasonWritingParameters).ForError(s.run(ctx))
 }
 
-func (s *writeParametersStep) run() error {
-	log.Printf("Writing parameters to %s", s.paramFile)
+func (s *writeParametersStep) run(ctx context.Context) error {
+	Logger(ctx).Infof("Writing parameters to %s", s.paramFile)
 	var params []string
 
 	values, err := s.params.Map()
@@ -0,0 +1,195 @@
+// Package telemetry records which features of ci-operator a job uses and,
+// when opted into, reports them so maintainers know what is used in practice.
+// Reports are anonymized: they never identify the job, its repository or its
+// namespace, only count the kinds of steps, cache hits, retries and backends.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// Usage holds the anonymized counts of features used by a job
+type Usage struct {
+	// JobType is the type of job ("presubmit", "postsubmit", "periodic" or "batch")
+	JobType string `json:"job_type"`
+	// StepTypes counts the steps which ran by their type, e.g. multiStageTestStep
+	StepTypes map[string]int `json:"step_types,omitempty"`
+	// CacheHits counts results of previous runs which were reused, by kind
+	CacheHits map[string]int `json:"cache_hits,omitempty"`
+	// Retries counts the steps which were attempted more than once
+	Retries int `json:"retries,omitempty"`
+	// Backends counts the backends tests ran on, e.g. cluster profiles
+	Backends map[string]int `json:"backends,omitempty"`
+}
+
+type recorder struct {
+	lock      sync.Mutex
+	stepTypes map[string]int
+	cacheHits map[string]int
+	retries   int
+	backends  map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		stepTypes: map[string]int{},
+		cacheHits: map[string]int{},
+		backends:  map[string]int{},
+	}
+}
+
+// usage is recorded for the whole process, like metrics, so that steps need
+// not be handed a recorder explicitly; jobs executed by the same process
+// record to their own one through WithUsage instead
+var usage = newRecorder()
+
+type recorderKey struct{}
+
+// WithUsage returns a context whose steps record their usage apart from the
+// other jobs executed by the same process
+func WithUsage(ctx context.Context) context.Context {
+	return context.WithValue(ctx, recorderKey{}, newRecorder())
+}
+
+// CopyUsage returns a context which records to the same usage as the source
+// context, for contexts which are not derived from it
+func CopyUsage(ctx, source context.Context) context.Context {
+	if r, ok := source.Value(recorderKey{}).(*recorder); ok {
+		return context.WithValue(ctx, recorderKey{}, r)
+	}
+	return ctx
+}
+
+func recorderFor(ctx context.Context) *recorder {
+	if r, ok := ctx.Value(recorderKey{}).(*recorder); ok {
+		return r
+	}
+	return usage
+}
+
+// RecordStep records that a step of the type of the given step ran
+func RecordStep(ctx context.Context, step api.Step) {
+	t := reflect.TypeOf(step)
+	if t == nil {
+		return
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	r := recorderFor(ctx)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stepTypes[t.Name()]++
+}
+
+// RecordCacheHit records that a result of a previous run of the given kind,
+// e.g. a build, was reused
+func RecordCacheHit(ctx context.Context, kind string) {
+	r := recorderFor(ctx)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cacheHits[kind]++
+}
+
+// RecordRetry records that a step was attempted again
+func RecordRetry(ctx context.Context) {
+	r := recorderFor(ctx)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.retries++
+}
+
+// RecordBackend records that a test ran on the given backend
+func RecordBackend(ctx context.Context, backend string) {
+	r := recorderFor(ctx)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.backends[backend]++
+}
+
+func (r *recorder) snapshot(jobType string) Usage {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	copyCounts := func(counts map[string]int) map[string]int {
+		if len(counts) == 0 {
+			return nil
+		}
+		ret := make(map[string]int, len(counts))
+		for k, v := range counts {
+			ret[k] = v
+		}
+		return ret
+	}
+	return Usage{
+		JobType:   jobType,
+		StepTypes: copyCounts(r.stepTypes),
+		CacheHits: copyCounts(r.cacheHits),
+		Retries:   r.retries,
+		Backends:  copyCounts(r.backends),
+	}
+}
+
+// Options holds the configuration for reporting usage
+type Options struct {
+	address string
+}
+
+// Bind adds flags for the options
+func (o *Options) Bind(flag *flag.FlagSet) {
+	flag.StringVar(&o.address, "telemetry-address", "", "Address of the server to report anonymized feature usage to. Usage is not reported unless set.")
+}
+
+// Report sends the usage recorded so far in the context. Reporting is opt-in
+// and best-effort, so errors are logged but not exposed.
+func (o *Options) Report(ctx context.Context, spec *api.JobSpec) {
+	if o.address == "" {
+		return
+	}
+	var jobType string
+	if spec != nil {
+		jobType = string(spec.Type)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	if err := report(client, o.address, recorderFor(ctx).snapshot(jobType)); err != nil {
+		logrus.Tracef("could not report usage: %v", err)
+	}
+}
+
+func report(client *http.Client, address string, usage Usage) error {
+	data, err := json.Marshal(usage)
+	if err != nil {
+		return fmt.Errorf("could not marshal usage: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/usage", address), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not send request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logrus.Tracef("could not close usage response: %v", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("response was %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	v1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+type fakeStep struct{ api.Step }
+
+func TestReport(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		record   func(ctx context.Context)
+		expected string
+	}{
+		{
+			name:     "nothing recorded",
+			record:   func(context.Context) {},
+			expected: `{"job_type":"periodic"}`,
+		},
+		{
+			name: "usage of other jobs is not reported",
+			record: func(ctx context.Context) {
+				other := WithUsage(context.Background())
+				RecordStep(other, &fakeStep{})
+				RecordRetry(other)
+				RecordBackend(ctx, "aws")
+			},
+			expected: `{"job_type":"periodic","backends":{"aws":1}}`,
+		},
+		{
+			name: "usage is counted",
+			record: func(ctx context.Context) {
+				RecordStep(ctx, &fakeStep{})
+				RecordStep(ctx, fakeStep{})
+				RecordCacheHit(ctx, "build")
+				RecordRetry(ctx)
+				RecordRetry(ctx)
+				RecordBackend(ctx, "aws")
+			},
+			expected: `{"job_type":"periodic","step_types":{"fakeStep":2},"cache_hits":{"build":1},"retries":2,"backends":{"aws":1}}`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx := WithUsage(context.Background())
+			testCase.record(ctx)
+			var actual string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/usage" {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				body, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Errorf("failed to read body: %v", err)
+				}
+				actual = string(body)
+			}))
+			defer server.Close()
+			o := Options{address: server.URL}
+			o.Report(ctx, &api.JobSpec{JobSpec: downwardapi.JobSpec{Job: "secret-job", Type: v1.PeriodicJob}})
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("unexpected report: %s", diff)
+			}
+		})
+	}
+}
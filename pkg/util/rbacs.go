@@ -22,7 +22,7 @@ func CreateRBACs(ctx context.Context, sa *coreapi.ServiceAccount, role *rbacapi.
 
 	err := client.Create(ctx, sa)
 	if err != nil && !kerrors.IsAlreadyExists(err) {
-		return results.ForReason("creating_service_account").WithError(err).Errorf("could not create service account '%s'", sa.Name)
+		return results.ForReason(results.ReasonCreatingServiceAccount).WithError(err).Errorf("could not create service account '%s'", sa.Name)
 	}
 
 	if kerrors.IsAlreadyExists(err) {
@@ -30,11 +30,11 @@ func CreateRBACs(ctx context.Context, sa *coreapi.ServiceAccount, role *rbacapi.
 	}
 
 	if err := client.Create(ctx, role); err != nil && !kerrors.IsAlreadyExists(err) {
-		return results.ForReason("creating_roles").WithError(err).Errorf("could not create role '%s'", role.Name)
+		return results.ForReason(results.ReasonCreatingRoles).WithError(err).Errorf("could not create role '%s'", role.Name)
 	}
 	for _, roleBinding := range roleBindings {
 		if err := client.Create(ctx, &roleBinding); err != nil && !kerrors.IsAlreadyExists(err) {
-			return results.ForReason("binding_roles").WithError(err).Errorf("could not create role binding '%s'", roleBinding.Name)
+			return results.ForReason(results.ReasonBindingRoles).WithError(err).Errorf("could not create role binding '%s'", roleBinding.Name)
 		}
 	}
 
@@ -58,7 +58,7 @@ func CreateRBACs(ctx context.Context, sa *coreapi.ServiceAccount, role *rbacapi.
 		return true, nil
 	},
 	); err != nil {
-		return results.ForReason("create_dockercfg_secrets").WithError(err).Errorf("timeout while waiting for dockercfg secret creation for service account '%s'", sa.Name)
+		return results.ForReason(results.ReasonCreatingDockercfgSecrets).WithError(err).Errorf("timeout while waiting for dockercfg secret creation for service account '%s'", sa.Name)
 	}
 
 	return nil
@@ -0,0 +1,77 @@
+package util
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// sealingKeyLength is the length of the keys values are sealed with, which
+// selects AES-256
+const sealingKeyLength = 32
+
+// NewSealingKey generates a random key to seal values with, encoded so it
+// can be passed in the environment
+func NewSealingKey() (string, error) {
+	key := make([]byte, sealingKeyLength)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", fmt.Errorf("could not generate sealing key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+func sealingCipher(key string) (cipher.AEAD, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sealing key: %w", err)
+	}
+	if len(raw) != sealingKeyLength {
+		return nil, fmt.Errorf("invalid sealing key: expected %d bytes, got %d", sealingKeyLength, len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SealValues encrypts the values with the key, so that they can be stored
+// in a secret without being readable by anyone who can read the secret
+func SealValues(key string, values map[string][]byte) (map[string][]byte, error) {
+	gcm, err := sealingCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	sealed := make(map[string][]byte, len(values))
+	for name, value := range values {
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, fmt.Errorf("could not generate nonce: %w", err)
+		}
+		// the name is authenticated, so values cannot be swapped
+		sealed[name] = gcm.Seal(nonce, nonce, value, []byte(name))
+	}
+	return sealed, nil
+}
+
+// UnsealValues decrypts values sealed with the key
+func UnsealValues(key string, sealed map[string][]byte) (map[string][]byte, error) {
+	gcm, err := sealingCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(sealed))
+	for name, value := range sealed {
+		if len(value) < gcm.NonceSize() {
+			return nil, fmt.Errorf("could not unseal %s: value is too short", name)
+		}
+		nonce, ciphertext := value[:gcm.NonceSize()], value[gcm.NonceSize():]
+		if values[name], err = gcm.Open(nil, nonce, ciphertext, []byte(name)); err != nil {
+			return nil, fmt.Errorf("could not unseal %s: %w", name, err)
+		}
+	}
+	return values, nil
+}
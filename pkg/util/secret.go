@@ -9,6 +9,7 @@ import (
 
 	coreapi "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -41,24 +42,28 @@ func SecretFromDir(path string) (*coreapi.Secret, error) {
 
 // UpdateSecret adds new values to an existing secret.
 // New values are added, existing values are overwritten. The secret will be
-// created if it doesn't already exist.
+// created if it doesn't already exist. Concurrent updates of the secret are
+// retried, so the function can be called for secrets that are being set up
+// by another client at the same time.
 func UpdateSecret(ctx context.Context, client ctrlruntimeclient.Client, secret *coreapi.Secret) (created bool, err error) {
-	err = client.Create(ctx, secret)
+	err = client.Create(ctx, secret.DeepCopy())
 	if err == nil {
 		return true, nil
 	}
 	if !kerrors.IsAlreadyExists(err) {
 		return false, err
 	}
-	existing := &coreapi.Secret{}
-	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Name: secret.Name}, existing); err != nil {
-		return false, err
-	}
-	if l := len(secret.Data); l != 0 && existing.Data == nil {
-		existing.Data = make(map[string][]byte, l)
-	}
-	for k, v := range secret.Data {
-		existing.Data[k] = v
-	}
-	return false, client.Update(ctx, existing)
+	return false, retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing := &coreapi.Secret{}
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: secret.Namespace, Name: secret.Name}, existing); err != nil {
+			return err
+		}
+		if l := len(secret.Data); l != 0 && existing.Data == nil {
+			existing.Data = make(map[string][]byte, l)
+		}
+		for k, v := range secret.Data {
+			existing.Data[k] = v
+		}
+		return client.Update(ctx, existing)
+	})
 }
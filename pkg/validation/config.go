@@ -3,12 +3,20 @@ package validation
 import (
 	"errors"
 	"fmt"
+	"net/mail"
+	"net/url"
+	"path"
+	"regexp"
 	"strings"
 
+	"github.com/docker/distribution/reference"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/sets"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/quay"
+	"github.com/openshift/ci-tools/pkg/steps/utils"
 )
 
 // ValidateAtRuntime validates all the configuration's values without knowledge of config
@@ -33,6 +41,15 @@ func IsValidConfiguration(config *api.ReleaseBuildConfiguration, org, repo strin
 func validateConfiguration(config *api.ReleaseBuildConfiguration, org, repo string, resolved bool) error {
 	var validationErrors []error
 
+	// tests are validated as they run, after their matrices are expanded
+	if tests, err := api.ExpandTestMatrices(config.Tests); err != nil {
+		validationErrors = append(validationErrors, err)
+	} else {
+		expanded := *config
+		expanded.Tests = tests
+		config = &expanded
+	}
+
 	validationErrors = append(validationErrors, validateReleaseBuildConfiguration(config, org, repo)...)
 	validationErrors = append(validationErrors, validateBuildRootImageConfiguration("build_root", config.InputConfiguration.BuildRootImage, len(config.Images) > 0))
 	releases := sets.NewString()
@@ -67,6 +84,10 @@ func validateConfiguration(config *api.ReleaseBuildConfiguration, org, repo stri
 		validationErrors = append(validationErrors, validateImageStreamTagReferenceMap("base_rpm_images", config.InputConfiguration.BaseRPMImages)...)
 	}
 
+	if config.InputConfiguration.SignedBaseImages != nil {
+		validationErrors = append(validationErrors, validateSignedBaseImages("signed_base_images", config.InputConfiguration.SignedBaseImages, config.InputConfiguration.BaseImages)...)
+	}
+
 	// Validate tag_specification
 	if config.InputConfiguration.ReleaseTagConfiguration != nil {
 		validationErrors = append(validationErrors, validateReleaseTagConfiguration("tag_specification", *config.InputConfiguration.ReleaseTagConfiguration)...)
@@ -79,6 +100,38 @@ func validateConfiguration(config *api.ReleaseBuildConfiguration, org, repo stri
 
 	validationErrors = append(validationErrors, validateReleases("releases", config.Releases, config.ReleaseTagConfiguration != nil)...)
 
+	if config.Attestations != nil {
+		validationErrors = append(validationErrors, validateAttestations("attestations", *config.Attestations, len(config.Images))...)
+	}
+
+	if len(config.Mirror) > 0 {
+		validationErrors = append(validationErrors, validateImageMirrors("mirror", config.Mirror)...)
+	}
+
+	if config.VulnerabilityScan != nil {
+		validationErrors = append(validationErrors, validateVulnerabilityScan("vulnerability_scan", *config.VulnerabilityScan, config.Images)...)
+	}
+
+	if config.FIPSCheck != nil {
+		validationErrors = append(validationErrors, validateFIPSCheck("fips_check", *config.FIPSCheck, config.Images)...)
+	}
+
+	if config.Approval != nil {
+		validationErrors = append(validationErrors, validateApproval("approval", *config.Approval)...)
+	}
+
+	if config.Contacts != nil {
+		validationErrors = append(validationErrors, validateContacts("contacts", *config.Contacts)...)
+	}
+	validationErrors = append(validationErrors, validateStatusContexts("status_contexts", config.StatusContexts)...)
+	if config.Namespace != nil {
+		validationErrors = append(validationErrors, validateNamespace("namespace", *config.Namespace)...)
+	}
+	validationErrors = append(validationErrors, validateCloneIntoPods(config)...)
+	if config.Clonerefs != nil {
+		validationErrors = append(validationErrors, validateClonerefs("clonerefs", *config.Clonerefs)...)
+	}
+
 	var lines []string
 	for _, err := range validationErrors {
 		if err == nil {
@@ -96,6 +149,159 @@ func validateConfiguration(config *api.ReleaseBuildConfiguration, org, repo stri
 	}
 }
 
+func validateContacts(fieldRoot string, contacts api.Contacts) []error {
+	var validationErrors []error
+	if contacts.Team == "" {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.team: value required but not provided", fieldRoot))
+	}
+	if contacts.SlackChannel != "" && !strings.HasPrefix(contacts.SlackChannel, "#") {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.slack_channel: must start with '#', not %q", fieldRoot, contacts.SlackChannel))
+	}
+	if contacts.Email != "" {
+		if _, err := mail.ParseAddress(contacts.Email); err != nil {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.email: invalid address %q: %w", fieldRoot, contacts.Email, err))
+		}
+	}
+	if contacts.Escalation != "" {
+		if u, err := url.Parse(contacts.Escalation); err != nil || u.Scheme == "" || u.Host == "" {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.escalation: must be an absolute URL, not %q", fieldRoot, contacts.Escalation))
+		}
+	}
+	return validationErrors
+}
+
+// statusDescriptionLimit is the longest description GitHub accepts for a
+// commit status
+const statusDescriptionLimit = 140
+
+func validateStatusContexts(fieldRoot string, contexts []api.StatusContext) []error {
+	var validationErrors []error
+	seen := sets.NewString()
+	for i, context := range contexts {
+		root := fmt.Sprintf("%s[%d]", fieldRoot, i)
+		if context.Context == "" {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.context: value required but not provided", root))
+		} else if seen.Has(context.Context) {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.context: duplicated context %q", root, context.Context))
+		} else {
+			seen.Insert(context.Context)
+		}
+		if len(context.Description) > statusDescriptionLimit {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.description: must be at most %d characters long", root, statusDescriptionLimit))
+		}
+		if len(context.Steps) == 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.steps: value required but not provided", root))
+		}
+		for j, step := range context.Steps {
+			if step == "" {
+				validationErrors = append(validationErrors, fmt.Errorf("%s.steps[%d]: must not be empty", root, j))
+			}
+		}
+	}
+	return validationErrors
+}
+
+// validateCloneIntoPods ensures nothing needs the src image when it is not
+// built; multi-stage steps are checked with the other test dependencies
+func validateCloneIntoPods(config *api.ReleaseBuildConfiguration) []error {
+	if !config.CloneIntoPods {
+		return nil
+	}
+	var validationErrors []error
+	if config.BuildRootImage == nil {
+		validationErrors = append(validationErrors, errors.New("clone_into_pods: tests run from the build root, which is not configured"))
+	}
+	for _, field := range []struct {
+		name string
+		set  bool
+	}{
+		{name: "binary_build_commands", set: config.BinaryBuildCommands != ""},
+		{name: "test_binary_build_commands", set: config.TestBinaryBuildCommands != ""},
+		{name: "rpm_build_commands", set: config.RpmBuildCommands != ""},
+		{name: "images", set: len(config.Images) > 0},
+		{name: "operator", set: config.Operator != nil},
+	} {
+		if field.set {
+			validationErrors = append(validationErrors, fmt.Errorf("clone_into_pods: cannot be set with %s, which needs the src image", field.name))
+		}
+	}
+	return validationErrors
+}
+
+// validateClonerefs ensures the image the source is cloned with is fully
+// qualified and the path of the tool in it absolute
+func validateClonerefs(fieldRoot string, clonerefs api.ClonerefsConfiguration) []error {
+	var validationErrors []error
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{name: "namespace", value: clonerefs.Image.Namespace},
+		{name: "name", value: clonerefs.Image.Name},
+		{name: "tag", value: clonerefs.Image.Tag},
+	} {
+		if field.value == "" {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.image.%s: must be set", fieldRoot, field.name))
+		}
+	}
+	if clonerefs.Image.As != "" {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.image.as: cannot be set", fieldRoot))
+	}
+	if clonerefs.Path != "" && !strings.HasPrefix(clonerefs.Path, "/") {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.path: must be absolute, not %q", fieldRoot, clonerefs.Path))
+	}
+	return validationErrors
+}
+
+// limitRangeResources are the resources a limit range may constrain for
+// containers
+var limitRangeResources = sets.NewString("cpu", "memory", "ephemeral-storage")
+
+func validateNamespace(fieldRoot string, namespace api.NamespaceConfiguration) []error {
+	var validationErrors []error
+	for _, key := range sets.StringKeySet(namespace.Quota).List() {
+		if errs := k8svalidation.IsQualifiedName(key); len(errs) != 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.quota: invalid resource name %q: %s", fieldRoot, key, strings.Join(errs, ", ")))
+		}
+		validationErrors = append(validationErrors, validateNonNegativeQuantity(fmt.Sprintf("%s.quota.%s", fieldRoot, key), namespace.Quota[key])...)
+	}
+	if limitRange := namespace.LimitRange; limitRange != nil {
+		for _, item := range []struct {
+			field string
+			list  api.ResourceList
+		}{{"default", limitRange.Default}, {"default_request", limitRange.DefaultRequest}, {"max", limitRange.Max}} {
+			root := fmt.Sprintf("%s.limit_range.%s", fieldRoot, item.field)
+			for _, key := range sets.StringKeySet(item.list).List() {
+				if !limitRangeResources.Has(key) {
+					validationErrors = append(validationErrors, fmt.Errorf("%s: invalid resource %q, must be one of %s", root, key, strings.Join(limitRangeResources.List(), ", ")))
+					continue
+				}
+				validationErrors = append(validationErrors, validateNonNegativeQuantity(fmt.Sprintf("%s.%s", root, key), item.list[key])...)
+			}
+		}
+		if len(limitRange.Default) == 0 && len(limitRange.DefaultRequest) == 0 && len(limitRange.Max) == 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.limit_range: should have at least one default, default request or max", fieldRoot))
+		}
+	}
+	if namespace.PriorityClassName != "" {
+		if errs := k8svalidation.IsDNS1123Subdomain(namespace.PriorityClassName); len(errs) != 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.priority_class_name: invalid name %q: %s", fieldRoot, namespace.PriorityClassName, strings.Join(errs, ", ")))
+		}
+	}
+	return validationErrors
+}
+
+func validateNonNegativeQuantity(fieldRoot, value string) []error {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return []error{fmt.Errorf("%s: invalid quantity: %w", fieldRoot, err)}
+	}
+	if quantity.Sign() == -1 {
+		return []error{fmt.Errorf("%s: quantity cannot be negative", fieldRoot)}
+	}
+	return nil
+}
+
 func validateBuildRootImageConfiguration(fieldRoot string, input *api.BuildRootImageConfiguration, hasImages bool) error {
 	if input == nil {
 		if hasImages {
@@ -147,6 +353,44 @@ func validateImages(fieldRoot string, input []api.ProjectDirectoryImageBuildStep
 		if image.DockerfileLiteral != nil && (image.ContextDir != "" || image.DockerfilePath != "") {
 			validationErrors = append(validationErrors, fmt.Errorf("%s: dockerfile_literal is mutually exclusive with context_dir and dockerfile_path", fieldRootN))
 		}
+		validationErrors = append(validationErrors, validateBuildArgs(fieldRootN+".build_args", image.BuildArgs)...)
+	}
+	return validationErrors
+}
+
+// buildArgName restricts build arguments to names an ARG instruction accepts
+var buildArgName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func validateBuildArgs(fieldRoot string, args []api.BuildArg) []error {
+	var validationErrors []error
+	seen := sets.NewString()
+	for num, arg := range args {
+		fieldRootN := fmt.Sprintf("%s[%d]", fieldRoot, num)
+		if !buildArgName.MatchString(arg.Name) {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.name: %q is not a valid build argument name", fieldRootN, arg.Name))
+		} else if seen.Has(arg.Name) {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.name: duplicate build argument %s", fieldRootN, arg.Name))
+		}
+		seen.Insert(arg.Name)
+		if arg.ValueFrom == nil {
+			continue
+		}
+		if arg.Value != "" {
+			validationErrors = append(validationErrors, fmt.Errorf("%s: value and value_from are mutually exclusive", fieldRootN))
+		}
+		source := arg.ValueFrom
+		switch {
+		case (source.JobMetadata == "") == (source.Parameter == ""):
+			validationErrors = append(validationErrors, fmt.Errorf("%s.value_from: exactly one of job_metadata or parameter must be set", fieldRootN))
+		case source.Parameter == utils.ImageFormatEnv:
+			validationErrors = append(validationErrors, fmt.Errorf("%s.value_from.parameter: %s is not available until all images are built", fieldRootN, utils.ImageFormatEnv))
+		case source.JobMetadata != "":
+			switch source.JobMetadata {
+			case api.BuildArgCommit, api.BuildArgBaseRef, api.BuildArgBaseSHA, api.BuildArgPullNumber, api.BuildArgJobName, api.BuildArgBuildID:
+			default:
+				validationErrors = append(validationErrors, fmt.Errorf("%s.value_from.job_metadata: unknown property %q", fieldRootN, source.JobMetadata))
+			}
+		}
 	}
 	return validationErrors
 }
@@ -166,6 +410,46 @@ func validateOperator(fieldRoot string, input *api.OperatorStepConfiguration, li
 			validationErrors = append(validationErrors, fmt.Errorf("%s.with: could not resolve '%s' to an image involved in the config", fieldRootN, sub.With))
 		}
 	}
+	for num, bundle := range input.Bundles {
+		fieldRootN := fmt.Sprintf("%s.bundles[%d]", fieldRoot, num)
+		for i, channel := range bundle.Channels {
+			if !channelName.MatchString(channel) {
+				validationErrors = append(validationErrors, fmt.Errorf("%s.channels[%d]: %q is not a valid channel name", fieldRootN, i, channel))
+			}
+		}
+		if bundle.DefaultChannel != "" && !channelName.MatchString(bundle.DefaultChannel) {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.default_channel: %q is not a valid channel name", fieldRootN, bundle.DefaultChannel))
+		}
+	}
+	if input.Index != nil {
+		validationErrors = append(validationErrors, validateOperatorIndex(fieldRoot+".index", *input.Index)...)
+	}
+	return validationErrors
+}
+
+// channelName restricts channels to names which need no quoting when the
+// metadata of bundles is rewritten
+var channelName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+func validateOperatorIndex(fieldRoot string, index api.OperatorIndexConfiguration) []error {
+	var validationErrors []error
+	if index.BaseIndex != "" {
+		if _, err := reference.ParseNormalizedNamed(index.BaseIndex); err != nil {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.base_index: invalid pull spec %q: %v", fieldRoot, index.BaseIndex, err))
+		}
+	}
+	switch index.UpdateGraph {
+	case "", api.IndexUpdateGraphSemver, api.IndexUpdateGraphSemverSkipPatch, api.IndexUpdateGraphReplaces:
+	default:
+		validationErrors = append(validationErrors, fmt.Errorf("%s.update_graph: must be one of %s, %s, %s, not %q", fieldRoot, api.IndexUpdateGraphSemver, api.IndexUpdateGraphSemverSkipPatch, api.IndexUpdateGraphReplaces, index.UpdateGraph))
+	}
+	if index.PushTo != "" {
+		if named, err := reference.ParseNormalizedNamed(index.PushTo); err != nil {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.push_to: invalid pull spec %q: %v", fieldRoot, index.PushTo, err))
+		} else if _, tagged := named.(reference.Tagged); !tagged {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.push_to: pull spec %q must have a tag", fieldRoot, index.PushTo))
+		}
+	}
 	return validationErrors
 }
 
@@ -190,6 +474,125 @@ func validateImageStreamTagReferenceMap(fieldRoot string, input map[string]api.I
 	return validationErrors
 }
 
+func validateAttestations(fieldRoot string, attestations api.AttestationConfiguration, images int) []error {
+	var validationErrors []error
+	if images == 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s: requires images to be built", fieldRoot))
+	}
+	switch attestations.SBOMFormat {
+	case "", api.SBOMFormatSPDX, api.SBOMFormatCycloneDX:
+	default:
+		validationErrors = append(validationErrors, fmt.Errorf("%s.sbom_format: must be one of %s, %s, not %q", fieldRoot, api.SBOMFormatSPDX, api.SBOMFormatCycloneDX, attestations.SBOMFormat))
+	}
+	return validationErrors
+}
+
+func validateImageMirrors(fieldRoot string, mirrors []api.ImageMirror) []error {
+	var validationErrors []error
+	destinations := sets.NewString()
+	for i, mirror := range mirrors {
+		fieldRoot := fmt.Sprintf("%s[%d]", fieldRoot, i)
+		if parts := strings.Split(mirror.From, ":"); len(parts) != 2 || parts[1] == "" || (parts[0] != api.PipelineImageStream && !api.IsReleaseStream(parts[0])) {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.from: must be a tag of the %s or a stable image stream like %s:bin, not %q", fieldRoot, api.PipelineImageStream, api.PipelineImageStream, mirror.From))
+		}
+		named, err := reference.ParseNormalizedNamed(mirror.To)
+		if err != nil {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.to: invalid pull spec %q: %v", fieldRoot, mirror.To, err))
+			continue
+		}
+		if _, tagged := named.(reference.Tagged); !tagged {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.to: pull spec %q must have a tag", fieldRoot, mirror.To))
+		}
+		if destinations.Has(mirror.To) {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.to: %s is mirrored to more than once", fieldRoot, mirror.To))
+		}
+		destinations.Insert(mirror.To)
+	}
+	return validationErrors
+}
+
+func validateVulnerabilityScan(fieldRoot string, scan api.VulnerabilityScanConfiguration, images []api.ProjectDirectoryImageBuildStepConfiguration) []error {
+	var validationErrors []error
+	if len(images) == 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s: requires images to be built", fieldRoot))
+	}
+	switch scan.Scanner {
+	case "", api.VulnerabilityScannerTrivy:
+		if scan.ClairURL != "" {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.clair_url: can only be set with the %s scanner", fieldRoot, api.VulnerabilityScannerClair))
+		}
+	case api.VulnerabilityScannerClair:
+		if u, err := url.Parse(scan.ClairURL); err != nil || u.Scheme == "" || u.Host == "" {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.clair_url: must be an absolute URL, not %q", fieldRoot, scan.ClairURL))
+		}
+	default:
+		validationErrors = append(validationErrors, fmt.Errorf("%s.scanner: must be one of %s, %s, not %q", fieldRoot, api.VulnerabilityScannerTrivy, api.VulnerabilityScannerClair, scan.Scanner))
+	}
+	built := sets.NewString()
+	for _, image := range images {
+		built.Insert(string(image.To))
+	}
+	for i, image := range scan.Images {
+		if !built.Has(string(image)) {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.images[%d]: %s is not built by the job", fieldRoot, i, image))
+		}
+	}
+	if severities := api.VulnerabilitySeverities[1:]; scan.SeverityThreshold != "" && !sets.NewString(severities...).Has(scan.SeverityThreshold) {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.severity_threshold: must be one of %s, not %q", fieldRoot, strings.Join(severities, ", "), scan.SeverityThreshold))
+	}
+	switch scan.Action {
+	case "", api.VulnerabilityScanActionFail, api.VulnerabilityScanActionAnnotate:
+	default:
+		validationErrors = append(validationErrors, fmt.Errorf("%s.action: must be one of %s, %s, not %q", fieldRoot, api.VulnerabilityScanActionFail, api.VulnerabilityScanActionAnnotate, scan.Action))
+	}
+	return validationErrors
+}
+
+func validateFIPSCheck(fieldRoot string, check api.FIPSCheckConfiguration, images []api.ProjectDirectoryImageBuildStepConfiguration) []error {
+	var validationErrors []error
+	if len(check.Images) == 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.images: at least one image must be checked", fieldRoot))
+	}
+	built := sets.NewString()
+	for _, image := range images {
+		built.Insert(string(image.To))
+	}
+	for i, image := range check.Images {
+		if !built.Has(string(image)) {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.images[%d]: %s is not built by the job", fieldRoot, i, image))
+		}
+	}
+	for i, dir := range check.Paths {
+		if !path.IsAbs(dir) || path.Clean(dir) != dir {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.paths[%d]: must be a clean absolute path, not %q", fieldRoot, i, dir))
+		}
+	}
+	for i, symbol := range check.ForbiddenSymbols {
+		if strings.TrimSpace(symbol) == "" || strings.Contains(symbol, "\n") {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.forbidden_symbols[%d]: must be a single non-empty line, not %q", fieldRoot, i, symbol))
+		}
+	}
+	switch check.Action {
+	case "", api.FIPSCheckActionFail, api.FIPSCheckActionWarn:
+	default:
+		validationErrors = append(validationErrors, fmt.Errorf("%s.action: must be one of %s, %s, not %q", fieldRoot, api.FIPSCheckActionFail, api.FIPSCheckActionWarn, check.Action))
+	}
+	return validationErrors
+}
+
+func validateApproval(fieldRoot string, approval api.ApprovalConfiguration) []error {
+	var validationErrors []error
+	if approval.URL != "" {
+		if parsed, err := url.Parse(approval.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.url: must be an absolute HTTP(S) URL, not %q", fieldRoot, approval.URL))
+		}
+	}
+	if approval.Timeout != nil && approval.Timeout.Duration <= 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.timeout: must be positive", fieldRoot))
+	}
+	return validationErrors
+}
+
 func validatePromotionConfiguration(fieldRoot string, input api.PromotionConfiguration) []error {
 	var validationErrors []error
 
@@ -204,6 +607,95 @@ func validatePromotionConfiguration(fieldRoot string, input api.PromotionConfigu
 	if len(input.Name) != 0 && len(input.Tag) != 0 {
 		validationErrors = append(validationErrors, fmt.Errorf("%s: both name and tag defined", fieldRoot))
 	}
+
+	for i, target := range input.Targets {
+		validationErrors = append(validationErrors, validatePromotionTarget(fmt.Sprintf("%s.targets[%d]", fieldRoot, i), target)...)
+	}
+	if input.Signing != nil {
+		validationErrors = append(validationErrors, validateImageSigning(fieldRoot+".signing", *input.Signing)...)
+	}
+	return validationErrors
+}
+
+func validateSignedBaseImages(fieldRoot string, signed map[string]api.ImageSigningConfiguration, baseImages map[string]api.ImageStreamTagReference) []error {
+	var validationErrors []error
+	for alias, signature := range signed {
+		if _, ok := baseImages[alias]; !ok {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.%s: no such base image", fieldRoot, alias))
+		}
+		validationErrors = append(validationErrors, validateImageSigning(fmt.Sprintf("%s.%s", fieldRoot, alias), signature)...)
+	}
+	return validationErrors
+}
+
+func validateImageSigning(fieldRoot string, input api.ImageSigningConfiguration) []error {
+	var validationErrors []error
+	if input.Keyless && input.OIDCIssuer == "" {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.oidc_issuer: must be set for keyless signing", fieldRoot))
+	}
+	if !input.Keyless && input.FulcioURL != "" {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.fulcio_url: can only be set for keyless signing", fieldRoot))
+	}
+	for _, field := range []struct{ name, value string }{
+		{name: "fulcio_url", value: input.FulcioURL},
+		{name: "rekor_url", value: input.RekorURL},
+		{name: "oidc_issuer", value: input.OIDCIssuer},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if u, err := url.Parse(field.value); err != nil || u.Scheme == "" || u.Host == "" {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.%s: must be an absolute URL, not %q", fieldRoot, field.name, field.value))
+		}
+	}
+	return validationErrors
+}
+
+var tagTemplateVariable = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+func validatePromotionTarget(fieldRoot string, target api.PromotionTarget) []error {
+	var validationErrors []error
+	if len(target.Namespace) == 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s: no namespace defined", fieldRoot))
+	}
+	if len(target.Name) == 0 && len(target.Tag) == 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s: no name or tag defined", fieldRoot))
+	}
+	if len(target.Name) != 0 && len(target.Tag) != 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s: both name and tag defined", fieldRoot))
+	}
+	if target.Quay != nil {
+		roles := sets.NewString()
+		for _, role := range quay.Roles {
+			roles.Insert(string(role))
+		}
+		robots := sets.StringKeySet(target.Quay.RobotPermissions)
+		for _, robot := range robots.List() {
+			if robot == "" {
+				validationErrors = append(validationErrors, fmt.Errorf("%s.quay.robot_permissions: robot name cannot be empty", fieldRoot))
+			}
+			if role := target.Quay.RobotPermissions[robot]; !roles.Has(role) {
+				validationErrors = append(validationErrors, fmt.Errorf("%s.quay.robot_permissions.%s: role must be one of %s, not %q", fieldRoot, robot, strings.Join(roles.List(), ", "), role))
+			}
+		}
+	}
+	if target.TagTemplate == "" {
+		return validationErrors
+	}
+	if len(target.Name) == 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.tag_template: can only be set with name", fieldRoot))
+	}
+	known := sets.NewString(api.PromotionTagTemplateVariables...)
+	var hasComponent bool
+	for _, match := range tagTemplateVariable.FindAllStringSubmatch(target.TagTemplate, -1) {
+		if !known.Has(match[1]) {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.tag_template: unknown variable ${%s}, must be one of %s", fieldRoot, match[1], strings.Join(api.PromotionTagTemplateVariables, ", ")))
+		}
+		hasComponent = hasComponent || match[1] == "component"
+	}
+	if !hasComponent {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.tag_template: must contain ${component}", fieldRoot))
+	}
 	return validationErrors
 }
 
@@ -243,6 +735,36 @@ func validateReleaseBuildConfiguration(input *api.ReleaseBuildConfiguration, org
 	}
 
 	validationErrors = append(validationErrors, validateResources("resources", input.Resources)...)
+	validationErrors = append(validationErrors, validateArtifactGathering("artifact_gathering", input.ArtifactGathering, input.Tests)...)
+	return validationErrors
+}
+
+func validateArtifactGathering(fieldRoot string, config api.ArtifactGatheringConfiguration, tests []api.TestStepConfiguration) []error {
+	var validationErrors []error
+	multiStage := sets.NewString()
+	for _, test := range tests {
+		if test.MultiStageTestConfiguration != nil || test.MultiStageTestConfigurationLiteral != nil {
+			multiStage.Insert(test.As)
+		}
+	}
+	for _, step := range sets.StringKeySet(config).List() {
+		gathering := config[step]
+		if multiStage.Has(step) {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.%s: the steps of multi-stage tests upload their own artifacts, which cannot be limited", fieldRoot, step))
+		}
+		if gathering.MaxSize != "" {
+			if size, err := resource.ParseQuantity(gathering.MaxSize); err != nil {
+				validationErrors = append(validationErrors, fmt.Errorf("%s.%s.max_size: invalid quantity: %w", fieldRoot, step, err))
+			} else if size.Sign() <= 0 {
+				validationErrors = append(validationErrors, fmt.Errorf("%s.%s.max_size must be positive, got %s", fieldRoot, step, gathering.MaxSize))
+			}
+		}
+		for i, dir := range gathering.Compress {
+			if cleaned := path.Clean(dir); dir == "" || path.IsAbs(dir) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+				validationErrors = append(validationErrors, fmt.Errorf("%s.%s.compress[%d]: must be a directory inside the artifact directory, got %q", fieldRoot, step, i, dir))
+			}
+		}
+	}
 	return validationErrors
 }
 
@@ -3,14 +3,18 @@ package validation
 import (
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/utils/diff"
 	utilpointer "k8s.io/utils/pointer"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/testhelper"
 )
 
 func TestValidateBuildRoot(t *testing.T) {
@@ -110,6 +114,36 @@ func TestValidateBaseImages(t *testing.T) {
 	}
 }
 
+func TestValidateSignedBaseImages(t *testing.T) {
+	baseImages := map[string]api.ImageStreamTagReference{"base": {Namespace: "ocp", Name: "4.8", Tag: "base"}}
+	for _, tc := range []struct {
+		id       string
+		signed   map[string]api.ImageSigningConfiguration
+		expected []error
+	}{
+		{
+			id:     "signed base image",
+			signed: map[string]api.ImageSigningConfiguration{"base": {}},
+		},
+		{
+			id:       "no such base image",
+			signed:   map[string]api.ImageSigningConfiguration{"other": {}},
+			expected: []error{errors.New("signed_base_images.other: no such base image")},
+		},
+		{
+			id:       "invalid signing configuration",
+			signed:   map[string]api.ImageSigningConfiguration{"base": {Keyless: true}},
+			expected: []error{errors.New("signed_base_images.base.oidc_issuer: must be set for keyless signing")},
+		},
+	} {
+		t.Run(tc.id, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expected, validateSignedBaseImages("signed_base_images", tc.signed, baseImages), testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("unexpected errors: %s", diff)
+			}
+		})
+	}
+}
+
 func TestValidateBaseRpmImages(t *testing.T) {
 	for _, tc := range []struct {
 		id            string
@@ -257,6 +291,59 @@ func TestValidateResources(t *testing.T) {
 	}
 }
 
+func TestValidateArtifactGathering(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		input    api.ArtifactGatheringConfiguration
+		tests    []api.TestStepConfiguration
+		expected []error
+	}{
+		{
+			name: "no configuration is valid",
+		},
+		{
+			name: "valid configuration",
+			input: api.ArtifactGatheringConfiguration{
+				"*":   {MaxSize: "10Gi", Compress: []string{"must-gather", "logs/nodes"}},
+				"e2e": {MaxSize: "50Gi"},
+			},
+		},
+		{
+			name:     "invalid size",
+			input:    api.ArtifactGatheringConfiguration{"*": {MaxSize: "0"}},
+			expected: []error{errors.New("artifact_gathering.*.max_size must be positive, got 0")},
+		},
+		{
+			name:  "directories outside of the artifacts",
+			input: api.ArtifactGatheringConfiguration{"e2e": {Compress: []string{"/tmp", "../other", "."}}},
+			expected: []error{
+				errors.New(`artifact_gathering.e2e.compress[0]: must be a directory inside the artifact directory, got "/tmp"`),
+				errors.New(`artifact_gathering.e2e.compress[1]: must be a directory inside the artifact directory, got "../other"`),
+				errors.New(`artifact_gathering.e2e.compress[2]: must be a directory inside the artifact directory, got "."`),
+			},
+		},
+		{
+			name:  "multi-stage test",
+			input: api.ArtifactGatheringConfiguration{"*": {MaxSize: "10Gi"}, "e2e": {MaxSize: "50Gi"}, "unit": {MaxSize: "1Gi"}},
+			tests: []api.TestStepConfiguration{
+				{As: "e2e", MultiStageTestConfiguration: &api.MultiStageTestConfiguration{}},
+				{As: "unit", ContainerTestConfiguration: &api.ContainerTestConfiguration{}},
+			},
+			expected: []error{errors.New("artifact_gathering.e2e: the steps of multi-stage tests upload their own artifacts, which cannot be limited")},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := validateArtifactGathering("artifact_gathering", testCase.input, testCase.tests)
+			if diff := cmp.Diff(testCase.expected, actual, cmp.Comparer(func(x, y error) bool {
+				return x.Error() == y.Error()
+			})); diff != "" {
+				t.Errorf("unexpected errors: %s", diff)
+			}
+		})
+	}
+}
+
 func TestValidatePromotion(t *testing.T) {
 	var testCases = []struct {
 		name     string
@@ -283,6 +370,61 @@ func TestValidatePromotion(t *testing.T) {
 			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", Tag: "baz"},
 			expected: []error{errors.New("promotion: both name and tag defined")},
 		},
+		{
+			name: "additional targets are valid",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", Targets: []api.PromotionTarget{
+				{Namespace: "other", Tag: "latest"},
+				{Namespace: "other", Name: "all", TagTemplate: "${component}-${branch}"},
+			}},
+			expected: nil,
+		},
+		{
+			name: "invalid targets yield errors",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", Targets: []api.PromotionTarget{
+				{Tag: "latest", TagTemplate: "${component}"},
+				{Namespace: "other", Name: "all", TagTemplate: "${branch}-${release}"},
+			}},
+			expected: []error{
+				errors.New("promotion.targets[0]: no namespace defined"),
+				errors.New("promotion.targets[0].tag_template: can only be set with name"),
+				errors.New("promotion.targets[1].tag_template: unknown variable ${release}, must be one of component, org, repo, branch, variant"),
+				errors.New("promotion.targets[1].tag_template: must contain ${component}"),
+			},
+		},
+		{
+			name: "Quay target with invalid robot permissions",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", Targets: []api.PromotionTarget{
+				{Namespace: "openshift", Tag: "latest", Quay: &api.QuayPromotion{RobotPermissions: map[string]string{"ci": "write", "mirror": "push"}}},
+			}},
+			expected: []error{
+				errors.New(`promotion.targets[0].quay.robot_permissions.mirror: role must be one of admin, read, write, not "push"`),
+			},
+		},
+		{
+			name:  "signing with a key",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", Signing: &api.ImageSigningConfiguration{RekorURL: "https://rekor.example.com"}},
+		},
+		{
+			name: "keyless signing",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", Signing: &api.ImageSigningConfiguration{
+				Keyless: true, FulcioURL: "https://fulcio.example.com", OIDCIssuer: "https://oidc.example.com",
+			}},
+		},
+		{
+			name: "invalid signing yields errors",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", Signing: &api.ImageSigningConfiguration{
+				FulcioURL: "https://fulcio.example.com", RekorURL: "rekor",
+			}},
+			expected: []error{
+				errors.New("promotion.signing.fulcio_url: can only be set for keyless signing"),
+				errors.New(`promotion.signing.rekor_url: must be an absolute URL, not "rekor"`),
+			},
+		},
+		{
+			name:     "keyless signing without issuer",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", Signing: &api.ImageSigningConfiguration{Keyless: true}},
+			expected: []error{errors.New("promotion.signing.oidc_issuer: must be set for keyless signing")},
+		},
 	}
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
@@ -293,6 +435,448 @@ func TestValidatePromotion(t *testing.T) {
 	}
 }
 
+func TestValidateAttestations(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		input    api.AttestationConfiguration
+		images   int
+		expected []error
+	}{
+		{
+			name:   "default format",
+			images: 1,
+		},
+		{
+			name:   "CycloneDX format",
+			input:  api.AttestationConfiguration{SBOMFormat: api.SBOMFormatCycloneDX},
+			images: 2,
+		},
+		{
+			name:  "no images and unknown format",
+			input: api.AttestationConfiguration{SBOMFormat: "syft-json"},
+			expected: []error{
+				errors.New("attestations: requires images to be built"),
+				errors.New(`attestations.sbom_format: must be one of spdx-json, cyclonedx-json, not "syft-json"`),
+			},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, validateAttestations("attestations", test.input, test.images), cmp.Comparer(func(x, y error) bool {
+				return x.Error() == y.Error()
+			})); diff != "" {
+				t.Errorf("got incorrect errors: %s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateImageMirrors(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		input    []api.ImageMirror
+		expected []error
+	}{
+		{
+			name: "valid mirrors",
+			input: []api.ImageMirror{
+				{From: "pipeline:bin", To: "quay.io/org/bin:latest"},
+				{From: "stable-initial:installer", To: "registry.example.com:5000/ocp/installer:4.9"},
+			},
+		},
+		{
+			name: "invalid mirrors",
+			input: []api.ImageMirror{
+				{From: "bin", To: "quay.io/org/bin"},
+				{From: "release:latest", To: "quay.io/Org/bin:latest"},
+				{From: "pipeline:bin", To: "quay.io/org/bin:latest"},
+				{From: "pipeline:cli", To: "quay.io/org/bin:latest"},
+			},
+			expected: []error{
+				errors.New(`mirror[0].from: must be a tag of the pipeline or a stable image stream like pipeline:bin, not "bin"`),
+				errors.New(`mirror[0].to: pull spec "quay.io/org/bin" must have a tag`),
+				errors.New(`mirror[1].from: must be a tag of the pipeline or a stable image stream like pipeline:bin, not "release:latest"`),
+				errors.New(`mirror[1].to: invalid pull spec "quay.io/Org/bin:latest": invalid reference format: repository name must be lowercase`),
+				errors.New(`mirror[3].to: quay.io/org/bin:latest is mirrored to more than once`),
+			},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, validateImageMirrors("mirror", test.input), cmp.Comparer(func(x, y error) bool {
+				return x.Error() == y.Error()
+			})); diff != "" {
+				t.Errorf("got incorrect errors: %s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateVulnerabilityScan(t *testing.T) {
+	images := []api.ProjectDirectoryImageBuildStepConfiguration{{To: "src-image"}, {To: "bin-image"}}
+	var testCases = []struct {
+		name     string
+		input    api.VulnerabilityScanConfiguration
+		images   []api.ProjectDirectoryImageBuildStepConfiguration
+		expected []error
+	}{
+		{
+			name:   "defaults",
+			images: images,
+		},
+		{
+			name: "Clair scanner annotating some images",
+			input: api.VulnerabilityScanConfiguration{
+				Scanner:           api.VulnerabilityScannerClair,
+				ClairURL:          "https://clair.example.com",
+				Images:            []api.PipelineImageStreamTagReference{"bin-image"},
+				SeverityThreshold: "MEDIUM",
+				Action:            api.VulnerabilityScanActionAnnotate,
+			},
+			images: images,
+		},
+		{
+			name:     "no images",
+			expected: []error{errors.New("vulnerability_scan: requires images to be built")},
+		},
+		{
+			name:   "Clair scanner without address",
+			input:  api.VulnerabilityScanConfiguration{Scanner: api.VulnerabilityScannerClair},
+			images: images,
+			expected: []error{
+				errors.New(`vulnerability_scan.clair_url: must be an absolute URL, not ""`),
+			},
+		},
+		{
+			name: "invalid fields",
+			input: api.VulnerabilityScanConfiguration{
+				ClairURL:          "https://clair.example.com",
+				Images:            []api.PipelineImageStreamTagReference{"src-image", "other"},
+				SeverityThreshold: "UNKNOWN",
+				Action:            "warn",
+			},
+			images: images,
+			expected: []error{
+				errors.New("vulnerability_scan.clair_url: can only be set with the clair scanner"),
+				errors.New("vulnerability_scan.images[1]: other is not built by the job"),
+				errors.New(`vulnerability_scan.severity_threshold: must be one of LOW, MEDIUM, HIGH, CRITICAL, not "UNKNOWN"`),
+				errors.New(`vulnerability_scan.action: must be one of fail, annotate, not "warn"`),
+			},
+		},
+		{
+			name:     "unknown scanner",
+			input:    api.VulnerabilityScanConfiguration{Scanner: "grype"},
+			images:   images,
+			expected: []error{errors.New(`vulnerability_scan.scanner: must be one of trivy, clair, not "grype"`)},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, validateVulnerabilityScan("vulnerability_scan", test.input, test.images), cmp.Comparer(func(x, y error) bool {
+				return x.Error() == y.Error()
+			})); diff != "" {
+				t.Errorf("got incorrect errors: %s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateFIPSCheck(t *testing.T) {
+	images := []api.ProjectDirectoryImageBuildStepConfiguration{{To: "src-image"}, {To: "bin-image"}}
+	var testCases = []struct {
+		name     string
+		input    api.FIPSCheckConfiguration
+		images   []api.ProjectDirectoryImageBuildStepConfiguration
+		expected []error
+	}{
+		{
+			name:   "defaults",
+			input:  api.FIPSCheckConfiguration{Images: []api.PipelineImageStreamTagReference{"bin-image"}},
+			images: images,
+		},
+		{
+			name: "custom paths and symbols with warnings",
+			input: api.FIPSCheckConfiguration{
+				Images:              []api.PipelineImageStreamTagReference{"src-image", "bin-image"},
+				Paths:               []string{"/opt/app/bin"},
+				ForbiddenSymbols:    []string{"crypto/internal/boring/sig.StandardCrypto", "EVP_md5"},
+				AllowStaticBinaries: true,
+				Action:              api.FIPSCheckActionWarn,
+			},
+			images: images,
+		},
+		{
+			name:     "no images",
+			images:   images,
+			expected: []error{errors.New("fips_check.images: at least one image must be checked")},
+		},
+		{
+			name: "invalid fields",
+			input: api.FIPSCheckConfiguration{
+				Images:           []api.PipelineImageStreamTagReference{"src-image", "other"},
+				Paths:            []string{"usr/bin", "/usr/bin/"},
+				ForbiddenSymbols: []string{" ", "a\nb"},
+				Action:           "annotate",
+			},
+			images: images,
+			expected: []error{
+				errors.New("fips_check.images[1]: other is not built by the job"),
+				errors.New(`fips_check.paths[0]: must be a clean absolute path, not "usr/bin"`),
+				errors.New(`fips_check.paths[1]: must be a clean absolute path, not "/usr/bin/"`),
+				errors.New(`fips_check.forbidden_symbols[0]: must be a single non-empty line, not " "`),
+				errors.New(`fips_check.forbidden_symbols[1]: must be a single non-empty line, not "a\nb"`),
+				errors.New(`fips_check.action: must be one of fail, warn, not "annotate"`),
+			},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, validateFIPSCheck("fips_check", test.input, test.images), cmp.Comparer(func(x, y error) bool {
+				return x.Error() == y.Error()
+			})); diff != "" {
+				t.Errorf("got incorrect errors: %s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateApproval(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		input    api.ApprovalConfiguration
+		expected []error
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:  "URL and timeout",
+			input: api.ApprovalConfiguration{URL: "https://approvals.example.com/decision", Timeout: &prowv1.Duration{Duration: 30 * time.Minute}},
+		},
+		{
+			name:  "invalid fields",
+			input: api.ApprovalConfiguration{URL: "approvals.example.com", Timeout: &prowv1.Duration{}},
+			expected: []error{
+				errors.New(`approval.url: must be an absolute HTTP(S) URL, not "approvals.example.com"`),
+				errors.New("approval.timeout: must be positive"),
+			},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, validateApproval("approval", test.input), cmp.Comparer(func(x, y error) bool {
+				return x.Error() == y.Error()
+			})); diff != "" {
+				t.Errorf("got incorrect errors: %s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateContacts(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		input    api.Contacts
+		expected []error
+	}{
+		{
+			name:  "valid contacts",
+			input: api.Contacts{Team: "Test Platform", SlackChannel: "#forum-testplatform", Email: "team@example.com", Escalation: "https://issues.example.com/browse/DPTP"},
+		},
+		{
+			name:  "only the team is required",
+			input: api.Contacts{Team: "Test Platform"},
+		},
+		{
+			name:  "invalid contacts yield errors",
+			input: api.Contacts{SlackChannel: "forum-testplatform", Email: "team", Escalation: "DPTP"},
+			expected: []error{
+				errors.New("contacts.team: value required but not provided"),
+				errors.New(`contacts.slack_channel: must start with '#', not "forum-testplatform"`),
+				errors.New(`contacts.email: invalid address "team": mail: missing '@' or angle-addr`),
+				errors.New(`contacts.escalation: must be an absolute URL, not "DPTP"`),
+			},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, validateContacts("contacts", test.input), cmp.Comparer(func(x, y error) bool {
+				return x.Error() == y.Error()
+			})); diff != "" {
+				t.Errorf("got incorrect errors: %s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		input    api.NamespaceConfiguration
+		expected []error
+	}{
+		{
+			name: "valid namespace configuration",
+			input: api.NamespaceConfiguration{
+				Quota:             api.ResourceList{"requests.cpu": "20", "limits.memory": "64Gi", "pods": "50"},
+				LimitRange:        &api.LimitRangeConfiguration{Default: api.ResourceList{"memory": "4Gi"}, DefaultRequest: api.ResourceList{"cpu": "100m"}, Max: api.ResourceList{"cpu": "8"}},
+				PriorityClassName: "release-blocking",
+			},
+		},
+		{
+			name: "invalid namespace configuration yields errors",
+			input: api.NamespaceConfiguration{
+				Quota:             api.ResourceList{"pods": "-1", "requests cpu": "1", "requests.memory": "lots"},
+				LimitRange:        &api.LimitRangeConfiguration{Default: api.ResourceList{"pods": "1"}, Max: api.ResourceList{"cpu": "-2"}},
+				PriorityClassName: "Release_Blocking",
+			},
+			expected: []error{
+				errors.New("namespace.quota.pods: quantity cannot be negative"),
+				errors.New(`namespace.quota: invalid resource name "requests cpu": name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')`),
+				errors.New("namespace.quota.requests.memory: invalid quantity: quantities must match the regular expression '^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'"),
+				errors.New(`namespace.limit_range.default: invalid resource "pods", must be one of cpu, ephemeral-storage, memory`),
+				errors.New("namespace.limit_range.max.cpu: quantity cannot be negative"),
+				errors.New(`namespace.priority_class_name: invalid name "Release_Blocking": a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`),
+			},
+		},
+		{
+			name:     "empty limit range is rejected",
+			input:    api.NamespaceConfiguration{LimitRange: &api.LimitRangeConfiguration{}},
+			expected: []error{errors.New("namespace.limit_range: should have at least one default, default request or max")},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, validateNamespace("namespace", test.input), cmp.Comparer(func(x, y error) bool {
+				return x.Error() == y.Error()
+			})); diff != "" {
+				t.Errorf("got incorrect errors: %s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateCloneIntoPods(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		input    api.ReleaseBuildConfiguration
+		expected []error
+	}{
+		{
+			name:  "unset is valid",
+			input: api.ReleaseBuildConfiguration{BinaryBuildCommands: "make"},
+		},
+		{
+			name: "tests from the build root are valid",
+			input: api.ReleaseBuildConfiguration{
+				CloneIntoPods:      true,
+				InputConfiguration: api.InputConfiguration{BuildRootImage: &api.BuildRootImageConfiguration{}},
+			},
+		},
+		{
+			name: "builds from src are rejected",
+			input: api.ReleaseBuildConfiguration{
+				CloneIntoPods:       true,
+				BinaryBuildCommands: "make",
+				Images:              []api.ProjectDirectoryImageBuildStepConfiguration{{To: "image"}},
+			},
+			expected: []error{
+				errors.New("clone_into_pods: tests run from the build root, which is not configured"),
+				errors.New("clone_into_pods: cannot be set with binary_build_commands, which needs the src image"),
+				errors.New("clone_into_pods: cannot be set with images, which needs the src image"),
+			},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, validateCloneIntoPods(&test.input), cmp.Comparer(func(x, y error) bool {
+				return x.Error() == y.Error()
+			})); diff != "" {
+				t.Errorf("got incorrect errors: %s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateClonerefs(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		input    api.ClonerefsConfiguration
+		expected []error
+	}{
+		{
+			name:  "image with the default path is valid",
+			input: api.ClonerefsConfiguration{Image: api.ImageStreamTagReference{Namespace: "mirror", Name: "clonerefs", Tag: "latest"}},
+		},
+		{
+			name:  "image with a path is valid",
+			input: api.ClonerefsConfiguration{Image: api.ImageStreamTagReference{Namespace: "mirror", Name: "prow-utils", Tag: "latest"}, Path: "/usr/bin/clonerefs"},
+		},
+		{
+			name:  "incomplete image and relative path are rejected",
+			input: api.ClonerefsConfiguration{Image: api.ImageStreamTagReference{Name: "clonerefs", As: "clonerefs"}, Path: "clonerefs"},
+			expected: []error{
+				errors.New("clonerefs.image.namespace: must be set"),
+				errors.New("clonerefs.image.tag: must be set"),
+				errors.New("clonerefs.image.as: cannot be set"),
+				errors.New(`clonerefs.path: must be absolute, not "clonerefs"`),
+			},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, validateClonerefs("clonerefs", test.input), cmp.Comparer(func(x, y error) bool {
+				return x.Error() == y.Error()
+			})); diff != "" {
+				t.Errorf("got incorrect errors: %s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateStatusContexts(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		input    []api.StatusContext
+		expected []error
+	}{
+		{
+			name: "no status contexts",
+		},
+		{
+			name: "valid status contexts",
+			input: []api.StatusContext{
+				{Context: "images-built", Description: "Images were built", Steps: []string{"[images]"}},
+				{Context: "cluster-installed", Steps: []string{"e2e-aws"}},
+			},
+		},
+		{
+			name: "invalid status contexts yield errors",
+			input: []api.StatusContext{
+				{Context: "images-built", Steps: []string{"[images]"}},
+				{Context: "images-built", Description: strings.Repeat("a", 141), Steps: []string{""}},
+				{},
+			},
+			expected: []error{
+				errors.New(`status_contexts[1].context: duplicated context "images-built"`),
+				errors.New("status_contexts[1].description: must be at most 140 characters long"),
+				errors.New("status_contexts[1].steps[0]: must not be empty"),
+				errors.New("status_contexts[2].context: value required but not provided"),
+				errors.New("status_contexts[2].steps: value required but not provided"),
+			},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, validateStatusContexts("status_contexts", test.input), cmp.Comparer(func(x, y error) bool {
+				return x.Error() == y.Error()
+			})); diff != "" {
+				t.Errorf("got incorrect errors: %s", diff)
+			}
+		})
+	}
+}
+
 func TestValidateReleaseTagConfiguration(t *testing.T) {
 	var testCases = []struct {
 		name     string
@@ -394,6 +978,37 @@ func TestValidateImages(t *testing.T) {
 				errors.New("images[0]: dockerfile_literal is mutually exclusive with context_dir and dockerfile_path"),
 			},
 		},
+		{
+			name: "valid build arguments",
+			input: []api.ProjectDirectoryImageBuildStepConfiguration{{
+				To: "amsterdam",
+				BuildArgs: []api.BuildArg{
+					{Name: "VERSION", Value: "4.9"},
+					{Name: "GIT_COMMIT", ValueFrom: &api.BuildArgSource{JobMetadata: api.BuildArgCommit}},
+					{Name: "RELEASE", ValueFrom: &api.BuildArgSource{Parameter: "RELEASE_IMAGE_LATEST"}},
+				},
+			}},
+		},
+		{
+			name: "invalid build arguments",
+			input: []api.ProjectDirectoryImageBuildStepConfiguration{{
+				To: "amsterdam",
+				BuildArgs: []api.BuildArg{
+					{Name: "1VERSION", Value: "4.9"},
+					{Name: "COMMIT", ValueFrom: &api.BuildArgSource{JobMetadata: "tree"}},
+					{Name: "COMMIT", Value: "abc", ValueFrom: &api.BuildArgSource{JobMetadata: api.BuildArgCommit, Parameter: "RELEASE_IMAGE_LATEST"}},
+					{Name: "FORMAT", ValueFrom: &api.BuildArgSource{Parameter: "IMAGE_FORMAT"}},
+				},
+			}},
+			output: []error{
+				errors.New(`images[0].build_args[0].name: "1VERSION" is not a valid build argument name`),
+				errors.New(`images[0].build_args[1].value_from.job_metadata: unknown property "tree"`),
+				errors.New("images[0].build_args[2].name: duplicate build argument COMMIT"),
+				errors.New("images[0].build_args[2]: value and value_from are mutually exclusive"),
+				errors.New("images[0].build_args[2].value_from: exactly one of job_metadata or parameter must be set"),
+				errors.New("images[0].build_args[3].value_from.parameter: IMAGE_FORMAT is not available until all images are built"),
+			},
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
@@ -460,6 +1075,29 @@ func TestValidateOperator(t *testing.T) {
 				errors.New("operator.substitute[0].with: could not resolve 'substitute' to an image involved in the config"),
 			},
 		},
+		{
+			name: "valid channels and index",
+			input: &api.OperatorStepConfiguration{
+				Bundles: []api.Bundle{{Channels: []string{"alpha", "stable-4.9"}, DefaultChannel: "stable-4.9"}},
+				Index:   &api.OperatorIndexConfiguration{BaseIndex: "quay.io/org/index:v4.9", UpdateGraph: api.IndexUpdateGraphSemverSkipPatch, PushTo: "quay.io/org/index:ci"},
+			},
+			withResolvesTo: goodStepLink,
+		},
+		{
+			name: "invalid channels and index",
+			input: &api.OperatorStepConfiguration{
+				Bundles: []api.Bundle{{Channels: []string{"alpha?"}, DefaultChannel: "'stable'"}},
+				Index:   &api.OperatorIndexConfiguration{BaseIndex: "quay.io/Org/index", UpdateGraph: "latest", PushTo: "quay.io/org/index"},
+			},
+			withResolvesTo: goodStepLink,
+			output: []error{
+				errors.New(`operator.bundles[0].channels[0]: "alpha?" is not a valid channel name`),
+				errors.New(`operator.bundles[0].default_channel: "'stable'" is not a valid channel name`),
+				errors.New(`operator.index.base_index: invalid pull spec "quay.io/Org/index": invalid reference format: repository name must be lowercase`),
+				errors.New(`operator.index.update_graph: must be one of semver, semver-skippatch, replaces, not "latest"`),
+				errors.New(`operator.index.push_to: pull spec "quay.io/org/index" must have a tag`),
+			},
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
@@ -571,6 +1209,21 @@ func TestReleaseBuildConfiguration_validateTestStepDependencies(t *testing.T) {
 				errors.New(`tests[1].literal_steps.post[0].dependencies[0]: cannot determine source for dependency "pipeline:rpms" - this dependency requires built RPMs, which are not configured`),
 			},
 		},
+		{
+			name: "src is not available when cloning into pods",
+			config: api.ReleaseBuildConfiguration{
+				CloneIntoPods: true,
+				Tests: []api.TestStepConfiguration{
+					{MultiStageTestConfigurationLiteral: &api.MultiStageTestConfigurationLiteral{
+						Test: []api.LiteralTestStep{{From: "src", Dependencies: []api.StepDependency{{Name: "pipeline:src"}}}},
+					}},
+				},
+			},
+			expected: []error{
+				errors.New("tests[0].literal_steps.test[0].from: the src image is not built when clone_into_pods is set"),
+				errors.New(`tests[0].literal_steps.test[0].dependencies[0]: cannot determine source for dependency "pipeline:src" - the src image is not built when clone_into_pods is set`),
+			},
+		},
 	}
 
 	for _, testCase := range testCases {
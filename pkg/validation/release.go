@@ -5,9 +5,12 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/blang/semver"
+
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/release/official"
 )
 
 func validateReleases(fieldRoot string, releases map[string]api.UnresolvedRelease, hasTagSpec bool) []error {
@@ -32,17 +35,22 @@ func validateReleases(fieldRoot string, releases map[string]api.UnresolvedReleas
 		if release.Prerelease != nil {
 			set = set + 1
 		}
+		if release.Assembled != nil {
+			set = set + 1
+		}
 
 		if set > 1 {
-			validationErrors = append(validationErrors, fmt.Errorf("%s.%s: cannot set more than one of candidate, prerelease and release", fieldRoot, name))
+			validationErrors = append(validationErrors, fmt.Errorf("%s.%s: cannot set more than one of candidate, prerelease, release and assembled", fieldRoot, name))
 		} else if set == 0 {
-			validationErrors = append(validationErrors, fmt.Errorf("%s.%s: must set candidate, prerelease or release", fieldRoot, name))
+			validationErrors = append(validationErrors, fmt.Errorf("%s.%s: must set candidate, prerelease, release or assembled", fieldRoot, name))
 		} else if release.Candidate != nil {
 			validationErrors = append(validationErrors, validateCandidate(fmt.Sprintf("%s.%s", fieldRoot, name), *release.Candidate)...)
 		} else if release.Release != nil {
 			validationErrors = append(validationErrors, validateRelease(fmt.Sprintf("%s.%s", fieldRoot, name), *release.Release)...)
 		} else if release.Prerelease != nil {
 			validationErrors = append(validationErrors, validatePrerelease(fmt.Sprintf("%s.%s", fieldRoot, name), *release.Prerelease)...)
+		} else if release.Assembled != nil {
+			validationErrors = append(validationErrors, validateAssembledRelease(fmt.Sprintf("%s.%s.assembled", fieldRoot, name), name, *release.Assembled)...)
 		}
 	}
 	return validationErrors
@@ -121,7 +129,15 @@ func validateRelease(fieldRoot string, release api.Release) []error {
 		return validationErrors
 	}
 
-	if err := validateVersion(fmt.Sprintf("%s.version", fieldRoot), release.Version); err != nil {
+	if release.VersionRange != "" {
+		if release.Version != "" {
+			validationErrors = append(validationErrors, fmt.Errorf("%s: cannot set both version and version_range", fieldRoot))
+		} else if _, err := semver.ParseRange(release.VersionRange); err != nil {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.version_range: invalid range: %v", fieldRoot, err))
+		} else if _, err := official.MinorVersionsInRange(release.VersionRange); err != nil {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.version_range: %v", fieldRoot, err))
+		}
+	} else if err := validateVersion(fmt.Sprintf("%s.version", fieldRoot), release.Version); err != nil {
 		validationErrors = append(validationErrors, err)
 	}
 
@@ -151,3 +167,21 @@ func validatePrerelease(fieldRoot string, prerelease api.Prerelease) []error {
 
 	return validationErrors
 }
+
+func validateAssembledRelease(fieldRoot, name string, release api.AssembledRelease) []error {
+	var validationErrors []error
+	if release.SourceRelease() == name {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.from: cannot assemble a release from itself", fieldRoot))
+	}
+	for _, filter := range []struct{ field, expression string }{{"include", release.Include}, {"exclude", release.Exclude}} {
+		if _, err := regexp.Compile(filter.expression); err != nil {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.%s: invalid regular expression: %v", fieldRoot, filter.field, err))
+		}
+	}
+	for _, tag := range sets.StringKeySet(release.Overrides).List() {
+		if release.Overrides[tag] == "" {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.overrides.%s: image cannot be empty", fieldRoot, tag))
+		}
+	}
+	return validationErrors
+}
@@ -49,6 +49,12 @@ func TestValidateReleases(t *testing.T) {
 						},
 					},
 				},
+				"fourth": {
+					Assembled: &api.AssembledRelease{
+						Exclude:   "^(baremetal-.*|ovirt-.*)$",
+						Overrides: map[string]string{"machine-config-operator": "mco"},
+					},
+				},
 			},
 		},
 		{
@@ -74,7 +80,7 @@ func TestValidateReleases(t *testing.T) {
 				"latest": {},
 			},
 			output: []error{
-				errors.New("root.latest: must set candidate, prerelease or release"),
+				errors.New("root.latest: must set candidate, prerelease, release or assembled"),
 			},
 		},
 		{
@@ -86,7 +92,7 @@ func TestValidateReleases(t *testing.T) {
 				},
 			},
 			output: []error{
-				errors.New("root.latest: cannot set more than one of candidate, prerelease and release"),
+				errors.New("root.latest: cannot set more than one of candidate, prerelease, release and assembled"),
 			},
 		},
 		{
@@ -99,7 +105,7 @@ func TestValidateReleases(t *testing.T) {
 				},
 			},
 			output: []error{
-				errors.New("root.latest: cannot set more than one of candidate, prerelease and release"),
+				errors.New("root.latest: cannot set more than one of candidate, prerelease, release and assembled"),
 			},
 		},
 		{
@@ -129,10 +135,20 @@ func TestValidateReleases(t *testing.T) {
 						},
 					},
 				},
+				"fourth": {
+					Assembled: &api.AssembledRelease{
+						From:      "fourth",
+						Include:   "(cli",
+						Overrides: map[string]string{"cli": ""},
+					},
+				},
 			},
 			hasTagSpec: true,
 			output: []error{
 				errors.New("root.first.product: must be one of ocp, okd"),
+				errors.New("root.fourth.assembled.from: cannot assemble a release from itself"),
+				errors.New("root.fourth.assembled.include: invalid regular expression: error parsing regexp: missing closing ): `(cli`"),
+				errors.New("root.fourth.assembled.overrides.cli: image cannot be empty"),
 				errors.New("root.second.channel: must be one of candidate, fast, stable"),
 				errors.New("root.third.version_bounds.upper: must be set"),
 			},
@@ -331,6 +347,44 @@ func TestValidateRelease(t *testing.T) {
 				errors.New(`root.version: must be a minor version in the form [0-9]\.[0-9]+`),
 			},
 		},
+		{
+			name: "valid release from version range",
+			input: api.Release{
+				Channel:      api.ReleaseChannelStable,
+				VersionRange: ">=4.12.0 <4.14.0",
+			},
+		},
+		{
+			name: "invalid release with version and version range",
+			input: api.Release{
+				Channel:      api.ReleaseChannelStable,
+				Version:      "4.12",
+				VersionRange: ">=4.12.0 <4.14.0",
+			},
+			output: []error{
+				errors.New("root: cannot set both version and version_range"),
+			},
+		},
+		{
+			name: "invalid release from version range",
+			input: api.Release{
+				Channel:      api.ReleaseChannelStable,
+				VersionRange: ">=4.12",
+			},
+			output: []error{
+				errors.New(`root.version_range: invalid range: Could not parse Range ">=4.12": Could not parse version "4.12" in ">=4.12": No Major.Minor.Patch elements found`),
+			},
+		},
+		{
+			name: "invalid release from open-ended version range",
+			input: api.Release{
+				Channel:      api.ReleaseChannelStable,
+				VersionRange: ">=4.12.0",
+			},
+			output: []error{
+				errors.New(`root.version_range: range ">=4.12.0" has no upper bound`),
+			},
+		},
 	}
 
 	for _, testCase := range testCases {
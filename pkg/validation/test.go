@@ -2,10 +2,13 @@ package validation
 
 import (
 	"fmt"
+	"mime"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/docker/distribution/reference"
 	"gopkg.in/robfig/cron.v2"
 
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -13,6 +16,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps/gather"
 )
 
 type testStage uint8
@@ -131,6 +135,9 @@ func validateTestStepConfiguration(fieldRoot string, input []api.TestStepConfigu
 func validateTestStepDependencies(config *api.ReleaseBuildConfiguration) []error {
 	dependencyErrors := func(step api.LiteralTestStep, testIdx int, stageField, stepField string, stepIdx int) []error {
 		var errs []error
+		if config.CloneIntoPods && step.From == string(api.PipelineImageStreamTagReferenceSource) {
+			errs = append(errs, fmt.Errorf("tests[%d].%s.%s[%d].from: the src image is not built when clone_into_pods is set", testIdx, stageField, stepField, stepIdx))
+		}
 		for dependencyIdx, dependency := range step.Dependencies {
 			validationError := func(message string) error {
 				return fmt.Errorf("tests[%d].%s.%s[%d].dependencies[%d]: cannot determine source for dependency %q - %s", testIdx, stageField, stepField, stepIdx, dependencyIdx, dependency.Name, message)
@@ -169,7 +176,9 @@ func validateTestStepDependencies(config *api.ReleaseBuildConfiguration) []error
 							errs = append(errs, validationError("this dependency requires a build root, which is not configured"))
 						}
 					case string(api.PipelineImageStreamTagReferenceSource):
-						// always present
+						if config.CloneIntoPods {
+							errs = append(errs, validationError("the src image is not built when clone_into_pods is set"))
+						}
 					case string(api.PipelineImageStreamTagReferenceBinaries):
 						if config.BinaryBuildCommands == "" {
 							errs = append(errs, validationError("this dependency requires built binaries, which are not configured"))
@@ -354,6 +363,14 @@ func validateTestConfigurationType(fieldRoot string, test api.TestStepConfigurat
 		validationErrors = append(validationErrors, validateTestSteps(context.forField(".pre"), testStagePre, testConfig.Pre)...)
 		validationErrors = append(validationErrors, validateTestSteps(context.forField(".test"), testStageTest, testConfig.Test)...)
 		validationErrors = append(validationErrors, validateTestSteps(context.forField(".post"), testStagePost, testConfig.Post)...)
+
+		validationErrors = append(validationErrors, validateSharedDir(fieldRoot+".shared_dir", testConfig.SharedDir)...)
+		validationErrors = append(validationErrors, validateDataDir(fieldRoot+".data_dir", testConfig.DataDir)...)
+		validationErrors = append(validationErrors, validateClusterProvisioning(fieldRoot, testConfig.ClusterProfile, testConfig.ClusterProvisioning)...)
+		validationErrors = append(validationErrors, validateClusterClaim(fieldRoot, testConfig.ClusterProvisioning, testConfig.ClusterClaim)...)
+		validationErrors = append(validationErrors, validateClusterProfileOverlay(fieldRoot, testConfig.ClusterProfile, testConfig.ClusterProfileOverlay)...)
+		validationErrors = append(validationErrors, validateGather(fieldRoot+".gather", testConfig.Gather)...)
+		validationErrors = append(validationErrors, validateDisruptionRetries(fieldRoot+".disruption_retries", testConfig.DisruptionRetries)...)
 	}
 	if testConfig := test.MultiStageTestConfigurationLiteral; testConfig != nil {
 		typeCount++
@@ -371,6 +388,15 @@ func validateTestConfigurationType(fieldRoot string, test api.TestStepConfigurat
 		for i, s := range testConfig.Post {
 			validationErrors = append(validationErrors, validateLiteralTestStep(context.forField(fmt.Sprintf(".post[%d]", i)), testStagePost, s)...)
 		}
+		validationErrors = append(validationErrors, validateObservers(context.forField(".observers"), testConfig.Observers)...)
+
+		validationErrors = append(validationErrors, validateSharedDir(fieldRoot+".shared_dir", testConfig.SharedDir)...)
+		validationErrors = append(validationErrors, validateDataDir(fieldRoot+".data_dir", testConfig.DataDir)...)
+		validationErrors = append(validationErrors, validateClusterProvisioning(fieldRoot, testConfig.ClusterProfile, testConfig.ClusterProvisioning)...)
+		validationErrors = append(validationErrors, validateClusterClaim(fieldRoot, testConfig.ClusterProvisioning, testConfig.ClusterClaim)...)
+		validationErrors = append(validationErrors, validateClusterProfileOverlay(fieldRoot, testConfig.ClusterProfile, testConfig.ClusterProfileOverlay)...)
+		validationErrors = append(validationErrors, validateGather(fieldRoot+".gather", testConfig.Gather)...)
+		validationErrors = append(validationErrors, validateDisruptionRetries(fieldRoot+".disruption_retries", testConfig.DisruptionRetries)...)
 	}
 	if typeCount == 0 {
 		validationErrors = append(validationErrors, fmt.Errorf("%s has no type, you may want to specify 'container' for a container based test", fieldRoot))
@@ -436,7 +462,9 @@ func validateLiteralTestStep(context context, stage testStage, step api.LiteralT
 	} else {
 		context.seen.Insert(step.As)
 	}
-	if len(step.From) == 0 && step.FromImage == nil {
+	if step.Upgrade != nil {
+		ret = append(ret, validateUpgradeStep(context.fieldRoot, step)...)
+	} else if len(step.From) == 0 && step.FromImage == nil {
 		ret = append(ret, fmt.Errorf("%s: `from` or `from_image` is required", context.fieldRoot))
 	} else if len(step.From) != 0 && step.FromImage != nil {
 		ret = append(ret, fmt.Errorf("%s: `from` and `from_image` cannot be set together", context.fieldRoot))
@@ -451,36 +479,35 @@ func validateLiteralTestStep(context context, stage testStage, step api.LiteralT
 			ret = append(ret, fmt.Errorf("%s.from_image: `tag` is required", context.fieldRoot))
 		}
 	} else {
-		imageParts := strings.Split(step.From, ":")
-		if len(imageParts) > 2 {
-			ret = append(ret, fmt.Errorf("%s.from: '%s' is not a valid imagestream reference", context.fieldRoot, step.From))
-		}
-		for i, obj := range imageParts {
-			if len(validation.IsDNS1123Subdomain(obj)) != 0 {
-				ret = append(ret, fmt.Errorf("%s.from: '%s' is not a valid Kubernetes object name", context.fieldRoot, obj))
-			} else if i == 0 && len(imageParts) == 2 {
-				switch obj {
-				case api.PipelineImageStream, api.ReleaseStreamFor(api.LatestReleaseName), api.ReleaseStreamFor(api.InitialReleaseName), api.ReleaseImageStream:
-				default:
-					releaseName := api.ReleaseNameFrom(obj)
-					if !context.releases.Has(releaseName) {
-						ret = append(ret, fmt.Errorf("%s.from: unknown imagestream '%s'", context.fieldRoot, imageParts[0]))
-					}
-
-				}
-			}
-		}
+		ret = append(ret, validateImageStreamReference(context.fieldRoot+".from", step.From, context.releases)...)
 	}
-	if len(step.Commands) == 0 {
-		ret = append(ret, fmt.Errorf("%s: `commands` is required", context.fieldRoot))
+	if step.Upgrade == nil {
+		if len(step.Commands) == 0 {
+			ret = append(ret, fmt.Errorf("%s: `commands` is required", context.fieldRoot))
+		}
+		ret = append(ret, validateResourceRequirements(context.fieldRoot+".resources", step.Resources)...)
 	}
-	ret = append(ret, validateResourceRequirements(context.fieldRoot+".resources", step.Resources)...)
 	ret = append(ret, validateCredentials(context.fieldRoot, step.Credentials)...)
-	if err := validateParameters(&context, step.Environment); err != nil {
-		ret = append(ret, err)
-	}
+	ret = append(ret, validateParameters(&context, step.Environment)...)
 	ret = append(ret, validateDependencies(context.fieldRoot, step.Dependencies)...)
+	ret = append(ret, validateExternalDependencies(context.fieldRoot, step.ExternalDependencies, step.Dependencies)...)
 	ret = append(ret, validateLeases(context.forField(".leases"), step.Leases)...)
+	ret = append(ret, validateWorkspace(context.fieldRoot+".workspace", step.Workspace)...)
+	ret = append(ret, validateSidecars(context.fieldRoot+".sidecars", step.Sidecars, context.releases)...)
+	ret = append(ret, validateServiceAccount(context.fieldRoot+".service_account", step.ServiceAccount)...)
+	if step.Timeout != nil && step.Timeout.Duration <= 0 {
+		ret = append(ret, fmt.Errorf("%s.timeout must be positive, got %s", context.fieldRoot, step.Timeout.Duration))
+	}
+	if step.GracePeriod != nil && step.GracePeriod.Duration <= 0 {
+		ret = append(ret, fmt.Errorf("%s.grace_period must be positive, got %s", context.fieldRoot, step.GracePeriod.Duration))
+	}
+	ret = append(ret, validateRetries(context.fieldRoot+".retries", step.Retries)...)
+	ret = append(ret, validateArtifactsToRegistry(context.fieldRoot+".artifacts_to_registry", step.ArtifactsToRegistry)...)
+	if step.If != "" {
+		if _, err := api.ParseCondition(step.If); err != nil {
+			ret = append(ret, fmt.Errorf("%s.if: invalid expression: %w", context.fieldRoot, err))
+		}
+	}
 	switch stage {
 	case testStagePre, testStageTest:
 		if step.OptionalOnSuccess != nil {
@@ -490,14 +517,386 @@ func validateLiteralTestStep(context context, stage testStage, step api.LiteralT
 	return
 }
 
+// validateUpgradeStep checks the releases of an upgrade step and that it
+// sets none of the fields that only apply to steps running in a pod
+func validateUpgradeStep(fieldRoot string, step api.LiteralTestStep) (ret []error) {
+	for _, release := range []struct{ field, name string }{
+		{field: "from", name: step.Upgrade.From},
+		{field: "to", name: step.Upgrade.To},
+	} {
+		if release.name == "" {
+			ret = append(ret, fmt.Errorf("%s.upgrade.%s is required", fieldRoot, release.field))
+		} else if len(validation.IsDNS1123Subdomain(release.name)) != 0 {
+			ret = append(ret, fmt.Errorf("%s.upgrade.%s: '%s' is not a valid release name", fieldRoot, release.field, release.name))
+		}
+	}
+	if step.Upgrade.From != "" && step.Upgrade.From == step.Upgrade.To {
+		ret = append(ret, fmt.Errorf("%s.upgrade: `from` and `to` must be different releases", fieldRoot))
+	}
+	for _, field := range []struct {
+		name string
+		set  bool
+	}{
+		{name: "from", set: step.From != ""},
+		{name: "from_image", set: step.FromImage != nil},
+		{name: "commands", set: step.Commands != ""},
+		{name: "cli", set: step.Cli != ""},
+		{name: "sidecars", set: len(step.Sidecars) != 0},
+		{name: "workspace", set: step.Workspace != nil},
+		{name: "retries", set: step.Retries != nil},
+		{name: "artifacts_to_registry", set: step.ArtifactsToRegistry != nil},
+	} {
+		if field.set {
+			ret = append(ret, fmt.Errorf("%s: `%s` cannot be set for upgrade steps", fieldRoot, field.name))
+		}
+	}
+	return
+}
+
+// validateObservers must run after the steps of the test are validated, as
+// observer pods are named like step pods and their names cannot clash
+func validateObservers(context context, observers []api.Observer) (ret []error) {
+	for i, observer := range observers {
+		fieldRoot := fmt.Sprintf("%s[%d]", context.fieldRoot, i)
+		if observer.Name == "" {
+			ret = append(ret, fmt.Errorf("%s: `name` is required", fieldRoot))
+		} else if context.seen.Has(observer.Name) {
+			ret = append(ret, fmt.Errorf("%s: duplicated name %q", fieldRoot, observer.Name))
+		} else {
+			context.seen.Insert(observer.Name)
+		}
+		if len(observer.From) == 0 && observer.FromImage == nil {
+			ret = append(ret, fmt.Errorf("%s: `from` or `from_image` is required", fieldRoot))
+		} else if len(observer.From) != 0 && observer.FromImage != nil {
+			ret = append(ret, fmt.Errorf("%s: `from` and `from_image` cannot be set together", fieldRoot))
+		} else if len(observer.From) != 0 {
+			ret = append(ret, validateImageStreamReference(fieldRoot+".from", observer.From, context.releases)...)
+		}
+		if len(observer.Commands) == 0 {
+			ret = append(ret, fmt.Errorf("%s: `commands` is required", fieldRoot))
+		}
+	}
+	return
+}
+
+func validateImageStreamReference(fieldRoot, from string, releases sets.String) (ret []error) {
+	imageParts := strings.Split(from, ":")
+	if len(imageParts) > 2 {
+		ret = append(ret, fmt.Errorf("%s: '%s' is not a valid imagestream reference", fieldRoot, from))
+	}
+	for i, obj := range imageParts {
+		if len(validation.IsDNS1123Subdomain(obj)) != 0 {
+			ret = append(ret, fmt.Errorf("%s: '%s' is not a valid Kubernetes object name", fieldRoot, obj))
+		} else if i == 0 && len(imageParts) == 2 {
+			switch obj {
+			case api.PipelineImageStream, api.ReleaseStreamFor(api.LatestReleaseName), api.ReleaseStreamFor(api.InitialReleaseName), api.ReleaseImageStream:
+			default:
+				releaseName := api.ReleaseNameFrom(obj)
+				if !releases.Has(releaseName) {
+					ret = append(ret, fmt.Errorf("%s: unknown imagestream '%s'", fieldRoot, imageParts[0]))
+				}
+
+			}
+		}
+	}
+	return
+}
+
+// reservedContainerNames are used by the containers ci-operator and Prow add
+// to the pod of a step
+var reservedContainerNames = sets.NewString("test", "sidecar", "place-entrypoint", "cp-entrypoint-wrapper", "artifacts", "push-artifacts")
+
+func validateSidecars(fieldRoot string, sidecars []api.Sidecar, releases sets.String) []error {
+	var errs []error
+	seen := sets.NewString()
+	for i, sidecar := range sidecars {
+		field := fmt.Sprintf("%s[%d]", fieldRoot, i)
+		if sidecar.Name == "" {
+			errs = append(errs, fmt.Errorf("%s.name cannot be empty", field))
+		} else if msgs := validation.IsDNS1123Label(sidecar.Name); len(msgs) != 0 {
+			errs = append(errs, fmt.Errorf("%s.name: '%s' is not a valid container name: %s", field, sidecar.Name, strings.Join(msgs, ", ")))
+		} else if reservedContainerNames.Has(sidecar.Name) {
+			errs = append(errs, fmt.Errorf("%s.name: '%s' is reserved", field, sidecar.Name))
+		} else if seen.Has(sidecar.Name) {
+			errs = append(errs, fmt.Errorf("%s.name: duplicated name %q", field, sidecar.Name))
+		}
+		seen.Insert(sidecar.Name)
+		if sidecar.From == "" {
+			errs = append(errs, fmt.Errorf("%s.from cannot be empty", field))
+		} else {
+			errs = append(errs, validateImageStreamReference(field+".from", sidecar.From, releases)...)
+		}
+		if sidecar.Commands == "" {
+			errs = append(errs, fmt.Errorf("%s.commands cannot be empty", field))
+		}
+		errs = append(errs, validateResourceRequirements(field+".resources", sidecar.Resources)...)
+	}
+	return errs
+}
+
+func validateServiceAccount(fieldRoot string, serviceAccount *api.StepServiceAccount) []error {
+	if serviceAccount == nil {
+		return nil
+	}
+	var errs []error
+	for i, rule := range serviceAccount.Rules {
+		field := fmt.Sprintf("%s.rules[%d]", fieldRoot, i)
+		// an empty list of API groups is valid RBAC but never matches anything
+		if len(rule.APIGroups) == 0 {
+			errs = append(errs, fmt.Errorf("%s.api_groups cannot be empty, use \"\" for the core group", field))
+		}
+		if len(rule.Resources) == 0 {
+			errs = append(errs, fmt.Errorf("%s.resources cannot be empty", field))
+		}
+		if len(rule.Verbs) == 0 {
+			errs = append(errs, fmt.Errorf("%s.verbs cannot be empty", field))
+		}
+	}
+	return errs
+}
+
+func validateWorkspace(fieldRoot string, workspace *api.WorkspaceConfiguration) []error {
+	if workspace == nil {
+		return nil
+	}
+	var errs []error
+	if workspace.Size == "" {
+		errs = append(errs, fmt.Errorf("%s.size cannot be empty", fieldRoot))
+	} else if size, err := resource.ParseQuantity(workspace.Size); err != nil {
+		errs = append(errs, fmt.Errorf("%s.size: invalid quantity: %w", fieldRoot, err))
+	} else if size.Sign() <= 0 {
+		errs = append(errs, fmt.Errorf("%s.size must be positive, got %s", fieldRoot, workspace.Size))
+	}
+	if workspace.MountPath != "" && !filepath.IsAbs(workspace.MountPath) {
+		errs = append(errs, fmt.Errorf("%s.mount_path is not absolute: %s", fieldRoot, workspace.MountPath))
+	}
+	return errs
+}
+
+func validateSharedDir(fieldRoot string, sharedDir *api.SharedDirConfiguration) []error {
+	if sharedDir == nil {
+		return nil
+	}
+	var errs []error
+	switch sharedDir.Medium {
+	case "", api.SharedDirMediumDisk, api.SharedDirMediumMemory:
+	default:
+		errs = append(errs, fmt.Errorf("%s.medium must be one of %s, %s, got %s", fieldRoot, api.SharedDirMediumDisk, api.SharedDirMediumMemory, sharedDir.Medium))
+	}
+	if sharedDir.SizeLimit != "" {
+		if limit, err := resource.ParseQuantity(sharedDir.SizeLimit); err != nil {
+			errs = append(errs, fmt.Errorf("%s.size_limit: invalid quantity: %w", fieldRoot, err))
+		} else if limit.Sign() <= 0 {
+			errs = append(errs, fmt.Errorf("%s.size_limit must be positive, got %s", fieldRoot, sharedDir.SizeLimit))
+		}
+	}
+	return errs
+}
+
+func validateDataDir(fieldRoot string, dataDir *api.DataDirConfiguration) []error {
+	if dataDir == nil {
+		return nil
+	}
+	var errs []error
+	if dataDir.Size == "" {
+		errs = append(errs, fmt.Errorf("%s.size cannot be empty", fieldRoot))
+	} else if size, err := resource.ParseQuantity(dataDir.Size); err != nil {
+		errs = append(errs, fmt.Errorf("%s.size: invalid quantity: %w", fieldRoot, err))
+	} else if size.Sign() <= 0 {
+		errs = append(errs, fmt.Errorf("%s.size must be positive, got %s", fieldRoot, dataDir.Size))
+	}
+	return errs
+}
+
+func validateClusterProvisioning(fieldRoot string, profile api.ClusterProfile, provisioning *api.ClusterProvisioningConfiguration) []error {
+	if provisioning == nil {
+		return nil
+	}
+	var errs []error
+	if profile == "" {
+		errs = append(errs, fmt.Errorf("%s.cluster_provisioning requires cluster_profile to be set", fieldRoot))
+	} else if !sets.NewString(api.HiveClusterTypes...).Has(profile.ClusterType()) {
+		errs = append(errs, fmt.Errorf("%s.cluster_provisioning: clusters of type %s cannot be provisioned, must be one of %s", fieldRoot, profile.ClusterType(), strings.Join(api.HiveClusterTypes, ", ")))
+	}
+	if provisioning.Version == "" {
+		errs = append(errs, fmt.Errorf("%s.cluster_provisioning.version cannot be empty", fieldRoot))
+	}
+	if provisioning.Region == "" {
+		errs = append(errs, fmt.Errorf("%s.cluster_provisioning.region cannot be empty", fieldRoot))
+	}
+	if provisioning.BaseDomain == "" {
+		errs = append(errs, fmt.Errorf("%s.cluster_provisioning.base_domain cannot be empty", fieldRoot))
+	}
+	return errs
+}
+
+func validateClusterClaim(fieldRoot string, provisioning *api.ClusterProvisioningConfiguration, claim *api.ClusterClaimConfiguration) []error {
+	if claim == nil {
+		return nil
+	}
+	var errs []error
+	if provisioning != nil {
+		errs = append(errs, fmt.Errorf("%s.cluster_claim and %s.cluster_provisioning are mutually exclusive", fieldRoot, fieldRoot))
+	}
+	if claim.Namespace == "" {
+		errs = append(errs, fmt.Errorf("%s.cluster_claim.namespace cannot be empty", fieldRoot))
+	}
+	if claim.Pool == "" {
+		errs = append(errs, fmt.Errorf("%s.cluster_claim.pool cannot be empty", fieldRoot))
+	}
+	if claim.Retries != nil && *claim.Retries < 0 {
+		errs = append(errs, fmt.Errorf("%s.cluster_claim.retries cannot be negative, got %d", fieldRoot, *claim.Retries))
+	}
+	return errs
+}
+
+func validateClusterProfileOverlay(fieldRoot string, profile api.ClusterProfile, overlay *api.ClusterProfileOverlay) []error {
+	if overlay == nil {
+		return nil
+	}
+	var errs []error
+	if profile == "" {
+		errs = append(errs, fmt.Errorf("%s.cluster_profile_overlay requires cluster_profile to be set", fieldRoot))
+	}
+	validateSources := func(field string, sources []api.ClusterProfileOverlaySource) {
+		for i, source := range sources {
+			if source.Namespace == "" {
+				errs = append(errs, fmt.Errorf("%s.cluster_profile_overlay.%s[%d].namespace cannot be empty", fieldRoot, field, i))
+			}
+			if source.Name == "" {
+				errs = append(errs, fmt.Errorf("%s.cluster_profile_overlay.%s[%d].name cannot be empty", fieldRoot, field, i))
+			}
+		}
+	}
+	validateSources("secrets", overlay.Secrets)
+	validateSources("config_maps", overlay.ConfigMaps)
+	return errs
+}
+
+func validateGather(fieldRoot string, config *api.GatherConfiguration) []error {
+	if config == nil {
+		return nil
+	}
+	var errs []error
+	for i, step := range config.Steps {
+		if !gather.Known(step) {
+			errs = append(errs, fmt.Errorf("%s.steps[%d]: unknown step %q, must be one of %s", fieldRoot, i, step, strings.Join(knownGatherSteps(), ", ")))
+		}
+	}
+	if config.MaxSize != "" {
+		if size, err := resource.ParseQuantity(config.MaxSize); err != nil {
+			errs = append(errs, fmt.Errorf("%s.max_size: invalid quantity: %w", fieldRoot, err))
+		} else if size.Sign() <= 0 {
+			errs = append(errs, fmt.Errorf("%s.max_size must be positive, got %s", fieldRoot, config.MaxSize))
+		}
+	}
+	if config.Timeout != nil && config.Timeout.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("%s.timeout must be positive, got %s", fieldRoot, config.Timeout.Duration))
+	}
+	return errs
+}
+
+func knownGatherSteps() []string {
+	var names []string
+	for _, step := range gather.DefaultSteps {
+		names = append(names, string(step))
+	}
+	return names
+}
+
+func validateDisruptionRetries(fieldRoot string, retries *int) []error {
+	if retries != nil && *retries < 0 {
+		return []error{fmt.Errorf("%s must not be negative, got %d", fieldRoot, *retries)}
+	}
+	return nil
+}
+
+func validateRetries(fieldRoot string, retries *api.StepRetries) []error {
+	if retries == nil {
+		return nil
+	}
+	var errs []error
+	if retries.Count < 1 {
+		errs = append(errs, fmt.Errorf("%s.count must be positive, got %d", fieldRoot, retries.Count))
+	}
+	if retries.Until != nil && retries.Until.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("%s.until must be positive, got %s", fieldRoot, retries.Until.Duration))
+	}
+	return errs
+}
+
+var (
+	// artifactTag is the format of tags in the registry
+	artifactTag = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127}$`)
+	// artifactFile allows paths and glob patterns the shell does not
+	// interpret otherwise
+	artifactFile = regexp.MustCompile(`^[a-zA-Z0-9_.*?/-]+$`)
+)
+
+func validateArtifactsToRegistry(fieldRoot string, artifacts *api.ArtifactsToRegistry) []error {
+	if artifacts == nil {
+		return nil
+	}
+	var errs []error
+	if artifacts.Repository == "" {
+		errs = append(errs, fmt.Errorf("%s.repository cannot be empty", fieldRoot))
+	} else if named, err := reference.ParseNormalizedNamed(artifacts.Repository); err != nil {
+		errs = append(errs, fmt.Errorf("%s.repository: invalid repository %q: %v", fieldRoot, artifacts.Repository, err))
+	} else if !reference.IsNameOnly(named) {
+		errs = append(errs, fmt.Errorf("%s.repository: %q must not have a tag or digest, use tag instead", fieldRoot, artifacts.Repository))
+	}
+	if artifacts.Tag != "" && !artifactTag.MatchString(artifacts.Tag) {
+		errs = append(errs, fmt.Errorf("%s.tag: %q is not a valid tag", fieldRoot, artifacts.Tag))
+	}
+	if artifacts.ArtifactType != "" {
+		if _, _, err := mime.ParseMediaType(artifacts.ArtifactType); err != nil {
+			errs = append(errs, fmt.Errorf("%s.artifact_type: invalid media type %q: %v", fieldRoot, artifacts.ArtifactType, err))
+		}
+	}
+	if len(artifacts.Files) == 0 {
+		errs = append(errs, fmt.Errorf("%s.files cannot be empty", fieldRoot))
+	}
+	credentials := artifacts.Credentials
+	switch {
+	case credentials.VaultPath != "" && (credentials.Name != "" || credentials.Namespace != ""):
+		errs = append(errs, fmt.Errorf("%s.credentials.vault_path cannot be set together with name or namespace", fieldRoot))
+	case credentials.VaultPath == "" && (credentials.Name == "" || credentials.Namespace == ""):
+		errs = append(errs, fmt.Errorf("%s.credentials must reference a secret by namespace and name or by vault_path", fieldRoot))
+	case credentials.Name == api.RegistryPushCredentialsCICentralSecret:
+		// the push credentials of CI may write to any repository
+		errs = append(errs, fmt.Errorf("%s.credentials: %s cannot be used, provide credentials for the repository", fieldRoot, api.RegistryPushCredentialsCICentralSecret))
+	}
+	if credentials.MountPath != "" {
+		errs = append(errs, fmt.Errorf("%s.credentials.mount_path cannot be set", fieldRoot))
+	}
+	for i, file := range artifacts.Files {
+		field := fmt.Sprintf("%s.files[%d]", fieldRoot, i)
+		switch {
+		case !artifactFile.MatchString(file):
+			errs = append(errs, fmt.Errorf("%s: %q may only contain alphanumeric characters, '_', '.', '-', '/' and the globs '*' and '?'", field, file))
+		case filepath.IsAbs(file):
+			errs = append(errs, fmt.Errorf("%s: %q must be relative to the artifacts directory", field, file))
+		case file == ".." || strings.HasPrefix(file, "../") || strings.Contains(file, "/../") || strings.HasSuffix(file, "/.."):
+			errs = append(errs, fmt.Errorf("%s: %q must not leave the artifacts directory", field, file))
+		}
+	}
+	return errs
+}
+
 func validateCredentials(fieldRoot string, credentials []api.CredentialReference) []error {
 	var errs []error
 	for i, credential := range credentials {
-		if credential.Name == "" {
-			errs = append(errs, fmt.Errorf("%s.credentials[%d].name cannot be empty", fieldRoot, i))
-		}
-		if credential.Namespace == "" {
-			errs = append(errs, fmt.Errorf("%s.credentials[%d].namespace cannot be empty", fieldRoot, i))
+		if credential.VaultPath != "" {
+			if credential.Name != "" || credential.Namespace != "" {
+				errs = append(errs, fmt.Errorf("%s.credentials[%d].vault_path cannot be set together with name or namespace", fieldRoot, i))
+			}
+		} else {
+			if credential.Name == "" {
+				errs = append(errs, fmt.Errorf("%s.credentials[%d].name cannot be empty", fieldRoot, i))
+			}
+			if credential.Namespace == "" {
+				errs = append(errs, fmt.Errorf("%s.credentials[%d].namespace cannot be empty", fieldRoot, i))
+			}
 		}
 		if credential.MountPath == "" {
 			errs = append(errs, fmt.Errorf("%s.credentials[%d].mountPath cannot be empty", fieldRoot, i))
@@ -535,20 +934,44 @@ func validateCredentials(fieldRoot string, credentials []api.CredentialReference
 	return errs
 }
 
-func validateParameters(context *context, params []api.StepParameter) error {
+func validateParameters(context *context, params []api.StepParameter) []error {
+	var errs []error
 	var missing []string
-	for _, param := range params {
-		if param.Default != nil {
+	for i, param := range params {
+		fieldRoot := fmt.Sprintf("%s.env[%d]", context.fieldRoot, i)
+		switch param.Type {
+		case "", api.ParameterTypeString, api.ParameterTypeInt, api.ParameterTypeBool:
+			if len(param.Values) != 0 {
+				errs = append(errs, fmt.Errorf("%s.values can only be set for parameters of type %s", fieldRoot, api.ParameterTypeEnum))
+			}
+		case api.ParameterTypeEnum:
+			if len(param.Values) == 0 {
+				errs = append(errs, fmt.Errorf("%s.values must be set for parameters of type %s", fieldRoot, api.ParameterTypeEnum))
+				continue
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s.type must be one of %s, %s, %s or %s, got %q", fieldRoot, api.ParameterTypeString, api.ParameterTypeInt, api.ParameterTypeBool, api.ParameterTypeEnum, param.Type))
 			continue
 		}
-		if _, ok := context.env[param.Name]; !ok {
-			missing = append(missing, param.Name)
+		value, ok := context.env[param.Name]
+		if !ok {
+			if param.Default == nil {
+				missing = append(missing, param.Name)
+				continue
+			}
+			value = *param.Default
+		}
+		if err := param.ValidateValue(value); err != nil {
+			if param.Secret {
+				err = fmt.Errorf("invalid value for parameter of type %s", param.Type)
+			}
+			errs = append(errs, fmt.Errorf("%s: parameter %s: %v", context.fieldRoot, param.Name, err))
 		}
 	}
 	if missing != nil {
-		return fmt.Errorf("%s: unresolved parameter(s): %s", context.fieldRoot, missing)
+		errs = append(errs, fmt.Errorf("%s: unresolved parameter(s): %s", context.fieldRoot, missing))
 	}
-	return nil
+	return errs
 }
 
 func validateDependencies(fieldRoot string, dependencies []api.StepDependency) []error {
@@ -571,6 +994,34 @@ func validateDependencies(fieldRoot string, dependencies []api.StepDependency) [
 	return errs
 }
 
+var imageDigest = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+func validateExternalDependencies(fieldRoot string, externals []api.ExternalImageDependency, dependencies []api.StepDependency) []error {
+	var errs []error
+	env := sets.NewString()
+	for _, dependency := range dependencies {
+		env.Insert(dependency.Env)
+	}
+	for i, external := range externals {
+		if external.PullSpec == "" {
+			errs = append(errs, fmt.Errorf("%s.external_dependencies[%d].pull_spec must be set", fieldRoot, i))
+		} else if _, err := reference.ParseNormalizedNamed(external.PullSpec); err != nil {
+			errs = append(errs, fmt.Errorf("%s.external_dependencies[%d].pull_spec is not a valid pull spec: %w", fieldRoot, i, err))
+		}
+		if !imageDigest.MatchString(external.Digest) {
+			errs = append(errs, fmt.Errorf("%s.external_dependencies[%d].digest must take the `sha256:<hex>` form, not %q", fieldRoot, i, external.Digest))
+		}
+		if external.Env == "" {
+			errs = append(errs, fmt.Errorf("%s.external_dependencies[%d].env must be set", fieldRoot, i))
+		} else if env.Has(external.Env) {
+			errs = append(errs, fmt.Errorf("%s.external_dependencies[%d].env targets an environment variable that is already set by another dependency", fieldRoot, i))
+		} else {
+			env.Insert(external.Env)
+		}
+	}
+	return errs
+}
+
 func validateLeases(context context, leases []api.StepLease) (ret []error) {
 	for i, l := range leases {
 		if l.ResourceType == "" {
@@ -3,13 +3,17 @@ package validation
 import (
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/utils/diff"
 
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+
 	"github.com/openshift/ci-tools/pkg/api"
 )
 
@@ -737,6 +741,72 @@ func TestValidateTestSteps(t *testing.T) {
 		errs: []error{
 			errors.New("test[0]: `optional_on_success` is only allowed for Post steps"),
 		},
+	}, {
+		name: "invalid timeouts",
+		steps: []api.TestStep{{
+			LiteralTestStep: &api.LiteralTestStep{
+				As:          "as",
+				From:        "from",
+				Commands:    "commands",
+				Resources:   resources,
+				Timeout:     &prowv1.Duration{},
+				GracePeriod: &prowv1.Duration{Duration: -time.Second}},
+		}},
+		errs: []error{
+			errors.New("test[0].timeout must be positive, got 0s"),
+			errors.New("test[0].grace_period must be positive, got -1s"),
+		},
+	}, {
+		name: "valid condition",
+		steps: []api.TestStep{{
+			LiteralTestStep: &api.LiteralTestStep{
+				As:        "as",
+				From:      "from",
+				Commands:  "commands",
+				Resources: resources,
+				If:        "CLUSTER_TYPE == aws"},
+		}},
+	}, {
+		name: "invalid condition",
+		steps: []api.TestStep{{
+			LiteralTestStep: &api.LiteralTestStep{
+				As:        "as",
+				From:      "from",
+				Commands:  "commands",
+				Resources: resources,
+				If:        "CLUSTER_TYPE = aws"},
+		}},
+		errs: []error{
+			errors.New(`test[0].if: invalid expression: unexpected character '='`),
+		},
+	}, {
+		name: "valid upgrade step",
+		steps: []api.TestStep{{
+			LiteralTestStep: &api.LiteralTestStep{
+				As:      "as",
+				Upgrade: &api.UpgradeStep{From: "initial", To: "latest"}},
+		}},
+	}, {
+		name: "invalid upgrade step",
+		steps: []api.TestStep{{
+			LiteralTestStep: &api.LiteralTestStep{
+				As:        "as",
+				From:      "from",
+				Commands:  "commands",
+				Resources: resources,
+				Upgrade:   &api.UpgradeStep{From: "latest", To: "latest"}},
+		}, {
+			LiteralTestStep: &api.LiteralTestStep{
+				As:      "other",
+				Upgrade: &api.UpgradeStep{To: "Latest"}},
+		}},
+		errs: []error{
+			errors.New("test[0].upgrade: `from` and `to` must be different releases"),
+			errors.New("test[0]: `from` cannot be set for upgrade steps"),
+			errors.New("test[0]: `commands` cannot be set for upgrade steps"),
+			errors.New("test[1].upgrade.from is required"),
+			errors.New("test[1].upgrade.to: 'Latest' is not a valid release name"),
+		},
 	}, {
 		name: "Multiple errors",
 		steps: []api.TestStep{{
@@ -832,6 +902,31 @@ func TestValidateParameters(t *testing.T) {
 		params: []api.StepParameter{{Name: "TEST0"}, {Name: "TEST1"}},
 		env:    api.TestEnvironment{"TEST0": "test0"},
 		err:    []error{errors.New("test: unresolved parameter(s): [TEST1]")},
+	}, {
+		name:   "typed parameters with valid values",
+		params: []api.StepParameter{{Name: "COUNT", Type: api.ParameterTypeInt}, {Name: "ENABLED", Type: api.ParameterTypeBool}, {Name: "MODE", Type: api.ParameterTypeEnum, Values: []string{"fast", "slow"}}},
+		env:    api.TestEnvironment{"COUNT": "3", "ENABLED": "true", "MODE": "slow"},
+	}, {
+		name:   "typed parameters with invalid values",
+		params: []api.StepParameter{{Name: "COUNT", Type: api.ParameterTypeInt}, {Name: "MODE", Type: api.ParameterTypeEnum, Values: []string{"fast", "slow"}, Default: &defaultStr}},
+		env:    api.TestEnvironment{"COUNT": "three"},
+		err: []error{
+			errors.New(`test: parameter COUNT: value "three" is not an integer`),
+			errors.New(`test: parameter MODE: value "default" is not one of fast, slow`),
+		},
+	}, {
+		name:   "invalid value of a secret parameter is not exposed",
+		params: []api.StepParameter{{Name: "TOKEN", Type: api.ParameterTypeInt, Secret: true}},
+		env:    api.TestEnvironment{"TOKEN": "hunter2"},
+		err:    []error{errors.New("test: parameter TOKEN: invalid value for parameter of type int")},
+	}, {
+		name:   "invalid type and values",
+		params: []api.StepParameter{{Name: "A", Type: "float"}, {Name: "B", Type: api.ParameterTypeEnum, Default: &defaultStr}, {Name: "C", Values: []string{"c"}, Default: &defaultStr}},
+		err: []error{
+			errors.New(`test.env[0].type must be one of string, int, bool or enum, got "float"`),
+			errors.New("test.env[1].values must be set for parameters of type enum"),
+			errors.New("test.env[2].values can only be set for parameters of type enum"),
+		},
 	}} {
 		t.Run(tc.name, func(t *testing.T) {
 			err := validateLiteralTestStep(newContext("test", tc.env, tc.releases), testStageTest, api.LiteralTestStep{
@@ -869,6 +964,21 @@ func TestValidateCredentials(t *testing.T) {
 				errors.New("root.credentials[0].name cannot be empty"),
 			},
 		},
+		{
+			name: "cred mount from vault means no error",
+			input: []api.CredentialReference{
+				{VaultPath: "kv/team/aws", MountPath: "/foo"},
+			},
+		},
+		{
+			name: "cred mount from vault and namespace means error",
+			input: []api.CredentialReference{
+				{VaultPath: "kv/team/aws", Namespace: "ns", MountPath: "/foo"},
+			},
+			output: []error{
+				errors.New("root.credentials[0].vault_path cannot be set together with name or namespace"),
+			},
+		},
 		{
 			name: "cred mount with no namespace means error",
 			input: []api.CredentialReference{
@@ -945,6 +1055,539 @@ func TestValidateCredentials(t *testing.T) {
 	}
 }
 
+func TestValidateWorkspace(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		input  *api.WorkspaceConfiguration
+		output []error
+	}{
+		{
+			name: "no workspace means no error",
+		},
+		{
+			name:  "valid workspace means no error",
+			input: &api.WorkspaceConfiguration{Size: "100Gi", StorageClass: "fast", MountPath: "/scratch"},
+		},
+		{
+			name:   "workspace without size means error",
+			input:  &api.WorkspaceConfiguration{},
+			output: []error{errors.New("root.workspace.size cannot be empty")},
+		},
+		{
+			name:   "workspace with negative size means error",
+			input:  &api.WorkspaceConfiguration{Size: "-1Gi"},
+			output: []error{errors.New("root.workspace.size must be positive, got -1Gi")},
+		},
+		{
+			name:   "workspace with relative mount path means error",
+			input:  &api.WorkspaceConfiguration{Size: "1Gi", MountPath: "scratch"},
+			output: []error{errors.New("root.workspace.mount_path is not absolute: scratch")},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual, expected := validateWorkspace("root.workspace", testCase.input), testCase.output; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: got incorrect errors: %s", testCase.name, cmp.Diff(actual, expected, cmp.Comparer(func(x, y error) bool {
+					return x.Error() == y.Error()
+				})))
+			}
+		})
+	}
+}
+
+func TestValidateSharedDir(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		input  *api.SharedDirConfiguration
+		output []error
+	}{
+		{
+			name: "no shared dir means no error",
+		},
+		{
+			name:  "valid shared dir means no error",
+			input: &api.SharedDirConfiguration{Medium: api.SharedDirMediumMemory, SizeLimit: "100Mi"},
+		},
+		{
+			name:   "unknown medium means error",
+			input:  &api.SharedDirConfiguration{Medium: "tape"},
+			output: []error{errors.New("root.shared_dir.medium must be one of disk, memory, got tape")},
+		},
+		{
+			name:   "negative size limit means error",
+			input:  &api.SharedDirConfiguration{SizeLimit: "-1Mi"},
+			output: []error{errors.New("root.shared_dir.size_limit must be positive, got -1Mi")},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual, expected := validateSharedDir("root.shared_dir", testCase.input), testCase.output; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: got incorrect errors: %s", testCase.name, cmp.Diff(actual, expected, cmp.Comparer(func(x, y error) bool {
+					return x.Error() == y.Error()
+				})))
+			}
+		})
+	}
+}
+
+func TestValidateDataDir(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		input  *api.DataDirConfiguration
+		output []error
+	}{
+		{
+			name: "no data dir means no error",
+		},
+		{
+			name:  "valid data dir means no error",
+			input: &api.DataDirConfiguration{Size: "1Ti", StorageClass: "fast"},
+		},
+		{
+			name:   "data dir without size means error",
+			input:  &api.DataDirConfiguration{},
+			output: []error{errors.New("root.data_dir.size cannot be empty")},
+		},
+		{
+			name:   "data dir with zero size means error",
+			input:  &api.DataDirConfiguration{Size: "0"},
+			output: []error{errors.New("root.data_dir.size must be positive, got 0")},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual, expected := validateDataDir("root.data_dir", testCase.input), testCase.output; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: got incorrect errors: %s", testCase.name, cmp.Diff(actual, expected, cmp.Comparer(func(x, y error) bool {
+					return x.Error() == y.Error()
+				})))
+			}
+		})
+	}
+}
+
+func TestValidateClusterClaim(t *testing.T) {
+	negative := -1
+	var testCases = []struct {
+		name         string
+		provisioning *api.ClusterProvisioningConfiguration
+		input        *api.ClusterClaimConfiguration
+		output       []error
+	}{
+		{
+			name: "no claim means no error",
+		},
+		{
+			name:  "valid claim means no error",
+			input: &api.ClusterClaimConfiguration{Namespace: "pools", Pool: "ocp-4.9-aws"},
+		},
+		{
+			name:         "claim with provisioning means error",
+			provisioning: &api.ClusterProvisioningConfiguration{Version: "openshift-v4.9.0", Region: "us-east-1"},
+			input:        &api.ClusterClaimConfiguration{Namespace: "pools", Pool: "ocp-4.9-aws"},
+			output:       []error{errors.New("root.cluster_claim and root.cluster_provisioning are mutually exclusive")},
+		},
+		{
+			name:  "claim without pool and with negative retries means error",
+			input: &api.ClusterClaimConfiguration{Retries: &negative},
+			output: []error{
+				errors.New("root.cluster_claim.namespace cannot be empty"),
+				errors.New("root.cluster_claim.pool cannot be empty"),
+				errors.New("root.cluster_claim.retries cannot be negative, got -1"),
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual, expected := validateClusterClaim("root", testCase.provisioning, testCase.input), testCase.output; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: got incorrect errors: %s", testCase.name, cmp.Diff(actual, expected, cmp.Comparer(func(x, y error) bool {
+					return x.Error() == y.Error()
+				})))
+			}
+		})
+	}
+}
+
+func TestValidateClusterProvisioning(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		profile api.ClusterProfile
+		input   *api.ClusterProvisioningConfiguration
+		output  []error
+	}{
+		{
+			name:    "no provisioning means no error",
+			profile: api.ClusterProfileAWS,
+		},
+		{
+			name:    "valid provisioning means no error",
+			profile: api.ClusterProfileGCP,
+			input:   &api.ClusterProvisioningConfiguration{Version: "openshift-v4.9.0", Region: "us-east1", BaseDomain: "ci.example.com"},
+		},
+		{
+			name:   "provisioning without profile means error",
+			input:  &api.ClusterProvisioningConfiguration{Version: "openshift-v4.9.0", Region: "us-east-1", BaseDomain: "ci.example.com"},
+			output: []error{errors.New("root.cluster_provisioning requires cluster_profile to be set")},
+		},
+		{
+			name:    "provisioning with unsupported profile means error",
+			profile: api.ClusterProfileVSphere,
+			input:   &api.ClusterProvisioningConfiguration{Version: "openshift-v4.9.0", Region: "us-east-1", BaseDomain: "ci.example.com"},
+			output:  []error{errors.New("root.cluster_provisioning: clusters of type vsphere cannot be provisioned, must be one of aws, gcp")},
+		},
+		{
+			name:    "provisioning without version, region and base domain means error",
+			profile: api.ClusterProfileAWS,
+			input:   &api.ClusterProvisioningConfiguration{},
+			output: []error{
+				errors.New("root.cluster_provisioning.version cannot be empty"),
+				errors.New("root.cluster_provisioning.region cannot be empty"),
+				errors.New("root.cluster_provisioning.base_domain cannot be empty"),
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual, expected := validateClusterProvisioning("root", testCase.profile, testCase.input), testCase.output; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: got incorrect errors: %s", testCase.name, cmp.Diff(actual, expected, cmp.Comparer(func(x, y error) bool {
+					return x.Error() == y.Error()
+				})))
+			}
+		})
+	}
+}
+
+func TestValidateClusterProfileOverlay(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		profile api.ClusterProfile
+		input   *api.ClusterProfileOverlay
+		output  []error
+	}{
+		{
+			name:    "no overlay means no error",
+			profile: api.ClusterProfileAWS,
+		},
+		{
+			name:    "valid overlay means no error",
+			profile: api.ClusterProfileAWS,
+			input: &api.ClusterProfileOverlay{
+				Secrets:     []api.ClusterProfileOverlaySource{{Namespace: "ns", Name: "pull-secret"}},
+				ConfigMaps:  []api.ClusterProfileOverlaySource{{Namespace: "ns", Name: "install-config"}},
+				Environment: api.TestEnvironment{"EXTRA_ACCOUNT": "true"},
+			},
+		},
+		{
+			name:   "overlay without profile means error",
+			input:  &api.ClusterProfileOverlay{Environment: api.TestEnvironment{"EXTRA_ACCOUNT": "true"}},
+			output: []error{errors.New("root.cluster_profile_overlay requires cluster_profile to be set")},
+		},
+		{
+			name:    "sources without namespace or name mean error",
+			profile: api.ClusterProfileAWS,
+			input: &api.ClusterProfileOverlay{
+				Secrets:    []api.ClusterProfileOverlaySource{{Name: "pull-secret"}},
+				ConfigMaps: []api.ClusterProfileOverlaySource{{Namespace: "ns", Name: "install-config"}, {Namespace: "ns"}},
+			},
+			output: []error{
+				errors.New("root.cluster_profile_overlay.secrets[0].namespace cannot be empty"),
+				errors.New("root.cluster_profile_overlay.config_maps[1].name cannot be empty"),
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual, expected := validateClusterProfileOverlay("root", testCase.profile, testCase.input), testCase.output; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: got incorrect errors: %s", testCase.name, cmp.Diff(actual, expected, cmp.Comparer(func(x, y error) bool {
+					return x.Error() == y.Error()
+				})))
+			}
+		})
+	}
+}
+
+func TestValidateGather(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		input  *api.GatherConfiguration
+		output []error
+	}{
+		{
+			name: "no gather configuration means no error",
+		},
+		{
+			name:  "valid gather configuration means no error",
+			input: &api.GatherConfiguration{Steps: []api.GatherStep{api.GatherStepMustGather}, MaxSize: "1Gi", Timeout: &prowv1.Duration{Duration: time.Hour}},
+		},
+		{
+			name:  "disabled gather means no error",
+			input: &api.GatherConfiguration{Disabled: true},
+		},
+		{
+			name:   "unknown step means error",
+			input:  &api.GatherConfiguration{Steps: []api.GatherStep{"audit-logs"}},
+			output: []error{errors.New(`root.gather.steps[0]: unknown step "audit-logs", must be one of must-gather, extra`)},
+		},
+		{
+			name:   "zero size means error",
+			input:  &api.GatherConfiguration{MaxSize: "0"},
+			output: []error{errors.New("root.gather.max_size must be positive, got 0")},
+		},
+		{
+			name:   "negative timeout means error",
+			input:  &api.GatherConfiguration{Timeout: &prowv1.Duration{Duration: -time.Minute}},
+			output: []error{errors.New("root.gather.timeout must be positive, got -1m0s")},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual, expected := validateGather("root.gather", testCase.input), testCase.output; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: got incorrect errors: %s", testCase.name, cmp.Diff(actual, expected, cmp.Comparer(func(x, y error) bool {
+					return x.Error() == y.Error()
+				})))
+			}
+		})
+	}
+}
+
+func TestValidateDisruptionRetries(t *testing.T) {
+	zero, negative := 0, -1
+	var testCases = []struct {
+		name   string
+		input  *int
+		output []error
+	}{
+		{
+			name: "default means no error",
+		},
+		{
+			name:  "disabled means no error",
+			input: &zero,
+		},
+		{
+			name:   "negative count means error",
+			input:  &negative,
+			output: []error{errors.New("root.disruption_retries must not be negative, got -1")},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual, expected := validateDisruptionRetries("root.disruption_retries", testCase.input), testCase.output; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: got incorrect errors: %s", testCase.name, cmp.Diff(actual, expected, cmp.Comparer(func(x, y error) bool {
+					return x.Error() == y.Error()
+				})))
+			}
+		})
+	}
+}
+
+func TestValidateRetries(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		input  *api.StepRetries
+		output []error
+	}{
+		{
+			name: "no retries means no error",
+		},
+		{
+			name:  "valid retries means no error",
+			input: &api.StepRetries{Count: 2, Until: &prowv1.Duration{Duration: time.Hour}},
+		},
+		{
+			name:   "retries without count means error",
+			input:  &api.StepRetries{},
+			output: []error{errors.New("root.retries.count must be positive, got 0")},
+		},
+		{
+			name:   "retries with negative until means error",
+			input:  &api.StepRetries{Count: 1, Until: &prowv1.Duration{Duration: -time.Minute}},
+			output: []error{errors.New("root.retries.until must be positive, got -1m0s")},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual, expected := validateRetries("root.retries", testCase.input), testCase.output; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: got incorrect errors: %s", testCase.name, cmp.Diff(actual, expected, cmp.Comparer(func(x, y error) bool {
+					return x.Error() == y.Error()
+				})))
+			}
+		})
+	}
+}
+
+func TestValidateArtifactsToRegistry(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		input  *api.ArtifactsToRegistry
+		output []error
+	}{
+		{
+			name: "no artifacts means no error",
+		},
+		{
+			name:  "valid artifacts mean no error",
+			input: &api.ArtifactsToRegistry{Repository: "quay.io/org/bundles", Tag: "v1.0", ArtifactType: "application/vnd.example.bundle", Files: []string{"bundle.tar.gz", "bin/*"}, Credentials: api.CredentialReference{Namespace: "org", Name: "bundles-push"}},
+		},
+		{
+			name:  "incomplete artifacts mean error",
+			input: &api.ArtifactsToRegistry{},
+			output: []error{
+				errors.New("root.artifacts_to_registry.repository cannot be empty"),
+				errors.New("root.artifacts_to_registry.files cannot be empty"),
+				errors.New("root.artifacts_to_registry.credentials must reference a secret by namespace and name or by vault_path"),
+			},
+		},
+		{
+			name:  "credentials from Vault mean no error",
+			input: &api.ArtifactsToRegistry{Repository: "quay.io/org/bundles", Files: []string{"bundle.tar.gz"}, Credentials: api.CredentialReference{VaultPath: "org/bundles-push"}},
+		},
+		{
+			name:  "central push credentials mean error",
+			input: &api.ArtifactsToRegistry{Repository: "quay.io/org/bundles", Files: []string{"bundle.tar.gz"}, Credentials: api.CredentialReference{Namespace: "test-credentials", Name: "registry-push-credentials-ci-central"}},
+			output: []error{
+				errors.New("root.artifacts_to_registry.credentials: registry-push-credentials-ci-central cannot be used, provide credentials for the repository"),
+			},
+		},
+		{
+			name:  "ambiguous credentials with a mount path mean error",
+			input: &api.ArtifactsToRegistry{Repository: "quay.io/org/bundles", Files: []string{"bundle.tar.gz"}, Credentials: api.CredentialReference{Namespace: "org", Name: "bundles-push", VaultPath: "org/bundles-push", MountPath: "/push"}},
+			output: []error{
+				errors.New("root.artifacts_to_registry.credentials.vault_path cannot be set together with name or namespace"),
+				errors.New("root.artifacts_to_registry.credentials.mount_path cannot be set"),
+			},
+		},
+		{
+			name:  "repository with tag and invalid tag mean error",
+			input: &api.ArtifactsToRegistry{Repository: "quay.io/org/bundles:latest", Tag: "-v1", Files: []string{"bundle.tar.gz"}, Credentials: api.CredentialReference{Namespace: "org", Name: "bundles-push"}},
+			output: []error{
+				errors.New(`root.artifacts_to_registry.repository: "quay.io/org/bundles:latest" must not have a tag or digest, use tag instead`),
+				errors.New(`root.artifacts_to_registry.tag: "-v1" is not a valid tag`),
+			},
+		},
+		{
+			name:   "invalid artifact type means error",
+			input:  &api.ArtifactsToRegistry{Repository: "quay.io/org/bundles", ArtifactType: "bundle/", Files: []string{"bundle.tar.gz"}, Credentials: api.CredentialReference{Namespace: "org", Name: "bundles-push"}},
+			output: []error{errors.New(`root.artifacts_to_registry.artifact_type: invalid media type "bundle/": mime: expected token after slash`)},
+		},
+		{
+			name:  "files outside of the artifacts or with shell characters mean error",
+			input: &api.ArtifactsToRegistry{Repository: "quay.io/org/bundles", Files: []string{"/etc/passwd", "../secret", "$(id)"}, Credentials: api.CredentialReference{Namespace: "org", Name: "bundles-push"}},
+			output: []error{
+				errors.New(`root.artifacts_to_registry.files[0]: "/etc/passwd" must be relative to the artifacts directory`),
+				errors.New(`root.artifacts_to_registry.files[1]: "../secret" must not leave the artifacts directory`),
+				errors.New(`root.artifacts_to_registry.files[2]: "$(id)" may only contain alphanumeric characters, '_', '.', '-', '/' and the globs '*' and '?'`),
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual, expected := validateArtifactsToRegistry("root.artifacts_to_registry", testCase.input), testCase.output; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: got incorrect errors: %s", testCase.name, cmp.Diff(actual, expected, cmp.Comparer(func(x, y error) bool {
+					return x.Error() == y.Error()
+				})))
+			}
+		})
+	}
+}
+
+func TestValidateSidecars(t *testing.T) {
+	resources := api.ResourceRequirements{Requests: api.ResourceList{"cpu": "100m"}}
+	var testCases = []struct {
+		name   string
+		input  []api.Sidecar
+		output []error
+	}{
+		{
+			name: "no sidecars means no error",
+		},
+		{
+			name:  "valid sidecars mean no error",
+			input: []api.Sidecar{{Name: "db", From: "postgres", Commands: "postgres", Resources: resources}, {Name: "proxy", From: "pipeline:proxy", Commands: "proxy", Resources: resources}},
+		},
+		{
+			name:  "sidecar with invalid name means error",
+			input: []api.Sidecar{{Name: "DB", From: "postgres", Commands: "postgres", Resources: resources}},
+			output: []error{
+				errors.New("root.sidecars[0].name: 'DB' is not a valid container name: a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')"),
+			},
+		},
+		{
+			name:   "sidecar with reserved name means error",
+			input:  []api.Sidecar{{Name: "test", From: "postgres", Commands: "postgres", Resources: resources}},
+			output: []error{errors.New("root.sidecars[0].name: 'test' is reserved")},
+		},
+		{
+			name:   "duplicated sidecars mean error",
+			input:  []api.Sidecar{{Name: "db", From: "postgres", Commands: "postgres", Resources: resources}, {Name: "db", From: "postgres", Commands: "postgres", Resources: resources}},
+			output: []error{errors.New(`root.sidecars[1].name: duplicated name "db"`)},
+		},
+		{
+			name:  "incomplete sidecar means error",
+			input: []api.Sidecar{{Name: "db"}},
+			output: []error{
+				errors.New("root.sidecars[0].from cannot be empty"),
+				errors.New("root.sidecars[0].commands cannot be empty"),
+				errors.New("'root.sidecars[0].resources' should have at least one request or limit"),
+			},
+		},
+		{
+			name:   "sidecar from unknown imagestream means error",
+			input:  []api.Sidecar{{Name: "db", From: "unknown:postgres", Commands: "postgres", Resources: resources}},
+			output: []error{errors.New("root.sidecars[0].from: unknown imagestream 'unknown'")},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual, expected := validateSidecars("root.sidecars", testCase.input, sets.NewString()), testCase.output; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: got incorrect errors: %s", testCase.name, cmp.Diff(actual, expected, cmp.Comparer(func(x, y error) bool {
+					return x.Error() == y.Error()
+				})))
+			}
+		})
+	}
+}
+
+func TestValidateServiceAccount(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		input  *api.StepServiceAccount
+		output []error
+	}{
+		{
+			name: "no service account means no error",
+		},
+		{
+			name:  "service account without rules means no error",
+			input: &api.StepServiceAccount{},
+		},
+		{
+			name: "valid rules mean no error",
+			input: &api.StepServiceAccount{Rules: []api.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+				{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, ResourceNames: []string{"app"}, Verbs: []string{"get", "update"}},
+			}},
+		},
+		{
+			name:  "incomplete rule means error",
+			input: &api.StepServiceAccount{Rules: []api.PolicyRule{{}}},
+			output: []error{
+				errors.New(`root.service_account.rules[0].api_groups cannot be empty, use "" for the core group`),
+				errors.New("root.service_account.rules[0].resources cannot be empty"),
+				errors.New("root.service_account.rules[0].verbs cannot be empty"),
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual, expected := validateServiceAccount("root.service_account", testCase.input), testCase.output; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: got incorrect errors: %s", testCase.name, cmp.Diff(actual, expected, cmp.Comparer(func(x, y error) bool {
+					return x.Error() == y.Error()
+				})))
+			}
+		})
+	}
+}
+
 func TestValidateDependencies(t *testing.T) {
 	var testCases = []struct {
 		name   string
@@ -990,6 +1633,104 @@ func TestValidateDependencies(t *testing.T) {
 	}
 }
 
+func TestValidateExternalDependencies(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	var testCases = []struct {
+		name   string
+		input  []api.ExternalImageDependency
+		output []error
+	}{
+		{
+			name: "no external dependencies",
+		},
+		{
+			name: "valid external dependencies",
+			input: []api.ExternalImageDependency{
+				{PullSpec: "quay.io/org/image:tag", Digest: digest, Env: "IMAGE"},
+				{PullSpec: "quay.io/org/other@" + digest, Digest: digest, Env: "OTHER"},
+			},
+		},
+		{
+			name: "invalid external dependencies",
+			input: []api.ExternalImageDependency{
+				{},
+				{PullSpec: "quay.io/org/image:tag", Digest: "sha256:abc", Env: "SOURCE"},
+				{PullSpec: "quay.io/org/image:tag", Digest: digest, Env: "IMAGE"},
+				{PullSpec: "quay.io/org/image:tag", Digest: digest, Env: "IMAGE"},
+				{PullSpec: "quay.io/Org/image:tag", Digest: digest, Env: "UPPER"},
+			},
+			output: []error{
+				errors.New("root.external_dependencies[0].pull_spec must be set"),
+				errors.New("root.external_dependencies[0].digest must take the `sha256:<hex>` form, not \"\""),
+				errors.New("root.external_dependencies[0].env must be set"),
+				errors.New("root.external_dependencies[1].digest must take the `sha256:<hex>` form, not \"sha256:abc\""),
+				errors.New("root.external_dependencies[1].env targets an environment variable that is already set by another dependency"),
+				errors.New("root.external_dependencies[3].env targets an environment variable that is already set by another dependency"),
+				errors.New("root.external_dependencies[4].pull_spec is not a valid pull spec: invalid reference format: repository name must be lowercase"),
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := validateExternalDependencies("root", testCase.input, []api.StepDependency{{Name: "src", Env: "SOURCE"}})
+			if diff := cmp.Diff(testCase.output, actual, cmp.Comparer(func(x, y error) bool {
+				return x.Error() == y.Error()
+			})); diff != "" {
+				t.Errorf("got incorrect errors: %s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateObservers(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		input  []api.Observer
+		output []error
+	}{
+		{
+			name: "no observers",
+		},
+		{
+			name: "valid observers",
+			input: []api.Observer{
+				{Name: "must-gather", From: "src", Commands: "gather"},
+				{Name: "tcpdump", FromImage: &api.ImageStreamTagReference{Namespace: "ci", Name: "tcpdump", Tag: "latest"}, Commands: "tcpdump"},
+			},
+		},
+		{
+			name: "invalid observers",
+			input: []api.Observer{
+				{},
+				{Name: "step", From: "src", Commands: "gather"},
+				{Name: "both", From: "src", FromImage: &api.ImageStreamTagReference{Namespace: "ci", Name: "tcpdump", Tag: "latest"}, Commands: "gather"},
+				{Name: "unknown", From: "unknown:src", Commands: "gather"},
+			},
+			output: []error{
+				errors.New("root.observers[0]: `name` is required"),
+				errors.New("root.observers[0]: `from` or `from_image` is required"),
+				errors.New("root.observers[0]: `commands` is required"),
+				errors.New("root.observers[1]: duplicated name \"step\""),
+				errors.New("root.observers[2]: `from` and `from_image` cannot be set together"),
+				errors.New("root.observers[3].from: unknown imagestream 'unknown'"),
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			context := newContext("root", nil, sets.NewString())
+			context.seen.Insert("step")
+			if diff := cmp.Diff(testCase.output, validateObservers(context.forField(".observers"), testCase.input), cmp.Comparer(func(x, y error) bool {
+				return x.Error() == y.Error()
+			})); diff != "" {
+				t.Errorf("got incorrect errors: %s", diff)
+			}
+		})
+	}
+}
+
 func TestValidateLeases(t *testing.T) {
 	for _, tc := range []struct {
 		name string
@@ -19,10 +19,42 @@ func New(addr, token string) (*VaultClient, error) {
 	return &VaultClient{client}, nil
 }
 
+// NewFromAppRole logs into Vault with the AppRole auth method
+func NewFromAppRole(addr, roleID, secretID string) (*VaultClient, error) {
+	return newFromLogin(addr, "auth/approle/login", map[string]interface{}{"role_id": roleID, "secret_id": secretID})
+}
+
+// NewFromKubernetesAuth logs into Vault with the Kubernetes auth method,
+// authenticating as the service account the token belongs to
+func NewFromKubernetesAuth(addr, role, serviceAccountToken string) (*VaultClient, error) {
+	return newFromLogin(addr, "auth/kubernetes/login", map[string]interface{}{"role": role, "jwt": serviceAccountToken})
+}
+
+func newFromLogin(addr, path string, data map[string]interface{}) (*VaultClient, error) {
+	client, err := api.NewClient(&api.Config{Address: addr})
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.Logical().Write(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in: %w", err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("failed to log in: no token in response")
+	}
+	client.SetToken(secret.Auth.ClientToken)
+	return &VaultClient{client}, nil
+}
+
 type VaultClient struct {
 	*api.Client
 }
 
+// RevokeSelf revokes the token of the client, which cannot be used afterwards
+func (v *VaultClient) RevokeSelf() error {
+	return v.Auth().Token().RevokeSelf("")
+}
+
 func (v *VaultClient) GetUserFromAliasName(userName string) (*Entity, error) {
 	rawAliases, err := v.Client.Logical().List("identity/entity-alias/id")
 	if err != nil {
@@ -2,6 +2,9 @@ package vaultclient
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -81,3 +84,46 @@ func TestListKVRecursively(tt *testing.T) {
 		t.Errorf("actual resutl differs from expected: %v", err)
 	}
 }
+
+func TestNewFromLogin(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		response    string
+		expectedErr bool
+	}{
+		{
+			name:     "token from login is used",
+			response: `{"auth":{"client_token":"token"}}`,
+		},
+		{
+			name:        "response without token is an error",
+			response:    `{}`,
+			expectedErr: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/auth/approle/login" {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				var body map[string]string
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("failed to decode body: %v", err)
+				}
+				if diff := cmp.Diff(map[string]string{"role_id": "role", "secret_id": "secret"}, body); diff != "" {
+					t.Errorf("unexpected login request: %s", diff)
+				}
+				w.Write([]byte(testCase.response))
+			}))
+			defer server.Close()
+			client, err := NewFromAppRole(server.URL, "role", "secret")
+			if (err != nil) != testCase.expectedErr {
+				t.Fatalf("expected error: %t, got %v", testCase.expectedErr, err)
+			}
+			if err == nil && client.Token() != "token" {
+				t.Errorf("expected client to use token from login, got %q", client.Token())
+			}
+		})
+	}
+}
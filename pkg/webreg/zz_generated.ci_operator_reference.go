@@ -1,6 +1,36 @@
 package webreg
 
-const ciOperatorReferenceYaml = "# The list of base images describe\n" +
+const ciOperatorReferenceYaml = "# APIVersion is the version of the schema the configuration is\n" +
+	"# written in. Configurations without it are in the first version.\n" +
+	"# Older versions are migrated to ConfigAPIVersion when loaded.\n" +
+	"apiVersion: ' '\n" +
+	"# Approval pauses the job before it promotes until the promotion\n" +
+	"# is approved, for pipelines which promote in stages.\n" +
+	"approval:\n" +
+	"    # Timeout is how long the job waits for a decision before it\n" +
+	"    # fails. Defaults to one hour.\n" +
+	"    timeout: 0s\n" +
+	"    # URL is polled for the decision with the namespace, job and\n" +
+	"    # build ID as query parameters. It must respond with a JSON\n" +
+	"    # object holding the decision, one of pending, approved and\n" +
+	"    # rejected, and the approver.\n" +
+	"    url: ' '\n" +
+	"# ArtifactGathering limits the artifacts ci-operator gathers from the\n" +
+	"# pods of steps. The special name '*' may be used to set the default for\n" +
+	"# all steps. Multi-stage tests upload their artifacts from their pods\n" +
+	"# and cannot be limited here.\n" +
+	"artifact_gathering:\n" +
+	"    \"\":\n" +
+	"        compress:\n" +
+	"            - \"\"\n" +
+	"        max_size: ' '\n" +
+	"# Attestations enables generating a software bill of materials\n" +
+	"# and provenance for every image built by the job.\n" +
+	"attestations:\n" +
+	"    # SBOMFormat is the format of the bill of materials, one of\n" +
+	"    # spdx-json or cyclonedx-json. Defaults to spdx-json.\n" +
+	"    sbom_format: ' '\n" +
+	"# The list of base images describe\n" +
 	"# which images are going to be necessary outside\n" +
 	"# of the pipeline. The key will be the alias that other\n" +
 	"# steps use to refer to this image.\n" +
@@ -70,12 +100,75 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"# Go. If specified the location of the repository we are\n" +
 	"# cloning from is ignored.\n" +
 	"canonical_go_repository: \"\"\n" +
+	"# Clonerefs replaces the image the source is cloned with, for forks and\n" +
+	"# disconnected installations which supply their own clonerefs. The\n" +
+	"# --clonerefs-image flag of ci-operator takes precedence.\n" +
+	"clonerefs:\n" +
+	"    # Image is the image stream tag holding the tool.\n" +
+	"    image:\n" +
+	"        # As is an optional string to use as the intermediate name for this reference.\n" +
+	"        as: ' '\n" +
+	"        name: ' '\n" +
+	"        namespace: ' '\n" +
+	"        tag: ' '\n" +
+	"    # Path is the path of the tool in the image, /clonerefs by default.\n" +
+	"    path: ' '\n" +
+	"# Contacts identifies the team owning the jobs generated from this\n" +
+	"# configuration and how to reach it. They are included in failure\n" +
+	"# summaries so that failures can be routed to the owners.\n" +
+	"contacts:\n" +
+	"    # Email is the address of the team's mailing list.\n" +
+	"    email: ' '\n" +
+	"    # Escalation is a link to where issues should be reported, e.g. a bug\n" +
+	"    # tracker component or a runbook.\n" +
+	"    escalation: ' '\n" +
+	"    # SlackChannel is the channel where the team can be reached, e.g. #forum-team.\n" +
+	"    slack_channel: ' '\n" +
+	"    # Team is the name of the team owning the jobs.\n" +
+	"    team: ' '\n" +
+	"# FIPSCheck enables checking that binaries in images built by the\n" +
+	"# job use FIPS-compliant cryptography.\n" +
+	"fips_check:\n" +
+	"    # Action is taken when binaries which are not compliant are\n" +
+	"    # found: fail fails the job, while warn only reports them.\n" +
+	"    # Defaults to fail.\n" +
+	"    action: ' '\n" +
+	"    # ForbiddenSymbols are symbols binaries must not contain.\n" +
+	"    # Defaults to the marker of the native Go cryptography.\n" +
+	"    forbidden_symbols:\n" +
+	"        - \"\"\n" +
+	"    # Images are the built images which are checked.\n" +
+	"    images:\n" +
+	"        - \"\"\n" +
+	"    # Paths are the directories in the images searched for\n" +
+	"    # binaries. Defaults to /usr/bin, /usr/sbin, /usr/libexec and\n" +
+	"    # /usr/local/bin.\n" +
+	"    paths:\n" +
+	"        - \"\"\n" +
 	"# Images describes the images that are built\n" +
 	"# baseImage the project as part of the release\n" +
 	"# process. The name of each image is its \"to\" value\n" +
 	"# and can be used to build only a specific image.\n" +
 	"images:\n" +
-	"    - # ContextDir is the directory in the project\n" +
+	"    - # BuildArgs are passed to the Dockerfile as build arguments, so\n" +
+	"      # that it can consume them with ARG instructions.\n" +
+	"      build_args:\n" +
+	"        - # Name is the name of the argument in the ARG instruction.\n" +
+	"          name: ' '\n" +
+	"          # Value is the static value of the argument.\n" +
+	"          value: ' '\n" +
+	"          # ValueFrom sources the value of the argument from the job.\n" +
+	"          # Mutually exclusive with Value.\n" +
+	"          value_from:\n" +
+	"            # JobMetadata is a property of the job: one of commit, base_ref,\n" +
+	"            # base_sha, pull_number, job_name or build_id. The commit is the\n" +
+	"            # head of the pull request under test or the base when there is\n" +
+	"            # none. Properties the job does not have are passed empty.\n" +
+	"            job_metadata: ' '\n" +
+	"            # Parameter is a parameter of the job, like RELEASE_IMAGE_LATEST.\n" +
+	"            # The build waits for the step providing it.\n" +
+	"            parameter: ' '\n" +
+	"      # ContextDir is the directory in the project\n" +
 	"      # from which this build should be run.\n" +
 	"      context_dir: ' '\n" +
 	"      # DockerfileLiteral can be used to provide an inline Dockerfile.\n" +
@@ -106,12 +199,63 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                  # SourcePath is a file or directory in the source image to copy from.\n" +
 	"                  source_path: ' '\n" +
 	"      to: ' '\n" +
+	"# Mirror lists images of the job which are pushed to external\n" +
+	"# registries once its tests pass, whether it promotes or not.\n" +
+	"mirror:\n" +
+	"    - # From is the image mirrored, as a tag of the pipeline or of a\n" +
+	"      # stable image stream, like pipeline:bin or stable:installer.\n" +
+	"      from: ' '\n" +
+	"      # To is the pull spec the image is pushed to.\n" +
+	"      to: ' '\n" +
+	"# Namespace configures policies applied to the ephemeral namespace the\n" +
+	"# job runs in, protecting shared clusters from runaway tests.\n" +
+	"namespace:\n" +
+	"    # LimitRange sets defaults and bounds for the containers created in\n" +
+	"    # the namespace.\n" +
+	"    limit_range:\n" +
+	"        # Default are the limits of containers which do not set them.\n" +
+	"        default:\n" +
+	"            \"\": \"\"\n" +
+	"        # DefaultRequest are the requests of containers which do not set them.\n" +
+	"        default_request:\n" +
+	"            \"\": \"\"\n" +
+	"        # Max are the largest limits a container may set.\n" +
+	"        max:\n" +
+	"            \"\": \"\"\n" +
+	"    # PriorityClassName is set on the pods created in the namespace which\n" +
+	"    # do not request a priority class, e.g. to prioritize release-blocking\n" +
+	"    # jobs. The priority class must exist in the cluster.\n" +
+	"    priority_class_name: ' '\n" +
+	"    # Quota caps the aggregate resources used in the namespace, keyed by\n" +
+	"    # the resource names of a ResourceQuota, e.g. requests.cpu or pods.\n" +
+	"    quota:\n" +
+	"        \"\": \"\"\n" +
 	"# Operator describes the operator bundle(s) that is built by the project\n" +
 	"operator:\n" +
 	"    # Bundles define a dockerfile and build context to build a bundle\n" +
 	"    bundles:\n" +
-	"        - context_dir: ' '\n" +
+	"        - # Channels are the channels the bundle is published in, overriding the\n" +
+	"          # ones in the metadata/annotations.yaml file of the bundle.\n" +
+	"          channels:\n" +
+	"            - \"\"\n" +
+	"          context_dir: ' '\n" +
+	"          # DefaultChannel is the default channel of the package, overriding the\n" +
+	"          # one in the metadata of the bundle.\n" +
+	"          default_channel: ' '\n" +
 	"          dockerfile_path: ' '\n" +
+	"    # Index configures the index image built from the bundles, which steps\n" +
+	"    # can use as the `ci-index` dependency.\n" +
+	"    index:\n" +
+	"        # BaseIndex is the pull spec of an existing index the bundles are added\n" +
+	"        # to. The index only contains the bundles when unset.\n" +
+	"        base_index: ' '\n" +
+	"        # PushTo is a pull spec, with a tag, the index is pushed to together\n" +
+	"        # with the promoted images.\n" +
+	"        push_to: ' '\n" +
+	"        # UpdateGraph is the mode used to add the bundles to the update graph\n" +
+	"        # of their package: semver, semver-skippatch or replaces. Defaults to\n" +
+	"        # semver.\n" +
+	"        update_graph: ' '\n" +
 	"    # Substitutions describes the pullspecs in the operator manifests that must be subsituted\n" +
 	"    # with the pull specs of the images in the CI registry\n" +
 	"    substitutions:\n" +
@@ -145,13 +289,73 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"    # Namespace identifies the namespace to which the built\n" +
 	"    # artifacts will be published to.\n" +
 	"    namespace: ' '\n" +
+	"    # Signing signs the images with cosign once they are\n" +
+	"    # promoted, pushing the signatures next to them.\n" +
+	"    signing:\n" +
+	"        # FulcioURL is the Fulcio instance issuing certificates for\n" +
+	"        # keyless signing. Defaults to the public instance.\n" +
+	"        fulcio_url: ' '\n" +
+	"        # OIDCIssuer is the issuer of the identity tokens of the\n" +
+	"        # promotion pod, which keyless signatures are verified\n" +
+	"        # against. Required for keyless signing.\n" +
+	"        oidc_issuer: ' '\n" +
+	"        # RekorURL is the transparency log signatures are recorded\n" +
+	"        # in. Keyless signatures are always recorded, by default in\n" +
+	"        # the public instance, while signatures made with a key are\n" +
+	"        # only recorded if this is set.\n" +
+	"        rekor_url: ' '\n" +
 	"    # Tag is the ImageStreamTag tagged in for each\n" +
 	"    # build image's ImageStream.\n" +
 	"    tag: ' '\n" +
+	"    # Targets are further destinations the images are promoted\n" +
+	"    # to, in addition to the one configured above.\n" +
+	"    targets:\n" +
+	"        - # Name is an optional image stream name to use that\n" +
+	"          # contains all component tags. If specified, tag is\n" +
+	"          # ignored.\n" +
+	"          name: ' '\n" +
+	"          # Namespace identifies the namespace to which the built\n" +
+	"          # artifacts will be published to.\n" +
+	"          namespace: ' '\n" +
+	"          # Quay pushes the images directly to repositories on quay.io\n" +
+	"          # instead of tagging them into image streams. The namespace\n" +
+	"          # is the Quay organization then.\n" +
+	"          quay:\n" +
+	"            # RobotPermissions grants robot accounts of the organization\n" +
+	"            # a role on the repositories. Keys are robot account names\n" +
+	"            # without the organization prefix and values are one of\n" +
+	"            # read, write or admin.\n" +
+	"            robot_permissions:\n" +
+	"                \"\": \"\"\n" +
+	"          # Tag is the ImageStreamTag tagged in for each\n" +
+	"          # build image's ImageStream.\n" +
+	"          tag: ' '\n" +
+	"          # TagTemplate names the tags of the image stream set in\n" +
+	"          # name, e.g. `${component}-${branch}`. Besides ${component},\n" +
+	"          # the name of the image, ${org}, ${repo}, ${branch} and\n" +
+	"          # ${variant} of the configuration can be used. Defaults to\n" +
+	"          # `${component}`.\n" +
+	"          tag_template: ' '\n" +
 	"# RawSteps are literal Steps that should be\n" +
 	"# included in the final pipeline.\n" +
 	"raw_steps:\n" +
 	"    - bundle_source_step:\n" +
+	"        # Bundles are the bundles built from the source, whose channels are\n" +
+	"        # overridden when configured\n" +
+	"        bundles:\n" +
+	"            - # Channels are the channels the bundle is published in, overriding the\n" +
+	"              # ones in the metadata/annotations.yaml file of the bundle.\n" +
+	"              channels:\n" +
+	"                - \"\"\n" +
+	"              context_dir: ' '\n" +
+	"              # DefaultChannel is the default channel of the package, overriding the\n" +
+	"              # one in the metadata of the bundle.\n" +
+	"              default_channel: ' '\n" +
+	"              dockerfile_path: ' '\n" +
+	"        # PinnedImages are the images built by the job whose pullspecs in the\n" +
+	"        # CSVs are pinned to their digests\n" +
+	"        pinned_images:\n" +
+	"            - \"\"\n" +
 	"        # Substitutions contains pullspecs that need to be replaced by images\n" +
 	"        # in the CI cluster for operator bundle images\n" +
 	"        substitutions:\n" +
@@ -160,11 +364,15 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"              # With is the string that the PullSpec is being replaced by\n" +
 	"              with: ' '\n" +
 	"      index_generator_step:\n" +
+	"        # BaseIndex is the pull spec of an index the bundles are added to.\n" +
+	"        base_index: ' '\n" +
 	"        # OperatorIndex is a list of the names of the bundle images that the\n" +
 	"        # index will contain in its database.\n" +
 	"        operator_index:\n" +
 	"            - \"\"\n" +
 	"        to: ' '\n" +
+	"        # UpdateGraph is the mode used to add the bundles to the update graph.\n" +
+	"        update_graph: ' '\n" +
 	"      input_image_tag_step:\n" +
 	"        base_image:\n" +
 	"            # As is an optional string to use as the intermediate name for this reference.\n" +
@@ -172,6 +380,20 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"            name: ' '\n" +
 	"            namespace: ' '\n" +
 	"            tag: ' '\n" +
+	"        # Signature is verified before the image is tagged in.\n" +
+	"        signature:\n" +
+	"            # FulcioURL is the Fulcio instance issuing certificates for\n" +
+	"            # keyless signing. Defaults to the public instance.\n" +
+	"            fulcio_url: ' '\n" +
+	"            # OIDCIssuer is the issuer of the identity tokens of the\n" +
+	"            # promotion pod, which keyless signatures are verified\n" +
+	"            # against. Required for keyless signing.\n" +
+	"            oidc_issuer: ' '\n" +
+	"            # RekorURL is the transparency log signatures are recorded\n" +
+	"            # in. Keyless signatures are always recorded, by default in\n" +
+	"            # the public instance, while signatures made with a key are\n" +
+	"            # only recorded if this is set.\n" +
+	"            rekor_url: ' '\n" +
 	"        to: ' '\n" +
 	"      output_image_tag_step:\n" +
 	"        from: ' '\n" +
@@ -223,6 +445,24 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                      # SourcePath is a file or directory in the source image to copy from.\n" +
 	"                      source_path: ' '\n" +
 	"      project_directory_image_build_step:\n" +
+	"        # BuildArgs are passed to the Dockerfile as build arguments, so\n" +
+	"        # that it can consume them with ARG instructions.\n" +
+	"        build_args:\n" +
+	"            - # Name is the name of the argument in the ARG instruction.\n" +
+	"              name: ' '\n" +
+	"              # Value is the static value of the argument.\n" +
+	"              value: ' '\n" +
+	"              # ValueFrom sources the value of the argument from the job.\n" +
+	"              # Mutually exclusive with Value.\n" +
+	"              value_from:\n" +
+	"                # JobMetadata is a property of the job: one of commit, base_ref,\n" +
+	"                # base_sha, pull_number, job_name or build_id. The commit is the\n" +
+	"                # head of the pull request under test or the base when there is\n" +
+	"                # none. Properties the job does not have are passed empty.\n" +
+	"                job_metadata: ' '\n" +
+	"                # Parameter is a parameter of the job, like RELEASE_IMAGE_LATEST.\n" +
+	"                # The build waits for the step providing it.\n" +
+	"                parameter: ' '\n" +
 	"        # ContextDir is the directory in the project\n" +
 	"        # from which this build should be run.\n" +
 	"        context_dir: ' '\n" +
@@ -263,6 +503,22 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"        # job are tagged from.\n" +
 	"        namespace: ' '\n" +
 	"      resolved_release_images_step:\n" +
+	"        # Assembled describes a payload assembled by the job from a subset of\n" +
+	"        # the images of another release\n" +
+	"        assembled:\n" +
+	"            # Exclude is a regular expression matching the tags of the release which\n" +
+	"            # are left out of the payload.\n" +
+	"            exclude: ' '\n" +
+	"            # From is the name of the release whose images are assembled into the\n" +
+	"            # payload, `latest` by default.\n" +
+	"            from: ' '\n" +
+	"            # Include is a regular expression matching the tags of the release which\n" +
+	"            # are part of the payload. All tags are included if unset.\n" +
+	"            include: ' '\n" +
+	"            # Overrides maps tags of the payload to the images built by the job which\n" +
+	"            # replace them, e.g. `machine-config-operator: machine-config-operator`.\n" +
+	"            overrides:\n" +
+	"                \"\": \"\"\n" +
 	"        # Candidate describes a candidate release payload\n" +
 	"        candidate:\n" +
 	"            # Architecture is the architecture for the product.\n" +
@@ -295,6 +551,10 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"            channel: ' '\n" +
 	"            # Version is the minor version to search for\n" +
 	"            version: ' '\n" +
+	"            # VersionRange is a semantic version range, e.g. `>=4.12.0 <4.14.0`, to\n" +
+	"            # search for the newest release in instead of the minor version. Every\n" +
+	"            # alternative of the range needs an upper bound.\n" +
+	"            version_range: ' '\n" +
 	"      rpm_image_injection_step:\n" +
 	"        from: ' '\n" +
 	"        to: ' '\n" +
@@ -347,14 +607,109 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"            # all previous `pre` and `test` steps were successful. The given step must explicitly\n" +
 	"            # ask for being skipped by setting the OptionalOnSuccess flag to true.\n" +
 	"            allow_skip_on_success: false\n" +
+	"            # ClusterClaim claims a cluster from a Hive pool before the steps run\n" +
+	"            # and releases it when they finish.\n" +
+	"            cluster_claim:\n" +
+	"                # Namespace is the namespace of the ClusterPool on the Hive cluster.\n" +
+	"                namespace: ' '\n" +
+	"                # Pool is the name of the ClusterPool the cluster is claimed from.\n" +
+	"                pool: ' '\n" +
+	"                # Retries is how many times an unhealthy cluster is released and another\n" +
+	"                # one is claimed. Defaults to two.\n" +
+	"                retries: 0\n" +
 	"            # ClusterProfile defines the profile/cloud provider for end-to-end test steps.\n" +
 	"            cluster_profile: ' '\n" +
+	"            # ClusterProfileOverlay adds secrets, config maps and parameters to the\n" +
+	"            # cluster profile of the test.\n" +
+	"            cluster_profile_overlay:\n" +
+	"                # ConfigMaps are copied into the cluster profile after the secrets, with\n" +
+	"                # the same precedence.\n" +
+	"                config_maps:\n" +
+	"                    - # Name is the name of the source object.\n" +
+	"                      name: ' '\n" +
+	"                      # Namespace is where the source object lives.\n" +
+	"                      namespace: ' '\n" +
+	"                # Environment has additional parameters exposed to all steps.\n" +
+	"                env:\n" +
+	"                    \"\": \"\"\n" +
+	"                # Secrets are copied into the cluster profile. Their keys override keys\n" +
+	"                # of the profile and of secrets earlier in the list.\n" +
+	"                secrets:\n" +
+	"                    - # Name is the name of the source object.\n" +
+	"                      name: ' '\n" +
+	"                      # Namespace is where the source object lives.\n" +
+	"                      namespace: ' '\n" +
+	"            # ClusterProvisioning installs a short-lived cluster with Hive before\n" +
+	"            # the steps run and deprovisions it when they finish.\n" +
+	"            cluster_provisioning:\n" +
+	"                # BaseDomain is the DNS zone of the cloud account of the cluster profile\n" +
+	"                # the cluster is installed under, e.g. origin-ci-int-aws.dev.rhcloud.com.\n" +
+	"                base_domain: ' '\n" +
+	"                # Region is the region of the cloud the cluster is installed in.\n" +
+	"                region: ' '\n" +
+	"                # Version is the name of the ClusterImageSet on the Hive cluster with the\n" +
+	"                # release to install, e.g. openshift-v4.9.0.\n" +
+	"                version: ' '\n" +
+	"            # Comparison runs the test twice, once for a baseline and once for a\n" +
+	"            # candidate, and records a combined comparison of both runs.\n" +
+	"            comparison:\n" +
+	"                # Baseline holds the overrides for the reference run.\n" +
+	"                baseline:\n" +
+	"                    # Dependencies overrides the images used for dependency parameters,\n" +
+	"                    # for example to run the test against a different release payload.\n" +
+	"                    dependencies:\n" +
+	"                        \"\": \"\"\n" +
+	"                    # Environment overrides the values of parameters for the steps.\n" +
+	"                    env:\n" +
+	"                        \"\": \"\"\n" +
+	"                # Candidate holds the overrides for the run being evaluated.\n" +
+	"                candidate:\n" +
+	"                    # Dependencies overrides the images used for dependency parameters,\n" +
+	"                    # for example to run the test against a different release payload.\n" +
+	"                    dependencies:\n" +
+	"                        \"\": \"\"\n" +
+	"                    # Environment overrides the values of parameters for the steps.\n" +
+	"                    env:\n" +
+	"                        \"\": \"\"\n" +
+	"            # DataDir provisions a volume which is mounted in all steps as $DATA_DIR,\n" +
+	"            # for data that is too large to be handed off in $SHARED_DIR.\n" +
+	"            data_dir:\n" +
+	"                # Size is the requested capacity of the volume, e.g. 200Gi.\n" +
+	"                size: ' '\n" +
+	"                # StorageClass is the storage class used to provision the volume. The\n" +
+	"                # default storage class of the cluster is used if unset.\n" +
+	"                storage_class: ' '\n" +
 	"            # Dependencies holds override values for dependency parameters.\n" +
 	"            dependencies:\n" +
 	"                \"\": \"\"\n" +
+	"            # Deprecations holds the notices of the deprecated registry components\n" +
+	"            # the test was resolved from, keyed like `chain/ipi-install`. It is set\n" +
+	"            # by the resolver so that the job can report them.\n" +
+	"            deprecations:\n" +
+	"                \"\":\n" +
+	"                    # Message explains why the component is deprecated.\n" +
+	"                    message: ' '\n" +
+	"                    # Replacement names the component that should be used instead, if any.\n" +
+	"                    replacement: ' '\n" +
+	"            # DisruptionRetries is how many times a pod of the test is re-created\n" +
+	"            # when the infrastructure terminates it before it completes, e.g. when\n" +
+	"            # it is evicted or its node is lost. Defaults to one; zero disables it.\n" +
+	"            disruption_retries: 0\n" +
 	"            # Environment has the values of parameters for the steps.\n" +
 	"            env:\n" +
 	"                \"\": \"\"\n" +
+	"            # Gather configures the built-in steps collecting data from the cluster\n" +
+	"            # of the test, which run first in the post phase of tests using a\n" +
+	"            # cluster profile.\n" +
+	"            gather:\n" +
+	"                # MaxSize is the maximum size of the data collected by every step, e.g.\n" +
+	"                # 500Mi. The largest files are removed until the data fits.\n" +
+	"                max_size: ' '\n" +
+	"                # Steps are the built-in steps to run, all of them if unset.\n" +
+	"                steps:\n" +
+	"                    - \"\"\n" +
+	"                # Timeout is how long every step may collect data before it is stopped.\n" +
+	"                timeout: 0s\n" +
 	"            # Leases lists resources that should be acquired for the test.\n" +
 	"            leases:\n" +
 	"                - # Env is the environment variable that will contain the resource name.\n" +
@@ -379,12 +734,43 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"            # Post is the array of test steps run after the tests finish and teardown/deprovision resources.\n" +
 	"            # Post steps always run, even if previous steps fail.\n" +
 	"            post:\n" +
-	"                - # As is the name of the LiteralTestStep.\n" +
+	"                - # ArtifactsToRegistry, when set, publishes files from the artifacts of\n" +
+	"                  # the step as an OCI artifact once the step succeeds.\n" +
+	"                  artifacts_to_registry:\n" +
+	"                    # ArtifactType is the media type of the artifact.\n" +
+	"                    artifact_type: ' '\n" +
+	"                    # Credentials is the secret of the repository's owners holding the\n" +
+	"                    # .dockerconfigjson which may push to the repository, referenced by\n" +
+	"                    # namespace and name or by its path in Vault. The mount path is chosen\n" +
+	"                    # by ci-operator and must not be set.\n" +
+	"                    credentials:\n" +
+	"                        # MountPath is where the secret should be mounted.\n" +
+	"                        mount_path: ' '\n" +
+	"                        # Names is which source secret to mount.\n" +
+	"                        name: ' '\n" +
+	"                        # Namespace is where the source secret exists.\n" +
+	"                        namespace: ' '\n" +
+	"                        # VaultPath is the path of a key-value secret in Vault to mount instead\n" +
+	"                        # of a secret from a namespace. The secret is read when the test starts\n" +
+	"                        # and deleted from the test namespace when it finishes.\n" +
+	"                        vault_path: ' '\n" +
+	"                    # Files are the paths of the files in the artifact, relative to the\n" +
+	"                    # artifacts directory of the step. Glob patterns are allowed.\n" +
+	"                    files:\n" +
+	"                        - \"\"\n" +
+	"                    # Repository is the repository the artifact is pushed to, e.g.\n" +
+	"                    # quay.io/org/bundles.\n" +
+	"                    repository: ' '\n" +
+	"                    # Tag is the tag of the artifact. Defaults to the names of the test and\n" +
+	"                    # the step and the build ID of the job.\n" +
+	"                    tag: ' '\n" +
+	"                  # As is the name of the LiteralTestStep.\n" +
 	"                  as: ' '\n" +
 	"                  # BestEffort defines if this step should cause the job to fail when the\n" +
-	"                  # step fails. This only applies when AllowBestEffortPostSteps flag is set\n" +
-	"                  # to true in MultiStageTestConfiguration. This option is applicable to\n" +
-	"                  # `post` steps.\n" +
+	"                  # step fails. The failure of a best-effort step is still reported, but\n" +
+	"                  # the following steps run as if it succeeded. For `post` steps, this only\n" +
+	"                  # applies when AllowBestEffortPostSteps flag is set to true in\n" +
+	"                  # MultiStageTestConfiguration.\n" +
 	"                  best_effort: false\n" +
 	"                  # Cli is the (optional) name of the release from which the `oc` binary\n" +
 	"                  # will be injected into this step.\n" +
@@ -399,6 +785,10 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                      name: ' '\n" +
 	"                      # Namespace is where the source secret exists.\n" +
 	"                      namespace: ' '\n" +
+	"                      # VaultPath is the path of a key-value secret in Vault to mount instead\n" +
+	"                      # of a secret from a namespace. The secret is read when the test starts\n" +
+	"                      # and deleted from the test namespace when it finishes.\n" +
+	"                      vault_path: ' '\n" +
 	"                  # Dependencies lists images which must be available before the test runs\n" +
 	"                  # and the environment variables which are used to expose their pull specs.\n" +
 	"                  dependencies:\n" +
@@ -414,6 +804,21 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                      documentation: ' '\n" +
 	"                      # Name of the environment variable.\n" +
 	"                      name: ' '\n" +
+	"                      # Type is the type of the values of the parameter, a string if unset.\n" +
+	"                      type: ' '\n" +
+	"                      # Values are the values allowed for a parameter of the enum type.\n" +
+	"                      values:\n" +
+	"                        - \"\"\n" +
+	"                  # ExternalDependencies lists images from outside of the CI system, pinned\n" +
+	"                  # to a digest, which are imported before the test runs and exposed with\n" +
+	"                  # environment variables like Dependencies.\n" +
+	"                  external_dependencies:\n" +
+	"                    - # Digest is the digest the image is expected to have, e.g. sha256:...\n" +
+	"                      digest: ' '\n" +
+	"                      # Env is the environment variable that the image's pull spec is exposed with\n" +
+	"                      env: ' '\n" +
+	"                      # PullSpec is the full pull spec of the image, e.g. quay.io/org/image:tag\n" +
+	"                      pull_spec: ' '\n" +
 	"                  # From is the container image that will be used for this step.\n" +
 	"                  from: ' '\n" +
 	"                  # FromImage is a literal ImageStreamTag reference to use for this step.\n" +
@@ -426,6 +831,12 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                  # GracePeriod is how long the we will wait after sending SIGINT to send\n" +
 	"                  # SIGKILL when aborting a Step.\n" +
 	"                  grace_period: 0s\n" +
+	"                  # If is an expression which determines whether the step runs, comparing\n" +
+	"                  # parameters of the step and CLUSTER_TYPE to values, for example\n" +
+	"                  # `CLUSTER_TYPE == aws || CLUSTER_TYPE == gcp && FIPS_ENABLED != true`.\n" +
+	"                  # Values containing spaces or operators need to be quoted. The step is\n" +
+	"                  # skipped when the expression does not hold.\n" +
+	"                  if: ' '\n" +
 	"                  # Leases lists resources that should be acquired for the test.\n" +
 	"                  leases:\n" +
 	"                    - # Env is the environment variable that will contain the resource name.\n" +
@@ -450,16 +861,119 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    # These are directly used in creating the Pods that execute the Job.\n" +
 	"                    requests:\n" +
 	"                        \"\": \"\"\n" +
+	"                  # Retries, when set, re-runs the step in a new pod when it fails. The\n" +
+	"                  # artifacts of each attempt are stored in a directory named after it.\n" +
+	"                  retries:\n" +
+	"                    # Count is how many times the step is re-run after it fails.\n" +
+	"                    count: 0\n" +
+	"                    # Until limits how long after the first attempt started new attempts\n" +
+	"                    # may start, so retries do not push the test past its timeout. New\n" +
+	"                    # attempts are only limited by Count when unset.\n" +
+	"                    until: 0s\n" +
+	"                  # ServiceAccount, when set, runs the step with a service account of its\n" +
+	"                  # own that is only granted the declared rules, instead of the one shared\n" +
+	"                  # by all steps of the test, which can view everything in the namespace.\n" +
+	"                  service_account:\n" +
+	"                    # Rules are the permissions granted to the step in the test namespace.\n" +
+	"                    rules:\n" +
+	"                        - # APIGroups are the groups of the resources, \"\" for the core group.\n" +
+	"                          api_groups:\n" +
+	"                            - \"\"\n" +
+	"                          # ResourceNames optionally restricts the rule to named objects.\n" +
+	"                          resource_names:\n" +
+	"                            - \"\"\n" +
+	"                          # Resources are the resources the rule applies to.\n" +
+	"                          resources:\n" +
+	"                            - \"\"\n" +
+	"                          # Verbs are the operations allowed on the resources.\n" +
+	"                          verbs:\n" +
+	"                            - \"\"\n" +
+	"                  # Sidecars are containers that run next to the step's container, e.g.\n" +
+	"                  # to provide a database the test connects to over localhost. They are\n" +
+	"                  # terminated when the step's commands finish.\n" +
+	"                  sidecars:\n" +
+	"                    - # Commands is the shell script that starts the service.\n" +
+	"                      commands: ' '\n" +
+	"                      # From is the container image that will be used for the sidecar, with\n" +
+	"                      # the same semantics as the `from` field of a step.\n" +
+	"                      from: ' '\n" +
+	"                      # Name is the name of the sidecar container.\n" +
+	"                      name: ' '\n" +
+	"                      # Resources defines the resource requirements for the sidecar.\n" +
+	"                      resources:\n" +
+	"                        # Limits are resource limits applied to an individual step in the job.\n" +
+	"                        # These are directly used in creating the Pods that execute the Job.\n" +
+	"                        limits:\n" +
+	"                            \"\": \"\"\n" +
+	"                        # Requests are resource requests applied to an individual step in the job.\n" +
+	"                        # These are directly used in creating the Pods that execute the Job.\n" +
+	"                        requests:\n" +
+	"                            \"\": \"\"\n" +
 	"                  # Timeout is how long the we will wait before aborting a job with SIGINT.\n" +
+	"                  # The pod of the step is terminated if it still runs once the timeout,\n" +
+	"                  # the grace period and some time to start the pod and upload artifacts\n" +
+	"                  # have passed.\n" +
 	"                  timeout: 0s\n" +
+	"                  # Upgrade, when set, makes ci-operator upgrade the cluster of the test\n" +
+	"                  # itself instead of running commands in a pod. The step then needs no\n" +
+	"                  # `from` or `commands` and its timeout bounds the whole upgrade.\n" +
+	"                  upgrade:\n" +
+	"                    # From is the name of the release the cluster must run before the\n" +
+	"                    # upgrade, e.g. initial.\n" +
+	"                    from: ' '\n" +
+	"                    # To is the name of the release the cluster is upgraded to, e.g. latest.\n" +
+	"                    to: ' '\n" +
+	"                  # Workspace is a volume backed by a PersistentVolumeClaim that is\n" +
+	"                  # provisioned for this step, for scratch space larger than the node's\n" +
+	"                  # ephemeral storage allows.\n" +
+	"                  workspace:\n" +
+	"                    # MountPath is where the volume is mounted, /workspace by default.\n" +
+	"                    # The path is exposed to the step as $WORKSPACE_DIR.\n" +
+	"                    mount_path: ' '\n" +
+	"                    # Size is the requested capacity of the volume, e.g. 200Gi.\n" +
+	"                    size: ' '\n" +
+	"                    # StorageClass is the storage class used to provision the volume. The\n" +
+	"                    # default storage class of the cluster is used if unset.\n" +
+	"                    storage_class: ' '\n" +
 	"            # Pre is the array of test steps run to set up the environment for the test.\n" +
 	"            pre:\n" +
-	"                - # As is the name of the LiteralTestStep.\n" +
+	"                - # ArtifactsToRegistry, when set, publishes files from the artifacts of\n" +
+	"                  # the step as an OCI artifact once the step succeeds.\n" +
+	"                  artifacts_to_registry:\n" +
+	"                    # ArtifactType is the media type of the artifact.\n" +
+	"                    artifact_type: ' '\n" +
+	"                    # Credentials is the secret of the repository's owners holding the\n" +
+	"                    # .dockerconfigjson which may push to the repository, referenced by\n" +
+	"                    # namespace and name or by its path in Vault. The mount path is chosen\n" +
+	"                    # by ci-operator and must not be set.\n" +
+	"                    credentials:\n" +
+	"                        # MountPath is where the secret should be mounted.\n" +
+	"                        mount_path: ' '\n" +
+	"                        # Names is which source secret to mount.\n" +
+	"                        name: ' '\n" +
+	"                        # Namespace is where the source secret exists.\n" +
+	"                        namespace: ' '\n" +
+	"                        # VaultPath is the path of a key-value secret in Vault to mount instead\n" +
+	"                        # of a secret from a namespace. The secret is read when the test starts\n" +
+	"                        # and deleted from the test namespace when it finishes.\n" +
+	"                        vault_path: ' '\n" +
+	"                    # Files are the paths of the files in the artifact, relative to the\n" +
+	"                    # artifacts directory of the step. Glob patterns are allowed.\n" +
+	"                    files:\n" +
+	"                        - \"\"\n" +
+	"                    # Repository is the repository the artifact is pushed to, e.g.\n" +
+	"                    # quay.io/org/bundles.\n" +
+	"                    repository: ' '\n" +
+	"                    # Tag is the tag of the artifact. Defaults to the names of the test and\n" +
+	"                    # the step and the build ID of the job.\n" +
+	"                    tag: ' '\n" +
+	"                  # As is the name of the LiteralTestStep.\n" +
 	"                  as: ' '\n" +
 	"                  # BestEffort defines if this step should cause the job to fail when the\n" +
-	"                  # step fails. This only applies when AllowBestEffortPostSteps flag is set\n" +
-	"                  # to true in MultiStageTestConfiguration. This option is applicable to\n" +
-	"                  # `post` steps.\n" +
+	"                  # step fails. The failure of a best-effort step is still reported, but\n" +
+	"                  # the following steps run as if it succeeded. For `post` steps, this only\n" +
+	"                  # applies when AllowBestEffortPostSteps flag is set to true in\n" +
+	"                  # MultiStageTestConfiguration.\n" +
 	"                  best_effort: false\n" +
 	"                  # Cli is the (optional) name of the release from which the `oc` binary\n" +
 	"                  # will be injected into this step.\n" +
@@ -474,6 +988,10 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                      name: ' '\n" +
 	"                      # Namespace is where the source secret exists.\n" +
 	"                      namespace: ' '\n" +
+	"                      # VaultPath is the path of a key-value secret in Vault to mount instead\n" +
+	"                      # of a secret from a namespace. The secret is read when the test starts\n" +
+	"                      # and deleted from the test namespace when it finishes.\n" +
+	"                      vault_path: ' '\n" +
 	"                  # Dependencies lists images which must be available before the test runs\n" +
 	"                  # and the environment variables which are used to expose their pull specs.\n" +
 	"                  dependencies:\n" +
@@ -489,6 +1007,21 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                      documentation: ' '\n" +
 	"                      # Name of the environment variable.\n" +
 	"                      name: ' '\n" +
+	"                      # Type is the type of the values of the parameter, a string if unset.\n" +
+	"                      type: ' '\n" +
+	"                      # Values are the values allowed for a parameter of the enum type.\n" +
+	"                      values:\n" +
+	"                        - \"\"\n" +
+	"                  # ExternalDependencies lists images from outside of the CI system, pinned\n" +
+	"                  # to a digest, which are imported before the test runs and exposed with\n" +
+	"                  # environment variables like Dependencies.\n" +
+	"                  external_dependencies:\n" +
+	"                    - # Digest is the digest the image is expected to have, e.g. sha256:...\n" +
+	"                      digest: ' '\n" +
+	"                      # Env is the environment variable that the image's pull spec is exposed with\n" +
+	"                      env: ' '\n" +
+	"                      # PullSpec is the full pull spec of the image, e.g. quay.io/org/image:tag\n" +
+	"                      pull_spec: ' '\n" +
 	"                  # From is the container image that will be used for this step.\n" +
 	"                  from: ' '\n" +
 	"                  # FromImage is a literal ImageStreamTag reference to use for this step.\n" +
@@ -501,6 +1034,12 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                  # GracePeriod is how long the we will wait after sending SIGINT to send\n" +
 	"                  # SIGKILL when aborting a Step.\n" +
 	"                  grace_period: 0s\n" +
+	"                  # If is an expression which determines whether the step runs, comparing\n" +
+	"                  # parameters of the step and CLUSTER_TYPE to values, for example\n" +
+	"                  # `CLUSTER_TYPE == aws || CLUSTER_TYPE == gcp && FIPS_ENABLED != true`.\n" +
+	"                  # Values containing spaces or operators need to be quoted. The step is\n" +
+	"                  # skipped when the expression does not hold.\n" +
+	"                  if: ' '\n" +
 	"                  # Leases lists resources that should be acquired for the test.\n" +
 	"                  leases:\n" +
 	"                    - # Env is the environment variable that will contain the resource name.\n" +
@@ -525,16 +1064,126 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    # These are directly used in creating the Pods that execute the Job.\n" +
 	"                    requests:\n" +
 	"                        \"\": \"\"\n" +
+	"                  # Retries, when set, re-runs the step in a new pod when it fails. The\n" +
+	"                  # artifacts of each attempt are stored in a directory named after it.\n" +
+	"                  retries:\n" +
+	"                    # Count is how many times the step is re-run after it fails.\n" +
+	"                    count: 0\n" +
+	"                    # Until limits how long after the first attempt started new attempts\n" +
+	"                    # may start, so retries do not push the test past its timeout. New\n" +
+	"                    # attempts are only limited by Count when unset.\n" +
+	"                    until: 0s\n" +
+	"                  # ServiceAccount, when set, runs the step with a service account of its\n" +
+	"                  # own that is only granted the declared rules, instead of the one shared\n" +
+	"                  # by all steps of the test, which can view everything in the namespace.\n" +
+	"                  service_account:\n" +
+	"                    # Rules are the permissions granted to the step in the test namespace.\n" +
+	"                    rules:\n" +
+	"                        - # APIGroups are the groups of the resources, \"\" for the core group.\n" +
+	"                          api_groups:\n" +
+	"                            - \"\"\n" +
+	"                          # ResourceNames optionally restricts the rule to named objects.\n" +
+	"                          resource_names:\n" +
+	"                            - \"\"\n" +
+	"                          # Resources are the resources the rule applies to.\n" +
+	"                          resources:\n" +
+	"                            - \"\"\n" +
+	"                          # Verbs are the operations allowed on the resources.\n" +
+	"                          verbs:\n" +
+	"                            - \"\"\n" +
+	"                  # Sidecars are containers that run next to the step's container, e.g.\n" +
+	"                  # to provide a database the test connects to over localhost. They are\n" +
+	"                  # terminated when the step's commands finish.\n" +
+	"                  sidecars:\n" +
+	"                    - # Commands is the shell script that starts the service.\n" +
+	"                      commands: ' '\n" +
+	"                      # From is the container image that will be used for the sidecar, with\n" +
+	"                      # the same semantics as the `from` field of a step.\n" +
+	"                      from: ' '\n" +
+	"                      # Name is the name of the sidecar container.\n" +
+	"                      name: ' '\n" +
+	"                      # Resources defines the resource requirements for the sidecar.\n" +
+	"                      resources:\n" +
+	"                        # Limits are resource limits applied to an individual step in the job.\n" +
+	"                        # These are directly used in creating the Pods that execute the Job.\n" +
+	"                        limits:\n" +
+	"                            \"\": \"\"\n" +
+	"                        # Requests are resource requests applied to an individual step in the job.\n" +
+	"                        # These are directly used in creating the Pods that execute the Job.\n" +
+	"                        requests:\n" +
+	"                            \"\": \"\"\n" +
 	"                  # Timeout is how long the we will wait before aborting a job with SIGINT.\n" +
+	"                  # The pod of the step is terminated if it still runs once the timeout,\n" +
+	"                  # the grace period and some time to start the pod and upload artifacts\n" +
+	"                  # have passed.\n" +
 	"                  timeout: 0s\n" +
+	"                  # Upgrade, when set, makes ci-operator upgrade the cluster of the test\n" +
+	"                  # itself instead of running commands in a pod. The step then needs no\n" +
+	"                  # `from` or `commands` and its timeout bounds the whole upgrade.\n" +
+	"                  upgrade:\n" +
+	"                    # From is the name of the release the cluster must run before the\n" +
+	"                    # upgrade, e.g. initial.\n" +
+	"                    from: ' '\n" +
+	"                    # To is the name of the release the cluster is upgraded to, e.g. latest.\n" +
+	"                    to: ' '\n" +
+	"                  # Workspace is a volume backed by a PersistentVolumeClaim that is\n" +
+	"                  # provisioned for this step, for scratch space larger than the node's\n" +
+	"                  # ephemeral storage allows.\n" +
+	"                  workspace:\n" +
+	"                    # MountPath is where the volume is mounted, /workspace by default.\n" +
+	"                    # The path is exposed to the step as $WORKSPACE_DIR.\n" +
+	"                    mount_path: ' '\n" +
+	"                    # Size is the requested capacity of the volume, e.g. 200Gi.\n" +
+	"                    size: ' '\n" +
+	"                    # StorageClass is the storage class used to provision the volume. The\n" +
+	"                    # default storage class of the cluster is used if unset.\n" +
+	"                    storage_class: ' '\n" +
+	"            # SharedDir configures the volume backing $SHARED_DIR in steps.\n" +
+	"            shared_dir:\n" +
+	"                # Medium backs the volume, either `disk` (the default) or `memory`.\n" +
+	"                medium: ' '\n" +
+	"                # SizeLimit is the maximum size of the volume, e.g. 100Mi. Steps which\n" +
+	"                # write more than that are evicted.\n" +
+	"                size_limit: ' '\n" +
 	"            # Test is the array of test steps that define the actual test.\n" +
 	"            test:\n" +
-	"                - # As is the name of the LiteralTestStep.\n" +
+	"                - # ArtifactsToRegistry, when set, publishes files from the artifacts of\n" +
+	"                  # the step as an OCI artifact once the step succeeds.\n" +
+	"                  artifacts_to_registry:\n" +
+	"                    # ArtifactType is the media type of the artifact.\n" +
+	"                    artifact_type: ' '\n" +
+	"                    # Credentials is the secret of the repository's owners holding the\n" +
+	"                    # .dockerconfigjson which may push to the repository, referenced by\n" +
+	"                    # namespace and name or by its path in Vault. The mount path is chosen\n" +
+	"                    # by ci-operator and must not be set.\n" +
+	"                    credentials:\n" +
+	"                        # MountPath is where the secret should be mounted.\n" +
+	"                        mount_path: ' '\n" +
+	"                        # Names is which source secret to mount.\n" +
+	"                        name: ' '\n" +
+	"                        # Namespace is where the source secret exists.\n" +
+	"                        namespace: ' '\n" +
+	"                        # VaultPath is the path of a key-value secret in Vault to mount instead\n" +
+	"                        # of a secret from a namespace. The secret is read when the test starts\n" +
+	"                        # and deleted from the test namespace when it finishes.\n" +
+	"                        vault_path: ' '\n" +
+	"                    # Files are the paths of the files in the artifact, relative to the\n" +
+	"                    # artifacts directory of the step. Glob patterns are allowed.\n" +
+	"                    files:\n" +
+	"                        - \"\"\n" +
+	"                    # Repository is the repository the artifact is pushed to, e.g.\n" +
+	"                    # quay.io/org/bundles.\n" +
+	"                    repository: ' '\n" +
+	"                    # Tag is the tag of the artifact. Defaults to the names of the test and\n" +
+	"                    # the step and the build ID of the job.\n" +
+	"                    tag: ' '\n" +
+	"                  # As is the name of the LiteralTestStep.\n" +
 	"                  as: ' '\n" +
 	"                  # BestEffort defines if this step should cause the job to fail when the\n" +
-	"                  # step fails. This only applies when AllowBestEffortPostSteps flag is set\n" +
-	"                  # to true in MultiStageTestConfiguration. This option is applicable to\n" +
-	"                  # `post` steps.\n" +
+	"                  # step fails. The failure of a best-effort step is still reported, but\n" +
+	"                  # the following steps run as if it succeeded. For `post` steps, this only\n" +
+	"                  # applies when AllowBestEffortPostSteps flag is set to true in\n" +
+	"                  # MultiStageTestConfiguration.\n" +
 	"                  best_effort: false\n" +
 	"                  # Cli is the (optional) name of the release from which the `oc` binary\n" +
 	"                  # will be injected into this step.\n" +
@@ -549,6 +1198,10 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                      name: ' '\n" +
 	"                      # Namespace is where the source secret exists.\n" +
 	"                      namespace: ' '\n" +
+	"                      # VaultPath is the path of a key-value secret in Vault to mount instead\n" +
+	"                      # of a secret from a namespace. The secret is read when the test starts\n" +
+	"                      # and deleted from the test namespace when it finishes.\n" +
+	"                      vault_path: ' '\n" +
 	"                  # Dependencies lists images which must be available before the test runs\n" +
 	"                  # and the environment variables which are used to expose their pull specs.\n" +
 	"                  dependencies:\n" +
@@ -564,6 +1217,21 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                      documentation: ' '\n" +
 	"                      # Name of the environment variable.\n" +
 	"                      name: ' '\n" +
+	"                      # Type is the type of the values of the parameter, a string if unset.\n" +
+	"                      type: ' '\n" +
+	"                      # Values are the values allowed for a parameter of the enum type.\n" +
+	"                      values:\n" +
+	"                        - \"\"\n" +
+	"                  # ExternalDependencies lists images from outside of the CI system, pinned\n" +
+	"                  # to a digest, which are imported before the test runs and exposed with\n" +
+	"                  # environment variables like Dependencies.\n" +
+	"                  external_dependencies:\n" +
+	"                    - # Digest is the digest the image is expected to have, e.g. sha256:...\n" +
+	"                      digest: ' '\n" +
+	"                      # Env is the environment variable that the image's pull spec is exposed with\n" +
+	"                      env: ' '\n" +
+	"                      # PullSpec is the full pull spec of the image, e.g. quay.io/org/image:tag\n" +
+	"                      pull_spec: ' '\n" +
 	"                  # From is the container image that will be used for this step.\n" +
 	"                  from: ' '\n" +
 	"                  # FromImage is a literal ImageStreamTag reference to use for this step.\n" +
@@ -576,6 +1244,12 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                  # GracePeriod is how long the we will wait after sending SIGINT to send\n" +
 	"                  # SIGKILL when aborting a Step.\n" +
 	"                  grace_period: 0s\n" +
+	"                  # If is an expression which determines whether the step runs, comparing\n" +
+	"                  # parameters of the step and CLUSTER_TYPE to values, for example\n" +
+	"                  # `CLUSTER_TYPE == aws || CLUSTER_TYPE == gcp && FIPS_ENABLED != true`.\n" +
+	"                  # Values containing spaces or operators need to be quoted. The step is\n" +
+	"                  # skipped when the expression does not hold.\n" +
+	"                  if: ' '\n" +
 	"                  # Leases lists resources that should be acquired for the test.\n" +
 	"                  leases:\n" +
 	"                    - # Env is the environment variable that will contain the resource name.\n" +
@@ -600,8 +1274,86 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    # These are directly used in creating the Pods that execute the Job.\n" +
 	"                    requests:\n" +
 	"                        \"\": \"\"\n" +
+	"                  # Retries, when set, re-runs the step in a new pod when it fails. The\n" +
+	"                  # artifacts of each attempt are stored in a directory named after it.\n" +
+	"                  retries:\n" +
+	"                    # Count is how many times the step is re-run after it fails.\n" +
+	"                    count: 0\n" +
+	"                    # Until limits how long after the first attempt started new attempts\n" +
+	"                    # may start, so retries do not push the test past its timeout. New\n" +
+	"                    # attempts are only limited by Count when unset.\n" +
+	"                    until: 0s\n" +
+	"                  # ServiceAccount, when set, runs the step with a service account of its\n" +
+	"                  # own that is only granted the declared rules, instead of the one shared\n" +
+	"                  # by all steps of the test, which can view everything in the namespace.\n" +
+	"                  service_account:\n" +
+	"                    # Rules are the permissions granted to the step in the test namespace.\n" +
+	"                    rules:\n" +
+	"                        - # APIGroups are the groups of the resources, \"\" for the core group.\n" +
+	"                          api_groups:\n" +
+	"                            - \"\"\n" +
+	"                          # ResourceNames optionally restricts the rule to named objects.\n" +
+	"                          resource_names:\n" +
+	"                            - \"\"\n" +
+	"                          # Resources are the resources the rule applies to.\n" +
+	"                          resources:\n" +
+	"                            - \"\"\n" +
+	"                          # Verbs are the operations allowed on the resources.\n" +
+	"                          verbs:\n" +
+	"                            - \"\"\n" +
+	"                  # Sidecars are containers that run next to the step's container, e.g.\n" +
+	"                  # to provide a database the test connects to over localhost. They are\n" +
+	"                  # terminated when the step's commands finish.\n" +
+	"                  sidecars:\n" +
+	"                    - # Commands is the shell script that starts the service.\n" +
+	"                      commands: ' '\n" +
+	"                      # From is the container image that will be used for the sidecar, with\n" +
+	"                      # the same semantics as the `from` field of a step.\n" +
+	"                      from: ' '\n" +
+	"                      # Name is the name of the sidecar container.\n" +
+	"                      name: ' '\n" +
+	"                      # Resources defines the resource requirements for the sidecar.\n" +
+	"                      resources:\n" +
+	"                        # Limits are resource limits applied to an individual step in the job.\n" +
+	"                        # These are directly used in creating the Pods that execute the Job.\n" +
+	"                        limits:\n" +
+	"                            \"\": \"\"\n" +
+	"                        # Requests are resource requests applied to an individual step in the job.\n" +
+	"                        # These are directly used in creating the Pods that execute the Job.\n" +
+	"                        requests:\n" +
+	"                            \"\": \"\"\n" +
 	"                  # Timeout is how long the we will wait before aborting a job with SIGINT.\n" +
+	"                  # The pod of the step is terminated if it still runs once the timeout,\n" +
+	"                  # the grace period and some time to start the pod and upload artifacts\n" +
+	"                  # have passed.\n" +
 	"                  timeout: 0s\n" +
+	"                  # Upgrade, when set, makes ci-operator upgrade the cluster of the test\n" +
+	"                  # itself instead of running commands in a pod. The step then needs no\n" +
+	"                  # `from` or `commands` and its timeout bounds the whole upgrade.\n" +
+	"                  upgrade:\n" +
+	"                    # From is the name of the release the cluster must run before the\n" +
+	"                    # upgrade, e.g. initial.\n" +
+	"                    from: ' '\n" +
+	"                    # To is the name of the release the cluster is upgraded to, e.g. latest.\n" +
+	"                    to: ' '\n" +
+	"                  # Workspace is a volume backed by a PersistentVolumeClaim that is\n" +
+	"                  # provisioned for this step, for scratch space larger than the node's\n" +
+	"                  # ephemeral storage allows.\n" +
+	"                  workspace:\n" +
+	"                    # MountPath is where the volume is mounted, /workspace by default.\n" +
+	"                    # The path is exposed to the step as $WORKSPACE_DIR.\n" +
+	"                    mount_path: ' '\n" +
+	"                    # Size is the requested capacity of the volume, e.g. 200Gi.\n" +
+	"                    size: ' '\n" +
+	"                    # StorageClass is the storage class used to provision the volume. The\n" +
+	"                    # default storage class of the cluster is used if unset.\n" +
+	"                    storage_class: ' '\n" +
+	"        # Matrix expands the test into one test for every combination of the\n" +
+	"        # values of its parameters. Every ${{parameter}} in the test is replaced\n" +
+	"        # with the value of the parameter; when the name of the test does not\n" +
+	"        # reference any, the values are appended to it instead.\n" +
+	"        matrix:\n" +
+	"            \"\": null\n" +
 	"        openshift_ansible:\n" +
 	"            cluster_profile: ' '\n" +
 	"        openshift_ansible_custom:\n" +
@@ -648,14 +1400,100 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"            # all previous `pre` and `test` steps were successful. The given step must explicitly\n" +
 	"            # ask for being skipped by setting the OptionalOnSuccess flag to true.\n" +
 	"            allow_skip_on_success: false\n" +
+	"            # ClusterClaim claims a cluster from a Hive pool before the steps run\n" +
+	"            # and releases it when they finish.\n" +
+	"            cluster_claim:\n" +
+	"                # Namespace is the namespace of the ClusterPool on the Hive cluster.\n" +
+	"                namespace: ' '\n" +
+	"                # Pool is the name of the ClusterPool the cluster is claimed from.\n" +
+	"                pool: ' '\n" +
+	"                # Retries is how many times an unhealthy cluster is released and another\n" +
+	"                # one is claimed. Defaults to two.\n" +
+	"                retries: 0\n" +
 	"            # ClusterProfile defines the profile/cloud provider for end-to-end test steps.\n" +
 	"            cluster_profile: ' '\n" +
+	"            # ClusterProfileOverlay adds secrets, config maps and parameters to the\n" +
+	"            # cluster profile of the test.\n" +
+	"            cluster_profile_overlay:\n" +
+	"                # ConfigMaps are copied into the cluster profile after the secrets, with\n" +
+	"                # the same precedence.\n" +
+	"                config_maps:\n" +
+	"                    - # Name is the name of the source object.\n" +
+	"                      name: ' '\n" +
+	"                      # Namespace is where the source object lives.\n" +
+	"                      namespace: ' '\n" +
+	"                # Environment has additional parameters exposed to all steps.\n" +
+	"                env:\n" +
+	"                    \"\": \"\"\n" +
+	"                # Secrets are copied into the cluster profile. Their keys override keys\n" +
+	"                # of the profile and of secrets earlier in the list.\n" +
+	"                secrets:\n" +
+	"                    - # Name is the name of the source object.\n" +
+	"                      name: ' '\n" +
+	"                      # Namespace is where the source object lives.\n" +
+	"                      namespace: ' '\n" +
+	"            # ClusterProvisioning installs a short-lived cluster with Hive before\n" +
+	"            # the steps run and deprovisions it when they finish.\n" +
+	"            cluster_provisioning:\n" +
+	"                # BaseDomain is the DNS zone of the cloud account of the cluster profile\n" +
+	"                # the cluster is installed under, e.g. origin-ci-int-aws.dev.rhcloud.com.\n" +
+	"                base_domain: ' '\n" +
+	"                # Region is the region of the cloud the cluster is installed in.\n" +
+	"                region: ' '\n" +
+	"                # Version is the name of the ClusterImageSet on the Hive cluster with the\n" +
+	"                # release to install, e.g. openshift-v4.9.0.\n" +
+	"                version: ' '\n" +
+	"            # Comparison runs the test twice, once for a baseline and once for a\n" +
+	"            # candidate, and records a combined comparison of both runs.\n" +
+	"            comparison:\n" +
+	"                # Baseline holds the overrides for the reference run.\n" +
+	"                baseline:\n" +
+	"                    # Dependencies overrides the images used for dependency parameters,\n" +
+	"                    # for example to run the test against a different release payload.\n" +
+	"                    dependencies:\n" +
+	"                        \"\": \"\"\n" +
+	"                    # Environment overrides the values of parameters for the steps.\n" +
+	"                    env:\n" +
+	"                        \"\": \"\"\n" +
+	"                # Candidate holds the overrides for the run being evaluated.\n" +
+	"                candidate:\n" +
+	"                    # Dependencies overrides the images used for dependency parameters,\n" +
+	"                    # for example to run the test against a different release payload.\n" +
+	"                    dependencies:\n" +
+	"                        \"\": \"\"\n" +
+	"                    # Environment overrides the values of parameters for the steps.\n" +
+	"                    env:\n" +
+	"                        \"\": \"\"\n" +
+	"            # DataDir provisions a volume which is mounted in all steps as $DATA_DIR,\n" +
+	"            # for data that is too large to be handed off in $SHARED_DIR.\n" +
+	"            data_dir:\n" +
+	"                # Size is the requested capacity of the volume, e.g. 200Gi.\n" +
+	"                size: ' '\n" +
+	"                # StorageClass is the storage class used to provision the volume. The\n" +
+	"                # default storage class of the cluster is used if unset.\n" +
+	"                storage_class: ' '\n" +
 	"            # Dependencies holds override values for dependency parameters.\n" +
 	"            dependencies:\n" +
 	"                \"\": \"\"\n" +
+	"            # DisruptionRetries is how many times a pod of the test is re-created\n" +
+	"            # when the infrastructure terminates it before it completes, e.g. when\n" +
+	"            # it is evicted or its node is lost. Defaults to one; zero disables it.\n" +
+	"            disruption_retries: 0\n" +
 	"            # Environment has the values of parameters for the steps.\n" +
 	"            env:\n" +
 	"                \"\": \"\"\n" +
+	"            # Gather configures the built-in steps collecting data from the cluster\n" +
+	"            # of the test, which run first in the post phase of tests using a\n" +
+	"            # cluster profile.\n" +
+	"            gather:\n" +
+	"                # MaxSize is the maximum size of the data collected by every step, e.g.\n" +
+	"                # 500Mi. The largest files are removed until the data fits.\n" +
+	"                max_size: ' '\n" +
+	"                # Steps are the built-in steps to run, all of them if unset.\n" +
+	"                steps:\n" +
+	"                    - \"\"\n" +
+	"                # Timeout is how long every step may collect data before it is stopped.\n" +
+	"                timeout: 0s\n" +
 	"            # Leases lists resources that should be acquired for the test.\n" +
 	"            leases:\n" +
 	"                - # Env is the environment variable that will contain the resource name.\n" +
@@ -675,7 +1513,21 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"            # execution if previous Pre and Test steps passed.\n" +
 	"            post:\n" +
 	"                # LiteralTestStep is a full test step definition.\n" +
-	"                - as: ' '\n" +
+	"                - artifacts_to_registry:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    artifact_type: ' '\n" +
+	"                    credentials:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        mount_path: ' '\n" +
+	"                        name: ' '\n" +
+	"                        namespace: ' '\n" +
+	"                        vault_path: ' '\n" +
+	"                    files:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                    repository: ' '\n" +
+	"                    tag: ' '\n" +
+	"                  as: ' '\n" +
 	"                  best_effort: false\n" +
 	"                  # Chain is the name of a step chain reference.\n" +
 	"                  chain: \"\"\n" +
@@ -688,6 +1540,7 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    - mount_path: ' '\n" +
 	"                      name: ' '\n" +
 	"                      namespace: ' '\n" +
+	"                      vault_path: ' '\n" +
 	"                  dependencies:\n" +
 	"                    # LiteralTestStep is a full test step definition.\n" +
 	"                    - env: ' '\n" +
@@ -697,6 +1550,15 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    - default: \"\"\n" +
 	"                      documentation: ' '\n" +
 	"                      name: ' '\n" +
+	"                      type: ' '\n" +
+	"                      values:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                  external_dependencies:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - digest: ' '\n" +
+	"                      env: ' '\n" +
+	"                      pull_spec: ' '\n" +
 	"                  from: ' '\n" +
 	"                  from_image:\n" +
 	"                    # LiteralTestStep is a full test step definition.\n" +
@@ -705,6 +1567,7 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    namespace: ' '\n" +
 	"                    tag: ' '\n" +
 	"                  grace_period: 0s\n" +
+	"                  if: ' '\n" +
 	"                  leases:\n" +
 	"                    # LiteralTestStep is a full test step definition.\n" +
 	"                    - env: ' '\n" +
@@ -724,11 +1587,67 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    requests:\n" +
 	"                        # LiteralTestStep is a full test step definition.\n" +
 	"                        \"\": \"\"\n" +
+	"                  retries:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    count: 0\n" +
+	"                    until: 0s\n" +
+	"                  service_account:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    rules:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - api_groups:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            - \"\"\n" +
+	"                          resource_names:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            - \"\"\n" +
+	"                          resources:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            - \"\"\n" +
+	"                          verbs:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            - \"\"\n" +
+	"                  sidecars:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - commands: ' '\n" +
+	"                      from: ' '\n" +
+	"                      name: ' '\n" +
+	"                      resources:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        limits:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            \"\": \"\"\n" +
+	"                        requests:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            \"\": \"\"\n" +
 	"                  timeout: 0s\n" +
+	"                  upgrade:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    from: ' '\n" +
+	"                    to: ' '\n" +
+	"                  workspace:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    mount_path: ' '\n" +
+	"                    size: ' '\n" +
+	"                    storage_class: ' '\n" +
 	"            # Pre is the array of test steps run to set up the environment for the test.\n" +
 	"            pre:\n" +
 	"                # LiteralTestStep is a full test step definition.\n" +
-	"                - as: ' '\n" +
+	"                - artifacts_to_registry:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    artifact_type: ' '\n" +
+	"                    credentials:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        mount_path: ' '\n" +
+	"                        name: ' '\n" +
+	"                        namespace: ' '\n" +
+	"                        vault_path: ' '\n" +
+	"                    files:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                    repository: ' '\n" +
+	"                    tag: ' '\n" +
+	"                  as: ' '\n" +
 	"                  best_effort: false\n" +
 	"                  # Chain is the name of a step chain reference.\n" +
 	"                  chain: \"\"\n" +
@@ -741,6 +1660,7 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    - mount_path: ' '\n" +
 	"                      name: ' '\n" +
 	"                      namespace: ' '\n" +
+	"                      vault_path: ' '\n" +
 	"                  dependencies:\n" +
 	"                    # LiteralTestStep is a full test step definition.\n" +
 	"                    - env: ' '\n" +
@@ -750,6 +1670,15 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    - default: \"\"\n" +
 	"                      documentation: ' '\n" +
 	"                      name: ' '\n" +
+	"                      type: ' '\n" +
+	"                      values:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                  external_dependencies:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - digest: ' '\n" +
+	"                      env: ' '\n" +
+	"                      pull_spec: ' '\n" +
 	"                  from: ' '\n" +
 	"                  from_image:\n" +
 	"                    # LiteralTestStep is a full test step definition.\n" +
@@ -758,6 +1687,7 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    namespace: ' '\n" +
 	"                    tag: ' '\n" +
 	"                  grace_period: 0s\n" +
+	"                  if: ' '\n" +
 	"                  leases:\n" +
 	"                    # LiteralTestStep is a full test step definition.\n" +
 	"                    - env: ' '\n" +
@@ -777,11 +1707,74 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    requests:\n" +
 	"                        # LiteralTestStep is a full test step definition.\n" +
 	"                        \"\": \"\"\n" +
+	"                  retries:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    count: 0\n" +
+	"                    until: 0s\n" +
+	"                  service_account:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    rules:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - api_groups:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            - \"\"\n" +
+	"                          resource_names:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            - \"\"\n" +
+	"                          resources:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            - \"\"\n" +
+	"                          verbs:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            - \"\"\n" +
+	"                  sidecars:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - commands: ' '\n" +
+	"                      from: ' '\n" +
+	"                      name: ' '\n" +
+	"                      resources:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        limits:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            \"\": \"\"\n" +
+	"                        requests:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            \"\": \"\"\n" +
 	"                  timeout: 0s\n" +
+	"                  upgrade:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    from: ' '\n" +
+	"                    to: ' '\n" +
+	"                  workspace:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    mount_path: ' '\n" +
+	"                    size: ' '\n" +
+	"                    storage_class: ' '\n" +
+	"            # SharedDir configures the volume backing $SHARED_DIR in steps.\n" +
+	"            shared_dir:\n" +
+	"                # Medium backs the volume, either `disk` (the default) or `memory`.\n" +
+	"                medium: ' '\n" +
+	"                # SizeLimit is the maximum size of the volume, e.g. 100Mi. Steps which\n" +
+	"                # write more than that are evicted.\n" +
+	"                size_limit: ' '\n" +
 	"            # Test is the array of test steps that define the actual test.\n" +
 	"            test:\n" +
 	"                # LiteralTestStep is a full test step definition.\n" +
-	"                - as: ' '\n" +
+	"                - artifacts_to_registry:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    artifact_type: ' '\n" +
+	"                    credentials:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        mount_path: ' '\n" +
+	"                        name: ' '\n" +
+	"                        namespace: ' '\n" +
+	"                        vault_path: ' '\n" +
+	"                    files:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                    repository: ' '\n" +
+	"                    tag: ' '\n" +
+	"                  as: ' '\n" +
 	"                  best_effort: false\n" +
 	"                  # Chain is the name of a step chain reference.\n" +
 	"                  chain: \"\"\n" +
@@ -794,6 +1787,7 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    - mount_path: ' '\n" +
 	"                      name: ' '\n" +
 	"                      namespace: ' '\n" +
+	"                      vault_path: ' '\n" +
 	"                  dependencies:\n" +
 	"                    # LiteralTestStep is a full test step definition.\n" +
 	"                    - env: ' '\n" +
@@ -803,6 +1797,15 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    - default: \"\"\n" +
 	"                      documentation: ' '\n" +
 	"                      name: ' '\n" +
+	"                      type: ' '\n" +
+	"                      values:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                  external_dependencies:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - digest: ' '\n" +
+	"                      env: ' '\n" +
+	"                      pull_spec: ' '\n" +
 	"                  from: ' '\n" +
 	"                  from_image:\n" +
 	"                    # LiteralTestStep is a full test step definition.\n" +
@@ -811,6 +1814,7 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    namespace: ' '\n" +
 	"                    tag: ' '\n" +
 	"                  grace_period: 0s\n" +
+	"                  if: ' '\n" +
 	"                  leases:\n" +
 	"                    # LiteralTestStep is a full test step definition.\n" +
 	"                    - env: ' '\n" +
@@ -830,7 +1834,49 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                    requests:\n" +
 	"                        # LiteralTestStep is a full test step definition.\n" +
 	"                        \"\": \"\"\n" +
+	"                  retries:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    count: 0\n" +
+	"                    until: 0s\n" +
+	"                  service_account:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    rules:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - api_groups:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            - \"\"\n" +
+	"                          resource_names:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            - \"\"\n" +
+	"                          resources:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            - \"\"\n" +
+	"                          verbs:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            - \"\"\n" +
+	"                  sidecars:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - commands: ' '\n" +
+	"                      from: ' '\n" +
+	"                      name: ' '\n" +
+	"                      resources:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        limits:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            \"\": \"\"\n" +
+	"                        requests:\n" +
+	"                            # LiteralTestStep is a full test step definition.\n" +
+	"                            \"\": \"\"\n" +
 	"                  timeout: 0s\n" +
+	"                  upgrade:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    from: ' '\n" +
+	"                    to: ' '\n" +
+	"                  workspace:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    mount_path: ' '\n" +
+	"                    size: ' '\n" +
+	"                    storage_class: ' '\n" +
 	"            # Workflow is the name of the workflow to be used for this configuration. For fields defined in both\n" +
 	"            # the config and the workflow, the fields from the config will override what is set in Workflow.\n" +
 	"            workflow: \"\"\n" +
@@ -842,6 +1888,22 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"# they result in the same output.\n" +
 	"releases:\n" +
 	"    \"\":\n" +
+	"        # Assembled describes a payload assembled by the job from a subset of\n" +
+	"        # the images of another release\n" +
+	"        assembled:\n" +
+	"            # Exclude is a regular expression matching the tags of the release which\n" +
+	"            # are left out of the payload.\n" +
+	"            exclude: ' '\n" +
+	"            # From is the name of the release whose images are assembled into the\n" +
+	"            # payload, `latest` by default.\n" +
+	"            from: ' '\n" +
+	"            # Include is a regular expression matching the tags of the release which\n" +
+	"            # are part of the payload. All tags are included if unset.\n" +
+	"            include: ' '\n" +
+	"            # Overrides maps tags of the payload to the images built by the job which\n" +
+	"            # replace them, e.g. `machine-config-operator: machine-config-operator`.\n" +
+	"            overrides:\n" +
+	"                \"\": \"\"\n" +
 	"        # Candidate describes a candidate release payload\n" +
 	"        candidate:\n" +
 	"            # Architecture is the architecture for the product.\n" +
@@ -873,6 +1935,10 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"            channel: ' '\n" +
 	"            # Version is the minor version to search for\n" +
 	"            version: ' '\n" +
+	"            # VersionRange is a semantic version range, e.g. `>=4.12.0 <4.14.0`, to\n" +
+	"            # search for the newest release in instead of the minor version. Every\n" +
+	"            # alternative of the range needs an upper bound.\n" +
+	"            version_range: ' '\n" +
 	"# Resources is a set of resource requests or limits over the\n" +
 	"# input types. The special name '*' may be used to set default\n" +
 	"# requests and limits.\n" +
@@ -891,6 +1957,36 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"# unset, this will default under the repository root to\n" +
 	"# _output/local/releases/rpms/.\n" +
 	"rpm_build_location: ' '\n" +
+	"# SignedBaseImages are the base images whose signatures are\n" +
+	"# verified before they are used, keyed by their alias. Signatures\n" +
+	"# made with a key are verified with the public key ci-operator is\n" +
+	"# given.\n" +
+	"signed_base_images:\n" +
+	"    \"\":\n" +
+	"        # FulcioURL is the Fulcio instance issuing certificates for\n" +
+	"        # keyless signing. Defaults to the public instance.\n" +
+	"        fulcio_url: ' '\n" +
+	"        # OIDCIssuer is the issuer of the identity tokens of the\n" +
+	"        # promotion pod, which keyless signatures are verified\n" +
+	"        # against. Required for keyless signing.\n" +
+	"        oidc_issuer: ' '\n" +
+	"        # RekorURL is the transparency log signatures are recorded\n" +
+	"        # in. Keyless signatures are always recorded, by default in\n" +
+	"        # the public instance, while signatures made with a key are\n" +
+	"        # only recorded if this is set.\n" +
+	"        rekor_url: ' '\n" +
+	"# StatusContexts are GitHub commit statuses posted in addition to the\n" +
+	"# status of the job, each reporting whether a milestone of the job was\n" +
+	"# reached, so merges can be gated on them.\n" +
+	"status_contexts:\n" +
+	"    - # Context is the name of the status, e.g. images-built.\n" +
+	"      context: ' '\n" +
+	"      # Description is shown with the status once the milestone is reached.\n" +
+	"      description: ' '\n" +
+	"      # Steps are the names of the steps of the job that need to succeed for\n" +
+	"      # the milestone to be reached, e.g. [images] or the name of a test.\n" +
+	"      steps:\n" +
+	"        - \"\"\n" +
 	"# ReleaseTagConfiguration determines how the\n" +
 	"# full release is assembled.\n" +
 	"tag_specification:\n" +
@@ -942,14 +2038,109 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"        # all previous `pre` and `test` steps were successful. The given step must explicitly\n" +
 	"        # ask for being skipped by setting the OptionalOnSuccess flag to true.\n" +
 	"        allow_skip_on_success: false\n" +
+	"        # ClusterClaim claims a cluster from a Hive pool before the steps run\n" +
+	"        # and releases it when they finish.\n" +
+	"        cluster_claim:\n" +
+	"            # Namespace is the namespace of the ClusterPool on the Hive cluster.\n" +
+	"            namespace: ' '\n" +
+	"            # Pool is the name of the ClusterPool the cluster is claimed from.\n" +
+	"            pool: ' '\n" +
+	"            # Retries is how many times an unhealthy cluster is released and another\n" +
+	"            # one is claimed. Defaults to two.\n" +
+	"            retries: 0\n" +
 	"        # ClusterProfile defines the profile/cloud provider for end-to-end test steps.\n" +
 	"        cluster_profile: ' '\n" +
+	"        # ClusterProfileOverlay adds secrets, config maps and parameters to the\n" +
+	"        # cluster profile of the test.\n" +
+	"        cluster_profile_overlay:\n" +
+	"            # ConfigMaps are copied into the cluster profile after the secrets, with\n" +
+	"            # the same precedence.\n" +
+	"            config_maps:\n" +
+	"                - # Name is the name of the source object.\n" +
+	"                  name: ' '\n" +
+	"                  # Namespace is where the source object lives.\n" +
+	"                  namespace: ' '\n" +
+	"            # Environment has additional parameters exposed to all steps.\n" +
+	"            env:\n" +
+	"                \"\": \"\"\n" +
+	"            # Secrets are copied into the cluster profile. Their keys override keys\n" +
+	"            # of the profile and of secrets earlier in the list.\n" +
+	"            secrets:\n" +
+	"                - # Name is the name of the source object.\n" +
+	"                  name: ' '\n" +
+	"                  # Namespace is where the source object lives.\n" +
+	"                  namespace: ' '\n" +
+	"        # ClusterProvisioning installs a short-lived cluster with Hive before\n" +
+	"        # the steps run and deprovisions it when they finish.\n" +
+	"        cluster_provisioning:\n" +
+	"            # BaseDomain is the DNS zone of the cloud account of the cluster profile\n" +
+	"            # the cluster is installed under, e.g. origin-ci-int-aws.dev.rhcloud.com.\n" +
+	"            base_domain: ' '\n" +
+	"            # Region is the region of the cloud the cluster is installed in.\n" +
+	"            region: ' '\n" +
+	"            # Version is the name of the ClusterImageSet on the Hive cluster with the\n" +
+	"            # release to install, e.g. openshift-v4.9.0.\n" +
+	"            version: ' '\n" +
+	"        # Comparison runs the test twice, once for a baseline and once for a\n" +
+	"        # candidate, and records a combined comparison of both runs.\n" +
+	"        comparison:\n" +
+	"            # Baseline holds the overrides for the reference run.\n" +
+	"            baseline:\n" +
+	"                # Dependencies overrides the images used for dependency parameters,\n" +
+	"                # for example to run the test against a different release payload.\n" +
+	"                dependencies:\n" +
+	"                    \"\": \"\"\n" +
+	"                # Environment overrides the values of parameters for the steps.\n" +
+	"                env:\n" +
+	"                    \"\": \"\"\n" +
+	"            # Candidate holds the overrides for the run being evaluated.\n" +
+	"            candidate:\n" +
+	"                # Dependencies overrides the images used for dependency parameters,\n" +
+	"                # for example to run the test against a different release payload.\n" +
+	"                dependencies:\n" +
+	"                    \"\": \"\"\n" +
+	"                # Environment overrides the values of parameters for the steps.\n" +
+	"                env:\n" +
+	"                    \"\": \"\"\n" +
+	"        # DataDir provisions a volume which is mounted in all steps as $DATA_DIR,\n" +
+	"        # for data that is too large to be handed off in $SHARED_DIR.\n" +
+	"        data_dir:\n" +
+	"            # Size is the requested capacity of the volume, e.g. 200Gi.\n" +
+	"            size: ' '\n" +
+	"            # StorageClass is the storage class used to provision the volume. The\n" +
+	"            # default storage class of the cluster is used if unset.\n" +
+	"            storage_class: ' '\n" +
 	"        # Dependencies holds override values for dependency parameters.\n" +
 	"        dependencies:\n" +
 	"            \"\": \"\"\n" +
+	"        # Deprecations holds the notices of the deprecated registry components\n" +
+	"        # the test was resolved from, keyed like `chain/ipi-install`. It is set\n" +
+	"        # by the resolver so that the job can report them.\n" +
+	"        deprecations:\n" +
+	"            \"\":\n" +
+	"                # Message explains why the component is deprecated.\n" +
+	"                message: ' '\n" +
+	"                # Replacement names the component that should be used instead, if any.\n" +
+	"                replacement: ' '\n" +
+	"        # DisruptionRetries is how many times a pod of the test is re-created\n" +
+	"        # when the infrastructure terminates it before it completes, e.g. when\n" +
+	"        # it is evicted or its node is lost. Defaults to one; zero disables it.\n" +
+	"        disruption_retries: 0\n" +
 	"        # Environment has the values of parameters for the steps.\n" +
 	"        env:\n" +
 	"            \"\": \"\"\n" +
+	"        # Gather configures the built-in steps collecting data from the cluster\n" +
+	"        # of the test, which run first in the post phase of tests using a\n" +
+	"        # cluster profile.\n" +
+	"        gather:\n" +
+	"            # MaxSize is the maximum size of the data collected by every step, e.g.\n" +
+	"            # 500Mi. The largest files are removed until the data fits.\n" +
+	"            max_size: ' '\n" +
+	"            # Steps are the built-in steps to run, all of them if unset.\n" +
+	"            steps:\n" +
+	"                - \"\"\n" +
+	"            # Timeout is how long every step may collect data before it is stopped.\n" +
+	"            timeout: 0s\n" +
 	"        # Leases lists resources that should be acquired for the test.\n" +
 	"        leases:\n" +
 	"            - # Env is the environment variable that will contain the resource name.\n" +
@@ -974,12 +2165,43 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"        # Post is the array of test steps run after the tests finish and teardown/deprovision resources.\n" +
 	"        # Post steps always run, even if previous steps fail.\n" +
 	"        post:\n" +
-	"            - # As is the name of the LiteralTestStep.\n" +
+	"            - # ArtifactsToRegistry, when set, publishes files from the artifacts of\n" +
+	"              # the step as an OCI artifact once the step succeeds.\n" +
+	"              artifacts_to_registry:\n" +
+	"                # ArtifactType is the media type of the artifact.\n" +
+	"                artifact_type: ' '\n" +
+	"                # Credentials is the secret of the repository's owners holding the\n" +
+	"                # .dockerconfigjson which may push to the repository, referenced by\n" +
+	"                # namespace and name or by its path in Vault. The mount path is chosen\n" +
+	"                # by ci-operator and must not be set.\n" +
+	"                credentials:\n" +
+	"                    # MountPath is where the secret should be mounted.\n" +
+	"                    mount_path: ' '\n" +
+	"                    # Names is which source secret to mount.\n" +
+	"                    name: ' '\n" +
+	"                    # Namespace is where the source secret exists.\n" +
+	"                    namespace: ' '\n" +
+	"                    # VaultPath is the path of a key-value secret in Vault to mount instead\n" +
+	"                    # of a secret from a namespace. The secret is read when the test starts\n" +
+	"                    # and deleted from the test namespace when it finishes.\n" +
+	"                    vault_path: ' '\n" +
+	"                # Files are the paths of the files in the artifact, relative to the\n" +
+	"                # artifacts directory of the step. Glob patterns are allowed.\n" +
+	"                files:\n" +
+	"                    - \"\"\n" +
+	"                # Repository is the repository the artifact is pushed to, e.g.\n" +
+	"                # quay.io/org/bundles.\n" +
+	"                repository: ' '\n" +
+	"                # Tag is the tag of the artifact. Defaults to the names of the test and\n" +
+	"                # the step and the build ID of the job.\n" +
+	"                tag: ' '\n" +
+	"              # As is the name of the LiteralTestStep.\n" +
 	"              as: ' '\n" +
 	"              # BestEffort defines if this step should cause the job to fail when the\n" +
-	"              # step fails. This only applies when AllowBestEffortPostSteps flag is set\n" +
-	"              # to true in MultiStageTestConfiguration. This option is applicable to\n" +
-	"              # `post` steps.\n" +
+	"              # step fails. The failure of a best-effort step is still reported, but\n" +
+	"              # the following steps run as if it succeeded. For `post` steps, this only\n" +
+	"              # applies when AllowBestEffortPostSteps flag is set to true in\n" +
+	"              # MultiStageTestConfiguration.\n" +
 	"              best_effort: false\n" +
 	"              # Cli is the (optional) name of the release from which the `oc` binary\n" +
 	"              # will be injected into this step.\n" +
@@ -994,6 +2216,10 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                  name: ' '\n" +
 	"                  # Namespace is where the source secret exists.\n" +
 	"                  namespace: ' '\n" +
+	"                  # VaultPath is the path of a key-value secret in Vault to mount instead\n" +
+	"                  # of a secret from a namespace. The secret is read when the test starts\n" +
+	"                  # and deleted from the test namespace when it finishes.\n" +
+	"                  vault_path: ' '\n" +
 	"              # Dependencies lists images which must be available before the test runs\n" +
 	"              # and the environment variables which are used to expose their pull specs.\n" +
 	"              dependencies:\n" +
@@ -1009,6 +2235,21 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                  documentation: ' '\n" +
 	"                  # Name of the environment variable.\n" +
 	"                  name: ' '\n" +
+	"                  # Type is the type of the values of the parameter, a string if unset.\n" +
+	"                  type: ' '\n" +
+	"                  # Values are the values allowed for a parameter of the enum type.\n" +
+	"                  values:\n" +
+	"                    - \"\"\n" +
+	"              # ExternalDependencies lists images from outside of the CI system, pinned\n" +
+	"              # to a digest, which are imported before the test runs and exposed with\n" +
+	"              # environment variables like Dependencies.\n" +
+	"              external_dependencies:\n" +
+	"                - # Digest is the digest the image is expected to have, e.g. sha256:...\n" +
+	"                  digest: ' '\n" +
+	"                  # Env is the environment variable that the image's pull spec is exposed with\n" +
+	"                  env: ' '\n" +
+	"                  # PullSpec is the full pull spec of the image, e.g. quay.io/org/image:tag\n" +
+	"                  pull_spec: ' '\n" +
 	"              # From is the container image that will be used for this step.\n" +
 	"              from: ' '\n" +
 	"              # FromImage is a literal ImageStreamTag reference to use for this step.\n" +
@@ -1021,6 +2262,12 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"              # GracePeriod is how long the we will wait after sending SIGINT to send\n" +
 	"              # SIGKILL when aborting a Step.\n" +
 	"              grace_period: 0s\n" +
+	"              # If is an expression which determines whether the step runs, comparing\n" +
+	"              # parameters of the step and CLUSTER_TYPE to values, for example\n" +
+	"              # `CLUSTER_TYPE == aws || CLUSTER_TYPE == gcp && FIPS_ENABLED != true`.\n" +
+	"              # Values containing spaces or operators need to be quoted. The step is\n" +
+	"              # skipped when the expression does not hold.\n" +
+	"              if: ' '\n" +
 	"              # Leases lists resources that should be acquired for the test.\n" +
 	"              leases:\n" +
 	"                - # Env is the environment variable that will contain the resource name.\n" +
@@ -1045,16 +2292,119 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                # These are directly used in creating the Pods that execute the Job.\n" +
 	"                requests:\n" +
 	"                    \"\": \"\"\n" +
+	"              # Retries, when set, re-runs the step in a new pod when it fails. The\n" +
+	"              # artifacts of each attempt are stored in a directory named after it.\n" +
+	"              retries:\n" +
+	"                # Count is how many times the step is re-run after it fails.\n" +
+	"                count: 0\n" +
+	"                # Until limits how long after the first attempt started new attempts\n" +
+	"                # may start, so retries do not push the test past its timeout. New\n" +
+	"                # attempts are only limited by Count when unset.\n" +
+	"                until: 0s\n" +
+	"              # ServiceAccount, when set, runs the step with a service account of its\n" +
+	"              # own that is only granted the declared rules, instead of the one shared\n" +
+	"              # by all steps of the test, which can view everything in the namespace.\n" +
+	"              service_account:\n" +
+	"                # Rules are the permissions granted to the step in the test namespace.\n" +
+	"                rules:\n" +
+	"                    - # APIGroups are the groups of the resources, \"\" for the core group.\n" +
+	"                      api_groups:\n" +
+	"                        - \"\"\n" +
+	"                      # ResourceNames optionally restricts the rule to named objects.\n" +
+	"                      resource_names:\n" +
+	"                        - \"\"\n" +
+	"                      # Resources are the resources the rule applies to.\n" +
+	"                      resources:\n" +
+	"                        - \"\"\n" +
+	"                      # Verbs are the operations allowed on the resources.\n" +
+	"                      verbs:\n" +
+	"                        - \"\"\n" +
+	"              # Sidecars are containers that run next to the step's container, e.g.\n" +
+	"              # to provide a database the test connects to over localhost. They are\n" +
+	"              # terminated when the step's commands finish.\n" +
+	"              sidecars:\n" +
+	"                - # Commands is the shell script that starts the service.\n" +
+	"                  commands: ' '\n" +
+	"                  # From is the container image that will be used for the sidecar, with\n" +
+	"                  # the same semantics as the `from` field of a step.\n" +
+	"                  from: ' '\n" +
+	"                  # Name is the name of the sidecar container.\n" +
+	"                  name: ' '\n" +
+	"                  # Resources defines the resource requirements for the sidecar.\n" +
+	"                  resources:\n" +
+	"                    # Limits are resource limits applied to an individual step in the job.\n" +
+	"                    # These are directly used in creating the Pods that execute the Job.\n" +
+	"                    limits:\n" +
+	"                        \"\": \"\"\n" +
+	"                    # Requests are resource requests applied to an individual step in the job.\n" +
+	"                    # These are directly used in creating the Pods that execute the Job.\n" +
+	"                    requests:\n" +
+	"                        \"\": \"\"\n" +
 	"              # Timeout is how long the we will wait before aborting a job with SIGINT.\n" +
+	"              # The pod of the step is terminated if it still runs once the timeout,\n" +
+	"              # the grace period and some time to start the pod and upload artifacts\n" +
+	"              # have passed.\n" +
 	"              timeout: 0s\n" +
+	"              # Upgrade, when set, makes ci-operator upgrade the cluster of the test\n" +
+	"              # itself instead of running commands in a pod. The step then needs no\n" +
+	"              # `from` or `commands` and its timeout bounds the whole upgrade.\n" +
+	"              upgrade:\n" +
+	"                # From is the name of the release the cluster must run before the\n" +
+	"                # upgrade, e.g. initial.\n" +
+	"                from: ' '\n" +
+	"                # To is the name of the release the cluster is upgraded to, e.g. latest.\n" +
+	"                to: ' '\n" +
+	"              # Workspace is a volume backed by a PersistentVolumeClaim that is\n" +
+	"              # provisioned for this step, for scratch space larger than the node's\n" +
+	"              # ephemeral storage allows.\n" +
+	"              workspace:\n" +
+	"                # MountPath is where the volume is mounted, /workspace by default.\n" +
+	"                # The path is exposed to the step as $WORKSPACE_DIR.\n" +
+	"                mount_path: ' '\n" +
+	"                # Size is the requested capacity of the volume, e.g. 200Gi.\n" +
+	"                size: ' '\n" +
+	"                # StorageClass is the storage class used to provision the volume. The\n" +
+	"                # default storage class of the cluster is used if unset.\n" +
+	"                storage_class: ' '\n" +
 	"        # Pre is the array of test steps run to set up the environment for the test.\n" +
 	"        pre:\n" +
-	"            - # As is the name of the LiteralTestStep.\n" +
+	"            - # ArtifactsToRegistry, when set, publishes files from the artifacts of\n" +
+	"              # the step as an OCI artifact once the step succeeds.\n" +
+	"              artifacts_to_registry:\n" +
+	"                # ArtifactType is the media type of the artifact.\n" +
+	"                artifact_type: ' '\n" +
+	"                # Credentials is the secret of the repository's owners holding the\n" +
+	"                # .dockerconfigjson which may push to the repository, referenced by\n" +
+	"                # namespace and name or by its path in Vault. The mount path is chosen\n" +
+	"                # by ci-operator and must not be set.\n" +
+	"                credentials:\n" +
+	"                    # MountPath is where the secret should be mounted.\n" +
+	"                    mount_path: ' '\n" +
+	"                    # Names is which source secret to mount.\n" +
+	"                    name: ' '\n" +
+	"                    # Namespace is where the source secret exists.\n" +
+	"                    namespace: ' '\n" +
+	"                    # VaultPath is the path of a key-value secret in Vault to mount instead\n" +
+	"                    # of a secret from a namespace. The secret is read when the test starts\n" +
+	"                    # and deleted from the test namespace when it finishes.\n" +
+	"                    vault_path: ' '\n" +
+	"                # Files are the paths of the files in the artifact, relative to the\n" +
+	"                # artifacts directory of the step. Glob patterns are allowed.\n" +
+	"                files:\n" +
+	"                    - \"\"\n" +
+	"                # Repository is the repository the artifact is pushed to, e.g.\n" +
+	"                # quay.io/org/bundles.\n" +
+	"                repository: ' '\n" +
+	"                # Tag is the tag of the artifact. Defaults to the names of the test and\n" +
+	"                # the step and the build ID of the job.\n" +
+	"                tag: ' '\n" +
+	"              # As is the name of the LiteralTestStep.\n" +
 	"              as: ' '\n" +
 	"              # BestEffort defines if this step should cause the job to fail when the\n" +
-	"              # step fails. This only applies when AllowBestEffortPostSteps flag is set\n" +
-	"              # to true in MultiStageTestConfiguration. This option is applicable to\n" +
-	"              # `post` steps.\n" +
+	"              # step fails. The failure of a best-effort step is still reported, but\n" +
+	"              # the following steps run as if it succeeded. For `post` steps, this only\n" +
+	"              # applies when AllowBestEffortPostSteps flag is set to true in\n" +
+	"              # MultiStageTestConfiguration.\n" +
 	"              best_effort: false\n" +
 	"              # Cli is the (optional) name of the release from which the `oc` binary\n" +
 	"              # will be injected into this step.\n" +
@@ -1069,6 +2419,10 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                  name: ' '\n" +
 	"                  # Namespace is where the source secret exists.\n" +
 	"                  namespace: ' '\n" +
+	"                  # VaultPath is the path of a key-value secret in Vault to mount instead\n" +
+	"                  # of a secret from a namespace. The secret is read when the test starts\n" +
+	"                  # and deleted from the test namespace when it finishes.\n" +
+	"                  vault_path: ' '\n" +
 	"              # Dependencies lists images which must be available before the test runs\n" +
 	"              # and the environment variables which are used to expose their pull specs.\n" +
 	"              dependencies:\n" +
@@ -1084,6 +2438,21 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                  documentation: ' '\n" +
 	"                  # Name of the environment variable.\n" +
 	"                  name: ' '\n" +
+	"                  # Type is the type of the values of the parameter, a string if unset.\n" +
+	"                  type: ' '\n" +
+	"                  # Values are the values allowed for a parameter of the enum type.\n" +
+	"                  values:\n" +
+	"                    - \"\"\n" +
+	"              # ExternalDependencies lists images from outside of the CI system, pinned\n" +
+	"              # to a digest, which are imported before the test runs and exposed with\n" +
+	"              # environment variables like Dependencies.\n" +
+	"              external_dependencies:\n" +
+	"                - # Digest is the digest the image is expected to have, e.g. sha256:...\n" +
+	"                  digest: ' '\n" +
+	"                  # Env is the environment variable that the image's pull spec is exposed with\n" +
+	"                  env: ' '\n" +
+	"                  # PullSpec is the full pull spec of the image, e.g. quay.io/org/image:tag\n" +
+	"                  pull_spec: ' '\n" +
 	"              # From is the container image that will be used for this step.\n" +
 	"              from: ' '\n" +
 	"              # FromImage is a literal ImageStreamTag reference to use for this step.\n" +
@@ -1096,6 +2465,12 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"              # GracePeriod is how long the we will wait after sending SIGINT to send\n" +
 	"              # SIGKILL when aborting a Step.\n" +
 	"              grace_period: 0s\n" +
+	"              # If is an expression which determines whether the step runs, comparing\n" +
+	"              # parameters of the step and CLUSTER_TYPE to values, for example\n" +
+	"              # `CLUSTER_TYPE == aws || CLUSTER_TYPE == gcp && FIPS_ENABLED != true`.\n" +
+	"              # Values containing spaces or operators need to be quoted. The step is\n" +
+	"              # skipped when the expression does not hold.\n" +
+	"              if: ' '\n" +
 	"              # Leases lists resources that should be acquired for the test.\n" +
 	"              leases:\n" +
 	"                - # Env is the environment variable that will contain the resource name.\n" +
@@ -1120,16 +2495,126 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                # These are directly used in creating the Pods that execute the Job.\n" +
 	"                requests:\n" +
 	"                    \"\": \"\"\n" +
+	"              # Retries, when set, re-runs the step in a new pod when it fails. The\n" +
+	"              # artifacts of each attempt are stored in a directory named after it.\n" +
+	"              retries:\n" +
+	"                # Count is how many times the step is re-run after it fails.\n" +
+	"                count: 0\n" +
+	"                # Until limits how long after the first attempt started new attempts\n" +
+	"                # may start, so retries do not push the test past its timeout. New\n" +
+	"                # attempts are only limited by Count when unset.\n" +
+	"                until: 0s\n" +
+	"              # ServiceAccount, when set, runs the step with a service account of its\n" +
+	"              # own that is only granted the declared rules, instead of the one shared\n" +
+	"              # by all steps of the test, which can view everything in the namespace.\n" +
+	"              service_account:\n" +
+	"                # Rules are the permissions granted to the step in the test namespace.\n" +
+	"                rules:\n" +
+	"                    - # APIGroups are the groups of the resources, \"\" for the core group.\n" +
+	"                      api_groups:\n" +
+	"                        - \"\"\n" +
+	"                      # ResourceNames optionally restricts the rule to named objects.\n" +
+	"                      resource_names:\n" +
+	"                        - \"\"\n" +
+	"                      # Resources are the resources the rule applies to.\n" +
+	"                      resources:\n" +
+	"                        - \"\"\n" +
+	"                      # Verbs are the operations allowed on the resources.\n" +
+	"                      verbs:\n" +
+	"                        - \"\"\n" +
+	"              # Sidecars are containers that run next to the step's container, e.g.\n" +
+	"              # to provide a database the test connects to over localhost. They are\n" +
+	"              # terminated when the step's commands finish.\n" +
+	"              sidecars:\n" +
+	"                - # Commands is the shell script that starts the service.\n" +
+	"                  commands: ' '\n" +
+	"                  # From is the container image that will be used for the sidecar, with\n" +
+	"                  # the same semantics as the `from` field of a step.\n" +
+	"                  from: ' '\n" +
+	"                  # Name is the name of the sidecar container.\n" +
+	"                  name: ' '\n" +
+	"                  # Resources defines the resource requirements for the sidecar.\n" +
+	"                  resources:\n" +
+	"                    # Limits are resource limits applied to an individual step in the job.\n" +
+	"                    # These are directly used in creating the Pods that execute the Job.\n" +
+	"                    limits:\n" +
+	"                        \"\": \"\"\n" +
+	"                    # Requests are resource requests applied to an individual step in the job.\n" +
+	"                    # These are directly used in creating the Pods that execute the Job.\n" +
+	"                    requests:\n" +
+	"                        \"\": \"\"\n" +
 	"              # Timeout is how long the we will wait before aborting a job with SIGINT.\n" +
+	"              # The pod of the step is terminated if it still runs once the timeout,\n" +
+	"              # the grace period and some time to start the pod and upload artifacts\n" +
+	"              # have passed.\n" +
 	"              timeout: 0s\n" +
+	"              # Upgrade, when set, makes ci-operator upgrade the cluster of the test\n" +
+	"              # itself instead of running commands in a pod. The step then needs no\n" +
+	"              # `from` or `commands` and its timeout bounds the whole upgrade.\n" +
+	"              upgrade:\n" +
+	"                # From is the name of the release the cluster must run before the\n" +
+	"                # upgrade, e.g. initial.\n" +
+	"                from: ' '\n" +
+	"                # To is the name of the release the cluster is upgraded to, e.g. latest.\n" +
+	"                to: ' '\n" +
+	"              # Workspace is a volume backed by a PersistentVolumeClaim that is\n" +
+	"              # provisioned for this step, for scratch space larger than the node's\n" +
+	"              # ephemeral storage allows.\n" +
+	"              workspace:\n" +
+	"                # MountPath is where the volume is mounted, /workspace by default.\n" +
+	"                # The path is exposed to the step as $WORKSPACE_DIR.\n" +
+	"                mount_path: ' '\n" +
+	"                # Size is the requested capacity of the volume, e.g. 200Gi.\n" +
+	"                size: ' '\n" +
+	"                # StorageClass is the storage class used to provision the volume. The\n" +
+	"                # default storage class of the cluster is used if unset.\n" +
+	"                storage_class: ' '\n" +
+	"        # SharedDir configures the volume backing $SHARED_DIR in steps.\n" +
+	"        shared_dir:\n" +
+	"            # Medium backs the volume, either `disk` (the default) or `memory`.\n" +
+	"            medium: ' '\n" +
+	"            # SizeLimit is the maximum size of the volume, e.g. 100Mi. Steps which\n" +
+	"            # write more than that are evicted.\n" +
+	"            size_limit: ' '\n" +
 	"        # Test is the array of test steps that define the actual test.\n" +
 	"        test:\n" +
-	"            - # As is the name of the LiteralTestStep.\n" +
+	"            - # ArtifactsToRegistry, when set, publishes files from the artifacts of\n" +
+	"              # the step as an OCI artifact once the step succeeds.\n" +
+	"              artifacts_to_registry:\n" +
+	"                # ArtifactType is the media type of the artifact.\n" +
+	"                artifact_type: ' '\n" +
+	"                # Credentials is the secret of the repository's owners holding the\n" +
+	"                # .dockerconfigjson which may push to the repository, referenced by\n" +
+	"                # namespace and name or by its path in Vault. The mount path is chosen\n" +
+	"                # by ci-operator and must not be set.\n" +
+	"                credentials:\n" +
+	"                    # MountPath is where the secret should be mounted.\n" +
+	"                    mount_path: ' '\n" +
+	"                    # Names is which source secret to mount.\n" +
+	"                    name: ' '\n" +
+	"                    # Namespace is where the source secret exists.\n" +
+	"                    namespace: ' '\n" +
+	"                    # VaultPath is the path of a key-value secret in Vault to mount instead\n" +
+	"                    # of a secret from a namespace. The secret is read when the test starts\n" +
+	"                    # and deleted from the test namespace when it finishes.\n" +
+	"                    vault_path: ' '\n" +
+	"                # Files are the paths of the files in the artifact, relative to the\n" +
+	"                # artifacts directory of the step. Glob patterns are allowed.\n" +
+	"                files:\n" +
+	"                    - \"\"\n" +
+	"                # Repository is the repository the artifact is pushed to, e.g.\n" +
+	"                # quay.io/org/bundles.\n" +
+	"                repository: ' '\n" +
+	"                # Tag is the tag of the artifact. Defaults to the names of the test and\n" +
+	"                # the step and the build ID of the job.\n" +
+	"                tag: ' '\n" +
+	"              # As is the name of the LiteralTestStep.\n" +
 	"              as: ' '\n" +
 	"              # BestEffort defines if this step should cause the job to fail when the\n" +
-	"              # step fails. This only applies when AllowBestEffortPostSteps flag is set\n" +
-	"              # to true in MultiStageTestConfiguration. This option is applicable to\n" +
-	"              # `post` steps.\n" +
+	"              # step fails. The failure of a best-effort step is still reported, but\n" +
+	"              # the following steps run as if it succeeded. For `post` steps, this only\n" +
+	"              # applies when AllowBestEffortPostSteps flag is set to true in\n" +
+	"              # MultiStageTestConfiguration.\n" +
 	"              best_effort: false\n" +
 	"              # Cli is the (optional) name of the release from which the `oc` binary\n" +
 	"              # will be injected into this step.\n" +
@@ -1144,6 +2629,10 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                  name: ' '\n" +
 	"                  # Namespace is where the source secret exists.\n" +
 	"                  namespace: ' '\n" +
+	"                  # VaultPath is the path of a key-value secret in Vault to mount instead\n" +
+	"                  # of a secret from a namespace. The secret is read when the test starts\n" +
+	"                  # and deleted from the test namespace when it finishes.\n" +
+	"                  vault_path: ' '\n" +
 	"              # Dependencies lists images which must be available before the test runs\n" +
 	"              # and the environment variables which are used to expose their pull specs.\n" +
 	"              dependencies:\n" +
@@ -1159,6 +2648,21 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                  documentation: ' '\n" +
 	"                  # Name of the environment variable.\n" +
 	"                  name: ' '\n" +
+	"                  # Type is the type of the values of the parameter, a string if unset.\n" +
+	"                  type: ' '\n" +
+	"                  # Values are the values allowed for a parameter of the enum type.\n" +
+	"                  values:\n" +
+	"                    - \"\"\n" +
+	"              # ExternalDependencies lists images from outside of the CI system, pinned\n" +
+	"              # to a digest, which are imported before the test runs and exposed with\n" +
+	"              # environment variables like Dependencies.\n" +
+	"              external_dependencies:\n" +
+	"                - # Digest is the digest the image is expected to have, e.g. sha256:...\n" +
+	"                  digest: ' '\n" +
+	"                  # Env is the environment variable that the image's pull spec is exposed with\n" +
+	"                  env: ' '\n" +
+	"                  # PullSpec is the full pull spec of the image, e.g. quay.io/org/image:tag\n" +
+	"                  pull_spec: ' '\n" +
 	"              # From is the container image that will be used for this step.\n" +
 	"              from: ' '\n" +
 	"              # FromImage is a literal ImageStreamTag reference to use for this step.\n" +
@@ -1171,6 +2675,12 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"              # GracePeriod is how long the we will wait after sending SIGINT to send\n" +
 	"              # SIGKILL when aborting a Step.\n" +
 	"              grace_period: 0s\n" +
+	"              # If is an expression which determines whether the step runs, comparing\n" +
+	"              # parameters of the step and CLUSTER_TYPE to values, for example\n" +
+	"              # `CLUSTER_TYPE == aws || CLUSTER_TYPE == gcp && FIPS_ENABLED != true`.\n" +
+	"              # Values containing spaces or operators need to be quoted. The step is\n" +
+	"              # skipped when the expression does not hold.\n" +
+	"              if: ' '\n" +
 	"              # Leases lists resources that should be acquired for the test.\n" +
 	"              leases:\n" +
 	"                - # Env is the environment variable that will contain the resource name.\n" +
@@ -1195,8 +2705,86 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                # These are directly used in creating the Pods that execute the Job.\n" +
 	"                requests:\n" +
 	"                    \"\": \"\"\n" +
+	"              # Retries, when set, re-runs the step in a new pod when it fails. The\n" +
+	"              # artifacts of each attempt are stored in a directory named after it.\n" +
+	"              retries:\n" +
+	"                # Count is how many times the step is re-run after it fails.\n" +
+	"                count: 0\n" +
+	"                # Until limits how long after the first attempt started new attempts\n" +
+	"                # may start, so retries do not push the test past its timeout. New\n" +
+	"                # attempts are only limited by Count when unset.\n" +
+	"                until: 0s\n" +
+	"              # ServiceAccount, when set, runs the step with a service account of its\n" +
+	"              # own that is only granted the declared rules, instead of the one shared\n" +
+	"              # by all steps of the test, which can view everything in the namespace.\n" +
+	"              service_account:\n" +
+	"                # Rules are the permissions granted to the step in the test namespace.\n" +
+	"                rules:\n" +
+	"                    - # APIGroups are the groups of the resources, \"\" for the core group.\n" +
+	"                      api_groups:\n" +
+	"                        - \"\"\n" +
+	"                      # ResourceNames optionally restricts the rule to named objects.\n" +
+	"                      resource_names:\n" +
+	"                        - \"\"\n" +
+	"                      # Resources are the resources the rule applies to.\n" +
+	"                      resources:\n" +
+	"                        - \"\"\n" +
+	"                      # Verbs are the operations allowed on the resources.\n" +
+	"                      verbs:\n" +
+	"                        - \"\"\n" +
+	"              # Sidecars are containers that run next to the step's container, e.g.\n" +
+	"              # to provide a database the test connects to over localhost. They are\n" +
+	"              # terminated when the step's commands finish.\n" +
+	"              sidecars:\n" +
+	"                - # Commands is the shell script that starts the service.\n" +
+	"                  commands: ' '\n" +
+	"                  # From is the container image that will be used for the sidecar, with\n" +
+	"                  # the same semantics as the `from` field of a step.\n" +
+	"                  from: ' '\n" +
+	"                  # Name is the name of the sidecar container.\n" +
+	"                  name: ' '\n" +
+	"                  # Resources defines the resource requirements for the sidecar.\n" +
+	"                  resources:\n" +
+	"                    # Limits are resource limits applied to an individual step in the job.\n" +
+	"                    # These are directly used in creating the Pods that execute the Job.\n" +
+	"                    limits:\n" +
+	"                        \"\": \"\"\n" +
+	"                    # Requests are resource requests applied to an individual step in the job.\n" +
+	"                    # These are directly used in creating the Pods that execute the Job.\n" +
+	"                    requests:\n" +
+	"                        \"\": \"\"\n" +
 	"              # Timeout is how long the we will wait before aborting a job with SIGINT.\n" +
+	"              # The pod of the step is terminated if it still runs once the timeout,\n" +
+	"              # the grace period and some time to start the pod and upload artifacts\n" +
+	"              # have passed.\n" +
 	"              timeout: 0s\n" +
+	"              # Upgrade, when set, makes ci-operator upgrade the cluster of the test\n" +
+	"              # itself instead of running commands in a pod. The step then needs no\n" +
+	"              # `from` or `commands` and its timeout bounds the whole upgrade.\n" +
+	"              upgrade:\n" +
+	"                # From is the name of the release the cluster must run before the\n" +
+	"                # upgrade, e.g. initial.\n" +
+	"                from: ' '\n" +
+	"                # To is the name of the release the cluster is upgraded to, e.g. latest.\n" +
+	"                to: ' '\n" +
+	"              # Workspace is a volume backed by a PersistentVolumeClaim that is\n" +
+	"              # provisioned for this step, for scratch space larger than the node's\n" +
+	"              # ephemeral storage allows.\n" +
+	"              workspace:\n" +
+	"                # MountPath is where the volume is mounted, /workspace by default.\n" +
+	"                # The path is exposed to the step as $WORKSPACE_DIR.\n" +
+	"                mount_path: ' '\n" +
+	"                # Size is the requested capacity of the volume, e.g. 200Gi.\n" +
+	"                size: ' '\n" +
+	"                # StorageClass is the storage class used to provision the volume. The\n" +
+	"                # default storage class of the cluster is used if unset.\n" +
+	"                storage_class: ' '\n" +
+	"      # Matrix expands the test into one test for every combination of the\n" +
+	"      # values of its parameters. Every ${{parameter}} in the test is replaced\n" +
+	"      # with the value of the parameter; when the name of the test does not\n" +
+	"      # reference any, the values are appended to it instead.\n" +
+	"      matrix:\n" +
+	"        \"\": null\n" +
 	"      openshift_ansible:\n" +
 	"        cluster_profile: ' '\n" +
 	"      openshift_ansible_custom:\n" +
@@ -1243,14 +2831,100 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"        # all previous `pre` and `test` steps were successful. The given step must explicitly\n" +
 	"        # ask for being skipped by setting the OptionalOnSuccess flag to true.\n" +
 	"        allow_skip_on_success: false\n" +
+	"        # ClusterClaim claims a cluster from a Hive pool before the steps run\n" +
+	"        # and releases it when they finish.\n" +
+	"        cluster_claim:\n" +
+	"            # Namespace is the namespace of the ClusterPool on the Hive cluster.\n" +
+	"            namespace: ' '\n" +
+	"            # Pool is the name of the ClusterPool the cluster is claimed from.\n" +
+	"            pool: ' '\n" +
+	"            # Retries is how many times an unhealthy cluster is released and another\n" +
+	"            # one is claimed. Defaults to two.\n" +
+	"            retries: 0\n" +
 	"        # ClusterProfile defines the profile/cloud provider for end-to-end test steps.\n" +
 	"        cluster_profile: ' '\n" +
+	"        # ClusterProfileOverlay adds secrets, config maps and parameters to the\n" +
+	"        # cluster profile of the test.\n" +
+	"        cluster_profile_overlay:\n" +
+	"            # ConfigMaps are copied into the cluster profile after the secrets, with\n" +
+	"            # the same precedence.\n" +
+	"            config_maps:\n" +
+	"                - # Name is the name of the source object.\n" +
+	"                  name: ' '\n" +
+	"                  # Namespace is where the source object lives.\n" +
+	"                  namespace: ' '\n" +
+	"            # Environment has additional parameters exposed to all steps.\n" +
+	"            env:\n" +
+	"                \"\": \"\"\n" +
+	"            # Secrets are copied into the cluster profile. Their keys override keys\n" +
+	"            # of the profile and of secrets earlier in the list.\n" +
+	"            secrets:\n" +
+	"                - # Name is the name of the source object.\n" +
+	"                  name: ' '\n" +
+	"                  # Namespace is where the source object lives.\n" +
+	"                  namespace: ' '\n" +
+	"        # ClusterProvisioning installs a short-lived cluster with Hive before\n" +
+	"        # the steps run and deprovisions it when they finish.\n" +
+	"        cluster_provisioning:\n" +
+	"            # BaseDomain is the DNS zone of the cloud account of the cluster profile\n" +
+	"            # the cluster is installed under, e.g. origin-ci-int-aws.dev.rhcloud.com.\n" +
+	"            base_domain: ' '\n" +
+	"            # Region is the region of the cloud the cluster is installed in.\n" +
+	"            region: ' '\n" +
+	"            # Version is the name of the ClusterImageSet on the Hive cluster with the\n" +
+	"            # release to install, e.g. openshift-v4.9.0.\n" +
+	"            version: ' '\n" +
+	"        # Comparison runs the test twice, once for a baseline and once for a\n" +
+	"        # candidate, and records a combined comparison of both runs.\n" +
+	"        comparison:\n" +
+	"            # Baseline holds the overrides for the reference run.\n" +
+	"            baseline:\n" +
+	"                # Dependencies overrides the images used for dependency parameters,\n" +
+	"                # for example to run the test against a different release payload.\n" +
+	"                dependencies:\n" +
+	"                    \"\": \"\"\n" +
+	"                # Environment overrides the values of parameters for the steps.\n" +
+	"                env:\n" +
+	"                    \"\": \"\"\n" +
+	"            # Candidate holds the overrides for the run being evaluated.\n" +
+	"            candidate:\n" +
+	"                # Dependencies overrides the images used for dependency parameters,\n" +
+	"                # for example to run the test against a different release payload.\n" +
+	"                dependencies:\n" +
+	"                    \"\": \"\"\n" +
+	"                # Environment overrides the values of parameters for the steps.\n" +
+	"                env:\n" +
+	"                    \"\": \"\"\n" +
+	"        # DataDir provisions a volume which is mounted in all steps as $DATA_DIR,\n" +
+	"        # for data that is too large to be handed off in $SHARED_DIR.\n" +
+	"        data_dir:\n" +
+	"            # Size is the requested capacity of the volume, e.g. 200Gi.\n" +
+	"            size: ' '\n" +
+	"            # StorageClass is the storage class used to provision the volume. The\n" +
+	"            # default storage class of the cluster is used if unset.\n" +
+	"            storage_class: ' '\n" +
 	"        # Dependencies holds override values for dependency parameters.\n" +
 	"        dependencies:\n" +
 	"            \"\": \"\"\n" +
+	"        # DisruptionRetries is how many times a pod of the test is re-created\n" +
+	"        # when the infrastructure terminates it before it completes, e.g. when\n" +
+	"        # it is evicted or its node is lost. Defaults to one; zero disables it.\n" +
+	"        disruption_retries: 0\n" +
 	"        # Environment has the values of parameters for the steps.\n" +
 	"        env:\n" +
 	"            \"\": \"\"\n" +
+	"        # Gather configures the built-in steps collecting data from the cluster\n" +
+	"        # of the test, which run first in the post phase of tests using a\n" +
+	"        # cluster profile.\n" +
+	"        gather:\n" +
+	"            # MaxSize is the maximum size of the data collected by every step, e.g.\n" +
+	"            # 500Mi. The largest files are removed until the data fits.\n" +
+	"            max_size: ' '\n" +
+	"            # Steps are the built-in steps to run, all of them if unset.\n" +
+	"            steps:\n" +
+	"                - \"\"\n" +
+	"            # Timeout is how long every step may collect data before it is stopped.\n" +
+	"            timeout: 0s\n" +
 	"        # Leases lists resources that should be acquired for the test.\n" +
 	"        leases:\n" +
 	"            - # Env is the environment variable that will contain the resource name.\n" +
@@ -1270,7 +2944,21 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"        # execution if previous Pre and Test steps passed.\n" +
 	"        post:\n" +
 	"            # LiteralTestStep is a full test step definition.\n" +
-	"            - as: ' '\n" +
+	"            - artifacts_to_registry:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                artifact_type: ' '\n" +
+	"                credentials:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    mount_path: ' '\n" +
+	"                    name: ' '\n" +
+	"                    namespace: ' '\n" +
+	"                    vault_path: ' '\n" +
+	"                files:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - \"\"\n" +
+	"                repository: ' '\n" +
+	"                tag: ' '\n" +
+	"              as: ' '\n" +
 	"              best_effort: false\n" +
 	"              # Chain is the name of a step chain reference.\n" +
 	"              chain: \"\"\n" +
@@ -1283,6 +2971,7 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                - mount_path: ' '\n" +
 	"                  name: ' '\n" +
 	"                  namespace: ' '\n" +
+	"                  vault_path: ' '\n" +
 	"              dependencies:\n" +
 	"                # LiteralTestStep is a full test step definition.\n" +
 	"                - env: ' '\n" +
@@ -1292,6 +2981,15 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                - default: \"\"\n" +
 	"                  documentation: ' '\n" +
 	"                  name: ' '\n" +
+	"                  type: ' '\n" +
+	"                  values:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - \"\"\n" +
+	"              external_dependencies:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                - digest: ' '\n" +
+	"                  env: ' '\n" +
+	"                  pull_spec: ' '\n" +
 	"              from: ' '\n" +
 	"              from_image:\n" +
 	"                # LiteralTestStep is a full test step definition.\n" +
@@ -1300,6 +2998,7 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                namespace: ' '\n" +
 	"                tag: ' '\n" +
 	"              grace_period: 0s\n" +
+	"              if: ' '\n" +
 	"              leases:\n" +
 	"                # LiteralTestStep is a full test step definition.\n" +
 	"                - env: ' '\n" +
@@ -1319,11 +3018,67 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                requests:\n" +
 	"                    # LiteralTestStep is a full test step definition.\n" +
 	"                    \"\": \"\"\n" +
+	"              retries:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                count: 0\n" +
+	"                until: 0s\n" +
+	"              service_account:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                rules:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - api_groups:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                      resource_names:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                      resources:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                      verbs:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"              sidecars:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                - commands: ' '\n" +
+	"                  from: ' '\n" +
+	"                  name: ' '\n" +
+	"                  resources:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    limits:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        \"\": \"\"\n" +
+	"                    requests:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        \"\": \"\"\n" +
 	"              timeout: 0s\n" +
+	"              upgrade:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                from: ' '\n" +
+	"                to: ' '\n" +
+	"              workspace:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                mount_path: ' '\n" +
+	"                size: ' '\n" +
+	"                storage_class: ' '\n" +
 	"        # Pre is the array of test steps run to set up the environment for the test.\n" +
 	"        pre:\n" +
 	"            # LiteralTestStep is a full test step definition.\n" +
-	"            - as: ' '\n" +
+	"            - artifacts_to_registry:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                artifact_type: ' '\n" +
+	"                credentials:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    mount_path: ' '\n" +
+	"                    name: ' '\n" +
+	"                    namespace: ' '\n" +
+	"                    vault_path: ' '\n" +
+	"                files:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - \"\"\n" +
+	"                repository: ' '\n" +
+	"                tag: ' '\n" +
+	"              as: ' '\n" +
 	"              best_effort: false\n" +
 	"              # Chain is the name of a step chain reference.\n" +
 	"              chain: \"\"\n" +
@@ -1336,6 +3091,7 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                - mount_path: ' '\n" +
 	"                  name: ' '\n" +
 	"                  namespace: ' '\n" +
+	"                  vault_path: ' '\n" +
 	"              dependencies:\n" +
 	"                # LiteralTestStep is a full test step definition.\n" +
 	"                - env: ' '\n" +
@@ -1345,6 +3101,15 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                - default: \"\"\n" +
 	"                  documentation: ' '\n" +
 	"                  name: ' '\n" +
+	"                  type: ' '\n" +
+	"                  values:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - \"\"\n" +
+	"              external_dependencies:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                - digest: ' '\n" +
+	"                  env: ' '\n" +
+	"                  pull_spec: ' '\n" +
 	"              from: ' '\n" +
 	"              from_image:\n" +
 	"                # LiteralTestStep is a full test step definition.\n" +
@@ -1353,6 +3118,7 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                namespace: ' '\n" +
 	"                tag: ' '\n" +
 	"              grace_period: 0s\n" +
+	"              if: ' '\n" +
 	"              leases:\n" +
 	"                # LiteralTestStep is a full test step definition.\n" +
 	"                - env: ' '\n" +
@@ -1372,11 +3138,74 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                requests:\n" +
 	"                    # LiteralTestStep is a full test step definition.\n" +
 	"                    \"\": \"\"\n" +
+	"              retries:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                count: 0\n" +
+	"                until: 0s\n" +
+	"              service_account:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                rules:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - api_groups:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                      resource_names:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                      resources:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                      verbs:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"              sidecars:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                - commands: ' '\n" +
+	"                  from: ' '\n" +
+	"                  name: ' '\n" +
+	"                  resources:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    limits:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        \"\": \"\"\n" +
+	"                    requests:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        \"\": \"\"\n" +
 	"              timeout: 0s\n" +
+	"              upgrade:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                from: ' '\n" +
+	"                to: ' '\n" +
+	"              workspace:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                mount_path: ' '\n" +
+	"                size: ' '\n" +
+	"                storage_class: ' '\n" +
+	"        # SharedDir configures the volume backing $SHARED_DIR in steps.\n" +
+	"        shared_dir:\n" +
+	"            # Medium backs the volume, either `disk` (the default) or `memory`.\n" +
+	"            medium: ' '\n" +
+	"            # SizeLimit is the maximum size of the volume, e.g. 100Mi. Steps which\n" +
+	"            # write more than that are evicted.\n" +
+	"            size_limit: ' '\n" +
 	"        # Test is the array of test steps that define the actual test.\n" +
 	"        test:\n" +
 	"            # LiteralTestStep is a full test step definition.\n" +
-	"            - as: ' '\n" +
+	"            - artifacts_to_registry:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                artifact_type: ' '\n" +
+	"                credentials:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    mount_path: ' '\n" +
+	"                    name: ' '\n" +
+	"                    namespace: ' '\n" +
+	"                    vault_path: ' '\n" +
+	"                files:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - \"\"\n" +
+	"                repository: ' '\n" +
+	"                tag: ' '\n" +
+	"              as: ' '\n" +
 	"              best_effort: false\n" +
 	"              # Chain is the name of a step chain reference.\n" +
 	"              chain: \"\"\n" +
@@ -1389,6 +3218,7 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                - mount_path: ' '\n" +
 	"                  name: ' '\n" +
 	"                  namespace: ' '\n" +
+	"                  vault_path: ' '\n" +
 	"              dependencies:\n" +
 	"                # LiteralTestStep is a full test step definition.\n" +
 	"                - env: ' '\n" +
@@ -1398,6 +3228,15 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                - default: \"\"\n" +
 	"                  documentation: ' '\n" +
 	"                  name: ' '\n" +
+	"                  type: ' '\n" +
+	"                  values:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - \"\"\n" +
+	"              external_dependencies:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                - digest: ' '\n" +
+	"                  env: ' '\n" +
+	"                  pull_spec: ' '\n" +
 	"              from: ' '\n" +
 	"              from_image:\n" +
 	"                # LiteralTestStep is a full test step definition.\n" +
@@ -1406,6 +3245,7 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                namespace: ' '\n" +
 	"                tag: ' '\n" +
 	"              grace_period: 0s\n" +
+	"              if: ' '\n" +
 	"              leases:\n" +
 	"                # LiteralTestStep is a full test step definition.\n" +
 	"                - env: ' '\n" +
@@ -1425,10 +3265,73 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                requests:\n" +
 	"                    # LiteralTestStep is a full test step definition.\n" +
 	"                    \"\": \"\"\n" +
+	"              retries:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                count: 0\n" +
+	"                until: 0s\n" +
+	"              service_account:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                rules:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    - api_groups:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                      resource_names:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                      resources:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"                      verbs:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        - \"\"\n" +
+	"              sidecars:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                - commands: ' '\n" +
+	"                  from: ' '\n" +
+	"                  name: ' '\n" +
+	"                  resources:\n" +
+	"                    # LiteralTestStep is a full test step definition.\n" +
+	"                    limits:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        \"\": \"\"\n" +
+	"                    requests:\n" +
+	"                        # LiteralTestStep is a full test step definition.\n" +
+	"                        \"\": \"\"\n" +
 	"              timeout: 0s\n" +
+	"              upgrade:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                from: ' '\n" +
+	"                to: ' '\n" +
+	"              workspace:\n" +
+	"                # LiteralTestStep is a full test step definition.\n" +
+	"                mount_path: ' '\n" +
+	"                size: ' '\n" +
+	"                storage_class: ' '\n" +
 	"        # Workflow is the name of the workflow to be used for this configuration. For fields defined in both\n" +
 	"        # the config and the workflow, the fields from the config will override what is set in Workflow.\n" +
 	"        workflow: \"\"\n" +
+	"# VulnerabilityScan enables scanning images built by the job\n" +
+	"# for known vulnerabilities.\n" +
+	"vulnerability_scan:\n" +
+	"    # Action is taken when vulnerabilities at or above the\n" +
+	"    # threshold are found: fail fails the job, while annotate only\n" +
+	"    # records them on the image stream. Defaults to fail.\n" +
+	"    action: ' '\n" +
+	"    # ClairURL is the address of the Clair instance. Required with\n" +
+	"    # the clair scanner.\n" +
+	"    clair_url: ' '\n" +
+	"    # Images are the built images which are scanned. Defaults to\n" +
+	"    # all of them.\n" +
+	"    images:\n" +
+	"        - \"\"\n" +
+	"    # Scanner is the scanner used, one of trivy or clair. Defaults\n" +
+	"    # to trivy.\n" +
+	"    scanner: ' '\n" +
+	"    # SeverityThreshold is the lowest severity of the\n" +
+	"    # vulnerabilities acted on, one of LOW, MEDIUM, HIGH or\n" +
+	"    # CRITICAL. Defaults to HIGH.\n" +
+	"    severity_threshold: ' '\n" +
 	"zz_generated_metadata:\n" +
 	"    branch: ' '\n" +
 	"    org: ' '\n" +